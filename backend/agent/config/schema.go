@@ -9,7 +9,8 @@ import (
 )
 
 const (
-	DefaultIntervalMs = 500
+	DefaultIntervalMs    = 500
+	DefaultJVMIntervalMs = 15000
 )
 
 type BootstrapConfig struct {
@@ -20,6 +21,8 @@ type BootstrapConfig struct {
 	CAFile            string         `json:"ca_file"`
 	MonitorConfigPath string         `json:"monitor_config_path,omitempty"`
 	MonitorConfig     *MonitorConfig `json:"monitor_config,omitempty"`
+	// Push streams events to server_addr instead of only serving /state
+	Push bool `json:"push,omitempty"`
 }
 
 type MonitorConfig struct {
@@ -27,6 +30,8 @@ type MonitorConfig struct {
 	Services   []string `json:"services"`
 	Ports      []int    `json:"ports"`
 	IntervalMs int      `json:"interval_ms"`
+	// JVMIntervalMs is how often the Hytale server's JVM is read with jstat
+	JVMIntervalMs int `json:"jvm_interval_ms,omitempty"`
 }
 
 func LoadBootstrap(path string) (*BootstrapConfig, error) {
@@ -77,6 +82,12 @@ func (c *MonitorConfig) Validate() error {
 	if c.IntervalMs < 100 {
 		return errors.New("interval_ms must be >= 100")
 	}
+	if c.JVMIntervalMs == 0 {
+		c.JVMIntervalMs = DefaultJVMIntervalMs
+	}
+	if c.JVMIntervalMs < 1000 {
+		return errors.New("jvm_interval_ms must be >= 1000")
+	}
 	for _, p := range c.Ports {
 		if p <= 0 || p > 65535 {
 			return fmt.Errorf("invalid port: %d", p)
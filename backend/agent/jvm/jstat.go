@@ -0,0 +1,147 @@
+// Package jvm reads heap, garbage collection and thread figures of a running
+// JVM. Heap and GC come from jstat, taken from the JDK the process runs on
+// when it ships one and from PATH otherwise; threads come from /proc.
+package jvm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandTimeout bounds each jstat run
+const commandTimeout = 5 * time.Second
+
+// Metrics is one reading of a JVM. Sizes are bytes and GC times are the
+// seconds spent collecting since the JVM started.
+type Metrics struct {
+	PID            int     `json:"pid"`
+	Timestamp      int64   `json:"timestamp"`
+	HeapUsed       int64   `json:"heap_used"`
+	HeapCommitted  int64   `json:"heap_committed"`
+	HeapMax        int64   `json:"heap_max,omitempty"`
+	MetaspaceUsed  int64   `json:"metaspace_used"`
+	YoungGCCount   int64   `json:"young_gc_count"`
+	YoungGCSeconds float64 `json:"young_gc_seconds"`
+	FullGCCount    int64   `json:"full_gc_count"`
+	FullGCSeconds  float64 `json:"full_gc_seconds"`
+	GCSeconds      float64 `json:"gc_seconds"`
+	Threads        int     `json:"threads"`
+	// Error says why heap and GC figures are missing, e.g. no jstat on the
+	// host or a JVM started with -XX:-UsePerfData
+	Error string `json:"error,omitempty"`
+}
+
+// Collect reads the JVM with the given PID. Threads are reported even when
+// jstat fails, with the failure in Error.
+func Collect(ctx context.Context, pid int) Metrics {
+	m := Metrics{PID: pid, Timestamp: time.Now().Unix(), Threads: readThreads(pid)}
+
+	jstat, err := findJstat(pid)
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	gc, err := run(ctx, jstat, "-gc", pid)
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	m.HeapUsed = kilobytes(gc, "S0U", "S1U", "EU", "OU")
+	m.HeapCommitted = kilobytes(gc, "S0C", "S1C", "EC", "OC")
+	m.MetaspaceUsed = kilobytes(gc, "MU")
+	m.YoungGCCount = int64(gc["YGC"])
+	m.YoungGCSeconds = gc["YGCT"]
+	m.FullGCCount = int64(gc["FGC"])
+	m.FullGCSeconds = gc["FGCT"]
+	m.GCSeconds = gc["GCT"]
+
+	// The maximum heap is the largest the young and old generations may grow
+	if capacity, err := run(ctx, jstat, "-gccapacity", pid); err == nil {
+		m.HeapMax = kilobytes(capacity, "NGCMX", "OGCMX")
+	}
+	return m
+}
+
+// findJstat prefers the jstat next to the process's java binary, which
+// matches its JVM version
+func findJstat(pid int) (string, error) {
+	if exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe")); err == nil {
+		candidate := filepath.Join(filepath.Dir(exe), "jstat")
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	path, err := exec.LookPath("jstat")
+	if err != nil {
+		return "", errors.New("jstat not found; install a JDK on the host")
+	}
+	return path, nil
+}
+
+// run runs one jstat option and returns its columns by header
+func run(ctx context.Context, jstat, option string, pid int) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, jstat, option, strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("jstat %s: %w", option, err)
+	}
+	return parseColumns(string(out))
+}
+
+// parseColumns reads jstat's header line and value line. Columns the
+// collector in use does not have are printed as "-" and left out.
+func parseColumns(output string) (map[string]float64, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("unexpected jstat output %q", output)
+	}
+	headers := strings.Fields(lines[0])
+	values := strings.Fields(lines[len(lines)-1])
+	if len(headers) != len(values) {
+		return nil, fmt.Errorf("jstat printed %d columns for %d headers", len(values), len(headers))
+	}
+	columns := make(map[string]float64, len(headers))
+	for i, header := range headers {
+		value, err := strconv.ParseFloat(values[i], 64)
+		if err != nil {
+			continue
+		}
+		columns[header] = value
+	}
+	return columns, nil
+}
+
+// kilobytes sums jstat columns, which are in KB, into bytes
+func kilobytes(columns map[string]float64, names ...string) int64 {
+	var total float64
+	for _, name := range names {
+		total += columns[name]
+	}
+	return int64(total * 1024)
+}
+
+func readThreads(pid int) int {
+	file, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "Threads:"); ok {
+			threads, _ := strconv.Atoi(strings.TrimSpace(value))
+			return threads
+		}
+	}
+	return 0
+}
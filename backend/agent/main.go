@@ -10,7 +10,10 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/user"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,7 +22,9 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/TheGojiOG/HytaleSM/agent/config"
+	"github.com/TheGojiOG/HytaleSM/agent/jvm"
 	"github.com/TheGojiOG/HytaleSM/agent/ports"
+	"github.com/TheGojiOG/HytaleSM/agent/process"
 	"github.com/TheGojiOG/HytaleSM/agent/systemd"
 	"github.com/TheGojiOG/HytaleSM/agent/transport"
 )
@@ -77,8 +82,6 @@ func main() {
 
 	m := &metrics{}
 
-	_ = boot
-
 	stateWriter := newStateWriter(*statePath)
 	currentState := &agentState{
 		HostUUID: hostID,
@@ -101,6 +104,15 @@ func main() {
 
 	go serveMetrics(*metricsAddr, m)
 	go serveStateTLS(*stateAddr, *stateCert, *stateKey, *stateCA, store)
+
+	events := newPusher(store, m, boot.Push)
+	if boot.Push {
+		client := transport.NewClient(boot.ServerAddr, boot.CertFile, boot.KeyFile, boot.CAFile)
+		go client.Stream(ctx, events.hello, events.out, func(err error) {
+			atomic.AddUint64(&m.streamErrors, 1)
+			log.Printf("event stream to %s: %v", boot.ServerAddr, err)
+		})
+	}
 	var watcherCancel context.CancelFunc
 
 	applyConfig := func(cfg *config.MonitorConfig) {
@@ -118,22 +130,31 @@ func main() {
 					st.Services[ev.Service] = ev.NewState
 					st.Timestamp = ev.Timestamp
 				})
-				atomic.AddUint64(&m.eventsSent, 1)
+				events.event(transport.Event{Kind: transport.EventService, Service: ev.Service, OldState: ev.OldState, NewState: ev.NewState, Timestamp: ev.Timestamp})
 			})
 		}()
 
+		go watchJVM(watchCtx, time.Duration(cfg.JVMIntervalMs)*time.Millisecond, store)
+
+		lastJava := ""
 		go ports.Watch(watchCtx, cfg.Ports, interval, func(pe ports.PortEvent) {
 			store.Update(func(st *agentState) {
 				st.Ports[pe.Port] = pe.Open
 				st.Timestamp = pe.Timestamp
 			})
-			atomic.AddUint64(&m.eventsSent, 1)
+			events.event(transport.Event{Kind: transport.EventPort, Port: pe.Port, Open: pe.Open, Timestamp: pe.Timestamp})
 		}, func(java []ports.JavaProcess) {
+			now := time.Now().Unix()
 			store.Update(func(st *agentState) {
 				st.Java = java
-				st.Timestamp = time.Now().Unix()
+				st.Timestamp = now
 			})
-			atomic.AddUint64(&m.eventsSent, 1)
+			// CPU and memory change on every snapshot; only a process starting,
+			// exiting or changing its ports is worth pushing
+			if key := javaKey(java); key != lastJava {
+				lastJava = key
+				events.event(transport.Event{Kind: transport.EventJava, Timestamp: now})
+			}
 		})
 	}
 
@@ -150,16 +171,104 @@ func main() {
 			store.Update(func(st *agentState) {
 				st.Timestamp = time.Now().Unix()
 			})
+			events.heartbeat()
 		}
 	}
 }
 
+// pusher queues events for the stream to the manager. When pushing is off,
+// events are only counted.
+type pusher struct {
+	enabled bool
+	store   *stateStore
+	metrics *metrics
+	out     chan transport.Message
+}
+
+func newPusher(store *stateStore, m *metrics, enabled bool) *pusher {
+	return &pusher{enabled: enabled, store: store, metrics: m, out: make(chan transport.Message, 256)}
+}
+
+// hello is the first message of every connection, carrying the whole state
+func (p *pusher) hello() transport.Message {
+	return transport.Message{Type: transport.MessageHello, Version: agentVersion, State: p.state(), Timestamp: time.Now().Unix()}
+}
+
+func (p *pusher) event(ev transport.Event) {
+	atomic.AddUint64(&p.metrics.eventsSent, 1)
+	p.send(transport.Message{Type: transport.MessageEvent, Event: &ev, State: p.state(), Timestamp: ev.Timestamp})
+}
+
+// heartbeat keeps the stream alive and the manager's copy of the state fresh
+func (p *pusher) heartbeat() {
+	p.send(transport.Message{Type: transport.MessageHeartbeat, State: p.state(), Timestamp: time.Now().Unix()})
+}
+
+// send queues a message without blocking the watchers. A message dropped
+// while the queue is full is covered by the state the next one carries.
+func (p *pusher) send(msg transport.Message) {
+	if !p.enabled {
+		return
+	}
+	select {
+	case p.out <- msg:
+	default:
+	}
+}
+
+func (p *pusher) state() json.RawMessage {
+	if !p.enabled {
+		return nil
+	}
+	snapshot := p.store.Snapshot()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// watchJVM reads the heap, GC and threads of every Hytale server JVM on the
+// host into the state, on its own interval since jstat is too slow for the
+// port and process watcher's
+func watchJVM(ctx context.Context, interval time.Duration, store *stateStore) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			readings := []jvm.Metrics{}
+			for _, proc := range store.Snapshot().Java {
+				if strings.Contains(proc.Cmdline, "HytaleServer.jar") {
+					readings = append(readings, jvm.Collect(ctx, proc.PID))
+				}
+			}
+			store.Update(func(st *agentState) {
+				st.JVM = readings
+			})
+		}
+	}
+}
+
+// javaKey identifies the Java processes and the ports they listen on
+func javaKey(java []ports.JavaProcess) string {
+	parts := make([]string, 0, len(java))
+	for _, proc := range java {
+		parts = append(parts, fmt.Sprintf("%d:%v", proc.PID, proc.ListenPorts))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
 type agentState struct {
 	HostUUID  string              `json:"host_uuid"`
 	Timestamp int64               `json:"timestamp"`
 	Services  map[string]string   `json:"services"`
 	Ports     map[int]bool        `json:"ports"`
 	Java      []ports.JavaProcess `json:"java"`
+	JVM       []jvm.Metrics       `json:"jvm"`
 }
 
 type stateWriter struct {
@@ -243,6 +352,7 @@ func cloneAgentState(src *agentState) agentState {
 		Services:  make(map[string]string, len(src.Services)),
 		Ports:     make(map[int]bool, len(src.Ports)),
 		Java:      make([]ports.JavaProcess, len(src.Java)),
+		JVM:       make([]jvm.Metrics, len(src.JVM)),
 	}
 	for k, v := range src.Services {
 		clone.Services[k] = v
@@ -251,9 +361,20 @@ func cloneAgentState(src *agentState) agentState {
 		clone.Ports[k] = v
 	}
 	copy(clone.Java, src.Java)
+	copy(clone.JVM, src.JVM)
 	return clone
 }
 
+// agentGroup is the group the agent runs as, which may write to the console
+// of the servers it starts
+func agentGroup() string {
+	group, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	if err != nil {
+		return ""
+	}
+	return group.Name
+}
+
 func readMachineID() (string, error) {
 	paths := []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
 	for _, p := range paths {
@@ -296,6 +417,7 @@ func serveStateTLS(addr, certPath, keyPath, caPath string, store *stateStore) {
 		state := store.Snapshot()
 		_ = json.NewEncoder(w).Encode(state)
 	})
+	process.NewManager(agentGroup()).Register(mux)
 	pool := x509.NewCertPool()
 	if caPath != "" {
 		if data, err := os.ReadFile(caPath); err == nil {
@@ -30,8 +30,20 @@ type JavaProcess struct {
 	StartTicks  uint64 `json:"start_ticks"`
 	Cmdline     string `json:"cmdline"`
 	ListenPorts []int  `json:"listen_ports"`
+	// CPUPercent is the share of all the host's cores used since the
+	// previous snapshot, or over the process's uptime in the first one.
+	// MemoryPercent is the share of the host's memory resident.
+	CPUPercent    float64 `json:"cpu_percent"`
+	RSSBytes      int64   `json:"rss_bytes"`
+	MemoryPercent float64 `json:"memory_percent"`
+	OpenFiles     int     `json:"open_files"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
 }
 
+// javaRefresh is how often an unchanged Java snapshot is sent again, so the
+// CPU and uptime of an idle process keep moving
+const javaRefresh = 10 * time.Second
+
 func Watch(ctx context.Context, ports []int, interval time.Duration, onPortEvent func(PortEvent), onJavaSnapshot func([]JavaProcess)) {
 	if interval <= 0 {
 		interval = 500 * time.Millisecond
@@ -42,7 +54,7 @@ func Watch(ctx context.Context, ports []int, interval time.Duration, onPortEvent
 	}
 
 	lastPorts := make(map[int]bool)
-	var lastJavaHash [32]byte
+	javaWatch := newJavaWatch()
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -63,8 +75,7 @@ func Watch(ctx context.Context, ports []int, interval time.Duration, onPortEvent
 			}
 
 			java := readJavaProcesses()
-			if javaChanged(java, lastJavaHash) {
-				lastJavaHash = hashJava(java)
+			if javaWatch.due(java, time.Now()) {
 				onJavaSnapshot(java)
 			}
 		}
@@ -78,9 +89,34 @@ func Snapshot(ports []int) (map[int]bool, []JavaProcess) {
 		filtered[p] = openPorts[p]
 	}
 	java := readJavaProcesses()
+	newCPUUsage().apply(java, time.Now())
 	return filtered, java
 }
 
+// javaWatch decides which Java snapshots Watch sends
+type javaWatch struct {
+	usage    *cpuUsage
+	lastHash [32]byte
+	lastSent time.Time
+}
+
+func newJavaWatch() *javaWatch {
+	return &javaWatch{usage: newCPUUsage()}
+}
+
+// due fills in the CPU usage of java and reports whether it should be sent:
+// when a process started, exited or changed, or javaRefresh after the last
+// snapshot sent
+func (w *javaWatch) due(java []JavaProcess, now time.Time) bool {
+	w.usage.apply(java, now)
+	if !javaChanged(java, w.lastHash) && now.Sub(w.lastSent) < javaRefresh {
+		return false
+	}
+	w.lastHash = hashJava(java)
+	w.lastSent = now
+	return true
+}
+
 func readListeningPorts() map[int]bool {
 	ports := make(map[int]bool)
 	parseProcNet("/proc/net/tcp", ports)
@@ -128,6 +164,8 @@ func readJavaProcesses() []JavaProcess {
 	java := make([]JavaProcess, 0)
 	inodeMap := readListeningInodes()
 	pidPorts := mapInodesToPids(inodeMap)
+	memTotal := readMemTotal()
+	bootUptime := readUptime()
 
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -148,6 +186,7 @@ func readJavaProcesses() []JavaProcess {
 		proc.Cmdline = cmdline
 		proc.ListenPorts = pidPorts[pid]
 		sort.Ints(proc.ListenPorts)
+		addUsage(&proc, memTotal, bootUptime)
 		java = append(java, proc)
 	}
 
@@ -346,7 +385,7 @@ func javaChanged(java []JavaProcess, lastHash [32]byte) bool {
 func hashJava(java []JavaProcess) [32]byte {
 	var b strings.Builder
 	for _, p := range java {
-		fmt.Fprintf(&b, "%d|%s|%s|%d|%d|%d|%d|%d|%s|%v\n", p.PID, p.User, p.State, p.VSize, p.RSS, p.UTimeTicks, p.STimeTicks, p.StartTicks, p.Cmdline, p.ListenPorts)
+		fmt.Fprintf(&b, "%d|%s|%s|%d|%d|%d|%d|%d|%s|%v|%d\n", p.PID, p.User, p.State, p.VSize, p.RSS, p.UTimeTicks, p.STimeTicks, p.StartTicks, p.Cmdline, p.ListenPorts, p.OpenFiles)
 	}
 	return sha256Sum(b.String())
 }
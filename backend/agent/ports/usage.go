@@ -0,0 +1,129 @@
+package ports
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat. It is
+// 100 on every Linux architecture the agent ships for.
+const clockTicks = 100
+
+// cpuUsage turns the CPU ticks of successive snapshots into a percentage
+type cpuUsage struct {
+	last map[int]cpuSample
+}
+
+type cpuSample struct {
+	ticks uint64
+	start uint64
+	at    time.Time
+}
+
+func newCPUUsage() *cpuUsage {
+	return &cpuUsage{last: make(map[int]cpuSample)}
+}
+
+// apply sets CPUPercent on each process from the ticks it used since the
+// previous snapshot. A process seen for the first time, or a PID reused by a
+// new process, reads its average over its uptime instead.
+func (u *cpuUsage) apply(java []JavaProcess, now time.Time) {
+	seen := make(map[int]cpuSample, len(java))
+	for i := range java {
+		proc := &java[i]
+		sample := cpuSample{ticks: proc.UTimeTicks + proc.STimeTicks, start: proc.StartTicks, at: now}
+		seen[proc.PID] = sample
+		used, elapsed := float64(sample.ticks)/clockTicks, float64(proc.UptimeSeconds)
+		if prev, ok := u.last[proc.PID]; ok && prev.start == sample.start {
+			if sample.ticks < prev.ticks {
+				continue
+			}
+			used, elapsed = float64(sample.ticks-prev.ticks)/clockTicks, now.Sub(prev.at).Seconds()
+		}
+		if elapsed <= 0 {
+			continue
+		}
+		proc.CPUPercent = used / (elapsed * float64(runtime.NumCPU())) * 100
+	}
+	u.last = seen
+}
+
+// addUsage fills in the figures derived from /proc that readProcStat leaves
+// out: resident memory in bytes and as a share of the host's, open files
+// and uptime
+func addUsage(proc *JavaProcess, memTotal int64, bootUptime float64) {
+	proc.RSSBytes = proc.RSS * int64(os.Getpagesize())
+	if memTotal > 0 {
+		proc.MemoryPercent = float64(proc.RSSBytes) / float64(memTotal) * 100
+	}
+	proc.OpenFiles = countOpenFiles(proc.PID)
+	if bootUptime > 0 {
+		if uptime := bootUptime - float64(proc.StartTicks)/clockTicks; uptime > 0 {
+			proc.UptimeSeconds = int64(uptime)
+		}
+	}
+}
+
+func countOpenFiles(pid int) int {
+	fds, err := os.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "fd"))
+	if err != nil {
+		return 0
+	}
+	return len(fds)
+}
+
+// readMemTotal returns the host's memory in bytes
+func readMemTotal() int64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	return parseMemTotal(file)
+}
+
+// parseMemTotal reads MemTotal from /proc/meminfo, or 0 when it is missing
+// or malformed
+func parseMemTotal(r io.Reader) int64 {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil || kb < 0 {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
+
+// readUptime returns the seconds since the host booted
+func readUptime() float64 {
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+	return parseUptime(string(data))
+}
+
+// parseUptime reads the first field of /proc/uptime, or 0 when it is
+// malformed
+func parseUptime(data string) float64 {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		return 0
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || uptime < 0 {
+		return 0
+	}
+	return uptime
+}
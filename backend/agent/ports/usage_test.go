@@ -0,0 +1,208 @@
+package ports
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCPUUsageApply(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	cpus := float64(runtime.NumCPU())
+	tests := []struct {
+		name    string
+		prev    *JavaProcess
+		elapsed time.Duration
+		current JavaProcess
+		want    float64
+	}{
+		{
+			name:    "first sample",
+			elapsed: time.Second,
+			current: JavaProcess{PID: 10, UTimeTicks: 500, StartTicks: 7},
+			want:    0,
+		},
+		{
+			name:    "first sample of a running process",
+			elapsed: time.Second,
+			current: JavaProcess{PID: 10, UTimeTicks: 3000, STimeTicks: 1000, StartTicks: 7, UptimeSeconds: 80},
+			want:    50 / cpus,
+		},
+		{
+			name:    "one core busy",
+			prev:    &JavaProcess{PID: 10, UTimeTicks: 100, STimeTicks: 50, StartTicks: 7},
+			elapsed: 2 * time.Second,
+			current: JavaProcess{PID: 10, UTimeTicks: 250, STimeTicks: 100, StartTicks: 7},
+			want:    100 / cpus,
+		},
+		{
+			name:    "pid reused by a new process",
+			prev:    &JavaProcess{PID: 10, UTimeTicks: 100, StartTicks: 7},
+			elapsed: time.Second,
+			current: JavaProcess{PID: 10, UTimeTicks: 300, StartTicks: 900},
+			want:    0,
+		},
+		{
+			name:    "counter went backwards",
+			prev:    &JavaProcess{PID: 10, UTimeTicks: 1 << 40, StartTicks: 7},
+			elapsed: time.Second,
+			current: JavaProcess{PID: 10, UTimeTicks: 20, StartTicks: 7},
+			want:    0,
+		},
+		{
+			name:    "no time passed",
+			prev:    &JavaProcess{PID: 10, UTimeTicks: 100, StartTicks: 7},
+			elapsed: 0,
+			current: JavaProcess{PID: 10, UTimeTicks: 200, StartTicks: 7},
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage := newCPUUsage()
+			if tt.prev != nil {
+				usage.apply([]JavaProcess{*tt.prev}, start)
+			}
+			java := []JavaProcess{tt.current}
+			usage.apply(java, start.Add(tt.elapsed))
+			if math.Abs(java[0].CPUPercent-tt.want) > 1e-9 {
+				t.Fatalf("expected %.4f%% CPU, got %.4f%%", tt.want, java[0].CPUPercent)
+			}
+			if _, ok := usage.last[tt.current.PID]; !ok {
+				t.Fatal("expected the sample kept for the next snapshot")
+			}
+		})
+	}
+
+	// A process that exited is forgotten
+	usage := newCPUUsage()
+	usage.apply([]JavaProcess{{PID: 10}, {PID: 11}}, start)
+	usage.apply([]JavaProcess{{PID: 11}}, start.Add(time.Second))
+	if _, ok := usage.last[10]; ok {
+		t.Fatal("expected the exited process dropped")
+	}
+}
+
+func TestJavaWatchRefreshesIdleProcess(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	watch := newJavaWatch()
+	sample := func(at time.Duration, ticks uint64) ([]JavaProcess, bool) {
+		java := []JavaProcess{{PID: 10, UTimeTicks: ticks, StartTicks: 7, UptimeSeconds: 100 + int64(at/time.Second)}}
+		return java, watch.due(java, start.Add(at))
+	}
+
+	if _, due := sample(0, 1000); !due {
+		t.Fatal("expected the first snapshot sent")
+	}
+	busy, due := sample(time.Second, 1100)
+	if !due || busy[0].CPUPercent <= 0 {
+		t.Fatalf("expected the busy process sent with its CPU, got %v %.2f%%", due, busy[0].CPUPercent)
+	}
+
+	// Idle from here on: nothing in the snapshot changes but the usage
+	var idle []JavaProcess
+	for at := 2 * time.Second; at <= time.Second+javaRefresh; at += time.Second {
+		java, due := sample(at, 1100)
+		if due != (at == time.Second+javaRefresh) {
+			t.Fatalf("unexpected send decision %v at %v", due, at)
+		}
+		if due {
+			idle = java
+		}
+	}
+	if idle[0].CPUPercent != 0 {
+		t.Fatalf("expected the idle process to report no CPU, got %.2f%%", idle[0].CPUPercent)
+	}
+	if idle[0].UptimeSeconds <= busy[0].UptimeSeconds {
+		t.Fatalf("expected uptime to keep increasing, got %d after %d", idle[0].UptimeSeconds, busy[0].UptimeSeconds)
+	}
+}
+
+func TestAddUsage(t *testing.T) {
+	pageSize := int64(os.Getpagesize())
+	tests := []struct {
+		name       string
+		proc       JavaProcess
+		memTotal   int64
+		bootUptime float64
+		wantMemPct float64
+		wantUptime int64
+	}{
+		{
+			name:       "running for a minute",
+			proc:       JavaProcess{PID: -1, RSS: 256, StartTicks: 4000},
+			memTotal:   1024 * pageSize,
+			bootUptime: 100,
+			wantMemPct: 25,
+			wantUptime: 60,
+		},
+		{
+			name:       "unknown host memory and uptime",
+			proc:       JavaProcess{PID: -1, RSS: 256, StartTicks: 4000},
+			wantMemPct: 0,
+			wantUptime: 0,
+		},
+		{
+			name:       "start after the reported uptime",
+			proc:       JavaProcess{PID: -1, RSS: 1, StartTicks: 50000},
+			memTotal:   pageSize,
+			bootUptime: 100,
+			wantMemPct: 100,
+			wantUptime: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc := tt.proc
+			addUsage(&proc, tt.memTotal, tt.bootUptime)
+			if proc.RSSBytes != tt.proc.RSS*pageSize {
+				t.Fatalf("expected %d RSS bytes, got %d", tt.proc.RSS*pageSize, proc.RSSBytes)
+			}
+			if math.Abs(proc.MemoryPercent-tt.wantMemPct) > 1e-9 {
+				t.Fatalf("expected %.2f%% memory, got %.2f%%", tt.wantMemPct, proc.MemoryPercent)
+			}
+			if proc.UptimeSeconds != tt.wantUptime {
+				t.Fatalf("expected %ds uptime, got %d", tt.wantUptime, proc.UptimeSeconds)
+			}
+			if proc.OpenFiles != 0 {
+				t.Fatalf("expected no open files for a missing process, got %d", proc.OpenFiles)
+			}
+		})
+	}
+}
+
+func TestParseMemTotal(t *testing.T) {
+	tests := map[string]int64{
+		"MemTotal:       16318480 kB\nMemFree:         1234 kB\n": 16318480 * 1024,
+		"MemFree:         1234 kB\nMemTotal: 2048 kB\n":           2048 * 1024,
+		"MemFree:         1234 kB\n":                              0,
+		"MemTotal:\n":                                             0,
+		"MemTotal: lots kB\n":                                     0,
+		"MemTotal: -5 kB\n":                                       0,
+		"":                                                        0,
+	}
+	for input, want := range tests {
+		if got := parseMemTotal(strings.NewReader(input)); got != want {
+			t.Errorf("parseMemTotal(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestParseUptime(t *testing.T) {
+	tests := map[string]float64{
+		"350735.47 234388.90\n": 350735.47,
+		"12":                    12,
+		"":                      0,
+		"   \n":                 0,
+		"soon 1.0":              0,
+		"-4 1.0":                0,
+	}
+	for input, want := range tests {
+		if got := parseUptime(input); got != want {
+			t.Errorf("parseUptime(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
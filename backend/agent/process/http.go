@@ -0,0 +1,79 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Register serves the manager's lifecycle calls on mux, under
+// /servers/{name}. The mux must require client certificates from the
+// manager's CA; those the CA issued to agents are refused.
+func (m *Manager) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /servers/{name}", m.handle(func(r *http.Request, name string) (interface{}, error) {
+		return m.Status(r.Context(), name)
+	}))
+	mux.HandleFunc("POST /servers/{name}/start", m.handle(func(r *http.Request, name string) (interface{}, error) {
+		var opts StartOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			return nil, badRequest{err}
+		}
+		return nil, m.Start(r.Context(), name, opts)
+	}))
+	mux.HandleFunc("POST /servers/{name}/stop", m.handle(func(r *http.Request, name string) (interface{}, error) {
+		return nil, m.Stop(r.Context(), name)
+	}))
+	mux.HandleFunc("POST /servers/{name}/kill", m.handle(func(r *http.Request, name string) (interface{}, error) {
+		return nil, m.Kill(r.Context(), name)
+	}))
+	mux.HandleFunc("POST /servers/{name}/interrupt", m.handle(func(r *http.Request, name string) (interface{}, error) {
+		return nil, m.Interrupt(r.Context(), name)
+	}))
+	mux.HandleFunc("POST /servers/{name}/command", m.handle(func(r *http.Request, name string) (interface{}, error) {
+		var body struct {
+			Command string `json:"command"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, badRequest{err}
+		}
+		return nil, m.SendCommand(r.Context(), name, body.Command)
+	}))
+}
+
+type badRequest struct{ error }
+
+// handle validates the server name and writes the result as JSON
+func (m *Manager) handle(fn func(r *http.Request, name string) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || strings.HasPrefix(r.TLS.PeerCertificates[0].Subject.CommonName, "agent:") {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "only the manager may control servers"})
+			return
+		}
+		name := r.PathValue("name")
+		if !ValidName(name) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid server name"})
+			return
+		}
+		result, err := fn(r, name)
+		var invalid badRequest
+		switch {
+		case errors.As(err, &invalid):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, ErrNotRunning):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		case result == nil:
+			writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
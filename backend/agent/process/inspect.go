@@ -0,0 +1,70 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks is USER_HZ, the unit of process start times in /proc
+const clockTicks = 100
+
+// serverProcess returns the Java process in the unit's cgroup, or the main
+// process when there is none, and how long it has run
+func serverProcess(cgroup string, mainPID int) (int, int64) {
+	pid := mainPID
+	if cgroup != "" {
+		data, _ := os.ReadFile(filepath.Join("/sys/fs/cgroup", cgroup, "cgroup.procs"))
+		for _, field := range strings.Fields(string(data)) {
+			candidate, err := strconv.Atoi(field)
+			if err != nil {
+				continue
+			}
+			comm, _ := os.ReadFile(filepath.Join("/proc", field, "comm"))
+			if strings.TrimSpace(string(comm)) == "java" {
+				pid = candidate
+				break
+			}
+		}
+	}
+	if pid == 0 {
+		return 0, 0
+	}
+	return pid, processUptime(pid)
+}
+
+// processUptime returns the seconds since the process started
+func processUptime(pid int) int64 {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0
+	}
+	// The fields after the parenthesised command start at field 3; the start
+	// time is field 22
+	end := strings.LastIndex(string(stat), ")")
+	if end == -1 {
+		return 0
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 20 {
+		return 0
+	}
+	start, err := strconv.ParseFloat(fields[19], 64)
+	if err != nil {
+		return 0
+	}
+	data, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+	boot := strings.Fields(string(data))
+	if len(boot) == 0 {
+		return 0
+	}
+	uptime, err := strconv.ParseFloat(boot[0], 64)
+	if err != nil || uptime < start/clockTicks {
+		return 0
+	}
+	return int64(uptime - start/clockTicks)
+}
@@ -0,0 +1,229 @@
+// Package process runs game servers for the manager as transient systemd
+// units, so starting, stopping and console input need no SSH session. Each
+// server gets a service unit and a socket unit holding a FIFO on its stdin;
+// both live until the host reboots or the server stops.
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// unitPrefix keeps the agent to the units it created
+	unitPrefix = "hsm-"
+
+	// StopTimeout is how long systemd waits for a stopped server to exit
+	// before killing it
+	StopTimeout = 60 * time.Second
+
+	commandTimeout = 15 * time.Second
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,100}$`)
+	userPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+	// ErrNotRunning is returned for a server whose unit is not active
+	ErrNotRunning = errors.New("server is not running")
+)
+
+// StartOptions describes the process of a server
+type StartOptions struct {
+	// User the server runs as; never root
+	User    string `json:"user"`
+	Command string `json:"command"`
+	// LogFile, when set, receives the console output as well as the journal
+	LogFile string `json:"log_file,omitempty"`
+}
+
+// Status is whether a server's unit is running, its main PID and the PID and
+// uptime of the server process in it
+type Status struct {
+	Running       bool   `json:"running"`
+	State         string `json:"state"`
+	PID           int    `json:"pid,omitempty"`
+	ServerPID     int    `json:"server_pid,omitempty"`
+	UptimeSeconds int64  `json:"uptime_seconds,omitempty"`
+}
+
+// Manager starts and controls server units through systemctl and
+// systemd-run, which the agent's polkit rule allows
+type Manager struct {
+	// group may write to the console FIFOs; the agent's own
+	group string
+}
+
+// NewManager creates a manager. group is the agent's group, given write
+// access to each server's console FIFO.
+func NewManager(group string) *Manager {
+	return &Manager{group: group}
+}
+
+// ValidName reports whether name can identify a server unit
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// Start creates and starts the server's units. A unit left over from a
+// previous run that failed is cleared first.
+func (m *Manager) Start(ctx context.Context, name string, opts StartOptions) error {
+	if !ValidName(name) {
+		return fmt.Errorf("invalid server name %q", name)
+	}
+	if !userPattern.MatchString(opts.User) || opts.User == "root" {
+		return fmt.Errorf("servers must run as an unprivileged user, got %q", opts.User)
+	}
+	if strings.TrimSpace(opts.Command) == "" {
+		return errors.New("command is required")
+	}
+	status, err := m.Status(ctx, name)
+	if err != nil {
+		return err
+	}
+	if status.Running {
+		return fmt.Errorf("server %s is already %s", name, status.State)
+	}
+
+	unit := unitPrefix + name
+	_, _ = m.systemctl(ctx, "stop", unit+".socket")
+	_, _ = m.systemctl(ctx, "reset-failed", unit+".service", unit+".socket")
+
+	inner := opts.Command + " 2>&1"
+	if opts.LogFile != "" {
+		inner += fmt.Sprintf(` | tee -i -a "%s"`, opts.LogFile)
+	}
+	args := []string{
+		"--no-ask-password",
+		"--unit=" + unit,
+		"--description=Hytale server " + name,
+		"--collect",
+		"--socket-property=ListenFIFO=" + fifoPath(unit),
+		"--socket-property=SocketUser=" + opts.User,
+		"--socket-property=SocketMode=0620",
+		"--socket-property=RemoveOnStop=yes",
+	}
+	if m.group != "" {
+		args = append(args, "--socket-property=SocketGroup="+m.group)
+	}
+	args = append(args,
+		"--property=User="+opts.User,
+		"--property=StandardInput=socket",
+		"--property=StandardOutput=journal",
+		"--property=StandardError=journal",
+		"--property=TimeoutStopSec="+strconv.Itoa(int(StopTimeout.Seconds())),
+		"--property=SuccessExitStatus=130 143",
+		"/bin/bash", "-c", inner,
+	)
+	if output, err := run(ctx, "systemd-run", args...); err != nil {
+		return fmt.Errorf("create units: %w (output: %s)", err, output)
+	}
+	// systemd-run only starts the socket; the service would otherwise wait
+	// for the first console command
+	if output, err := m.systemctl(ctx, "start", unit+".service"); err != nil {
+		return fmt.Errorf("start service: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// Stop has systemd stop the server's units without waiting for them: it
+// sends SIGTERM and kills the server once StopTimeout passes
+func (m *Manager) Stop(ctx context.Context, name string) error {
+	unit := unitPrefix + name
+	if output, err := m.systemctl(ctx, "stop", "--no-block", unit+".service", unit+".socket"); err != nil {
+		return fmt.Errorf("stop: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// Kill sends SIGKILL to the server's processes and stops its units
+func (m *Manager) Kill(ctx context.Context, name string) error {
+	unit := unitPrefix + name
+	if output, err := m.systemctl(ctx, "kill", "--signal=SIGKILL", unit+".service"); err != nil {
+		return fmt.Errorf("kill: %w (output: %s)", err, output)
+	}
+	return m.Stop(ctx, name)
+}
+
+// Interrupt sends SIGINT to the server's main process
+func (m *Manager) Interrupt(ctx context.Context, name string) error {
+	unit := unitPrefix + name
+	if output, err := m.systemctl(ctx, "kill", "--signal=SIGINT", "--kill-whom=main", unit+".service"); err != nil {
+		return fmt.Errorf("interrupt: %w (output: %s)", err, output)
+	}
+	return nil
+}
+
+// Status reads the state and main PID of the server's service
+func (m *Manager) Status(ctx context.Context, name string) (Status, error) {
+	output, err := m.systemctl(ctx, "show", "--property=ActiveState", "--property=MainPID", "--property=ControlGroup", unitPrefix+name+".service")
+	if err != nil {
+		return Status{}, fmt.Errorf("status: %w (output: %s)", err, output)
+	}
+	var status Status
+	var cgroup string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "ActiveState":
+			status.State = value
+		case "MainPID":
+			status.PID, _ = strconv.Atoi(value)
+		case "ControlGroup":
+			cgroup = value
+		}
+	}
+	switch status.State {
+	case "active", "activating", "reloading", "deactivating":
+		status.Running = true
+		status.ServerPID, status.UptimeSeconds = serverProcess(cgroup, status.PID)
+	}
+	return status, nil
+}
+
+// SendCommand writes a line to the server's console FIFO
+func (m *Manager) SendCommand(ctx context.Context, name, command string) error {
+	if strings.ContainsAny(command, "\r\n") {
+		return errors.New("command must be a single line")
+	}
+	status, err := m.Status(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !status.Running {
+		return ErrNotRunning
+	}
+	// Non-blocking, so a console nobody reads fails instead of hanging
+	fifo, err := os.OpenFile(fifoPath(unitPrefix+name), os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("open console: %w", err)
+	}
+	defer fifo.Close()
+	if _, err := fifo.WriteString(command + "\n"); err != nil {
+		return fmt.Errorf("write console: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) systemctl(ctx context.Context, args ...string) (string, error) {
+	return run(ctx, "systemctl", append([]string{"--no-ask-password"}, args...)...)
+}
+
+func fifoPath(unit string) string {
+	return "/run/" + unit + ".stdin"
+}
+
+func run(ctx context.Context, name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
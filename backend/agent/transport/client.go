@@ -14,10 +14,6 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
-const (
-	streamMethod = "/agent.AgentService/Stream"
-)
-
 type Client struct {
 	addr       string
 	certFile   string
@@ -50,9 +46,10 @@ func (c *Client) Run(ctx context.Context) (func(map[string]any) error, <-chan ma
 		return nil, nil, fmt.Errorf("grpc dial: %w", err)
 	}
 
-	streamDesc := &grpc.StreamDesc{StreamName: "Stream", ServerStreams: true, ClientStreams: true}
-	stream, err := conn.NewStream(ctx, streamDesc, streamMethod)
+	streamDesc := &grpc.StreamDesc{StreamName: StreamName, ServerStreams: true, ClientStreams: true}
+	stream, err := conn.NewStream(ctx, streamDesc, StreamMethod)
 	if err != nil {
+		conn.Close()
 		return nil, nil, fmt.Errorf("grpc stream: %w", err)
 	}
 
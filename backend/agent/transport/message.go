@@ -0,0 +1,74 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The stream is a gRPC bidirectional stream of structpb.Struct messages
+const (
+	ServiceName  = "agent.AgentService"
+	StreamName   = "Stream"
+	StreamMethod = "/" + ServiceName + "/" + StreamName
+)
+
+// Message types sent by the agent
+const (
+	MessageHello     = "hello"
+	MessageEvent     = "event"
+	MessageHeartbeat = "heartbeat"
+)
+
+// Event kinds
+const (
+	EventService = "service"
+	EventPort    = "port"
+	EventJava    = "java"
+)
+
+// Message is what the agent sends over the stream. Every message carries the
+// agent's whole state, so the manager never depends on seeing every event.
+type Message struct {
+	Type      string          `json:"type"`
+	Version   string          `json:"version,omitempty"`
+	Event     *Event          `json:"event,omitempty"`
+	State     json.RawMessage `json:"state,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+// Event is a change the agent observed
+type Event struct {
+	Kind      string `json:"kind"`
+	Service   string `json:"service,omitempty"`
+	OldState  string `json:"old_state,omitempty"`
+	NewState  string `json:"new_state,omitempty"`
+	Port      int    `json:"port,omitempty"`
+	Open      bool   `json:"open,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Map converts the message to the form sent over the stream
+func (m Message) Map() (map[string]any, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("encode message: %w", err)
+	}
+	return values, nil
+}
+
+// DecodeMessage reads a message received over the stream
+func DecodeMessage(values map[string]any) (Message, error) {
+	var m Message
+	data, err := json.Marshal(values)
+	if err != nil {
+		return m, fmt.Errorf("decode message: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("decode message: %w", err)
+	}
+	return m, nil
+}
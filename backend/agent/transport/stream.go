@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff between reconnects, doubled after each failed attempt
+const (
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+	// stableAfter is how long a stream must stay up for the backoff to start over
+	stableAfter = 30 * time.Second
+)
+
+// Stream keeps a stream to the manager open until ctx is done, reconnecting
+// with exponential backoff. hello is sent first on every connection, then
+// every message from out. Messages taken from out while the stream is down
+// are lost; the hello of the next connection carries the current state.
+func (c *Client) Stream(ctx context.Context, hello func() Message, out <-chan Message, onError func(error)) {
+	backoff := minBackoff
+	for {
+		connected := time.Now()
+		err := c.streamOnce(ctx, hello, out)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+		if time.Since(connected) >= stableAfter {
+			backoff = minBackoff
+		}
+
+		// Jitter keeps agents restarted together from reconnecting together
+		wait := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// errStreamClosed is reported when the manager ends the stream
+type errStreamClosed struct{}
+
+func (errStreamClosed) Error() string { return "stream closed by manager" }
+
+func (c *Client) streamOnce(ctx context.Context, hello func() Message, out <-chan Message) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	send, recv, err := c.Run(ctx)
+	if err != nil {
+		return err
+	}
+	sendMessage := func(m Message) error {
+		values, err := m.Map()
+		if err != nil {
+			return err
+		}
+		return send(values)
+	}
+	if err := sendMessage(hello()); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-out:
+			if err := sendMessage(m); err != nil {
+				return err
+			}
+		case _, ok := <-recv:
+			if !ok {
+				return errStreamClosed{}
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// client calls the manager's REST and WebSocket API with an API key
+type client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newClient(baseURL, apiKey string) *client {
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// apiError is an error response from the manager
+type apiError struct {
+	Status    int
+	Code      string
+	Message   string
+	RequestID string
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// do sends a request to /api/v1 and decodes a JSON response into out, if set
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return decodeError(resp.StatusCode, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+func decodeError(status int, data []byte) error {
+	var body struct {
+		Error     json.RawMessage `json:"error"`
+		Code      string          `json:"code"`
+		RequestID string          `json:"request_id"`
+	}
+	apiErr := &apiError{Status: status, Message: strings.TrimSpace(string(data))}
+	if json.Unmarshal(data, &body) != nil {
+		return apiErr
+	}
+
+	// v1 routes put the message in "error"; v2 wraps everything in an object
+	var message string
+	var envelope struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(body.Error, &message) == nil {
+		apiErr.Message, apiErr.Code, apiErr.RequestID = message, body.Code, body.RequestID
+	} else if json.Unmarshal(body.Error, &envelope) == nil {
+		apiErr.Message, apiErr.Code, apiErr.RequestID = envelope.Message, envelope.Code, envelope.RequestID
+	}
+	return apiErr
+}
+
+// wsMessage is a message from one of the manager's WebSocket streams
+type wsMessage struct {
+	Type      string                 `json:"type"`
+	Payload   map[string]interface{} `json:"payload"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// stream connects to a WebSocket under /api/v1 and calls fn for each message
+// until ctx is done, the connection closes or fn returns false
+func (c *client) stream(ctx context.Context, path string, fn func(wsMessage) bool) error {
+	conn, err := c.dial(ctx, path)
+	if err != nil {
+		return err
+	}
+	return readMessages(ctx, conn, fn)
+}
+
+// dial opens a WebSocket under /api/v1
+func (c *client) dial(ctx context.Context, path string) (*websocket.Conn, error) {
+	u, err := url.Parse(c.baseURL + "/api/v1" + path)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+
+	header := http.Header{}
+	header.Set("X-API-Key", c.apiKey)
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, decodeError(resp.StatusCode, data)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// readMessages calls fn for each message on conn, then closes it
+func readMessages(ctx context.Context, conn *websocket.Conn, fn func(wsMessage) bool) error {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				return nil
+			}
+			return err
+		}
+		if !fn(msg) {
+			return nil
+		}
+	}
+}
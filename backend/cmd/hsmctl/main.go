@@ -0,0 +1,534 @@
+// Command hsmctl manages a HytaleSM manager from the command line.
+//
+// It authenticates with an API key (create one with POST /api/v1/auth/api-keys)
+// read from --api-key or HSMCTL_API_KEY, against the manager at --url or
+// HSMCTL_URL.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/gorilla/websocket"
+)
+
+const usage = `Usage: hsmctl [--url URL] [--api-key KEY] [--json] <command>
+
+Commands:
+  servers list                      List servers and their connection status
+  servers status <id>               Show a server's health
+  servers start <id>                Start a server
+  servers stop <id> [--force]       Stop a server (graceful unless --force)
+  servers restart <id> [--force]    Restart a server
+  tasks list <id>                   List a server's recent tasks
+  tasks log <id> <task-id>          Print a task's full output
+  tasks tail <id>                   Stream task output and status changes
+  console tail <id>                 Stream console output
+  console exec <id> <command...>    Send a console command
+  backups list <id>                 List a server's backups
+  backups create <id> [--dir DIR]... [--working-dir DIR] [--dest PATH]
+                                    Back up a server, defaulting to its backup settings
+  deploy <id> <package> [--follow]  Deploy a release package and optionally follow its output
+  import <pterodactyl|csv> <file> [--dry-run] [--username USER] [--key-path PATH]
+         [--auth-method METHOD] [--process-manager PM] [--host HOST] [--group GROUP]
+                                    Create servers from a Pterodactyl export or SSH inventory CSV
+
+Environment:
+  HSMCTL_URL      Manager base URL (default http://localhost:8080)
+  HSMCTL_API_KEY  API key used when --api-key is not given`
+
+// cli holds the parsed global flags and where output goes
+type cli struct {
+	client *client
+	json   bool
+	out    io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("hsmctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprintln(stderr, usage) }
+	baseURL := flags.String("url", envOr("HSMCTL_URL", "http://localhost:8080"), "manager base URL")
+	apiKey := flags.String("api-key", os.Getenv("HSMCTL_API_KEY"), "API key")
+	asJSON := flags.Bool("json", false, "print raw JSON responses")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+	if *apiKey == "" {
+		fmt.Fprintln(stderr, "hsmctl: an API key is required (--api-key or HSMCTL_API_KEY)")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	c := &cli{client: newClient(*baseURL, *apiKey), json: *asJSON, out: stdout}
+	if err := c.dispatch(ctx, flags.Args()); err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			fmt.Fprintf(stderr, "hsmctl: %s\n\n%s\n", usageErr, usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "hsmctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// usageError reports a malformed command line
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+func (c *cli) dispatch(ctx context.Context, args []string) error {
+	group, rest := args[0], args[1:]
+	if group == "deploy" {
+		return c.deploy(ctx, rest)
+	}
+	if group == "import" {
+		return c.importServers(ctx, rest)
+	}
+	if group == "help" {
+		fmt.Fprintln(c.out, usage)
+		return nil
+	}
+	if len(rest) == 0 {
+		return usageError("missing subcommand for " + group)
+	}
+	action, rest := rest[0], rest[1:]
+
+	switch group + " " + action {
+	case "servers list":
+		return c.listServers(ctx)
+	case "servers status":
+		return c.withServer(rest, func(id string, _ []string) error { return c.get(ctx, "/servers/"+url.PathEscape(id)+"/status") })
+	case "servers start":
+		return c.withServer(rest, func(id string, _ []string) error {
+			return c.post(ctx, "/servers/"+url.PathEscape(id)+"/start", nil)
+		})
+	case "servers stop", "servers restart":
+		return c.withServer(rest, func(id string, extra []string) error {
+			graceful := "true"
+			if len(extra) > 0 && extra[0] == "--force" {
+				graceful = "false"
+			}
+			return c.post(ctx, "/servers/"+url.PathEscape(id)+"/"+action+"?graceful="+graceful, nil)
+		})
+	case "tasks list":
+		return c.withServer(rest, func(id string, _ []string) error { return c.listTasks(ctx, id) })
+	case "tasks log":
+		return c.withServer(rest, func(id string, extra []string) error {
+			if len(extra) == 0 {
+				return usageError("tasks log needs a task ID")
+			}
+			return c.taskLog(ctx, id, extra[0])
+		})
+	case "tasks tail":
+		return c.withServer(rest, func(id string, _ []string) error { return c.tailTasks(ctx, id) })
+	case "console tail":
+		return c.withServer(rest, func(id string, _ []string) error { return c.tailConsole(ctx, id) })
+	case "console exec":
+		return c.withServer(rest, func(id string, extra []string) error {
+			if len(extra) == 0 {
+				return usageError("console exec needs a command")
+			}
+			return c.post(ctx, "/servers/"+url.PathEscape(id)+"/command", map[string]string{"command": strings.Join(extra, " ")})
+		})
+	case "backups list":
+		return c.withServer(rest, func(id string, _ []string) error { return c.get(ctx, "/servers/"+url.PathEscape(id)+"/backups") })
+	case "backups create":
+		return c.withServer(rest, func(id string, extra []string) error { return c.createBackup(ctx, id, extra) })
+	}
+	return usageError("unknown command: " + group + " " + action)
+}
+
+func (c *cli) withServer(args []string, fn func(id string, extra []string) error) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return usageError("missing server ID")
+	}
+	return fn(args[0], args[1:])
+}
+
+// get prints the JSON response of a GET request
+func (c *cli) get(ctx context.Context, path string) error {
+	var out json.RawMessage
+	if err := c.client.do(ctx, "GET", path, nil, &out); err != nil {
+		return err
+	}
+	return c.printJSON(out)
+}
+
+// post sends a request and prints the response's message, or the whole response with --json
+func (c *cli) post(ctx context.Context, path string, body interface{}) error {
+	var out json.RawMessage
+	if err := c.client.do(ctx, "POST", path, body, &out); err != nil {
+		return err
+	}
+	if !c.json {
+		var resp struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(out, &resp) == nil && resp.Message != "" {
+			fmt.Fprintln(c.out, resp.Message)
+			return nil
+		}
+	}
+	return c.printJSON(out)
+}
+
+func (c *cli) printJSON(data json.RawMessage) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		_, err = c.out.Write(data)
+		return err
+	}
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func (c *cli) listServers(ctx context.Context) error {
+	var servers []struct {
+		ID               string `json:"id"`
+		Name             string `json:"name"`
+		ConnectionStatus string `json:"connection_status"`
+		Host             string `json:"host"`
+		Port             int    `json:"port"`
+	}
+	var raw json.RawMessage
+	if err := c.client.do(ctx, "GET", "/servers", nil, &raw); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(raw)
+	}
+	if err := json.Unmarshal(raw, &servers); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tHOST")
+	for _, s := range servers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s:%d\n", s.ID, s.Name, s.ConnectionStatus, s.Host, s.Port)
+	}
+	return w.Flush()
+}
+
+func (c *cli) listTasks(ctx context.Context, serverID string) error {
+	var resp struct {
+		Tasks []struct {
+			ID         string `json:"id"`
+			Task       string `json:"task"`
+			Status     string `json:"status"`
+			StartedAt  string `json:"started_at"`
+			FinishedAt string `json:"finished_at"`
+			Error      string `json:"error"`
+		} `json:"tasks"`
+	}
+	var raw json.RawMessage
+	if err := c.client.do(ctx, "GET", "/servers/"+url.PathEscape(serverID)+"/tasks", nil, &raw); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(raw)
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTASK\tSTATUS\tSTARTED\tERROR")
+	for _, t := range resp.Tasks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.Task, t.Status, t.StartedAt, t.Error)
+	}
+	return w.Flush()
+}
+
+func (c *cli) taskLog(ctx context.Context, serverID, taskID string) error {
+	var resp struct {
+		Lines []struct {
+			Line string `json:"line"`
+		} `json:"lines"`
+	}
+	var raw json.RawMessage
+	path := "/servers/" + url.PathEscape(serverID) + "/tasks/" + url.PathEscape(taskID) + "/log"
+	if err := c.client.do(ctx, "GET", path, nil, &raw); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(raw)
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return err
+	}
+	for _, line := range resp.Lines {
+		fmt.Fprintln(c.out, line.Line)
+	}
+	return nil
+}
+
+// tailTasks prints task output as it arrives
+func (c *cli) tailTasks(ctx context.Context, serverID string) error {
+	conn, err := c.client.dial(ctx, "/ws/servers/"+url.PathEscape(serverID)+"/tasks")
+	if err != nil {
+		return err
+	}
+	return c.printTasks(ctx, conn, "")
+}
+
+// printTasks prints task messages from conn. With follow set it only prints
+// live messages for tasks of that name and returns once one finishes, failing
+// if the task failed.
+func (c *cli) printTasks(ctx context.Context, conn *websocket.Conn, follow string) error {
+	var taskErr error
+	err := readMessages(ctx, conn, func(msg wsMessage) bool {
+		historical, _ := msg.Payload["historical"].(bool)
+		task, _ := msg.Payload["task"].(string)
+		if follow != "" && (historical || task != follow) {
+			return true
+		}
+		if c.json {
+			_ = json.NewEncoder(c.out).Encode(msg)
+		}
+
+		switch msg.Type {
+		case "task_output":
+			if !c.json {
+				line, _ := msg.Payload["line"].(string)
+				fmt.Fprintf(c.out, "[%s] %s\n", task, line)
+			}
+		case "task_status":
+			status, _ := msg.Payload["status"].(string)
+			if !c.json && !historical {
+				fmt.Fprintf(c.out, "[%s] %s\n", task, status)
+			}
+			if follow != "" && (status == "complete" || status == "failed") {
+				if status == "failed" {
+					message, _ := msg.Payload["error"].(string)
+					taskErr = fmt.Errorf("%s failed: %s", task, message)
+				}
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return taskErr
+}
+
+func (c *cli) tailConsole(ctx context.Context, serverID string) error {
+	return c.client.stream(ctx, "/ws/console/"+url.PathEscape(serverID), func(msg wsMessage) bool {
+		if c.json {
+			_ = json.NewEncoder(c.out).Encode(msg)
+			return true
+		}
+		switch msg.Type {
+		case "console_output":
+			line, _ := msg.Payload["line"].(string)
+			fmt.Fprintln(c.out, line)
+		case "error":
+			message, _ := msg.Payload["message"].(string)
+			fmt.Fprintln(c.out, "error: "+message)
+		}
+		return true
+	})
+}
+
+func (c *cli) createBackup(ctx context.Context, serverID string, args []string) error {
+	flags := flag.NewFlagSet("backups create", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	var dirs stringList
+	flags.Var(&dirs, "dir", "directory to back up, relative to the working directory (repeatable)")
+	workingDir := flags.String("working-dir", "", "server working directory")
+	dest := flags.String("dest", "", "local destination path on the server host")
+	if err := flags.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+
+	// Fill gaps from the server's own settings
+	var server struct {
+		Server struct {
+			WorkingDirectory string `json:"working_directory"`
+		} `json:"server"`
+		Backups struct {
+			Directories  []string `json:"directories"`
+			Destinations []struct {
+				Type string `json:"type"`
+				Path string `json:"path"`
+			} `json:"destinations"`
+		} `json:"backups"`
+	}
+	if err := c.client.do(ctx, "GET", "/servers/"+url.PathEscape(serverID), nil, &server); err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		dirs = server.Backups.Directories
+	}
+	if *workingDir == "" {
+		*workingDir = server.Server.WorkingDirectory
+	}
+	if *dest == "" {
+		for _, d := range server.Backups.Destinations {
+			if d.Type == "local" && d.Path != "" {
+				*dest = d.Path
+				break
+			}
+		}
+	}
+	if len(dirs) == 0 || *workingDir == "" || *dest == "" {
+		return usageError("backups create needs --dir, --working-dir and --dest when the server has no backup settings")
+	}
+
+	body := map[string]interface{}{
+		"directories": dirs,
+		"working_dir": *workingDir,
+		"destination": map[string]string{"type": "local", "path": *dest},
+	}
+	return c.post(ctx, "/servers/"+url.PathEscape(serverID)+"/backups", body)
+}
+
+func (c *cli) deploy(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return usageError("deploy needs a server ID and a package name")
+	}
+	serverID, pkg := args[0], args[1]
+	follow := len(args) > 2 && args[2] == "--follow"
+
+	// Subscribe before starting so no output is missed
+	var conn *websocket.Conn
+	if follow {
+		var err error
+		if conn, err = c.client.dial(ctx, "/ws/servers/"+url.PathEscape(serverID)+"/tasks"); err != nil {
+			return err
+		}
+	}
+
+	if err := c.post(ctx, "/servers/"+url.PathEscape(serverID)+"/releases/deploy", map[string]string{"package_name": pkg}); err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return err
+	}
+	if conn == nil {
+		return nil
+	}
+	return c.printTasks(ctx, conn, "release-deploy")
+}
+
+// importServers converts another panel's export on the manager and prints
+// what was created and what was skipped
+func (c *cli) importServers(ctx context.Context, args []string) error {
+	if len(args) < 2 {
+		return usageError("import needs a format (pterodactyl or csv) and a file")
+	}
+	format, file := args[0], args[1]
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "only show what would be created")
+	defaults := map[string]*string{
+		"username":        flags.String("username", "", "SSH user for servers the export has none for"),
+		"key_path":        flags.String("key-path", "", "SSH private key path on the manager host"),
+		"auth_method":     flags.String("auth-method", "", "key or password"),
+		"process_manager": flags.String("process-manager", "", "screen, tmux, systemd, docker or agent"),
+		"host":            flags.String("host", "", "host for servers the export has none for"),
+		"group":           flags.String("group", "", "group to put the servers in"),
+	}
+	if err := flags.Parse(args[2:]); err != nil {
+		return usageError(err.Error())
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"format": format, "data": string(data), "dry_run": *dryRun}
+	filled := map[string]string{}
+	for name, value := range defaults {
+		if *value != "" {
+			filled[name] = *value
+		}
+	}
+	body["defaults"] = filled
+
+	var raw json.RawMessage
+	if err := c.client.do(ctx, "POST", "/servers/import/panel", body, &raw); err != nil {
+		return err
+	}
+	if c.json {
+		return c.printJSON(raw)
+	}
+	var resp struct {
+		Servers []struct {
+			Server struct {
+				ID         string `json:"id"`
+				Name       string `json:"name"`
+				Connection struct {
+					Host string `json:"host"`
+				} `json:"connection"`
+			} `json:"server"`
+			Created bool   `json:"created"`
+			Reason  string `json:"reason"`
+		} `json:"servers"`
+		Warnings []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tHOST\tRESULT")
+	for _, s := range resp.Servers {
+		result := "created"
+		switch {
+		case s.Reason != "":
+			result = "skipped: " + s.Reason
+		case !s.Created:
+			result = "would create"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Server.ID, s.Server.Name, s.Server.Connection.Host, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, warning := range resp.Warnings {
+		fmt.Fprintln(c.out, "warning: "+warning)
+	}
+	return nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
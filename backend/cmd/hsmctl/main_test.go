@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunSendsAPIKeyAndReportsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "hsm_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Invalid or expired API key","code":"invalid_token"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/servers":
+			_, _ = w.Write([]byte(`[{"id":"alpha","name":"Alpha","connection_status":"running","host":"10.0.0.5","port":22}]`))
+		case "/api/v1/servers/alpha/stop":
+			if r.Method != http.MethodPost || r.URL.Query().Get("graceful") != "false" {
+				t.Errorf("unexpected stop request %s %s", r.Method, r.URL)
+			}
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"message":"Server stop initiated"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"Server not found","code":"server_not_found","request_id":"req-1"}`))
+		}
+	}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--url", srv.URL, "--api-key", "hsm_test", "servers", "list"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("servers list exited %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "alpha") || !strings.Contains(stdout.String(), "10.0.0.5:22") {
+		t.Fatalf("unexpected listing:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--url", srv.URL, "--api-key", "hsm_test", "servers", "stop", "alpha", "--force"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("servers stop exited %d: %s", code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "Server stop initiated" {
+		t.Fatalf("unexpected stop output %q", stdout.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"--url", srv.URL, "--api-key", "hsm_test", "servers", "status", "beta"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit 1 for a missing server, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "server_not_found: Server not found (request req-1)") {
+		t.Fatalf("unexpected error output %q", stderr.String())
+	}
+
+	stderr.Reset()
+	if code := run([]string{"--url", srv.URL, "--api-key", "wrong", "servers", "list"}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "401") {
+		t.Fatalf("expected a 401 error, got %d %q", code, stderr.String())
+	}
+}
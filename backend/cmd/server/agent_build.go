@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/agentbin"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+// buildAgentBinaries prepares the agent for each architecture in
+// <data_dir>/agent-binaries. Binaries embedded in this build are extracted;
+// otherwise the agent is compiled from source. Each compiled binary has a
+// .source file next to it holding the hash of the inputs it was built from;
+// when the hash still matches, the existing binary is reused.
+func buildAgentBinaries(cfg *config.Config) error {
+	if cfg == nil {
+		return fmt.Errorf("config is nil")
+	}
+	dataDir := cfg.Storage.DataDir
+	if dataDir == "" {
+		return fmt.Errorf("data dir not configured")
+	}
+
+	var modDir, hash string
+	for _, arch := range agentbin.Archs {
+		if agentbin.Embedded(arch) {
+			sum, err := agentbin.Extract(dataDir, arch)
+			if err != nil {
+				return err
+			}
+			logging.L().Info("Using embedded agent binary", "arch", arch, "sha256", shortHash(sum))
+			continue
+		}
+
+		binPath := agentbin.Path(dataDir, arch)
+		if _, err := exec.LookPath("go"); err != nil {
+			sum, verifyErr := agentbin.Verify(binPath)
+			if verifyErr != nil {
+				return fmt.Errorf("no embedded agent binary for %s and go toolchain not found", arch)
+			}
+			logging.L().Warn("Go toolchain not found, using existing agent binary", "arch", arch, "sha256", shortHash(sum))
+			continue
+		}
+
+		if hash == "" {
+			var err error
+			if modDir, err = findGoModDir(); err != nil {
+				return err
+			}
+			if hash, err = agentSourceHash(modDir); err != nil {
+				return fmt.Errorf("hash agent source: %w", err)
+			}
+		}
+
+		stampPath := binPath + ".source"
+		if info, ok := reusableAgentBinary(binPath, stampPath, hash); ok {
+			logging.L().Info("Reusing agent binary", "arch", arch, "version", shortHash(hash), "built_at", info.ModTime().UTC().Format(time.RFC3339))
+			continue
+		}
+
+		started := time.Now()
+		if _, err := agentbin.Build(modDir, dataDir, arch); err != nil {
+			return err
+		}
+		if err := os.WriteFile(stampPath, []byte(hash+"\n"), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", stampPath, err)
+		}
+		logging.L().Info("Built agent binary", "arch", arch, "version", shortHash(hash), "duration_ms", time.Since(started).Milliseconds())
+	}
+
+	return nil
+}
+
+// reusableAgentBinary reports whether binPath was built from hash and still
+// matches its recorded checksum
+func reusableAgentBinary(binPath, stampPath, hash string) (os.FileInfo, bool) {
+	info, err := os.Stat(binPath)
+	if err != nil || info.Size() == 0 {
+		return nil, false
+	}
+	if _, err := agentbin.Verify(binPath); err != nil {
+		return nil, false
+	}
+	stamp, err := os.ReadFile(stampPath)
+	if err != nil || strings.TrimSpace(string(stamp)) != hash {
+		return nil, false
+	}
+	return info, true
+}
+
+// agentSourceHash hashes everything that affects the agent build: the Go
+// toolchain version, go.mod, go.sum and every file under agent/
+func agentSourceHash(modDir string) (string, error) {
+	h := sha256.New()
+
+	goVersion, err := exec.Command("go", "env", "GOVERSION").Output()
+	if err != nil {
+		return "", fmt.Errorf("go env GOVERSION: %w", err)
+	}
+	fmt.Fprintf(h, "go %s\n", strings.TrimSpace(string(goVersion)))
+
+	files := []string{"go.mod", "go.sum"}
+	agentDir := filepath.Join(modDir, "agent")
+	err = filepath.WalkDir(agentDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, err := filepath.Rel(modDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	for _, rel := range files {
+		if err := hashFile(h, modDir, rel); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, modDir, rel string) error {
+	file, err := os.Open(filepath.Join(modDir, rel))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(w, "file %s\n", filepath.ToSlash(rel))
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+func findGoModDir() (string, error) {
+	start, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	current := start
+	for i := 0; i < 8; i++ {
+		if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			break
+		}
+		current = parent
+	}
+	return "", fmt.Errorf("go.mod not found from %s", start)
+}
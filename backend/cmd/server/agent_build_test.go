@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/agentbin"
+)
+
+func TestAgentSourceHashTracksAgentFiles(t *testing.T) {
+	modDir := t.TempDir()
+	writeFile := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(modDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("go.mod", "module example\n")
+	writeFile("agent/main.go", "package main\n")
+
+	first, err := agentSourceHash(modDir)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if again, _ := agentSourceHash(modDir); again != first {
+		t.Fatalf("expected a stable hash, got %s then %s", first, again)
+	}
+
+	binPath := filepath.Join(modDir, "hytale-agent-linux-amd64")
+	writeFile("hytale-agent-linux-amd64", "binary")
+	writeFile("hytale-agent-linux-amd64.source", first+"\n")
+	if _, err := agentbin.WriteChecksum(binPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reusableAgentBinary(binPath, binPath+".source", first); !ok {
+		t.Fatal("expected the binary to be reused when the hash matches")
+	}
+
+	writeFile("agent/ports/ports.go", "package ports\n")
+	changed, err := agentSourceHash(modDir)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if changed == first {
+		t.Fatal("expected adding an agent file to change the hash")
+	}
+	if _, ok := reusableAgentBinary(binPath, binPath+".source", changed); ok {
+		t.Fatal("expected a rebuild after the source changed")
+	}
+}
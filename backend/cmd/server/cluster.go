@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
+	"github.com/TheGojiOG/HytaleSM/internal/websocket"
+)
+
+// relayedMessage is a WebSocket message published on another instance
+type relayedMessage struct {
+	Room    string             `json:"room"`
+	Message *websocket.Message `json:"message"`
+}
+
+// relayHub passes the messages published on hub to the clients connected to
+// the other instances, and theirs to hub's
+func relayHub(hub *websocket.Hub, node *cluster.Node) {
+	hub.SetRelay(func(room string, message *websocket.Message) {
+		node.Publish("ws", relayedMessage{Room: room, Message: message})
+	})
+	node.Subscribe("ws", func(data json.RawMessage) {
+		var relayed relayedMessage
+		if err := json.Unmarshal(data, &relayed); err == nil && relayed.Message != nil {
+			hub.BroadcastToRoom(relayed.Room, relayed.Message)
+		}
+	})
+}
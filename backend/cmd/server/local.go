@@ -0,0 +1,26 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// prepareLocalMode does what the start scripts do before launching the
+// manager: it loads .env and generates any missing secrets into it. This lets
+// the binary be started directly, e.g. on Windows without PowerShell.
+func prepareLocalMode() error {
+	path := config.EnvFilePath()
+	if err := config.LoadEnvFile(path); err != nil {
+		return err
+	}
+	generated, err := config.EnsureEnvSecrets(path)
+	if err != nil {
+		return err
+	}
+	if len(generated) > 0 {
+		log.Printf("[Config] Generated %s in %s", strings.Join(generated, ", "), path)
+	}
+	return nil
+}
@@ -1,46 +1,56 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/alerts"
 	"github.com/TheGojiOG/HytaleSM/internal/api"
-	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/console"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/features"
 	"github.com/TheGojiOG/HytaleSM/internal/logging"
 	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+	"github.com/TheGojiOG/HytaleSM/internal/notify"
+	"github.com/TheGojiOG/HytaleSM/internal/selfbackup"
 	"github.com/TheGojiOG/HytaleSM/internal/server"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
 	"github.com/TheGojiOG/HytaleSM/internal/websocket"
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+	// "local" starts the manager without the start scripts, reading its
+	// secrets from .env and creating them there on first run
+	if len(os.Args) > 1 && os.Args[1] == "local" {
+		if err := prepareLocalMode(); err != nil {
+			log.Fatalf("Failed to prepare local mode: %v", err)
+		}
+		os.Args = append(os.Args[:1], os.Args[2:]...)
 	}
 
-	// Initialize server manager
-	serverManager, err := config.NewServerManager(cfg.Storage.ConfigDir)
-	if err != nil {
-		log.Fatalf("Failed to initialize server manager: %v", err)
+	// Validation reports every problem itself, so it runs before the fatal load below
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:]))
 	}
 
-	if err := buildAgentBinaries(cfg); err != nil {
-		log.Printf("Agent build failed: %v", err)
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
 	// Set up logging
@@ -48,27 +58,51 @@ func main() {
 		log.Fatalf("Failed to set up logging: %v", err)
 	}
 	defer logging.Close()
+	features.Set(cfg.Features)
 
 	// Check if running migrations
 	if len(os.Args) > 1 && os.Args[1] == "migrate" {
-		runMigrations(cfg)
+		runMigrations(cfg, os.Args[2:])
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "self-backup" {
+		runSelfBackup(cfg, os.Args[2:])
+		return
+	}
+
+	if err := buildAgentBinaries(cfg); err != nil {
+		logging.L().Error("Agent build failed", "error", err)
+	}
+
+	// Export traces when enabled
+	shutdownTracing := tracing.Init(cfg.Tracing)
+
 	// Initialize database
-	db, err := database.NewDB(cfg.Database.Path)
+	db, err := database.Open(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
 	// Run migrations automatically
-	log.Println("Running database migrations...")
+	logging.L().Info("Running database migrations")
 	if err := db.Migrate(); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
-	log.Println("Migrations completed successfully")
+	logging.L().Info("Migrations completed successfully")
 
+	// Initialize server manager (definitions live in the database, servers.yaml is an export)
+	serverManager, err := config.NewServerManagerWithStore(cfg.Storage.ConfigDir, database.NewServerStore(db.DB))
+	if err != nil {
+		log.Fatalf("Failed to initialize server manager: %v", err)
+	}
+	configHistory := config.NewHistory(filepath.Join(cfg.Storage.ConfigDir, ".history"), cfg.Storage.ConfigVersions)
+	configHistory.Track("config.yaml", config.GetConfigPath())
+	serverManager.SetHistory(configHistory)
+	if err := serverManager.Save(); err != nil {
+		logging.L().Error("Failed to refresh servers.yaml export", "error", err)
+	}
 
 	// Initialize activity logger
 	logDir := filepath.Join(cfg.Storage.DataDir, "logs", "activity")
@@ -79,12 +113,35 @@ func main() {
 	defer activityLogger.Close()
 
 	// Initialize SSH connection pool
-	log.Println("Initializing SSH connection pool...")
+	logging.L().Info("Initializing SSH connection pool")
 	sshPool := ssh.NewConnectionPool(db.DB)
+	sshPool.SetHostKeyPolicy(ssh.HostKeyPolicy(cfg.Security.SSH))
 	defer sshPool.Stop()
 
-	// Initialize process manager (using screen impl)
-	processManager := server.NewScreenProcessManager(sshPool)
+	// Initialize process managers; each server picks screen, tmux, systemd,
+	// docker or agent
+	processManager := server.NewProcessManagerRouter(server.NewScreenProcessManager(sshPool), func(serverID string) string {
+		def, _ := serverManager.GetByID(serverID)
+		return def.Server.ProcessManager
+	})
+	processManager.Register("tmux", server.NewTmuxProcessManager(sshPool))
+	processManager.Register("docker", server.NewDockerProcessManager(sshPool, func(serverID string) server.DockerOptions {
+		def, _ := serverManager.GetByID(serverID)
+		return dockerOptions(def)
+	}))
+	processManager.Register("systemd", server.NewSystemdProcessManager(sshPool, func(serverID string) server.SystemdOptions {
+		def, _ := serverManager.GetByID(serverID)
+		return server.SystemdOptions{UnitName: def.Server.SystemdService}
+	}))
+	processManager.Register("agent", server.NewAgentProcessManager(func(serverID string) string {
+		def, _ := serverManager.GetByID(serverID)
+		return def.Connection.Host
+	}, func() (*tls.Config, error) {
+		return agentcert.ClientTLSConfig(db.DB, cfg.Storage.DataDir)
+	}))
+	for _, kind := range []string{"tmux", "docker", "systemd", "agent"} {
+		processManager.Gate(kind, processManagerGate(kind))
+	}
 
 	// Initialize status detector
 	executor := server.NewDefaultCommandExecutor(sshPool)
@@ -94,33 +151,83 @@ func main() {
 	lifecycleManager := server.NewLifecycleManager(sshPool, processManager, statusDetector, db.DB)
 
 	// Initialize WebSocket hub
-	log.Println("Initializing WebSocket hub...")
+	logging.L().Info("Initializing WebSocket hub")
 	hub := websocket.NewHub()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go hub.Run(ctx)
 
+	// Instances sharing the database elect one leader for the schedulers and
+	// collectors and relay WebSocket messages to each other
+	node := cluster.Standalone()
+	if cfg.Cluster.Enabled {
+		node = cluster.New(db.DB, cluster.SettingsFrom(cfg.Cluster))
+		relayHub(hub, node)
+	}
+
 	// Initialize console session manager
-	log.Println("Initializing console session manager...")
+	logging.L().Info("Initializing console session manager")
 	sessionManager := console.NewSessionManager(hub, sshPool, db.DB)
+	if cfg.Console.Index {
+		// Console output is stored for searching and pruned after the retention
+		consoleIndex := console.NewLogIndex(db, time.Duration(cfg.Console.RetentionDays)*24*time.Hour)
+		consoleIndex.Start()
+		defer consoleIndex.Stop()
+		sessionManager.SetLogIndex(consoleIndex)
+	}
 
 	// Start metrics collector
-	metricsCollector := metrics.NewCollector(cfg, serverManager, db)
-	metricsCollector.Start()
+	// Metrics rows are queued and written in batches
+	metricsWriter := metrics.NewWriter(db)
+	metricsWriter.Start()
+	defer metricsWriter.Stop()
+
+	metricsCollector := metrics.NewCollector(cfg, serverManager, db, metricsWriter)
+	// Collected samples are checked against the alert rules
+	alertEvaluator := alerts.NewEvaluator(alerts.NewStore(db.DB))
+	metricsCollector.SetAlerts(alertEvaluator)
+	if node.Clustered() {
+		metricsCollector.SetCPUSamples(metrics.NewSharedCPUSamples(db.DB, "collector"))
+	}
+	node.OnLead(metricsCollector.Start)
 	defer metricsCollector.Stop()
 
-	// Start backup schedule runner
-	backupScheduler := backup.NewScheduleRunner(cfg, db.DB, sshPool)
-	backupScheduler.Start(ctx)
-
-	log.Println("All server components initialized successfully")
+	// Raw samples are rolled up into hourly and daily rows and pruned by the leader
+	metricsRollup := metrics.NewRollup(db, cfg.Metrics)
+	node.OnLead(metricsRollup.Start)
+	defer metricsRollup.Stop()
+
+	// Settings that can change without a restart are pushed to their owners on reload
+	reloader := config.NewReloader(cfg)
+	reloader.OnReload(func(updated *config.Config) {
+		logging.SetLevel(updated.Logging.Level)
+		logging.SetModuleLevels(updated.Logging.Modules)
+		metricsCollector.SetConfig(updated.Metrics)
+		metricsRollup.SetConfig(updated.Metrics)
+		features.Set(updated.Features)
+		sshPool.SetHostKeyPolicy(ssh.HostKeyPolicy(updated.Security.SSH))
+	})
+
+	// Start manager self-backup scheduler
+	selfBackups := selfbackup.NewManager(cfg, db, config.GetConfigPath())
+	node.OnLead(selfBackups.Start)
+	reloader.OnReload(func(updated *config.Config) {
+		selfBackups.SetKnownHostsPath(updated.Security.SSH.KnownHostsPath)
+	})
+
+	// Start database health monitor
+	dbHealth := database.NewHealthMonitor(db, cfg.Database.Path, cfg.Database.Health, newDBHealthAlerter(cfg))
+	dbHealth.Start(ctx)
+
+	logging.L().Info("All server components initialized successfully")
 
 	// Set up HTTP server
-	router, shutdownOps := api.SetupRouter(cfg, serverManager, db, sshPool, lifecycleManager, statusDetector, processManager, activityLogger, hub, sessionManager)
+	router, shutdownOps := api.SetupRouter(cfg, serverManager, db, sshPool, lifecycleManager, statusDetector, processManager, activityLogger, hub, sessionManager, selfBackups, dbHealth, reloader, metricsWriter, alertEvaluator, configHistory, node)
+	node.Start(ctx)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      router,
+		Handler:      api.VersionFallback(router),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -128,7 +235,7 @@ func main() {
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Starting server on %s", server.Addr)
+		logging.L().Info("Starting server", "addr", server.Addr)
 
 		if cfg.Server.TLS.Enabled {
 			if err := server.ListenAndServeTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile); err != nil && err != http.ErrServerClosed {
@@ -141,32 +248,52 @@ func main() {
 		}
 	}()
 
+	// Reload configuration on SIGHUP; HTTP, WebSocket and console sessions stay up
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			result, err := reloader.Reload()
+			if err != nil {
+				logging.For("config").Warn("Reload rejected, keeping current configuration", "error", err)
+				continue
+			}
+			logging.For("config").Info("Reloaded configuration", "applied", result.Applied, "requires_restart", result.RequiresRestart)
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	logging.L().Info("Shutting down server")
 
 	// Graceful shutdown with timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
+	// Stop accepting requests first so no new tasks start
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Fatalf("Server forced to shutdown: %v", err)
+	}
+
+	// Cancel running tasks and wait for background operations before
+	// their SSH connections and the activity log go away
+	shutdownOps(shutdownCtx)
+	cancel()
+	node.Wait()
+
 	// Stop SSH pool
-	log.Println("Closing SSH connections...")
+	logging.L().Info("Closing SSH connections")
 	sshPool.Stop()
 
 	// Close activity logger
 	activityLogger.Close()
 
-	// Shutdown HTTP server
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
-	}
+	// Flush any spans still queued for export
+	shutdownTracing(shutdownCtx)
 
-	// Wait for background operations
-	shutdownOps()
-
-	log.Println("Server exited")
+	logging.L().Info("Server exited")
 }
 
 func setupLogging(cfg *config.Config) error {
@@ -186,81 +313,57 @@ func setupLogging(cfg *config.Config) error {
 	return err
 }
 
-func runMigrations(cfg *config.Config) {
-	log.Println("Running database migrations...")
+// newDBHealthAlerter emails the configured recipients when database health degrades
+func newDBHealthAlerter(cfg *config.Config) func(database.HealthReport) {
+	mailer := notify.NewSMTPSender(cfg.Notifications.SMTP)
+	recipients := cfg.Database.Health.AlertEmails
 
-	db, err := database.NewDB(cfg.Database.Path)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer db.Close()
+	return func(report database.HealthReport) {
+		if len(recipients) == 0 || !mailer.Enabled() {
+			return
+		}
 
-	if err := db.Migrate(); err != nil {
-		log.Fatalf("Migration failed: %v", err)
+		subject := fmt.Sprintf("Hytale Server Manager database health: %s", report.Status)
+		body := fmt.Sprintf("Database health check at %s reported %s.\n\n%s\n",
+			report.CheckedAt.Format(time.RFC3339), report.Status, strings.Join(report.Warnings, "\n"))
+		go func() {
+			if err := mailer.Send(recipients, subject, body); err != nil {
+				logging.For("database").Error("Failed to send health alert email", "error", err)
+			}
+		}()
 	}
-
-	log.Println("Migrations completed successfully")
 }
 
-
-func buildAgentBinaries(cfg *config.Config) error {
-	if cfg == nil {
-		return fmt.Errorf("config is nil")
-	}
-	dataDir := cfg.Storage.DataDir
-	if dataDir == "" {
-		return fmt.Errorf("data dir not configured")
-	}
-
-	modDir, err := findGoModDir()
-	if err != nil {
-		return err
-	}
-
-	binDir := filepath.Join(dataDir, "agent-binaries")
-	if err := os.MkdirAll(binDir, 0755); err != nil {
-		return err
-	}
-
-	archs := []string{"amd64", "arm64"}
-	for _, arch := range archs {
-		binPath := filepath.Join(binDir, fmt.Sprintf("hytale-agent-linux-%s", arch))
-		if err := buildAgentBinary(modDir, arch, binPath); err != nil {
-			return err
+// processManagerGate refuses to start servers with an experimental process
+// manager unless the process_managers feature flag is on
+func processManagerGate(kind string) func() error {
+	return func() error {
+		if !features.Enabled(features.ProcessManagers) {
+			return fmt.Errorf("the %s process manager is experimental; enable the %s feature flag to start servers with it", kind, features.ProcessManagers)
 		}
+		return nil
 	}
-
-	return nil
-}
-
-func buildAgentBinary(modDir, arch, outputPath string) error {
-	cmd := exec.Command("go", "build", "-o", outputPath, "./agent")
-	cmd.Dir = modDir
-	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+arch, "CGO_ENABLED=0")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("build %s: %w: %s", arch, err, out.String())
-	}
-	return nil
 }
 
-func findGoModDir() (string, error) {
-	start, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	current := start
-	for i := 0; i < 8; i++ {
-		if _, err := os.Stat(filepath.Join(current, "go.mod")); err == nil {
-			return current, nil
-		}
-		parent := filepath.Dir(current)
-		if parent == current {
-			break
-		}
-		current = parent
+// dockerOptions maps a server definition to the container settings of the
+// docker process manager
+func dockerOptions(def config.ServerDefinition) server.DockerOptions {
+	opts := server.DockerOptions{WorkingDir: def.Server.WorkingDirectory}
+	if docker := def.Server.Docker; docker != nil {
+		opts.Image = docker.Image
+		opts.Network = docker.Network
+		opts.Ports = docker.Ports
+		opts.Volumes = docker.Volumes
+		opts.Env = docker.Env
+		opts.ExtraArgs = docker.ExtraArgs
+		opts.User = docker.User
+		opts.Host = docker.Host
+		opts.TLSCACert = docker.TLSCACert
+		opts.TLSCert = docker.TLSCert
+		opts.TLSKey = docker.TLSKey
 	}
-	return "", fmt.Errorf("go.mod not found from %s", start)
+	// Validated with the definition, so an unset limit is the only failure
+	opts.MemoryBytes, _ = config.ParseMemorySize(def.Runtime.MemoryLimit)
+	opts.CPUs, _ = strconv.ParseFloat(def.Runtime.CPULimit, 64)
+	return opts
 }
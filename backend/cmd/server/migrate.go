@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+const migrateUsage = `Usage: server migrate [command]
+
+Commands:
+  up [version]   Apply pending migrations, optionally stopping after version (default)
+  down [n]       Roll back the last n applied migrations (default 1)
+  status         List applied and pending migrations`
+
+// runMigrations handles the migrate subcommands
+func runMigrations(cfg *config.Config, args []string) {
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+		args = args[1:]
+	}
+
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Println(migrateUsage)
+		return
+	}
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	switch command {
+	case "up":
+		target := ""
+		if len(args) > 0 {
+			target = args[0]
+		}
+		log.Println("Running database migrations...")
+		if err := db.MigrateTo(target); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		log.Println("Migrations completed successfully")
+
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			steps, err = strconv.Atoi(args[0])
+			if err != nil || steps <= 0 {
+				log.Fatalf("Invalid number of migrations to roll back: %s", args[0])
+			}
+		}
+		rolledBack, err := db.Rollback(steps)
+		for _, version := range rolledBack {
+			fmt.Printf("Rolled back migration: %s\n", version)
+		}
+		if err != nil {
+			log.Fatalf("Rollback failed: %v", err)
+		}
+		if len(rolledBack) == 0 {
+			log.Println("No applied migrations to roll back")
+		}
+
+	case "status":
+		states, err := db.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		printMigrationStatus(states)
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+func printMigrationStatus(states []database.MigrationState) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATUS\tAPPLIED AT")
+
+	pending := 0
+	for _, state := range states {
+		status := "pending"
+		switch {
+		case state.Unknown:
+			status = "applied (unknown to this build)"
+		case state.Modified:
+			status = "applied (modified)"
+		case state.Applied:
+			status = "applied"
+		default:
+			pending++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", state.Version, status, state.AppliedAt)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d pending migration(s)\n", pending)
+}
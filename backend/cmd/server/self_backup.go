@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/selfbackup"
+)
+
+const selfBackupUsage = `Usage: server self-backup <command>
+
+Commands:
+  create            Snapshot the manager database, config files and keys
+  list              List snapshots in the self-backup directory
+  verify <archive>  Check that a snapshot can be decrypted and restored
+  restore <archive> Restore a snapshot (stop the manager first)`
+
+// runSelfBackup handles the self-backup subcommands
+func runSelfBackup(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, selfBackupUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "create":
+		db, err := database.Open(cfg.Database)
+		if err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
+		}
+		defer db.Close()
+
+		snapshot, err := selfbackup.NewManager(cfg, db, config.GetConfigPath()).Create()
+		if err != nil {
+			log.Fatalf("Snapshot failed: %v", err)
+		}
+		fmt.Printf("Created %s (%d bytes) in %s\n", snapshot.Name, snapshot.SizeBytes, cfg.SelfBackup.Dir)
+
+	case "list":
+		snapshots, err := selfbackup.NewManager(cfg, nil, config.GetConfigPath()).List()
+		if err != nil {
+			log.Fatalf("Failed to list snapshots: %v", err)
+		}
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s\t%d bytes\n", snapshot.Name, snapshot.SizeBytes)
+		}
+
+	case "verify":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, selfBackupUsage)
+			os.Exit(2)
+		}
+
+		manifest, err := selfbackup.Verify(cfg, args[1])
+		if err != nil {
+			log.Fatalf("Snapshot is not restorable: %v", err)
+		}
+		for _, entry := range manifest.Entries {
+			fmt.Printf("%s\t%d bytes\n", entry.Name, entry.SizeBytes)
+		}
+		fmt.Printf("Snapshot from %s is valid\n", manifest.CreatedAt.Format(time.RFC3339))
+
+	case "restore":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, selfBackupUsage)
+			os.Exit(2)
+		}
+
+		result, err := selfbackup.Restore(cfg, config.GetConfigPath(), args[1])
+		if err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+
+		names := make([]string, 0, len(result.Restored))
+		for name := range result.Restored {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("Restored %s -> %s\n", name, result.Restored[name])
+		}
+		if !result.Manifest.DatabaseIncluded {
+			fmt.Printf("Snapshot did not include the %s database; restore it with its native tooling\n", result.Manifest.DatabaseDriver)
+		}
+		if result.EncryptionKeyPath != "" {
+			fmt.Printf("Set ENCRYPTION_KEY to the value in %s before starting the manager\n", result.EncryptionKeyPath)
+		}
+		fmt.Println("Previous files were kept with a .pre-restore-<timestamp> suffix")
+
+	default:
+		fmt.Fprintln(os.Stderr, selfBackupUsage)
+		os.Exit(2)
+	}
+}
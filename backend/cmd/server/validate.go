@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+const validateUsage = `Usage: server validate [--json]
+
+Checks config.yaml, servers.yaml and tasks.yaml without starting the server.
+Exits 1 when any error is found; warnings alone exit 0.`
+
+// runValidate checks the deployment configuration and returns the process exit code
+func runValidate(args []string) int {
+	asJSON := false
+	for _, arg := range args {
+		switch arg {
+		case "--json":
+			asJSON = true
+		case "help", "-h", "--help":
+			fmt.Println(validateUsage)
+			return 0
+		default:
+			fmt.Fprintln(os.Stderr, validateUsage)
+			return 2
+		}
+	}
+
+	configPath := config.GetConfigPath()
+	issues := []config.CheckIssue{}
+	cfg, err := config.Read()
+	if err != nil {
+		issues = append(issues, config.CheckIssue{Severity: config.CheckError, File: configPath, Message: err.Error()})
+	} else {
+		issues = config.CheckDeployment(cfg, configPath)
+	}
+
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == config.CheckError {
+			errorCount++
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(struct {
+			Valid      bool                `json:"valid"`
+			ConfigPath string              `json:"config_path"`
+			Issues     []config.CheckIssue `json:"issues"`
+		}{errorCount == 0, configPath, issues})
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, issue := range issues {
+			field := issue.Field
+			if field == "" {
+				field = "-"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", issue.Severity, issue.File, field, issue.Message)
+		}
+		writer.Flush()
+		fmt.Printf("%s: %d error(s), %d warning(s)\n", configPath, errorCount, len(issues)-errorCount)
+	}
+
+	if errorCount > 0 {
+		return 1
+	}
+	return 0
+}
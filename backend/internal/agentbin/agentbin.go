@@ -0,0 +1,177 @@
+// Package agentbin provides the hytale-agent binaries uploaded to game hosts.
+//
+// Release builds embed prebuilt binaries (see scripts/build-agent.sh), so the
+// manager host needs no Go toolchain. Development builds fall back to
+// compiling the agent from source with the go command. Either way each binary
+// has a <name>.sha256 file next to it, and Verify checks it before upload.
+package agentbin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Archs are the architectures the agent is built for
+var Archs = []string{"amd64", "arm64"}
+
+// dist holds binaries copied in by scripts/build-agent.sh before a release
+// build, along with a SHA256SUMS file. It is empty (apart from .gitkeep) in a
+// source checkout.
+//
+//go:embed all:dist
+var dist embed.FS
+
+// ErrChecksumMismatch is returned when a binary does not match its recorded checksum
+var ErrChecksumMismatch = errors.New("agent binary checksum mismatch")
+
+// Name returns the file name of the agent binary for arch
+func Name(arch string) string {
+	return "hytale-agent-linux-" + arch
+}
+
+// Dir returns the directory agent binaries are kept in
+func Dir(dataDir string) string {
+	return filepath.Join(dataDir, "agent-binaries")
+}
+
+// Path returns where the agent binary for arch is kept
+func Path(dataDir, arch string) string {
+	return filepath.Join(Dir(dataDir), Name(arch))
+}
+
+// Embedded reports whether this build carries a prebuilt binary for arch
+func Embedded(arch string) bool {
+	_, err := fs.Stat(dist, "dist/"+Name(arch))
+	return err == nil
+}
+
+// Extract writes the embedded binary for arch into dataDir, unless an
+// identical copy is already there. The embedded copy is checked against the
+// embedded SHA256SUMS first. It returns the binary's checksum.
+func Extract(dataDir, arch string) (string, error) {
+	data, err := dist.ReadFile("dist/" + Name(arch))
+	if err != nil {
+		return "", fmt.Errorf("no embedded agent binary for %s", arch)
+	}
+	sum := checksum(data)
+	if expected, ok := embeddedChecksum(Name(arch)); !ok || expected != sum {
+		return "", fmt.Errorf("embedded %s: %w", Name(arch), ErrChecksumMismatch)
+	}
+
+	path := Path(dataDir, arch)
+	if existing, err := Verify(path); err == nil && existing == sum {
+		return sum, nil
+	}
+	if err := os.MkdirAll(Dir(dataDir), 0755); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path+".sha256", []byte(sum+"  "+Name(arch)+"\n"), 0644); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// Build compiles the agent for arch from the module at modDir into dataDir
+// and records its checksum. It needs the go command on PATH.
+func Build(modDir, dataDir, arch string) (string, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		return "", fmt.Errorf("go toolchain not found and no embedded agent binary for %s", arch)
+	}
+	if err := os.MkdirAll(Dir(dataDir), 0755); err != nil {
+		return "", err
+	}
+
+	path := Path(dataDir, arch)
+	cmd := exec.Command("go", "build", "-o", path, "./agent")
+	cmd.Dir = modDir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+arch, "CGO_ENABLED=0")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("build %s: %w: %s", arch, err, out.String())
+	}
+	return WriteChecksum(path)
+}
+
+// WriteChecksum records the checksum of the binary at path in path.sha256
+func WriteChecksum(path string) (string, error) {
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return "", err
+	}
+	line := sum + "  " + filepath.Base(path) + "\n"
+	if err := os.WriteFile(path+".sha256", []byte(line), 0644); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// Verify checks the binary at path against path.sha256 and returns its checksum
+func Verify(path string) (string, error) {
+	recorded, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return "", fmt.Errorf("read checksum: %w", err)
+	}
+	fields := strings.Fields(string(recorded))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%s: %w", filepath.Base(path), ErrChecksumMismatch)
+	}
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return "", err
+	}
+	if sum != fields[0] {
+		return "", fmt.Errorf("%s: %w", filepath.Base(path), ErrChecksumMismatch)
+	}
+	return sum, nil
+}
+
+func embeddedChecksum(name string) (string, bool) {
+	sums, err := dist.ReadFile("dist/SHA256SUMS")
+	if err != nil {
+		return "", false
+	}
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,36 @@
+package agentbin
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestVerifyDetectsModifiedBinary(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(Dir(dataDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := Path(dataDir, "amd64")
+	if err := os.WriteFile(path, []byte("agent v1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Verify(path); err == nil {
+		t.Fatal("expected verification to fail without a recorded checksum")
+	}
+	sum, err := WriteChecksum(path)
+	if err != nil {
+		t.Fatalf("write checksum: %v", err)
+	}
+	if got, err := Verify(path); err != nil || got != sum {
+		t.Fatalf("expected %s, got %s (%v)", sum, got, err)
+	}
+
+	if err := os.WriteFile(path, []byte("agent v2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(path); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+}
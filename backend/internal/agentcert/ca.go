@@ -158,6 +158,10 @@ func generateCA(certPath, keyPath string) (*CA, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create ca cert: %w", err)
 	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse ca cert: %w", err)
+	}
 
 	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
 	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
@@ -169,7 +173,7 @@ func generateCA(certPath, keyPath string) (*CA, error) {
 		return nil, fmt.Errorf("write ca key: %w", err)
 	}
 
-	return &CA{Cert: tmpl, Key: key, CertPEM: certPEM, KeyPEM: keyPEM, CertPath: certPath, KeyPath: keyPath}, nil
+	return &CA{Cert: cert, Key: key, CertPEM: certPEM, KeyPEM: keyPEM, CertPath: certPath, KeyPath: keyPath}, nil
 }
 
 func fileExists(path string) bool {
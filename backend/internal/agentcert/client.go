@@ -0,0 +1,47 @@
+package agentcert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ManagerClientName is the client certificate the manager presents to agents
+const ManagerClientName = "server-manager"
+
+// ClientTLSConfig returns the TLS settings the manager calls agents with: its
+// client certificate, trusting only the agent CA kept under dataDir and only
+// agent certificates that were not revoked
+func ClientTLSConfig(db *sql.DB, dataDir string) (*tls.Config, error) {
+	clientCert, err := GetClientCert(db, ManagerClientName)
+	if err != nil {
+		return nil, fmt.Errorf("load manager client cert: %w", err)
+	}
+	if clientCert == nil {
+		return nil, errors.New("manager client cert not found; install the agent first")
+	}
+	cert, err := tls.X509KeyPair(clientCert.CertPEM, clientCert.KeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manager client cert: %w", err)
+	}
+
+	caData, err := os.ReadFile(filepath.Join(dataDir, "agent-ca", "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("read agent CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, errors.New("invalid agent CA")
+	}
+
+	return &tls.Config{
+		MinVersion:            tls.VersionTLS12,
+		RootCAs:               pool,
+		Certificates:          []tls.Certificate{cert},
+		VerifyPeerCertificate: RejectRevoked(db),
+	}, nil
+}
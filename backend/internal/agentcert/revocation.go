@@ -0,0 +1,166 @@
+package agentcert
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Kinds of certificate issued for an agent host
+const (
+	// KindHTTPS is the certificate the agent serves its state endpoint with
+	KindHTTPS = "https"
+	// KindClient is the certificate the agent pushes its events with
+	KindClient = "client"
+)
+
+// ErrCertNotFound is returned when revoking a certificate the server has no
+// unrevoked record of
+var ErrCertNotFound = errors.New("certificate not found or already revoked")
+
+// Certificate is an HTTPS or client certificate issued to a server's agent
+type Certificate struct {
+	Kind        string     `json:"kind"`
+	ServerID    string     `json:"server_id"`
+	HostUUID    string     `json:"host_uuid"`
+	Serial      string     `json:"serial"`
+	Fingerprint string     `json:"fingerprint"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the certificate is neither revoked nor expired
+func (c Certificate) Active(now time.Time) bool {
+	return c.RevokedAt == nil && now.Before(c.ExpiresAt)
+}
+
+// ListCertificates returns the certificates issued for a server's agent,
+// newest first
+func ListCertificates(db *sql.DB, serverID string) ([]Certificate, error) {
+	certs := make([]Certificate, 0)
+	for kind, table := range map[string]string{KindHTTPS: "agent_https_certs", KindClient: "agent_certificates"} {
+		rows, err := db.Query(`
+			SELECT server_id, host_uuid, serial, fingerprint, issued_at, expires_at, revoked_at
+			FROM `+table+` WHERE server_id = ?
+		`, serverID)
+		if err != nil {
+			return nil, fmt.Errorf("list agent certs: %w", err)
+		}
+		for rows.Next() {
+			cert := Certificate{Kind: kind}
+			var revokedAt sql.NullTime
+			if err := rows.Scan(&cert.ServerID, &cert.HostUUID, &cert.Serial, &cert.Fingerprint,
+				&cert.IssuedAt, &cert.ExpiresAt, &revokedAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan agent cert: %w", err)
+			}
+			if revokedAt.Valid {
+				cert.RevokedAt = &revokedAt.Time
+			}
+			certs = append(certs, cert)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].IssuedAt.After(certs[j].IssuedAt)
+	})
+	return certs, nil
+}
+
+// Latest returns the newest unrevoked certificate of a kind issued for a
+// server's agent, or nil when there is none
+func Latest(certs []Certificate, kind string) *Certificate {
+	for i := range certs {
+		if certs[i].Kind == kind && certs[i].RevokedAt == nil {
+			return &certs[i]
+		}
+	}
+	return nil
+}
+
+// Revoke revokes one of a server's agent certificates by serial. The manager
+// stops trusting a revoked HTTPS certificate and refuses event streams from an
+// agent presenting a revoked client certificate.
+func Revoke(db *sql.DB, serverID, serial string) error {
+	now := time.Now()
+	var revoked int64
+	for _, table := range []string{"agent_https_certs", "agent_certificates"} {
+		result, err := db.Exec(`UPDATE `+table+` SET revoked_at = ? WHERE server_id = ? AND serial = ? AND revoked_at IS NULL`,
+			now, serverID, serial)
+		if err != nil {
+			return fmt.Errorf("revoke agent cert: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		revoked += affected
+	}
+	if revoked == 0 {
+		return ErrCertNotFound
+	}
+	return nil
+}
+
+// IsRevoked reports whether an agent certificate with the serial was revoked
+func IsRevoked(db *sql.DB, serial string) (bool, error) {
+	var revoked bool
+	err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM agent_https_certs WHERE serial = ? AND revoked_at IS NOT NULL)
+			OR EXISTS (SELECT 1 FROM agent_certificates WHERE serial = ? AND revoked_at IS NOT NULL)
+	`, serial, serial).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("check agent cert revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// RejectRevoked returns a tls.Config.VerifyPeerCertificate callback that
+// fails the handshake with an agent presenting a revoked certificate. It runs
+// after the chain was verified against the agent CA.
+func RejectRevoked(db *sql.DB) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+			return errors.New("agent presented no certificate")
+		}
+		serial := fmt.Sprintf("%x", verifiedChains[0][0].SerialNumber)
+		revoked, err := IsRevoked(db, serial)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return fmt.Errorf("agent certificate %s has been revoked", serial)
+		}
+		return nil
+	}
+}
+
+// SaveClientCert stores the manager's client certificate, replacing the one
+// it renews
+func SaveClientCert(tx *sql.Tx, name, serial, fingerprint string, certPEM, keyPEM []byte, expiresAt time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO agent_client_certs (name, serial, fingerprint, cert_pem, key_pem, issued_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, datetime('now'), ?)
+		ON CONFLICT(name) DO UPDATE SET
+			serial = excluded.serial,
+			fingerprint = excluded.fingerprint,
+			cert_pem = excluded.cert_pem,
+			key_pem = excluded.key_pem,
+			issued_at = excluded.issued_at,
+			expires_at = excluded.expires_at,
+			revoked_at = NULL
+	`, name, serial, fingerprint, string(certPEM), string(keyPEM), expiresAt)
+	if err != nil {
+		return fmt.Errorf("save client cert: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package agentcert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestRevokeRejectsCertificate(t *testing.T) {
+	root := t.TempDir()
+	db, err := database.NewDB(filepath.Join(root, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	ca, err := LoadOrCreateCA(filepath.Join(root, "agent-ca"))
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	certPEM, keyPEM, serial, notAfter, fingerprint, err := IssueServerCert(ca, "127.0.0.1", "srv-1", "host-1", time.Hour)
+	if err != nil {
+		t.Fatalf("failed to issue cert: %v", err)
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertHTTPSCertificate(tx, "srv-1", "host-1", serial, fingerprint, certPEM, keyPEM, notAfter); err != nil {
+		t.Fatalf("failed to store cert: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse cert: %v", err)
+	}
+	chains := [][]*x509.Certificate{{leaf, ca.Cert}}
+	verify := RejectRevoked(db.DB)
+	if err := verify(nil, chains); err != nil {
+		t.Fatalf("expected unrevoked cert to pass, got %v", err)
+	}
+
+	certs, err := ListCertificates(db.DB, "srv-1")
+	if err != nil {
+		t.Fatalf("failed to list certs: %v", err)
+	}
+	if len(certs) != 1 || certs[0].Kind != KindHTTPS || Latest(certs, KindHTTPS) == nil {
+		t.Fatalf("unexpected certs: %+v", certs)
+	}
+
+	if err := Revoke(db.DB, "srv-2", serial); !errors.Is(err, ErrCertNotFound) {
+		t.Fatalf("expected another server's revoke to fail, got %v", err)
+	}
+	if err := Revoke(db.DB, "srv-1", serial); err != nil {
+		t.Fatalf("failed to revoke: %v", err)
+	}
+	if err := Revoke(db.DB, "srv-1", serial); !errors.Is(err, ErrCertNotFound) {
+		t.Fatalf("expected second revoke to fail, got %v", err)
+	}
+
+	if revoked, err := IsRevoked(db.DB, serial); err != nil || !revoked {
+		t.Fatalf("expected cert to be revoked, got %v, %v", revoked, err)
+	}
+	if err := verify(nil, chains); err == nil {
+		t.Fatal("expected revoked cert to be rejected")
+	}
+	certs, err = ListCertificates(db.DB, "srv-1")
+	if err != nil {
+		t.Fatalf("failed to list certs: %v", err)
+	}
+	if certs[0].RevokedAt == nil || Latest(certs, KindHTTPS) != nil {
+		t.Fatalf("expected cert to be listed as revoked: %+v", certs)
+	}
+}
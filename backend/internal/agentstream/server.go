@@ -0,0 +1,344 @@
+// Package agentstream receives the events agents push to the manager. Each
+// agent keeps one gRPC stream open, authenticated with the client certificate
+// issued to it on install, and sends its whole state with every event, so
+// the manager can read a host's services, ports and Java processes without
+// polling the agent.
+package agentstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/TheGojiOG/HytaleSM/agent/transport"
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("agentstream")
+
+// StaleAfter is how long a stream may stay silent before its state is no
+// longer used; agents send a heartbeat every few seconds
+const StaleAfter = 30 * time.Second
+
+// ErrUnknownAgent is returned for client certificates that were not issued
+// to an agent or were revoked
+var ErrUnknownAgent = errors.New("unknown agent certificate")
+
+// Update is a message an agent pushed, with the servers on its host
+type Update struct {
+	HostUUID  string
+	ServerIDs []string
+	Type      string
+	Event     *transport.Event
+	// State is the agent's state as JSON, the same document its /state endpoint serves
+	State json.RawMessage
+}
+
+// Agent describes a connected agent
+type Agent struct {
+	HostUUID    string    `json:"host_uuid"`
+	ServerIDs   []string  `json:"server_ids"`
+	Version     string    `json:"version,omitempty"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+type connection struct {
+	id    uint64
+	agent Agent
+	state json.RawMessage
+}
+
+// Server accepts agent streams and keeps the last state of each connected host
+type Server struct {
+	db *sql.DB
+	ca *agentcert.CA
+
+	mu           sync.RWMutex
+	hosts        map[string]*connection
+	nextID       uint64
+	listeners    []func(Update)
+	disconnected []func(Agent)
+	stopping     bool
+
+	certMu sync.Mutex
+	certs  map[string]*tls.Certificate
+}
+
+// NewServer creates a server authenticating agents against the certificates
+// recorded in db, which ca issued
+func NewServer(db *sql.DB, ca *agentcert.CA) *Server {
+	return &Server{
+		db:    db,
+		ca:    ca,
+		hosts: make(map[string]*connection),
+		certs: make(map[string]*tls.Certificate),
+	}
+}
+
+// OnUpdate registers fn to be called with every message an agent pushes.
+// It is called on the agent's stream, so it must not block.
+func (s *Server) OnUpdate(fn func(Update)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// OnDisconnect registers fn to be called when an agent's stream closes and
+// the agent has not reconnected in the meantime. It is not called for the
+// streams closed while the server stops.
+func (s *Server) OnDisconnect(fn func(Agent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnected = append(s.disconnected, fn)
+}
+
+// State returns the last state pushed by the agent on a server's host, while
+// its stream is up and not stale
+func (s *Server) State(serverID string) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, conn := range s.hosts {
+		if len(conn.state) == 0 || time.Since(conn.agent.LastSeen) > StaleAfter {
+			continue
+		}
+		for _, id := range conn.agent.ServerIDs {
+			if id == serverID {
+				return conn.state, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Agents returns the connected agents, by host UUID
+func (s *Server) Agents() []Agent {
+	s.mu.RLock()
+	agents := make([]Agent, 0, len(s.hosts))
+	for _, conn := range s.hosts {
+		agent := conn.agent
+		agent.ServerIDs = append([]string{}, agent.ServerIDs...)
+		agents = append(agents, agent)
+	}
+	s.mu.RUnlock()
+	sort.Slice(agents, func(i, j int) bool { return agents[i].HostUUID < agents[j].HostUUID })
+	return agents
+}
+
+// ListenAndServe accepts agent streams on addr until ctx is done
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen for agent streams: %w", err)
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve accepts agent streams on listener until ctx is done
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca.Cert)
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+		ClientCAs:      pool,
+		GetCertificate: s.certificate,
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: transport.ServiceName,
+		HandlerType: (*any)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    transport.StreamName,
+			Handler:       func(_ any, stream grpc.ServerStream) error { return s.handle(stream) },
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+	}, s)
+
+	stop := context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		s.stopping = true
+		s.mu.Unlock()
+		server.Stop()
+	})
+	defer stop()
+	logger.Info("Accepting agent streams", "addr", listener.Addr().String())
+	if err := server.Serve(listener); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("serve agent streams: %w", err)
+	}
+	return nil
+}
+
+// certificate issues the manager's server certificate for the name the agent
+// dialed, or for the address it connected to when it dialed an IP
+func (s *Server) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := hello.ServerName
+	if name == "" && hello.Conn != nil {
+		if host, _, err := net.SplitHostPort(hello.Conn.LocalAddr().String()); err == nil {
+			name = host
+		}
+	}
+
+	s.certMu.Lock()
+	defer s.certMu.Unlock()
+	if cert, ok := s.certs[name]; ok && time.Until(cert.Leaf.NotAfter) > 24*time.Hour {
+		return cert, nil
+	}
+	certPEM, keyPEM, _, _, _, err := agentcert.IssueServerCert(s.ca, name, "hytale-manager", "", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, err
+		}
+	}
+	s.certs[name] = &cert
+	return &cert, nil
+}
+
+func (s *Server) handle(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	remote, ok := peer.FromContext(ctx)
+	if !ok {
+		return ErrUnknownAgent
+	}
+	info, ok := remote.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.PeerCertificates) == 0 {
+		return ErrUnknownAgent
+	}
+	hostUUID, serverIDs, err := s.lookupAgent(ctx, info.State.PeerCertificates[0])
+	if err != nil {
+		logger.Warn("Rejected agent stream", "remote_addr", remote.Addr.String(), "error", err)
+		return err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.nextID++
+	conn := &connection{id: s.nextID, agent: Agent{
+		HostUUID:    hostUUID,
+		ServerIDs:   serverIDs,
+		RemoteAddr:  remote.Addr.String(),
+		ConnectedAt: now,
+		LastSeen:    now,
+	}}
+	s.hosts[hostUUID] = conn
+	s.mu.Unlock()
+	logger.Info("Agent stream connected", "host_uuid", hostUUID, "servers", serverIDs, "remote_addr", remote.Addr.String())
+
+	defer func() {
+		var listeners []func(Agent)
+		s.mu.Lock()
+		// A reconnect may have replaced this stream already
+		if current, ok := s.hosts[hostUUID]; ok && current.id == conn.id {
+			delete(s.hosts, hostUUID)
+			if !s.stopping {
+				listeners = append(listeners, s.disconnected...)
+			}
+		}
+		agent := conn.agent
+		s.mu.Unlock()
+		logger.Info("Agent stream closed", "host_uuid", hostUUID)
+
+		for _, fn := range listeners {
+			fn(agent)
+		}
+	}()
+
+	for {
+		msg := new(structpb.Struct)
+		if err := stream.RecvMsg(msg); err != nil {
+			return nil
+		}
+		message, err := transport.DecodeMessage(msg.AsMap())
+		if err != nil {
+			logger.Warn("Dropped malformed agent message", "host_uuid", hostUUID, "error", err)
+			continue
+		}
+		s.apply(conn, message)
+	}
+}
+
+// apply records a message and passes it to the listeners
+func (s *Server) apply(conn *connection, message transport.Message) {
+	s.mu.Lock()
+	conn.agent.LastSeen = time.Now()
+	if message.Version != "" {
+		conn.agent.Version = message.Version
+	}
+	if len(message.State) > 0 {
+		conn.state = message.State
+	}
+	update := Update{
+		HostUUID:  conn.agent.HostUUID,
+		ServerIDs: conn.agent.ServerIDs,
+		Type:      message.Type,
+		Event:     message.Event,
+		State:     conn.state,
+	}
+	listeners := append([]func(Update){}, s.listeners...)
+	s.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(update)
+	}
+}
+
+// lookupAgent finds the host a client certificate was issued to and the
+// servers installed on that host
+func (s *Server) lookupAgent(ctx context.Context, cert *x509.Certificate) (string, []string, error) {
+	serial := fmt.Sprintf("%x", cert.SerialNumber)
+	var hostUUID string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT host_uuid FROM agent_certificates
+		WHERE serial = ? AND revoked_at IS NULL
+		ORDER BY issued_at DESC LIMIT 1
+	`, serial).Scan(&hostUUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrUnknownAgent
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to look up agent certificate: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT server_id FROM agent_certificates
+		WHERE host_uuid = ? AND revoked_at IS NULL
+		ORDER BY server_id
+	`, hostUUID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list servers of agent host: %w", err)
+	}
+	defer rows.Close()
+	serverIDs := make([]string, 0)
+	for rows.Next() {
+		var serverID string
+		if err := rows.Scan(&serverID); err != nil {
+			return "", nil, fmt.Errorf("failed to scan agent server: %w", err)
+		}
+		serverIDs = append(serverIDs, serverID)
+	}
+	return hostUUID, serverIDs, rows.Err()
+}
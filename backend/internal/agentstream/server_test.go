@@ -0,0 +1,115 @@
+package agentstream
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/agent/transport"
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestAgentStreamPushesState(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	ca, err := agentcert.LoadOrCreateCA(filepath.Join(dir, "agent-ca"))
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+
+	// Only the first certificate is recorded as issued to an agent
+	writeCert := func(name string, record bool) (string, string) {
+		certPEM, keyPEM, serial, notAfter, fingerprint, err := agentcert.IssueAgentCert(ca, "host-1", "srv-1", time.Hour)
+		if err != nil {
+			t.Fatalf("issue cert: %v", err)
+		}
+		if record {
+			tx, err := db.DB.Begin()
+			if err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+			if err := agentcert.InsertCertificate(tx, "srv-1", "host-1", serial, fingerprint, certPEM, notAfter); err != nil {
+				t.Fatalf("insert cert: %v", err)
+			}
+			if err := tx.Commit(); err != nil {
+				t.Fatalf("commit: %v", err)
+			}
+		}
+		certFile := filepath.Join(dir, name+".crt")
+		keyFile := filepath.Join(dir, name+".key")
+		if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return certFile, keyFile
+	}
+	caFile := filepath.Join(dir, "agent-ca", "ca.crt")
+	certFile, keyFile := writeCert("agent", true)
+	strayCert, strayKey := writeCert("stray", false)
+
+	server := NewServer(db.DB, ca)
+	updates := make(chan Update, 8)
+	server.OnUpdate(func(update Update) { updates <- update })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Serve(ctx, listener)
+
+	hello := func() transport.Message {
+		return transport.Message{Type: transport.MessageHello, Version: "test", State: []byte(`{"host_uuid":"host-1"}`)}
+	}
+	go transport.NewClient(listener.Addr().String(), certFile, keyFile, caFile).Stream(ctx, hello, nil, nil)
+
+	select {
+	case update := <-updates:
+		if update.HostUUID != "host-1" || len(update.ServerIDs) != 1 || update.ServerIDs[0] != "srv-1" || update.Type != transport.MessageHello {
+			t.Fatalf("unexpected update %+v", update)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the agent's hello")
+	}
+	if state, ok := server.State("srv-1"); !ok || string(state) != `{"host_uuid":"host-1"}` {
+		t.Fatalf("expected the pushed state, got %s %v", state, ok)
+	}
+	if _, ok := server.State("srv-2"); ok {
+		t.Fatal("expected no state for a server on another host")
+	}
+
+	stray := transport.NewClient(listener.Addr().String(), strayCert, strayKey, caFile)
+	errs := make(chan error, 1)
+	strayCtx, stopStray := context.WithCancel(ctx)
+	defer stopStray()
+	go stray.Stream(strayCtx, hello, nil, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	select {
+	case <-errs:
+	case update := <-updates:
+		t.Fatalf("expected an unrecorded certificate to be rejected, got %+v", update)
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the stray agent's stream to be closed")
+	}
+	if agents := server.Agents(); len(agents) != 1 || agents[0].Version != "test" {
+		t.Fatalf("expected one connected agent, got %+v", agents)
+	}
+}
@@ -0,0 +1,187 @@
+// Package alerts raises alerts when a server's metrics cross the thresholds
+// of alert rules. The metrics collector hands every sample to an Evaluator,
+// which fires an alert once a rule's condition has held for the rule's
+// duration and resolves it when the condition clears.
+package alerts
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("alerts")
+
+// Metrics rules can watch. The percentages are derived from the used and
+// total readings.
+const (
+	MetricCPU           = "cpu_usage"
+	MetricMemoryPercent = "memory_percent"
+	MetricDiskPercent   = "disk_percent"
+	MetricMemoryUsed    = "memory_used"
+	MetricDiskUsed      = "disk_used"
+	MetricLoad1         = "load1"
+	MetricNetworkRx     = "network_rx"
+	MetricNetworkTx     = "network_tx"
+)
+
+// Metrics lists every metric a rule can watch
+var Metrics = []string{MetricCPU, MetricMemoryPercent, MetricDiskPercent, MetricMemoryUsed, MetricDiskUsed, MetricLoad1, MetricNetworkRx, MetricNetworkTx}
+
+// Comparators lists how a reading can be compared with a threshold
+var Comparators = []string{">", ">=", "<", "<="}
+
+// Severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Severities lists every severity, least severe first
+var Severities = []string{SeverityInfo, SeverityWarning, SeverityCritical}
+
+// Alert states
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+const defaultDuration = 5 * time.Minute
+
+// Rule raises an alert when Metric compared with Threshold holds for Duration
+type Rule struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Metric     string    `json:"metric"`
+	Comparator string    `json:"comparator"`
+	Threshold  float64   `json:"threshold"`
+	Duration   string    `json:"duration"`
+	Severity   string    `json:"severity"`
+	ServerIDs  []string  `json:"server_ids"` // empty applies to every server
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Alert is one period in which a rule's condition held for a server
+type Alert struct {
+	ID         string     `json:"id"`
+	RuleID     string     `json:"rule_id"`
+	RuleName   string     `json:"rule_name"`
+	ServerID   string     `json:"server_id"`
+	Metric     string     `json:"metric"`
+	Comparator string     `json:"comparator"`
+	Threshold  float64    `json:"threshold"`
+	Severity   string     `json:"severity"`
+	State      string     `json:"state"`
+	Value      float64    `json:"value"` // the reading that fired or resolved it
+	StartedAt  time.Time  `json:"started_at"`
+	FiredAt    time.Time  `json:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Normalize trims fields and fills in defaults
+func (r *Rule) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Metric = strings.TrimSpace(r.Metric)
+	r.Comparator = strings.TrimSpace(r.Comparator)
+	r.Severity = strings.ToLower(strings.TrimSpace(r.Severity))
+	if r.Severity == "" {
+		r.Severity = SeverityWarning
+	}
+	if strings.TrimSpace(r.Duration) == "" {
+		r.Duration = defaultDuration.String()
+	}
+	if r.ServerIDs == nil {
+		r.ServerIDs = []string{}
+	}
+}
+
+// Validate checks the rule's metric, comparator, duration and severity
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !slices.Contains(Metrics, r.Metric) {
+		return fmt.Errorf("metric must be one of %s", strings.Join(Metrics, ", "))
+	}
+	if !slices.Contains(Comparators, r.Comparator) {
+		return fmt.Errorf("comparator must be one of %s", strings.Join(Comparators, ", "))
+	}
+	if d, err := time.ParseDuration(r.Duration); err != nil || d < 0 {
+		return fmt.Errorf("invalid duration %q", r.Duration)
+	}
+	if !slices.Contains(Severities, r.Severity) {
+		return fmt.Errorf("severity must be one of %s", strings.Join(Severities, ", "))
+	}
+	return nil
+}
+
+// AppliesTo reports whether the rule watches a server
+func (r *Rule) AppliesTo(serverID string) bool {
+	return r.Enabled && (len(r.ServerIDs) == 0 || slices.Contains(r.ServerIDs, serverID))
+}
+
+// Breached reports whether a reading meets the rule's condition
+func (r *Rule) Breached(value float64) bool {
+	switch r.Comparator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	}
+	return false
+}
+
+func (r *Rule) duration() time.Duration {
+	d, err := time.ParseDuration(r.Duration)
+	if err != nil || d < 0 {
+		return defaultDuration
+	}
+	return d
+}
+
+// MetricValue reads a metric from a collected sample
+func MetricValue(values map[string]interface{}, metric string) (float64, bool) {
+	switch metric {
+	case MetricMemoryPercent:
+		return percent(values, "memory_used", "memory_total")
+	case MetricDiskPercent:
+		return percent(values, "disk_used", "disk_total")
+	}
+	return number(values[metric])
+}
+
+func percent(values map[string]interface{}, usedKey, totalKey string) (float64, bool) {
+	used, ok := number(values[usedKey])
+	if !ok {
+		return 0, false
+	}
+	total, ok := number(values[totalKey])
+	if !ok || total <= 0 {
+		return 0, false
+	}
+	return used / total * 100, true
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package alerts
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestEvaluatorFiresAfterDurationAndResolves(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "alerts.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	ctx := context.Background()
+	store := NewStore(db.DB)
+	rule := &Rule{ID: "mem", Name: "Memory nearly full", Metric: MetricMemoryPercent, Comparator: ">=", Threshold: 90, Duration: "2m", Enabled: true}
+	rule.Normalize()
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := store.SaveRule(ctx, rule); err != nil {
+		t.Fatalf("save rule: %v", err)
+	}
+
+	evaluator := NewEvaluator(store)
+	var changes []Alert
+	evaluator.OnChange(func(alert Alert) { changes = append(changes, alert) })
+	memory := func(used int64) map[string]interface{} {
+		return map[string]interface{}{"memory_used": used, "memory_total": int64(1000)}
+	}
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	evaluator.Evaluate(ctx, "alpha", memory(950), start)
+	evaluator.Evaluate(ctx, "alpha", memory(960), start.Add(time.Minute))
+	if len(changes) != 0 {
+		t.Fatalf("expected the alert to stay pending for the duration, got %+v", changes)
+	}
+	evaluator.Evaluate(ctx, "alpha", map[string]interface{}{"cpu_usage": 10.0}, start.Add(90*time.Second))
+	evaluator.Evaluate(ctx, "alpha", memory(970), start.Add(2*time.Minute))
+	if len(changes) != 1 || changes[0].State != StateFiring || changes[0].Value != 97 || !changes[0].StartedAt.Equal(start) {
+		t.Fatalf("expected the alert to fire after two minutes, got %+v", changes)
+	}
+	evaluator.Evaluate(ctx, "alpha", memory(980), start.Add(3*time.Minute))
+	if len(changes) != 1 {
+		t.Fatalf("expected a firing alert to fire once, got %d changes", len(changes))
+	}
+
+	firing, err := store.ListAlerts(ctx, AlertFilter{State: StateFiring})
+	if err != nil || len(firing) != 1 || firing[0].ServerID != "alpha" {
+		t.Fatalf("expected one firing alert, got %+v (%v)", firing, err)
+	}
+
+	evaluator.Evaluate(ctx, "alpha", memory(500), start.Add(4*time.Minute))
+	if len(changes) != 2 || changes[1].State != StateResolved || changes[1].ResolvedAt == nil {
+		t.Fatalf("expected the alert to resolve, got %+v", changes)
+	}
+	if firing, _ := store.ListAlerts(ctx, AlertFilter{State: StateFiring}); len(firing) != 0 {
+		t.Fatalf("expected no firing alerts, got %+v", firing)
+	}
+
+	// Pending time starts over once the condition clears
+	evaluator.Evaluate(ctx, "alpha", memory(990), start.Add(5*time.Minute))
+	evaluator.Evaluate(ctx, "alpha", memory(990), start.Add(6*time.Minute))
+	if len(changes) != 2 {
+		t.Fatalf("expected a new breach to wait for the duration again, got %+v", changes)
+	}
+	evaluator.Evaluate(ctx, "alpha", memory(990), start.Add(7*time.Minute))
+	if len(changes) != 3 {
+		t.Fatalf("expected the alert to fire again, got %+v", changes)
+	}
+	if err := store.DeleteRule(ctx, "mem"); err != nil {
+		t.Fatalf("delete rule: %v", err)
+	}
+	if firing, _ := store.ListAlerts(ctx, AlertFilter{State: StateFiring}); len(firing) != 0 {
+		t.Fatalf("expected deleting the rule to resolve its alerts, got %+v", firing)
+	}
+}
+
+func TestRuleValidation(t *testing.T) {
+	rule := &Rule{Name: "cpu", Metric: MetricCPU, Comparator: ">", Threshold: 90}
+	rule.Normalize()
+	if err := rule.Validate(); err != nil || rule.Severity != SeverityWarning || rule.Duration != "5m0s" {
+		t.Fatalf("expected defaults to make the rule valid, got %+v (%v)", rule, err)
+	}
+	for _, bad := range []Rule{
+		{Name: "x", Metric: "fan_speed", Comparator: ">"},
+		{Name: "x", Metric: MetricCPU, Comparator: "=>"},
+		{Name: "x", Metric: MetricCPU, Comparator: ">", Duration: "-1m"},
+		{Name: "x", Metric: MetricCPU, Comparator: ">", Severity: "panic"},
+	} {
+		bad.Normalize()
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+	if _, ok := MetricValue(map[string]interface{}{"disk_used": int64(5)}, MetricDiskPercent); ok {
+		t.Fatalf("expected no disk percentage without a total")
+	}
+}
@@ -0,0 +1,110 @@
+package alerts
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Evaluator checks metrics samples against the stored rules. A breached
+// rule is pending until its condition has held for the rule's duration and
+// then fires; it resolves on the first sample that no longer breaches it.
+// Pending conditions are kept in memory, so they start over after a restart
+// or when another instance starts leading; firing alerts are stored.
+type Evaluator struct {
+	store *Store
+
+	mu        sync.Mutex
+	pending   map[string]time.Time
+	listeners []func(Alert)
+}
+
+// NewEvaluator creates an evaluator for the rules in store
+func NewEvaluator(store *Store) *Evaluator {
+	return &Evaluator{store: store, pending: make(map[string]time.Time)}
+}
+
+// OnChange registers fn to be called whenever an alert fires or resolves
+func (e *Evaluator) OnChange(fn func(Alert)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// Evaluate checks one sample of a server's metrics. A nil Evaluator does
+// nothing.
+func (e *Evaluator) Evaluate(ctx context.Context, serverID string, values map[string]interface{}, now time.Time) {
+	if e == nil {
+		return
+	}
+	rules, err := e.store.ListRules(ctx)
+	if err != nil {
+		logger.Error("Failed to load alert rules", "error", err)
+		return
+	}
+	for _, rule := range rules {
+		value, ok := MetricValue(values, rule.Metric)
+		if !ok {
+			continue
+		}
+		key := rule.ID + "/" + serverID
+		breached := rule.AppliesTo(serverID) && rule.Breached(value)
+
+		e.mu.Lock()
+		since, pending := e.pending[key]
+		if !breached {
+			delete(e.pending, key)
+		} else if !pending {
+			since = now
+			e.pending[key] = now
+		}
+		e.mu.Unlock()
+
+		firing, err := e.store.FiringAlert(ctx, rule.ID, serverID)
+		if err != nil {
+			logger.Error("Failed to load firing alert", "rule", rule.Name, "server_id", serverID, "error", err)
+			continue
+		}
+		switch {
+		case breached && firing == nil && now.Sub(since) >= rule.duration():
+			alert := &Alert{
+				ID:         uuid.New().String(),
+				RuleID:     rule.ID,
+				RuleName:   rule.Name,
+				ServerID:   serverID,
+				Metric:     rule.Metric,
+				Comparator: rule.Comparator,
+				Threshold:  rule.Threshold,
+				Severity:   rule.Severity,
+				State:      StateFiring,
+				Value:      value,
+				StartedAt:  since.UTC(),
+				FiredAt:    now.UTC(),
+			}
+			e.save(ctx, alert)
+		case !breached && firing != nil:
+			resolvedAt := now.UTC()
+			firing.State = StateResolved
+			firing.Value = value
+			firing.ResolvedAt = &resolvedAt
+			e.save(ctx, firing)
+		}
+	}
+}
+
+func (e *Evaluator) save(ctx context.Context, alert *Alert) {
+	if err := e.store.SaveAlert(ctx, alert); err != nil {
+		logger.Error("Failed to save alert", "rule", alert.RuleName, "server_id", alert.ServerID, "error", err)
+		return
+	}
+	logger.Info("Alert "+alert.State, "rule", alert.RuleName, "server_id", alert.ServerID, "value", alert.Value)
+
+	e.mu.Lock()
+	listeners := append([]func(Alert){}, e.listeners...)
+	e.mu.Unlock()
+	for _, fn := range listeners {
+		fn(*alert)
+	}
+}
@@ -0,0 +1,244 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for unknown rule IDs
+var ErrNotFound = errors.New("alert rule not found")
+
+// ErrNameTaken is returned when another rule has the same name
+var ErrNameTaken = errors.New("an alert rule with this name already exists")
+
+// Store persists alert rules and alerts. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new alert store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const ruleColumns = `id, name, metric, comparator, threshold, duration, severity, server_ids, enabled, created_at, updated_at`
+
+const alertColumns = `id, rule_id, rule_name, server_id, metric, comparator, threshold, severity, state, value, started_at, fired_at, resolved_at`
+
+// AlertFilter selects alerts; empty fields match everything
+type AlertFilter struct {
+	State    string
+	ServerID string
+	RuleID   string
+	Limit    int
+}
+
+// GetRule returns one rule
+func (s *Store) GetRule(ctx context.Context, id string) (*Rule, error) {
+	rule, err := scanRule(s.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM alert_rules WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alert rule: %w", err)
+	}
+	return rule, nil
+}
+
+// ListRules returns every rule, by name
+func (s *Store) ListRules(ctx context.Context) ([]*Rule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+ruleColumns+` FROM alert_rules ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*Rule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveRule creates or updates a rule
+func (s *Store) SaveRule(ctx context.Context, rule *Rule) error {
+	now := time.Now().UTC()
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = now
+	}
+	rule.UpdatedAt = now
+
+	var taken string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM alert_rules WHERE name = ? AND id != ?`, rule.Name, rule.ID).Scan(&taken)
+	if err == nil {
+		return ErrNameTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check alert rule name: %w", err)
+	}
+	serverIDs, err := json.Marshal(rule.ServerIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode server ids: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO alert_rules (`+ruleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			metric = excluded.metric,
+			comparator = excluded.comparator,
+			threshold = excluded.threshold,
+			duration = excluded.duration,
+			severity = excluded.severity,
+			server_ids = excluded.server_ids,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`, rule.ID, rule.Name, rule.Metric, rule.Comparator, rule.Threshold, rule.Duration, rule.Severity, string(serverIDs),
+		rule.Enabled, rule.CreatedAt, rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteRule removes a rule and resolves its firing alerts, which would
+// otherwise never clear
+func (s *Store) DeleteRule(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE alerts SET state = ?, resolved_at = ? WHERE rule_id = ? AND state = ?`,
+		StateResolved, time.Now().UTC(), id, StateFiring); err != nil {
+		return fmt.Errorf("failed to resolve alerts of rule: %w", err)
+	}
+	return tx.Commit()
+}
+
+// FiringAlert returns the firing alert of a rule for a server, or nil
+func (s *Store) FiringAlert(ctx context.Context, ruleID, serverID string) (*Alert, error) {
+	alert, err := scanAlert(s.db.QueryRowContext(ctx, `SELECT `+alertColumns+` FROM alerts WHERE rule_id = ? AND server_id = ? AND state = ?`,
+		ruleID, serverID, StateFiring))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load firing alert: %w", err)
+	}
+	return alert, nil
+}
+
+// SaveAlert creates or updates an alert
+func (s *Store) SaveAlert(ctx context.Context, alert *Alert) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO alerts (`+alertColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			state = excluded.state,
+			value = excluded.value,
+			resolved_at = excluded.resolved_at
+	`, alert.ID, alert.RuleID, alert.RuleName, alert.ServerID, alert.Metric, alert.Comparator, alert.Threshold, alert.Severity,
+		alert.State, alert.Value, alert.StartedAt.UTC(), alert.FiredAt.UTC(), alert.ResolvedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert: %w", err)
+	}
+	return nil
+}
+
+// ListAlerts returns alerts, most recently fired first
+func (s *Store) ListAlerts(ctx context.Context, filter AlertFilter) ([]*Alert, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.State != "" {
+		where = append(where, "state = ?")
+		args = append(args, filter.State)
+	}
+	if filter.ServerID != "" {
+		where = append(where, "server_id = ?")
+		args = append(args, filter.ServerID)
+	}
+	if filter.RuleID != "" {
+		where = append(where, "rule_id = ?")
+		args = append(args, filter.RuleID)
+	}
+	query := `SELECT ` + alertColumns + ` FROM alerts`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY fired_at DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := make([]*Alert, 0)
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRule(row rowScanner) (*Rule, error) {
+	var (
+		rule      Rule
+		serverIDs string
+	)
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.Metric, &rule.Comparator, &rule.Threshold, &rule.Duration, &rule.Severity,
+		&serverIDs, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(serverIDs), &rule.ServerIDs); err != nil {
+		return nil, fmt.Errorf("invalid servers of alert rule %s: %w", rule.ID, err)
+	}
+	rule.Normalize()
+	return &rule, nil
+}
+
+func scanAlert(row rowScanner) (*Alert, error) {
+	var (
+		alert      Alert
+		resolvedAt sql.NullTime
+	)
+	if err := row.Scan(&alert.ID, &alert.RuleID, &alert.RuleName, &alert.ServerID, &alert.Metric, &alert.Comparator,
+		&alert.Threshold, &alert.Severity, &alert.State, &alert.Value, &alert.StartedAt, &alert.FiredAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+	return &alert, nil
+}
@@ -1,7 +1,11 @@
 package api
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/gin-gonic/gin"
 )
 
 func TestParseDurationFallback(t *testing.T) {
@@ -10,3 +14,31 @@ func TestParseDurationFallback(t *testing.T) {
 		t.Fatalf("expected 15 minute fallback, got %v", result)
 	}
 }
+
+func TestVersionFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/servers", func(c *gin.Context) { c.String(http.StatusOK, "v1 list") })
+	router.GET("/api/v1/servers/:id", func(c *gin.Context) { c.String(http.StatusOK, "v1 "+c.Param("id")) })
+	router.GET("/api/v2/servers", func(c *gin.Context) { c.String(http.StatusOK, "v2 list") })
+	handler := VersionFallback(router)
+
+	cases := map[string]string{
+		"/api/v2/servers":       "v2 list",
+		"/api/v2/servers/alpha": "v1 alpha",
+		"/api/v1/servers":       "v1 list",
+	}
+	for path, expected := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != expected {
+			t.Fatalf("%s: expected %q, got %d %q", path, expected, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v2/servers", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected unknown v2 route to 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,156 @@
+// Package apierror writes every API error in one envelope with a
+// machine-readable code, so clients can branch on the code instead of the
+// message text.
+//
+// v2 routes respond with
+//
+//	{"error": {"code": "...", "message": "...", "details": ..., "request_id": "..."}}
+//
+// and v1 routes keep "error" as the message string, adding code and
+// request_id beside it so existing clients continue to work.
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+)
+
+// RequestIDKey is the context key the RequestID middleware stores the request ID under
+const RequestIDKey = "request_id"
+
+// Error codes
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeInvalidCursor      = "invalid_cursor"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidToken       = "invalid_token"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeServerNotFound     = "server_not_found"
+	CodeConflict           = "conflict"
+	CodeVersionConflict    = "version_conflict"
+	CodePreconditionFailed = "precondition_failed"
+	CodeValidationFailed   = "validation_failed"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+	CodeUpstreamFailed     = "upstream_failed"
+	CodeHostKeyUntrusted   = "ssh_host_key_untrusted"
+	CodeMaintenance        = "maintenance_mode"
+	CodeUnavailable        = "unavailable"
+)
+
+// CodeInfo documents an error code
+type CodeInfo struct {
+	Code        string `json:"code"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Codes is the registry of every code the API returns, with its usual status
+var Codes = []CodeInfo{
+	{CodeInvalidRequest, http.StatusBadRequest, "The request body or parameters are malformed"},
+	{CodeInvalidCursor, http.StatusBadRequest, "The pagination cursor was not issued by this API"},
+	{CodeUnauthorized, http.StatusUnauthorized, "Authentication is required"},
+	{CodeInvalidCredentials, http.StatusUnauthorized, "The username, password or one-time code is wrong"},
+	{CodeInvalidToken, http.StatusUnauthorized, "The access, refresh or reset token is invalid or expired"},
+	{CodeForbidden, http.StatusForbidden, "The caller lacks the required permission"},
+	{CodeNotFound, http.StatusNotFound, "The requested resource does not exist"},
+	{CodeServerNotFound, http.StatusNotFound, "The server ID is not defined"},
+	{CodeConflict, http.StatusConflict, "The resource already exists or is in use"},
+	{CodeVersionConflict, http.StatusConflict, "The resource changed since it was read; reload and retry"},
+	{CodePreconditionFailed, http.StatusPreconditionFailed, "An If-Match or similar precondition did not hold"},
+	{CodeValidationFailed, http.StatusUnprocessableEntity, "The request is well formed but its values are invalid"},
+	{CodeRateLimited, http.StatusTooManyRequests, "Too many requests; retry after the Retry-After delay"},
+	{CodeInternal, http.StatusInternalServerError, "An unexpected error occurred on the manager"},
+	{CodeUpstreamFailed, http.StatusBadGateway, "A managed host, agent or external service failed"},
+	{CodeHostKeyUntrusted, http.StatusBadGateway, "The host offered an SSH host key that is not approved"},
+	{CodeMaintenance, http.StatusServiceUnavailable, "Maintenance mode blocks the request"},
+	{CodeUnavailable, http.StatusServiceUnavailable, "A required component is not available"},
+}
+
+// Error is the body of an error response
+type Error struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// CodeForStatus returns the generic code for an HTTP status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed, http.StatusPreconditionRequired:
+		return CodePreconditionFailed
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return CodeUpstreamFailed
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// Respond writes an error response
+func Respond(c *gin.Context, status int, code, message string) {
+	RespondDetails(c, status, code, message, nil)
+}
+
+// RespondDetails writes an error response carrying details, either a string
+// or a gin.H. On v1 routes the keys of a gin.H are also written at the top
+// level, where clients found them before the envelope existed.
+func RespondDetails(c *gin.Context, status int, code, message string, details interface{}) {
+	c.JSON(status, body(c, code, message, details))
+}
+
+// Abort writes an error response and stops the handler chain
+func Abort(c *gin.Context, status int, code, message string) {
+	AbortDetails(c, status, code, message, nil)
+}
+
+// AbortDetails is Abort with details, see RespondDetails
+func AbortDetails(c *gin.Context, status int, code, message string, details interface{}) {
+	c.AbortWithStatusJSON(status, body(c, code, message, details))
+}
+
+func body(c *gin.Context, code, message string, details interface{}) interface{} {
+	apiErr := Error{Code: code, Message: message, Details: details, RequestID: c.GetString(RequestIDKey)}
+	if versioning.ForRequest(c.FullPath(), c.Request.URL.Path) != versioning.V1 {
+		return gin.H{"error": apiErr}
+	}
+
+	legacy := gin.H{}
+	switch value := details.(type) {
+	case gin.H:
+		for key, field := range value {
+			legacy[key] = field
+		}
+	case nil:
+	default:
+		legacy["details"] = value
+	}
+	legacy["error"] = message
+	legacy["code"] = code
+	if apiErr.RequestID != "" {
+		legacy["request_id"] = apiErr.RequestID
+	}
+	return legacy
+}
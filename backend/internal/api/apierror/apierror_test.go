@@ -0,0 +1,64 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondShapesByVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(RequestIDKey, "req-1") })
+	handler := func(c *gin.Context) {
+		RespondDetails(c, http.StatusConflict, CodeVersionConflict, "Server was modified", gin.H{"current_version": 3})
+	}
+	router.PUT("/api/v1/servers/:id", handler)
+	router.PUT("/api/v2/servers/:id", handler)
+
+	send := func(path string) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, nil))
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("%s: expected 409, got %d", path, rec.Code)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", path, err)
+		}
+		return body
+	}
+
+	legacy := send("/api/v1/servers/alpha")
+	if legacy["error"] != "Server was modified" || legacy["code"] != CodeVersionConflict || legacy["request_id"] != "req-1" {
+		t.Fatalf("unexpected v1 body: %v", legacy)
+	}
+	if legacy["current_version"] != float64(3) {
+		t.Fatalf("expected v1 details at the top level, got %v", legacy)
+	}
+
+	envelope, ok := send("/api/v2/servers/alpha")["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected v2 error object")
+	}
+	details, _ := envelope["details"].(map[string]interface{})
+	if envelope["code"] != CodeVersionConflict || envelope["message"] != "Server was modified" || envelope["request_id"] != "req-1" || details["current_version"] != float64(3) {
+		t.Fatalf("unexpected v2 envelope: %v", envelope)
+	}
+}
+
+func TestCodesAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, info := range Codes {
+		if seen[info.Code] {
+			t.Fatalf("duplicate error code %s", info.Code)
+		}
+		seen[info.Code] = true
+		if CodeForStatus(info.Status) == "" {
+			t.Fatalf("no generic code for status %d", info.Status)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+const (
+	// agentCertTTL is how long agent and manager client certificates are valid
+	agentCertTTL = 365 * 24 * time.Hour
+	// managerClientCertRenewBefore is how long before expiry installs re-issue
+	// the manager's client certificate
+	managerClientCertRenewBefore = 30 * 24 * time.Hour
+	// agentCertCheckInterval is how often the leader looks for expiring certificates
+	agentCertCheckInterval = 12 * time.Hour
+	// agentCertRenewTask names the task that pushes renewed certificates
+	agentCertRenewTask = "agent-cert-renew"
+)
+
+// errAgentNotInstalled is returned when renewing the certificates of a server
+// whose agent was never issued one
+var errAgentNotInstalled = errors.New("no agent certificate was issued for this server; install the agent first")
+
+// ListAgentCertificates lists the certificates issued for a server's agent
+// GET /api/v1/servers/:id/agent/certs
+func (h *ServerHandler) ListAgentCertificates(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	certs, err := agentcert.ListCertificates(h.db.DB, serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list agent certificates", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list agent certificates")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"certificates": certs})
+}
+
+// RenewAgentCertificates re-issues a server's agent certificates and pushes
+// them to the host over SSH as a task
+// POST /api/v1/servers/:id/agent/certs/renew
+func (h *ServerHandler) RenewAgentCertificates(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	certs, err := agentcert.ListCertificates(h.db.DB, serverID)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list agent certificates")
+		return
+	}
+	if newestCert(certs, agentcert.KindHTTPS) == nil {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, errAgentNotInstalled.Error())
+		return
+	}
+
+	h.goTask(c, serverID, agentCertRenewTask, func(ctx context.Context, task *taskRecord) {
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+		h.finishTask(serverID, task.ID, h.renewAgentCertificates(ctx, serverID, emit))
+	})
+	c.JSON(http.StatusAccepted, gin.H{"message": "Agent certificate renewal started", "server_id": serverID})
+}
+
+// RevokeAgentCertificate revokes one of a server's agent certificates. The
+// manager stops trusting it at once; renew the server's certificates to give
+// the agent a new one.
+// POST /api/v1/servers/:id/agent/certs/:serial/revoke
+func (h *ServerHandler) RevokeAgentCertificate(c *gin.Context) {
+	serverID := c.Param("id")
+	serial := strings.ToLower(strings.TrimSpace(c.Param("serial")))
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	err := agentcert.Revoke(h.db.DB, serverID, serial)
+	if errors.Is(err, agentcert.ErrCertNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Certificate not found or already revoked")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to revoke agent certificate", "server_id", serverID, "serial", serial, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke certificate")
+		return
+	}
+	logger.InfoContext(c.Request.Context(), "Agent certificate revoked", "server_id", serverID, "serial", serial, "user_id", getUserIDFromContext(c))
+	c.JSON(http.StatusOK, gin.H{"message": "Certificate revoked", "serial": serial})
+}
+
+// StartAgentCertRenewal renews, until ctx is done, the agent certificates
+// that expire or were revoked within renewBefore, and the manager's own
+// client certificate. It checks right away and then twice a day.
+func (h *ServerHandler) StartAgentCertRenewal(ctx context.Context, renewBefore time.Duration) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(h.tasksCtx, cancel)
+	go func() {
+		defer cancel()
+		defer stop()
+		ticker := time.NewTicker(agentCertCheckInterval)
+		defer ticker.Stop()
+		for {
+			h.renewExpiringAgentCerts(ctx, renewBefore)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (h *ServerHandler) renewExpiringAgentCerts(ctx context.Context, renewBefore time.Duration) {
+	ca, err := agentcert.LoadOrCreateCA(filepath.Join(h.config.Storage.DataDir, "agent-ca"))
+	if err != nil {
+		logger.Error("Failed to load agent CA", "error", err)
+		return
+	}
+	if existing, err := agentcert.GetClientCert(h.db.DB, agentcert.ManagerClientName); err == nil && existing != nil {
+		if err := h.renewManagerClientCert(ca, renewBefore); err != nil {
+			logger.Error("Failed to renew manager client certificate", "error", err)
+		}
+	}
+
+	deadline := time.Now().Add(renewBefore)
+	var due []config.ServerDefinition
+	for _, serverDef := range h.serverManager.GetAll() {
+		certs, err := agentcert.ListCertificates(h.db.DB, serverDef.ID)
+		if err != nil {
+			logger.Warn("Failed to list agent certificates", "server_id", serverDef.ID, "error", err)
+			continue
+		}
+		if dueForRenewal(certs, deadline) {
+			due = append(due, serverDef)
+		}
+	}
+
+	// Each server waits for its own turn, so a busy one holds up no other
+	fanOut(ctx, due, maxBatchParallelism, batchServerTimeout, func(ctx context.Context, serverDef config.ServerDefinition) {
+		serverID := serverDef.ID
+		err := h.runQueuedTask(ctx, serverID, agentCertRenewTask, func(ctx context.Context, task *taskRecord) error {
+			emit := func(line string) {
+				h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+			}
+			return h.renewAgentCertificates(ctx, serverID, emit)
+		})
+		if err != nil {
+			logger.Warn("Failed to renew agent certificates", "server_id", serverID, "error", err)
+		}
+	})
+}
+
+// dueForRenewal reports whether a server with an agent has no certificate of
+// a kind it was issued that stays valid and unrevoked past deadline
+func dueForRenewal(certs []agentcert.Certificate, deadline time.Time) bool {
+	for _, kind := range []string{agentcert.KindHTTPS, agentcert.KindClient} {
+		if newestCert(certs, kind) == nil {
+			continue
+		}
+		current := agentcert.Latest(certs, kind)
+		if current == nil || !current.Active(deadline) {
+			return true
+		}
+	}
+	return false
+}
+
+// newestCert returns the newest certificate of a kind, revoked or not
+func newestCert(certs []agentcert.Certificate, kind string) *agentcert.Certificate {
+	for i := range certs {
+		if certs[i].Kind == kind {
+			return &certs[i]
+		}
+	}
+	return nil
+}
+
+// renewAgentCertificates issues a server's agent a new HTTPS certificate, and
+// a new client certificate if it pushes its events, uploads them over SFTP
+// and restarts the agent
+func (h *ServerHandler) renewAgentCertificates(ctx context.Context, serverID string, emit func(string)) error {
+	certs, err := agentcert.ListCertificates(h.db.DB, serverID)
+	if err != nil {
+		return err
+	}
+	previous := newestCert(certs, agentcert.KindHTTPS)
+	if previous == nil {
+		return errAgentNotInstalled
+	}
+	push := newestCert(certs, agentcert.KindClient) != nil
+
+	serverDef, conn, err := h.connectServer(serverID)
+	if err != nil {
+		return err
+	}
+	ca, err := agentcert.LoadOrCreateCA(filepath.Join(h.config.Storage.DataDir, "agent-ca"))
+	if err != nil {
+		return fmt.Errorf("load agent CA: %w", err)
+	}
+	if err := h.renewManagerClientCert(ca, managerClientCertRenewBefore); err != nil {
+		return err
+	}
+
+	emit("Issuing renewed agent certificates...")
+	httpsCertPEM, httpsKeyPEM, serial, notAfter, fingerprint, err := agentcert.IssueServerCert(ca, serverDef.Connection.Host, serverID, previous.HostUUID, agentCertTTL)
+	if err != nil {
+		return fmt.Errorf("issue HTTPS cert: %w", err)
+	}
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := agentcert.InsertHTTPSCertificate(tx, serverID, previous.HostUUID, serial, fingerprint, httpsCertPEM, httpsKeyPEM, notAfter); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	var agentCertPEM, agentKeyPEM []byte
+	if push {
+		if agentCertPEM, agentKeyPEM, err = h.issueAgentClientCert(ca, previous.HostUUID, serverID); err != nil {
+			return fmt.Errorf("issue agent client cert: %w", err)
+		}
+	}
+
+	sftpClient, err := conn.Client.NewSFTP()
+	if err != nil {
+		return fmt.Errorf("open SFTP: %w", err)
+	}
+	defer sftpClient.Close()
+
+	remoteDir := "/tmp/hytale-agent-renew"
+	_ = sftpClient.MkdirAll(remoteDir)
+	type upload struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}
+	uploads := []upload{
+		{"server.crt", httpsCertPEM, 0644},
+		{"server.key", httpsKeyPEM, 0600},
+		{"ca.crt", ca.CertPEM, 0644},
+	}
+	if push {
+		uploads = append(uploads, upload{"agent.crt", agentCertPEM, 0644}, upload{"agent.key", agentKeyPEM, 0600})
+	}
+	for _, u := range uploads {
+		if err := uploadBytesSFTP(sftpClient, path.Join(remoteDir, u.name), u.data, u.mode); err != nil {
+			return fmt.Errorf("upload %s: %w", u.name, err)
+		}
+	}
+
+	script := AgentCertRenewScript
+	script = strings.ReplaceAll(script, "{{AGENT_CERTS_DIR}}", escapeForScript(remoteDir))
+	script = strings.ReplaceAll(script, "{{AGENT_PUSH}}", boolToScript(push))
+	writer := newLineSinkWriter(emit)
+	err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(script), writer, writer)
+	writer.FlushRemaining()
+	if err != nil {
+		return fmt.Errorf("install renewed certificates: %w", err)
+	}
+
+	emit(fmt.Sprintf("Agent certificates renewed until %s", notAfter.Format(time.DateOnly)))
+	logger.InfoContext(ctx, "Agent certificates renewed", "server_id", serverID, "serial", serial, "expires_at", notAfter)
+	return nil
+}
+
+// renewManagerClientCert issues the client certificate the manager calls
+// agents with when there is none or it expires within renewBefore
+func (h *ServerHandler) renewManagerClientCert(ca *agentcert.CA, renewBefore time.Duration) error {
+	current, err := agentcert.GetClientCert(h.db.DB, agentcert.ManagerClientName)
+	if err != nil {
+		return fmt.Errorf("load manager client cert: %w", err)
+	}
+	if current != nil && time.Until(current.ExpiresAt) >= renewBefore {
+		return nil
+	}
+
+	certPEM, keyPEM, serial, notAfter, fingerprint, err := agentcert.IssueClientCert(ca, agentcert.ManagerClientName, agentCertTTL)
+	if err != nil {
+		return fmt.Errorf("issue manager client cert: %w", err)
+	}
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if err := agentcert.SaveClientCert(tx, agentcert.ManagerClientName, serial, fingerprint, certPEM, keyPEM, notAfter); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	caDir := filepath.Join(h.config.Storage.DataDir, "agent-ca")
+	_ = os.WriteFile(filepath.Join(caDir, "manager-client.crt"), certPEM, 0644)
+	_ = os.WriteFile(filepath.Join(caDir, "manager-client.key"), keyPEM, 0600)
+	logger.Info("Manager client certificate issued", "serial", serial, "expires_at", notAfter)
+	return nil
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestDueForRenewal(t *testing.T) {
+	now := time.Now()
+	deadline := now.Add(30 * 24 * time.Hour)
+	revokedAt := now.Add(-time.Hour)
+	cert := func(kind string, expiresIn time.Duration, revoked bool) agentcert.Certificate {
+		c := agentcert.Certificate{Kind: kind, IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(expiresIn)}
+		if revoked {
+			c.RevokedAt = &revokedAt
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		certs []agentcert.Certificate
+		want  bool
+	}{
+		{
+			name:  "no agent",
+			certs: nil,
+			want:  false,
+		},
+		{
+			name:  "not yet due",
+			certs: []agentcert.Certificate{cert(agentcert.KindHTTPS, 300*24*time.Hour, false)},
+			want:  false,
+		},
+		{
+			name:  "inside the renewal window",
+			certs: []agentcert.Certificate{cert(agentcert.KindHTTPS, 10*24*time.Hour, false)},
+			want:  true,
+		},
+		{
+			name:  "expired",
+			certs: []agentcert.Certificate{cert(agentcert.KindHTTPS, -time.Hour, false)},
+			want:  true,
+		},
+		{
+			name:  "revoked",
+			certs: []agentcert.Certificate{cert(agentcert.KindHTTPS, 300*24*time.Hour, true)},
+			want:  true,
+		},
+		{
+			name: "revoked but already renewed",
+			certs: []agentcert.Certificate{
+				cert(agentcert.KindHTTPS, 365*24*time.Hour, false),
+				cert(agentcert.KindHTTPS, 300*24*time.Hour, true),
+			},
+			want: false,
+		},
+		{
+			name: "client certificate due",
+			certs: []agentcert.Certificate{
+				cert(agentcert.KindHTTPS, 300*24*time.Hour, false),
+				cert(agentcert.KindClient, 10*24*time.Hour, false),
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dueForRenewal(tt.certs, deadline); got != tt.want {
+				t.Fatalf("expected due %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// setupAgentCertHandler returns a server handler backed by a migrated
+// database and its own agent CA
+func setupAgentCertHandler(t *testing.T) (*ServerHandler, *config.ServerManager, *agentcert.CA) {
+	handler, _, _, sm := setupTestServerHandler(t)
+	t.Cleanup(func() { handler.activityLogger.Close() })
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	handler.db = db
+	handler.config.Storage.DataDir = t.TempDir()
+
+	ca, err := agentcert.LoadOrCreateCA(filepath.Join(handler.config.Storage.DataDir, "agent-ca"))
+	if err != nil {
+		t.Fatalf("create CA: %v", err)
+	}
+	return handler, sm, ca
+}
+
+// issueAgentHTTPSCert stores an HTTPS certificate for a server's agent and
+// returns its serial and parsed certificate
+func issueAgentHTTPSCert(t *testing.T, handler *ServerHandler, ca *agentcert.CA, serverID string, ttl time.Duration) (string, *x509.Certificate) {
+	t.Helper()
+	certPEM, keyPEM, serial, notAfter, fingerprint, err := agentcert.IssueServerCert(ca, "127.0.0.2", serverID, "host-"+serverID, ttl)
+	if err != nil {
+		t.Fatalf("issue cert: %v", err)
+	}
+	tx, err := handler.db.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := agentcert.InsertHTTPSCertificate(tx, serverID, "host-"+serverID, serial, fingerprint, certPEM, keyPEM, notAfter); err != nil {
+		t.Fatalf("store cert: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(certPEM)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse cert: %v", err)
+	}
+	return serial, leaf
+}
+
+func TestRevokeAgentCertificateRejectsCertificate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, ca := setupAgentCertHandler(t)
+	serial, leaf := issueAgentHTTPSCert(t, handler, ca, "test-server", time.Hour)
+
+	verify := agentcert.RejectRevoked(handler.db.DB)
+	chains := [][]*x509.Certificate{{leaf, ca.Cert}}
+	if err := verify(nil, chains); err != nil {
+		t.Fatalf("expected the certificate trusted before revoking, got %v", err)
+	}
+
+	revoke := func() int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "test-server"}, {Key: "serial", Value: " " + strings.ToUpper(serial) + " "}}
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/servers/test-server/agent/certs/"+serial+"/revoke", nil)
+		handler.RevokeAgentCertificate(c)
+		return w.Code
+	}
+	if code := revoke(); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+
+	certs, err := agentcert.ListCertificates(handler.db.DB, "test-server")
+	if err != nil {
+		t.Fatalf("list certs: %v", err)
+	}
+	if len(certs) != 1 || certs[0].Serial != serial || certs[0].RevokedAt == nil {
+		t.Fatalf("expected the certificate stored as revoked, got %+v", certs)
+	}
+	if err := verify(nil, chains); err == nil {
+		t.Fatal("expected the revoked certificate to be refused")
+	}
+	if code := revoke(); code != http.StatusNotFound {
+		t.Fatalf("expected revoking twice to return 404, got %d", code)
+	}
+}
+
+func TestRenewExpiringAgentCertsSkipsBusyServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, sm, ca := setupAgentCertHandler(t)
+
+	// Nothing listens on the servers' port, so a renewal fails as soon as it
+	// runs
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("no second loopback address: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	for _, serverID := range []string{"busy-server", "other-server"} {
+		if err := sm.Add(config.ServerDefinition{
+			ID:   serverID,
+			Name: serverID,
+			Connection: config.ConnectionConfig{
+				Host:       "127.0.0.2",
+				Port:       port,
+				Username:   "test",
+				AuthMethod: "password",
+				Password:   "test",
+			},
+			Server: config.GameServerConfig{
+				Executable:       "java",
+				WorkingDirectory: t.TempDir(),
+				ProcessManager:   "screen",
+			},
+		}); err != nil {
+			t.Fatalf("add server: %v", err)
+		}
+		issueAgentHTTPSCert(t, handler, ca, serverID, time.Hour)
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/servers/busy-server/deploy", nil)
+	release := make(chan struct{})
+	handler.goTask(c, "busy-server", "release-deploy", func(ctx context.Context, task *taskRecord) {
+		<-release
+		handler.finishTask("busy-server", task.ID, nil)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.renewExpiringAgentCerts(context.Background(), managerClientCertRenewBefore)
+	}()
+
+	renewStatus := func(serverID string) taskStatus {
+		for _, record := range handler.listTasks(serverID) {
+			if record.Task == agentCertRenewTask {
+				return record.Status
+			}
+		}
+		return ""
+	}
+	waitFor(t, func() bool {
+		return renewStatus("other-server") == taskStatusFailed
+	})
+	if status := renewStatus("busy-server"); status != taskStatusQueued {
+		t.Fatalf("expected the busy server's renewal to stay queued, got %q", status)
+	}
+	select {
+	case <-done:
+		t.Fatal("expected the renewal pass to wait for the busy server")
+	default:
+	}
+
+	close(release)
+	<-done
+	if status := renewStatus("busy-server"); status != taskStatusFailed {
+		t.Fatalf("expected the busy server's renewal to run once it was free, got %q", status)
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"archive/tar"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/agentbin"
 	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
 )
@@ -39,16 +41,23 @@ func (h *AgentHandler) DownloadBinary(c *gin.Context) {
 		arch = "amd64"
 	}
 	if arch != "amd64" && arch != "arm64" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported arch"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "unsupported arch")
 		return
 	}
 
-	path := filepath.Join(h.cfg.Storage.DataDir, "agent-binaries", "hytale-agent-linux-"+arch)
+	path := agentbin.Path(h.cfg.Storage.DataDir, arch)
 	if _, err := os.Stat(path); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "agent binary not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "agent binary not found")
+		return
+	}
+	sum, err := agentbin.Verify(path)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Agent binary failed checksum verification", "arch", arch, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "agent binary failed checksum verification")
 		return
 	}
 
+	c.Header("X-Checksum-SHA256", sum)
 	c.Header("Content-Type", "application/octet-stream")
 	c.Header("Content-Disposition", "attachment; filename=hytale-agent-linux-"+arch)
 	c.File(path)
@@ -57,53 +66,53 @@ func (h *AgentHandler) DownloadBinary(c *gin.Context) {
 func (h *AgentHandler) IssueCertificate(c *gin.Context) {
 	var req agentCertRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "invalid request")
 		return
 	}
 	if req.Token == "" || req.HostUUID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "token and host_uuid are required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "token and host_uuid are required")
 		return
 	}
 
 	caDir := filepath.Join(h.cfg.Storage.DataDir, "agent-ca")
 	ca, err := agentcert.LoadOrCreateCA(caDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load CA"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "failed to load CA")
 		return
 	}
 
 	tx, err := h.db.DB.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start transaction"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "failed to start transaction")
 		return
 	}
 	defer tx.Rollback()
 
 	serverID, err := agentcert.ConsumeRequest(tx, req.Token, req.HostUUID, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "request not found or expired"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "request not found or expired")
 		return
 	}
 
 	certPEM, keyPEM, serial, notAfter, fingerprint, err := agentcert.IssueAgentCert(ca, req.HostUUID, serverID, 365*24*time.Hour)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue cert"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "failed to issue cert")
 		return
 	}
 
 	if err := agentcert.InsertCertificate(tx, serverID, req.HostUUID, serial, fingerprint, certPEM, notAfter); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store cert"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "failed to store cert")
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to finalize cert"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "failed to finalize cert")
 		return
 	}
 
 	payload, err := buildCertArchive(certPEM, keyPEM, ca.CertPEM)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build response"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "failed to build response")
 		return
 	}
 
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/agent/transport"
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/agentstream"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
+)
+
+// Agent state transports reported in health checks
+const (
+	agentTransportStream = "stream"
+	agentTransportPoll   = "poll"
+)
+
+// SetAgentStreams has health checks read the state agents push instead of
+// polling them, and re-checks a server as soon as its agent reports a change
+func (h *ServerHandler) SetAgentStreams(streams *agentstream.Server) {
+	h.agentStreams = streams
+	streams.OnUpdate(h.agentUpdate)
+}
+
+// agentUpdate passes an agent's events to the clients watching its servers.
+// Heartbeats only keep the state fresh.
+func (h *ServerHandler) agentUpdate(update agentstream.Update) {
+	if update.Type == transport.MessageHeartbeat {
+		return
+	}
+	for _, serverID := range update.ServerIDs {
+		if update.Event != nil {
+			h.hub.Publish(fmt.Sprintf("server-tasks:%s", serverID), &ws.Message{
+				Type: "agent_event",
+				Payload: map[string]interface{}{
+					"server_id": serverID,
+					"host_uuid": update.HostUUID,
+					"event":     update.Event,
+				},
+				Timestamp: time.Now(),
+			})
+		}
+		h.invalidateServer(serverID)
+	}
+}
+
+// ListAgentStreams returns the agents connected to this instance
+func (h *ServerHandler) ListAgentStreams(c *gin.Context) {
+	agents := []agentstream.Agent{}
+	if h.agentStreams != nil {
+		agents = h.agentStreams.Agents()
+	}
+	c.JSON(http.StatusOK, gin.H{"agents": agents})
+}
+
+// readAgentState returns a server's agent state, pushed over its stream when
+// one is up and polled otherwise, with the transport it came from
+func (h *ServerHandler) readAgentState(serverID string, serverDef config.ServerDefinition) (*AgentState, string) {
+	if h.agentStreams != nil {
+		if data, ok := h.agentStreams.State(serverID); ok {
+			var state AgentState
+			if err := json.Unmarshal(data, &state); err == nil {
+				return &state, agentTransportStream
+			}
+		}
+	}
+	return h.fetchAgentState(serverID, serverDef), agentTransportPoll
+}
+
+// issueAgentClientCert issues and records the certificate an agent
+// authenticates its stream with
+func (h *ServerHandler) issueAgentClientCert(ca *agentcert.CA, hostUUID, serverID string) (certPEM, keyPEM []byte, err error) {
+	certPEM, keyPEM, serial, notAfter, fingerprint, err := agentcert.IssueAgentCert(ca, hostUUID, serverID, 365*24*time.Hour)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	if err := agentcert.InsertCertificate(tx, serverID, hostUUID, serial, fingerprint, certPEM, notAfter); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return certPEM, keyPEM, nil
+}
+
+// agentStreamAddr is the address agents push to: agents.advertise_addr, or
+// the host the manager was reached on with the stream port
+func agentStreamAddr(managerHost string, cfg config.AgentsConfig) string {
+	if cfg.AdvertiseAddr != "" {
+		return cfg.AdvertiseAddr
+	}
+	host := managerHost
+	if h, _, err := net.SplitHostPort(managerHost); err == nil {
+		host = h
+	}
+	_, port, _ := net.SplitHostPort(cfg.StreamAddr)
+	return net.JoinHostPort(host, port)
+}
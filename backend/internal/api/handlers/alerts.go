@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/TheGojiOG/HytaleSM/internal/alerts"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AlertHandler manages metric alert rules and lists their alerts
+type AlertHandler struct {
+	store *alerts.Store
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(store *alerts.Store) *AlertHandler {
+	return &AlertHandler{store: store}
+}
+
+type alertRuleRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Metric     string   `json:"metric" binding:"required"`
+	Comparator string   `json:"comparator" binding:"required"`
+	Threshold  *float64 `json:"threshold" binding:"required"`
+	Duration   string   `json:"duration"`
+	Severity   string   `json:"severity"`
+	ServerIDs  []string `json:"server_ids"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// ListAlerts returns alerts, most recently fired first, filtered by state,
+// server_id and rule_id
+func (h *AlertHandler) ListAlerts(c *gin.Context) {
+	filter := alerts.AlertFilter{
+		State:    c.Query("state"),
+		ServerID: c.Query("server_id"),
+		RuleID:   c.Query("rule_id"),
+		Limit:    100,
+	}
+	if filter.State != "" && filter.State != alerts.StateFiring && filter.State != alerts.StateResolved {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "state must be firing or resolved")
+		return
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 500 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 500")
+			return
+		}
+		filter.Limit = limit
+	}
+
+	list, err := h.store.ListAlerts(c.Request.Context(), filter)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list alerts", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load alerts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": list})
+}
+
+// ListAlertRules returns the alert rules, by name, with the metrics they can watch
+func (h *AlertHandler) ListAlertRules(c *gin.Context) {
+	rules, err := h.store.ListRules(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list alert rules", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load alert rules")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules, "metrics": alerts.Metrics})
+}
+
+// CreateAlertRule adds an alert rule
+func (h *AlertHandler) CreateAlertRule(c *gin.Context) {
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	rule := &alerts.Rule{ID: uuid.New().String(), Enabled: true}
+	req.apply(rule)
+	if !h.saveRule(c, rule) {
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateAlertRule changes an alert rule. Firing alerts keep the threshold
+// they fired with until they resolve.
+func (h *AlertHandler) UpdateAlertRule(c *gin.Context) {
+	rule, err := h.store.GetRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, alerts.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Alert rule not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load alert rule", "rule_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load alert rule")
+		return
+	}
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	req.apply(rule)
+	if !h.saveRule(c, rule) {
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRule removes an alert rule and resolves its firing alerts
+func (h *AlertHandler) DeleteAlertRule(c *gin.Context) {
+	err := h.store.DeleteRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, alerts.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Alert rule not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to delete alert rule", "rule_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete alert rule")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted"})
+}
+
+func (req alertRuleRequest) apply(rule *alerts.Rule) {
+	rule.Name = req.Name
+	rule.Metric = req.Metric
+	rule.Comparator = req.Comparator
+	rule.Threshold = *req.Threshold
+	rule.Duration = req.Duration
+	rule.Severity = req.Severity
+	rule.ServerIDs = req.ServerIDs
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+func (h *AlertHandler) saveRule(c *gin.Context, rule *alerts.Rule) bool {
+	rule.Normalize()
+	if err := rule.Validate(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	err := h.store.SaveRule(c.Request.Context(), rule)
+	if errors.Is(err, alerts.ErrNameTaken) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, err.Error())
+		return false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to save alert rule", "rule_id", rule.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save alert rule")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+)
+
+// APIKeyHandler lets users manage API keys for scripts and the hsmctl CLI
+type APIKeyHandler struct {
+	store *auth.APIKeyStore
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(store *auth.APIKeyStore) *APIKeyHandler {
+	return &APIKeyHandler{store: store}
+}
+
+type createAPIKeyRequest struct {
+	Name          string `json:"name" binding:"required"`
+	ExpiresInDays int    `json:"expires_in_days"`
+}
+
+// ListAPIKeys returns the current user's active keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	keys, err := h.store.List(c.Request.Context(), userID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list API keys", "user_id", userID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list API keys")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// CreateAPIKey issues a key for the current user. The key is only returned here.
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "name is required")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, "name must be 1-100 characters")
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, "expires_in_days must not be negative")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		expiry := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		expiresAt = &expiry
+	}
+
+	userID := c.GetInt64("user_id")
+	record, key, err := h.store.Create(c.Request.Context(), userID, name, expiresAt)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to create API key", "user_id", userID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create API key")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"api_key": record, "key": key})
+}
+
+// RevokeAPIKey disables one of the current user's keys
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	keyID, err := strconv.ParseInt(c.Param("keyId"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid API key ID")
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	revoked, err := h.store.Revoke(c.Request.Context(), userID, keyID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to revoke API key", "user_id", userID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke API key")
+		return
+	}
+	if !revoked {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "API key not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+)
+
+// Actions in an apply plan
+const (
+	applyCreate    = "create"
+	applyUpdate    = "update"
+	applyDelete    = "delete"
+	applyUnchanged = "unchanged"
+)
+
+// Kinds of objects an apply document manages
+const (
+	applyKindServer            = "server"
+	applyKindSchedule          = "schedule"
+	applyKindMaintenanceWindow = "maintenance_window"
+)
+
+// ApplyHandler serves the declarative apply endpoint, which brings servers,
+// scheduled tasks and maintenance windows in line with a desired-state
+// document. It is the backend for infrastructure-as-code tooling: applying
+// the same document twice changes nothing the second time.
+type ApplyHandler struct {
+	servers   *ServerHandler
+	schedules *ScheduleHandler
+	windows   *MaintenanceWindowHandler
+}
+
+// applyRequest is a desired-state document. A section that is left out is
+// not managed by the document; with prune set, objects missing from a
+// section that is present are deleted.
+type applyRequest struct {
+	Servers            *[]config.ServerDefinition `json:"servers"`
+	Schedules          *[]applySchedule           `json:"schedules"`
+	MaintenanceWindows *[]applyMaintenanceWindow  `json:"maintenance_windows"`
+	Prune              bool                       `json:"prune"`
+	DryRun             bool                       `json:"dry_run"`
+}
+
+type applySchedule struct {
+	ID string `json:"id"`
+	scheduleRequest
+}
+
+type applyMaintenanceWindow struct {
+	ID string `json:"id"`
+	maintenanceWindowRequest
+}
+
+// applyChange is one entry of the plan an apply returns
+type applyChange struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Action string `json:"action"`
+}
+
+// applyPlan holds the desired objects next to the changes they need
+type applyPlan struct {
+	changes   []applyChange
+	servers   []config.ServerDefinition
+	schedules []*scheduler.Schedule
+	windows   []*maintenance.Window
+	actions   map[string]string
+}
+
+func (p *applyPlan) add(kind, id, action string) {
+	p.changes = append(p.changes, applyChange{Kind: kind, ID: id, Action: action})
+	p.actions[kind+"/"+id] = action
+}
+
+func (p *applyPlan) action(kind, id string) string {
+	return p.actions[kind+"/"+id]
+}
+
+// pending returns the changes other than unchanged objects
+func (p *applyPlan) pending() []applyChange {
+	pending := []applyChange{}
+	for _, change := range p.changes {
+		if change.Action != applyUnchanged {
+			pending = append(pending, change)
+		}
+	}
+	return pending
+}
+
+// NewApplyHandler creates a new apply handler
+func NewApplyHandler(servers *ServerHandler, schedules *ScheduleHandler, windows *MaintenanceWindowHandler) *ApplyHandler {
+	return &ApplyHandler{
+		servers:   servers,
+		schedules: schedules,
+		windows:   windows,
+	}
+}
+
+// Apply applies a desired-state document and returns its plan of changes.
+// Objects are matched by ID; with dry_run set nothing is changed.
+func (h *ApplyHandler) Apply(c *gin.Context) {
+	var req applyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	plan, err := h.plan(c.Request.Context(), req)
+	if err != nil {
+		var invalid applyInvalidError
+		if errors.As(err, &invalid) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+		logger.ErrorContext(c.Request.Context(), "Failed to plan apply", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load current state")
+		return
+	}
+
+	if !req.DryRun {
+		if err := h.apply(c.Request.Context(), plan); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to apply desired state", "error", err)
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to apply desired state", err.Error())
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"plan": plan.changes, "dry_run": req.DryRun})
+}
+
+// applyInvalidError reports an object of the document that fails validation
+type applyInvalidError struct {
+	kind string
+	id   string
+	err  error
+}
+
+func (e applyInvalidError) Error() string {
+	if e.id == "" {
+		return fmt.Sprintf("%s: %v", e.kind, e.err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.kind, e.id, e.err)
+}
+
+// plan validates the document and works out the change each object needs
+func (h *ApplyHandler) plan(ctx context.Context, req applyRequest) (*applyPlan, error) {
+	plan := &applyPlan{actions: make(map[string]string)}
+
+	// Schedules and windows may refer to servers the same document creates,
+	// but not to servers it deletes
+	serverExists := func(serverID string) bool {
+		_, found := h.servers.serverManager.GetByID(serverID)
+		return found
+	}
+	if req.Servers != nil {
+		desired := make(map[string]bool, len(*req.Servers))
+		for _, serverDef := range *req.Servers {
+			desired[serverDef.ID] = true
+		}
+		serverExists = func(serverID string) bool {
+			if desired[serverID] {
+				return true
+			}
+			_, found := h.servers.serverManager.GetByID(serverID)
+			return found && !req.Prune
+		}
+	}
+
+	if req.Servers != nil {
+		if err := h.planServers(plan, *req.Servers, req.Prune); err != nil {
+			return nil, err
+		}
+	}
+	if req.Schedules != nil {
+		if err := h.planSchedules(ctx, plan, *req.Schedules, req.Prune, serverExists); err != nil {
+			return nil, err
+		}
+	}
+	if req.MaintenanceWindows != nil {
+		if err := h.planWindows(ctx, plan, *req.MaintenanceWindows, req.Prune, serverExists); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+func (h *ApplyHandler) planServers(plan *applyPlan, desired []config.ServerDefinition, prune bool) error {
+	seen := make(map[string]bool, len(desired))
+	for _, serverDef := range desired {
+		if serverDef.ID == "" {
+			return applyInvalidError{kind: applyKindServer, err: errors.New("id is required")}
+		}
+		if seen[serverDef.ID] {
+			return applyInvalidError{kind: applyKindServer, id: serverDef.ID, err: errors.New("listed more than once")}
+		}
+		seen[serverDef.ID] = true
+
+		serverDef.Version = 0
+		if err := config.ValidateServerDefinition(&serverDef); err != nil {
+			return applyInvalidError{kind: applyKindServer, id: serverDef.ID, err: err}
+		}
+		plan.servers = append(plan.servers, serverDef)
+
+		current, found := h.servers.serverManager.GetByID(serverDef.ID)
+		switch {
+		case !found:
+			plan.add(applyKindServer, serverDef.ID, applyCreate)
+		case h.serverChanged(current, serverDef):
+			plan.add(applyKindServer, serverDef.ID, applyUpdate)
+		default:
+			plan.add(applyKindServer, serverDef.ID, applyUnchanged)
+		}
+	}
+
+	if prune {
+		for _, serverDef := range h.servers.serverManager.GetAll() {
+			if !seen[serverDef.ID] {
+				plan.add(applyKindServer, serverDef.ID, applyDelete)
+			}
+		}
+	}
+	return nil
+}
+
+// serverChanged reports whether applying desired would change the stored
+// definition. An inline SSH key counts as unchanged when it matches the key
+// stored for the server.
+func (h *ApplyHandler) serverChanged(current, desired config.ServerDefinition) bool {
+	if desired.Connection.AuthMethod == "key" && desired.Connection.KeyContent != "" {
+		keyPath := h.servers.sshKeyPath(desired.ID)
+		if current.Connection.KeyPath != keyPath {
+			return true
+		}
+		stored, err := ssh.ReadPrivateKeyBytes(keyPath)
+		if err != nil || string(stored) != desired.Connection.KeyContent {
+			return true
+		}
+		desired.Connection.KeyPath = keyPath
+	}
+
+	currentYAML, err := config.MarshalServersYAML([]config.ServerDefinition{current})
+	if err != nil {
+		return true
+	}
+	desiredYAML, err := config.MarshalServersYAML([]config.ServerDefinition{desired})
+	if err != nil {
+		return true
+	}
+	return !bytes.Equal(currentYAML, desiredYAML)
+}
+
+func (h *ApplyHandler) planSchedules(ctx context.Context, plan *applyPlan, desired []applySchedule, prune bool, serverExists func(string) bool) error {
+	existing, err := h.schedules.store.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	current := make(map[string]*scheduler.Schedule, len(existing))
+	for _, schedule := range existing {
+		current[schedule.ID] = schedule
+	}
+
+	seen := make(map[string]bool, len(desired))
+	for _, req := range desired {
+		if req.ID == "" {
+			return applyInvalidError{kind: applyKindSchedule, err: errors.New("id is required")}
+		}
+		if seen[req.ID] {
+			return applyInvalidError{kind: applyKindSchedule, id: req.ID, err: errors.New("listed more than once")}
+		}
+		seen[req.ID] = true
+
+		schedule := &scheduler.Schedule{ID: req.ID}
+		old, found := current[req.ID]
+		if found {
+			copied := *old
+			schedule = &copied
+		}
+		schedule.Enabled = true
+		if err := req.applyTo(schedule, serverExists); err != nil {
+			return applyInvalidError{kind: applyKindSchedule, id: req.ID, err: err}
+		}
+		plan.schedules = append(plan.schedules, schedule)
+
+		switch {
+		case !found:
+			plan.add(applyKindSchedule, req.ID, applyCreate)
+		case scheduleChanged(old, schedule):
+			plan.add(applyKindSchedule, req.ID, applyUpdate)
+		default:
+			plan.add(applyKindSchedule, req.ID, applyUnchanged)
+		}
+	}
+
+	if prune {
+		for _, schedule := range existing {
+			if !seen[schedule.ID] {
+				plan.add(applyKindSchedule, schedule.ID, applyDelete)
+			}
+		}
+	}
+	return nil
+}
+
+func scheduleChanged(current, desired *scheduler.Schedule) bool {
+	return current.Name != desired.Name ||
+		current.ServerID != desired.ServerID ||
+		current.Type != desired.Type ||
+		current.Cron != desired.Cron ||
+		current.Enabled != desired.Enabled ||
+		current.OverlapPolicy != desired.OverlapPolicy ||
+		!sameJSON(current.Params, desired.Params)
+}
+
+// sameJSON compares two JSON documents ignoring insignificant whitespace
+func sameJSON(a, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
+
+func (h *ApplyHandler) planWindows(ctx context.Context, plan *applyPlan, desired []applyMaintenanceWindow, prune bool, serverExists func(string) bool) error {
+	existing, err := h.windows.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[string]*maintenance.Window, len(existing))
+	for _, window := range existing {
+		current[window.ID] = window
+	}
+
+	seen := make(map[string]bool, len(desired))
+	for _, req := range desired {
+		if req.ID == "" {
+			return applyInvalidError{kind: applyKindMaintenanceWindow, err: errors.New("id is required")}
+		}
+		if seen[req.ID] {
+			return applyInvalidError{kind: applyKindMaintenanceWindow, id: req.ID, err: errors.New("listed more than once")}
+		}
+		seen[req.ID] = true
+
+		window := &maintenance.Window{ID: req.ID}
+		old, found := current[req.ID]
+		if found {
+			copied := *old
+			window = &copied
+		}
+		window.Enabled = true
+		if err := req.applyTo(window, serverExists); err != nil {
+			return applyInvalidError{kind: applyKindMaintenanceWindow, id: req.ID, err: err}
+		}
+		plan.windows = append(plan.windows, window)
+
+		switch {
+		case !found:
+			plan.add(applyKindMaintenanceWindow, req.ID, applyCreate)
+		case windowChanged(old, window):
+			plan.add(applyKindMaintenanceWindow, req.ID, applyUpdate)
+		default:
+			plan.add(applyKindMaintenanceWindow, req.ID, applyUnchanged)
+		}
+	}
+
+	if prune {
+		for _, window := range existing {
+			if !seen[window.ID] {
+				plan.add(applyKindMaintenanceWindow, window.ID, applyDelete)
+			}
+		}
+	}
+	return nil
+}
+
+func windowChanged(current, desired *maintenance.Window) bool {
+	return current.Name != desired.Name ||
+		!slices.Equal(current.ServerIDs, desired.ServerIDs) ||
+		current.Group != desired.Group ||
+		!sameTime(current.StartsAt, desired.StartsAt) ||
+		!sameTime(current.EndsAt, desired.EndsAt) ||
+		current.Cron != desired.Cron ||
+		current.Duration != desired.Duration ||
+		current.Enabled != desired.Enabled
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// apply makes the planned changes. Objects are created and updated before
+// anything is deleted, and servers are deleted last, so a failure part way
+// through leaves nothing pointing at a missing server. Applying the same
+// document again picks up where a failed apply stopped.
+func (h *ApplyHandler) apply(ctx context.Context, plan *applyPlan) error {
+	if err := h.applyServers(plan); err != nil {
+		return err
+	}
+
+	for _, schedule := range plan.schedules {
+		action := plan.action(applyKindSchedule, schedule.ID)
+		if action == applyUnchanged {
+			continue
+		}
+		if err := h.schedules.store.Save(ctx, schedule); err != nil {
+			return fmt.Errorf("schedule %s: %w", schedule.ID, err)
+		}
+		if action == applyUpdate {
+			h.schedules.syncBackupSchedule(ctx, schedule)
+		}
+	}
+
+	windowsChanged := false
+	for _, window := range plan.windows {
+		if plan.action(applyKindMaintenanceWindow, window.ID) == applyUnchanged {
+			continue
+		}
+		if err := h.windows.store.Save(ctx, window); err != nil {
+			return fmt.Errorf("maintenance window %s: %w", window.ID, err)
+		}
+		windowsChanged = true
+	}
+
+	for _, change := range plan.changes {
+		if change.Action != applyDelete {
+			continue
+		}
+		switch change.Kind {
+		case applyKindSchedule:
+			if _, err := h.schedules.store.Delete(ctx, change.ID); err != nil {
+				return fmt.Errorf("schedule %s: %w", change.ID, err)
+			}
+		case applyKindMaintenanceWindow:
+			if err := h.windows.manager.Close(ctx, change.ID); err != nil {
+				return fmt.Errorf("maintenance window %s: %w", change.ID, err)
+			}
+			if _, err := h.windows.store.Delete(ctx, change.ID); err != nil {
+				return fmt.Errorf("maintenance window %s: %w", change.ID, err)
+			}
+			windowsChanged = true
+		}
+	}
+	if windowsChanged {
+		h.windows.refresh()
+	}
+
+	return h.pruneServers(plan)
+}
+
+// applyServers creates and updates servers
+func (h *ApplyHandler) applyServers(plan *applyPlan) error {
+	manager := h.servers.serverManager
+	for _, serverDef := range plan.servers {
+		action := plan.action(applyKindServer, serverDef.ID)
+		if action == applyUnchanged {
+			continue
+		}
+		if err := h.servers.persistSSHKey(serverDef.ID, &serverDef.Connection); err != nil {
+			return fmt.Errorf("server %s: failed to store SSH key: %w", serverDef.ID, err)
+		}
+
+		err := manager.Persist(func() error {
+			if action == applyCreate {
+				return manager.Add(serverDef)
+			}
+			_, err := manager.UpdateVersioned(serverDef)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("server %s: %w", serverDef.ID, err)
+		}
+		if action == applyUpdate {
+			h.servers.invalidateServer(serverDef.ID)
+		}
+	}
+	return nil
+}
+
+// pruneServers deletes the servers missing from the document
+func (h *ApplyHandler) pruneServers(plan *applyPlan) error {
+	manager := h.servers.serverManager
+	for _, change := range plan.changes {
+		if change.Kind != applyKindServer || change.Action != applyDelete {
+			continue
+		}
+		err := manager.Persist(func() error { return manager.Delete(change.ID) })
+		if err != nil && !errors.Is(err, config.ErrPersistFailed) {
+			return fmt.Errorf("server %s: %w", change.ID, err)
+		}
+		h.servers.forgetServer(change.ID)
+		if err != nil {
+			return fmt.Errorf("server %s: %w", change.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
+)
+
+func TestApplyHandler_ConvergesOnDesiredState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serverHandler, _, _, sm := setupTestServerHandler(t)
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "apply.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	scheduleStore := scheduler.NewStore(db.DB)
+	windowStore := maintenance.NewStore(db.DB)
+	handler := NewApplyHandler(
+		serverHandler,
+		NewScheduleHandler(scheduleStore, nil, nil, sm),
+		NewMaintenanceWindowHandler(windowStore, maintenance.NewManager(windowStore, serverHandler), sm),
+	)
+
+	existing, _ := sm.GetByID("test-server")
+	added := existing
+	added.ID = "web-2"
+	added.Name = "Web 2"
+
+	apply := func(doc map[string]interface{}) map[string]string {
+		t.Helper()
+		body, _ := json.Marshal(doc)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/apply", bytes.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		handler.Apply(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Plan []applyChange `json:"plan"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode plan: %v", err)
+		}
+		actions := make(map[string]string)
+		for _, change := range resp.Plan {
+			actions[change.Kind+"/"+change.ID] = change.Action
+		}
+		return actions
+	}
+
+	doc := map[string]interface{}{
+		"servers": []interface{}{existing, added},
+		"schedules": []interface{}{
+			map[string]interface{}{"id": "nightly", "server_id": "web-2", "type": "restart", "cron": "0 4 * * *"},
+		},
+		"dry_run": true,
+	}
+	want := map[string]string{"server/test-server": "unchanged", "server/web-2": "create", "schedule/nightly": "create"}
+	if got := apply(doc); !equalActions(got, want) {
+		t.Fatalf("dry run plan = %v, want %v", got, want)
+	}
+	if _, found := sm.GetByID("web-2"); found {
+		t.Fatal("expected a dry run to change nothing")
+	}
+
+	doc["dry_run"] = false
+	if got := apply(doc); !equalActions(got, want) {
+		t.Fatalf("apply plan = %v, want %v", got, want)
+	}
+	if _, found := sm.GetByID("web-2"); !found {
+		t.Fatal("expected the server to be created")
+	}
+	if _, err := scheduleStore.Get(context.Background(), "nightly"); err != nil {
+		t.Fatalf("expected the schedule to be created: %v", err)
+	}
+
+	want = map[string]string{"server/test-server": "unchanged", "server/web-2": "unchanged", "schedule/nightly": "unchanged"}
+	if got := apply(doc); !equalActions(got, want) {
+		t.Fatalf("second apply plan = %v, want %v", got, want)
+	}
+
+	pruned := map[string]interface{}{
+		"servers":   []interface{}{existing},
+		"schedules": []interface{}{},
+		"prune":     true,
+	}
+	want = map[string]string{"server/test-server": "unchanged", "server/web-2": "delete", "schedule/nightly": "delete"}
+	if got := apply(pruned); !equalActions(got, want) {
+		t.Fatalf("prune plan = %v, want %v", got, want)
+	}
+	if _, found := sm.GetByID("web-2"); found {
+		t.Fatal("expected the server to be deleted")
+	}
+}
+
+func equalActions(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for key, action := range want {
+		if got[key] != action {
+			return false
+		}
+	}
+	return true
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
 	"github.com/TheGojiOG/HytaleSM/internal/models"
 )
@@ -52,16 +55,16 @@ type AuthHandler struct {
 	db          *sql.DB
 	jwtManager  *auth.JWTManager
 	rbacManager *auth.RBACManager
-	bcryptCost  int
+	passwords   auth.PasswordHasher
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(db *sql.DB, jwtManager *auth.JWTManager, rbacManager *auth.RBACManager, bcryptCost int) *AuthHandler {
+func NewAuthHandler(db *sql.DB, jwtManager *auth.JWTManager, rbacManager *auth.RBACManager, passwords auth.PasswordHasher) *AuthHandler {
 	return &AuthHandler{
 		db:         db,
 		jwtManager: jwtManager,
 		rbacManager: rbacManager,
-		bcryptCost: bcryptCost,
+		passwords:  passwords,
 	}
 }
 
@@ -69,11 +72,11 @@ func NewAuthHandler(db *sql.DB, jwtManager *auth.JWTManager, rbacManager *auth.R
 func (h *AuthHandler) Register(c *gin.Context) {
 	needsSetup, err := h.needsSetup()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
 		return
 	}
 	if needsSetup {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Initial setup required"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Initial setup required")
 		return
 	}
 
@@ -85,65 +88,65 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Check if username already exists
 	var exists bool
-	err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", req.Username).Scan(&exists)
+	err = h.db.QueryRowContext(c.Request.Context(), "SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", req.Username).Scan(&exists)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
 		return
 	}
 	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Username already exists")
 		return
 	}
 
 	// Check if email already exists
-	err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", req.Email).Scan(&exists)
+	err = h.db.QueryRowContext(c.Request.Context(), "SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", req.Email).Scan(&exists)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
 		return
 	}
 	if exists {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Email already exists")
 		return
 	}
 
 	// Create user
-	user, err := models.NewUser(req.Username, req.Email, req.Password, req.FullName, h.bcryptCost)
+	user, err := models.NewUser(req.Username, req.Email, req.Password, req.FullName, h.passwords)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create user")
 		return
 	}
 
-	result, err := h.db.Exec(`
+	result, err := h.db.ExecContext(c.Request.Context(), `
 		INSERT INTO users (username, email, password_hash, full_name, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, user.Username, user.Email, user.PasswordHash, user.FullName, user.CreatedAt, user.UpdatedAt)
 	
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user")
 		return
 	}
 
 	userID, err := result.LastInsertId()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get user ID")
 		return
 	}
 	user.ID = userID
 
 	// Assign default "Viewer" role (least privilege)
-	_, err = h.db.Exec(`
+	_, err = h.db.ExecContext(c.Request.Context(), `
 		INSERT INTO user_roles (user_id, role_id)
-		SELECT ?, id FROM roles WHERE name = 'Viewer'
+		SELECT u.id, r.id FROM users u JOIN roles r ON r.name = 'Viewer' WHERE u.id = ?
 	`, userID)
 	
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign default role"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to assign default role")
 		return
 	}
 
@@ -162,14 +165,14 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request")
 		return
 	}
 
 	// Get user from database
 	var user models.User
 	query := `SELECT id, organization_id, username, email, password_hash, is_active FROM users WHERE username = ?`
-	err := h.db.QueryRow(query, req.Username).Scan(
+	err := h.db.QueryRowContext(c.Request.Context(), query, req.Username).Scan(
 		&user.ID,
 		&user.OrganizationID,
 		&user.Username,
@@ -178,72 +181,78 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		&user.IsActive,
 	)
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
 		return
 	}
 
 	// Check if user is active
 	if !user.IsActive {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Account is disabled")
 		return
 	}
 
 	// Verify password
 	if err := auth.VerifyPassword(req.Password, user.PasswordHash); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")
 		return
 	}
+	h.rehashPassword(c.Request.Context(), user.ID, req.Password, user.PasswordHash)
 
-	roles, err := h.rbacManager.GetUserRoles(user.ID)
+	tokens, err := startSession(c.Request.Context(), h.db, h.jwtManager, h.rbacManager, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
+		logger.ErrorContext(c.Request.Context(), "Failed to start session", "user_id", user.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate tokens")
 		return
 	}
 
-	permissions, err := h.rbacManager.GetUserPermissions(user.ID)
+	setAuthCookies(c, h.jwtManager, tokens)
+
+	// Return response
+	c.JSON(http.StatusOK, models.LoginResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		User:         &user,
+	})
+}
+
+// startSession issues a token pair carrying the user's current roles and
+// stores its refresh token
+func startSession(ctx context.Context, db *sql.DB, jwtManager *auth.JWTManager, rbacManager *auth.RBACManager, user *models.User) (*auth.TokenPair, error) {
+	roles, err := rbacManager.GetUserRoles(user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
-		return
+		return nil, fmt.Errorf("failed to load user roles: %w", err)
+	}
+
+	permissions, err := rbacManager.GetUserPermissions(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user permissions: %w", err)
 	}
 	permissionsHash := auth.ComputePermissionsHash(permissions)
 
-	// Generate tokens
-	tokens, tokenHash, err := h.jwtManager.GenerateTokenPair(user.ID, user.Username, user.OrganizationID, roles, permissionsHash)
+	tokens, tokenHash, err := jwtManager.GenerateTokenPair(user.ID, user.Username, user.OrganizationID, roles, permissionsHash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
-		return
+		return nil, err
 	}
 
-	// Store refresh token in database
-	expiresAt := h.jwtManager.GetRefreshTokenExpiry()
-	_, err = h.db.Exec(
+	_, err = db.ExecContext(ctx,
 		`INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES (?, ?, ?)`,
-		user.ID, tokenHash, expiresAt,
+		user.ID, tokenHash, jwtManager.GetRefreshTokenExpiry(),
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
-		return
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
 	}
-
-	setAuthCookies(c, h.jwtManager, tokens)
-
-	// Return response
-	c.JSON(http.StatusOK, models.LoginResponse{
-		AccessToken:  tokens.AccessToken,
-		RefreshToken: tokens.RefreshToken,
-		User:         &user,
-	})
+	return tokens, nil
 }
 
 // SetupStatus reports whether the system requires initial setup
 func (h *AuthHandler) SetupStatus(c *gin.Context) {
 	needsSetup, err := h.needsSetup()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
 		return
 	}
 
@@ -254,11 +263,11 @@ func (h *AuthHandler) SetupStatus(c *gin.Context) {
 func (h *AuthHandler) SetupInitialAdmin(c *gin.Context) {
 	needsSetup, err := h.needsSetup()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
 		return
 	}
 	if !needsSetup {
-		c.JSON(http.StatusConflict, gin.H{"error": "Setup already completed"})
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Setup already completed")
 		return
 	}
 
@@ -269,19 +278,19 @@ func (h *AuthHandler) SetupInitialAdmin(c *gin.Context) {
 		FullName string `json:"full_name" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
-	user, err := models.NewUser(req.Username, req.Email, req.Password, req.FullName, h.bcryptCost)
+	user, err := models.NewUser(req.Username, req.Email, req.Password, req.FullName, h.passwords)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create user")
 		return
 	}
 
 	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
 		return
 	}
 	defer tx.Rollback()
@@ -291,27 +300,27 @@ func (h *AuthHandler) SetupInitialAdmin(c *gin.Context) {
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, user.Username, user.Email, user.PasswordHash, user.FullName, user.CreatedAt, user.UpdatedAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save user")
 		return
 	}
 
 	userID, err := result.LastInsertId()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user ID"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get user ID")
 		return
 	}
 
 	_, err = tx.Exec(`
 		INSERT OR IGNORE INTO user_roles (user_id, role_id)
-		SELECT ?, id FROM roles WHERE name IN ('Admin', 'ReleaseManager')
+		SELECT u.id, r.id FROM users u JOIN roles r ON r.name IN ('Admin', 'ReleaseManager') WHERE u.id = ?
 	`, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign roles"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to assign roles")
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finalize setup"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to finalize setup")
 		return
 	}
 
@@ -343,7 +352,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		}
 	}
 	if req.RefreshToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request")
 		return
 	}
 
@@ -363,42 +372,42 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		INNER JOIN users u ON rt.user_id = u.id
 		WHERE rt.token_hash = ?
 	`
-	err := h.db.QueryRow(query, tokenHash).Scan(&userID, &username, &organizationID, &expiresAt, &revoked)
+	err := h.db.QueryRowContext(c.Request.Context(), query, tokenHash).Scan(&userID, &username, &organizationID, &expiresAt, &revoked)
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid refresh token")
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
 		return
 	}
 
 	// Check if token is revoked or expired
 	if revoked {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Token has been revoked")
 		return
 	}
 	if time.Now().After(expiresAt) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has expired"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Token has expired")
 		return
 	}
 
 	// Revoke old refresh token (rotation)
-	_, err = h.db.Exec(`UPDATE refresh_tokens SET revoked = 1 WHERE token_hash = ?`, tokenHash)
+	_, err = h.db.ExecContext(c.Request.Context(), `UPDATE refresh_tokens SET revoked = 1 WHERE token_hash = ?`, tokenHash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke old token"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke old token")
 		return
 	}
 
 	roles, err := h.rbacManager.GetUserRoles(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load user roles")
 		return
 	}
 
 	permissions, err := h.rbacManager.GetUserPermissions(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user permissions"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load user permissions")
 		return
 	}
 	permissionsHash := auth.ComputePermissionsHash(permissions)
@@ -406,18 +415,18 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	// Generate new tokens
 	tokens, newTokenHash, err := h.jwtManager.GenerateTokenPair(userID, username, organizationID, roles, permissionsHash)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate tokens")
 		return
 	}
 
 	// Store new refresh token
 	newExpiresAt := h.jwtManager.GetRefreshTokenExpiry()
-	_, err = h.db.Exec(
+	_, err = h.db.ExecContext(c.Request.Context(),
 		`INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES (?, ?, ?)`,
 		userID, newTokenHash, newExpiresAt,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store refresh token"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store refresh token")
 		return
 	}
 
@@ -442,7 +451,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	if req.RefreshToken != "" {
 		// Hash and revoke the refresh token
 		tokenHash := h.jwtManager.HashRefreshToken(req.RefreshToken)
-		_, _ = h.db.Exec(`UPDATE refresh_tokens SET revoked = 1 WHERE token_hash = ?`, tokenHash)
+		_, _ = h.db.ExecContext(c.Request.Context(), `UPDATE refresh_tokens SET revoked = 1 WHERE token_hash = ?`, tokenHash)
 	}
 
 	clearAuthCookies(c)
@@ -454,14 +463,14 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "User not authenticated")
 		return
 	}
 
 	// Get user from database
 	var user models.User
 	query := `SELECT id, organization_id, username, email, is_active, created_at, updated_at FROM users WHERE id = ?`
-	err := h.db.QueryRow(query, userID).Scan(
+	err := h.db.QueryRowContext(c.Request.Context(), query, userID).Scan(
 		&user.ID,
 		&user.OrganizationID,
 		&user.Username,
@@ -471,9 +480,29 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 		&user.UpdatedAt,
 	)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get user")
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
+
+// rehashPassword upgrades a stored hash made with another algorithm or older
+// parameters, now that the plain text password is known. Failures only get
+// logged; the old hash keeps working.
+func (h *AuthHandler) rehashPassword(ctx context.Context, userID int64, password, currentHash string) {
+	if !h.passwords.NeedsRehash(currentHash) {
+		return
+	}
+	hash, err := h.passwords.Hash(password)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to rehash password", "user_id", userID, "error", err)
+		return
+	}
+	if _, err := h.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND password_hash = ?`,
+		hash, userID, currentHash,
+	); err != nil {
+		logger.WarnContext(ctx, "Failed to store rehashed password", "user_id", userID, "error", err)
+	}
+}
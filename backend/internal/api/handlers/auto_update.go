@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/autoupdate"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+)
+
+// AutoUpdateHandler serves the auto-update policy API
+type AutoUpdateHandler struct {
+	store         *autoupdate.Store
+	manager       *autoupdate.Manager
+	windows       *maintenance.Store
+	serverManager *config.ServerManager
+}
+
+type autoUpdatePolicyRequest struct {
+	Name                 string   `json:"name"`
+	ServerIDs            []string `json:"server_ids"`
+	Group                string   `json:"group"`
+	Patchline            string   `json:"patchline"`
+	WindowID             string   `json:"window_id"`
+	Backup               *bool    `json:"backup"`
+	HealthStartupMinutes int      `json:"health_startup_minutes"`
+	HealthStableMinutes  int      `json:"health_stable_minutes"`
+	RollbackOnFailure    bool     `json:"rollback_on_failure"`
+	Enabled              *bool    `json:"enabled"`
+}
+
+// NewAutoUpdateHandler creates a new auto-update policy handler
+func NewAutoUpdateHandler(store *autoupdate.Store, manager *autoupdate.Manager, windows *maintenance.Store, serverManager *config.ServerManager) *AutoUpdateHandler {
+	return &AutoUpdateHandler{
+		store:         store,
+		manager:       manager,
+		windows:       windows,
+		serverManager: serverManager,
+	}
+}
+
+// ListAutoUpdatePolicies returns all auto-update policies
+func (h *AutoUpdateHandler) ListAutoUpdatePolicies(c *gin.Context) {
+	policies, err := h.store.List(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list auto-update policies", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load auto-update policies")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// GetAutoUpdatePolicy returns one auto-update policy
+func (h *AutoUpdateHandler) GetAutoUpdatePolicy(c *gin.Context) {
+	policy, ok := h.loadPolicy(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// CreateAutoUpdatePolicy adds an auto-update policy
+func (h *AutoUpdateHandler) CreateAutoUpdatePolicy(c *gin.Context) {
+	var req autoUpdatePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	policy := &autoupdate.Policy{ID: uuid.New().String(), Backup: true, Enabled: true}
+	if !h.applyRequest(c, policy, req) {
+		return
+	}
+
+	if err := h.store.Save(c.Request.Context(), policy); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to create auto-update policy", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save auto-update policy")
+		return
+	}
+	h.manager.Refresh()
+	c.JSON(http.StatusCreated, policy)
+}
+
+// UpdateAutoUpdatePolicy replaces an auto-update policy's settings
+func (h *AutoUpdateHandler) UpdateAutoUpdatePolicy(c *gin.Context) {
+	policy, ok := h.loadPolicy(c)
+	if !ok {
+		return
+	}
+
+	var req autoUpdatePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if !h.applyRequest(c, policy, req) {
+		return
+	}
+
+	if err := h.store.Save(c.Request.Context(), policy); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to update auto-update policy", "policy_id", policy.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save auto-update policy")
+		return
+	}
+	h.manager.Refresh()
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteAutoUpdatePolicy removes an auto-update policy. A run in progress
+// finishes.
+func (h *AutoUpdateHandler) DeleteAutoUpdatePolicy(c *gin.Context) {
+	deleted, err := h.store.Delete(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to delete auto-update policy", "policy_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete auto-update policy")
+		return
+	}
+	if !deleted {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Auto-update policy not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Auto-update policy deleted"})
+}
+
+// ListAutoUpdateRuns returns the auto-update runs, newest first, optionally
+// of one policy or server
+func (h *AutoUpdateHandler) ListAutoUpdateRuns(c *gin.Context) {
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 500 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+	runs, err := h.store.ListRuns(c.Request.Context(), c.Query("policy_id"), c.Query("server_id"), limit)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list auto-update runs", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load auto-update runs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+func (h *AutoUpdateHandler) loadPolicy(c *gin.Context) (*autoupdate.Policy, bool) {
+	policy, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, autoupdate.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Auto-update policy not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load auto-update policy", "policy_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load auto-update policy")
+		return nil, false
+	}
+	return policy, true
+}
+
+// applyRequest copies a create or update request onto policy and validates
+// it, including that its servers and maintenance window exist
+func (h *AutoUpdateHandler) applyRequest(c *gin.Context, policy *autoupdate.Policy, req autoUpdatePolicyRequest) bool {
+	policy.Name = req.Name
+	policy.ServerIDs = req.ServerIDs
+	policy.Group = req.Group
+	policy.Patchline = req.Patchline
+	policy.WindowID = req.WindowID
+	policy.HealthStartupMinutes = req.HealthStartupMinutes
+	policy.HealthStableMinutes = req.HealthStableMinutes
+	policy.RollbackOnFailure = req.RollbackOnFailure
+	if req.Backup != nil {
+		policy.Backup = *req.Backup
+	}
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	policy.Normalize()
+
+	err := policy.Validate()
+	for _, serverID := range policy.ServerIDs {
+		if _, found := h.serverManager.GetByID(serverID); err == nil && !found {
+			err = fmt.Errorf("server %s does not exist", serverID)
+		}
+	}
+	if err == nil {
+		if _, windowErr := h.windows.Get(c.Request.Context(), policy.WindowID); errors.Is(windowErr, maintenance.ErrNotFound) {
+			err = fmt.Errorf("maintenance window %s does not exist", policy.WindowID)
+		} else if windowErr != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to load maintenance window", "window_id", policy.WindowID, "error", windowErr)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load maintenance window")
+			return false
+		}
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	return true
+}
+
+// RunTask runs an auto-update as a server task, queued with the server's
+// other tasks
+func (h *ServerHandler) RunTask(ctx context.Context, serverID, name string, work func(ctx context.Context, taskID string) error) error {
+	return h.runQueuedTask(ctx, serverID, name, func(ctx context.Context, task *taskRecord) error {
+		return work(ctx, task.ID)
+	})
+}
+
+// AutoDeploy deploys a release package for an auto-update policy. The
+// server's maintenance window keeps it stopped, so it is started here to check
+// the release and stopped again afterwards; the window starts it when it
+// closes, if it ran before. A server that fails the check is rolled back when
+// the policy asks for it, and AutoDeploy then reports true.
+func (h *ServerHandler) AutoDeploy(ctx context.Context, policy *autoupdate.Policy, serverID, packageName string, emit func(string)) (bool, error) {
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return false, fmt.Errorf("server %s not found", serverID)
+	}
+	gate, err := newRolloutGate(&RolloutHealthCheck{StartupMinutes: policy.HealthStartupMinutes, StableMinutes: policy.HealthStableMinutes})
+	if err != nil {
+		return false, err
+	}
+	configFiles, err := config.RenderTemplates(h.config.Storage.ConfigDir, serverDef)
+	if err != nil {
+		return false, fmt.Errorf("failed to render config templates: %w", err)
+	}
+
+	// The window may not have stopped the server yet
+	if _, err := h.StopForMaintenance(ctx, serverID); err != nil {
+		return false, fmt.Errorf("failed to stop server: %w", err)
+	}
+	_, conn, err := h.connectServer(serverID)
+	if err != nil {
+		return false, err
+	}
+	if err := h.runReleaseDeploy(ctx, serverID, serverDef, conn, configFiles, ReleaseDeployRequest{PackageName: packageName}, nil, emit); err != nil {
+		return false, err
+	}
+	defer func() {
+		emit("Stopping server until the maintenance window closes...")
+		if _, err := h.StopForMaintenance(context.WithoutCancel(ctx), serverID); err != nil {
+			emit("Failed to stop server: " + err.Error())
+		}
+	}()
+
+	emit("Starting server to check the release...")
+	h.resetWatchdog(serverID)
+	if err = h.lifecycleManager.StartServer(serverID, h.createServerConfig(&serverDef)); err != nil {
+		h.activityLogger.LogServerStart(serverID, nil, false, err.Error())
+	} else {
+		h.activityLogger.LogServerStart(serverID, nil, true, "")
+		h.invalidateServer(serverID)
+		err = waitHealthy(ctx, gate, func() (HealthCheck, bool) {
+			snapshot, ok := h.statusRefresher.CheckNow(serverID)
+			return snapshot.Health, ok
+		}, emit)
+	}
+	if err == nil {
+		emit("Server is healthy.")
+		return false, nil
+	}
+	if !policy.RollbackOnFailure || ctx.Err() != nil {
+		return false, err
+	}
+
+	emit("Health check failed: " + err.Error())
+	manager := releases.NewManager(h.config, h.db)
+	active, previous, rollbackErr := manager.RollbackTarget(serverID)
+	if rollbackErr == nil {
+		rollbackErr = h.runReleaseRollback(ctx, serverDef, conn, manager, active, previous, nil, emit)
+	}
+	if rollbackErr != nil {
+		return false, fmt.Errorf("%w; rollback failed: %v", err, rollbackErr)
+	}
+	return true, err
+}
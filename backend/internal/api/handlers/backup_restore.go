@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/gin-gonic/gin"
+)
+
+// restoreListLimit bounds the overwritten and removed files a restore task
+// prints; the counts are always complete
+const restoreListLimit = 200
+
+type backupRestoreRequest struct {
+	BackupID string `json:"backup_id"`
+	// At picks the newest completed backup taken at or before it instead
+	At     *time.Time `json:"at"`
+	DryRun bool       `json:"dry_run"`
+	// Restart starts the server after the restore; by default it is started
+	// again if it was running
+	Restart *bool `json:"restart"`
+}
+
+// SetBackups enables staged restores of the backups manager keeps
+func (h *ServerHandler) SetBackups(manager *backup.BackupManager) {
+	h.backups = manager
+}
+
+// RestoreServerBackup restores a backup, picked by ID or by point in time,
+// as a task streamed over the task WebSocket. The backup is downloaded,
+// verified and extracted next to the working directory while the server
+// runs; it is then stopped, the backup's files swapped in and the server
+// started again. A dry run stops after listing what would change.
+func (h *ServerHandler) RestoreServerBackup(c *gin.Context) {
+	serverID := c.Param("id")
+	var req backupRestoreRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	req.BackupID = strings.TrimSpace(req.BackupID)
+	if (req.BackupID == "") == (req.At == nil) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "either backup_id or at is required")
+		return
+	}
+	if h.backups == nil {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeInternal, "Backups are not available")
+		return
+	}
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	var record *backup.BackupRecord
+	var err error
+	if req.At != nil {
+		record, err = h.backups.FindBackupAt(serverID, *req.At)
+	} else if record, err = h.backups.GetBackup(req.BackupID); err == nil && record.ServerID != serverID {
+		err = backup.ErrBackupNotFound
+	}
+	if errors.Is(err, backup.ErrBackupNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to find backup", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to find backup")
+		return
+	}
+	if record.Status != "completed" {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, fmt.Sprintf("Backup is %s, not completed", record.Status))
+		return
+	}
+
+	serverDef, conn, err := h.connectServer(serverID)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return
+	}
+	client, err := conn.Client.NewSFTP()
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to open SFTP: %v", err))
+		return
+	}
+	target, err := remoteHomePath(client, serverDef.Server.WorkingDirectory)
+	client.Close()
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to resolve the working directory: %v", err))
+		return
+	}
+
+	userID := getUserIDFromContext(c)
+	name := "backup-restore"
+	if req.DryRun {
+		name = "backup-restore-dry-run"
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":    "Backup restore started",
+		"backup_id":  record.ID,
+		"created_at": record.CreatedAt,
+		"dry_run":    req.DryRun,
+	})
+
+	h.goTask(c, serverID, name, func(ctx context.Context, task *taskRecord) {
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+		finish := func(err error) {
+			if err != nil {
+				emit("Restore failed: " + err.Error())
+			}
+			if !req.DryRun {
+				h.logRestore(serverID, userID, record, err)
+			}
+			h.finishTask(serverID, task.ID, err)
+		}
+
+		emit(fmt.Sprintf("Restoring backup %s taken at %s", record.ID, record.CreatedAt.Format(time.RFC3339)))
+		serverConfig := h.createServerConfig(serverDef)
+		restore, err := h.backups.StageRestore(ctx, serverID, record, backup.RestoreOptions{
+			Target:    target,
+			RunAsUser: serverConfig.RunAsUser,
+			UseSudo:   serverConfig.UseSudo,
+			Progress:  emit,
+		})
+		if err != nil {
+			finish(err)
+			return
+		}
+		defer restore.Cleanup()
+
+		emit(fmt.Sprintf("The restore replaces %s: %d files overwritten, %d removed",
+			strings.Join(restore.Entries, ", "), len(restore.Overwritten), len(restore.Removed)))
+		emitFiles(emit, "overwrite", restore.Overwritten)
+		emitFiles(emit, "remove", restore.Removed)
+		if req.DryRun {
+			emit("Dry run: nothing was changed")
+			finish(nil)
+			return
+		}
+		if err := ctx.Err(); err != nil {
+			finish(err)
+			return
+		}
+
+		h.processManager.SetRunAsUser(serverID, serverConfig.RunAsUser, serverConfig.UseSudo)
+		status, err := h.statusDetector.DetectStatus(serverID, serverConfig.SessionName)
+		if err != nil {
+			finish(fmt.Errorf("failed to check server status: %w", err))
+			return
+		}
+		running := status.Status != server.StatusOffline
+		restart := running
+		if req.Restart != nil {
+			restart = *req.Restart
+		}
+		if running {
+			emit("Stopping server...")
+			h.resetWatchdog(serverID)
+			if err := h.lifecycleManager.StopServer(serverID, serverConfig, true); err != nil {
+				h.activityLogger.LogServerStop(serverID, userID, true, false, err.Error())
+				finish(fmt.Errorf("failed to stop server: %w", err))
+				return
+			}
+			h.activityLogger.LogServerStop(serverID, userID, true, true, "")
+		}
+
+		if err := restore.Apply(); err != nil {
+			finish(err)
+			return
+		}
+		emit("Backup restored")
+
+		if restart {
+			emit("Starting server...")
+			h.resetWatchdog(serverID)
+			if err := h.lifecycleManager.StartServer(serverID, serverConfig); err != nil {
+				h.activityLogger.LogServerStart(serverID, userID, false, err.Error())
+				finish(fmt.Errorf("backup restored, but the server failed to start: %w", err))
+				return
+			}
+			h.activityLogger.LogServerStart(serverID, userID, true, "")
+			emit("Server started")
+		}
+		finish(nil)
+	})
+}
+
+func (h *ServerHandler) logRestore(serverID string, userID *int64, record *backup.BackupRecord, err error) {
+	activity := &logging.Activity{
+		ServerID:     serverID,
+		UserID:       userID,
+		ActivityType: logging.ActivityBackupRestore,
+		Description:  fmt.Sprintf("Restored backup %s", record.Filename),
+		Metadata:     map[string]interface{}{"backup_id": record.ID, "created_at": record.CreatedAt},
+		Success:      err == nil,
+	}
+	if err != nil {
+		activity.ErrorMessage = err.Error()
+	}
+	h.activityLogger.LogActivity(activity)
+}
+
+// emitFiles prints up to restoreListLimit of a restore's files
+func emitFiles(emit func(string), change string, names []string) {
+	for i, name := range names {
+		if i == restoreListLimit {
+			emit(fmt.Sprintf("  ...and %d more to %s", len(names)-i, change))
+			return
+		}
+		emit(fmt.Sprintf("  %s: %s", change, name))
+	}
+}
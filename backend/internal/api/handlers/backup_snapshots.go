@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/gin-gonic/gin"
+)
+
+type snapshotRepository struct {
+	Repository string            `json:"repository"`
+	Snapshots  []backup.Snapshot `json:"snapshots"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// ListSnapshots lists a server's snapshots in each restic repository it has
+// backups in, or only in the one given as ?repository=. A repository that
+// can't be read is reported with its error rather than failing the listing.
+// GET /api/v1/servers/:id/backups/snapshots
+func (h *BackupHandler) ListSnapshots(c *gin.Context) {
+	serverID := c.Param("id")
+	repositories, ok := h.snapshotRepositories(c, serverID)
+	if !ok {
+		return
+	}
+
+	listed := make([]snapshotRepository, 0, len(repositories))
+	for _, repository := range repositories {
+		entry := snapshotRepository{Repository: repository, Snapshots: []backup.Snapshot{}}
+		snapshots, err := h.backupManager.ListSnapshots(serverID, repository)
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to list restic snapshots", "server_id", serverID, "repository", repository, "error", err)
+			entry.Error = err.Error()
+		} else {
+			entry.Snapshots = snapshots
+		}
+		listed = append(listed, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"repositories": listed})
+}
+
+// PruneSnapshots frees the space of deleted backups in a server's restic
+// repositories, or only in ?repository=
+// POST /api/v1/servers/:id/backups/snapshots/prune
+func (h *BackupHandler) PruneSnapshots(c *gin.Context) {
+	serverID := c.Param("id")
+	repositories, ok := h.snapshotRepositories(c, serverID)
+	if !ok {
+		return
+	}
+
+	for _, repository := range repositories {
+		if err := h.backupManager.PruneRepository(serverID, repository); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to prune restic repository", "server_id", serverID, "repository", repository, "error", err)
+			apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Failed to prune "+repository, err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Restic repositories pruned",
+		"repositories": repositories,
+	})
+}
+
+// snapshotRepositories checks the server, connects to it and returns the
+// restic repositories a request is about
+func (h *BackupHandler) snapshotRepositories(c *gin.Context, serverID string) ([]string, bool) {
+	user := c.MustGet("user").(*auth.Claims)
+	if !h.verifyServerOwnership(c, serverID, fmt.Sprintf("%d", user.UserID)) {
+		return nil, false
+	}
+	serverDef, err := h.GetServerDefinitionFromConfig(serverID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return nil, false
+	}
+
+	repositories, err := h.backupManager.ResticRepositories(serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list restic repositories", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list restic repositories")
+		return nil, false
+	}
+	// Only repositories the server has backups in are opened, so its
+	// password is never handed to another one
+	if repository := c.Query("repository"); repository != "" {
+		if !slices.Contains(repositories, repository) {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "The server has no backups in that repository")
+			return nil, false
+		}
+		repositories = []string{repository}
+	}
+	if len(repositories) > 0 && !h.ensureConnection(c, serverDef) {
+		return nil, false
+	}
+	return repositories, true
+}
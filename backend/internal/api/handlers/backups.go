@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
 	"github.com/TheGojiOG/HytaleSM/internal/backup"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
 	"github.com/TheGojiOG/HytaleSM/internal/permissions"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 )
 
@@ -24,6 +30,7 @@ type BackupHandler struct {
 	backupManager *backup.BackupManager
 	retentionMgr  *backup.RetentionManager
 	scheduleStore *backup.ScheduleStore
+	schedules     *scheduler.Store
 	sshPool       *ssh.ConnectionPool
 }
 
@@ -50,6 +57,10 @@ type backupScheduleUpsertRequest struct {
 		S3AccessKey string `json:"s3_access_key"`
 		S3SecretKey string `json:"s3_secret_key"`
 		S3Endpoint  string `json:"s3_endpoint"`
+
+		// restic fields; the password is kept encrypted with the server
+		ResticBackend  string `json:"restic_backend"`
+		ResticPassword string `json:"restic_password"`
 	} `json:"destination"`
 	Compression struct {
 		Type  string `json:"type"`
@@ -71,16 +82,29 @@ func NewBackupHandler(cfg *config.Config, db *sql.DB, pool *ssh.ConnectionPool)
 		backupManager: backupMgr,
 		retentionMgr:  retentionMgr,
 		scheduleStore: scheduleStore,
+		schedules:     scheduler.NewStore(db),
 		sshPool:       pool,
 	}
 }
 
+// SetHooks runs post_backup hooks after backups created through the API
+func (h *BackupHandler) SetHooks(runner *hooks.Runner) {
+	h.backupManager.SetHooks(runner)
+}
+
+// Manager returns the backup manager the handler creates and restores backups with
+func (h *BackupHandler) Manager() *backup.BackupManager {
+	return h.backupManager
+}
+
 // RegisterRoutes registers backup routes under the servers group
 
 func (h *BackupHandler) RegisterRoutes(serversGroup *gin.RouterGroup, rbacManager *auth.RBACManager) {
 	// These routes are under /servers, so we add /:id/backups
 	serversGroup.POST(":id/backups", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsCreate), h.CreateBackup)
 	serversGroup.GET(":id/backups", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsList), h.ListBackups)
+	serversGroup.GET(":id/backups/snapshots", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsList), h.ListSnapshots)
+	serversGroup.POST(":id/backups/snapshots/prune", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsRetentionEnforce), h.PruneSnapshots)
 	serversGroup.GET(":id/backups/:backupId", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsGet), h.GetBackup)
 	serversGroup.POST(":id/backups/:backupId/restore", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsRestore), h.RestoreBackup)
 	serversGroup.DELETE(":id/backups/:backupId", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsDelete), h.DeleteBackup)
@@ -107,7 +131,7 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 		Exclude     []string `json:"exclude"`
 		WorkingDir  string   `json:"working_dir" binding:"required"`
 		Destination struct {
-			Type string `json:"type" binding:"required,oneof=local sftp s3"`
+			Type string `json:"type" binding:"required,oneof=local sftp s3 restic"`
 			Path string `json:"path" binding:"required"`
 
 			// SFTP fields
@@ -123,6 +147,10 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 			S3AccessKey string `json:"s3_access_key"`
 			S3SecretKey string `json:"s3_secret_key"`
 			S3Endpoint  string `json:"s3_endpoint"`
+
+			// restic fields; the password is kept encrypted with the server
+			ResticBackend  string `json:"restic_backend"`
+			ResticPassword string `json:"restic_password"`
 		} `json:"destination" binding:"required"`
 		Compression struct {
 			Type  string `json:"type"`
@@ -133,15 +161,15 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Verify server ownership and get server config
 	servers, err := config.LoadServers(h.config.Storage.ConfigDir)
 	if err != nil {
-		log.Printf("[API] Failed to load servers: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load servers"})
+		logger.ErrorContext(c.Request.Context(), "Failed to load servers", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load servers")
 		return
 	}
 
@@ -154,47 +182,19 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 	}
 
 	if serverDef == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	// Create SSH connection if it doesn't exist
-	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
-	}
-
-	log.Printf("[API] Server %s connection config: host=%s, port=%d, user=%s, authMethod=%s",
-		serverID, serverDef.Connection.Host, serverDef.Connection.Port,
-		serverDef.Connection.Username, serverDef.Connection.AuthMethod)
-
-	switch serverDef.Connection.AuthMethod {
-	case "key":
-		sshConfig.KeyPath = serverDef.Connection.KeyPath
-		log.Printf("[API] Using SSH key auth: %s", sshConfig.KeyPath)
-	case "password":
-		sshConfig.Password = serverDef.Connection.Password
-		log.Printf("[API] Using SSH password auth")
-	default:
-		log.Printf("[API] Invalid auth method: '%s'", serverDef.Connection.AuthMethod)
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid SSH auth method: '%s'", serverDef.Connection.AuthMethod)})
+	if !h.ensureConnection(c, serverDef) {
 		return
 	}
 
-	_, err = h.sshPool.GetConnection(serverID, sshConfig)
-	if err != nil {
-		log.Printf("[API] Failed to create SSH connection: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create SSH connection", "details": err.Error()})
-		return
-	}
-
-	log.Printf("[API] Successfully created/retrieved SSH connection for server %s", serverID)
+	logger.DebugContext(c.Request.Context(), "SSH connection ready", "server_id", serverID)
 
 	// Create destination config
+	hostKeys := h.sshPool.HostKeyPolicy()
 	destConfig := &backup.DestinationConfig{
 		Type:            req.Destination.Type,
 		Path:            req.Destination.Path,
@@ -203,13 +203,15 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 		SFTPUsername:    req.Destination.SFTPUsername,
 		SFTPPassword:    req.Destination.SFTPPassword,
 		SFTPKeyPath:     req.Destination.SFTPKeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		KnownHostsPath:  hostKeys.KnownHostsPath,
+		TrustOnFirstUse: hostKeys.TrustOnFirstUse,
 		S3Bucket:        req.Destination.S3Bucket,
 		S3Region:        req.Destination.S3Region,
 		S3AccessKey:     req.Destination.S3AccessKey,
 		S3SecretKey:     req.Destination.S3SecretKey,
 		S3Endpoint:      req.Destination.S3Endpoint,
+		ResticBackend:   req.Destination.ResticBackend,
+		ResticPassword:  req.Destination.ResticPassword,
 	}
 
 	// Create backup request
@@ -228,8 +230,8 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 	// Create backup (this may take a while)
 	record, err := h.backupManager.CreateBackup(backupReq)
 	if err != nil {
-		log.Printf("[API] Failed to create backup: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup", "details": err.Error()})
+		logger.ErrorContext(c.Request.Context(), "Failed to create backup", "server_id", serverID, "error", err)
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create backup", err.Error())
 		return
 	}
 
@@ -239,7 +241,43 @@ func (h *BackupHandler) CreateBackup(c *gin.Context) {
 	})
 }
 
-// ListBackups lists all backups for a server
+// ensureConnection opens the pooled SSH connection the backup manager runs
+// commands on, responding with an error when it can't
+func (h *BackupHandler) ensureConnection(c *gin.Context, serverDef *config.ServerDefinition) bool {
+	serverID := serverDef.ID
+	sshConfig := &ssh.ClientConfig{
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+	}
+
+	logger.DebugContext(c.Request.Context(), "SSH connection config", "server_id", serverID, "host", serverDef.Connection.Host, "port", serverDef.Connection.Port, "username", serverDef.Connection.Username, "auth_method", serverDef.Connection.AuthMethod)
+
+	switch serverDef.Connection.AuthMethod {
+	case "key":
+		sshConfig.KeyPath = serverDef.Connection.KeyPath
+		logger.DebugContext(c.Request.Context(), "Using SSH key auth", "server_id", serverID, "key_path", sshConfig.KeyPath)
+	case "password":
+		sshConfig.Password = serverDef.Connection.Password
+		logger.DebugContext(c.Request.Context(), "Using SSH password auth", "server_id", serverID)
+	default:
+		logger.WarnContext(c.Request.Context(), "Invalid SSH auth method", "server_id", serverID, "auth_method", serverDef.Connection.AuthMethod)
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("Invalid SSH auth method: '%s'", serverDef.Connection.AuthMethod))
+		return false
+	}
+
+	_, err := h.sshPool.GetConnection(serverID, sshConfig)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to create SSH connection", "server_id", serverID, "error", err)
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create SSH connection", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// ListBackups lists all backups for a server; ?format=csv exports the inventory as CSV
 // GET /api/v1/servers/:serverId/backups
 func (h *BackupHandler) ListBackups(c *gin.Context) {
 	serverID := c.Param("id")
@@ -250,19 +288,64 @@ func (h *BackupHandler) ListBackups(c *gin.Context) {
 		return
 	}
 
+	page, ok := parsePage(c, listingPages(c, backupPages))
+	if !ok {
+		return
+	}
+
 	backups, err := h.backupManager.ListBackups(serverID)
 	if err != nil {
-		log.Printf("[API] Failed to list backups: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list backups"})
+		logger.ErrorContext(c.Request.Context(), "Failed to list backups", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list backups")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	if backups == nil {
+		backups = []*backup.BackupRecord{}
+	}
+	keys := make([]string, len(backups))
+	for i, record := range backups {
+		keys[i] = record.ID
+	}
+	start, end, info, err := pageWindow(keys, page)
+	if err != nil {
+		respondPageError(c, err, "Failed to list backups")
+		return
+	}
+	backups = backups[start:end]
+
+	if wantsCSV(c) {
+		writeBackupsCSV(c, serverID, backups, info)
+		return
+	}
+	respondPage(c, backups, info, gin.H{
 		"backups": backups,
 		"count":   len(backups),
 	})
 }
 
+func writeBackupsCSV(c *gin.Context, serverID string, backups []*backup.BackupRecord, info database.PageInfo) {
+	rows := make([][]string, 0, len(backups))
+	for _, record := range backups {
+		rows = append(rows, []string{
+			record.ID,
+			record.ServerID,
+			record.Filename,
+			strconv.FormatInt(record.SizeBytes, 10),
+			record.CreatedAt.UTC().Format(time.RFC3339),
+			record.DestinationType,
+			record.DestinationPath,
+			record.Status,
+			record.ErrorMessage,
+			record.CreatedBy,
+		})
+	}
+	writeCSVPage(c, fmt.Sprintf("backups-%s.csv", serverID), []string{
+		"id", "server_id", "filename", "size_bytes", "created_at", "destination_type",
+		"destination_path", "status", "error_message", "created_by",
+	}, rows, info)
+}
+
 // GetBackup retrieves a specific backup
 // GET /api/v1/servers/:serverId/backups/:backupId
 func (h *BackupHandler) GetBackup(c *gin.Context) {
@@ -277,13 +360,13 @@ func (h *BackupHandler) GetBackup(c *gin.Context) {
 
 	backup, err := h.backupManager.GetBackup(backupID)
 	if err != nil {
-		log.Printf("[API] Failed to get backup: %v", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+		logger.ErrorContext(c.Request.Context(), "Failed to get backup", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Backup not found")
 		return
 	}
 
 	if backup.ServerID != serverID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Backup does not belong to this server"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Backup does not belong to this server")
 		return
 	}
 
@@ -302,7 +385,7 @@ func (h *BackupHandler) RestoreBackup(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -313,8 +396,8 @@ func (h *BackupHandler) RestoreBackup(c *gin.Context) {
 
 	// Restore backup
 	if err := h.backupManager.RestoreBackup(backupID, serverID, req.Destination); err != nil {
-		log.Printf("[API] Failed to restore backup: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore backup", "details": err.Error()})
+		logger.ErrorContext(c.Request.Context(), "Failed to restore backup", "server_id", serverID, "error", err)
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to restore backup", err.Error())
 		return
 	}
 
@@ -338,21 +421,33 @@ func (h *BackupHandler) DeleteBackup(c *gin.Context) {
 	}
 
 	// Verify backup belongs to server
-	backup, err := h.backupManager.GetBackup(backupID)
+	record, err := h.backupManager.GetBackup(backupID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Backup not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Backup not found")
 		return
 	}
 
-	if backup.ServerID != serverID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Backup does not belong to this server"})
+	if record.ServerID != serverID {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Backup does not belong to this server")
 		return
 	}
 
+	// Forgetting a restic snapshot runs restic on the server's host
+	if record.DestinationType == backup.DestinationRestic {
+		serverDef, err := h.GetServerDefinitionFromConfig(serverID)
+		if err != nil {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+			return
+		}
+		if !h.ensureConnection(c, serverDef) {
+			return
+		}
+	}
+
 	// Delete backup
 	if err := h.backupManager.DeleteBackup(backupID); err != nil {
-		log.Printf("[API] Failed to delete backup: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete backup"})
+		logger.ErrorContext(c.Request.Context(), "Failed to delete backup", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete backup")
 		return
 	}
 
@@ -373,7 +468,7 @@ func (h *BackupHandler) EnforceRetention(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -387,8 +482,8 @@ func (h *BackupHandler) EnforceRetention(c *gin.Context) {
 
 	// Enforce retention
 	if err := h.retentionMgr.EnforceRetention(serverID, req.RetentionCount); err != nil {
-		log.Printf("[API] Failed to enforce retention: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enforce retention"})
+		logger.ErrorContext(c.Request.Context(), "Failed to enforce retention", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to enforce retention")
 		return
 	}
 
@@ -420,8 +515,8 @@ func (h *BackupHandler) GetBackupSchedule(c *gin.Context) {
 	}
 
 	if err != sql.ErrNoRows {
-		log.Printf("[API] Failed to get backup schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load backup schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to get backup schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load backup schedule")
 		return
 	}
 
@@ -440,8 +535,8 @@ func (h *BackupHandler) ListBackupSchedules(c *gin.Context) {
 
 	schedules, err := h.scheduleStore.ListSchedules(serverID)
 	if err != nil {
-		log.Printf("[API] Failed to list schedules: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load schedules"})
+		logger.ErrorContext(c.Request.Context(), "Failed to list schedules", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load schedules")
 		return
 	}
 
@@ -457,7 +552,7 @@ func (h *BackupHandler) CreateBackupSchedule(c *gin.Context) {
 	var req backupScheduleUpsertRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -467,30 +562,34 @@ func (h *BackupHandler) CreateBackupSchedule(c *gin.Context) {
 
 	if req.Enabled {
 		if req.Schedule == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "schedule is required when enabled"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "schedule is required when enabled")
 			return
 		}
 		if len(req.Directories) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "directories are required when enabled"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "directories are required when enabled")
 			return
 		}
 		if req.Destination.Type == "" || req.Destination.Path == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "destination type and path are required"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "destination type and path are required")
 			return
 		}
 	}
 
 	schedule := h.buildScheduleFromRequest(serverID, req)
+	if !h.saveScheduleCredentials(c, schedule) {
+		return
+	}
 
 	if err := h.scheduleStore.UpsertSchedule(schedule); err != nil {
-		log.Printf("[API] Failed to create schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to create schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save schedule")
 		return
 	}
+	h.syncUnifiedSchedule(c.Request.Context(), schedule)
 
 	if serverDef, err := h.GetServerDefinitionFromConfig(serverID); err == nil {
-		if err := backup.InstallCronJob(h.config, h.sshPool, serverDef, schedule); err != nil {
-			log.Printf("[API] Warning: Failed to install cron job: %v", err)
+		if err := backup.InstallCronJob(h.sshPool, serverDef, schedule); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to install cron job", "server_id", serverID, "error", err)
 		}
 	}
 
@@ -507,7 +606,7 @@ func (h *BackupHandler) UpdateBackupSchedule(c *gin.Context) {
 	var req backupScheduleUpsertRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -516,17 +615,21 @@ func (h *BackupHandler) UpdateBackupSchedule(c *gin.Context) {
 	}
 
 	schedule := h.buildScheduleFromRequest(serverID, req)
+	if !h.saveScheduleCredentials(c, schedule) {
+		return
+	}
 	schedule.ID = scheduleID
 
 	if err := h.scheduleStore.UpsertSchedule(schedule); err != nil {
-		log.Printf("[API] Failed to update schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to update schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save schedule")
 		return
 	}
+	h.syncUnifiedSchedule(c.Request.Context(), schedule)
 
 	if serverDef, err := h.GetServerDefinitionFromConfig(serverID); err == nil {
-		if err := backup.InstallCronJob(h.config, h.sshPool, serverDef, schedule); err != nil {
-			log.Printf("[API] Warning: Failed to install cron job: %v", err)
+		if err := backup.InstallCronJob(h.sshPool, serverDef, schedule); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to install cron job", "server_id", serverID, "error", err)
 		}
 	}
 
@@ -547,16 +650,17 @@ func (h *BackupHandler) DeleteBackupScheduleByID(c *gin.Context) {
 	schedule, _ := h.scheduleStore.GetScheduleByID(serverID, scheduleID)
 	serverDef, err := h.GetServerDefinitionFromConfig(serverID)
 	if err == nil {
-		if err := backup.RemoveCronJob(h.config, h.sshPool, serverDef, schedule); err != nil {
-			log.Printf("[API] Warning: Failed to remove cron job: %v", err)
+		if err := backup.RemoveCronJob(h.sshPool, serverDef, schedule); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to remove cron job", "server_id", serverID, "error", err)
 		}
 	}
 
 	if err := h.scheduleStore.DeleteScheduleByID(serverID, scheduleID); err != nil {
-		log.Printf("[API] Failed to delete schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to delete schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete schedule")
 		return
 	}
+	h.removeUnifiedSchedule(c.Request.Context(), scheduleID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
 }
@@ -570,7 +674,7 @@ func (h *BackupHandler) UpsertBackupSchedule(c *gin.Context) {
 	var req backupScheduleUpsertRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -580,36 +684,40 @@ func (h *BackupHandler) UpsertBackupSchedule(c *gin.Context) {
 
 	if req.Enabled {
 		if req.Schedule == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "schedule is required when enabled"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "schedule is required when enabled")
 			return
 		}
 		if len(req.Directories) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "directories are required when enabled"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "directories are required when enabled")
 			return
 		}
 		if req.Destination.Type == "" || req.Destination.Path == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "destination type and path are required"})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "destination type and path are required")
 			return
 		}
 	}
 
 	schedule := h.buildScheduleFromRequest(serverID, req)
+	if !h.saveScheduleCredentials(c, schedule) {
+		return
+	}
 
 	if err := h.scheduleStore.UpsertSchedule(schedule); err != nil {
-		log.Printf("[API] Failed to upsert backup schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save backup schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to upsert backup schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save backup schedule")
 		return
 	}
+	h.syncUnifiedSchedule(c.Request.Context(), schedule)
 
 	if serverDef, err := h.GetServerDefinitionFromConfig(serverID); err == nil {
-		if err := backup.InstallCronJob(h.config, h.sshPool, serverDef, schedule); err != nil {
-			log.Printf("[API] Warning: Failed to install cron job: %v", err)
+		if err := backup.InstallCronJob(h.sshPool, serverDef, schedule); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to install cron job", "server_id", serverID, "error", err)
 		}
 	}
 
 	// Update YAML server backups for visibility
 	if err := h.updateServerBackupConfig(serverID, req); err != nil {
-		log.Printf("[API] Warning: Failed to update server backup config: %v", err)
+		logger.WarnContext(c.Request.Context(), "Failed to update server backup config", "server_id", serverID, "error", err)
 	}
 
 	updated, err := h.scheduleStore.GetSchedule(serverID)
@@ -633,30 +741,31 @@ func (h *BackupHandler) InitializeDefaultBackupSchedule(c *gin.Context) {
 
 	serverDef, err := h.GetServerDefinitionFromConfig(serverID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	existing, err := h.scheduleStore.ListSchedules(serverID)
 	if err == nil && len(existing) > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Schedule already exists"})
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Schedule already exists")
 		return
 	}
 
 	defaultSchedule, err := backup.BuildDefaultSchedule(serverDef)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	if err := h.scheduleStore.UpsertSchedule(defaultSchedule); err != nil {
-		log.Printf("[API] Failed to save default schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save default schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to save default schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save default schedule")
 		return
 	}
+	h.syncUnifiedSchedule(c.Request.Context(), defaultSchedule)
 
-	if err := backup.InstallCronJob(h.config, h.sshPool, serverDef, defaultSchedule); err != nil {
-		log.Printf("[API] Warning: Failed to install cron job: %v", err)
+	if err := backup.InstallCronJob(h.sshPool, serverDef, defaultSchedule); err != nil {
+		logger.WarnContext(c.Request.Context(), "Failed to install cron job", "server_id", serverID, "error", err)
 	}
 
 	_ = h.updateServerBackupConfig(serverID, backupScheduleUpsertRequest{
@@ -680,6 +789,8 @@ func (h *BackupHandler) InitializeDefaultBackupSchedule(c *gin.Context) {
 			S3AccessKey string `json:"s3_access_key"`
 			S3SecretKey string `json:"s3_secret_key"`
 			S3Endpoint string `json:"s3_endpoint"`
+			ResticBackend string `json:"restic_backend"`
+			ResticPassword string `json:"restic_password"`
 		}{
 			Type: defaultSchedule.Destination.Type,
 			Path: defaultSchedule.Destination.Path,
@@ -707,24 +818,57 @@ func (h *BackupHandler) DeleteBackupSchedule(c *gin.Context) {
 	}
 
 	schedule, _ := h.scheduleStore.GetSchedule(serverID)
+	existing, _ := h.scheduleStore.ListSchedules(serverID)
 	serverDef, err := h.GetServerDefinitionFromConfig(serverID)
 	if err == nil {
-		if err := backup.RemoveCronJob(h.config, h.sshPool, serverDef, schedule); err != nil {
-			log.Printf("[API] Warning: Failed to remove cron job: %v", err)
+		if err := backup.RemoveCronJob(h.sshPool, serverDef, schedule); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to remove cron job", "server_id", serverID, "error", err)
 		}
 	}
 
 	if err := h.scheduleStore.DeleteSchedule(serverID); err != nil {
-		log.Printf("[API] Failed to delete schedule: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		logger.ErrorContext(c.Request.Context(), "Failed to delete schedule", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete schedule")
 		return
 	}
+	for _, removed := range existing {
+		h.removeUnifiedSchedule(c.Request.Context(), removed.ID)
+	}
 
 	_ = h.updateServerBackupConfig(serverID, backupScheduleUpsertRequest{})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
 }
 
+// syncUnifiedSchedule keeps the scheduler entry that runs a backup schedule
+// in step with it. The entry's name and overlap policy are left as they are.
+func (h *BackupHandler) syncUnifiedSchedule(ctx context.Context, schedule *backup.BackupSchedule) {
+	entry, err := h.schedules.Get(ctx, scheduler.BackupEntryID(schedule.ID))
+	if err != nil {
+		entry = &scheduler.Schedule{
+			ID:            scheduler.BackupEntryID(schedule.ID),
+			Name:          "Backup",
+			Type:          scheduler.TypeBackup,
+			OverlapPolicy: scheduler.OverlapSkip,
+		}
+	}
+	params, _ := json.Marshal(scheduler.BackupParams{BackupScheduleID: schedule.ID})
+	entry.ServerID = schedule.ServerID
+	entry.Cron = schedule.Schedule
+	entry.Enabled = schedule.Enabled && schedule.Schedule != ""
+	entry.Params = params
+
+	if err := h.schedules.Save(ctx, entry); err != nil {
+		logger.WarnContext(ctx, "Failed to update scheduled backup task", "schedule_id", schedule.ID, "error", err)
+	}
+}
+
+func (h *BackupHandler) removeUnifiedSchedule(ctx context.Context, backupScheduleID string) {
+	if _, err := h.schedules.Delete(ctx, scheduler.BackupEntryID(backupScheduleID)); err != nil {
+		logger.WarnContext(ctx, "Failed to remove scheduled backup task", "schedule_id", backupScheduleID, "error", err)
+	}
+}
+
 // GetBackupCron returns the current crontab for the service user
 // GET /api/v1/servers/:id/backups/cron
 func (h *BackupHandler) GetBackupCron(c *gin.Context) {
@@ -737,16 +881,16 @@ func (h *BackupHandler) GetBackupCron(c *gin.Context) {
 
 	serverDef, err := h.GetServerDefinitionFromConfig(serverID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	runAsUser := strings.TrimSpace(serverDef.Dependencies.ServiceUser)
 	useSudo := serverDef.Dependencies.UseSudo || runAsUser != ""
 
-	output, err := backup.ReadCronTab(h.config, h.sshPool, serverDef, runAsUser, useSudo)
+	output, err := backup.ReadCronTab(h.sshPool, serverDef, runAsUser, useSudo)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read cron", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read cron", err.Error())
 		return
 	}
 
@@ -768,18 +912,20 @@ func (h *BackupHandler) GetBackupCron(c *gin.Context) {
 
 func (h *BackupHandler) buildScheduleFromRequest(serverID string, req backupScheduleUpsertRequest) *backup.BackupSchedule {
 	destConfig := backup.DestinationConfig{
-		Type:         req.Destination.Type,
-		Path:         req.Destination.Path,
-		SFTPHost:     req.Destination.SFTPHost,
-		SFTPPort:     req.Destination.SFTPPort,
-		SFTPUsername: req.Destination.SFTPUsername,
-		SFTPPassword: req.Destination.SFTPPassword,
-		SFTPKeyPath:  req.Destination.SFTPKeyPath,
-		S3Bucket:     req.Destination.S3Bucket,
-		S3Region:     req.Destination.S3Region,
-		S3AccessKey:  req.Destination.S3AccessKey,
-		S3SecretKey:  req.Destination.S3SecretKey,
-		S3Endpoint:   req.Destination.S3Endpoint,
+		Type:           req.Destination.Type,
+		Path:           req.Destination.Path,
+		SFTPHost:       req.Destination.SFTPHost,
+		SFTPPort:       req.Destination.SFTPPort,
+		SFTPUsername:   req.Destination.SFTPUsername,
+		SFTPPassword:   req.Destination.SFTPPassword,
+		SFTPKeyPath:    req.Destination.SFTPKeyPath,
+		S3Bucket:       req.Destination.S3Bucket,
+		S3Region:       req.Destination.S3Region,
+		S3AccessKey:    req.Destination.S3AccessKey,
+		S3SecretKey:    req.Destination.S3SecretKey,
+		S3Endpoint:     req.Destination.S3Endpoint,
+		ResticBackend:  req.Destination.ResticBackend,
+		ResticPassword: req.Destination.ResticPassword,
 	}
 
 	return &backup.BackupSchedule{
@@ -796,6 +942,20 @@ func (h *BackupHandler) buildScheduleFromRequest(serverID string, req backupSche
 	}
 }
 
+// saveScheduleCredentials moves a restic schedule's secrets into the server's
+// encrypted credentials before the schedule is stored
+func (h *BackupHandler) saveScheduleCredentials(c *gin.Context, schedule *backup.BackupSchedule) bool {
+	if schedule.Destination.Type != backup.DestinationRestic {
+		return true
+	}
+	if err := h.backupManager.SaveResticCredentials(schedule.ServerID, &schedule.Destination); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to save restic credentials", "server_id", schedule.ServerID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save restic credentials")
+		return false
+	}
+	return true
+}
+
 func (h *BackupHandler) updateServerBackupConfig(serverID string, req backupScheduleUpsertRequest) error {
 	servers, err := config.LoadServers(h.config.Storage.ConfigDir)
 	if err != nil {
@@ -821,6 +981,7 @@ func (h *BackupHandler) updateServerBackupConfig(serverID string, req backupSche
 					Endpoint: req.Destination.S3Endpoint,
 					Bucket:   req.Destination.S3Bucket,
 					Region:   req.Destination.S3Region,
+					Backend:  req.Destination.ResticBackend,
 				},
 			}
 		}
@@ -856,8 +1017,8 @@ func (h *BackupHandler) verifyServerOwnership(c *gin.Context, serverID, userID s
 	// Load servers from YAML config
 	servers, err := config.LoadServers(h.config.Storage.ConfigDir)
 	if err != nil {
-		log.Printf("[API] Failed to load servers: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load servers"})
+		logger.ErrorContext(c.Request.Context(), "Failed to load servers", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load servers")
 		return false
 	}
 
@@ -869,7 +1030,7 @@ func (h *BackupHandler) verifyServerOwnership(c *gin.Context, serverID, userID s
 		}
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+	apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 	return false
 }
 
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/permissions"
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
+	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBatchParallelism = 4
+	maxBatchParallelism     = 16
+	// batchServerTimeout bounds one server's part of a batch; a deploy
+	// uploads a whole release
+	batchServerTimeout = time.Hour
+	// maxBatches is how many batches are kept to be looked up
+	maxBatches = 50
+)
+
+const (
+	// taskStatusPending marks a server a batch has not reached yet
+	taskStatusPending taskStatus = "pending"
+	// taskStatusSkipped marks a server a halted rollout never reached
+	taskStatusSkipped taskStatus = "skipped"
+)
+
+// batchActions maps each batch action to the permission it needs on every
+// server of the batch
+var batchActions = map[string]string{
+	"start":   permissions.ServersStart,
+	"stop":    permissions.ServersStop,
+	"restart": permissions.ServersRestart,
+	"deploy":  permissions.ServersReleaseDeploy,
+	"rollout": permissions.ServersReleaseDeploy,
+}
+
+// BatchRequest picks the servers of a batch by ID, by tags or by group; a
+// server matched by any of them is included once
+type BatchRequest struct {
+	ServerIDs []string `json:"server_ids"`
+	// Tags matches the servers that have every tag
+	Tags  []string `json:"tags"`
+	Group string   `json:"group"`
+	// Parallelism is how many servers are worked on at once
+	Parallelism int `json:"parallelism"`
+	// Graceful applies to stop and restart and defaults to true
+	Graceful *bool `json:"graceful"`
+	// Deploy is the release the deploy and rollout actions deploy to every server
+	Deploy *ReleaseDeployRequest `json:"deploy"`
+	// Waves splits a rollout into waves, each a number of servers or a
+	// percentage of all of them, e.g. ["1", "25%", "100%"]
+	Waves []string `json:"waves"`
+	// Health is what a rollout waits for on the servers of a wave
+	Health *RolloutHealthCheck `json:"health"`
+	// RollbackOnFailure rolls back the servers a halted rollout deployed to
+	RollbackOnFailure bool `json:"rollback_on_failure"`
+}
+
+type batchServer struct {
+	ServerID   string     `json:"server_id"`
+	TaskID     string     `json:"task_id,omitempty"`
+	Status     taskStatus `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Wave numbers the rollout wave of the server, from 1
+	Wave       int  `json:"wave,omitempty"`
+	RolledBack bool `json:"rolled_back,omitempty"`
+}
+
+// batchWave is a wave of a rollout
+type batchWave struct {
+	Servers []string   `json:"servers"`
+	Status  taskStatus `json:"status"`
+}
+
+type batchRecord struct {
+	ID          string         `json:"id"`
+	Action      string         `json:"action"`
+	Status      taskStatus     `json:"status"`
+	Parallelism int            `json:"parallelism"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	RequestID   string         `json:"request_id,omitempty"`
+	Servers     []*batchServer `json:"servers"`
+	// Waves and Halted are set for a rollout; Halted says why it stopped
+	Waves  []*batchWave `json:"waves,omitempty"`
+	Halted string       `json:"halted,omitempty"`
+}
+
+func (b *batchRecord) clone() *batchRecord {
+	copied := *b
+	copied.Servers = make([]*batchServer, len(b.Servers))
+	for i, server := range b.Servers {
+		entry := *server
+		copied.Servers[i] = &entry
+	}
+	if b.Waves != nil {
+		copied.Waves = make([]*batchWave, len(b.Waves))
+		for i, wave := range b.Waves {
+			entry := *wave
+			entry.Servers = slices.Clone(wave.Servers)
+			copied.Waves[i] = &entry
+		}
+	}
+	return &copied
+}
+
+// batchStore keeps the recent batches of this manager instance. Every change
+// is published under its lock, so subscribers see the changes in order.
+type batchStore struct {
+	mu      sync.Mutex
+	order   []string
+	batches map[string]*batchRecord
+}
+
+func newBatchStore() *batchStore {
+	return &batchStore{batches: make(map[string]*batchRecord)}
+}
+
+func (s *batchStore) add(batch *batchRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[batch.ID] = batch
+	s.order = append(s.order, batch.ID)
+	if len(s.order) > maxBatches {
+		delete(s.batches, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *batchStore) get(id string) (*batchRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch, ok := s.batches[id]
+	if !ok {
+		return nil, false
+	}
+	return batch.clone(), true
+}
+
+// update changes a batch and hands a copy of the result to publish
+func (s *batchStore) update(id string, change func(*batchRecord), publish func(*batchRecord)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch, ok := s.batches[id]
+	if !ok {
+		return
+	}
+	change(batch)
+	publish(batch.clone())
+}
+
+func batchRoom(batchID string) string {
+	return "server-batch:" + batchID
+}
+
+func batchStatusMessage(batch *batchRecord) *ws.Message {
+	return &ws.Message{Type: "batch_status", Payload: batch, Timestamp: time.Now()}
+}
+
+// BatchServers starts, stops, restarts or deploys a release to many servers
+// at once. Up to parallelism servers are worked on at a time, each as a task
+// of its own on the server's task stream; the batch and each server's status
+// are streamed over WS /ws/servers/batch/:batchId. A rollout deploys in waves
+// and waits for the servers of each wave to stay healthy before the next.
+// POST /api/v1/servers/batch/:action
+func (h *ServerHandler) BatchServers(c *gin.Context) {
+	action := c.Param("action")
+	permission, ok := batchActions[action]
+	if !ok {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, fmt.Sprintf("Unknown batch action %q", action))
+		return
+	}
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if (action == "deploy" || action == "rollout") && (req.Deploy == nil || strings.TrimSpace(req.Deploy.PackageName) == "") {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "deploy.package_name is required")
+		return
+	}
+	if req.Parallelism < 0 || req.Parallelism > maxBatchParallelism {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("parallelism must be between 1 and %d", maxBatchParallelism))
+		return
+	}
+	if req.Parallelism == 0 {
+		req.Parallelism = defaultBatchParallelism
+	}
+
+	servers, err := selectBatchServers(h.serverManager.GetAll(), req)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	var (
+		waves [][]config.ServerDefinition
+		gate  rolloutGate
+	)
+	if action == "rollout" {
+		if waves, err = planWaves(servers, req.Waves); err == nil {
+			gate, err = newRolloutGate(req.Health)
+		}
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+	}
+	userID := getUserIDFromContext(c)
+	if userID == nil {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	var denied []string
+	for _, serverDef := range servers {
+		allowed, err := middleware.HasPermission(h.rbacManager, *userID, serverDef.ID, permission)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Server permission check failed", "server_id", serverDef.ID, "permission", permission, "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check permissions")
+			return
+		}
+		if allowed && action == "rollout" && req.RollbackOnFailure {
+			allowed, err = middleware.HasPermission(h.rbacManager, *userID, serverDef.ID, permissions.ServersReleaseRollback)
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "Server permission check failed", "server_id", serverDef.ID, "permission", permissions.ServersReleaseRollback, "error", err)
+				apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check permissions")
+				return
+			}
+		}
+		if !allowed {
+			denied = append(denied, serverDef.ID)
+		}
+	}
+	if len(denied) > 0 {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions for servers: "+strings.Join(denied, ", "))
+		return
+	}
+
+	// Rendered up front so a broken template fails the request, not the batch
+	configFiles := make(map[string][]config.RenderedFile)
+	if action == "deploy" || action == "rollout" {
+		for _, serverDef := range servers {
+			files, err := config.RenderTemplates(h.config.Storage.ConfigDir, serverDef)
+			if err != nil {
+				apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, fmt.Sprintf("Failed to render config templates of %s: %v", serverDef.ID, err))
+				return
+			}
+			configFiles[serverDef.ID] = files
+		}
+	}
+
+	batch := &batchRecord{
+		ID:          fmt.Sprintf("batch-%d", time.Now().UnixNano()),
+		Action:      action,
+		Status:      taskStatusRunning,
+		Parallelism: req.Parallelism,
+		StartedAt:   time.Now(),
+		RequestID:   tracing.RequestID(c.Request.Context()),
+	}
+	for _, serverDef := range servers {
+		batch.Servers = append(batch.Servers, &batchServer{ServerID: serverDef.ID, Status: taskStatusPending})
+	}
+	for i, wave := range waves {
+		entry := &batchWave{Status: taskStatusPending}
+		for _, serverDef := range wave {
+			entry.Servers = append(entry.Servers, serverDef.ID)
+			for _, server := range batch.Servers {
+				if server.ServerID == serverDef.ID {
+					server.Wave = i + 1
+				}
+			}
+		}
+		batch.Waves = append(batch.Waves, entry)
+	}
+	h.batches.add(batch)
+	logger.InfoContext(c.Request.Context(), "Batch requested", "batch_id", batch.ID, "action", action, "servers", len(servers), "user_id", *userID)
+	c.JSON(http.StatusAccepted, batch.clone())
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
+	stop := context.AfterFunc(h.tasksCtx, cancel)
+	h.pendingOps.Add(1)
+	go func() {
+		defer h.pendingOps.Done()
+		defer cancel()
+		defer stop()
+		if action == "rollout" {
+			h.runRollout(ctx, batch.ID, waves, req, gate, configFiles, userID)
+			return
+		}
+		fanOut(ctx, servers, req.Parallelism, batchServerTimeout, func(ctx context.Context, serverDef config.ServerDefinition) {
+			h.runBatchServer(ctx, batch.ID, action, serverDef, req, configFiles[serverDef.ID], userID)
+		})
+		h.finishBatch(batch.ID)
+	}()
+}
+
+// GetBatch returns a batch and the status of each of its servers
+// GET /api/v1/servers/batch/:batchId
+func (h *ServerHandler) GetBatch(c *gin.Context) {
+	batch, ok := h.readableBatch(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// HandleBatchWebSocket streams a batch's status: the current status when the
+// client connects, then the whole batch again whenever a server's changes
+// WS /ws/servers/batch/:batchId
+func (h *ServerHandler) HandleBatchWebSocket(c *gin.Context) {
+	batch, ok := h.readableBatch(c)
+	if !ok {
+		return
+	}
+	claims := c.MustGet("user").(*auth.Claims)
+
+	upgrader := buildUpgrader(h.config.Security.CORS.AllowedOrigins)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to upgrade WebSocket", "batch_id", batch.ID, "error", err)
+		return
+	}
+	client := &ws.Client{
+		ID:       fmt.Sprintf("batch-%s-%d", batch.ID, time.Now().UnixNano()),
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Conn:     conn,
+		Room:     batchRoom(batch.ID),
+		Send:     make(chan *ws.Message, 256),
+		Hub:      h.hub,
+	}
+	h.hub.Register <- client
+	// Sent through the hub once registered, so it is never newer than the
+	// changes that follow it
+	h.batches.update(batch.ID, func(*batchRecord) {}, func(current *batchRecord) {
+		h.hub.BroadcastToRoom(batchRoom(batch.ID), batchStatusMessage(current))
+	})
+
+	go client.WritePump()
+	go client.ReadPump()
+}
+
+// readableBatch returns the batch named in the request if the user may read
+// the tasks of all its servers
+func (h *ServerHandler) readableBatch(c *gin.Context) (*batchRecord, bool) {
+	batch, ok := h.batches.get(c.Param("batchId"))
+	if !ok {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Batch not found")
+		return nil, false
+	}
+	userID := getUserIDFromContext(c)
+	if userID == nil {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return nil, false
+	}
+	for _, server := range batch.Servers {
+		allowed, err := middleware.HasPermission(h.rbacManager, *userID, server.ServerID, permissions.ServersTasksRead)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Server permission check failed", "server_id", server.ServerID, "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check permissions")
+			return nil, false
+		}
+		if !allowed {
+			apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions for server "+server.ServerID)
+			return nil, false
+		}
+	}
+	return batch, true
+}
+
+// runBatchServer runs a batch's action on one server as a task, once the
+// executor lets it
+func (h *ServerHandler) runBatchServer(ctx context.Context, batchID, action string, serverDef config.ServerDefinition, req BatchRequest, configFiles []config.RenderedFile, userID *int64) {
+	serverID := serverDef.ID
+	err := h.runQueuedTask(ctx, serverID, "batch-"+action, func(ctx context.Context, task *taskRecord) error {
+		h.updateBatchServer(batchID, serverID, func(server *batchServer) {
+			server.TaskID = task.ID
+			server.Status = taskStatusRunning
+			server.StartedAt = &task.StartedAt
+		})
+
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+		err := h.runBatchAction(ctx, action, serverDef, req, configFiles, userID, emit)
+		if err != nil {
+			emit(fmt.Sprintf("Batch %s failed: %v", action, err))
+		}
+		return err
+	})
+
+	h.updateBatchServer(batchID, serverID, func(server *batchServer) {
+		now := time.Now()
+		server.FinishedAt = &now
+		server.Status = taskStatusComplete
+		if err != nil {
+			server.Status = taskStatusFailed
+			server.Error = err.Error()
+		}
+	})
+}
+
+func (h *ServerHandler) runBatchAction(ctx context.Context, action string, serverDef config.ServerDefinition, req BatchRequest, configFiles []config.RenderedFile, userID *int64, emit func(string)) error {
+	serverID := serverDef.ID
+	if action == "deploy" {
+		_, conn, err := h.connectServer(serverID)
+		if err != nil {
+			return err
+		}
+		return h.runReleaseDeploy(ctx, serverID, serverDef, conn, configFiles, *req.Deploy, userID, emit)
+	}
+
+	if action != "stop" {
+		if window, active := h.inMaintenance(serverID); active {
+			return errors.New(maintenanceError(window))
+		}
+	}
+	graceful := req.Graceful == nil || *req.Graceful
+	serverConfig := h.createServerConfig(&serverDef)
+	h.resetWatchdog(serverID)
+	switch action {
+	case "start":
+		emit("Starting server...")
+		if err := h.lifecycleManager.StartServer(serverID, serverConfig); err != nil {
+			h.activityLogger.LogServerStart(serverID, userID, false, err.Error())
+			return err
+		}
+		h.activityLogger.LogServerStart(serverID, userID, true, "")
+		emit("Server started")
+	case "stop":
+		emit("Stopping server...")
+		if err := h.lifecycleManager.StopServer(serverID, serverConfig, graceful); err != nil {
+			h.activityLogger.LogServerStop(serverID, userID, graceful, false, err.Error())
+			return err
+		}
+		h.activityLogger.LogServerStop(serverID, userID, graceful, true, "")
+		emit("Server stopped")
+	case "restart":
+		emit("Restarting server...")
+		if err := h.lifecycleManager.RestartServer(serverID, serverConfig, graceful); err != nil {
+			h.activityLogger.LogServerRestart(serverID, userID, graceful, false, err.Error())
+			return err
+		}
+		h.activityLogger.LogServerRestart(serverID, userID, graceful, true, "")
+		emit("Server restarted")
+	}
+	return nil
+}
+
+func (h *ServerHandler) updateBatchServer(batchID, serverID string, change func(*batchServer)) {
+	h.batches.update(batchID, func(batch *batchRecord) {
+		for _, server := range batch.Servers {
+			if server.ServerID == serverID {
+				change(server)
+			}
+		}
+	}, h.publishBatch)
+}
+
+// finishBatch completes a batch. Servers it never reached, because the
+// manager shut down, count as failed; a halted rollout failed.
+func (h *ServerHandler) finishBatch(batchID string) {
+	h.batches.update(batchID, func(batch *batchRecord) {
+		now := time.Now()
+		batch.FinishedAt = &now
+		batch.Status = taskStatusComplete
+		if batch.Halted != "" {
+			batch.Status = taskStatusFailed
+		}
+		for _, server := range batch.Servers {
+			if server.Status == taskStatusPending {
+				server.Status = taskStatusFailed
+				server.Error = "not started: the manager is shutting down"
+			}
+			if server.Status == taskStatusFailed {
+				batch.Status = taskStatusFailed
+			}
+		}
+		logger.Info("Batch finished", "batch_id", batch.ID, "action", batch.Action, "status", batch.Status)
+	}, h.publishBatch)
+}
+
+func (h *ServerHandler) publishBatch(batch *batchRecord) {
+	h.hub.Publish(batchRoom(batch.ID), batchStatusMessage(batch))
+}
+
+// selectBatchServers returns the servers a batch request names, in the
+// manager's order. Unknown server IDs and an empty selection are errors.
+func selectBatchServers(all []config.ServerDefinition, req BatchRequest) ([]config.ServerDefinition, error) {
+	if len(req.ServerIDs) == 0 && len(req.Tags) == 0 && strings.TrimSpace(req.Group) == "" {
+		return nil, errors.New("server_ids, tags or group is required")
+	}
+	known := make(map[string]bool, len(all))
+	for _, serverDef := range all {
+		known[serverDef.ID] = true
+	}
+	var unknown []string
+	for _, id := range req.ServerIDs {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown servers: %s", strings.Join(unknown, ", "))
+	}
+
+	group := strings.TrimSpace(req.Group)
+	var selected []config.ServerDefinition
+	for _, serverDef := range all {
+		byTags := len(req.Tags) > 0
+		for _, tag := range req.Tags {
+			if !slices.Contains(serverDef.Tags, tag) {
+				byTags = false
+				break
+			}
+		}
+		if slices.Contains(req.ServerIDs, serverDef.ID) || byTags || (group != "" && strings.EqualFold(serverDef.Group, group)) {
+			selected = append(selected, serverDef)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no servers match the selection")
+	}
+	return selected, nil
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+func TestSelectBatchServers(t *testing.T) {
+	all := []config.ServerDefinition{
+		{ID: "eu-1", Group: "Europe", Tags: []string{"eu", "survival"}},
+		{ID: "eu-2", Group: "Europe", Tags: []string{"eu", "creative"}},
+		{ID: "us-1", Group: "America", Tags: []string{"us", "survival"}},
+		{ID: "lobby"},
+	}
+	ids := func(servers []config.ServerDefinition) string {
+		var names []string
+		for _, server := range servers {
+			names = append(names, server.ID)
+		}
+		return strings.Join(names, ",")
+	}
+
+	for _, tc := range []struct {
+		req  BatchRequest
+		want string
+	}{
+		{BatchRequest{ServerIDs: []string{"us-1", "eu-1"}}, "eu-1,us-1"},
+		{BatchRequest{Tags: []string{"survival"}}, "eu-1,us-1"},
+		{BatchRequest{Tags: []string{"eu", "survival"}}, "eu-1"},
+		{BatchRequest{Group: "europe"}, "eu-1,eu-2"},
+		{BatchRequest{ServerIDs: []string{"lobby", "eu-1"}, Tags: []string{"eu"}}, "eu-1,eu-2,lobby"},
+	} {
+		servers, err := selectBatchServers(all, tc.req)
+		if err != nil || ids(servers) != tc.want {
+			t.Errorf("%+v: expected %s, got %s (%v)", tc.req, tc.want, ids(servers), err)
+		}
+	}
+
+	for _, req := range []BatchRequest{
+		{},
+		{ServerIDs: []string{"eu-1", "missing"}},
+		{Tags: []string{"asia"}},
+	} {
+		if _, err := selectBatchServers(all, req); err == nil {
+			t.Errorf("expected %+v to be refused", req)
+		}
+	}
+}
+
+func TestBatchStoreKeepsRecentBatches(t *testing.T) {
+	store := newBatchStore()
+	for i := 0; i <= maxBatches; i++ {
+		store.add(&batchRecord{ID: fmt.Sprintf("batch-%d", i), Servers: []*batchServer{{ServerID: "s", Status: taskStatusPending}}})
+	}
+	if _, ok := store.get("batch-0"); ok {
+		t.Fatal("expected the oldest batch to be dropped")
+	}
+	newest := fmt.Sprintf("batch-%d", maxBatches)
+	batch, ok := store.get(newest)
+	if !ok {
+		t.Fatal("expected the newest batch to be kept")
+	}
+	batch.Servers[0].Status = taskStatusFailed
+	if again, _ := store.get(newest); again.Servers[0].Status != taskStatusPending {
+		t.Fatal("expected get to return a copy")
+	}
+}
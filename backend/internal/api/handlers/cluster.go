@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+)
+
+// watchdogResetTopic carries the IDs of servers started or stopped by hand, so
+// the leader's watchdog forgets their crashes whichever instance handled it
+const watchdogResetTopic = "watchdog.reset"
+
+// orphanedTaskInterval is how often the leader closes the tasks of instances
+// that stopped while running them
+const orphanedTaskInterval = time.Minute
+
+// SetCluster keeps task records and output in the database, restoring the
+// history from before a restart, and shares them and CPU samples with the
+// other manager instances of a cluster, whose tasks, starts and stops wait
+// for each other on the same server. It must be called before SetWatchdog
+// and before node is started.
+func (h *ServerHandler) SetCluster(node *cluster.Node) {
+	h.cluster = node
+	h.sharedTasks = &sharedTaskStore{db: h.db.DB, instance: node.ID(), clustered: node.Clustered()}
+	h.sharedTasks.restore(time.Duration(h.config.Tasks.RetentionDays) * 24 * time.Hour)
+	if !node.Clustered() {
+		return
+	}
+
+	h.cpuSamples = metrics.NewSharedCPUSamples(h.db.DB, "live")
+	if h.lifecycleManager != nil {
+		h.lifecycleManager.SetLocker(node)
+	}
+
+	node.Subscribe(watchdogResetTopic, func(data json.RawMessage) {
+		var serverID string
+		if err := json.Unmarshal(data, &serverID); err == nil && h.watchdog != nil {
+			h.watchdog.Reset(serverID)
+		}
+	})
+	node.OnLead(func(ctx context.Context) {
+		go h.closeOrphanedTasks(ctx)
+	})
+}
+
+// leading reports whether this instance runs the background jobs only one
+// instance of a cluster may run
+func (h *ServerHandler) leading() bool {
+	return h.cluster == nil || h.cluster.Leader()
+}
+
+// lockServer waits until no other instance of a cluster runs a task on the
+// server or starts or stops it, and keeps them off it until unlock is called
+func (h *ServerHandler) lockServer(ctx context.Context, serverID string) (unlock func(), err error) {
+	if h.cluster == nil {
+		return func() {}, nil
+	}
+	return h.cluster.Lock(ctx, server.ServerLease(serverID))
+}
+
+func (h *ServerHandler) closeOrphanedTasks(ctx context.Context) {
+	ticker := time.NewTicker(orphanedTaskInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			alive, err := h.cluster.Instances(ctx)
+			if err != nil {
+				logger.Warn("Failed to list cluster instances", "error", err)
+				continue
+			}
+			h.sharedTasks.failOrphaned(alive)
+		}
+	}
+}
@@ -51,5 +51,9 @@ func (m *MockProcessManager) GetPID(serverID, sessionName string) (int, error) {
 	return 0, nil
 }
 
+func (m *MockProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {
+	// No-op for mock
+}
+
 // Ensure interface compliance
 var _ server.ProcessManager = &MockProcessManager{}
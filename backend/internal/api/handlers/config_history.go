@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// ConfigHistoryHandler lists and restores previous versions of servers.yaml
+// and config.yaml
+type ConfigHistoryHandler struct {
+	history       *config.History
+	serverManager *config.ServerManager
+	reloader      *config.Reloader
+}
+
+// NewConfigHistoryHandler creates a new config history handler
+func NewConfigHistoryHandler(history *config.History, serverManager *config.ServerManager, reloader *config.Reloader) *ConfigHistoryHandler {
+	return &ConfigHistoryHandler{history: history, serverManager: serverManager, reloader: reloader}
+}
+
+// ConfigVersionDetail is one snapshot with its content and the diff from the snapshot before it
+type ConfigVersionDetail struct {
+	config.ConfigVersion
+	Content string `json:"content"`
+	Diff    string `json:"diff"`
+}
+
+// ListVersions returns the stored versions of every tracked file, or of ?file= only
+func (h *ConfigHistoryHandler) ListVersions(c *gin.Context) {
+	files := h.history.Files()
+	if file := c.Query("file"); file != "" {
+		files = []string{file}
+	}
+
+	versions := make([]config.ConfigVersion, 0)
+	for _, file := range files {
+		fileVersions, err := h.history.Versions(file)
+		if err != nil {
+			h.respondError(c, err)
+			return
+		}
+		versions = append(versions, fileVersions...)
+	}
+	c.JSON(http.StatusOK, gin.H{"files": h.history.Files(), "versions": versions})
+}
+
+// GetVersion returns one stored version with a diff against the version before it
+func (h *ConfigHistoryHandler) GetVersion(c *gin.Context) {
+	detail, err := h.versionDetail(c.Param("file"), c.Param("version"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, detail)
+}
+
+// RestoreVersion puts a stored version back in place. Restoring servers.yaml
+// replaces the server definitions; restoring config.yaml reloads it and rolls
+// back if the restored file is invalid. The current file is snapshotted first.
+func (h *ConfigHistoryHandler) RestoreVersion(c *gin.Context) {
+	file, version := c.Param("file"), c.Param("version")
+	data, err := h.history.Read(file, version)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	path, err := h.history.Path(file)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	if err := h.history.Record(file); err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to snapshot current file", err.Error())
+		return
+	}
+
+	switch filepath.Base(path) {
+	case "servers.yaml":
+		var created, updated, deleted int
+		err := h.serverManager.Persist(func() (err error) {
+			created, updated, deleted, err = h.serverManager.RestoreYAML(data)
+			return err
+		})
+		if errors.Is(err, config.ErrPersistFailed) {
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save servers.yaml", err.Error())
+			return
+		}
+		if err != nil {
+			apierror.RespondDetails(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "Failed to restore server definitions", err.Error())
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "Restored config version", "file", file, "version", version)
+		c.JSON(http.StatusOK, gin.H{"file": file, "version": version, "created": created, "updated": updated, "deleted": deleted})
+
+	default:
+		previous, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read current file", err.Error())
+			return
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to write file", err.Error())
+			return
+		}
+		result, err := h.reloader.Reload()
+		if err != nil {
+			_ = os.WriteFile(path, previous, 0644)
+			apierror.RespondDetails(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "Restored configuration is invalid", err.Error())
+			return
+		}
+		if err := h.history.Record(file); err != nil {
+			logger.WarnContext(c.Request.Context(), "Failed to snapshot restored file", "file", file, "error", err)
+		}
+		logger.InfoContext(c.Request.Context(), "Restored config version", "file", file, "version", version)
+		c.JSON(http.StatusOK, gin.H{"file": file, "version": version, "reload": result})
+	}
+}
+
+func (h *ConfigHistoryHandler) versionDetail(file, version string) (*ConfigVersionDetail, error) {
+	data, err := h.history.Read(file, version)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := h.history.Diff(file, version)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := h.history.Versions(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return &ConfigVersionDetail{ConfigVersion: v, Content: string(data), Diff: diff}, nil
+		}
+	}
+	return nil, config.ErrConfigVersionNotFound
+}
+
+func (h *ConfigHistoryHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, config.ErrConfigFileNotTracked):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Config file has no history")
+	case errors.Is(err, config.ErrConfigVersionNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Config version not found")
+	default:
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read config history", err.Error())
+	}
+}
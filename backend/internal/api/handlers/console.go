@@ -1,9 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/console"
@@ -30,6 +31,7 @@ type ConsoleHandler struct {
 	sshPool        *ssh.ConnectionPool
 	rbacManager    *auth.RBACManager
 	commandHistory *console.CommandHistory
+	processes      server.ProcessManager
 }
 
 // NewConsoleHandler creates a new console handler
@@ -52,6 +54,12 @@ func NewConsoleHandler(
 	}
 }
 
+// SetProcessManager lets consoles of servers whose process manager follows
+// their output, such as docker, read it from there instead of screen
+func (h *ConsoleHandler) SetProcessManager(processes server.ProcessManager) {
+	h.processes = processes
+}
+
 // HandleConsoleWebSocket handles WebSocket connections for console streaming
 // WS /ws/console/:serverId
 func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
@@ -60,7 +68,7 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 	// Get user from context (set by JWT middleware)
 	userClaims, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 	claims := userClaims.(*auth.Claims)
@@ -68,14 +76,14 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 	// Check permission to view console
 	hasPermission, err := h.rbacManager.HasServerPermission(claims.UserID, serverID, permissions.ServersConsoleView)
 	if err != nil || !hasPermission {
-		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to view console"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "No permission to view console")
 		return
 	}
 
 	// Get server definition
 	servers, err := config.LoadServers(h.config.Storage.ConfigDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load servers"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load servers")
 		return
 	}
 
@@ -88,7 +96,7 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 	}
 
 	if serverDef == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
@@ -98,12 +106,10 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 		// Create new session
 		// First, ensure SSH connection exists
 		sshConfig := &ssh.ClientConfig{
-			Host:            serverDef.Connection.Host,
-			Port:            serverDef.Connection.Port,
-			Username:        serverDef.Connection.Username,
-			AuthMethod:      serverDef.Connection.AuthMethod,
-			KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-			TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+			Host:       serverDef.Connection.Host,
+			Port:       serverDef.Connection.Port,
+			Username:   serverDef.Connection.Username,
+			AuthMethod: serverDef.Connection.AuthMethod,
 		}
 
 		if serverDef.Connection.AuthMethod == "key" {
@@ -114,7 +120,7 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 
 		sshConn, err := h.sshPool.GetConnection(serverID, sshConfig)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to server", "details": err.Error()})
+			respondSSHConnectError(c, "Failed to connect to server", err)
 			return
 		}
 
@@ -125,9 +131,13 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 		if runAsUser == "" || runAsUser == sshConfig.Username {
 			useSudo = false
 		}
-		session, err = h.sessionManager.StartSession(serverID, sessionName, sshConn, runAsUser, useSudo)
+		if source, ok := h.processSource(serverID, sessionName); ok {
+			session, err = h.sessionManager.StartSourceSession(serverID, sessionName, source)
+		} else {
+			session, err = h.sessionManager.StartSession(serverID, sessionName, sshConn, runAsUser, useSudo)
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start console session", "details": err.Error()})
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to start console session", err.Error())
 			return
 		}
 	}
@@ -136,8 +146,8 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 	upgrader := buildUpgrader(h.config.Security.CORS.AllowedOrigins)
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("[Console] Failed to upgrade WebSocket: %v (origin=%s, server=%s)", err, c.Request.Header.Get("Origin"), serverID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "WebSocket upgrade failed", "details": err.Error()})
+		logger.ErrorContext(c.Request.Context(), "Failed to upgrade WebSocket", "server_id", serverID, "origin", c.Request.Header.Get("Origin"), "error", err)
+		apierror.RespondDetails(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "WebSocket upgrade failed", err.Error())
 		return
 	}
 
@@ -183,6 +193,36 @@ func (h *ConsoleHandler) HandleConsoleWebSocket(c *gin.Context) {
 	go h.handleClientMessages(client, session, claims)
 }
 
+// processSource returns a console source for a server whose process manager
+// follows its output
+func (h *ConsoleHandler) processSource(serverID, sessionName string) (console.Source, bool) {
+	router, ok := h.processes.(*server.ProcessManagerRouter)
+	if !ok {
+		return nil, false
+	}
+	streamer, ok := router.Streamer(serverID)
+	if !ok {
+		return nil, false
+	}
+	return &processSource{streamer: streamer, processes: router, serverID: serverID, sessionName: sessionName}, true
+}
+
+// processSource feeds a console session from a process manager
+type processSource struct {
+	streamer    server.LogStreamer
+	processes   server.ProcessManager
+	serverID    string
+	sessionName string
+}
+
+func (ps *processSource) StreamLogs(ctx context.Context, onLine func(string)) error {
+	return ps.streamer.StreamLogs(ctx, ps.serverID, ps.sessionName, onLine)
+}
+
+func (ps *processSource) SendCommand(command string) error {
+	return ps.processes.SendCommand(ps.serverID, ps.sessionName, command)
+}
+
 // handleClientMessages handles incoming messages from WebSocket client
 func (h *ConsoleHandler) handleClientMessages(client *ws.Client, session *console.Session, claims *auth.Claims) {
 	defer func() {
@@ -202,7 +242,7 @@ func (h *ConsoleHandler) handleClientMessages(client *ws.Client, session *consol
 		err := client.Conn.ReadJSON(&msg)
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[Console] WebSocket error: %v", err)
+				logger.Error("WebSocket error", "error", err)
 			}
 			break
 		}
@@ -231,7 +271,7 @@ func (h *ConsoleHandler) handleClientMessages(client *ws.Client, session *consol
 			})
 
 		default:
-			log.Printf("[Console] Unknown message type: %s", msg.Type)
+			logger.Warn("Unknown message type", "type", msg.Type)
 		}
 	}
 }
@@ -313,7 +353,7 @@ func (h *ConsoleHandler) GetCommandHistory(c *gin.Context) {
 	// Check permission
 	hasPermission, err := h.rbacManager.HasServerPermission(userClaims.UserID, serverID, permissions.ServersConsoleView)
 	if err != nil || !hasPermission {
-		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to view console"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "No permission to view console")
 		return
 	}
 
@@ -328,8 +368,8 @@ func (h *ConsoleHandler) GetCommandHistory(c *gin.Context) {
 	// Get history
 	commands, err := h.commandHistory.GetRecentCommands(serverID, limit)
 	if err != nil {
-		log.Printf("[Console] Failed to get command history: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get command history"})
+		logger.ErrorContext(c.Request.Context(), "Failed to get command history", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get command history")
 		return
 	}
 
@@ -349,7 +389,7 @@ func (h *ConsoleHandler) SearchCommandHistory(c *gin.Context) {
 	// Check permission
 	hasPermission, err := h.rbacManager.HasServerPermission(userClaims.UserID, serverID, permissions.ServersConsoleView)
 	if err != nil || !hasPermission {
-		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to view console"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "No permission to view console")
 		return
 	}
 
@@ -362,8 +402,8 @@ func (h *ConsoleHandler) SearchCommandHistory(c *gin.Context) {
 
 	commands, err := h.commandHistory.SearchCommands(serverID, query, limit)
 	if err != nil {
-		log.Printf("[Console] Failed to search command history: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search command history"})
+		logger.ErrorContext(c.Request.Context(), "Failed to search command history", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to search command history")
 		return
 	}
 
@@ -374,6 +414,67 @@ func (h *ConsoleHandler) SearchCommandHistory(c *gin.Context) {
 	})
 }
 
+// SearchConsoleLogs searches the server's stored console output, newest
+// first, optionally within ?from= and ?to=
+// GET /api/v1/servers/:id/console/search?q=keyword
+func (h *ConsoleHandler) SearchConsoleLogs(c *gin.Context) {
+	serverID := c.Param("id")
+	userClaims := c.MustGet("user").(*auth.Claims)
+
+	hasPermission, err := h.rbacManager.HasServerPermission(userClaims.UserID, serverID, permissions.ServersConsoleView)
+	if err != nil || !hasPermission {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "No permission to view console")
+		return
+	}
+	index := h.sessionManager.LogIndex()
+	if index == nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Console output is not indexed")
+		return
+	}
+
+	page, ok := parsePage(c, consolePages)
+	if !ok {
+		return
+	}
+	from, to, err := listingRange(c)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	query := console.LogQuery{
+		ServerID: serverID,
+		Text:     c.Query("q"),
+		From:     from,
+		To:       to,
+		Limit:    page.QueryLimit(),
+	}
+	if page.After != "" {
+		if query.Before, err = strconv.ParseInt(page.After, 10, 64); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCursor, "Invalid cursor")
+			return
+		}
+	}
+
+	lines, total, err := index.Search(c.Request.Context(), query)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to search console logs", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to search console logs")
+		return
+	}
+
+	count, hasMore := page.Trim(len(lines))
+	lines = lines[:count]
+	lastKey := ""
+	if count > 0 {
+		lastKey = strconv.FormatInt(lines[count-1].ID, 10)
+	}
+	respondPage(c, lines, page.Info(total, hasMore, lastKey), gin.H{
+		"lines": lines,
+		"count": len(lines),
+		"query": query.Text,
+	})
+}
+
 // GetAutocomplete returns command autocomplete suggestions
 // GET /api/v1/servers/:id/console/autocomplete?prefix=say
 func (h *ConsoleHandler) GetAutocomplete(c *gin.Context) {
@@ -384,14 +485,14 @@ func (h *ConsoleHandler) GetAutocomplete(c *gin.Context) {
 	// Check permission
 	hasPermission, err := h.rbacManager.HasServerPermission(userClaims.UserID, serverID, permissions.ServersConsoleView)
 	if err != nil || !hasPermission {
-		c.JSON(http.StatusForbidden, gin.H{"error": "No permission to view console"})
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "No permission to view console")
 		return
 	}
 
 	suggestions, err := h.commandHistory.GetAutocomplete(serverID, prefix, 10)
 	if err != nil {
-		log.Printf("[Console] Failed to get autocomplete: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get autocomplete"})
+		logger.ErrorContext(c.Request.Context(), "Failed to get autocomplete", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get autocomplete")
 		return
 	}
 
@@ -408,7 +509,7 @@ func (h *ConsoleHandler) recordConsoleSession(sessionID, serverID string, userID
 	`, sessionID, serverID, userID, ip, userAgent)
 
 	if err != nil {
-		log.Printf("[Console] Failed to record session: %v", err)
+		logger.Error("Failed to record session", "server_id", serverID, "error", err)
 	}
 }
 
@@ -421,7 +522,7 @@ func (h *ConsoleHandler) updateSessionDisconnected(sessionID string) {
 	`, sessionID)
 
 	if err != nil {
-		log.Printf("[Console] Failed to update session: %v", err)
+		logger.Error("Failed to update session", "error", err)
 	}
 }
 
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+	"github.com/TheGojiOG/HytaleSM/internal/permissions"
+)
+
+// What raised a dashboard alert
+const (
+	alertCrashLoop  = "crash_loop"
+	alertDrift      = "drift"
+	alertLoginSpike = "login_spike"
+)
+
+// dashboardServers counts the servers the caller may see by connection status
+type dashboardServers struct {
+	Total    int                                   `json:"total"`
+	ByStatus map[models.ServerConnectionStatus]int `json:"by_status"`
+}
+
+// dashboardAgents counts the agents of those servers by what their last check found
+type dashboardAgents struct {
+	Connected int `json:"connected"`
+	// Unreachable agents run on hosts that answered over SSH
+	Unreachable int `json:"unreachable"`
+	// Unknown agents run on hosts that did not answer or were not checked yet
+	Unknown int `json:"unknown"`
+}
+
+// dashboardAlert is a condition that needs attention right now
+type dashboardAlert struct {
+	Type     string     `json:"type"`
+	ServerID string     `json:"server_id,omitempty"`
+	Host     string     `json:"host,omitempty"`
+	Message  string     `json:"message"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// dashboardTask is a task still running on a server
+type dashboardTask struct {
+	ServerID  string    `json:"server_id"`
+	ID        string    `json:"id"`
+	Task      string    `json:"task"`
+	StartedAt time.Time `json:"started_at"`
+	LastLine  string    `json:"last_line,omitempty"`
+}
+
+// dashboardBackup is the newest backup of a server
+type dashboardBackup struct {
+	ServerID  string    `json:"server_id"`
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Status    string    `json:"status"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// dashboardRelease is the newest downloaded release
+type dashboardRelease struct {
+	ID           int64      `json:"id"`
+	Version      string     `json:"version"`
+	Patchline    string     `json:"patchline"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"`
+}
+
+// dashboardSummary is everything the dashboard shows, in one response
+type dashboardSummary struct {
+	Servers       dashboardServers  `json:"servers"`
+	Alerts        []dashboardAlert  `json:"alerts"`
+	RunningTasks  []dashboardTask   `json:"running_tasks"`
+	LastBackups   []dashboardBackup `json:"last_backups"`
+	LatestRelease *dashboardRelease `json:"latest_release"`
+	Agents        dashboardAgents   `json:"agents"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+}
+
+// GetDashboard returns the counts and states the dashboard shows in one response.
+// Each part only covers what the caller may see: server and agent counts need
+// status access to a server, and alerts, tasks, backups and the latest
+// release need the permission of the endpoint that lists them.
+func (h *ServerHandler) GetDashboard(c *gin.Context) {
+	access := newCallerAccess(c)
+	summary := dashboardSummary{
+		Servers:      dashboardServers{ByStatus: make(map[models.ServerConnectionStatus]int)},
+		Alerts:       h.dashboardAlerts(access),
+		RunningTasks: h.dashboardTasks(access),
+		GeneratedAt:  time.Now().UTC(),
+	}
+	servers := h.visibleServers(access, permissions.ServersStatusRead)
+	summary.Servers.Total = len(servers)
+	for _, serverID := range servers {
+		snapshot, ok := h.statusRefresher.Get(serverID)
+		if !ok {
+			// Like the server list, count a server not checked yet as disconnected
+			h.statusRefresher.Refresh(serverID)
+			summary.Servers.ByStatus[models.StatusDisconnected]++
+			summary.Agents.Unknown++
+			continue
+		}
+		summary.Servers.ByStatus[snapshot.Health.ConnectionStatus]++
+		switch {
+		case snapshot.Health.AgentStatus.Connected:
+			summary.Agents.Connected++
+		case snapshot.Health.SSHStatus.Connected:
+			summary.Agents.Unreachable++
+		default:
+			summary.Agents.Unknown++
+		}
+	}
+
+	var err error
+	if summary.LastBackups, err = h.dashboardBackups(access); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load dashboard backups", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load dashboard")
+		return
+	}
+	if summary.LatestRelease, err = h.dashboardRelease(access); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load dashboard release", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load dashboard")
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// dashboardAlerts lists the servers the watchdog gave up on, the servers that
+// drifted from their definition and the hosts with a failed login spike
+func (h *ServerHandler) dashboardAlerts(access *callerAccess) []dashboardAlert {
+	alerts := make([]dashboardAlert, 0)
+	if h.watchdog != nil {
+		for _, serverID := range h.visibleServers(access, permissions.ServersStatusRead) {
+			state := h.watchdog.State(serverID)
+			if !state.GaveUp {
+				continue
+			}
+			message := "The watchdog stopped restarting the server after repeated crashes"
+			if state.LastError != "" {
+				message += ": " + state.LastError
+			}
+			alerts = append(alerts, dashboardAlert{Type: alertCrashLoop, ServerID: serverID, Message: message, Since: state.LastCrash})
+		}
+	}
+	for _, serverID := range h.visibleServers(access, permissions.ServersDriftRead) {
+		report, ok := h.driftDetector.Get(serverID)
+		if !ok || !report.Drifted {
+			continue
+		}
+		drifted := 0
+		for _, item := range report.Items {
+			if item.Status == DriftStatusDrift {
+				drifted++
+			}
+		}
+		checkedAt := report.CheckedAt
+		alerts = append(alerts, dashboardAlert{
+			Type:     alertDrift,
+			ServerID: serverID,
+			Message:  fmt.Sprintf("%d checks differ from the server definition", drifted),
+			Since:    &checkedAt,
+		})
+	}
+	if h.can(access, "", permissions.SecurityHostsRead) {
+		for _, report := range h.hostSecurity.All() {
+			if !report.Spike {
+				continue
+			}
+			checkedAt := report.CheckedAt
+			alerts = append(alerts, dashboardAlert{
+				Type:    alertLoginSpike,
+				Host:    report.Host,
+				Message: fmt.Sprintf("%d failed SSH logins within %s", report.FailedLogins, report.Window),
+				Since:   &checkedAt,
+			})
+		}
+	}
+	return alerts
+}
+
+// dashboardTasks lists the running tasks, oldest first
+func (h *ServerHandler) dashboardTasks(access *callerAccess) []dashboardTask {
+	tasks := make([]dashboardTask, 0)
+	for _, serverID := range h.visibleServers(access, permissions.ServersTasksRead) {
+		for _, record := range h.listTasks(serverID) {
+			if record.Status != taskStatusRunning {
+				continue
+			}
+			tasks = append(tasks, dashboardTask{
+				ServerID:  serverID,
+				ID:        record.ID,
+				Task:      record.Task,
+				StartedAt: record.StartedAt,
+				LastLine:  record.LastLine,
+			})
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].StartedAt.Before(tasks[j].StartedAt) })
+	return tasks
+}
+
+// dashboardBackups returns the newest backup of each server, by server
+func (h *ServerHandler) dashboardBackups(access *callerAccess) ([]dashboardBackup, error) {
+	backups := make([]dashboardBackup, 0)
+	servers := h.visibleServers(access, permissions.ServersBackupsList)
+	if len(servers) == 0 {
+		return backups, nil
+	}
+	where, args := inServers(servers)
+	rows, err := h.db.QueryContext(access.ctx, `
+		SELECT id, server_id, filename, status, size_bytes, created_at
+		FROM backups
+		WHERE `+where+` AND status != 'deleted'
+			AND created_at = (
+				SELECT MAX(created_at) FROM backups newest
+				WHERE newest.server_id = backups.server_id AND newest.status != 'deleted'
+			)
+		ORDER BY server_id, id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query backups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var backup dashboardBackup
+		if err := rows.Scan(&backup.ID, &backup.ServerID, &backup.Filename, &backup.Status, &backup.SizeBytes, &backup.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan backup: %w", err)
+		}
+		// Backups created within the same second tie; keep one
+		if n := len(backups); n > 0 && backups[n-1].ServerID == backup.ServerID {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+	return backups, rows.Err()
+}
+
+// dashboardRelease returns the newest release that can be deployed, or nil
+func (h *ServerHandler) dashboardRelease(access *callerAccess) (*dashboardRelease, error) {
+	if !h.can(access, "", permissions.ReleasesList) {
+		return nil, nil
+	}
+	var release dashboardRelease
+	var downloadedAt sql.NullTime
+	err := h.db.QueryRowContext(access.ctx, `
+		SELECT id, version, patchline, downloaded_at
+		FROM releases
+		WHERE removed = 0 AND status = 'ready'
+		ORDER BY id DESC
+		LIMIT 1
+	`).Scan(&release.ID, &release.Version, &release.Patchline, &downloadedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query latest release: %w", err)
+	}
+	if downloadedAt.Valid {
+		release.DownloadedAt = &downloadedAt.Time
+	}
+	return &release, nil
+}
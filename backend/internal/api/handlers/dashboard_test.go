@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+)
+
+func TestGetDashboardSummarizesWhatTheCallerMaySee(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	handler.db = db
+	handler.rbacManager = auth.NewRBACManager(db.DB)
+
+	users := map[string]int64{}
+	for _, user := range []struct{ name, role string }{{"test-viewer", "Viewer"}, {"test-admin", "Admin"}} {
+		result, err := db.Exec(`INSERT INTO users (username, email, password_hash) VALUES (?, ?, 'x')`, user.name, user.name+"@example.com")
+		if err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		if _, err := db.Exec(`INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE name = ?`, id, user.role); err != nil {
+			t.Fatalf("assign role: %v", err)
+		}
+		users[user.name] = id
+	}
+
+	older := time.Now().Add(-2 * time.Hour).UTC()
+	newer := time.Now().Add(-time.Hour).UTC()
+	for _, backup := range []struct {
+		id      string
+		created time.Time
+	}{{"backup-old", older}, {"backup-new", newer}} {
+		if _, err := db.Exec(`INSERT INTO backups (id, server_id, filename, size_bytes, created_at, destination_type, destination_path, status)
+			VALUES (?, 'test-server', ?, 10, ?, 'local', '/backups', 'completed')`, backup.id, backup.id+".tar.gz", backup.created); err != nil {
+			t.Fatalf("insert backup: %v", err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO releases (version, file_path, sha256) VALUES ('1.0.0', '/releases/1.0.0.zip', 'abc')`); err != nil {
+		t.Fatalf("insert release: %v", err)
+	}
+
+	handler.statusRefresher.store("test-server", HealthCheck{
+		ConnectionStatus: models.StatusRunning,
+		SSHStatus:        SSHHealthStatus{Connected: true},
+	})
+	handler.hostSecurity.mu.Lock()
+	handler.hostSecurity.reports["test.example.com"] = HostSecurityReport{Host: "test.example.com", Spike: true, FailedLogins: 150, Window: "1h0m0s"}
+	handler.hostSecurity.mu.Unlock()
+
+	dashboard := func(user string) dashboardSummary {
+		router := gin.New()
+		router.GET("/dashboard", func(c *gin.Context) {
+			c.Set("user_id", users[user])
+			handler.GetDashboard(c)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var summary dashboardSummary
+		if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+			t.Fatalf("decode dashboard: %v", err)
+		}
+		return summary
+	}
+
+	summary := dashboard("test-viewer")
+	if summary.Servers.Total != 1 || summary.Servers.ByStatus[models.StatusRunning] != 1 {
+		t.Fatalf("expected one running server, got %+v", summary.Servers)
+	}
+	if summary.Agents.Unreachable != 1 {
+		t.Fatalf("expected the agent to be unreachable over a live SSH connection, got %+v", summary.Agents)
+	}
+	if len(summary.LastBackups) != 1 || summary.LastBackups[0].ID != "backup-new" {
+		t.Fatalf("expected only the newest backup, got %+v", summary.LastBackups)
+	}
+	if summary.LatestRelease != nil || len(summary.Alerts) != 0 {
+		t.Fatalf("expected a viewer not to see releases or host security alerts, got %+v and %+v", summary.LatestRelease, summary.Alerts)
+	}
+
+	summary = dashboard("test-admin")
+	if len(summary.Alerts) != 1 || summary.Alerts[0].Type != alertLoginSpike {
+		t.Fatalf("expected the login spike alert, got %+v", summary.Alerts)
+	}
+	if summary.LatestRelease == nil || summary.LatestRelease.Version != "1.0.0" {
+		t.Fatalf("expected the release, got %+v", summary.LatestRelease)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DebugHandler exposes pprof profiles and runtime state for diagnosing a
+// running manager. Routes are only registered when debug.enabled is set.
+type DebugHandler struct {
+	startedAt time.Time
+}
+
+// NewDebugHandler creates a new debug handler
+func NewDebugHandler() *DebugHandler {
+	return &DebugHandler{startedAt: time.Now()}
+}
+
+// RegisterRoutes mounts the pprof endpoints under the group, which must be
+// rooted at /debug for the pprof index links to resolve
+func (h *DebugHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/pprof/", h.PprofIndex)
+	rg.GET("/pprof/cmdline", h.PprofCmdline)
+	rg.GET("/pprof/profile", h.PprofProfile)
+	rg.GET("/pprof/symbol", h.PprofSymbol)
+	rg.POST("/pprof/symbol", h.PprofSymbol)
+	rg.GET("/pprof/trace", h.PprofTrace)
+	rg.GET("/pprof/:profile", h.PprofNamed)
+	rg.GET("/goroutines", h.Goroutines)
+	rg.GET("/buildinfo", h.BuildInfo)
+}
+
+// PprofIndex lists the available runtime profiles
+func (h *DebugHandler) PprofIndex(c *gin.Context) {
+	pprof.Index(c.Writer, c.Request)
+}
+
+// PprofCmdline returns the running program's command line
+func (h *DebugHandler) PprofCmdline(c *gin.Context) {
+	pprof.Cmdline(c.Writer, c.Request)
+}
+
+// PprofProfile records a CPU profile for the number of seconds in ?seconds (default 30)
+func (h *DebugHandler) PprofProfile(c *gin.Context) {
+	pprof.Profile(c.Writer, c.Request)
+}
+
+// PprofSymbol looks up the function names for program counters
+func (h *DebugHandler) PprofSymbol(c *gin.Context) {
+	pprof.Symbol(c.Writer, c.Request)
+}
+
+// PprofTrace records an execution trace for the number of seconds in ?seconds (default 1)
+func (h *DebugHandler) PprofTrace(c *gin.Context) {
+	pprof.Trace(c.Writer, c.Request)
+}
+
+// PprofNamed returns a named profile such as heap, goroutine, block or mutex
+func (h *DebugHandler) PprofNamed(c *gin.Context) {
+	pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+}
+
+// Goroutines dumps every goroutine's stack as plain text
+func (h *DebugHandler) Goroutines(c *gin.Context) {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", buf)
+}
+
+// BuildInfo reports the binary's module versions, VCS stamp and runtime counters
+func (h *DebugHandler) BuildInfo(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	response := gin.H{
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"num_cpu":    runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"goroutines": runtime.NumGoroutine(),
+		"heap_alloc": mem.HeapAlloc,
+		"heap_sys":   mem.HeapSys,
+		"num_gc":     mem.NumGC,
+		"started_at": h.startedAt,
+		"uptime":     time.Since(h.startedAt).Round(time.Second).String(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		settings := make(map[string]string, len(info.Settings))
+		for _, setting := range info.Settings {
+			settings[setting.Key] = setting.Value
+		}
+		deps := make(map[string]string, len(info.Deps))
+		for _, dep := range info.Deps {
+			deps[dep.Path] = dep.Version
+		}
+		response["path"] = info.Path
+		response["main_version"] = info.Main.Version
+		response["settings"] = settings
+		response["deps"] = deps
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDebugRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewDebugHandler().RegisterRoutes(router.Group("/debug"))
+
+	cases := map[string]string{
+		"/debug/pprof/":                  "goroutine",
+		"/debug/pprof/goroutine?debug=1": "goroutine profile",
+		"/debug/goroutines":              "TestDebugRoutes",
+		"/debug/buildinfo":               "go_version",
+	}
+	for path, want := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("%s: expected body to contain %q", path, want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultKeepReleases is how many earlier releases a deploy keeps on the host
+const defaultKeepReleases = 3
+
+// ListDeployments returns a server's release deployments, newest first
+func (h *ServerHandler) ListDeployments(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 500 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+
+	deployments, err := releases.NewManager(h.config, h.db).ListDeployments(serverID, limit)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list deployments", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load deployments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deployments": deployments})
+}
+
+// RollbackRelease switches a server back to the release deployed before the
+// active one and restarts it
+func (h *ServerHandler) RollbackRelease(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	if window, active := h.inMaintenance(serverID); active {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, maintenanceError(window))
+		return
+	}
+	if h.db == nil {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Deployment history is not available")
+		return
+	}
+
+	manager := releases.NewManager(h.config, h.db)
+	active, previous, err := manager.RollbackTarget(serverID)
+	if errors.Is(err, releases.ErrNoRollbackTarget) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "No previous release to roll back to")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load deployments", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load deployments")
+		return
+	}
+
+	conn, err := h.sshPool.GetConnection(serverID, h.createServerConfig(&serverDef).SSHConfig)
+	if err != nil {
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
+		return
+	}
+
+	userID := getUserIDFromContext(c)
+	c.JSON(http.StatusAccepted, gin.H{"message": "Release rollback started", "from": active, "to": previous})
+
+	h.goTask(c, serverID, "release-rollback", func(ctx context.Context, task *taskRecord) {
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+		h.finishTask(serverID, task.ID, h.runReleaseRollback(ctx, serverDef, conn, manager, active, previous, userID, emit))
+	})
+}
+
+// runReleaseRollback points the server's current release back at previous
+// and restarts the server
+func (h *ServerHandler) runReleaseRollback(ctx context.Context, serverDef config.ServerDefinition, conn *ssh.PooledConnection, manager *releases.Manager, active, previous *releases.Deployment, userID *int64, emit func(string)) error {
+	serverID := serverDef.ID
+	emit(fmt.Sprintf("Rolling back from %s to %s...", active.ReleaseDir, previous.ReleaseDir))
+
+	writer := newLineSinkWriter(emit)
+	err := conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(releaseRollbackScript(previous)), writer, writer)
+	writer.FlushRemaining()
+	if err == nil {
+		err = manager.CompleteRollback(active, previous)
+	}
+	activity := &logging.Activity{
+		ServerID:     serverID,
+		UserID:       userID,
+		ActivityType: logging.ActivityReleaseRollback,
+		Description:  fmt.Sprintf("Rolled back release %s to %s", active.PackageName, previous.PackageName),
+		Metadata: map[string]interface{}{
+			"from_deployment": active.ID,
+			"to_deployment":   previous.ID,
+			"release_dir":     previous.ReleaseDir,
+		},
+		Success: err == nil,
+	}
+	if err != nil {
+		activity.ErrorMessage = err.Error()
+	}
+	_ = h.activityLogger.LogActivity(activity)
+	if err != nil {
+		emit("Rollback failed: " + err.Error())
+		return err
+	}
+
+	emit("Restarting server...")
+	h.resetWatchdog(serverID)
+	if err := h.lifecycleManager.RestartServer(serverID, h.createServerConfig(&serverDef), true); err != nil {
+		h.activityLogger.LogServerRestart(serverID, userID, true, false, err.Error())
+		emit("Restart failed: " + err.Error())
+		return err
+	}
+	h.activityLogger.LogServerRestart(serverID, userID, true, true, "")
+	emit("Rollback complete.")
+	return nil
+}
+
+// releaseRollbackScript renders the script that makes a deployment's release
+// the current one again
+func releaseRollbackScript(d *releases.Deployment) string {
+	script := strings.ReplaceAll(ServerReleaseRollbackScript, "{{ACTIVATE_RELEASE}}", ReleaseActivateScript)
+	script = strings.ReplaceAll(script, "{{SERVICE_USER}}", escapeForScript(d.ServiceUser))
+	script = strings.ReplaceAll(script, "{{INSTALL_DIR}}", escapeForScriptPath(d.InstallDir))
+	script = strings.ReplaceAll(script, "{{RELEASE_NAME}}", escapeForScript(d.ReleaseDir))
+	script = strings.ReplaceAll(script, "{{USE_SUDO}}", boolToScript(d.UseSudo))
+	return script
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestReleaseRollbackScriptSwitchesReleases(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the release scripts need GNU tools")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	current, err := user.Current()
+	if err != nil {
+		t.Skip("current user unknown")
+	}
+
+	installDir := t.TempDir()
+	// An install from before releases were versioned, with a world and a
+	// rendered config file
+	writeTestFile(t, filepath.Join(installDir, "Server", "HytaleServer.jar"), "legacy")
+	writeTestFile(t, filepath.Join(installDir, "Server", "universe", "world.dat"), "world")
+	writeTestFile(t, filepath.Join(installDir, "Server", "config.json"), "rendered")
+	writeTestFile(t, filepath.Join(installDir, "releases", "r1", "Server", "HytaleServer.jar"), "v1")
+	writeTestFile(t, filepath.Join(installDir, "releases", "r1", "Server", "only-v1.txt"), "v1")
+	writeTestFile(t, filepath.Join(installDir, "releases", "r1", "Assets.zip"), "assets v1")
+	writeTestFile(t, filepath.Join(installDir, "releases", "r2", "Server", "HytaleServer.jar"), "v2")
+	writeTestFile(t, filepath.Join(installDir, "releases", "r2", "Server", "config.json"), "packaged")
+	writeTestFile(t, filepath.Join(installDir, "releases", "r2", "Assets.zip"), "assets v2")
+
+	run := func(release string) string {
+		t.Helper()
+		script := releaseRollbackScript(&releases.Deployment{
+			InstallDir:  installDir,
+			ReleaseDir:  release,
+			ServiceUser: current.Username,
+		})
+		output, err := exec.Command("bash", "-c", script).CombinedOutput()
+		if err != nil {
+			t.Fatalf("rollback to %s: %v\n%s", release, err, output)
+		}
+		return string(output)
+	}
+
+	run("r1")
+	// A rollback keeps files that are in the way, as a deploy would have replaced them
+	if got := readTestFile(t, filepath.Join(installDir, "Server", "HytaleServer.jar")); got != "legacy" {
+		t.Fatalf("expected the regular file to be kept, got %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(installDir, "Assets.zip")); got != "assets v1" {
+		t.Fatalf("expected r1 assets, got %q", got)
+	}
+	os.Remove(filepath.Join(installDir, "Server", "HytaleServer.jar"))
+	run("r1")
+	if got := readTestFile(t, filepath.Join(installDir, "Server", "HytaleServer.jar")); got != "v1" {
+		t.Fatalf("expected r1 server, got %q", got)
+	}
+
+	output := run("r2")
+	if target, _ := os.Readlink(filepath.Join(installDir, "releases", "current")); target != "r2" {
+		t.Fatalf("expected current to point at r2, got %q", target)
+	}
+	if got := readTestFile(t, filepath.Join(installDir, "Server", "HytaleServer.jar")); got != "v2" {
+		t.Fatalf("expected r2 server, got %q", got)
+	}
+	if _, err := os.Lstat(filepath.Join(installDir, "Server", "only-v1.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the link to a file r2 lacks to be removed, got %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(installDir, "Server", "config.json")); got != "rendered" || !strings.Contains(output, "Keeping Server/config.json") {
+		t.Fatalf("expected the rendered config to be kept, got %q\n%s", got, output)
+	}
+	if got := readTestFile(t, filepath.Join(installDir, "Server", "universe", "world.dat")); got != "world" {
+		t.Fatalf("expected the world to be untouched, got %q", got)
+	}
+
+	run("r1")
+	if got := readTestFile(t, filepath.Join(installDir, "Server", "only-v1.txt")); got != "v1" {
+		t.Fatalf("expected r1 files back, got %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(installDir, "Assets.zip")); got != "assets v1" {
+		t.Fatalf("expected r1 assets, got %q", got)
+	}
+}
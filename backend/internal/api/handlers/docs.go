@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/openapi"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+)
+
+// swaggerUIPolicy relaxes the API's CSP just enough to load Swagger UI from unpkg
+const swaggerUIPolicy = "default-src 'none'; " +
+	"script-src 'self' https://unpkg.com; " +
+	"style-src 'self' 'unsafe-inline' https://unpkg.com; " +
+	"img-src 'self' data: https://unpkg.com; " +
+	"connect-src 'self'; " +
+	"font-src 'self'; " +
+	"object-src 'none'; " +
+	"frame-ancestors 'none';"
+
+// DocsHandler serves the generated OpenAPI spec and its Swagger UI
+type DocsHandler struct{}
+
+// NewDocsHandler creates a new docs handler
+func NewDocsHandler() *DocsHandler {
+	return &DocsHandler{}
+}
+
+// OpenAPISpec returns the OpenAPI 3 document
+func (h *DocsHandler) OpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openapi.Spec)
+}
+
+// SwaggerUI renders the interactive API documentation
+func (h *DocsHandler) SwaggerUI(c *gin.Context) {
+	c.Header("Content-Security-Policy", swaggerUIPolicy)
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.SwaggerUI)
+}
+
+// SwaggerInit returns the script that points Swagger UI at the spec
+func (h *DocsHandler) SwaggerInit(c *gin.Context) {
+	c.Data(http.StatusOK, "application/javascript; charset=utf-8", openapi.SwaggerInit)
+}
+
+// Versions lists the supported API versions and the routes scheduled for removal
+func (h *DocsHandler) Versions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"latest":       versioning.Latest,
+		"supported":    versioning.Supported,
+		"deprecations": versioning.Deprecations,
+	})
+}
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/agentbin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultDriftInterval applies when drift.interval is unset
+	defaultDriftInterval = time.Hour
+	// driftConcurrency bounds how many servers a sweep checks at once
+	driftConcurrency = 4
+	// driftCheckTimeout bounds the SSH work for one server
+	driftCheckTimeout = 2 * time.Minute
+)
+
+// Drift check outcomes
+const (
+	DriftStatusOK      = "ok"
+	DriftStatusDrift   = "drift"
+	DriftStatusUnknown = "unknown"
+)
+
+// DriftItem compares one part of a server's expected state with what was found on the host
+type DriftItem struct {
+	Check    string `json:"check"` // service_user, install_dir, executable, backup_cron or agent_version
+	Status   string `json:"status"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// DriftReport is the result of the last drift check for a server
+type DriftReport struct {
+	ServerID  string      `json:"server_id"`
+	CheckedAt time.Time   `json:"checked_at"`
+	Drifted   bool        `json:"drifted"`
+	Error     string      `json:"error,omitempty"`
+	Items     []DriftItem `json:"items"`
+}
+
+// DriftDetector periodically compares each server's definition with the state
+// of its host and keeps the latest report per server
+type DriftDetector struct {
+	servers  func() []string
+	check    func(ctx context.Context, serverID string) (DriftReport, bool)
+	interval atomic.Int64
+
+	mu      sync.RWMutex
+	reports map[string]DriftReport
+}
+
+// NewDriftDetector creates a detector. check inspects one server, returning
+// false if it no longer exists.
+func NewDriftDetector(servers func() []string, check func(ctx context.Context, serverID string) (DriftReport, bool), interval time.Duration) *DriftDetector {
+	d := &DriftDetector{
+		servers: servers,
+		check:   check,
+		reports: make(map[string]DriftReport),
+	}
+	d.SetInterval(interval)
+	return d
+}
+
+// SetInterval changes how often every server is re-checked. It takes effect
+// after the current wait.
+func (d *DriftDetector) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDriftInterval
+	}
+	d.interval.Store(int64(interval))
+}
+
+// Run checks every server immediately and then on each interval until ctx is done
+func (d *DriftDetector) Run(ctx context.Context) {
+	d.checkAll(ctx)
+	timer := time.NewTimer(time.Duration(d.interval.Load()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			d.checkAll(ctx)
+			timer.Reset(time.Duration(d.interval.Load()))
+		}
+	}
+}
+
+// Get returns the last report for a server
+func (d *DriftDetector) Get(serverID string) (DriftReport, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	report, ok := d.reports[serverID]
+	return report, ok
+}
+
+// CheckNow inspects a server in the calling goroutine and stores the report
+func (d *DriftDetector) CheckNow(ctx context.Context, serverID string) (DriftReport, bool) {
+	report, ok := d.check(ctx, serverID)
+	if !ok {
+		d.Forget(serverID)
+		return DriftReport{}, false
+	}
+
+	d.mu.Lock()
+	previous, existed := d.reports[serverID]
+	d.reports[serverID] = report
+	d.mu.Unlock()
+
+	if report.Drifted && (!existed || !previous.Drifted) {
+		logger.Warn("Configuration drift detected", "server_id", serverID, "checks", driftedChecks(report))
+	} else if !report.Drifted && existed && previous.Drifted && report.Error == "" {
+		logger.Info("Configuration drift resolved", "server_id", serverID)
+	}
+	return report, true
+}
+
+// Forget drops a deleted server's report
+func (d *DriftDetector) Forget(serverID string) {
+	d.mu.Lock()
+	delete(d.reports, serverID)
+	d.mu.Unlock()
+}
+
+func (d *DriftDetector) checkAll(ctx context.Context) {
+	fanOut(ctx, d.servers(), driftConcurrency, driftCheckTimeout, func(ctx context.Context, serverID string) {
+		d.CheckNow(ctx, serverID)
+	})
+}
+
+func driftedChecks(report DriftReport) []string {
+	var checks []string
+	for _, item := range report.Items {
+		if item.Status == DriftStatusDrift {
+			checks = append(checks, item.Check)
+		}
+	}
+	return checks
+}
+
+// StartDriftDetector begins checking every server for drift in the background.
+// It stops when ctx is cancelled or the handler shuts down.
+func (h *ServerHandler) StartDriftDetector(ctx context.Context, interval time.Duration) {
+	h.driftDetector.SetInterval(interval)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(h.tasksCtx, cancel)
+	go func() {
+		defer cancel()
+		defer stop()
+		h.driftDetector.Run(ctx)
+	}()
+}
+
+// GetServerDrift reports where a server's host differs from its configuration
+// GET /api/v1/servers/:id/drift
+// Returns the last background result; ?refresh=true checks the server again first.
+func (h *ServerHandler) GetServerDrift(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	report, ok := h.driftDetector.Get(serverID)
+	if !ok || c.Query("refresh") == "true" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), driftCheckTimeout)
+		defer cancel()
+		report, ok = h.driftDetector.CheckNow(ctx, serverID)
+		if !ok {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// driftExpectation is the manager's view of a server's host
+type driftExpectation struct {
+	ServiceUser     string
+	InstallDir      string
+	Executable      string
+	BackupSchedules map[string]string // schedule ID -> cron expression
+	AgentSHA256     string            // empty when no local agent binary exists for the host's arch
+}
+
+// driftObservation is what drift_check.sh and the crontab report for a host
+type driftObservation struct {
+	UserOK      bool
+	DirOK       bool
+	DirPath     string
+	DirOwner    string
+	ExecOK      bool
+	ExecPath    string
+	Arch        string
+	AgentSHA256 string
+	Crontab     string
+	CrontabErr  error
+}
+
+// checkDrift inspects one server over SSH. Connection failures are reported in
+// the result rather than as an error so the last report shows why it is stale.
+func (h *ServerHandler) checkDrift(ctx context.Context, serverID string) (DriftReport, bool) {
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return DriftReport{}, false
+	}
+	report := DriftReport{ServerID: serverID, CheckedAt: time.Now(), Items: []DriftItem{}}
+
+	deps := resolveDependencies(serverDef)
+	expected := driftExpectation{
+		ServiceUser:     deps.ServiceUser,
+		InstallDir:      deps.InstallDir,
+		Executable:      strings.TrimSpace(serverDef.Server.Executable),
+		BackupSchedules: make(map[string]string),
+	}
+	schedules, err := backup.NewScheduleStore(h.db.DB).ListSchedules(serverID)
+	if err != nil {
+		report.Error = "Failed to load backup schedules: " + err.Error()
+		return report, true
+	}
+	for _, schedule := range schedules {
+		if schedule.Enabled && strings.TrimSpace(schedule.Schedule) != "" {
+			expected.BackupSchedules[schedule.ID] = strings.TrimSpace(schedule.Schedule)
+		}
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
+	}
+	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
+	if err != nil {
+		report.Error = "Failed to connect via SSH: " + err.Error()
+		return report, true
+	}
+
+	script := ServerDriftCheckScript
+	script = strings.ReplaceAll(script, "{{SERVICE_USER}}", escapeForScript(deps.ServiceUser))
+	script = strings.ReplaceAll(script, "{{INSTALL_DIR}}", escapeForScriptPath(deps.InstallDir))
+	script = strings.ReplaceAll(script, "{{WORKING_DIR}}", escapeForScriptPath(serverDef.Server.WorkingDirectory))
+	script = strings.ReplaceAll(script, "{{EXECUTABLE}}", escapeForScriptPath(expected.Executable))
+
+	output, err := conn.Client.RunCommandContext(ctx, bashDollarQuotedCommand(script))
+	if err != nil {
+		report.Error = "Drift check failed: " + err.Error()
+		return report, true
+	}
+	observed := parseDriftCheckOutput(output)
+	observed.Crontab, observed.CrontabErr = h.readBackupCrontabs(serverDef, deps, schedules)
+
+	if arch := normalizeArch(observed.Arch); arch != "" {
+		if sum, err := agentbin.Verify(agentbin.Path(h.config.Storage.DataDir, arch)); err == nil {
+			expected.AgentSHA256 = sum
+		}
+	}
+
+	report.Items = compareDrift(serverID, expected, observed)
+	report.Drifted = len(driftedChecks(report)) > 0
+	return report, true
+}
+
+// readBackupCrontabs reads the crontab of every user a backup schedule runs as,
+// or of the service user when the server has no schedules
+func (h *ServerHandler) readBackupCrontabs(serverDef config.ServerDefinition, deps config.DependenciesConfig, schedules []*backup.BackupSchedule) (string, error) {
+	type cronUser struct {
+		name    string
+		useSudo bool
+	}
+	seen := make(map[cronUser]bool)
+	var users []cronUser
+	for _, schedule := range schedules {
+		runAsUser := strings.TrimSpace(schedule.RunAsUser)
+		user := cronUser{name: runAsUser, useSudo: schedule.UseSudo || runAsUser != ""}
+		if !seen[user] {
+			seen[user] = true
+			users = append(users, user)
+		}
+	}
+	if len(users) == 0 {
+		users = append(users, cronUser{name: deps.ServiceUser, useSudo: true})
+	}
+
+	var crontabs []string
+	for _, user := range users {
+		output, err := backup.ReadCronTab(h.sshPool, &serverDef, user.name, user.useSudo)
+		if err != nil {
+			return "", err
+		}
+		crontabs = append(crontabs, output)
+	}
+	return strings.Join(crontabs, "\n"), nil
+}
+
+func parseDriftCheckOutput(output string) driftObservation {
+	var observed driftObservation
+	for _, line := range strings.Split(output, "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "USER_OK":
+			observed.UserOK = val == "1"
+		case "DIR_OK":
+			observed.DirOK = val == "1"
+		case "DIR_PATH":
+			observed.DirPath = val
+		case "DIR_OWNER":
+			observed.DirOwner = val
+		case "EXEC_OK":
+			observed.ExecOK = val == "1"
+		case "EXEC_PATH":
+			observed.ExecPath = val
+		case "ARCH":
+			observed.Arch = val
+		case "AGENT_SHA256":
+			observed.AgentSHA256 = val
+		}
+	}
+	return observed
+}
+
+// compareDrift lists the differences between the expected and observed state
+func compareDrift(serverID string, expected driftExpectation, observed driftObservation) []DriftItem {
+	items := []DriftItem{}
+
+	user := DriftItem{Check: "service_user", Status: DriftStatusOK, Expected: expected.ServiceUser, Actual: expected.ServiceUser}
+	if !observed.UserOK {
+		user.Status, user.Actual, user.Message = DriftStatusDrift, "", "service user does not exist"
+	}
+	items = append(items, user)
+
+	dir := DriftItem{Check: "install_dir", Status: DriftStatusOK, Expected: expected.InstallDir, Actual: observed.DirPath}
+	switch {
+	case !observed.DirOK:
+		dir.Status, dir.Message = DriftStatusDrift, "install directory is missing"
+	case observed.UserOK && observed.DirOwner != "" && observed.DirOwner != expected.ServiceUser:
+		dir.Status, dir.Message = DriftStatusDrift, "install directory is owned by "+observed.DirOwner
+	}
+	items = append(items, dir)
+
+	if expected.Executable != "" {
+		exec := DriftItem{Check: "executable", Status: DriftStatusOK, Expected: expected.Executable, Actual: observed.ExecPath}
+		if !observed.ExecOK {
+			exec.Status, exec.Message = DriftStatusDrift, "server executable not found"
+		}
+		items = append(items, exec)
+	}
+
+	items = append(items, compareBackupCron(serverID, expected, observed)...)
+
+	agent := DriftItem{Check: "agent_version", Status: DriftStatusOK, Expected: expected.AgentSHA256, Actual: observed.AgentSHA256}
+	switch {
+	case observed.AgentSHA256 == "":
+		agent.Status, agent.Message = DriftStatusUnknown, "agent is not installed"
+	case expected.AgentSHA256 == "":
+		agent.Status, agent.Message = DriftStatusUnknown, "no local agent binary for "+observed.Arch
+	case observed.AgentSHA256 != expected.AgentSHA256:
+		agent.Status, agent.Message = DriftStatusDrift, "installed agent differs from the manager's build"
+	}
+	items = append(items, agent)
+
+	return items
+}
+
+func compareBackupCron(serverID string, expected driftExpectation, observed driftObservation) []DriftItem {
+	if observed.CrontabErr != nil {
+		return []DriftItem{{Check: "backup_cron", Status: DriftStatusUnknown, Message: "failed to read crontab: " + observed.CrontabErr.Error()}}
+	}
+
+	installed := backup.InstalledCronSchedules(observed.Crontab, serverID)
+	if len(expected.BackupSchedules) == 0 && len(installed) == 0 {
+		return []DriftItem{{Check: "backup_cron", Status: DriftStatusOK, Message: "no backup schedules"}}
+	}
+
+	ids := make([]string, 0, len(expected.BackupSchedules)+len(installed))
+	for id := range expected.BackupSchedules {
+		ids = append(ids, id)
+	}
+	for id := range installed {
+		if _, ok := expected.BackupSchedules[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var items []DriftItem
+	for _, id := range ids {
+		want, wanted := expected.BackupSchedules[id]
+		have, present := installed[id]
+		item := DriftItem{Check: "backup_cron", Status: DriftStatusOK, Expected: want, Actual: have, Message: "schedule " + id}
+		switch {
+		case !present:
+			item.Status, item.Message = DriftStatusDrift, "cron entry missing for schedule "+id
+		case !wanted:
+			item.Status, item.Message = DriftStatusDrift, "cron entry has no enabled schedule: "+backup.CronMarker(serverID, id)
+		case want != have:
+			item.Status, item.Message = DriftStatusDrift, "cron expression differs for schedule "+id
+		}
+		items = append(items, item)
+	}
+	return items
+}
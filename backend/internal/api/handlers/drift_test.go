@@ -0,0 +1,56 @@
+package handlers
+
+import "testing"
+
+func TestCompareDrift(t *testing.T) {
+	observed := parseDriftCheckOutput(`USER_OK=1
+DIR_OK=1
+DIR_PATH=/home/hytale/hytale-server
+DIR_OWNER=root
+EXEC_OK=1
+EXEC_PATH=/home/hytale/hytale-server/server.jar
+ARCH=x86_64
+AGENT_SHA256=aaaa
+`)
+	observed.Crontab = `0 3 * * * /bin/bash -lc "tar ..." # hsm-backup:alpha:daily
+0 4 * * * /bin/bash -lc "tar ..." # hsm-backup:alpha:weekly
+0 5 * * * /bin/bash -lc "tar ..." # hsm-backup:alpha-2:daily
+`
+	expected := driftExpectation{
+		ServiceUser: "hytale",
+		InstallDir:  "~/hytale-server",
+		Executable:  "server.jar",
+		BackupSchedules: map[string]string{
+			"daily":  "0 3 * * *",
+			"hourly": "0 * * * *",
+		},
+		AgentSHA256: "bbbb",
+	}
+
+	statuses := map[string]string{}
+	for _, item := range compareDrift("alpha", expected, observed) {
+		key := item.Check
+		if item.Check == "backup_cron" {
+			key += ":" + item.Expected + "|" + item.Actual
+		}
+		statuses[key] = item.Status
+	}
+
+	want := map[string]string{
+		"service_user":                    DriftStatusOK,
+		"install_dir":                     DriftStatusDrift, // owned by root
+		"executable":                      DriftStatusOK,
+		"backup_cron:0 3 * * *|0 3 * * *": DriftStatusOK,
+		"backup_cron:0 * * * *|":          DriftStatusDrift, // not installed
+		"backup_cron:|0 4 * * *":          DriftStatusDrift, // no longer configured
+		"agent_version":                   DriftStatusDrift,
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected %d items, got %v", len(want), statuses)
+	}
+	for key, status := range want {
+		if statuses[key] != status {
+			t.Errorf("%s: expected %s, got %q", key, status, statuses[key])
+		}
+	}
+}
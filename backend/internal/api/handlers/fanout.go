@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// fanOut calls fn for every item with at most limit calls running at once, so
+// a request touching every server opens a bounded number of connections. Each
+// call gets a context that ends after timeout or when ctx is done; items not
+// yet started when ctx ends are skipped. fanOut returns once all calls finish.
+func fanOut[T any](ctx context.Context, items []T, limit int, timeout time.Duration, fn func(ctx context.Context, item T)) {
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+
+	work := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range work {
+				itemCtx, cancel := context.WithTimeout(ctx, timeout)
+				fn(itemCtx, item)
+				cancel()
+			}
+		}()
+	}
+
+feed:
+	for _, item := range items {
+		select {
+		case work <- item:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+}
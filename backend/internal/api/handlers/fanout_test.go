@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOutBoundsConcurrencyAndTimesOut(t *testing.T) {
+	items := make([]int, 20)
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	timedOut := 0
+
+	fanOut(context.Background(), items, 4, 20*time.Millisecond, func(ctx context.Context, _ int) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			current := peak.Load()
+			if n <= current || peak.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		<-ctx.Done()
+		mu.Lock()
+		if ctx.Err() == context.DeadlineExceeded {
+			timedOut++
+		}
+		mu.Unlock()
+	})
+
+	if peak.Load() > 4 {
+		t.Fatalf("expected at most 4 concurrent calls, got %d", peak.Load())
+	}
+	if timedOut != len(items) {
+		t.Fatalf("expected every call to hit its timeout, got %d of %d", timedOut, len(items))
+	}
+}
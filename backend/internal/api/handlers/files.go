@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/files"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/sftp"
+)
+
+// maxUploadSize bounds a file uploaded into a server directory
+const maxUploadSize = 1 << 30
+
+// uploadField is the multipart field carrying an uploaded file
+const uploadField = "file"
+
+// File manager operations, as recorded in the activity log
+const (
+	fileOpWrite  = "write"
+	fileOpUpload = "upload"
+	fileOpMkdir  = "mkdir"
+	fileOpRename = "rename"
+	fileOpChmod  = "chmod"
+	fileOpDelete = "delete"
+)
+
+type fileContentRequest struct {
+	Path     string `json:"path" binding:"required"`
+	Content  string `json:"content"`
+	Checksum string `json:"checksum"` // of the content the edit was made against; empty to skip the check
+}
+
+type fileRenameRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+type filePathRequest struct {
+	Path string `json:"path" binding:"required"`
+}
+
+type fileChmodRequest struct {
+	Path string `json:"path" binding:"required"`
+	Mode string `json:"mode" binding:"required"` // octal, e.g. "0644"
+}
+
+// ListFiles lists a directory of the server's working directory; path
+// defaults to the working directory itself
+func (h *ServerHandler) ListFiles(c *gin.Context) {
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := files.Clean(c.Query("path"))
+	entries, err := fsys.List(rel)
+	if err != nil {
+		h.respondFileError(c, "list", rel, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": rel, "entries": entries})
+}
+
+// GetFileContent returns a text file with the checksum to send back with an edit
+func (h *ServerHandler) GetFileContent(c *gin.Context) {
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := files.Clean(c.Query("path"))
+	data, err := fsys.ReadText(rel)
+	if err == nil {
+		var entry files.Entry
+		if entry, err = fsys.Stat(rel); err == nil {
+			c.JSON(http.StatusOK, gin.H{"file": entry, "content": string(data), "checksum": files.Checksum(data)})
+			return
+		}
+	}
+	h.respondFileError(c, "read", rel, err)
+}
+
+// UpdateFileContent writes a text file, creating it when missing. An edit
+// made against an older version of the file is refused with its current checksum.
+func (h *ServerHandler) UpdateFileContent(c *gin.Context) {
+	var req fileContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(req.Content) > files.MaxTextSize {
+		h.respondFileError(c, fileOpWrite, req.Path, files.ErrTooLarge)
+		return
+	}
+	if !files.IsText([]byte(req.Content)) {
+		h.respondFileError(c, fileOpWrite, req.Path, files.ErrNotText)
+		return
+	}
+
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := files.Clean(req.Path)
+	if req.Checksum != "" {
+		current, err := fsys.ReadText(rel)
+		if err != nil && !errors.Is(err, files.ErrNotFound) {
+			h.respondFileError(c, fileOpWrite, rel, err)
+			return
+		}
+		if checksum := files.Checksum(current); checksum != req.Checksum {
+			apierror.RespondDetails(c, http.StatusConflict, apierror.CodeVersionConflict,
+				fmt.Sprintf("%s was changed since it was read; reload and try again", path.Base(rel)),
+				gin.H{"current_checksum": checksum})
+			return
+		}
+	}
+
+	err := fsys.Write(rel, strings.NewReader(req.Content), true)
+	h.logFileChange(c, fileOpWrite, fmt.Sprintf("Edited %s", rel), gin.H{"path": rel, "size": len(req.Content)}, err)
+	if err != nil {
+		h.respondFileError(c, fileOpWrite, rel, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": rel, "checksum": files.Checksum([]byte(req.Content))})
+}
+
+// UploadFile streams the multipart file into the directory given by path.
+// An existing file is only replaced with overwrite=true.
+func (h *ServerHandler) UploadFile(c *gin.Context) {
+	dir := files.Clean(c.Query("path"))
+	overwrite := c.Query("overwrite") == "true"
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "expected a multipart/form-data upload")
+		return
+	}
+
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest,
+				fmt.Sprintf("expected a %q field with the file", uploadField))
+			return
+		}
+		if part.FormName() != uploadField {
+			part.Close()
+			continue
+		}
+		name := part.FileName()
+		if name == "" || name != path.Base(name) || name == ".." || strings.Contains(name, `\`) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, fmt.Sprintf("invalid file name %q", name))
+			return
+		}
+		rel := path.Join(dir, name)
+		counter := &countingReader{r: part}
+		err = fsys.Write(rel, counter, overwrite)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = fmt.Errorf("upload is larger than %d bytes", maxUploadSize)
+		}
+		h.logFileChange(c, fileOpUpload, fmt.Sprintf("Uploaded %s", rel), gin.H{"path": rel, "size": counter.n}, err)
+		if err != nil {
+			h.respondFileError(c, fileOpUpload, rel, err)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"path": rel, "size": counter.n})
+		return
+	}
+}
+
+// DownloadFile sends a file as an attachment
+func (h *ServerHandler) DownloadFile(c *gin.Context) {
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := files.Clean(c.Query("path"))
+	f, entry, err := fsys.Open(rel)
+	if err != nil {
+		h.respondFileError(c, "download", rel, err)
+		return
+	}
+	defer f.Close()
+	c.DataFromReader(http.StatusOK, entry.Size, "application/octet-stream", f, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": entry.Name}),
+	})
+}
+
+// MakeDirectory creates a directory
+func (h *ServerHandler) MakeDirectory(c *gin.Context) {
+	var req filePathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	rel := files.Clean(req.Path)
+	h.fileChange(c, fileOpMkdir, rel, fmt.Sprintf("Created directory %s", rel), gin.H{"path": rel},
+		func(fsys *files.FS) error { return fsys.Mkdir(rel) })
+}
+
+// RenameFile moves a file or directory within the server's working directory
+func (h *ServerHandler) RenameFile(c *gin.Context) {
+	var req fileRenameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	from, to := files.Clean(req.From), files.Clean(req.To)
+	h.fileChange(c, fileOpRename, from, fmt.Sprintf("Renamed %s to %s", from, to), gin.H{"path": from, "to": to},
+		func(fsys *files.FS) error { return fsys.Rename(from, to) })
+}
+
+// ChmodFile sets the permission bits of a file or directory
+func (h *ServerHandler) ChmodFile(c *gin.Context) {
+	var req fileChmodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	mode, err := strconv.ParseUint(req.Mode, 8, 32)
+	if err != nil || mode > 0o777 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, files.ErrBadMode.Error())
+		return
+	}
+	rel := files.Clean(req.Path)
+	h.fileChange(c, fileOpChmod, rel, fmt.Sprintf("Changed mode of %s to %04o", rel, mode), gin.H{"path": rel, "mode": fmt.Sprintf("%04o", mode)},
+		func(fsys *files.FS) error { return fsys.Chmod(rel, os.FileMode(mode)) })
+}
+
+// DeleteFile deletes a file or empty directory, or a whole directory with recursive=true
+func (h *ServerHandler) DeleteFile(c *gin.Context) {
+	raw, ok := c.GetQuery("path")
+	if !ok || strings.TrimSpace(raw) == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "path is required")
+		return
+	}
+	rel := files.Clean(raw)
+	recursive := c.Query("recursive") == "true"
+	h.fileChange(c, fileOpDelete, rel, fmt.Sprintf("Deleted %s", rel), gin.H{"path": rel, "recursive": recursive},
+		func(fsys *files.FS) error { return fsys.Remove(rel, recursive) })
+}
+
+// fileChange runs a change to the server's files and records it in the activity log
+func (h *ServerHandler) fileChange(c *gin.Context, operation, rel, description string, metadata gin.H, change func(*files.FS) error) {
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	err := change(fsys)
+	h.logFileChange(c, operation, description, metadata, err)
+	if err != nil {
+		h.respondFileError(c, operation, rel, err)
+		return
+	}
+	c.JSON(http.StatusOK, metadata)
+}
+
+// openServerFiles opens SFTP to a server and its working directory,
+// responding with the error when that fails. The caller closes the client.
+func (h *ServerHandler) openServerFiles(c *gin.Context) (*files.FS, *sftp.Client, bool) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return nil, nil, false
+	}
+	fsys, client, err := h.openServerFS(serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to open server directory", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return nil, nil, false
+	}
+	return fsys, client, true
+}
+
+// openServerFS opens SFTP to a server and its working directory. The caller
+// closes the client.
+func (h *ServerHandler) openServerFS(serverID string) (*files.FS, *sftp.Client, error) {
+	serverDef, conn, err := h.connectServer(serverID)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := conn.Client.NewSFTP()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to open SFTP: %v", err)
+	}
+	root, err := remoteHomePath(client, serverDef.Server.WorkingDirectory)
+	var fsys *files.FS
+	if err == nil {
+		fsys, err = files.New(client, root)
+	}
+	if err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("Failed to open the server directory: %v", err)
+	}
+	return fsys, client, nil
+}
+
+// respondFileError maps an error from package files to a response
+func (h *ServerHandler) respondFileError(c *gin.Context, operation, rel string, err error) {
+	switch {
+	case errors.Is(err, files.ErrNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, fmt.Sprintf("%s: %v", rel, err))
+	case errors.Is(err, files.ErrExists), errors.Is(err, files.ErrNotEmpty):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, fmt.Sprintf("%s: %v", rel, err))
+	case errors.Is(err, files.ErrTooLarge):
+		apierror.Respond(c, http.StatusRequestEntityTooLarge, apierror.CodeValidationFailed, err.Error())
+	case errors.Is(err, files.ErrNotText):
+		apierror.Respond(c, http.StatusUnsupportedMediaType, apierror.CodeValidationFailed, err.Error()+"; download it instead")
+	case errors.Is(err, files.ErrOutsideRoot), errors.Is(err, files.ErrRoot), errors.Is(err, files.ErrIsDir),
+		errors.Is(err, files.ErrNotDir), errors.Is(err, files.ErrBadMode), errors.Is(err, files.ErrBadArchive):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, fmt.Sprintf("%s: %v", rel, err))
+	case errors.Is(err, os.ErrPermission):
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, fmt.Sprintf("%s: permission denied on the host", rel))
+	default:
+		logger.ErrorContext(c.Request.Context(), "File operation failed", "server_id", c.Param("id"), "operation", operation, "path", rel, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to %s %s: %v", operation, rel, err))
+	}
+}
+
+func (h *ServerHandler) logFileChange(c *gin.Context, operation, description string, metadata gin.H, err error) {
+	activity := &logging.Activity{
+		ServerID:     c.Param("id"),
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivityFileChange,
+		Description:  description,
+		Metadata:     map[string]interface{}{"operation": operation},
+		Success:      err == nil,
+	}
+	for key, value := range metadata {
+		activity.Metadata[key] = value
+	}
+	if err != nil {
+		activity.ErrorMessage = err.Error()
+	}
+	_ = h.activityLogger.LogActivity(activity)
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/gamejobs"
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+)
+
+type gameJobRequest struct {
+	Name            string `json:"name"`
+	Command         string `json:"command" binding:"required"`
+	PauseCommand    string `json:"pause_command"`
+	ResumeCommand   string `json:"resume_command"`
+	ProgressPattern string `json:"progress_pattern"`
+	DonePattern     string `json:"done_pattern"`
+	FailPattern     string `json:"fail_pattern"`
+}
+
+// SetGameJobs lets the handler serve game jobs and has a server's running
+// jobs wait for it when it stops
+func (h *ServerHandler) SetGameJobs(manager *gamejobs.Manager) {
+	h.gameJobs = manager
+	h.statusRefresher.OnChange(func(serverID string, previous, current models.ServerConnectionStatus) {
+		// Only the leader runs jobs, and only an exit of the process stops them
+		if !h.leading() || previous != models.StatusRunning || current != models.StatusOnline {
+			return
+		}
+		go manager.ServerStopped(context.Background(), serverID)
+	})
+}
+
+// ListGameJobs returns a server's game jobs, newest first
+func (h *ServerHandler) ListGameJobs(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	jobs, err := h.gameJobs.List(c.Request.Context(), serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list game jobs", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load game jobs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetGameJob returns one of a server's game jobs
+func (h *ServerHandler) GetGameJob(c *gin.Context) {
+	job, ok := h.loadGameJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CreateGameJob defines a long-running in-game job, such as world pre-generation, on a server.
+// Its command is sent to the console as soon as the server runs, and its
+// progress is read from the console output.
+func (h *ServerHandler) CreateGameJob(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	var req gameJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	job := &gamejobs.Job{
+		ID:              uuid.New().String(),
+		ServerID:        serverID,
+		Name:            req.Name,
+		Command:         req.Command,
+		PauseCommand:    req.PauseCommand,
+		ResumeCommand:   req.ResumeCommand,
+		ProgressPattern: req.ProgressPattern,
+		DonePattern:     req.DonePattern,
+		FailPattern:     req.FailPattern,
+	}
+	job.Normalize()
+	if err := job.Validate(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	if err := h.gameJobs.Create(c.Request.Context(), job); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to create game job", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save game job")
+		return
+	}
+	c.JSON(http.StatusCreated, job)
+}
+
+// PauseGameJob pauses a game job with its pause command
+func (h *ServerHandler) PauseGameJob(c *gin.Context) {
+	h.changeGameJob(c, "pause", h.gameJobs.Pause)
+}
+
+// ResumeGameJob resumes a paused game job once its server runs
+func (h *ServerHandler) ResumeGameJob(c *gin.Context) {
+	h.changeGameJob(c, "resume", h.gameJobs.Resume)
+}
+
+// CancelGameJob ends a game job for good, sending its pause command if it is running
+func (h *ServerHandler) CancelGameJob(c *gin.Context) {
+	h.changeGameJob(c, "cancel", h.gameJobs.Cancel)
+}
+
+func (h *ServerHandler) changeGameJob(c *gin.Context, action string, change func(context.Context, string) (*gamejobs.Job, error)) {
+	job, ok := h.loadGameJob(c)
+	if !ok {
+		return
+	}
+	updated, err := change(c.Request.Context(), job.ID)
+	if errors.Is(err, gamejobs.ErrNotActive) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, fmt.Sprintf("Cannot %s a job that is %s", action, job.Status))
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to change game job", "job_id", job.ID, "action", action, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *ServerHandler) loadGameJob(c *gin.Context) (*gamejobs.Job, bool) {
+	job, err := h.gameJobs.Get(c.Request.Context(), c.Param("jobId"))
+	if errors.Is(err, gamejobs.ErrNotFound) || (err == nil && job.ServerID != c.Param("id")) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Game job not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load game job", "job_id", c.Param("jobId"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load game job")
+		return nil, false
+	}
+	return job, true
+}
+
+// ServerState reports whether a server's process is running, from the last
+// background status check
+func (h *ServerHandler) ServerState(serverID string) gamejobs.ServerState {
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		return gamejobs.ServerGone
+	}
+	snapshot, ok := h.statusRefresher.Get(serverID)
+	if !ok {
+		return gamejobs.ServerUnknown
+	}
+	switch snapshot.Health.ConnectionStatus {
+	case models.StatusRunning:
+		return gamejobs.ServerRunning
+	case models.StatusOnline:
+		return gamejobs.ServerStopped
+	default:
+		return gamejobs.ServerUnknown
+	}
+}
+
+// SendCommand sends a game job's command to a server's console
+func (h *ServerHandler) SendCommand(ctx context.Context, serverID, command string) error {
+	if _, _, err := h.connectServer(serverID); err != nil {
+		return err
+	}
+	if err := h.processManager.SendCommand(serverID, server.SafeSessionName(serverID), command); err != nil {
+		h.activityLogger.LogCommandExecute(serverID, nil, command, false, "", err.Error())
+		return err
+	}
+	h.activityLogger.LogCommandExecute(serverID, nil, command, true, "", "")
+	return nil
+}
+
+// ReadConsole returns up to limit bytes of a server's console log from
+// offset on, and the log's size
+func (h *ServerHandler) ReadConsole(ctx context.Context, serverID string, offset, limit int64) (string, int64, error) {
+	serverDef, conn, err := h.connectServer(serverID)
+	if err != nil {
+		return "", 0, err
+	}
+	serverConfig := h.createServerConfig(serverDef)
+
+	script := fmt.Sprintf("LOG_FILE=\"%s\"\nLOG_FILE=\"${LOG_FILE/#\\~/$HOME}\"\n"+
+		"SIZE=$(stat -c %%s \"$LOG_FILE\" 2>/dev/null || echo 0)\necho \"$SIZE\"\n"+
+		"if [ \"$SIZE\" -gt %d ] && [ %d -gt 0 ]; then tail -c +%d \"$LOG_FILE\" | head -c %d; fi",
+		escapeForScriptPath(serverConfig.LogFile), offset, limit, offset+1, limit)
+	output, err := conn.Client.RunCommandContext(ctx, bashDollarQuotedCommand(script))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read console log: %w", err)
+	}
+	sizeLine, data, _ := strings.Cut(output, "\n")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeLine), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected console log size %q", sizeLine)
+	}
+	return data, size, nil
+}
+
+// StartTask records a game job's run as a server task. A run only sends
+// console commands and follows the log for as long as the job lasts, so it is
+// not queued with the server's deploys and restarts.
+func (h *ServerHandler) StartTask(ctx context.Context, serverID, name string) string {
+	return h.startTask(ctx, serverID, name).ID
+}
+
+// TaskOutput adds a line to a game job's task
+func (h *ServerHandler) TaskOutput(serverID, taskID, name, line string) {
+	h.appendTaskStreamLine(serverID, taskID, name, line)
+}
+
+// FinishTask marks a game job's task as done, or failed when err is set
+func (h *ServerHandler) FinishTask(serverID, taskID string, err error) {
+	h.finishTask(serverID, taskID, err)
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/gitops"
+)
+
+// Outcomes of a GitOps sync
+const (
+	gitOpsSynced   = "synced"
+	gitOpsConflict = "conflict"
+	gitOpsFailed   = "failed"
+)
+
+// gitOpsHistory is the number of sync results kept
+const gitOpsHistory = 100
+
+// GitOpsHandler keeps server definitions and schedules in line with a Git
+// repository and reports how the syncs went. Changes made in the manager to
+// objects the repository defines are conflicts: a sync that would undo them
+// is held back until it is forced or the repository catches up.
+type GitOpsHandler struct {
+	repo  *gitops.Repo
+	apply *ApplyHandler
+	db    *sql.DB
+
+	// mu runs one sync at a time
+	mu sync.Mutex
+}
+
+// gitOpsSync is the result of one sync. A sync with the same outcome as the
+// one before only moves its finished_at forward.
+type gitOpsSync struct {
+	ID         int64         `json:"id"`
+	Commit     string        `json:"commit"`
+	Status     string        `json:"status"`
+	Changes    []applyChange `json:"changes"`
+	Conflicts  []applyChange `json:"conflicts"`
+	Error      string        `json:"error,omitempty"`
+	Forced     bool          `json:"forced"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+}
+
+// NewGitOpsHandler creates a new GitOps handler. repo is nil when GitOps
+// sync is turned off.
+func NewGitOpsHandler(repo *gitops.Repo, apply *ApplyHandler, db *sql.DB) *GitOpsHandler {
+	return &GitOpsHandler{repo: repo, apply: apply, db: db}
+}
+
+// Start syncs right away and then every interval until ctx is cancelled
+func (h *GitOpsHandler) Start(ctx context.Context, interval time.Duration) {
+	if h.repo == nil {
+		return
+	}
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			result := h.Sync(ctx, false)
+			if result.Status == gitOpsFailed && ctx.Err() == nil {
+				logger.Warn("GitOps sync failed", "commit", result.Commit, "error", result.Error)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Sync fetches the repository and applies what changed. Unless force is
+// set, changes to objects that were edited in the manager since the last
+// sync are reported as conflicts and nothing is applied.
+func (h *GitOpsHandler) Sync(ctx context.Context, force bool) gitOpsSync {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	result := gitOpsSync{
+		Changes:   []applyChange{},
+		Conflicts: []applyChange{},
+		Forced:    force,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := h.sync(ctx, force, &result); err != nil {
+		result.Status = gitOpsFailed
+		result.Error = err.Error()
+	}
+	result.FinishedAt = time.Now().UTC()
+	h.record(&result)
+	return result
+}
+
+func (h *GitOpsHandler) sync(ctx context.Context, force bool, result *gitOpsSync) error {
+	commit, err := h.repo.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	result.Commit = commit
+
+	plan, err := h.plan(ctx, commit)
+	if err != nil {
+		return err
+	}
+	pending := plan.pending()
+	if !force {
+		if conflicts := h.conflicts(ctx, pending); len(conflicts) > 0 {
+			result.Status = gitOpsConflict
+			result.Conflicts = conflicts
+			return nil
+		}
+	}
+
+	if len(pending) > 0 {
+		if err := h.apply.apply(ctx, plan); err != nil {
+			return err
+		}
+		logger.InfoContext(ctx, "Applied GitOps changes", "commit", commit, "changes", len(pending))
+	}
+	result.Status = gitOpsSynced
+	result.Changes = pending
+	return nil
+}
+
+// plan works out the changes that bring the manager in line with commit
+func (h *GitOpsHandler) plan(ctx context.Context, commit string) (*applyPlan, error) {
+	doc, err := h.repo.Load(ctx, commit)
+	if err != nil {
+		return nil, err
+	}
+
+	req := applyRequest{Prune: h.repo.Settings().Prune}
+	if doc.Servers != nil {
+		req.Servers = &doc.Servers
+	}
+	if doc.Schedules != nil {
+		var schedules []applySchedule
+		if err := json.Unmarshal(doc.Schedules, &schedules); err != nil {
+			return nil, applyInvalidError{kind: applyKindSchedule, err: err}
+		}
+		req.Schedules = &schedules
+	}
+	return h.apply.plan(ctx, req)
+}
+
+// conflicts returns the pending changes to objects that no longer match the
+// commit of the last sync, i.e. were edited in the manager since
+func (h *GitOpsHandler) conflicts(ctx context.Context, pending []applyChange) []applyChange {
+	if len(pending) == 0 {
+		return nil
+	}
+	last, err := h.lastSynced(ctx)
+	if err != nil || last == "" {
+		return nil
+	}
+	previous, err := h.plan(ctx, last)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to compare with the last synced commit", "commit", last, "error", err)
+		return nil
+	}
+
+	edited := make(map[string]bool)
+	for _, change := range previous.pending() {
+		edited[change.Kind+"/"+change.ID] = true
+	}
+	var conflicts []applyChange
+	for _, change := range pending {
+		if edited[change.Kind+"/"+change.ID] {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// lastSynced returns the commit of the last successful sync
+func (h *GitOpsHandler) lastSynced(ctx context.Context) (string, error) {
+	var commit string
+	err := h.db.QueryRowContext(ctx, `
+		SELECT commit_hash FROM gitops_syncs WHERE status = ? ORDER BY id DESC LIMIT 1
+	`, gitOpsSynced).Scan(&commit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return commit, err
+}
+
+// record stores a sync result, folding it into the previous one when
+// nothing new happened
+func (h *GitOpsHandler) record(result *gitOpsSync) {
+	changes, _ := json.Marshal(result.Changes)
+	conflicts, _ := json.Marshal(result.Conflicts)
+
+	if previous, err := h.history(context.Background(), 1); err == nil && len(previous) == 1 {
+		prev := previous[0]
+		prevConflicts, _ := json.Marshal(prev.Conflicts)
+		if prev.Commit == result.Commit && prev.Status == result.Status && prev.Error == result.Error &&
+			len(result.Changes) == 0 && string(prevConflicts) == string(conflicts) {
+			if _, err := h.db.Exec(`UPDATE gitops_syncs SET finished_at = ? WHERE id = ?`, result.FinishedAt, prev.ID); err != nil {
+				logger.Warn("Failed to record GitOps sync", "error", err)
+			}
+			result.ID = prev.ID
+			return
+		}
+	}
+
+	res, err := h.db.Exec(`
+		INSERT INTO gitops_syncs (commit_hash, status, changes, conflicts, error, forced, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, result.Commit, result.Status, string(changes), string(conflicts), result.Error, result.Forced, result.StartedAt, result.FinishedAt)
+	if err != nil {
+		logger.Warn("Failed to record GitOps sync", "error", err)
+		return
+	}
+	result.ID, _ = res.LastInsertId()
+
+	if _, err := h.db.Exec(`
+		DELETE FROM gitops_syncs WHERE id NOT IN (SELECT id FROM gitops_syncs ORDER BY id DESC LIMIT ?)
+	`, gitOpsHistory); err != nil {
+		logger.Warn("Failed to trim GitOps sync history", "error", err)
+	}
+}
+
+// history returns the latest sync results, newest first
+func (h *GitOpsHandler) history(ctx context.Context, limit int) ([]gitOpsSync, error) {
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT id, commit_hash, status, changes, conflicts, error, forced, started_at, finished_at
+		FROM gitops_syncs
+		ORDER BY id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	syncs := []gitOpsSync{}
+	for rows.Next() {
+		var (
+			entry              gitOpsSync
+			commit, syncErr    sql.NullString
+			changes, conflicts string
+		)
+		if err := rows.Scan(&entry.ID, &commit, &entry.Status, &changes, &conflicts, &syncErr, &entry.Forced, &entry.StartedAt, &entry.FinishedAt); err != nil {
+			return nil, err
+		}
+		entry.Commit = commit.String
+		entry.Error = syncErr.String
+		_ = json.Unmarshal([]byte(changes), &entry.Changes)
+		_ = json.Unmarshal([]byte(conflicts), &entry.Conflicts)
+		syncs = append(syncs, entry)
+	}
+	return syncs, rows.Err()
+}
+
+// GetStatus reports the GitOps settings and the latest sync results
+func (h *GitOpsHandler) GetStatus(c *gin.Context) {
+	if h.repo == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	syncs, err := h.history(c.Request.Context(), 20)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load GitOps syncs", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load sync status")
+		return
+	}
+	var last *gitOpsSync
+	if len(syncs) > 0 {
+		last = &syncs[0]
+	}
+
+	settings := h.repo.Settings()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    true,
+		"repository": settings.Repository,
+		"branch":     settings.Branch,
+		"path":       settings.Path,
+		"prune":      settings.Prune,
+		"last":       last,
+		"history":    syncs,
+	})
+}
+
+// SyncNow fetches the repository and applies it right away.
+// ?force=true also applies changes that conflict with edits made in the manager.
+func (h *GitOpsHandler) SyncNow(c *gin.Context) {
+	if h.repo == nil {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "GitOps sync is not enabled")
+		return
+	}
+
+	result := h.Sync(c.Request.Context(), c.Query("force") == "true")
+	if result.Status == gitOpsFailed {
+		apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "GitOps sync failed", result.Error)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
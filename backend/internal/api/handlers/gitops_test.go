@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/gitops"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
+)
+
+func commitServersYAML(t *testing.T, dir, name string) {
+	t.Helper()
+	content := strings.ReplaceAll(`schema_version: 1
+servers:
+  - id: web-1
+    name: NAME
+    connection:
+      host: 10.0.0.5
+      username: hytale
+      auth_method: key
+      key_path: /keys/web-1
+    server:
+      working_directory: /opt/hytale
+      executable: java
+      process_manager: screen
+`, "NAME", name)
+	if err := os.WriteFile(filepath.Join(dir, "servers.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "--quiet", "-m", "servers"}} {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+}
+
+func TestGitOpsHandler_HoldsBackConflictingChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	serverHandler, _, _, sm := setupTestServerHandler(t)
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "gitops.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	windowStore := maintenance.NewStore(db.DB)
+	applyHandler := NewApplyHandler(
+		serverHandler,
+		NewScheduleHandler(scheduler.NewStore(db.DB), nil, nil, sm),
+		NewMaintenanceWindowHandler(windowStore, maintenance.NewManager(windowStore, serverHandler), sm),
+	)
+
+	source := t.TempDir()
+	if output, err := exec.Command("git", "-C", source, "init", "--quiet", "-b", "main").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, output)
+	}
+	commitServersYAML(t, source, "Web 1")
+	repo := gitops.NewRepo(filepath.Join(t.TempDir(), "gitops"), config.GitOpsConfig{Repository: source})
+	handler := NewGitOpsHandler(repo, applyHandler, db.DB)
+	ctx := context.Background()
+
+	result := handler.Sync(ctx, false)
+	if result.Status != gitOpsSynced || len(result.Changes) != 1 || result.Changes[0].Action != applyCreate {
+		t.Fatalf("expected the server to be created, got %+v", result)
+	}
+	if result = handler.Sync(ctx, false); result.Status != gitOpsSynced || len(result.Changes) != 0 {
+		t.Fatalf("expected nothing to change, got %+v", result)
+	}
+
+	// The server is renamed in the manager while the repository renames it too
+	edited, _ := sm.GetByID("web-1")
+	edited.Name = "Edited in the manager"
+	if _, err := sm.UpdateVersioned(edited); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	commitServersYAML(t, source, "Web One")
+
+	result = handler.Sync(ctx, false)
+	if result.Status != gitOpsConflict || len(result.Conflicts) != 1 || result.Conflicts[0].ID != "web-1" {
+		t.Fatalf("expected a conflict on web-1, got %+v", result)
+	}
+	if current, _ := sm.GetByID("web-1"); current.Name != "Edited in the manager" {
+		t.Fatalf("expected the conflicting change to be held back, got name %q", current.Name)
+	}
+
+	if result = handler.Sync(ctx, true); result.Status != gitOpsSynced || len(result.Changes) != 1 {
+		t.Fatalf("expected a forced sync to apply the change, got %+v", result)
+	}
+	if current, _ := sm.GetByID("web-1"); current.Name != "Web One" {
+		t.Fatalf("expected the repository's name, got %q", current.Name)
+	}
+
+	syncs, err := handler.history(ctx, 10)
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(syncs) != 3 {
+		t.Fatalf("expected the unchanged sync to be folded into the first, got %d results", len(syncs))
+	}
+}
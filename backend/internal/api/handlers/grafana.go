@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// GrafanaHandler serves server metrics to Grafana's JSON datasource (and the
+// older SimpleJSON one), so dashboards read them without access to the
+// database
+type GrafanaHandler struct {
+	db            *sql.DB
+	cfg           *config.Config
+	serverManager *config.ServerManager
+}
+
+// NewGrafanaHandler creates a new Grafana datasource handler
+func NewGrafanaHandler(db *sql.DB, cfg *config.Config, serverManager *config.ServerManager) *GrafanaHandler {
+	return &GrafanaHandler{db: db, cfg: cfg, serverManager: serverManager}
+}
+
+type grafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMs    int64 `json:"intervalMs"`
+	MaxDataPoints int64 `json:"maxDataPoints"`
+	Targets       []struct {
+		RefID   string                 `json:"refId"`
+		Target  string                 `json:"target"`
+		Hide    bool                   `json:"hide"`
+		Payload map[string]interface{} `json:"payload"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is a time series as Grafana reads it: datapoints are
+// [value, unix milliseconds] pairs
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// TestDatasource answers the datasource test Grafana runs when it is saved
+func (h *GrafanaHandler) TestDatasource(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Search lists the metrics a query can target, for SimpleJSON
+func (h *GrafanaHandler) Search(c *gin.Context) {
+	c.JSON(http.StatusOK, metrics.SeriesMetrics())
+}
+
+// Metrics lists the metrics a query can target, with a server selector,
+// for the JSON datasource's query editor
+func (h *GrafanaHandler) Metrics(c *gin.Context) {
+	options := make([]gin.H, 0)
+	for _, server := range h.serverManager.GetAll() {
+		options = append(options, gin.H{"label": server.Name, "value": server.ID})
+	}
+	list := make([]gin.H, 0)
+	for _, name := range metrics.SeriesMetrics() {
+		list = append(list, gin.H{
+			"label": name,
+			"value": name,
+			"payloads": []gin.H{{
+				"label":       "Servers",
+				"name":        "server_id",
+				"type":        "multi-select",
+				"placeholder": "All servers",
+				"options":     options,
+			}},
+		})
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Query returns one series per server for each target. The resolution
+// follows the range: raw samples for up to two days, hourly rollups for up
+// to a month and daily rollups beyond, averaged (or maxed, for _max
+// metrics) into buckets of the panel's interval.
+func (h *GrafanaHandler) Query(c *gin.Context) {
+	var req grafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	from, to := req.Range.From.UTC(), req.Range.To.UTC()
+	if from.IsZero() || !to.After(from) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "range.from must be before range.to")
+		return
+	}
+
+	step := time.Duration(req.IntervalMs) * time.Millisecond
+	if req.MaxDataPoints > 0 {
+		if perPoint := to.Sub(from) / time.Duration(req.MaxDataPoints); perPoint > step {
+			step = perPoint
+		}
+	}
+	resolution := metrics.ChooseResolution(from, to, time.Now().UTC(), h.cfg.Metrics)
+
+	result := make([]grafanaSeries, 0)
+	for _, target := range req.Targets {
+		if target.Hide || target.Target == "" {
+			continue
+		}
+		query := metrics.SeriesQuery{
+			Metric:    target.Target,
+			ServerIDs: payloadStrings(target.Payload["server_id"]),
+			From:      from,
+			To:        to,
+			Step:      step,
+		}
+		series, err := metrics.QuerySeries(c.Request.Context(), h.db, query, resolution)
+		if errors.Is(err, metrics.ErrUnknownMetric) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to query metrics for Grafana", "metric", target.Target, "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load metrics")
+			return
+		}
+		for _, s := range series {
+			points := make([][2]float64, 0, len(s.Points))
+			for _, point := range s.Points {
+				points = append(points, [2]float64{point.Value, float64(point.Time.UnixMilli())})
+			}
+			result = append(result, grafanaSeries{Target: s.ServerID + "." + s.Metric, RefID: target.RefID, Datapoints: points})
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// payloadStrings reads a payload value the editor sent as one string or a
+// list of them
+func payloadStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/notify"
+	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultHostSecurityInterval applies when host_security.interval is unset
+	defaultHostSecurityInterval = 15 * time.Minute
+	// defaultHostSecurityWindow applies when host_security.window is unset
+	defaultHostSecurityWindow = time.Hour
+	// hostSecurityConcurrency bounds how many hosts a sweep checks at once
+	hostSecurityConcurrency = 4
+	// hostSecurityCheckTimeout bounds the SSH work for one host
+	hostSecurityCheckTimeout = time.Minute
+	// hostSecurityTopSources is how many attacking addresses a report lists
+	hostSecurityTopSources = 10
+)
+
+// Fail2banJail is the state of one fail2ban jail on a host
+type Fail2banJail struct {
+	Name            string   `json:"name"`
+	CurrentlyFailed int      `json:"currently_failed"`
+	TotalFailed     int      `json:"total_failed"`
+	CurrentlyBanned int      `json:"currently_banned"`
+	TotalBanned     int      `json:"total_banned"`
+	BannedIPs       []string `json:"banned_ips"`
+}
+
+// LoginSource counts the failed SSH logins from one address
+type LoginSource struct {
+	IP       string `json:"ip"`
+	Attempts int    `json:"attempts"`
+}
+
+// HostSecurityReport is the result of the last security check of a host,
+// shared by every server on it
+type HostSecurityReport struct {
+	Host          string         `json:"host"`
+	ServerIDs     []string       `json:"server_ids"`
+	CheckedAt     time.Time      `json:"checked_at"`
+	Error         string         `json:"error,omitempty"`
+	Fail2ban      bool           `json:"fail2ban"`
+	Fail2banError string         `json:"fail2ban_error,omitempty"`
+	Jails         []Fail2banJail `json:"jails"`
+	BannedIPs     int            `json:"banned_ips"`
+	// AuthSource is where failed logins were read from: journal, a log file,
+	// or empty when neither could be read
+	AuthSource   string        `json:"auth_source"`
+	Window       string        `json:"window"`
+	FailedLogins int           `json:"failed_logins"`
+	TopSources   []LoginSource `json:"top_sources"`
+	// Spike is set while failed logins within the window reach the threshold
+	Spike bool `json:"spike"`
+}
+
+// HostSecurityMonitor periodically checks every host's fail2ban jails and
+// failed SSH logins and keeps the latest report per host
+type HostSecurityMonitor struct {
+	hosts    func() map[string][]string
+	check    func(ctx context.Context, host string, serverIDs []string) HostSecurityReport
+	interval atomic.Int64
+
+	mu      sync.RWMutex
+	reports map[string]HostSecurityReport
+	onSpike []func(HostSecurityReport)
+}
+
+// NewHostSecurityMonitor creates a monitor. hosts maps each host to the
+// servers on it and check inspects one host.
+func NewHostSecurityMonitor(hosts func() map[string][]string, check func(ctx context.Context, host string, serverIDs []string) HostSecurityReport, interval time.Duration) *HostSecurityMonitor {
+	m := &HostSecurityMonitor{
+		hosts:   hosts,
+		check:   check,
+		reports: make(map[string]HostSecurityReport),
+	}
+	m.SetInterval(interval)
+	return m
+}
+
+// SetInterval changes how often every host is re-checked. It takes effect
+// after the current wait.
+func (m *HostSecurityMonitor) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultHostSecurityInterval
+	}
+	m.interval.Store(int64(interval))
+}
+
+// OnSpike registers fn to be called when a host's failed logins reach the
+// threshold, once until they drop below it again
+func (m *HostSecurityMonitor) OnSpike(fn func(HostSecurityReport)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onSpike = append(m.onSpike, fn)
+}
+
+// Run checks every host immediately and then on each interval until ctx is done
+func (m *HostSecurityMonitor) Run(ctx context.Context) {
+	m.checkAll(ctx)
+	timer := time.NewTimer(time.Duration(m.interval.Load()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			m.checkAll(ctx)
+			timer.Reset(time.Duration(m.interval.Load()))
+		}
+	}
+}
+
+// Get returns the last report for a host
+func (m *HostSecurityMonitor) Get(host string) (HostSecurityReport, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	report, ok := m.reports[host]
+	return report, ok
+}
+
+// All returns the last report of every host, sorted by host
+func (m *HostSecurityMonitor) All() []HostSecurityReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	reports := make([]HostSecurityReport, 0, len(m.reports))
+	for _, report := range m.reports {
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Host < reports[j].Host })
+	return reports
+}
+
+// CheckNow inspects a host in the calling goroutine and stores the report.
+// It returns false if no server uses the host any more.
+func (m *HostSecurityMonitor) CheckNow(ctx context.Context, host string) (HostSecurityReport, bool) {
+	serverIDs, ok := m.hosts()[host]
+	if !ok {
+		m.mu.Lock()
+		delete(m.reports, host)
+		m.mu.Unlock()
+		return HostSecurityReport{}, false
+	}
+	report := m.check(ctx, host, serverIDs)
+
+	m.mu.Lock()
+	previous, existed := m.reports[host]
+	// A failed check keeps the spike state of the last one
+	if report.Error != "" && existed {
+		report.Spike = previous.Spike
+	}
+	m.reports[host] = report
+	listeners := append([]func(HostSecurityReport){}, m.onSpike...)
+	m.mu.Unlock()
+
+	if report.Spike && (!existed || !previous.Spike) {
+		logger.Warn("Failed SSH login spike", "host", host, "failed_logins", report.FailedLogins, "window", report.Window)
+		for _, fn := range listeners {
+			fn(report)
+		}
+	} else if !report.Spike && existed && previous.Spike {
+		logger.Info("Failed SSH login spike over", "host", host, "failed_logins", report.FailedLogins)
+	}
+	return report, true
+}
+
+func (m *HostSecurityMonitor) checkAll(ctx context.Context) {
+	hosts := m.hosts()
+	m.mu.Lock()
+	for host := range m.reports {
+		if _, ok := hosts[host]; !ok {
+			delete(m.reports, host)
+		}
+	}
+	m.mu.Unlock()
+
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+	fanOut(ctx, names, hostSecurityConcurrency, hostSecurityCheckTimeout, func(ctx context.Context, host string) {
+		m.CheckNow(ctx, host)
+	})
+}
+
+// StartHostSecurityMonitor begins checking every host in the background. It
+// stops when ctx is cancelled or the handler shuts down.
+func (h *ServerHandler) StartHostSecurityMonitor(ctx context.Context, interval time.Duration) {
+	h.hostSecurity.SetInterval(interval)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(h.tasksCtx, cancel)
+	go func() {
+		defer cancel()
+		defer stop()
+		h.hostSecurity.Run(ctx)
+	}()
+}
+
+// SetSecurityAlerts mails failed-login spikes to host_security.alert_emails
+// through mailer, besides the activity log and websocket message every spike
+// gets
+func (h *ServerHandler) SetSecurityAlerts(mailer *notify.SMTPSender) {
+	h.hostSecurity.OnSpike(func(report HostSecurityReport) {
+		to := h.config.HostSecurity.AlertEmails
+		if len(to) == 0 || !mailer.Enabled() {
+			return
+		}
+		subject, body := securityAlertMessage(report)
+		if err := mailer.Send(to, subject, body); err != nil {
+			logger.Error("Failed to send security alert email", "host", report.Host, "error", err)
+		}
+	})
+}
+
+// ListHostSecurity returns the last security report of every host.
+// ?refresh=true checks every host again first.
+func (h *ServerHandler) ListHostSecurity(c *gin.Context) {
+	if c.Query("refresh") == "true" {
+		h.hostSecurity.checkAll(c.Request.Context())
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": h.hostSecurity.All()})
+}
+
+// GetServerSecurity reports the fail2ban bans and failed SSH logins of a server's host
+// GET /api/v1/servers/:id/security
+// Returns the last background result; ?refresh=true checks the host again first.
+func (h *ServerHandler) GetServerSecurity(c *gin.Context) {
+	serverDef, found := h.serverManager.GetByID(c.Param("id"))
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	host := serverDef.Connection.Host
+
+	report, ok := h.hostSecurity.Get(host)
+	if !ok || c.Query("refresh") == "true" {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), hostSecurityCheckTimeout)
+		defer cancel()
+		if report, ok = h.hostSecurity.CheckNow(ctx, host); !ok {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+			return
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// serverHosts maps every host to the servers on it
+func (h *ServerHandler) serverHosts() map[string][]string {
+	hosts := make(map[string][]string)
+	for _, serverDef := range h.serverManager.GetAll() {
+		host := serverDef.Connection.Host
+		hosts[host] = append(hosts[host], serverDef.ID)
+	}
+	for _, ids := range hosts {
+		sort.Strings(ids)
+	}
+	return hosts
+}
+
+// checkHostSecurity inspects one host over the connection of its first
+// server. Connection failures are reported in the result.
+func (h *ServerHandler) checkHostSecurity(ctx context.Context, host string, serverIDs []string) HostSecurityReport {
+	window := defaultHostSecurityWindow
+	if parsed, err := time.ParseDuration(h.config.HostSecurity.Window); err == nil && parsed > 0 {
+		window = parsed
+	}
+	report := HostSecurityReport{
+		Host:       host,
+		ServerIDs:  serverIDs,
+		CheckedAt:  time.Now(),
+		Jails:      []Fail2banJail{},
+		Window:     window.String(),
+		TopSources: []LoginSource{},
+	}
+
+	_, conn, err := h.connectServer(serverIDs[0])
+	if err != nil {
+		report.Error = "Failed to connect via SSH: " + err.Error()
+		return report
+	}
+	script := strings.ReplaceAll(HostSecurityCheckScript, "{{WINDOW_MINUTES}}", strconv.Itoa(int(window.Minutes())))
+	output, err := conn.Client.RunCommandContext(ctx, bashDollarQuotedCommand(script))
+	if err != nil {
+		report.Error = "Security check failed: " + err.Error()
+		return report
+	}
+
+	parseHostSecurityOutput(&report, output)
+	threshold := h.config.HostSecurity.SpikeThreshold
+	report.Spike = threshold > 0 && report.FailedLogins >= threshold
+	return report
+}
+
+// reportSecuritySpike records a failed-login spike on every server of the
+// host and tells clients watching them
+func (h *ServerHandler) reportSecuritySpike(report HostSecurityReport) {
+	metadata := map[string]interface{}{
+		"host":          report.Host,
+		"failed_logins": report.FailedLogins,
+		"window":        report.Window,
+		"banned_ips":    report.BannedIPs,
+		"top_sources":   report.TopSources,
+	}
+	for _, serverID := range report.ServerIDs {
+		h.activityLogger.LogSecurityAlert(serverID, metadata)
+		h.hub.Publish(fmt.Sprintf("server-tasks:%s", serverID), &ws.Message{
+			Type:      "host_security_alert",
+			Payload:   report,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// Patterns for the sshd lines of failed logins. The process ID ties an
+// "Invalid user" line to the failed attempts of the same connection.
+var (
+	sshdPID       = regexp.MustCompile(`sshd(?:-session)?\[(\d+)\]`)
+	failedLoginRe = regexp.MustCompile(`Failed \S+ for (?:invalid user )?\S* ?from (\S+)`)
+	invalidUserRe = regexp.MustCompile(`Invalid user \S* ?from (\S+)`)
+)
+
+// parseHostSecurityOutput fills a report from the output of
+// host_security_check.sh: key=value lines, then after "---" the sshd lines of
+// failed logins within the window
+func parseHostSecurityOutput(report *HostSecurityReport, output string) {
+	header, logins, _ := strings.Cut(output, "\n---\n")
+	for _, line := range strings.Split(header, "\n") {
+		key, val, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "FAIL2BAN":
+			report.Fail2ban = val == "1"
+		case "FAIL2BAN_ERROR":
+			report.Fail2banError = val
+		case "JAIL":
+			jail := parseJail(val)
+			report.Jails = append(report.Jails, jail)
+			report.BannedIPs += jail.CurrentlyBanned
+		case "AUTH_SOURCE":
+			report.AuthSource = val
+		}
+	}
+
+	// A password attempt on an unknown user logs "Invalid user" and then
+	// "Failed password for invalid user"; count those sessions by their
+	// failed lines
+	lines := strings.Split(logins, "\n")
+	failedPIDs := make(map[string]bool)
+	for _, line := range lines {
+		if match := sshdPID.FindStringSubmatch(line); match != nil && failedLoginRe.MatchString(line) {
+			failedPIDs[match[1]] = true
+		}
+	}
+	attempts := make(map[string]int)
+	for _, line := range lines {
+		var ip string
+		if match := failedLoginRe.FindStringSubmatch(line); match != nil {
+			ip = match[1]
+		} else if match := invalidUserRe.FindStringSubmatch(line); match != nil {
+			if pid := sshdPID.FindStringSubmatch(line); pid != nil && failedPIDs[pid[1]] {
+				continue
+			}
+			ip = match[1]
+		} else {
+			continue
+		}
+		report.FailedLogins++
+		attempts[ip]++
+	}
+
+	for ip, count := range attempts {
+		report.TopSources = append(report.TopSources, LoginSource{IP: ip, Attempts: count})
+	}
+	sort.Slice(report.TopSources, func(i, j int) bool {
+		a, b := report.TopSources[i], report.TopSources[j]
+		if a.Attempts != b.Attempts {
+			return a.Attempts > b.Attempts
+		}
+		return a.IP < b.IP
+	})
+	if len(report.TopSources) > hostSecurityTopSources {
+		report.TopSources = report.TopSources[:hostSecurityTopSources]
+	}
+}
+
+// parseJail reads "name|currently failed|total failed|currently banned|total banned|banned IPs"
+func parseJail(value string) Fail2banJail {
+	fields := strings.Split(value, "|")
+	for len(fields) < 6 {
+		fields = append(fields, "")
+	}
+	number := func(s string) int {
+		n, _ := strconv.Atoi(strings.TrimSpace(s))
+		return n
+	}
+	return Fail2banJail{
+		Name:            fields[0],
+		CurrentlyFailed: number(fields[1]),
+		TotalFailed:     number(fields[2]),
+		CurrentlyBanned: number(fields[3]),
+		TotalBanned:     number(fields[4]),
+		BannedIPs:       append([]string{}, strings.Fields(fields[5])...),
+	}
+}
+
+func securityAlertMessage(report HostSecurityReport) (string, string) {
+	subject := fmt.Sprintf("Hytale Server Manager: %d failed SSH logins on %s", report.FailedLogins, report.Host)
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d failed SSH logins were logged on %s within the last %s (checked %s).\n",
+		report.FailedLogins, report.Host, report.Window, report.CheckedAt.Format(time.RFC3339))
+	fmt.Fprintf(&body, "Servers on this host: %s\n", strings.Join(report.ServerIDs, ", "))
+	if report.Fail2ban {
+		fmt.Fprintf(&body, "fail2ban currently bans %d addresses.\n", report.BannedIPs)
+	} else {
+		body.WriteString("fail2ban is not installed on this host.\n")
+	}
+	if len(report.TopSources) > 0 {
+		body.WriteString("\nTop sources:\n\n")
+		for _, source := range report.TopSources {
+			fmt.Fprintf(&body, "%s\t%d\n", source.IP, source.Attempts)
+		}
+	}
+	return subject, body.String()
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseHostSecurityOutput(t *testing.T) {
+	report := HostSecurityReport{Jails: []Fail2banJail{}, TopSources: []LoginSource{}}
+	parseHostSecurityOutput(&report, `FAIL2BAN=1
+JAIL=sshd|2|310|3|41|203.0.113.5 203.0.113.9 198.51.100.7
+JAIL=recidive|0|4|1|1|203.0.113.5
+AUTH_SOURCE=journal
+---
+Mar 10 12:01:02 host sshd[100]: Invalid user admin from 203.0.113.5 port 4022
+Mar 10 12:01:04 host sshd[100]: Failed password for invalid user admin from 203.0.113.5 port 4022 ssh2
+Mar 10 12:01:09 host sshd[100]: Failed password for invalid user admin from 203.0.113.5 port 4022 ssh2
+Mar 10 12:02:00 host sshd-session[101]: Invalid user  from 198.51.100.7 port 51000
+Mar 10 12:03:00 host sshd[102]: Failed publickey for root from 2001:db8::1 port 22 ssh2
+`)
+
+	if !report.Fail2ban || len(report.Jails) != 2 || report.BannedIPs != 4 {
+		t.Fatalf("unexpected fail2ban state %+v", report)
+	}
+	if jail := report.Jails[0]; jail.Name != "sshd" || jail.TotalFailed != 310 || len(jail.BannedIPs) != 3 {
+		t.Fatalf("unexpected sshd jail %+v", jail)
+	}
+	if report.AuthSource != "journal" {
+		t.Fatalf("expected the journal as source, got %q", report.AuthSource)
+	}
+	// Two failed passwords, one key-only invalid user and one failed key
+	if report.FailedLogins != 4 {
+		t.Fatalf("expected 4 failed logins, got %d", report.FailedLogins)
+	}
+	if top := report.TopSources[0]; top.IP != "203.0.113.5" || top.Attempts != 2 || len(report.TopSources) != 3 {
+		t.Fatalf("unexpected top sources %+v", report.TopSources)
+	}
+}
+
+func TestHostSecuritySpikeAlertsOnce(t *testing.T) {
+	failed := 0
+	monitor := NewHostSecurityMonitor(
+		func() map[string][]string { return map[string][]string{"10.0.0.1": {"alpha", "beta"}} },
+		func(ctx context.Context, host string, serverIDs []string) HostSecurityReport {
+			return HostSecurityReport{Host: host, ServerIDs: serverIDs, FailedLogins: failed, Spike: failed >= 100}
+		},
+		0,
+	)
+	alerts := 0
+	monitor.OnSpike(func(HostSecurityReport) { alerts++ })
+
+	ctx := context.Background()
+	for _, count := range []int{5, 150, 400, 20, 120} {
+		failed = count
+		monitor.checkAll(ctx)
+	}
+	if alerts != 2 {
+		t.Fatalf("expected an alert per spike, got %d", alerts)
+	}
+	if _, ok := monitor.CheckNow(ctx, "10.0.0.2"); ok {
+		t.Fatal("expected an unknown host to be reported as gone")
+	}
+	if reports := monitor.All(); len(reports) != 1 || len(reports[0].ServerIDs) != 2 {
+		t.Fatalf("unexpected reports %+v", reports)
+	}
+}
@@ -2,14 +2,18 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
 )
 
 // IAMHandler handles roles and permissions management
@@ -31,14 +35,14 @@ type roleResponse struct {
 
 // ListPermissions returns all permissions
 func (h *IAMHandler) ListPermissions(c *gin.Context) {
-	rows, err := h.db.Query(`
+	rows, err := h.db.QueryContext(c.Request.Context(), `
 		SELECT id, name, description, category
 		FROM permissions
 		ORDER BY category, name
 	`)
 	if err != nil {
-		log.Printf("[IAM] list permissions query failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list permissions"})
+		logger.ErrorContext(c.Request.Context(), "list permissions query failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list permissions")
 		return
 	}
 	defer rows.Close()
@@ -50,8 +54,8 @@ func (h *IAMHandler) ListPermissions(c *gin.Context) {
 		var description sql.NullString
 		var category sql.NullString
 		if err := rows.Scan(&id, &name, &description, &category); err != nil {
-			log.Printf("[IAM] scan permission failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan permission"})
+			logger.ErrorContext(c.Request.Context(), "scan permission failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan permission")
 			return
 		}
 		permissions = append(permissions, gin.H{
@@ -63,8 +67,8 @@ func (h *IAMHandler) ListPermissions(c *gin.Context) {
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("[IAM] list permissions rows error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list permissions"})
+		logger.ErrorContext(c.Request.Context(), "list permissions rows error", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list permissions")
 		return
 	}
 
@@ -73,7 +77,7 @@ func (h *IAMHandler) ListPermissions(c *gin.Context) {
 
 // ListRoles returns all roles with permissions
 func (h *IAMHandler) ListRoles(c *gin.Context) {
-	rows, err := h.db.Query(`
+	rows, err := h.db.QueryContext(c.Request.Context(), `
 		SELECT r.id, r.name, r.description, p.name
 		FROM roles r
 		LEFT JOIN role_permissions rp ON rp.role_id = r.id
@@ -81,8 +85,8 @@ func (h *IAMHandler) ListRoles(c *gin.Context) {
 		ORDER BY r.name, p.name
 	`)
 	if err != nil {
-		log.Printf("[IAM] list roles query failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+		logger.ErrorContext(c.Request.Context(), "list roles query failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list roles")
 		return
 	}
 	defer rows.Close()
@@ -94,8 +98,8 @@ func (h *IAMHandler) ListRoles(c *gin.Context) {
 		var roleDescription sql.NullString
 		var permissionName sql.NullString
 		if err := rows.Scan(&roleID, &roleName, &roleDescription, &permissionName); err != nil {
-			log.Printf("[IAM] scan role failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan role"})
+			logger.ErrorContext(c.Request.Context(), "scan role failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan role")
 			return
 		}
 		role, exists := roles[roleID]
@@ -109,8 +113,8 @@ func (h *IAMHandler) ListRoles(c *gin.Context) {
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("[IAM] list roles rows error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list roles"})
+		logger.ErrorContext(c.Request.Context(), "list roles rows error", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list roles")
 		return
 	}
 
@@ -125,7 +129,7 @@ func (h *IAMHandler) ListRoles(c *gin.Context) {
 // GetRole returns a specific role with permissions
 func (h *IAMHandler) GetRole(c *gin.Context) {
 	roleID := c.Param("id")
-	rows, err := h.db.Query(`
+	rows, err := h.db.QueryContext(c.Request.Context(), `
 		SELECT r.id, r.name, r.description, p.name
 		FROM roles r
 		LEFT JOIN role_permissions rp ON rp.role_id = r.id
@@ -134,8 +138,8 @@ func (h *IAMHandler) GetRole(c *gin.Context) {
 		ORDER BY p.name
 	`, roleID)
 	if err != nil {
-		log.Printf("[IAM] get role query failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get role"})
+		logger.ErrorContext(c.Request.Context(), "get role query failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get role")
 		return
 	}
 	defer rows.Close()
@@ -147,8 +151,8 @@ func (h *IAMHandler) GetRole(c *gin.Context) {
 		var description sql.NullString
 		var permissionName sql.NullString
 		if err := rows.Scan(&id, &name, &description, &permissionName); err != nil {
-			log.Printf("[IAM] scan role detail failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan role"})
+			logger.ErrorContext(c.Request.Context(), "scan role detail failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan role")
 			return
 		}
 		if role == nil {
@@ -160,12 +164,12 @@ func (h *IAMHandler) GetRole(c *gin.Context) {
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("[IAM] get role rows error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get role"})
+		logger.ErrorContext(c.Request.Context(), "get role rows error", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get role")
 		return
 	}
 	if role == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Role not found")
 		return
 	}
 
@@ -180,42 +184,42 @@ func (h *IAMHandler) CreateRole(c *gin.Context) {
 		Permissions []string `json:"permission_names"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin transaction"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to begin transaction")
 		return
 	}
 	defer tx.Rollback()
 
 	result, err := tx.Exec("INSERT INTO roles (name, description) VALUES (?, ?)", req.Name, req.Description)
 	if err != nil {
-		log.Printf("[IAM] create role failed: %v", err)
-		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+		logger.ErrorContext(c.Request.Context(), "create role failed", "error", err)
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Role already exists")
 		return
 	}
 
 	roleID, err := result.LastInsertId()
 	if err != nil {
-		log.Printf("[IAM] create role id failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		logger.ErrorContext(c.Request.Context(), "create role id failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create role")
 		return
 	}
 
 	if len(req.Permissions) > 0 {
 		if err := assignRolePermissions(tx, roleID, req.Permissions); err != nil {
-			log.Printf("[IAM] assign role permissions failed: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			logger.ErrorContext(c.Request.Context(), "assign role permissions failed", "error", err)
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 			return
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("[IAM] create role commit failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		logger.ErrorContext(c.Request.Context(), "create role commit failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to commit transaction")
 		return
 	}
 
@@ -230,21 +234,21 @@ func (h *IAMHandler) UpdateRole(c *gin.Context) {
 		Description *string `json:"description"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	query := "UPDATE roles SET name = COALESCE(?, name), description = COALESCE(?, description) WHERE id = ?"
-	result, err := h.db.Exec(query, req.Name, req.Description, roleID)
+	result, err := h.db.ExecContext(c.Request.Context(), query, req.Name, req.Description, roleID)
 	if err != nil {
-		log.Printf("[IAM] update role failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		logger.ErrorContext(c.Request.Context(), "update role failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update role")
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Role not found")
 		return
 	}
 
@@ -254,15 +258,15 @@ func (h *IAMHandler) UpdateRole(c *gin.Context) {
 // DeleteRole deletes a role
 func (h *IAMHandler) DeleteRole(c *gin.Context) {
 	roleID := c.Param("id")
-	result, err := h.db.Exec("DELETE FROM roles WHERE id = ?", roleID)
+	result, err := h.db.ExecContext(c.Request.Context(), "DELETE FROM roles WHERE id = ?", roleID)
 	if err != nil {
-		log.Printf("[IAM] delete role failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		logger.ErrorContext(c.Request.Context(), "delete role failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete role")
 		return
 	}
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Role not found")
 		return
 	}
 
@@ -274,116 +278,256 @@ func (h *IAMHandler) SetRolePermissions(c *gin.Context) {
 	roleID := c.Param("id")
 	roleIDInt, err := strconv.ParseInt(roleID, 10, 64)
 	if err != nil {
-		log.Printf("[IAM] invalid role id: %s", roleID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role id"})
+		logger.WarnContext(c.Request.Context(), "invalid role id", "role_id", roleID)
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid role id")
 		return
 	}
 	var req struct {
 		Permissions []string `json:"permission_names" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin transaction"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to begin transaction")
 		return
 	}
 	defer tx.Rollback()
 
 	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleIDInt); err != nil {
-		log.Printf("[IAM] clear role permissions failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear role permissions"})
+		logger.ErrorContext(c.Request.Context(), "clear role permissions failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to clear role permissions")
 		return
 	}
 
 	if err := assignRolePermissions(tx, roleIDInt, req.Permissions); err != nil {
-		log.Printf("[IAM] set role permissions failed: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.ErrorContext(c.Request.Context(), "set role permissions failed", "error", err)
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	if err := tx.Commit(); err != nil {
-		log.Printf("[IAM] set role permissions commit failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		logger.ErrorContext(c.Request.Context(), "set role permissions commit failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to commit transaction")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Role permissions updated successfully"})
 }
 
-// ListAuditLogs returns audit log entries
+// ListAuditLogs returns audit log entries, newest first; ?format=csv exports
+// them as CSV. They can be filtered by ?user_id=, ?action= (part of the
+// method and route, such as "DELETE" or "/backups"), ?resource_type=,
+// ?resource_id=, ?ip_address=, ?success= and a ?from= and ?to= time range.
 func (h *IAMHandler) ListAuditLogs(c *gin.Context) {
-	limit := 100
-	offset := 0
-	if value := c.Query("limit"); value != "" {
-		if parsed, err := strconv.Atoi(value); err == nil {
-			if parsed > 0 && parsed <= 500 {
-				limit = parsed
-			}
-		}
+	page, ok := parsePage(c, listingPages(c, auditLogPages))
+	if !ok {
+		return
 	}
-	if value := c.Query("offset"); value != "" {
-		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+	where, args, err := auditLogFilter(c)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	var total int
+	if err := h.db.QueryRowContext(c.Request.Context(), "SELECT COUNT(*) FROM audit_logs WHERE "+where, args...).Scan(&total); err != nil {
+		logger.ErrorContext(c.Request.Context(), "count audit logs failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list audit logs")
+		return
 	}
 
-	rows, err := h.db.Query(`
-		SELECT id, user_id, action, resource_type, resource_id, ip_address, user_agent, success, details, created_at
+	query := `
+		SELECT ` + auditLogColumns + `
 		FROM audit_logs
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?
-	`, limit, offset)
+		WHERE ` + where
+	if page.After != "" {
+		afterID, err := strconv.ParseInt(page.After, 10, 64)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCursor, "Invalid cursor")
+			return
+		}
+		query += " AND id < ?"
+		args = append(args, afterID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, page.QueryLimit())
+
+	// offset is still accepted from clients written before cursors existed
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 && page.After == "" {
+		query += " OFFSET ?"
+		args = append(args, offset)
+	}
+
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
 	if err != nil {
-		log.Printf("[IAM] list audit logs query failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit logs"})
+		logger.ErrorContext(c.Request.Context(), "list audit logs query failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list audit logs")
 		return
 	}
 	defer rows.Close()
 
-	var logs []gin.H
+	logs := []gin.H{}
 	for rows.Next() {
-		var id int64
-		var userID sql.NullInt64
-		var action, resourceType, resourceID, ipAddress, userAgent, details string
-		var success bool
-		var createdAt time.Time
-		if err := rows.Scan(&id, &userID, &action, &resourceType, &resourceID, &ipAddress, &userAgent, &success, &details, &createdAt); err != nil {
-			log.Printf("[IAM] scan audit log failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan audit log"})
+		entry, err := scanAuditLog(rows)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "scan audit log failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan audit log")
 			return
 		}
+		logs = append(logs, entry)
+	}
 
-		var uid *int64
-		if userID.Valid {
-			value := userID.Int64
-			uid = &value
-		}
+	if err := rows.Err(); err != nil {
+		logger.ErrorContext(c.Request.Context(), "list audit logs rows error", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list audit logs")
+		return
+	}
 
-		logs = append(logs, gin.H{
-			"id":            id,
-			"user_id":       uid,
-			"action":        action,
-			"resource_type": resourceType,
-			"resource_id":   resourceID,
-			"ip_address":    ipAddress,
-			"user_agent":    userAgent,
-			"success":       success,
-			"details":       details,
-			"created_at":    createdAt,
-		})
+	count, hasMore := page.Trim(len(logs))
+	logs = logs[:count]
+	lastKey := ""
+	if count > 0 {
+		lastKey = strconv.FormatInt(logs[count-1]["id"].(int64), 10)
+	}
+	info := page.Info(total, hasMore, lastKey)
+	if wantsCSV(c) {
+		writeAuditLogsCSV(c, logs, info)
+		return
 	}
+	respondPage(c, logs, info, gin.H{"audit_logs": logs, "count": len(logs)})
+}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("[IAM] list audit logs rows error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit logs"})
+// GetAuditLog returns one audit log entry with its details decoded
+func (h *IAMHandler) GetAuditLog(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid audit log ID")
 		return
 	}
+	row := h.db.QueryRowContext(c.Request.Context(), "SELECT "+auditLogColumns+" FROM audit_logs WHERE id = ?", id)
+	entry, err := scanAuditLog(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Audit log entry not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "get audit log failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load audit log")
+		return
+	}
+	var details interface{}
+	if json.Unmarshal([]byte(entry["details"].(string)), &details) == nil {
+		entry["details"] = details
+	}
+	c.JSON(http.StatusOK, entry)
+}
 
-	c.JSON(http.StatusOK, gin.H{"audit_logs": logs, "count": len(logs)})
+const auditLogColumns = "id, user_id, action, resource_type, resource_id, ip_address, user_agent, success, details, created_at"
+
+func scanAuditLog(row interface{ Scan(...interface{}) error }) (gin.H, error) {
+	var id int64
+	var userID sql.NullInt64
+	var action string
+	var resourceType, resourceID, ipAddress, userAgent, details sql.NullString
+	var success bool
+	var createdAt time.Time
+	if err := row.Scan(&id, &userID, &action, &resourceType, &resourceID, &ipAddress, &userAgent, &success, &details, &createdAt); err != nil {
+		return nil, err
+	}
+
+	var uid *int64
+	if userID.Valid {
+		value := userID.Int64
+		uid = &value
+	}
+	return gin.H{
+		"id":            id,
+		"user_id":       uid,
+		"action":        action,
+		"resource_type": resourceType.String,
+		"resource_id":   resourceID.String,
+		"ip_address":    ipAddress.String,
+		"user_agent":    userAgent.String,
+		"success":       success,
+		"details":       details.String,
+		"created_at":    createdAt,
+	}, nil
+}
+
+// auditLogFilter builds the WHERE clause of an audit log listing from its
+// query parameters
+func auditLogFilter(c *gin.Context) (string, []interface{}, error) {
+	where := "1=1"
+	args := []interface{}{}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("user_id must be a number")
+		}
+		where += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	if value := c.Query("action"); value != "" {
+		where += " AND action LIKE ? ESCAPE '\\'"
+		args = append(args, likePattern(value))
+	}
+	for _, column := range []string{"resource_type", "resource_id", "ip_address"} {
+		if value := c.Query(column); value != "" {
+			where += " AND " + column + " = ?"
+			args = append(args, value)
+		}
+	}
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("success must be true or false")
+		}
+		where += " AND success = ?"
+		args = append(args, success)
+	}
+	from, to, err := listingRange(c)
+	if err != nil {
+		return "", nil, err
+	}
+	if !from.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, from.UTC().Format(metrics.TimestampFormat))
+	}
+	if !to.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, to.UTC().Format(metrics.TimestampFormat))
+	}
+	return where, args, nil
+}
+
+var auditLogCSVColumns = []string{
+	"id", "user_id", "action", "resource_type", "resource_id",
+	"ip_address", "user_agent", "success", "details", "created_at",
+}
+
+func writeAuditLogsCSV(c *gin.Context, logs []gin.H, info database.PageInfo) {
+	rows := make([][]string, 0, len(logs))
+	for _, entry := range logs {
+		row := make([]string, len(auditLogCSVColumns))
+		for i, column := range auditLogCSVColumns {
+			switch value := entry[column].(type) {
+			case *int64:
+				if value != nil {
+					row[i] = strconv.FormatInt(*value, 10)
+				}
+			case time.Time:
+				row[i] = value.UTC().Format(time.RFC3339)
+			default:
+				row[i] = csvValue(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	writeCSVPage(c, "audit-logs.csv", auditLogCSVColumns, rows, info)
 }
 
 func assignRolePermissions(tx *sql.Tx, roleID int64, permissionNames []string) error {
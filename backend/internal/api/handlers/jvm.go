@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// JVMMetrics is the agent's reading of one JVM: heap, metaspace and GC from
+// jstat, threads from /proc. Error is set when jstat could not read it.
+type JVMMetrics struct {
+	PID            int     `json:"pid"`
+	Timestamp      int64   `json:"timestamp"`
+	HeapUsed       int64   `json:"heap_used"`
+	HeapCommitted  int64   `json:"heap_committed"`
+	HeapMax        int64   `json:"heap_max,omitempty"`
+	MetaspaceUsed  int64   `json:"metaspace_used"`
+	YoungGCCount   int64   `json:"young_gc_count"`
+	YoungGCSeconds float64 `json:"young_gc_seconds"`
+	FullGCCount    int64   `json:"full_gc_count"`
+	FullGCSeconds  float64 `json:"full_gc_seconds"`
+	GCSeconds      float64 `json:"gc_seconds"`
+	Threads        int     `json:"threads"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// jvmReading returns the agent's reading of the JVM with the given PID
+func jvmReading(state *AgentState, pid int) *JVMMetrics {
+	for i := range state.JVM {
+		if state.JVM[i].PID == pid {
+			return &state.JVM[i]
+		}
+	}
+	return nil
+}
+
+// recordJVM stores a server's JVM reading at most once per metrics interval.
+// Every instance runs health checks, so only the leader writes.
+func (h *ServerHandler) recordJVM(serverID string, serverDef config.ServerDefinition, reading *JVMMetrics) {
+	if h.db == nil || reading == nil || reading.Error != "" || reading.Timestamp == 0 || !h.leading() {
+		return
+	}
+	settings := h.config.Metrics
+	if !settings.Enabled {
+		return
+	}
+	interval := serverDef.Monitoring.Interval
+	if interval <= 0 {
+		interval = settings.DefaultInterval
+	}
+	if interval <= 0 {
+		interval = 60
+	}
+
+	at := time.Unix(reading.Timestamp, 0).UTC()
+	h.jvmMu.Lock()
+	last, ok := h.jvmRecorded[serverID]
+	if ok && at.Sub(last) < time.Duration(interval)*time.Second {
+		h.jvmMu.Unlock()
+		return
+	}
+	h.jvmRecorded[serverID] = at
+	h.jvmMu.Unlock()
+
+	sample := metrics.JVMSample{
+		Timestamp:      at,
+		PID:            reading.PID,
+		HeapUsed:       reading.HeapUsed,
+		HeapCommitted:  reading.HeapCommitted,
+		HeapMax:        reading.HeapMax,
+		MetaspaceUsed:  reading.MetaspaceUsed,
+		YoungGCCount:   reading.YoungGCCount,
+		YoungGCSeconds: reading.YoungGCSeconds,
+		FullGCCount:    reading.FullGCCount,
+		FullGCSeconds:  reading.FullGCSeconds,
+		GCSeconds:      reading.GCSeconds,
+		Threads:        reading.Threads,
+	}
+	if err := metrics.RecordJVM(context.Background(), h.db.DB, serverID, sample); err != nil {
+		logger.Warn("Failed to record JVM metrics", "server_id", serverID, "error", err)
+	}
+}
+
+// GetJVMMetrics returns a server's JVM readings, newest first.
+// ?from= and ?to= narrow them to a range and ?limit= caps them (default 500).
+func (h *ServerHandler) GetJVMMetrics(c *gin.Context) {
+	serverID := c.Param("id")
+	from, to, err := listingRange(c)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "500"))
+	if limit <= 0 || limit > 5000 {
+		limit = 500
+	}
+
+	samples, err := metrics.ListJVM(c.Request.Context(), h.db.DB, serverID, from, to, limit)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load JVM metrics", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load JVM metrics")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"server_id": serverID, "jvm": samples})
+}
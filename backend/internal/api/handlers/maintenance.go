@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+)
+
+// MaintenanceWindowHandler serves the maintenance window API
+type MaintenanceWindowHandler struct {
+	store         *maintenance.Store
+	manager       *maintenance.Manager
+	serverManager *config.ServerManager
+	cluster       *cluster.Node
+}
+
+// maintenanceRefreshTopic tells the other instances of a cluster that windows
+// were changed, opened or closed
+const maintenanceRefreshTopic = "maintenance.refresh"
+
+type maintenanceWindowRequest struct {
+	Name      string     `json:"name"`
+	ServerIDs []string   `json:"server_ids"`
+	Group     string     `json:"group"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	Cron      string     `json:"cron"`
+	Duration  string     `json:"duration"`
+	Enabled   *bool      `json:"enabled"`
+}
+
+// NewMaintenanceWindowHandler creates a new maintenance window handler
+func NewMaintenanceWindowHandler(store *maintenance.Store, manager *maintenance.Manager, serverManager *config.ServerManager) *MaintenanceWindowHandler {
+	return &MaintenanceWindowHandler{
+		store:         store,
+		manager:       manager,
+		serverManager: serverManager,
+	}
+}
+
+// SetCluster lets the leading manager instance open and close the windows,
+// while the others follow it and pass on the windows changed through them.
+// It must be called before the manager is started.
+func (h *MaintenanceWindowHandler) SetCluster(node *cluster.Node) {
+	h.cluster = node
+	if !node.Clustered() {
+		return
+	}
+	h.manager.SetLeader(node.Leader)
+	h.manager.OnChange(func() { node.Publish(maintenanceRefreshTopic, nil) })
+	node.Subscribe(maintenanceRefreshTopic, func(json.RawMessage) { h.manager.Refresh() })
+}
+
+// refresh has the windows checked again on every instance
+func (h *MaintenanceWindowHandler) refresh() {
+	h.manager.Refresh()
+	if h.cluster != nil {
+		h.cluster.Publish(maintenanceRefreshTopic, nil)
+	}
+}
+
+// ListMaintenanceWindows returns all maintenance windows and which are open
+func (h *MaintenanceWindowHandler) ListMaintenanceWindows(c *gin.Context) {
+	windows, err := h.store.List(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list maintenance windows", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load maintenance windows")
+		return
+	}
+	open := h.manager.OpenWindows()
+	active := make([]string, 0, len(open))
+	for _, window := range windows {
+		if open[window.ID] {
+			active = append(active, window.ID)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"windows": windows, "open": active})
+}
+
+// GetMaintenanceWindow returns one maintenance window
+func (h *MaintenanceWindowHandler) GetMaintenanceWindow(c *gin.Context) {
+	window, ok := h.loadWindow(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"window": window, "open": h.manager.OpenWindows()[window.ID]})
+}
+
+// CreateMaintenanceWindow adds a maintenance window
+func (h *MaintenanceWindowHandler) CreateMaintenanceWindow(c *gin.Context) {
+	var req maintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	window := &maintenance.Window{ID: uuid.New().String(), Enabled: true}
+	if !h.applyRequest(c, window, req) {
+		return
+	}
+
+	if err := h.store.Save(c.Request.Context(), window); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to create maintenance window", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save maintenance window")
+		return
+	}
+	h.refresh()
+	c.JSON(http.StatusCreated, window)
+}
+
+// UpdateMaintenanceWindow replaces a maintenance window's settings. An open
+// window that no longer applies closes on the next check.
+func (h *MaintenanceWindowHandler) UpdateMaintenanceWindow(c *gin.Context) {
+	window, ok := h.loadWindow(c)
+	if !ok {
+		return
+	}
+
+	var req maintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if !h.applyRequest(c, window, req) {
+		return
+	}
+
+	if err := h.store.Save(c.Request.Context(), window); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to update maintenance window", "window_id", window.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save maintenance window")
+		return
+	}
+	h.refresh()
+	c.JSON(http.StatusOK, window)
+}
+
+// DeleteMaintenanceWindow closes a maintenance window, starting the servers
+// it stopped, and removes it
+func (h *MaintenanceWindowHandler) DeleteMaintenanceWindow(c *gin.Context) {
+	window, ok := h.loadWindow(c)
+	if !ok {
+		return
+	}
+	if err := h.manager.Close(c.Request.Context(), window.ID); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to close maintenance window", "window_id", window.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to close maintenance window")
+		return
+	}
+
+	deleted, err := h.store.Delete(c.Request.Context(), window.ID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to delete maintenance window", "window_id", window.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete maintenance window")
+		return
+	}
+	if !deleted {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Maintenance window not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance window deleted"})
+}
+
+func (h *MaintenanceWindowHandler) loadWindow(c *gin.Context) (*maintenance.Window, bool) {
+	window, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, maintenance.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Maintenance window not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load maintenance window", "window_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load maintenance window")
+		return nil, false
+	}
+	return window, true
+}
+
+// applyRequest copies a create or update request onto window and validates it
+func (h *MaintenanceWindowHandler) applyRequest(c *gin.Context, window *maintenance.Window, req maintenanceWindowRequest) bool {
+	if err := req.applyTo(window, h.serverExists); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *MaintenanceWindowHandler) serverExists(serverID string) bool {
+	_, found := h.serverManager.GetByID(serverID)
+	return found
+}
+
+// applyTo copies the request onto window, fills in defaults and checks the
+// result, including that its servers exist
+func (req maintenanceWindowRequest) applyTo(window *maintenance.Window, serverExists func(string) bool) error {
+	window.Name = req.Name
+	window.ServerIDs = req.ServerIDs
+	window.Group = req.Group
+	window.StartsAt = req.StartsAt
+	window.EndsAt = req.EndsAt
+	window.Cron = req.Cron
+	window.Duration = req.Duration
+	if req.Enabled != nil {
+		window.Enabled = *req.Enabled
+	}
+	window.Normalize()
+
+	if err := window.Validate(); err != nil {
+		return err
+	}
+	for _, serverID := range window.ServerIDs {
+		if !serverExists(serverID) {
+			return fmt.Errorf("server %s does not exist", serverID)
+		}
+	}
+	return nil
+}
+
+// SetMaintenanceWindows makes the handler refuse starts of servers in an
+// open maintenance window and mark their status changes
+func (h *ServerHandler) SetMaintenanceWindows(manager *maintenance.Manager) {
+	h.maintenance = manager
+}
+
+// inMaintenance returns the open maintenance window covering a server, if any
+func (h *ServerHandler) inMaintenance(serverID string) (*maintenance.Window, bool) {
+	if h.maintenance == nil {
+		return nil, false
+	}
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return nil, false
+	}
+	return h.maintenance.Active(serverID, serverDef.Group)
+}
+
+// maintenanceError describes why a server cannot be started right now
+func maintenanceError(window *maintenance.Window) string {
+	until := ""
+	if window.ActiveUntil != nil {
+		until = " until " + window.ActiveUntil.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("Server is in maintenance window %q%s", window.Name, until)
+}
+
+// ServerGroups returns the group of every defined server, keyed by ID
+func (h *ServerHandler) ServerGroups() map[string]string {
+	servers := h.serverManager.GetAll()
+	groups := make(map[string]string, len(servers))
+	for _, serverDef := range servers {
+		groups[serverDef.ID] = serverDef.Group
+	}
+	return groups
+}
+
+// StopForMaintenance gracefully stops a server when a maintenance window
+// opens. It reports whether the server was running.
+func (h *ServerHandler) StopForMaintenance(ctx context.Context, serverID string) (bool, error) {
+	if _, _, err := h.connectServer(serverID); err != nil {
+		return false, err
+	}
+	status, err := h.statusDetector.DetectStatus(serverID, server.SafeSessionName(serverID))
+	if err != nil {
+		return false, err
+	}
+	if status.Status == server.StatusOffline {
+		return false, nil
+	}
+
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return false, fmt.Errorf("server %s not found", serverID)
+	}
+
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(serverID)
+
+	logger.InfoContext(ctx, "Stopping server for maintenance", "server_id", serverID)
+	if err := h.lifecycleManager.StopServer(serverID, h.createServerConfig(&serverDef), true); err != nil {
+		h.activityLogger.LogServerStop(serverID, nil, true, false, err.Error())
+		return true, err
+	}
+	h.activityLogger.LogServerStop(serverID, nil, true, true, "")
+	return true, nil
+}
+
+// StartAfterMaintenance starts a server a closed maintenance window stopped
+func (h *ServerHandler) StartAfterMaintenance(ctx context.Context, serverID string) error {
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return fmt.Errorf("server %s not found", serverID)
+	}
+
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(serverID)
+
+	logger.InfoContext(ctx, "Starting server after maintenance", "server_id", serverID)
+	if err := h.lifecycleManager.StartServer(serverID, h.createServerConfig(&serverDef)); err != nil {
+		h.activityLogger.LogServerStart(serverID, nil, false, err.Error())
+		return err
+	}
+	h.activityLogger.LogServerStart(serverID, nil, true, "")
+	return nil
+}
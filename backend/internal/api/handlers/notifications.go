@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/notifications"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// NotificationHandler manages notification channels and rules
+type NotificationHandler struct {
+	store      *notifications.Store
+	dispatcher *notifications.Dispatcher
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(store *notifications.Store, dispatcher *notifications.Dispatcher) *NotificationHandler {
+	return &NotificationHandler{store: store, dispatcher: dispatcher}
+}
+
+// channelRequest creates or changes a channel. Channels are shown with their
+// URL redacted, so an empty url on update keeps the stored one; a missing
+// secret keeps the stored secret and an empty one removes it.
+type channelRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Type       string   `json:"type" binding:"required"`
+	URL        string   `json:"url"`
+	Secret     *string  `json:"secret"`
+	Recipients []string `json:"recipients"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+type ruleRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Event      string   `json:"event" binding:"required"`
+	ChannelIDs []string `json:"channel_ids" binding:"required"`
+	ServerIDs  []string `json:"server_ids"`
+	Threshold  float64  `json:"threshold"`
+	Cooldown   string   `json:"cooldown"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// ListChannels returns the notification channels, by name
+func (h *NotificationHandler) ListChannels(c *gin.Context) {
+	channels, err := h.store.ListChannels(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list notification channels", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load notification channels")
+		return
+	}
+	redacted := make([]notifications.Channel, 0, len(channels))
+	for _, channel := range channels {
+		redacted = append(redacted, channel.Redacted())
+	}
+	c.JSON(http.StatusOK, gin.H{"channels": redacted})
+}
+
+// CreateChannel adds a notification channel
+func (h *NotificationHandler) CreateChannel(c *gin.Context) {
+	var req channelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	channel := &notifications.Channel{ID: uuid.New().String(), Enabled: true}
+	req.apply(channel)
+	if !h.saveChannel(c, channel) {
+		return
+	}
+	c.JSON(http.StatusCreated, channel.Redacted())
+}
+
+// UpdateChannel changes a notification channel
+func (h *NotificationHandler) UpdateChannel(c *gin.Context) {
+	channel, ok := h.loadChannel(c)
+	if !ok {
+		return
+	}
+	var req channelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	req.apply(channel)
+	if !h.saveChannel(c, channel) {
+		return
+	}
+	c.JSON(http.StatusOK, channel.Redacted())
+}
+
+// DeleteChannel removes a notification channel no rule sends to
+func (h *NotificationHandler) DeleteChannel(c *gin.Context) {
+	err := h.store.DeleteChannel(c.Request.Context(), c.Param("id"))
+	var inUse *notifications.ChannelInUseError
+	switch {
+	case errors.Is(err, notifications.ErrNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Notification channel not found")
+		return
+	case errors.As(err, &inUse):
+		apierror.RespondDetails(c, http.StatusConflict, apierror.CodeConflict, err.Error(), gin.H{"rules": inUse.Rules})
+		return
+	case err != nil:
+		logger.ErrorContext(c.Request.Context(), "Failed to delete notification channel", "channel_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete notification channel")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted"})
+}
+
+// TestChannel sends a test message through a channel
+func (h *NotificationHandler) TestChannel(c *gin.Context) {
+	channel, ok := h.loadChannel(c)
+	if !ok {
+		return
+	}
+	if err := h.dispatcher.Test(c.Request.Context(), channel); err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Test notification failed: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Test notification sent"})
+}
+
+// ListRules returns the notification rules, by name
+func (h *NotificationHandler) ListRules(c *gin.Context) {
+	rules, err := h.store.ListRules(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list notification rules", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load notification rules")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules, "events": notifications.Events})
+}
+
+// CreateRule adds a notification rule
+func (h *NotificationHandler) CreateRule(c *gin.Context) {
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	rule := &notifications.Rule{ID: uuid.New().String(), Enabled: true}
+	req.apply(rule)
+	if !h.saveRule(c, rule) {
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule changes a notification rule
+func (h *NotificationHandler) UpdateRule(c *gin.Context) {
+	rule, err := h.store.GetRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, notifications.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Notification rule not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load notification rule", "rule_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load notification rule")
+		return
+	}
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	req.apply(rule)
+	if !h.saveRule(c, rule) {
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes a notification rule
+func (h *NotificationHandler) DeleteRule(c *gin.Context) {
+	err := h.store.DeleteRule(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, notifications.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Notification rule not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to delete notification rule", "rule_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete notification rule")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification rule deleted"})
+}
+
+// ListDeliveries returns the latest notifications sent, newest first
+func (h *NotificationHandler) ListDeliveries(c *gin.Context) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 500 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+	deliveries, err := h.store.ListDeliveries(c.Request.Context(), limit)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list notification deliveries", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load notification deliveries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func (req channelRequest) apply(channel *notifications.Channel) {
+	channel.Name = req.Name
+	channel.Type = req.Type
+	if req.URL != "" {
+		channel.URL = req.URL
+	}
+	if req.Secret != nil {
+		channel.Secret = *req.Secret
+	}
+	channel.Recipients = req.Recipients
+	if req.Enabled != nil {
+		channel.Enabled = *req.Enabled
+	}
+}
+
+func (req ruleRequest) apply(rule *notifications.Rule) {
+	rule.Name = req.Name
+	rule.Event = req.Event
+	rule.ChannelIDs = req.ChannelIDs
+	rule.ServerIDs = req.ServerIDs
+	rule.Threshold = req.Threshold
+	rule.Cooldown = req.Cooldown
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
+
+func (h *NotificationHandler) loadChannel(c *gin.Context) (*notifications.Channel, bool) {
+	channel, err := h.store.GetChannel(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, notifications.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Notification channel not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load notification channel", "channel_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load notification channel")
+		return nil, false
+	}
+	return channel, true
+}
+
+func (h *NotificationHandler) saveChannel(c *gin.Context, channel *notifications.Channel) bool {
+	channel.Normalize()
+	if err := channel.Validate(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	err := h.store.SaveChannel(c.Request.Context(), channel)
+	if errors.Is(err, notifications.ErrNameTaken) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, err.Error())
+		return false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to save notification channel", "channel_id", channel.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save notification channel")
+		return false
+	}
+	return true
+}
+
+func (h *NotificationHandler) saveRule(c *gin.Context, rule *notifications.Rule) bool {
+	rule.Normalize()
+	if err := rule.Validate(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	err := h.store.SaveRule(c.Request.Context(), rule)
+	switch {
+	case errors.Is(err, notifications.ErrNameTaken):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, err.Error())
+		return false
+	case errors.Is(err, notifications.ErrNotFound):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	case err != nil:
+		logger.ErrorContext(c.Request.Context(), "Failed to save notification rule", "rule_id", rule.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save notification rule")
+		return false
+	}
+	return true
+}
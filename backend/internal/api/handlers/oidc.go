@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const oidcFlowCookieName = "hsm_oidc_flow"
+
+var (
+	errOIDCAccountDisabled = errors.New("account_disabled")
+	errOIDCEmailMissing    = errors.New("email_missing")
+	errOIDCEmailInUse      = errors.New("email_in_use")
+	errOIDCNoRole          = errors.New("no_role")
+)
+
+// OIDCHandler signs users in through an OpenID Connect provider. Accounts are
+// created on first login with roles mapped from the provider's groups.
+type OIDCHandler struct {
+	db          *sql.DB
+	cfg         config.OIDCConfig
+	provider    *auth.OIDCProvider
+	jwtManager  *auth.JWTManager
+	rbacManager *auth.RBACManager
+}
+
+// NewOIDCHandler creates a new OIDC handler. provider is nil when single
+// sign-on is disabled.
+func NewOIDCHandler(db *sql.DB, cfg config.OIDCConfig, provider *auth.OIDCProvider, jwtManager *auth.JWTManager, rbacManager *auth.RBACManager) *OIDCHandler {
+	return &OIDCHandler{
+		db:          db,
+		cfg:         cfg,
+		provider:    provider,
+		jwtManager:  jwtManager,
+		rbacManager: rbacManager,
+	}
+}
+
+// Status tells the login page whether to offer single sign-on
+// GET /api/v1/auth/oidc
+func (h *OIDCHandler) Status(c *gin.Context) {
+	if h.provider == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":      true,
+		"display_name": h.cfg.DisplayName,
+		"login_url":    "/api/v1/auth/oidc/login",
+	})
+}
+
+// Login sends the browser to the identity provider
+// GET /api/v1/auth/oidc/login
+func (h *OIDCHandler) Login(c *gin.Context) {
+	if h.provider == nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Single sign-on is not enabled")
+		return
+	}
+
+	authURL, flow, err := h.provider.NewFlow(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to start OIDC login", "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Identity provider is unavailable")
+		return
+	}
+
+	// Lax, so the cookie comes back on the provider's top-level redirect
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookieName, flow, int(auth.OIDCFlowDuration.Seconds()), "/api/v1/auth/oidc", "", isSecureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback finishes a login started by Login and sends the browser back to
+// the frontend, signed in through the usual auth cookies. Failures land on
+// the frontend too, with ?sso_error= set.
+// GET /api/v1/auth/oidc/callback
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	if h.provider == nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Single sign-on is not enabled")
+		return
+	}
+	ctx := c.Request.Context()
+
+	sealed, _ := c.Cookie(oidcFlowCookieName)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookieName, "", -1, "/api/v1/auth/oidc", "", isSecureRequest(c), true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		logger.WarnContext(ctx, "Identity provider refused the login", "error", providerErr, "description", c.Query("error_description"))
+		h.redirectFrontend(c, "provider_error")
+		return
+	}
+	flow, err := h.provider.OpenFlow(sealed, c.Query("state"))
+	if err != nil {
+		h.redirectFrontend(c, "invalid_state")
+		return
+	}
+	identity, err := h.provider.Exchange(ctx, c.Query("code"), flow)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to complete OIDC login", "error", err)
+		h.redirectFrontend(c, "login_failed")
+		return
+	}
+
+	user, err := h.resolveUser(ctx, identity)
+	if err != nil {
+		code := "login_failed"
+		switch {
+		case errors.Is(err, errOIDCAccountDisabled), errors.Is(err, errOIDCEmailMissing),
+			errors.Is(err, errOIDCEmailInUse), errors.Is(err, errOIDCNoRole):
+			code = err.Error()
+			logger.WarnContext(ctx, "OIDC login refused", "subject", identity.Subject, "email", identity.Email, "reason", code)
+		default:
+			logger.ErrorContext(ctx, "Failed to resolve OIDC user", "subject", identity.Subject, "error", err)
+		}
+		h.redirectFrontend(c, code)
+		return
+	}
+
+	tokens, err := startSession(ctx, h.db, h.jwtManager, h.rbacManager, user)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to start session", "user_id", user.ID, "error", err)
+		h.redirectFrontend(c, "login_failed")
+		return
+	}
+	setAuthCookies(c, h.jwtManager, tokens)
+	h.redirectFrontend(c, "")
+}
+
+func (h *OIDCHandler) redirectFrontend(c *gin.Context, ssoError string) {
+	target, err := url.Parse(h.cfg.FrontendURL)
+	if err != nil {
+		target = &url.URL{Path: "/"}
+	}
+	if ssoError != "" {
+		query := target.Query()
+		query.Set("sso_error", ssoError)
+		target.RawQuery = query.Encode()
+	}
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// resolveUser returns the account linked to an identity, creating it on the
+// first login. Roles come from the provider's groups only at creation; after
+// that they are managed in the panel.
+func (h *OIDCHandler) resolveUser(ctx context.Context, identity *auth.OIDCIdentity) (*models.User, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user := &models.User{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT u.id, u.organization_id, u.username, u.email, u.full_name, u.is_active
+		FROM user_identities i
+		INNER JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = ? AND i.subject = ?
+	`, identity.Issuer, identity.Subject).Scan(&user.ID, &user.OrganizationID, &user.Username, &user.Email, &user.FullName, &user.IsActive)
+	switch {
+	case err == nil:
+		if !user.IsActive {
+			return nil, errOIDCAccountDisabled
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE user_identities SET email = ?, last_login_at = ? WHERE issuer = ? AND subject = ?`,
+			identity.Email, time.Now(), identity.Issuer, identity.Subject); err != nil {
+			return nil, err
+		}
+		return user, tx.Commit()
+	case err != sql.ErrNoRows:
+		return nil, err
+	}
+
+	if identity.Email == "" {
+		return nil, errOIDCEmailMissing
+	}
+	err = tx.QueryRowContext(ctx, `SELECT id, organization_id, username, email, full_name, is_active FROM users WHERE email = ?`, identity.Email).
+		Scan(&user.ID, &user.OrganizationID, &user.Username, &user.Email, &user.FullName, &user.IsActive)
+	switch {
+	case err == nil:
+		// Taking over a local account by email is only safe when the
+		// provider vouches for the address
+		if !h.cfg.LinkVerifiedEmail || !identity.EmailVerified {
+			return nil, errOIDCEmailInUse
+		}
+		if !user.IsActive {
+			return nil, errOIDCAccountDisabled
+		}
+	case err == sql.ErrNoRows:
+		if err := h.createUser(ctx, tx, identity, user); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_identities (user_id, issuer, subject, email, created_at, last_login_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, user.ID, identity.Issuer, identity.Subject, identity.Email, time.Now(), time.Now()); err != nil {
+		return nil, err
+	}
+	return user, tx.Commit()
+}
+
+// createUser adds the account for a first login and grants the roles its
+// groups map to. SSO accounts get no password; one can be set later through
+// the password reset flow.
+func (h *OIDCHandler) createUser(ctx context.Context, tx *sql.Tx, identity *auth.OIDCIdentity, user *models.User) error {
+	roles := auth.MapGroupRoles(identity.Groups, h.cfg.GroupRoles, h.cfg.DefaultRole)
+	if len(roles) == 0 {
+		return errOIDCNoRole
+	}
+
+	username, err := uniqueUsername(ctx, tx, oidcUsername(identity))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO users (username, email, password_hash, full_name, created_at, updated_at)
+		VALUES (?, ?, '', ?, ?, ?)
+	`, username, identity.Email, identity.Name, now, now)
+	if err != nil {
+		return err
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	granted := 0
+	for _, role := range roles {
+		result, err := tx.ExecContext(ctx, `INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE name = ?`, userID, role)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			granted++
+		} else {
+			logger.WarnContext(ctx, "OIDC group maps to an unknown role", "role", role)
+		}
+	}
+	if granted == 0 {
+		return errOIDCNoRole
+	}
+
+	*user = models.User{
+		ID:             userID,
+		OrganizationID: 1,
+		Username:       username,
+		Email:          identity.Email,
+		FullName:       identity.Name,
+		IsActive:       true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	return nil
+}
+
+// oidcUsername picks a username from the identity's preferred username or
+// the local part of its email, keeping only characters safe in a username
+func oidcUsername(identity *auth.OIDCIdentity) string {
+	candidate := identity.Username
+	if candidate == "" {
+		candidate, _, _ = strings.Cut(identity.Email, "@")
+	}
+	var b strings.Builder
+	for _, r := range candidate {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+		}
+		if b.Len() == 40 {
+			break
+		}
+	}
+	if b.Len() < 3 {
+		return "user"
+	}
+	return b.String()
+}
+
+// uniqueUsername appends a number to base until no account uses it
+func uniqueUsername(ctx context.Context, tx *sql.Tx, base string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		username := base
+		if i > 1 {
+			username = fmt.Sprintf("%s-%d", base, i)
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)`, username).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return username, nil
+		}
+	}
+	return "", fmt.Errorf("no free username for %q", base)
+}
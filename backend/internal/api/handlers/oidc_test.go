@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestOIDCResolveUser(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "oidc.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO users (username, email, password_hash) VALUES ('ana', 'ana@example.com', 'hash')`); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.OIDCConfig{GroupRoles: map[string]string{"admins": "Admin"}, DefaultRole: "Viewer"}
+	handler := NewOIDCHandler(db.DB, cfg, nil, nil, auth.NewRBACManager(db.DB))
+	ctx := context.Background()
+
+	identity := &auth.OIDCIdentity{Issuer: "https://sso.example", Subject: "u-1", Email: "ana.b@example.com", Username: "ana", Groups: []string{"/admins"}}
+	user, err := handler.resolveUser(ctx, identity)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.Username != "ana-2" {
+		t.Fatalf("expected a free username, got %s", user.Username)
+	}
+	roles, _ := handler.rbacManager.GetUserRoles(user.ID)
+	if len(roles) != 1 || roles[0] != "Admin" {
+		t.Fatalf("expected the group to grant Admin, got %v", roles)
+	}
+
+	identity.Groups = nil
+	again, err := handler.resolveUser(ctx, identity)
+	if err != nil || again.ID != user.ID {
+		t.Fatalf("expected the same account on the next login, got %+v (%v)", again, err)
+	}
+
+	taken := &auth.OIDCIdentity{Issuer: "https://sso.example", Subject: "u-2", Email: "ana@example.com", EmailVerified: true}
+	if _, err := handler.resolveUser(ctx, taken); !errors.Is(err, errOIDCEmailInUse) {
+		t.Fatalf("expected a local account's email to be refused, got %v", err)
+	}
+	handler.cfg.LinkVerifiedEmail = true
+	linked, err := handler.resolveUser(ctx, taken)
+	if err != nil || linked.Username != "ana" {
+		t.Fatalf("expected the verified email to link the local account, got %+v (%v)", linked, err)
+	}
+
+	handler.cfg.DefaultRole = ""
+	stranger := &auth.OIDCIdentity{Issuer: "https://sso.example", Subject: "u-3", Email: "eve@example.com", Groups: []string{"guests"}}
+	if _, err := handler.resolveUser(ctx, stranger); !errors.Is(err, errOIDCNoRole) {
+		t.Fatalf("expected a user without a mapped group to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+// Pagination headers sent by v1 list routes, whose bodies keep their original shape
+const (
+	TotalCountHeader = "X-Total-Count"
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// pageLimits bounds the page size of one listing
+type pageLimits struct {
+	Default int
+	Max     int
+	// unboundedV1 keeps v1's behaviour of returning everything when neither limit nor cursor is given
+	unboundedV1 bool
+}
+
+var (
+	auditLogPages = pageLimits{Default: 100, Max: 500}
+	activityPages = pageLimits{Default: 50, Max: 500}
+	metricsPages  = pageLimits{Default: 50, Max: 500}
+	releasePages  = pageLimits{Default: 50, Max: 500}
+	userPages     = pageLimits{Default: 100, Max: 500, unboundedV1: true}
+	backupPages   = pageLimits{Default: 100, Max: 500, unboundedV1: true}
+	consolePages  = pageLimits{Default: 100, Max: 500}
+)
+
+// parsePage reads the limit and cursor query parameters. It writes a 400
+// response and returns false when the cursor is malformed.
+func parsePage(c *gin.Context, limits pageLimits) (database.PageRequest, bool) {
+	page := database.PageRequest{Limit: limits.Default}
+	limitParam, hasLimit := c.GetQuery("limit")
+	cursor := c.Query("cursor")
+
+	if limits.unboundedV1 && !hasLimit && cursor == "" && versioning.ForRequest(c.FullPath(), c.Request.URL.Path) == versioning.V1 {
+		page.Limit = 0
+	}
+	if parsed, err := strconv.Atoi(limitParam); err == nil && parsed > 0 {
+		page.Limit = parsed
+		if page.Limit > limits.Max {
+			page.Limit = limits.Max
+		}
+	}
+
+	if cursor != "" {
+		after, err := database.DecodeCursor(cursor)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCursor, "Invalid cursor")
+			return page, false
+		}
+		page.After = after
+	}
+	return page, true
+}
+
+// pageWindow applies a page to keys that are already sorted, for listings
+// that are loaded in full. It returns the slice bounds of the page.
+func pageWindow(keys []string, page database.PageRequest) (int, int, database.PageInfo, error) {
+	start := 0
+	if page.After != "" {
+		start = -1
+		for i, key := range keys {
+			if key == page.After {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return 0, 0, database.PageInfo{}, database.ErrInvalidCursor
+		}
+	}
+
+	count, hasMore := page.Trim(len(keys) - start)
+	end := start + count
+	lastKey := ""
+	if count > 0 {
+		lastKey = keys[end-1]
+	}
+	return start, end, page.Info(len(keys), hasMore, lastKey), nil
+}
+
+// respondPage writes a listing. v2 wraps it in a data/pagination envelope;
+// v1 keeps its legacy body and reports the page through headers.
+func respondPage(c *gin.Context, items interface{}, info database.PageInfo, legacy interface{}) {
+	if versioning.ForRequest(c.FullPath(), c.Request.URL.Path) != versioning.V1 {
+		c.JSON(http.StatusOK, gin.H{"data": items, "pagination": info})
+		return
+	}
+	c.Header(TotalCountHeader, strconv.Itoa(info.Total))
+	if info.NextCursor != "" {
+		c.Header(NextCursorHeader, info.NextCursor)
+	}
+	c.JSON(http.StatusOK, legacy)
+}
+
+// respondPageError maps a listing error to a response
+func respondPageError(c *gin.Context, err error, message string) {
+	if errors.Is(err, database.ErrInvalidCursor) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidCursor, "Invalid cursor")
+		return
+	}
+	apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, message)
+}
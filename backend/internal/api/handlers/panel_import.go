@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/importer"
+)
+
+// panelImportRequest carries an export from another panel
+type panelImportRequest struct {
+	// Format is "pterodactyl" or "csv"
+	Format   string            `json:"format" binding:"required"`
+	Data     string            `json:"data" binding:"required"`
+	Defaults importer.Defaults `json:"defaults"`
+	DryRun   bool              `json:"dry_run"`
+}
+
+// panelImportServer is the outcome for one converted server
+type panelImportServer struct {
+	Server  config.ServerDefinition `json:"server"`
+	Created bool                    `json:"created"`
+	Reason  string                  `json:"reason,omitempty"`
+}
+
+// ImportFromPanel creates servers from a Pterodactyl export or an SSH inventory CSV.
+// Servers whose ID is taken or whose definition is incomplete are skipped
+// with the reason; dry_run only converts.
+func (h *ServerHandler) ImportFromPanel(c *gin.Context) {
+	var req panelImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	result, err := importer.Convert(req.Format, []byte(req.Data), req.Defaults)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	servers := make([]panelImportServer, len(result.Servers))
+	for i, def := range result.Servers {
+		servers[i].Server = def
+		if _, exists := h.serverManager.GetByID(def.ID); exists {
+			servers[i].Reason = "a server with this ID already exists"
+		} else if err := config.ValidateServerDefinition(&def); err != nil {
+			servers[i].Reason = err.Error()
+		}
+	}
+
+	created := 0
+	if !req.DryRun {
+		err = h.serverManager.Persist(func() error {
+			for i := range servers {
+				if servers[i].Reason != "" {
+					continue
+				}
+				if err := h.serverManager.Add(servers[i].Server); err != nil {
+					servers[i].Reason = err.Error()
+					continue
+				}
+				servers[i].Created = true
+				created++
+			}
+			return nil
+		})
+		if errors.Is(err, config.ErrPersistFailed) {
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save servers")
+			return
+		}
+		logger.InfoContext(c.Request.Context(), "Imported servers from another panel", "format", req.Format, "created", created, "user_id", getUserIDFromContext(c))
+	}
+
+	skipped := 0
+	for _, server := range servers {
+		if server.Reason != "" {
+			skipped++
+		}
+	}
+	warnings := result.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"servers":  servers,
+		"created":  created,
+		"skipped":  skipped,
+		"warnings": warnings,
+		"dry_run":  req.DryRun,
+	})
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/notify"
+)
+
+const passwordResetGenericMessage = "If an account exists for that email, a reset link has been sent"
+
+// passwordResetResponseTime is the least time a forgot-password request
+// takes. Issuing a token makes known addresses slower to answer than unknown
+// ones; every answer waits until then so the two cannot be told apart.
+const passwordResetResponseTime = 250 * time.Millisecond
+
+// PasswordResetHandler handles the forgot-password flow
+type PasswordResetHandler struct {
+	db             *sql.DB
+	cfg            config.PasswordResetConfig
+	mailer         *notify.SMTPSender
+	passwords      auth.PasswordHasher
+	tokenDuration  time.Duration
+	resendCooldown time.Duration
+}
+
+// NewPasswordResetHandler creates a new password reset handler
+func NewPasswordResetHandler(db *sql.DB, cfg config.PasswordResetConfig, mailer *notify.SMTPSender, passwords auth.PasswordHasher) *PasswordResetHandler {
+	tokenDuration, err := time.ParseDuration(cfg.TokenDuration)
+	if err != nil || tokenDuration <= 0 {
+		tokenDuration = 30 * time.Minute
+	}
+	resendCooldown, err := time.ParseDuration(cfg.ResendCooldown)
+	if err != nil || resendCooldown < 0 {
+		resendCooldown = 2 * time.Minute
+	}
+
+	return &PasswordResetHandler{
+		db:             db,
+		cfg:            cfg,
+		mailer:         mailer,
+		passwords:      passwords,
+		tokenDuration:  tokenDuration,
+		resendCooldown: resendCooldown,
+	}
+}
+
+// RequestPasswordReset issues a reset token and emails it to the account owner.
+// The response is identical whether or not the email matches an account.
+func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
+	started := time.Now()
+	if !h.cfg.Enabled || !h.mailer.Enabled() {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Password reset is not available")
+		return
+	}
+
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request")
+		return
+	}
+
+	h.cleanupExpired()
+
+	var userID int64
+	var username, email string
+	err := h.db.QueryRowContext(c.Request.Context(),
+		`SELECT id, username, email FROM users WHERE lower(email) = lower(?) AND is_active = 1`,
+		strings.TrimSpace(req.Email),
+	).Scan(&userID, &username, &email)
+	if err == sql.ErrNoRows {
+		acceptPasswordReset(c, started)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+
+	if h.recentlyRequested(userID) {
+		acceptPasswordReset(c, started)
+		return
+	}
+
+	token, tokenHash, err := auth.GenerateResetToken()
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to generate reset token")
+		return
+	}
+
+	now := time.Now()
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Only the most recent link stays valid
+	if _, err := tx.Exec(`DELETE FROM password_reset_tokens WHERE user_id = ? AND used_at IS NULL`, userID); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, requested_ip, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, tokenHash, now.Add(h.tokenDuration), c.ClientIP(), now); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store reset token")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+
+	// Deliver asynchronously so response timing does not reveal whether the account exists
+	subject := "Hytale Server Manager password reset"
+	body := h.buildResetEmail(username, token)
+	ctx := context.WithoutCancel(c.Request.Context())
+	go func() {
+		if err := h.mailer.Send([]string{email}, subject, body); err != nil {
+			logger.ErrorContext(ctx, "failed to send reset email for user", "user_id", userID, "error", err)
+		}
+	}()
+
+	acceptPasswordReset(c, started)
+}
+
+// acceptPasswordReset answers a forgot-password request passwordResetResponseTime
+// after it started
+func acceptPasswordReset(c *gin.Context, started time.Time) {
+	time.Sleep(time.Until(started.Add(passwordResetResponseTime)))
+	c.JSON(http.StatusAccepted, gin.H{"message": passwordResetGenericMessage})
+}
+
+// ResetPassword consumes a reset token and sets a new password
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	if !h.cfg.Enabled {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Password reset is not available")
+		return
+	}
+
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	tokenHash := auth.HashResetToken(strings.TrimSpace(req.Token))
+
+	var tokenID, userID int64
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err := h.db.QueryRow(`
+		SELECT id, user_id, expires_at, used_at FROM password_reset_tokens WHERE token_hash = ?
+	`, tokenHash).Scan(&tokenID, &userID, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid or expired reset token")
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid or expired reset token")
+		return
+	}
+
+	passwordHash, err := h.passwords.Hash(req.Password)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to hash password")
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`, now, tokenID)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid or expired reset token")
+		return
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`, passwordHash, now, userID); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update password")
+		return
+	}
+
+	// Sign out every existing session for the account
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked = 1 WHERE user_id = ?`, userID); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to revoke sessions")
+		return
+	}
+	if _, err := tx.Exec(`DELETE FROM password_reset_tokens WHERE user_id = ? AND used_at IS NULL`, userID); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Database error")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update password")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+func (h *PasswordResetHandler) recentlyRequested(userID int64) bool {
+	if h.resendCooldown == 0 {
+		return false
+	}
+
+	var lastCreated time.Time
+	err := h.db.QueryRow(`
+		SELECT created_at FROM password_reset_tokens WHERE user_id = ? ORDER BY id DESC LIMIT 1
+	`, userID).Scan(&lastCreated)
+	if err != nil {
+		return false
+	}
+	return time.Since(lastCreated) < h.resendCooldown
+}
+
+func (h *PasswordResetHandler) cleanupExpired() {
+	if _, err := h.db.Exec(`DELETE FROM password_reset_tokens WHERE expires_at < ?`, time.Now().Add(-24*time.Hour)); err != nil {
+		logger.Error("failed to clean up expired tokens", "error", err)
+	}
+}
+
+func (h *PasswordResetHandler) buildResetEmail(username, token string) string {
+	link := buildResetLink(h.cfg.ResetURL, token)
+	return fmt.Sprintf(`Hello %s,
+
+A password reset was requested for your Hytale Server Manager account.
+Use the link below to choose a new password. It expires in %s and can only be used once.
+
+%s
+
+If you did not request this, you can ignore this email.
+`, username, h.tokenDuration.String(), link)
+}
+
+func buildResetLink(baseURL, token string) string {
+	parsed, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || baseURL == "" {
+		return token
+	}
+	query := parsed.Query()
+	query.Set("token", token)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
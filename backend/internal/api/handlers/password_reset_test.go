@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/notify"
+)
+
+func TestRequestPasswordResetTakesTheSameTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := db.DB.Exec(`INSERT INTO users (username, email, password_hash) VALUES ('alice', 'alice@example.com', 'hash')`); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Nothing listens on the relay, so the emails are dropped
+	mailer := notify.NewSMTPSender(config.SMTPConfig{Enabled: true, Host: "127.0.0.1", Port: 1, From: "hsm@example.com"})
+	handler := NewPasswordResetHandler(db.DB, config.PasswordResetConfig{Enabled: true, ResendCooldown: "2m"}, mailer, auth.PasswordHasher{})
+
+	request := func(email string) (int, string, time.Duration) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/password/forgot", strings.NewReader(`{"email":"`+email+`"}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		started := time.Now()
+		handler.RequestPasswordReset(c)
+		return w.Code, w.Body.String(), time.Since(started)
+	}
+
+	for _, email := range []string{"nobody@example.com", "alice@example.com", "alice@example.com"} {
+		code, body, took := request(email)
+		if code != http.StatusAccepted || !strings.Contains(body, passwordResetGenericMessage) {
+			t.Fatalf("expected the generic answer for %s, got %d %s", email, code, body)
+		}
+		if took < passwordResetResponseTime {
+			t.Fatalf("expected %s to take at least %v, took %v", email, passwordResetResponseTime, took)
+		}
+	}
+
+	var tokens int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM password_reset_tokens`).Scan(&tokens); err != nil {
+		t.Fatalf("count tokens: %v", err)
+	}
+	if tokens != 1 {
+		t.Fatalf("expected one token for the known address, got %d", tokens)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/files"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/playerlists"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/sftp"
+)
+
+// maxPlayerListSize bounds the whitelist and ban list files read from a server
+const maxPlayerListSize = 4 << 20
+
+// Ways an edit of a player list is applied
+const (
+	appliedViaFile    = "file"
+	appliedViaConsole = "console"
+)
+
+type whitelistRequest struct {
+	Enabled  bool     `json:"enabled"`
+	List     []string `json:"list"`
+	Checksum string   `json:"checksum"`
+}
+
+type bansRequest struct {
+	Bans     []playerlists.Ban `json:"bans"`
+	Checksum string            `json:"checksum"`
+}
+
+// playerListFile is a whitelist or ban list file as read from a server
+type playerListFile struct {
+	path     string
+	data     []byte
+	checksum string
+}
+
+// GetWhitelist returns a server's whitelist.json with its checksum
+func (h *ServerHandler) GetWhitelist(c *gin.Context) {
+	client, file, ok := h.loadPlayerList(c, playerlists.WhitelistFile)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	whitelist, err := playerlists.ParseWhitelist(file.data)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"whitelist": whitelist, "checksum": file.checksum, "path": file.path})
+}
+
+// UpdateWhitelist replaces a server's whitelist. The file is rewritten while
+// the server is stopped; while it runs, the change is made with console
+// commands and the server writes the file itself.
+func (h *ServerHandler) UpdateWhitelist(c *gin.Context) {
+	var req whitelistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	desired := &playerlists.Whitelist{Enabled: req.Enabled, List: req.List}
+	if err := desired.Normalize(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+
+	client, file, ok := h.loadPlayerList(c, playerlists.WhitelistFile)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	if !checkPlayerListChecksum(c, file, req.Checksum) {
+		return
+	}
+
+	h.applyPlayerList(c, client, file, desired, func() ([]string, error) {
+		current, err := playerlists.ParseWhitelist(file.data)
+		if err != nil {
+			return nil, err
+		}
+		return playerlists.WhitelistCommands(current, desired), nil
+	})
+}
+
+// GetBans returns a server's bans.json with its checksum
+func (h *ServerHandler) GetBans(c *gin.Context) {
+	client, file, ok := h.loadPlayerList(c, playerlists.BansFile)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	bans, err := playerlists.ParseBans(file.data)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bans": bans, "checksum": file.checksum, "path": file.path})
+}
+
+// UpdateBans replaces a server's ban list, through the file while the server
+// is stopped and with ban and unban commands while it runs
+func (h *ServerHandler) UpdateBans(c *gin.Context) {
+	var req bansRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	desired := req.Bans
+	if desired == nil {
+		desired = []playerlists.Ban{}
+	}
+	if err := playerlists.NormalizeBans(desired); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+
+	client, file, ok := h.loadPlayerList(c, playerlists.BansFile)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	if !checkPlayerListChecksum(c, file, req.Checksum) {
+		return
+	}
+
+	// New bans are dated now, as the server does
+	current, parseErr := playerlists.ParseBans(file.data)
+	now := time.Now().UnixMilli()
+	for i := range desired {
+		if desired[i].Timestamp == 0 {
+			desired[i].Timestamp = now
+		}
+	}
+
+	h.applyPlayerList(c, client, file, desired, func() ([]string, error) {
+		if parseErr != nil {
+			return nil, parseErr
+		}
+		return playerlists.BanCommands(current, desired), nil
+	})
+}
+
+// applyPlayerList writes a player list's new content, or sends the commands
+// making the change when the server runs
+func (h *ServerHandler) applyPlayerList(c *gin.Context, client *sftp.Client, file *playerListFile, desired any, commands func() ([]string, error)) {
+	serverID := c.Param("id")
+	name := path.Base(file.path)
+
+	if h.serverRunning(serverID) {
+		pending, err := commands()
+		if err != nil {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict,
+				fmt.Sprintf("Cannot change %s while the server runs: %v", name, err))
+			return
+		}
+		userID := getUserIDFromContext(c)
+		sent := make([]string, 0, len(pending))
+		for _, command := range pending {
+			if err := h.processManager.SendCommand(serverID, server.SafeSessionName(serverID), command); err != nil {
+				h.activityLogger.LogCommandExecute(serverID, userID, command, false, "", err.Error())
+				apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed,
+					fmt.Sprintf("Failed to send %q", command), gin.H{"sent": sent})
+				return
+			}
+			h.activityLogger.LogCommandExecute(serverID, userID, command, true, "", "")
+			sent = append(sent, command)
+		}
+		c.JSON(http.StatusOK, gin.H{"applied_via": appliedViaConsole, "commands": sent, "path": file.path})
+		return
+	}
+
+	data, err := playerlists.Encode(desired)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, err.Error())
+		return
+	}
+	if err := files.WriteAtomic(client, file.path, bytes.NewReader(data)); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to write player list", "server_id", serverID, "path", file.path, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Failed to write %s: %v", name, err))
+		return
+	}
+	h.activityLogger.LogActivity(&logging.Activity{
+		ServerID:     serverID,
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivityConfigUpdate,
+		Description:  fmt.Sprintf("Updated %s", name),
+		Metadata:     map[string]interface{}{"path": file.path},
+		Success:      true,
+	})
+	c.JSON(http.StatusOK, gin.H{"applied_via": appliedViaFile, "checksum": playerlists.Checksum(data), "path": file.path})
+}
+
+// loadPlayerList opens SFTP to a server and reads one of its player list
+// files, responding with the error when that fails. The caller closes the client.
+func (h *ServerHandler) loadPlayerList(c *gin.Context, name string) (*sftp.Client, *playerListFile, bool) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return nil, nil, false
+	}
+	_, conn, err := h.connectServer(serverID)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return nil, nil, false
+	}
+	client, err := conn.Client.NewSFTP()
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to open SFTP: %v", err))
+		return nil, nil, false
+	}
+
+	file := &playerListFile{}
+	file.path, err = remoteHomePath(client, path.Join(serverDef.Server.WorkingDirectory, name))
+	if err == nil {
+		file.data, err = readRemoteFile(client, file.path, maxPlayerListSize)
+	}
+	if err != nil {
+		client.Close()
+		logger.ErrorContext(c.Request.Context(), "Failed to read player list", "server_id", serverID, "file", name, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to read %s: %v", name, err))
+		return nil, nil, false
+	}
+	file.checksum = playerlists.Checksum(file.data)
+	return client, file, true
+}
+
+// checkPlayerListChecksum refuses an edit made against an older version of the file
+func checkPlayerListChecksum(c *gin.Context, file *playerListFile, checksum string) bool {
+	if checksum == "" || checksum == file.checksum {
+		return true
+	}
+	apierror.RespondDetails(c, http.StatusConflict, apierror.CodeVersionConflict,
+		fmt.Sprintf("%s was changed since it was read; reload and try again", path.Base(file.path)),
+		gin.H{"current_checksum": file.checksum})
+	return false
+}
+
+// remoteHomePath resolves a leading ~ against the SFTP user's home directory
+func remoteHomePath(client *sftp.Client, p string) (string, error) {
+	if p != "~" && !strings.HasPrefix(p, "~/") {
+		return p, nil
+	}
+	home, err := client.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, strings.TrimPrefix(p, "~")), nil
+}
+
+// readRemoteFile reads a file of at most limit bytes; a missing file is empty
+func readRemoteFile(client *sftp.Client, p string, limit int64) ([]byte, error) {
+	f, err := client.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("file is larger than %d bytes", limit)
+	}
+	return data, nil
+}
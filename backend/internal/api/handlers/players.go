@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+	"github.com/TheGojiOG/HytaleSM/internal/players"
+	"github.com/TheGojiOG/HytaleSM/internal/query"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/gin-gonic/gin"
+)
+
+// How long the console is watched for the answer to players.ListCommand,
+// and how often it is read meanwhile
+const (
+	playerListTimeout = 5 * time.Second
+	playerListPoll    = 250 * time.Millisecond
+	// playerListReadLimit bounds the console output read after the command
+	playerListReadLimit = 64 << 10
+)
+
+// Where a player list came from
+const (
+	playersFromQuery   = "query"
+	playersFromConsole = "console"
+)
+
+// errNoPlayerList is returned when the server did not print its player list in time
+var errNoPlayerList = errors.New("the server did not list its players in time")
+
+type playerActionRequest struct {
+	Player string `json:"player" binding:"required"` // name or UUID
+	Reason string `json:"reason"`
+}
+
+// ListPlayers returns the players online on a server. A server with a query
+// port is asked there; otherwise the list is requested on the console and
+// read back from the console log.
+func (h *ServerHandler) ListPlayers(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	if serverDef.Query.QueryPort > 0 {
+		if status := h.probeGame(serverDef)(); status != nil && status.Source == query.SourceQuery {
+			list := make([]players.Player, 0, len(status.PlayerNames))
+			for _, name := range status.PlayerNames {
+				list = append(list, players.Player{Name: name})
+			}
+			c.JSON(http.StatusOK, gin.H{"players": list, "count": status.Players, "max_players": status.MaxPlayers, "source": playersFromQuery})
+			return
+		}
+	}
+
+	if !h.serverRunning(serverID) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Server is not running")
+		return
+	}
+	list, err := h.consolePlayers(c.Request.Context(), serverID)
+	if errors.Is(err, errNoPlayerList) {
+		apierror.Respond(c, http.StatusGatewayTimeout, apierror.CodeUpstreamFailed, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list players", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"players": list, "count": len(list), "source": playersFromConsole})
+}
+
+// consolePlayers sends players.ListCommand and waits for the list to show
+// up in the console log
+func (h *ServerHandler) consolePlayers(ctx context.Context, serverID string) ([]players.Player, error) {
+	_, offset, err := h.ReadConsole(ctx, serverID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.processManager.SendCommand(serverID, server.SafeSessionName(serverID), players.ListCommand); err != nil {
+		return nil, fmt.Errorf("failed to send %q: %w", players.ListCommand, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, playerListTimeout)
+	defer cancel()
+	ticker := time.NewTicker(playerListPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errNoPlayerList
+		case <-ticker.C:
+		}
+		output, _, err := h.ReadConsole(ctx, serverID, offset, playerListReadLimit)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errNoPlayerList
+			}
+			return nil, err
+		}
+		if list, found := players.ParseList(output); found {
+			return list, nil
+		}
+	}
+}
+
+// KickPlayer disconnects a player from a running server
+func (h *ServerHandler) KickPlayer(c *gin.Context) {
+	h.playerAction(c, logging.ActivityPlayerKick, "Kicked %s", players.KickCommand)
+}
+
+// BanPlayer bans a player for good through the console of a running server
+func (h *ServerHandler) BanPlayer(c *gin.Context) {
+	h.playerAction(c, logging.ActivityPlayerBan, "Banned %s", players.BanCommand)
+}
+
+// UnbanPlayer lifts a player's ban through the console of a running server
+func (h *ServerHandler) UnbanPlayer(c *gin.Context) {
+	h.playerAction(c, logging.ActivityPlayerUnban, "Unbanned %s", func(target, _ string) string {
+		return players.UnbanCommand(target)
+	})
+}
+
+// WhitelistAddPlayer adds a player to the whitelist of a running server
+func (h *ServerHandler) WhitelistAddPlayer(c *gin.Context) {
+	h.playerAction(c, logging.ActivityPlayerWhitelist, "Added %s to the whitelist", func(target, _ string) string {
+		return players.WhitelistAddCommand(target)
+	})
+}
+
+// WhitelistRemovePlayer takes a player off the whitelist of a running server
+func (h *ServerHandler) WhitelistRemovePlayer(c *gin.Context) {
+	h.playerAction(c, logging.ActivityPlayerWhitelist, "Removed %s from the whitelist", func(target, _ string) string {
+		return players.WhitelistRemoveCommand(target)
+	})
+}
+
+// playerAction sends the console command command builds for the requested
+// player and records it in the activity log, described with description.
+// Lists of a stopped server are edited through their files instead, with PUT
+// whitelist and PUT bans.
+func (h *ServerHandler) playerAction(c *gin.Context, activityType, description string, command func(target, reason string) string) {
+	serverID := c.Param("id")
+	var req playerActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	target, err := players.Target(req.Player)
+	if err == nil {
+		req.Reason, err = players.Reason(req.Reason)
+	}
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	if !h.serverRunning(serverID) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict,
+			"Server is not running; edit the whitelist or bans file instead")
+		return
+	}
+
+	line := command(target, req.Reason)
+	err = h.processManager.SendCommand(serverID, server.SafeSessionName(serverID), line)
+	activity := &logging.Activity{
+		ServerID:     serverID,
+		UserID:       getUserIDFromContext(c),
+		ActivityType: activityType,
+		Description:  fmt.Sprintf(description, target),
+		Metadata:     map[string]interface{}{"player": target, "command": line},
+		Success:      err == nil,
+	}
+	if req.Reason != "" {
+		activity.Metadata["reason"] = req.Reason
+	}
+	if err != nil {
+		activity.ErrorMessage = err.Error()
+	}
+	_ = h.activityLogger.LogActivity(activity)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to send player command", "server_id", serverID, "command", line, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to send %q", line))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"player": target, "command": line})
+}
+
+// serverRunning checks a server's status now and reports whether it runs
+func (h *ServerHandler) serverRunning(serverID string) bool {
+	snapshot, ok := h.statusRefresher.CheckNow(serverID)
+	return ok && snapshot.Health.ConnectionStatus == models.StatusRunning
+}
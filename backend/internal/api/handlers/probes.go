@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+)
+
+const defaultProbeTimeout = 2 * time.Second
+
+// ProbeHandler serves the liveness and readiness endpoints used by container
+// orchestrators and monitoring. Neither requires authentication.
+type ProbeHandler struct {
+	db        *database.DB
+	pool      *ssh.ConnectionPool
+	configDir string
+	checkSSH  bool
+	timeout   time.Duration
+	started   time.Time
+}
+
+// NewProbeHandler creates a new probe handler. The SSH pool is only checked
+// when checkSSH is set.
+func NewProbeHandler(db *database.DB, pool *ssh.ConnectionPool, configDir string, checkSSH bool, timeout time.Duration) *ProbeHandler {
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	return &ProbeHandler{
+		db:        db,
+		pool:      pool,
+		configDir: configDir,
+		checkSSH:  checkSSH,
+		timeout:   timeout,
+		started:   time.Now(),
+	}
+}
+
+// componentStatus is the result of one readiness check
+type componentStatus struct {
+	Status     string      `json:"status"` // ok or fail
+	Error      string      `json:"error,omitempty"`
+	DurationMS int64       `json:"duration_ms"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+type probeCheck struct {
+	name string
+	run  func(ctx context.Context) (interface{}, error)
+}
+
+// Livez reports that the process is up and serving requests
+func (h *ProbeHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":         "ok",
+		"uptime_seconds": int64(time.Since(h.started).Seconds()),
+	})
+}
+
+// Readyz checks the database, migrations, config directory and SSH pool
+// (when enabled), and returns 503 if any of them fails
+func (h *ProbeHandler) Readyz(c *gin.Context) {
+	checks := []probeCheck{
+		{"database", h.checkDatabase},
+		{"migrations", h.checkMigrations},
+		{"config_dir", h.checkConfigDir},
+	}
+	if h.checkSSH && h.pool != nil {
+		checks = append(checks, probeCheck{"ssh_pool", h.checkSSHPool})
+	}
+
+	var mu sync.Mutex
+	components := make(map[string]componentStatus, len(checks))
+	fanOut(c.Request.Context(), checks, len(checks), h.timeout, func(ctx context.Context, check probeCheck) {
+		result := runProbeCheck(ctx, check.run)
+		mu.Lock()
+		components[check.name] = result
+		mu.Unlock()
+	})
+
+	status, code := "ok", http.StatusOK
+	for _, check := range checks {
+		result, ok := components[check.name]
+		if !ok {
+			// the request ended before the check started
+			result = componentStatus{Status: "fail", Error: "not run"}
+			components[check.name] = result
+		}
+		if result.Status == "fail" {
+			status, code = "fail", http.StatusServiceUnavailable
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "components": components})
+}
+
+// runProbeCheck runs fn and gives up when ctx ends, since not every check
+// can be cancelled
+func runProbeCheck(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) componentStatus {
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	start := time.Now()
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := fn(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result.err = errors.New("timed out")
+	}
+
+	status := componentStatus{Status: "ok", DurationMS: time.Since(start).Milliseconds(), Details: result.details}
+	if result.err != nil {
+		status.Status = "fail"
+		status.Error = result.err.Error()
+	}
+	return status
+}
+
+func (h *ProbeHandler) checkDatabase(ctx context.Context) (interface{}, error) {
+	return gin.H{"driver": string(h.db.Dialect)}, h.db.PingContext(ctx)
+}
+
+func (h *ProbeHandler) checkMigrations(ctx context.Context) (interface{}, error) {
+	states, err := h.db.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	applied, pending, modified := 0, 0, 0
+	for _, state := range states {
+		switch {
+		case !state.Applied:
+			pending++
+		case state.Modified:
+			modified++
+			applied++
+		default:
+			applied++
+		}
+	}
+	details := gin.H{"applied": applied, "pending": pending, "modified": modified}
+	if pending > 0 {
+		return details, fmt.Errorf("%d migrations pending", pending)
+	}
+	if modified > 0 {
+		return details, fmt.Errorf("%d applied migrations were modified", modified)
+	}
+	return details, nil
+}
+
+func (h *ProbeHandler) checkConfigDir(ctx context.Context) (interface{}, error) {
+	file, err := os.CreateTemp(h.configDir, ".readyz-*")
+	if err != nil {
+		return nil, fmt.Errorf("config directory is not writable: %w", err)
+	}
+	name := file.Name()
+	file.Close()
+	return nil, os.Remove(name)
+}
+
+func (h *ProbeHandler) checkSSHPool(ctx context.Context) (interface{}, error) {
+	stats := h.pool.GetStats()
+	if failed, _ := stats["failed"].(int); failed > 0 {
+		return stats, fmt.Errorf("%d SSH connections failing", failed)
+	}
+	return stats, nil
+}
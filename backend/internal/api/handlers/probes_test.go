@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestReadyzReportsComponents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	db, err := database.NewDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	readyz := func(configDir string) (int, map[string]componentStatus) {
+		router := gin.New()
+		router.GET("/readyz", NewProbeHandler(db, nil, configDir, true, 0).Readyz)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body struct {
+			Components map[string]componentStatus `json:"components"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return w.Code, body.Components
+	}
+
+	code, components := readyz(dir)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %+v", code, components)
+	}
+	for _, name := range []string{"database", "migrations", "config_dir"} {
+		if components[name].Status != "ok" {
+			t.Fatalf("expected %s to be ok, got %+v", name, components[name])
+		}
+	}
+	if _, ok := components["ssh_pool"]; ok {
+		t.Fatalf("expected ssh_pool check to be skipped without a pool")
+	}
+
+	code, components = readyz(filepath.Join(dir, "missing"))
+	if code != http.StatusServiceUnavailable || components["config_dir"].Status != "fail" {
+		t.Fatalf("expected unwritable config dir to fail readiness, got %d: %+v", code, components["config_dir"])
+	}
+}
@@ -2,9 +2,13 @@ package handlers
 
 import (
 	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -13,7 +17,9 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
 	"github.com/TheGojiOG/HytaleSM/internal/logging"
@@ -53,11 +59,14 @@ type DownloaderAuthStatusResponse struct {
 
 const downloaderZipURL = "https://downloader.hytale.com/hytale-downloader.zip"
 
-func NewReleaseHandler(cfg *config.Config, db *database.DB, logger *logging.ActivityLogger, hub *ws.Hub) *ReleaseHandler {
+// maxReleaseUploadSize bounds an uploaded server package, assets included
+const maxReleaseUploadSize = 8 << 30
+
+func NewReleaseHandler(cfg *config.Config, db *database.DB, activityLogger *logging.ActivityLogger, hub *ws.Hub) *ReleaseHandler {
 	h := &ReleaseHandler{
 		cfg:            cfg,
 		manager:        releases.NewManager(cfg, db),
-		activityLogger: logger,
+		activityLogger: activityLogger,
 		hub:            hub,
 	}
 
@@ -75,19 +84,42 @@ func NewReleaseHandler(cfg *config.Config, db *database.DB, logger *logging.Acti
 	return h
 }
 
+// releaseJobEvent carries a job event to the other manager instances
+type releaseJobEvent struct {
+	JobID string               `json:"job_id"`
+	Event releases.StreamEvent `json:"event"`
+}
+
+// SetCluster lets every manager instance of a cluster list the release jobs
+// and stream their output, whichever instance runs them
+func (h *ReleaseHandler) SetCluster(node *cluster.Node) {
+	if !node.Clustered() {
+		return
+	}
+	h.manager.Share(func(jobID string, event releases.StreamEvent) {
+		node.Publish("release.job", releaseJobEvent{JobID: jobID, Event: event})
+	})
+	node.Subscribe("release.job", func(data json.RawMessage) {
+		var relayed releaseJobEvent
+		if err := json.Unmarshal(data, &relayed); err == nil {
+			h.manager.Deliver(relayed.JobID, relayed.Event)
+		}
+	})
+}
+
 // HandleReleaseJobWebSocket streams release job output via WebSocket
 // WS /ws/releases/jobs/:id
 func (h *ReleaseHandler) HandleReleaseJobWebSocket(c *gin.Context) {
 	jobID := c.Param("id")
 	job, ok := h.manager.GetJob(jobID)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Job not found")
 		return
 	}
 
 	userClaims, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 	claims := userClaims.(*auth.Claims)
@@ -144,10 +176,9 @@ func (h *ReleaseHandler) HandleReleaseJobWebSocket(c *gin.Context) {
 }
 
 func (h *ReleaseHandler) ListReleases(c *gin.Context) {
-	limitParam := c.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitParam)
-	if err != nil || limit <= 0 || limit > 500 {
-		limit = 50
+	page, ok := parsePage(c, releasePages)
+	if !ok {
+		return
 	}
 
 	includeRemoved := false
@@ -156,25 +187,25 @@ func (h *ReleaseHandler) ListReleases(c *gin.Context) {
 		includeRemoved = true
 	}
 
-	items, err := h.manager.ListReleases(limit, includeRemoved)
+	items, info, err := h.manager.ListReleases(includeRemoved, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load releases"})
+		respondPageError(c, err, "Failed to load releases")
 		return
 	}
 
-	c.JSON(http.StatusOK, ReleaseListResponse{Releases: items})
+	respondPage(c, items, info, ReleaseListResponse{Releases: items})
 }
 
 func (h *ReleaseHandler) GetRelease(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid release id"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid release id")
 		return
 	}
 
 	release, err := h.manager.GetRelease(id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Release not found")
 		return
 	}
 
@@ -184,25 +215,25 @@ func (h *ReleaseHandler) GetRelease(c *gin.Context) {
 func (h *ReleaseHandler) DeleteRelease(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid release id"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid release id")
 		return
 	}
 
 	release, err := h.manager.GetRelease(id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Release not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Release not found")
 		return
 	}
 
 	if release.FilePath != "" {
 		if err := os.Remove(release.FilePath); err != nil && !os.IsNotExist(err) {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete release file", "details": err.Error()})
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete release file", err.Error())
 			return
 		}
 	}
 
 	if err := h.manager.DeleteRelease(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete release"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete release")
 		return
 	}
 
@@ -236,7 +267,7 @@ func (h *ReleaseHandler) GetJob(c *gin.Context) {
 	jobID := c.Param("id")
 	job, ok := h.manager.GetJob(jobID)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Job not found")
 		return
 	}
 	c.JSON(http.StatusOK, ReleaseJobResponse{Job: job})
@@ -245,7 +276,7 @@ func (h *ReleaseHandler) GetJob(c *gin.Context) {
 func (h *ReleaseHandler) DownloadRelease(c *gin.Context) {
 	var req ReleaseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -373,10 +404,156 @@ func (h *ReleaseHandler) DownloadRelease(c *gin.Context) {
 	c.JSON(http.StatusAccepted, ReleaseJobResponse{Job: job})
 }
 
+// UploadRelease stores a server package uploaded as the multipart "file"
+// field next to the downloaded ones, once it is checked to be a zip with
+// Server/HytaleServer.jar. version defaults to the file name without .zip and
+// patchline to "manual"; a release that exists is only replaced with
+// overwrite=true.
+// POST /api/v1/releases/upload
+func (h *ReleaseHandler) UploadRelease(c *gin.Context) {
+	if h.cfg == nil || strings.TrimSpace(h.cfg.Storage.ReleasesDir) == "" {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Releases directory not configured")
+		return
+	}
+	overwrite := c.Query("overwrite") == "true"
+	patchline := strings.TrimSpace(c.Query("patchline"))
+	if patchline == "" {
+		patchline = "manual"
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxReleaseUploadSize)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "expected a multipart/form-data upload")
+		return
+	}
+	var part *multipart.Part
+	for {
+		part, err = reader.NextPart()
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest,
+				fmt.Sprintf("expected a %q field with the file", uploadField))
+			return
+		}
+		if part.FormName() == uploadField {
+			break
+		}
+		part.Close()
+	}
+	if !strings.HasSuffix(strings.ToLower(part.FileName()), ".zip") {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, "the server package must be a .zip file")
+		return
+	}
+	name := sanitizeFilename(filepath.Base(strings.ReplaceAll(part.FileName(), `\`, "/")))
+	version := strings.TrimSpace(c.Query("version"))
+	if version == "" {
+		version = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	officialDir := filepath.Join(h.cfg.Storage.ReleasesDir, "official_server_files")
+	finalPath := filepath.Join(officialDir, name)
+	existing, err := h.manager.GetReleaseByVersionPatchline(version, patchline)
+	if err != nil {
+		existing = nil
+	}
+	if !overwrite {
+		if existing != nil && !existing.Removed {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict,
+				fmt.Sprintf("release %s (%s) already exists; upload with overwrite=true to replace it", version, patchline))
+			return
+		}
+		if _, err := os.Stat(finalPath); err == nil {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict,
+				fmt.Sprintf("%s already exists; upload with overwrite=true to replace it", name))
+			return
+		}
+	}
+
+	if err := os.MkdirAll(officialDir, 0755); err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create releases directory", err.Error())
+		return
+	}
+	// Written next to the releases under a name the disk sync skips, then
+	// moved into place once it is checked
+	tmp, err := os.CreateTemp(officialDir, ".upload-*.part")
+	if err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store upload", err.Error())
+		return
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), part)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apierror.Respond(c, http.StatusRequestEntityTooLarge, apierror.CodeValidationFailed,
+			fmt.Sprintf("upload is larger than %d bytes", maxReleaseUploadSize))
+		return
+	}
+	if err != nil {
+		apierror.RespondDetails(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Failed to receive upload", err.Error())
+		return
+	}
+	if err := releases.ValidatePackage(tmpPath); err != nil {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store upload", err.Error())
+		return
+	}
+
+	release := &releases.Release{
+		Version:      version,
+		Patchline:    patchline,
+		FilePath:     finalPath,
+		FileSize:     size,
+		SHA256:       hex.EncodeToString(hasher.Sum(nil)),
+		DownloadedAt: time.Now().UTC(),
+		Status:       "ready",
+		Source:       "uploaded",
+	}
+	if existing != nil {
+		release.ID = existing.ID
+		if existing.FilePath != "" && filepath.Clean(existing.FilePath) != finalPath {
+			if err := os.Remove(existing.FilePath); err != nil && !os.IsNotExist(err) {
+				logger.WarnContext(c.Request.Context(), "Failed to remove replaced release file", "path", existing.FilePath, "error", err)
+			}
+		}
+		err = h.manager.UpdateRelease(release)
+	} else {
+		err = h.manager.InsertRelease(release)
+	}
+	if err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to record release", err.Error())
+		return
+	}
+
+	_ = h.activityLogger.LogActivity(&logging.Activity{
+		ServerID:     "",
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivityConfigUpdate,
+		Description:  "Release uploaded",
+		Metadata: map[string]interface{}{
+			"version":   version,
+			"patchline": patchline,
+			"path":      finalPath,
+			"sha256":    release.SHA256,
+			"size":      size,
+		},
+		Success: true,
+	})
+	c.JSON(http.StatusCreated, release)
+}
+
 func (h *ReleaseHandler) PrintVersion(c *gin.Context) {
 	var req ReleaseRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -467,7 +644,7 @@ func (h *ReleaseHandler) DownloaderAuthStatus(c *gin.Context) {
 			c.JSON(http.StatusOK, DownloaderAuthStatusResponse{Exists: false})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read auth status"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read auth status")
 		return
 	}
 
@@ -504,7 +681,7 @@ func (h *ReleaseHandler) DownloaderAuthStatus(c *gin.Context) {
 func (h *ReleaseHandler) ResetDownloaderAuth(c *gin.Context) {
 	path := h.manager.CredentialsPath()
 	if err := deleteIfExists(path); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset credentials", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to reset credentials", err.Error())
 		return
 	}
 	_ = h.activityLogger.LogActivity(&logging.Activity{
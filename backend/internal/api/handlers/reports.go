@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+)
+
+// ReportsHandler serves per-server usage reports, e.g. for billing
+type ReportsHandler struct {
+	db            *sql.DB
+	serverManager *config.ServerManager
+}
+
+// serverUsage is one server's line in a usage report
+type serverUsage struct {
+	Name string `json:"name"`
+	metrics.Usage
+}
+
+// NewReportsHandler creates a new reports handler
+func NewReportsHandler(db *sql.DB, serverManager *config.ServerManager) *ReportsHandler {
+	return &ReportsHandler{db: db, serverManager: serverManager}
+}
+
+// GetUsage reports each server's CPU-hours, memory, disk and backup storage over a period.
+// The period is ?period= (24h, 7d, 30d, month or last_month; default 30d) or
+// ?from= and ?to= as dates or RFC 3339 times. ?server_id= narrows the report
+// and ?format=csv returns it as CSV.
+func (h *ReportsHandler) GetUsage(c *gin.Context) {
+	now := time.Now().UTC()
+	from, to, err := reportPeriod(c, now)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	report, err := metrics.UsageReport(c.Request.Context(), h.db, from, to, now)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to build usage report", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to build usage report")
+		return
+	}
+
+	// Every current server gets a line, even without usage; servers that
+	// have since been deleted keep theirs
+	names := make(map[string]string)
+	for _, server := range h.serverManager.GetAll() {
+		names[server.ID] = server.Name
+		if _, ok := report[server.ID]; !ok {
+			report[server.ID] = &metrics.Usage{ServerID: server.ID}
+		}
+	}
+	wanted := make(map[string]bool)
+	for _, id := range c.QueryArray("server_id") {
+		wanted[id] = true
+	}
+	servers := make([]serverUsage, 0, len(report))
+	for id, usage := range report {
+		if len(wanted) > 0 && !wanted[id] {
+			continue
+		}
+		servers = append(servers, serverUsage{Name: names[id], Usage: *usage})
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ServerID < servers[j].ServerID })
+
+	if c.Query("format") == "csv" {
+		writeUsageCSV(c, from, to, servers)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "servers": servers})
+}
+
+// reportPeriod reads the period of a report from the query
+func reportPeriod(c *gin.Context, now time.Time) (time.Time, time.Time, error) {
+	fromParam, toParam := c.Query("from"), c.Query("to")
+	if fromParam != "" || toParam != "" {
+		if fromParam == "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("from is required with to")
+		}
+		from, _, err := parseReportTime(fromParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to := now
+		if toParam != "" {
+			var isDate bool
+			if to, isDate, err = parseReportTime(toParam); err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+			// A date includes that whole day
+			if isDate {
+				to = to.AddDate(0, 0, 1)
+			}
+		}
+		if !to.After(from) {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be after from")
+		}
+		return from, to, nil
+	}
+
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	switch period := c.DefaultQuery("period", "30d"); period {
+	case "24h":
+		return now.Add(-24 * time.Hour), now, nil
+	case "7d":
+		return now.AddDate(0, 0, -7), now, nil
+	case "30d":
+		return now.AddDate(0, 0, -30), now, nil
+	case "month":
+		return startOfMonth, now, nil
+	case "last_month":
+		return startOfMonth.AddDate(0, -1, 0), startOfMonth, nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q (use 24h, 7d, 30d, month or last_month)", period)
+	}
+}
+
+// parseReportTime accepts a date (taken as UTC midnight) or an RFC 3339 time
+func parseReportTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time %q (use YYYY-MM-DD or RFC 3339)", value)
+	}
+	return t.UTC(), false, nil
+}
+
+func writeUsageCSV(c *gin.Context, from, to time.Time, servers []serverUsage) {
+	rows := make([][]string, 0, len(servers))
+	for _, s := range servers {
+		rows = append(rows, []string{
+			s.ServerID,
+			s.Name,
+			from.Format(time.RFC3339),
+			to.Format(time.RFC3339),
+			strconv.FormatFloat(s.MonitoredHours, 'f', 2, 64),
+			strconv.FormatFloat(s.CPUHours, 'f', 3, 64),
+			strconv.FormatFloat(s.AvgCPUPercent, 'f', 2, 64),
+			strconv.FormatInt(s.AvgMemoryBytes, 10),
+			strconv.FormatInt(s.PeakMemoryBytes, 10),
+			strconv.FormatInt(s.AvgDiskBytes, 10),
+			strconv.FormatInt(s.PeakDiskBytes, 10),
+			strconv.Itoa(s.BackupCount),
+			strconv.FormatInt(s.BackupBytes, 10),
+			strconv.FormatInt(s.BackupBytesCreated, 10),
+		})
+	}
+	writeCSV(c, fmt.Sprintf("usage-%s-%s.csv", from.Format("20060102"), to.Format("20060102")), []string{
+		"server_id", "name", "from", "to", "monitored_hours", "cpu_hours", "avg_cpu_percent",
+		"avg_memory_bytes", "peak_memory_bytes", "avg_disk_bytes", "peak_disk_bytes",
+		"backup_count", "backup_bytes", "backup_bytes_created",
+	}, rows)
+}
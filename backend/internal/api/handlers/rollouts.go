@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+)
+
+const (
+	defaultRolloutStartupMinutes = 5
+	defaultRolloutStableMinutes  = 5
+	// maxRolloutMinutes bounds each of the health check's waits
+	maxRolloutMinutes = 120
+	// rolloutHealthInterval is how often a rollout checks a server of a wave
+	rolloutHealthInterval = 15 * time.Second
+)
+
+// defaultRolloutWaves deploys to one server, then a quarter, then the rest
+var defaultRolloutWaves = []string{"1", "25%", "100%"}
+
+// RolloutHealthCheck is what a rollout waits for on each server of a wave
+// before it starts the next: the process running and its game port answering
+// within StartupMinutes, then no crash or restart for StableMinutes
+type RolloutHealthCheck struct {
+	StartupMinutes int `json:"startup_minutes"`
+	StableMinutes  int `json:"stable_minutes"`
+}
+
+type rolloutGate struct {
+	startup  time.Duration
+	stable   time.Duration
+	interval time.Duration
+}
+
+func newRolloutGate(check *RolloutHealthCheck) (rolloutGate, error) {
+	gate := rolloutGate{
+		startup:  defaultRolloutStartupMinutes * time.Minute,
+		stable:   defaultRolloutStableMinutes * time.Minute,
+		interval: rolloutHealthInterval,
+	}
+	if check == nil {
+		return gate, nil
+	}
+	if check.StartupMinutes < 0 || check.StartupMinutes > maxRolloutMinutes || check.StableMinutes < 0 || check.StableMinutes > maxRolloutMinutes {
+		return gate, fmt.Errorf("health.startup_minutes and health.stable_minutes must be between 0 and %d", maxRolloutMinutes)
+	}
+	if check.StartupMinutes > 0 {
+		gate.startup = time.Duration(check.StartupMinutes) * time.Minute
+	}
+	if check.StableMinutes > 0 {
+		gate.stable = time.Duration(check.StableMinutes) * time.Minute
+	}
+	return gate, nil
+}
+
+// planWaves splits servers, in order, into rollout waves. Each wave is a
+// number of servers or a percentage of all of them, rounded up, counted from
+// the start: ["1", "50%"] of 10 servers is 1 server, then 4 more. The last
+// wave takes the servers that are left; waves that would be empty are dropped.
+func planWaves(servers []config.ServerDefinition, waves []string) ([][]config.ServerDefinition, error) {
+	if len(waves) == 0 {
+		waves = defaultRolloutWaves
+	}
+	var (
+		plan [][]config.ServerDefinition
+		done int
+	)
+	for i, wave := range waves {
+		wave = strings.TrimSpace(wave)
+		var target int
+		if percent, ok := strings.CutSuffix(wave, "%"); ok {
+			value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+			if err != nil || value <= 0 || value > 100 {
+				return nil, fmt.Errorf("wave %q must be a percentage between 0 and 100", wave)
+			}
+			target = int(math.Ceil(value * float64(len(servers)) / 100))
+		} else {
+			value, err := strconv.Atoi(wave)
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("wave %q must be a number of servers or a percentage", wave)
+			}
+			target = value
+		}
+		if i == len(waves)-1 || target > len(servers) {
+			target = len(servers)
+		}
+		if target <= done {
+			continue
+		}
+		plan = append(plan, servers[done:target])
+		done = target
+		if done == len(servers) {
+			break
+		}
+	}
+	return plan, nil
+}
+
+// rolloutHealthy reports whether a health check shows a server that is up:
+// its process running and its game port answering, when it was probed
+func rolloutHealthy(health HealthCheck) (bool, string) {
+	if !health.ProcessStatus.Running {
+		return false, "the server process is not running"
+	}
+	if health.Game != nil && !health.Game.Online {
+		reason := "the game port does not answer"
+		if health.Game.Error != "" {
+			reason += ": " + health.Game.Error
+		}
+		return false, reason
+	}
+	return true, ""
+}
+
+// waitHealthy checks a server every gate.interval until it has been up for
+// gate.stable. It fails when the server does not come up within
+// gate.startup, or goes down or restarts after it came up.
+func waitHealthy(ctx context.Context, gate rolloutGate, check func() (HealthCheck, bool), emit func(string)) error {
+	ticker := time.NewTicker(gate.interval)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(gate.startup)
+	var (
+		upSince time.Time
+		pid     int
+	)
+	for {
+		health, ok := check()
+		if !ok {
+			return errors.New("the server no longer exists")
+		}
+		up, reason := rolloutHealthy(health)
+		switch {
+		case up && upSince.IsZero():
+			upSince, pid = time.Now(), health.ProcessStatus.PID
+			emit(fmt.Sprintf("Server is up, watching it for %s...", gate.stable))
+		case up && pid != 0 && health.ProcessStatus.PID != 0 && health.ProcessStatus.PID != pid:
+			return fmt.Errorf("the server restarted while it was watched (pid %d, now %d)", pid, health.ProcessStatus.PID)
+		case !up && !upSince.IsZero():
+			return fmt.Errorf("the server went down after %s: %s", time.Since(upSince).Round(time.Second), reason)
+		case !up && time.Now().After(deadline):
+			return fmt.Errorf("the server did not come up within %s: %s", gate.startup, reason)
+		}
+		if !upSince.IsZero() && time.Since(upSince) >= gate.stable {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// runRollout deploys to the waves of a rollout one after another. A wave
+// with a failed server halts the rollout: later waves are skipped and, when
+// asked for, every server the rollout deployed to is rolled back.
+func (h *ServerHandler) runRollout(ctx context.Context, batchID string, waves [][]config.ServerDefinition, req BatchRequest, gate rolloutGate, configFiles map[string][]config.RenderedFile, userID *int64) {
+	defer h.finishBatch(batchID)
+
+	var (
+		mu       sync.Mutex
+		deployed []config.ServerDefinition
+	)
+	for i, wave := range waves {
+		h.updateBatchWave(batchID, i, taskStatusRunning)
+		failed := 0
+		fanOut(ctx, wave, req.Parallelism, batchServerTimeout+gate.startup+gate.stable, func(ctx context.Context, serverDef config.ServerDefinition) {
+			wasDeployed, err := h.runRolloutServer(ctx, batchID, serverDef, req, gate, configFiles[serverDef.ID], userID)
+			mu.Lock()
+			defer mu.Unlock()
+			if wasDeployed {
+				deployed = append(deployed, serverDef)
+			}
+			if err != nil {
+				failed++
+			}
+		})
+		if failed == 0 && ctx.Err() == nil {
+			h.updateBatchWave(batchID, i, taskStatusComplete)
+			continue
+		}
+
+		h.updateBatchWave(batchID, i, taskStatusFailed)
+		reason := fmt.Sprintf("wave %d failed on %d of %d servers", i+1, failed, len(wave))
+		if ctx.Err() != nil {
+			reason = "the manager is shutting down"
+		}
+		h.haltRollout(batchID, reason)
+		logger.Warn("Rollout halted", "batch_id", batchID, "wave", i+1, "reason", reason)
+		if req.RollbackOnFailure && ctx.Err() == nil {
+			h.rollbackRollout(ctx, batchID, deployed, userID)
+		}
+		return
+	}
+}
+
+// runRolloutServer deploys the release to one server of a wave, restarts it
+// and waits for it to stay healthy, as a task once the executor lets it. It
+// reports whether the release was deployed, also when the server then failed
+// its health check.
+func (h *ServerHandler) runRolloutServer(ctx context.Context, batchID string, serverDef config.ServerDefinition, req BatchRequest, gate rolloutGate, configFiles []config.RenderedFile, userID *int64) (bool, error) {
+	serverID := serverDef.ID
+	deployed := false
+	err := h.runQueuedTask(ctx, serverID, "batch-rollout", func(ctx context.Context, task *taskRecord) error {
+		h.updateBatchServer(batchID, serverID, func(server *batchServer) {
+			server.TaskID = task.ID
+			server.Status = taskStatusRunning
+			server.StartedAt = &task.StartedAt
+		})
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+
+		err := func() error {
+			if window, active := h.inMaintenance(serverID); active {
+				return errors.New(maintenanceError(window))
+			}
+			_, conn, err := h.connectServer(serverID)
+			if err != nil {
+				return err
+			}
+			if err := h.runReleaseDeploy(ctx, serverID, serverDef, conn, configFiles, *req.Deploy, userID, emit); err != nil {
+				return err
+			}
+			deployed = true
+
+			emit("Restarting server...")
+			h.resetWatchdog(serverID)
+			if err := h.lifecycleManager.RestartServer(serverID, h.createServerConfig(&serverDef), true); err != nil {
+				h.activityLogger.LogServerRestart(serverID, userID, true, false, err.Error())
+				return err
+			}
+			h.activityLogger.LogServerRestart(serverID, userID, true, true, "")
+			h.invalidateServer(serverID)
+
+			return waitHealthy(ctx, gate, func() (HealthCheck, bool) {
+				snapshot, ok := h.statusRefresher.CheckNow(serverID)
+				return snapshot.Health, ok
+			}, emit)
+		}()
+		if err != nil {
+			emit(fmt.Sprintf("Rollout failed: %v", err))
+		} else {
+			emit("Server is healthy.")
+		}
+		return err
+	})
+
+	h.updateBatchServer(batchID, serverID, func(server *batchServer) {
+		now := time.Now()
+		server.FinishedAt = &now
+		server.Status = taskStatusComplete
+		if err != nil {
+			server.Status = taskStatusFailed
+			server.Error = err.Error()
+		}
+	})
+	return deployed, err
+}
+
+// rollbackRollout rolls the servers a halted rollout deployed to back to
+// their previous release, each as a task of its own
+func (h *ServerHandler) rollbackRollout(ctx context.Context, batchID string, servers []config.ServerDefinition, userID *int64) {
+	manager := releases.NewManager(h.config, h.db)
+	fanOut(ctx, servers, maxBatchParallelism, batchServerTimeout, func(ctx context.Context, serverDef config.ServerDefinition) {
+		serverID := serverDef.ID
+		err := h.runQueuedTask(ctx, serverID, "release-rollback", func(ctx context.Context, task *taskRecord) error {
+			emit := func(line string) {
+				h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+			}
+			err := func() error {
+				active, previous, err := manager.RollbackTarget(serverID)
+				if err != nil {
+					return err
+				}
+				_, conn, err := h.connectServer(serverID)
+				if err != nil {
+					return err
+				}
+				return h.runReleaseRollback(ctx, serverDef, conn, manager, active, previous, userID, emit)
+			}()
+			if err != nil {
+				emit("Rollback failed: " + err.Error())
+			}
+			return err
+		})
+
+		h.updateBatchServer(batchID, serverID, func(server *batchServer) {
+			if err != nil {
+				server.Error = strings.TrimPrefix(server.Error+"; ", "; ") + "rollback failed: " + err.Error()
+				return
+			}
+			server.RolledBack = true
+		})
+	})
+}
+
+func (h *ServerHandler) updateBatchWave(batchID string, wave int, status taskStatus) {
+	h.batches.update(batchID, func(batch *batchRecord) {
+		if wave < len(batch.Waves) {
+			batch.Waves[wave].Status = status
+		}
+	}, h.publishBatch)
+}
+
+// haltRollout records why a rollout stopped and skips what it did not reach
+func (h *ServerHandler) haltRollout(batchID, reason string) {
+	h.batches.update(batchID, func(batch *batchRecord) {
+		batch.Halted = reason
+		for _, wave := range batch.Waves {
+			if wave.Status == taskStatusPending {
+				wave.Status = taskStatusSkipped
+			}
+		}
+		for _, server := range batch.Servers {
+			if server.Status == taskStatusPending {
+				server.Status = taskStatusSkipped
+			}
+		}
+	}, h.publishBatch)
+}
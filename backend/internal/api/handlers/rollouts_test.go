@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/query"
+)
+
+func TestPlanWaves(t *testing.T) {
+	servers := make([]config.ServerDefinition, 10)
+	for i := range servers {
+		servers[i].ID = fmt.Sprintf("s%d", i)
+	}
+	sizes := func(plan [][]config.ServerDefinition) string {
+		var out []string
+		for _, wave := range plan {
+			out = append(out, fmt.Sprint(len(wave)))
+		}
+		return strings.Join(out, ",")
+	}
+
+	for _, tc := range []struct {
+		waves []string
+		want  string
+	}{
+		{nil, "1,2,7"},
+		{[]string{"1", "50%"}, "1,9"},
+		{[]string{"2", "30%", "100%"}, "2,1,7"},
+		{[]string{"2", "2", "100%"}, "2,8"},
+		{[]string{"25%", "50%", "100%"}, "3,2,5"},
+		{[]string{"20"}, "10"},
+	} {
+		plan, err := planWaves(servers, tc.waves)
+		if err != nil || sizes(plan) != tc.want {
+			t.Errorf("%v: expected %s, got %s (%v)", tc.waves, tc.want, sizes(plan), err)
+		}
+	}
+	plan, _ := planWaves(servers, []string{"3", "100%"})
+	if plan[1][0].ID != "s3" {
+		t.Errorf("expected the second wave to start after the first, got %s", plan[1][0].ID)
+	}
+
+	for _, waves := range [][]string{{"0"}, {"abc"}, {"150%"}, {"-5%"}} {
+		if _, err := planWaves(servers, waves); err == nil {
+			t.Errorf("expected %v to be refused", waves)
+		}
+	}
+}
+
+func TestWaitHealthy(t *testing.T) {
+	gate := rolloutGate{startup: 40 * time.Millisecond, stable: 30 * time.Millisecond, interval: 5 * time.Millisecond}
+	up := func(pid int) HealthCheck {
+		return HealthCheck{ProcessStatus: ProcessHealthStatus{Running: true, PID: pid}, Game: &query.Status{Online: true}}
+	}
+	down := HealthCheck{}
+	// sequence answers the checks in order and then repeats the last answer
+	sequence := func(checks ...HealthCheck) func() (HealthCheck, bool) {
+		return func() (HealthCheck, bool) {
+			check := checks[0]
+			if len(checks) > 1 {
+				checks = checks[1:]
+			}
+			return check, true
+		}
+	}
+	noEmit := func(string) {}
+
+	if err := waitHealthy(context.Background(), gate, sequence(down, down, up(10)), noEmit); err != nil {
+		t.Fatalf("expected a server that came up to pass, got %v", err)
+	}
+	starting := up(10)
+	starting.Game = &query.Status{Online: false, Error: "timeout"}
+	if err := waitHealthy(context.Background(), gate, sequence(starting), noEmit); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected a server whose port never answered to fail, got %v", err)
+	}
+	if err := waitHealthy(context.Background(), gate, sequence(up(10), up(10), down), noEmit); err == nil || !strings.Contains(err.Error(), "went down") {
+		t.Fatalf("expected a crash to fail, got %v", err)
+	}
+	if err := waitHealthy(context.Background(), gate, sequence(up(10), up(11)), noEmit); err == nil || !strings.Contains(err.Error(), "restarted") {
+		t.Fatalf("expected a restart to fail, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitHealthy(ctx, gate, sequence(down), noEmit); err != context.Canceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+)
+
+const defaultScriptTimeout = 10 * time.Minute
+
+// ScheduleHandler serves the scheduled task API. Backup schedules appear
+// here as backup tasks; their backup settings stay with the server's
+// backup schedule endpoints.
+type ScheduleHandler struct {
+	store         *scheduler.Store
+	scheduler     *scheduler.Scheduler
+	backups       *backup.ScheduleStore
+	serverManager *config.ServerManager
+}
+
+type scheduleRequest struct {
+	Name          string          `json:"name"`
+	ServerID      string          `json:"server_id"`
+	Type          string          `json:"type" binding:"required"`
+	Cron          string          `json:"cron" binding:"required"`
+	Enabled       *bool           `json:"enabled"`
+	OverlapPolicy string          `json:"overlap_policy"`
+	Params        json.RawMessage `json:"params"`
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(store *scheduler.Store, sched *scheduler.Scheduler, backups *backup.ScheduleStore, serverManager *config.ServerManager) *ScheduleHandler {
+	return &ScheduleHandler{
+		store:         store,
+		scheduler:     sched,
+		backups:       backups,
+		serverManager: serverManager,
+	}
+}
+
+// ListSchedules returns all scheduled tasks, optionally only those of one server
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.store.List(c.Request.Context(), c.Query("server_id"))
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list schedules", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load schedules")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// GetSchedule returns one scheduled task
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule, "running": h.scheduler.Running(schedule.ID)})
+}
+
+// CreateSchedule adds a scheduled task
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	h.createSchedule(c, "")
+}
+
+// createSchedule adds a scheduled task from the request body. A non-empty
+// serverID puts it on that server.
+func (h *ScheduleHandler) createSchedule(c *gin.Context, serverID string) {
+	var req scheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if serverID != "" {
+		req.ServerID = serverID
+	}
+
+	schedule := &scheduler.Schedule{ID: uuid.New().String(), Enabled: true}
+	if !h.applyRequest(c, schedule, req) {
+		return
+	}
+
+	if err := h.store.Save(c.Request.Context(), schedule); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to create schedule", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save schedule")
+		return
+	}
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateSchedule replaces a scheduled task's settings
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+	h.updateSchedule(c, schedule, "")
+}
+
+// updateSchedule applies an update request to schedule and saves it. A
+// non-empty serverID keeps the task on that server.
+func (h *ScheduleHandler) updateSchedule(c *gin.Context, schedule *scheduler.Schedule, serverID string) {
+	var req scheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if serverID != "" {
+		req.ServerID = serverID
+	}
+	if !h.applyRequest(c, schedule, req) {
+		return
+	}
+
+	if err := h.store.Save(c.Request.Context(), schedule); err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to update schedule", "schedule_id", schedule.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save schedule")
+		return
+	}
+	h.syncBackupSchedule(c.Request.Context(), schedule)
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule removes a scheduled task and its run history
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	h.deleteSchedule(c, c.Param("id"))
+}
+
+func (h *ScheduleHandler) deleteSchedule(c *gin.Context, id string) {
+	deleted, err := h.store.Delete(c.Request.Context(), id)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to delete schedule", "schedule_id", id, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete schedule")
+		return
+	}
+	if !deleted {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Schedule not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule deleted"})
+}
+
+// RunSchedule starts a scheduled task now, applying its overlap policy
+func (h *ScheduleHandler) RunSchedule(c *gin.Context) {
+	run, err := h.scheduler.RunNow(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, scheduler.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Schedule not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to run schedule", "schedule_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to run schedule")
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+// ListScheduleRuns returns a scheduled task's run history, newest first
+func (h *ScheduleHandler) ListScheduleRuns(c *gin.Context) {
+	schedule, ok := h.loadSchedule(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	runs, err := h.store.ListRuns(c.Request.Context(), schedule.ID, limit)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list schedule runs", "schedule_id", schedule.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load run history")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// ListServerSchedules returns the scheduled tasks of one server
+func (h *ScheduleHandler) ListServerSchedules(c *gin.Context) {
+	serverID := c.Param("id")
+	if !h.serverExists(serverID) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	schedules, err := h.store.List(c.Request.Context(), serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list schedules", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load schedules")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+// CreateServerSchedule adds a scheduled task to a server
+func (h *ScheduleHandler) CreateServerSchedule(c *gin.Context) {
+	if !h.serverExists(c.Param("id")) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	h.createSchedule(c, c.Param("id"))
+}
+
+// GetServerSchedule returns one of a server's scheduled tasks
+func (h *ScheduleHandler) GetServerSchedule(c *gin.Context) {
+	schedule, ok := h.loadServerSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedule": schedule, "running": h.scheduler.Running(schedule.ID)})
+}
+
+// UpdateServerSchedule replaces the settings of one of a server's scheduled tasks
+func (h *ScheduleHandler) UpdateServerSchedule(c *gin.Context) {
+	schedule, ok := h.loadServerSchedule(c)
+	if !ok {
+		return
+	}
+	h.updateSchedule(c, schedule, schedule.ServerID)
+}
+
+// DeleteServerSchedule removes one of a server's scheduled tasks and its run history
+func (h *ScheduleHandler) DeleteServerSchedule(c *gin.Context) {
+	schedule, ok := h.loadServerSchedule(c)
+	if !ok {
+		return
+	}
+	h.deleteSchedule(c, schedule.ID)
+}
+
+// loadServerSchedule loads the :scheduleId task, which must belong to the :id server
+func (h *ScheduleHandler) loadServerSchedule(c *gin.Context) (*scheduler.Schedule, bool) {
+	schedule, err := h.store.Get(c.Request.Context(), c.Param("scheduleId"))
+	if errors.Is(err, scheduler.ErrNotFound) || (err == nil && schedule.ServerID != c.Param("id")) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Schedule not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load schedule", "schedule_id", c.Param("scheduleId"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load schedule")
+		return nil, false
+	}
+	return schedule, true
+}
+
+func (h *ScheduleHandler) loadSchedule(c *gin.Context) (*scheduler.Schedule, bool) {
+	schedule, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, scheduler.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Schedule not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load schedule", "schedule_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load schedule")
+		return nil, false
+	}
+	return schedule, true
+}
+
+// applyRequest copies a create or update request onto schedule and validates it
+func (h *ScheduleHandler) applyRequest(c *gin.Context, schedule *scheduler.Schedule, req scheduleRequest) bool {
+	if err := req.applyTo(schedule, h.serverExists); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	return true
+}
+
+func (h *ScheduleHandler) serverExists(serverID string) bool {
+	_, found := h.serverManager.GetByID(serverID)
+	return found
+}
+
+// applyTo copies the request onto schedule, fills in defaults and checks the
+// result, including that its server exists
+func (req scheduleRequest) applyTo(schedule *scheduler.Schedule, serverExists func(string) bool) error {
+	schedule.Name = req.Name
+	schedule.ServerID = req.ServerID
+	schedule.Type = req.Type
+	schedule.Cron = req.Cron
+	schedule.OverlapPolicy = req.OverlapPolicy
+	schedule.Params = req.Params
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	schedule.Normalize()
+
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	if schedule.ServerID != "" && !serverExists(schedule.ServerID) {
+		return fmt.Errorf("server %s does not exist", schedule.ServerID)
+	}
+	return nil
+}
+
+// syncBackupSchedule writes the cron expression and enabled flag of a backup
+// task back to the backup schedule it runs
+func (h *ScheduleHandler) syncBackupSchedule(ctx context.Context, schedule *scheduler.Schedule) {
+	if schedule.Type != scheduler.TypeBackup {
+		return
+	}
+	var params scheduler.BackupParams
+	if err := schedule.DecodeParams(&params); err != nil || params.BackupScheduleID == "" {
+		return
+	}
+
+	linked, err := h.backups.GetScheduleByID(schedule.ServerID, params.BackupScheduleID)
+	if err != nil {
+		return
+	}
+	linked.Schedule = schedule.Cron
+	linked.Enabled = schedule.Enabled
+	if err := h.backups.UpsertSchedule(linked); err != nil {
+		logger.WarnContext(ctx, "Failed to update backup schedule", "schedule_id", linked.ID, "error", err)
+	}
+}
+
+// ScheduledCommand sends a command task's console command to its server
+func (h *ServerHandler) ScheduledCommand(ctx context.Context, schedule *scheduler.Schedule) (string, error) {
+	var params scheduler.CommandParams
+	if err := schedule.DecodeParams(&params); err != nil {
+		return "", err
+	}
+	if _, _, err := h.connectServer(schedule.ServerID); err != nil {
+		return "", err
+	}
+
+	err := h.processManager.SendCommand(schedule.ServerID, server.SafeSessionName(schedule.ServerID), params.Command)
+	if err != nil {
+		h.activityLogger.LogCommandExecute(schedule.ServerID, nil, params.Command, false, "", err.Error())
+		return "", err
+	}
+	h.activityLogger.LogCommandExecute(schedule.ServerID, nil, params.Command, true, "", "")
+	return "Command sent: " + params.Command, nil
+}
+
+// ScheduledRestart restarts a restart task's server, counting down with
+// in-game warnings when the task has any
+func (h *ServerHandler) ScheduledRestart(ctx context.Context, schedule *scheduler.Schedule) (string, error) {
+	var params scheduler.RestartParams
+	if err := schedule.DecodeParams(&params); err != nil {
+		return "", err
+	}
+	graceful := params.Graceful == nil || *params.Graceful
+
+	serverConfig, status, err := h.scheduledServer(schedule.ServerID)
+	if err != nil {
+		return "", err
+	}
+	if status == server.StatusOffline && params.SkipIfOffline {
+		return "Server is offline, restart skipped", scheduler.ErrSkipped
+	}
+	if err := applyWarnings(serverConfig, params.PowerParams); err != nil {
+		return "", err
+	}
+
+	h.resetWatchdog(schedule.ServerID)
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(schedule.ServerID)
+
+	if err := h.lifecycleManager.RestartServer(schedule.ServerID, serverConfig, graceful); err != nil {
+		h.activityLogger.LogServerRestart(schedule.ServerID, nil, graceful, false, err.Error())
+		return "", err
+	}
+	h.activityLogger.LogServerRestart(schedule.ServerID, nil, graceful, true, "")
+	return fmt.Sprintf("Server restarted (graceful: %t)", graceful), nil
+}
+
+// ScheduledStop stops a stop task's server, counting down with in-game
+// warnings when the task has any
+func (h *ServerHandler) ScheduledStop(ctx context.Context, schedule *scheduler.Schedule) (string, error) {
+	var params scheduler.StopParams
+	if err := schedule.DecodeParams(&params); err != nil {
+		return "", err
+	}
+	graceful := params.Graceful == nil || *params.Graceful
+
+	serverConfig, status, err := h.scheduledServer(schedule.ServerID)
+	if err != nil {
+		return "", err
+	}
+	if status == server.StatusOffline {
+		if params.SkipIfOffline {
+			return "Server is offline, stop skipped", scheduler.ErrSkipped
+		}
+		return "Server is already offline", nil
+	}
+	if err := applyWarnings(serverConfig, params.PowerParams); err != nil {
+		return "", err
+	}
+
+	h.resetWatchdog(schedule.ServerID)
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(schedule.ServerID)
+
+	if err := h.lifecycleManager.StopServer(schedule.ServerID, serverConfig, graceful); err != nil {
+		h.activityLogger.LogServerStop(schedule.ServerID, nil, graceful, false, err.Error())
+		return "", err
+	}
+	h.activityLogger.LogServerStop(schedule.ServerID, nil, graceful, true, "")
+	return fmt.Sprintf("Server stopped (graceful: %t)", graceful), nil
+}
+
+// ScheduledStart starts a start task's server unless it is already running
+func (h *ServerHandler) ScheduledStart(ctx context.Context, schedule *scheduler.Schedule) (string, error) {
+	serverConfig, status, err := h.scheduledServer(schedule.ServerID)
+	if err != nil {
+		return "", err
+	}
+	if status == server.StatusOnline || status == server.StatusStarting {
+		return "Server is already " + status + ", start skipped", scheduler.ErrSkipped
+	}
+
+	h.resetWatchdog(schedule.ServerID)
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(schedule.ServerID)
+
+	if err := h.lifecycleManager.StartServer(schedule.ServerID, serverConfig); err != nil {
+		h.activityLogger.LogServerStart(schedule.ServerID, nil, false, err.Error())
+		return "", err
+	}
+	h.activityLogger.LogServerStart(schedule.ServerID, nil, true, "")
+	return "Server started", nil
+}
+
+// scheduledServer prepares a lifecycle task on a server: it refuses to run
+// during a maintenance window and returns the server's current status
+func (h *ServerHandler) scheduledServer(serverID string) (*server.ServerConfig, string, error) {
+	serverDef, _, err := h.connectServer(serverID)
+	if err != nil {
+		return nil, "", err
+	}
+	if window, active := h.inMaintenance(serverID); active {
+		return nil, "", errors.New(maintenanceError(window))
+	}
+
+	serverConfig := h.createServerConfig(serverDef)
+	h.processManager.SetRunAsUser(serverID, serverConfig.RunAsUser, serverConfig.UseSudo)
+	status, err := h.statusDetector.DetectStatus(serverID, serverConfig.SessionName)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to check server status: %w", err)
+	}
+	return serverConfig, status.Status, nil
+}
+
+// applyWarnings replaces the default stop warnings with a task's countdown.
+// The last warning is followed by a wait for the time it announced.
+func applyWarnings(serverConfig *server.ServerConfig, params scheduler.PowerParams) error {
+	delays, err := params.WarningDelays()
+	if err != nil || len(delays) == 0 {
+		return err
+	}
+	warnings := make([]server.StopWarning, 0, len(delays)+1)
+	var previous time.Duration
+	for i, left := range delays {
+		var wait time.Duration
+		if i > 0 {
+			wait = previous - left
+		}
+		warnings = append(warnings, server.StopWarning{Delay: wait, Message: params.Message(left)})
+		previous = left
+	}
+	serverConfig.StopWarnings = append(warnings, server.StopWarning{Delay: previous})
+	return nil
+}
+
+// ScheduledScript runs a script task on its server's host, in the server's
+// working directory
+func (h *ServerHandler) ScheduledScript(ctx context.Context, schedule *scheduler.Schedule) (string, error) {
+	var params scheduler.ScriptParams
+	if err := schedule.DecodeParams(&params); err != nil {
+		return "", err
+	}
+	timeout := defaultScriptTimeout
+	if strings.TrimSpace(params.Timeout) != "" {
+		parsed, err := time.ParseDuration(params.Timeout)
+		if err != nil {
+			return "", fmt.Errorf("invalid timeout %q", params.Timeout)
+		}
+		timeout = parsed
+	}
+
+	serverDef, conn, err := h.connectServer(schedule.ServerID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	script := fmt.Sprintf("WORKING_DIR=\"%s\"\nWORKING_DIR=\"${WORKING_DIR/#\\~/$HOME}\"\ncd \"$WORKING_DIR\" || exit 1\n%s",
+		escapeForScriptPath(serverDef.Server.WorkingDirectory), params.Script)
+	output, err := conn.Client.RunCommandContext(ctx, bashDollarQuotedCommand(script))
+	if err != nil {
+		return output, fmt.Errorf("script failed: %w", err)
+	}
+	return output, nil
+}
+
+// connectServer opens the pooled connection background work on a server uses
+func (h *ServerHandler) connectServer(serverID string) (*config.ServerDefinition, *ssh.PooledConnection, error) {
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return nil, nil, fmt.Errorf("server %s not found", serverID)
+	}
+	conn, err := h.sshPool.GetConnection(serverID, h.createServerConfig(&serverDef).SSHConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	return &serverDef, conn, nil
+}
+
+// BackupExecutor runs backup tasks with runner
+func BackupExecutor(runner *backup.ScheduleRunner) scheduler.Executor {
+	return func(ctx context.Context, schedule *scheduler.Schedule) (string, error) {
+		var params scheduler.BackupParams
+		if err := schedule.DecodeParams(&params); err != nil {
+			return "", err
+		}
+		return runner.RunSchedule(schedule.ServerID, params.BackupScheduleID)
+	}
+}
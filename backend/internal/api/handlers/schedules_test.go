@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+)
+
+func TestServerSchedulesStayOnTheirServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serverHandler, _, _, sm := setupTestServerHandler(t)
+	defer serverHandler.activityLogger.Close()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "schedules.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	store := scheduler.NewStore(db.DB)
+	handler := NewScheduleHandler(store, scheduler.NewScheduler(store), nil, sm)
+
+	router := gin.New()
+	router.GET("/servers/:id/schedules", handler.ListServerSchedules)
+	router.POST("/servers/:id/schedules", handler.CreateServerSchedule)
+	router.PUT("/servers/:id/schedules/:scheduleId", handler.UpdateServerSchedule)
+	router.DELETE("/servers/:id/schedules/:scheduleId", handler.DeleteServerSchedule)
+	send := func(method, path string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		return w
+	}
+
+	// The path decides the server, whatever the body says
+	request := gin.H{"type": "restart", "cron": "0 4 * * *", "server_id": "other-server",
+		"params": gin.H{"warnings": []string{"5m", "1m"}, "skip_if_offline": true}}
+	w := send(http.MethodPost, "/servers/test-server/schedules", request)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected the schedule to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	var created scheduler.Schedule
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ServerID != "test-server" {
+		t.Fatalf("expected the schedule on test-server, got %q", created.ServerID)
+	}
+
+	if w := send(http.MethodPost, "/servers/missing/schedules", request); w.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown server to be rejected, got %d", w.Code)
+	}
+	request["params"] = gin.H{"warnings": []string{"tomorrow"}}
+	if w := send(http.MethodPut, "/servers/test-server/schedules/"+created.ID, request); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid warning to be rejected, got %d", w.Code)
+	}
+	if w := send(http.MethodDelete, "/servers/other-server/schedules/"+created.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected another server's schedule to be hidden, got %d", w.Code)
+	}
+
+	w = send(http.MethodGet, "/servers/test-server/schedules", nil)
+	var listed struct {
+		Schedules []scheduler.Schedule `json:"schedules"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &listed)
+	if w.Code != http.StatusOK || len(listed.Schedules) != 1 || listed.Schedules[0].ID != created.ID {
+		t.Fatalf("expected the server's schedule, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodDelete, "/servers/test-server/schedules/"+created.ID, nil); w.Code != http.StatusOK {
+		t.Fatalf("expected the schedule to be deleted, got %d", w.Code)
+	}
+}
+
+func TestApplyWarningsCountsDown(t *testing.T) {
+	serverConfig := &server.ServerConfig{StopWarnings: []server.StopWarning{{Message: "default"}}}
+	if err := applyWarnings(serverConfig, scheduler.PowerParams{}); err != nil || serverConfig.StopWarnings[0].Message != "default" {
+		t.Fatalf("expected the default warnings without a countdown, got %+v (%v)", serverConfig.StopWarnings, err)
+	}
+
+	params := scheduler.PowerParams{Warnings: []string{"1m", "10m", "10s"}}
+	if err := applyWarnings(serverConfig, params); err != nil {
+		t.Fatalf("apply warnings: %v", err)
+	}
+	want := []server.StopWarning{
+		{Delay: 0, Message: "Server shutting down in 10 minutes"},
+		{Delay: 9 * time.Minute, Message: "Server shutting down in 1 minute"},
+		{Delay: 50 * time.Second, Message: "Server shutting down in 10 seconds"},
+		{Delay: 10 * time.Second},
+	}
+	if len(serverConfig.StopWarnings) != len(want) {
+		t.Fatalf("expected %d warnings, got %+v", len(want), serverConfig.StopWarnings)
+	}
+	for i := range want {
+		if serverConfig.StopWarnings[i] != want[i] {
+			t.Errorf("warning %d: expected %+v, got %+v", i, want[i], serverConfig.StopWarnings[i])
+		}
+	}
+}
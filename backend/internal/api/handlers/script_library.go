@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/scriptlib"
+)
+
+// scriptOutputLimit bounds the output kept with a script run
+const scriptOutputLimit = 64 * 1024
+
+type scriptRequest struct {
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	Body        string                `json:"body" binding:"required"`
+	Parameters  []scriptlib.Parameter `json:"parameters"`
+}
+
+type scriptRunRequest struct {
+	ServerIDs  []string          `json:"server_ids" binding:"required"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// SetScriptLibrary lets the handler serve the script library. Runs left
+// running by a previous process are closed, unless other instances share the
+// database and may still be running theirs.
+func (h *ServerHandler) SetScriptLibrary(store *scriptlib.Store) {
+	h.scriptLibrary = store
+	if h.cluster != nil && h.cluster.Clustered() {
+		return
+	}
+	if err := store.FailInterrupted(context.Background()); err != nil {
+		logger.Error("Failed to close interrupted script runs", "error", err)
+	}
+}
+
+// ListScripts returns the script library, by name
+func (h *ServerHandler) ListScripts(c *gin.Context) {
+	scripts, err := h.scriptLibrary.List(c.Request.Context())
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list scripts", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load scripts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scripts": scripts})
+}
+
+// GetScript returns one script of the library
+func (h *ServerHandler) GetScript(c *gin.Context) {
+	script, ok := h.loadScript(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, script)
+}
+
+// CreateScript adds a script to the library. It can only be run once approved.
+func (h *ServerHandler) CreateScript(c *gin.Context) {
+	var req scriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	username := usernameFromContext(c)
+	script := &scriptlib.Script{
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Description: req.Description,
+		Body:        req.Body,
+		Parameters:  req.Parameters,
+		Status:      scriptlib.StatusPending,
+		CreatedBy:   username,
+		UpdatedBy:   username,
+	}
+	if !h.saveScript(c, script) {
+		return
+	}
+	c.JSON(http.StatusCreated, script)
+}
+
+// UpdateScript changes a script. A change to its text or parameters has it
+// wait for approval again.
+func (h *ServerHandler) UpdateScript(c *gin.Context) {
+	script, ok := h.loadScript(c)
+	if !ok {
+		return
+	}
+	var req scriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	next := *script
+	next.Name = req.Name
+	next.Description = req.Description
+	next.Body = req.Body
+	next.Parameters = req.Parameters
+	next.UpdatedBy = usernameFromContext(c)
+	next.Normalize()
+	if script.Changed(&next) {
+		next.Status = scriptlib.StatusPending
+		next.ApprovedBy = ""
+		next.ApprovedAt = nil
+	}
+	if !h.saveScript(c, &next) {
+		return
+	}
+	c.JSON(http.StatusOK, next)
+}
+
+// DeleteScript removes a script from the library. The history of its runs is kept.
+func (h *ServerHandler) DeleteScript(c *gin.Context) {
+	err := h.scriptLibrary.Delete(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, scriptlib.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Script not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to delete script", "script_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete script")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Script deleted"})
+}
+
+// ApproveScript allows a script to be run as it stands
+func (h *ServerHandler) ApproveScript(c *gin.Context) {
+	script, ok := h.loadScript(c)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	script.Status = scriptlib.StatusApproved
+	script.ApprovedBy = usernameFromContext(c)
+	script.ApprovedAt = &now
+	if !h.saveScript(c, script) {
+		return
+	}
+	c.JSON(http.StatusOK, script)
+}
+
+// RunScript runs an approved script on the hosts of the given servers. It
+// runs once per host, as a task of the first given server on that host, so
+// its output streams through that server's task WebSocket.
+func (h *ServerHandler) RunScript(c *gin.Context) {
+	script, ok := h.loadScript(c)
+	if !ok {
+		return
+	}
+	if script.Status != scriptlib.StatusApproved {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Script must be approved before it is run")
+		return
+	}
+	var req scriptRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(req.ServerIDs) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, "server_ids must list at least one server")
+		return
+	}
+	values, err := script.Values(req.Parameters)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+
+	username := usernameFromContext(c)
+	hosts := make(map[string]bool)
+	runs := make([]*scriptlib.Run, 0, len(req.ServerIDs))
+	for _, serverID := range req.ServerIDs {
+		serverDef, found := h.serverManager.GetByID(serverID)
+		if !found {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, fmt.Sprintf("Server %s not found", serverID))
+			return
+		}
+		host := serverDef.Connection.Host
+		if hosts[host] {
+			continue
+		}
+		hosts[host] = true
+		runs = append(runs, &scriptlib.Run{
+			ID:         uuid.New().String(),
+			ScriptID:   script.ID,
+			ScriptName: script.Name,
+			Body:       script.Body,
+			ServerID:   serverID,
+			Host:       host,
+			Parameters: values,
+			Status:     scriptlib.RunRunning,
+			StartedBy:  username,
+		})
+	}
+
+	for _, run := range runs {
+		if err := h.scriptLibrary.SaveRun(c.Request.Context(), run); err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to record script run", "script_id", script.ID, "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to record script run")
+			return
+		}
+	}
+	userID := getUserIDFromContext(c)
+	for _, run := range runs {
+		run := *run
+		h.goTask(c, run.ServerID, "script:"+script.Name, func(ctx context.Context, task *taskRecord) {
+			run.TaskID = task.ID
+			if err := h.scriptLibrary.SaveRun(ctx, &run); err != nil {
+				logger.ErrorContext(ctx, "Failed to record script run task", "run_id", run.ID, "error", err)
+			}
+			h.runScript(ctx, &run, task, userID)
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"runs": runs})
+}
+
+func (h *ServerHandler) runScript(ctx context.Context, run *scriptlib.Run, task *taskRecord, userID *int64) {
+	outputLog := &strings.Builder{}
+	var outputMu sync.Mutex
+	emit := func(line string) {
+		outputMu.Lock()
+		appendOutput(outputLog, line, scriptOutputLimit)
+		outputMu.Unlock()
+		h.appendTaskStreamLine(run.ServerID, task.ID, task.Task, line)
+	}
+
+	emit(fmt.Sprintf("Running script %s on %s...", run.ScriptName, run.Host))
+	_, conn, err := h.connectServer(run.ServerID)
+	if err == nil {
+		writer := newLineSinkWriter(emit)
+		err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(scriptlib.Command(run.Body, run.Parameters)), writer, writer)
+		writer.FlushRemaining()
+	}
+
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	run.Status = scriptlib.RunCompleted
+	if err != nil {
+		emit("Script failed: " + err.Error())
+		run.Status = scriptlib.RunFailed
+		run.Error = err.Error()
+	} else {
+		emit("Script complete.")
+	}
+	outputMu.Lock()
+	run.Output = outputLog.String()
+	outputMu.Unlock()
+
+	h.finishTask(run.ServerID, task.ID, err)
+	if saveErr := h.scriptLibrary.SaveRun(context.WithoutCancel(ctx), run); saveErr != nil {
+		logger.ErrorContext(ctx, "Failed to record script run result", "run_id", run.ID, "error", saveErr)
+	}
+	_ = h.activityLogger.LogActivity(&logging.Activity{
+		ServerID:     run.ServerID,
+		UserID:       userID,
+		ActivityType: logging.ActivityScriptRun,
+		Description:  fmt.Sprintf("Ran script %s on %s", run.ScriptName, run.Host),
+		Metadata: map[string]interface{}{
+			"script_id": run.ScriptID,
+			"run_id":    run.ID,
+			"host":      run.Host,
+			"output":    truncateOutput(run.Output, 2000),
+		},
+		Success:      err == nil,
+		ErrorMessage: run.Error,
+	})
+}
+
+// ListScriptRuns returns the history of script runs, newest first. It can be
+// narrowed with the script_id and server_id query parameters.
+func (h *ServerHandler) ListScriptRuns(c *gin.Context) {
+	filter := scriptlib.RunFilter{
+		ScriptID: c.Query("script_id"),
+		ServerID: c.Query("server_id"),
+		Limit:    50,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = min(limit, 500)
+	}
+	runs, err := h.scriptLibrary.ListRuns(c.Request.Context(), filter)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list script runs", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load script runs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+// GetScriptRun returns one script run with its output
+func (h *ServerHandler) GetScriptRun(c *gin.Context) {
+	run, err := h.scriptLibrary.GetRun(c.Request.Context(), c.Param("runId"))
+	if errors.Is(err, scriptlib.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Script run not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load script run", "run_id", c.Param("runId"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load script run")
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+func (h *ServerHandler) loadScript(c *gin.Context) (*scriptlib.Script, bool) {
+	script, err := h.scriptLibrary.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, scriptlib.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Script not found")
+		return nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load script", "script_id", c.Param("id"), "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load script")
+		return nil, false
+	}
+	return script, true
+}
+
+func (h *ServerHandler) saveScript(c *gin.Context, script *scriptlib.Script) bool {
+	script.Normalize()
+	if err := script.Validate(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return false
+	}
+	err := h.scriptLibrary.Save(c.Request.Context(), script)
+	if errors.Is(err, scriptlib.ErrNameTaken) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, err.Error())
+		return false
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to save script", "script_id", script.ID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save script")
+		return false
+	}
+	return true
+}
+
+func usernameFromContext(c *gin.Context) string {
+	claims, ok := c.Get("user")
+	if !ok {
+		return ""
+	}
+	if user, ok := claims.(*auth.Claims); ok && user != nil {
+		return user.Username
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/scriptlib"
+)
+
+func TestScriptsRunOnlyOnceApproved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	handler.SetScriptLibrary(scriptlib.NewStore(db.DB))
+
+	router := gin.New()
+	router.POST("/scripts", handler.CreateScript)
+	router.PUT("/scripts/:id", handler.UpdateScript)
+	router.POST("/scripts/:id/approve", handler.ApproveScript)
+	router.POST("/scripts/:id/run", handler.RunScript)
+	send := func(method, path string, body any) (int, scriptlib.Script) {
+		payload, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewReader(payload)))
+		var script scriptlib.Script
+		_ = json.Unmarshal(w.Body.Bytes(), &script)
+		return w.Code, script
+	}
+
+	request := scriptRequest{Name: "clean-logs", Body: `find /var/log -mtime +"$DAYS" -delete`, Parameters: []scriptlib.Parameter{{Name: "DAYS", Default: "7"}}}
+	code, script := send(http.MethodPost, "/scripts", request)
+	if code != http.StatusCreated || script.Status != scriptlib.StatusPending {
+		t.Fatalf("expected a pending script, got %d %+v", code, script)
+	}
+
+	run := scriptRunRequest{ServerIDs: []string{"test-server"}}
+	if code, _ := send(http.MethodPost, "/scripts/"+script.ID+"/run", run); code != http.StatusConflict {
+		t.Fatalf("expected a pending script not to run, got %d", code)
+	}
+
+	if code, approved := send(http.MethodPost, "/scripts/"+script.ID+"/approve", nil); code != http.StatusOK || approved.Status != scriptlib.StatusApproved {
+		t.Fatalf("expected the script to be approved, got %d %+v", code, approved)
+	}
+
+	request.Description = "Removes old logs"
+	if code, updated := send(http.MethodPut, "/scripts/"+script.ID, request); code != http.StatusOK || updated.Status != scriptlib.StatusApproved {
+		t.Fatalf("expected a description change to keep the approval, got %d %+v", code, updated)
+	}
+
+	request.Body = "rm -rf /var/log/old"
+	if code, updated := send(http.MethodPut, "/scripts/"+script.ID, request); code != http.StatusOK || updated.Status != scriptlib.StatusPending || updated.ApprovedAt != nil {
+		t.Fatalf("expected a body change to need approval again, got %d %+v", code, updated)
+	}
+}
@@ -23,6 +23,12 @@ var ServerDependenciesCheckScript string
 //go:embed scripts/deploy_release.sh.tmpl
 var ServerReleaseDeployScript string
 
+//go:embed scripts/release_activate.sh.tmpl
+var ReleaseActivateScript string
+
+//go:embed scripts/rollback_release.sh.tmpl
+var ServerReleaseRollbackScript string
+
 //go:embed scripts/start_hytale.sh.tmpl
 var StartHytaleScript string
 
@@ -31,3 +37,12 @@ var NodeExporterCheckRunningScript string
 
 //go:embed scripts/node_exporter_check_enabled.sh
 var NodeExporterCheckEnabledScript string
+
+//go:embed scripts/drift_check.sh.tmpl
+var ServerDriftCheckScript string
+
+//go:embed scripts/host_security_check.sh.tmpl
+var HostSecurityCheckScript string
+
+//go:embed scripts/agent_cert_renew.sh.tmpl
+var AgentCertRenewScript string
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
+	"github.com/TheGojiOG/HytaleSM/internal/permissions"
+)
+
+// What a search looks through, in the order results are listed
+const (
+	searchServers  = "servers"
+	searchBackups  = "backups"
+	searchReleases = "releases"
+	searchUsers    = "users"
+	searchTasks    = "tasks"
+	searchActivity = "activity"
+)
+
+var searchTypes = []string{searchServers, searchBackups, searchReleases, searchUsers, searchTasks, searchActivity}
+
+const (
+	minSearchLength    = 2
+	defaultSearchLimit = 5
+	maxSearchLimit     = 25
+)
+
+// searchResult is one match of a search, in the same shape whatever was matched
+type searchResult struct {
+	Type     string     `json:"type"`
+	ID       string     `json:"id"`
+	Title    string     `json:"title"`
+	Detail   string     `json:"detail,omitempty"`
+	ServerID string     `json:"server_id,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+}
+
+// callerAccess caches the permission checks of one request, for handlers
+// that show each caller only what it may see
+type callerAccess struct {
+	ctx     context.Context
+	userID  int64
+	allowed map[string]bool
+}
+
+func newCallerAccess(c *gin.Context) *callerAccess {
+	return &callerAccess{
+		ctx:     c.Request.Context(),
+		userID:  c.GetInt64("user_id"),
+		allowed: make(map[string]bool),
+	}
+}
+
+// searchRequest is one search: the pattern to match and what the caller may see
+type searchRequest struct {
+	*callerAccess
+	term    string
+	pattern string
+	limit   int
+}
+
+// Search looks for ?q= in the servers, backups, releases, users, tasks and
+// activity the caller may see, for a command palette. ?type= narrows it to
+// some of them and ?limit= bounds the results of each (default 5, at most 25).
+func (h *ServerHandler) Search(c *gin.Context) {
+	term := strings.TrimSpace(c.Query("q"))
+	if len([]rune(term)) < minSearchLength {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, fmt.Sprintf("q must be at least %d characters", minSearchLength))
+		return
+	}
+	types, err := searchTypesParam(c.QueryArray("type"))
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	limit := defaultSearchLimit
+	if parsed, err := strconv.Atoi(c.Query("limit")); err == nil && parsed > 0 {
+		limit = min(parsed, maxSearchLimit)
+	}
+
+	req := &searchRequest{
+		callerAccess: newCallerAccess(c),
+		term:         strings.ToLower(term),
+		pattern:      likePattern(term),
+		limit:        limit,
+	}
+	searchers := map[string]func(*searchRequest) ([]searchResult, error){
+		searchServers:  h.searchServers,
+		searchBackups:  h.searchBackups,
+		searchReleases: h.searchReleases,
+		searchUsers:    h.searchUsers,
+		searchTasks:    h.searchTasks,
+		searchActivity: h.searchActivity,
+	}
+
+	results := make([]searchResult, 0)
+	for _, kind := range types {
+		found, err := searchers[kind](req)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Search failed", "type", kind, "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Search failed")
+			return
+		}
+		results = append(results, found...)
+	}
+	c.JSON(http.StatusOK, gin.H{"query": term, "results": results})
+}
+
+// searchTypesParam reads ?type=, repeated or comma-separated; none means all
+func searchTypesParam(values []string) ([]string, error) {
+	wanted := make(map[string]bool)
+	for _, value := range values {
+		for _, kind := range strings.Split(value, ",") {
+			kind = strings.TrimSpace(kind)
+			if kind == "" {
+				continue
+			}
+			if !slices.Contains(searchTypes, kind) {
+				return nil, fmt.Errorf("unknown type %q (use %s)", kind, strings.Join(searchTypes, ", "))
+			}
+			wanted[kind] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return searchTypes, nil
+	}
+	types := make([]string, 0, len(wanted))
+	for _, kind := range searchTypes {
+		if wanted[kind] {
+			types = append(types, kind)
+		}
+	}
+	return types, nil
+}
+
+// likePattern matches term anywhere in a lowercased column, with LIKE's
+// wildcards taken literally
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(term))
+	return "%" + escaped + "%"
+}
+
+// can reports whether the caller holds permission, on serverID when it is
+// set. A failed check counts as denied.
+func (h *ServerHandler) can(access *callerAccess, serverID, permission string) bool {
+	key := serverID + "\x00" + permission
+	if allowed, ok := access.allowed[key]; ok {
+		return allowed
+	}
+	allowed, err := middleware.HasPermission(h.rbacManager, access.userID, serverID, permission)
+	if err != nil {
+		logger.WarnContext(access.ctx, "Permission check failed", "server_id", serverID, "permission", permission, "error", err)
+	}
+	access.allowed[key] = allowed
+	return allowed
+}
+
+// visibleServers returns the IDs of the servers the caller holds permission on
+func (h *ServerHandler) visibleServers(access *callerAccess, permission string) []string {
+	ids := make([]string, 0)
+	for _, server := range h.serverManager.GetAll() {
+		if h.can(access, server.ID, permission) {
+			ids = append(ids, server.ID)
+		}
+	}
+	return ids
+}
+
+// inServers narrows a query to some servers, returning the condition and its arguments
+func inServers(ids []string) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "server_id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args
+}
+
+func (h *ServerHandler) searchServers(req *searchRequest) ([]searchResult, error) {
+	results := make([]searchResult, 0)
+	for _, server := range h.serverManager.GetAll() {
+		if len(results) == req.limit {
+			break
+		}
+		fields := []string{server.ID, server.Name, server.Description, server.Connection.Host}
+		if !slices.ContainsFunc(fields, func(field string) bool { return strings.Contains(strings.ToLower(field), req.term) }) {
+			continue
+		}
+		if !h.can(req.callerAccess, server.ID, permissions.ServersGet) {
+			continue
+		}
+		results = append(results, searchResult{
+			Type:     searchServers,
+			ID:       server.ID,
+			Title:    server.Name,
+			Detail:   server.Connection.Host,
+			ServerID: server.ID,
+		})
+	}
+	return results, nil
+}
+
+func (h *ServerHandler) searchBackups(req *searchRequest) ([]searchResult, error) {
+	servers := h.visibleServers(req.callerAccess, permissions.ServersBackupsList)
+	if len(servers) == 0 {
+		return nil, nil
+	}
+	where, args := inServers(servers)
+	args = append(args, req.pattern, req.pattern, req.limit)
+	rows, err := h.db.QueryContext(req.ctx, `
+		SELECT id, server_id, filename, status, created_at
+		FROM backups
+		WHERE `+where+` AND status != 'deleted'
+			AND (LOWER(filename) LIKE ? ESCAPE '\' OR LOWER(id) LIKE ? ESCAPE '\')
+		ORDER BY created_at DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query backups: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]searchResult, 0)
+	for rows.Next() {
+		var result searchResult
+		var status string
+		var createdAt time.Time
+		if err := rows.Scan(&result.ID, &result.ServerID, &result.Title, &status, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan backup: %w", err)
+		}
+		result.Type = searchBackups
+		result.Detail = status
+		result.Time = &createdAt
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (h *ServerHandler) searchReleases(req *searchRequest) ([]searchResult, error) {
+	if !h.can(req.callerAccess, "", permissions.ReleasesList) {
+		return nil, nil
+	}
+	rows, err := h.db.QueryContext(req.ctx, `
+		SELECT id, version, patchline, downloaded_at
+		FROM releases
+		WHERE removed = 0 AND (LOWER(version) LIKE ? ESCAPE '\' OR LOWER(patchline) LIKE ? ESCAPE '\')
+		ORDER BY id DESC
+		LIMIT ?
+	`, req.pattern, req.pattern, req.limit)
+	if err != nil {
+		return nil, fmt.Errorf("query releases: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]searchResult, 0)
+	for rows.Next() {
+		var id int64
+		var result searchResult
+		var downloadedAt sql.NullTime
+		if err := rows.Scan(&id, &result.Title, &result.Detail, &downloadedAt); err != nil {
+			return nil, fmt.Errorf("scan release: %w", err)
+		}
+		result.Type = searchReleases
+		result.ID = strconv.FormatInt(id, 10)
+		if downloadedAt.Valid {
+			result.Time = &downloadedAt.Time
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (h *ServerHandler) searchUsers(req *searchRequest) ([]searchResult, error) {
+	if !h.can(req.callerAccess, "", permissions.IAMUsersList) {
+		return nil, nil
+	}
+	rows, err := h.db.QueryContext(req.ctx, `
+		SELECT id, username, email, full_name
+		FROM users
+		WHERE LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\' OR LOWER(full_name) LIKE ? ESCAPE '\'
+		ORDER BY username
+		LIMIT ?
+	`, req.pattern, req.pattern, req.pattern, req.limit)
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]searchResult, 0)
+	for rows.Next() {
+		var id int64
+		var username, email, fullName string
+		if err := rows.Scan(&id, &username, &email, &fullName); err != nil {
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		detail := email
+		if fullName != "" {
+			detail = fullName + " <" + email + ">"
+		}
+		results = append(results, searchResult{
+			Type:   searchUsers,
+			ID:     strconv.FormatInt(id, 10),
+			Title:  username,
+			Detail: detail,
+		})
+	}
+	return results, rows.Err()
+}
+
+func (h *ServerHandler) searchTasks(req *searchRequest) ([]searchResult, error) {
+	results := make([]searchResult, 0)
+	for _, serverID := range h.visibleServers(req.callerAccess, permissions.ServersTasksRead) {
+		for _, record := range h.listTasks(serverID) {
+			fields := []string{record.ID, record.Task, record.LastLine, record.Error}
+			if !slices.ContainsFunc(fields, func(field string) bool { return strings.Contains(strings.ToLower(field), req.term) }) {
+				continue
+			}
+			started := record.StartedAt
+			results = append(results, searchResult{
+				Type:     searchTasks,
+				ID:       record.ID,
+				Title:    record.Task,
+				Detail:   string(record.Status),
+				ServerID: serverID,
+				Time:     &started,
+			})
+		}
+	}
+	// Newest first across servers
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Time.After(*results[j].Time) })
+	if len(results) > req.limit {
+		results = results[:req.limit]
+	}
+	return results, nil
+}
+
+func (h *ServerHandler) searchActivity(req *searchRequest) ([]searchResult, error) {
+	servers := h.visibleServers(req.callerAccess, permissions.ServersActivityRead)
+	if len(servers) == 0 {
+		return nil, nil
+	}
+	where, args := inServers(servers)
+	args = append(args, req.pattern, req.pattern, req.pattern, req.limit)
+	rows, err := h.db.QueryContext(req.ctx, `
+		SELECT id, server_id, activity_type, description, timestamp
+		FROM activity_log
+		WHERE `+where+`
+			AND (LOWER(description) LIKE ? ESCAPE '\' OR LOWER(activity_type) LIKE ? ESCAPE '\' OR LOWER(error_message) LIKE ? ESCAPE '\')
+		ORDER BY id DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query activity: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]searchResult, 0)
+	for rows.Next() {
+		var id int64
+		var result searchResult
+		var description sql.NullString
+		var timestamp time.Time
+		if err := rows.Scan(&id, &result.ServerID, &result.Detail, &description, &timestamp); err != nil {
+			return nil, fmt.Errorf("scan activity: %w", err)
+		}
+		result.Type = searchActivity
+		result.ID = strconv.FormatInt(id, 10)
+		result.Title = description.String
+		if result.Title == "" {
+			result.Title = result.Detail
+		}
+		result.Time = &timestamp
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestSearchRespectsPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	handler.db = db
+	handler.rbacManager = auth.NewRBACManager(db.DB)
+
+	users := map[string]int64{}
+	for _, user := range []struct{ name, role string }{{"test-viewer", "Viewer"}, {"test-admin", "Admin"}} {
+		result, err := db.Exec(`INSERT INTO users (username, email, password_hash) VALUES (?, ?, 'x')`, user.name, user.name+"@example.com")
+		if err != nil {
+			t.Fatalf("insert user: %v", err)
+		}
+		id, _ := result.LastInsertId()
+		if _, err := db.Exec(`INSERT INTO user_roles (user_id, role_id) SELECT ?, id FROM roles WHERE name = ?`, id, user.role); err != nil {
+			t.Fatalf("assign role: %v", err)
+		}
+		users[user.name] = id
+	}
+	if _, err := db.Exec(`INSERT INTO activity_log (server_id, activity_type, description) VALUES ('test-server', 'server.start', 'Started for the 100% test')`); err != nil {
+		t.Fatalf("insert activity: %v", err)
+	}
+
+	search := func(user, query string) (int, map[string][]searchResult) {
+		router := gin.New()
+		router.GET("/search", func(c *gin.Context) {
+			c.Set("user_id", users[user])
+			handler.Search(c)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/search?"+query, nil))
+		var body struct {
+			Results []searchResult `json:"results"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		byType := make(map[string][]searchResult)
+		for _, result := range body.Results {
+			byType[result.Type] = append(byType[result.Type], result)
+		}
+		return w.Code, byType
+	}
+
+	code, found := search("test-viewer", "q=TEST")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(found[searchServers]) != 1 || found[searchServers][0].ID != "test-server" {
+		t.Fatalf("expected the test server, got %+v", found)
+	}
+	if len(found[searchUsers]) != 0 {
+		t.Fatalf("expected a viewer not to find users, got %+v", found[searchUsers])
+	}
+
+	_, found = search("test-admin", "q=test&type=users")
+	if len(found[searchUsers]) != 2 || len(found[searchServers]) != 0 {
+		t.Fatalf("expected only the two users, got %+v", found)
+	}
+
+	// LIKE wildcards in the query are matched literally
+	_, found = search("test-admin", "q=100%25&type=activity")
+	if len(found[searchActivity]) != 1 {
+		t.Fatalf("expected the activity entry, got %+v", found)
+	}
+	if _, found = search("test-admin", "q=1_0&type=activity"); len(found[searchActivity]) != 0 {
+		t.Fatalf("expected _ not to match any character, got %+v", found)
+	}
+
+	if code, _ := search("test-admin", "q=t"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a one-letter query, got %d", code)
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/selfbackup"
+)
+
+// SelfBackupHandler exposes snapshots of the manager's own database, config and keys
+type SelfBackupHandler struct {
+	manager *selfbackup.Manager
+}
+
+// NewSelfBackupHandler creates a new self-backup handler
+func NewSelfBackupHandler(manager *selfbackup.Manager) *SelfBackupHandler {
+	return &SelfBackupHandler{manager: manager}
+}
+
+// ListSnapshots returns the available manager snapshots, newest first
+func (h *SelfBackupHandler) ListSnapshots(c *gin.Context) {
+	snapshots, err := h.manager.List()
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list snapshots")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"snapshots": snapshots})
+}
+
+// CreateSnapshot takes a snapshot immediately
+func (h *SelfBackupHandler) CreateSnapshot(c *gin.Context) {
+	snapshot, err := h.manager.Create()
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Manual snapshot failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create snapshot")
+		return
+	}
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// DownloadSnapshot streams a snapshot archive
+func (h *SelfBackupHandler) DownloadSnapshot(c *gin.Context) {
+	name := c.Param("name")
+	path, err := h.manager.Path(name)
+	if err != nil {
+		h.respondPathError(c, err)
+		return
+	}
+	c.FileAttachment(path, name)
+}
+
+// VerifySnapshot decrypts and unpacks a snapshot and checks its files without
+// restoring it
+func (h *SelfBackupHandler) VerifySnapshot(c *gin.Context) {
+	name := c.Param("name")
+	if _, err := h.manager.Path(name); err != nil {
+		h.respondPathError(c, err)
+		return
+	}
+
+	manifest, err := h.manager.Verify(name)
+	if errors.Is(err, selfbackup.ErrPassphraseRequired) || errors.Is(err, selfbackup.ErrWrongPassphrase) {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true, "manifest": manifest})
+}
+
+// DeleteSnapshot removes a snapshot archive
+func (h *SelfBackupHandler) DeleteSnapshot(c *gin.Context) {
+	if err := h.manager.Delete(c.Param("name")); err != nil {
+		h.respondPathError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot deleted"})
+}
+
+func (h *SelfBackupHandler) respondPathError(c *gin.Context, err error) {
+	if os.IsNotExist(err) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Snapshot not found")
+		return
+	}
+	apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/files"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/serverconfig"
+	"github.com/gin-gonic/gin"
+)
+
+// maxConfigBackups is how many copies of config.json from before an edit are kept
+const maxConfigBackups = 10
+
+type serverConfigRequest struct {
+	Settings *serverconfig.Config `json:"settings"`
+	// Password replaces the join password; nil keeps it, "" removes it
+	Password *string              `json:"password"`
+	Launch   *serverconfig.Launch `json:"launch"`
+	Checksum string               `json:"checksum"`
+}
+
+// GetServerConfig returns a server's config.json as typed settings, with the
+// join password left out, and the launch settings from servers.yaml
+func (h *ServerHandler) GetServerConfig(c *gin.Context) {
+	serverDef, found := h.serverManager.GetByID(c.Param("id"))
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	settings, data, ok := h.readServerConfig(c, fsys)
+	if !ok {
+		return
+	}
+	passwordSet := settings.Password != ""
+	settings.Password = ""
+	c.JSON(http.StatusOK, gin.H{
+		"path":         serverconfig.File,
+		"exists":       data != nil,
+		"settings":     settings,
+		"password_set": passwordSet,
+		"checksum":     files.Checksum(data),
+		"launch":       launchSettings(serverDef),
+	})
+}
+
+// UpdateServerConfig validates and applies new settings, launch settings or
+// both. config.json is copied aside before it is rewritten; the change takes
+// effect when the server next starts.
+func (h *ServerHandler) UpdateServerConfig(c *gin.Context) {
+	serverID := c.Param("id")
+	var req serverConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if req.Settings == nil && req.Launch == nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "settings or launch is required")
+		return
+	}
+	if req.Launch != nil {
+		if err := req.Launch.Validate(); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+	}
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	response := gin.H{"restart_required": h.serverRunning(serverID)}
+	if req.Settings != nil {
+		fsys, client, ok := h.openServerFiles(c)
+		if !ok {
+			return
+		}
+		defer client.Close()
+		current, data, ok := h.readServerConfig(c, fsys)
+		if !ok {
+			return
+		}
+		if checksum := files.Checksum(data); req.Checksum != "" && req.Checksum != checksum {
+			apierror.RespondDetails(c, http.StatusConflict, apierror.CodeVersionConflict,
+				fmt.Sprintf("%s was changed since it was read; reload and try again", serverconfig.File),
+				gin.H{"current_checksum": checksum})
+			return
+		}
+
+		settings := req.Settings
+		settings.Keep(current)
+		settings.Password = current.Password
+		if req.Password != nil {
+			settings.Password = *req.Password
+		}
+		if err := settings.Validate(); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+		encoded, err := serverconfig.Encode(settings)
+		if err != nil {
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, err.Error())
+			return
+		}
+
+		backup := ""
+		if data != nil {
+			if backup, err = fsys.Backup(serverconfig.File, data, maxConfigBackups); err != nil {
+				logger.ErrorContext(c.Request.Context(), "Failed to back up server config", "server_id", serverID, "error", err)
+				apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed,
+					fmt.Sprintf("Failed to back up %s; it was not changed: %v", serverconfig.File, err))
+				return
+			}
+		}
+		err = fsys.Write(serverconfig.File, bytes.NewReader(encoded), true)
+		h.logFileChange(c, fileOpWrite, fmt.Sprintf("Updated %s", serverconfig.File), gin.H{"path": serverconfig.File, "backup": backup}, err)
+		if err != nil {
+			h.respondFileError(c, fileOpWrite, serverconfig.File, err)
+			return
+		}
+		response["password_set"] = settings.Password != ""
+		settings.Password = ""
+		response["settings"] = settings
+		response["checksum"] = files.Checksum(encoded)
+		response["backup"] = backup
+	}
+
+	if req.Launch != nil {
+		var saved config.ServerDefinition
+		err := h.serverManager.Persist(func() (err error) {
+			serverDef, found := h.serverManager.GetByID(serverID)
+			if !found {
+				return config.ErrServerNotFound
+			}
+			serverDef.Runtime.JavaXms = req.Launch.JavaXms
+			serverDef.Runtime.JavaXmx = req.Launch.JavaXmx
+			serverDef.Runtime.JavaMetaspace = req.Launch.JavaMetaspace
+			serverDef.Query.Port = req.Launch.Port
+			saved, err = h.serverManager.UpdateVersioned(serverDef)
+			return err
+		})
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "Failed to save launch settings", "server_id", serverID, "error", err)
+			status := http.StatusInternalServerError
+			if !errors.Is(err, config.ErrPersistFailed) {
+				status = http.StatusBadRequest
+			}
+			apierror.Respond(c, status, apierror.CodeValidationFailed, fmt.Sprintf("Failed to save launch settings: %v", err))
+			return
+		}
+		h.activityLogger.LogActivity(&logging.Activity{
+			ServerID:     serverID,
+			UserID:       getUserIDFromContext(c),
+			ActivityType: logging.ActivityConfigUpdate,
+			Description:  "Updated launch settings",
+			Metadata:     map[string]interface{}{"java_xms": req.Launch.JavaXms, "java_xmx": req.Launch.JavaXmx, "port": req.Launch.Port},
+			Success:      true,
+		})
+		response["launch"] = launchSettings(saved)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// readServerConfig reads and parses config.json, responding with the error
+// when that fails. A missing file gives the defaults and nil data.
+func (h *ServerHandler) readServerConfig(c *gin.Context, fsys *files.FS) (*serverconfig.Config, []byte, bool) {
+	data, err := fsys.ReadText(serverconfig.File)
+	if err != nil && !errors.Is(err, files.ErrNotFound) {
+		h.respondFileError(c, "read", serverconfig.File, err)
+		return nil, nil, false
+	}
+	settings, err := serverconfig.Parse(data)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed,
+			err.Error()+"; fix it through the file manager")
+		return nil, nil, false
+	}
+	return settings, data, true
+}
+
+func launchSettings(serverDef config.ServerDefinition) serverconfig.Launch {
+	return serverconfig.Launch{
+		JavaXms:       serverDef.Runtime.JavaXms,
+		JavaXmx:       serverDef.Runtime.JavaXmx,
+		JavaMetaspace: serverDef.Runtime.JavaMetaspace,
+		Port:          serverDef.Query.Port,
+	}
+}
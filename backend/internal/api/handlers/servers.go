@@ -2,18 +2,15 @@ package handlers
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"regexp"
@@ -25,19 +22,49 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/sftp"
+	"github.com/TheGojiOG/HytaleSM/internal/agentbin"
 	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/agentstream"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/TheGojiOG/HytaleSM/internal/cache"
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
-	crypto "github.com/TheGojiOG/HytaleSM/internal/crypto"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/gamejobs"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
 	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
 	"github.com/TheGojiOG/HytaleSM/internal/models"
+	"github.com/TheGojiOG/HytaleSM/internal/query"
 	"github.com/TheGojiOG/HytaleSM/internal/releases"
+	"github.com/TheGojiOG/HytaleSM/internal/scriptlib"
 	"github.com/TheGojiOG/HytaleSM/internal/server"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
+	"github.com/TheGojiOG/HytaleSM/internal/watchdog"
 	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
 )
 
+// logger is shared by the handlers in this package
+var logger = logging.For("api")
+
+// How long dashboard reads may be served from memory. Installs invalidate
+// the affected entries sooner.
+const (
+	latestMetricsCacheTTL = 5 * time.Second
+	nodeExporterCacheTTL  = 30 * time.Second
+)
+
+// Live metrics limits used when metrics.live_concurrency or live_timeout is unset
+const (
+	defaultLiveConcurrency = 16
+	defaultLiveTimeout     = 5 * time.Second
+)
+
 // ServerHandler handles server management requests
 type ServerHandler struct {
 	config           *config.Config
@@ -50,19 +77,38 @@ type ServerHandler struct {
 	processManager   server.ProcessManager
 	activityLogger   *logging.ActivityLogger
 	hub              *ws.Hub
+	metricsWriter    *metrics.Writer
 	pendingOps       sync.WaitGroup
-	cpuMu            sync.Mutex
-	cpuSamples       map[string]cpuSample
+	tasksCtx         context.Context
+	cancelTasks      context.CancelFunc
+	cpuSamples       *metrics.CPUSamples
 	streamMu         sync.Mutex
 	streamBuffers    map[string]*taskStreamBuffer
+	streamStore      *taskStreamStore
+	sharedTasks      *sharedTaskStore
 	tasksMu          sync.Mutex
 	tasks            map[string]*serverTaskState
-}
-
-type cpuSample struct {
-	timestamp time.Time
-	idle      float64
-	total     float64
+	batches          *batchStore
+	executor         *taskExecutor
+	statusRefresher  *StatusRefresher
+	driftDetector    *DriftDetector
+	hostSecurity     *HostSecurityMonitor
+	metricsCache     *cache.TTL[string, map[string]map[string]interface{}]
+	exporterCache    *cache.TTL[string, map[string]interface{}]
+	maintenance      *maintenance.Manager
+	watchdog         *watchdog.Watchdog
+	gameJobs         *gamejobs.Manager
+	scriptLibrary    *scriptlib.Store
+	agentStreams     *agentstream.Server
+	cluster          *cluster.Node
+	router           *gin.Engine
+	hooks            *hooks.Runner
+	backups          *backup.BackupManager
+	jvmMu            sync.Mutex
+	jvmRecorded      map[string]time.Time
+	liveMu           sync.Mutex
+	liveConcurrency  int
+	liveTimeout      time.Duration
 }
 
 // NewServerHandler creates a new server handler
@@ -75,10 +121,12 @@ func NewServerHandler(
 	lifecycle *server.LifecycleManager,
 	status *server.StatusDetector,
 	process server.ProcessManager,
-	logger *logging.ActivityLogger,
+	activityLogger *logging.ActivityLogger,
 	hub *ws.Hub,
+	metricsWriter *metrics.Writer,
 ) *ServerHandler {
-	return &ServerHandler{
+	tasksCtx, cancelTasks := context.WithCancel(context.Background())
+	h := &ServerHandler{
 		config:           cfg,
 		db:               db,
 		serverManager:    serverManager,
@@ -87,12 +135,116 @@ func NewServerHandler(
 		lifecycleManager: lifecycle,
 		statusDetector:   status,
 		processManager:   process,
-		activityLogger:   logger,
+		activityLogger:   activityLogger,
 		hub:              hub,
-		cpuSamples:       make(map[string]cpuSample),
+		metricsWriter:    metricsWriter,
+		cpuSamples:       metrics.NewCPUSamples(),
 		streamBuffers:    make(map[string]*taskStreamBuffer),
 		tasks:            make(map[string]*serverTaskState),
+		batches:          newBatchStore(),
+		executor:         newTaskExecutor(cfg.Tasks.MaxConcurrent),
+		tasksCtx:         tasksCtx,
+		cancelTasks:      cancelTasks,
+		metricsCache:     cache.New[string, map[string]map[string]interface{}](latestMetricsCacheTTL),
+		exporterCache:    cache.New[string, map[string]interface{}](nodeExporterCacheTTL),
+		jvmRecorded:      make(map[string]time.Time),
+	}
+	if cfg.Tasks.Persist {
+		h.streamStore = newTaskStreamStore(cfg.Tasks.Dir)
+	}
+	h.SetLiveMetricsLimits(cfg.Metrics.LiveConcurrency, time.Duration(cfg.Metrics.LiveTimeout)*time.Second)
+	h.restoreTasks()
+	h.statusRefresher = NewStatusRefresher(h.serverIDs, h.probeStatus, defaultStatusInterval)
+	h.statusRefresher.OnChange(h.broadcastStatusChange)
+	h.driftDetector = NewDriftDetector(h.serverIDs, h.checkDrift, defaultDriftInterval)
+	h.hostSecurity = NewHostSecurityMonitor(h.serverHosts, h.checkHostSecurity, defaultHostSecurityInterval)
+	h.hostSecurity.OnSpike(h.reportSecuritySpike)
+	metricsWriter.OnFlush(h.metricsCache.Clear)
+	return h
+}
+
+// StartStatusRefresher begins checking server status in the background every
+// interval. It stops when the handler shuts down.
+func (h *ServerHandler) StartStatusRefresher(interval time.Duration) {
+	h.statusRefresher.SetInterval(interval)
+	go h.statusRefresher.Run(h.tasksCtx)
+}
+
+// SetStatusInterval changes how often server status is re-checked
+func (h *ServerHandler) SetStatusInterval(interval time.Duration) {
+	h.statusRefresher.SetInterval(interval)
+}
+
+// SetLiveMetricsLimits changes how many servers GetLiveMetrics polls at once
+// and how long it waits for each. Zero values fall back to the defaults.
+func (h *ServerHandler) SetLiveMetricsLimits(concurrency int, timeout time.Duration) {
+	if concurrency <= 0 {
+		concurrency = defaultLiveConcurrency
+	}
+	if timeout <= 0 {
+		timeout = defaultLiveTimeout
+	}
+	h.liveMu.Lock()
+	defer h.liveMu.Unlock()
+	h.liveConcurrency = concurrency
+	h.liveTimeout = timeout
+}
+
+// OnStatusChange registers fn to be called when a server's connection status
+// changes. Changes of servers in an open maintenance window are expected and
+// are not passed on.
+func (h *ServerHandler) OnStatusChange(fn StatusChangeFunc) {
+	h.statusRefresher.OnChange(func(serverID string, previous, current models.ServerConnectionStatus) {
+		if _, active := h.inMaintenance(serverID); active {
+			return
+		}
+		fn(serverID, previous, current)
+	})
+}
+
+// invalidateServer schedules a fresh status check for a server whose state just changed
+func (h *ServerHandler) invalidateServer(serverID string) {
+	h.statusRefresher.Refresh(serverID)
+}
+
+// forgetServer drops what is kept in memory about a deleted server
+func (h *ServerHandler) forgetServer(serverID string) {
+	h.statusRefresher.Forget(serverID)
+	h.driftDetector.Forget(serverID)
+	h.exporterCache.Delete(serverID)
+}
+
+func (h *ServerHandler) serverIDs() []string {
+	servers := h.serverManager.GetAll()
+	ids := make([]string, 0, len(servers))
+	for _, serverDef := range servers {
+		ids = append(ids, serverDef.ID)
 	}
+	return ids
+}
+
+func (h *ServerHandler) probeStatus(serverID string) (HealthCheck, bool) {
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		return HealthCheck{}, false
+	}
+	return h.performHealthCheck(serverID, serverDef, server.SafeSessionName(serverID)), true
+}
+
+// broadcastStatusChange tells clients watching a server that its status changed
+func (h *ServerHandler) broadcastStatusChange(serverID string, previous, current models.ServerConnectionStatus) {
+	_, inMaintenance := h.inMaintenance(serverID)
+	logger.Info("Server status changed", "server_id", serverID, "previous", previous, "status", current, "maintenance", inMaintenance)
+	h.hub.BroadcastToRoom(fmt.Sprintf("server-tasks:%s", serverID), &ws.Message{
+		Type: "server_status",
+		Payload: map[string]interface{}{
+			"server_id":         serverID,
+			"connection_status": current,
+			"previous_status":   previous,
+			"maintenance":       inMaintenance,
+		},
+		Timestamp: time.Now(),
+	})
 }
 
 // WaitForCompletion waits for all pending background operations to finish
@@ -100,6 +252,25 @@ func (h *ServerHandler) WaitForCompletion() {
 	h.pendingOps.Wait()
 }
 
+// Shutdown cancels running tasks (deploys, installs, benchmarks) and waits
+// for them and any lifecycle operations to finish, or for ctx to expire.
+func (h *ServerHandler) Shutdown(ctx context.Context) error {
+	h.cancelTasks()
+
+	done := make(chan struct{})
+	go func() {
+		h.pendingOps.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // ListServers returns all servers with their connection status
 func (h *ServerHandler) ListServers(c *gin.Context) {
 	servers := h.serverManager.GetAll()
@@ -107,14 +278,14 @@ func (h *ServerHandler) ListServers(c *gin.Context) {
 	// Build response with connection status for each server
 	response := make([]models.ServerListItem, 0, len(servers))
 	for _, serverDef := range servers {
-		sessionName := server.SafeSessionName(serverDef.ID)
-		statusInfo, _ := h.statusDetector.DetectStatus(serverDef.ID, sessionName)
-		if statusInfo == nil {
-			statusInfo = &server.ServerStatusInfo{Status: server.StatusOffline}
+		// Servers not checked yet report disconnected until their first check completes
+		connectionStatus := models.StatusDisconnected
+		if snapshot, ok := h.statusRefresher.Get(serverDef.ID); ok {
+			connectionStatus = snapshot.Health.ConnectionStatus
+		} else {
+			h.statusRefresher.Refresh(serverDef.ID)
 		}
 		
-		connectionStatus := h.determineConnectionStatus(serverDef.ID, serverDef, statusInfo)
-		
 		response = append(response, models.ServerListItem{
 			ID:               serverDef.ID,
 			Name:             serverDef.Name,
@@ -134,10 +305,11 @@ func (h *ServerHandler) GetServer(c *gin.Context) {
 	server, found := h.serverManager.GetByID(serverID)
 
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
+	c.Header("ETag", serverETag(server.Version))
 	c.JSON(http.StatusOK, server)
 }
 
@@ -145,7 +317,7 @@ func (h *ServerHandler) GetServer(c *gin.Context) {
 func (h *ServerHandler) CreateServer(c *gin.Context) {
 	var newServer config.ServerDefinition
 	if err := c.ShouldBindJSON(&newServer); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -155,20 +327,23 @@ func (h *ServerHandler) CreateServer(c *gin.Context) {
 	}
 
 	if err := h.persistSSHKey(newServer.ID, &newServer.Connection); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store SSH key", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store SSH key", err.Error())
 		return
 	}
 
-	if err := h.serverManager.Add(newServer); err != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	if err := h.serverManager.Persist(func() error { return h.serverManager.Add(newServer) }); err != nil {
+		if errors.Is(err, config.ErrPersistFailed) {
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save servers")
+			return
+		}
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, err.Error())
 		return
 	}
 
-	if err := h.serverManager.Save(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save servers"})
-		return
+	if created, ok := h.serverManager.GetByID(newServer.ID); ok {
+		newServer = created
 	}
-
+	c.Header("ETag", serverETag(newServer.Version))
 	c.JSON(http.StatusCreated, gin.H{"message": "Server created successfully", "id": newServer.ID, "server": newServer})
 }
 
@@ -177,65 +352,180 @@ func (h *ServerHandler) UpdateServer(c *gin.Context) {
 	serverID := c.Param("id")
 	var updatedServer config.ServerDefinition
 	if err := c.ShouldBindJSON(&updatedServer); err != nil {
-		log.Printf("[UpdateServer] Failed to bind JSON for server %s: %v", serverID, err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.ErrorContext(c.Request.Context(), "Invalid server update payload", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	updatedServer.ID = serverID
 
-	log.Printf("[UpdateServer] Updating server %s with dependencies: install_dir=%s, service_user=%s, use_sudo=%v",
-		serverID, updatedServer.Dependencies.InstallDir, updatedServer.Dependencies.ServiceUser, updatedServer.Dependencies.UseSudo)
-	log.Printf("[UpdateServer] Runtime config: java_xms=%s, java_xmx=%s, java_metaspace=%s, enable_backup=%v, backup_dir=%s, backup_frequency=%s, assets_path=%s, extra_java_args=%s, extra_server_args=%s",
-		updatedServer.Runtime.JavaXms, updatedServer.Runtime.JavaXmx, updatedServer.Runtime.JavaMetaspace,
-		updatedServer.Runtime.EnableBackup, updatedServer.Runtime.BackupDir, updatedServer.Runtime.BackupFrequency,
-		updatedServer.Runtime.AssetsPath, updatedServer.Runtime.ExtraJavaArgs, updatedServer.Runtime.ExtraServerArgs)
+	// If-Match takes precedence over the version in the body; without either the write is unconditional
+	if version, ok, err := ifMatchVersion(c); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	} else if ok {
+		updatedServer.Version = version
+	}
+
+	logger.DebugContext(c.Request.Context(), "Updating server dependencies", "server_id", serverID, "install_dir", updatedServer.Dependencies.InstallDir, "service_user", updatedServer.Dependencies.ServiceUser, "use_sudo", updatedServer.Dependencies.UseSudo)
+	logger.DebugContext(c.Request.Context(), "Updating server runtime config", "server_id", serverID, "java_xms", updatedServer.Runtime.JavaXms, "java_xmx", updatedServer.Runtime.JavaXmx, "java_metaspace", updatedServer.Runtime.JavaMetaspace, "enable_backup", updatedServer.Runtime.EnableBackup, "backup_dir", updatedServer.Runtime.BackupDir, "backup_frequency", updatedServer.Runtime.BackupFrequency, "assets_path", updatedServer.Runtime.AssetsPath, "extra_java_args", updatedServer.Runtime.ExtraJavaArgs, "extra_server_args", updatedServer.Runtime.ExtraServerArgs)
 
 	if err := h.persistSSHKey(updatedServer.ID, &updatedServer.Connection); err != nil {
-		log.Printf("[UpdateServer] Failed to persist SSH key for server %s: %v", serverID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store SSH key", "details": err.Error()})
+		logger.ErrorContext(c.Request.Context(), "Failed to persist SSH key", "server_id", serverID, "error", err)
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store SSH key", err.Error())
 		return
 	}
 
-	if err := h.serverManager.Update(updatedServer); err != nil {
-		log.Printf("[UpdateServer] Failed to update server %s: %v", serverID, err)
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	var saved, previous config.ServerDefinition
+	err := h.serverManager.Persist(func() (err error) {
+		previous, _ = h.serverManager.GetByID(serverID)
+		saved, err = h.serverManager.UpdateVersioned(updatedServer)
+		return err
+	})
+	if errors.Is(err, config.ErrPersistFailed) {
+		logger.ErrorContext(c.Request.Context(), "Failed to save servers config", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save servers")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to update server", "server_id", serverID, "error", err)
+		h.respondServerWriteError(c, err, saved.Version)
 		return
 	}
 
-	if err := h.serverManager.Save(); err != nil {
-		log.Printf("[UpdateServer] Failed to save servers config: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save servers"})
+	h.invalidateServer(serverID)
+	h.recordServerChanges(c, previous, saved)
+	logger.InfoContext(c.Request.Context(), "Server updated", "server_id", serverID)
+	c.Header("ETag", serverETag(saved.Version))
+	c.JSON(http.StatusOK, gin.H{"message": "Server updated successfully", "version": saved.Version})
+}
+
+// recordServerChanges stores the fields an update changed with the request's
+// audit log entry and in the server's activity log
+func (h *ServerHandler) recordServerChanges(c *gin.Context, previous, saved config.ServerDefinition) {
+	changes := config.DiffServers(previous, saved)
+	c.Set(middleware.AuditDetailsKey, map[string]interface{}{"changes": changes, "version": saved.Version})
+	if len(changes) == 0 {
 		return
 	}
 
-	log.Printf("[UpdateServer] Successfully updated and saved server %s", serverID)
-	c.JSON(http.StatusOK, gin.H{"message": "Server updated successfully"})
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	description := fmt.Sprintf("Server definition updated: %s", strings.Join(fields, ", "))
+	if len(fields) > 5 {
+		description = fmt.Sprintf("Server definition updated: %s and %d more", strings.Join(fields[:5], ", "), len(fields)-5)
+	}
+	h.activityLogger.LogActivity(&logging.Activity{
+		ServerID:     saved.ID,
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivityConfigUpdate,
+		Description:  description,
+		Metadata:     map[string]interface{}{"changes": changes, "version": saved.Version},
+		Success:      true,
+	})
 }
 
 // DeleteServer deletes a server definition
 func (h *ServerHandler) DeleteServer(c *gin.Context) {
 	serverID := c.Param("id")
 
-	if err := h.serverManager.Delete(serverID); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	version, _, err := ifMatchVersion(c)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
-	if err := h.serverManager.Save(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save servers"})
+	err = h.serverManager.Persist(func() error { return h.serverManager.DeleteVersioned(serverID, version) })
+	if err != nil && !errors.Is(err, config.ErrPersistFailed) {
+		current, _ := h.serverManager.GetByID(serverID)
+		h.respondServerWriteError(c, err, current.Version)
+		return
+	}
+	h.forgetServer(serverID)
+
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save servers")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Server deleted successfully"})
 }
 
+// ExportServers returns every server definition in the servers.yaml format
+func (h *ServerHandler) ExportServers(c *gin.Context) {
+	data, err := h.serverManager.ExportYAML()
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to export servers")
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="servers.yaml"`)
+	c.Data(http.StatusOK, "application/x-yaml", data)
+}
+
+// ImportServers creates or overwrites server definitions from a servers.yaml body
+func (h *ServerHandler) ImportServers(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, 4<<20))
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Failed to read request body")
+		return
+	}
+
+	var created, updated int
+	err = h.serverManager.Persist(func() (err error) {
+		created, updated, err = h.serverManager.ImportYAML(data)
+		return err
+	})
+	if errors.Is(err, config.ErrPersistFailed) {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save servers")
+		return
+	}
+	if err != nil {
+		apierror.RespondDetails(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error(), gin.H{"created": created, "updated": updated})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Servers imported", "created": created, "updated": updated})
+}
+
+func (h *ServerHandler) respondServerWriteError(c *gin.Context, err error, currentVersion int64) {
+	switch {
+	case errors.Is(err, config.ErrVersionConflict):
+		apierror.RespondDetails(c, http.StatusConflict, apierror.CodeVersionConflict,
+			"Server was modified by another request; reload and try again",
+			gin.H{"current_version": currentVersion})
+	case errors.Is(err, config.ErrServerNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, err.Error())
+	default:
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+	}
+}
+
+func serverETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion parses an If-Match header carrying a server version ETag
+func ifMatchVersion(c *gin.Context) (int64, bool, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, false, nil
+	}
+	header = strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false, fmt.Errorf("invalid If-Match header")
+	}
+	return version, true, nil
+}
+
 // TestConnection validates SSH access and returns basic system info
 func (h *ServerHandler) TestConnection(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 	if h.processManager != nil {
@@ -243,33 +533,31 @@ func (h *ServerHandler) TestConnection(c *gin.Context) {
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
 	run := func(cmd string) string {
-		output, err := conn.Client.RunCommand(cmd)
+		output, err := conn.Client.RunCommandContext(c.Request.Context(), cmd)
 		if err != nil {
 			return ""
 		}
@@ -285,13 +573,13 @@ func (h *ServerHandler) TestConnection(c *gin.Context) {
 	}
 
 	metrics := h.collectMetrics(run)
-	if nodeMetrics, err := h.collectNodeExporterMetrics(serverID, serverDef); err == nil && len(nodeMetrics) > 0 {
+	if nodeMetrics, err := h.collectNodeExporterMetrics(c.Request.Context(), serverID, serverDef); err == nil && len(nodeMetrics) > 0 {
 		metrics = nodeMetrics
 	} else if err != nil {
-		log.Printf("[API] Node exporter metrics unavailable for %s: %v", serverID, err)
+		logger.WarnContext(c.Request.Context(), "Node exporter metrics unavailable", "server_id", serverID, "error", err)
 	}
 	if err := h.recordMetrics(serverID, metrics, "online"); err != nil {
-		log.Printf("[API] Failed to record metrics for %s: %v", serverID, err)
+		logger.ErrorContext(c.Request.Context(), "Failed to record metrics", "server_id", serverID, "error", err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -306,37 +594,76 @@ func (h *ServerHandler) TestConnection(c *gin.Context) {
 	})
 }
 
-// GetMetrics returns recent metrics history for a server
+// GetMetrics returns recent metrics history for a server.
+// ?from= and ?to= narrow it to a range and ?format=csv exports it as CSV.
 func (h *ServerHandler) GetMetrics(c *gin.Context) {
 	serverID := c.Param("id")
-	limitParam := c.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitParam)
-	if err != nil || limit <= 0 || limit > 500 {
-		limit = 50
+	page, ok := parsePage(c, listingPages(c, metricsPages))
+	if !ok {
+		return
+	}
+	from, to, err := listingRange(c)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	where := "server_id = ?"
+	args := []interface{}{serverID}
+	if !from.IsZero() {
+		where += " AND timestamp >= ?"
+		args = append(args, from.UTC().Format(metrics.TimestampFormat))
+	}
+	if !to.IsZero() {
+		where += " AND timestamp < ?"
+		args = append(args, to.UTC().Format(metrics.TimestampFormat))
 	}
 
-	rows, err := h.db.Query(`
-		SELECT timestamp, cpu_usage, memory_used, memory_total, disk_used, disk_total, network_rx, network_tx, status
+	var total int
+	if err := h.db.QueryRowContext(c.Request.Context(), "SELECT COUNT(*) FROM server_metrics WHERE "+where, args...).Scan(&total); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load metrics")
+		return
+	}
+
+	query := `
+		SELECT id, timestamp, cpu_usage, memory_used, memory_total, disk_used, disk_total, network_rx, network_tx, status
 		FROM server_metrics
-		WHERE server_id = ?
-		ORDER BY timestamp DESC
-		LIMIT ?
-	`, serverID, limit)
+		WHERE ` + where
+	if page.After != "" {
+		afterID, err := strconv.ParseInt(page.After, 10, 64)
+		if err != nil {
+			respondPageError(c, database.ErrInvalidCursor, "Failed to load metrics")
+			return
+		}
+		query += " AND id < ?"
+		args = append(args, afterID)
+	}
+	query += " ORDER BY id DESC"
+	if limit := page.QueryLimit(); limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load metrics"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load metrics")
 		return
 	}
 	defer rows.Close()
 
-	metrics := make([]map[string]interface{}, 0)
+	samples := make([]map[string]interface{}, 0)
+	ids := make([]int64, 0)
 	for rows.Next() {
+		var id int64
 		var timestamp string
 		var cpuUsage, memoryUsed, memoryTotal, diskUsed, diskTotal, networkRx, networkTx interface{}
 		var status string
-		if err := rows.Scan(&timestamp, &cpuUsage, &memoryUsed, &memoryTotal, &diskUsed, &diskTotal, &networkRx, &networkTx, &status); err != nil {
+		if err := rows.Scan(&id, &timestamp, &cpuUsage, &memoryUsed, &memoryTotal, &diskUsed, &diskTotal, &networkRx, &networkTx, &status); err != nil {
 			continue
 		}
-		metrics = append(metrics, map[string]interface{}{
+		ids = append(ids, id)
+		samples = append(samples, map[string]interface{}{
+			"id":           id,
 			"timestamp":    timestamp,
 			"cpu_usage":    cpuUsage,
 			"memory_used":  memoryUsed,
@@ -349,7 +676,35 @@ func (h *ServerHandler) GetMetrics(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+	count, hasMore := page.Trim(len(samples))
+	samples = samples[:count]
+	lastKey := ""
+	if count > 0 {
+		lastKey = strconv.FormatInt(ids[count-1], 10)
+	}
+	info := page.Info(total, hasMore, lastKey)
+	if wantsCSV(c) {
+		writeMetricsCSV(c, serverID, samples, info)
+		return
+	}
+	respondPage(c, samples, info, gin.H{"metrics": samples})
+}
+
+var metricsCSVColumns = []string{
+	"id", "timestamp", "cpu_usage", "memory_used", "memory_total",
+	"disk_used", "disk_total", "network_rx", "network_tx", "status",
+}
+
+func writeMetricsCSV(c *gin.Context, serverID string, samples []map[string]interface{}, info database.PageInfo) {
+	rows := make([][]string, 0, len(samples))
+	for _, sample := range samples {
+		row := make([]string, len(metricsCSVColumns))
+		for i, column := range metricsCSVColumns {
+			row[i] = csvValue(sample[column])
+		}
+		rows = append(rows, row)
+	}
+	writeCSVPage(c, fmt.Sprintf("metrics-%s.csv", serverID), metricsCSVColumns, rows, info)
 }
 
 // GetLatestMetrics returns the latest metrics per server
@@ -358,8 +713,12 @@ func (h *ServerHandler) GetLatestMetrics(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"metrics": map[string]interface{}{}})
 		return
 	}
+	if metrics, ok := h.metricsCache.Get(""); ok {
+		c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+		return
+	}
 
-	rows, err := h.db.Query(`
+	rows, err := h.db.QueryContext(c.Request.Context(), `
 		SELECT sm.server_id, sm.timestamp, sm.cpu_usage, sm.memory_used, sm.memory_total, sm.disk_used, sm.disk_total, sm.network_rx, sm.network_tx, sm.status
 		FROM server_metrics sm
 		INNER JOIN (
@@ -369,7 +728,7 @@ func (h *ServerHandler) GetLatestMetrics(c *gin.Context) {
 		) latest ON sm.server_id = latest.server_id AND sm.timestamp = latest.max_ts
 	`)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load latest metrics"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load latest metrics")
 		return
 	}
 	defer rows.Close()
@@ -395,69 +754,108 @@ func (h *ServerHandler) GetLatestMetrics(c *gin.Context) {
 			"status":       status,
 		}
 	}
+	h.metricsCache.Set("", metrics)
 	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
 }
 
 // GetLiveMetrics collects live node_exporter metrics for all servers
 func (h *ServerHandler) GetLiveMetrics(c *gin.Context) {
-	servers := h.serverManager.GetAll()
-	metrics := make(map[string]map[string]interface{})
-	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	for _, serverDef := range servers {
-		if serverDef.ID == "" {
-			continue
+	servers := make([]config.ServerDefinition, 0)
+	for _, serverDef := range h.serverManager.GetAll() {
+		if serverDef.ID != "" {
+			servers = append(servers, serverDef)
 		}
+	}
 
-		wg.Add(1)
-		go func(def config.ServerDefinition) {
-			defer wg.Done()
+	h.liveMu.Lock()
+	concurrency, timeout := h.liveConcurrency, h.liveTimeout
+	h.liveMu.Unlock()
 
-			data, err := h.collectNodeExporterMetrics(def.ID, def)
-			if err != nil || len(data) == 0 {
-				return
-			}
+	metrics := make(map[string]map[string]interface{})
+	var mu sync.Mutex
+	fanOut(c.Request.Context(), servers, concurrency, timeout, func(ctx context.Context, def config.ServerDefinition) {
+		data, err := h.collectNodeExporterMetrics(ctx, def.ID, def)
+		if err != nil || len(data) == 0 {
+			return
+		}
 
-			timestamp := time.Now().UTC().Format(time.RFC3339)
-			data["timestamp"] = timestamp
+		timestamp := time.Now().UTC().Format(time.RFC3339)
+		data["timestamp"] = timestamp
 
-			_ = h.recordMetrics(def.ID, data, "online")
+		_ = h.recordMetrics(def.ID, data, "online")
 
-			mu.Lock()
-			metrics[def.ID] = data
-			mu.Unlock()
-		}(serverDef)
-	}
+		mu.Lock()
+		metrics[def.ID] = data
+		mu.Unlock()
+	})
 
-	wg.Wait()
 	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
 }
 
-// GetServerActivity returns recent activity log entries for a server
+// GetServerActivity returns recent activity log entries for a server.
+// ?format=csv exports them as CSV.
 func (h *ServerHandler) GetServerActivity(c *gin.Context) {
 	serverID := c.Param("id")
-	limitParam := c.DefaultQuery("limit", "50")
-	limit, err := strconv.Atoi(limitParam)
-	if err != nil || limit <= 0 || limit > 500 {
-		limit = 50
+	page, ok := parsePage(c, listingPages(c, activityPages))
+	if !ok {
+		return
 	}
 	activityType := strings.TrimSpace(c.Query("type"))
 
-	activities, err := h.activityLogger.GetActivities(serverID, activityType, time.Time{}, limit)
+	activities, info, err := h.activityLogger.ListActivities(serverID, activityType, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load activity log"})
+		respondPageError(c, err, "Failed to load activity log")
+		return
+	}
+
+	if wantsCSV(c) {
+		writeActivityCSV(c, serverID, activities, info)
 		return
 	}
+	respondPage(c, activities, info, gin.H{"activities": activities})
+}
+
+func writeActivityCSV(c *gin.Context, serverID string, activities []*logging.Activity, info database.PageInfo) {
+	rows := make([][]string, 0, len(activities))
+	for _, activity := range activities {
+		userID := ""
+		if activity.UserID != nil {
+			userID = strconv.FormatInt(*activity.UserID, 10)
+		}
+		metadata := ""
+		if len(activity.Metadata) > 0 {
+			if data, err := json.Marshal(activity.Metadata); err == nil {
+				metadata = string(data)
+			}
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(activity.ID, 10),
+			activity.Timestamp.UTC().Format(time.RFC3339),
+			activity.ServerID,
+			userID,
+			activity.ActivityType,
+			activity.Description,
+			strconv.FormatBool(activity.Success),
+			activity.ErrorMessage,
+			metadata,
+		})
+	}
+	writeCSVPage(c, fmt.Sprintf("activity-%s.csv", serverID), []string{
+		"id", "timestamp", "server_id", "user_id", "activity_type", "description", "success", "error_message", "metadata",
+	}, rows, info)
+}
 
-	c.JSON(http.StatusOK, gin.H{"activities": activities})
+// SetHooks runs the configured hooks around server starts and release deploys
+func (h *ServerHandler) SetHooks(runner *hooks.Runner) {
+	h.hooks = runner
+	h.lifecycleManager.SetHooks(runner)
 }
 
 // GetServerTasks returns recent tasks for a server
 func (h *ServerHandler) GetServerTasks(c *gin.Context) {
 	serverID := c.Param("id")
 	if _, found := h.serverManager.GetByID(serverID); !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 	if serverDef, ok := h.serverManager.GetByID(serverID); ok {
@@ -475,6 +873,7 @@ func (h *ServerHandler) GetServerTasks(c *gin.Context) {
 			"status":     record.Status,
 			"started_at": record.StartedAt,
 			"last_line":  record.LastLine,
+			"rerunnable": record.rerunnable(),
 		}
 		if record.FinishedAt != nil {
 			entry["finished_at"] = *record.FinishedAt
@@ -482,44 +881,82 @@ func (h *ServerHandler) GetServerTasks(c *gin.Context) {
 		if record.Error != "" {
 			entry["error"] = record.Error
 		}
+		if record.RerunOf != "" {
+			entry["rerun_of"] = record.RerunOf
+		}
 		response = append(response, entry)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"tasks": response})
 }
 
+// GetServerTaskLog returns a task's complete output from disk, including lines
+// that no longer fit in the in-memory stream buffer
+func (h *ServerHandler) GetServerTaskLog(c *gin.Context) {
+	serverID := c.Param("id")
+	taskID := c.Param("taskId")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	var (
+		lines []taskStreamLine
+		err   error
+	)
+	if h.sharedTasks != nil {
+		lines, err = h.sharedTasks.readLog(serverID, taskID)
+	} else {
+		lines, err = h.streamStore.readLog(serverID, taskID)
+	}
+	if errors.Is(err, errTaskLogNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Task log not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to read task log", "server_id", serverID, "task_id", taskID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read task log")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task_id": taskID, "lines": lines})
+}
+
 // GetNodeExporterStatus checks node_exporter installation and service status
 func (h *ServerHandler) GetNodeExporterStatus(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
+	}
+
+	if cached, ok := h.exporterCache.Get(serverID); ok {
+		c.JSON(http.StatusOK, withNodeExporterURL(cached, serverDef))
+		return
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
@@ -536,7 +973,7 @@ func (h *ServerHandler) GetNodeExporterStatus(c *gin.Context) {
 			Success:      false,
 			ErrorMessage: err.Error(),
 		})
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check node_exporter status", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check node_exporter status", err.Error())
 		return
 	}
 
@@ -552,8 +989,21 @@ func (h *ServerHandler) GetNodeExporterStatus(c *gin.Context) {
 		Success:      true,
 	})
 
-	status["url"] = resolveNodeExporterURL(serverDef)
-	c.JSON(http.StatusOK, status)
+	h.exporterCache.Set(serverID, status)
+	c.JSON(http.StatusOK, withNodeExporterURL(status, serverDef))
+}
+
+// withNodeExporterURL copies a status so cached entries are never modified
+func withNodeExporterURL(status map[string]interface{}, serverDef config.ServerDefinition) map[string]interface{} {
+	response := make(map[string]interface{}, len(status)+2)
+	for key, value := range status {
+		response[key] = value
+	}
+	response["local"] = metrics.IsLocal(serverDef)
+	if !metrics.IsLocal(serverDef) {
+		response["url"] = resolveNodeExporterURL(serverDef)
+	}
+	return response
 }
 
 // InstallNodeExporter installs node_exporter and streams output to the task stream
@@ -561,40 +1011,38 @@ func (h *ServerHandler) InstallNodeExporter(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
 	c.JSON(http.StatusAccepted, gin.H{"message": "Node exporter install started"})
 
-	go func() {
-		task := h.startTask(serverID, "node-exporter-install")
+	h.goTask(c, serverID, "node-exporter-install", func(ctx context.Context, task *taskRecord) {
+		defer h.exporterCache.Delete(serverID)
 		outputLog := &strings.Builder{}
 		var outputMu sync.Mutex
 		emit := func(line string) {
@@ -608,7 +1056,7 @@ func (h *ServerHandler) InstallNodeExporter(c *gin.Context) {
 
 		installScript := NodeExporterInstallScript
 		writer := newLineSinkWriter(emit)
-		err = conn.Client.StreamCommand(bashDollarQuotedCommand(installScript), writer, writer)
+		err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(installScript), writer, writer)
 		writer.FlushRemaining()
 
 		status, statusErr := h.checkNodeExporterStatus(conn.Client)
@@ -659,7 +1107,7 @@ func (h *ServerHandler) InstallNodeExporter(c *gin.Context) {
 			},
 			Success: true,
 		})
-	}()
+	})
 }
 
 func (h *ServerHandler) collectMetrics(run func(string) string) map[string]interface{} {
@@ -694,14 +1142,23 @@ func (h *ServerHandler) collectMetrics(run func(string) string) map[string]inter
 	return metrics
 }
 
-func (h *ServerHandler) collectNodeExporterMetrics(serverID string, serverDef config.ServerDefinition) (map[string]interface{}, error) {
+func (h *ServerHandler) collectNodeExporterMetrics(ctx context.Context, serverID string, serverDef config.ServerDefinition) (map[string]interface{}, error) {
+	// Servers on the manager's host need no node_exporter
+	if metrics.IsLocal(serverDef) {
+		return metrics.LocalMetrics(serverID, serverDef, h.cpuSamples)
+	}
+
 	url := resolveNodeExporterURL(serverDef)
 	if url == "" {
 		return nil, fmt.Errorf("node exporter URL not resolved")
 	}
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
 	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -745,7 +1202,7 @@ func (h *ServerHandler) collectNodeExporterMetrics(serverID string, serverDef co
 	}
 
 	if parsed.cpuTotal > 0 {
-		if usage, ok := h.calculateCPUUsage(serverID, parsed.cpuIdle, parsed.cpuTotal); ok {
+		if usage, ok := h.cpuSamples.Usage(serverID, parsed.cpuIdle, parsed.cpuTotal); ok {
 			metrics["cpu_usage"] = usage
 		}
 	}
@@ -753,38 +1210,6 @@ func (h *ServerHandler) collectNodeExporterMetrics(serverID string, serverDef co
 	return metrics, nil
 }
 
-func (h *ServerHandler) calculateCPUUsage(serverID string, idle float64, total float64) (float64, bool) {
-	h.cpuMu.Lock()
-	defer h.cpuMu.Unlock()
-
-	now := time.Now()
-	prev, ok := h.cpuSamples[serverID]
-	h.cpuSamples[serverID] = cpuSample{timestamp: now, idle: idle, total: total}
-	if !ok {
-		return 0, false
-	}
-
-	if total <= prev.total {
-		return 0, false
-	}
-
-	deltaIdle := idle - prev.idle
-	deltaTotal := total - prev.total
-	if deltaTotal <= 0 {
-		return 0, false
-	}
-
-	usage := (1 - (deltaIdle / deltaTotal)) * 100
-	if usage < 0 {
-		usage = 0
-	}
-	if usage > 100 {
-		usage = 100
-	}
-
-	return usage, true
-}
-
 type nodeExporterMetrics struct {
 	memoryTotal     float64
 	memoryAvailable float64
@@ -996,27 +1421,12 @@ func normalizeNodeExporterURL(raw string) string {
 	return url
 }
 
+// recordMetrics queues a metrics row; the latest-metrics cache is cleared once it is written
 func (h *ServerHandler) recordMetrics(serverID string, metrics map[string]interface{}, status string) error {
 	if h.db == nil {
 		return nil
 	}
-
-	_, err := h.db.Exec(
-		"INSERT INTO server_metrics ("+
-			"server_id, cpu_usage, memory_used, memory_total, disk_used, disk_total, network_rx, network_tx, status"+
-			") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		serverID,
-		metrics["cpu_usage"],
-		metrics["memory_used"],
-		metrics["memory_total"],
-		metrics["disk_used"],
-		metrics["disk_total"],
-		metrics["network_rx"],
-		metrics["network_tx"],
-		status,
-	)
-
-	return err
+	return h.metricsWriter.Record(serverID, metrics, status)
 }
 
 func parseFloat(value string) (float64, error) {
@@ -1153,6 +1563,31 @@ func uploadFileSFTP(client *sftp.Client, localPath, remotePath string, mode os.F
 	return nil
 }
 
+// uploadConfigFiles uploads rendered config files into a staging directory
+// only the SSH user can read, for the deploy script to move into place
+func uploadConfigFiles(client *ssh.Client, dir string, files []config.RenderedFile) error {
+	sftpClient, err := client.NewSFTP()
+	if err != nil {
+		return fmt.Errorf("failed to open SFTP: %w", err)
+	}
+	defer sftpClient.Close()
+
+	if err := sftpClient.Mkdir(dir); err != nil {
+		return err
+	}
+	_ = sftpClient.Chmod(dir, 0700)
+	for _, file := range files {
+		target := path.Join(dir, file.Path)
+		if err := sftpClient.MkdirAll(path.Dir(target)); err != nil {
+			return err
+		}
+		if err := uploadBytesSFTP(sftpClient, target, file.Data, 0600); err != nil {
+			return fmt.Errorf("%s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
 func uploadBytesSFTP(client *sftp.Client, remotePath string, data []byte, mode os.FileMode) error {
 	remote, err := client.Create(remotePath)
 	if err != nil {
@@ -1167,43 +1602,42 @@ func uploadBytesSFTP(client *sftp.Client, remotePath string, data []byte, mode o
 	return nil
 }
 
-func ensureAgentBinary(arch string, dataDir string, emit func(string)) (string, error) {
+// ensureAgentBinary returns the local agent binary for arch, extracting or
+// building it if startup did not, and verifies its checksum so a corrupted or
+// replaced file is never uploaded
+func ensureAgentBinary(arch string, dataDir string, emit func(string)) (string, string, error) {
 	if dataDir == "" {
-		return "", fmt.Errorf("data dir not configured")
-	}
-	binDir := filepath.Join(dataDir, "agent-binaries")
-	if err := os.MkdirAll(binDir, 0755); err != nil {
-		return "", err
+		return "", "", fmt.Errorf("data dir not configured")
 	}
-	binPath := filepath.Join(binDir, fmt.Sprintf("hytale-agent-linux-%s", arch))
-	if _, err := os.Stat(binPath); err == nil {
-		return binPath, nil
-	}
-
-	if emit != nil {
-		emit("Agent binary missing; attempting local build...")
+	binPath := agentbin.Path(dataDir, arch)
+	if _, err := os.Stat(binPath); err != nil {
+		if agentbin.Embedded(arch) {
+			if _, err := agentbin.Extract(dataDir, arch); err != nil {
+				return "", "", err
+			}
+		} else {
+			if emit != nil {
+				emit("Agent binary missing; attempting local build...")
+			}
+			startDir, err := os.Getwd()
+			if err != nil {
+				return "", "", err
+			}
+			modDir, err := findGoModDir(startDir)
+			if err != nil {
+				return "", "", err
+			}
+			if _, err := agentbin.Build(modDir, dataDir, arch); err != nil {
+				return "", "", fmt.Errorf("build agent: %w", err)
+			}
+		}
 	}
 
-	startDir, err := os.Getwd()
-	if err != nil {
-		return "", err
-	}
-	modDir, err := findGoModDir(startDir)
+	sum, err := agentbin.Verify(binPath)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-
-	cmd := exec.Command("go", "build", "-o", binPath, "./agent")
-	cmd.Dir = modDir
-	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+arch, "CGO_ENABLED=0")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("build agent: %w: %s", err, out.String())
-	}
-
-	return binPath, nil
+	return binPath, sum, nil
 }
 
 func findGoModDir(start string) (string, error) {
@@ -1265,6 +1699,7 @@ type ReleaseDeployRequest struct {
 	AssetsPath        *string `json:"assets_path"`
 	ExtraJavaArgs     *string `json:"extra_java_args"`
 	ExtraServerArgs   *string `json:"extra_server_args"`
+	KeepReleases      *int    `json:"keep_releases"` // earlier releases kept for rollback
 }
 
 type TransferBenchmarkRequest struct {
@@ -1274,18 +1709,28 @@ type TransferBenchmarkRequest struct {
 }
 
 type taskStreamLine struct {
-	Line      string
-	Task      string
-	TaskID    string
-	Timestamp time.Time
+	// Seq numbers a server's lines in order, so a subscriber can resume after
+	// the last line it received
+	Seq       int64     `json:"seq,omitempty"`
+	Line      string    `json:"line"`
+	Task      string    `json:"task"`
+	TaskID    string    `json:"task_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// RequestID is the ID of the request that started the task
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type taskStatus string
 
 const (
+	// taskStatusQueued marks a task waiting for the executor to run it
+	taskStatusQueued   taskStatus = "queued"
 	taskStatusRunning  taskStatus = "running"
 	taskStatusComplete taskStatus = "complete"
 	taskStatusFailed   taskStatus = "failed"
+	// taskStatusInterrupted marks a task still running when its manager
+	// instance stopped
+	taskStatusInterrupted taskStatus = "interrupted"
 )
 
 // AgentState represents the state information returned by the agent
@@ -1295,6 +1740,7 @@ type AgentState struct {
 	Services      map[string]string `json:"services"`
 	Ports         map[int]bool  `json:"ports"`
 	JavaProcesses []JavaProcess `json:"java"`
+	JVM           []JVMMetrics  `json:"jvm"`
 }
 
 // JavaProcess represents a Java process detected by the agent
@@ -1303,6 +1749,12 @@ type JavaProcess struct {
 	User        string `json:"user"`
 	CommandLine string `json:"cmdline"`
 	ListenPorts []int  `json:"listen_ports"`
+	// CPUPercent and MemoryPercent are shares of the whole host
+	CPUPercent    float64 `json:"cpu_percent"`
+	RSSBytes      int64   `json:"rss_bytes"`
+	MemoryPercent float64 `json:"memory_percent"`
+	OpenFiles     int     `json:"open_files"`
+	UptimeSeconds int64   `json:"uptime_seconds"`
 }
 
 // HealthCheck represents comprehensive server health information
@@ -1312,6 +1764,8 @@ type HealthCheck struct {
 	AgentStatus      AgentHealthStatus             `json:"agent"`
 	ProcessStatus    ProcessHealthStatus           `json:"process"`
 	ScreenStatus     ScreenHealthStatus            `json:"screen"`
+	// Game is what the server's game ports answered, when they were probed
+	Game *query.Status `json:"game,omitempty"`
 }
 
 // SSHHealthStatus represents SSH connectivity status
@@ -1330,6 +1784,10 @@ type AgentHealthStatus struct {
 	JavaProcesses []JavaProcess     `json:"java_processes,omitempty"`
 	ListeningPorts map[int]bool     `json:"listening_ports,omitempty"`
 	Services      map[string]string `json:"services,omitempty"`
+	// JVM is the agent's last reading of the Hytale server's JVM
+	JVM *JVMMetrics `json:"jvm,omitempty"`
+	// Transport is stream when the agent pushed its state, poll when it was fetched
+	Transport string `json:"transport,omitempty"`
 }
 
 // ProcessHealthStatus represents Hytale server process status
@@ -1339,6 +1797,11 @@ type ProcessHealthStatus struct {
 	Port          string `json:"port,omitempty"`
 	UptimeSeconds int64  `json:"uptime_seconds"`
 	DetectionMethod string `json:"detection_method,omitempty"`
+	// Resource usage of the process, when the agent detected it
+	CPUPercent    float64 `json:"cpu_percent,omitempty"`
+	MemoryBytes   int64   `json:"memory_bytes,omitempty"`
+	MemoryPercent float64 `json:"memory_percent,omitempty"`
+	OpenFiles     int     `json:"open_files,omitempty"`
 }
 
 // ScreenHealthStatus represents screen session status
@@ -1356,6 +1819,10 @@ type taskRecord struct {
 	FinishedAt *time.Time `json:"finished_at,omitempty"`
 	LastLine   string     `json:"last_line,omitempty"`
 	Error      string     `json:"error,omitempty"`
+	RequestID  string     `json:"request_id,omitempty"`
+	RerunOf    string     `json:"rerun_of,omitempty"`
+	// origin is the request that started the task, when one did
+	origin *taskOrigin
 }
 
 type serverTaskState struct {
@@ -1367,40 +1834,52 @@ type taskStreamBuffer struct {
 	mu    sync.RWMutex
 	max   int
 	lines []taskStreamLine
+	// seq is the number of the last line added
+	seq int64
 }
 
 func newTaskStreamBuffer(max int) *taskStreamBuffer {
 	return &taskStreamBuffer{max: max, lines: make([]taskStreamLine, 0, max)}
 }
 
-func (b *taskStreamBuffer) Add(line taskStreamLine) {
+func (b *taskStreamBuffer) Add(line taskStreamLine) taskStreamLine {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	return b.add(line)
+}
+
+// add keeps a line, numbering it after the last one unless it already has a
+// number. The caller holds mu.
+func (b *taskStreamBuffer) add(line taskStreamLine) taskStreamLine {
+	if line.Seq == 0 {
+		line.Seq = b.seq + 1
+	}
+	if line.Seq > b.seq {
+		b.seq = line.Seq
+	}
 	b.lines = append(b.lines, line)
 	if len(b.lines) > b.max {
 		b.lines = b.lines[len(b.lines)-b.max:]
 	}
+	return line
 }
 
-func (b *taskStreamBuffer) GetLines() []taskStreamLine {
+// LinesSince returns the kept lines numbered after since
+func (b *taskStreamBuffer) LinesSince(since int64) []taskStreamLine {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	result := make([]taskStreamLine, len(b.lines))
-	copy(result, b.lines)
+	result := make([]taskStreamLine, 0, len(b.lines))
+	for _, line := range b.lines {
+		if line.Seq > since {
+			result = append(result, line)
+		}
+	}
 	return result
 }
 
-func (h *ServerHandler) InstallDependencies(c *gin.Context) {
-	serverID := c.Param("id")
-	serverDef, found := h.serverManager.GetByID(serverID)
-	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
-		return
-	}
-
-	var req DependenciesInstallRequest
-	_ = c.ShouldBindJSON(&req)
-
+// resolveDependencies returns the server's dependency settings, or the
+// defaults used by the dependency installer when none are configured
+func resolveDependencies(serverDef config.ServerDefinition) config.DependenciesConfig {
 	merged := config.DependenciesConfig{
 		SkipUpdate:  false,
 		UseSudo:     true,
@@ -1408,7 +1887,6 @@ func (h *ServerHandler) InstallDependencies(c *gin.Context) {
 		ServiceUser: "hytale",
 		InstallDir:  "~/hytale-server",
 	}
-
 	if serverDef.Dependencies.Configured {
 		merged = serverDef.Dependencies
 		if merged.ServiceUser == "" {
@@ -1418,6 +1896,21 @@ func (h *ServerHandler) InstallDependencies(c *gin.Context) {
 			merged.InstallDir = "~/hytale-server"
 		}
 	}
+	return merged
+}
+
+func (h *ServerHandler) InstallDependencies(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	var req DependenciesInstallRequest
+	_ = c.ShouldBindJSON(&req)
+
+	merged := resolveDependencies(serverDef)
 
 	if req.SkipUpdate != nil {
 		merged.SkipUpdate = *req.SkipUpdate
@@ -1441,40 +1934,36 @@ func (h *ServerHandler) InstallDependencies(c *gin.Context) {
 	if req.SaveConfig {
 		serverDef.Dependencies = merged
 		serverDef.Dependencies.Configured = true
-		_ = h.serverManager.Update(serverDef)
-		_ = h.serverManager.Save()
+		_ = h.serverManager.Persist(func() error { return h.serverManager.Update(serverDef) })
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
 	c.JSON(http.StatusAccepted, gin.H{"message": "Dependency install started"})
 
-	go func() {
-		task := h.startTask(serverID, "dependencies-install")
+	h.goTask(c, serverID, "dependencies-install", func(ctx context.Context, task *taskRecord) {
 		outputLog := &strings.Builder{}
 		var outputMu sync.Mutex
 		emit := func(line string) {
@@ -1507,7 +1996,7 @@ func (h *ServerHandler) InstallDependencies(c *gin.Context) {
 		script = strings.ReplaceAll(script, "{{INSTALL_DIR}}", escapeForScriptPath(merged.InstallDir))
 
 		writer := newLineSinkWriter(emit)
-		err = conn.Client.StreamCommand(bashDollarQuotedCommand(script), writer, writer)
+		err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(script), writer, writer)
 		close(done)
 		keepAlive.Stop()
 		writer.FlushRemaining()
@@ -1541,14 +2030,14 @@ func (h *ServerHandler) InstallDependencies(c *gin.Context) {
 			},
 			Success: true,
 		})
-	}()
+	})
 }
 
 func (h *ServerHandler) InstallAgent(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
@@ -1561,38 +2050,38 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
 	managerHost := resolveManagerHost(c, h.config)
 	agentUser := "hytale-agent"
+	// Only agents of a manager accepting streams are told to push
+	push := h.agentStreams != nil
+	streamAddr := agentStreamAddr(managerHost, h.config.Agents)
 
 	c.JSON(http.StatusAccepted, gin.H{"message": "Agent install started"})
 
-	go func() {
-		task := h.startTask(serverID, "agent-install")
+	h.goTask(c, serverID, "agent-install", func(ctx context.Context, task *taskRecord) {
 		outputLog := &strings.Builder{}
 		var outputMu sync.Mutex
 		emit := func(line string) {
@@ -1618,7 +2107,7 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 
 		emit("Preparing agent artifacts...")
 
-		rawArch, err := conn.Client.RunCommand("uname -m")
+		rawArch, err := conn.Client.RunCommandContext(ctx, "uname -m")
 		if err != nil {
 			emit("Install failed: unable to detect architecture")
 			h.finishTask(serverID, task.ID, err)
@@ -1631,12 +2120,13 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 			return
 		}
 
-		localBin, err := ensureAgentBinary(arch, h.config.Storage.DataDir, emit)
+		localBin, localSum, err := ensureAgentBinary(arch, h.config.Storage.DataDir, emit)
 		if err != nil {
-			emit("Install failed: agent binary unavailable")
+			emit("Install failed: agent binary unavailable: " + err.Error())
 			h.finishTask(serverID, task.ID, err)
 			return
 		}
+		emit(fmt.Sprintf("Agent binary %s verified (sha256 %s)", filepath.Base(localBin), localSum[:12]))
 
 		hostUUID := strings.TrimSpace(fetchRemoteMachineID(conn))
 		if hostUUID == "" {
@@ -1660,48 +2150,11 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 			return
 		}
 
-		clientCert, err := agentcert.GetClientCert(h.db.DB, "server-manager")
-		if err != nil {
-			emit("Install failed: unable to load manager client cert")
+		if err := h.renewManagerClientCert(ca, managerClientCertRenewBefore); err != nil {
+			emit("Install failed: unable to issue manager client cert")
 			h.finishTask(serverID, task.ID, err)
 			return
 		}
-		if clientCert == nil || time.Until(clientCert.ExpiresAt) < (30*24*time.Hour) {
-			clientPEM, clientKeyPEM, clientSerial, clientNotAfter, clientFingerprint, err := agentcert.IssueClientCert(ca, "server-manager", 365*24*time.Hour)
-			if err != nil {
-				emit("Install failed: unable to issue manager client cert")
-				h.finishTask(serverID, task.ID, err)
-				return
-			}
-
-			tx, err := h.db.DB.Begin()
-			if err != nil {
-				emit("Install failed: unable to store manager client cert")
-				h.finishTask(serverID, task.ID, err)
-				return
-			}
-			if err := agentcert.InsertClientCert(tx, "server-manager", clientSerial, clientFingerprint, clientPEM, clientKeyPEM, clientNotAfter); err != nil {
-				_ = tx.Rollback()
-				emit("Install failed: unable to store manager client cert")
-				h.finishTask(serverID, task.ID, err)
-				return
-			}
-			if err := tx.Commit(); err != nil {
-				emit("Install failed: unable to store manager client cert")
-				h.finishTask(serverID, task.ID, err)
-				return
-			}
-			clientCert = &agentcert.ClientCert{
-				Name:        "server-manager",
-				CertPEM:     clientPEM,
-				KeyPEM:      clientKeyPEM,
-				Serial:      clientSerial,
-				Fingerprint: clientFingerprint,
-				ExpiresAt:   clientNotAfter,
-			}
-			_ = os.WriteFile(filepath.Join(caDir, "manager-client.crt"), clientPEM, 0644)
-			_ = os.WriteFile(filepath.Join(caDir, "manager-client.key"), clientKeyPEM, 0600)
-		}
 
 		tx, err := h.db.DB.Begin()
 		if err != nil {
@@ -1721,6 +2174,17 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 			return
 		}
 
+		// Agents that push their events authenticate with a client certificate of their own
+		var agentCertPEM, agentKeyPEM []byte
+		if push {
+			agentCertPEM, agentKeyPEM, err = h.issueAgentClientCert(ca, hostUUID, serverID)
+			if err != nil {
+				emit("Install failed: unable to issue agent client cert")
+				h.finishTask(serverID, task.ID, err)
+				return
+			}
+		}
+
 		sftpClient, err := conn.Client.NewSFTPWithOptions(
 			sftp.MaxPacketUnchecked(131072),
 			sftp.UseConcurrentWrites(true),
@@ -1757,6 +2221,19 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 			h.finishTask(serverID, task.ID, err)
 			return
 		}
+		if push {
+			if err := uploadBytesSFTP(sftpClient, path.Join(remoteHTTPSDir, "agent.crt"), agentCertPEM, 0644); err != nil {
+				emit("Install failed: unable to upload agent client cert")
+				h.finishTask(serverID, task.ID, err)
+				return
+			}
+			if err := uploadBytesSFTP(sftpClient, path.Join(remoteHTTPSDir, "agent.key"), agentKeyPEM, 0600); err != nil {
+				emit("Install failed: unable to upload agent client key")
+				h.finishTask(serverID, task.ID, err)
+				return
+			}
+			emit("Agent will push its events to " + streamAddr)
+		}
 
 		script := ServerAgentInstallScript
 		script = strings.ReplaceAll(script, "{{USE_SUDO}}", boolToScript(useSudo))
@@ -1764,9 +2241,11 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 		script = strings.ReplaceAll(script, "{{AGENT_SERVER_ADDR}}", escapeForScript(managerHost))
 		script = strings.ReplaceAll(script, "{{AGENT_STAGED_BIN}}", escapeForScript(remoteBin))
 		script = strings.ReplaceAll(script, "{{AGENT_HTTPS_CERTS_DIR}}", escapeForScript(remoteHTTPSDir))
+		script = strings.ReplaceAll(script, "{{AGENT_PUSH}}", boolToScript(push))
+		script = strings.ReplaceAll(script, "{{AGENT_STREAM_ADDR}}", escapeForScript(streamAddr))
 
 		writer := newLineSinkWriter(emit)
-		err = conn.Client.StreamCommand(bashDollarQuotedCommand(script), writer, writer)
+		err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(script), writer, writer)
 		close(done)
 		keepAlive.Stop()
 		writer.FlushRemaining()
@@ -1799,57 +2278,40 @@ func (h *ServerHandler) InstallAgent(c *gin.Context) {
 			},
 			Success: true,
 		})
-	}()
+	})
 }
 
 func (h *ServerHandler) CheckDependencies(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
-	merged := config.DependenciesConfig{
-		SkipUpdate:  false,
-		UseSudo:     true,
-		CreateUser:  true,
-		ServiceUser: "hytale",
-		InstallDir:  "~/hytale-server",
-	}
-	if serverDef.Dependencies.Configured {
-		merged = serverDef.Dependencies
-		if merged.ServiceUser == "" {
-			merged.ServiceUser = "hytale"
-		}
-		if merged.InstallDir == "" {
-			merged.InstallDir = "~/hytale-server"
-		}
-	}
+	merged := resolveDependencies(serverDef)
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
@@ -1857,9 +2319,9 @@ func (h *ServerHandler) CheckDependencies(c *gin.Context) {
 	script = strings.ReplaceAll(script, "{{SERVICE_USER}}", escapeForScript(merged.ServiceUser))
 	script = strings.ReplaceAll(script, "{{INSTALL_DIR}}", escapeForScriptPath(merged.InstallDir))
 
-	output, err := conn.Client.RunCommand(bashDollarQuotedCommand(script))
+	output, err := conn.Client.RunCommandContext(c.Request.Context(), bashDollarQuotedCommand(script))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Dependency check failed", "details": err.Error(), "output": output})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Dependency check failed", gin.H{"details": err.Error(), "output": output})
 		return
 	}
 
@@ -1873,40 +2335,40 @@ func (h *ServerHandler) GetAgentState(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	if strings.TrimSpace(serverDef.Connection.Host) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Server host is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Server host is required")
 		return
 	}
 
 	clientCert, err := agentcert.GetClientCert(h.db.DB, "server-manager")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load manager client cert", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load manager client cert", err.Error())
 		return
 	}
 	if clientCert == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Manager client cert not found. Install agent first."})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Manager client cert not found. Install agent first.")
 		return
 	}
 
 	cert, err := tls.X509KeyPair(clientCert.CertPEM, clientCert.KeyPEM)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid manager client cert", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Invalid manager client cert", err.Error())
 		return
 	}
 
 	caPath := filepath.Join(h.config.Storage.DataDir, "agent-ca", "ca.crt")
 	caData, err := os.ReadFile(caPath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read agent CA", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read agent CA", err.Error())
 		return
 	}
 	pool := x509.NewCertPool()
 	if !pool.AppendCertsFromPEM(caData) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid agent CA"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Invalid agent CA")
 		return
 	}
 
@@ -1914,9 +2376,10 @@ func (h *ServerHandler) GetAgentState(c *gin.Context) {
 		Timeout: 8 * time.Second,
 		Transport: &http.Transport{
 			TLSClientConfig: &tls.Config{
-				MinVersion:   tls.VersionTLS12,
-				RootCAs:      pool,
-				Certificates: []tls.Certificate{cert},
+				MinVersion:            tls.VersionTLS12,
+				RootCAs:               pool,
+				Certificates:          []tls.Certificate{cert},
+				VerifyPeerCertificate: agentcert.RejectRevoked(h.db.DB),
 			},
 		},
 	}
@@ -1925,30 +2388,30 @@ func (h *ServerHandler) GetAgentState(c *gin.Context) {
 	resp, err := client.Get(url)
 	if err != nil {
 		diag := h.diagnoseAgentConnection(serverDef)
-		payload := gin.H{"error": "Failed to fetch agent state", "details": err.Error()}
+		details := gin.H{"details": err.Error()}
 		if diag != nil {
-			payload["agent_status"] = diag.Status
-			payload["listening"] = diag.Listening
-			payload["journal"] = diag.Journal
-			payload["process"] = diag.Process
+			details["agent_status"] = diag.Status
+			details["listening"] = diag.Listening
+			details["journal"] = diag.Journal
+			details["process"] = diag.Process
 		}
-		c.JSON(http.StatusBadGateway, payload)
+		apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Failed to fetch agent state", details)
 		return
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to read agent response", "details": err.Error()})
+		apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Failed to read agent response", err.Error())
 		return
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Agent returned error", "status": resp.StatusCode, "body": string(body)})
+		apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Agent returned error", gin.H{"status": resp.StatusCode, "body": string(body)})
 		return
 	}
 
 	if len(body) == 0 {
-		c.JSON(http.StatusBadGateway, gin.H{"error": "Empty agent response"})
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Empty agent response")
 		return
 	}
 
@@ -1959,17 +2422,17 @@ func (h *ServerHandler) KillProcess(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	var req ProcessKillRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request")
 		return
 	}
 	if req.PID <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "pid must be > 0"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "pid must be > 0")
 		return
 	}
 
@@ -1983,35 +2446,34 @@ func (h *ServerHandler) KillProcess(c *gin.Context) {
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
 	cmd := fmt.Sprintf("%skill -TERM %d >/dev/null 2>&1; sleep 1; %skill -0 %d >/dev/null 2>&1 || exit 0; %skill -KILL %d", sudo, req.PID, sudo, req.PID, sudo, req.PID)
-	output, err := conn.Client.RunCommand(bashDollarQuotedCommand(cmd))
+	output, err := conn.Client.RunCommandContext(c.Request.Context(), bashDollarQuotedCommand(cmd))
+	h.invalidateServer(serverID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to kill process", "details": err.Error(), "output": output})
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to kill process", gin.H{"details": err.Error(), "output": output})
 		return
 	}
 
@@ -2037,14 +2499,12 @@ type agentConnDiag struct {
 
 func (h *ServerHandler) diagnoseAgentConnection(serverDef config.ServerDefinition) *agentConnDiag {
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
@@ -2082,50 +2542,54 @@ func (h *ServerHandler) DeployRelease(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	var req ReleaseDeployRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 	if strings.TrimSpace(req.PackageName) == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "package_name is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "package_name is required")
+		return
+	}
+	// Rendered up front so a broken template fails the request, not the deploy
+	configFiles, err := config.RenderTemplates(h.config.Storage.ConfigDir, serverDef)
+	if err != nil {
+		apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, fmt.Sprintf("Failed to render config templates: %v", err))
 		return
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH key path is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH key path is required")
 		return
 	}
 	if sshConfig.AuthMethod == "password" && sshConfig.Password == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "SSH password is required"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "SSH password is required")
 		return
 	}
 
 	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect via SSH", "details": err.Error()})
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
 		return
 	}
 
+	userID := getUserIDFromContext(c)
 	c.JSON(http.StatusAccepted, gin.H{"message": "Release deployment started"})
 
-	go func() {
-		task := h.startTask(serverID, "release-deploy")
+	h.goTask(c, serverID, "release-deploy", func(ctx context.Context, task *taskRecord) {
 		outputLog := &strings.Builder{}
 		var outputMu sync.Mutex
 		emit := func(line string) {
@@ -2135,192 +2599,257 @@ func (h *ServerHandler) DeployRelease(c *gin.Context) {
 			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
 		}
 
-		emit("Starting release deployment...")
+		h.finishTask(serverID, task.ID, h.runReleaseDeploy(ctx, serverID, serverDef, conn, configFiles, req, userID, emit))
+	})
+}
 
-		manager := releases.NewManager(h.config, h.db)
-		releasesList, listErr := manager.ListAllReleases()
-		if listErr != nil {
-			emit("Failed to load releases: " + listErr.Error())
-			h.finishTask(serverID, task.ID, listErr)
-			return
-		}
+// runReleaseDeploy uploads a release package and the server's rendered config
+// files to its host and runs the deploy script, around the deploy hooks. The
+// package is extracted to its own release directory and recorded in the
+// deployments table, so it can be rolled back.
+func (h *ServerHandler) runReleaseDeploy(ctx context.Context, serverID string, serverDef config.ServerDefinition, conn *ssh.PooledConnection, configFiles []config.RenderedFile, req ReleaseDeployRequest, userID *int64, emit func(string)) error {
+	emit("Starting release deployment...")
 
-		var selected *releases.Release
-		for _, release := range releasesList {
-			base := strings.TrimSuffix(filepath.Base(release.FilePath), filepath.Ext(release.FilePath))
-			if base == req.PackageName && !release.Removed {
-				selected = release
-				break
-			}
-		}
-		if selected == nil {
-			emit("Release not found: " + req.PackageName)
-			h.finishTask(serverID, task.ID, fmt.Errorf("release not found"))
-			return
-		}
+	manager := releases.NewManager(h.config, h.db)
+	releasesList, listErr := manager.ListAllReleases()
+	if listErr != nil {
+		emit("Failed to load releases: " + listErr.Error())
+		return listErr
+	}
 
-		if _, err := os.Stat(selected.FilePath); err != nil {
-			emit("Release file missing: " + selected.FilePath)
-			h.finishTask(serverID, task.ID, err)
-			return
+	var selected *releases.Release
+	for _, release := range releasesList {
+		if release.PackageName() == req.PackageName && !release.Removed {
+			selected = release
+			break
 		}
+	}
+	if selected == nil {
+		emit("Release not found: " + req.PackageName)
+		return fmt.Errorf("release not found")
+	}
 
-		installDir := "~/hytale-server"
-		serviceUser := "hytale"
-		useSudo := true
-		if serverDef.Dependencies.Configured {
-			if serverDef.Dependencies.InstallDir != "" {
-				installDir = serverDef.Dependencies.InstallDir
-			}
-			if serverDef.Dependencies.ServiceUser != "" {
-				serviceUser = serverDef.Dependencies.ServiceUser
-			}
-			useSudo = serverDef.Dependencies.UseSudo
-		}
-		if req.InstallDir != nil && strings.TrimSpace(*req.InstallDir) != "" {
-			installDir = strings.TrimSpace(*req.InstallDir)
-		}
-		if req.ServiceUser != nil && strings.TrimSpace(*req.ServiceUser) != "" {
-			serviceUser = strings.TrimSpace(*req.ServiceUser)
+	if _, err := os.Stat(selected.FilePath); err != nil {
+		emit("Release file missing: " + selected.FilePath)
+		return err
+	}
+
+	installDir := "~/hytale-server"
+	serviceUser := "hytale"
+	useSudo := true
+	if serverDef.Dependencies.Configured {
+		if serverDef.Dependencies.InstallDir != "" {
+			installDir = serverDef.Dependencies.InstallDir
 		}
-		if req.UseSudo != nil {
-			useSudo = *req.UseSudo
+		if serverDef.Dependencies.ServiceUser != "" {
+			serviceUser = serverDef.Dependencies.ServiceUser
 		}
+		useSudo = serverDef.Dependencies.UseSudo
+	}
+	if req.InstallDir != nil && strings.TrimSpace(*req.InstallDir) != "" {
+		installDir = strings.TrimSpace(*req.InstallDir)
+	}
+	if req.ServiceUser != nil && strings.TrimSpace(*req.ServiceUser) != "" {
+		serviceUser = strings.TrimSpace(*req.ServiceUser)
+	}
+	if req.UseSudo != nil {
+		useSudo = *req.UseSudo
+	}
+
+	userHome, err := resolveUserHome(conn.Client, serviceUser)
+	if err != nil {
+		emit("Failed to resolve user home: " + err.Error())
+		return err
+	}
+	installDir = resolveTilde(installDir, userHome)
+	installDirUnix := toUnixPath(installDir)
+
+	hookDetails := map[string]interface{}{"package": req.PackageName, "install_dir": installDirUnix}
+	if err := h.hooks.Run(ctx, hooks.NewEvent(hooks.PreDeploy, serverID, hookDetails)); err != nil {
+		emit("Deployment stopped: " + err.Error())
+		return err
+	}
+	finish := func(err error) error {
+		h.hooks.Run(ctx, hooks.NewEvent(hooks.PostDeploy, serverID, hookDetails).WithResult(err))
+		return err
+	}
 
-		userHome, err := resolveUserHome(conn.Client, serviceUser)
-		if err != nil {
-			emit("Failed to resolve user home: " + err.Error())
-			h.finishTask(serverID, task.ID, err)
-			return
+	remoteZip := fmt.Sprintf("/tmp/%s.zip", req.PackageName)
+	skipUpload := false
+	expectedHash := strings.TrimSpace(selected.SHA256)
+	if expectedHash != "" {
+		remoteHash, hashErr := remoteSHA256(conn.Client, remoteZip)
+		if hashErr != nil {
+			emit("Remote hash check skipped: " + hashErr.Error())
+		} else if remoteHash != "" && strings.EqualFold(remoteHash, expectedHash) {
+			skipUpload = true
+			emit("Existing package verified by SHA256. Skipping upload.")
+		} else if remoteHash != "" {
+			emit("Existing package hash mismatch. Re-uploading.")
 		}
-		installDir = resolveTilde(installDir, userHome)
-		installDirUnix := toUnixPath(installDir)
-
-		remoteZip := fmt.Sprintf("/tmp/%s.zip", req.PackageName)
-		skipUpload := false
-		expectedHash := strings.TrimSpace(selected.SHA256)
-		if expectedHash != "" {
-			remoteHash, hashErr := remoteSHA256(conn.Client, remoteZip)
-			if hashErr != nil {
-				emit("Remote hash check skipped: " + hashErr.Error())
-			} else if remoteHash != "" && strings.EqualFold(remoteHash, expectedHash) {
-				skipUpload = true
-				emit("Existing package verified by SHA256. Skipping upload.")
-			} else if remoteHash != "" {
-				emit("Existing package hash mismatch. Re-uploading.")
-			}
-		} else {
-			emit("No SHA256 available for package; uploading fresh copy.")
+	} else {
+		emit("No SHA256 available for package; uploading fresh copy.")
+	}
+	if !skipUpload {
+		if err := uploadFile(ctx, conn.Client, selected.FilePath, remoteZip, emit); err != nil {
+			emit("Upload failed: " + err.Error())
+			return finish(err)
 		}
-		if !skipUpload {
-			if err := uploadFile(conn.Client, selected.FilePath, remoteZip, emit); err != nil {
-				emit("Upload failed: " + err.Error())
-				h.finishTask(serverID, task.ID, err)
-				return
-			}
+	}
+
+	remoteConfigDir := ""
+	if len(configFiles) > 0 {
+		remoteConfigDir = fmt.Sprintf("/tmp/hsm-config-%s-%d", serverID, time.Now().UnixNano())
+		emit(fmt.Sprintf("Uploading %d rendered config file(s)...", len(configFiles)))
+		if err := uploadConfigFiles(conn.Client, remoteConfigDir, configFiles); err != nil {
+			emit("Config upload failed: " + err.Error())
+			return finish(err)
 		}
+	}
 
-		javaXms := "10G"
-		javaXmx := "10G"
-		javaMetaspace := "2560M"
-		enableStringDedup := true
-		enableAOT := true
-		enableBackup := true
-		backupDir := path.Join(installDirUnix, "Backups")
-		backupFrequency := 30
-		assetsPath := path.Join(installDirUnix, "Assets.zip")
-		extraJavaArgs := ""
-		extraServerArgs := ""
+	javaXms := "10G"
+	javaXmx := "10G"
+	javaMetaspace := "2560M"
+	enableStringDedup := true
+	enableAOT := true
+	enableBackup := true
+	backupDir := path.Join(installDirUnix, "Backups")
+	backupFrequency := 30
+	assetsPath := path.Join(installDirUnix, "Assets.zip")
+	extraJavaArgs := ""
+	extraServerArgs := ""
 
-		if req.JavaXms != nil {
-			javaXms = strings.TrimSpace(*req.JavaXms)
-		}
-		if req.JavaXmx != nil {
-			javaXmx = strings.TrimSpace(*req.JavaXmx)
-		}
-		if req.JavaMetaspace != nil {
-			javaMetaspace = strings.TrimSpace(*req.JavaMetaspace)
-		}
-		if req.EnableStringDedup != nil {
-			enableStringDedup = *req.EnableStringDedup
-		}
-		if req.EnableAOT != nil {
-			enableAOT = *req.EnableAOT
-		}
-		if req.EnableBackup != nil {
-			enableBackup = *req.EnableBackup
-		}
-		if req.BackupDir != nil && strings.TrimSpace(*req.BackupDir) != "" {
-			backupDir = strings.TrimSpace(*req.BackupDir)
-		}
-		if req.BackupFrequency != nil {
-			backupFrequency = *req.BackupFrequency
-		}
-		if req.AssetsPath != nil && strings.TrimSpace(*req.AssetsPath) != "" {
-			assetsPath = strings.TrimSpace(*req.AssetsPath)
-		}
-		if req.ExtraJavaArgs != nil {
-			extraJavaArgs = strings.TrimSpace(*req.ExtraJavaArgs)
-		}
-		if req.ExtraServerArgs != nil {
-			extraServerArgs = strings.TrimSpace(*req.ExtraServerArgs)
-		}
+	if req.JavaXms != nil {
+		javaXms = strings.TrimSpace(*req.JavaXms)
+	}
+	if req.JavaXmx != nil {
+		javaXmx = strings.TrimSpace(*req.JavaXmx)
+	}
+	if req.JavaMetaspace != nil {
+		javaMetaspace = strings.TrimSpace(*req.JavaMetaspace)
+	}
+	if req.EnableStringDedup != nil {
+		enableStringDedup = *req.EnableStringDedup
+	}
+	if req.EnableAOT != nil {
+		enableAOT = *req.EnableAOT
+	}
+	if req.EnableBackup != nil {
+		enableBackup = *req.EnableBackup
+	}
+	if req.BackupDir != nil && strings.TrimSpace(*req.BackupDir) != "" {
+		backupDir = strings.TrimSpace(*req.BackupDir)
+	}
+	if req.BackupFrequency != nil {
+		backupFrequency = *req.BackupFrequency
+	}
+	if req.AssetsPath != nil && strings.TrimSpace(*req.AssetsPath) != "" {
+		assetsPath = strings.TrimSpace(*req.AssetsPath)
+	}
+	if req.ExtraJavaArgs != nil {
+		extraJavaArgs = strings.TrimSpace(*req.ExtraJavaArgs)
+	}
+	if req.ExtraServerArgs != nil {
+		extraServerArgs = strings.TrimSpace(*req.ExtraServerArgs)
+	}
 
-		backupDir = toUnixPath(backupDir)
-		assetsPath = toUnixPath(assetsPath)
+	backupDir = toUnixPath(backupDir)
+	assetsPath = toUnixPath(assetsPath)
 
-		script := ServerReleaseDeployScript
-		script = strings.ReplaceAll(script, "{{SERVICE_USER}}", escapeForScript(serviceUser))
-		script = strings.ReplaceAll(script, "{{INSTALL_DIR}}", escapeForScriptPath(installDirUnix))
-		script = strings.ReplaceAll(script, "{{PACKAGE_PATH}}", escapeForScript(remoteZip))
-		script = strings.ReplaceAll(script, "{{PACKAGE_SHA256}}", escapeForScript(strings.TrimSpace(selected.SHA256)))
-		script = strings.ReplaceAll(script, "{{USE_SUDO}}", boolToScript(useSudo))
-		script = strings.ReplaceAll(script, "{{ENABLE_STRING_DEDUP}}", boolToScript(enableStringDedup))
-		script = strings.ReplaceAll(script, "{{ENABLE_AOT}}", boolToScript(enableAOT))
-		script = strings.ReplaceAll(script, "{{ENABLE_BACKUP}}", boolToScript(enableBackup))
-		script = strings.ReplaceAll(script, "{{BACKUP_DIR}}", escapeForScriptPath(backupDir))
-		script = strings.ReplaceAll(script, "{{BACKUP_FREQUENCY}}", fmt.Sprintf("%d", backupFrequency))
-		script = strings.ReplaceAll(script, "{{ASSETS_PATH}}", escapeForScriptPath(assetsPath))
-		script = strings.ReplaceAll(script, "{{JAVA_XMS}}", escapeForScript(javaXms))
-		script = strings.ReplaceAll(script, "{{JAVA_XMX}}", escapeForScript(javaXmx))
-		script = strings.ReplaceAll(script, "{{JAVA_METASPACE}}", escapeForScript(javaMetaspace))
-		script = strings.ReplaceAll(script, "{{EXTRA_JAVA_ARGS}}", escapeForScript(extraJavaArgs))
-		script = strings.ReplaceAll(script, "{{EXTRA_SERVER_ARGS}}", escapeForScript(extraServerArgs))
-		script = strings.ReplaceAll(script, "{{SERVER_DIR}}", escapeForScriptPath(path.Join(installDirUnix, "Server")))
-
-		emit("Extracting and configuring release...")
-		writer := newLineSinkWriter(emit)
-		err = conn.Client.StreamCommand(bashDollarQuotedCommand(script), writer, writer)
-		writer.FlushRemaining()
-		if err != nil {
-			emit("Deploy failed: " + err.Error())
-			h.finishTask(serverID, task.ID, err)
-			return
-		}
+	keepReleases := defaultKeepReleases
+	if req.KeepReleases != nil && *req.KeepReleases >= 0 {
+		keepReleases = *req.KeepReleases
+	}
+	deployment := &releases.Deployment{
+		ServerID:    serverID,
+		PackageName: req.PackageName,
+		SHA256:      strings.TrimSpace(selected.SHA256),
+		InstallDir:  installDirUnix,
+		ReleaseDir:  releases.ReleaseDirName(req.PackageName, time.Now()),
+		ServiceUser: serviceUser,
+		UseSudo:     useSudo,
+		DeployedBy:  userID,
+	}
+	pruned, err := manager.PruneCandidates(serverID, installDirUnix, keepReleases)
+	if err != nil {
+		emit("Old releases are kept: " + err.Error())
+		pruned = nil
+	}
+	pruneNames := make([]string, 0, len(pruned))
+	for _, old := range pruned {
+		pruneNames = append(pruneNames, old.ReleaseDir)
+	}
+	if err := manager.StartDeployment(deployment); err != nil {
+		emit("Failed to record deployment: " + err.Error())
+		return finish(err)
+	}
+
+	script := strings.ReplaceAll(ServerReleaseDeployScript, "{{ACTIVATE_RELEASE}}", ReleaseActivateScript)
+	script = strings.ReplaceAll(script, "{{SERVICE_USER}}", escapeForScript(serviceUser))
+	script = strings.ReplaceAll(script, "{{INSTALL_DIR}}", escapeForScriptPath(installDirUnix))
+	script = strings.ReplaceAll(script, "{{PACKAGE_PATH}}", escapeForScript(remoteZip))
+	script = strings.ReplaceAll(script, "{{PACKAGE_SHA256}}", escapeForScript(strings.TrimSpace(selected.SHA256)))
+	script = strings.ReplaceAll(script, "{{USE_SUDO}}", boolToScript(useSudo))
+	script = strings.ReplaceAll(script, "{{ENABLE_STRING_DEDUP}}", boolToScript(enableStringDedup))
+	script = strings.ReplaceAll(script, "{{ENABLE_AOT}}", boolToScript(enableAOT))
+	script = strings.ReplaceAll(script, "{{ENABLE_BACKUP}}", boolToScript(enableBackup))
+	script = strings.ReplaceAll(script, "{{BACKUP_DIR}}", escapeForScriptPath(backupDir))
+	script = strings.ReplaceAll(script, "{{BACKUP_FREQUENCY}}", fmt.Sprintf("%d", backupFrequency))
+	script = strings.ReplaceAll(script, "{{ASSETS_PATH}}", escapeForScriptPath(assetsPath))
+	script = strings.ReplaceAll(script, "{{JAVA_XMS}}", escapeForScript(javaXms))
+	script = strings.ReplaceAll(script, "{{JAVA_XMX}}", escapeForScript(javaXmx))
+	script = strings.ReplaceAll(script, "{{JAVA_METASPACE}}", escapeForScript(javaMetaspace))
+	script = strings.ReplaceAll(script, "{{EXTRA_JAVA_ARGS}}", escapeForScript(extraJavaArgs))
+	script = strings.ReplaceAll(script, "{{EXTRA_SERVER_ARGS}}", escapeForScript(extraServerArgs))
+	script = strings.ReplaceAll(script, "{{SERVER_DIR}}", escapeForScriptPath(path.Join(installDirUnix, "Server")))
+	script = strings.ReplaceAll(script, "{{CONFIG_DIR}}", escapeForScriptPath(remoteConfigDir))
+	script = strings.ReplaceAll(script, "{{RELEASE_NAME}}", escapeForScript(deployment.ReleaseDir))
+	script = strings.ReplaceAll(script, "{{PRUNE_RELEASES}}", escapeForScript(strings.Join(pruneNames, " ")))
+
+	emit("Extracting and configuring release...")
+	writer := newLineSinkWriter(emit)
+	err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(script), writer, writer)
+	writer.FlushRemaining()
+	if recordErr := manager.FinishDeployment(deployment, err); recordErr != nil {
+		logger.ErrorContext(ctx, "Failed to record deployment", "server_id", serverID, "deployment_id", deployment.ID, "error", recordErr)
+	}
+	if err != nil {
+		emit("Deploy failed: " + err.Error())
+		return finish(err)
+	}
+	if err := manager.MarkPruned(pruned); err != nil {
+		logger.ErrorContext(ctx, "Failed to record pruned releases", "server_id", serverID, "error", err)
+	}
 
-		emit("Release deployment complete.")
-		h.finishTask(serverID, task.ID, nil)
-	}()
+	emit("Release deployment complete.")
+	return finish(nil)
 }
 
+// HandleServerTasksWebSocket streams a server's task output and status. A
+// reconnecting client passes ?since= with the seq of the last line it received
+// and is sent only the lines after it.
 func (h *ServerHandler) HandleServerTasksWebSocket(c *gin.Context) {
 	serverID := c.Param("id")
 	if _, found := h.serverManager.GetByID(serverID); !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	userClaims, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
 		return
 	}
 	claims := userClaims.(*auth.Claims)
+	since, ok := taskStreamSince(c)
+	if !ok {
+		return
+	}
 
 	upgrader := buildUpgrader(h.config.Security.CORS.AllowedOrigins)
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("[Tasks] Failed to upgrade WebSocket: %v", err)
+		logger.ErrorContext(c.Request.Context(), "Failed to upgrade WebSocket", "server_id", serverID, "error", err)
 		return
 	}
 
@@ -2331,30 +2860,11 @@ func (h *ServerHandler) HandleServerTasksWebSocket(c *gin.Context) {
 		Username: claims.Username,
 		Conn:     conn,
 		Room:     room,
-		Send:     make(chan *ws.Message, 256),
+		Send:     make(chan *ws.Message, h.taskSubscriberQueue()),
 		Hub:      h.hub,
 	}
 
-	h.hub.Register <- client
-
-	go func() {
-		for _, entry := range h.getTaskStreamBuffer(serverID).GetLines() {
-			client.SendMessage("task_output", map[string]interface{}{
-				"line":       entry.Line,
-				"server_id":  serverID,
-				"task_id":    entry.TaskID,
-				"task":       entry.Task,
-				"timestamp":  entry.Timestamp,
-				"historical": true,
-			})
-		}
-	}()
-
-	go func() {
-		for _, record := range h.listTasks(serverID) {
-			h.broadcastTaskStatus(serverID, record, true)
-		}
-	}()
+	h.subscribeTasks(serverID, client, since)
 
 	go client.WritePump()
 	go client.ReadPump()
@@ -2364,7 +2874,7 @@ func (h *ServerHandler) StartTransferBenchmark(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
@@ -2372,9 +2882,8 @@ func (h *ServerHandler) StartTransferBenchmark(c *gin.Context) {
 	_ = c.ShouldBindJSON(&req)
 
 	params := normalizeBenchmarkRequest(req)
-	go func() {
-		task := h.startTask(serverID, "transfer-benchmark")
-		err := h.runTransferBenchmark(serverID, serverDef, params, func(line string) {
+	h.goTask(c, serverID, "transfer-benchmark", func(ctx context.Context, task *taskRecord) {
+		err := h.runTransferBenchmark(ctx, serverID, serverDef, params, func(line string) {
 			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
 		})
 		if err != nil {
@@ -2382,7 +2891,7 @@ func (h *ServerHandler) StartTransferBenchmark(c *gin.Context) {
 			return
 		}
 		h.finishTask(serverID, task.ID, nil)
-	}()
+	})
 
 	c.JSON(http.StatusAccepted, gin.H{"message": "Benchmark started"})
 }
@@ -2421,20 +2930,18 @@ func normalizeBenchmarkRequest(req TransferBenchmarkRequest) benchmarkParams {
 	return benchmarkParams{sizeMB: sizeMB, blockMB: blockMB, removeAfter: removeAfter}
 }
 
-func (h *ServerHandler) runTransferBenchmark(serverID string, serverDef config.ServerDefinition, params benchmarkParams, emit func(string)) error {
+func (h *ServerHandler) runTransferBenchmark(ctx context.Context, serverID string, serverDef config.ServerDefinition, params benchmarkParams, emit func(string)) error {
 	if emit == nil {
 		emit = func(string) {}
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
@@ -2483,6 +2990,10 @@ func (h *ServerHandler) runTransferBenchmark(serverID string, serverDef config.S
 		defer close(doneCh)
 		var written int64
 		for written < totalBytes {
+			if err := ctx.Err(); err != nil {
+				errCh <- err
+				return
+			}
 			remaining := totalBytes - written
 			writeSize := blockBytes
 			if remaining < writeSize {
@@ -2503,6 +3014,10 @@ func (h *ServerHandler) runTransferBenchmark(serverID string, serverDef config.S
 	for {
 		select {
 		case err := <-errCh:
+			if ctx.Err() != nil {
+				_ = sftpClient.Remove(remotePath)
+				return fmt.Errorf("benchmark cancelled: %w", err)
+			}
 			return fmt.Errorf("write failed: %w", err)
 		case <-doneCh:
 			current := atomic.LoadInt64(&totalWritten)
@@ -2548,11 +3063,53 @@ func (h *ServerHandler) getTaskStreamBuffer(serverID string) *taskStreamBuffer {
 	if buf, ok := h.streamBuffers[serverID]; ok {
 		return buf
 	}
-	buf := newTaskStreamBuffer(1000)
+	buf := newTaskStreamBuffer(h.streamBufferLines())
 	h.streamBuffers[serverID] = buf
 	return buf
 }
 
+// recentTaskLines returns the server's latest task output numbered after
+// since, at most the stream buffer's worth, for backfilling a subscriber
+func (h *ServerHandler) recentTaskLines(serverID string, since int64) []taskStreamLine {
+	if h.sharedTasks != nil {
+		lines, err := h.sharedTasks.recentLines(serverID, since, h.streamBufferLines())
+		if err == nil {
+			return lines
+		}
+		logger.Warn("Failed to read shared task output", "server_id", serverID, "error", err)
+	}
+	return h.getTaskStreamBuffer(serverID).LinesSince(since)
+}
+
+func (h *ServerHandler) streamBufferLines() int {
+	if h.config.Tasks.StreamBufferLines > 0 {
+		return h.config.Tasks.StreamBufferLines
+	}
+	return defaultStreamBufferLines
+}
+
+// restoreTasks loads the tasks and output persisted before the last restart so
+// the tasks WebSocket can backfill them
+func (h *ServerHandler) restoreTasks() {
+	retention := time.Duration(h.config.Tasks.RetentionDays) * 24 * time.Hour
+	records, lines := h.streamStore.load(h.streamBufferLines(), retention)
+	for serverID, tasks := range records {
+		state := h.getServerTaskState(serverID)
+		for i := range tasks {
+			record := tasks[i]
+			state.tasks[record.ID] = &record
+			state.order = append(state.order, record.ID)
+		}
+	}
+	for serverID, entries := range lines {
+		buf := h.getTaskStreamBuffer(serverID)
+		// Lines logged before lines were numbered follow the last numbered one
+		for _, entry := range entries {
+			buf.Add(entry)
+		}
+	}
+}
+
 func (h *ServerHandler) getServerTaskState(serverID string) *serverTaskState {
 	if state, ok := h.tasks[serverID]; ok {
 		return state
@@ -2562,29 +3119,165 @@ func (h *ServerHandler) getServerTaskState(serverID string) *serverTaskState {
 	return state
 }
 
-func (h *ServerHandler) startTask(serverID string, task string) *taskRecord {
+// RunningTasks returns the number of tasks this instance is running, by task
+func (h *ServerHandler) RunningTasks() map[string]int {
+	return h.countTasks(taskStatusRunning)
+}
+
+// QueuedTasks returns the number of tasks waiting on this instance for a free
+// slot, by task
+func (h *ServerHandler) QueuedTasks() map[string]int {
+	return h.countTasks(taskStatusQueued)
+}
+
+func (h *ServerHandler) countTasks(status taskStatus) map[string]int {
+	h.tasksMu.Lock()
+	defer h.tasksMu.Unlock()
+	counts := make(map[string]int)
+	for _, state := range h.tasks {
+		for _, record := range state.tasks {
+			if record.Status == status {
+				counts[record.Task]++
+			}
+		}
+	}
+	return counts
+}
+
+// goTask runs work in the background as a recorded task. The task is queued
+// until the executor has a slot for it and no other task runs on the server,
+// here or on another instance of a cluster. Its context keeps the request's ID and trace but outlives the request, and
+// is cancelled when the handler shuts down so remote commands are interrupted
+// rather than orphaned; a task still queued then fails without running.
+func (h *ServerHandler) goTask(c *gin.Context, serverID string, name string, work func(ctx context.Context, task *taskRecord)) {
+	ctx, cancel := context.WithCancel(withTaskOrigin(context.WithoutCancel(c.Request.Context()), c))
+	stop := context.AfterFunc(h.tasksCtx, cancel)
+	task := h.recordTask(ctx, serverID, name, taskStatusQueued)
+
+	h.pendingOps.Add(1)
+	h.executor.submit(serverID, func() {
+		defer h.pendingOps.Done()
+		defer cancel()
+		defer stop()
+		defer h.invalidateServer(serverID)
+		if err := ctx.Err(); err != nil {
+			h.finishTask(serverID, task.ID, err)
+			return
+		}
+		// The task stays queued while another instance works on the server
+		unlock, err := h.lockServer(ctx, serverID)
+		if err != nil {
+			h.finishTask(serverID, task.ID, err)
+			return
+		}
+		defer unlock()
+		h.runTask(ctx, serverID, task)
+		work(ctx, task)
+	})
+}
+
+// runQueuedTask runs work as a recorded task for callers without a request,
+// such as batches, rollouts and auto-updates. Like goTask it waits for the
+// executor and for the server's lease, so those callers count against
+// tasks.max_concurrent and never run alongside another task on the server,
+// here or on another instance, but it returns once the task is done, with
+// work's error. A task whose ctx is done before it leaves the queue
+// fails without running.
+func (h *ServerHandler) runQueuedTask(ctx context.Context, serverID string, name string, work func(ctx context.Context, task *taskRecord) error) error {
+	task := h.recordTask(ctx, serverID, name, taskStatusQueued)
+	done := make(chan error, 1)
+
+	h.pendingOps.Add(1)
+	h.executor.submit(serverID, func() {
+		defer h.pendingOps.Done()
+		defer h.invalidateServer(serverID)
+		err := ctx.Err()
+		if err == nil {
+			// The task stays queued while another instance works on the server
+			var unlock func()
+			if unlock, err = h.lockServer(ctx, serverID); err == nil {
+				h.runTask(ctx, serverID, task)
+				err = work(ctx, task)
+				unlock()
+			}
+		}
+		h.finishTask(serverID, task.ID, err)
+		done <- err
+	})
+	return <-done
+}
+
+// startTask records a background task that is already running. It bypasses
+// the executor, so it is only for work that holds no host for long and must
+// not wait behind deploys: game job runs, which follow a server's console for
+// as long as the job lasts.
+func (h *ServerHandler) startTask(ctx context.Context, serverID string, task string) *taskRecord {
+	return h.recordTask(ctx, serverID, task, taskStatusRunning)
+}
+
+// recordTask records a background task, tagged with the ID of the request that
+// started it and, when ctx carries them, that request and the task it re-runs
+func (h *ServerHandler) recordTask(ctx context.Context, serverID string, task string, status taskStatus) *taskRecord {
 	h.tasksMu.Lock()
 	state := h.getServerTaskState(serverID)
 	id := fmt.Sprintf("task-%s-%d", serverID, time.Now().UnixNano())
 	record := &taskRecord{
 		ID:        id,
 		Task:      task,
-		Status:    taskStatusRunning,
+		Status:    status,
 		StartedAt: time.Now(),
+		RequestID: tracing.RequestID(ctx),
+		RerunOf:   rerunOf(ctx),
+		origin:    taskOriginFrom(ctx),
 	}
 	state.tasks[id] = record
 	state.order = append(state.order, id)
-	if len(state.order) > 50 {
+	if len(state.order) > maxTasksPerServer {
 		oldest := state.order[0]
 		state.order = state.order[1:]
 		delete(state.tasks, oldest)
 	}
+	saved := *record
 	h.tasksMu.Unlock()
 
+	h.streamStore.saveTask(serverID, saved)
+	h.sharedTasks.saveTask(serverID, saved)
 	h.broadcastTaskStatus(serverID, record, false)
+	if status == taskStatusQueued {
+		logger.InfoContext(ctx, "Task queued", "server_id", serverID, "task_id", id, "task", task)
+	} else {
+		logger.InfoContext(ctx, "Task started", "server_id", serverID, "task_id", id, "task", task)
+	}
 	return record
 }
 
+// runTask marks a queued task as running. Its start time becomes the moment it
+// left the queue.
+func (h *ServerHandler) runTask(ctx context.Context, serverID string, record *taskRecord) {
+	h.tasksMu.Lock()
+	record.Status = taskStatusRunning
+	record.StartedAt = time.Now()
+	saved := *record
+	h.tasksMu.Unlock()
+
+	h.streamStore.saveTask(serverID, saved)
+	h.sharedTasks.saveTask(serverID, saved)
+	h.broadcastTaskStatus(serverID, record, false)
+	logger.InfoContext(ctx, "Task started", "server_id", serverID, "task_id", saved.ID, "task", saved.Task)
+}
+
+// taskRequestID returns the ID of the request that started a task
+func (h *ServerHandler) taskRequestID(serverID string, taskID string) string {
+	h.tasksMu.Lock()
+	defer h.tasksMu.Unlock()
+	if state, ok := h.tasks[serverID]; ok {
+		if record, ok := state.tasks[taskID]; ok {
+			return record.RequestID
+		}
+	}
+	return ""
+}
+
 func (h *ServerHandler) updateTaskLine(serverID string, taskID string, line string) {
 	h.tasksMu.Lock()
 	state, ok := h.tasks[serverID]
@@ -2618,9 +3311,23 @@ func (h *ServerHandler) finishTask(serverID string, taskID string, err error) {
 	} else {
 		record.Status = taskStatusComplete
 	}
+	saved := *record
 	h.tasksMu.Unlock()
 
+	h.streamStore.closeTask(taskID)
+	h.streamStore.saveTask(serverID, saved)
+	h.sharedTasks.saveTask(serverID, saved)
 	h.broadcastTaskStatus(serverID, record, false)
+
+	// Logged with the ID of the request that started the task, so a failure
+	// can be matched to the request and its SSH commands
+	ctx := tracing.WithRequestID(context.Background(), saved.RequestID)
+	duration := saved.FinishedAt.Sub(saved.StartedAt).String()
+	if err != nil {
+		logger.WarnContext(ctx, "Task failed", "server_id", serverID, "task_id", taskID, "task", saved.Task, "duration", duration, "error", err)
+	} else {
+		logger.InfoContext(ctx, "Task finished", "server_id", serverID, "task_id", taskID, "task", saved.Task, "duration", duration)
+	}
 }
 
 func (h *ServerHandler) broadcastTaskStatus(serverID string, record *taskRecord, historical bool) {
@@ -2639,15 +3346,38 @@ func (h *ServerHandler) broadcastTaskStatus(serverID string, record *taskRecord,
 	if record.Error != "" {
 		payload["error"] = record.Error
 	}
+	if record.RequestID != "" {
+		payload["request_id"] = record.RequestID
+	}
+	if record.RerunOf != "" {
+		payload["rerun_of"] = record.RerunOf
+	}
 
-	h.hub.BroadcastToRoom(fmt.Sprintf("server-tasks:%s", serverID), &ws.Message{
+	message := &ws.Message{
 		Type:      "task_status",
 		Payload:   payload,
 		Timestamp: time.Now(),
-	})
+	}
+	room := fmt.Sprintf("server-tasks:%s", serverID)
+	if historical {
+		// Backfill for one client; every instance answers from the same records
+		h.hub.BroadcastToRoom(room, message)
+		return
+	}
+	h.hub.Publish(room, message)
 }
 
+// listTasks returns the server's recent tasks, oldest first. In a cluster
+// they include the tasks other instances run.
 func (h *ServerHandler) listTasks(serverID string) []*taskRecord {
+	if h.sharedTasks != nil {
+		items, err := h.sharedTasks.listTasks(serverID)
+		if err == nil {
+			return items
+		}
+		logger.Warn("Failed to list shared tasks", "server_id", serverID, "error", err)
+	}
+
 	h.tasksMu.Lock()
 	defer h.tasksMu.Unlock()
 	state, ok := h.tasks[serverID]
@@ -2665,20 +3395,20 @@ func (h *ServerHandler) listTasks(serverID string) []*taskRecord {
 }
 
 func (h *ServerHandler) appendTaskStreamLine(serverID string, taskID string, task string, line string) {
-	entry := taskStreamLine{Line: line, Task: task, TaskID: taskID, Timestamp: time.Now()}
-	h.getTaskStreamBuffer(serverID).Add(entry)
+	entry := taskStreamLine{Line: line, Task: task, TaskID: taskID, Timestamp: time.Now(), RequestID: h.taskRequestID(serverID, taskID)}
+
+	// Lines are numbered and published under the buffer's lock, so subscribers
+	// receive a server's lines in the order of their numbers. A cluster numbers
+	// them by their shared row, which every instance agrees on.
+	buf := h.getTaskStreamBuffer(serverID)
+	buf.mu.Lock()
+	entry.Seq = h.sharedTasks.appendLine(serverID, entry)
+	entry = buf.add(entry)
+	h.streamStore.appendLine(serverID, entry)
+	h.hub.Publish(fmt.Sprintf("server-tasks:%s", serverID), taskOutputMessage(serverID, entry, false))
+	buf.mu.Unlock()
+
 	h.updateTaskLine(serverID, taskID, line)
-	h.hub.BroadcastToRoom(fmt.Sprintf("server-tasks:%s", serverID), &ws.Message{
-		Type: "task_output",
-		Payload: map[string]interface{}{
-			"line":      entry.Line,
-			"server_id": serverID,
-			"task_id":   entry.TaskID,
-			"task":      entry.Task,
-			"timestamp": entry.Timestamp,
-		},
-		Timestamp: entry.Timestamp,
-	})
 }
 
 func resolveUserHome(client *ssh.Client, user string) (string, error) {
@@ -2699,14 +3429,12 @@ func resolveTilde(path string, home string) string {
 
 func (h *ServerHandler) detectListeningJavaProcess(serverID string, serverDef config.ServerDefinition) (int, string, error) {
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		Password:        serverDef.Connection.Password,
-		KeyPath:         serverDef.Connection.KeyPath,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
 	}
 
 	if sshConfig.AuthMethod == "key" && sshConfig.KeyPath == "" {
@@ -2807,7 +3535,7 @@ func remoteSHA256(client *ssh.Client, path string) (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
-func uploadFile(client *ssh.Client, localPath string, remotePath string, emit func(string)) error {
+func uploadFile(ctx context.Context, client *ssh.Client, localPath string, remotePath string, emit func(string)) error {
 	sftpClient, err := client.NewSFTPWithOptions(
 		sftp.MaxPacketUnchecked(131072),
 		sftp.UseConcurrentWrites(true),
@@ -2818,7 +3546,7 @@ func uploadFile(client *ssh.Client, localPath string, remotePath string, emit fu
 	}
 	defer sftpClient.Close()
 
-	_ = sftpClient.MkdirAll(filepath.Dir(remotePath))
+	_ = sftpClient.MkdirAll(path.Dir(remotePath))
 
 	localFile, err := os.Open(localPath)
 	if err != nil {
@@ -2846,6 +3574,9 @@ func uploadFile(client *ssh.Client, localPath string, remotePath string, emit fu
 	lastReport := time.Now()
 	lastKeepAlive := time.Now()
 	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("upload cancelled: %w", err)
+		}
 		n, readErr := localFile.Read(buffer)
 		if n > 0 {
 			if _, err := remoteFile.Write(buffer[:n]); err != nil {
@@ -3006,14 +3737,18 @@ func (h *ServerHandler) StartServer(c *gin.Context) {
 
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	if window, active := h.inMaintenance(serverID); active {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, maintenanceError(window))
 		return
 	}
 
 	var req models.ServerStartRequest
 	if c.Request != nil && c.Request.ContentLength > 0 {
 		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 			return
 		}
 	}
@@ -3022,21 +3757,24 @@ func (h *ServerHandler) StartServer(c *gin.Context) {
 	if hasStartOverrides(&req) {
 		customConfig, err := h.createStartServerConfig(&serverDef, &req)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 			return
 		}
 		serverConfig = customConfig
 	}
 
+	h.resetWatchdog(serverID)
+	ctx := context.WithoutCancel(c.Request.Context())
 	h.pendingOps.Add(1)
 	go func() {
 		defer h.pendingOps.Done()
+		defer h.invalidateServer(serverID)
 		err := h.lifecycleManager.StartServer(serverID, serverConfig)
 		if err != nil {
-			log.Printf("[API] Failed to start server %s: %v", serverID, err)
+			logger.ErrorContext(ctx, "Failed to start server", "server_id", serverID, "error", err)
 			h.activityLogger.LogServerStart(serverID, userID, false, err.Error())
 		} else {
-			log.Printf("[API] Server %s started successfully", serverID)
+			logger.InfoContext(ctx, "Server started successfully", "server_id", serverID)
 			h.activityLogger.LogServerStart(serverID, userID, true, "")
 		}
 	}()
@@ -3050,27 +3788,30 @@ func (h *ServerHandler) StopServer(c *gin.Context) {
 	userID := getUserIDFromContext(c)
 	graceful := c.DefaultQuery("graceful", "true") == "true"
 
-	log.Printf("[StopServer] Request received for server %s (user: %s, graceful: %v)", serverID, userID, graceful)
+	logger.InfoContext(c.Request.Context(), "Stop requested", "server_id", serverID, "user_id", userID, "graceful", graceful)
 
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		log.Printf("[StopServer] Server %s not found", serverID)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		logger.WarnContext(c.Request.Context(), "Server not found", "server_id", serverID)
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
 	serverConfig := h.createServerConfig(&serverDef)
 
-	log.Printf("[StopServer] Initiating stop for server %s in background", serverID)
+	logger.InfoContext(c.Request.Context(), "Stopping server in background", "server_id", serverID)
+	h.resetWatchdog(serverID)
+	ctx := context.WithoutCancel(c.Request.Context())
 	h.pendingOps.Add(1)
 	go func() {
 		defer h.pendingOps.Done()
+		defer h.invalidateServer(serverID)
 		err := h.lifecycleManager.StopServer(serverID, serverConfig, graceful)
 		if err != nil {
-			log.Printf("[API] Failed to stop server %s: %v", serverID, err)
+			logger.ErrorContext(ctx, "Failed to stop server", "server_id", serverID, "error", err)
 			h.activityLogger.LogServerStop(serverID, userID, graceful, false, err.Error())
 		} else {
-			log.Printf("[API] Server %s stopped successfully", serverID)
+			logger.InfoContext(ctx, "Server stopped successfully", "server_id", serverID)
 			h.activityLogger.LogServerStop(serverID, userID, graceful, true, "")
 		}
 	}()
@@ -3086,14 +3827,18 @@ func (h *ServerHandler) RestartServer(c *gin.Context) {
 
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	if window, active := h.inMaintenance(serverID); active {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, maintenanceError(window))
 		return
 	}
 
 	var req models.ServerStartRequest
 	if c.Request != nil && c.Request.ContentLength > 0 {
 		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 			return
 		}
 	}
@@ -3102,21 +3847,24 @@ func (h *ServerHandler) RestartServer(c *gin.Context) {
 	if hasStartOverrides(&req) {
 		customConfig, err := h.createStartServerConfig(&serverDef, &req)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 			return
 		}
 		serverConfig = customConfig
 	}
 
+	h.resetWatchdog(serverID)
+	ctx := context.WithoutCancel(c.Request.Context())
 	h.pendingOps.Add(1)
 	go func() {
 		defer h.pendingOps.Done()
+		defer h.invalidateServer(serverID)
 		err := h.lifecycleManager.RestartServer(serverID, serverConfig, graceful)
 		if err != nil {
-			log.Printf("[API] Failed to restart server %s: %v", serverID, err)
+			logger.ErrorContext(ctx, "Failed to restart server", "server_id", serverID, "error", err)
 			h.activityLogger.LogServerRestart(serverID, userID, graceful, false, err.Error())
 		} else {
-			log.Printf("[API] Server %s restarted successfully", serverID)
+			logger.InfoContext(ctx, "Server restarted successfully", "server_id", serverID)
 			h.activityLogger.LogServerRestart(serverID, userID, graceful, true, "")
 		}
 	}()
@@ -3129,14 +3877,19 @@ func (h *ServerHandler) GetServerStatus(c *gin.Context) {
 	serverID := c.Param("id")
 	serverDef, found := h.serverManager.GetByID(serverID)
 	if !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
-	sessionName := server.SafeSessionName(serverID)
-
-	// Comprehensive health check
-	health := h.performHealthCheck(serverID, serverDef, sessionName)
+	// Served from the background refresher; only a server never checked before is probed inline
+	snapshot, ok := h.statusRefresher.Get(serverID)
+	if !ok {
+		if snapshot, ok = h.statusRefresher.CheckNow(serverID); !ok {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+			return
+		}
+	}
+	health := snapshot.Health
 
 	// Determine overall status based on health check
 	var overallStatus string
@@ -3161,11 +3914,17 @@ func (h *ServerHandler) GetServerStatus(c *gin.Context) {
 		PlayerCount:      0,
 		MaxPlayers:       20,
 		Uptime:           health.ProcessStatus.UptimeSeconds,
-		LastChecked:      time.Now(),
+		LastChecked:      snapshot.CheckedAt,
 		ErrorMessage:     errorMsg,
 		HealthCheck:      &health,
 	}
 
+	// Only a query port knows the players
+	if game := health.Game; game != nil && game.Source == query.SourceQuery {
+		status.PlayerCount = game.Players
+		status.MaxPlayers = game.MaxPlayers
+	}
+
 	c.JSON(http.StatusOK, status)
 }
 
@@ -3176,12 +3935,12 @@ func (h *ServerHandler) ExecuteCommand(c *gin.Context) {
 
 	var req models.CommandRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	if _, found := h.serverManager.GetByID(serverID); !found {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
 		return
 	}
 
@@ -3190,7 +3949,7 @@ func (h *ServerHandler) ExecuteCommand(c *gin.Context) {
 	err := h.processManager.SendCommand(serverID, sessionName, req.Command)
 
 	if err != nil {
-		log.Printf("[API] Failed to execute command on %s: %v", serverID, err)
+		logger.ErrorContext(c.Request.Context(), "Failed to execute command", "server_id", serverID, "error", err)
 		h.activityLogger.LogCommandExecute(serverID, userID, req.Command, false, "", err.Error())
 		c.JSON(http.StatusInternalServerError, models.CommandResponse{Success: false, Error: err.Error()})
 		return
@@ -3211,21 +3970,16 @@ func (h *ServerHandler) createServerConfig(def *config.ServerDefinition) *server
 		javaArgs = splitArgs(def.Server.JavaArgs)
 	}
 
+	// For localhost the pool hands out a client that runs commands on this
+	// machine instead of over SSH
 	sshConfig := &ssh.ClientConfig{
-		Host:            def.Connection.Host,
-		Port:            def.Connection.Port,
-		Username:        def.Connection.Username,
-		AuthMethod:      def.Connection.AuthMethod,
-		Password:        def.Connection.Password,
-		KeyPath:         def.Connection.KeyPath,
-		Timeout:         10 * time.Second,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
-	}
-
-	// If no host is specified, assume local execution (nil SSHConfig)
-	if def.Connection.Host == "" || def.Connection.Host == "localhost" || def.Connection.Host == "127.0.0.1" {
-		sshConfig = nil
+		Host:       def.Connection.Host,
+		Port:       def.Connection.Port,
+		Username:   def.Connection.Username,
+		AuthMethod: def.Connection.AuthMethod,
+		Password:   def.Connection.Password,
+		KeyPath:    def.Connection.KeyPath,
+		Timeout:    10 * time.Second,
 	}
 
 	return &server.ServerConfig{
@@ -3244,9 +3998,10 @@ func (h *ServerHandler) createServerConfig(def *config.ServerDefinition) *server
 			{Delay: 30 * time.Second, Message: "Server shutting down in 30 seconds..."},
 			{Delay: 20 * time.Second, Message: "Server shutting down in 10 seconds..."},
 		},
-		SSHConfig:  sshConfig,
-		RunAsUser:  def.Dependencies.ServiceUser,
-		UseSudo:    def.Dependencies.UseSudo,
+		SSHConfig:      sshConfig,
+		RunAsUser:      def.Dependencies.ServiceUser,
+		UseSudo:        def.Dependencies.UseSudo,
+		ProcessManager: def.Server.ProcessManager,
 	}
 }
 
@@ -3365,27 +4120,22 @@ func (h *ServerHandler) createStartServerConfig(def *config.ServerDefinition, re
 
 	serverArgs := []string{"--assets", assetsPath}
 	if enableBackup {
-		serverArgs = append(serverArgs, "--backup", "--backup-dir", backupDir, "--backup-frequency", fmt.Sprintf("%d", backupFrequency))
+		serverArgs = append(serverArgs, "--backup", "--backup-dir", backupDir, "--backup-frequency", backupFrequency)
 	}
 	if extraServerArgs != "" {
 		serverArgs = append(serverArgs, splitArgs(extraServerArgs)...)
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            def.Connection.Host,
-		Port:            def.Connection.Port,
-		Username:        def.Connection.Username,
-		AuthMethod:      def.Connection.AuthMethod,
-		Password:        def.Connection.Password,
-		KeyPath:         def.Connection.KeyPath,
-		Timeout:         10 * time.Second,
-		KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+		Host:       def.Connection.Host,
+		Port:       def.Connection.Port,
+		Username:   def.Connection.Username,
+		AuthMethod: def.Connection.AuthMethod,
+		Password:   def.Connection.Password,
+		KeyPath:    def.Connection.KeyPath,
+		Timeout:    10 * time.Second,
 	}
 
-	if def.Connection.Host == "" || def.Connection.Host == "localhost" || def.Connection.Host == "127.0.0.1" {
-		sshConfig = nil
-	}
 
 	return &server.ServerConfig{
 		ServerID:       def.ID,
@@ -3403,9 +4153,10 @@ func (h *ServerHandler) createStartServerConfig(def *config.ServerDefinition, re
 			{Delay: 30 * time.Second, Message: "Server shutting down in 30 seconds..."},
 			{Delay: 20 * time.Second, Message: "Server shutting down in 10 seconds..."},
 		},
-		SSHConfig: sshConfig,
-		RunAsUser: serviceUser,
-		UseSudo:   useSudo,
+		SSHConfig:      sshConfig,
+		RunAsUser:      serviceUser,
+		UseSudo:        useSudo,
+		ProcessManager: def.Server.ProcessManager,
 	}, nil
 }
 
@@ -3440,27 +4191,12 @@ func (h *ServerHandler) persistSSHKey(serverID string, conn *config.ConnectionCo
 		return nil
 	}
 
-	keysDir := filepath.Join(h.config.Storage.DataDir, "ssh_keys")
-	if err := os.MkdirAll(keysDir, 0700); err != nil {
-		return err
-	}
-
-	keyPath := filepath.Join(keysDir, fmt.Sprintf("%s.pem", serverID))
-
-	manager, err := crypto.NewEncryptionManager()
-	if err != nil {
-		return err
-	}
-
-	encrypted, err := manager.EncryptSSHKey(conn.KeyContent)
-	if err != nil {
+	keyPath := h.sshKeyPath(serverID)
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
 		return err
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(encrypted)
-	payload := []byte("ENC1\n" + encoded)
-
-	if err := os.WriteFile(keyPath, payload, 0600); err != nil {
+	if err := ssh.WritePrivateKeyFile(keyPath, []byte(conn.KeyContent)); err != nil {
 		return err
 	}
 
@@ -3469,44 +4205,9 @@ func (h *ServerHandler) persistSSHKey(serverID string, conn *config.ConnectionCo
 	return nil
 }
 
-// determineConnectionStatus determines the overall connection status of a server
-func (h *ServerHandler) determineConnectionStatus(serverID string, serverDef config.ServerDefinition, statusInfo *server.ServerStatusInfo) models.ServerConnectionStatus {
-	// Check if we have an active SSH connection
-	conn := h.sshPool.GetExistingConnection(serverID)
-	if conn == nil {
-		// No active SSH connection
-		return models.StatusDisconnected
-	}
-	
-	// SSH connected - check if Hytale process is running
-	// First check the status detector result
-	if statusInfo != nil && statusInfo.Status == server.StatusOnline {
-		// Hytale is running with console streaming
-		return models.StatusRunning
-	}
-	
-	// Try to get agent state for more accurate process detection
-	agentState := h.fetchAgentState(serverID, serverDef)
-	if agentState != nil && len(agentState.JavaProcesses) > 0 {
-		// Check if any Java process is running HytaleServer.jar
-		for _, proc := range agentState.JavaProcesses {
-			if strings.Contains(proc.CommandLine, "HytaleServer.jar") {
-				log.Printf("[Status] Server %s: Found Java process via agent (PID %d)", serverID, proc.PID)
-				return models.StatusRunning
-			}
-		}
-	}
-	
-	// Fallback: check for any Java process with HytaleServer.jar via SSH
-	output, err := conn.Client.RunCommand("pgrep -f 'HytaleServer.jar'")
-	if err == nil && strings.TrimSpace(output) != "" {
-		// Found a running Java process (Hytale server)
-		log.Printf("[Status] Server %s: Found Java process via pgrep", serverID)
-		return models.StatusRunning
-	}
-	
-	// SSH works but no Hytale instance running
-	return models.StatusOnline
+// sshKeyPath is where persistSSHKey stores a server's key
+func (h *ServerHandler) sshKeyPath(serverID string) string {
+	return filepath.Join(h.config.Storage.DataDir, "ssh_keys", fmt.Sprintf("%s.pem", serverID))
 }
 
 // performHealthCheck performs a comprehensive health check on a server
@@ -3527,19 +4228,20 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 		},
 	}
 
+	// Probe the game ports while SSH is checked
+	game := h.probeGame(serverDef)
+
 	// Check SSH connectivity - try to get or establish connection
 	conn := h.sshPool.GetExistingConnection(serverID)
 	if conn == nil {
 		// No existing connection, try to establish one
 		sshConfig := &ssh.ClientConfig{
-			Host:            serverDef.Connection.Host,
-			Port:            serverDef.Connection.Port,
-			Username:        serverDef.Connection.Username,
-			AuthMethod:      serverDef.Connection.AuthMethod,
-			Password:        serverDef.Connection.Password,
-			KeyPath:         serverDef.Connection.KeyPath,
-			KnownHostsPath:  h.config.Security.SSH.KnownHostsPath,
-			TrustOnFirstUse: h.config.Security.SSH.TrustOnFirstUse,
+			Host:       serverDef.Connection.Host,
+			Port:       serverDef.Connection.Port,
+			Username:   serverDef.Connection.Username,
+			AuthMethod: serverDef.Connection.AuthMethod,
+			Password:   serverDef.Connection.Password,
+			KeyPath:    serverDef.Connection.KeyPath,
 		}
 		
 		var err error
@@ -3547,6 +4249,10 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 		if err != nil {
 			health.SSHStatus.Error = fmt.Sprintf("Failed to connect: %v", err)
 			health.ConnectionStatus = models.StatusDisconnected
+			// A server answering on its game port runs even when SSH is down
+			if applyGameStatus(&health, game()) {
+				health.ConnectionStatus = models.StatusRunning
+			}
 			return health
 		}
 	}
@@ -3554,10 +4260,11 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 	health.SSHStatus.Connected = true
 
 	// Check agent status
-	agentState := h.fetchAgentState(serverID, serverDef)
+	agentState, agentTransport := h.readAgentState(serverID, serverDef)
 	if agentState != nil {
 		health.AgentStatus.Available = true
 		health.AgentStatus.Connected = true
+		health.AgentStatus.Transport = agentTransport
 		health.AgentStatus.JavaProcesses = agentState.JavaProcesses
 		health.AgentStatus.ListeningPorts = agentState.Ports
 		health.AgentStatus.Services = agentState.Services
@@ -3568,9 +4275,16 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 				health.ProcessStatus.Running = true
 				health.ProcessStatus.PID = proc.PID
 				health.ProcessStatus.DetectionMethod = "agent"
+				health.ProcessStatus.UptimeSeconds = proc.UptimeSeconds
+				health.ProcessStatus.CPUPercent = proc.CPUPercent
+				health.ProcessStatus.MemoryBytes = proc.RSSBytes
+				health.ProcessStatus.MemoryPercent = proc.MemoryPercent
+				health.ProcessStatus.OpenFiles = proc.OpenFiles
 				if len(proc.ListenPorts) > 0 {
 					health.ProcessStatus.Port = fmt.Sprintf("%d", proc.ListenPorts[0])
 				}
+				health.AgentStatus.JVM = jvmReading(agentState, proc.PID)
+				h.recordJVM(serverID, serverDef, health.AgentStatus.JVM)
 				break
 			}
 		}
@@ -3593,8 +4307,7 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 			// grep found the session name in screen -list output
 			health.ScreenStatus.SessionExists = true
 			health.ScreenStatus.Streaming = true
-			log.Printf("[HealthCheck] Server %s: Screen session '%s' detected for user %s: %s", 
-				serverID, sessionName, serviceUser, strings.TrimSpace(output))
+			logger.Debug("Screen session detected", "server_id", serverID, "session", sessionName, "service_user", serviceUser, "output", strings.TrimSpace(output))
 		} else {
 			// Try alternate detection: check if session exists with direct screen -ls
 			checkCmd := fmt.Sprintf("sudo -u %s screen -ls %s", serviceUser, sessionName)
@@ -3602,11 +4315,9 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 			if altErr == nil && !strings.Contains(altOutput, "No Sockets found") {
 				health.ScreenStatus.SessionExists = true
 				health.ScreenStatus.Streaming = true
-				log.Printf("[HealthCheck] Server %s: Screen session '%s' found via screen -ls for user %s", 
-					serverID, sessionName, serviceUser)
+				logger.Debug("Screen session found via screen -ls", "server_id", serverID, "session", sessionName, "service_user", serviceUser)
 			} else {
-				log.Printf("[HealthCheck] Server %s: Screen session '%s' not found for user %s. grep output: '%s', screen -ls: '%s'", 
-					serverID, sessionName, serviceUser, strings.TrimSpace(output), strings.TrimSpace(altOutput))
+				logger.Debug("Screen session not found", "server_id", serverID, "session", sessionName, "service_user", serviceUser, "grep_output", strings.TrimSpace(output), "screen_ls", strings.TrimSpace(altOutput))
 			}
 		}
 	}
@@ -3642,6 +4353,8 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 		}
 	}
 
+	applyGameStatus(&health, game())
+
 	// Determine overall connection status
 	if health.ProcessStatus.Running {
 		health.ConnectionStatus = models.StatusRunning
@@ -3651,54 +4364,61 @@ func (h *ServerHandler) performHealthCheck(serverID string, serverDef config.Ser
 		health.ConnectionStatus = models.StatusDisconnected
 	}
 
-	log.Printf("[HealthCheck] Server %s: SSH=%v, Agent=%v, Process=%v (PID=%d, Method=%s), Screen=%v",
-		serverID,
-		health.SSHStatus.Connected,
-		health.AgentStatus.Connected,
-		health.ProcessStatus.Running,
-		health.ProcessStatus.PID,
-		health.ProcessStatus.DetectionMethod,
-		health.ScreenStatus.Streaming,
-	)
+	logger.Debug("Health check complete", "server_id", serverID, "ssh", health.SSHStatus.Connected, "agent", health.AgentStatus.Connected, "process", health.ProcessStatus.Running, "pid", health.ProcessStatus.PID, "detection_method", health.ProcessStatus.DetectionMethod, "screen", health.ScreenStatus.Streaming)
 
 	return health
 }
 
-// fetchAgentState fetches agent state from the agent, returns nil if unavailable
-func (h *ServerHandler) fetchAgentState(serverID string, serverDef config.ServerDefinition) *AgentState {
-	if strings.TrimSpace(serverDef.Connection.Host) == "" {
-		return nil
+// probeGame starts probing a server's game ports and returns a function
+// waiting for the result, which is nil when probes are disabled
+func (h *ServerHandler) probeGame(serverDef config.ServerDefinition) func() *query.Status {
+	host := strings.TrimSpace(serverDef.Query.Host)
+	if host == "" {
+		host = strings.TrimSpace(serverDef.Connection.Host)
+	}
+	if serverDef.Query.Disabled || host == "" {
+		return func() *query.Status { return nil }
 	}
 
-	clientCert, err := agentcert.GetClientCert(h.db.DB, "server-manager")
-	if err != nil || clientCert == nil {
-		return nil
+	result := make(chan query.Status, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), query.DefaultTimeout)
+		defer cancel()
+		result <- query.Probe(ctx, host, serverDef.Query.Port, serverDef.Query.QueryPort)
+	}()
+	return func() *query.Status {
+		status := <-result
+		return &status
 	}
+}
 
-	cert, err := tls.X509KeyPair(clientCert.CertPEM, clientCert.KeyPEM)
-	if err != nil {
-		return nil
+// applyGameStatus records a game probe in a health check and counts a server
+// answering it as running when nothing else found the process
+func applyGameStatus(health *HealthCheck, status *query.Status) bool {
+	health.Game = status
+	if status == nil || !status.Online {
+		return false
 	}
+	if !health.ProcessStatus.Running {
+		health.ProcessStatus.Running = true
+		health.ProcessStatus.DetectionMethod = "query"
+	}
+	return true
+}
 
-	caPath := filepath.Join(h.config.Storage.DataDir, "agent-ca", "ca.crt")
-	caData, err := os.ReadFile(caPath)
-	if err != nil {
+// fetchAgentState fetches agent state from the agent, returns nil if unavailable
+func (h *ServerHandler) fetchAgentState(serverID string, serverDef config.ServerDefinition) *AgentState {
+	if strings.TrimSpace(serverDef.Connection.Host) == "" {
 		return nil
 	}
-	pool := x509.NewCertPool()
-	if !pool.AppendCertsFromPEM(caData) {
+
+	tlsConfig, err := agentcert.ClientTLSConfig(h.db.DB, h.config.Storage.DataDir)
+	if err != nil {
 		return nil
 	}
-
 	client := &http.Client{
-		Timeout: 3 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				MinVersion:   tls.VersionTLS12,
-				RootCAs:      pool,
-				Certificates: []tls.Certificate{cert},
-			},
-		},
+		Timeout:   3 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
 	}
 
 	url := fmt.Sprintf("https://%s:9443/state", strings.TrimSpace(serverDef.Connection.Host))
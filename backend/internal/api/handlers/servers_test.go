@@ -1,19 +1,25 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
 	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
 	"github.com/TheGojiOG/HytaleSM/internal/server"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
@@ -118,6 +124,7 @@ func setupTestServerHandler(t *testing.T) (*ServerHandler, *MockProcessManager,
 		mockPM,
 		activityLogger,
 		hub,
+		metrics.NewWriter(dbWrapper),
 	)
 
 	return handler, mockPM, mockExecutor, sm
@@ -167,18 +174,13 @@ func TestServerHandler_StartServer(t *testing.T) {
 			return "60", nil
 		},
 		"netstat": func(c string) (string, error) {
-			return "", nil // Port listening (no error = listening? No, grep returns 0 exit code)
-			// Wait, if execution succeeds and output is empty?
-			// The command is `netstat ... | grep ...`.
-			// If grep finds match, it outputs match line.
-			// So we should return "some line" to simulate match?
-			// "tcp 0 0 0.0.0.0:25565 ..."
-			return "tcp 0 0 0.0.0.0:25565 LISTEN", nil
+			return "", nil
 		},
 	}
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/servers/test-server/start", nil)
 
     // Setup params and claims
     c.Params = gin.Params{{Key: "id", Value: "test-server"}}
@@ -202,3 +204,188 @@ func TestServerHandler_StartServer(t *testing.T) {
     // Close logger to release file lock for cleanup
     handler.activityLogger.Close()
 }
+
+func TestServerHandler_UpdateServerStaleVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, sm := setupTestServerHandler(t)
+
+	current, _ := sm.GetByID("test-server")
+	current.Name = "Renamed"
+	body, _ := json.Marshal(current)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "test-server"}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/servers/test-server", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler.UpdateServer(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	details, _ := c.Get(middleware.AuditDetailsKey)
+	changes, _ := details.(map[string]interface{})["changes"].([]config.FieldChange)
+	if len(changes) != 1 || changes[0].Field != "name" || changes[0].New != "Renamed" {
+		t.Fatalf("Expected the rename in the audit details, got %+v", details)
+	}
+
+	// A second write still carrying the old version must be rejected
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "test-server"}}
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/servers/test-server", bytes.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("If-Match", serverETag(current.Version))
+	handler.UpdateServer(c)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServerHandler_GetServerActivityPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	for i := 0; i < 5; i++ {
+		if err := handler.activityLogger.LogActivity(&logging.Activity{ServerID: "test-server", ActivityType: logging.ActivityServerStart, Success: true}); err != nil {
+			t.Fatalf("failed to log activity: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.GET("/api/v1/servers/:id/activity", handler.GetServerActivity)
+	router.GET("/api/v2/servers/:id/activity", handler.GetServerActivity)
+
+	var seen []int64
+	cursor := ""
+	for pages := 0; pages < 5; pages++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v2/servers/test-server/activity?limit=2&cursor="+cursor, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var page struct {
+			Data       []logging.Activity `json:"data"`
+			Pagination database.PageInfo  `json:"pagination"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatalf("failed to decode page: %v", err)
+		}
+		if page.Pagination.Total != 5 {
+			t.Fatalf("expected total 5, got %d", page.Pagination.Total)
+		}
+		for _, activity := range page.Data {
+			seen = append(seen, activity.ID)
+		}
+		cursor = page.Pagination.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	if len(seen) != 5 || seen[0] <= seen[4] {
+		t.Fatalf("expected 5 activities newest first, got %v", seen)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/servers/test-server/activity?limit=2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Header().Get(TotalCountHeader) != "5" || w.Header().Get(NextCursorHeader) == "" {
+		t.Fatalf("expected v1 pagination headers, got %v", w.Header())
+	}
+	var legacy map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil || legacy["activities"] == nil {
+		t.Fatalf("expected legacy activities body, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/servers/test-server/activity?cursor=bogus", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid cursor, got %d", w.Code)
+	}
+}
+
+func TestServerHandler_GetMetricsExportsCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+	if _, err := handler.db.Exec(`CREATE TABLE server_metrics (
+		id INTEGER PRIMARY KEY,
+		server_id TEXT,
+		timestamp DATETIME,
+		cpu_usage REAL,
+		memory_used INTEGER,
+		memory_total INTEGER,
+		disk_used INTEGER,
+		disk_total INTEGER,
+		network_rx INTEGER,
+		network_tx INTEGER,
+		status TEXT
+	)`); err != nil {
+		t.Fatalf("failed to create metrics table: %v", err)
+	}
+	for _, at := range []string{"2026-03-01 12:00:00", "2026-03-02 12:00:00", "2026-03-03 12:00:00"} {
+		if _, err := handler.db.Exec(`INSERT INTO server_metrics (server_id, timestamp, cpu_usage, memory_used, status) VALUES ('test-server', ?, 12.5, 1024, 'running')`, at); err != nil {
+			t.Fatalf("failed to insert metrics: %v", err)
+		}
+	}
+
+	router := gin.New()
+	router.GET("/api/v2/servers/:id/metrics", handler.GetMetrics)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/servers/test-server/metrics?format=csv&from=2026-03-02&to=2026-03-03", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Fatalf("expected a CSV response, got %q", got)
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	// The header, then the samples of both days of the range
+	if len(records) != 3 || records[0][1] != "timestamp" || records[1][2] != "12.5" {
+		t.Fatalf("unexpected CSV %v", records)
+	}
+	if w.Header().Get(TotalCountHeader) != "2" {
+		t.Fatalf("expected a total of 2, got %v", w.Header())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v2/servers/test-server/metrics?from=2026-03-03&to=2026-03-01", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an empty range, got %d", w.Code)
+	}
+}
+
+func TestServerHandler_ShutdownCancelsTasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/servers/test-server/benchmark", nil)
+
+	started := make(chan struct{})
+	handler.goTask(c, "test-server", "long-task", func(ctx context.Context, task *taskRecord) {
+		close(started)
+		<-ctx.Done()
+		handler.finishTask("test-server", task.ID, ctx.Err())
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handler.Shutdown(ctx); err != nil {
+		t.Fatalf("expected tasks to stop before the deadline, got %v", err)
+	}
+
+	tasks := handler.listTasks("test-server")
+	if len(tasks) != 1 || tasks[0].Status != taskStatusFailed {
+		t.Fatalf("expected the cancelled task to be marked failed, got %+v", tasks)
+	}
+}
@@ -5,82 +5,249 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/features"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
 )
 
 type SettingsHandler struct {
-	cfg        *config.Config
-	configPath string
+	configPath  string
+	maintenance *middleware.MaintenanceState
+	reloader    *config.Reloader
+	history     *config.History
 }
 
 type SettingsPayload struct {
 	Security config.SecurityConfig `json:"security"`
 	Logging  config.LoggingConfig  `json:"logging"`
 	Metrics  config.MetricsConfig  `json:"metrics"`
+	// Features overrides feature flags by name; omitted keeps the current overrides
+	Features map[string]bool `json:"features"`
 }
 
 type SettingsResponse struct {
 	Security        config.SecurityConfig `json:"security"`
 	Logging         config.LoggingConfig  `json:"logging"`
 	Metrics         config.MetricsConfig  `json:"metrics"`
+	Features        map[string]bool       `json:"features"`
 	RequiresRestart bool                 `json:"requires_restart"`
 }
 
-func NewSettingsHandler(cfg *config.Config) *SettingsHandler {
+type MaintenancePayload struct {
+	Enabled      bool     `json:"enabled"`
+	Message      string   `json:"message"`
+	AllowedRoles []string `json:"allowed_roles"`
+}
+
+func NewSettingsHandler(maintenance *middleware.MaintenanceState, reloader *config.Reloader, history *config.History) *SettingsHandler {
 	return &SettingsHandler{
-		cfg:        cfg,
-		configPath: config.GetConfigPath(),
+		configPath:  config.GetConfigPath(),
+		maintenance: maintenance,
+		reloader:    reloader,
+		history:     history,
+	}
+}
+
+// saveConfig writes config.yaml and snapshots it into the config history
+func (h *SettingsHandler) saveConfig(cfg *config.Config) error {
+	if err := config.Save(cfg, h.configPath); err != nil {
+		return err
+	}
+	if err := h.history.Record("config.yaml"); err != nil {
+		logger.Warn("Failed to snapshot config.yaml", "error", err)
 	}
+	return nil
 }
 
 func (h *SettingsHandler) GetSettings(c *gin.Context) {
+	running := h.reloader.Config()
 	c.JSON(http.StatusOK, SettingsResponse{
-		Security:        h.cfg.Security,
-		Logging:         h.cfg.Logging,
-		Metrics:         h.cfg.Metrics,
-		RequiresRestart: true,
+		Security:        running.Security,
+		Logging:         running.Logging,
+		Metrics:         running.Metrics,
+		Features:        running.Features,
+		RequiresRestart: false,
 	})
 }
 
 func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 	var payload SettingsPayload
 	if err := c.ShouldBindJSON(&payload); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	payload.Security.CORS.AllowedOrigins = normalizeList(payload.Security.CORS.AllowedOrigins)
 	payload.Security.CORS.AllowedMethods = normalizeList(payload.Security.CORS.AllowedMethods)
 
+	running := h.reloader.Config()
 	if payload.Metrics.DefaultInterval <= 0 {
-		payload.Metrics.DefaultInterval = h.cfg.Metrics.DefaultInterval
+		payload.Metrics.DefaultInterval = running.Metrics.DefaultInterval
 	}
 	if payload.Metrics.RetentionDays <= 0 {
-		payload.Metrics.RetentionDays = h.cfg.Metrics.RetentionDays
+		payload.Metrics.RetentionDays = running.Metrics.RetentionDays
+	}
+	if payload.Metrics.StatusInterval <= 0 {
+		payload.Metrics.StatusInterval = running.Metrics.StatusInterval
+	}
+	if payload.Metrics.LiveConcurrency <= 0 {
+		payload.Metrics.LiveConcurrency = running.Metrics.LiveConcurrency
+	}
+	if payload.Metrics.LiveTimeout <= 0 {
+		payload.Metrics.LiveTimeout = running.Metrics.LiveTimeout
+	}
+	if payload.Logging.Modules == nil {
+		payload.Logging.Modules = running.Logging.Modules
+	}
+	if err := payload.Logging.Validate(); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	if payload.Features == nil {
+		payload.Features = running.Features
+	}
+	if err := features.Validate(payload.Features); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
 	}
 
-	updated := *h.cfg
+	updated := running
 	updated.Security = payload.Security
 	updated.Logging = payload.Logging
 	updated.Metrics = payload.Metrics
+	updated.Features = payload.Features
 
-	if err := config.Save(&updated, h.configPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings", "details": err.Error()})
+	if err := h.saveConfig(&updated); err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save settings", err.Error())
 		return
 	}
 
-	h.cfg.Security = updated.Security
-	h.cfg.Logging = updated.Logging
-	h.cfg.Metrics = updated.Metrics
+	// Some flags are only read at startup
+	restart := false
+	for _, flag := range features.All() {
+		if flag.RequiresRestart && features.EnabledIn(updated.Features, flag.Name) != features.EnabledIn(running.Features, flag.Name) {
+			restart = true
+		}
+	}
+
+	// Log level, CORS, rate limits, metrics and feature flags apply immediately; the rest on restart
+	result := h.reloader.Apply(&updated)
+	restart = restart || len(result.RequiresRestart) > 0
 
 	c.JSON(http.StatusOK, SettingsResponse{
-		Security:        h.cfg.Security,
-		Logging:         h.cfg.Logging,
-		Metrics:         h.cfg.Metrics,
-		RequiresRestart: true,
+		Security:        updated.Security,
+		Logging:         updated.Logging,
+		Metrics:         updated.Metrics,
+		Features:        updated.Features,
+		RequiresRestart: restart,
 	})
 }
 
+// ReloadConfig re-reads config.yaml and applies the settings that can change at runtime
+func (h *SettingsHandler) ReloadConfig(c *gin.Context) {
+	result, err := h.reloader.Reload()
+	if err != nil {
+		logger.WarnContext(c.Request.Context(), "Reload rejected", "error", err)
+		apierror.RespondDetails(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "Failed to reload configuration", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// LogLevelsPayload changes log levels at runtime. An empty module level
+// removes that module's override.
+type LogLevelsPayload struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+// GetLogLevels reports the global and per-module log levels in effect
+func (h *SettingsHandler) GetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, logging.CurrentLevels())
+}
+
+// UpdateLogLevels changes log levels without touching config.yaml. The change
+// lasts until the next restart or configuration reload.
+func (h *SettingsHandler) UpdateLogLevels(c *gin.Context) {
+	var payload LogLevelsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	if !config.ValidLogLevel(payload.Level) {
+		apierror.RespondDetails(c, http.StatusBadRequest, apierror.CodeValidationFailed, "Invalid log level", gin.H{"field": "level"})
+		return
+	}
+	for module, level := range payload.Modules {
+		if strings.TrimSpace(module) == "" || !config.ValidLogLevel(level) {
+			apierror.RespondDetails(c, http.StatusBadRequest, apierror.CodeValidationFailed, "Invalid log level", gin.H{"field": "modules." + module})
+			return
+		}
+	}
+
+	if strings.TrimSpace(payload.Level) != "" {
+		logging.SetLevel(payload.Level)
+	}
+	for module, level := range payload.Modules {
+		logging.SetModuleLevel(module, level)
+	}
+
+	levels := logging.CurrentLevels()
+	logger.InfoContext(c.Request.Context(), "Log levels changed", "level", levels.Level, "modules", levels.Modules, "user", c.GetString("username"))
+	c.JSON(http.StatusOK, levels)
+}
+
+// GetFeatures lists the feature flags with the value in effect, so the
+// frontend can hide experimental capabilities that are off
+func (h *SettingsHandler) GetFeatures(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"features": features.States()})
+}
+
+func (h *SettingsHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, h.maintenance.Status())
+}
+
+// UpdateMaintenance toggles the global read-only lock. It takes effect immediately.
+func (h *SettingsHandler) UpdateMaintenance(c *gin.Context) {
+	var payload MaintenancePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	current := h.maintenance.Config()
+	updated := config.MaintenanceConfig{
+		Enabled:      payload.Enabled,
+		Message:      strings.TrimSpace(payload.Message),
+		AllowedRoles: normalizeList(payload.AllowedRoles),
+	}
+	if updated.Message == "" {
+		updated.Message = current.Message
+	}
+	if len(updated.AllowedRoles) == 0 {
+		updated.AllowedRoles = current.AllowedRoles
+	}
+
+	persisted := h.reloader.Config()
+	persisted.Maintenance = updated
+	if err := h.saveConfig(&persisted); err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to save settings", err.Error())
+		return
+	}
+
+	changedBy := ""
+	if username, exists := c.Get("username"); exists {
+		changedBy, _ = username.(string)
+	}
+	h.maintenance.Set(updated, changedBy)
+	h.reloader.Apply(&persisted)
+
+	c.JSON(http.StatusOK, h.maintenance.Status())
+}
+
 func normalizeList(values []string) []string {
 	clean := make([]string, 0, len(values))
 	for _, value := range values {
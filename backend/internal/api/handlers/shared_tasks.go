@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// sharedTaskStore keeps task records and their output in the database, so
+// task history survives restarts and every manager instance of a cluster
+// lists and streams the tasks the others run. It replaces the in-memory task
+// state for reads; writes still go to both.
+type sharedTaskStore struct {
+	db       *sql.DB
+	instance string
+	// clustered is set when other instances may run tasks in the same
+	// database, so restore only closes this instance's tasks
+	clustered bool
+}
+
+// saveTask writes the task record, replacing any earlier version, and drops
+// the server's tasks beyond maxTasksPerServer
+func (s *sharedTaskStore) saveTask(serverID string, record taskRecord) {
+	if s == nil {
+		return
+	}
+	var finishedAt, method, path, params interface{}
+	if record.FinishedAt != nil {
+		finishedAt = record.FinishedAt.UTC()
+	}
+	if record.origin != nil {
+		method, path, params = record.origin.Method, record.origin.Path, string(record.origin.Body)
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO server_tasks (id, server_id, task, status, started_at, finished_at, error, request_id, instance_id, rerun_of, method, path, params)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status = excluded.status,
+			started_at = excluded.started_at,
+			finished_at = excluded.finished_at,
+			error = excluded.error
+	`, record.ID, serverID, record.Task, string(record.Status), record.StartedAt.UTC(), finishedAt, record.Error, record.RequestID, s.instance,
+		record.RerunOf, method, path, params)
+	if err != nil {
+		logger.Warn("Failed to share task", "server_id", serverID, "task_id", record.ID, "error", err)
+		return
+	}
+	if record.FinishedAt == nil {
+		s.trim(serverID)
+	}
+}
+
+func (s *sharedTaskStore) trim(serverID string) {
+	_, err := s.db.Exec(`
+		DELETE FROM server_tasks
+		WHERE server_id = ? AND id NOT IN (
+			SELECT id FROM server_tasks WHERE server_id = ? ORDER BY started_at DESC LIMIT ?
+		)
+	`, serverID, serverID, maxTasksPerServer)
+	if err == nil {
+		_, err = s.db.Exec(`
+			DELETE FROM server_task_lines
+			WHERE server_id = ? AND task_id NOT IN (SELECT id FROM server_tasks WHERE server_id = ?)
+		`, serverID, serverID)
+	}
+	if err != nil {
+		logger.Warn("Failed to trim shared tasks", "server_id", serverID, "error", err)
+	}
+}
+
+// appendLine adds one output line to the task's log and returns its number,
+// the line's row ID, or 0 when it was not stored
+func (s *sharedTaskStore) appendLine(serverID string, entry taskStreamLine) int64 {
+	if s == nil {
+		return 0
+	}
+	result, err := s.db.Exec(`
+		INSERT INTO server_task_lines (task_id, server_id, task, line, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, entry.TaskID, serverID, entry.Task, entry.Line, entry.Timestamp.UTC())
+	if err != nil {
+		logger.Warn("Failed to share task output", "server_id", serverID, "task_id", entry.TaskID, "error", err)
+		return 0
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return 0
+	}
+	return seq
+}
+
+// listTasks returns the server's recent tasks, oldest first
+func (s *sharedTaskStore) listTasks(serverID string) ([]*taskRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT `+taskColumns+`
+		FROM server_tasks t
+		WHERE t.server_id = ?
+		ORDER BY t.started_at DESC
+		LIMIT ?
+	`, serverID, maxTasksPerServer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*taskRecord
+	for rows.Next() {
+		record, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items, nil
+}
+
+// getTask returns one of the server's tasks, or errTaskNotFound
+func (s *sharedTaskStore) getTask(serverID, taskID string) (*taskRecord, error) {
+	record, err := scanTask(s.db.QueryRow(`
+		SELECT `+taskColumns+`
+		FROM server_tasks t
+		WHERE t.id = ? AND t.server_id = ?
+	`, taskID, serverID))
+	if err == sql.ErrNoRows {
+		return nil, errTaskNotFound
+	}
+	return record, err
+}
+
+// taskColumns are the server_tasks columns scanTask reads, with the task's
+// last output line
+const taskColumns = `t.id, t.task, t.status, t.started_at, t.finished_at, t.error, t.request_id, t.rerun_of,
+			t.method, t.path, t.params,
+			(SELECT l.line FROM server_task_lines l WHERE l.task_id = t.id ORDER BY l.id DESC LIMIT 1)`
+
+func scanTask(row interface{ Scan(...interface{}) error }) (*taskRecord, error) {
+	var (
+		record                                taskRecord
+		status                                string
+		finishedAt                            sql.NullTime
+		taskErr, requestID, rerunOf, lastLine sql.NullString
+		method, path, params                  sql.NullString
+	)
+	if err := row.Scan(&record.ID, &record.Task, &status, &record.StartedAt, &finishedAt, &taskErr, &requestID, &rerunOf,
+		&method, &path, &params, &lastLine); err != nil {
+		return nil, err
+	}
+	record.Status = taskStatus(status)
+	if finishedAt.Valid {
+		record.FinishedAt = &finishedAt.Time
+	}
+	record.Error = taskErr.String
+	record.RequestID = requestID.String
+	record.RerunOf = rerunOf.String
+	record.LastLine = lastLine.String
+	if method.Valid && path.Valid {
+		record.origin = &taskOrigin{Method: method.String, Path: path.String}
+		if params.String != "" {
+			record.origin.Body = []byte(params.String)
+		}
+	}
+	return &record, nil
+}
+
+// recentLines returns the server's last max output lines numbered after
+// since, oldest first
+func (s *sharedTaskStore) recentLines(serverID string, since int64, max int) ([]taskStreamLine, error) {
+	rows, err := s.db.Query(`
+		SELECT `+taskLineColumns+`
+		WHERE l.server_id = ? AND l.id > ?
+		ORDER BY l.id DESC
+		LIMIT ?
+	`, serverID, since, max)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := scanTaskLines(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, nil
+}
+
+// readLog returns every line a task wrote
+func (s *sharedTaskStore) readLog(serverID, taskID string) ([]taskStreamLine, error) {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM server_tasks WHERE id = ? AND server_id = ?`, taskID, serverID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, errTaskLogNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+taskLineColumns+`
+		WHERE l.task_id = ?
+		ORDER BY l.id
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return scanTaskLines(rows)
+}
+
+// taskLineColumns selects the output lines scanTaskLines reads, with the ID
+// of the request that started their task
+const taskLineColumns = `l.id, l.task_id, l.task, l.line, l.created_at, t.request_id
+		FROM server_task_lines l LEFT JOIN server_tasks t ON t.id = l.task_id`
+
+func scanTaskLines(rows *sql.Rows) ([]taskStreamLine, error) {
+	defer rows.Close()
+	lines := make([]taskStreamLine, 0, 256)
+	for rows.Next() {
+		var (
+			entry     taskStreamLine
+			requestID sql.NullString
+		)
+		if err := rows.Scan(&entry.Seq, &entry.TaskID, &entry.Task, &entry.Line, &entry.Timestamp, &requestID); err != nil {
+			return nil, err
+		}
+		entry.RequestID = requestID.String
+		lines = append(lines, entry)
+	}
+	return lines, rows.Err()
+}
+
+// restore records the tasks this instance was running or had queued when it
+// stopped as interrupted, and removes tasks older than retention. Outside a
+// cluster every such task was this instance's, whatever ID it had before the
+// restart.
+func (s *sharedTaskStore) restore(retention time.Duration) {
+	now := time.Now().UTC()
+	query := `UPDATE server_tasks SET status = ?, error = ?, finished_at = ? WHERE status IN (?, ?)`
+	args := []interface{}{string(taskStatusInterrupted), "interrupted by manager restart", now, string(taskStatusRunning), string(taskStatusQueued)}
+	if s.clustered {
+		query += ` AND instance_id = ?`
+		args = append(args, s.instance)
+	}
+	_, err := s.db.Exec(query, args...)
+	if err != nil {
+		logger.Warn("Failed to close interrupted shared tasks", "error", err)
+	}
+
+	if retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-retention)
+	_, err = s.db.Exec(`DELETE FROM server_tasks WHERE started_at < ? AND status NOT IN (?, ?)`, cutoff, string(taskStatusRunning), string(taskStatusQueued))
+	if err == nil {
+		_, err = s.db.Exec(`DELETE FROM server_task_lines WHERE task_id NOT IN (SELECT id FROM server_tasks)`)
+	}
+	if err != nil {
+		logger.Warn("Failed to remove old shared tasks", "error", err)
+	}
+}
+
+// failOrphaned records the running and queued tasks of instances that are no
+// longer running as interrupted
+func (s *sharedTaskStore) failOrphaned(alive []string) {
+	if len(alive) == 0 {
+		return
+	}
+	args := []interface{}{string(taskStatusInterrupted), "interrupted: manager instance stopped", time.Now().UTC(), string(taskStatusRunning), string(taskStatusQueued)}
+	for _, id := range alive {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(alive)), ", ")
+	_, err := s.db.Exec(`
+		UPDATE server_tasks SET status = ?, error = ?, finished_at = ?
+		WHERE status IN (?, ?) AND instance_id NOT IN (`+placeholders+`)
+	`, args...)
+	if err != nil {
+		logger.Warn("Failed to close orphaned shared tasks", "error", err)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/gin-gonic/gin"
+)
+
+// ListSSHConnections returns the state of every server's SSH connection with
+// the pool totals
+func (h *ServerHandler) ListSSHConnections(c *gin.Context) {
+	connections, err := h.sshPool.Connections()
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to list SSH connections", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list SSH connections")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"connections": connections, "stats": h.sshPool.GetStats()})
+}
+
+// GetSSHConnection returns the state of a server's SSH connection
+func (h *ServerHandler) GetSSHConnection(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	h.respondSSHConnection(c, serverID)
+}
+
+// CloseSSHConnection drops a server's pooled SSH connection; the next
+// operation opens a new one
+func (h *ServerHandler) CloseSSHConnection(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	h.sshPool.RemoveConnection(serverID)
+	h.logConnectionAction(c, serverID, "SSH connection closed", nil)
+	h.respondSSHConnection(c, serverID)
+}
+
+// ReconnectSSHConnection drops a server's pooled SSH connection and opens a
+// new one
+func (h *ServerHandler) ReconnectSSHConnection(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		Password:   serverDef.Connection.Password,
+		KeyPath:    serverDef.Connection.KeyPath,
+	}
+
+	h.sshPool.RemoveConnection(serverID)
+	if _, err := h.sshPool.GetConnection(serverID, sshConfig); err != nil {
+		h.logConnectionAction(c, serverID, "SSH reconnect failed", err)
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
+		return
+	}
+	h.logConnectionAction(c, serverID, "SSH connection reopened", nil)
+	h.respondSSHConnection(c, serverID)
+}
+
+func (h *ServerHandler) respondSSHConnection(c *gin.Context, serverID string) {
+	info, _, err := h.sshPool.Connection(serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load SSH connection", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load SSH connection")
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *ServerHandler) logConnectionAction(c *gin.Context, serverID, description string, err error) {
+	activity := &logging.Activity{
+		ServerID:     serverID,
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivitySSHReconnect,
+		Description:  description,
+		Success:      err == nil,
+	}
+	if err != nil {
+		activity.ErrorMessage = err.Error()
+	}
+	_ = h.activityLogger.LogActivity(activity)
+}
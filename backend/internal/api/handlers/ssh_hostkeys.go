@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/gin-gonic/gin"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+// hostKeyScanTimeout bounds reading the key a host offers
+const hostKeyScanTimeout = 15 * time.Second
+
+// Host key actions
+const (
+	hostKeyApprove = "approve"
+	hostKeyRotate  = "rotate"
+	hostKeyForget  = "forget"
+)
+
+type hostKeyRequest struct {
+	Action      string `json:"action" binding:"required"`
+	Fingerprint string `json:"fingerprint"` // approve: the pending key's fingerprint
+	PublicKey   string `json:"public_key"`  // rotate: pin this key instead of reading it from the host
+}
+
+// respondSSHConnectError reports a failed SSH connection, with its own code
+// when the host offered a key that is not approved
+func respondSSHConnectError(c *gin.Context, message string, err error) {
+	var keyErr *ssh.HostKeyError
+	if errors.As(err, &keyErr) {
+		apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeHostKeyUntrusted, keyErr.Error(), gin.H{
+			"expected": keyErr.Expected,
+			"offered":  keyErr.Offered,
+		})
+		return
+	}
+	apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, message, err.Error())
+}
+
+// hostKeyStore returns the host key store, responding when there is none
+func (h *ServerHandler) hostKeyStore(c *gin.Context) (*ssh.HostKeyStore, bool) {
+	if h.sshPool == nil || h.sshPool.HostKeys() == nil {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "SSH host keys are not available")
+		return nil, false
+	}
+	return h.sshPool.HostKeys(), true
+}
+
+// GetSSHHostKey returns the SSH host key trusted for a server and the key it
+// offered last if that one is not trusted
+func (h *ServerHandler) GetSSHHostKey(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	store, ok := h.hostKeyStore(c)
+	if !ok {
+		return
+	}
+	keys, err := store.Get(c.Request.Context(), serverID)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to load SSH host keys", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load SSH host keys")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"server_id": serverID,
+		"host":      serverDef.Connection.Host,
+		"port":      serverDef.Connection.Port,
+		"local":     ssh.IsLocalHost(serverDef.Connection.Host),
+		"trusted":   keys.Trusted,
+		"pending":   keys.Pending,
+	})
+}
+
+// UpdateSSHHostKey approves the pending host key of a server, rotates the key
+// by reading the one the host offers now (or pinning a given key), or forgets
+// the keys so known_hosts decides again
+func (h *ServerHandler) UpdateSSHHostKey(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	var req hostKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if ssh.IsLocalHost(serverDef.Connection.Host) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "The server runs on the manager's host and has no SSH host key")
+		return
+	}
+	store, ok := h.hostKeyStore(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	user := c.GetString("username")
+
+	var (
+		description string
+		key         *ssh.HostKey
+		err         error
+	)
+	switch strings.ToLower(strings.TrimSpace(req.Action)) {
+	case hostKeyApprove:
+		if strings.TrimSpace(req.Fingerprint) == "" {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "fingerprint is required")
+			return
+		}
+		key, err = store.Approve(ctx, serverID, req.Fingerprint, user)
+		if errors.Is(err, ssh.ErrNoPendingHostKey) || errors.Is(err, ssh.ErrHostKeyFingerprint) {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, err.Error())
+			return
+		}
+		description = "SSH host key approved"
+
+	case hostKeyRotate:
+		if strings.TrimSpace(req.PublicKey) != "" {
+			parsed, _, _, _, parseErr := xssh.ParseAuthorizedKey([]byte(req.PublicKey))
+			if parseErr != nil {
+				apierror.Respond(c, http.StatusUnprocessableEntity, apierror.CodeValidationFailed, "public_key is not a valid SSH public key")
+				return
+			}
+			key, err = store.Pin(ctx, serverID, parsed, user)
+			description = "SSH host key pinned"
+			break
+		}
+		offered, scanErr := ssh.ScanHostKey(serverDef.Connection.Host, serverDef.Connection.Port, hostKeyScanTimeout)
+		if scanErr != nil {
+			apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, scanErr.Error())
+			return
+		}
+		// The host's key only becomes trusted once someone approves it
+		key, err = store.Offer(ctx, serverID, offered)
+		description = "SSH host key read from host"
+
+	case hostKeyForget:
+		err = store.Forget(ctx, serverID)
+		description = "SSH host keys forgotten"
+
+	default:
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "action must be approve, rotate or forget")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to update SSH host key", "server_id", serverID, "action", req.Action, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update SSH host key")
+		return
+	}
+
+	// Connections made with the old key must not outlive it
+	h.sshPool.RemoveConnection(serverID)
+
+	metadata := map[string]interface{}{"action": req.Action}
+	if key != nil {
+		metadata["fingerprint"] = key.Fingerprint
+	}
+	_ = h.activityLogger.LogActivity(&logging.Activity{
+		ServerID:     serverID,
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivitySSHHostKey,
+		Description:  description,
+		Metadata:     metadata,
+		Success:      true,
+	})
+
+	keys, err := store.Get(ctx, serverID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to load SSH host keys", "server_id", serverID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load SSH host keys")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"server_id": serverID,
+		"host":      serverDef.Connection.Host,
+		"port":      serverDef.Connection.Port,
+		"local":     false,
+		"trusted":   keys.Trusted,
+		"pending":   keys.Pending,
+	})
+}
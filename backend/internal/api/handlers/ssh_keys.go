@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/credentials"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/gin-gonic/gin"
+)
+
+type rotateKeyRequest struct {
+	KeepOldKey bool `json:"keep_old_key"` // leave the old key in authorized_keys
+}
+
+// RotateSSHKey replaces the key the manager logs in to a server with: a new
+// key is added to the SSH user's authorized_keys, checked, stored encrypted
+// and the old key is removed from the host
+func (h *ServerHandler) RotateSSHKey(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	var req rotateKeyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	if ssh.IsLocalHost(serverDef.Connection.Host) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "The server runs on the manager's host and logs in without SSH")
+		return
+	}
+	if serverDef.Connection.AuthMethod != "key" || serverDef.Connection.KeyPath == "" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Key rotation needs a server that logs in with an SSH key")
+		return
+	}
+	if h.db == nil {
+		apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Credential storage is not available")
+		return
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
+		KeyPath:    serverDef.Connection.KeyPath,
+	}
+	conn, err := h.sshPool.GetConnection(serverID, sshConfig)
+	if err != nil {
+		respondSSHConnectError(c, "Failed to connect via SSH", err)
+		return
+	}
+
+	keyPath := h.sshKeyPath(serverID)
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to store SSH key", err.Error())
+		return
+	}
+	store := credentials.NewStore(h.db.DB)
+	result, err := store.RotateSSHKey(c.Request.Context(), credentials.SSHKeyRotation{
+		ServerID: serverID,
+		Current:  conn.Client,
+		Dial: func(path string) (credentials.RemoteConn, error) {
+			dialConfig := *sshConfig
+			dialConfig.KeyPath = path
+			return h.sshPool.Dial(serverID, &dialConfig)
+		},
+		CurrentKeyPath: serverDef.Connection.KeyPath,
+		KeyPath:        keyPath,
+		KeepOldKey:     req.KeepOldKey,
+	})
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to rotate SSH key", "server_id", serverID, "error", err)
+		_ = h.activityLogger.LogActivity(&logging.Activity{
+			ServerID:     serverID,
+			UserID:       getUserIDFromContext(c),
+			ActivityType: logging.ActivitySSHKeyRotate,
+			Description:  "SSH key rotation failed",
+			Success:      false,
+			ErrorMessage: err.Error(),
+		})
+		apierror.RespondDetails(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, "Failed to rotate SSH key", err.Error())
+		return
+	}
+
+	// The first rotation moves a key given by path into the manager's key store
+	if serverDef.Connection.KeyPath != keyPath {
+		err := h.serverManager.Persist(func() error {
+			current, found := h.serverManager.GetByID(serverID)
+			if !found {
+				return config.ErrServerNotFound
+			}
+			current.Connection.KeyPath = keyPath
+			return h.serverManager.Update(current)
+		})
+		if err != nil && !errors.Is(err, config.ErrServerNotFound) {
+			logger.ErrorContext(c.Request.Context(), "Failed to save rotated SSH key path", "server_id", serverID, "error", err)
+			apierror.RespondDetails(c, http.StatusInternalServerError, apierror.CodeInternal, "SSH key rotated but the server could not be saved", err.Error())
+			return
+		}
+		h.invalidateServer(serverID)
+	}
+	h.sshPool.RemoveConnection(serverID)
+
+	_ = h.activityLogger.LogActivity(&logging.Activity{
+		ServerID:     serverID,
+		UserID:       getUserIDFromContext(c),
+		ActivityType: logging.ActivitySSHKeyRotate,
+		Description:  "SSH key rotated",
+		Metadata: map[string]interface{}{
+			"fingerprint":         result.Key.Fingerprint,
+			"retired_fingerprint": result.Retired,
+			"retire_error":        result.RetireError,
+		},
+		Success: true,
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/TheGojiOG/HytaleSM/internal/startup"
+)
+
+// BulkStartRequest selects the servers of a bulk start; empty means all
+type BulkStartRequest struct {
+	ServerIDs []string `json:"server_ids"`
+}
+
+// BulkStart starts several servers in the background, each after the
+// servers it starts after. The response lists the order.
+func (h *ServerHandler) BulkStart(c *gin.Context) {
+	var req BulkStartRequest
+	if c.Request != nil && c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+
+	plan, err := startup.Plan(h.serverManager.GetAll(), req.ServerIDs)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+
+	logger.InfoContext(c.Request.Context(), "Bulk start requested", "steps", plan.Steps, "user_id", getUserIDFromContext(c))
+	h.runStartSequence(plan, "bulk start")
+	c.JSON(http.StatusAccepted, gin.H{"message": "Bulk start initiated", "steps": plan.Steps})
+}
+
+// AutoStartServers starts the servers marked auto_start in the background,
+// in dependency order. It is called once when the manager starts.
+func (h *ServerHandler) AutoStartServers() {
+	var ids []string
+	for _, serverDef := range h.serverManager.GetAll() {
+		if serverDef.AutoStart {
+			ids = append(ids, serverDef.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	plan, err := startup.Plan(h.serverManager.GetAll(), ids)
+	if err != nil {
+		logger.Error("Cannot auto-start servers", "error", err)
+		return
+	}
+	logger.Info("Auto-starting servers", "steps", plan.Steps)
+	h.runStartSequence(plan, "auto-start")
+}
+
+func (h *ServerHandler) runStartSequence(plan *startup.Sequence, name string) {
+	h.pendingOps.Add(1)
+	go func() {
+		defer h.pendingOps.Done()
+		for _, result := range plan.Run(h.tasksCtx, h) {
+			if result.Status == startup.StatusStarted {
+				continue
+			}
+			logger.Warn("Server not started by "+name, "server_id", result.ServerID, "status", result.Status, "error", result.Error)
+			if result.Status == startup.StatusSkipped {
+				h.activityLogger.LogServerStart(result.ServerID, nil, false, result.Error)
+			}
+		}
+	}()
+}
+
+// EnsureStarted starts a server for a start sequence unless it is already
+// running
+func (h *ServerHandler) EnsureStarted(ctx context.Context, serverID string) error {
+	if window, active := h.inMaintenance(serverID); active {
+		return errors.New(maintenanceError(window))
+	}
+	serverDef, _, err := h.connectServer(serverID)
+	if err != nil {
+		return err
+	}
+	serverConfig := h.createServerConfig(serverDef)
+
+	h.processManager.SetRunAsUser(serverID, serverConfig.RunAsUser, serverConfig.UseSudo)
+	status, err := h.statusDetector.DetectStatus(serverID, serverConfig.SessionName)
+	if err == nil && (status.Status == server.StatusOnline || status.Status == server.StatusStarting) {
+		return nil
+	}
+
+	h.resetWatchdog(serverID)
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(serverID)
+
+	if err := h.lifecycleManager.StartServer(serverID, serverConfig); err != nil {
+		h.activityLogger.LogServerStart(serverID, nil, false, err.Error())
+		return err
+	}
+	h.activityLogger.LogServerStart(serverID, nil, true, "")
+	return nil
+}
+
+// PortListening reports whether a port is listening on a server's host
+func (h *ServerHandler) PortListening(ctx context.Context, serverID string, port int) (bool, error) {
+	if _, _, err := h.connectServer(serverID); err != nil {
+		return false, err
+	}
+	return h.statusDetector.CheckPort(serverID, port)
+}
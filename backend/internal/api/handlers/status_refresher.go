@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+)
+
+// defaultStatusInterval applies when monitoring.status_interval is unset
+const defaultStatusInterval = 15 * time.Second
+
+// statusSnapshot is the result of the last background health check for a server
+type statusSnapshot struct {
+	Health    HealthCheck
+	CheckedAt time.Time
+}
+
+// StatusChangeFunc is called when a server's connection status changes between checks
+type StatusChangeFunc func(serverID string, previous, current models.ServerConnectionStatus)
+
+// StatusRefresher runs health checks for every server in the background so
+// request handlers read cached results instead of waiting on SSH, screen and
+// pgrep probes. Each server is probed independently, so one unreachable host
+// does not delay the others.
+type StatusRefresher struct {
+	servers  func() []string
+	probe    func(serverID string) (HealthCheck, bool)
+	interval atomic.Int64
+
+	mu        sync.RWMutex
+	snapshots map[string]statusSnapshot
+	inflight  map[string]bool
+	listeners []StatusChangeFunc
+
+	wake chan string
+}
+
+// NewStatusRefresher creates a refresher. servers lists the IDs to keep
+// current and probe checks one of them, returning false if it no longer exists.
+func NewStatusRefresher(servers func() []string, probe func(serverID string) (HealthCheck, bool), interval time.Duration) *StatusRefresher {
+	r := &StatusRefresher{
+		servers:   servers,
+		probe:     probe,
+		snapshots: make(map[string]statusSnapshot),
+		inflight:  make(map[string]bool),
+		wake:      make(chan string, 64),
+	}
+	r.SetInterval(interval)
+	return r
+}
+
+// SetInterval changes how often every server is re-checked. It takes effect
+// after the current wait.
+func (r *StatusRefresher) SetInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultStatusInterval
+	}
+	r.interval.Store(int64(interval))
+}
+
+// OnChange registers fn to be called whenever a server's connection status changes
+func (r *StatusRefresher) OnChange(fn StatusChangeFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Run checks every server immediately and then on each interval until ctx is done
+func (r *StatusRefresher) Run(ctx context.Context) {
+	r.refreshAll()
+	timer := time.NewTimer(time.Duration(r.interval.Load()))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case serverID := <-r.wake:
+			r.refreshOne(serverID)
+		case <-timer.C:
+			r.refreshAll()
+			timer.Reset(time.Duration(r.interval.Load()))
+		}
+	}
+}
+
+// Get returns the last check for a server
+func (r *StatusRefresher) Get(serverID string) (statusSnapshot, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snapshot, ok := r.snapshots[serverID]
+	return snapshot, ok
+}
+
+// Refresh asks for a server to be re-checked soon, e.g. after a lifecycle
+// action. It never blocks; if the refresher is busy the next sweep covers it.
+func (r *StatusRefresher) Refresh(serverID string) {
+	select {
+	case r.wake <- serverID:
+	default:
+	}
+}
+
+// CheckNow probes a server in the calling goroutine and stores the result.
+// It is used when no snapshot exists yet.
+func (r *StatusRefresher) CheckNow(serverID string) (statusSnapshot, bool) {
+	health, ok := r.probe(serverID)
+	if !ok {
+		r.Forget(serverID)
+		return statusSnapshot{}, false
+	}
+	return r.store(serverID, health), true
+}
+
+// Forget drops a deleted server's snapshot
+func (r *StatusRefresher) Forget(serverID string) {
+	r.mu.Lock()
+	delete(r.snapshots, serverID)
+	r.mu.Unlock()
+}
+
+func (r *StatusRefresher) refreshAll() {
+	for _, serverID := range r.servers() {
+		r.refreshOne(serverID)
+	}
+}
+
+// refreshOne starts a probe unless one is already running for the server
+func (r *StatusRefresher) refreshOne(serverID string) {
+	if serverID == "" {
+		return
+	}
+	r.mu.Lock()
+	if r.inflight[serverID] {
+		r.mu.Unlock()
+		return
+	}
+	r.inflight[serverID] = true
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.inflight, serverID)
+			r.mu.Unlock()
+		}()
+		r.CheckNow(serverID)
+	}()
+}
+
+func (r *StatusRefresher) store(serverID string, health HealthCheck) statusSnapshot {
+	snapshot := statusSnapshot{Health: health, CheckedAt: time.Now()}
+
+	r.mu.Lock()
+	previous, existed := r.snapshots[serverID]
+	r.snapshots[serverID] = snapshot
+	listeners := append([]StatusChangeFunc{}, r.listeners...)
+	r.mu.Unlock()
+
+	if existed && previous.Health.ConnectionStatus != health.ConnectionStatus {
+		for _, fn := range listeners {
+			fn(serverID, previous.Health.ConnectionStatus, health.ConnectionStatus)
+		}
+	}
+	return snapshot
+}
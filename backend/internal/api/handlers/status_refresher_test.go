@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+)
+
+func TestStatusRefresherCachesAndReportsChanges(t *testing.T) {
+	var mu sync.Mutex
+	current := models.StatusOnline
+	probe := func(serverID string) (HealthCheck, bool) {
+		if serverID != "alpha" {
+			return HealthCheck{}, false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return HealthCheck{ConnectionStatus: current}, true
+	}
+	refresher := NewStatusRefresher(func() []string { return []string{"alpha"} }, probe, time.Hour)
+
+	changes := make(chan models.ServerConnectionStatus, 1)
+	refresher.OnChange(func(serverID string, previous, next models.ServerConnectionStatus) {
+		changes <- next
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go refresher.Run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if snapshot, ok := refresher.Get("alpha"); ok {
+			if snapshot.Health.ConnectionStatus != models.StatusOnline {
+				t.Fatalf("expected online, got %s", snapshot.Health.ConnectionStatus)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("initial sweep did not record a status")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	current = models.StatusRunning
+	mu.Unlock()
+	refresher.Refresh("alpha")
+
+	select {
+	case status := <-changes:
+		if status != models.StatusRunning {
+			t.Fatalf("expected change to running, got %s", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a status change event")
+	}
+
+	if _, ok := refresher.CheckNow("missing"); ok {
+		t.Fatal("expected unknown server to report not found")
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+// DatabaseHealthHandler reports on and maintains the manager database
+type DatabaseHealthHandler struct {
+	monitor *database.HealthMonitor
+}
+
+// NewDatabaseHealthHandler creates a new database health handler
+func NewDatabaseHealthHandler(monitor *database.HealthMonitor) *DatabaseHealthHandler {
+	return &DatabaseHealthHandler{monitor: monitor}
+}
+
+// GetHealth returns the latest maintenance report. Until the first scheduled run
+// completes, sizes are collected on demand without running the integrity check.
+func (h *DatabaseHealthHandler) GetHealth(c *gin.Context) {
+	if report := h.monitor.Latest(); report != nil {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	report, err := h.monitor.Run(c.Request.Context(), false)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to collect database health")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RunMaintenance runs the integrity check and incremental vacuum immediately
+func (h *DatabaseHealthHandler) RunMaintenance(c *gin.Context) {
+	report, err := h.monitor.Run(c.Request.Context(), true)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import "sync"
+
+// defaultMaxConcurrentTasks is used when tasks.max_concurrent is unset
+const defaultMaxConcurrentTasks = 8
+
+// taskExecutor runs background tasks from a queue: at most limit at once
+// across all servers, and one at a time per server, so two deploys or a
+// deploy and an install never race on the same host. Tasks start in the
+// order they were submitted, except that a server's task waits while that
+// server is busy without holding up the servers behind it.
+type taskExecutor struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	busy    map[string]bool
+	queue   []queuedTask
+}
+
+type queuedTask struct {
+	serverID string
+	run      func()
+}
+
+func newTaskExecutor(limit int) *taskExecutor {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentTasks
+	}
+	return &taskExecutor{limit: limit, busy: make(map[string]bool)}
+}
+
+// submit queues run for the server and starts it as soon as a slot is free
+func (e *taskExecutor) submit(serverID string, run func()) {
+	e.mu.Lock()
+	e.queue = append(e.queue, queuedTask{serverID: serverID, run: run})
+	e.dispatch()
+	e.mu.Unlock()
+}
+
+// dispatch starts the queued tasks that may run now. The caller holds mu.
+func (e *taskExecutor) dispatch() {
+	waiting := e.queue[:0]
+	for _, task := range e.queue {
+		if e.running >= e.limit || e.busy[task.serverID] {
+			waiting = append(waiting, task)
+			continue
+		}
+		e.running++
+		e.busy[task.serverID] = true
+		go e.execute(task)
+	}
+	clear(e.queue[len(waiting):])
+	e.queue = waiting
+}
+
+func (e *taskExecutor) execute(task queuedTask) {
+	defer func() {
+		e.mu.Lock()
+		e.running--
+		delete(e.busy, task.serverID)
+		e.dispatch()
+		e.mu.Unlock()
+	}()
+	task.run()
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTaskExecutorLimitsAndSerializesTasks(t *testing.T) {
+	executor := newTaskExecutor(2)
+
+	var (
+		mu       sync.Mutex
+		running  = make(map[string]int)
+		total    int
+		peak     int
+		order    []string
+		finished sync.WaitGroup
+	)
+	release := make(chan struct{})
+	submit := func(serverID, name string) {
+		finished.Add(1)
+		executor.submit(serverID, func() {
+			defer finished.Done()
+			mu.Lock()
+			running[serverID]++
+			total++
+			if running[serverID] > 1 {
+				t.Errorf("two tasks ran at once on %s", serverID)
+			}
+			peak = max(peak, total)
+			order = append(order, name)
+			mu.Unlock()
+
+			<-release
+
+			mu.Lock()
+			running[serverID]--
+			total--
+			mu.Unlock()
+		})
+	}
+
+	submit("alpha", "alpha-1")
+	submit("alpha", "alpha-2")
+	submit("beta", "beta-1")
+	submit("gamma", "gamma-1")
+
+	// alpha-2 waits for alpha-1 without holding up beta; gamma waits for a slot
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 2
+	})
+	mu.Lock()
+	started := slices.Sorted(slices.Values(order))
+	mu.Unlock()
+	if !slices.Equal(started, []string{"alpha-1", "beta-1"}) {
+		t.Fatalf("expected alpha-1 and beta-1 to start first, got %v", started)
+	}
+
+	close(release)
+	finished.Wait()
+	if peak != 2 {
+		t.Fatalf("expected at most 2 tasks at once, got %d", peak)
+	}
+	if len(order) != 4 {
+		t.Fatalf("expected every task to run, got %v", order)
+	}
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGoTaskQueuesBehindServerTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/servers/test-server/deploy", nil)
+
+	release := make(chan struct{})
+	for _, name := range []string{"release-deploy", "dependencies-install"} {
+		handler.goTask(c, "test-server", name, func(ctx context.Context, task *taskRecord) {
+			<-release
+			handler.finishTask("test-server", task.ID, nil)
+		})
+	}
+
+	waitFor(t, func() bool {
+		tasks := handler.listTasks("test-server")
+		return len(tasks) == 2 && tasks[0].Status == taskStatusRunning
+	})
+	tasks := handler.listTasks("test-server")
+	if tasks[1].Status != taskStatusQueued {
+		t.Fatalf("expected the second task queued behind the first, got %+v", tasks[1])
+	}
+	if queued := handler.QueuedTasks(); queued["dependencies-install"] != 1 {
+		t.Fatalf("expected one queued install, got %v", queued)
+	}
+
+	close(release)
+	handler.pendingOps.Wait()
+	for _, record := range handler.listTasks("test-server") {
+		if record.Status != taskStatusComplete {
+			t.Fatalf("expected both tasks to complete, got %+v", record)
+		}
+	}
+}
+
+func TestRunQueuedTaskWaitsForServerTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/servers/test-server/deploy", nil)
+
+	release := make(chan struct{})
+	handler.goTask(c, "test-server", "release-deploy", func(ctx context.Context, task *taskRecord) {
+		<-release
+		handler.finishTask("test-server", task.ID, nil)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.runQueuedTask(context.Background(), "test-server", "batch-deploy", func(ctx context.Context, task *taskRecord) error {
+			return errors.New("deploy failed")
+		})
+	}()
+
+	waitFor(t, func() bool {
+		return handler.QueuedTasks()["batch-deploy"] == 1
+	})
+	select {
+	case err := <-done:
+		t.Fatalf("expected the batch deploy to wait for the running deploy, got %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-done; err == nil || err.Error() != "deploy failed" {
+		t.Fatalf("expected the task's error, got %v", err)
+	}
+	tasks := handler.listTasks("test-server")
+	if len(tasks) != 2 || tasks[1].Status != taskStatusFailed {
+		t.Fatalf("expected the batch deploy recorded as failed, got %+v", tasks)
+	}
+
+	// A task whose context ends while it is queued fails without running
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	if err := handler.runQueuedTask(ctx, "test-server", "batch-deploy", func(ctx context.Context, task *taskRecord) error {
+		ran = true
+		return nil
+	}); !errors.Is(err, context.Canceled) || ran {
+		t.Fatalf("expected a cancelled task not to run, got %v (ran %v)", err, ran)
+	}
+}
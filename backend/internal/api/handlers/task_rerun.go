@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
+)
+
+// errTaskNotFound is returned for a task no store has a record of
+var errTaskNotFound = errors.New("task not found")
+
+// taskOrigin is the request that started a task. Re-running the task replays
+// it, so the new run gets the same parameters and passes the same permission
+// checks as the current user.
+type taskOrigin struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+type taskOriginKey struct{}
+
+type rerunOfKey struct{}
+
+// withTaskOrigin returns ctx carrying the request c is handling, with the body
+// the RetainBody middleware kept. A request whose body sets a password, key or
+// token is not kept, so the secret is not stored with the task, and the task
+// cannot be re-run.
+func withTaskOrigin(ctx context.Context, c *gin.Context) context.Context {
+	origin := &taskOrigin{Method: c.Request.Method, Path: c.Request.URL.RequestURI()}
+	if body, ok := c.Get(gin.BodyBytesKey); ok {
+		origin.Body, _ = body.([]byte)
+	}
+	if middleware.CarriesSecrets(origin.Body) {
+		return ctx
+	}
+	return context.WithValue(ctx, taskOriginKey{}, origin)
+}
+
+func taskOriginFrom(ctx context.Context) *taskOrigin {
+	origin, _ := ctx.Value(taskOriginKey{}).(*taskOrigin)
+	return origin
+}
+
+// rerunOf returns the ID of the task a replayed request re-runs, if any
+func rerunOf(ctx context.Context) string {
+	id, _ := ctx.Value(rerunOfKey{}).(string)
+	return id
+}
+
+// rerunnable reports whether the task failed and can be started again with
+// the request that started it
+func (r *taskRecord) rerunnable() bool {
+	if r.origin == nil {
+		return false
+	}
+	return r.Status == taskStatusFailed || r.Status == taskStatusInterrupted
+}
+
+// SetRouter gives the handler the router failed tasks are re-run through
+func (h *ServerHandler) SetRouter(router *gin.Engine) {
+	h.router = router
+}
+
+// getTask returns one of the server's recent tasks
+func (h *ServerHandler) getTask(serverID, taskID string) (*taskRecord, error) {
+	if h.sharedTasks != nil {
+		return h.sharedTasks.getTask(serverID, taskID)
+	}
+
+	h.tasksMu.Lock()
+	defer h.tasksMu.Unlock()
+	if state, ok := h.tasks[serverID]; ok {
+		if record, ok := state.tasks[taskID]; ok {
+			clone := *record
+			return &clone, nil
+		}
+	}
+	return nil, errTaskNotFound
+}
+
+// RerunServerTask starts a failed or interrupted task again by replaying the
+// request that started it. The replayed request is authenticated and
+// authorized like the original, and its response is returned.
+func (h *ServerHandler) RerunServerTask(c *gin.Context) {
+	serverID := c.Param("id")
+	taskID := c.Param("taskId")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	record, err := h.getTask(serverID, taskID)
+	if errors.Is(err, errTaskNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Task not found")
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to read task", "server_id", serverID, "task_id", taskID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read task")
+		return
+	}
+	if record.Status != taskStatusFailed && record.Status != taskStatusInterrupted {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Only failed or interrupted tasks can be re-run")
+		return
+	}
+	if !record.rerunnable() || h.router == nil {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Task was not started by a request, or its request carried secrets, and cannot be re-run")
+		return
+	}
+
+	ctx := context.WithValue(c.Request.Context(), rerunOfKey{}, record.ID)
+	req, err := http.NewRequestWithContext(ctx, record.origin.Method, record.origin.Path, bytes.NewReader(record.origin.Body))
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to replay task request", "server_id", serverID, "task_id", taskID, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to re-run task")
+		return
+	}
+	req.Header = c.Request.Header.Clone()
+	req.Header.Del("Content-Length")
+	if len(record.origin.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Del("Content-Type")
+	}
+	req.RemoteAddr = c.Request.RemoteAddr
+	c.Request = req
+	h.router.HandleContext(c)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestSharedTaskStoreRestoresInterruptedTasks(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	started := time.Now().Add(-time.Minute)
+	before := &sharedTaskStore{db: db.DB, instance: "before-restart"}
+	before.saveTask("alpha", taskRecord{
+		ID: "task-alpha-1", Task: "release-deploy", Status: taskStatusRunning, StartedAt: started,
+		origin: &taskOrigin{Method: http.MethodPost, Path: "/api/v1/servers/alpha/deploy", Body: []byte(`{"version":"1.2"}`)},
+	})
+	before.saveTask("alpha", taskRecord{ID: "task-alpha-2", Task: "batch-restart", Status: taskStatusRunning, StartedAt: started})
+
+	// A clustered instance leaves the tasks other instances may still run
+	peer := &sharedTaskStore{db: db.DB, instance: "peer", clustered: true}
+	peer.restore(24 * time.Hour)
+	if record, err := peer.getTask("alpha", "task-alpha-1"); err != nil || record.Status != taskStatusRunning {
+		t.Fatalf("expected another instance's task left running, got %+v (%v)", record, err)
+	}
+
+	// A single instance closes every task running when it stopped
+	after := &sharedTaskStore{db: db.DB, instance: "after-restart"}
+	after.restore(24 * time.Hour)
+	tasks, err := after.listTasks("alpha")
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("expected both tasks restored, got %+v (%v)", tasks, err)
+	}
+	for _, record := range tasks {
+		if record.Status != taskStatusInterrupted || record.FinishedAt == nil {
+			t.Fatalf("expected the task marked interrupted, got %+v", record)
+		}
+	}
+	if !tasks[0].rerunnable() || string(tasks[0].origin.Body) != `{"version":"1.2"}` {
+		t.Fatalf("expected the deploy re-runnable with its parameters, got %+v", tasks[0].origin)
+	}
+	if tasks[1].rerunnable() {
+		t.Fatal("expected a task not started by a request not to be re-runnable")
+	}
+	if _, err := after.getTask("beta", "task-alpha-1"); !errors.Is(err, errTaskNotFound) {
+		t.Fatalf("expected another server's task not found, got %v", err)
+	}
+}
+
+func TestRerunServerTaskReplaysRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+
+	bodies := make(chan string, 2)
+	router := gin.New()
+	router.Use(middleware.RetainBody())
+	router.POST("/servers/:id/work", func(c *gin.Context) {
+		var req struct {
+			Version string `json:"version"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		serverID := c.Param("id")
+		handler.goTask(c, serverID, "work", func(ctx context.Context, task *taskRecord) {
+			handler.finishTask(serverID, task.ID, errors.New("host unreachable"))
+			bodies <- req.Version
+		})
+		c.Status(http.StatusAccepted)
+	})
+	router.POST("/servers/:id/tasks/:taskId/rerun", handler.RerunServerTask)
+	handler.SetRouter(router)
+
+	req := httptest.NewRequest(http.MethodPost, "/servers/test-server/work", strings.NewReader(`{"version":"1.2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", w.Code)
+	}
+	<-bodies
+	handler.pendingOps.Wait()
+	tasks := handler.listTasks("test-server")
+	if len(tasks) != 1 || !tasks[0].rerunnable() {
+		t.Fatalf("expected one failed, re-runnable task, got %+v", tasks)
+	}
+	failed := tasks[0]
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/servers/test-server/tasks/"+failed.ID+"/rerun", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected the replayed request's 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if version := <-bodies; version != "1.2" {
+		t.Fatalf("expected the re-run with the same parameters, got %q", version)
+	}
+	handler.pendingOps.Wait()
+	tasks = handler.listTasks("test-server")
+	if len(tasks) != 2 || tasks[1].RerunOf != failed.ID {
+		t.Fatalf("expected a second task re-running the first, got %+v", tasks)
+	}
+
+	// Only failed or interrupted tasks run again
+	handler.tasksMu.Lock()
+	handler.tasks["test-server"].tasks[failed.ID].Status = taskStatusComplete
+	handler.tasksMu.Unlock()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/servers/test-server/tasks/"+failed.ID+"/rerun", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a completed task, got %d", w.Code)
+	}
+
+	// A request carrying a secret is not kept, so its task cannot be re-run
+	req = httptest.NewRequest(http.MethodPost, "/servers/test-server/work", strings.NewReader(`{"version":"1.3","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	<-bodies
+	handler.pendingOps.Wait()
+	tasks = handler.listTasks("test-server")
+	if len(tasks) != 3 || tasks[2].origin != nil || tasks[2].rerunnable() {
+		t.Fatalf("expected the task's request not kept, got %+v", tasks[2])
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStreamBufferLines = 1000
+	maxTasksPerServer        = 50
+)
+
+// errTaskLogNotFound is returned when a task has no log on disk
+var errTaskLogNotFound = errors.New("task log not found")
+
+// taskStreamStore keeps task records and their full output on disk, one
+// directory per server: <task>.json holds the record and <task>.log the
+// output as JSON lines. Memory only keeps the most recent lines; the log
+// files hold everything, so very long install logs are not truncated.
+type taskStreamStore struct {
+	dir   string
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+// newTaskStreamStore returns nil when dir is empty, which disables persistence
+func newTaskStreamStore(dir string) *taskStreamStore {
+	if strings.TrimSpace(dir) == "" {
+		return nil
+	}
+	return &taskStreamStore{dir: dir, files: make(map[string]*os.File)}
+}
+
+func (s *taskStreamStore) serverDir(serverID string) string {
+	return filepath.Join(s.dir, url.PathEscape(serverID))
+}
+
+func (s *taskStreamStore) taskPath(serverID, taskID, ext string) string {
+	return filepath.Join(s.serverDir(serverID), url.PathEscape(taskID)+ext)
+}
+
+// saveTask writes the task record, replacing any earlier version
+func (s *taskStreamStore) saveTask(serverID string, record taskRecord) {
+	if s == nil {
+		return
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(s.serverDir(serverID), 0750); err != nil {
+		logger.Warn("Failed to create task stream directory", "server_id", serverID, "error", err)
+		return
+	}
+	path := s.taskPath(serverID, record.ID, ".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0640); err != nil {
+		logger.Warn("Failed to persist task", "server_id", serverID, "task_id", record.ID, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		logger.Warn("Failed to persist task", "server_id", serverID, "task_id", record.ID, "error", err)
+	}
+}
+
+// appendLine adds one output line to the task's log
+func (s *taskStreamStore) appendLine(serverID string, entry taskStreamLine) {
+	if s == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[entry.TaskID]
+	if !ok {
+		if err := os.MkdirAll(s.serverDir(serverID), 0750); err != nil {
+			return
+		}
+		file, err = os.OpenFile(s.taskPath(serverID, entry.TaskID, ".log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+		if err != nil {
+			logger.Warn("Failed to open task log", "server_id", serverID, "task_id", entry.TaskID, "error", err)
+			return
+		}
+		s.files[entry.TaskID] = file
+	}
+	_, _ = file.Write(append(data, '\n'))
+}
+
+// closeTask releases the task's log file once it has finished
+func (s *taskStreamStore) closeTask(taskID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if file, ok := s.files[taskID]; ok {
+		_ = file.Close()
+		delete(s.files, taskID)
+	}
+}
+
+// readLog returns every line a task wrote
+func (s *taskStreamStore) readLog(serverID, taskID string) ([]taskStreamLine, error) {
+	if s == nil {
+		return nil, errTaskLogNotFound
+	}
+	file, err := os.Open(s.taskPath(serverID, taskID, ".log"))
+	if os.IsNotExist(err) {
+		return nil, errTaskLogNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := make([]taskStreamLine, 0, 256)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry taskStreamLine
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			lines = append(lines, entry)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// load reads the persisted tasks for every server, newest last, and the most
+// recent maxLines of their output. Tasks still marked running or queued were
+// cut off by a restart and are recorded as interrupted. Files older than retention are
+// removed.
+func (s *taskStreamStore) load(maxLines int, retention time.Duration) (map[string][]taskRecord, map[string][]taskStreamLine) {
+	records := make(map[string][]taskRecord)
+	lines := make(map[string][]taskStreamLine)
+	if s == nil {
+		return records, lines
+	}
+
+	serverDirs, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read task stream directory", "dir", s.dir, "error", err)
+		}
+		return records, lines
+	}
+
+	now := time.Now()
+	for _, serverDir := range serverDirs {
+		if !serverDir.IsDir() {
+			continue
+		}
+		serverID, err := url.PathUnescape(serverDir.Name())
+		if err != nil {
+			continue
+		}
+
+		tasks := s.loadServerTasks(serverID, now, retention)
+		if len(tasks) > maxTasksPerServer {
+			tasks = tasks[len(tasks)-maxTasksPerServer:]
+		}
+
+		var tail []taskStreamLine
+		for i := len(tasks) - 1; i >= 0 && len(tail) < maxLines; i-- {
+			taskLines, err := s.readLog(serverID, tasks[i].ID)
+			if err != nil {
+				continue
+			}
+			if need := maxLines - len(tail); len(taskLines) > need {
+				taskLines = taskLines[len(taskLines)-need:]
+			}
+			tail = append(taskLines, tail...)
+		}
+
+		if len(tasks) > 0 {
+			records[serverID] = tasks
+		}
+		if len(tail) > 0 {
+			lines[serverID] = tail
+		}
+	}
+	return records, lines
+}
+
+func (s *taskStreamStore) loadServerTasks(serverID string, now time.Time, retention time.Duration) []taskRecord {
+	entries, err := os.ReadDir(s.serverDir(serverID))
+	if err != nil {
+		return nil
+	}
+
+	tasks := make([]taskRecord, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(s.serverDir(serverID), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var record taskRecord
+		if err := json.Unmarshal(data, &record); err != nil || record.ID == "" {
+			continue
+		}
+
+		finished := record.StartedAt
+		if record.FinishedAt != nil {
+			finished = *record.FinishedAt
+		}
+		if retention > 0 && now.Sub(finished) > retention {
+			_ = os.Remove(path)
+			_ = os.Remove(s.taskPath(serverID, record.ID, ".log"))
+			continue
+		}
+
+		if record.Status == taskStatusRunning || record.Status == taskStatusQueued {
+			interruptedAt := now
+			record.Status = taskStatusInterrupted
+			record.Error = "interrupted by manager restart"
+			record.FinishedAt = &interruptedAt
+			s.saveTask(serverID, record)
+		}
+		tasks = append(tasks, record)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].StartedAt.Before(tasks[j].StartedAt) })
+	return tasks
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
+)
+
+func TestTaskStreamStoreRestoresAfterRestart(t *testing.T) {
+	store := newTaskStreamStore(t.TempDir())
+	started := time.Now().Add(-time.Minute)
+
+	done := taskRecord{ID: "task-alpha-1", Task: "agent-install", Status: taskStatusComplete, StartedAt: started, FinishedAt: &started}
+	running := taskRecord{ID: "task-alpha-2", Task: "release-deploy", Status: taskStatusRunning, StartedAt: started.Add(time.Second)}
+	store.saveTask("alpha", done)
+	store.saveTask("alpha", running)
+	for i := 0; i < 5; i++ {
+		store.appendLine("alpha", taskStreamLine{Line: fmt.Sprintf("install %d", i), Task: done.Task, TaskID: done.ID, Timestamp: started})
+		store.appendLine("alpha", taskStreamLine{Line: fmt.Sprintf("deploy %d", i), Task: running.Task, TaskID: running.ID, Timestamp: started})
+	}
+	store.closeTask(done.ID)
+	store.closeTask(running.ID)
+
+	restarted := newTaskStreamStore(store.dir)
+	records, lines := restarted.load(7, 24*time.Hour)
+
+	tasks := records["alpha"]
+	if len(tasks) != 2 || tasks[0].ID != done.ID || tasks[1].ID != running.ID {
+		t.Fatalf("expected both tasks in start order, got %+v", tasks)
+	}
+	if tasks[1].Status != taskStatusInterrupted || tasks[1].FinishedAt == nil {
+		t.Fatalf("expected the cut off task to be marked interrupted, got %+v", tasks[1])
+	}
+
+	tail := lines["alpha"]
+	if len(tail) != 7 || tail[0].Line != "install 3" || tail[6].Line != "deploy 4" {
+		t.Fatalf("expected the last 7 lines across tasks, got %+v", tail)
+	}
+
+	full, err := restarted.readLog("alpha", done.ID)
+	if err != nil || len(full) != 5 {
+		t.Fatalf("expected the full log of 5 lines, got %d (%v)", len(full), err)
+	}
+
+	if records, _ := restarted.load(7, time.Nanosecond); len(records["alpha"]) != 0 {
+		t.Fatalf("expected expired tasks to be pruned, got %+v", records["alpha"])
+	}
+}
+
+func TestSharedTaskStoreSeenByOtherInstances(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	first := &sharedTaskStore{db: db.DB, instance: "a"}
+	second := &sharedTaskStore{db: db.DB, instance: "b"}
+	started := time.Now().Add(-time.Minute)
+
+	running := taskRecord{ID: "task-alpha-1", Task: "release-deploy", Status: taskStatusRunning, StartedAt: started}
+	first.saveTask("alpha", running)
+	for i := 0; i < 3; i++ {
+		first.appendLine("alpha", taskStreamLine{Line: fmt.Sprintf("deploy %d", i), Task: running.Task, TaskID: running.ID, Timestamp: started})
+	}
+
+	tasks, err := second.listTasks("alpha")
+	if err != nil || len(tasks) != 1 || tasks[0].Status != taskStatusRunning || tasks[0].LastLine != "deploy 2" {
+		t.Fatalf("expected the other instance's running task, got %+v (%v)", tasks, err)
+	}
+	tail, err := second.recentLines("alpha", 0, 2)
+	if err != nil || len(tail) != 2 || tail[0].Line != "deploy 1" || tail[1].Line != "deploy 2" {
+		t.Fatalf("expected the last 2 lines, got %+v (%v)", tail, err)
+	}
+	if _, err := second.readLog("alpha", "task-alpha-9"); !errors.Is(err, errTaskLogNotFound) {
+		t.Fatalf("expected an unknown task to have no log, got %v", err)
+	}
+
+	second.failOrphaned([]string{"b"})
+	tasks, _ = second.listTasks("alpha")
+	if len(tasks) != 1 || tasks[0].Status != taskStatusInterrupted || tasks[0].FinishedAt == nil {
+		t.Fatalf("expected the stopped instance's task to be marked interrupted, got %+v", tasks)
+	}
+}
+
+func TestTaskEventsResumeAfterLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handler.hub.Run(ctx)
+
+	for i := 1; i <= 5; i++ {
+		handler.appendTaskStreamLine("test-server", "task-1", "release-deploy", fmt.Sprintf("deploy %d", i))
+	}
+
+	router := gin.New()
+	router.GET("/servers/:id/tasks/events", func(c *gin.Context) {
+		c.Set("user", &auth.Claims{UserID: 1, Username: "admin"})
+		handler.StreamServerTasks(c)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/servers/test-server/tasks/events", nil)
+	req.Header.Set("Last-Event-ID", "3")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", resp.Header.Get("Content-Type"))
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	// The lines after the last event ID, then a live line, each once
+	var ids []string
+	var live bool
+	for len(ids) < 3 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream ended after ids %v", ids)
+			}
+			if id, found := strings.CutPrefix(line, "id: "); found {
+				ids = append(ids, id)
+				if len(ids) == 2 && !live {
+					live = true
+					handler.appendTaskStreamLine("test-server", "task-1", "release-deploy", "deploy 6")
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after ids %v", ids)
+		}
+	}
+	if strings.Join(ids, ",") != "4,5,6" {
+		t.Fatalf("expected lines 4 to 6 once each, got %v", ids)
+	}
+	deadline := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case line := <-lines:
+			if strings.HasPrefix(line, "id: ") {
+				t.Fatalf("expected no more lines, got %s", line)
+			}
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func TestTaskOutputCarriesRequestID(t *testing.T) {
+	handler, _, _, _ := setupTestServerHandler(t)
+	defer handler.activityLogger.Close()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	handler.sharedTasks = &sharedTaskStore{db: db.DB, instance: "a"}
+
+	task := handler.startTask(tracing.WithRequestID(context.Background(), "req-deploy-1"), "test-server", "release-deploy")
+	handler.appendTaskStreamLine("test-server", task.ID, task.Task, "unpacking")
+	handler.finishTask("test-server", task.ID, errors.New("checksum mismatch"))
+
+	lines := handler.recentTaskLines("test-server", 0)
+	if len(lines) != 1 || lines[0].RequestID != "req-deploy-1" {
+		t.Fatalf("expected the line tagged with the request ID, got %+v", lines)
+	}
+	if message := taskOutputMessage("test-server", lines[0], false); message.Payload.(map[string]interface{})["request_id"] != "req-deploy-1" {
+		t.Fatalf("expected the request ID in the task_output message, got %+v", message.Payload)
+	}
+	logged, err := handler.sharedTasks.readLog("test-server", task.ID)
+	if err != nil || len(logged) != 1 || logged[0].RequestID != "req-deploy-1" {
+		t.Fatalf("expected the task log tagged with the request ID, got %+v (%v)", logged, err)
+	}
+}
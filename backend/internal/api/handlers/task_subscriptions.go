@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
+)
+
+// taskEventKeepalive is how often an idle task event stream sends a comment,
+// so proxies do not close it
+const taskEventKeepalive = 25 * time.Second
+
+// taskBackfill holds back the live messages of a new tasks subscriber while
+// it is sent the output it missed, then only lets through lines numbered
+// after that output, so every line reaches the subscriber once and in order
+type taskBackfill struct {
+	mu    sync.Mutex
+	done  bool
+	floor int64
+	held  []*ws.Message
+}
+
+// allow is the subscriber's hub filter
+func (b *taskBackfill) allow(msg *ws.Message) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.done {
+		b.held = append(b.held, msg)
+		return false
+	}
+	return !b.sent(msg)
+}
+
+// sent reports whether msg is a line the backfill already delivered
+func (b *taskBackfill) sent(msg *ws.Message) bool {
+	seq, ok := taskMessageSeq(msg)
+	return ok && seq <= b.floor
+}
+
+// finish delivers the missed lines, then the messages held back meanwhile
+func (b *taskBackfill) finish(client *ws.Client, serverID string, lines []taskStreamLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, entry := range lines {
+		if err := client.Deliver(taskOutputMessage(serverID, entry, true)); err != nil {
+			logger.Warn("Failed to backfill task output", "client_id", client.ID, "error", err)
+		}
+		if entry.Seq > b.floor {
+			b.floor = entry.Seq
+		}
+	}
+	for _, msg := range b.held {
+		if !b.sent(msg) {
+			_ = client.Deliver(msg)
+		}
+	}
+	b.held = nil
+	b.done = true
+}
+
+// taskMessageSeq returns the number of a task_output message. Messages relayed
+// from other instances were decoded from JSON, so their numbers are floats.
+func taskMessageSeq(msg *ws.Message) (int64, bool) {
+	if msg.Type != "task_output" {
+		return 0, false
+	}
+	payload, ok := msg.Payload.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	switch seq := payload["seq"].(type) {
+	case int64:
+		return seq, true
+	case float64:
+		return int64(seq), true
+	}
+	return 0, false
+}
+
+func taskOutputMessage(serverID string, entry taskStreamLine, historical bool) *ws.Message {
+	payload := map[string]interface{}{
+		"seq":       entry.Seq,
+		"line":      entry.Line,
+		"server_id": serverID,
+		"task_id":   entry.TaskID,
+		"task":      entry.Task,
+		"timestamp": entry.Timestamp,
+	}
+	if entry.RequestID != "" {
+		payload["request_id"] = entry.RequestID
+	}
+	if historical {
+		payload["historical"] = true
+	}
+	return &ws.Message{Type: "task_output", Payload: payload, Timestamp: entry.Timestamp}
+}
+
+// taskSubscriberQueue sizes a subscriber's send queue to hold a full backfill
+func (h *ServerHandler) taskSubscriberQueue() int {
+	return h.streamBufferLines() + 256
+}
+
+// subscribeTasks registers client for a server's task messages and queues the
+// output numbered after since; since 0 replays the buffered output. Output
+// further back than the buffer is only in the task logs.
+func (h *ServerHandler) subscribeTasks(serverID string, client *ws.Client, since int64) {
+	backfill := &taskBackfill{floor: since}
+	client.Filter = backfill.allow
+	h.hub.Register <- client
+	// Read once registered, so a line is either in the backfill or held back
+	backfill.finish(client, serverID, h.recentTaskLines(serverID, since))
+
+	go func() {
+		for _, record := range h.listTasks(serverID) {
+			h.broadcastTaskStatus(serverID, record, true)
+		}
+	}()
+}
+
+// taskStreamSince reads where a subscriber resumes: ?since= or, for event
+// streams, the Last-Event-ID header the browser sends when it reconnects
+func taskStreamSince(c *gin.Context) (int64, bool) {
+	value := c.Query("since")
+	if value == "" {
+		value = c.GetHeader("Last-Event-ID")
+	}
+	if value == "" {
+		return 0, true
+	}
+	since, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || since < 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "since must be the number of a task output line")
+		return 0, false
+	}
+	return since, true
+}
+
+// StreamServerTasks streams a server's task output and status as server-sent
+// events. Output events carry their line number as the event ID, so a
+// reconnecting EventSource resumes after the last line it received.
+func (h *ServerHandler) StreamServerTasks(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	userClaims, exists := c.Get("user")
+	if !exists {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	claims := userClaims.(*auth.Claims)
+	since, ok := taskStreamSince(c)
+	if !ok {
+		return
+	}
+
+	client := &ws.Client{
+		ID:       fmt.Sprintf("tasks-events-%s-%d", serverID, time.Now().UnixNano()),
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Room:     fmt.Sprintf("server-tasks:%s", serverID),
+		Send:     make(chan *ws.Message, h.taskSubscriberQueue()),
+		Hub:      h.hub,
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	h.subscribeTasks(serverID, client, since)
+	keepalive := time.NewTicker(taskEventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			h.hub.Unregister <- client
+			return
+		case msg, open := <-client.Send:
+			if !open {
+				return
+			}
+			if err := writeTaskEvent(c.Writer, msg); err != nil {
+				h.hub.Unregister <- client
+				return
+			}
+			c.Writer.Flush()
+		case <-keepalive.C:
+			if _, err := io.WriteString(c.Writer, ": keepalive\n\n"); err != nil {
+				h.hub.Unregister <- client
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeTaskEvent(w io.Writer, msg *ws.Message) error {
+	data, err := json.Marshal(msg.Payload)
+	if err != nil {
+		// Skip the event rather than end the stream
+		return nil
+	}
+	event := ""
+	if seq, ok := taskMessageSeq(msg); ok {
+		event = fmt.Sprintf("id: %d\n", seq)
+	}
+	event += fmt.Sprintf("event: %s\ndata: %s\n\n", msg.Type, data)
+	_, err = io.WriteString(w, event)
+	return err
+}
@@ -2,11 +2,11 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
 	"github.com/TheGojiOG/HytaleSM/internal/models"
 )
@@ -15,28 +15,51 @@ import (
 type UserHandler struct {
 	db          *sql.DB
 	rbacManager *auth.RBACManager
-	bcryptCost  int
+	passwords   auth.PasswordHasher
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(db *sql.DB, rbacManager *auth.RBACManager, bcryptCost int) *UserHandler {
+func NewUserHandler(db *sql.DB, rbacManager *auth.RBACManager, passwords auth.PasswordHasher) *UserHandler {
 	return &UserHandler{
 		db:          db,
 		rbacManager: rbacManager,
-		bcryptCost:  bcryptCost,
+		passwords:   passwords,
 	}
 }
 
 // ListUsers returns all users with roles
 func (h *UserHandler) ListUsers(c *gin.Context) {
-	rows, err := h.db.Query(`
+	page, ok := parsePage(c, userPages)
+	if !ok {
+		return
+	}
+
+	var total int
+	if err := h.db.QueryRowContext(c.Request.Context(), "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		logger.ErrorContext(c.Request.Context(), "count users failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list users")
+		return
+	}
+
+	query := `
 		SELECT id, organization_id, username, email, is_active, created_at, updated_at
 		FROM users
-		ORDER BY username
-	`)
+	`
+	args := []interface{}{}
+	if page.After != "" {
+		query += " WHERE username > ?"
+		args = append(args, page.After)
+	}
+	query += " ORDER BY username"
+	if limit := page.QueryLimit(); limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := h.db.QueryContext(c.Request.Context(), query, args...)
 	if err != nil {
-		log.Printf("[Users] list users query failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		logger.ErrorContext(c.Request.Context(), "list users query failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list users")
 		return
 	}
 	defer rows.Close()
@@ -51,7 +74,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		Roles []roleSummary `json:"roles"`
 	}
 
-	var users []userWithRoles
+	users := []userWithRoles{}
 	userIDs := make([]int64, 0)
 	for rows.Next() {
 		var user models.User
@@ -64,8 +87,8 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		); err != nil {
-			log.Printf("[Users] scan user failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan user"})
+			logger.ErrorContext(c.Request.Context(), "scan user failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan user")
 			return
 		}
 		users = append(users, userWithRoles{User: user})
@@ -73,11 +96,19 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("[Users] list users rows error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		logger.ErrorContext(c.Request.Context(), "list users rows error", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list users")
 		return
 	}
 
+	count, hasMore := page.Trim(len(users))
+	users = users[:count]
+	userIDs = userIDs[:count]
+	lastKey := ""
+	if count > 0 {
+		lastKey = users[count-1].Username
+	}
+
 	if len(userIDs) > 0 {
 		placeholders := make([]string, len(userIDs))
 		args := make([]interface{}, 0, len(userIDs))
@@ -87,10 +118,10 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		}
 
 		query := "SELECT ur.user_id, r.id, r.name FROM user_roles ur JOIN roles r ON ur.role_id = r.id WHERE ur.user_id IN (" + strings.Join(placeholders, ",") + ") ORDER BY r.name"
-		roleRows, err := h.db.Query(query, args...)
+		roleRows, err := h.db.QueryContext(c.Request.Context(), query, args...)
 		if err != nil {
-			log.Printf("[Users] load user roles query failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
+			logger.ErrorContext(c.Request.Context(), "load user roles query failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load user roles")
 			return
 		}
 		defer roleRows.Close()
@@ -101,16 +132,16 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 			var roleID int64
 			var roleName string
 			if err := roleRows.Scan(&userID, &roleID, &roleName); err != nil {
-				log.Printf("[Users] scan user role failed: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan user roles"})
+				logger.ErrorContext(c.Request.Context(), "scan user role failed", "error", err)
+				apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan user roles")
 				return
 			}
 			roleMap[userID] = append(roleMap[userID], roleSummary{ID: roleID, Name: roleName})
 		}
 
 		if err := roleRows.Err(); err != nil {
-			log.Printf("[Users] load user roles rows error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
+			logger.ErrorContext(c.Request.Context(), "load user roles rows error", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load user roles")
 			return
 		}
 
@@ -119,7 +150,7 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, users)
+	respondPage(c, users, page.Info(total, hasMore, lastKey), users)
 }
 
 // GetUser returns a specific user with roles
@@ -127,7 +158,7 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id")
 
 	var user models.User
-	err := h.db.QueryRow(`
+	err := h.db.QueryRowContext(c.Request.Context(), `
 		SELECT id, organization_id, username, email, is_active, created_at, updated_at
 		FROM users WHERE id = ?
 	`, id).Scan(
@@ -141,16 +172,16 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	)
 
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "User not found")
 		return
 	}
 	if err != nil {
-		log.Printf("[Users] get user failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+		logger.ErrorContext(c.Request.Context(), "get user failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to get user")
 		return
 	}
 
-	rows, err := h.db.Query(`
+	rows, err := h.db.QueryContext(c.Request.Context(), `
 		SELECT r.id, r.name
 		FROM user_roles ur
 		JOIN roles r ON ur.role_id = r.id
@@ -158,8 +189,8 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		ORDER BY r.name
 	`, id)
 	if err != nil {
-		log.Printf("[Users] load user roles failed: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
+		logger.ErrorContext(c.Request.Context(), "load user roles failed", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load user roles")
 		return
 	}
 	defer rows.Close()
@@ -169,16 +200,16 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 		var roleID int64
 		var roleName string
 		if err := rows.Scan(&roleID, &roleName); err != nil {
-			log.Printf("[Users] scan user roles failed: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan user roles"})
+			logger.ErrorContext(c.Request.Context(), "scan user roles failed", "error", err)
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to scan user roles")
 			return
 		}
 		roles = append(roles, gin.H{"id": roleID, "name": roleName})
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Printf("[Users] user roles rows error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user roles"})
+		logger.ErrorContext(c.Request.Context(), "user roles rows error", "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to load user roles")
 		return
 	}
 
@@ -198,33 +229,33 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Hash password
-	passwordHash, err := auth.HashPassword(req.Password, h.bcryptCost)
+	passwordHash, err := h.passwords.Hash(req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to hash password")
 		return
 	}
 
 	// Insert user
-	result, err := h.db.Exec(`
+	result, err := h.db.ExecContext(c.Request.Context(), `
 		INSERT INTO users (username, email, password_hash)
 		VALUES (?, ?, ?)
 	`, req.Username, req.Email, passwordHash)
 
 	if err != nil {
 		// Check for unique constraint violation
-		c.JSON(http.StatusConflict, gin.H{"error": "Username or email already exists"})
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Username or email already exists")
 		return
 	}
 
 	userID, _ := result.LastInsertId()
-	_, _ = h.db.Exec(`
+	_, _ = h.db.ExecContext(c.Request.Context(), `
 		INSERT INTO user_roles (user_id, role_id)
-		SELECT ?, id FROM roles WHERE name = 'Viewer'
+		SELECT u.id, r.id FROM users u JOIN roles r ON r.name = 'Viewer' WHERE u.id = ?
 	`, userID)
 
 	c.JSON(http.StatusCreated, gin.H{
@@ -239,7 +270,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
@@ -253,9 +284,9 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	if req.Password != nil {
-		passwordHash, err := auth.HashPassword(*req.Password, h.bcryptCost)
+		passwordHash, err := h.passwords.Hash(*req.Password)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to hash password")
 			return
 		}
 		query += ", password_hash = ?"
@@ -270,15 +301,15 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	query += " WHERE id = ?"
 	args = append(args, id)
 
-	result, err := h.db.Exec(query, args...)
+	result, err := h.db.ExecContext(c.Request.Context(), query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to update user")
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "User not found")
 		return
 	}
 
@@ -289,15 +320,15 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
-	result, err := h.db.Exec("DELETE FROM users WHERE id = ?", id)
+	result, err := h.db.ExecContext(c.Request.Context(), "DELETE FROM users WHERE id = ?", id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete user")
 		return
 	}
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "User not found")
 		return
 	}
 
@@ -312,34 +343,34 @@ func (h *UserHandler) AssignRoles(c *gin.Context) {
 		RoleIDs []int64 `json:"role_ids" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Begin transaction
 	tx, err := h.db.Begin()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin transaction"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to begin transaction")
 		return
 	}
 	defer tx.Rollback()
 
 	// Remove existing roles
 	if _, err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove existing roles"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to remove existing roles")
 		return
 	}
 
 	// Assign new roles
 	for _, roleID := range req.RoleIDs {
 		if _, err := tx.Exec("INSERT INTO user_roles (user_id, role_id) VALUES (?, ?)", id, roleID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to assign role")
 			return
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to commit transaction")
 		return
 	}
 
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/models"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/TheGojiOG/HytaleSM/internal/watchdog"
+	ws "github.com/TheGojiOG/HytaleSM/internal/websocket"
+)
+
+// SetWatchdog hands servers that stop running without being stopped through
+// the manager to w, and reports their crashes
+func (h *ServerHandler) SetWatchdog(w *watchdog.Watchdog) {
+	h.watchdog = w
+	w.OnCrash(h.reportCrash)
+	h.statusRefresher.OnChange(func(serverID string, previous, current models.ServerConnectionStatus) {
+		// Every instance sees the exit; only the leader restarts the server
+		if !h.leading() {
+			return
+		}
+		// A lost SSH connection says nothing about the process
+		if previous == models.StatusRunning && current == models.StatusOnline {
+			w.ServerExited(serverID)
+		}
+	})
+}
+
+// resetWatchdog forgets a server's crashes after it was started or stopped by hand
+func (h *ServerHandler) resetWatchdog(serverID string) {
+	if h.watchdog != nil {
+		h.watchdog.Reset(serverID)
+	}
+	if h.cluster != nil {
+		h.cluster.Publish(watchdogResetTopic, serverID)
+	}
+}
+
+// GetWatchdogState returns the watchdog's recent restarts of a server and
+// whether it gave up on it
+func (h *ServerHandler) GetWatchdogState(c *gin.Context) {
+	serverID := c.Param("id")
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+
+	state := watchdog.State{ServerID: serverID, Restarts: []time.Time{}}
+	if h.watchdog != nil {
+		state = h.watchdog.State(serverID)
+	}
+	c.JSON(http.StatusOK, gin.H{"watchdog": state, "enabled": h.watchdogEnabled(&serverDef)})
+}
+
+// ResetWatchdog clears a server's crash count so the watchdog restarts it
+// again after it gave up
+func (h *ServerHandler) ResetWatchdog(c *gin.Context) {
+	serverID := c.Param("id")
+	if _, found := h.serverManager.GetByID(serverID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Server not found")
+		return
+	}
+	h.resetWatchdog(serverID)
+	c.JSON(http.StatusOK, gin.H{"message": "Watchdog reset", "server_id": serverID})
+}
+
+func (h *ServerHandler) watchdogEnabled(serverDef *config.ServerDefinition) bool {
+	if serverDef.Watchdog.Enabled != nil {
+		return *serverDef.Watchdog.Enabled
+	}
+	return h.config.Watchdog.Enabled
+}
+
+// Watched reports whether the watchdog should restart a server that exited:
+// it is enabled for the server, the server was started and not stopped
+// through the manager, and no maintenance window covers it
+func (h *ServerHandler) Watched(serverID string) bool {
+	serverDef, found := h.serverManager.GetByID(serverID)
+	if !found || !h.watchdogEnabled(&serverDef) {
+		return false
+	}
+	if _, active := h.inMaintenance(serverID); active {
+		return false
+	}
+	return h.lifecycleManager.WantsRunning(serverID)
+}
+
+// Restart starts a crashed server again, clearing a session the dead process
+// left behind
+func (h *ServerHandler) Restart(ctx context.Context, serverID string) error {
+	serverDef, _, err := h.connectServer(serverID)
+	if err != nil {
+		return err
+	}
+	serverConfig := h.createServerConfig(serverDef)
+
+	h.pendingOps.Add(1)
+	defer h.pendingOps.Done()
+	defer h.invalidateServer(serverID)
+
+	h.processManager.SetRunAsUser(serverID, serverConfig.RunAsUser, serverConfig.UseSudo)
+	status, err := h.statusDetector.DetectStatus(serverID, serverConfig.SessionName)
+	if err == nil && status.Status == server.StatusOnline {
+		logger.InfoContext(ctx, "Crashed server is running again", "server_id", serverID)
+		return nil
+	}
+	if err == nil && status.Status == server.StatusError {
+		if err := h.processManager.Stop(serverID, serverConfig.SessionName); err != nil {
+			logger.WarnContext(ctx, "Failed to clear session of crashed server", "server_id", serverID, "error", err)
+		}
+	}
+
+	if err := h.lifecycleManager.StartServer(serverID, serverConfig); err != nil {
+		h.activityLogger.LogServerStart(serverID, nil, false, err.Error())
+		return err
+	}
+	h.activityLogger.LogServerStart(serverID, nil, true, "")
+	return nil
+}
+
+// ConsoleTail returns the last lines of a server's console log
+func (h *ServerHandler) ConsoleTail(ctx context.Context, serverID string, lines int) ([]string, error) {
+	serverDef, conn, err := h.connectServer(serverID)
+	if err != nil {
+		return nil, err
+	}
+	serverConfig := h.createServerConfig(serverDef)
+
+	script := fmt.Sprintf("LOG_FILE=\"%s\"\nLOG_FILE=\"${LOG_FILE/#\\~/$HOME}\"\ntail -n %d \"$LOG_FILE\"",
+		escapeForScriptPath(serverConfig.LogFile), lines)
+	output, err := conn.Client.RunCommandContext(ctx, bashDollarQuotedCommand(script))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read console log: %w", err)
+	}
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// reportCrash records a crash in the activity log and tells clients watching the server
+func (h *ServerHandler) reportCrash(crash watchdog.Crash) {
+	metadata := map[string]interface{}{
+		"restarts": crash.Restarts,
+		"gave_up":  crash.GaveUp,
+	}
+	if crash.NextRestart != nil {
+		metadata["next_restart"] = crash.NextRestart.UTC().Format(time.RFC3339)
+	}
+	if len(crash.ConsoleLines) > 0 {
+		metadata["console_lines"] = crash.ConsoleLines
+	}
+	h.activityLogger.LogServerCrash(crash.ServerID, metadata, crash.RestartError)
+
+	h.hub.Publish(fmt.Sprintf("server-tasks:%s", crash.ServerID), &ws.Message{
+		Type:      "server_crash",
+		Payload:   crash,
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
+	"github.com/TheGojiOG/HytaleSM/internal/files"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/permissions"
+	"github.com/TheGojiOG/HytaleSM/internal/server"
+	"github.com/TheGojiOG/HytaleSM/internal/worlds"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWorldUploadSize bounds an uploaded world archive
+const maxWorldUploadSize = 8 << 30
+
+// worldFlushDelay is how long a running server gets to write its world to
+// disk before a snapshot is taken
+const worldFlushDelay = 5 * time.Second
+
+// World operations, as recorded in the activity log
+const (
+	worldOpUpload    = "upload"
+	worldOpDuplicate = "duplicate"
+	worldOpDelete    = "delete"
+	worldOpSnapshot  = "snapshot"
+	worldOpTransfer  = "transfer"
+)
+
+// worldProgressInterval is how often a transfer reports its progress
+const worldProgressInterval = 5 * time.Second
+
+type worldDuplicateRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type worldTransferRequest struct {
+	TargetServerID string `json:"target_server_id" binding:"required"`
+	// Name is the world's name on the target; it defaults to the same name
+	Name      string `json:"name"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+type worldSnapshotRequest struct {
+	// World defaults to the world players join
+	World string `json:"world"`
+}
+
+// ListWorlds returns the server's world saves with their sizes
+func (h *ServerHandler) ListWorlds(c *gin.Context) {
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	active, ok := h.activeWorld(c, fsys)
+	if !ok {
+		return
+	}
+
+	entries, err := fsys.List(worlds.Dir)
+	if err != nil && !errors.Is(err, files.ErrNotFound) {
+		h.respondFileError(c, "list", worlds.Dir, err)
+		return
+	}
+	list := make([]worlds.World, 0, len(entries))
+	for _, entry := range entries {
+		// Uploads and copies in progress have names no world can take
+		if !entry.Dir || worlds.ValidateName(entry.Name) != nil {
+			continue
+		}
+		size, err := fsys.Size(entry.Path)
+		if err != nil {
+			h.respondFileError(c, "measure", entry.Path, err)
+			return
+		}
+		list = append(list, worlds.World{
+			Name:    entry.Name,
+			Path:    entry.Path,
+			Size:    size,
+			ModTime: entry.ModTime,
+			Active:  entry.Name == active,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"worlds": list, "active": active})
+}
+
+// DownloadWorld sends a world as a gzipped tar, its files under the world's name
+func (h *ServerHandler) DownloadWorld(c *gin.Context) {
+	world := c.Param("world")
+	if err := worlds.ValidateName(world); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := worlds.Path(world)
+	if !h.statWorld(c, fsys, rel) {
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": world + ".tar.gz"}))
+	c.Header("Content-Type", "application/gzip")
+	c.Status(http.StatusOK)
+	if err := fsys.Archive(rel, c.Writer); err != nil {
+		// The archive is cut off; the client sees a broken download
+		logger.ErrorContext(c.Request.Context(), "World download failed", "server_id", c.Param("id"), "world", world, "error", err)
+	}
+}
+
+// UploadWorld unpacks a gzipped tar into a world named by the name query
+// parameter. The archive may hold the world's files or a single directory
+// with them, as DownloadWorld sends. An existing world is only replaced with
+// overwrite=true, and never the one a running server plays.
+func (h *ServerHandler) UploadWorld(c *gin.Context) {
+	serverID := c.Param("id")
+	world := c.Query("name")
+	if err := worlds.ValidateName(world); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	overwrite := c.Query("overwrite") == "true"
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWorldUploadSize)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "expected a multipart/form-data upload")
+		return
+	}
+
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := worlds.Path(world)
+	_, statErr := fsys.Stat(rel)
+	exists := statErr == nil
+	if exists && !overwrite {
+		h.respondFileError(c, worldOpUpload, rel, files.ErrExists)
+		return
+	}
+	if exists && !h.worldChangeAllowed(c, c.Param("id"), fsys, world) {
+		return
+	}
+	if err := ensureDir(fsys, worlds.Dir); err != nil {
+		h.respondFileError(c, worldOpUpload, worlds.Dir, err)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest,
+				fmt.Sprintf("expected a %q field with the archive", uploadField))
+			return
+		}
+		if part.FormName() != uploadField {
+			part.Close()
+			continue
+		}
+
+		counter := &countingReader{r: part}
+		err = unpackWorld(fsys, world, counter, exists)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = fmt.Errorf("upload is larger than %d bytes", maxWorldUploadSize)
+		}
+		h.logWorldChange(serverID, getUserIDFromContext(c), worldOpUpload, fmt.Sprintf("Uploaded world %s", world),
+			map[string]interface{}{"world": world, "size": counter.n, "overwrite": exists}, err)
+		if err != nil {
+			h.respondFileError(c, worldOpUpload, rel, err)
+			return
+		}
+		entry, err := fsys.Stat(rel)
+		if err != nil {
+			h.respondFileError(c, worldOpUpload, rel, err)
+			return
+		}
+		size, _ := fsys.Size(rel)
+		c.JSON(http.StatusCreated, worlds.World{Name: world, Path: rel, Size: size, ModTime: entry.ModTime})
+		return
+	}
+}
+
+// unpackWorld extracts an archive next to the worlds and moves it into
+// place, replacing the world when replace is set
+func unpackWorld(fsys *files.FS, world string, archive io.Reader, replace bool) error {
+	stamp := strconv.FormatInt(time.Now().UnixNano(), 36)
+	staging := path.Join(worlds.Dir, "."+world+".upload-"+stamp)
+	if err := fsys.Extract(staging, archive); err != nil {
+		return err
+	}
+	defer fsys.Remove(staging, true)
+
+	// An archive holding a single directory holds the world inside it
+	src := staging
+	if entries, err := fsys.List(staging); err == nil && len(entries) == 1 && entries[0].Dir {
+		src = entries[0].Path
+	}
+	rel := worlds.Path(world)
+	if replace {
+		old := path.Join(worlds.Dir, "."+world+".replaced-"+stamp)
+		if err := fsys.Rename(rel, old); err != nil {
+			return err
+		}
+		defer fsys.Remove(old, true)
+	}
+	return fsys.Rename(src, rel)
+}
+
+// DuplicateWorld copies a world to a new name on the same server
+func (h *ServerHandler) DuplicateWorld(c *gin.Context) {
+	serverID := c.Param("id")
+	world := c.Param("world")
+	var req worldDuplicateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	for _, name := range []string{world, req.Name} {
+		if err := worlds.ValidateName(name); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+	}
+
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	src, dest := worlds.Path(world), worlds.Path(req.Name)
+	if !h.statWorld(c, fsys, src) {
+		return
+	}
+	if _, err := fsys.Stat(dest); err == nil {
+		h.respondFileError(c, worldOpDuplicate, dest, files.ErrExists)
+		return
+	}
+	srcPath, err := fsys.HostPath(src)
+	var destPath string
+	if err == nil {
+		destPath, err = fsys.HostPath(dest)
+	}
+	if err != nil {
+		h.respondFileError(c, worldOpDuplicate, dest, err)
+		return
+	}
+	_, conn, err := h.connectServer(serverID)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return
+	}
+
+	output, err := conn.Client.RunCommandContext(c.Request.Context(), bashDollarQuotedCommand(worlds.CopyScript(srcPath, destPath)))
+	if err != nil {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(output))
+	}
+	h.logWorldChange(serverID, getUserIDFromContext(c), worldOpDuplicate, fmt.Sprintf("Duplicated world %s as %s", world, req.Name),
+		map[string]interface{}{"world": world, "to": req.Name}, err)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Failed to duplicate world", "server_id", serverID, "world", world, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, fmt.Sprintf("Failed to duplicate %s: %v", world, err))
+		return
+	}
+	entry, err := fsys.Stat(dest)
+	if err != nil {
+		h.respondFileError(c, worldOpDuplicate, dest, err)
+		return
+	}
+	size, _ := fsys.Size(dest)
+	c.JSON(http.StatusCreated, worlds.World{Name: req.Name, Path: dest, Size: size, ModTime: entry.ModTime})
+}
+
+// DeleteWorld deletes a world. The world a running server plays is refused.
+func (h *ServerHandler) DeleteWorld(c *gin.Context) {
+	world := c.Param("world")
+	if err := worlds.ValidateName(world); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	rel := worlds.Path(world)
+	if !h.statWorld(c, fsys, rel) {
+		return
+	}
+	if !h.worldChangeAllowed(c, c.Param("id"), fsys, world) {
+		return
+	}
+
+	err := fsys.Remove(rel, true)
+	h.logWorldChange(c.Param("id"), getUserIDFromContext(c), worldOpDelete, fmt.Sprintf("Deleted world %s", world),
+		map[string]interface{}{"world": world}, err)
+	if err != nil {
+		h.respondFileError(c, worldOpDelete, rel, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "World deleted", "world": world})
+}
+
+// TransferWorld copies a world to another server as a world-transfer task on
+// this one. The world is read over SFTP and written to the target over SFTP
+// as it is read, without being stored on the manager. It needs
+// servers.worlds.manage on the target.
+func (h *ServerHandler) TransferWorld(c *gin.Context) {
+	serverID := c.Param("id")
+	world := c.Param("world")
+	var req worldTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	name := req.Name
+	if name == "" {
+		name = world
+	}
+	for _, candidate := range []string{world, name} {
+		if err := worlds.ValidateName(candidate); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+			return
+		}
+	}
+	if req.TargetServerID == serverID {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, "target_server_id must be another server; duplicate the world instead")
+		return
+	}
+	if _, found := h.serverManager.GetByID(req.TargetServerID); !found {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeServerNotFound, "Target server not found")
+		return
+	}
+	userID := getUserIDFromContext(c)
+	if userID == nil {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+		return
+	}
+	allowed, err := middleware.HasPermission(h.rbacManager, *userID, req.TargetServerID, permissions.ServersWorldsManage)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "Server permission check failed", "server_id", req.TargetServerID, "permission", permissions.ServersWorldsManage, "error", err)
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check permissions")
+		return
+	}
+	if !allowed {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions for servers: "+req.TargetServerID)
+		return
+	}
+
+	src, srcClient, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	rel := worlds.Path(world)
+	if !h.statWorld(c, src, rel) {
+		srcClient.Close()
+		return
+	}
+	dest, destClient, err := h.openServerFS(req.TargetServerID)
+	if err != nil {
+		srcClient.Close()
+		logger.ErrorContext(c.Request.Context(), "Failed to open server directory", "server_id", req.TargetServerID, "error", err)
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return
+	}
+	closeClients := func() {
+		srcClient.Close()
+		destClient.Close()
+	}
+	target := worlds.Path(name)
+	_, statErr := dest.Stat(target)
+	exists := statErr == nil
+	if exists && !req.Overwrite {
+		closeClients()
+		h.respondFileError(c, worldOpTransfer, target, files.ErrExists)
+		return
+	}
+	if exists && !h.worldChangeAllowed(c, req.TargetServerID, dest, name) {
+		closeClients()
+		return
+	}
+	if err := ensureDir(dest, worlds.Dir); err != nil {
+		closeClients()
+		h.respondFileError(c, worldOpTransfer, worlds.Dir, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":          "World transfer started",
+		"world":            world,
+		"target_server_id": req.TargetServerID,
+		"name":             name,
+	})
+
+	h.goTask(c, serverID, "world-transfer", func(ctx context.Context, task *taskRecord) {
+		defer closeClients()
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+		total, err := src.Size(rel)
+		if err == nil {
+			emit(fmt.Sprintf("Transferring world %s (%d bytes) to %s as %s...", world, total, req.TargetServerID, name))
+			err = relayWorld(ctx, src, rel, dest, name, exists, total, emit)
+		}
+		metadata := map[string]interface{}{"world": world, "target_server_id": req.TargetServerID, "name": name, "size": total, "overwrite": exists}
+		if err != nil {
+			emit("Transfer failed: " + err.Error())
+		} else {
+			emit(fmt.Sprintf("World %s is on %s as %s.", world, req.TargetServerID, name))
+		}
+		h.logWorldChange(serverID, userID, worldOpTransfer, fmt.Sprintf("Transferred world %s to %s", world, req.TargetServerID), metadata, err)
+		h.logWorldChange(req.TargetServerID, userID, worldOpTransfer, fmt.Sprintf("Received world %s from %s", name, serverID), metadata, err)
+		h.finishTask(serverID, task.ID, err)
+	})
+}
+
+// relayWorld archives a world on src and unpacks it on dest as it is read,
+// reporting progress against total, the bytes the world holds
+func relayWorld(ctx context.Context, src *files.FS, rel string, dest *files.FS, name string, replace bool, total int64, emit func(string)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	reader, writer := io.Pipe()
+	stop := context.AfterFunc(ctx, func() { reader.CloseWithError(ctx.Err()) })
+	defer stop()
+
+	archived := make(chan error, 1)
+	go func() {
+		last := time.Now()
+		err := src.ArchiveProgress(rel, writer, func(done int64) {
+			if time.Since(last) < worldProgressInterval {
+				return
+			}
+			last = time.Now()
+			percent := int64(100)
+			if total > 0 {
+				percent = min(done*100/total, 100)
+			}
+			emit(fmt.Sprintf("Transferred %d of %d bytes (%d%%)", done, total, percent))
+		})
+		writer.CloseWithError(err)
+		archived <- err
+	}()
+
+	err := unpackWorld(dest, name, reader, replace)
+	// The unpacker stops at the end of the tar; the rest of the stream is
+	// read so the archive can finish
+	if err == nil {
+		_, err = io.Copy(io.Discard, reader)
+	}
+	reader.CloseWithError(err)
+	if archiveErr := <-archived; archiveErr != nil && err == nil {
+		err = archiveErr
+	}
+	return err
+}
+
+// SnapshotWorld tars a world into the snapshot directory on the host as a
+// server task. A running server's autosave is paused while the world is read,
+// after it was told to save.
+func (h *ServerHandler) SnapshotWorld(c *gin.Context) {
+	serverID := c.Param("id")
+	var req worldSnapshotRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+
+	fsys, client, ok := h.openServerFiles(c)
+	if !ok {
+		return
+	}
+	defer client.Close()
+	world := req.World
+	if world == "" {
+		if world, ok = h.activeWorld(c, fsys); !ok {
+			return
+		}
+	}
+	if err := worlds.ValidateName(world); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeValidationFailed, err.Error())
+		return
+	}
+	rel := worlds.Path(world)
+	if !h.statWorld(c, fsys, rel) {
+		return
+	}
+	snapshot := worlds.SnapshotPath(world, time.Now())
+	srcPath, err := fsys.HostPath(rel)
+	var destPath string
+	if err == nil {
+		destPath, err = fsys.HostPath(snapshot)
+	}
+	if err != nil {
+		h.respondFileError(c, worldOpSnapshot, snapshot, err)
+		return
+	}
+	_, conn, err := h.connectServer(serverID)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadGateway, apierror.CodeUpstreamFailed, err.Error())
+		return
+	}
+
+	userID := getUserIDFromContext(c)
+	c.JSON(http.StatusAccepted, gin.H{"message": "World snapshot started", "world": world, "path": snapshot})
+
+	h.goTask(c, serverID, "world-snapshot", func(ctx context.Context, task *taskRecord) {
+		emit := func(line string) {
+			h.appendTaskStreamLine(serverID, task.ID, task.Task, line)
+		}
+		if h.serverRunning(serverID) {
+			emit("Pausing autosave...")
+			h.sendWorldCommand(serverID, worlds.SaveOffCommand, emit)
+			defer func() {
+				emit("Resuming autosave...")
+				h.sendWorldCommand(serverID, worlds.SaveOnCommand, emit)
+			}()
+			h.sendWorldCommand(serverID, worlds.SaveCommand, emit)
+			select {
+			case <-time.After(worldFlushDelay):
+			case <-ctx.Done():
+			}
+		}
+
+		emit(fmt.Sprintf("Writing %s...", snapshot))
+		var size string
+		writer := newLineSinkWriter(func(line string) {
+			size = line
+			emit(line)
+		})
+		err := ctx.Err()
+		if err == nil {
+			err = conn.Client.StreamCommandContext(ctx, bashDollarQuotedCommand(worlds.SnapshotScript(srcPath, destPath)), writer, writer)
+			writer.FlushRemaining()
+		}
+		metadata := map[string]interface{}{"world": world, "path": snapshot}
+		if n, parseErr := strconv.ParseInt(strings.TrimSpace(size), 10, 64); err == nil && parseErr == nil {
+			metadata["size"] = n
+			emit(fmt.Sprintf("Snapshot of %s written to %s (%d bytes).", world, snapshot, n))
+		}
+		if err != nil {
+			emit("Snapshot failed: " + err.Error())
+		}
+		h.logWorldChange(serverID, userID, worldOpSnapshot, fmt.Sprintf("Snapshotted world %s", world), metadata, err)
+		h.finishTask(serverID, task.ID, err)
+	})
+}
+
+// sendWorldCommand sends a console command around a snapshot. A command that
+// fails is reported and the snapshot goes on.
+func (h *ServerHandler) sendWorldCommand(serverID, command string, emit func(string)) {
+	if err := h.processManager.SendCommand(serverID, server.SafeSessionName(serverID), command); err != nil {
+		emit(fmt.Sprintf("Failed to send %q: %v", command, err))
+	}
+}
+
+// activeWorld returns the world players join, from config.json
+func (h *ServerHandler) activeWorld(c *gin.Context, fsys *files.FS) (string, bool) {
+	settings, _, ok := h.readServerConfig(c, fsys)
+	if !ok {
+		return "", false
+	}
+	return settings.Defaults.World, true
+}
+
+// statWorld checks that a world exists, responding when it does not
+func (h *ServerHandler) statWorld(c *gin.Context, fsys *files.FS, rel string) bool {
+	entry, err := fsys.Stat(rel)
+	if err == nil && !entry.Dir {
+		err = files.ErrNotDir
+	}
+	if errors.Is(err, files.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, fmt.Sprintf("World %s not found", path.Base(rel)))
+		return false
+	}
+	if err != nil {
+		h.respondFileError(c, "read", rel, err)
+		return false
+	}
+	return true
+}
+
+// worldChangeAllowed refuses replacing or deleting the world a running server
+// plays
+func (h *ServerHandler) worldChangeAllowed(c *gin.Context, serverID string, fsys *files.FS, world string) bool {
+	active, ok := h.activeWorld(c, fsys)
+	if !ok {
+		return false
+	}
+	if world == active && h.serverRunning(serverID) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict,
+			fmt.Sprintf("World %s is in use; stop the server first", world))
+		return false
+	}
+	return true
+}
+
+// ensureDir creates a directory and its missing parents
+func ensureDir(fsys *files.FS, rel string) error {
+	var dir string
+	for _, part := range strings.Split(files.Clean(rel), "/") {
+		dir = path.Join(dir, part)
+		if err := fsys.Mkdir(dir); err != nil && !errors.Is(err, files.ErrExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *ServerHandler) logWorldChange(serverID string, userID *int64, operation, description string, metadata map[string]interface{}, err error) {
+	activity := &logging.Activity{
+		ServerID:     serverID,
+		UserID:       userID,
+		ActivityType: logging.ActivityWorldChange,
+		Description:  description,
+		Metadata:     map[string]interface{}{"operation": operation},
+		Success:      err == nil,
+	}
+	for key, value := range metadata {
+		activity.Metadata[key] = value
+	}
+	if err != nil {
+		activity.ErrorMessage = err.Error()
+	}
+	_ = h.activityLogger.LogActivity(activity)
+}
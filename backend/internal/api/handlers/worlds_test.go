@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/files"
+	"github.com/TheGojiOG/HytaleSM/internal/worlds"
+	"github.com/pkg/sftp"
+)
+
+// newTestServerFS serves SFTP for a temporary server directory in-process
+// and opens it
+func newTestServerFS(t *testing.T) (*files.FS, string) {
+	t.Helper()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	if err != nil {
+		t.Fatalf("start sftp server: %v", err)
+	}
+	go server.Serve()
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	if err != nil {
+		t.Fatalf("start sftp client: %v", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, filepath.FromSlash(worlds.Dir)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := files.New(client, filepath.ToSlash(dir))
+	if err != nil {
+		t.Fatalf("open server directory: %v", err)
+	}
+	return fsys, dir
+}
+
+func TestRelayWorldCopiesBetweenServers(t *testing.T) {
+	src, srcDir := newTestServerFS(t)
+	dest, destDir := newTestServerFS(t)
+	writeTestFile(t, filepath.Join(srcDir, "universe", "worlds", "default", "config.json"), `{"seed":1}`)
+	writeTestFile(t, filepath.Join(srcDir, "universe", "worlds", "default", "chunks", "0.0.region"), strings.Repeat("x", 1<<16))
+	// The target already has an older copy, which is replaced
+	writeTestFile(t, filepath.Join(destDir, "universe", "worlds", "lobby", "stale.txt"), "old")
+
+	total, err := src.Size(worlds.Path("default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := relayWorld(context.Background(), src, worlds.Path("default"), dest, "lobby", true, total, func(string) {}); err != nil {
+		t.Fatalf("relay: %v", err)
+	}
+
+	lobby := filepath.Join(destDir, "universe", "worlds", "lobby")
+	if got := readTestFile(t, filepath.Join(lobby, "config.json")); got != `{"seed":1}` {
+		t.Fatalf("expected config.json on the target, got %q", got)
+	}
+	if info, err := os.Stat(filepath.Join(lobby, "chunks", "0.0.region")); err != nil || info.Size() != 1<<16 {
+		t.Fatalf("expected the region file on the target, got %v %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(lobby, "stale.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the old world replaced, got %v", err)
+	}
+	// Nothing staged is left next to the worlds
+	entries, err := os.ReadDir(filepath.Join(destDir, "universe", "worlds"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the transferred world, got %v %v", entries, err)
+	}
+}
+
+func TestRelayWorldStopsWhenCancelled(t *testing.T) {
+	src, srcDir := newTestServerFS(t)
+	dest, destDir := newTestServerFS(t)
+	writeTestFile(t, filepath.Join(srcDir, "universe", "worlds", "default", "config.json"), "{}")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := relayWorld(ctx, src, worlds.Path("default"), dest, "default", false, 2, func(string) {}); err == nil {
+		t.Fatal("expected a cancelled transfer to fail")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "universe", "worlds", "default")); !os.IsNotExist(err) {
+		t.Fatalf("expected no world on the target, got %v", err)
+	}
+}
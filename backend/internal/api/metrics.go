@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/handlers"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/telemetry"
+	"github.com/TheGojiOG/HytaleSM/internal/websocket"
+)
+
+// databaseSizeTimeout bounds the size query run on every scrape
+const databaseSizeTimeout = 2 * time.Second
+
+// registerManagerMetrics adds the manager's own state to registry, next to
+// the HTTP metrics: pooled SSH connections, WebSocket clients, running
+// tasks, backup results and the database size. Gauges are read when
+// /metrics is scraped, so they cost nothing between scrapes.
+func registerManagerMetrics(
+	registry *telemetry.Registry,
+	db *database.DB,
+	pool *ssh.ConnectionPool,
+	hub *websocket.Hub,
+	serverHandler *handlers.ServerHandler,
+	hookRunner *hooks.Runner,
+) {
+	registry.NewGaugeFunc("hsm_ssh_pool_connections",
+		"Pooled SSH connections.", func(observe func(float64, ...string)) {
+			observe(float64(pool.GetConnectionCount()))
+		})
+	registry.NewGaugeFunc("hsm_ssh_pool_connections_by_health",
+		"Pooled SSH connections by the result of their last health check.", func(observe func(float64, ...string)) {
+			stats := pool.GetStats()
+			for _, health := range []string{"healthy", "degraded", "failed"} {
+				count, _ := stats[health].(int)
+				observe(float64(count), health)
+			}
+		}, "health")
+	registry.NewGaugeFunc("hsm_websocket_clients",
+		"Connected WebSocket clients by kind of room (console, server-tasks, release-job, ...).", func(observe func(float64, ...string)) {
+			for room, count := range hub.ClientCounts() {
+				observe(float64(count), room)
+			}
+		}, "room")
+	registry.NewGaugeFunc("hsm_tasks_running",
+		"Background tasks (installs, deploys, updates, ...) running on this instance, by task.", func(observe func(float64, ...string)) {
+			for task, count := range serverHandler.RunningTasks() {
+				observe(float64(count), task)
+			}
+		}, "task")
+	registry.NewGaugeFunc("hsm_tasks_queued",
+		"Background tasks waiting on this instance for a free slot, by task.", func(observe func(float64, ...string)) {
+			for task, count := range serverHandler.QueuedTasks() {
+				observe(float64(count), task)
+			}
+		}, "task")
+	registry.NewGaugeFunc("hsm_database_size_bytes",
+		"Size of the manager's database.", func(observe func(float64, ...string)) {
+			ctx, cancel := context.WithTimeout(context.Background(), databaseSizeTimeout)
+			defer cancel()
+			size, err := db.Size(ctx)
+			if err != nil {
+				logging.For("api").Warn("Failed to read the database size for metrics", "error", err)
+				return
+			}
+			observe(float64(size))
+		})
+
+	backups := registry.NewCounterVec("hsm_backups_total",
+		"Backups finished by this instance, by result (success or failure).", "result")
+	backups.Add(0, "success")
+	backups.Add(0, "failure")
+	hookRunner.OnEvent(func(event hooks.Event) {
+		if event.Name != hooks.PostBackup || event.Success == nil {
+			return
+		}
+		if *event.Success {
+			backups.Inc("success")
+		} else {
+			backups.Inc("failure")
+		}
+	})
+}
@@ -1,17 +1,61 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 )
 
-// Audit logs every API action into audit_logs
+// AuditDetailsKey is the context key under which a handler leaves details,
+// a map[string]interface{}, to be stored with its audit log entry
+const AuditDetailsKey = "audit_details"
+
+// auditBodyLimit is the most of a request body kept with its audit log
+// entry; a longer body is noted as truncated instead
+const auditBodyLimit = 16 << 10
+
+// auditRedacted replaces the values of sensitive fields in an audited body
+const auditRedacted = "[redacted]"
+
+// auditSensitiveWords mark a body field whose value is never audited
+var auditSensitiveWords = []string{
+	"password", "passphrase", "secret", "token", "private_key", "key_content",
+	"access_key", "api_key", "credential", "authorization", "totp", "recovery_code",
+}
+
+// readOnlyPosts are routes that take POST only because their clients send
+// the query in the body (Grafana's JSON datasource). They change nothing, so
+// they are neither audited nor held back by the maintenance lock.
+var readOnlyPosts = map[string]bool{
+	"/api/v1/grafana/search":  true,
+	"/api/v1/grafana/metrics": true,
+	"/api/v1/grafana/query":   true,
+}
+
+// Audit logs every mutating API request into audit_logs, with its JSON body
+// once sensitive fields are redacted. Reads are not audited.
 func Audit(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		if readOnlyPosts[c.FullPath()] {
+			c.Next()
+			return
+		}
+		body, truncated := readAuditBody(c)
+
 		c.Next()
 
 		path := c.FullPath()
@@ -19,10 +63,6 @@ func Audit(db *sql.DB) gin.HandlerFunc {
 			path = c.Request.URL.Path
 		}
 
-		if path == "/health" {
-			return
-		}
-
 		action := fmt.Sprintf("%s %s", c.Request.Method, path)
 		status := c.Writer.Status()
 		success := status < 400
@@ -36,23 +76,133 @@ func Audit(db *sql.DB) gin.HandlerFunc {
 
 		resourceType, resourceID := deriveResource(path, c)
 
-		detailsJSON, _ := json.Marshal(map[string]interface{}{
-			"status": status,
-		})
+		details := map[string]interface{}{}
+		if extra, ok := c.Get(AuditDetailsKey); ok {
+			if extra, ok := extra.(map[string]interface{}); ok {
+				for key, value := range extra {
+					details[key] = value
+				}
+			}
+		}
+		details["status"] = status
+		if body != nil {
+			details["body"] = body
+		} else if truncated {
+			details["body_truncated"] = true
+		}
+		details["request_id"] = c.GetString(apierror.RequestIDKey)
+		if keyID, exists := c.Get("api_key_id"); exists {
+			details["api_key_id"] = keyID
+		}
+		detailsJSON, _ := json.Marshal(details)
 
-		_, _ = db.Exec(`
+		_, _ = db.ExecContext(context.WithoutCancel(c.Request.Context()), `
 			INSERT INTO audit_logs (user_id, action, resource_type, resource_id, ip_address, user_agent, success, details)
 			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 		`, userIDValue, action, resourceType, resourceID, c.ClientIP(), c.Request.UserAgent(), success, string(detailsJSON))
 	}
 }
 
+// readAuditBody reads up to auditBodyLimit of a JSON request body and leaves
+// the whole body for the handler. It returns the body with sensitive fields
+// redacted, or reports that it was too long to keep.
+func readAuditBody(c *gin.Context) (interface{}, bool) {
+	if c.Request.Body == nil || c.ContentType() != "application/json" {
+		return nil, false
+	}
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, auditBodyLimit+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	if err != nil || len(head) == 0 {
+		return nil, false
+	}
+	if len(head) > auditBodyLimit {
+		return nil, true
+	}
+	var body interface{}
+	if err := json.Unmarshal(head, &body); err != nil {
+		return nil, false
+	}
+	return redactAuditValue("", body), false
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// redactAuditValue replaces the values of sensitive fields, at any depth
+func redactAuditValue(name string, value interface{}) interface{} {
+	if name != "" && isAuditSensitive(name) {
+		if value == nil || value == "" {
+			return value
+		}
+		return auditRedacted
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = redactAuditValue(key, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactAuditValue(name, child)
+		}
+	}
+	return value
+}
+
+// CarriesSecrets reports whether a JSON body sets a field the audit log
+// redacts. A body that does not parse is assumed to.
+func CarriesSecrets(body []byte) bool {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return true
+	}
+	return carriesSecrets("", value)
+}
+
+func carriesSecrets(name string, value interface{}) bool {
+	if name != "" && isAuditSensitive(name) && value != nil && value != "" {
+		return true
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if carriesSecrets(key, child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if carriesSecrets(name, child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isAuditSensitive(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range auditSensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
 func deriveResource(path string, c *gin.Context) (string, string) {
 	resourceID := ""
 	if id := c.Param("id"); id != "" {
 		resourceID = id
 	} else if id := c.Param("backupId"); id != "" {
 		resourceID = id
+	} else if len(c.Params) > 0 {
+		resourceID = c.Params[0].Value
 	}
 
 	resourceType := ""
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuditRecordsMutatingRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(Audit(db.DB))
+	var received string
+	handle := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.Status(http.StatusNoContent)
+	}
+	router.GET("/api/v1/servers/:id", handle)
+	router.PUT("/api/v1/servers/:id", handle)
+
+	body := `{"name":"Survival","connection":{"password":"hunter2","host":"10.0.0.5"},"tokens":[{"api_key":"abc"}]}`
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/servers/alpha", nil),
+		httptest.NewRequest(http.MethodPut, "/api/v1/servers/alpha", strings.NewReader(body)),
+		httptest.NewRequest(http.MethodPut, "/api/v1/servers/alpha", strings.NewReader(`{"notes":"`+strings.Repeat("x", auditBodyLimit)+`"}`)),
+	} {
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if !strings.HasPrefix(received, `{"notes":"xxx`) || len(received) != auditBodyLimit+len(`{"notes":""}`) {
+		t.Fatalf("expected the handler to read the whole long body, got %d bytes", len(received))
+	}
+
+	rows, err := db.DB.Query(`SELECT action, resource_type, resource_id, details FROM audit_logs ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var entries []map[string]interface{}
+	for rows.Next() {
+		var action, resourceType, resourceID, details string
+		if err := rows.Scan(&action, &resourceType, &resourceID, &details); err != nil {
+			t.Fatal(err)
+		}
+		if action != "PUT /api/v1/servers/:id" || resourceType != "servers" || resourceID != "alpha" {
+			t.Fatalf("unexpected entry %s %s %s", action, resourceType, resourceID)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(details), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, decoded)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected only the two writes to be audited, got %d entries", len(entries))
+	}
+
+	audited, _ := json.Marshal(entries[0]["body"])
+	if strings.Contains(string(audited), "hunter2") || strings.Contains(string(audited), "abc") || !strings.Contains(string(audited), "10.0.0.5") {
+		t.Fatalf("expected the secrets to be redacted and the rest kept, got %s", audited)
+	}
+	if entries[1]["body"] != nil || entries[1]["body_truncated"] != true {
+		t.Fatalf("expected the long body to be noted as truncated, got %v", entries[1])
+	}
+}
+
+func TestCarriesSecrets(t *testing.T) {
+	for body, want := range map[string]bool{
+		``:                                      false,
+		`{"version":"1.2"}`:                     false,
+		`{"connection":{"password":""}}`:        false,
+		`{"connection":{"password":"hunter2"}}`: true,
+		`{"keys":[{"private_key":"-----BEGIN"}]}`: true,
+		`{"version":`: true,
+	} {
+		if got := CarriesSecrets([]byte(body)); got != want {
+			t.Errorf("CarriesSecrets(%s) = %v, want %v", body, got, want)
+		}
+	}
+}
@@ -1,18 +1,20 @@
 package middleware
 
 import (
-	"log"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
 )
 
 const accessTokenCookieName = "hsm_access"
 
-// Auth middleware validates JWT tokens
-func Auth(jwtManager *auth.JWTManager) gin.HandlerFunc {
+// Auth middleware validates JWT tokens, or API keys sent in the X-API-Key
+// header or as a bearer token when apiKeys is set
+func Auth(jwtManager *auth.JWTManager, apiKeys *auth.APIKeyStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get authorization header or query token (for WebSocket clients)
 		authHeader := c.GetHeader("Authorization")
@@ -21,13 +23,31 @@ func Auth(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			// Check Bearer token format
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-				c.Abort()
+				apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Invalid authorization header format")
 				return
 			}
 			token = parts[1]
 		}
 
+		// API keys are only accepted in headers so they stay out of URLs and logs
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			token = key
+		}
+		if auth.IsAPIKey(token) && apiKeys != nil {
+			claims, keyID, err := apiKeys.Authenticate(c.Request.Context(), token)
+			if err != nil {
+				if !errors.Is(err, auth.ErrInvalidAPIKey) {
+					logger.ErrorContext(c.Request.Context(), "api key lookup failed", "error", err)
+				}
+				apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired API key")
+				return
+			}
+			setUser(c, claims)
+			c.Set("api_key_id", keyID)
+			c.Next()
+			return
+		}
+
 		if token == "" {
 			if cookie, err := c.Cookie(accessTokenCookieName); err == nil && cookie != "" {
 				token = cookie
@@ -39,58 +59,48 @@ func Auth(jwtManager *auth.JWTManager) gin.HandlerFunc {
 		}
 
 		if token == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "Authentication required")
 			return
 		}
 
 		// Validate token
 		claims, err := jwtManager.ValidateAccessToken(token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid or expired token")
 			return
 		}
 
-		// Store user info in context
-		c.Set("user", claims)  // Store full claims object
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
-		c.Set("organization_id", claims.OrganizationID)
-
+		setUser(c, claims)
 		c.Next()
 	}
 }
 
+// setUser stores the authenticated user's claims in the request context
+func setUser(c *gin.Context, claims *auth.Claims) {
+	c.Set("user", claims) // Store full claims object
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+	c.Set("organization_id", claims.OrganizationID)
+}
+
 // RequirePermission checks if the user has a specific permission
 func RequirePermission(rbacManager *auth.RBACManager, permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "User not authenticated")
 			return
 		}
 
-		permissionsToCheck := append([]string{permission}, legacyPermissions(permission)...)
-		allowed := false
-		for _, perm := range permissionsToCheck {
-			hasPermission, err := rbacManager.HasPermission(userID.(int64), perm)
-			if err != nil {
-				log.Printf("[RBAC] permission check failed: user=%v permission=%s err=%v", userID, perm, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
-				c.Abort()
-				return
-			}
-			if hasPermission {
-				allowed = true
-				break
-			}
+		allowed, err := HasPermission(rbacManager, userID.(int64), "", permission)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "permission check failed", "user_id", userID, "permission", permission, "error", err)
+			apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check permissions")
+			return
 		}
 
 		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-			c.Abort()
+			apierror.Abort(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions")
 			return
 		}
 
@@ -103,37 +113,25 @@ func RequireServerPermission(rbacManager *auth.RBACManager, permission string) g
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-			c.Abort()
+			apierror.Abort(c, http.StatusUnauthorized, apierror.CodeUnauthorized, "User not authenticated")
 			return
 		}
 
 		serverID := c.Param("id")
 		if serverID == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Server ID is required"})
-			c.Abort()
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Server ID is required")
 			return
 		}
 
-		permissionsToCheck := append([]string{permission}, legacyPermissions(permission)...)
-		allowed := false
-		for _, perm := range permissionsToCheck {
-			hasPermission, err := rbacManager.HasServerPermission(userID.(int64), serverID, perm)
-			if err != nil {
-				log.Printf("[RBAC] server permission check failed: user=%v server=%s permission=%s err=%v", userID, serverID, perm, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
-				c.Abort()
-				return
-			}
-			if hasPermission {
-				allowed = true
-				break
-			}
+		allowed, err := HasPermission(rbacManager, userID.(int64), serverID, permission)
+		if err != nil {
+			logger.ErrorContext(c.Request.Context(), "server permission check failed", "user_id", userID, "server_id", serverID, "permission", permission, "error", err)
+			apierror.Abort(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to check permissions")
+			return
 		}
 
 		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions for this server"})
-			c.Abort()
+			apierror.Abort(c, http.StatusForbidden, apierror.CodeForbidden, "Insufficient permissions for this server")
 			return
 		}
 
@@ -141,6 +139,24 @@ func RequireServerPermission(rbacManager *auth.RBACManager, permission string) g
 	}
 }
 
+// HasPermission reports whether a user holds permission, or a legacy
+// permission it replaced. With a serverID, a role on that server counts too.
+func HasPermission(rbacManager *auth.RBACManager, userID int64, serverID, permission string) (bool, error) {
+	for _, perm := range append([]string{permission}, legacyPermissions(permission)...) {
+		var allowed bool
+		var err error
+		if serverID == "" {
+			allowed, err = rbacManager.HasPermission(userID, perm)
+		} else {
+			allowed, err = rbacManager.HasServerPermission(userID, serverID, perm)
+		}
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+	return false, nil
+}
+
 func legacyPermissions(permission string) []string {
 	switch permission {
 	case "servers.list", "servers.get", "servers.metrics.read", "servers.metrics.latest", "servers.metrics.live", "servers.activity.read", "servers.status.read":
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+)
+
+// APIVersionHeader reports which API version served the request
+const APIVersionHeader = "X-API-Version"
+
+// Deprecation announces superseded routes with Deprecation, Sunset and Link
+// headers so clients can migrate before the route is removed
+func Deprecation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if deprecation, ok := versioning.Lookup(c.Request.Method, route); ok {
+			for name, values := range deprecation.Headers() {
+				for _, value := range values {
+					c.Writer.Header().Add(name, value)
+				}
+			}
+		}
+		if version := versioning.FromPath(route); version != "" {
+			c.Header(APIVersionHeader, version)
+		}
+		c.Next()
+	}
+}
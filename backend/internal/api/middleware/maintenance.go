@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// MaintenanceState holds the live maintenance lock shared by the middleware and settings API
+type MaintenanceState struct {
+	mu        sync.RWMutex
+	cfg       config.MaintenanceConfig
+	changedAt time.Time
+	changedBy string
+}
+
+// MaintenanceStatus is the serializable view of the maintenance lock
+type MaintenanceStatus struct {
+	Enabled      bool      `json:"enabled"`
+	Message      string    `json:"message"`
+	AllowedRoles []string  `json:"allowed_roles"`
+	ChangedAt    time.Time `json:"changed_at"`
+	ChangedBy    string    `json:"changed_by,omitempty"`
+}
+
+// NewMaintenanceState creates the maintenance lock from configuration
+func NewMaintenanceState(cfg config.MaintenanceConfig) *MaintenanceState {
+	return &MaintenanceState{cfg: cfg, changedAt: time.Now()}
+}
+
+// Status returns a snapshot of the maintenance lock
+func (s *MaintenanceState) Status() MaintenanceStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return MaintenanceStatus{
+		Enabled:      s.cfg.Enabled,
+		Message:      s.cfg.Message,
+		AllowedRoles: append([]string{}, s.cfg.AllowedRoles...),
+		ChangedAt:    s.changedAt,
+		ChangedBy:    s.changedBy,
+	}
+}
+
+// Config returns the maintenance settings for persistence
+func (s *MaintenanceState) Config() config.MaintenanceConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cfg := s.cfg
+	cfg.AllowedRoles = append([]string{}, s.cfg.AllowedRoles...)
+	return cfg
+}
+
+// Set replaces the maintenance settings
+func (s *MaintenanceState) Set(cfg config.MaintenanceConfig, changedBy string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cfg.Enabled != s.cfg.Enabled {
+		s.changedAt = time.Now()
+		s.changedBy = changedBy
+	}
+	s.cfg = cfg
+}
+
+func (s *MaintenanceState) allows(roles []string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, allowed := range s.cfg.AllowedRoles {
+		for _, role := range roles {
+			if strings.EqualFold(strings.TrimSpace(allowed), role) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Maintenance rejects mutating requests while the maintenance lock is enabled.
+// Users holding one of the allowed roles bypass the lock so they can finish the upgrade.
+func Maintenance(state *MaintenanceState) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if state == nil || !isMutatingMethod(c.Request.Method) || c.Request.URL.Path == "/api/v1/auth/logout" || readOnlyPosts[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		status := state.Status()
+		if !status.Enabled {
+			c.Next()
+			return
+		}
+
+		if value, exists := c.Get("user"); exists {
+			if claims, ok := value.(*auth.Claims); ok && state.allows(claims.Roles) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("Retry-After", "300")
+		apierror.AbortDetails(c, http.StatusServiceUnavailable, apierror.CodeMaintenance, "Maintenance mode active", gin.H{
+			"message": status.Message,
+			"since":   status.ChangedAt,
+		})
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	default:
+		return true
+	}
+}
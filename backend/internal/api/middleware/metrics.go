@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/telemetry"
+)
+
+// HTTPMetrics counts requests and records their latency per route
+type HTTPMetrics struct {
+	requests *telemetry.CounterVec
+	errors   *telemetry.CounterVec
+	duration *telemetry.HistogramVec
+}
+
+// NewHTTPMetrics registers the HTTP metrics on registry
+func NewHTTPMetrics(registry *telemetry.Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: registry.NewCounterVec("hsm_http_requests_total",
+			"HTTP requests by route, method and status code.", "method", "route", "status"),
+		errors: registry.NewCounterVec("hsm_http_request_errors_total",
+			"HTTP requests that ended in a 5xx response, by route and method.", "method", "route"),
+		duration: registry.NewHistogramVec("hsm_http_request_duration_seconds",
+			"HTTP request latency by route and method, excluding WebSocket streams.", nil, "method", "route"),
+	}
+}
+
+// Metrics records every request against its route pattern (e.g.
+// /api/v1/servers/:id), so series stay bounded however many servers exist.
+// WebSocket upgrades are counted but left out of the latency histogram since
+// they last as long as the stream.
+func Metrics(metrics *HTTPMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		status := c.Writer.Status()
+
+		metrics.requests.Inc(method, route, strconv.Itoa(status))
+		if status >= 500 {
+			metrics.errors.Inc(method, route)
+		}
+		if !c.IsWebsocket() {
+			metrics.duration.Observe(time.Since(start).Seconds(), method, route)
+		}
+	}
+}
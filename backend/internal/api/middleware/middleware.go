@@ -7,48 +7,56 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/logging"
 )
 
+// logger writes to the "api" module, as the handlers do
+var logger = logging.For("api")
+
 // CORS middleware adds CORS headers
 func CORS(cfg config.CORSConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-		allowed := isOriginAllowed(origin, cfg.AllowedOrigins)
-
-		if allowed {
-			if origin != "" {
-				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-			} else if containsWildcard(cfg.AllowedOrigins) {
-				c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-			}
-		}
+		applyCORS(c, cfg)
+	}
+}
 
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, Authorization, Accept, Origin, Cache-Control, X-Requested-With")
-
-		// Set allowed methods
-		methods := "GET, POST, PUT, DELETE, OPTIONS"
-		if len(cfg.AllowedMethods) > 0 {
-			methods = ""
-			for i, method := range cfg.AllowedMethods {
-				if i > 0 {
-					methods += ", "
-				}
-				methods += method
-			}
+func applyCORS(c *gin.Context, cfg config.CORSConfig) {
+	origin := c.Request.Header.Get("Origin")
+	allowed := isOriginAllowed(origin, cfg.AllowedOrigins)
+
+	if allowed {
+		if origin != "" {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+		} else if containsWildcard(cfg.AllowedOrigins) {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		}
-		c.Writer.Header().Set("Access-Control-Allow-Methods", methods)
+	}
 
-		// Handle preflight requests
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+	c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, Authorization, Accept, Origin, Cache-Control, X-Requested-With")
+
+	// Set allowed methods
+	methods := "GET, POST, PUT, DELETE, OPTIONS"
+	if len(cfg.AllowedMethods) > 0 {
+		methods = ""
+		for i, method := range cfg.AllowedMethods {
+			if i > 0 {
+				methods += ", "
+			}
+			methods += method
 		}
+	}
+	c.Writer.Header().Set("Access-Control-Allow-Methods", methods)
 
-		c.Next()
+	// Handle preflight requests
+	if c.Request.Method == "OPTIONS" {
+		c.AbortWithStatus(204)
+		return
 	}
+
+	c.Next()
 }
 
 // Logger is a custom logging middleware
@@ -73,7 +81,7 @@ func Logger() gin.HandlerFunc {
 		c.Writer.Header().Set("X-Response-Time", latency.String())
 
 		if path != "/health" || gin.Mode() == gin.DebugMode {
-			logging.L().Info("http_request",
+			logger.InfoContext(c.Request.Context(), "http_request",
 				"method", c.Request.Method,
 				"path", path,
 				"status", c.Writer.Status(),
@@ -86,10 +94,12 @@ func Logger() gin.HandlerFunc {
 
 // RateLimit middleware (simple in-memory implementation)
 func RateLimit(enabled bool, requestsPerMinute int) gin.HandlerFunc {
-	limiter := newRateLimiter(enabled, requestsPerMinute)
+	return rateLimitWith(newRateLimiter(enabled, requestsPerMinute))
+}
 
+func rateLimitWith(limiter *rateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !limiter.enabled {
+		if !limiter.isEnabled() {
 			c.Next()
 			return
 		}
@@ -102,9 +112,7 @@ func RateLimit(enabled bool, requestsPerMinute int) gin.HandlerFunc {
 
 		if !limiter.allow(c.ClientIP()) {
 			c.Header("Retry-After", "60")
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded",
-			})
+			apierror.Abort(c, http.StatusTooManyRequests, apierror.CodeRateLimited, "Rate limit exceeded")
 			return
 		}
 
@@ -164,6 +172,20 @@ func newRateLimiter(enabled bool, requestsPerMinute int) *rateLimiter {
 	}
 }
 
+// update changes the limits in place; running windows keep their counts
+func (rl *rateLimiter) update(enabled bool, requestsPerMinute int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.enabled = enabled && requestsPerMinute > 0
+	rl.requestsPerMinute = requestsPerMinute
+}
+
+func (rl *rateLimiter) isEnabled() bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.enabled
+}
+
 func (rl *rateLimiter) allow(key string) bool {
 	now := time.Now()
 
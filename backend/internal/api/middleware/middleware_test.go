@@ -1,8 +1,17 @@
 package middleware
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/telemetry"
 )
 
 func TestIsOriginAllowed(t *testing.T) {
@@ -50,3 +59,179 @@ func TestRateLimiter(t *testing.T) {
 		t.Fatalf("expected request to be allowed after window reset")
 	}
 }
+
+func TestMaintenanceBlocksMutatingRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	state := NewMaintenanceState(config.MaintenanceConfig{Enabled: true, Message: "upgrading", AllowedRoles: []string{"Admin"}})
+
+	newRouter := func(roles []string) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user", &auth.Claims{Roles: roles})
+			c.Next()
+		})
+		router.Use(Maintenance(state))
+		router.Any("/api/v1/servers", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+
+	cases := []struct {
+		method string
+		roles  []string
+		want   int
+	}{
+		{http.MethodGet, []string{"Viewer"}, http.StatusOK},
+		{http.MethodPost, []string{"Viewer"}, http.StatusServiceUnavailable},
+		{http.MethodPost, []string{"Admin"}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		newRouter(tc.roles).ServeHTTP(rec, httptest.NewRequest(tc.method, "/api/v1/servers", nil))
+		if rec.Code != tc.want {
+			t.Fatalf("%s with roles %v: expected %d, got %d", tc.method, tc.roles, tc.want, rec.Code)
+		}
+	}
+
+	state.Set(config.MaintenanceConfig{Enabled: false}, "admin")
+	rec := httptest.NewRecorder()
+	newRouter([]string{"Viewer"}).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/servers", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected request to pass once maintenance is disabled, got %d", rec.Code)
+	}
+}
+
+func TestSecurityStateFollowsReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	state := NewSecurityState(config.SecurityConfig{
+		CORS:      config.CORSConfig{AllowedOrigins: []string{"https://old.example"}},
+		RateLimit: config.RateLimitConfig{Enabled: true, RequestsPerMinute: 1},
+	})
+
+	router := gin.New()
+	router.Use(state.CORS(), state.RateLimit())
+	router.GET("/api/v1/servers", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/servers", nil)
+		req.Header.Set("Origin", "https://new.example")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send(); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("did not expect origin to be allowed before reload")
+	}
+	if rec := send(); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected second request to be rate limited, got %d", rec.Code)
+	}
+
+	state.Set(config.SecurityConfig{
+		CORS:      config.CORSConfig{AllowedOrigins: []string{"https://new.example"}},
+		RateLimit: config.RateLimitConfig{Enabled: false},
+	})
+
+	rec := send()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected rate limit to be lifted after reload, got %d", rec.Code)
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://new.example" {
+		t.Fatalf("expected reloaded origin to be allowed")
+	}
+}
+
+func TestDeprecationHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	original := versioning.Deprecations
+	versioning.Deprecations = []versioning.Deprecation{{
+		Method:    http.MethodGet,
+		Path:      "/api/v1/servers/:id",
+		Since:     "2026-01-01",
+		Sunset:    "2026-07-01",
+		Successor: "/api/v2/servers/:id",
+	}}
+	defer func() { versioning.Deprecations = original }()
+
+	router := gin.New()
+	router.Use(Deprecation())
+	router.GET("/api/v1/servers/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/v2/servers/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/servers/alpha", nil))
+	if rec.Header().Get("Deprecation") != "@1767225600" {
+		t.Fatalf("unexpected Deprecation header %q", rec.Header().Get("Deprecation"))
+	}
+	if rec.Header().Get("Sunset") != "Wed, 01 Jul 2026 00:00:00 GMT" {
+		t.Fatalf("unexpected Sunset header %q", rec.Header().Get("Sunset"))
+	}
+	if rec.Header().Get("Link") != `</api/v2/servers/:id>; rel="successor-version"` {
+		t.Fatalf("unexpected Link header %q", rec.Header().Get("Link"))
+	}
+	if rec.Header().Get(APIVersionHeader) != "v1" {
+		t.Fatalf("expected v1 version header")
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v2/servers/alpha", nil))
+	if rec.Header().Get("Deprecation") != "" || rec.Header().Get(APIVersionHeader) != "v2" {
+		t.Fatalf("did not expect v2 route to be deprecated")
+	}
+}
+
+func TestMetricsRecordsRoutePattern(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := telemetry.NewRegistry()
+	router := gin.New()
+	router.Use(Metrics(NewHTTPMetrics(registry)))
+	router.GET("/servers/:id", func(c *gin.Context) { c.Status(http.StatusInternalServerError) })
+
+	for _, id := range []string{"a", "b"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/servers/"+id, nil))
+	}
+
+	var out strings.Builder
+	registry.WriteText(&out)
+	for _, want := range []string{
+		`hsm_http_requests_total{method="GET",route="/servers/:id",status="500"} 2`,
+		`hsm_http_request_errors_total{method="GET",route="/servers/:id"} 2`,
+		`hsm_http_request_duration_seconds_count{method="GET",route="/servers/:id"} 2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRetainBodyKeepsJSONBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RetainBody())
+	router.POST("/echo", func(c *gin.Context) {
+		var req map[string]string
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		retained, _ := c.Get(gin.BodyBytesKey)
+		data, _ := retained.([]byte)
+		c.String(http.StatusOK, "%s|%s", req["name"], data)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"name":"lobby"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `lobby|{"name":"lobby"}` {
+		t.Fatalf("expected the body both bound and retained, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Other content is passed through without being kept
+	req = httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"name":"lobby"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "lobby|" {
+		t.Fatalf("expected a non-JSON body not retained, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+)
+
+// maxRetainedBody is the largest request body RetainBody keeps
+const maxRetainedBody = 1 << 20
+
+// RetainBody keeps the body of small JSON requests under gin.BodyBytesKey, so
+// a handler starting a background task can record the parameters to re-run
+// it with. The handler still reads the body as usual.
+func RetainBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := c.Request
+		if req.Body == nil || req.ContentLength <= 0 || req.ContentLength > maxRetainedBody || c.ContentType() != gin.MIMEJSON {
+			c.Next()
+			return
+		}
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			apierror.Abort(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Failed to read request body")
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		c.Set(gin.BodyBytesKey, data)
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// SecurityState holds the live CORS and rate limit settings so a config reload
+// can change them without rebuilding the router
+type SecurityState struct {
+	mu      sync.RWMutex
+	cors    config.CORSConfig
+	limiter *rateLimiter
+}
+
+// NewSecurityState creates the shared security settings from configuration
+func NewSecurityState(cfg config.SecurityConfig) *SecurityState {
+	return &SecurityState{
+		cors:    cfg.CORS,
+		limiter: newRateLimiter(cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerMinute),
+	}
+}
+
+// Set replaces the CORS policy and rate limits
+func (s *SecurityState) Set(cfg config.SecurityConfig) {
+	s.mu.Lock()
+	s.cors = cfg.CORS
+	s.mu.Unlock()
+
+	s.limiter.update(cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerMinute)
+}
+
+// CORS returns middleware applying the current CORS policy
+func (s *SecurityState) CORS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.mu.RLock()
+		cfg := s.cors
+		s.mu.RUnlock()
+
+		applyCORS(c, cfg)
+	}
+}
+
+// RateLimit returns middleware enforcing the current global rate limit
+func (s *SecurityState) RateLimit() gin.HandlerFunc {
+	return rateLimitWith(s.limiter)
+}
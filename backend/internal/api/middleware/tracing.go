@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
+)
+
+// Tracing opens a server span for each request, continuing the caller's trace
+// when a traceparent header is present. It is a no-op while tracing is off.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.ContextWithTraceParent(c.Request.Context(), c.GetHeader("traceparent"))
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+route, tracing.KindServer)
+		if span == nil {
+			c.Next()
+			return
+		}
+		defer span.End()
+
+		span.SetAttribute("http.request.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", c.Request.URL.Path)
+		span.SetAttribute("client.address", c.ClientIP())
+		c.Header("traceparent", span.TraceParent())
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if status >= 500 {
+			span.RecordError(errStatus(status))
+		}
+	}
+}
+
+type errStatus int
+
+func (e errStatus) Error() string {
+	return "HTTP " + strconv.Itoa(int(e))
+}
@@ -0,0 +1,579 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+)
+
+// Route is a single endpoint found in the router source
+type Route struct {
+	Method        string
+	Path          string // gin syntax, e.g. /api/v1/servers/:id
+	Handler       string // receiver type and method, e.g. ServerHandler.GetServer
+	Summary       string
+	Authenticated bool
+	Permission    string
+	ServerScoped  bool // permission may be granted per server
+}
+
+type group struct {
+	prefix        string
+	authenticated bool
+}
+
+type routeParser struct {
+	fset        *token.FileSet
+	handlerDocs map[string]string // "ServerHandler.GetServer" -> first doc sentence
+	handlerFunc map[string]*ast.FuncDecl
+	permissions map[string]string // constant name -> permission
+	handlerVars map[string]string // variable -> handler type
+	comments    map[int]string    // routes.go line -> comment ending on it
+	groups      map[string]group
+	routes      []Route
+}
+
+var pathParamPattern = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// ParseRoutes reads internal/api/routes.go, the handlers package and the
+// permission constants, and returns every route registered by SetupRouter
+func ParseRoutes(apiDir string) ([]Route, error) {
+	p := &routeParser{
+		fset:        token.NewFileSet(),
+		handlerDocs: map[string]string{},
+		handlerFunc: map[string]*ast.FuncDecl{},
+		permissions: map[string]string{},
+		handlerVars: map[string]string{},
+		comments:    map[int]string{},
+		groups:      map[string]group{},
+	}
+
+	if err := p.loadPermissions(filepath.Join(apiDir, "..", "permissions", "constants.go")); err != nil {
+		return nil, err
+	}
+	if err := p.loadHandlers(filepath.Join(apiDir, "handlers")); err != nil {
+		return nil, err
+	}
+
+	file, err := parser.ParseFile(p.fset, filepath.Join(apiDir, "routes.go"), nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse routes.go: %w", err)
+	}
+	for _, comment := range file.Comments {
+		p.comments[p.fset.Position(comment.End()).Line] = firstSentence(comment.Text())
+	}
+	var setup *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "SetupRouter" {
+			setup = fn
+		}
+	}
+	if setup == nil {
+		return nil, fmt.Errorf("SetupRouter not found in routes.go")
+	}
+
+	p.groups["router"] = group{}
+	p.walk(setup.Body, nil)
+
+	sort.SliceStable(p.routes, func(i, j int) bool {
+		if p.routes[i].Path != p.routes[j].Path {
+			return p.routes[i].Path < p.routes[j].Path
+		}
+		return p.routes[i].Method < p.routes[j].Method
+	})
+	return p.routes, nil
+}
+
+func (p *routeParser) loadPermissions(path string) error {
+	file, err := parser.ParseFile(p.fset, path, nil, 0)
+	if err != nil {
+		return fmt.Errorf("failed to parse permission constants: %w", err)
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				if i >= len(valueSpec.Values) {
+					continue
+				}
+				if lit, ok := valueSpec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					value, _ := strconv.Unquote(lit.Value)
+					p.permissions[name.Name] = value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *routeParser) loadHandlers(dir string) error {
+	packages, err := parser.ParseDir(p.fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse handlers: %w", err)
+	}
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+					continue
+				}
+				receiver := receiverType(fn.Recv.List[0].Type)
+				key := receiver + "." + fn.Name.Name
+				p.handlerFunc[key] = fn
+				if fn.Doc != nil {
+					p.handlerDocs[key] = firstSentence(fn.Doc.Text())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// walk visits statements in source order. groupParams maps parameter names to
+// groups when descending into a RegisterRoutes method.
+func (p *routeParser) walk(node ast.Node, groupParams map[string]group) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch stmt := n.(type) {
+		case *ast.AssignStmt:
+			p.assign(stmt, groupParams)
+		case *ast.CallExpr:
+			p.call(stmt, groupParams)
+		case *ast.FuncLit:
+			// Inline handlers and reload callbacks never register routes
+			return false
+		}
+		return true
+	})
+}
+
+func (p *routeParser) lookupGroup(name string, groupParams map[string]group) (group, bool) {
+	if g, ok := groupParams[name]; ok {
+		return g, true
+	}
+	g, ok := p.groups[name]
+	return g, ok
+}
+
+func (p *routeParser) assign(stmt *ast.AssignStmt, groupParams map[string]group) {
+	if len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
+		return
+	}
+	name, ok := stmt.Lhs[0].(*ast.Ident)
+	if !ok {
+		return
+	}
+	call, ok := stmt.Rhs[0].(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	receiver, ok := selector.X.(*ast.Ident)
+	if !ok {
+		return
+	}
+
+	switch {
+	case receiver.Name == "handlers" && strings.HasPrefix(selector.Sel.Name, "New"):
+		p.handlerVars[name.Name] = strings.TrimPrefix(selector.Sel.Name, "New")
+	case selector.Sel.Name == "Group":
+		parent, ok := p.lookupGroup(receiver.Name, groupParams)
+		if !ok || len(call.Args) == 0 {
+			return
+		}
+		p.groups[name.Name] = group{
+			prefix:        joinPath(parent.prefix, stringArg(call.Args[0])),
+			authenticated: parent.authenticated,
+		}
+	}
+}
+
+func (p *routeParser) call(call *ast.CallExpr, groupParams map[string]group) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	receiver, ok := selector.X.(*ast.Ident)
+	if !ok {
+		return
+	}
+	method := selector.Sel.Name
+
+	if method == "RegisterRoutes" {
+		p.registerRoutes(receiver.Name, call, groupParams)
+		return
+	}
+
+	g, ok := p.lookupGroup(receiver.Name, groupParams)
+	if !ok {
+		return
+	}
+
+	switch method {
+	case "Use":
+		for _, arg := range call.Args {
+			if isCall(arg, "middleware", "Auth") {
+				g.authenticated = true
+			}
+		}
+		if _, isParam := groupParams[receiver.Name]; !isParam {
+			p.groups[receiver.Name] = g
+		}
+	case "GET", "POST", "PUT", "PATCH", "DELETE":
+		if len(call.Args) < 2 {
+			return
+		}
+		route := Route{
+			Method:        method,
+			Path:          joinPath(g.prefix, stringArg(call.Args[0])),
+			Authenticated: g.authenticated,
+		}
+		for _, arg := range call.Args[1 : len(call.Args)-1] {
+			inner, ok := arg.(*ast.CallExpr)
+			if !ok || len(inner.Args) < 2 {
+				continue
+			}
+			scoped := isCall(arg, "middleware", "RequireServerPermission")
+			if !scoped && !isCall(arg, "middleware", "RequirePermission") {
+				continue
+			}
+			if constant, ok := inner.Args[1].(*ast.SelectorExpr); ok {
+				route.Permission = p.permissions[constant.Sel.Name]
+				route.ServerScoped = scoped
+			}
+		}
+		if handler, ok := call.Args[len(call.Args)-1].(*ast.SelectorExpr); ok {
+			if variable, ok := handler.X.(*ast.Ident); ok {
+				handlerType := p.handlerVars[variable.Name]
+				if handlerType == "" {
+					handlerType = variable.Name
+				}
+				route.Handler = handlerType + "." + handler.Sel.Name
+				route.Summary = p.handlerDocs[route.Handler]
+			}
+		} else {
+			// Inline handlers are described by the comment above the registration
+			route.Summary = p.comments[p.fset.Position(call.Pos()).Line-1]
+		}
+		p.routes = append(p.routes, route)
+	}
+}
+
+// registerRoutes follows handler.RegisterRoutes(group, ...) into the handler method
+func (p *routeParser) registerRoutes(variable string, call *ast.CallExpr, groupParams map[string]group) {
+	handlerType := p.handlerVars[variable]
+	fn := p.handlerFunc[handlerType+".RegisterRoutes"]
+	if fn == nil || len(call.Args) == 0 {
+		return
+	}
+	groupArg, ok := call.Args[0].(*ast.Ident)
+	if !ok {
+		return
+	}
+	g, ok := p.lookupGroup(groupArg.Name, groupParams)
+	if !ok || len(fn.Type.Params.List) == 0 || len(fn.Type.Params.List[0].Names) == 0 {
+		return
+	}
+
+	receiverName := fn.Recv.List[0].Names[0].Name
+	previous, hadPrevious := p.handlerVars[receiverName]
+	p.handlerVars[receiverName] = handlerType
+	p.walk(fn.Body, map[string]group{fn.Type.Params.List[0].Names[0].Name: g})
+	if hadPrevious {
+		p.handlerVars[receiverName] = previous
+	} else {
+		delete(p.handlerVars, receiverName)
+	}
+}
+
+// Generate builds the OpenAPI 3 document for the routes in apiDir
+func Generate(apiDir string) ([]byte, error) {
+	routes, err := ParseRoutes(apiDir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]map[string]interface{}{}
+	operationIDs := map[string]int{}
+	for _, route := range routes {
+		path := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(route.Method)] = buildOperation(route, operationIDs)
+	}
+
+	codes := make([]string, 0, len(apierror.Codes))
+	codeDescriptions := "Machine-readable error code:"
+	for _, info := range apierror.Codes {
+		codes = append(codes, info.Code)
+		codeDescriptions += fmt.Sprintf("\n- `%s` (%d): %s", info.Code, info.Status, info.Description)
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Hytale Server Manager API",
+			"version":     "v1",
+			"description": "Generated from internal/api/routes.go. Routes that list x-permission require that permission; x-permission-scope \"server\" means it may be granted for individual servers.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+			"schemas": map[string]interface{}{
+				"ErrorCode": map[string]interface{}{
+					"type":        "string",
+					"enum":        codes,
+					"description": codeDescriptions,
+				},
+				"Error": map[string]interface{}{
+					"type":        "object",
+					"description": "v1 error body",
+					"required":    []string{"error", "code"},
+					"properties": map[string]interface{}{
+						"error":      map[string]interface{}{"type": "string"},
+						"code":       map[string]interface{}{"$ref": "#/components/schemas/ErrorCode"},
+						"details":    map[string]interface{}{},
+						"request_id": map[string]interface{}{"type": "string"},
+					},
+				},
+				"ErrorEnvelope": map[string]interface{}{
+					"type":        "object",
+					"description": "v2 error body",
+					"required":    []string{"error"},
+					"properties": map[string]interface{}{
+						"error": map[string]interface{}{
+							"type":     "object",
+							"required": []string{"code", "message"},
+							"properties": map[string]interface{}{
+								"code":       map[string]interface{}{"$ref": "#/components/schemas/ErrorCode"},
+								"message":    map[string]interface{}{"type": "string"},
+								"details":    map[string]interface{}{},
+								"request_id": map[string]interface{}{"type": "string"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func buildOperation(route Route, operationIDs map[string]int) map[string]interface{} {
+	// Inline handlers have no name, so fall back to their summary or method and path
+	name := upperFirst(strings.ToLower(route.Method)) + upperFirst(routeTag(route.Path))
+	if route.Handler != "" {
+		name = route.Handler[strings.LastIndex(route.Handler, ".")+1:]
+	} else if route.Summary != "" {
+		name = ""
+		for _, word := range strings.Fields(route.Summary) {
+			name += upperFirst(strings.ToLower(word))
+		}
+	}
+	operationID := lowerFirst(name)
+	if version := versioning.FromPath(route.Path); version != "" && version != versioning.V1 {
+		operationID += strings.ToUpper(version)
+	}
+	operationIDs[operationID]++
+	if count := operationIDs[operationID]; count > 1 {
+		operationID = fmt.Sprintf("%s%d", operationID, count)
+	}
+
+	summary := route.Summary
+	if summary == "" {
+		summary = humanize(name)
+	}
+
+	errorSchema := "#/components/schemas/Error"
+	if versioning.FromPath(route.Path) == versioning.V2 {
+		errorSchema = "#/components/schemas/ErrorEnvelope"
+	}
+	errorResponse := func(description string) map[string]interface{} {
+		return map[string]interface{}{
+			"description": description,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": map[string]interface{}{"$ref": errorSchema},
+				},
+			},
+		}
+	}
+	responses := map[string]interface{}{
+		"200": map[string]interface{}{"description": "Success"},
+	}
+
+	operation := map[string]interface{}{
+		"operationId": operationID,
+		"summary":     summary,
+		"tags":        []string{routeTag(route.Path)},
+		"responses":   responses,
+	}
+
+	var parameters []interface{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	if parameters != nil {
+		operation["parameters"] = parameters
+	}
+
+	if route.Authenticated {
+		operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		responses["401"] = errorResponse("Missing or invalid access token")
+	}
+	if route.Permission != "" {
+		operation["x-permission"] = route.Permission
+		scope := "global"
+		if route.ServerScoped {
+			scope = "server"
+		}
+		operation["x-permission-scope"] = scope
+		operation["description"] = fmt.Sprintf("Requires the `%s` permission (%s scope).", route.Permission, scope)
+		responses["403"] = errorResponse("Permission denied")
+	}
+	if strings.Contains(route.Path, "/ws/") {
+		description, _ := operation["description"].(string)
+		operation["description"] = strings.TrimSpace(description + " WebSocket endpoint; upgrade the connection with a GET request.")
+		responses["101"] = map[string]interface{}{"description": "Switching protocols"}
+	}
+	if route.Method == "POST" || route.Method == "PUT" || route.Method == "PATCH" {
+		responses["400"] = errorResponse("Invalid request")
+	}
+	if deprecation, ok := versioning.Lookup(route.Method, route.Path); ok {
+		operation["deprecated"] = true
+		operation["x-sunset"] = deprecation.Sunset
+		description, _ := operation["description"].(string)
+		operation["description"] = strings.TrimSpace(fmt.Sprintf("%s Deprecated since %s and removed on %s; use `%s %s` instead.",
+			description, deprecation.Since, deprecation.Sunset, route.Method, deprecation.Successor))
+	}
+	return operation
+}
+
+func routeTag(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "api" || (i <= 1 && len(segment) == 2 && segment[0] == 'v') {
+			continue
+		}
+		if segment == "ws" && i+1 < len(segments) {
+			return segments[i+1]
+		}
+		return segment
+	}
+	return "default"
+}
+
+func receiverType(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+func isCall(expr ast.Expr, pkg, name string) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := selector.X.(*ast.Ident)
+	return ok && ident.Name == pkg && selector.Sel.Name == name
+}
+
+func stringArg(expr ast.Expr) string {
+	if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+		value, _ := strconv.Unquote(lit.Value)
+		return value
+	}
+	return ""
+}
+
+func joinPath(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimSuffix(prefix, "/") + path
+}
+
+func firstSentence(doc string) string {
+	doc = strings.TrimSpace(doc)
+	if index := strings.Index(doc, "\n"); index >= 0 {
+		doc = doc[:index]
+	}
+	return strings.TrimSuffix(strings.TrimSpace(doc), ".")
+}
+
+func humanize(name string) string {
+	var words []string
+	start := 0
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(name[i-1])) {
+			words = append(words, strings.ToLower(name[start:i]))
+			start = i
+		}
+	}
+	words = append(words, strings.ToLower(name[start:]))
+	sentence := strings.Join(words, " ")
+	return upperFirst(sentence)
+}
+
+func upperFirst(value string) string {
+	if value == "" {
+		return value
+	}
+	return strings.ToUpper(value[:1]) + value[1:]
+}
+
+func lowerFirst(value string) string {
+	if value == "" {
+		return value
+	}
+	return strings.ToLower(value[:1]) + value[1:]
+}
@@ -0,0 +1,25 @@
+// Package openapi generates and serves the OpenAPI description of the HTTP API.
+// The spec is generated from the router source so it cannot drift from the
+// registered routes; regenerate it after changing routes.go:
+//
+//	go generate ./internal/api/openapi
+package openapi
+
+import _ "embed"
+
+//go:generate go run ../../../tools/openapi-gen -api .. -out openapi.json
+
+// Spec is the generated OpenAPI 3 document
+//
+//go:embed openapi.json
+var Spec []byte
+
+// SwaggerUI is the HTML page that renders Spec
+//
+//go:embed swagger.html
+var SwaggerUI []byte
+
+// SwaggerInit boots Swagger UI; it is a separate file so the page needs no inline script
+//
+//go:embed swagger-init.js
+var SwaggerInit []byte
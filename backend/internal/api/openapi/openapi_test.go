@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSpecIsUpToDate(t *testing.T) {
+	generated, err := Generate("..")
+	if err != nil {
+		t.Fatalf("failed to generate spec: %v", err)
+	}
+	if !bytes.Equal(generated, Spec) {
+		t.Fatalf("openapi.json is stale; run go generate ./internal/api/openapi")
+	}
+}
+
+func TestSpecIncludesPermissions(t *testing.T) {
+	var document struct {
+		Paths map[string]map[string]struct {
+			Permission string `json:"x-permission"`
+			Scope      string `json:"x-permission-scope"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(Spec, &document); err != nil {
+		t.Fatalf("failed to parse spec: %v", err)
+	}
+
+	restore := document.Paths["/api/v1/servers/{id}/backups/{backupId}/restore"]["post"]
+	if restore.Permission != "servers.backups.restore" || restore.Scope != "server" {
+		t.Fatalf("unexpected permission for backup restore: %+v", restore)
+	}
+	if login := document.Paths["/api/v1/auth/login"]["post"]; login.Permission != "" {
+		t.Fatalf("did not expect login to require a permission")
+	}
+}
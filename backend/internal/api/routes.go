@@ -1,20 +1,44 @@
 package api
 
 import (
-	"log"
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/agentcert"
+	"github.com/TheGojiOG/HytaleSM/internal/agentstream"
+	"github.com/TheGojiOG/HytaleSM/internal/alerts"
+	"github.com/TheGojiOG/HytaleSM/internal/api/apierror"
 	"github.com/TheGojiOG/HytaleSM/internal/api/handlers"
 	"github.com/TheGojiOG/HytaleSM/internal/api/middleware"
 	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/autoupdate"
+	"github.com/TheGojiOG/HytaleSM/internal/backup"
+	"github.com/TheGojiOG/HytaleSM/internal/cluster"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/console"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/features"
+	"github.com/TheGojiOG/HytaleSM/internal/gamejobs"
+	"github.com/TheGojiOG/HytaleSM/internal/gitops"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
 	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/maintenance"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+	"github.com/TheGojiOG/HytaleSM/internal/notifications"
+	"github.com/TheGojiOG/HytaleSM/internal/notify"
 	"github.com/TheGojiOG/HytaleSM/internal/permissions"
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+	"github.com/TheGojiOG/HytaleSM/internal/scheduler"
+	"github.com/TheGojiOG/HytaleSM/internal/scriptlib"
+	"github.com/TheGojiOG/HytaleSM/internal/selfbackup"
 	"github.com/TheGojiOG/HytaleSM/internal/server"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/telemetry"
+	"github.com/TheGojiOG/HytaleSM/internal/watchdog"
 	"github.com/TheGojiOG/HytaleSM/internal/websocket"
 )
 
@@ -30,7 +54,14 @@ func SetupRouter(
 	logger *logging.ActivityLogger,
 	hub *websocket.Hub,
 	sessionManager *console.SessionManager,
-) (*gin.Engine, func()) {
+	selfBackups *selfbackup.Manager,
+	dbHealth *database.HealthMonitor,
+	reloader *config.Reloader,
+	metricsWriter *metrics.Writer,
+	alertEvaluator *alerts.Evaluator,
+	configHistory *config.History,
+	node *cluster.Node,
+) (*gin.Engine, func(context.Context)) {
 	// Set Gin mode based on environment
 	if cfg.Logging.Level == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -40,14 +71,27 @@ func SetupRouter(
 
 	router := gin.New()
 
+	// CORS, rate limits and the maintenance lock follow config reloads
+	security := middleware.NewSecurityState(cfg.Security)
+	maintenanceMode := middleware.NewMaintenanceState(cfg.Maintenance)
+	reloader.OnReload(func(updated *config.Config) {
+		security.Set(updated.Security)
+		maintenanceMode.Set(updated.Maintenance, "config-reload")
+	})
+
 	// Global middleware
+	router.Use(middleware.RequestID())
+	router.Use(middleware.Tracing())
+	registry := telemetry.NewRegistry()
+	router.Use(middleware.Metrics(middleware.NewHTTPMetrics(registry))) // outside Recovery so panics count as 500s
 	router.Use(gin.Recovery())
 	router.Use(middleware.Logger())
 	router.Use(middleware.Audit(db.DB))
-	router.Use(middleware.CORS(cfg.Security.CORS))
-	router.Use(middleware.RateLimit(cfg.Security.RateLimit.Enabled, cfg.Security.RateLimit.RequestsPerMinute))
+	router.Use(security.CORS())
+	router.Use(security.RateLimit())
 	router.Use(middleware.SecurityHeaders())
 	router.Use(middleware.ContentSecurityPolicy(cfg.Logging.Level == "debug"))
+	router.Use(middleware.Deprecation())
 
 	// Initialize JWT manager
 	jwtManager := auth.NewJWTManager(
@@ -58,36 +102,253 @@ func SetupRouter(
 
 	// Initialize RBAC manager
 	rbacManager := auth.NewRBACManager(db.DB)
+	apiKeys := auth.NewAPIKeyStore(db.DB)
+	passwords := auth.PasswordHasher{
+		Algorithm:  cfg.Auth.PasswordHash,
+		BcryptCost: cfg.Auth.BcryptCost,
+		Argon2:     auth.Argon2Params(cfg.Auth.Argon2),
+	}
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db.DB, jwtManager, rbacManager, cfg.Auth.BcryptCost)
-	serverHandler := handlers.NewServerHandler(cfg, db, serverManager, rbacManager, pool, lifecycle, status, process, logger, hub)
-	userHandler := handlers.NewUserHandler(db.DB, rbacManager, cfg.Auth.BcryptCost)
+	authHandler := handlers.NewAuthHandler(db.DB, jwtManager, rbacManager, passwords)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeys)
+	serverHandler := handlers.NewServerHandler(cfg, db, serverManager, rbacManager, pool, lifecycle, status, process, logger, hub, metricsWriter)
+	serverHandler.SetCluster(node)
+	serverHandler.SetRouter(router)
+	userHandler := handlers.NewUserHandler(db.DB, rbacManager, passwords)
 	backupHandler := handlers.NewBackupHandler(cfg, db.DB, pool)
 	consoleHandler := handlers.NewConsoleHandler(cfg, db.DB, hub, sessionManager, pool, rbacManager)
-	settingsHandler := handlers.NewSettingsHandler(cfg)
+	consoleHandler.SetProcessManager(process)
+	settingsHandler := handlers.NewSettingsHandler(maintenanceMode, reloader, configHistory)
+	configHistoryHandler := handlers.NewConfigHistoryHandler(configHistory, serverManager, reloader)
 	releaseHandler := handlers.NewReleaseHandler(cfg, db, logger, hub)
+	releaseHandler.SetCluster(node)
 	agentHandler := handlers.NewAgentHandler(cfg, db)
+	mailer := notify.NewSMTPSender(cfg.Notifications.SMTP)
+	passwordResetHandler := handlers.NewPasswordResetHandler(db.DB, cfg.Auth.PasswordReset, mailer, passwords)
+	var oidcProvider *auth.OIDCProvider
+	if cfg.Auth.OIDC.Enabled {
+		oidcProvider = auth.NewOIDCProvider(auth.OIDCOptions{
+			Issuer:       cfg.Auth.OIDC.Issuer,
+			ClientID:     cfg.Auth.OIDC.ClientID,
+			ClientSecret: cfg.Auth.OIDC.ClientSecret,
+			RedirectURL:  cfg.Auth.OIDC.RedirectURL,
+			Scopes:       cfg.Auth.OIDC.Scopes,
+			GroupsClaim:  cfg.Auth.OIDC.GroupsClaim,
+			FlowSecret:   cfg.Auth.JWTSecret,
+		})
+	}
+	oidcHandler := handlers.NewOIDCHandler(db.DB, cfg.Auth.OIDC, oidcProvider, jwtManager, rbacManager)
+	selfBackupHandler := handlers.NewSelfBackupHandler(selfBackups)
+	dbHealthHandler := handlers.NewDatabaseHealthHandler(dbHealth)
+	probeTimeout, _ := time.ParseDuration(cfg.Probes.Timeout)
+	probeHandler := handlers.NewProbeHandler(db, pool, cfg.Storage.ConfigDir, cfg.Probes.CheckSSH, probeTimeout)
+	docsHandler := handlers.NewDocsHandler()
+	debugHandler := handlers.NewDebugHandler()
+	iamHandler := handlers.NewIAMHandler(db.DB)
+	// Reset emails and reset token guesses are limited per client IP, each
+	// with its own budget
+	forgotPasswordLimit := middleware.RateLimit(true, cfg.Auth.PasswordReset.RequestsPerMinute)
+	passwordResetLimit := middleware.RateLimit(true, cfg.Auth.PasswordReset.RequestsPerMinute)
+
+	// Configured hooks run around starts, deploys and backups
+	hookRunner := hooks.NewRunner(cfg.Hooks, logger)
+	serverHandler.SetHooks(hookRunner)
+	backupHandler.SetHooks(hookRunner)
+	serverHandler.SetBackups(backupHandler.Manager())
+	reloader.OnReload(func(updated *config.Config) {
+		hookRunner.SetHooks(updated.Hooks)
+	})
+	registerManagerMetrics(registry, db, pool, hub, serverHandler, hookRunner)
+
+	// Notification rules send crashes, failed backups, high CPU, lost agents
+	// and finished deploys to Discord, Slack, webhooks and email
+	notificationStore := notifications.NewStore(db.DB)
+	notifier := notifications.NewDispatcher(notificationStore, mailer)
+	notificationHandler := handlers.NewNotificationHandler(notificationStore, notifier)
+	hookRunner.OnEvent(func(event hooks.Event) {
+		if notification, ok := notifications.HookEvent(event); ok {
+			notifier.Notify(notification)
+		}
+	})
+	metricsWriter.OnRecord(func(sample metrics.Sample) {
+		if notification, ok := notifications.CPUEvent(sample); ok {
+			notifier.Notify(notification)
+		}
+	})
+	alertEvaluator.OnChange(func(alert alerts.Alert) {
+		notifier.Notify(notifications.AlertEvent(alert))
+	})
+	alertHandler := handlers.NewAlertHandler(alerts.NewStore(db.DB))
+
+	// Maintenance windows stop and start servers in the background
+	maintenanceStore := maintenance.NewStore(db.DB)
+	maintenanceManager := maintenance.NewManager(maintenanceStore, serverHandler)
+	serverHandler.SetMaintenanceWindows(maintenanceManager)
+	maintenanceHandler := handlers.NewMaintenanceWindowHandler(maintenanceStore, maintenanceManager, serverManager)
+	maintenanceHandler.SetCluster(node)
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+	maintenanceManager.Start(maintenanceCtx)
+
+	// Servers that exit without being stopped are restarted and reported
+	crashWatchdog := watchdog.New(serverHandler, watchdog.SettingsFrom(cfg.Watchdog))
+	crashWatchdog.OnCrash(watchdog.EmailNotifier(mailer, func() []string { return cfg.Watchdog.AlertEmails }))
+	crashWatchdog.OnCrash(func(crash watchdog.Crash) {
+		notifier.Notify(notifications.CrashEvent(crash))
+	})
+	serverHandler.SetWatchdog(crashWatchdog)
+	reloader.OnReload(func(updated *config.Config) {
+		crashWatchdog.SetSettings(watchdog.SettingsFrom(updated.Watchdog))
+	})
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	node.OnLead(func(ctx context.Context) {
+		ctx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(watchdogCtx, cancel)
+		context.AfterFunc(ctx, func() { stop() })
+		crashWatchdog.Start(ctx)
+	})
+
+	// Long-running in-game jobs are launched and followed by the leading instance
+	gameJobManager := gamejobs.NewManager(gamejobs.NewStore(db.DB), serverHandler)
+	serverHandler.SetGameJobs(gameJobManager)
+	node.OnLead(gameJobManager.Start)
+
+	// Approved maintenance scripts run on server hosts as tasks
+	serverHandler.SetScriptLibrary(scriptlib.NewStore(db.DB))
+
+	// Agents installed with agent_push on stream their events to this instance
+	streamCtx, stopStreams := context.WithCancel(context.Background())
+	if features.Enabled(features.AgentPush) {
+		ca, err := agentcert.LoadOrCreateCA(filepath.Join(cfg.Storage.DataDir, "agent-ca"))
+		if err != nil {
+			logging.For("api").Error("Agent event streams disabled: failed to load agent CA", "error", err)
+		} else {
+			agentStreams := agentstream.NewServer(db.DB, ca)
+			serverHandler.SetAgentStreams(agentStreams)
+			agentStreams.OnDisconnect(func(agent agentstream.Agent) {
+				for _, notification := range notifications.AgentOfflineEvents(agent) {
+					notifier.Notify(notification)
+				}
+			})
+			go func() {
+				if err := agentStreams.ListenAndServe(streamCtx, cfg.Agents.StreamAddr); err != nil {
+					logging.For("api").Error("Agent event streams stopped", "error", err)
+				}
+			}()
+		}
+	}
+
+	// Servers marked auto_start come up in start_after order, once, on the
+	// first instance to lead after it started
+	var autoStart sync.Once
+	node.OnLead(func(context.Context) {
+		autoStart.Do(serverHandler.AutoStartServers)
+	})
+
+	// Scheduled tasks, including backup schedules, run in the background on the
+	// leading instance
+	scheduleStore := scheduler.NewStore(db.DB)
+	taskScheduler := scheduler.NewScheduler(scheduleStore)
+	taskScheduler.Register(scheduler.TypeCommand, serverHandler.ScheduledCommand)
+	taskScheduler.Register(scheduler.TypeRestart, serverHandler.ScheduledRestart)
+	taskScheduler.Register(scheduler.TypeStop, serverHandler.ScheduledStop)
+	taskScheduler.Register(scheduler.TypeStart, serverHandler.ScheduledStart)
+	taskScheduler.Register(scheduler.TypeScript, serverHandler.ScheduledScript)
+	backupRunner := backup.NewScheduleRunner(cfg, db.DB, pool)
+	backupRunner.SetHooks(hookRunner)
+	taskScheduler.Register(scheduler.TypeBackup, handlers.BackupExecutor(backupRunner))
+	taskScheduler.Register(scheduler.TypeWebhook, scheduler.WebhookExecutor(nil))
+	node.OnLead(taskScheduler.Start)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleStore, taskScheduler, backup.NewScheduleStore(db.DB), serverManager)
+	applyHandler := handlers.NewApplyHandler(serverHandler, scheduleHandler, maintenanceHandler)
+
+	// Auto-update policies deploy new releases while maintenance windows are
+	// open, on the leading instance
+	autoUpdateStore := autoupdate.NewStore(db.DB)
+	autoUpdater := autoupdate.NewManager(autoUpdateStore, releases.NewManager(cfg, db), serverHandler, maintenanceManager.OpenWindows)
+	autoUpdater.SetBackup(func(serverID string) (string, error) {
+		return backupRunner.RunSchedule(serverID, "")
+	})
+	autoUpdateCtx, stopAutoUpdates := context.WithCancel(context.Background())
+	node.OnLead(func(ctx context.Context) {
+		ctx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(autoUpdateCtx, cancel)
+		context.AfterFunc(ctx, func() { stop() })
+		autoUpdater.Start(ctx)
+	})
+	autoUpdateHandler := handlers.NewAutoUpdateHandler(autoUpdateStore, autoUpdater, maintenanceStore, serverManager)
+
+	// Server definitions and schedules follow a Git repository, synced by the
+	// leading instance
+	var gitRepo *gitops.Repo
+	if cfg.GitOps.Enabled {
+		gitRepo = gitops.NewRepo(filepath.Join(cfg.Storage.DataDir, "gitops"), cfg.GitOps)
+	}
+	gitOpsHandler := handlers.NewGitOpsHandler(gitRepo, applyHandler, db.DB)
+	reportsHandler := handlers.NewReportsHandler(db.DB, serverManager)
+	grafanaHandler := handlers.NewGrafanaHandler(db.DB, cfg, serverManager)
+	if cfg.GitOps.Enabled {
+		gitOpsInterval, _ := time.ParseDuration(cfg.GitOps.Interval)
+		node.OnLead(func(ctx context.Context) {
+			gitOpsHandler.Start(ctx, gitOpsInterval)
+		})
+	}
+
+	// Server status is checked in the background and served from memory
+	serverHandler.StartStatusRefresher(time.Duration(cfg.Metrics.StatusInterval) * time.Second)
+	reloader.OnReload(func(updated *config.Config) {
+		serverHandler.SetStatusInterval(time.Duration(updated.Metrics.StatusInterval) * time.Second)
+		serverHandler.SetLiveMetricsLimits(updated.Metrics.LiveConcurrency, time.Duration(updated.Metrics.LiveTimeout)*time.Second)
+	})
+	if cfg.Drift.Enabled {
+		driftInterval, _ := time.ParseDuration(cfg.Drift.Interval)
+		node.OnLead(func(ctx context.Context) {
+			serverHandler.StartDriftDetector(ctx, driftInterval)
+		})
+	}
+	// Agent certificates are renewed before they expire; "0" leaves it to the operator
+	if certRenewBefore, _ := time.ParseDuration(cfg.Agents.CertRenewBefore); certRenewBefore > 0 {
+		node.OnLead(func(ctx context.Context) {
+			serverHandler.StartAgentCertRenewal(ctx, certRenewBefore)
+		})
+	}
+	serverHandler.SetSecurityAlerts(mailer)
+	if cfg.HostSecurity.Enabled {
+		securityInterval, _ := time.ParseDuration(cfg.HostSecurity.Interval)
+		node.OnLead(func(ctx context.Context) {
+			serverHandler.StartHostSecurityMonitor(ctx, securityInterval)
+		})
+	}
 
 	// Public routes
 	public := router.Group("/api/v1")
 	{
 		public.GET("/auth/setup-status", authHandler.SetupStatus)
 		public.POST("/auth/setup", authHandler.SetupInitialAdmin)
-		public.POST("/auth/register", authHandler.Register)
+		public.POST("/auth/register", middleware.Maintenance(maintenanceMode), authHandler.Register)
 		public.POST("/auth/login", authHandler.Login)
 		public.POST("/auth/refresh", authHandler.RefreshToken)
+		public.POST("/auth/password/forgot", forgotPasswordLimit, passwordResetHandler.RequestPasswordReset)
+		public.POST("/auth/password/reset", passwordResetLimit, passwordResetHandler.ResetPassword)
+		public.GET("/auth/oidc", oidcHandler.Status)
+		public.GET("/auth/oidc/login", oidcHandler.Login)
+		public.GET("/auth/oidc/callback", oidcHandler.Callback)
 		public.POST("/agents/cert-issue", agentHandler.IssueCertificate)
 		public.GET("/agents/binary", agentHandler.DownloadBinary)
 	}
 
 	// Protected routes
 	protected := router.Group("/api/v1")
-	protected.Use(middleware.Auth(jwtManager))
+	protected.Use(middleware.Auth(jwtManager, apiKeys))
+	protected.Use(middleware.Maintenance(maintenanceMode))
+	protected.Use(middleware.RetainBody())
 	{
 		// Auth routes
 		protected.POST("/auth/logout", authHandler.Logout)
 		protected.GET("/auth/me", authHandler.GetCurrentUser)
+		protected.GET("/auth/api-keys", apiKeyHandler.ListAPIKeys)
+		protected.POST("/auth/api-keys", apiKeyHandler.CreateAPIKey)
+		protected.DELETE("/auth/api-keys/:keyId", apiKeyHandler.RevokeAPIKey)
 
 		// Server routes
 		servers := protected.Group("/servers")
@@ -99,21 +360,82 @@ func SetupRouter(
 			servers.DELETE(":id", middleware.RequirePermission(rbacManager, permissions.ServersDelete), serverHandler.DeleteServer)
 			servers.POST(":id/test-connection", middleware.RequireServerPermission(rbacManager, permissions.ServersTestConnection), serverHandler.TestConnection)
 			servers.GET(":id/metrics", middleware.RequireServerPermission(rbacManager, permissions.ServersMetricsRead), serverHandler.GetMetrics)
+			servers.GET(":id/metrics/jvm", middleware.RequireServerPermission(rbacManager, permissions.ServersMetricsRead), serverHandler.GetJVMMetrics)
 			servers.GET(":id/activity", middleware.RequireServerPermission(rbacManager, permissions.ServersActivityRead), serverHandler.GetServerActivity)
 			servers.GET(":id/tasks", middleware.RequireServerPermission(rbacManager, permissions.ServersTasksRead), serverHandler.GetServerTasks)
+			servers.GET(":id/tasks/:taskId/log", middleware.RequireServerPermission(rbacManager, permissions.ServersTasksRead), serverHandler.GetServerTaskLog)
+			// A re-run replays the request that started the task, authorized like the original
+			servers.POST(":id/tasks/:taskId/rerun", middleware.RequireServerPermission(rbacManager, permissions.ServersTasksRead), serverHandler.RerunServerTask)
+			servers.GET(":id/tasks/events", middleware.RequireServerPermission(rbacManager, permissions.ServersTasksRead), serverHandler.StreamServerTasks)
 			servers.GET("/metrics/latest", middleware.RequirePermission(rbacManager, permissions.ServersMetricsLatest), serverHandler.GetLatestMetrics)
 			servers.GET("/metrics/live", middleware.RequirePermission(rbacManager, permissions.ServersMetricsLive), serverHandler.GetLiveMetrics)
+			servers.GET("/export", middleware.RequirePermission(rbacManager, permissions.ServersExport), serverHandler.ExportServers)
+			servers.POST("/import", middleware.RequirePermission(rbacManager, permissions.ServersImport), serverHandler.ImportServers)
+			servers.POST("/import/panel", middleware.RequirePermission(rbacManager, permissions.ServersImport), serverHandler.ImportFromPanel)
 			servers.GET(":id/node-exporter/status", middleware.RequireServerPermission(rbacManager, permissions.ServersNodeExporterStatus), serverHandler.GetNodeExporterStatus)
 			servers.POST(":id/node-exporter/install", middleware.RequireServerPermission(rbacManager, permissions.ServersNodeExporterInstall), serverHandler.InstallNodeExporter)
 
+			servers.POST("/start", middleware.RequirePermission(rbacManager, permissions.ServersStart), serverHandler.BulkStart)
+			servers.POST("/batch/:action", serverHandler.BatchServers)
+			servers.GET("/batch/:batchId", serverHandler.GetBatch)
 			servers.POST(":id/start", middleware.RequireServerPermission(rbacManager, permissions.ServersStart), serverHandler.StartServer)
 			servers.POST(":id/stop", middleware.RequireServerPermission(rbacManager, permissions.ServersStop), serverHandler.StopServer)
 			servers.POST(":id/restart", middleware.RequireServerPermission(rbacManager, permissions.ServersRestart), serverHandler.RestartServer)
 			servers.GET(":id/status", middleware.RequireServerPermission(rbacManager, permissions.ServersStatusRead), serverHandler.GetServerStatus)
+			servers.GET(":id/drift", middleware.RequireServerPermission(rbacManager, permissions.ServersDriftRead), serverHandler.GetServerDrift)
+			servers.GET(":id/ssh/hostkey", middleware.RequireServerPermission(rbacManager, permissions.ServersSSHHostKeyRead), serverHandler.GetSSHHostKey)
+			servers.POST(":id/ssh/hostkey", middleware.RequireServerPermission(rbacManager, permissions.ServersSSHHostKeyManage), serverHandler.UpdateSSHHostKey)
+			servers.POST(":id/ssh/rotate-key", middleware.RequireServerPermission(rbacManager, permissions.ServersSSHKeyRotate), serverHandler.RotateSSHKey)
+			servers.GET(":id/ssh/connection", middleware.RequireServerPermission(rbacManager, permissions.ServersStatusRead), serverHandler.GetSSHConnection)
+			servers.POST(":id/ssh/connection/close", middleware.RequireServerPermission(rbacManager, permissions.ServersSSHConnectionManage), serverHandler.CloseSSHConnection)
+			servers.POST(":id/ssh/connection/reconnect", middleware.RequireServerPermission(rbacManager, permissions.ServersSSHConnectionManage), serverHandler.ReconnectSSHConnection)
+			servers.GET(":id/security", middleware.RequireServerPermission(rbacManager, permissions.SecurityHostsRead), serverHandler.GetServerSecurity)
+			servers.GET(":id/watchdog", middleware.RequireServerPermission(rbacManager, permissions.ServersStatusRead), serverHandler.GetWatchdogState)
+			servers.POST(":id/watchdog/reset", middleware.RequireServerPermission(rbacManager, permissions.ServersRestart), serverHandler.ResetWatchdog)
 			servers.POST(":id/command", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleExecute), serverHandler.ExecuteCommand)
+			servers.GET(":id/whitelist", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersRead), serverHandler.GetWhitelist)
+			servers.PUT(":id/whitelist", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.UpdateWhitelist)
+			servers.GET(":id/bans", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersRead), serverHandler.GetBans)
+			servers.PUT(":id/bans", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.UpdateBans)
+			servers.POST(":id/whitelist/add", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.WhitelistAddPlayer)
+			servers.POST(":id/whitelist/remove", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.WhitelistRemovePlayer)
+			servers.GET(":id/players", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersRead), serverHandler.ListPlayers)
+			servers.POST(":id/players/kick", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.KickPlayer)
+			servers.POST(":id/players/ban", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.BanPlayer)
+			servers.POST(":id/players/unban", middleware.RequireServerPermission(rbacManager, permissions.ServersPlayersManage), serverHandler.UnbanPlayer)
+			servers.GET(":id/config", middleware.RequireServerPermission(rbacManager, permissions.ServersGet), serverHandler.GetServerConfig)
+			servers.PUT(":id/config", middleware.RequirePermission(rbacManager, permissions.ServersUpdate), serverHandler.UpdateServerConfig)
+			servers.GET(":id/files", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesView), serverHandler.ListFiles)
+			servers.DELETE(":id/files", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesEdit), serverHandler.DeleteFile)
+			servers.GET(":id/files/content", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesView), serverHandler.GetFileContent)
+			servers.PUT(":id/files/content", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesEdit), serverHandler.UpdateFileContent)
+			servers.GET(":id/files/download", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesView), serverHandler.DownloadFile)
+			servers.POST(":id/files/upload", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesEdit), serverHandler.UploadFile)
+			servers.POST(":id/files/mkdir", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesEdit), serverHandler.MakeDirectory)
+			servers.POST(":id/files/rename", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesEdit), serverHandler.RenameFile)
+			servers.POST(":id/files/chmod", middleware.RequireServerPermission(rbacManager, permissions.ServersFilesEdit), serverHandler.ChmodFile)
+			servers.GET(":id/worlds", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsView), serverHandler.ListWorlds)
+			servers.POST(":id/worlds/upload", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsManage), serverHandler.UploadWorld)
+			servers.POST(":id/worlds/snapshot", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsManage), serverHandler.SnapshotWorld)
+			servers.GET(":id/worlds/:world/download", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsView), serverHandler.DownloadWorld)
+			servers.POST(":id/worlds/:world/duplicate", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsManage), serverHandler.DuplicateWorld)
+			servers.POST(":id/worlds/:world/transfer", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsView), serverHandler.TransferWorld)
+			servers.DELETE(":id/worlds/:world", middleware.RequireServerPermission(rbacManager, permissions.ServersWorldsManage), serverHandler.DeleteWorld)
+			servers.GET(":id/jobs", middleware.RequireServerPermission(rbacManager, permissions.ServersTasksRead), serverHandler.ListGameJobs)
+			servers.POST(":id/jobs", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleExecute), serverHandler.CreateGameJob)
+			servers.GET(":id/jobs/:jobId", middleware.RequireServerPermission(rbacManager, permissions.ServersTasksRead), serverHandler.GetGameJob)
+			servers.POST(":id/jobs/:jobId/pause", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleExecute), serverHandler.PauseGameJob)
+			servers.POST(":id/jobs/:jobId/resume", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleExecute), serverHandler.ResumeGameJob)
+			servers.POST(":id/jobs/:jobId/cancel", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleExecute), serverHandler.CancelGameJob)
+			servers.GET(":id/schedules", middleware.RequireServerPermission(rbacManager, permissions.SchedulesRead), scheduleHandler.ListServerSchedules)
+			servers.POST(":id/schedules", middleware.RequireServerPermission(rbacManager, permissions.SchedulesManage), scheduleHandler.CreateServerSchedule)
+			servers.GET(":id/schedules/:scheduleId", middleware.RequireServerPermission(rbacManager, permissions.SchedulesRead), scheduleHandler.GetServerSchedule)
+			servers.PUT(":id/schedules/:scheduleId", middleware.RequireServerPermission(rbacManager, permissions.SchedulesManage), scheduleHandler.UpdateServerSchedule)
+			servers.DELETE(":id/schedules/:scheduleId", middleware.RequireServerPermission(rbacManager, permissions.SchedulesManage), scheduleHandler.DeleteServerSchedule)
 
 			// Backup routes under specific server
 			backupHandler.RegisterRoutes(servers, rbacManager)
+			servers.POST(":id/backups/restore", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsRestore), serverHandler.RestoreServerBackup)
 		}
 
 		// User management routes
@@ -130,18 +452,134 @@ func SetupRouter(
 		// Console routes
 		protected.GET("/servers/:id/console/history", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleHistoryRead), consoleHandler.GetCommandHistory)
 		protected.GET("/servers/:id/console/history/search", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleHistorySearch), consoleHandler.SearchCommandHistory)
+		protected.GET("/servers/:id/console/search", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleHistorySearch), consoleHandler.SearchConsoleLogs)
 		protected.GET("/servers/:id/console/autocomplete", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleAutocomplete), consoleHandler.GetAutocomplete)
 		protected.POST("/servers/:id/dependencies/install", middleware.RequireServerPermission(rbacManager, permissions.ServersDependenciesInstall), serverHandler.InstallDependencies)
 		protected.POST("/servers/:id/agent/install", middleware.RequireServerPermission(rbacManager, permissions.ServersAgentInstall), serverHandler.InstallAgent)
 		protected.GET("/servers/:id/agent/state", middleware.RequireServerPermission(rbacManager, permissions.ServersAgentStateRead), serverHandler.GetAgentState)
+		protected.GET("/servers/:id/agent/certs", middleware.RequireServerPermission(rbacManager, permissions.ServersAgentStateRead), serverHandler.ListAgentCertificates)
+		protected.POST("/servers/:id/agent/certs/renew", middleware.RequireServerPermission(rbacManager, permissions.ServersAgentInstall), serverHandler.RenewAgentCertificates)
+		protected.POST("/servers/:id/agent/certs/:serial/revoke", middleware.RequireServerPermission(rbacManager, permissions.ServersAgentInstall), serverHandler.RevokeAgentCertificate)
+		protected.GET("/agents/streams", middleware.RequirePermission(rbacManager, permissions.ServersAgentStateRead), serverHandler.ListAgentStreams)
 		protected.POST("/servers/:id/processes/kill", middleware.RequireServerPermission(rbacManager, permissions.ServersProcessKill), serverHandler.KillProcess)
 		protected.GET("/servers/:id/dependencies/check", middleware.RequireServerPermission(rbacManager, permissions.ServersDependenciesCheck), serverHandler.CheckDependencies)
 		protected.POST("/servers/:id/releases/deploy", middleware.RequireServerPermission(rbacManager, permissions.ServersReleaseDeploy), serverHandler.DeployRelease)
+		protected.POST("/servers/:id/deploy/rollback", middleware.RequireServerPermission(rbacManager, permissions.ServersReleaseRollback), serverHandler.RollbackRelease)
+		protected.GET("/servers/:id/deployments", middleware.RequireServerPermission(rbacManager, permissions.ServersDeploymentsRead), serverHandler.ListDeployments)
 		protected.POST("/servers/:id/transfer/benchmark", middleware.RequireServerPermission(rbacManager, permissions.ServersTransferBenchmark), serverHandler.StartTransferBenchmark)
 
 		// Settings routes
 		protected.GET("/settings", middleware.RequirePermission(rbacManager, permissions.SettingsGet), settingsHandler.GetSettings)
 		protected.PUT("/settings", middleware.RequirePermission(rbacManager, permissions.SettingsUpdate), settingsHandler.UpdateSettings)
+		protected.GET("/settings/maintenance", middleware.RequirePermission(rbacManager, permissions.SettingsGet), settingsHandler.GetMaintenance)
+		protected.PUT("/settings/maintenance", middleware.RequirePermission(rbacManager, permissions.SettingsUpdate), settingsHandler.UpdateMaintenance)
+
+		// Manager self-backup and database routes
+		system := protected.Group("/system")
+		{
+			// Every signed-in user may read the feature flags; the frontend hides what is off
+			system.GET("/features", settingsHandler.GetFeatures)
+			system.GET("/backups", middleware.RequirePermission(rbacManager, permissions.SystemBackupsList), selfBackupHandler.ListSnapshots)
+			system.POST("/backups", middleware.RequirePermission(rbacManager, permissions.SystemBackupsCreate), selfBackupHandler.CreateSnapshot)
+			system.GET("/backups/:name/download", middleware.RequirePermission(rbacManager, permissions.SystemBackupsDownload), selfBackupHandler.DownloadSnapshot)
+			system.POST("/backups/:name/verify", middleware.RequirePermission(rbacManager, permissions.SystemBackupsList), selfBackupHandler.VerifySnapshot)
+			system.DELETE("/backups/:name", middleware.RequirePermission(rbacManager, permissions.SystemBackupsDelete), selfBackupHandler.DeleteSnapshot)
+			system.GET("/db", middleware.RequirePermission(rbacManager, permissions.SystemDatabaseRead), dbHealthHandler.GetHealth)
+			system.POST("/db/maintenance", middleware.RequirePermission(rbacManager, permissions.SystemDatabaseMaintain), dbHealthHandler.RunMaintenance)
+			system.GET("/ssh/connections", middleware.RequirePermission(rbacManager, permissions.SystemSSHConnectionsRead), serverHandler.ListSSHConnections)
+			system.POST("/config/reload", middleware.RequirePermission(rbacManager, permissions.SystemConfigReload), settingsHandler.ReloadConfig)
+			system.GET("/config/versions", middleware.RequirePermission(rbacManager, permissions.SystemConfigHistoryRead), configHistoryHandler.ListVersions)
+			system.GET("/config/versions/:file/:version", middleware.RequirePermission(rbacManager, permissions.SystemConfigHistoryRead), configHistoryHandler.GetVersion)
+			system.POST("/config/versions/:file/:version/restore", middleware.RequirePermission(rbacManager, permissions.SystemConfigRestore), configHistoryHandler.RestoreVersion)
+			system.GET("/logging", middleware.RequirePermission(rbacManager, permissions.SystemLoggingRead), settingsHandler.GetLogLevels)
+			system.PUT("/logging", middleware.RequirePermission(rbacManager, permissions.SystemLoggingUpdate), settingsHandler.UpdateLogLevels)
+			system.GET("/gitops", middleware.RequirePermission(rbacManager, permissions.SystemGitOpsRead), gitOpsHandler.GetStatus)
+			system.POST("/gitops/sync", middleware.RequirePermission(rbacManager, permissions.SystemGitOpsSync), gitOpsHandler.SyncNow)
+		}
+
+		// Declarative apply of servers, schedules and maintenance windows
+		protected.POST("/apply", middleware.RequirePermission(rbacManager, permissions.SystemApply), applyHandler.Apply)
+
+		// Search across everything the caller may see; each type is filtered by its own permission
+		protected.GET("/search", serverHandler.Search)
+
+		// Dashboard summary; like search, each part is filtered by its own permission
+		protected.GET("/dashboard", serverHandler.GetDashboard)
+
+		// Per-server usage reports
+		protected.GET("/reports/usage", middleware.RequirePermission(rbacManager, permissions.ReportsUsageRead), reportsHandler.GetUsage)
+
+		// Server metrics for Grafana's JSON datasource
+		grafana := protected.Group("/grafana")
+		grafana.Use(middleware.RequirePermission(rbacManager, permissions.ServersMetricsRead))
+		{
+			grafana.GET("", grafanaHandler.TestDatasource)
+			grafana.POST("/search", grafanaHandler.Search)
+			grafana.POST("/metrics", grafanaHandler.Metrics)
+			grafana.POST("/query", grafanaHandler.Query)
+		}
+		protected.GET("/security/hosts", middleware.RequirePermission(rbacManager, permissions.SecurityHostsRead), serverHandler.ListHostSecurity)
+
+		// Scheduled task routes
+		schedules := protected.Group("/schedules")
+		{
+			schedules.GET("", middleware.RequirePermission(rbacManager, permissions.SchedulesRead), scheduleHandler.ListSchedules)
+			schedules.POST("", middleware.RequirePermission(rbacManager, permissions.SchedulesManage), scheduleHandler.CreateSchedule)
+			schedules.GET("/:id", middleware.RequirePermission(rbacManager, permissions.SchedulesRead), scheduleHandler.GetSchedule)
+			schedules.PUT("/:id", middleware.RequirePermission(rbacManager, permissions.SchedulesManage), scheduleHandler.UpdateSchedule)
+			schedules.DELETE("/:id", middleware.RequirePermission(rbacManager, permissions.SchedulesManage), scheduleHandler.DeleteSchedule)
+			schedules.POST("/:id/run", middleware.RequirePermission(rbacManager, permissions.SchedulesManage), scheduleHandler.RunSchedule)
+			schedules.GET("/:id/runs", middleware.RequirePermission(rbacManager, permissions.SchedulesRead), scheduleHandler.ListScheduleRuns)
+		}
+
+		// Script library routes; runs stream through the task WebSocket of a server on each host
+		scripts := protected.Group("/scripts")
+		{
+			scripts.GET("", middleware.RequirePermission(rbacManager, permissions.ScriptsRun), serverHandler.ListScripts)
+			scripts.POST("", middleware.RequirePermission(rbacManager, permissions.ScriptsManage), serverHandler.CreateScript)
+			scripts.GET("/runs", middleware.RequirePermission(rbacManager, permissions.ScriptsRun), serverHandler.ListScriptRuns)
+			scripts.GET("/runs/:runId", middleware.RequirePermission(rbacManager, permissions.ScriptsRun), serverHandler.GetScriptRun)
+			scripts.GET("/:id", middleware.RequirePermission(rbacManager, permissions.ScriptsRun), serverHandler.GetScript)
+			scripts.PUT("/:id", middleware.RequirePermission(rbacManager, permissions.ScriptsManage), serverHandler.UpdateScript)
+			scripts.DELETE("/:id", middleware.RequirePermission(rbacManager, permissions.ScriptsManage), serverHandler.DeleteScript)
+			scripts.POST("/:id/approve", middleware.RequirePermission(rbacManager, permissions.ScriptsManage), serverHandler.ApproveScript)
+			scripts.POST("/:id/run", middleware.RequirePermission(rbacManager, permissions.ScriptsRun), serverHandler.RunScript)
+		}
+
+		// Metric alert rules and the alerts they raised
+		alertRoutes := protected.Group("/alerts")
+		{
+			alertRoutes.GET("", middleware.RequirePermission(rbacManager, permissions.AlertsView), alertHandler.ListAlerts)
+			alertRoutes.GET("/rules", middleware.RequirePermission(rbacManager, permissions.AlertsView), alertHandler.ListAlertRules)
+			alertRoutes.POST("/rules", middleware.RequirePermission(rbacManager, permissions.AlertsManage), alertHandler.CreateAlertRule)
+			alertRoutes.PUT("/rules/:id", middleware.RequirePermission(rbacManager, permissions.AlertsManage), alertHandler.UpdateAlertRule)
+			alertRoutes.DELETE("/rules/:id", middleware.RequirePermission(rbacManager, permissions.AlertsManage), alertHandler.DeleteAlertRule)
+		}
+
+		// Notification channels and the rules that send events to them
+		notificationRoutes := protected.Group("/notifications")
+		{
+			notificationRoutes.GET("/channels", middleware.RequirePermission(rbacManager, permissions.NotificationsView), notificationHandler.ListChannels)
+			notificationRoutes.POST("/channels", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.CreateChannel)
+			notificationRoutes.PUT("/channels/:id", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.UpdateChannel)
+			notificationRoutes.DELETE("/channels/:id", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.DeleteChannel)
+			notificationRoutes.POST("/channels/:id/test", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.TestChannel)
+			notificationRoutes.GET("/rules", middleware.RequirePermission(rbacManager, permissions.NotificationsView), notificationHandler.ListRules)
+			notificationRoutes.POST("/rules", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.CreateRule)
+			notificationRoutes.PUT("/rules/:id", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.UpdateRule)
+			notificationRoutes.DELETE("/rules/:id", middleware.RequirePermission(rbacManager, permissions.NotificationsManage), notificationHandler.DeleteRule)
+			notificationRoutes.GET("/deliveries", middleware.RequirePermission(rbacManager, permissions.NotificationsView), notificationHandler.ListDeliveries)
+		}
+
+		// Maintenance window routes
+		maintenanceWindows := protected.Group("/maintenance-windows")
+		{
+			maintenanceWindows.GET("", middleware.RequirePermission(rbacManager, permissions.MaintenanceWindowsRead), maintenanceHandler.ListMaintenanceWindows)
+			maintenanceWindows.POST("", middleware.RequirePermission(rbacManager, permissions.MaintenanceWindowsManage), maintenanceHandler.CreateMaintenanceWindow)
+			maintenanceWindows.GET("/:id", middleware.RequirePermission(rbacManager, permissions.MaintenanceWindowsRead), maintenanceHandler.GetMaintenanceWindow)
+			maintenanceWindows.PUT("/:id", middleware.RequirePermission(rbacManager, permissions.MaintenanceWindowsManage), maintenanceHandler.UpdateMaintenanceWindow)
+			maintenanceWindows.DELETE("/:id", middleware.RequirePermission(rbacManager, permissions.MaintenanceWindowsManage), maintenanceHandler.DeleteMaintenanceWindow)
+		}
 
 		// Releases routes
 		releases := protected.Group("/releases")
@@ -152,6 +590,7 @@ func SetupRouter(
 			releases.GET("/jobs", middleware.RequirePermission(rbacManager, permissions.ReleasesJobsList), releaseHandler.ListJobs)
 			releases.GET("/jobs/:id", middleware.RequirePermission(rbacManager, permissions.ReleasesJobsGet), releaseHandler.GetJob)
 			releases.POST("/download", middleware.RequirePermission(rbacManager, permissions.ReleasesDownload), releaseHandler.DownloadRelease)
+			releases.POST("/upload", middleware.RequirePermission(rbacManager, permissions.ReleasesUpload), releaseHandler.UploadRelease)
 			releases.POST("/downloader/init", middleware.RequirePermission(rbacManager, permissions.ReleasesDownload), releaseHandler.InitDownloader)
 			releases.POST("/print-version", middleware.RequirePermission(rbacManager, permissions.ReleasesPrintVersion), releaseHandler.PrintVersion)
 			releases.POST("/check-update", middleware.RequirePermission(rbacManager, permissions.ReleasesCheckUpdate), releaseHandler.CheckUpdate)
@@ -161,8 +600,18 @@ func SetupRouter(
 			releases.POST("/reset-auth", middleware.RequirePermission(rbacManager, permissions.ReleasesResetAuth), releaseHandler.ResetDownloaderAuth)
 		}
 
+		// Auto-update policy routes
+		autoUpdate := protected.Group("/auto-update")
+		{
+			autoUpdate.GET("/policies", middleware.RequirePermission(rbacManager, permissions.ReleasesAutoUpdateRead), autoUpdateHandler.ListAutoUpdatePolicies)
+			autoUpdate.POST("/policies", middleware.RequirePermission(rbacManager, permissions.ReleasesAutoUpdateManage), autoUpdateHandler.CreateAutoUpdatePolicy)
+			autoUpdate.GET("/policies/:id", middleware.RequirePermission(rbacManager, permissions.ReleasesAutoUpdateRead), autoUpdateHandler.GetAutoUpdatePolicy)
+			autoUpdate.PUT("/policies/:id", middleware.RequirePermission(rbacManager, permissions.ReleasesAutoUpdateManage), autoUpdateHandler.UpdateAutoUpdatePolicy)
+			autoUpdate.DELETE("/policies/:id", middleware.RequirePermission(rbacManager, permissions.ReleasesAutoUpdateManage), autoUpdateHandler.DeleteAutoUpdatePolicy)
+			autoUpdate.GET("/runs", middleware.RequirePermission(rbacManager, permissions.ReleasesAutoUpdateRead), autoUpdateHandler.ListAutoUpdateRuns)
+		}
+
 		// IAM routes (roles/permissions)
-		iamHandler := handlers.NewIAMHandler(db.DB)
 		iam := protected.Group("/iam")
 		{
 			iam.GET("/permissions", middleware.RequirePermission(rbacManager, permissions.IAMPermissionsList), iamHandler.ListPermissions)
@@ -173,23 +622,81 @@ func SetupRouter(
 			iam.DELETE("/roles/:id", middleware.RequirePermission(rbacManager, permissions.IAMRolesDelete), iamHandler.DeleteRole)
 			iam.PUT("/roles/:id/permissions", middleware.RequirePermission(rbacManager, permissions.IAMRolesPermissionsUpdate), iamHandler.SetRolePermissions)
 			iam.GET("/audit-logs", middleware.RequirePermission(rbacManager, permissions.IAMAuditLogsList), iamHandler.ListAuditLogs)
+			iam.GET("/audit-logs/:id", middleware.RequirePermission(rbacManager, permissions.IAMAuditLogsList), iamHandler.GetAuditLog)
 		}
 
 		// WebSocket routes (authentication handled in handler)
 		protected.GET("/ws/console/:id", consoleHandler.HandleConsoleWebSocket)
+		protected.GET("/ws/servers/batch/:batchId", serverHandler.HandleBatchWebSocket)
 		protected.GET("/ws/servers/:id/tasks", middleware.RequireServerPermission(rbacManager, permissions.ServersTransferBenchmark), serverHandler.HandleServerTasksWebSocket)
 		protected.GET("/ws/releases/jobs/:id", middleware.RequirePermission(rbacManager, permissions.ReleasesJobsStream), releaseHandler.HandleReleaseJobWebSocket)
 	}
 
+	// v2 routes. Only endpoints whose request or response shape changed are
+	// registered here; everything else is served by its v1 route through
+	// VersionFallback. Add the superseded v1 route to versioning.Deprecations.
+	protectedV2 := router.Group("/api/v2")
+	protectedV2.Use(middleware.Auth(jwtManager, apiKeys))
+	protectedV2.Use(middleware.Maintenance(maintenanceMode))
+	{
+		// Listings return a data/pagination envelope
+		protectedV2.GET("/servers/:id/metrics", middleware.RequireServerPermission(rbacManager, permissions.ServersMetricsRead), serverHandler.GetMetrics)
+		protectedV2.GET("/servers/:id/activity", middleware.RequireServerPermission(rbacManager, permissions.ServersActivityRead), serverHandler.GetServerActivity)
+		protectedV2.GET("/servers/:id/backups", middleware.RequireServerPermission(rbacManager, permissions.ServersBackupsList), backupHandler.ListBackups)
+		protectedV2.GET("/users", middleware.RequirePermission(rbacManager, permissions.IAMUsersList), userHandler.ListUsers)
+		protectedV2.GET("/releases", middleware.RequirePermission(rbacManager, permissions.ReleasesList), releaseHandler.ListReleases)
+		protectedV2.GET("/iam/audit-logs", middleware.RequirePermission(rbacManager, permissions.IAMAuditLogsList), iamHandler.ListAuditLogs)
+		protectedV2.GET("/servers/:id/console/search", middleware.RequireServerPermission(rbacManager, permissions.ServersConsoleHistorySearch), consoleHandler.SearchConsoleLogs)
+	}
+
+	// Unknown routes get the same error envelope as handler errors
+	router.NoRoute(func(c *gin.Context) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Route not found")
+	})
+
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
-	shutdown := func() {
-		log.Println("Waiting for background server operations to complete...")
-		serverHandler.WaitForCompletion()
-		log.Println("Background operations completed")
+	// Liveness and readiness probes for orchestrators
+	router.GET("/livez", probeHandler.Livez)
+	router.GET("/readyz", probeHandler.Readyz)
+
+	if cfg.Prometheus.Enabled {
+		// Manager metrics in the Prometheus text format
+		router.GET("/metrics", gin.WrapH(registry.Handler()))
+	}
+
+	// API documentation (regenerate with go generate ./internal/api/openapi)
+	router.GET("/api/openapi.json", docsHandler.OpenAPISpec)
+	router.GET("/api/docs", docsHandler.SwaggerUI)
+	router.GET("/api/docs/init.js", docsHandler.SwaggerInit)
+	router.GET("/api/versions", docsHandler.Versions)
+
+	// Profiling and runtime introspection, registered only when debug.enabled is set
+	if cfg.Debug.Enabled {
+		debug := router.Group("/debug")
+		debug.Use(middleware.Auth(jwtManager, apiKeys))
+		debug.Use(middleware.RequirePermission(rbacManager, permissions.SystemDebug))
+		debugHandler.RegisterRoutes(debug)
+	}
+
+	// Running tasks and scheduled tasks are cancelled; lifecycle operations are left to finish
+	shutdown := func(ctx context.Context) {
+		stopMaintenance()
+		stopWatchdog()
+		stopAutoUpdates()
+		stopStreams()
+		if err := taskScheduler.Stop(ctx); err != nil {
+			logging.For("api").Warn("Scheduled tasks still running at shutdown", "error", err)
+		}
+		logging.For("api").Info("Waiting for background server operations to complete")
+		if err := serverHandler.Shutdown(ctx); err != nil {
+			logging.For("api").Warn("Background operations still running at shutdown", "error", err)
+			return
+		}
+		logging.For("api").Info("Background operations completed")
 	}
 
 	return router, shutdown
@@ -0,0 +1,117 @@
+// Package versioning tracks the supported API versions and the v1 routes that
+// have been superseded in v2. It has no dependencies so the router, middleware
+// and OpenAPI generator can all read the same table.
+package versioning
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API versions
+const (
+	V1     = "v1"
+	V2     = "v2"
+	Latest = V2
+)
+
+// Supported lists the versions currently served, oldest first
+var Supported = []string{V1, V2}
+
+// Prefix returns the route prefix for a version, e.g. /api/v2
+func Prefix(version string) string {
+	return "/api/" + version
+}
+
+// FromPath returns the version a request path or route pattern belongs to,
+// or an empty string for unversioned routes such as /health
+func FromPath(path string) string {
+	for _, version := range Supported {
+		prefix := Prefix(version)
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return version
+		}
+	}
+	return ""
+}
+
+// ForRequest returns the version serving a request from its matched route
+// pattern, falling back to the request path and then to v1
+func ForRequest(routePattern, path string) string {
+	if version := FromPath(routePattern); version != "" {
+		return version
+	}
+	if version := FromPath(path); version != "" {
+		return version
+	}
+	return V1
+}
+
+// Deprecation marks a route that has a replacement and will be removed
+type Deprecation struct {
+	Method    string `json:"method"`
+	Path      string `json:"path"`      // gin route pattern, e.g. /api/v1/servers
+	Since     string `json:"since"`     // date the replacement shipped, YYYY-MM-DD
+	Sunset    string `json:"sunset"`    // date the route will be removed, YYYY-MM-DD
+	Successor string `json:"successor"` // replacement route pattern
+}
+
+// Deprecations lists superseded routes. Add an entry whenever a v2 route changes
+// a request or response shape, so v1 clients get Deprecation and Sunset headers.
+var Deprecations = []Deprecation{
+	// Cursor pagination: v2 wraps listings in a data/pagination envelope
+	{Method: "GET", Path: "/api/v1/servers/:id/metrics", Since: "2026-10-18", Sunset: "2027-04-18", Successor: "/api/v2/servers/:id/metrics"},
+	{Method: "GET", Path: "/api/v1/servers/:id/activity", Since: "2026-10-18", Sunset: "2027-04-18", Successor: "/api/v2/servers/:id/activity"},
+	{Method: "GET", Path: "/api/v1/servers/:id/backups", Since: "2026-10-18", Sunset: "2027-04-18", Successor: "/api/v2/servers/:id/backups"},
+	{Method: "GET", Path: "/api/v1/users", Since: "2026-10-18", Sunset: "2027-04-18", Successor: "/api/v2/users"},
+	{Method: "GET", Path: "/api/v1/releases", Since: "2026-10-18", Sunset: "2027-04-18", Successor: "/api/v2/releases"},
+	{Method: "GET", Path: "/api/v1/iam/audit-logs", Since: "2026-10-18", Sunset: "2027-04-18", Successor: "/api/v2/iam/audit-logs"},
+}
+
+// Lookup returns the deprecation for a route pattern, if any
+func Lookup(method, path string) (Deprecation, bool) {
+	for _, deprecation := range Deprecations {
+		if deprecation.Method == method && deprecation.Path == path {
+			return deprecation, true
+		}
+	}
+	return Deprecation{}, false
+}
+
+// SinceTime parses Since; the zero time is returned if it is not set
+func (d Deprecation) SinceTime() time.Time {
+	return parseDate(d.Since)
+}
+
+// SunsetTime parses Sunset; the zero time is returned if it is not set
+func (d Deprecation) SunsetTime() time.Time {
+	return parseDate(d.Sunset)
+}
+
+// Headers returns the Deprecation (RFC 9745), Sunset (RFC 8594) and Link
+// headers announcing the deprecation
+func (d Deprecation) Headers() http.Header {
+	headers := http.Header{}
+	if since := d.SinceTime(); !since.IsZero() {
+		headers.Set("Deprecation", "@"+strconv.FormatInt(since.Unix(), 10))
+	} else {
+		headers.Set("Deprecation", "true")
+	}
+	if sunset := d.SunsetTime(); !sunset.IsZero() {
+		headers.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		headers.Set("Link", "<"+d.Successor+">; rel=\"successor-version\"")
+	}
+	return headers
+}
+
+func parseDate(value string) time.Time {
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/TheGojiOG/HytaleSM/internal/api/versioning"
+)
+
+// VersionFallback serves /api/v2 requests that have no v2 route yet from the
+// matching v1 route. Clients can switch their base URL to v2 in one go while
+// endpoints move over individually; only routes whose shape changed get a v2
+// registration. The rewrite happens before gin routes the request so audit and
+// rate limiting see it once.
+func VersionFallback(router *gin.Engine) http.Handler {
+	v2Prefix := versioning.Prefix(versioning.V2)
+	v1Prefix := versioning.Prefix(versioning.V1)
+
+	routes := map[string][][]string{}
+	for _, route := range router.Routes() {
+		if strings.HasPrefix(route.Path, v2Prefix+"/") {
+			routes[route.Method] = append(routes[route.Method], splitPath(route.Path))
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, v2Prefix+"/") || matchesRoute(routes[r.Method], splitPath(r.URL.Path)) {
+			router.ServeHTTP(w, r)
+			return
+		}
+
+		rewritten := new(http.Request)
+		*rewritten = *r
+		rewritten.URL = new(url.URL)
+		*rewritten.URL = *r.URL
+		rewritten.URL.Path = v1Prefix + strings.TrimPrefix(r.URL.Path, v2Prefix)
+		if r.URL.RawPath != "" {
+			rewritten.URL.RawPath = v1Prefix + strings.TrimPrefix(r.URL.RawPath, v2Prefix)
+		}
+		router.ServeHTTP(w, rewritten)
+	})
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchesRoute reports whether path segments match any gin route pattern
+func matchesRoute(patterns [][]string, segments []string) bool {
+	for _, pattern := range patterns {
+		if matchesPattern(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPattern(pattern, segments []string) bool {
+	for i, part := range pattern {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if part != segments[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(segments)
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT
+const APIKeyPrefix = "hsm_"
+
+// ErrInvalidAPIKey is returned for unknown, revoked or expired keys
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKey describes a stored key. The key itself is only shown once, at creation.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APIKeyStore issues and checks API keys. A key acts as the user who created
+// it, with that user's current roles and permissions.
+type APIKeyStore struct {
+	db *sql.DB
+}
+
+// NewAPIKeyStore creates a new API key store
+func NewAPIKeyStore(db *sql.DB) *APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+// IsAPIKey reports whether token looks like an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// GenerateAPIKey returns a new key and the hash to store for it
+func GenerateAPIKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashResetToken(key), nil
+}
+
+// Create stores a new key for the user and returns it with its plaintext value
+func (s *APIKeyStore) Create(ctx context.Context, userID int64, name string, expiresAt *time.Time) (*APIKey, string, error) {
+	key, hash, err := GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	record := &APIKey{Name: name, Prefix: key[:len(APIKeyPrefix)+6], CreatedAt: now, ExpiresAt: expiresAt}
+	var expires interface{}
+	if expiresAt != nil {
+		expires = *expiresAt
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (user_id, name, key_hash, prefix, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, name, hash, record.Prefix, now, expires)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store api key: %w", err)
+	}
+	if record.ID, err = result.LastInsertId(); err != nil {
+		return nil, "", fmt.Errorf("failed to read api key id: %w", err)
+	}
+	return record, key, nil
+}
+
+// List returns the user's keys that have not been revoked
+func (s *APIKeyStore) List(ctx context.Context, userID int64) ([]APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, prefix, created_at, expires_at, last_used_at
+		FROM api_keys
+		WHERE user_id = ? AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		var key APIKey
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables one of the user's keys. It returns false if no such key exists.
+func (s *APIKeyStore) Revoke(ctx context.Context, userID, keyID int64) (bool, error) {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`,
+		time.Now(), keyID, userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Authenticate resolves a key to claims for its owner, who must still be active
+func (s *APIKeyStore) Authenticate(ctx context.Context, key string) (*Claims, int64, error) {
+	var claims Claims
+	var keyID int64
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT k.id, k.expires_at, u.id, u.username, u.organization_id
+		FROM api_keys k
+		INNER JOIN users u ON k.user_id = u.id
+		WHERE k.key_hash = ? AND k.revoked_at IS NULL AND u.is_active = 1
+	`, HashResetToken(key)).Scan(&keyID, &expiresAt, &claims.UserID, &claims.Username, &claims.OrganizationID)
+	if err == sql.ErrNoRows {
+		return nil, 0, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to look up api key: %w", err)
+	}
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return nil, 0, ErrInvalidAPIKey
+	}
+
+	_, _ = s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, time.Now(), keyID)
+	return &claims, keyID, nil
+}
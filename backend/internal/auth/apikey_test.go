@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestAPIKeyStoreLifecycle(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+
+	result, err := db.Exec(`INSERT INTO users (username, email, password_hash) VALUES ('ci', 'ci@example.com', 'hash')`)
+	if err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+	userID, _ := result.LastInsertId()
+
+	ctx := context.Background()
+	store := NewAPIKeyStore(db.DB)
+	record, key, err := store.Create(ctx, userID, "deploy bot", nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !IsAPIKey(key) || record.Prefix != key[:len(record.Prefix)] {
+		t.Fatalf("unexpected key %q with prefix %q", key, record.Prefix)
+	}
+
+	claims, keyID, err := store.Authenticate(ctx, key)
+	if err != nil || claims.UserID != userID || claims.Username != "ci" || keyID != record.ID {
+		t.Fatalf("expected key to authenticate as ci, got %+v, %d, %v", claims, keyID, err)
+	}
+	if _, _, err := store.Authenticate(ctx, key+"x"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected unknown key to be rejected, got %v", err)
+	}
+
+	keys, err := store.List(ctx, userID)
+	if err != nil || len(keys) != 1 || keys[0].LastUsedAt == nil {
+		t.Fatalf("expected one used key, got %+v (%v)", keys, err)
+	}
+
+	if revoked, err := store.Revoke(ctx, userID, record.ID); err != nil || !revoked {
+		t.Fatalf("revoke: %v %v", revoked, err)
+	}
+	if _, _, err := store.Authenticate(ctx, key); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected revoked key to be rejected, got %v", err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	_, expired, err := store.Create(ctx, userID, "old", &past)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, _, err := store.Authenticate(ctx, expired); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("expected expired key to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCFlowDuration bounds how long a login may sit at the identity provider
+const OIDCFlowDuration = 10 * time.Minute
+
+// oidcKeyRefreshInterval limits how often an unknown key id refetches the JWKS
+const oidcKeyRefreshInterval = time.Minute
+
+// ErrInvalidOIDCFlow is returned when the flow cookie is missing, forged or expired
+var ErrInvalidOIDCFlow = errors.New("invalid oidc flow")
+
+// OIDCOptions configures an OpenID Connect provider
+type OIDCOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+	// FlowSecret signs the cookie that carries state between login and callback
+	FlowSecret string
+	HTTPClient *http.Client
+}
+
+// OIDCIdentity is what a verified ID token says about the user
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+	Groups        []string
+}
+
+// OIDCFlow is the per-login state kept in the browser between the redirect to
+// the provider and the callback
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider signs users in through an OpenID Connect identity provider
+// (Keycloak, Authentik, Google, ...) with the authorization code flow and
+// PKCE. Discovery runs on first use, so the panel starts even while the
+// provider is unreachable.
+type OIDCProvider struct {
+	opts    OIDCOptions
+	flowKey []byte
+	client  *http.Client
+
+	mu          sync.Mutex
+	metadata    *oidcMetadata
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+// NewOIDCProvider creates a new OpenID Connect provider
+func NewOIDCProvider(opts OIDCOptions) *OIDCProvider {
+	opts.Issuer = strings.TrimRight(opts.Issuer, "/")
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = []string{"openid", "profile", "email"}
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = "groups"
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	// The flow cookie is signed with a key derived from the secret, never the
+	// secret itself, so it can't be replayed as an access token
+	mac := hmac.New(sha256.New, []byte(opts.FlowSecret))
+	mac.Write([]byte("hytalesm-oidc-flow"))
+
+	return &OIDCProvider{
+		opts:    opts,
+		flowKey: mac.Sum(nil),
+		client:  client,
+	}
+}
+
+// NewFlow starts a login: it returns the provider URL to send the browser to
+// and the sealed flow to keep in a cookie until the callback
+func (p *OIDCProvider) NewFlow(ctx context.Context) (string, string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	flow := OIDCFlow{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(OIDCFlowDuration)),
+		},
+	}
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *value, err = randomURLToken(32); err != nil {
+			return "", "", err
+		}
+	}
+	sealed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &flow).SignedString(p.flowKey)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to seal oidc flow: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(flow.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.opts.ClientID},
+		"redirect_uri":          {p.opts.RedirectURL},
+		"scope":                 {strings.Join(p.opts.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), sealed, nil
+}
+
+// OpenFlow checks a sealed flow and the state the provider sent back
+func (p *OIDCProvider) OpenFlow(sealed, state string) (*OIDCFlow, error) {
+	flow := &OIDCFlow{}
+	_, err := jwt.ParseWithClaims(sealed, flow, func(token *jwt.Token) (interface{}, error) {
+		return p.flowKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidOIDCFlow
+	}
+	if state == "" || !hmac.Equal([]byte(flow.State), []byte(state)) {
+		return nil, ErrInvalidOIDCFlow
+	}
+	return flow, nil
+}
+
+// Exchange redeems an authorization code and returns the verified identity
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, flow *OIDCFlow) (*OIDCIdentity, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.opts.RedirectURL},
+		"code_verifier": {flow.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.opts.ClientID), url.QueryEscape(p.opts.ClientSecret))
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if status != http.StatusOK {
+		if tokens.Error != "" {
+			return nil, fmt.Errorf("token request refused: %s %s", tokens.Error, tokens.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token request refused with status %d", status)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+	return p.VerifyIDToken(ctx, tokens.IDToken, flow.Nonce)
+}
+
+// VerifyIDToken checks an ID token's signature, issuer, audience, expiry and
+// nonce and returns the identity it describes
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw, nonce string) (*OIDCIdentity, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.opts.Issuer),
+		jwt.WithAudience(p.opts.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+	if got, _ := claims["nonce"].(string); nonce == "" || !hmac.Equal([]byte(got), []byte(nonce)) {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+
+	identity := &OIDCIdentity{Issuer: p.opts.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	identity.Username, _ = claims["preferred_username"].(string)
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	identity.Groups = claimStrings(claims[p.opts.GroupsClaim])
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("invalid id token: missing subject")
+	}
+	return identity, nil
+}
+
+// MapGroupRoles returns the roles a user's groups grant. Keycloak sends group
+// paths, so "/admins" also matches a mapping for "admins". Without any match
+// the default role is used; an empty default means no roles.
+func MapGroupRoles(groups []string, mapping map[string]string, defaultRole string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		role, ok := mapping[group]
+		if !ok {
+			role, ok = mapping[strings.TrimPrefix(group, "/")]
+		}
+		if ok && role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 && defaultRole != "" {
+		roles = []string{defaultRole}
+	}
+	return roles
+}
+
+// discover fetches and caches the provider's metadata. The fetch runs
+// without the lock, so a slow provider holds up only the logins waiting for
+// it; concurrent first logins may each fetch, and the first result is kept.
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	cached := p.metadata
+	p.mu.Unlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.opts.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata oidcMetadata
+	status, err := p.doJSON(req, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery failed with status %d", status)
+	}
+	if strings.TrimRight(metadata.Issuer, "/") != p.opts.Issuer {
+		return nil, fmt.Errorf("oidc discovery returned issuer %q, expected %q", metadata.Issuer, p.opts.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery is missing an endpoint")
+	}
+	// Tokens are checked against the issuer as configured
+	metadata.Issuer = p.opts.Issuer
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata == nil {
+		p.metadata = &metadata
+	}
+	return p.metadata, nil
+}
+
+// key returns the signing key with the given id, refetching the JWKS when the
+// provider has rotated to a key not seen yet. Like discover, it fetches
+// without holding the lock and swaps the new keys in afterwards.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	fresh := time.Since(p.keysFetched) < oidcKeyRefreshInterval
+	jwksURI := p.metadata.JWKSURI
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if fresh {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("jwks request failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("jwks request failed with status %d", status)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = time.Now()
+	p.mu.Unlock()
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	// A provider with a single key may leave kid out of its tokens
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, out interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, out); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+func (k oidcJWK) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 {
+			return nil, fmt.Errorf("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("ec point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// claimStrings reads a claim that may be a single string or a list
+func claimStrings(value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func randomURLToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
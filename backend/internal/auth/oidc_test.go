@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testOIDCServer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	claims   jwt.MapClaims
+	verifier string
+}
+
+func newTestOIDCServer(t *testing.T) *testOIDCServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testOIDCServer{key: key}
+	mux := http.NewServeMux()
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/authorize",
+			"token_endpoint":         s.URL + "/token",
+			"jwks_uri":               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "panel" || secret != "s3cret" || r.FormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		s.verifier = r.FormValue("code_verifier")
+		json.NewEncoder(w).Encode(map[string]string{"id_token": s.sign(t, s.claims)})
+	})
+	return s
+}
+
+func (s *testOIDCServer) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	server := newTestOIDCServer(t)
+	provider := NewOIDCProvider(OIDCOptions{
+		Issuer:       server.URL + "/",
+		ClientID:     "panel",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://panel.example/api/v1/auth/oidc/callback",
+		FlowSecret:   "jwt-secret",
+	})
+	ctx := context.Background()
+
+	authURL, sealed, err := provider.NewFlow(ctx)
+	if err != nil {
+		t.Fatalf("new flow: %v", err)
+	}
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "panel" {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+
+	if _, err := provider.OpenFlow(sealed, "forged-state"); err == nil {
+		t.Fatal("expected a mismatched state to be refused")
+	}
+	if _, err := provider.OpenFlow(sealed[:len(sealed)-2]+"xx", query.Get("state")); err == nil {
+		t.Fatal("expected a tampered flow to be refused")
+	}
+	flow, err := provider.OpenFlow(sealed, query.Get("state"))
+	if err != nil {
+		t.Fatalf("open flow: %v", err)
+	}
+	if _, err := NewJWTManager("jwt-secret", time.Minute, time.Hour).ValidateAccessToken(sealed); err == nil {
+		t.Fatal("expected the flow cookie not to pass as an access token")
+	}
+
+	server.claims = jwt.MapClaims{
+		"iss":                server.URL,
+		"aud":                "panel",
+		"sub":                "user-1",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              query.Get("nonce"),
+		"email":              "ana@example.com",
+		"email_verified":     true,
+		"preferred_username": "ana",
+		"groups":             []string{"/admins", "players"},
+	}
+	if _, err := provider.Exchange(ctx, "bad-code", flow); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("expected a refused code to fail, got %v", err)
+	}
+	identity, err := provider.Exchange(ctx, "good-code", flow)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	challenge := sha256.Sum256([]byte(server.verifier))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != query.Get("code_challenge") {
+		t.Fatal("expected the token request to carry the PKCE verifier")
+	}
+	if identity.Subject != "user-1" || identity.Username != "ana" || !identity.EmailVerified || !reflect.DeepEqual(identity.Groups, []string{"/admins", "players"}) {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+}
+
+func TestOIDCVerifyIDTokenRefusesBadTokens(t *testing.T) {
+	server := newTestOIDCServer(t)
+	provider := NewOIDCProvider(OIDCOptions{Issuer: server.URL, ClientID: "panel", FlowSecret: "jwt-secret"})
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{"iss": server.URL, "aud": "panel", "sub": "user-1", "nonce": "n1", "exp": time.Now().Add(time.Minute).Unix()}
+	}
+	if _, err := provider.VerifyIDToken(context.Background(), server.sign(t, valid()), "n1"); err != nil {
+		t.Fatalf("expected a valid token to pass: %v", err)
+	}
+
+	for name, edit := range map[string]func(jwt.MapClaims){
+		"issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example" },
+		"audience": func(c jwt.MapClaims) { c["aud"] = "other-client" },
+		"expired":  func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"nonce":    func(c jwt.MapClaims) { c["nonce"] = "replayed" },
+		"subject":  func(c jwt.MapClaims) { delete(c, "sub") },
+	} {
+		claims := valid()
+		edit(claims)
+		if _, err := provider.VerifyIDToken(context.Background(), server.sign(t, claims), "n1"); err == nil {
+			t.Errorf("expected a token with a bad %s to be refused", name)
+		}
+	}
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("panel"))
+	if _, err := provider.VerifyIDToken(context.Background(), hmacToken, "n1"); err == nil {
+		t.Error("expected an HMAC-signed token to be refused")
+	}
+}
+
+func TestMapGroupRoles(t *testing.T) {
+	mapping := map[string]string{"admins": "Admin", "ops": "Operator", "staff": "Operator"}
+	for _, tc := range []struct {
+		groups      []string
+		defaultRole string
+		want        []string
+	}{
+		{[]string{"/admins", "ops"}, "Viewer", []string{"Admin", "Operator"}},
+		{[]string{"ops", "staff"}, "Viewer", []string{"Operator"}},
+		{[]string{"guests"}, "Viewer", []string{"Viewer"}},
+		{nil, "", nil},
+	} {
+		if got := MapGroupRoles(tc.groups, mapping, tc.defaultRole); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: expected %v, got %v", tc.groups, tc.want, got)
+		}
+	}
+}
+
+func TestOIDCDiscoveryDoesNotHoldUpOtherLogins(t *testing.T) {
+	hung := make(chan struct{})
+	var calls atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first discovery hangs until its login gives up
+		if calls.Add(1) == 1 {
+			close(hung)
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	}))
+	t.Cleanup(server.Close)
+	provider := NewOIDCProvider(OIDCOptions{Issuer: server.URL, ClientID: "panel", FlowSecret: "jwt-secret"})
+
+	slowCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slow := make(chan error, 1)
+	go func() {
+		_, _, err := provider.NewFlow(slowCtx)
+		slow <- err
+	}()
+	<-hung
+
+	ctx, cancelFast := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFast()
+	if _, _, err := provider.NewFlow(ctx); err != nil {
+		t.Fatalf("expected a second login to discover the provider, got %v", err)
+	}
+
+	cancel()
+	if err := <-slow; err == nil {
+		t.Fatal("expected the hung discovery to fail once its login gave up")
+	}
+}
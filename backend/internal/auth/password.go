@@ -1,26 +1,195 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Password hash algorithms accepted by PasswordHasher
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// ErrPasswordMismatch is returned when a password does not match its hash
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordHasher hashes new passwords with the configured algorithm. The zero
+// value uses bcrypt with the default cost.
+type PasswordHasher struct {
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Hash hashes a plain text password with the configured algorithm
+func (h PasswordHasher) Hash(password string) (string, error) {
+	if h.Algorithm == PasswordHashArgon2id {
+		return HashPasswordArgon2id(password, h.argon2Params())
+	}
+	return HashPassword(password, h.BcryptCost)
+}
+
+// NeedsRehash reports whether hash was made with a different algorithm or
+// weaker parameters than the hasher would use now, so it should be replaced
+// the next time the plain text password is known
+func (h PasswordHasher) NeedsRehash(hash string) bool {
+	if h.Algorithm == PasswordHashArgon2id {
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return true
+		}
+		want := h.argon2Params()
+		return params.Memory != want.Memory || params.Iterations != want.Iterations ||
+			params.Parallelism != want.Parallelism || params.KeyLength != want.KeyLength
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost != normalizeBcryptCost(h.BcryptCost)
+}
+
+func (h PasswordHasher) argon2Params() Argon2Params {
+	params := h.Argon2
+	if params.Memory == 0 {
+		params.Memory = DefaultArgon2Params.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultArgon2Params.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	return params
+}
+
 // HashPassword hashes a plain text password using bcrypt
 func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), normalizeBcryptCost(cost))
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func normalizeBcryptCost(cost int) int {
 	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
-		cost = bcrypt.DefaultCost
+		return bcrypt.DefaultCost
 	}
+	return cost
+}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	if err != nil {
+// HashPasswordArgon2id hashes a plain text password using argon2id, encoded
+// in the PHC string format: $argon2id$v=19$m=...,t=...,p=...$salt$key
+func HashPasswordArgon2id(password string, params Argon2Params) (string, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	return string(hash), nil
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
-// VerifyPassword compares a plain text password with a hashed password
+// VerifyPassword compares a plain text password with a bcrypt or argon2id hash
 func VerifyPassword(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != PasswordHashArgon2id {
+		return params, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errors.New("unsupported argon2id version")
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
+// GenerateResetToken creates a random single-use token and the hash stored for it
+func GenerateResetToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, HashResetToken(token), nil
+}
+
+// HashResetToken hashes a reset token for storage and lookup
+func HashResetToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return base64.URLEncoding.EncodeToString(hash[:])
 }
@@ -16,3 +16,50 @@ func TestHashAndVerifyPassword(t *testing.T) {
 		t.Fatalf("expected wrong password to fail")
 	}
 }
+
+func TestGenerateResetToken(t *testing.T) {
+	token, hash, err := GenerateResetToken()
+	if err != nil {
+		t.Fatalf("failed to generate reset token: %v", err)
+	}
+	if token == "" || hash == "" || token == hash {
+		t.Fatalf("expected distinct token and hash")
+	}
+	if HashResetToken(token) != hash {
+		t.Fatalf("expected hash to be reproducible from token")
+	}
+}
+
+func TestArgon2idHashAndRehash(t *testing.T) {
+	hasher := PasswordHasher{Algorithm: PasswordHashArgon2id, Argon2: Argon2Params{Memory: 8 * 1024, Iterations: 1, Parallelism: 1}}
+	hash, err := hasher.Hash("secret")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if err := VerifyPassword("secret", hash); err != nil {
+		t.Fatalf("expected password to verify, got %v", err)
+	}
+	if err := VerifyPassword("wrong", hash); err == nil {
+		t.Fatalf("expected wrong password to fail")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Fatalf("expected hash with current parameters to be kept")
+	}
+
+	bcryptHash, err := HashPassword("secret", 10)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	if !hasher.NeedsRehash(bcryptHash) {
+		t.Fatalf("expected bcrypt hash to need rehashing under argon2id")
+	}
+	if !(PasswordHasher{BcryptCost: 12}).NeedsRehash(hash) {
+		t.Fatalf("expected argon2id hash to need rehashing under bcrypt")
+	}
+
+	stronger := hasher
+	stronger.Argon2.Iterations = 2
+	if !stronger.NeedsRehash(hash) {
+		t.Fatalf("expected hash with older parameters to need rehashing")
+	}
+}
@@ -0,0 +1,210 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+)
+
+type fakeControl struct {
+	mu       sync.Mutex
+	groups   map[string]string
+	deployed []string
+	backups  []string
+	result   func(serverID string) (bool, error)
+}
+
+func (f *fakeControl) ServerGroups() map[string]string {
+	return f.groups
+}
+
+func (f *fakeControl) RunTask(ctx context.Context, serverID, name string, work func(ctx context.Context, taskID string) error) error {
+	return work(ctx, serverID+"-task")
+}
+
+func (f *fakeControl) TaskOutput(serverID, taskID, name, line string) {}
+
+func (f *fakeControl) AutoDeploy(ctx context.Context, policy *Policy, serverID, packageName string, emit func(string)) (bool, error) {
+	f.mu.Lock()
+	f.deployed = append(f.deployed, serverID+":"+packageName)
+	f.mu.Unlock()
+	if f.result != nil {
+		return f.result(serverID)
+	}
+	return false, nil
+}
+
+type fakeReleases struct {
+	latest *releases.Release
+	active map[string]string
+}
+
+func (f *fakeReleases) LatestRelease(patchline string) (*releases.Release, error) {
+	return f.latest, nil
+}
+
+func (f *fakeReleases) ActiveDeployment(serverID string) (*releases.Deployment, error) {
+	if name, ok := f.active[serverID]; ok {
+		return &releases.Deployment{ServerID: serverID, PackageName: name}, nil
+	}
+	return nil, nil
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	return NewStore(db.DB)
+}
+
+func newTestManager(t *testing.T, control *fakeControl, available *fakeReleases, open map[string]bool) (*Manager, *Store) {
+	t.Helper()
+	store := newTestStore(t)
+	policy := &Policy{ID: "p1", Group: "eu", WindowID: "w1", Backup: true, Enabled: true}
+	policy.Normalize()
+	if err := store.Save(context.Background(), policy); err != nil {
+		t.Fatalf("failed to save policy: %v", err)
+	}
+	manager := NewManager(store, available, control, func() map[string]bool { return open })
+	manager.SetBackup(func(serverID string) (string, error) {
+		control.mu.Lock()
+		defer control.mu.Unlock()
+		control.backups = append(control.backups, serverID)
+		return "backup of " + serverID, nil
+	})
+	return manager, store
+}
+
+// reconcileAndWait checks the policies once and waits for the runs it started
+func reconcileAndWait(t *testing.T, manager *Manager) {
+	t.Helper()
+	manager.reconcile(context.Background())
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		manager.mu.Lock()
+		busy := len(manager.busy)
+		manager.mu.Unlock()
+		if busy == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("auto-update runs did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newRelease() *releases.Release {
+	return &releases.Release{ID: 7, Version: "2026.10.1", Patchline: "default", FilePath: "/data/releases/2026.10.1-default.zip"}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	policy := &Policy{WindowID: "w1"}
+	policy.Normalize()
+	if err := policy.Validate(); err == nil {
+		t.Fatal("expected a policy without targets to be rejected")
+	}
+	policy.Group = "eu"
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	policy.HealthStableMinutes = maxHealthMinutes + 1
+	if err := policy.Validate(); err == nil {
+		t.Fatal("expected health_stable_minutes over the limit to be rejected")
+	}
+	if policy.Patchline != "default" || policy.Name == "" {
+		t.Fatalf("expected defaults, got patchline %q name %q", policy.Patchline, policy.Name)
+	}
+}
+
+func TestDeploysOnceInOpenWindow(t *testing.T) {
+	control := &fakeControl{groups: map[string]string{"a": "eu", "b": "eu", "c": "us"}}
+	available := &fakeReleases{latest: newRelease(), active: map[string]string{"b": "2026.10.1-default"}}
+	open := map[string]bool{}
+	manager, store := newTestManager(t, control, available, open)
+
+	reconcileAndWait(t, manager)
+	if len(control.deployed) != 0 {
+		t.Fatalf("expected no deploys while the window is closed, got %v", control.deployed)
+	}
+
+	open["w1"] = true
+	reconcileAndWait(t, manager)
+	// b already runs the release and c is not covered
+	if len(control.deployed) != 1 || control.deployed[0] != "a:2026.10.1-default" {
+		t.Fatalf("expected only a to be deployed, got %v", control.deployed)
+	}
+	if len(control.backups) != 1 || control.backups[0] != "a" {
+		t.Fatalf("expected a backup of a first, got %v", control.backups)
+	}
+
+	runs, err := store.ListRuns(context.Background(), "p1", "", 10)
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != StatusComplete || runs[0].FinishedAt == nil || runs[0].ReleaseID != 7 {
+		t.Fatalf("expected one complete run, got %+v", runs)
+	}
+
+	// The release is not deployed to a twice, even though the fake does not
+	// record it as active
+	reconcileAndWait(t, manager)
+	if len(control.deployed) != 1 {
+		t.Fatalf("expected no second deploy, got %v", control.deployed)
+	}
+}
+
+func TestRecordsRollbackAndFailure(t *testing.T) {
+	control := &fakeControl{
+		groups: map[string]string{"a": "eu", "b": "eu"},
+		result: func(serverID string) (bool, error) {
+			if serverID == "a" {
+				return true, errors.New("server did not become healthy")
+			}
+			return false, errors.New("deploy failed")
+		},
+	}
+	manager, store := newTestManager(t, control, &fakeReleases{latest: newRelease()}, map[string]bool{"w1": true})
+
+	reconcileAndWait(t, manager)
+	for serverID, status := range map[string]string{"a": StatusRolledBack, "b": StatusFailed} {
+		runs, err := store.ListRuns(context.Background(), "", serverID, 10)
+		if err != nil {
+			t.Fatalf("failed to list runs: %v", err)
+		}
+		if len(runs) != 1 || runs[0].Status != status || runs[0].Error == "" {
+			t.Fatalf("expected one %s run on %s, got %+v", status, serverID, runs)
+		}
+	}
+}
+
+func TestFailInterrupted(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	run := &Run{PolicyID: "p1", ServerID: "a", ReleaseID: 1, PackageName: "pkg", Version: "1", TaskID: "t"}
+	if err := store.StartRun(ctx, run); err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	if err := store.FailInterrupted(ctx); err != nil {
+		t.Fatalf("failed to fail interrupted runs: %v", err)
+	}
+	runs, err := store.ListRuns(ctx, "p1", "a", 10)
+	if err != nil {
+		t.Fatalf("failed to list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != StatusFailed || runs[0].FinishedAt == nil {
+		t.Fatalf("expected the interrupted run to be failed, got %+v", runs)
+	}
+}
@@ -0,0 +1,259 @@
+package autoupdate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/releases"
+)
+
+var logger = logging.For("autoupdate")
+
+// TaskName is the name of the server tasks runs are recorded as
+const TaskName = "auto-update"
+
+// ServerControl deploys releases to servers for the manager
+type ServerControl interface {
+	// ServerGroups returns the group of every defined server, keyed by ID
+	ServerGroups() map[string]string
+	// RunTask records a task on a server, runs work as it once no other
+	// task runs on the server and returns work's error
+	RunTask(ctx context.Context, serverID, name string, work func(ctx context.Context, taskID string) error) error
+	// TaskOutput adds a line to a task's output
+	TaskOutput(serverID, taskID, name, line string)
+	// AutoDeploy deploys a release package to a server its maintenance
+	// window keeps stopped and checks that the server then comes up and
+	// stays up. It reports whether a server that failed was rolled back.
+	AutoDeploy(ctx context.Context, policy *Policy, serverID, packageName string, emit func(string)) (bool, error)
+}
+
+// Releases tells the manager which release is newest and what servers run
+type Releases interface {
+	LatestRelease(patchline string) (*releases.Release, error)
+	ActiveDeployment(serverID string) (*releases.Deployment, error)
+}
+
+// Manager deploys new releases to the servers of the enabled policies while
+// their maintenance window is open, never updating a server twice at once
+type Manager struct {
+	store    *Store
+	releases Releases
+	control  ServerControl
+	// windows returns the IDs of the open maintenance windows
+	windows  func() map[string]bool
+	backup   func(serverID string) (string, error)
+	interval time.Duration
+
+	mu   sync.Mutex
+	busy map[string]bool
+	wake chan struct{}
+}
+
+// NewManager creates an auto-update manager
+func NewManager(store *Store, available Releases, control ServerControl, windows func() map[string]bool) *Manager {
+	return &Manager{
+		store:    store,
+		releases: available,
+		control:  control,
+		windows:  windows,
+		interval: time.Minute,
+		busy:     make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// SetBackup sets how the backup before a deploy is taken. It returns a
+// summary of the backup. It must be called before Start.
+func (m *Manager) SetBackup(backup func(serverID string) (string, error)) {
+	m.backup = backup
+}
+
+// Start checks the policies every interval until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	// Runs recorded as running were cut off when the manager stopped
+	if err := m.store.FailInterrupted(ctx); err != nil {
+		logger.Error("Failed to close interrupted auto-update runs", "error", err)
+	}
+	ticker := time.NewTicker(m.interval)
+	go func() {
+		defer ticker.Stop()
+		m.reconcile(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Info("Stopping auto-update manager")
+				return
+			case <-ticker.C:
+			case <-m.wake:
+			}
+			m.reconcile(ctx)
+		}
+	}()
+}
+
+// Refresh checks the policies again soon, after one was created or changed
+func (m *Manager) Refresh() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) reconcile(ctx context.Context) {
+	policies, err := m.store.List(ctx)
+	if err != nil {
+		logger.Error("Failed to load auto-update policies", "error", err)
+		return
+	}
+	open := m.windows()
+
+	var groups map[string]string
+	for _, policy := range policies {
+		if !policy.Enabled || !open[policy.WindowID] {
+			continue
+		}
+		release, err := m.releases.LatestRelease(policy.Patchline)
+		if err != nil {
+			logger.Error("Failed to find the latest release", "policy_id", policy.ID, "patchline", policy.Patchline, "error", err)
+			continue
+		}
+		if release == nil {
+			continue
+		}
+		if groups == nil {
+			groups = m.control.ServerGroups()
+		}
+		for _, serverID := range targets(policy, groups) {
+			if m.due(ctx, policy, serverID, release) {
+				go func(policy *Policy, serverID string) {
+					defer m.release(serverID)
+					m.run(ctx, policy, serverID, release)
+				}(policy, serverID)
+			}
+		}
+	}
+}
+
+// due claims a server for a run when it does not run release yet and the
+// policy has not tried release on it before
+func (m *Manager) due(ctx context.Context, policy *Policy, serverID string, release *releases.Release) bool {
+	if !m.claim(serverID) {
+		return false
+	}
+	due, err := func() (bool, error) {
+		active, err := m.releases.ActiveDeployment(serverID)
+		if err != nil || (active != nil && active.PackageName == release.PackageName()) {
+			return false, err
+		}
+		attempted, err := m.store.Attempted(ctx, policy.ID, serverID, release.ID)
+		return !attempted, err
+	}()
+	if err != nil {
+		logger.Error("Failed to check whether a server needs an update", "policy_id", policy.ID, "server_id", serverID, "error", err)
+	}
+	if !due {
+		m.release(serverID)
+	}
+	return due
+}
+
+func (m *Manager) run(ctx context.Context, policy *Policy, serverID string, release *releases.Release) {
+	// The update waits its turn behind the server's other tasks
+	err := m.control.RunTask(ctx, serverID, TaskName, func(ctx context.Context, taskID string) error {
+		return m.update(ctx, policy, serverID, release, taskID)
+	})
+	if err != nil && ctx.Err() != nil {
+		logger.Warn("Auto-update interrupted", "policy_id", policy.ID, "server_id", serverID, "error", err)
+	}
+}
+
+// update deploys the release to the server as the task taskID and records the run
+func (m *Manager) update(ctx context.Context, policy *Policy, serverID string, release *releases.Release, taskID string) error {
+	packageName := release.PackageName()
+	run := &Run{
+		PolicyID:    policy.ID,
+		ServerID:    serverID,
+		ReleaseID:   release.ID,
+		PackageName: packageName,
+		Version:     release.Version,
+		TaskID:      taskID,
+	}
+	if err := m.store.StartRun(ctx, run); err != nil {
+		logger.Error("Failed to record auto-update run", "policy_id", policy.ID, "server_id", serverID, "error", err)
+		return err
+	}
+	emit := func(line string) {
+		m.control.TaskOutput(serverID, taskID, TaskName, line)
+	}
+
+	logger.Info("Auto-updating server", "policy_id", policy.ID, "server_id", serverID, "package", packageName)
+	emit(fmt.Sprintf("Policy %q: updating to %s (%s, version %s)", policy.Name, packageName, release.Patchline, release.Version))
+	rolledBack := false
+	err := func() error {
+		if policy.Backup {
+			if m.backup == nil {
+				return errors.New("backups are not available")
+			}
+			emit("Creating a backup before the deploy...")
+			summary, err := m.backup(serverID)
+			if err != nil {
+				return fmt.Errorf("pre-deploy backup failed: %w", err)
+			}
+			emit(summary)
+		}
+		var err error
+		rolledBack, err = m.control.AutoDeploy(ctx, policy, serverID, packageName, emit)
+		return err
+	}()
+
+	run.Status = StatusComplete
+	switch {
+	case rolledBack:
+		run.Status = StatusRolledBack
+	case err != nil:
+		run.Status = StatusFailed
+	}
+	if err != nil {
+		run.Error = err.Error()
+		emit("Auto-update failed: " + err.Error())
+		logger.Warn("Auto-update failed", "policy_id", policy.ID, "server_id", serverID, "package", packageName, "error", err)
+	} else {
+		emit("Auto-update complete.")
+	}
+	if err := m.store.FinishRun(context.WithoutCancel(ctx), run); err != nil {
+		logger.Error("Failed to record auto-update result", "run_id", run.ID, "error", err)
+	}
+	return err
+}
+
+func targets(policy *Policy, groups map[string]string) []string {
+	var ids []string
+	for serverID, group := range groups {
+		if policy.Covers(serverID, group) {
+			ids = append(ids, serverID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *Manager) claim(serverID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[serverID] {
+		return false
+	}
+	m.busy[serverID] = true
+	return true
+}
+
+func (m *Manager) release(serverID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busy, serverID)
+}
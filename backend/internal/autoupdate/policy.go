@@ -0,0 +1,111 @@
+package autoupdate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxHealthMinutes bounds each of the health check's waits
+const maxHealthMinutes = 120
+
+// Run statuses
+const (
+	StatusRunning  = "running"
+	StatusComplete = "complete"
+	StatusFailed   = "failed"
+	// StatusRolledBack runs failed their health check and were rolled back
+	StatusRolledBack = "rolled_back"
+)
+
+// Policy keeps servers on the newest release of a patchline. When a release
+// appears that a server does not run yet, it is deployed the next time
+// maintenance window WindowID is open: after a backup, unless Backup is off,
+// and followed by a health check. It covers the servers in ServerIDs and
+// every server whose group is Group.
+type Policy struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	ServerIDs []string `json:"server_ids"`
+	Group     string   `json:"group,omitempty"`
+	Patchline string   `json:"patchline"`
+	WindowID  string   `json:"window_id"`
+	Backup    bool     `json:"backup"`
+	// HealthStartupMinutes and HealthStableMinutes are how long a deployed
+	// server gets to come up, and then must stay up; 0 uses the default
+	HealthStartupMinutes int `json:"health_startup_minutes"`
+	HealthStableMinutes  int `json:"health_stable_minutes"`
+	// RollbackOnFailure rolls a server that fails its health check back to
+	// the release it ran before
+	RollbackOnFailure bool      `json:"rollback_on_failure"`
+	Enabled           bool      `json:"enabled"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Normalize trims fields and fills in defaults
+func (p *Policy) Normalize() {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Group = strings.TrimSpace(p.Group)
+	p.Patchline = strings.TrimSpace(p.Patchline)
+	p.WindowID = strings.TrimSpace(p.WindowID)
+	ids := make([]string, 0, len(p.ServerIDs))
+	for _, id := range p.ServerIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	p.ServerIDs = ids
+	if p.Patchline == "" {
+		p.Patchline = "default"
+	}
+	if p.Name == "" {
+		p.Name = "Auto-update " + p.Patchline
+	}
+}
+
+// Validate checks that the policy has targets, a window and sane health
+// check times
+func (p *Policy) Validate() error {
+	if len(p.ServerIDs) == 0 && p.Group == "" {
+		return fmt.Errorf("server_ids or group is required")
+	}
+	if p.WindowID == "" {
+		return fmt.Errorf("window_id is required")
+	}
+	for field, minutes := range map[string]int{"health_startup_minutes": p.HealthStartupMinutes, "health_stable_minutes": p.HealthStableMinutes} {
+		if minutes < 0 || minutes > maxHealthMinutes {
+			return fmt.Errorf("%s must be between 0 and %d", field, maxHealthMinutes)
+		}
+	}
+	return nil
+}
+
+// Covers reports whether the policy applies to a server in group
+func (p *Policy) Covers(serverID, group string) bool {
+	if p.Group != "" && strings.EqualFold(p.Group, group) {
+		return true
+	}
+	for _, id := range p.ServerIDs {
+		if id == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+// Run is one auto-update of one server, recorded as a server task whose
+// output streams like any other
+type Run struct {
+	ID          int64      `json:"id"`
+	PolicyID    string     `json:"policy_id"`
+	ServerID    string     `json:"server_id"`
+	ReleaseID   int64      `json:"release_id"`
+	PackageName string     `json:"package_name"`
+	Version     string     `json:"version"`
+	TaskID      string     `json:"task_id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,225 @@
+package autoupdate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned for unknown policy IDs
+var ErrNotFound = errors.New("auto-update policy not found")
+
+// Store persists auto-update policies and their runs. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new auto-update store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const policyColumns = `id, name, server_ids, server_group, patchline, window_id, backup, health_startup_minutes, health_stable_minutes, rollback_on_failure, enabled, created_at, updated_at`
+
+const runColumns = `id, policy_id, server_id, release_id, package_name, version, task_id, status, error, started_at, finished_at`
+
+// Get returns one policy
+func (s *Store) Get(ctx context.Context, id string) (*Policy, error) {
+	policy, err := scanPolicy(s.db.QueryRowContext(ctx, `SELECT `+policyColumns+` FROM auto_update_policies WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load auto-update policy: %w", err)
+	}
+	return policy, nil
+}
+
+// List returns every policy
+func (s *Store) List(ctx context.Context) ([]*Policy, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+policyColumns+` FROM auto_update_policies ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auto-update policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := make([]*Policy, 0)
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan auto-update policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// Save creates or updates a policy
+func (s *Store) Save(ctx context.Context, policy *Policy) error {
+	now := time.Now().UTC()
+	if policy.CreatedAt.IsZero() {
+		policy.CreatedAt = now
+	}
+	policy.UpdatedAt = now
+
+	serverIDs, _ := json.Marshal(policy.ServerIDs)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO auto_update_policies (`+policyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			server_ids = excluded.server_ids,
+			server_group = excluded.server_group,
+			patchline = excluded.patchline,
+			window_id = excluded.window_id,
+			backup = excluded.backup,
+			health_startup_minutes = excluded.health_startup_minutes,
+			health_stable_minutes = excluded.health_stable_minutes,
+			rollback_on_failure = excluded.rollback_on_failure,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`,
+		policy.ID, policy.Name, string(serverIDs), nullString(policy.Group), policy.Patchline, policy.WindowID, policy.Backup,
+		policy.HealthStartupMinutes, policy.HealthStableMinutes, policy.RollbackOnFailure, policy.Enabled,
+		policy.CreatedAt, policy.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save auto-update policy: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a policy; its runs are kept. It returns false if no such
+// policy exists.
+func (s *Store) Delete(ctx context.Context, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM auto_update_policies WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete auto-update policy: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// StartRun records a run that is about to start
+func (s *Store) StartRun(ctx context.Context, run *Run) error {
+	run.Status = StatusRunning
+	run.StartedAt = time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO auto_update_runs (policy_id, server_id, release_id, package_name, version, task_id, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.PolicyID, run.ServerID, run.ReleaseID, run.PackageName, run.Version, run.TaskID, run.Status, run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record auto-update run: %w", err)
+	}
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// FinishRun records how a run ended
+func (s *Store) FinishRun(ctx context.Context, run *Run) error {
+	now := time.Now().UTC()
+	run.FinishedAt = &now
+	_, err := s.db.ExecContext(ctx, `UPDATE auto_update_runs SET status = ?, error = ?, finished_at = ? WHERE id = ?`,
+		run.Status, nullString(run.Error), now, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update auto-update run: %w", err)
+	}
+	return nil
+}
+
+// FailInterrupted marks the runs still recorded as running as failed, when
+// the manager starts and no run can be in progress
+func (s *Store) FailInterrupted(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE auto_update_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?`,
+		StatusFailed, "interrupted by a manager restart", time.Now().UTC(), StatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update auto-update runs: %w", err)
+	}
+	return nil
+}
+
+// Attempted reports whether a policy already ran a release on a server,
+// whatever the outcome, so a failed release is not tried again every window
+func (s *Store) Attempted(ctx context.Context, policyID, serverID string, releaseID int64) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM auto_update_runs WHERE policy_id = ? AND server_id = ? AND release_id = ?`,
+		policyID, serverID, releaseID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check auto-update runs: %w", err)
+	}
+	return count > 0, nil
+}
+
+// ListRuns returns the runs of a policy, or of all policies when policyID is
+// empty, newest first
+func (s *Store) ListRuns(ctx context.Context, policyID, serverID string, limit int) ([]*Run, error) {
+	query := `SELECT ` + runColumns + ` FROM auto_update_runs WHERE 1 = 1`
+	var args []any
+	if policyID != "" {
+		query += ` AND policy_id = ?`
+		args = append(args, policyID)
+	}
+	if serverID != "" {
+		query += ` AND server_id = ?`
+		args = append(args, serverID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auto-update runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*Run, 0)
+	for rows.Next() {
+		var (
+			run        Run
+			runError   sql.NullString
+			finishedAt sql.NullTime
+		)
+		if err := rows.Scan(&run.ID, &run.PolicyID, &run.ServerID, &run.ReleaseID, &run.PackageName, &run.Version,
+			&run.TaskID, &run.Status, &runError, &run.StartedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan auto-update run: %w", err)
+		}
+		run.Error = runError.String
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPolicy(row rowScanner) (*Policy, error) {
+	var (
+		policy    Policy
+		serverIDs string
+		group     sql.NullString
+	)
+	if err := row.Scan(&policy.ID, &policy.Name, &serverIDs, &group, &policy.Patchline, &policy.WindowID, &policy.Backup,
+		&policy.HealthStartupMinutes, &policy.HealthStableMinutes, &policy.RollbackOnFailure, &policy.Enabled,
+		&policy.CreatedAt, &policy.UpdatedAt); err != nil {
+		return nil, err
+	}
+	policy.Group = group.String
+	if err := json.Unmarshal([]byte(serverIDs), &policy.ServerIDs); err != nil {
+		return nil, fmt.Errorf("invalid server_ids: %w", err)
+	}
+	return &policy, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -2,9 +2,7 @@ package backup
 
 import (
 	"fmt"
-	"log"
 	"path"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -56,8 +54,8 @@ func (ah *ArchiveHandler) CreateArchive(serverID string, directories []string, e
 	filename := fmt.Sprintf("backup_%s.%s", timestamp, archiveExt)
 	archivePath := path.Join(workingDir, filename)
 
-	log.Printf("[Archive] Creating archive %s for server %s", filename, serverID)
-	log.Printf("[Archive] Backing up directories: %v from %s", directories, workingDir)
+	logger.Info("Creating archive", "filename", filename, "server_id", serverID)
+	logger.Info("Backing up directories", "server_id", serverID, "directories", directories, "working_dir", workingDir)
 
 	// Validate directories exist (relative to workingDir)
 	for _, dir := range directories {
@@ -80,7 +78,7 @@ func (ah *ArchiveHandler) CreateArchive(serverID string, directories []string, e
 	// Use relative paths within the working directory
 	tarCmd := ah.buildTarCommand(directories, exclude, archivePath, workingDir, compression)
 	
-	log.Printf("[Archive] Running tar command: %s", tarCmd)
+	logger.Debug("Running tar command", "server_id", serverID, "tar_cmd", tarCmd)
 	output, err := ah.runCommand(conn, tarCmd, options)
 	if err != nil {
 		// Cleanup temp dir on failure
@@ -90,7 +88,7 @@ func (ah *ArchiveHandler) CreateArchive(serverID string, directories []string, e
 
 	// Cleanup temp directory
 	if _, err := ah.runCommand(conn, fmt.Sprintf("rm -rf '%s'", tempDir), options); err != nil {
-		log.Printf("[Archive] Warning: Failed to cleanup temp directory: %v", err)
+		logger.Warn("Failed to cleanup temp directory", "server_id", serverID, "error", err)
 	}
 
 	// Get archive size
@@ -109,7 +107,7 @@ func (ah *ArchiveHandler) CreateArchive(serverID string, directories []string, e
 	fileCountCmd := fmt.Sprintf("tar -%sf '%s' | wc -l", tarListFlag(compression), archivePath)
 	countOutput, err := ah.runCommand(conn, fileCountCmd, options)
 	if err != nil {
-		log.Printf("[Archive] Warning: Failed to count files: %v", err)
+		logger.Warn("Failed to count files", "server_id", serverID, "error", err)
 	}
 
 	var fileCount int
@@ -125,8 +123,7 @@ func (ah *ArchiveHandler) CreateArchive(serverID string, directories []string, e
 		Compression: compression,
 	}
 
-	log.Printf("[Archive] Archive created successfully: %s (size: %d bytes, files: %d)", 
-		filename, sizeBytes, fileCount)
+	logger.Info("Archive created", "server_id", serverID, "filename", filename, "size_bytes", sizeBytes, "file_count", fileCount)
 
 	return info, nil
 }
@@ -138,8 +135,10 @@ func (ah *ArchiveHandler) buildTarCommand(directories []string, exclude []string
 	for _, dir := range directories {
 		// If path is absolute, try to make it relative to workingDir
 		if path.IsAbs(dir) {
-			relPath, err := filepath.Rel(workingDir, dir)
-			if err == nil && !strings.HasPrefix(relPath, "..") {
+			// Remote paths are always slash-separated, whatever the manager's OS
+			base := path.Clean(workingDir)
+			relPath := strings.TrimPrefix(path.Clean(dir), base+"/")
+			if relPath != path.Clean(dir) && base != "/" {
 				relativePaths = append(relativePaths, relPath)
 			} else {
 				relativePaths = append(relativePaths, dir)
@@ -167,7 +166,7 @@ func (ah *ArchiveHandler) ExtractArchive(serverID, archivePath, destination stri
 		return fmt.Errorf("no SSH connection available for server %s", serverID)
 	}
 
-	log.Printf("[Archive] Extracting archive %s to %s", archivePath, destination)
+	logger.Info("Extracting archive", "server_id", serverID, "archive_path", archivePath, "destination", destination)
 
 	// Ensure destination directory exists
 	mkdirCmd := fmt.Sprintf("mkdir -p '%s'", destination)
@@ -185,7 +184,7 @@ func (ah *ArchiveHandler) ExtractArchive(serverID, archivePath, destination stri
 		return fmt.Errorf("failed to extract archive: %w (output: %s)", err, output)
 	}
 
-	log.Printf("[Archive] Archive extracted successfully to %s", destination)
+	logger.Info("Archive extracted", "server_id", serverID, "destination", destination)
 	return nil
 }
 
@@ -215,14 +214,14 @@ func (ah *ArchiveHandler) DeleteArchive(serverID, archivePath string) error {
 		return fmt.Errorf("no SSH connection available for server %s", serverID)
 	}
 
-	log.Printf("[Archive] Deleting archive %s", archivePath)
+	logger.Info("Deleting archive", "server_id", serverID, "archive_path", archivePath)
 
 	deleteCmd := fmt.Sprintf("rm -f '%s'", archivePath)
 	if _, err := ah.runCommand(conn, deleteCmd, ArchiveOptions{}); err != nil {
 		return fmt.Errorf("failed to delete archive: %w", err)
 	}
 
-	log.Printf("[Archive] Archive deleted successfully")
+	logger.Info("Archive deleted", "server_id", serverID)
 	return nil
 }
 
@@ -233,14 +232,14 @@ func (ah *ArchiveHandler) DeleteArchiveWithOptions(serverID, archivePath string,
 		return fmt.Errorf("no SSH connection available for server %s", serverID)
 	}
 
-	log.Printf("[Archive] Deleting archive %s", archivePath)
+	logger.Info("Deleting archive", "server_id", serverID, "archive_path", archivePath)
 
 	deleteCmd := fmt.Sprintf("rm -f '%s'", archivePath)
 	if _, err := ah.runCommand(conn, deleteCmd, options); err != nil {
 		return fmt.Errorf("failed to delete archive: %w", err)
 	}
 
-	log.Printf("[Archive] Archive deleted successfully")
+	logger.Info("Archive deleted", "server_id", serverID)
 	return nil
 }
 
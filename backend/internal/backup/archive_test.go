@@ -1,11 +1,14 @@
 package backup
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestBuildTarCommandRelativePaths(t *testing.T) {
 	handler := &ArchiveHandler{}
 	cmd := handler.buildTarCommand(
-		[]string{"/srv/data", "world"},
+		[]string{"/srv/data", "/srv/data/region", "/opt/other", "world"},
 		[]string{"*.log"},
 		"/tmp/archive.tar.gz",
 		"/srv",
@@ -14,4 +17,7 @@ func TestBuildTarCommandRelativePaths(t *testing.T) {
 	if cmd == "" {
 		t.Fatalf("expected tar command to be generated")
 	}
+	if !strings.Contains(cmd, "'data' 'data/region' '/opt/other' 'world'") {
+		t.Fatalf("expected paths under the working directory to be relative, got %q", cmd)
+	}
 }
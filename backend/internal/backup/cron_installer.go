@@ -11,22 +11,25 @@ import (
 const cronMarkerPrefix = "# hsm-backup:"
 
 // InstallCronJob ensures a cron entry exists for the schedule on the target server.
-func InstallCronJob(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *config.ServerDefinition, schedule *BackupSchedule) error {
+func InstallCronJob(pool *ssh.ConnectionPool, serverDef *config.ServerDefinition, schedule *BackupSchedule) error {
 	if schedule == nil || !schedule.Enabled || schedule.Schedule == "" {
 		return nil
 	}
+	// restic needs its repository password, which only the manager has, so
+	// those schedules are run by the manager's scheduler instead of cron
+	if schedule.Destination.Type == DestinationRestic {
+		return nil
+	}
 
 	if serverDef == nil {
 		return fmt.Errorf("server definition is required")
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		KnownHostsPath:  cfg.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: cfg.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
 	}
 
 	switch serverDef.Connection.AuthMethod {
@@ -54,10 +57,11 @@ func InstallCronJob(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *con
 		return err
 	}
 
-	marker := cronMarkerPrefix + serverDef.ID
-	if schedule != nil && schedule.ID != "" {
-		marker = cronMarkerPrefix + serverDef.ID + ":" + schedule.ID
+	scheduleID := ""
+	if schedule != nil {
+		scheduleID = schedule.ID
 	}
+	marker := CronMarker(serverDef.ID, scheduleID)
 	current, _ := runCronCommand(conn, "crontab -l 2>/dev/null || true", runAsUser, useSudo)
 	filtered := filterCronLines(current, marker)
 	filtered = append(filtered, cronLine+" "+marker)
@@ -71,18 +75,16 @@ func InstallCronJob(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *con
 }
 
 // RemoveCronJob removes the backup cron entry for a server.
-func RemoveCronJob(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *config.ServerDefinition, schedule *BackupSchedule) error {
+func RemoveCronJob(pool *ssh.ConnectionPool, serverDef *config.ServerDefinition, schedule *BackupSchedule) error {
 	if serverDef == nil {
 		return fmt.Errorf("server definition is required")
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		KnownHostsPath:  cfg.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: cfg.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
 	}
 
 	switch serverDef.Connection.AuthMethod {
@@ -106,10 +108,11 @@ func RemoveCronJob(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *conf
 		useSudo = schedule.UseSudo || runAsUser != ""
 	}
 
-	marker := cronMarkerPrefix + serverDef.ID
-	if schedule != nil && schedule.ID != "" {
-		marker = cronMarkerPrefix + serverDef.ID + ":" + schedule.ID
+	scheduleID := ""
+	if schedule != nil {
+		scheduleID = schedule.ID
 	}
+	marker := CronMarker(serverDef.ID, scheduleID)
 	current, _ := runCronCommand(conn, "crontab -l 2>/dev/null || true", runAsUser, useSudo)
 	filtered := filterCronLines(current, marker)
 	installCmd := buildCrontabInstallCommand(filtered)
@@ -122,18 +125,16 @@ func RemoveCronJob(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *conf
 }
 
 // ReadCronTab returns the current crontab for the given user (via sudo when required).
-func ReadCronTab(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *config.ServerDefinition, runAsUser string, useSudo bool) (string, error) {
+func ReadCronTab(pool *ssh.ConnectionPool, serverDef *config.ServerDefinition, runAsUser string, useSudo bool) (string, error) {
 	if serverDef == nil {
 		return "", fmt.Errorf("server definition is required")
 	}
 
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		KnownHostsPath:  cfg.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: cfg.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
 	}
 
 	switch serverDef.Connection.AuthMethod {
@@ -159,6 +160,45 @@ func ReadCronTab(cfg *config.Config, pool *ssh.ConnectionPool, serverDef *config
 	return strings.TrimSpace(output), nil
 }
 
+// CronMarker returns the comment that tags a schedule's line in the crontab.
+// Entries written before schedules had IDs carry only the server ID.
+func CronMarker(serverID, scheduleID string) string {
+	if scheduleID == "" {
+		return cronMarkerPrefix + serverID
+	}
+	return cronMarkerPrefix + serverID + ":" + scheduleID
+}
+
+// InstalledCronSchedules finds the backup entries for a server in a crontab and
+// returns the cron expression of each, keyed by schedule ID ("" for entries
+// without one).
+func InstalledCronSchedules(crontab string, serverID string) map[string]string {
+	installed := make(map[string]string)
+	for _, line := range strings.Split(crontab, "\n") {
+		trimmed := strings.TrimSpace(line)
+		idx := strings.LastIndex(trimmed, cronMarkerPrefix)
+		if idx < 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		tag := strings.TrimSpace(trimmed[idx+len(cronMarkerPrefix):])
+		scheduleID := ""
+		switch {
+		case tag == serverID:
+		case strings.HasPrefix(tag, serverID+":"):
+			scheduleID = strings.TrimPrefix(tag, serverID+":")
+		default:
+			continue
+		}
+
+		expression := trimmed[:idx]
+		if cmd := strings.Index(expression, " /bin/bash "); cmd >= 0 {
+			expression = expression[:cmd]
+		}
+		installed[scheduleID] = strings.TrimSpace(expression)
+	}
+	return installed
+}
+
 func buildCronLine(serverDef *config.ServerDefinition, schedule *BackupSchedule) (string, error) {
 	compression := normalizeCompression(schedule.Compression)
 	archiveExt := compressionArchiveExtension(compression)
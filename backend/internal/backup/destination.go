@@ -32,7 +32,7 @@ type BackupFile struct {
 
 // DestinationConfig contains configuration for a backup destination
 type DestinationConfig struct {
-	Type string // "local", "sftp", "s3"
+	Type string // "local", "sftp", "s3", "restic"
 	Path string // Base path for backups
 
 	// SFTP specific
@@ -50,6 +50,12 @@ type DestinationConfig struct {
 	S3AccessKey string
 	S3SecretKey string
 	S3Endpoint  string // Optional, for S3-compatible storage
+
+	// Restic specific: the backend the repository lives on, using the fields
+	// above for SFTP and S3, and its password. The password is kept in
+	// server_credentials, never with a schedule.
+	ResticBackend  string
+	ResticPassword string `json:"-"`
 }
 
 // NewDestination creates a new backup destination based on config
@@ -61,6 +67,8 @@ func NewDestination(config *DestinationConfig) (Destination, error) {
 		return NewSFTPDestination(config)
 	case "s3":
 		return NewS3Destination(config)
+	case DestinationRestic:
+		return nil, fmt.Errorf("restic repositories are read and written by restic on the server's host")
 	default:
 		return nil, fmt.Errorf("unsupported destination type: %s", config.Type)
 	}
@@ -3,7 +3,6 @@ package backup
 import (
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"time"
@@ -29,7 +28,7 @@ func (ld *LocalDestination) Upload(filename string, reader io.Reader, sizeBytes
 	}
 
 	destPath := filepath.Join(ld.basePath, filename)
-	log.Printf("[LocalDest] Uploading %s to %s (%d bytes)", filename, destPath, sizeBytes)
+	logger.Info("Uploading", "filename", filename, "dest_path", destPath, "size_bytes", sizeBytes)
 
 	// Create destination file
 	file, err := os.Create(destPath)
@@ -50,14 +49,14 @@ func (ld *LocalDestination) Upload(filename string, reader io.Reader, sizeBytes
 		return fmt.Errorf("size mismatch: expected %d bytes, wrote %d bytes", sizeBytes, written)
 	}
 
-	log.Printf("[LocalDest] Upload complete: %s", filename)
+	logger.Info("Upload complete", "filename", filename)
 	return nil
 }
 
 // Download reads a backup file from the local destination
 func (ld *LocalDestination) Download(filename string, writer io.Writer) error {
 	srcPath := filepath.Join(ld.basePath, filename)
-	log.Printf("[LocalDest] Downloading %s from %s", filename, srcPath)
+	logger.Info("Downloading", "filename", filename, "src_path", srcPath)
 
 	file, err := os.Open(srcPath)
 	if err != nil {
@@ -69,20 +68,20 @@ func (ld *LocalDestination) Download(filename string, writer io.Writer) error {
 		return fmt.Errorf("failed to read backup file: %w", err)
 	}
 
-	log.Printf("[LocalDest] Download complete: %s", filename)
+	logger.Info("Download complete", "filename", filename)
 	return nil
 }
 
 // Delete removes a backup file from the local destination
 func (ld *LocalDestination) Delete(filename string) error {
 	destPath := filepath.Join(ld.basePath, filename)
-	log.Printf("[LocalDest] Deleting %s", destPath)
+	logger.Info("Deleting", "dest_path", destPath)
 
 	if err := os.Remove(destPath); err != nil {
 		return fmt.Errorf("failed to delete backup file: %w", err)
 	}
 
-	log.Printf("[LocalDest] Delete complete: %s", filename)
+	logger.Info("Delete complete", "filename", filename)
 	return nil
 }
 
@@ -106,7 +105,7 @@ func (ld *LocalDestination) List() ([]BackupFile, error) {
 
 		info, err := entry.Info()
 		if err != nil {
-			log.Printf("[LocalDest] Warning: Failed to get info for %s: %v", entry.Name(), err)
+			logger.Warn("Failed to get info", "name", entry.Name(), "error", err)
 			continue
 		}
 
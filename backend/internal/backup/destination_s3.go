@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"path"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -51,8 +50,7 @@ func NewS3Destination(config *DestinationConfig) (*S3Destination, error) {
 		s3Client: s3Client,
 	}
 
-	log.Printf("[S3Dest] Initialized S3 destination: bucket=%s, region=%s", 
-		config.S3Bucket, config.S3Region)
+	logger.Info("Initialized S3 destination", "bucket", config.S3Bucket, "region", config.S3Region)
 
 	return dest, nil
 }
@@ -60,8 +58,7 @@ func NewS3Destination(config *DestinationConfig) (*S3Destination, error) {
 // Upload uploads a backup file to S3
 func (sd *S3Destination) Upload(filename string, reader io.Reader, sizeBytes int64) error {
 	key := path.Join(sd.config.Path, filename)
-	log.Printf("[S3Dest] Uploading %s to s3://%s/%s (%d bytes)", 
-		filename, sd.config.S3Bucket, key, sizeBytes)
+	logger.Info("Uploading to S3", "filename", filename, "bucket", sd.config.S3Bucket, "key", key, "size_bytes", sizeBytes)
 
 	// Read all data into memory (required for S3 PutObject)
 	// For large files, consider using multipart upload
@@ -84,15 +81,14 @@ func (sd *S3Destination) Upload(filename string, reader io.Reader, sizeBytes int
 		return fmt.Errorf("failed to upload to S3: %w", err)
 	}
 
-	log.Printf("[S3Dest] Upload complete: %s", filename)
+	logger.Info("Upload complete", "filename", filename)
 	return nil
 }
 
 // Download downloads a backup file from S3
 func (sd *S3Destination) Download(filename string, writer io.Writer) error {
 	key := path.Join(sd.config.Path, filename)
-	log.Printf("[S3Dest] Downloading %s from s3://%s/%s", 
-		filename, sd.config.S3Bucket, key)
+	logger.Info("Downloading from S3", "filename", filename, "bucket", sd.config.S3Bucket, "key", key)
 
 	// Get object from S3
 	result, err := sd.s3Client.GetObject(&s3.GetObjectInput{
@@ -110,14 +106,14 @@ func (sd *S3Destination) Download(filename string, writer io.Writer) error {
 		return fmt.Errorf("failed to read S3 object: %w", err)
 	}
 
-	log.Printf("[S3Dest] Download complete: %s", filename)
+	logger.Info("Download complete", "filename", filename)
 	return nil
 }
 
 // Delete removes a backup file from S3
 func (sd *S3Destination) Delete(filename string) error {
 	key := path.Join(sd.config.Path, filename)
-	log.Printf("[S3Dest] Deleting s3://%s/%s", sd.config.S3Bucket, key)
+	logger.Info("Deleting from S3", "bucket", sd.config.S3Bucket, "key", key)
 
 	_, err := sd.s3Client.DeleteObject(&s3.DeleteObjectInput{
 		Bucket: aws.String(sd.config.S3Bucket),
@@ -128,7 +124,7 @@ func (sd *S3Destination) Delete(filename string) error {
 		return fmt.Errorf("failed to delete from S3: %w", err)
 	}
 
-	log.Printf("[S3Dest] Delete complete: %s", filename)
+	logger.Info("Delete complete", "filename", filename)
 	return nil
 }
 
@@ -139,7 +135,7 @@ func (sd *S3Destination) List() ([]BackupFile, error) {
 		prefix = prefix + "/"
 	}
 
-	log.Printf("[S3Dest] Listing objects with prefix: %s", prefix)
+	logger.Debug("Listing S3 objects", "prefix", prefix)
 
 	result, err := sd.s3Client.ListObjectsV2(&s3.ListObjectsV2Input{
 		Bucket: aws.String(sd.config.S3Bucket),
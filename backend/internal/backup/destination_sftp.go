@@ -3,7 +3,6 @@ package backup
 import (
 	"fmt"
 	"io"
-	"log"
 	"path"
 	"time"
 
@@ -80,7 +79,7 @@ func (sd *SFTPDestination) connect() error {
 
 	// Connect to SSH server
 	addr := fmt.Sprintf("%s:%d", sd.config.SFTPHost, sd.config.SFTPPort)
-	log.Printf("[SFTPDest] Connecting to %s...", addr)
+	logger.Info("Connecting to SFTP destination", "addr", addr)
 
 	sshClient, err := xssh.Dial("tcp", addr, sshConfig)
 	if err != nil {
@@ -106,7 +105,7 @@ func (sd *SFTPDestination) connect() error {
 		return fmt.Errorf("failed to create base directory: %w", err)
 	}
 
-	log.Printf("[SFTPDest] Connected successfully")
+	logger.Info("Connected to SFTP destination", "addr", addr)
 	return nil
 }
 
@@ -124,7 +123,7 @@ func (sd *SFTPDestination) Close() error {
 // Upload uploads a backup file to the SFTP destination
 func (sd *SFTPDestination) Upload(filename string, reader io.Reader, sizeBytes int64) error {
 	destPath := path.Join(sd.config.Path, filename)
-	log.Printf("[SFTPDest] Uploading %s to %s (%d bytes)", filename, destPath, sizeBytes)
+	logger.Info("Uploading", "filename", filename, "dest_path", destPath, "size_bytes", sizeBytes)
 
 	// Create destination file
 	file, err := sd.sftpClient.Create(destPath)
@@ -145,14 +144,14 @@ func (sd *SFTPDestination) Upload(filename string, reader io.Reader, sizeBytes i
 		return fmt.Errorf("size mismatch: expected %d bytes, wrote %d bytes", sizeBytes, written)
 	}
 
-	log.Printf("[SFTPDest] Upload complete: %s", filename)
+	logger.Info("Upload complete", "filename", filename)
 	return nil
 }
 
 // Download downloads a backup file from the SFTP destination
 func (sd *SFTPDestination) Download(filename string, writer io.Writer) error {
 	srcPath := path.Join(sd.config.Path, filename)
-	log.Printf("[SFTPDest] Downloading %s from %s", filename, srcPath)
+	logger.Info("Downloading", "filename", filename, "src_path", srcPath)
 
 	file, err := sd.sftpClient.Open(srcPath)
 	if err != nil {
@@ -164,20 +163,20 @@ func (sd *SFTPDestination) Download(filename string, writer io.Writer) error {
 		return fmt.Errorf("failed to read remote file: %w", err)
 	}
 
-	log.Printf("[SFTPDest] Download complete: %s", filename)
+	logger.Info("Download complete", "filename", filename)
 	return nil
 }
 
 // Delete removes a backup file from the SFTP destination
 func (sd *SFTPDestination) Delete(filename string) error {
 	destPath := path.Join(sd.config.Path, filename)
-	log.Printf("[SFTPDest] Deleting %s", destPath)
+	logger.Info("Deleting", "dest_path", destPath)
 
 	if err := sd.sftpClient.Remove(destPath); err != nil {
 		return fmt.Errorf("failed to delete remote file: %w", err)
 	}
 
-	log.Printf("[SFTPDest] Delete complete: %s", filename)
+	logger.Info("Delete complete", "filename", filename)
 	return nil
 }
 
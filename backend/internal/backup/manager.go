@@ -2,22 +2,30 @@ package backup
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/credentials"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
 	"github.com/google/uuid"
 	"github.com/pkg/sftp"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 )
 
+// logger is shared by the backup package
+var logger = logging.For("backup")
+
 // BackupManager orchestrates backup operations
 type BackupManager struct {
 	db            *sql.DB
 	sshPool       *ssh.ConnectionPool
 	archiveHandler *ArchiveHandler
+	hooks          *hooks.Runner
+	credentials    *credentials.Store
 }
 
 // BackupRequest represents a backup creation request
@@ -54,13 +62,33 @@ func NewBackupManager(db *sql.DB, pool *ssh.ConnectionPool) *BackupManager {
 		db:             db,
 		sshPool:        pool,
 		archiveHandler: NewArchiveHandler(pool),
+		credentials:    credentials.NewStore(db),
 	}
 }
 
-// CreateBackup creates a new backup
+// SetHooks runs post_backup hooks after each backup
+func (bm *BackupManager) SetHooks(runner *hooks.Runner) {
+	bm.hooks = runner
+}
+
+// CreateBackup creates a new backup and then runs the post_backup hooks,
+// whether it succeeded or not
 func (bm *BackupManager) CreateBackup(req *BackupRequest) (*BackupRecord, error) {
+	record, err := bm.createBackup(req)
+
+	details := map[string]interface{}{"destination_type": req.Destination.Type}
+	if record != nil {
+		details["backup_id"] = record.ID
+		details["filename"] = record.Filename
+		details["size_bytes"] = record.SizeBytes
+	}
+	bm.hooks.Run(context.Background(), hooks.NewEvent(hooks.PostBackup, req.ServerID, details).WithResult(err))
+	return record, err
+}
+
+func (bm *BackupManager) createBackup(req *BackupRequest) (*BackupRecord, error) {
 	backupID := "backup-" + uuid.New().String()[:8]
-	log.Printf("[BackupMgr] Creating backup %s for server %s", backupID, req.ServerID)
+	logger.Info("Creating backup", "backup_id", backupID, "server_id", req.ServerID)
 
 	// Create initial backup record
 	record := &BackupRecord{
@@ -77,6 +105,21 @@ func (bm *BackupManager) CreateBackup(req *BackupRequest) (*BackupRecord, error)
 		return nil, fmt.Errorf("failed to save backup record: %w", err)
 	}
 
+	if req.Destination.Type == DestinationRestic {
+		if err := bm.createResticBackup(req, record); err != nil {
+			record.Status = "failed"
+			record.ErrorMessage = err.Error()
+			bm.saveBackupRecord(record)
+			return nil, fmt.Errorf("failed to create restic snapshot: %w", err)
+		}
+		record.Status = "completed"
+		if err := bm.saveBackupRecord(record); err != nil {
+			logger.Warn("Failed to update backup status", "error", err)
+		}
+		logger.Info("Backup created", "backup_id", backupID, "snapshot", record.Filename, "data_added", record.SizeBytes)
+		return record, nil
+	}
+
 	// Create archive on remote server
 	archiveInfo, err := bm.archiveHandler.CreateArchive(req.ServerID, req.Directories, req.Exclude, req.WorkingDir, ArchiveOptions{
 		Compression: req.Compression,
@@ -119,7 +162,7 @@ func (bm *BackupManager) CreateBackup(req *BackupRequest) (*BackupRecord, error)
 	// Mark as completed
 	record.Status = "completed"
 	if err := bm.saveBackupRecord(record); err != nil {
-		log.Printf("[BackupMgr] Warning: Failed to update backup status: %v", err)
+		logger.Warn("Failed to update backup status", "error", err)
 	}
 
 	// Cleanup local archive after successful transfer (optional, depends on destination type)
@@ -128,19 +171,18 @@ func (bm *BackupManager) CreateBackup(req *BackupRequest) (*BackupRecord, error)
 			RunAsUser: req.RunAsUser,
 			UseSudo:   req.UseSudo,
 		}); err != nil {
-			log.Printf("[BackupMgr] Warning: Failed to cleanup local archive: %v", err)
+			logger.Warn("Failed to cleanup local archive", "error", err)
 		}
 	}
 
-	log.Printf("[BackupMgr] Backup %s created successfully: %s (%d bytes)", 
-		backupID, archiveInfo.Filename, archiveInfo.SizeBytes)
+	logger.Info("Backup created", "backup_id", backupID, "filename", archiveInfo.Filename, "size_bytes", archiveInfo.SizeBytes)
 
 	return record, nil
 }
 
 // transferToDestination transfers the backup to the configured destination
 func (bm *BackupManager) transferToDestination(serverID string, archiveInfo *ArchiveInfo, destConfig *DestinationConfig) error {
-	log.Printf("[BackupMgr] Transferring backup to %s destination", destConfig.Type)
+	logger.Info("Transferring backup to destination", "server_id", serverID, "type", destConfig.Type)
 
 	// Create destination
 	dest, err := NewDestination(destConfig)
@@ -188,13 +230,13 @@ func (bm *BackupManager) transferToDestination(serverID string, archiveInfo *Arc
 		return fmt.Errorf("failed to upload to destination: %w", err)
 	}
 
-	log.Printf("[BackupMgr] Transfer complete")
+	logger.Info("Transfer complete", "server_id", serverID)
 	return nil
 }
 
 // RestoreBackup restores a backup to the server
 func (bm *BackupManager) RestoreBackup(backupID, serverID, destination string) error {
-	log.Printf("[BackupMgr] Restoring backup %s to %s", backupID, destination)
+	logger.Info("Restoring backup", "server_id", serverID, "backup_id", backupID, "destination", destination)
 
 	// Get backup record
 	record, err := bm.GetBackup(backupID)
@@ -202,12 +244,12 @@ func (bm *BackupManager) RestoreBackup(backupID, serverID, destination string) e
 		return fmt.Errorf("failed to get backup record: %w", err)
 	}
 
-	if record.ServerID != serverID {
-		return fmt.Errorf("backup does not belong to server %s", serverID)
+	if err := checkRestorable(record, serverID); err != nil {
+		return err
 	}
 
-	if record.Status != "completed" {
-		return fmt.Errorf("backup is not in completed state: %s", record.Status)
+	if record.DestinationType == DestinationRestic {
+		return bm.restoreSnapshot(record, destination)
 	}
 
 	// Create destination config
@@ -268,16 +310,16 @@ func (bm *BackupManager) RestoreBackup(backupID, serverID, destination string) e
 
 	// Cleanup temp file
 	if err := bm.archiveHandler.DeleteArchive(serverID, tempPath); err != nil {
-		log.Printf("[BackupMgr] Warning: Failed to cleanup temp file: %v", err)
+		logger.Warn("Failed to cleanup temp file", "server_id", serverID, "error", err)
 	}
 
-	log.Printf("[BackupMgr] Backup %s restored successfully to %s", backupID, destination)
+	logger.Info("Backup restored", "server_id", serverID, "backup_id", backupID, "destination", destination)
 	return nil
 }
 
 // DeleteBackup deletes a backup
 func (bm *BackupManager) DeleteBackup(backupID string) error {
-	log.Printf("[BackupMgr] Deleting backup %s", backupID)
+	logger.Info("Deleting backup", "backup_id", backupID)
 
 	// Get backup record
 	record, err := bm.GetBackup(backupID)
@@ -285,6 +327,18 @@ func (bm *BackupManager) DeleteBackup(backupID string) error {
 		return fmt.Errorf("failed to get backup record: %w", err)
 	}
 
+	if record.DestinationType == DestinationRestic {
+		if err := bm.forgetSnapshot(record); err != nil {
+			return err
+		}
+		record.Status = "deleted"
+		if err := bm.saveBackupRecord(record); err != nil {
+			return fmt.Errorf("failed to update backup record: %w", err)
+		}
+		logger.Info("Backup deleted", "backup_id", backupID, "snapshot", record.Filename)
+		return nil
+	}
+
 	// Create destination
 	destConfig := &DestinationConfig{
 		Type: record.DestinationType,
@@ -302,7 +356,7 @@ func (bm *BackupManager) DeleteBackup(backupID string) error {
 
 	// Delete from destination
 	if err := dest.Delete(record.Filename); err != nil {
-		log.Printf("[BackupMgr] Warning: Failed to delete from destination: %v", err)
+		logger.Warn("Failed to delete from destination", "error", err)
 	}
 
 	// Update database record
@@ -311,7 +365,7 @@ func (bm *BackupManager) DeleteBackup(backupID string) error {
 		return fmt.Errorf("failed to update backup record: %w", err)
 	}
 
-	log.Printf("[BackupMgr] Backup %s deleted successfully", backupID)
+	logger.Info("Backup deleted", "backup_id", backupID)
 	return nil
 }
 
@@ -367,7 +421,7 @@ func (bm *BackupManager) ListBackups(serverID string) ([]*BackupRecord, error) {
 
 		if metadataJSON.Valid {
 			if err := json.Unmarshal([]byte(metadataJSON.String), &record.Metadata); err != nil {
-				log.Printf("[BackupMgr] Warning: Failed to parse metadata: %v", err)
+				logger.Warn("Failed to parse metadata", "server_id", serverID, "error", err)
 			}
 		}
 
@@ -407,7 +461,7 @@ func (bm *BackupManager) GetBackup(backupID string) (*BackupRecord, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("backup not found: %s", backupID)
+		return nil, fmt.Errorf("%w: %s", ErrBackupNotFound, backupID)
 	}
 
 	if err != nil {
@@ -424,7 +478,7 @@ func (bm *BackupManager) GetBackup(backupID string) (*BackupRecord, error) {
 
 	if metadataJSON.Valid {
 		if err := json.Unmarshal([]byte(metadataJSON.String), &record.Metadata); err != nil {
-			log.Printf("[BackupMgr] Warning: Failed to parse metadata: %v", err)
+			logger.Warn("Failed to parse metadata", "error", err)
 		}
 	}
 
@@ -439,10 +493,21 @@ func (bm *BackupManager) saveBackupRecord(record *BackupRecord) error {
 	}
 
 	query := `
-		INSERT OR REPLACE INTO backups 
+		INSERT INTO backups 
 		(id, server_id, filename, size_bytes, created_at, destination_type, 
 		 destination_path, status, error_message, metadata, created_by)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			server_id = excluded.server_id,
+			filename = excluded.filename,
+			size_bytes = excluded.size_bytes,
+			created_at = excluded.created_at,
+			destination_type = excluded.destination_type,
+			destination_path = excluded.destination_path,
+			status = excluded.status,
+			error_message = excluded.error_message,
+			metadata = excluded.metadata,
+			created_by = excluded.created_by
 	`
 
 	_, err = bm.db.Exec(query,
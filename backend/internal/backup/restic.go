@@ -0,0 +1,529 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/credentials"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/google/uuid"
+)
+
+// DestinationRestic keeps backups as snapshots in a deduplicated, encrypted
+// restic repository. restic runs on the server's host and reads the server's
+// files directly, so no archive is made and only changed data is stored.
+const DestinationRestic = "restic"
+
+// Backends a restic repository can live on
+const (
+	// ResticBackendLocal is a directory on the server's host
+	ResticBackendLocal = "local"
+	// ResticBackendSFTP is an SFTP server the host reaches with its own SSH keys
+	ResticBackendSFTP = "sftp"
+	ResticBackendS3   = "s3"
+)
+
+// Credential types of restic destinations, kept per server in server_credentials
+const (
+	CredentialResticPassword    = "restic_password"
+	CredentialResticS3AccessKey = "restic_s3_access_key"
+	CredentialResticS3SecretKey = "restic_s3_secret_key"
+)
+
+// minResticVersion is the oldest restic whose JSON output is read
+const minResticVersion = "0.10.0"
+
+//go:embed restic_install.sh
+var resticInstallScript string
+
+var resticVersionPattern = regexp.MustCompile(`restic (\d+)\.(\d+)\.(\d+)`)
+
+// Snapshot is a snapshot in a restic repository
+type Snapshot struct {
+	ID       string    `json:"id"`
+	ShortID  string    `json:"short_id"`
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Paths    []string  `json:"paths"`
+	Tags     []string  `json:"tags,omitempty"`
+	// BackupID is the backup recorded for the snapshot, if any
+	BackupID string `json:"backup_id,omitempty"`
+}
+
+// resticSummary is the last message of restic backup --json
+type resticSummary struct {
+	MessageType         string `json:"message_type"`
+	SnapshotID          string `json:"snapshot_id"`
+	FilesNew            int    `json:"files_new"`
+	FilesChanged        int    `json:"files_changed"`
+	TotalFilesProcessed int    `json:"total_files_processed"`
+	TotalBytesProcessed int64  `json:"total_bytes_processed"`
+	DataAdded           int64  `json:"data_added"`
+}
+
+// ResticRepository returns the repository restic is given for a destination.
+// For the SFTP backend, Path may also name the server itself as user@host:/path.
+func ResticRepository(config *DestinationConfig) (string, error) {
+	repoPath := strings.TrimSpace(config.Path)
+	switch config.ResticBackend {
+	case "", ResticBackendLocal:
+		if !path.IsAbs(repoPath) {
+			return "", fmt.Errorf("a local restic repository needs an absolute path, got %q", repoPath)
+		}
+		return path.Clean(repoPath), nil
+	case ResticBackendSFTP:
+		if config.SFTPHost == "" {
+			if !strings.Contains(repoPath, ":") {
+				return "", fmt.Errorf("an SFTP restic repository needs sftp_host or a path such as user@host:/srv/restic")
+			}
+			return "sftp:" + repoPath, nil
+		}
+		host := config.SFTPHost
+		if config.SFTPUsername != "" {
+			host = config.SFTPUsername + "@" + host
+		}
+		if !path.IsAbs(repoPath) {
+			return "", fmt.Errorf("an SFTP restic repository needs an absolute path, got %q", repoPath)
+		}
+		if config.SFTPPort != 0 && config.SFTPPort != 22 {
+			return fmt.Sprintf("sftp://%s:%d/%s", host, config.SFTPPort, path.Clean(repoPath)), nil
+		}
+		return "sftp:" + host + ":" + path.Clean(repoPath), nil
+	case ResticBackendS3:
+		if config.S3Bucket == "" {
+			return "", fmt.Errorf("an S3 restic repository needs s3_bucket")
+		}
+		endpoint := strings.TrimSuffix(config.S3Endpoint, "/")
+		if endpoint == "" {
+			endpoint = "s3.amazonaws.com"
+		}
+		repository := "s3:" + endpoint + "/" + config.S3Bucket
+		if prefix := strings.Trim(repoPath, "/"); prefix != "" {
+			repository += "/" + prefix
+		}
+		return repository, nil
+	default:
+		return "", fmt.Errorf("unsupported restic backend: %s", config.ResticBackend)
+	}
+}
+
+// SaveResticCredentials stores the repository password and S3 keys given
+// with a restic destination for the server and clears them from config, so
+// they are not kept in plain text with a schedule. Values left empty keep the
+// stored ones.
+func (bm *BackupManager) SaveResticCredentials(serverID string, config *DestinationConfig) error {
+	for _, secret := range []struct {
+		credentialType string
+		value          *string
+	}{
+		{CredentialResticPassword, &config.ResticPassword},
+		{CredentialResticS3AccessKey, &config.S3AccessKey},
+		{CredentialResticS3SecretKey, &config.S3SecretKey},
+	} {
+		if *secret.value == "" {
+			continue
+		}
+		if err := bm.credentials.Set(serverID, secret.credentialType, *secret.value); err != nil {
+			return fmt.Errorf("failed to save %s: %w", secret.credentialType, err)
+		}
+		*secret.value = ""
+	}
+	return nil
+}
+
+// createResticBackup takes a snapshot of the request's directories
+func (bm *BackupManager) createResticBackup(req *BackupRequest, record *BackupRecord) error {
+	repository, err := ResticRepository(req.Destination)
+	if err != nil {
+		return err
+	}
+	record.DestinationPath = repository
+	if err := bm.SaveResticCredentials(req.ServerID, req.Destination); err != nil {
+		return err
+	}
+	// A new repository gets a generated password
+	if _, err := bm.credentials.Get(req.ServerID, CredentialResticPassword); errors.Is(err, credentials.ErrNotFound) {
+		password, err := generateResticPassword()
+		if err != nil {
+			return err
+		}
+		if err := bm.credentials.Set(req.ServerID, CredentialResticPassword, password); err != nil {
+			return fmt.Errorf("failed to save %s: %w", CredentialResticPassword, err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	options := ArchiveOptions{RunAsUser: req.RunAsUser, UseSudo: req.UseSudo}
+	version, err := bm.InstallRestic(req.ServerID)
+	if err != nil {
+		return err
+	}
+	session, err := bm.openRestic(req.ServerID, repository, options, req.Destination.S3Region)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+	if output, err := session.run("cat config >/dev/null 2>&1 || restic init"); err != nil {
+		return fmt.Errorf("failed to open restic repository %s: %w (output: %s)", repository, err, strings.TrimSpace(output))
+	}
+
+	output, err := session.run(resticBackupArgs(req.ServerID, req.Directories, req.Exclude), "cd '"+escapeSingleQuotes(req.WorkingDir)+"' && pwd")
+	if err != nil {
+		return fmt.Errorf("restic backup failed: %w (output: %s)", err, lastLines(output, 5))
+	}
+	workingDir, summary, err := parseResticBackup(output)
+	if err != nil {
+		return err
+	}
+
+	record.Filename = summary.SnapshotID
+	record.SizeBytes = summary.DataAdded
+	record.Metadata = map[string]interface{}{
+		"directories":    req.Directories,
+		"exclude":        req.Exclude,
+		"file_count":     summary.TotalFilesProcessed,
+		"files_new":      summary.FilesNew,
+		"files_changed":  summary.FilesChanged,
+		"total_bytes":    summary.TotalBytesProcessed,
+		"working_dir":    workingDir,
+		"restic_version": version,
+		"run_as_user":    req.RunAsUser,
+		"use_sudo":       req.UseSudo,
+	}
+	return nil
+}
+
+// InstallRestic makes sure restic is installed on a server's host, installing
+// it with the host's package manager when it is missing, and returns its version
+func (bm *BackupManager) InstallRestic(serverID string) (string, error) {
+	conn := bm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return "", fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+	if output, err := conn.Client.RunCommand(resticInstallScript); err != nil {
+		return "", fmt.Errorf("failed to install restic: %w (output: %s)", err, lastLines(output, 5))
+	}
+	output, err := conn.Client.RunCommand("restic version")
+	if err != nil {
+		return "", fmt.Errorf("failed to run restic: %w", err)
+	}
+	version, err := checkResticVersion(output)
+	if err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// checkResticVersion reads the output of restic version and refuses one
+// older than minResticVersion
+func checkResticVersion(output string) (string, error) {
+	match := resticVersionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return "", fmt.Errorf("unexpected restic version output: %s", strings.TrimSpace(output))
+	}
+	version := strings.Join(match[1:], ".")
+	minimum := resticVersionPattern.FindStringSubmatch("restic " + minResticVersion)
+	for i := 1; i <= 3; i++ {
+		have, _ := strconv.Atoi(match[i])
+		want, _ := strconv.Atoi(minimum[i])
+		if have != want {
+			if have < want {
+				return "", fmt.Errorf("restic %s is too old; %s or newer is needed", version, minResticVersion)
+			}
+			break
+		}
+	}
+	return version, nil
+}
+
+// ListSnapshots lists a server's snapshots in a restic repository, newest first
+func (bm *BackupManager) ListSnapshots(serverID, repository string) ([]Snapshot, error) {
+	session, err := bm.openRestic(serverID, repository, bm.resticOptions(serverID, repository), "")
+	if err != nil {
+		return nil, err
+	}
+	defer session.close()
+	output, err := session.run("snapshots --json --host '" + escapeSingleQuotes(serverID) + "'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w (output: %s)", err, lastLines(output, 5))
+	}
+	snapshots, err := parseSnapshots(output)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := bm.ListBackups(serverID)
+	if err != nil {
+		return nil, err
+	}
+	backups := make(map[string]string)
+	for _, record := range records {
+		if record.DestinationType == DestinationRestic && record.DestinationPath == repository {
+			backups[record.Filename] = record.ID
+		}
+	}
+	for i := range snapshots {
+		snapshots[i].BackupID = backups[snapshots[i].ID]
+	}
+	return snapshots, nil
+}
+
+// ResticRepositories returns the repositories a server has restic backups in
+func (bm *BackupManager) ResticRepositories(serverID string) ([]string, error) {
+	records, err := bm.ListBackups(serverID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var repositories []string
+	for _, record := range records {
+		if record.DestinationType == DestinationRestic && record.Status == "completed" && !seen[record.DestinationPath] {
+			seen[record.DestinationPath] = true
+			repositories = append(repositories, record.DestinationPath)
+		}
+	}
+	return repositories, nil
+}
+
+// forgetSnapshot removes a backup's snapshot from its repository. The data
+// only it used is freed by the next PruneRepository.
+func (bm *BackupManager) forgetSnapshot(record *BackupRecord) error {
+	session, err := bm.openRestic(record.ServerID, record.DestinationPath, recordOptions(record), "")
+	if err != nil {
+		return err
+	}
+	defer session.close()
+	if output, err := session.run("forget '" + escapeSingleQuotes(record.Filename) + "'"); err != nil {
+		return fmt.Errorf("failed to forget snapshot %s: %w (output: %s)", record.Filename, err, lastLines(output, 5))
+	}
+	return nil
+}
+
+// restoreSnapshot restores a backup's snapshot into destination, where its
+// files keep their full paths
+func (bm *BackupManager) restoreSnapshot(record *BackupRecord, destination string) error {
+	session, err := bm.openRestic(record.ServerID, record.DestinationPath, recordOptions(record), "")
+	if err != nil {
+		return err
+	}
+	defer session.close()
+	args := fmt.Sprintf("restore '%s' --target '%s'", escapeSingleQuotes(record.Filename), escapeSingleQuotes(destination))
+	if output, err := session.run(args); err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w (output: %s)", record.Filename, err, lastLines(output, 5))
+	}
+	logger.Info("Backup restored", "server_id", record.ServerID, "backup_id", record.ID, "destination", destination)
+	return nil
+}
+
+// PruneRepository frees the data no snapshot in a restic repository uses
+// anymore
+func (bm *BackupManager) PruneRepository(serverID, repository string) error {
+	logger.Info("Pruning restic repository", "server_id", serverID, "repository", repository)
+	session, err := bm.openRestic(serverID, repository, bm.resticOptions(serverID, repository), "")
+	if err != nil {
+		return err
+	}
+	defer session.close()
+	if output, err := session.run("prune"); err != nil {
+		return fmt.Errorf("failed to prune %s: %w (output: %s)", repository, err, lastLines(output, 5))
+	}
+	return nil
+}
+
+// resticOptions returns how the newest backup in a repository ran, so the
+// repository is opened as the user that owns it
+func (bm *BackupManager) resticOptions(serverID, repository string) ArchiveOptions {
+	records, err := bm.ListBackups(serverID)
+	if err != nil {
+		return ArchiveOptions{}
+	}
+	for _, record := range records {
+		if record.DestinationType == DestinationRestic && record.DestinationPath == repository {
+			return recordOptions(record)
+		}
+	}
+	return ArchiveOptions{}
+}
+
+func recordOptions(record *BackupRecord) ArchiveOptions {
+	runAsUser, _ := record.Metadata["run_as_user"].(string)
+	useSudo, _ := record.Metadata["use_sudo"].(bool)
+	return ArchiveOptions{RunAsUser: runAsUser, UseSudo: useSudo}
+}
+
+// resticSession runs restic on a server's host. The repository, its password
+// and S3 keys are passed in a private environment file rather than on the
+// command line, which is logged.
+type resticSession struct {
+	conn    *ssh.PooledConnection
+	options ArchiveOptions
+	envFile string
+}
+
+func (bm *BackupManager) openRestic(serverID, repository string, options ArchiveOptions, s3Region string) (*resticSession, error) {
+	conn := bm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return nil, fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+	password, err := bm.credentials.Get(serverID, CredentialResticPassword)
+	if errors.Is(err, credentials.ErrNotFound) {
+		return nil, fmt.Errorf("server %s has no restic repository password", serverID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]string{"RESTIC_REPOSITORY": repository, "RESTIC_PASSWORD": password}
+	if s3Region != "" {
+		env["AWS_DEFAULT_REGION"] = s3Region
+	}
+	for name, credentialType := range map[string]string{
+		"AWS_ACCESS_KEY_ID":     CredentialResticS3AccessKey,
+		"AWS_SECRET_ACCESS_KEY": CredentialResticS3SecretKey,
+	} {
+		value, err := bm.credentials.Get(serverID, credentialType)
+		if err == nil {
+			env[name] = value
+		} else if !errors.Is(err, credentials.ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	client, err := conn.Client.NewSFTP()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+	}
+	defer client.Close()
+	envFile := fmt.Sprintf("/tmp/hytalesm-restic-%s.env", uuid.New().String()[:8])
+	file, err := client.OpenFile(envFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restic environment: %w", err)
+	}
+	session := &resticSession{conn: conn, options: options, envFile: envFile}
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		session.close()
+		return nil, fmt.Errorf("failed to protect restic environment: %w", err)
+	}
+	_, err = file.Write([]byte(resticEnvironment(env)))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && options.UseSudo && strings.TrimSpace(options.RunAsUser) != "" {
+		_, err = conn.Client.RunCommand(fmt.Sprintf("sudo chown '%s' '%s'", escapeSingleQuotes(options.RunAsUser), envFile))
+	}
+	if err != nil {
+		session.close()
+		return nil, fmt.Errorf("failed to write restic environment: %w", err)
+	}
+	return session, nil
+}
+
+// run runs restic with args, after the optional shell command before
+func (s *resticSession) run(args string, before ...string) (string, error) {
+	command := fmt.Sprintf("set -a && . '%s' && set +a && restic %s 2>&1", s.envFile, args)
+	if len(before) > 0 {
+		command = strings.Join(before, " && ") + " && " + command
+	}
+	return s.conn.Client.RunCommand(wrapCommandForUser(command, s.options))
+}
+
+func (s *resticSession) close() {
+	command := fmt.Sprintf("rm -f '%s'", s.envFile)
+	if _, err := s.conn.Client.RunCommand(wrapCommandForUser(command, s.options)); err != nil {
+		logger.Warn("Failed to delete restic environment", "path", s.envFile, "error", err)
+	}
+}
+
+func resticEnvironment(env map[string]string) string {
+	var b strings.Builder
+	for _, name := range []string{"RESTIC_REPOSITORY", "RESTIC_PASSWORD", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_DEFAULT_REGION"} {
+		if value, ok := env[name]; ok {
+			fmt.Fprintf(&b, "%s='%s'\n", name, escapeSingleQuotes(value))
+		}
+	}
+	return b.String()
+}
+
+func resticBackupArgs(serverID string, directories, exclude []string) string {
+	args := []string{"backup", "--json", "--host", "'" + escapeSingleQuotes(serverID) + "'", "--tag", "hytalesm"}
+	if excludeArgs := buildExcludeArgs(exclude); excludeArgs != "" {
+		args = append(args, excludeArgs)
+	}
+	args = append(args, "--")
+	for _, dir := range directories {
+		args = append(args, "'"+escapeSingleQuotes(dir)+"'")
+	}
+	return strings.Join(args, " ")
+}
+
+// parseResticBackup reads the working directory printed before restic
+// backup --json and the summary restic prints last
+func parseResticBackup(output string) (string, *resticSummary, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	workingDir := ""
+	var summary *resticSummary
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if workingDir == "" && path.IsAbs(line) {
+			workingDir = line
+			continue
+		}
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var message resticSummary
+		if json.Unmarshal([]byte(line), &message) == nil && message.MessageType == "summary" {
+			summary = &message
+		}
+	}
+	if summary == nil || summary.SnapshotID == "" {
+		return "", nil, fmt.Errorf("restic did not report a snapshot: %s", lastLines(output, 5))
+	}
+	return workingDir, summary, nil
+}
+
+func parseSnapshots(output string) ([]Snapshot, error) {
+	start := strings.Index(output, "[")
+	if start < 0 {
+		return nil, fmt.Errorf("unexpected restic snapshots output: %s", lastLines(output, 5))
+	}
+	var snapshots []Snapshot
+	if err := json.NewDecoder(strings.NewReader(output[start:])).Decode(&snapshots); err != nil {
+		return nil, fmt.Errorf("failed to read restic snapshots: %w", err)
+	}
+	// restic lists the oldest first
+	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	}
+	return snapshots, nil
+}
+
+func generateResticPassword() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate a repository password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// lastLines keeps the end of a command's output for an error message
+func lastLines(output string, n int) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,24 @@
+set -e
+if command -v restic >/dev/null 2>&1; then
+  exit 0
+fi
+echo 'Installing restic'
+export DEBIAN_FRONTEND=noninteractive
+SUDO=''
+if [ $(id -u) -ne 0 ]; then SUDO='sudo -n'; fi
+if command -v apt-get >/dev/null 2>&1; then
+  $SUDO apt-get update -y >/dev/null
+  $SUDO apt-get install -y restic >/dev/null
+elif command -v dnf >/dev/null 2>&1; then
+  $SUDO dnf install -y restic >/dev/null
+elif command -v yum >/dev/null 2>&1; then
+  $SUDO yum install -y restic >/dev/null
+elif command -v pacman >/dev/null 2>&1; then
+  $SUDO pacman -Sy --noconfirm restic >/dev/null
+elif command -v apk >/dev/null 2>&1; then
+  $SUDO apk add --no-cache restic >/dev/null
+else
+  echo 'Unsupported package manager; install restic manually'
+  exit 2
+fi
+command -v restic >/dev/null 2>&1
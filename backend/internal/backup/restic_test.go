@@ -0,0 +1,101 @@
+package backup
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestResticRepository(t *testing.T) {
+	for _, tc := range []struct {
+		config DestinationConfig
+		want   string
+	}{
+		{DestinationConfig{Path: "/srv/restic/"}, "/srv/restic"},
+		{DestinationConfig{ResticBackend: ResticBackendSFTP, Path: "/srv/restic", SFTPHost: "nas", SFTPUsername: "backup"}, "sftp:backup@nas:/srv/restic"},
+		{DestinationConfig{ResticBackend: ResticBackendSFTP, Path: "/srv/restic", SFTPHost: "nas", SFTPPort: 2222}, "sftp://nas:2222//srv/restic"},
+		{DestinationConfig{ResticBackend: ResticBackendSFTP, Path: "backup@nas:/srv/restic"}, "sftp:backup@nas:/srv/restic"},
+		{DestinationConfig{ResticBackend: ResticBackendS3, Path: "/hytale/", S3Bucket: "backups"}, "s3:s3.amazonaws.com/backups/hytale"},
+		{DestinationConfig{ResticBackend: ResticBackendS3, S3Bucket: "backups", S3Endpoint: "https://minio:9000/"}, "s3:https://minio:9000/backups"},
+	} {
+		got, err := ResticRepository(&tc.config)
+		if err != nil || got != tc.want {
+			t.Errorf("%+v: expected %q, got %q (%v)", tc.config, tc.want, got, err)
+		}
+	}
+
+	for _, config := range []DestinationConfig{
+		{Path: "restic"},
+		{ResticBackend: ResticBackendSFTP, Path: "/srv/restic"},
+		{ResticBackend: ResticBackendSFTP, Path: "restic", SFTPHost: "nas"},
+		{ResticBackend: ResticBackendS3, Path: "hytale"},
+		{ResticBackend: "b2", Path: "/srv/restic"},
+	} {
+		if _, err := ResticRepository(&config); err == nil {
+			t.Errorf("expected %+v to be refused", config)
+		}
+	}
+}
+
+func TestCheckResticVersion(t *testing.T) {
+	for output, want := range map[string]string{
+		"restic 0.16.4 compiled with go1.21.6 on linux/amd64": "0.16.4",
+		"restic 0.10.0 compiled with go1.15 on linux/arm64":   "0.10.0",
+		"restic 1.0.0 compiled with go1.24 on linux/amd64":    "1.0.0",
+	} {
+		if got, err := checkResticVersion(output); err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s (%v)", output, want, got, err)
+		}
+	}
+	for _, output := range []string{"restic 0.9.6 compiled with go1.13 on linux/amd64", "sh: restic: not found"} {
+		if _, err := checkResticVersion(output); err == nil {
+			t.Errorf("expected %q to be refused", output)
+		}
+	}
+}
+
+func TestParseResticBackup(t *testing.T) {
+	output := strings.Join([]string{
+		"/home/hytale/server",
+		`{"message_type":"status","percent_done":0.5}`,
+		"warning: file vanished",
+		`{"message_type":"summary","files_new":3,"files_changed":1,"total_files_processed":40,"total_bytes_processed":1048576,"data_added":2048,"snapshot_id":"4f1c2a"}`,
+	}, "\n")
+	workingDir, summary, err := parseResticBackup(output)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if workingDir != "/home/hytale/server" || summary.SnapshotID != "4f1c2a" || summary.DataAdded != 2048 || summary.TotalFilesProcessed != 40 {
+		t.Fatalf("unexpected result %q %+v", workingDir, summary)
+	}
+
+	if _, _, err := parseResticBackup("/home/hytale/server\nFatal: unable to open repository"); err == nil {
+		t.Fatal("expected output without a summary to be refused")
+	}
+}
+
+func TestParseSnapshots(t *testing.T) {
+	snapshots, err := parseSnapshots(`repository 1a2b opened
+[{"id":"old","short_id":"o","time":"2025-06-01T00:00:00Z","hostname":"alpha","paths":["/srv/universe"]},{"id":"new","short_id":"n","time":"2025-06-02T00:00:00Z","hostname":"alpha","paths":["/srv/universe"],"tags":["hytalesm"]}]`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].ID != "new" || snapshots[1].ID != "old" {
+		t.Fatalf("expected the newest snapshot first, got %+v", snapshots)
+	}
+}
+
+func TestResticEnvironmentIsShellSafe(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	password := `it's $(touch pwned) "quoted"`
+	env := resticEnvironment(map[string]string{"RESTIC_REPOSITORY": "/srv/restic", "RESTIC_PASSWORD": password})
+	output, err := exec.Command("sh", "-c", env+`printf %s "$RESTIC_PASSWORD"`).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sh: %v (%s)", err, output)
+	}
+	if string(output) != password {
+		t.Fatalf("expected the password back unchanged, got %q", output)
+	}
+}
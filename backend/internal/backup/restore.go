@@ -0,0 +1,428 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"github.com/pkg/sftp"
+)
+
+// ErrBackupNotFound is returned when no backup matches an ID or a point in time
+var ErrBackupNotFound = errors.New("backup not found")
+
+// restoreDirPrefix names the staging directory a restore extracts into, inside
+// the directory it restores so the swap is a rename on one filesystem
+const restoreDirPrefix = ".restore-"
+
+// RestoreOptions describes where and as whom a staged restore runs
+type RestoreOptions struct {
+	// Target is the absolute directory the backup is restored into, usually
+	// the server's working directory
+	Target    string
+	RunAsUser string
+	UseSudo   bool
+	// Progress receives a line for each step; it may be nil
+	Progress func(string)
+}
+
+// Restore is a backup downloaded, verified and extracted next to the
+// directory it restores. Nothing in Target changes until Apply; Cleanup
+// removes what the restore left on the host.
+type Restore struct {
+	Record *BackupRecord
+	// Entries are the top-level files and directories the backup holds. Apply
+	// replaces each of them in Target as a whole.
+	Entries   []string
+	FileCount int
+	// Overwritten are the files in Target the backup has its own copy of;
+	// Removed are files under Entries the backup does not have
+	Overwritten []string
+	Removed     []string
+
+	conn        *ssh.PooledConnection
+	options     RestoreOptions
+	archivePath string
+	staging     string
+	// staged is where the backup's files are in staging
+	staged string
+}
+
+// FindBackupAt returns the newest completed backup of a server taken at or
+// before at
+func (bm *BackupManager) FindBackupAt(serverID string, at time.Time) (*BackupRecord, error) {
+	records, err := bm.ListBackups(serverID)
+	if err != nil {
+		return nil, err
+	}
+	record := backupAt(records, at)
+	if record == nil {
+		return nil, fmt.Errorf("%w: no completed backup taken at or before %s", ErrBackupNotFound, at.Format(time.RFC3339))
+	}
+	return record, nil
+}
+
+func backupAt(records []*BackupRecord, at time.Time) *BackupRecord {
+	var newest *BackupRecord
+	for _, record := range records {
+		if record.Status != "completed" || record.CreatedAt.After(at) {
+			continue
+		}
+		if newest == nil || record.CreatedAt.After(newest.CreatedAt) {
+			newest = record
+		}
+	}
+	return newest
+}
+
+// checkRestorable refuses a backup of another server or one that did not complete
+func checkRestorable(record *BackupRecord, serverID string) error {
+	if record.ServerID != serverID {
+		return fmt.Errorf("backup does not belong to server %s", serverID)
+	}
+	if record.Status != "completed" {
+		return fmt.Errorf("backup is not in completed state: %s", record.Status)
+	}
+	return nil
+}
+
+type restoreStep struct {
+	progress string
+	run      func() error
+}
+
+// StageRestore downloads a backup to its server's host, checks the archive
+// and extracts it into a staging directory inside options.Target, then works
+// out which files a restore would overwrite or remove. A restic snapshot is
+// restored into the staging directory by restic, which verifies each file.
+// The caller must Cleanup the returned Restore, whether or not it applies it.
+func (bm *BackupManager) StageRestore(ctx context.Context, serverID string, record *BackupRecord, options RestoreOptions) (*Restore, error) {
+	if !path.IsAbs(options.Target) {
+		return nil, fmt.Errorf("restore target must be an absolute path: %q", options.Target)
+	}
+	if err := checkRestorable(record, serverID); err != nil {
+		return nil, err
+	}
+	conn := bm.sshPool.GetExistingConnection(record.ServerID)
+	if conn == nil {
+		return nil, fmt.Errorf("no SSH connection available for server %s", record.ServerID)
+	}
+	target := path.Clean(options.Target)
+	options.Target = target
+	r := &Restore{
+		Record:      record,
+		conn:        conn,
+		options:     options,
+		archivePath: fmt.Sprintf("/tmp/restore_%s_%s", record.ID, path.Base(record.Filename)),
+		staging:     path.Join(target, restoreDirPrefix+record.ID),
+	}
+	r.staged = path.Join(r.staging, "staged")
+	logger.Info("Staging backup restore", "server_id", record.ServerID, "backup_id", record.ID, "target", target)
+
+	steps := []restoreStep{
+		{fmt.Sprintf("Downloading %s (%d bytes)...", record.Filename, record.SizeBytes), r.download},
+		{"Verifying archive...", r.verify},
+		{"Extracting into " + r.staging + "...", r.extract},
+		{"Comparing with " + target + "...", r.compare},
+	}
+	if record.DestinationType == DestinationRestic {
+		steps = []restoreStep{
+			{fmt.Sprintf("Restoring snapshot %s into %s...", record.Filename, r.staging), func() error { return r.restoreSnapshot(bm) }},
+			{"Comparing with " + target + "...", r.compare},
+		}
+	}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			r.Cleanup()
+			return nil, err
+		}
+		r.progress(step.progress)
+		if err := step.run(); err != nil {
+			r.Cleanup()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Apply swaps each top-level entry of the staged backup into Target. The
+// current copies are moved aside first and moved back if a swap fails, so
+// Target ends up either restored or as it was.
+func (r *Restore) Apply() error {
+	staged, previous := r.staged, path.Join(r.staging, "previous")
+	if _, err := r.run(fmt.Sprintf("mkdir -p '%s'", escapeSingleQuotes(previous))); err != nil {
+		return fmt.Errorf("failed to create %s: %w", previous, err)
+	}
+
+	type swap struct {
+		entry   string
+		existed bool
+	}
+	var swapped []swap
+	undo := func(cause error) error {
+		for i := len(swapped) - 1; i >= 0; i-- {
+			current := path.Join(r.options.Target, swapped[i].entry)
+			err := r.remove(current)
+			if err == nil && swapped[i].existed {
+				err = r.move(path.Join(previous, swapped[i].entry), current)
+			}
+			if err != nil {
+				return fmt.Errorf("%w; putting %s back also failed, the previous files are in %s: %v", cause, swapped[i].entry, previous, err)
+			}
+		}
+		return cause
+	}
+
+	for _, entry := range r.Entries {
+		r.progress("Restoring " + entry)
+		current := path.Join(r.options.Target, entry)
+		existed, err := r.exists(current)
+		if err != nil {
+			return undo(fmt.Errorf("failed to check %s: %w", current, err))
+		}
+		if existed {
+			if err := r.move(current, path.Join(previous, entry)); err != nil {
+				return undo(fmt.Errorf("failed to move %s aside: %w", entry, err))
+			}
+		}
+		swapped = append(swapped, swap{entry: entry, existed: existed})
+		if err := r.move(path.Join(staged, entry), current); err != nil {
+			return undo(fmt.Errorf("failed to restore %s: %w", entry, err))
+		}
+	}
+	logger.Info("Backup restored", "server_id", r.Record.ServerID, "backup_id", r.Record.ID, "target", r.options.Target)
+	return nil
+}
+
+// Cleanup deletes the downloaded archive and the staging directory, along
+// with the files Apply replaced
+func (r *Restore) Cleanup() error {
+	var errs []error
+	if _, err := r.conn.Client.RunCommand(fmt.Sprintf("rm -f '%s'", escapeSingleQuotes(r.archivePath))); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete %s: %w", r.archivePath, err))
+	}
+	if err := r.remove(r.staging); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete %s: %w", r.staging, err))
+	}
+	err := errors.Join(errs...)
+	if err != nil {
+		logger.Warn("Failed to clean up restore", "server_id", r.Record.ServerID, "backup_id", r.Record.ID, "error", err)
+	}
+	return err
+}
+
+// download streams the archive from its destination to the host
+func (r *Restore) download() error {
+	dest, err := NewDestination(&DestinationConfig{Type: r.Record.DestinationType, Path: r.Record.DestinationPath})
+	if err != nil {
+		return fmt.Errorf("failed to create destination: %w", err)
+	}
+	if sftpDest, ok := dest.(*SFTPDestination); ok {
+		defer sftpDest.Close()
+	}
+
+	sftpClient, err := r.conn.Client.NewSFTPWithOptions(
+		sftp.MaxPacketUnchecked(131072),
+		sftp.UseConcurrentWrites(true),
+		sftp.MaxConcurrentRequestsPerFile(64),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create SFTP client: %w", err)
+	}
+	defer sftpClient.Close()
+
+	file, err := sftpClient.Create(r.archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create restore file: %w", err)
+	}
+	if err := dest.Download(r.Record.Filename, file); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to download backup: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write restore file: %w", err)
+	}
+	// Extraction may run as the server's user
+	if err := sftpClient.Chmod(r.archivePath, 0644); err != nil {
+		return fmt.Errorf("failed to make restore file readable: %w", err)
+	}
+	info, err := sftpClient.Stat(r.archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to check restore file: %w", err)
+	}
+	if r.Record.SizeBytes > 0 && info.Size() != r.Record.SizeBytes {
+		return fmt.Errorf("downloaded %d bytes but the backup is %d bytes", info.Size(), r.Record.SizeBytes)
+	}
+	return nil
+}
+
+// verify reads the whole archive, which checks its compression, and the
+// paths in it
+func (r *Restore) verify() error {
+	compression := detectCompressionFromFilename(r.Record.Filename)
+	output, err := r.run(fmt.Sprintf("tar -%s '%s'", tarListFlag(compression), escapeSingleQuotes(r.archivePath)))
+	if err != nil {
+		return fmt.Errorf("archive is damaged: %w", err)
+	}
+	entries, files, err := archiveEntries(strings.Split(output, "\n"))
+	if err != nil {
+		return err
+	}
+	r.Entries, r.FileCount = entries, files
+	r.progress(fmt.Sprintf("Archive verified: %d files in %s", files, strings.Join(entries, ", ")))
+	return nil
+}
+
+// archiveEntries reads a tar listing into its top-level entries and number of
+// files. Paths that leave the directory they are extracted into are refused,
+// and restore staging directories are left out.
+func archiveEntries(listing []string) ([]string, int, error) {
+	seen := make(map[string]bool)
+	var entries []string
+	files := 0
+	for _, line := range listing {
+		name := strings.TrimSpace(line)
+		if name == "" {
+			continue
+		}
+		if strings.HasPrefix(name, "/") {
+			return nil, 0, fmt.Errorf("archive has an absolute path: %s", name)
+		}
+		clean := path.Clean(name)
+		if clean == ".." || strings.HasPrefix(clean, "../") {
+			return nil, 0, fmt.Errorf("archive has a path outside its directory: %s", name)
+		}
+		if clean == "." {
+			continue
+		}
+		top, _, _ := strings.Cut(clean, "/")
+		if strings.HasPrefix(top, restoreDirPrefix) {
+			// A restore that was running when the backup was taken
+			continue
+		}
+		if !strings.HasSuffix(name, "/") {
+			files++
+		}
+		if !seen[top] {
+			seen[top] = true
+			entries = append(entries, top)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, 0, errors.New("archive is empty")
+	}
+	sort.Strings(entries)
+	return entries, files, nil
+}
+
+// restoreSnapshot has restic restore a snapshot into staging and reads its
+// files under the working directory the backup was taken in
+func (r *Restore) restoreSnapshot(bm *BackupManager) error {
+	workingDir, _ := r.Record.Metadata["working_dir"].(string)
+	if !path.IsAbs(workingDir) {
+		return fmt.Errorf("snapshot %s does not record the directory it was taken in", r.Record.Filename)
+	}
+	session, err := bm.openRestic(r.Record.ServerID, r.Record.DestinationPath,
+		ArchiveOptions{RunAsUser: r.options.RunAsUser, UseSudo: r.options.UseSudo}, "")
+	if err != nil {
+		return err
+	}
+	defer session.close()
+
+	snapshot := path.Join(r.staging, "snapshot")
+	args := fmt.Sprintf("restore '%s' --target '%s' --verify", escapeSingleQuotes(r.Record.Filename), escapeSingleQuotes(snapshot))
+	output, err := session.run(args, fmt.Sprintf("rm -rf '%s'", escapeSingleQuotes(r.staging)))
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot %s: %w (output: %s)", r.Record.Filename, err, lastLines(output, 5))
+	}
+	r.staged = path.Join(snapshot, workingDir)
+
+	// Directories are listed with a trailing slash, as tar lists them
+	listing, err := r.run(fmt.Sprintf("cd '%s' && find . -mindepth 1 -type d | sed 's|$|/|' && find . ! -type d", escapeSingleQuotes(r.staged)))
+	if err != nil {
+		return fmt.Errorf("failed to list the restored files: %w", err)
+	}
+	entries, files, err := archiveEntries(strings.Split(listing, "\n"))
+	if err != nil {
+		return err
+	}
+	r.Entries, r.FileCount = entries, files
+	r.progress(fmt.Sprintf("Snapshot verified: %d files in %s", files, strings.Join(entries, ", ")))
+	return nil
+}
+
+func (r *Restore) extract() error {
+	staged := r.staged
+	compression := detectCompressionFromFilename(r.Record.Filename)
+	command := fmt.Sprintf("rm -rf '%s' && mkdir -p '%s' && tar -%s '%s' -C '%s' 2>&1",
+		escapeSingleQuotes(r.staging), escapeSingleQuotes(staged), tarExtractFlag(compression),
+		escapeSingleQuotes(r.archivePath), escapeSingleQuotes(staged))
+	if output, err := r.run(command); err != nil {
+		return fmt.Errorf("failed to extract archive: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// compare lists the files the backup would overwrite, and the files under its
+// entries that the swap would remove
+func (r *Restore) compare() error {
+	output, err := r.run(compareCommand(r.options.Target, r.staged, r.Entries))
+	if err != nil {
+		return fmt.Errorf("failed to compare with %s: %w", r.options.Target, err)
+	}
+	r.Overwritten, r.Removed = nil, nil
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(line, "overwrite ./"); ok {
+			r.Overwritten = append(r.Overwritten, name)
+		} else if name, ok := strings.CutPrefix(line, "remove "); ok {
+			r.Removed = append(r.Removed, name)
+		}
+	}
+	sort.Strings(r.Overwritten)
+	sort.Strings(r.Removed)
+	return nil
+}
+
+func compareCommand(target, staged string, entries []string) string {
+	quoted := make([]string, len(entries))
+	for i, entry := range entries {
+		quoted[i] = "'" + escapeSingleQuotes(entry) + "'"
+	}
+	target, staged = escapeSingleQuotes(target), escapeSingleQuotes(staged)
+	return fmt.Sprintf(`cd '%s' && find . ! -type d | while IFS= read -r f; do if [ -e '%s'/"$f" ]; then echo "overwrite $f"; fi; done; `+
+		`cd '%s' && find %s ! -type d 2>/dev/null | while IFS= read -r f; do if [ ! -e '%s'/"$f" ]; then echo "remove $f"; fi; done; true`,
+		staged, target, target, strings.Join(quoted, " "), staged)
+}
+
+func (r *Restore) exists(p string) (bool, error) {
+	output, err := r.run(fmt.Sprintf("if [ -e '%[1]s' ] || [ -L '%[1]s' ]; then echo yes; fi", escapeSingleQuotes(p)))
+	return strings.TrimSpace(output) == "yes", err
+}
+
+func (r *Restore) move(from, to string) error {
+	output, err := r.run(fmt.Sprintf("mv '%s' '%s' 2>&1", escapeSingleQuotes(from), escapeSingleQuotes(to)))
+	if err != nil && strings.TrimSpace(output) != "" {
+		return fmt.Errorf("%w (output: %s)", err, strings.TrimSpace(output))
+	}
+	return err
+}
+
+func (r *Restore) remove(p string) error {
+	_, err := r.run(fmt.Sprintf("rm -rf '%s'", escapeSingleQuotes(p)))
+	return err
+}
+
+func (r *Restore) run(command string) (string, error) {
+	return r.conn.Client.RunCommand(wrapCommandForUser(command, ArchiveOptions{RunAsUser: r.options.RunAsUser, UseSudo: r.options.UseSudo}))
+}
+
+func (r *Restore) progress(line string) {
+	if r.options.Progress != nil {
+		r.options.Progress(line)
+	}
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveEntries(t *testing.T) {
+	entries, files, err := archiveEntries([]string{"./", "./universe/", "./universe/worlds/default/chunk.bin", "config.json", "mods/a.jar", "mods/", ".restore-9/staged/x", ""})
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if strings.Join(entries, ",") != "config.json,mods,universe" || files != 3 {
+		t.Fatalf("unexpected entries %v and %d files", entries, files)
+	}
+
+	for _, listing := range [][]string{
+		{"/etc/passwd"},
+		{"universe/../../outside"},
+		{".restore-123/staged/x"},
+		{"./", ""},
+	} {
+		if _, _, err := archiveEntries(listing); err == nil {
+			t.Errorf("expected %v to be refused", listing)
+		}
+	}
+}
+
+func TestBackupAt(t *testing.T) {
+	day := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	records := []*BackupRecord{
+		{ID: "newest", Status: "completed", CreatedAt: day.Add(48 * time.Hour)},
+		{ID: "failed", Status: "failed", CreatedAt: day.Add(23 * time.Hour)},
+		{ID: "evening", Status: "completed", CreatedAt: day.Add(20 * time.Hour)},
+		{ID: "morning", Status: "completed", CreatedAt: day.Add(8 * time.Hour)},
+	}
+	for at, want := range map[time.Time]string{
+		day.Add(24 * time.Hour): "evening",
+		day.Add(20 * time.Hour): "evening",
+		day.Add(12 * time.Hour): "morning",
+		day.Add(72 * time.Hour): "newest",
+	} {
+		if got := backupAt(records, at); got == nil || got.ID != want {
+			t.Errorf("at %s: expected %s, got %+v", at, want, got)
+		}
+	}
+	if got := backupAt(records, day); got != nil {
+		t.Fatalf("expected no backup before the first, got %s", got.ID)
+	}
+}
+
+func TestCompareCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "server's dir")
+	staged := filepath.Join(target, ".restore-1", "staged")
+	for name, content := range map[string]string{
+		filepath.Join(target, "universe", "level.dat"):     "current",
+		filepath.Join(target, "universe", "new-chunk.bin"): "current",
+		filepath.Join(target, "logs", "latest.log"):        "kept",
+		filepath.Join(staged, "universe", "level.dat"):     "backup",
+		filepath.Join(staged, "universe", "old-chunk.bin"): "backup",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output, err := exec.Command("sh", "-c", compareCommand(filepath.ToSlash(target), filepath.ToSlash(staged), []string{"universe"})).CombinedOutput()
+	if err != nil {
+		t.Fatalf("compare: %v (%s)", err, output)
+	}
+	if got := strings.TrimSpace(string(output)); got != "overwrite ./universe/level.dat\nremove universe/new-chunk.bin" {
+		t.Fatalf("unexpected comparison:\n%s", got)
+	}
+}
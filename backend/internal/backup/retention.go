@@ -3,7 +3,6 @@ package backup
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"sort"
 )
 
@@ -30,11 +29,11 @@ func NewRetentionManager(db *sql.DB, backupMgr *BackupManager) *RetentionManager
 // EnforceRetention enforces retention policy for a server
 func (rm *RetentionManager) EnforceRetention(serverID string, retentionCount int) error {
 	if retentionCount <= 0 {
-		log.Printf("[Retention] No retention policy for server %s (keep all)", serverID)
+		logger.Debug("No retention policy, keeping all backups", "server_id", serverID)
 		return nil
 	}
 
-	log.Printf("[Retention] Enforcing retention policy for server %s (keep %d)", serverID, retentionCount)
+	logger.Info("Enforcing retention policy", "server_id", serverID, "retention_count", retentionCount)
 
 	// Get all completed backups for the server
 	backups, err := rm.backupManager.ListBackups(serverID)
@@ -52,8 +51,7 @@ func (rm *RetentionManager) EnforceRetention(serverID string, retentionCount int
 
 	// If we have fewer backups than the retention count, nothing to do
 	if len(completedBackups) <= retentionCount {
-		log.Printf("[Retention] Current backup count (%d) is within retention policy (%d)", 
-			len(completedBackups), retentionCount)
+		logger.Debug("Backup count within retention policy", "server_id", serverID, "backups", len(completedBackups), "retention_count", retentionCount)
 		return nil
 	}
 
@@ -64,26 +62,35 @@ func (rm *RetentionManager) EnforceRetention(serverID string, retentionCount int
 
 	// Delete old backups beyond retention count
 	deleted := 0
+	// restic only frees space once the forgotten snapshots are pruned
+	prune := make(map[string]bool)
 	for i := retentionCount; i < len(completedBackups); i++ {
 		backup := completedBackups[i]
-		log.Printf("[Retention] Deleting old backup: %s (created: %s)", 
-			backup.ID, backup.CreatedAt.Format("2006-01-02 15:04:05"))
+		logger.Info("Deleting old backup", "server_id", serverID, "backup_id", backup.ID, "created_at", backup.CreatedAt)
 
 		if err := rm.backupManager.DeleteBackup(backup.ID); err != nil {
-			log.Printf("[Retention] Error deleting backup %s: %v", backup.ID, err)
+			logger.Error("Error deleting backup", "server_id", serverID, "backup_id", backup.ID, "error", err)
 			continue
 		}
 
+		if backup.DestinationType == DestinationRestic {
+			prune[backup.DestinationPath] = true
+		}
 		deleted++
 	}
+	for repository := range prune {
+		if err := rm.backupManager.PruneRepository(serverID, repository); err != nil {
+			logger.Error("Error pruning restic repository", "server_id", serverID, "repository", repository, "error", err)
+		}
+	}
 
-	log.Printf("[Retention] Retention enforcement complete: deleted %d backups", deleted)
+	logger.Info("Retention enforcement complete", "server_id", serverID, "deleted", deleted)
 	return nil
 }
 
 // EnforceAllRetentions enforces retention policies for all servers
 func (rm *RetentionManager) EnforceAllRetentions() error {
-	log.Printf("[Retention] Enforcing retention policies for all servers")
+	logger.Info("Enforcing retention policies for all servers")
 
 	// Get all servers with backup schedules (use max retention per server)
 	query := `
@@ -105,19 +112,19 @@ func (rm *RetentionManager) EnforceAllRetentions() error {
 		var retentionCount int
 
 		if err := rows.Scan(&serverID, &retentionCount); err != nil {
-			log.Printf("[Retention] Error scanning row: %v", err)
+			logger.Error("Error scanning row", "error", err)
 			continue
 		}
 
 		if err := rm.EnforceRetention(serverID, retentionCount); err != nil {
-			log.Printf("[Retention] Error enforcing retention for server %s: %v", serverID, err)
+			logger.Error("Error enforcing retention", "server_id", serverID, "error", err)
 			continue
 		}
 
 		enforced++
 	}
 
-	log.Printf("[Retention] Enforced retention policies for %d servers", enforced)
+	logger.Info("Enforced retention policies", "servers", enforced)
 	return nil
 }
 
@@ -1,27 +1,24 @@
 package backup
 
 import (
-	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/robfig/cron/v3"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 )
 
-// ScheduleRunner executes scheduled backups
-// It polls the database for due schedules
-//
+// ScheduleRunner executes backup schedules. Timing is left to the unified
+// scheduler, which calls RunSchedule when a backup task is due.
 type ScheduleRunner struct {
 	cfg          *config.Config
 	sshPool      *ssh.ConnectionPool
 	backupMgr    *BackupManager
 	retentionMgr *RetentionManager
 	store        *ScheduleStore
-	interval     time.Duration
 }
 
 func NewScheduleRunner(cfg *config.Config, dbConn *sql.DB, pool *ssh.ConnectionPool) *ScheduleRunner {
@@ -34,63 +31,49 @@ func NewScheduleRunner(cfg *config.Config, dbConn *sql.DB, pool *ssh.ConnectionP
 		backupMgr:    backupMgr,
 		retentionMgr: retentionMgr,
 		store:        NewScheduleStore(dbConn),
-		interval:     30 * time.Second,
 	}
 }
 
-func (sr *ScheduleRunner) Start(ctx context.Context) {
-	ticker := time.NewTicker(sr.interval)
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				log.Printf("[BackupSchedule] Stopping schedule runner")
-				return
-			case <-ticker.C:
-				sr.runDueSchedules()
-			}
-		}
-	}()
+// SetHooks runs post_backup hooks after scheduled backups
+func (sr *ScheduleRunner) SetHooks(runner *hooks.Runner) {
+	sr.backupMgr.SetHooks(runner)
 }
 
-func (sr *ScheduleRunner) runDueSchedules() {
-	now := time.Now()
-	schedules, err := sr.store.ListDueSchedules(now)
-	if err != nil {
-		log.Printf("[BackupSchedule] Failed to list due schedules: %v", err)
-		return
-	}
-
-	if len(schedules) == 0 {
-		return
-	}
-
-	for _, schedule := range schedules {
-		nextRun, err := computeNextRun(schedule.Schedule, now)
+// RunSchedule creates a backup with the settings of a backup schedule, or
+// with the server's backup defaults when scheduleID is empty, and returns a
+// summary of the backup
+func (sr *ScheduleRunner) RunSchedule(serverID, scheduleID string) (string, error) {
+	schedule := &BackupSchedule{ServerID: serverID}
+	if scheduleID != "" {
+		stored, err := sr.store.GetScheduleByID(serverID, scheduleID)
 		if err != nil {
-			log.Printf("[BackupSchedule] Invalid schedule for server %s: %v", schedule.ServerID, err)
-			continue
+			return "", fmt.Errorf("failed to load backup schedule %s: %w", scheduleID, err)
 		}
+		schedule = stored
 
-		if err := sr.store.UpdateRuns(schedule.ID, now, nextRun); err != nil {
-			log.Printf("[BackupSchedule] Failed to update run times: %v", err)
+		now := time.Now()
+		if nextRun, err := computeNextRun(schedule.Schedule, now); err == nil {
+			if err := sr.store.UpdateRuns(schedule.ID, now, nextRun); err != nil {
+				logger.Error("Failed to update run times", "error", err)
+			}
 		}
+	}
 
-		go sr.executeSchedule(schedule)
+	record, err := sr.executeSchedule(schedule)
+	if err != nil {
+		return "", err
 	}
+	return fmt.Sprintf("Created backup %s (%s, %d bytes) at %s:%s", record.ID, record.Filename, record.SizeBytes, record.DestinationType, record.DestinationPath), nil
 }
 
-func (sr *ScheduleRunner) executeSchedule(schedule *BackupSchedule) {
+func (sr *ScheduleRunner) executeSchedule(schedule *BackupSchedule) (*BackupRecord, error) {
 	serverDef, err := sr.getServerDefinition(schedule.ServerID)
 	if err != nil {
-		log.Printf("[BackupSchedule] Failed to load server %s: %v", schedule.ServerID, err)
-		return
+		return nil, fmt.Errorf("failed to load server: %w", err)
 	}
 
 	if err := sr.ensureSSHConnection(schedule.ServerID, serverDef); err != nil {
-		log.Printf("[BackupSchedule] Failed SSH connection for server %s: %v", schedule.ServerID, err)
-		return
+		return nil, fmt.Errorf("SSH connection failed: %w", err)
 	}
 
 	directories := schedule.Directories
@@ -99,8 +82,7 @@ func (sr *ScheduleRunner) executeSchedule(schedule *BackupSchedule) {
 	}
 
 	if len(directories) == 0 {
-		log.Printf("[BackupSchedule] No directories configured for server %s", schedule.ServerID)
-		return
+		return nil, fmt.Errorf("no backup directories configured")
 	}
 
 	destination := schedule.Destination
@@ -111,15 +93,16 @@ func (sr *ScheduleRunner) executeSchedule(schedule *BackupSchedule) {
 		destination.S3Endpoint = firstDest.Endpoint
 		destination.S3Bucket = firstDest.Bucket
 		destination.S3Region = firstDest.Region
+		destination.ResticBackend = firstDest.Backend
 	}
 
 	if destination.Type == "" || destination.Path == "" {
-		log.Printf("[BackupSchedule] No destination configured for server %s", schedule.ServerID)
-		return
+		return nil, fmt.Errorf("no backup destination configured")
 	}
 
-	destination.KnownHostsPath = sr.cfg.Security.SSH.KnownHostsPath
-	destination.TrustOnFirstUse = sr.cfg.Security.SSH.TrustOnFirstUse
+	hostKeys := sr.sshPool.HostKeyPolicy()
+	destination.KnownHostsPath = hostKeys.KnownHostsPath
+	destination.TrustOnFirstUse = hostKeys.TrustOnFirstUse
 
 	backupReq := &BackupRequest{
 		ServerID:     schedule.ServerID,
@@ -133,16 +116,22 @@ func (sr *ScheduleRunner) executeSchedule(schedule *BackupSchedule) {
 		CreatedBy:    "scheduler",
 	}
 
-	if _, err := sr.backupMgr.CreateBackup(backupReq); err != nil {
-		log.Printf("[BackupSchedule] Backup failed for server %s: %v", schedule.ServerID, err)
-		return
+	record, err := sr.backupMgr.CreateBackup(backupReq)
+	if err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
 	}
 
-	if schedule.RetentionCount > 0 {
-		if err := sr.retentionMgr.EnforceRetention(schedule.ServerID, schedule.RetentionCount); err != nil {
-			log.Printf("[BackupSchedule] Retention enforcement failed for %s: %v", schedule.ServerID, err)
+	retention := schedule.RetentionCount
+	if schedule.ID == "" {
+		retention = serverDef.Backups.Retention.Count
+	}
+	if retention > 0 {
+		if err := sr.retentionMgr.EnforceRetention(schedule.ServerID, retention); err != nil {
+			logger.Error("Retention enforcement failed", "server_id", schedule.ServerID, "error", err)
 		}
 	}
+
+	return record, nil
 }
 
 func (sr *ScheduleRunner) getServerDefinition(serverID string) (*config.ServerDefinition, error) {
@@ -162,12 +151,10 @@ func (sr *ScheduleRunner) getServerDefinition(serverID string) (*config.ServerDe
 
 func (sr *ScheduleRunner) ensureSSHConnection(serverID string, serverDef *config.ServerDefinition) error {
 	sshConfig := &ssh.ClientConfig{
-		Host:            serverDef.Connection.Host,
-		Port:            serverDef.Connection.Port,
-		Username:        serverDef.Connection.Username,
-		AuthMethod:      serverDef.Connection.AuthMethod,
-		KnownHostsPath:  sr.cfg.Security.SSH.KnownHostsPath,
-		TrustOnFirstUse: sr.cfg.Security.SSH.TrustOnFirstUse,
+		Host:       serverDef.Connection.Host,
+		Port:       serverDef.Connection.Port,
+		Username:   serverDef.Connection.Username,
+		AuthMethod: serverDef.Connection.AuthMethod,
 	}
 
 	switch serverDef.Connection.AuthMethod {
@@ -0,0 +1,90 @@
+// Package cache provides a small in-process cache for read endpoints whose
+// answers are expensive to compute (SSH probes, aggregate queries) but may be
+// a few seconds stale.
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// TTL caches values for a fixed time after they are stored. It is safe for
+// concurrent use. Expired entries are dropped when read or on the next Set.
+type TTL[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[K]entry[V]
+	now     func() time.Time
+}
+
+// New creates a cache whose entries live for ttl
+func New[K comparable, V any](ttl time.Duration) *TTL[K, V] {
+	return &TTL[K, V]{
+		ttl:     ttl,
+		entries: make(map[K]entry[V]),
+		now:     time.Now,
+	}
+}
+
+// Get returns the cached value for key if it has not expired
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value under key
+func (c *TTL[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry[V]{value: value, expires: now.Add(c.ttl)}
+}
+
+// GetOrLoad returns the cached value for key, calling load and caching its
+// result on a miss. Errors are returned to the caller and not cached.
+func (c *TTL[K, V]) GetOrLoad(key K, load func() (V, error)) (V, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	c.Set(key, value)
+	return value, nil
+}
+
+// Delete drops the entry for key
+func (c *TTL[K, V]) Delete(key K) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// Clear drops every entry
+func (c *TTL[K, V]) Clear() {
+	c.mu.Lock()
+	c.entries = make(map[K]entry[V])
+	c.mu.Unlock()
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTTLExpiryAndInvalidation(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := New[string, int](5 * time.Second)
+	c.now = func() time.Time { return now }
+
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return loads, nil
+	}
+
+	if v, _ := c.GetOrLoad("a", load); v != 1 {
+		t.Fatalf("expected first load, got %d", v)
+	}
+	if v, _ := c.GetOrLoad("a", load); v != 1 {
+		t.Fatalf("expected cached value, got %d", v)
+	}
+
+	now = now.Add(5 * time.Second)
+	if v, _ := c.GetOrLoad("a", load); v != 2 {
+		t.Fatalf("expected reload after expiry, got %d", v)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected entry to be invalidated")
+	}
+
+	if _, err := c.GetOrLoad("b", func() (int, error) { return 0, errors.New("probe failed") }); err == nil {
+		t.Fatal("expected load error")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("expected failed load not to be cached")
+	}
+}
@@ -0,0 +1,203 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func setupNodes(t *testing.T, ids ...string) []*Node {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	nodes := make([]*Node, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, New(db.DB, Settings{InstanceID: id, LeaseTTL: 60 * time.Millisecond, PollInterval: 5 * time.Millisecond}))
+	}
+	return nodes
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the cluster")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOneNodeLeadsAndHandsOver(t *testing.T) {
+	nodes := setupNodes(t, "a", "b")
+
+	var mu sync.Mutex
+	running := make(map[string]bool)
+	cancels := make(map[string]context.CancelFunc)
+	for _, node := range nodes {
+		node := node
+		node.OnLead(func(ctx context.Context) {
+			mu.Lock()
+			running[node.ID()] = true
+			mu.Unlock()
+			go func() {
+				<-ctx.Done()
+				mu.Lock()
+				running[node.ID()] = false
+				mu.Unlock()
+			}()
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels[node.ID()] = cancel
+		node.Start(ctx)
+	}
+	t.Cleanup(func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	})
+
+	leader := func() *Node {
+		var found *Node
+		for _, node := range nodes {
+			if node.Leader() {
+				if found != nil {
+					t.Fatalf("both %s and %s lead", found.ID(), node.ID())
+				}
+				found = node
+			}
+		}
+		return found
+	}
+	waitFor(t, func() bool { return leader() != nil })
+	first := leader()
+	time.Sleep(100 * time.Millisecond) // several renewals
+	if leader() != first {
+		t.Fatal("expected the leader to keep its lease while renewing")
+	}
+
+	cancels[first.ID()]()
+	first.Wait()
+	waitFor(t, func() bool { return leader() != nil && leader() != first })
+
+	mu.Lock()
+	defer mu.Unlock()
+	if running[first.ID()] || !running[leader().ID()] {
+		t.Fatalf("expected only the new leader to run its work, got %v", running)
+	}
+}
+
+func TestEventsReachOtherNodes(t *testing.T) {
+	nodes := setupNodes(t, "a", "b")
+
+	var mu sync.Mutex
+	received := make(map[string][]string)
+	for _, node := range nodes {
+		node := node
+		node.Subscribe("greeting", func(data json.RawMessage) {
+			var text string
+			_ = json.Unmarshal(data, &text)
+			mu.Lock()
+			received[node.ID()] = append(received[node.ID()], text)
+			mu.Unlock()
+		})
+	}
+
+	nodes[0].Publish("greeting", "before start") // published before b joined
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	for _, node := range nodes {
+		node.Start(ctx)
+	}
+	nodes[0].Publish("greeting", "hello")
+	nodes[0].Publish("other", "ignored")
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received["b"]) > 0
+	})
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["b"]) != 1 || received["b"][0] != "hello" {
+		t.Fatalf("expected b to receive only the new event, got %v", received["b"])
+	}
+	if len(received["a"]) != 0 {
+		t.Fatalf("expected a not to receive its own events, got %v", received["a"])
+	}
+}
+
+func TestStandaloneNodeLeads(t *testing.T) {
+	node := Standalone()
+	started := false
+	node.OnLead(func(ctx context.Context) { started = true })
+	node.Start(context.Background())
+	if !started || !node.Leader() || node.Clustered() {
+		t.Fatal("expected a standalone node to lead right away")
+	}
+	node.Publish("greeting", "nobody listens")
+}
+
+func TestLockKeepsOtherNodesOut(t *testing.T) {
+	nodes := setupNodes(t, "a", "b")
+	a, b := nodes[0], nodes[1]
+
+	unlockOuter, err := a.Lock(context.Background(), "server:alpha")
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	// A second lock on the same instance shares the lease
+	unlockInner, err := a.Lock(context.Background(), "server:alpha")
+	if err != nil {
+		t.Fatalf("nested lock: %v", err)
+	}
+
+	tryLock := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		unlock, err := b.Lock(ctx, "server:alpha")
+		if err == nil {
+			unlock()
+		}
+		return err
+	}
+	// The lease is renewed for longer than it lives
+	if err := tryLock(); err != context.DeadlineExceeded {
+		t.Fatalf("expected b to wait for a's lock, got %v", err)
+	}
+	if unlock, err := b.Lock(context.Background(), "server:beta"); err != nil {
+		t.Fatalf("expected another server unaffected, got %v", err)
+	} else {
+		unlock()
+	}
+
+	unlockInner()
+	unlockInner()
+	if err := tryLock(); err != context.DeadlineExceeded {
+		t.Fatalf("expected the lease held until its last holder lets go, got %v", err)
+	}
+	unlockOuter()
+	if err := tryLock(); err != nil {
+		t.Fatalf("expected b to lock once a let go, got %v", err)
+	}
+
+	unlock, err := Standalone().Lock(context.Background(), "server:alpha")
+	if err != nil {
+		t.Fatalf("expected a standalone node to lock right away, got %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,103 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// eventRetention is how long published events stay in the database; an
+// instance that falls further behind misses them
+const eventRetention = 10 * time.Minute
+
+// Publish delivers payload, encoded as JSON, to the topic's subscribers on
+// the other instances. Subscribers on this instance are not called. It does
+// nothing on a standalone node.
+func (n *Node) Publish(topic string, payload interface{}) {
+	if !n.Clustered() {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Error("Failed to encode cluster event", "topic", topic, "error", err)
+		return
+	}
+	if _, err := n.db.Exec(`INSERT INTO cluster_events (origin, topic, payload, created_at) VALUES (?, ?, ?, ?)`,
+		n.id, topic, string(data), time.Now().UnixMilli()); err != nil {
+		logger.Error("Failed to publish cluster event", "topic", topic, "error", err)
+	}
+}
+
+// Subscribe registers fn for the events other instances publish on topic
+func (n *Node) Subscribe(topic string, fn func(data json.RawMessage)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers[topic] = append(n.handlers[topic], fn)
+}
+
+func (n *Node) poll(ctx context.Context) {
+	ticker := time.NewTicker(n.settings.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.deliver(ctx)
+		}
+	}
+}
+
+// deliver passes the events published since the last poll to their subscribers
+func (n *Node) deliver(ctx context.Context) {
+	rows, err := n.db.QueryContext(ctx, `
+		SELECT id, origin, topic, payload FROM cluster_events
+		WHERE id > ?
+		ORDER BY id
+	`, n.lastEvent)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warn("Failed to read cluster events", "error", err)
+		}
+		return
+	}
+
+	type event struct {
+		topic   string
+		payload json.RawMessage
+	}
+	var events []event
+	for rows.Next() {
+		var (
+			id            int64
+			origin, topic string
+			payload       string
+		)
+		if err := rows.Scan(&id, &origin, &topic, &payload); err != nil {
+			logger.Warn("Failed to scan cluster event", "error", err)
+			continue
+		}
+		n.lastEvent = id
+		if origin != n.id {
+			events = append(events, event{topic: topic, payload: json.RawMessage(payload)})
+		}
+	}
+	rows.Close()
+
+	for _, ev := range events {
+		n.mu.Lock()
+		handlers := append([]func(json.RawMessage){}, n.handlers[ev.topic]...)
+		n.mu.Unlock()
+		for _, fn := range handlers {
+			fn(ev.payload)
+		}
+	}
+}
+
+// prune removes events every instance has had time to pick up
+func (n *Node) prune(ctx context.Context) {
+	cutoff := time.Now().Add(-eventRetention).UnixMilli()
+	if _, err := n.db.ExecContext(ctx, `DELETE FROM cluster_events WHERE created_at < ?`, cutoff); err != nil && ctx.Err() == nil {
+		logger.Warn("Failed to prune cluster events", "error", err)
+	}
+}
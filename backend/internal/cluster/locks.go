@@ -0,0 +1,104 @@
+package cluster
+
+import (
+	"context"
+	"time"
+)
+
+// heldLease is a lease this instance holds for one or more of its callers
+type heldLease struct {
+	holders int
+	stop    context.CancelFunc
+	done    chan struct{}
+}
+
+// Lock takes the named lease, waiting while another instance holds it, and
+// renews it until the returned function is called. Callers on the same
+// instance share the lease, so a lock taken inside another for the same name
+// does not wait for it; it keeps out other instances, not other goroutines.
+// A standalone node has no one to keep out and locks straight away.
+func (n *Node) Lock(ctx context.Context, name string) (func(), error) {
+	if !n.Clustered() {
+		return func() {}, nil
+	}
+
+	ticker := time.NewTicker(n.settings.PollInterval)
+	defer ticker.Stop()
+	for {
+		held, err := n.take(ctx, name)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("Failed to take lease", "lease", name, "error", err)
+		}
+		if held {
+			return n.unlocker(name), nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// take joins the lease if this instance holds it already, or acquires it and
+// starts renewing it
+func (n *Node) take(ctx context.Context, name string) (bool, error) {
+	n.leasesMu.Lock()
+	defer n.leasesMu.Unlock()
+	if lease, ok := n.leases[name]; ok {
+		lease.holders++
+		return true, nil
+	}
+
+	held, err := n.acquire(ctx, name)
+	if err != nil || !held {
+		return false, err
+	}
+	renewCtx, stop := context.WithCancel(context.Background())
+	lease := &heldLease{holders: 1, stop: stop, done: make(chan struct{})}
+	n.leases[name] = lease
+	go n.renew(renewCtx, name, lease.done)
+	return true, nil
+}
+
+func (n *Node) renew(ctx context.Context, name string, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(n.settings.LeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := n.acquire(ctx, name)
+			if err != nil && ctx.Err() == nil {
+				logger.Warn("Failed to renew lease", "lease", name, "error", err)
+			} else if err == nil && !held {
+				logger.Warn("Lost lease to another instance", "lease", name)
+			}
+		}
+	}
+}
+
+// unlocker returns the function that lets go of the lease once its last
+// holder on this instance is done
+func (n *Node) unlocker(name string) func() {
+	released := false
+	return func() {
+		n.leasesMu.Lock()
+		defer n.leasesMu.Unlock()
+		if released {
+			return
+		}
+		released = true
+
+		lease := n.leases[name]
+		if lease.holders--; lease.holders > 0 {
+			return
+		}
+		delete(n.leases, name)
+		lease.stop()
+		<-lease.done
+		n.release(name)
+	}
+}
@@ -0,0 +1,264 @@
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("cluster")
+
+const (
+	// leaderLease is the lease held by the instance running the schedulers and collectors
+	leaderLease = "leader"
+	// memberLease prefixes the lease each running instance renews
+	memberLease = "instance:"
+)
+
+// Settings controls how a node takes part in the cluster
+type Settings struct {
+	InstanceID   string
+	LeaseTTL     time.Duration
+	PollInterval time.Duration
+}
+
+// SettingsFrom converts the cluster section of the configuration
+func SettingsFrom(cfg config.ClusterConfig) Settings {
+	return Settings{
+		InstanceID:   cfg.InstanceID,
+		LeaseTTL:     parseDuration(cfg.LeaseTTL, 15*time.Second),
+		PollInterval: parseDuration(cfg.PollInterval, time.Second),
+	}
+}
+
+// Node is this manager instance's place among the instances sharing one
+// database. One instance at a time holds the leader lease and runs the
+// schedulers and collectors registered with OnLead; events published on
+// one instance are delivered to the subscribers on the others.
+//
+// A standalone node, used when cluster mode is off, is always the leader and
+// publishes nowhere.
+type Node struct {
+	id       string
+	db       *sql.DB
+	settings Settings
+
+	mu        sync.Mutex
+	leading   bool
+	onLead    []func(ctx context.Context)
+	handlers  map[string][]func(json.RawMessage)
+	lastEvent int64
+	wg        sync.WaitGroup
+
+	leasesMu sync.Mutex
+	leases   map[string]*heldLease
+}
+
+// Standalone returns a node for a manager running on its own
+func Standalone() *Node {
+	hostname, _ := os.Hostname()
+	return &Node{id: hostname, handlers: make(map[string][]func(json.RawMessage))}
+}
+
+// New returns a node that elects a leader and exchanges events through db
+func New(db *sql.DB, settings Settings) *Node {
+	id := settings.InstanceID
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8])
+	}
+	return &Node{
+		id:       id,
+		db:       db,
+		settings: settings,
+		handlers: make(map[string][]func(json.RawMessage)),
+		leases:   make(map[string]*heldLease),
+	}
+}
+
+// ID identifies this instance
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Clustered reports whether other instances may share the database
+func (n *Node) Clustered() bool {
+	return n.db != nil
+}
+
+// Leader reports whether this instance currently holds the lead
+func (n *Node) Leader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leading
+}
+
+// OnLead registers fn to be called whenever this instance becomes the
+// leader. fn must not block; the work it starts should stop when ctx is
+// cancelled, which happens when the lead is lost or the node stops. It
+// must be registered before Start.
+func (n *Node) OnLead(fn func(ctx context.Context)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onLead = append(n.onLead, fn)
+}
+
+// Start takes part in leader elections and picks up events from other
+// instances until ctx is cancelled. A standalone node leads right away.
+func (n *Node) Start(ctx context.Context) {
+	if !n.Clustered() {
+		n.lead(ctx)
+		return
+	}
+
+	if err := n.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM cluster_events`).Scan(&n.lastEvent); err != nil {
+		logger.Error("Failed to read the latest cluster event", "error", err)
+	}
+	logger.Info("Joining cluster", "instance_id", n.id)
+
+	n.wg.Add(2)
+	go func() {
+		defer n.wg.Done()
+		n.elect(ctx)
+	}()
+	go func() {
+		defer n.wg.Done()
+		n.poll(ctx)
+	}()
+}
+
+// Wait blocks until the node has stopped and given up its lease
+func (n *Node) Wait() {
+	n.wg.Wait()
+}
+
+// Instances returns the IDs of the running instances, including this one
+func (n *Node) Instances(ctx context.Context) ([]string, error) {
+	if !n.Clustered() {
+		return []string{n.id}, nil
+	}
+	rows, err := n.db.QueryContext(ctx, `SELECT holder FROM cluster_leases WHERE name LIKE ? AND expires_at >= ?`,
+		memberLease+"%", time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// lead starts the registered work under a context cancelled when the lead
+// is lost, and returns that cancel function
+func (n *Node) lead(ctx context.Context) context.CancelFunc {
+	leadCtx, cancel := context.WithCancel(ctx)
+	n.mu.Lock()
+	n.leading = true
+	fns := append([]func(context.Context){}, n.onLead...)
+	n.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(leadCtx)
+	}
+	return cancel
+}
+
+func (n *Node) elect(ctx context.Context) {
+	ticker := time.NewTicker(n.settings.LeaseTTL / 3)
+	defer ticker.Stop()
+
+	var stopLeading context.CancelFunc
+	for {
+		if _, err := n.acquire(ctx, memberLease+n.id); err != nil && ctx.Err() == nil {
+			logger.Warn("Failed to renew cluster membership", "error", err)
+		}
+		held, err := n.acquire(ctx, leaderLease)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("Failed to renew the leader lease", "error", err)
+		}
+		switch {
+		case held && stopLeading == nil:
+			logger.Info("This instance is now the leader", "instance_id", n.id)
+			stopLeading = n.lead(ctx)
+		case !held && stopLeading != nil:
+			logger.Warn("This instance lost the lead", "instance_id", n.id)
+			n.setLeading(false)
+			stopLeading()
+			stopLeading = nil
+		}
+		if held {
+			n.prune(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			if stopLeading != nil {
+				n.setLeading(false)
+				stopLeading()
+				n.release(leaderLease)
+			}
+			n.release(memberLease + n.id)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Node) setLeading(leading bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.leading = leading
+}
+
+// acquire takes or renews a lease. It fails to take a lease another
+// instance holds until that instance stops renewing it.
+func (n *Node) acquire(ctx context.Context, name string) (bool, error) {
+	now := time.Now()
+	result, err := n.db.ExecContext(ctx, `
+		INSERT INTO cluster_leases (name, holder, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			holder = excluded.holder,
+			expires_at = excluded.expires_at
+		WHERE cluster_leases.holder = excluded.holder OR cluster_leases.expires_at < ?
+	`, name, n.id, now.Add(n.settings.LeaseTTL).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// release gives up a lease so another instance can take over without waiting
+// for it to expire
+func (n *Node) release(name string) {
+	if _, err := n.db.Exec(`DELETE FROM cluster_leases WHERE name = ? AND holder = ?`, name, n.id); err != nil {
+		logger.Warn("Failed to release lease", "lease", name, "error", err)
+	}
+}
+
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
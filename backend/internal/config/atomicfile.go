@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers see either the old or the new content and a crash
+// mid-write never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// writeLockedFile writes path atomically while holding an exclusive lock on
+// path+".lock", so separate processes (the manager and the CLI tools) do not
+// interleave writes
+func writeLockedFile(path string, data []byte, perm os.FileMode) error {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", filepath.Base(path), err)
+	}
+	defer unlock()
+	return writeFileAtomic(path, data, perm)
+}
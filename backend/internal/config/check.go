@@ -0,0 +1,240 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Check severities
+const (
+	CheckError   = "error"
+	CheckWarning = "warning"
+)
+
+// CheckIssue is a single problem found by CheckDeployment
+type CheckIssue struct {
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+}
+
+// scheduleParser accepts the same cron syntax as the backup and self-backup schedulers
+var scheduleParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+type deploymentCheck struct {
+	issues []CheckIssue
+}
+
+type checkField struct {
+	name  string
+	value string
+}
+
+func (d *deploymentCheck) add(severity, file, field, format string, args ...interface{}) {
+	d.issues = append(d.issues, CheckIssue{
+		Severity: severity,
+		File:     file,
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckDeployment inspects a configuration together with servers.yaml and
+// tasks.yaml in its config directory without starting anything. It keeps going
+// after the first problem so every issue is reported at once.
+func CheckDeployment(cfg *Config, configPath string) []CheckIssue {
+	check := &deploymentCheck{issues: []CheckIssue{}}
+	configFile := filepath.Base(configPath)
+
+	if _, err := os.Stat(configPath); err != nil {
+		check.add(CheckWarning, configFile, "", "config file not found at %s, defaults and environment variables are used", configPath)
+	}
+	if err := cfg.Validate(); err != nil {
+		check.add(CheckError, configFile, "", "%v", err)
+	}
+
+	check.durations(cfg, configFile)
+	check.paths(cfg, configFile)
+	check.tls(cfg, configFile)
+
+	if cfg.SelfBackup.Enabled {
+		if _, err := scheduleParser.Parse(cfg.SelfBackup.Schedule); err != nil {
+			check.add(CheckError, configFile, "self_backup.schedule", "invalid cron expression %q: %v", cfg.SelfBackup.Schedule, err)
+		}
+	}
+
+	serverIDs := check.servers(cfg.Storage.ConfigDir)
+	check.tasks(cfg.Storage.ConfigDir, serverIDs)
+
+	return check.issues
+}
+
+func (d *deploymentCheck) durations(cfg *Config, file string) {
+	durations := []checkField{
+		{"auth.access_token_duration", cfg.Auth.AccessTokenDuration},
+		{"auth.refresh_token_duration", cfg.Auth.RefreshTokenDuration},
+		{"auth.password_reset.token_duration", cfg.Auth.PasswordReset.TokenDuration},
+		{"auth.password_reset.resend_cooldown", cfg.Auth.PasswordReset.ResendCooldown},
+	}
+	for _, duration := range durations {
+		if duration.value == "" {
+			continue
+		}
+		if parsed, err := time.ParseDuration(duration.value); err != nil || parsed <= 0 {
+			d.add(CheckError, file, duration.name, "invalid duration %q", duration.value)
+		}
+	}
+}
+
+func (d *deploymentCheck) paths(cfg *Config, file string) {
+	if info, err := os.Stat(cfg.Storage.ConfigDir); err != nil || !info.IsDir() {
+		d.add(CheckError, file, "storage.config_dir", "directory %s does not exist", cfg.Storage.ConfigDir)
+	}
+
+	// These are created on startup, so a missing directory is only worth a warning
+	created := []checkField{
+		{"storage.data_dir", cfg.Storage.DataDir},
+		{"storage.backup_dir", cfg.Storage.BackupDir},
+		{"storage.releases_dir", cfg.Storage.ReleasesDir},
+		{"self_backup.dir", cfg.SelfBackup.Dir},
+	}
+	if !strings.HasPrefix(strings.ToLower(cfg.Database.Driver), "postgres") && cfg.Database.Path != "" {
+		created = append(created, checkField{"database.path", filepath.Dir(cfg.Database.Path)})
+	}
+	for _, dir := range created {
+		if dir.value == "" {
+			continue
+		}
+		if info, err := os.Stat(dir.value); err != nil {
+			d.add(CheckWarning, file, dir.name, "directory %s does not exist yet and will be created", dir.value)
+		} else if !info.IsDir() {
+			d.add(CheckError, file, dir.name, "%s is not a directory", dir.value)
+		}
+	}
+}
+
+func (d *deploymentCheck) tls(cfg *Config, file string) {
+	if !cfg.Server.TLS.Enabled {
+		return
+	}
+	files := []checkField{
+		{"server.tls.cert_file", cfg.Server.TLS.CertFile},
+		{"server.tls.key_file", cfg.Server.TLS.KeyFile},
+	}
+	for _, tlsFile := range files {
+		if tlsFile.value == "" {
+			continue
+		}
+		if _, err := os.Stat(tlsFile.value); err != nil {
+			d.add(CheckError, file, tlsFile.name, "file %s is not readable: %v", tlsFile.value, err)
+			return
+		}
+	}
+	if _, err := tls.LoadX509KeyPair(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile); err != nil {
+		d.add(CheckError, file, "server.tls", "certificate and key do not load: %v", err)
+	}
+}
+
+// servers checks servers.yaml and returns the IDs it defines
+func (d *deploymentCheck) servers(configDir string) map[string]bool {
+	ids := map[string]bool{}
+	data, err := os.ReadFile(filepath.Join(configDir, "servers.yaml"))
+	if os.IsNotExist(err) {
+		return ids
+	}
+	if err != nil {
+		d.add(CheckError, "servers.yaml", "", "failed to read: %v", err)
+		return ids
+	}
+
+	servers, schemaVersion, err := ParseServersYAML(data)
+	if err != nil {
+		d.add(CheckError, "servers.yaml", "", "%v", err)
+		return ids
+	}
+	if schemaVersion < ServersSchemaVersion {
+		d.add(CheckWarning, "servers.yaml", "schema_version", "written with schema version %d; it will be upgraded to %d on next start", schemaVersion, ServersSchemaVersion)
+	}
+
+	for i, server := range servers {
+		field := fmt.Sprintf("servers[%d]", i)
+		if server.ID != "" {
+			field = fmt.Sprintf("servers[%s]", server.ID)
+			if ids[server.ID] {
+				d.add(CheckError, "servers.yaml", field+".id", "duplicate server id")
+			}
+			ids[server.ID] = true
+		}
+
+		if err := ValidateServerDefinition(&server); err != nil {
+			d.add(CheckError, "servers.yaml", field, "%v", err)
+		}
+		if server.Connection.AuthMethod == "key" && server.Connection.KeyPath != "" {
+			if _, err := os.Stat(server.Connection.KeyPath); err != nil {
+				d.add(CheckWarning, "servers.yaml", field+".connection.key_path", "key file %s not found on this host", server.Connection.KeyPath)
+			}
+		}
+		if _, err := RenderTemplates(configDir, server); err != nil {
+			d.add(CheckError, "servers.yaml", field+".templates", "%v", err)
+		}
+		if server.Backups.Enabled {
+			if _, err := scheduleParser.Parse(server.Backups.Schedule); err != nil {
+				d.add(CheckError, "servers.yaml", field+".backups.schedule", "invalid cron expression %q: %v", server.Backups.Schedule, err)
+			}
+		}
+	}
+	if _, err := StartOrder(servers, nil); err != nil {
+		d.add(CheckError, "servers.yaml", "start_after", "%v", err)
+	}
+	return ids
+}
+
+func (d *deploymentCheck) tasks(configDir string, serverIDs map[string]bool) {
+	data, err := os.ReadFile(filepath.Join(configDir, "tasks.yaml"))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		d.add(CheckError, "tasks.yaml", "", "failed to read: %v", err)
+		return
+	}
+
+	var tasksFile struct {
+		Tasks []struct {
+			ID       string   `yaml:"id"`
+			Schedule string   `yaml:"schedule"`
+			Action   string   `yaml:"action"`
+			Targets  []string `yaml:"targets"`
+		} `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &tasksFile); err != nil {
+		d.add(CheckError, "tasks.yaml", "", "failed to parse: %v", err)
+		return
+	}
+
+	for i, task := range tasksFile.Tasks {
+		field := fmt.Sprintf("tasks[%d]", i)
+		if task.ID != "" {
+			field = fmt.Sprintf("tasks[%s]", task.ID)
+		}
+		if _, err := scheduleParser.Parse(task.Schedule); err != nil {
+			d.add(CheckError, "tasks.yaml", field+".schedule", "invalid cron expression %q: %v", task.Schedule, err)
+		}
+		if task.Action == "" {
+			d.add(CheckError, "tasks.yaml", field+".action", "action is required")
+		}
+		for _, target := range task.Targets {
+			if !serverIDs[target] {
+				d.add(CheckWarning, "tasks.yaml", field+".targets", "target %q is not defined in servers.yaml", target)
+			}
+		}
+	}
+}
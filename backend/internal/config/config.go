@@ -2,22 +2,44 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/features"
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig   `yaml:"server" json:"server"`
-	Database DatabaseConfig `yaml:"database" json:"database"`
-	Auth     AuthConfig     `yaml:"auth" json:"auth"`
-	Security SecurityConfig `yaml:"security" json:"security"`
-	Storage  StorageConfig  `yaml:"storage" json:"storage"`
-	Logging  LoggingConfig  `yaml:"logging" json:"logging"`
-	Metrics  MetricsConfig  `yaml:"metrics" json:"metrics"`
+	Server        ServerConfig        `yaml:"server" json:"server"`
+	Database      DatabaseConfig      `yaml:"database" json:"database"`
+	Auth          AuthConfig          `yaml:"auth" json:"auth"`
+	Security      SecurityConfig      `yaml:"security" json:"security"`
+	Storage       StorageConfig       `yaml:"storage" json:"storage"`
+	Logging       LoggingConfig       `yaml:"logging" json:"logging"`
+	Metrics       MetricsConfig       `yaml:"metrics" json:"metrics"`
+	Tracing       TracingConfig       `yaml:"tracing" json:"tracing"`
+	Debug         DebugConfig         `yaml:"debug" json:"debug"`
+	Notifications NotificationsConfig `yaml:"notifications" json:"notifications"`
+	Maintenance   MaintenanceConfig   `yaml:"maintenance" json:"maintenance"`
+	SelfBackup    SelfBackupConfig    `yaml:"self_backup" json:"self_backup"`
+	Tasks         TasksConfig         `yaml:"tasks" json:"tasks"`
+	Console       ConsoleConfig       `yaml:"console" json:"console"`
+	Probes        ProbesConfig        `yaml:"probes" json:"probes"`
+	Prometheus    PrometheusConfig    `yaml:"prometheus" json:"prometheus"`
+	Drift         DriftConfig         `yaml:"drift" json:"drift"`
+	Watchdog      WatchdogConfig      `yaml:"watchdog" json:"watchdog"`
+	HostSecurity  HostSecurityConfig  `yaml:"host_security" json:"host_security"`
+	Cluster       ClusterConfig       `yaml:"cluster" json:"cluster"`
+	Hooks         []HookConfig        `yaml:"hooks" json:"hooks"`
+	GitOps        GitOpsConfig        `yaml:"gitops" json:"gitops"`
+	Agents        AgentsConfig        `yaml:"agents" json:"agents"`
+	// Features overrides the defaults of experimental feature flags by name
+	Features map[string]bool `yaml:"features" json:"features"`
 }
 
 // ServerConfig contains HTTP server settings
@@ -36,16 +58,82 @@ type TLSConfig struct {
 
 // DatabaseConfig contains database settings
 type DatabaseConfig struct {
-	Path           string `yaml:"path" json:"path"`
-	MaxConnections int    `yaml:"max_connections" json:"max_connections"`
+	Driver             string               `yaml:"driver" json:"driver"` // "sqlite" (default) or "postgres"
+	Path               string               `yaml:"path" json:"path"`
+	DSN                string               `yaml:"dsn" json:"-"` // PostgreSQL connection string
+	MaxConnections     int                  `yaml:"max_connections" json:"max_connections"`
+	MaxIdleConnections int                  `yaml:"max_idle_connections" json:"max_idle_connections"`
+	ConnMaxLifetime    string               `yaml:"conn_max_lifetime" json:"conn_max_lifetime"` // e.g. "30m", empty keeps connections open
+	SQLite             SQLiteConfig         `yaml:"sqlite" json:"sqlite"`
+	Health             DatabaseHealthConfig `yaml:"health" json:"health"`
+}
+
+// DatabaseHealthConfig controls the periodic integrity check and vacuum job
+type DatabaseHealthConfig struct {
+	Enabled         bool     `yaml:"enabled" json:"enabled"`
+	Interval        string   `yaml:"interval" json:"interval"`                   // e.g. "6h"
+	IntegrityCheck  string   `yaml:"integrity_check" json:"integrity_check"`     // quick, full or off
+	VacuumPages     int      `yaml:"vacuum_pages" json:"vacuum_pages"`           // pages reclaimed per run, 0 disables
+	DiskWarnPercent int      `yaml:"disk_warn_percent" json:"disk_warn_percent"` // alert when the disk is this full
+	MaxSizeMB       int      `yaml:"max_size_mb" json:"max_size_mb"`             // alert when the database grows past this, 0 disables
+	AlertEmails     []string `yaml:"alert_emails" json:"alert_emails"`
+}
+
+// SQLiteConfig contains pragmas applied to every SQLite connection
+type SQLiteConfig struct {
+	JournalMode string `yaml:"journal_mode" json:"journal_mode"` // wal, delete, truncate, persist, memory
+	BusyTimeout string `yaml:"busy_timeout" json:"busy_timeout"` // how long a writer waits for a lock, e.g. "5s"
+	Synchronous string `yaml:"synchronous" json:"synchronous"`   // off, normal, full, extra
+	TxLock      string `yaml:"tx_lock" json:"tx_lock"`           // deferred, immediate or exclusive
 }
 
 // AuthConfig contains authentication settings
 type AuthConfig struct {
-	JWTSecret            string `yaml:"jwt_secret" json:"jwt_secret"`
-	AccessTokenDuration  string `yaml:"access_token_duration" json:"access_token_duration"`
-	RefreshTokenDuration string `yaml:"refresh_token_duration" json:"refresh_token_duration"`
-	BcryptCost           int    `yaml:"bcrypt_cost" json:"bcrypt_cost"`
+	JWTSecret            string              `yaml:"jwt_secret" json:"jwt_secret"`
+	AccessTokenDuration  string              `yaml:"access_token_duration" json:"access_token_duration"`
+	RefreshTokenDuration string              `yaml:"refresh_token_duration" json:"refresh_token_duration"`
+	BcryptCost           int                 `yaml:"bcrypt_cost" json:"bcrypt_cost"`
+	PasswordHash         string              `yaml:"password_hash" json:"password_hash"` // bcrypt or argon2id, used for new hashes
+	Argon2               Argon2Config        `yaml:"argon2" json:"argon2"`
+	PasswordReset        PasswordResetConfig `yaml:"password_reset" json:"password_reset"`
+	OIDC                 OIDCConfig          `yaml:"oidc" json:"oidc"`
+}
+
+// Argon2Config contains argon2id cost parameters, used when password_hash is argon2id
+type Argon2Config struct {
+	Memory      uint32 `yaml:"memory" json:"memory"` // KiB
+	Iterations  uint32 `yaml:"iterations" json:"iterations"`
+	Parallelism uint8  `yaml:"parallelism" json:"parallelism"`
+	SaltLength  uint32 `yaml:"salt_length" json:"salt_length"`
+	KeyLength   uint32 `yaml:"key_length" json:"key_length"`
+}
+
+// PasswordResetConfig contains forgot-password settings
+type PasswordResetConfig struct {
+	Enabled           bool   `yaml:"enabled" json:"enabled"`
+	TokenDuration     string `yaml:"token_duration" json:"token_duration"`
+	ResetURL          string `yaml:"reset_url" json:"reset_url"` // frontend page receiving ?token=
+	RequestsPerMinute int    `yaml:"requests_per_minute" json:"requests_per_minute"`
+	ResendCooldown    string `yaml:"resend_cooldown" json:"resend_cooldown"` // minimum gap between emails per account
+}
+
+// OIDCConfig contains OpenID Connect single sign-on settings. Password login
+// keeps working alongside it.
+type OIDCConfig struct {
+	Enabled      bool              `yaml:"enabled" json:"enabled"`
+	DisplayName  string            `yaml:"display_name" json:"display_name"` // shown on the login button
+	Issuer       string            `yaml:"issuer" json:"issuer"`
+	ClientID     string            `yaml:"client_id" json:"client_id"`
+	ClientSecret string            `yaml:"client_secret" json:"client_secret"`
+	RedirectURL  string            `yaml:"redirect_url" json:"redirect_url"` // this panel's /api/v1/auth/oidc/callback
+	Scopes       []string          `yaml:"scopes" json:"scopes"`
+	GroupsClaim  string            `yaml:"groups_claim" json:"groups_claim"`
+	GroupRoles   map[string]string `yaml:"group_roles" json:"group_roles"`   // provider group -> role, applied on first login
+	DefaultRole  string            `yaml:"default_role" json:"default_role"` // empty refuses users without a mapped group
+	// LinkVerifiedEmail lets a first login take over the local account with
+	// the same verified email instead of being refused
+	LinkVerifiedEmail bool   `yaml:"link_verified_email" json:"link_verified_email"`
+	FrontendURL       string `yaml:"frontend_url" json:"frontend_url"` // where the browser lands after signing in
 }
 
 // SecurityConfig contains security settings
@@ -75,11 +163,12 @@ type SSHConfig struct {
 
 // StorageConfig contains storage paths
 type StorageConfig struct {
-	ConfigDir string `yaml:"config_dir" json:"config_dir"`
-	BackupDir string `yaml:"backup_dir" json:"backup_dir"`
-	DataDir   string `yaml:"data_dir" json:"data_dir"`
-	ReleasesDir  string `yaml:"releases_dir" json:"releases_dir"`
-	DownloaderDir string `yaml:"downloader_dir" json:"downloader_dir"`
+	ConfigDir      string `yaml:"config_dir" json:"config_dir"`
+	BackupDir      string `yaml:"backup_dir" json:"backup_dir"`
+	DataDir        string `yaml:"data_dir" json:"data_dir"`
+	ReleasesDir    string `yaml:"releases_dir" json:"releases_dir"`
+	DownloaderDir  string `yaml:"downloader_dir" json:"downloader_dir"`
+	ConfigVersions int    `yaml:"config_versions" json:"config_versions"` // snapshots kept per file in <config_dir>/.history; 0 disables
 }
 
 // LoggingConfig contains logging settings
@@ -90,17 +179,230 @@ type LoggingConfig struct {
 	MaxSize    int    `yaml:"max_size" json:"max_size"`
 	MaxBackups int    `yaml:"max_backups" json:"max_backups"`
 	MaxAge     int    `yaml:"max_age" json:"max_age"`
+	// Modules overrides the level for individual modules, e.g. {"ssh": "debug"}
+	Modules map[string]string `yaml:"modules" json:"modules"`
 }
 
 // MetricsConfig contains metrics collection settings
 type MetricsConfig struct {
 	Enabled         bool `yaml:"enabled" json:"enabled"`
 	DefaultInterval int  `yaml:"default_interval" json:"default_interval"` // seconds
-	RetentionDays   int  `yaml:"retention_days" json:"retention_days"`
+	RetentionDays   int  `yaml:"retention_days" json:"retention_days"`     // raw samples; 0 keeps them forever
+	// Hourly and daily rollups are kept longer than the raw samples
+	HourlyRetentionDays int `yaml:"hourly_retention_days" json:"hourly_retention_days"`
+	DailyRetentionDays  int `yaml:"daily_retention_days" json:"daily_retention_days"`
+	StatusInterval      int `yaml:"status_interval" json:"status_interval"`   // seconds between background status checks per server
+	LiveConcurrency     int `yaml:"live_concurrency" json:"live_concurrency"` // servers polled at once for live metrics
+	LiveTimeout         int `yaml:"live_timeout" json:"live_timeout"`         // seconds per server before a live metrics poll is abandoned
+}
+
+// TracingConfig contains OpenTelemetry trace export settings
+type TracingConfig struct {
+	Enabled       bool   `yaml:"enabled" json:"enabled"`
+	Endpoint      string `yaml:"endpoint" json:"endpoint"` // OTLP/HTTP collector base URL
+	ServiceName   string `yaml:"service_name" json:"service_name"`
+	SamplePercent int    `yaml:"sample_percent" json:"sample_percent"`
+}
+
+// DebugConfig controls the admin-only pprof and runtime endpoints under /debug
+type DebugConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// NotificationsConfig contains outbound notification channel settings
+type NotificationsConfig struct {
+	SMTP SMTPConfig `yaml:"smtp" json:"smtp"`
+}
+
+// SMTPConfig contains SMTP email delivery settings
+type SMTPConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Host     string `yaml:"host" json:"host"`
+	Port     int    `yaml:"port" json:"port"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"-"`
+	From     string `yaml:"from" json:"from"`
+}
+
+// MaintenanceConfig contains the global read-only maintenance lock
+type MaintenanceConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	Message      string   `yaml:"message" json:"message"`
+	AllowedRoles []string `yaml:"allowed_roles" json:"allowed_roles"` // roles that may still mutate while locked
+}
+
+// SelfBackupConfig controls snapshots of the manager's own database, config and keys
+type SelfBackupConfig struct {
+	Enabled        bool   `yaml:"enabled" json:"enabled"`   // run the scheduled snapshot job
+	Schedule       string `yaml:"schedule" json:"schedule"` // cron expression
+	Dir            string `yaml:"dir" json:"dir"`           // defaults to <data_dir>/manager-backups
+	Retain         int    `yaml:"retain" json:"retain"`     // snapshots to keep, 0 keeps all
+	IncludeSecrets bool   `yaml:"include_secrets" json:"include_secrets"`
+	Passphrase     string `yaml:"passphrase" json:"-"` // encrypts snapshots when set
+}
+
+// TasksConfig controls how background task output (installs, deploys) is kept
+type TasksConfig struct {
+	StreamBufferLines int    `yaml:"stream_buffer_lines" json:"stream_buffer_lines"` // lines kept in memory per server for the tasks WebSocket backfill
+	Persist           bool   `yaml:"persist" json:"persist"`                         // write task output and status to disk so it survives restarts
+	Dir               string `yaml:"dir" json:"dir"`                                 // defaults to <data_dir>/task-streams
+	RetentionDays     int    `yaml:"retention_days" json:"retention_days"`           // 0 keeps task logs forever
+	MaxConcurrent     int    `yaml:"max_concurrent" json:"max_concurrent"`           // tasks run at once across all servers; more wait queued
+}
+
+// ConsoleConfig controls how console output is kept for searching
+type ConsoleConfig struct {
+	Index         bool `yaml:"index" json:"index"`                   // store console lines for GET /servers/:id/console/search
+	RetentionDays int  `yaml:"retention_days" json:"retention_days"` // 0 keeps indexed lines forever
+}
+
+// PrometheusConfig controls the manager's own /metrics endpoint
+type PrometheusConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+}
+
+// ProbesConfig controls the /livez and /readyz endpoints used by orchestrators
+type ProbesConfig struct {
+	Timeout  string `yaml:"timeout" json:"timeout"`     // per-check limit, e.g. "2s"
+	CheckSSH bool   `yaml:"check_ssh" json:"check_ssh"` // fail readiness while pooled SSH connections are failing
+}
+
+// DriftConfig controls the background comparison of server definitions with their hosts
+type DriftConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Interval string `yaml:"interval" json:"interval"` // time between checks of every server, e.g. "1h"
+}
+
+// HostSecurityConfig controls the background check of each host's fail2ban
+// jails and failed SSH logins
+type HostSecurityConfig struct {
+	Enabled        bool     `yaml:"enabled" json:"enabled"`
+	Interval       string   `yaml:"interval" json:"interval"`               // time between checks of every host, e.g. "15m"
+	Window         string   `yaml:"window" json:"window"`                   // how far back failed SSH logins are counted
+	SpikeThreshold int      `yaml:"spike_threshold" json:"spike_threshold"` // failed logins within window that raise an alert
+	AlertEmails    []string `yaml:"alert_emails" json:"alert_emails"`
+}
+
+// WatchdogConfig controls the automatic restart of servers that exit without
+// being stopped through the manager
+type WatchdogConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled"`
+	InitialDelay string   `yaml:"initial_delay" json:"initial_delay"` // wait before the first restart, doubled after each further crash
+	MaxDelay     string   `yaml:"max_delay" json:"max_delay"`
+	MaxRestarts  int      `yaml:"max_restarts" json:"max_restarts"` // restarts allowed within window before the watchdog gives up
+	Window       string   `yaml:"window" json:"window"`
+	ConsoleLines int      `yaml:"console_lines" json:"console_lines"` // console lines included in crash notifications
+	AlertEmails  []string `yaml:"alert_emails" json:"alert_emails"`
+}
+
+// ClusterConfig lets several manager instances run behind a load balancer
+// against the same PostgreSQL database
+type ClusterConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	InstanceID   string `yaml:"instance_id" json:"instance_id"`     // defaults to the hostname plus a random suffix
+	LeaseTTL     string `yaml:"lease_ttl" json:"lease_ttl"`         // how long a leader that stopped renewing keeps the lead, e.g. "15s"
+	PollInterval string `yaml:"poll_interval" json:"poll_interval"` // how often events from other instances are picked up
+}
+
+// GitOpsConfig has the manager pull server definitions and schedules from a
+// Git repository and apply them
+type GitOpsConfig struct {
+	Enabled    bool   `yaml:"enabled" json:"enabled"`
+	Repository string `yaml:"repository" json:"repository"` // clone URL, e.g. git@github.com:org/servers.git
+	Branch     string `yaml:"branch" json:"branch"`
+	Path       string `yaml:"path" json:"path"`             // directory holding servers.yaml and schedules.yaml
+	DeployKey  string `yaml:"deploy_key" json:"deploy_key"` // path to the SSH private key used to fetch
+	Interval   string `yaml:"interval" json:"interval"`     // time between syncs, e.g. "5m"
+	Prune      bool   `yaml:"prune" json:"prune"`           // delete servers and schedules missing from the repository
+}
+
+// AgentsConfig controls the stream agents push their events over when the
+// agent_push feature flag is on
+type AgentsConfig struct {
+	StreamAddr    string `yaml:"stream_addr" json:"stream_addr"`       // where the manager accepts agent streams, e.g. ":9444"
+	AdvertiseAddr string `yaml:"advertise_addr" json:"advertise_addr"` // host:port agents dial; defaults to the manager's host with the stream port
+	// CertRenewBefore is how long before expiry the leader re-issues an
+	// agent's certificates and pushes them over SSH; "0" turns renewal off
+	CertRenewBefore string `yaml:"cert_renew_before" json:"cert_renew_before"`
+}
+
+// HookConfig is a script or HTTP callout the manager runs at points in a
+// server's lifecycle
+type HookConfig struct {
+	Name      string            `yaml:"name" json:"name"`
+	Events    []string          `yaml:"events" json:"events"`         // pre_start, post_start, pre_deploy, post_deploy, post_backup
+	Type      string            `yaml:"type" json:"type"`             // script or http
+	Command   string            `yaml:"command" json:"command"`       // script: run with sh -c on the manager host
+	URL       string            `yaml:"url" json:"url"`               // http: receives the event as JSON
+	Method    string            `yaml:"method" json:"method"`         // http: POST when empty
+	Headers   map[string]string `yaml:"headers" json:"-"`             // http: may carry credentials
+	Timeout   string            `yaml:"timeout" json:"timeout"`       // e.g. "30s"
+	OnFailure string            `yaml:"on_failure" json:"on_failure"` // continue (default) or abort; abort stops the operation a pre_ hook runs before
+	Servers   []string          `yaml:"servers" json:"servers"`       // server IDs the hook runs for, all when empty
+}
+
+// HookEvents lists the points at which hooks can run
+var HookEvents = []string{"pre_start", "post_start", "pre_deploy", "post_deploy", "post_backup"}
+
+// Validate checks a hook's type, events, target, timeout and failure policy
+func (h HookConfig) Validate() error {
+	if strings.TrimSpace(h.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(h.Events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	for _, event := range h.Events {
+		known := false
+		for _, candidate := range HookEvents {
+			known = known || event == candidate
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q (use %s)", event, strings.Join(HookEvents, ", "))
+		}
+	}
+	switch h.Type {
+	case "script":
+		if strings.TrimSpace(h.Command) == "" {
+			return fmt.Errorf("command is required for script hooks")
+		}
+	case "http":
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return fmt.Errorf("url must be an http or https URL")
+		}
+	default:
+		return fmt.Errorf("type must be script or http")
+	}
+	if h.Timeout != "" {
+		if d, err := time.ParseDuration(h.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", h.Timeout)
+		}
+	}
+	switch h.OnFailure {
+	case "", "continue", "abort":
+	default:
+		return fmt.Errorf("on_failure must be continue or abort")
+	}
+	return nil
 }
 
 // Load loads configuration from file and environment variables
 func Load() (*Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Read loads configuration from file and environment variables without validating it
+func Read() (*Config, error) {
 	// Default configuration
 	cfg := &Config{
 		Server: ServerConfig{
@@ -111,14 +413,51 @@ func Load() (*Config, error) {
 			},
 		},
 		Database: DatabaseConfig{
-			Path:           "./data/hytale-manager.db",
-			MaxConnections: 25,
+			Driver:             "sqlite",
+			Path:               "./data/hytale-manager.db",
+			MaxConnections:     25,
+			MaxIdleConnections: 5,
+			SQLite: SQLiteConfig{
+				JournalMode: "wal",
+				BusyTimeout: "5s",
+				Synchronous: "normal",
+				TxLock:      "immediate",
+			},
+			Health: DatabaseHealthConfig{
+				Enabled:         true,
+				Interval:        "6h",
+				IntegrityCheck:  "quick",
+				VacuumPages:     1000,
+				DiskWarnPercent: 90,
+			},
 		},
 		Auth: AuthConfig{
 			JWTSecret:            getEnv("JWT_SECRET", "change-me-in-production"),
 			AccessTokenDuration:  "15m",
 			RefreshTokenDuration: "168h", // 7 days
 			BcryptCost:           12,
+			PasswordHash:         "bcrypt",
+			Argon2: Argon2Config{
+				Memory:      64 * 1024,
+				Iterations:  3,
+				Parallelism: 2,
+				SaltLength:  16,
+				KeyLength:   32,
+			},
+			PasswordReset: PasswordResetConfig{
+				Enabled:           true,
+				TokenDuration:     "30m",
+				ResetURL:          "http://localhost:5173/reset-password",
+				RequestsPerMinute: 5,
+				ResendCooldown:    "2m",
+			},
+			OIDC: OIDCConfig{
+				DisplayName: "Single sign-on",
+				Scopes:      []string{"openid", "profile", "email"},
+				GroupsClaim: "groups",
+				DefaultRole: "Viewer",
+				FrontendURL: "http://localhost:5173/",
+			},
 		},
 		Security: SecurityConfig{
 			RateLimit: RateLimitConfig{
@@ -135,11 +474,12 @@ func Load() (*Config, error) {
 			},
 		},
 		Storage: StorageConfig{
-			ConfigDir: "./configs",
-			BackupDir: "./data/backups",
-			DataDir:   "./data",
-			ReleasesDir:  "./hytale_repo",
-			DownloaderDir: "./hytale_repo/hytale-downloader",
+			ConfigDir:      "./configs",
+			BackupDir:      "./data/backups",
+			DataDir:        "./data",
+			ReleasesDir:    "./hytale_repo",
+			DownloaderDir:  "./hytale_repo/hytale-downloader",
+			ConfigVersions: 20,
 		},
 		Logging: LoggingConfig{
 			Level:      "info",
@@ -150,9 +490,84 @@ func Load() (*Config, error) {
 			MaxAge:     30,
 		},
 		Metrics: MetricsConfig{
-			Enabled:         true,
-			DefaultInterval: 60,
-			RetentionDays:   2,
+			Enabled:             true,
+			DefaultInterval:     60,
+			RetentionDays:       2,
+			HourlyRetentionDays: 30,
+			DailyRetentionDays:  365,
+			StatusInterval:      15,
+			LiveConcurrency:     16,
+			LiveTimeout:         5,
+		},
+		Tracing: TracingConfig{
+			Enabled:       false,
+			Endpoint:      "http://localhost:4318",
+			ServiceName:   "hytale-server-manager",
+			SamplePercent: 100,
+		},
+		Notifications: NotificationsConfig{
+			SMTP: SMTPConfig{
+				Enabled: false,
+				Port:    587,
+			},
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:      false,
+			Message:      "The manager is in maintenance mode. Changes are temporarily disabled.",
+			AllowedRoles: []string{"Admin"},
+		},
+		SelfBackup: SelfBackupConfig{
+			Enabled:        false,
+			Schedule:       "0 3 * * *",
+			Retain:         7,
+			IncludeSecrets: true,
+		},
+		Tasks: TasksConfig{
+			StreamBufferLines: 1000,
+			Persist:           true,
+			RetentionDays:     14,
+			MaxConcurrent:     8,
+		},
+		Console: ConsoleConfig{
+			Index:         true,
+			RetentionDays: 14,
+		},
+		Probes: ProbesConfig{
+			Timeout: "2s",
+		},
+		Prometheus: PrometheusConfig{
+			Enabled: true,
+		},
+		Drift: DriftConfig{
+			Enabled:  true,
+			Interval: "1h",
+		},
+		Watchdog: WatchdogConfig{
+			Enabled:      true,
+			InitialDelay: "10s",
+			MaxDelay:     "5m",
+			MaxRestarts:  5,
+			Window:       "1h",
+			ConsoleLines: 50,
+		},
+		HostSecurity: HostSecurityConfig{
+			Enabled:        true,
+			Interval:       "15m",
+			Window:         "1h",
+			SpikeThreshold: 100,
+		},
+		Cluster: ClusterConfig{
+			LeaseTTL:     "15s",
+			PollInterval: "1s",
+		},
+		GitOps: GitOpsConfig{
+			Branch:   "main",
+			Path:     ".",
+			Interval: "5m",
+		},
+		Agents: AgentsConfig{
+			StreamAddr:      ":9444",
+			CertRenewBefore: "720h",
 		},
 	}
 
@@ -178,10 +593,22 @@ func Load() (*Config, error) {
 		cfg.Auth.JWTSecret = jwtSecret
 	}
 
+	if clientSecret := os.Getenv("OIDC_CLIENT_SECRET"); clientSecret != "" {
+		cfg.Auth.OIDC.ClientSecret = clientSecret
+	}
+
 	if dbPath := os.Getenv("DATABASE_PATH"); dbPath != "" {
 		cfg.Database.Path = dbPath
 	}
 
+	if dbDriver := os.Getenv("DATABASE_DRIVER"); dbDriver != "" {
+		cfg.Database.Driver = dbDriver
+	}
+
+	if dbURL := os.Getenv("DATABASE_URL"); dbURL != "" {
+		cfg.Database.DSN = dbURL
+	}
+
 	if configDir := os.Getenv("CONFIG_DIR"); configDir != "" {
 		cfg.Storage.ConfigDir = configDir
 	}
@@ -202,14 +629,18 @@ func Load() (*Config, error) {
 		cfg.Logging.Level = logLevel
 	}
 
-	// Normalize storage paths based on config location
-	cfg.normalizeStoragePaths(configPath)
+	if smtpPassword := os.Getenv("SMTP_PASSWORD"); smtpPassword != "" {
+		cfg.Notifications.SMTP.Password = smtpPassword
+	}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	// HSM_* variables cover every field and take precedence over the legacy names above
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, err
 	}
 
+	// Normalize storage paths based on config location
+	cfg.normalizeStoragePaths(configPath)
+
 	return cfg, nil
 }
 
@@ -230,10 +661,268 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := features.Validate(c.Features); err != nil {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(c.Database.Driver)) {
+	case "", "sqlite", "sqlite3":
+		if err := c.Database.SQLite.Validate(); err != nil {
+			return err
+		}
+	case "postgres", "postgresql", "pg":
+		if !features.EnabledIn(c.Features, features.PostgresBackend) {
+			return fmt.Errorf("the postgres database driver is experimental; set features.%s to true to use it", features.PostgresBackend)
+		}
+		if strings.TrimSpace(c.Database.DSN) == "" {
+			return fmt.Errorf("database dsn (or DATABASE_URL) is required when driver is postgres")
+		}
+	default:
+		return fmt.Errorf("database driver must be 'sqlite' or 'postgres'")
+	}
+
+	if c.Database.MaxConnections < 0 || c.Database.MaxIdleConnections < 0 {
+		return fmt.Errorf("database connection limits must not be negative")
+	}
+	if c.Database.ConnMaxLifetime != "" {
+		if _, err := time.ParseDuration(c.Database.ConnMaxLifetime); err != nil {
+			return fmt.Errorf("invalid database conn_max_lifetime: %w", err)
+		}
+	}
+
 	if c.Auth.BcryptCost < 10 || c.Auth.BcryptCost > 14 {
 		return fmt.Errorf("bcrypt_cost must be between 10 and 14")
 	}
+	switch c.Auth.PasswordHash {
+	case "", "bcrypt":
+	case "argon2id":
+		if c.Auth.Argon2.Memory < 8*1024 || c.Auth.Argon2.Iterations < 1 || c.Auth.Argon2.Parallelism < 1 {
+			return fmt.Errorf("argon2 requires memory >= 8192 KiB, iterations >= 1 and parallelism >= 1")
+		}
+		if c.Auth.Argon2.SaltLength < 8 || c.Auth.Argon2.KeyLength < 16 {
+			return fmt.Errorf("argon2 requires salt_length >= 8 and key_length >= 16")
+		}
+	default:
+		return fmt.Errorf("invalid password_hash %q (use bcrypt or argon2id)", c.Auth.PasswordHash)
+	}
+	if c.Auth.OIDC.Enabled {
+		if err := c.Auth.OIDC.validate(); err != nil {
+			return err
+		}
+	}
 
+	if c.Database.Health.Interval != "" {
+		if _, err := time.ParseDuration(c.Database.Health.Interval); err != nil {
+			return fmt.Errorf("invalid database health interval: %w", err)
+		}
+	}
+	if c.Database.Health.DiskWarnPercent < 0 || c.Database.Health.DiskWarnPercent > 100 {
+		return fmt.Errorf("database health disk_warn_percent must be between 0 and 100")
+	}
+
+	if c.SelfBackup.Enabled && strings.TrimSpace(c.SelfBackup.Schedule) == "" {
+		return fmt.Errorf("self_backup is enabled but schedule is missing")
+	}
+	if c.Storage.ConfigVersions < 0 {
+		return fmt.Errorf("storage config_versions must not be negative")
+	}
+	if c.SelfBackup.Retain < 0 {
+		return fmt.Errorf("self_backup retain must not be negative")
+	}
+
+	if c.Probes.Timeout != "" {
+		if _, err := time.ParseDuration(c.Probes.Timeout); err != nil {
+			return fmt.Errorf("invalid probes timeout: %w", err)
+		}
+	}
+	if c.Agents.CertRenewBefore != "" {
+		if d, err := time.ParseDuration(c.Agents.CertRenewBefore); err != nil || d < 0 {
+			return fmt.Errorf("invalid agents cert_renew_before: %s", c.Agents.CertRenewBefore)
+		}
+	}
+	if c.Drift.Interval != "" {
+		interval, err := time.ParseDuration(c.Drift.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid drift interval: %w", err)
+		}
+		if interval < time.Minute {
+			return fmt.Errorf("drift interval must be at least 1m")
+		}
+	}
+	for name, value := range map[string]string{
+		"initial_delay": c.Watchdog.InitialDelay,
+		"max_delay":     c.Watchdog.MaxDelay,
+		"window":        c.Watchdog.Window,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid watchdog %s %q", name, value)
+		}
+	}
+	if c.Metrics.RetentionDays < 0 || c.Metrics.HourlyRetentionDays < 0 || c.Metrics.DailyRetentionDays < 0 {
+		return fmt.Errorf("metrics retention days must not be negative")
+	}
+	if c.Console.RetentionDays < 0 {
+		return fmt.Errorf("console retention_days must not be negative")
+	}
+	if c.Watchdog.MaxRestarts < 0 || c.Watchdog.ConsoleLines < 0 {
+		return fmt.Errorf("watchdog max_restarts and console_lines must not be negative")
+	}
+	if c.HostSecurity.Interval != "" {
+		interval, err := time.ParseDuration(c.HostSecurity.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid host_security interval: %w", err)
+		}
+		if interval < time.Minute {
+			return fmt.Errorf("host_security interval must be at least 1m")
+		}
+	}
+	if c.HostSecurity.Window != "" {
+		if d, err := time.ParseDuration(c.HostSecurity.Window); err != nil || d < time.Minute || d > 24*time.Hour {
+			return fmt.Errorf("host_security window must be a duration between 1m and 24h")
+		}
+	}
+	if c.HostSecurity.SpikeThreshold < 0 {
+		return fmt.Errorf("host_security spike_threshold must not be negative")
+	}
+	for name, value := range map[string]string{
+		"lease_ttl":     c.Cluster.LeaseTTL,
+		"poll_interval": c.Cluster.PollInterval,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid cluster %s %q", name, value)
+		}
+	}
+	if c.Cluster.Enabled {
+		switch strings.ToLower(strings.TrimSpace(c.Database.Driver)) {
+		case "postgres", "postgresql", "pg":
+		default:
+			return fmt.Errorf("cluster mode needs the postgres database driver")
+		}
+	}
+	if c.GitOps.Enabled && strings.TrimSpace(c.GitOps.Repository) == "" {
+		return fmt.Errorf("gitops is enabled but repository is missing")
+	}
+	if c.GitOps.Interval != "" {
+		interval, err := time.ParseDuration(c.GitOps.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid gitops interval: %w", err)
+		}
+		if interval < time.Minute {
+			return fmt.Errorf("gitops interval must be at least 1m")
+		}
+	}
+	if features.EnabledIn(c.Features, features.AgentPush) {
+		if _, _, err := net.SplitHostPort(c.Agents.StreamAddr); err != nil {
+			return fmt.Errorf("invalid agents stream_addr: %w", err)
+		}
+	}
+	if c.Agents.AdvertiseAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Agents.AdvertiseAddr); err != nil {
+			return fmt.Errorf("invalid agents advertise_addr: %w", err)
+		}
+	}
+	for i, hook := range c.Hooks {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("invalid hook %d (%s): %w", i+1, hook.Name, err)
+		}
+	}
+
+	if err := c.Logging.Validate(); err != nil {
+		return err
+	}
+
+	if c.Tracing.Enabled && strings.TrimSpace(c.Tracing.Endpoint) == "" {
+		return fmt.Errorf("tracing is enabled but endpoint is missing")
+	}
+	if c.Tracing.SamplePercent < 0 || c.Tracing.SamplePercent > 100 {
+		return fmt.Errorf("tracing sample_percent must be between 0 and 100")
+	}
+
+	if c.Notifications.SMTP.Enabled {
+		if c.Notifications.SMTP.Host == "" || c.Notifications.SMTP.From == "" {
+			return fmt.Errorf("SMTP is enabled but host or from is missing")
+		}
+	}
+
+	return nil
+}
+
+// validate checks the single sign-on settings once it is enabled
+func (o OIDCConfig) validate() error {
+	issuer, err := url.Parse(o.Issuer)
+	if err != nil || issuer.Host == "" || (issuer.Scheme != "https" && issuer.Scheme != "http") {
+		return fmt.Errorf("oidc issuer must be an http(s) URL")
+	}
+	if issuer.Scheme == "http" && issuer.Hostname() != "localhost" && issuer.Hostname() != "127.0.0.1" {
+		return fmt.Errorf("oidc issuer must use https")
+	}
+	if o.ClientID == "" {
+		return fmt.Errorf("oidc client_id is required")
+	}
+	if redirect, err := url.Parse(o.RedirectURL); err != nil || !redirect.IsAbs() {
+		return fmt.Errorf("oidc redirect_url must be an absolute URL")
+	}
+	if frontend, err := url.Parse(o.FrontendURL); err != nil || !frontend.IsAbs() {
+		return fmt.Errorf("oidc frontend_url must be an absolute URL")
+	}
+	for group, role := range o.GroupRoles {
+		if role == "" {
+			return fmt.Errorf("oidc group_roles maps %q to an empty role", group)
+		}
+	}
+	return nil
+}
+
+// Validate checks the global and per-module log levels
+func (l LoggingConfig) Validate() error {
+	if !ValidLogLevel(l.Level) {
+		return fmt.Errorf("invalid logging level: %s", l.Level)
+	}
+	for module, level := range l.Modules {
+		if strings.TrimSpace(module) == "" || !ValidLogLevel(level) {
+			return fmt.Errorf("invalid logging level for module %q: %s", module, level)
+		}
+	}
+	return nil
+}
+
+// ValidLogLevel reports whether level is one of debug, info, warn or error
+func ValidLogLevel(level string) bool {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "", "debug", "info", "warn", "warning", "error":
+		return true
+	}
+	return false
+}
+
+// Validate checks the SQLite pragma settings
+func (s SQLiteConfig) Validate() error {
+	switch strings.ToLower(s.JournalMode) {
+	case "", "wal", "delete", "truncate", "persist", "memory":
+	default:
+		return fmt.Errorf("invalid sqlite journal_mode: %s", s.JournalMode)
+	}
+	switch strings.ToLower(s.Synchronous) {
+	case "", "off", "normal", "full", "extra":
+	default:
+		return fmt.Errorf("invalid sqlite synchronous: %s", s.Synchronous)
+	}
+	switch strings.ToLower(s.TxLock) {
+	case "", "deferred", "immediate", "exclusive":
+	default:
+		return fmt.Errorf("invalid sqlite tx_lock: %s", s.TxLock)
+	}
+	if s.BusyTimeout != "" {
+		if d, err := time.ParseDuration(s.BusyTimeout); err != nil || d < 0 {
+			return fmt.Errorf("invalid sqlite busy_timeout: %s", s.BusyTimeout)
+		}
+	}
 	return nil
 }
 
@@ -334,4 +1023,14 @@ func (c *Config) normalizeStoragePaths(configPath string) {
 		c.Security.SSH.KnownHostsPath = filepath.Join(c.Storage.DataDir, "known_hosts")
 	}
 	c.Security.SSH.KnownHostsPath = resolvePath(c.Security.SSH.KnownHostsPath)
+
+	if strings.TrimSpace(c.SelfBackup.Dir) == "" {
+		c.SelfBackup.Dir = filepath.Join(c.Storage.DataDir, "manager-backups")
+	}
+	c.SelfBackup.Dir = resolvePath(c.SelfBackup.Dir)
+
+	if strings.TrimSpace(c.Tasks.Dir) == "" {
+		c.Tasks.Dir = filepath.Join(c.Storage.DataDir, "task-streams")
+	}
+	c.Tasks.Dir = resolvePath(c.Tasks.Dir)
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -86,3 +87,310 @@ func TestNormalizeStoragePathsDefaults(t *testing.T) {
 		t.Fatalf("expected KnownHostsPath to be set")
 	}
 }
+
+func TestReloaderAppliesRuntimeSettings(t *testing.T) {
+	current := &Config{}
+	current.Logging.Level = "info"
+	current.Security.RateLimit = RateLimitConfig{Enabled: true, RequestsPerMinute: 60}
+	current.Server.Port = 8080
+
+	reloader := NewReloader(current)
+	var notified *Config
+	reloader.OnReload(func(updated *Config) { notified = updated })
+
+	next := *current
+	next.Logging.Level = "debug"
+	next.Security.RateLimit.RequestsPerMinute = 120
+	next.Security.SSH.TrustOnFirstUse = true
+	next.Server.Port = 9090
+
+	result := reloader.Apply(&next)
+	if len(result.Applied) != 3 || result.Applied[0] != "logging.level" || result.Applied[1] != "security.rate_limit" || result.Applied[2] != "security.ssh" {
+		t.Fatalf("unexpected applied sections %v", result.Applied)
+	}
+	if len(result.RequiresRestart) != 1 || result.RequiresRestart[0] != "server" {
+		t.Fatalf("unexpected restart sections %v", result.RequiresRestart)
+	}
+	if current.Logging.Level != "debug" || current.Security.RateLimit.RequestsPerMinute != 120 || !current.Security.SSH.TrustOnFirstUse {
+		t.Fatalf("expected runtime settings to be applied in place")
+	}
+	if current.Server.Port != 8080 {
+		t.Fatalf("expected server port to wait for a restart, got %d", current.Server.Port)
+	}
+	if notified == nil || notified.Logging.Level != "debug" {
+		t.Fatalf("expected listener to receive the reloaded configuration")
+	}
+}
+
+func TestReloaderConfigReadsAlongsideApply(t *testing.T) {
+	current := &Config{}
+	current.Security.RateLimit.RequestsPerMinute = 4
+	reloader := NewReloader(current)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			next := reloader.Config()
+			next.Security.RateLimit.RequestsPerMinute = i + 1
+			reloader.Apply(&next)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if running := reloader.Config(); running.Security.RateLimit.RequestsPerMinute <= 0 {
+			t.Fatalf("unexpected rate limit %d", running.Security.RateLimit.RequestsPerMinute)
+		}
+	}
+	<-done
+
+	if running := reloader.Config(); running.Security.RateLimit.RequestsPerMinute != 100 {
+		t.Fatalf("expected the last applied value, got %d", running.Security.RateLimit.RequestsPerMinute)
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	secretPath := filepath.Join(t.TempDir(), "jwt")
+	if err := os.WriteFile(secretPath, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+
+	t.Setenv("HSM_SERVER_PORT", "9090")
+	t.Setenv("HSM_DB_SQLITE_BUSY_TIMEOUT", "10s")
+	t.Setenv("HSM_SECURITY_RATE_LIMIT_ENABLED", "false")
+	t.Setenv("HSM_SECURITY_CORS_ALLOWED_ORIGINS", "https://a.example, https://b.example")
+	t.Setenv("HSM_JWT_SECRET_FILE", secretPath)
+	t.Setenv("HSM_FEATURES", "process_managers=true, postgres_backend=false")
+
+	cfg := &Config{}
+	cfg.Security.RateLimit.Enabled = true
+	if err := applyEnvOverrides(cfg); err != nil {
+		t.Fatalf("failed to apply overrides: %v", err)
+	}
+
+	if cfg.Server.Port != 9090 {
+		t.Fatalf("expected port 9090, got %d", cfg.Server.Port)
+	}
+	if cfg.Database.SQLite.BusyTimeout != "10s" {
+		t.Fatalf("expected HSM_DB_ alias to set busy_timeout, got %q", cfg.Database.SQLite.BusyTimeout)
+	}
+	if cfg.Security.RateLimit.Enabled {
+		t.Fatalf("expected rate limit to be disabled")
+	}
+	if len(cfg.Security.CORS.AllowedOrigins) != 2 || cfg.Security.CORS.AllowedOrigins[1] != "https://b.example" {
+		t.Fatalf("unexpected origins %v", cfg.Security.CORS.AllowedOrigins)
+	}
+	if cfg.Auth.JWTSecret != "from-file" {
+		t.Fatalf("expected jwt secret from file, got %q", cfg.Auth.JWTSecret)
+	}
+	if !cfg.Features["process_managers"] || cfg.Features["postgres_backend"] || len(cfg.Features) != 2 {
+		t.Fatalf("unexpected feature flags %v", cfg.Features)
+	}
+
+	t.Setenv("HSM_SERVER_PORT", "not-a-port")
+	if err := applyEnvOverrides(cfg); err == nil {
+		t.Fatalf("expected invalid port to be rejected")
+	}
+}
+
+func TestCheckDeploymentReportsAllIssues(t *testing.T) {
+	root := t.TempDir()
+	configDir := filepath.Join(root, "configs")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatalf("failed to create configs dir: %v", err)
+	}
+
+	servers := `servers:
+  - id: alpha
+    name: Alpha
+    connection: {host: 10.0.0.1, username: hytale, auth_method: password}
+    server: {working_directory: /opt/hytale, executable: server.jar, process_manager: screen}
+    backups: {enabled: true, schedule: "every tuesday"}
+  - id: alpha
+    name: Duplicate
+    connection: {host: 10.0.0.2, username: hytale, auth_method: password}
+    server: {working_directory: /opt/hytale, executable: server.jar, process_manager: screen}
+`
+	tasks := `tasks:
+  - id: nightly
+    schedule: "0 3 * * *"
+    action: restart
+    targets: [alpha, missing]
+`
+	if err := os.WriteFile(filepath.Join(configDir, "servers.yaml"), []byte(servers), 0644); err != nil {
+		t.Fatalf("failed to write servers.yaml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "tasks.yaml"), []byte(tasks), 0644); err != nil {
+		t.Fatalf("failed to write tasks.yaml: %v", err)
+	}
+
+	cfg := &Config{}
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Auth.BcryptCost = 12
+	cfg.Auth.AccessTokenDuration = "soon"
+	cfg.Storage.ConfigDir = configDir
+	cfg.Server.TLS = TLSConfig{Enabled: true, CertFile: filepath.Join(root, "missing.pem"), KeyFile: filepath.Join(root, "missing.key")}
+
+	found := map[string]string{}
+	for _, issue := range CheckDeployment(cfg, filepath.Join(configDir, "config.yaml")) {
+		found[issue.File+":"+issue.Field] = issue.Severity
+	}
+
+	expected := map[string]string{
+		"config.yaml:auth.access_token_duration":       CheckError,
+		"config.yaml:server.tls.cert_file":             CheckError,
+		"servers.yaml:servers[alpha].backups.schedule": CheckError,
+		"servers.yaml:servers[alpha].id":               CheckError,
+		"tasks.yaml:tasks[nightly].targets":            CheckWarning,
+	}
+	for key, severity := range expected {
+		if found[key] != severity {
+			t.Fatalf("expected %s issue for %s, got issues %v", severity, key, found)
+		}
+	}
+}
+
+func TestHookValidation(t *testing.T) {
+	valid := HookConfig{Name: "notify", Events: []string{"pre_start", "post_backup"}, Type: "http", URL: "https://hooks.example.com/hsm", Timeout: "10s", OnFailure: "abort"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected a valid hook, got %v", err)
+	}
+
+	cases := map[string]func(h *HookConfig){
+		"unknown event":  func(h *HookConfig) { h.Events = []string{"pre_stop"} },
+		"no events":      func(h *HookConfig) { h.Events = nil },
+		"bad url":        func(h *HookConfig) { h.URL = "ftp://example.com" },
+		"script command": func(h *HookConfig) { h.Type = "script" },
+		"bad type":       func(h *HookConfig) { h.Type = "plugin" },
+		"bad timeout":    func(h *HookConfig) { h.Timeout = "soon" },
+		"bad policy":     func(h *HookConfig) { h.OnFailure = "retry" },
+	}
+	for name, mutate := range cases {
+		hook := valid
+		mutate(&hook)
+		if err := hook.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	configDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(configDir, TemplatesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := `{"ServerName": {{ json .server_name }}, "MOTD": {{ json .motd }}, "Port": {{ .port }}, "Seed": "{{ .world_seed }}"}`
+	if err := os.WriteFile(filepath.Join(configDir, TemplatesDir, "config.json.tmpl"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := ServerDefinition{
+		ID:        "survival-01",
+		Name:      `Survival "EU"`,
+		Query:     QueryConfig{Port: 5521},
+		Templates: []ConfigTemplate{{Template: "config.json.tmpl", Path: "./config.json"}},
+		Variables: map[string]string{"motd": "Welcome", "world_seed": "42"},
+	}
+	if err := validateTemplates(&server); err != nil {
+		t.Fatalf("expected valid templates, got %v", err)
+	}
+	files, err := RenderTemplates(configDir, server)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := `{"ServerName": "Survival \"EU\"", "MOTD": "Welcome", "Port": 5521, "Seed": "42"}`
+	if len(files) != 1 || files[0].Path != "config.json" || string(files[0].Data) != want {
+		t.Fatalf("unexpected rendered files %+v", files)
+	}
+
+	if err := os.WriteFile(filepath.Join(configDir, TemplatesDir, "config.json.tmpl"), []byte(`{{ .max_players }}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RenderTemplates(configDir, server); err == nil {
+		t.Fatal("expected an undefined variable to fail rendering")
+	}
+
+	for _, bad := range []ConfigTemplate{{Template: "../secrets", Path: "config.json"}, {Template: "config.json.tmpl", Path: "/etc/passwd"}, {Template: "config.json.tmpl", Path: "a/../../b"}} {
+		server.Templates = []ConfigTemplate{bad}
+		if err := validateTemplates(&server); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestDiffServersMasksSecrets(t *testing.T) {
+	before := ServerDefinition{
+		ID:         "survival-01",
+		Connection: ConnectionConfig{Host: "10.0.0.5", AuthMethod: "password", Password: "hunter2"},
+		Runtime:    RuntimeConfig{JavaXmx: "8G"},
+		StartAfter: []StartDependency{{Server: "proxy"}},
+		Variables:  map[string]string{"rcon_password": "old"},
+		Version:    3,
+	}
+	after := before
+	after.Connection.Password = "correct horse"
+	after.Runtime.JavaXmx = "12G"
+	after.StartAfter = nil
+	after.Variables = map[string]string{"rcon_password": "new", "motd": "Hi"}
+	after.Version = 4
+
+	got := DiffServers(before, after)
+	want := []FieldChange{
+		{Field: "connection.password", Old: maskedValue, New: maskedValue},
+		{Field: "runtime.java_xmx", Old: "8G", New: "12G"},
+		{Field: "start_after[0].server", Old: "proxy", New: nil},
+		{Field: "variables.motd", Old: nil, New: "Hi"},
+		{Field: "variables.rcon_password", Old: maskedValue, New: maskedValue},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if changes := DiffServers(before, before); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestDockerServerValidation(t *testing.T) {
+	valid := func() ServerDefinition {
+		return ServerDefinition{
+			ID:         "alpha",
+			Name:       "Alpha",
+			Connection: ConnectionConfig{Host: "10.0.0.5", Username: "hytale", AuthMethod: "key", KeyPath: "/keys/alpha"},
+			Server: GameServerConfig{
+				WorkingDirectory: "/srv/hytale",
+				Executable:       "HytaleServer.jar",
+				ProcessManager:   "docker",
+				Docker: &DockerConfig{
+					User:      "1000:1000",
+					Host:      "tcp://10.0.0.5:2376",
+					TLSCACert: "/certs/ca.pem",
+					TLSCert:   "/certs/cert.pem",
+					TLSKey:    "/certs/key.pem",
+				},
+			},
+			Runtime: RuntimeConfig{JavaXmx: "10G", MemoryLimit: "12g", CPULimit: "2.5"},
+		}
+	}
+	server := valid()
+	if err := ValidateServerDefinition(&server); err != nil {
+		t.Fatalf("expected a valid server, got %v", err)
+	}
+
+	cases := map[string]func(s *ServerDefinition){
+		"limit below heap":   func(s *ServerDefinition) { s.Runtime.MemoryLimit = "10g" },
+		"bad memory limit":   func(s *ServerDefinition) { s.Runtime.MemoryLimit = "lots" },
+		"bad cpu limit":      func(s *ServerDefinition) { s.Runtime.CPULimit = "0" },
+		"api without certs":  func(s *ServerDefinition) { s.Server.Docker.TLSKey = "" },
+		"api without user":   func(s *ServerDefinition) { s.Server.Docker.User = "" },
+		"api relative dir":   func(s *ServerDefinition) { s.Server.WorkingDirectory = "~/hytale" },
+		"api extra args":     func(s *ServerDefinition) { s.Server.Docker.ExtraArgs = []string{"--privileged"} },
+		"user name":          func(s *ServerDefinition) { s.Server.Docker.User = "hytale" },
+		"host with a scheme": func(s *ServerDefinition) { s.Server.Docker.Host = "unix:///var/run/docker.sock" },
+	}
+	for name, mutate := range cases {
+		server := valid()
+		mutate(&server)
+		if err := ValidateServerDefinition(&server); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
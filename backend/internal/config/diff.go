@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext = 3
+	// maxDiffCells bounds the LCS table; larger inputs are shown as a full replacement
+	maxDiffCells = 4_000_000
+)
+
+// UnifiedDiff returns a unified diff between a and b, or "" when they are equal
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	aLines, bLines := splitLines(string(a)), splitLines(string(b))
+	ops := diffLines(aLines, bLines)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	// Group the edit script into hunks with diffContext lines around changes
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		first := max(start-diffContext, 0)
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = run
+		}
+
+		aStart, bStart, aCount, bCount := ops[first].aLine, ops[first].bLine, 0, 0
+		for _, op := range ops[first:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, op := range ops[first:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		start = end
+	}
+	return out.String()
+}
+
+type diffOp struct {
+	kind         byte // ' ', '-' or '+'
+	text         string
+	aLine, bLine int // 1-based line numbers before this op
+}
+
+func diffLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	if len(a)*len(b) > maxDiffCells {
+		for i, line := range a {
+			ops = append(ops, diffOp{kind: '-', text: line, aLine: i + 1, bLine: 1})
+		}
+		for j, line := range b {
+			ops = append(ops, diffOp{kind: '+', text: line, aLine: len(a) + 1, bLine: j + 1})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aLine: i + 1, bLine: j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], aLine: i + 1, bLine: j + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], aLine: i + 1, bLine: j + 1})
+			j++
+		}
+	}
+	return ops
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// hunkRange formats a hunk header range; an empty range starts before its line
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
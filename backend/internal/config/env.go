@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts every generated environment variable. Each config field is
+// bound to HSM_ followed by its upper-cased yaml path, e.g. server.port is
+// HSM_SERVER_PORT and database.sqlite.busy_timeout is HSM_DATABASE_SQLITE_BUSY_TIMEOUT.
+const EnvPrefix = "HSM_"
+
+// envAliases are shorter names accepted alongside the generated ones
+var envAliases = map[string][]string{
+	"HSM_JWT_SECRET": {"HSM_AUTH_JWT_SECRET"},
+}
+
+// envPrefixAliases let a whole section be addressed by a shorter prefix
+var envPrefixAliases = map[string]string{
+	"HSM_DATABASE_": "HSM_DB_",
+}
+
+// EnvBinding pairs a config field with the environment variable that overrides it
+type EnvBinding struct {
+	Name string // canonical variable name
+	Path string // dotted yaml path
+}
+
+// EnvBindings lists every environment variable the loader understands
+func EnvBindings() []EnvBinding {
+	var bindings []EnvBinding
+	walkEnvFields(reflect.ValueOf(&Config{}).Elem(), "", func(path string, _ reflect.Value) error {
+		bindings = append(bindings, EnvBinding{Name: envName(path), Path: path})
+		return nil
+	})
+	return bindings
+}
+
+// applyEnvOverrides sets every field that has a matching HSM_ variable. A
+// variable with a _FILE suffix is read from that file instead, for secrets
+// mounted by Docker or systemd credentials.
+func applyEnvOverrides(cfg *Config) error {
+	return walkEnvFields(reflect.ValueOf(cfg).Elem(), "", func(path string, field reflect.Value) error {
+		name, value, ok, err := lookupEnv(envName(path))
+		if err != nil || !ok {
+			return err
+		}
+		if err := setEnvField(field, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+func walkEnvFields(value reflect.Value, prefix string, visit func(path string, field reflect.Value) error) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		fieldType := valueType.Field(i)
+		tag := strings.Split(fieldType.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" || !fieldType.IsExported() {
+			continue
+		}
+
+		path := tag
+		if prefix != "" {
+			path = prefix + "." + tag
+		}
+
+		field := value.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := walkEnvFields(field, path, visit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := visit(path, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func envName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// lookupEnv checks the canonical name, then its aliases, each with and without _FILE
+func lookupEnv(canonical string) (string, string, bool, error) {
+	names := []string{canonical}
+	for alias, targets := range envAliases {
+		for _, target := range targets {
+			if target == canonical {
+				names = append(names, alias)
+			}
+		}
+	}
+	for long, short := range envPrefixAliases {
+		if strings.HasPrefix(canonical, long) {
+			names = append(names, short+strings.TrimPrefix(canonical, long))
+		}
+	}
+
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			return name, value, true, nil
+		}
+		if path, ok := os.LookupEnv(name + "_FILE"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return name, "", false, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+			}
+			return name, strings.TrimSpace(string(data)), true, nil
+		}
+	}
+	return canonical, "", false, nil
+}
+
+func setEnvField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint8, reflect.Uint32:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if trimmed := strings.TrimSpace(item); trimmed != "" {
+				items = append(items, trimmed)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		elem := field.Type().Elem().Kind()
+		if field.Type().Key().Kind() != reflect.String || (elem != reflect.String && elem != reflect.Bool) {
+			return fmt.Errorf("unsupported map type %s", field.Type())
+		}
+		items := reflect.MakeMap(field.Type())
+		for _, item := range strings.Split(value, ",") {
+			key, val, ok := strings.Cut(item, "=")
+			if !ok {
+				if strings.TrimSpace(item) == "" {
+					continue
+				}
+				return fmt.Errorf("expected key=value, got %q", item)
+			}
+			entry := reflect.New(field.Type().Elem()).Elem()
+			if err := setEnvField(entry, val); err != nil {
+				return fmt.Errorf("%s: %w", strings.TrimSpace(key), err)
+			}
+			items.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), entry)
+		}
+		field.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
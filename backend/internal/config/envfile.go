@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// envSecrets are generated on first start when neither the environment nor
+// the .env file provides them, matching what the start scripts do
+var envSecrets = []string{"JWT_SECRET", "ENCRYPTION_KEY"}
+
+// EnvFilePath returns the .env file the start scripts use, in the directory
+// above configs/. HSM_ENV_FILE overrides it.
+func EnvFilePath() string {
+	if path := strings.TrimSpace(os.Getenv("HSM_ENV_FILE")); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(filepath.Dir(GetConfigPath())), ".env")
+}
+
+// LoadEnvFile sets environment variables from a KEY=VALUE file. Variables that
+// are already set keep their value. A missing file is not an error.
+func LoadEnvFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	// Windows PowerShell writes UTF-8 files with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if _, set := os.LookupEnv(key); set || key == "" {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// EnsureEnvSecrets generates a random JWT_SECRET and ENCRYPTION_KEY when the
+// environment has none, appends them to the env file and exports them. It
+// returns the names of the secrets it created.
+func EnsureEnvSecrets(path string) ([]string, error) {
+	var lines []string
+	var generated []string
+	for _, name := range envSecrets {
+		if os.Getenv(name) != "" {
+			continue
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		value := base64.StdEncoding.EncodeToString(secret)
+		if err := os.Setenv(name, value); err != nil {
+			return nil, err
+		}
+		lines = append(lines, name+"="+value)
+		generated = append(generated, name)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		lines[0] = "\n" + lines[0]
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write env file: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return nil, fmt.Errorf("failed to write env file: %w", err)
+	}
+	return generated, nil
+}
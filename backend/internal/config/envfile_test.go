@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvFileAndEnsureSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	content := "\xef\xbb\xbfJWT_SECRET=from-file\n# comment\nexport HSM_TEST_QUOTED=\"quoted value\"\nHSM_TEST_KEPT=file"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("JWT_SECRET", "")
+	os.Unsetenv("JWT_SECRET")
+	t.Setenv("ENCRYPTION_KEY", "")
+	os.Unsetenv("ENCRYPTION_KEY")
+	t.Setenv("HSM_TEST_QUOTED", "")
+	os.Unsetenv("HSM_TEST_QUOTED")
+	t.Setenv("HSM_TEST_KEPT", "env")
+
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatalf("LoadEnvFile: %v", err)
+	}
+	if got := os.Getenv("JWT_SECRET"); got != "from-file" {
+		t.Fatalf("expected JWT_SECRET from file, got %q", got)
+	}
+	if got := os.Getenv("HSM_TEST_QUOTED"); got != "quoted value" {
+		t.Fatalf("expected quotes to be stripped, got %q", got)
+	}
+	if got := os.Getenv("HSM_TEST_KEPT"); got != "env" {
+		t.Fatalf("expected the environment to win, got %q", got)
+	}
+
+	generated, err := EnsureEnvSecrets(path)
+	if err != nil {
+		t.Fatalf("EnsureEnvSecrets: %v", err)
+	}
+	if len(generated) != 1 || generated[0] != "ENCRYPTION_KEY" {
+		t.Fatalf("expected only ENCRYPTION_KEY to be generated, got %v", generated)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "\nENCRYPTION_KEY="+os.Getenv("ENCRYPTION_KEY")+"\n") {
+		t.Fatalf("expected the generated key to be appended, got %q", data)
+	}
+	if generated, _ := EnsureEnvSecrets(path); len(generated) != 0 {
+		t.Fatalf("expected no secrets on the second run, got %v", generated)
+	}
+}
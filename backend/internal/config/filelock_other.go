@@ -0,0 +1,9 @@
+//go:build !unix && !windows
+
+package config
+
+// lockFile is a no-op where no file locking is available; writes from one process are
+// still serialized by the ServerManager
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
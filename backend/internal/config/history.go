@@ -0,0 +1,231 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionFormat names snapshot files; it sorts in time order and is safe in URLs
+const versionFormat = "20060102T150405.000000000Z"
+
+var (
+	// ErrConfigFileNotTracked is returned for a file the history does not keep
+	ErrConfigFileNotTracked = errors.New("config file is not tracked")
+	// ErrConfigVersionNotFound is returned for an unknown snapshot
+	ErrConfigVersionNotFound = errors.New("config version not found")
+)
+
+// ConfigVersion describes one stored snapshot of a config file
+type ConfigVersion struct {
+	File      string    `json:"file"`
+	Version   string    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+}
+
+// History keeps the last versions of tracked config files (servers.yaml,
+// config.yaml) under <dir>/<file>/<version>, taking a snapshot after every
+// save so a bad edit can be inspected and rolled back. A nil History records
+// nothing.
+type History struct {
+	dir   string
+	keep  int
+	mu    sync.Mutex
+	files map[string]string // name -> path on disk
+}
+
+// NewHistory returns a history that keeps keep versions per file, or nil
+// when keep is not positive
+func NewHistory(dir string, keep int) *History {
+	if keep <= 0 || strings.TrimSpace(dir) == "" {
+		return nil
+	}
+	return &History{dir: dir, keep: keep, files: make(map[string]string)}
+}
+
+// Track adds a file to the history under name
+func (h *History) Track(name, path string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.files[name] = path
+}
+
+// Files returns the names of the tracked files
+func (h *History) Files() []string {
+	if h == nil {
+		return []string{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := make([]string, 0, len(h.files))
+	for name := range h.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Path returns where a tracked file lives on disk
+func (h *History) Path(name string) (string, error) {
+	if h == nil {
+		return "", ErrConfigFileNotTracked
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	path, ok := h.files[name]
+	if !ok {
+		return "", ErrConfigFileNotTracked
+	}
+	return path, nil
+}
+
+// Record snapshots the current content of a tracked file. Nothing is stored
+// when the file is missing or unchanged since the latest snapshot. Older
+// snapshots beyond the limit are removed.
+func (h *History) Record(name string) error {
+	if h == nil {
+		return nil
+	}
+	path, err := h.Path(name)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	versions, err := h.versions(name)
+	if err != nil {
+		return err
+	}
+	if len(versions) > 0 {
+		if latest, err := os.ReadFile(h.versionPath(name, versions[0].Version)); err == nil && bytes.Equal(latest, data) {
+			return nil
+		}
+	}
+
+	dir := filepath.Join(h.dir, name)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create config history directory: %w", err)
+	}
+	version := time.Now().UTC().Format(versionFormat)
+	if err := os.WriteFile(h.versionPath(name, version), data, 0600); err != nil {
+		return fmt.Errorf("failed to store %s version: %w", name, err)
+	}
+
+	versions, err = h.versions(name)
+	if err != nil {
+		return err
+	}
+	for _, old := range versions[min(len(versions), h.keep):] {
+		_ = os.Remove(h.versionPath(name, old.Version))
+	}
+	return nil
+}
+
+// Versions lists the snapshots of a tracked file, newest first
+func (h *History) Versions(name string) ([]ConfigVersion, error) {
+	if _, err := h.Path(name); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.versions(name)
+}
+
+// Read returns the content of one snapshot
+func (h *History) Read(name, version string) ([]byte, error) {
+	if _, err := h.Path(name); err != nil {
+		return nil, err
+	}
+	if _, err := time.Parse(versionFormat, version); err != nil {
+		return nil, ErrConfigVersionNotFound
+	}
+	data, err := os.ReadFile(h.versionPath(name, version))
+	if os.IsNotExist(err) {
+		return nil, ErrConfigVersionNotFound
+	}
+	return data, err
+}
+
+// Diff returns a unified diff from the snapshot before version to version.
+// The oldest snapshot is compared with an empty file.
+func (h *History) Diff(name, version string) (string, error) {
+	data, err := h.Read(name, version)
+	if err != nil {
+		return "", err
+	}
+	versions, err := h.Versions(name)
+	if err != nil {
+		return "", err
+	}
+
+	var previous []byte
+	previousName := "/dev/null"
+	for i, v := range versions {
+		if v.Version == version && i+1 < len(versions) {
+			previousName = name + "@" + versions[i+1].Version
+			if previous, err = h.Read(name, versions[i+1].Version); err != nil {
+				return "", err
+			}
+		}
+	}
+	return UnifiedDiff(previousName, name+"@"+version, previous, data), nil
+}
+
+func (h *History) versionPath(name, version string) string {
+	return filepath.Join(h.dir, name, version)
+}
+
+// versions lists snapshots newest first. h.mu must be held.
+func (h *History) versions(name string) ([]ConfigVersion, error) {
+	entries, err := os.ReadDir(filepath.Join(h.dir, name))
+	if os.IsNotExist(err) {
+		return []ConfigVersion{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config history: %w", err)
+	}
+
+	versions := make([]ConfigVersion, 0, len(entries))
+	for _, entry := range entries {
+		createdAt, err := time.Parse(versionFormat, entry.Name())
+		if entry.IsDir() || err != nil {
+			continue
+		}
+		data, err := os.ReadFile(h.versionPath(name, entry.Name()))
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		versions = append(versions, ConfigVersion{
+			File:      name,
+			Version:   entry.Name(),
+			CreatedAt: createdAt,
+			Size:      int64(len(data)),
+			SHA256:    hex.EncodeToString(sum[:]),
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version > versions[j].Version })
+	return versions, nil
+}
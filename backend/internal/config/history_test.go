@@ -0,0 +1,65 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryRecordsPrunesAndDiffs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "servers.yaml")
+	history := NewHistory(filepath.Join(dir, ".history"), 2)
+	history.Track("servers.yaml", path)
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if err := history.Record("servers.yaml"); err != nil {
+			t.Fatalf("failed to record: %v", err)
+		}
+	}
+
+	write("a\nb\nc\n")
+	write("a\nb\nc\n") // unchanged, not stored again
+	write("a\nB\nc\n")
+	write("a\nB\nc\nd\n")
+
+	versions, err := history.Versions("servers.yaml")
+	if err != nil {
+		t.Fatalf("failed to list versions: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions after pruning, got %d", len(versions))
+	}
+
+	diff, err := history.Diff("servers.yaml", versions[0].Version)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if !strings.Contains(diff, "@@ -1,3 +1,4 @@\n a\n B\n c\n+d\n") {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	data, err := history.Read("servers.yaml", versions[1].Version)
+	if err != nil || string(data) != "a\nB\nc\n" {
+		t.Fatalf("expected previous content, got %q (%v)", data, err)
+	}
+	if _, err := history.Versions("config.yaml"); err != ErrConfigFileNotTracked {
+		t.Fatalf("expected untracked file error, got %v", err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff := UnifiedDiff("old", "new", []byte("one\ntwo\nthree\n"), []byte("one\n2\nthree\n"))
+	want := "--- old\n+++ new\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n"
+	if diff != want {
+		t.Fatalf("expected:\n%s\ngot:\n%s", want, diff)
+	}
+	if UnifiedDiff("a", "b", []byte("same"), []byte("same")) != "" {
+		t.Fatalf("expected no diff for equal content")
+	}
+}
@@ -2,19 +2,42 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"log"
+	"path/filepath"
 	"sync"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
-// ServerManager handles thread-safe access to server configurations
+// ErrVersionConflict is returned when a write carries a stale server definition version
+var ErrVersionConflict = errors.New("server definition was modified by another request")
+
+// ErrServerNotFound is returned when a server definition does not exist
+var ErrServerNotFound = errors.New("server not found")
+
+// ErrPersistFailed wraps a failure to write servers.yaml after a change was applied
+var ErrPersistFailed = errors.New("failed to save servers")
+
+// ServerStore persists server definitions. Implementations enforce optimistic
+// locking: a non-zero Version must match the stored version or ErrVersionConflict
+// is returned. Version 0 skips the check.
+type ServerStore interface {
+	ListServers() ([]ServerDefinition, error)
+	CreateServer(server ServerDefinition) (ServerDefinition, error)
+	UpdateServer(server ServerDefinition) (ServerDefinition, error)
+	DeleteServer(id string, version int64) error
+}
+
+// ServerManager handles thread-safe access to server configurations.
+// With a store the database is the source of truth and servers.yaml is kept as an export.
 type ServerManager struct {
 	configDir string
 	mutex     sync.RWMutex
+	persistMu sync.Mutex // held across a change and the Save that follows it
 	servers   []ServerDefinition
+	store     ServerStore
+	history   *History
 }
 
 // NewServerManager creates a new server manager
@@ -31,33 +54,88 @@ func NewServerManager(configDir string) (*ServerManager, error) {
 	return sm, nil
 }
 
-// Load reads the configuration from disk
+// NewServerManagerWithStore creates a server manager backed by a store.
+// On first use an existing servers.yaml is imported into the store.
+func NewServerManagerWithStore(configDir string, store ServerStore) (*ServerManager, error) {
+	sm := &ServerManager{
+		configDir: configDir,
+		servers:   []ServerDefinition{},
+		store:     store,
+	}
+
+	existing, err := store.ListServers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load servers from store: %w", err)
+	}
+	if len(existing) == 0 {
+		legacy, err := LoadServers(configDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, server := range legacy {
+			if _, err := store.CreateServer(server); err != nil {
+				return nil, fmt.Errorf("failed to import server %s: %w", server.ID, err)
+			}
+		}
+		if len(legacy) > 0 {
+			log.Printf("[Config] Imported %d servers from servers.yaml", len(legacy))
+		}
+	}
+
+	if err := sm.Load(); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// SetHistory snapshots servers.yaml into history after every Save
+func (sm *ServerManager) SetHistory(history *History) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+	sm.history = history
+	history.Track("servers.yaml", filepath.Join(sm.configDir, "servers.yaml"))
+}
+
+// Load reads the configuration from the store, or from disk without one
 func (sm *ServerManager) Load() error {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
-	servers, err := LoadServers(sm.configDir)
+	if sm.store != nil {
+		servers, err := sm.store.ListServers()
+		if err != nil {
+			return err
+		}
+		sm.servers = servers
+		return nil
+	}
+
+	servers, schemaVersion, err := loadServersFile(sm.configDir)
 	if err != nil {
 		return err
 	}
+	if schemaVersion < ServersSchemaVersion {
+		if err := SaveServers(sm.configDir, servers); err != nil {
+			return fmt.Errorf("failed to upgrade servers.yaml: %w", err)
+		}
+		log.Printf("[Config] Upgraded servers.yaml from schema version %d to %d", schemaVersion, ServersSchemaVersion)
+	}
+	for i := range servers {
+		servers[i].Version = 1
+	}
 	sm.servers = servers
 	return nil
 }
 
-// Save writes the current configuration to disk
+// Save writes the current configuration to disk. With a store this only refreshes
+// the servers.yaml export, since every change is already persisted.
 func (sm *ServerManager) Save() error {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
-	serversPath := fmt.Sprintf("%s/servers.yaml", sm.configDir)
+	serversPath := filepath.Join(sm.configDir, "servers.yaml")
 	
-	data := struct {
-		Servers []ServerDefinition `yaml:"servers"`
-	}{
-		Servers: sm.servers,
-	}
-
-	out, err := yaml.Marshal(data)
+	out, err := MarshalServersYAML(sm.servers)
 	if err != nil {
 		return fmt.Errorf("failed to marshal servers config: %w", err)
 	}
@@ -69,11 +147,30 @@ func (sm *ServerManager) Save() error {
 			srv.ID, srv.Dependencies.InstallDir, srv.Dependencies.ServiceUser, srv.Dependencies.UseSudo)
 	}
 
-	if err := os.WriteFile(serversPath, out, 0644); err != nil {
+	if err := writeLockedFile(serversPath, out, 0644); err != nil {
 		return fmt.Errorf("failed to write servers config: %w", err)
 	}
 
 	fmt.Printf("[ServerManager.Save] Successfully wrote servers config\n")
+	if err := sm.history.Record("servers.yaml"); err != nil {
+		log.Printf("[Config] Failed to snapshot servers.yaml: %v", err)
+	}
+	return nil
+}
+
+// Persist runs change and then Save as one step, so concurrent changes cannot
+// interleave with each other's writes. An error from change is returned as
+// is; a failed Save is wrapped in ErrPersistFailed.
+func (sm *ServerManager) Persist(change func() error) error {
+	sm.persistMu.Lock()
+	defer sm.persistMu.Unlock()
+
+	if err := change(); err != nil {
+		return err
+	}
+	if err := sm.Save(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPersistFailed, err)
+	}
 	return nil
 }
 
@@ -122,44 +219,153 @@ func (sm *ServerManager) Add(server ServerDefinition) error {
 		return fmt.Errorf("invalid server definition: %w", err)
 	}
 
+	if sm.store != nil {
+		created, err := sm.store.CreateServer(server)
+		if err != nil {
+			return err
+		}
+		server = created
+	} else {
+		server.Version = 1
+	}
+
 	sm.servers = append(sm.servers, server)
 	return nil // Call Save() explicitly after adding
 }
 
 // Update updates an existing server definition
 func (sm *ServerManager) Update(server ServerDefinition) error {
+	_, err := sm.UpdateVersioned(server)
+	return err
+}
+
+// UpdateVersioned updates a server definition and returns it with its new version.
+// A non-zero server.Version must match the current version.
+func (sm *ServerManager) UpdateVersioned(server ServerDefinition) (ServerDefinition, error) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
 	// Validate server definition
 	if err := ValidateServerDefinition(&server); err != nil {
-		return fmt.Errorf("invalid server definition: %w", err)
+		return ServerDefinition{}, fmt.Errorf("invalid server definition: %w", err)
 	}
 
 	for i, s := range sm.servers {
-		if s.ID == server.ID {
-			sm.servers[i] = server
-			return nil // Call Save() explicitly after updating
+		if s.ID != server.ID {
+			continue
+		}
+		if server.Version != 0 && server.Version != s.Version {
+			return s, ErrVersionConflict
 		}
+
+		if sm.store != nil {
+			updated, err := sm.store.UpdateServer(server)
+			if err != nil {
+				return s, err
+			}
+			server = updated
+		} else {
+			server.Version = s.Version + 1
+		}
+
+		sm.servers[i] = server
+		return server, nil // Call Save() explicitly after updating
 	}
 
-	return fmt.Errorf("server with ID %s not found", server.ID)
+	return ServerDefinition{}, fmt.Errorf("server with ID %s not found: %w", server.ID, ErrServerNotFound)
 }
 
 // Delete removes a server definition
 func (sm *ServerManager) Delete(id string) error {
+	return sm.DeleteVersioned(id, 0)
+}
+
+// DeleteVersioned removes a server definition if version is 0 or matches the current version
+func (sm *ServerManager) DeleteVersioned(id string, version int64) error {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
 	for i, s := range sm.servers {
 		if s.ID == id {
+			if version != 0 && version != s.Version {
+				return ErrVersionConflict
+			}
+			if sm.store != nil {
+				if err := sm.store.DeleteServer(id, version); err != nil {
+					return err
+				}
+			}
 			// Remove element
 			sm.servers = append(sm.servers[:i], sm.servers[i+1:]...)
 			return nil // Call Save() explicitly after deleting
 		}
 	}
 
-	return fmt.Errorf("server with ID %s not found", id)
+	return fmt.Errorf("server with ID %s not found: %w", id, ErrServerNotFound)
+}
+
+// ExportYAML renders all server definitions in the servers.yaml format
+func (sm *ServerManager) ExportYAML() ([]byte, error) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return MarshalServersYAML(sm.servers)
+}
+
+// ImportYAML creates or overwrites server definitions from servers.yaml content
+func (sm *ServerManager) ImportYAML(data []byte) (created, updated int, err error) {
+	servers, _, err := ParseServersYAML(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i := range servers {
+		if err := ValidateServerDefinition(&servers[i]); err != nil {
+			return 0, 0, fmt.Errorf("invalid server definition at index %d: %w", i, err)
+		}
+	}
+
+	for _, server := range servers {
+		server.Version = 0
+		if _, found := sm.GetByID(server.ID); found {
+			if _, err := sm.UpdateVersioned(server); err != nil {
+				return created, updated, err
+			}
+			updated++
+			continue
+		}
+		if err := sm.Add(server); err != nil {
+			return created, updated, err
+		}
+		created++
+	}
+	return created, updated, nil
+}
+
+// RestoreYAML makes the server definitions match servers.yaml content exactly:
+// servers in data are created or overwritten and all others are deleted
+func (sm *ServerManager) RestoreYAML(data []byte) (created, updated, deleted int, err error) {
+	servers, _, err := ParseServersYAML(data)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	keep := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		keep[server.ID] = true
+	}
+
+	if created, updated, err = sm.ImportYAML(data); err != nil {
+		return created, updated, 0, err
+	}
+	for _, server := range sm.GetAll() {
+		if keep[server.ID] {
+			continue
+		}
+		if err := sm.Delete(server.ID); err != nil {
+			return created, updated, deleted, err
+		}
+		deleted++
+	}
+	return created, updated, deleted, nil
 }
 
 // UnmarshalJSON is a helper to verify JSON correctness
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -103,3 +106,43 @@ func TestServerManager_Concurrency(t *testing.T) {
 		}(i)
 	}
 }
+
+func TestServerManager_ConcurrentPersist(t *testing.T) {
+	tempDir := t.TempDir()
+	manager, err := NewServerManager(tempDir)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			server := ServerDefinition{
+				ID:         fmt.Sprintf("server-%d", id),
+				Name:       "Test Server",
+				Connection: ConnectionConfig{Host: "localhost", Username: "root", AuthMethod: "password"},
+				Server:     GameServerConfig{Executable: "java", WorkingDirectory: "/home/hytale", ProcessManager: "screen"},
+			}
+			if err := manager.Persist(func() error { return manager.Add(server) }); err != nil {
+				t.Errorf("Failed to persist server %d: %v", id, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	servers, err := LoadServers(tempDir)
+	if err != nil {
+		t.Fatalf("servers.yaml is not readable after concurrent saves: %v", err)
+	}
+	if len(servers) != 20 {
+		t.Fatalf("Expected 20 servers on disk, got %d", len(servers))
+	}
+	entries, _ := os.ReadDir(tempDir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Fatalf("Temporary file left behind: %s", entry.Name())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReloadResult describes what a configuration reload changed
+type ReloadResult struct {
+	ReloadedAt      time.Time `json:"reloaded_at"`
+	Applied         []string  `json:"applied"`          // sections now live
+	RequiresRestart []string  `json:"requires_restart"` // sections that changed on disk but only take effect after a restart
+}
+
+// Reloader re-reads the configuration at runtime and applies the settings that
+// can change without a restart: log level, CORS, rate limits, SSH host key
+// checking, metrics, the maintenance lock and feature flags. Everything else is
+// reported as requiring a restart.
+type Reloader struct {
+	mu        sync.Mutex
+	cfg       *Config
+	listeners []func(*Config)
+}
+
+// NewReloader creates a reloader that updates cfg in place
+func NewReloader(cfg *Config) *Reloader {
+	return &Reloader{cfg: cfg}
+}
+
+// OnReload registers fn to be called with a copy of the running configuration
+// after every reload that applied at least one change
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Config returns a copy of the running configuration. Reloads update the
+// shared Config in place, so request handlers read it through here.
+func (r *Reloader) Config() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.cfg
+}
+
+// Reload reads the config file and environment again. An invalid file leaves
+// the running configuration untouched.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	next, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return r.Apply(next), nil
+}
+
+// Apply copies the reloadable sections of next into the running configuration
+// and notifies listeners
+func (r *Reloader) Apply(next *Config) *ReloadResult {
+	r.mu.Lock()
+
+	current := r.cfg
+	result := &ReloadResult{
+		ReloadedAt:      time.Now().UTC(),
+		Applied:         []string{},
+		RequiresRestart: []string{},
+	}
+
+	// The log file defaults are filled in at startup, not by Load
+	if strings.TrimSpace(next.Logging.File) == "" {
+		next.Logging.File = current.Logging.File
+	}
+
+	if current.Logging.Level != next.Logging.Level {
+		current.Logging.Level = next.Logging.Level
+		result.Applied = append(result.Applied, "logging.level")
+	}
+	if !reflect.DeepEqual(current.Logging.Modules, next.Logging.Modules) {
+		current.Logging.Modules = next.Logging.Modules
+		result.Applied = append(result.Applied, "logging.modules")
+	}
+	if !reflect.DeepEqual(current.Security.CORS, next.Security.CORS) {
+		current.Security.CORS = next.Security.CORS
+		result.Applied = append(result.Applied, "security.cors")
+	}
+	if current.Security.RateLimit != next.Security.RateLimit {
+		current.Security.RateLimit = next.Security.RateLimit
+		result.Applied = append(result.Applied, "security.rate_limit")
+	}
+	// The SSH pool checks new connections against these; pooled ones reconnect
+	// when next used and close once the work running on them finishes
+	if current.Security.SSH != next.Security.SSH {
+		current.Security.SSH = next.Security.SSH
+		result.Applied = append(result.Applied, "security.ssh")
+	}
+	if current.Metrics != next.Metrics {
+		current.Metrics = next.Metrics
+		result.Applied = append(result.Applied, "metrics")
+	}
+	if !reflect.DeepEqual(current.Maintenance, next.Maintenance) {
+		current.Maintenance = next.Maintenance
+		result.Applied = append(result.Applied, "maintenance")
+	}
+	if !reflect.DeepEqual(current.Watchdog, next.Watchdog) {
+		current.Watchdog = next.Watchdog
+		result.Applied = append(result.Applied, "watchdog")
+	}
+	if !reflect.DeepEqual(current.Hooks, next.Hooks) {
+		current.Hooks = next.Hooks
+		result.Applied = append(result.Applied, "hooks")
+	}
+	if !reflect.DeepEqual(current.Features, next.Features) {
+		current.Features = next.Features
+		result.Applied = append(result.Applied, "features")
+	}
+
+	logging := next.Logging
+	logging.Level = current.Logging.Level
+	logging.Modules = current.Logging.Modules
+	restartOnly := []struct {
+		name          string
+		current, next interface{}
+	}{
+		{"server", current.Server, next.Server},
+		{"database", current.Database, next.Database},
+		{"auth", current.Auth, next.Auth},
+		{"storage", current.Storage, next.Storage},
+		{"logging", current.Logging, logging},
+		{"tracing", current.Tracing, next.Tracing},
+		{"debug", current.Debug, next.Debug},
+		{"notifications", current.Notifications, next.Notifications},
+		{"self_backup", current.SelfBackup, next.SelfBackup},
+		{"tasks", current.Tasks, next.Tasks},
+		{"probes", current.Probes, next.Probes},
+		{"prometheus", current.Prometheus, next.Prometheus},
+		{"drift", current.Drift, next.Drift},
+		{"host_security", current.HostSecurity, next.HostSecurity},
+		{"cluster", current.Cluster, next.Cluster},
+		{"gitops", current.GitOps, next.GitOps},
+		{"agents", current.Agents, next.Agents},
+	}
+	for _, section := range restartOnly {
+		if !reflect.DeepEqual(section.current, section.next) {
+			result.RequiresRestart = append(result.RequiresRestart, section.name)
+		}
+	}
+
+	snapshot := *current
+	listeners := append([]func(*Config){}, r.listeners...)
+	r.mu.Unlock()
+
+	if len(result.Applied) > 0 {
+		for _, fn := range listeners {
+			fn(&snapshot)
+		}
+	}
+	return result
+}
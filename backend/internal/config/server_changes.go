@@ -0,0 +1,114 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maskedValue replaces sensitive values in a change
+const maskedValue = "********"
+
+// sensitiveFieldWords mark a field whose value is never shown in a change
+var sensitiveFieldWords = []string{"password", "secret", "token", "key_content", "private_key"}
+
+// FieldChange is one field of a server definition that an update changed.
+// Field is the JSON path, e.g. runtime.java_xmx or start_after[0].server.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// DiffServers lists the fields that differ between two versions of a server
+// definition, in field order. Sensitive values show as masked; the version
+// is left out.
+func DiffServers(before, after ServerDefinition) []FieldChange {
+	before.Version, after.Version = 0, 0
+	oldFields, newFields := map[string]interface{}{}, map[string]interface{}{}
+	flattenJSON("", toJSONValue(before), oldFields)
+	flattenJSON("", toJSONValue(after), newFields)
+
+	names := make([]string, 0, len(oldFields)+len(newFields))
+	for name := range oldFields {
+		names = append(names, name)
+	}
+	for name := range newFields {
+		if _, ok := oldFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		oldValue, newValue := oldFields[name], newFields[name]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if isSensitiveField(name) {
+			oldValue, newValue = maskValue(oldValue), maskValue(newValue)
+		}
+		changes = append(changes, FieldChange{Field: name, Old: oldValue, New: newValue})
+	}
+	return changes
+}
+
+func toJSONValue(server ServerDefinition) interface{} {
+	data, err := json.Marshal(server)
+	if err != nil {
+		return nil
+	}
+	var value interface{}
+	_ = json.Unmarshal(data, &value)
+	return value
+}
+
+// flattenJSON records every leaf of a decoded JSON value under its path.
+// Empty objects and lists are leaves too, so clearing a list shows up.
+func flattenJSON(prefix string, value interface{}, out map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = v
+			return
+		}
+		for key, child := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenJSON(name, child, out)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			out[prefix] = v
+			return
+		}
+		for i, child := range v {
+			flattenJSON(fmt.Sprintf("%s[%d]", prefix, i), child, out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveFieldWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskValue hides a value but keeps whether it was set
+func maskValue(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return maskedValue
+}
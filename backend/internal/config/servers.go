@@ -3,9 +3,20 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+)
 
-	"gopkg.in/yaml.v3"
+var (
+	dockerPortPattern = regexp.MustCompile(`^([0-9.]+:)?[0-9]+:[0-9]+(/(tcp|udp))?$`)
+	envNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	memorySizePattern = regexp.MustCompile(`^([0-9]+)([bkmgtBKMGT]?)$`)
+	dockerUserPattern = regexp.MustCompile(`^[0-9]+(:[0-9]+)?$`)
+	dockerHostPattern = regexp.MustCompile(`^(tcp://)?[A-Za-z0-9.:\[\]-]+$`)
+	unitNamePattern   = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
 )
 
 // ServerDefinition represents a game server configuration
@@ -13,12 +24,31 @@ type ServerDefinition struct {
 	ID          string           `json:"id" yaml:"id"`
 	Name        string           `json:"name" yaml:"name"`
 	Description string           `json:"description" yaml:"description"`
+	// Group lets maintenance windows cover several servers at once
+	Group       string           `json:"group,omitempty" yaml:"group,omitempty"`
+	// Tags select servers for batch operations
+	Tags        []string         `json:"tags,omitempty" yaml:"tags,omitempty"`
 	Connection  ConnectionConfig `json:"connection" yaml:"connection"`
 	Server      GameServerConfig `json:"server" yaml:"server"`
 	Runtime     RuntimeConfig    `json:"runtime,omitempty" yaml:"runtime,omitempty"`
 	Backups     BackupConfig     `json:"backups" yaml:"backups"`
 	Monitoring  MonitoringConfig `json:"monitoring" yaml:"monitoring"`
 	Dependencies DependenciesConfig `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Watchdog    ServerWatchdogConfig `json:"watchdog,omitempty" yaml:"watchdog,omitempty"`
+	// Query is where the server answers status probes on its game ports
+	Query QueryConfig `json:"query,omitempty" yaml:"query,omitempty"`
+	// Templates are configuration files rendered from the templates directory
+	// and uploaded with every deployed release
+	Templates []ConfigTemplate `json:"templates,omitempty" yaml:"templates,omitempty"`
+	// Variables fill in the templates, next to the built-in ones
+	Variables map[string]string `json:"variables,omitempty" yaml:"variables,omitempty"`
+	// AutoStart starts the server when the manager starts
+	AutoStart bool `json:"auto_start,omitempty" yaml:"auto_start,omitempty"`
+	// StartAfter lists the servers that must be up before this one is started
+	// by a bulk or boot-time start
+	StartAfter []StartDependency `json:"start_after,omitempty" yaml:"start_after,omitempty"`
+	// Version increments on every write and is used for optimistic locking
+	Version int64 `json:"version" yaml:"-"`
 }
 
 // ConnectionConfig contains SSH connection details
@@ -32,14 +62,44 @@ type ConnectionConfig struct {
 	Password   string `json:"password,omitempty" yaml:"password,omitempty"`
 }
 
+// IsLocal reports whether the server runs on the manager's own host
+func (c ConnectionConfig) IsLocal() bool {
+	switch strings.ToLower(strings.TrimSpace(c.Host)) {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
 // GameServerConfig contains game server process settings
 type GameServerConfig struct {
-	WorkingDirectory  string `json:"working_directory" yaml:"working_directory"`
-	Executable        string `json:"executable" yaml:"executable"`
-	JavaArgs          string `json:"java_args" yaml:"java_args"`
-	ProcessManager    string `json:"process_manager" yaml:"process_manager"` // "screen" or "systemd"
-	ScreenSessionName string `json:"screen_session_name,omitempty" yaml:"screen_session_name,omitempty"`
-	SystemdService    string `json:"systemd_service_name,omitempty" yaml:"systemd_service_name,omitempty"`
+	WorkingDirectory  string        `json:"working_directory" yaml:"working_directory"`
+	Executable        string        `json:"executable" yaml:"executable"`
+	JavaArgs          string        `json:"java_args" yaml:"java_args"`
+	ProcessManager    string        `json:"process_manager" yaml:"process_manager"` // "screen", "tmux", "systemd", "docker" or "agent"
+	ScreenSessionName string        `json:"screen_session_name,omitempty" yaml:"screen_session_name,omitempty"`
+	SystemdService    string        `json:"systemd_service_name,omitempty" yaml:"systemd_service_name,omitempty"`
+	Docker            *DockerConfig `json:"docker,omitempty" yaml:"docker,omitempty"`
+}
+
+// DockerConfig contains container settings used when process_manager is "docker"
+type DockerConfig struct {
+	Image     string            `json:"image,omitempty" yaml:"image,omitempty"`
+	Network   string            `json:"network,omitempty" yaml:"network,omitempty"` // defaults to "host"
+	Ports     []string          `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Volumes   []string          `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Env       map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	ExtraArgs []string          `json:"extra_args,omitempty" yaml:"extra_args,omitempty"`
+	// User runs the container, as uid:gid; defaults to the SSH or service
+	// user over SSH and is required with Host
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+	// Host is the Docker API of the server's host, e.g. tcp://10.0.0.5:2376.
+	// When set the containers are managed over the API with the TLS client
+	// certificate below instead of the docker CLI over SSH.
+	Host      string `json:"host,omitempty" yaml:"host,omitempty"`
+	TLSCACert string `json:"tls_ca_cert,omitempty" yaml:"tls_ca_cert,omitempty"`
+	TLSCert   string `json:"tls_cert,omitempty" yaml:"tls_cert,omitempty"`
+	TLSKey    string `json:"tls_key,omitempty" yaml:"tls_key,omitempty"`
 }
 
 // BackupConfig contains backup settings for a server
@@ -58,13 +118,15 @@ type RetentionConfig struct {
 
 // BackupDestination represents a backup storage destination
 type BackupDestination struct {
-	Type          string `json:"type" yaml:"type"` // "local", "sftp", "s3"
+	Type          string `json:"type" yaml:"type"` // "local", "sftp", "s3", "restic"
 	Path          string `json:"path,omitempty" yaml:"path,omitempty"`
 	Endpoint      string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
 	Bucket        string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
 	Region        string `json:"region,omitempty" yaml:"region,omitempty"`
 	PathPrefix    string `json:"path_prefix,omitempty" yaml:"path_prefix,omitempty"`
 	CredentialsID string `json:"credentials_id,omitempty" yaml:"credentials_id,omitempty"`
+	// Backend is where a restic repository is kept: "local", "sftp" or "s3"
+	Backend string `json:"backend,omitempty" yaml:"backend,omitempty"`
 }
 
 // MonitoringConfig contains monitoring settings
@@ -76,6 +138,40 @@ type MonitoringConfig struct {
 	NodeExporterPort int      `json:"node_exporter_port,omitempty" yaml:"node_exporter_port,omitempty"`
 }
 
+// Conditions a start dependency waits for
+const (
+	WaitForStarted = "started" // the dependency's start finished
+	WaitForPort    = "port"    // a port is listening on the dependency's host
+)
+
+// StartDependency is a server that must be up before another one starts
+type StartDependency struct {
+	Server  string `json:"server" yaml:"server"`
+	WaitFor string `json:"wait_for,omitempty" yaml:"wait_for,omitempty"` // "started" (default) or "port"
+	Port    int    `json:"port,omitempty" yaml:"port,omitempty"`         // checked on the dependency's host for wait_for: port
+	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`   // how long to wait for the condition, default 5m
+	Delay   string `json:"delay,omitempty" yaml:"delay,omitempty"`       // extra wait once the condition holds
+}
+
+// ServerWatchdogConfig overrides the manager's watchdog settings for one server
+type ServerWatchdogConfig struct {
+	// Enabled turns automatic restarts off (or on) for this server; unset follows watchdog.enabled
+	Enabled *bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// QueryConfig says how to probe a server's game ports for its status
+type QueryConfig struct {
+	// Disabled skips the probes, e.g. when a firewall drops them
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// Host overrides the connection host, e.g. with the server's public address
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// Port is the game's UDP port; 5520 when unset
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
+	// QueryPort is served by a query plugin and adds players and version to
+	// the status; without it the game port is only pinged
+	QueryPort int `json:"query_port,omitempty" yaml:"query_port,omitempty"`
+}
+
 // RuntimeConfig contains runtime startup options for the server
 type RuntimeConfig struct {
 	JavaXms           string `json:"java_xms,omitempty" yaml:"java_xms,omitempty"`
@@ -89,6 +185,10 @@ type RuntimeConfig struct {
 	AssetsPath        string `json:"assets_path,omitempty" yaml:"assets_path,omitempty"`
 	ExtraJavaArgs     string `json:"extra_java_args,omitempty" yaml:"extra_java_args,omitempty"`
 	ExtraServerArgs   string `json:"extra_server_args,omitempty" yaml:"extra_server_args,omitempty"`
+	// MemoryLimit and CPULimit cap the container of a docker server, e.g.
+	// "12g" and "4"; the memory limit must leave room above java_xmx
+	MemoryLimit string `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	CPULimit    string `json:"cpu_limit,omitempty" yaml:"cpu_limit,omitempty"`
 }
 
 type DependenciesConfig struct {
@@ -103,50 +203,48 @@ type DependenciesConfig struct {
 
 // LoadServers loads server definitions from YAML file
 func LoadServers(configDir string) ([]ServerDefinition, error) {
-	serversPath := fmt.Sprintf("%s/servers.yaml", configDir)
+	servers, _, err := loadServersFile(configDir)
+	return servers, err
+}
+
+// loadServersFile loads servers.yaml and also returns the schema version it was
+// written with. A missing file counts as the current version.
+func loadServersFile(configDir string) ([]ServerDefinition, int, error) {
+	serversPath := filepath.Join(configDir, "servers.yaml")
 
 	data, err := os.ReadFile(serversPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Return empty list if file doesn't exist
-			return []ServerDefinition{}, nil
+			return []ServerDefinition{}, ServersSchemaVersion, nil
 		}
-		return nil, fmt.Errorf("failed to read servers file: %w", err)
+		return nil, 0, fmt.Errorf("failed to read servers file: %w", err)
 	}
 
-	var serversFile struct {
-		Servers []ServerDefinition `yaml:"servers"`
-	}
-
-	if err := yaml.Unmarshal(data, &serversFile); err != nil {
-		return nil, fmt.Errorf("failed to parse servers file: %w", err)
+	servers, version, err := ParseServersYAML(data)
+	if err != nil {
+		return nil, version, err
 	}
 
 	// Validate server definitions
-	for i, server := range serversFile.Servers {
+	for i, server := range servers {
 		if err := ValidateServerDefinition(&server); err != nil {
-			return nil, fmt.Errorf("invalid server definition at index %d: %w", i, err)
+			return nil, version, fmt.Errorf("invalid server definition at index %d: %w", i, err)
 		}
 	}
 
-	return serversFile.Servers, nil
+	return servers, version, nil
 }
 
 // SaveServers saves server definitions to YAML file
 func SaveServers(configDir string, servers []ServerDefinition) error {
-	serversFile := struct {
-		Servers []ServerDefinition `yaml:"servers"`
-	}{
-		Servers: servers,
-	}
-
-	data, err := yaml.Marshal(serversFile)
+	data, err := MarshalServersYAML(servers)
 	if err != nil {
 		return fmt.Errorf("failed to marshal servers: %w", err)
 	}
 
-	serversPath := fmt.Sprintf("%s/servers.yaml", configDir)
-	if err := os.WriteFile(serversPath, data, 0644); err != nil {
+	serversPath := filepath.Join(configDir, "servers.yaml")
+	if err := writeLockedFile(serversPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write servers file: %w", err)
 	}
 
@@ -166,14 +264,17 @@ func ValidateServerDefinition(server *ServerDefinition) error {
 	if server.Connection.Port == 0 {
 		server.Connection.Port = 22 // Default SSH port
 	}
-	if server.Connection.Username == "" {
-		return fmt.Errorf("connection username is required")
-	}
-	if server.Connection.AuthMethod != "key" && server.Connection.AuthMethod != "password" {
-		return fmt.Errorf("auth_method must be 'key' or 'password'")
-	}
-	if server.Connection.AuthMethod == "key" && server.Connection.KeyPath == "" && server.Connection.KeyContent == "" {
-		return fmt.Errorf("key_path is required when auth_method is 'key'")
+	// Servers on the manager's own host are run locally and need no login
+	if !server.Connection.IsLocal() {
+		if server.Connection.Username == "" {
+			return fmt.Errorf("connection username is required")
+		}
+		if server.Connection.AuthMethod != "key" && server.Connection.AuthMethod != "password" {
+			return fmt.Errorf("auth_method must be 'key' or 'password'")
+		}
+		if server.Connection.AuthMethod == "key" && server.Connection.KeyPath == "" && server.Connection.KeyContent == "" {
+			return fmt.Errorf("key_path is required when auth_method is 'key'")
+		}
 	}
 	if server.Server.WorkingDirectory == "" {
 		return fmt.Errorf("server working_directory is required")
@@ -190,13 +291,181 @@ func ValidateServerDefinition(server *ServerDefinition) error {
 	if server.Server.JavaArgs != "" && !isValidArgs(server.Server.JavaArgs) {
 		return fmt.Errorf("server java_args contains invalid characters")
 	}
-	if server.Server.ProcessManager != "screen" && server.Server.ProcessManager != "systemd" {
-		return fmt.Errorf("process_manager must be 'screen' or 'systemd'")
+	switch server.Server.ProcessManager {
+	case "screen", "tmux", "agent":
+	case "systemd":
+		if name := server.Server.SystemdService; name != "" && !unitNamePattern.MatchString(name) {
+			return fmt.Errorf("systemd_service_name contains invalid characters")
+		}
+	case "docker":
+		if err := validateDockerConfig(server.Server.Docker, server.Server.WorkingDirectory); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("process_manager must be 'screen', 'tmux', 'systemd', 'docker' or 'agent'")
+	}
+	if err := validateResourceLimits(server.Runtime); err != nil {
+		return err
+	}
+	for name, port := range map[string]int{"port": server.Query.Port, "query_port": server.Query.QueryPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("query %s must be between 1 and 65535", name)
+		}
+	}
+	if err := validateTemplates(server); err != nil {
+		return err
+	}
+	for _, dep := range server.StartAfter {
+		if err := validateStartDependency(server.ID, dep); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+func validateStartDependency(serverID string, dep StartDependency) error {
+	if dep.Server == "" {
+		return fmt.Errorf("start_after entries need a server")
+	}
+	if dep.Server == serverID {
+		return fmt.Errorf("a server cannot start after itself")
+	}
+	switch dep.WaitFor {
+	case "", WaitForStarted:
+	case WaitForPort:
+		if dep.Port <= 0 || dep.Port > 65535 {
+			return fmt.Errorf("start_after %s: wait_for port needs a port between 1 and 65535", dep.Server)
+		}
+	default:
+		return fmt.Errorf("start_after %s: wait_for must be 'started' or 'port'", dep.Server)
+	}
+	for name, value := range map[string]string{"timeout": dep.Timeout, "delay": dep.Delay} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			return fmt.Errorf("start_after %s: invalid %s %q", dep.Server, name, value)
+		}
+	}
+	return nil
+}
+
+func validateDockerConfig(docker *DockerConfig, workingDir string) error {
+	if docker == nil {
+		return nil
+	}
+	if docker.User != "" && !dockerUserPattern.MatchString(docker.User) {
+		return fmt.Errorf("docker user must be a uid or uid:gid")
+	}
+	if docker.Host != "" {
+		if err := validateDockerAPI(docker, workingDir); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsAny(docker.Image, " \t") || !isValidArgs(docker.Image) {
+		return fmt.Errorf("docker image contains invalid characters")
+	}
+	if strings.ContainsAny(docker.Network, " \t") || !isValidArgs(docker.Network) {
+		return fmt.Errorf("docker network contains invalid characters")
+	}
+	if docker.Network != "" && docker.Network != "host" {
+		for _, port := range docker.Ports {
+			if !dockerPortPattern.MatchString(port) {
+				return fmt.Errorf("docker port %q must look like [ip:]host:container[/udp]", port)
+			}
+		}
+	} else if len(docker.Ports) > 0 {
+		return fmt.Errorf("docker ports cannot be published on the host network")
+	}
+	for _, volume := range docker.Volumes {
+		if !isValidPath(volume) {
+			return fmt.Errorf("docker volume %q contains invalid characters", volume)
+		}
+	}
+	for key, value := range docker.Env {
+		if !envNamePattern.MatchString(key) || !isValidArgs(value) {
+			return fmt.Errorf("docker env %q contains invalid characters", key)
+		}
+	}
+	for _, arg := range docker.ExtraArgs {
+		if !isValidArgs(arg) {
+			return fmt.Errorf("docker extra_args contains invalid characters")
+		}
+	}
+	return nil
+}
+
+// validateDockerAPI checks the settings of a server whose containers are
+// managed over the Docker API, where nothing runs in a shell on the host
+func validateDockerAPI(docker *DockerConfig, workingDir string) error {
+	if !dockerHostPattern.MatchString(docker.Host) {
+		return fmt.Errorf("docker host must look like tcp://host:2376")
+	}
+	if docker.TLSCACert == "" || docker.TLSCert == "" || docker.TLSKey == "" {
+		return fmt.Errorf("docker tls_ca_cert, tls_cert and tls_key are required with docker host")
+	}
+	if docker.User == "" {
+		return fmt.Errorf("docker user is required with docker host")
+	}
+	if !strings.HasPrefix(workingDir, "/") {
+		return fmt.Errorf("working_directory must be an absolute path with docker host")
+	}
+	for _, volume := range docker.Volumes {
+		if !strings.HasPrefix(volume, "/") {
+			return fmt.Errorf("docker volume %q must start with an absolute host path with docker host", volume)
+		}
+	}
+	if len(docker.ExtraArgs) > 0 {
+		return fmt.Errorf("docker extra_args are passed to the docker CLI and cannot be used with docker host")
+	}
+	return nil
+}
+
+// validateResourceLimits checks the container limits, which must leave the
+// JVM room for more than its heap
+func validateResourceLimits(runtime RuntimeConfig) error {
+	if runtime.MemoryLimit != "" {
+		limit, ok := ParseMemorySize(runtime.MemoryLimit)
+		if !ok || limit < 6*1024*1024 {
+			return fmt.Errorf("runtime memory_limit must be a size such as 12g")
+		}
+		if heap, ok := ParseMemorySize(runtime.JavaXmx); ok && heap >= limit {
+			return fmt.Errorf("runtime memory_limit (%s) must be larger than java_xmx (%s)", runtime.MemoryLimit, runtime.JavaXmx)
+		}
+	}
+	if runtime.CPULimit != "" {
+		if cpus, err := strconv.ParseFloat(runtime.CPULimit, 64); err != nil || cpus <= 0 {
+			return fmt.Errorf("runtime cpu_limit must be a number of CPUs such as 2.5")
+		}
+	}
+	return nil
+}
+
+// ParseMemorySize reads a size such as 512m or 10G, as taken by -Xmx and
+// docker --memory, in bytes
+func ParseMemorySize(value string) (int64, bool) {
+	match := memorySizePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToLower(match[2]) {
+	case "k":
+		size <<= 10
+	case "m":
+		size <<= 20
+	case "g":
+		size <<= 30
+	case "t":
+		size <<= 40
+	}
+	return size, true
+}
+
 func isValidPath(s string) bool {
 	// Block shell metacharacters that could allow command injection
 	// The list includes: ; | & $ ` ( ) < > " '
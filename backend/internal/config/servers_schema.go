@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServersSchemaVersion is the servers.yaml layout this build reads and writes.
+// Bump it together with a new entry in serversMigrations whenever a field is
+// renamed or moved.
+const ServersSchemaVersion = 1
+
+// serversFile is the top level of servers.yaml
+type serversFile struct {
+	SchemaVersion int                `yaml:"schema_version"`
+	Servers       []ServerDefinition `yaml:"servers"`
+}
+
+// serversMigrations upgrade a decoded servers.yaml document one version at a
+// time: serversMigrations[n] turns version n into version n+1. They work on the
+// raw document so old field names can still be read after the struct changes.
+var serversMigrations = []func(doc map[string]interface{}) error{
+	// 0 -> 1: files written before schema_version existed share the version 1 layout
+	func(doc map[string]interface{}) error { return nil },
+}
+
+// ParseServersYAML decodes servers.yaml content written with any schema version
+// up to ServersSchemaVersion, upgrading it first. It also returns the version
+// the content was written with.
+func ParseServersYAML(data []byte) ([]ServerDefinition, int, error) {
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse servers file: %w", err)
+	}
+	if doc == nil {
+		return []ServerDefinition{}, ServersSchemaVersion, nil
+	}
+
+	version := 0
+	if raw, ok := doc["schema_version"]; ok {
+		parsed, ok := raw.(int)
+		if !ok || parsed < 0 {
+			return nil, 0, fmt.Errorf("invalid servers file schema_version %v", raw)
+		}
+		version = parsed
+	}
+	if version > ServersSchemaVersion {
+		return nil, version, fmt.Errorf("servers file schema_version %d is newer than supported version %d; upgrade the manager", version, ServersSchemaVersion)
+	}
+
+	for v := version; v < ServersSchemaVersion; v++ {
+		if err := serversMigrations[v](doc); err != nil {
+			return nil, version, fmt.Errorf("failed to upgrade servers file from schema_version %d: %w", v, err)
+		}
+	}
+	doc["schema_version"] = ServersSchemaVersion
+
+	upgraded, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, version, fmt.Errorf("failed to upgrade servers file: %w", err)
+	}
+	var file serversFile
+	if err := yaml.Unmarshal(upgraded, &file); err != nil {
+		return nil, version, fmt.Errorf("failed to parse servers file: %w", err)
+	}
+	if file.Servers == nil {
+		file.Servers = []ServerDefinition{}
+	}
+	return file.Servers, version, nil
+}
+
+// MarshalServersYAML encodes server definitions as servers.yaml with the
+// current schema version
+func MarshalServersYAML(servers []ServerDefinition) ([]byte, error) {
+	return yaml.Marshal(serversFile{SchemaVersion: ServersSchemaVersion, Servers: servers})
+}
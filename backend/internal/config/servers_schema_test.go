@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerManagerUpgradesServersSchema(t *testing.T) {
+	dir := t.TempDir()
+	legacy := `servers:
+  - id: alpha
+    name: Alpha
+    connection:
+      host: 10.0.0.1
+      username: hytale
+      auth_method: password
+    server:
+      working_directory: /opt/hytale
+      executable: start.sh
+      process_manager: screen
+`
+	if err := os.WriteFile(filepath.Join(dir, "servers.yaml"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("failed to write servers.yaml: %v", err)
+	}
+
+	sm, err := NewServerManager(dir)
+	if err != nil {
+		t.Fatalf("failed to load legacy servers.yaml: %v", err)
+	}
+	if server, ok := sm.GetByID("alpha"); !ok || server.Connection.Host != "10.0.0.1" {
+		t.Fatalf("expected legacy server to load, got %+v", server)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "servers.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read servers.yaml: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "schema_version: 1\n") {
+		t.Fatalf("expected servers.yaml to be rewritten with schema_version, got:\n%s", data)
+	}
+
+	if _, _, err := ParseServersYAML([]byte("schema_version: 99\nservers: []\n")); err == nil {
+		t.Fatalf("expected a newer schema version to be rejected")
+	}
+}
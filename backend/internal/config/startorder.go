@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StartOrder groups the servers in ids into steps: every server comes after
+// the servers it starts after, and the servers of one step can start
+// together. Dependencies on servers outside ids are ignored. An empty ids
+// orders every server.
+func StartOrder(servers []ServerDefinition, ids []string) ([][]string, error) {
+	byID := make(map[string]ServerDefinition, len(servers))
+	for _, server := range servers {
+		byID[server.ID] = server
+	}
+	if len(ids) == 0 {
+		for _, server := range servers {
+			ids = append(ids, server.ID)
+		}
+	}
+
+	selected := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := byID[id]; !ok {
+			return nil, fmt.Errorf("server %s not found", id)
+		}
+		selected[id] = true
+	}
+
+	// remaining counts each server's unstarted dependencies
+	remaining := make(map[string]int, len(selected))
+	dependents := make(map[string][]string)
+	for id := range selected {
+		remaining[id] = 0
+		for _, dep := range byID[id].StartAfter {
+			if _, ok := byID[dep.Server]; !ok {
+				return nil, fmt.Errorf("server %s starts after unknown server %s", id, dep.Server)
+			}
+			if selected[dep.Server] {
+				remaining[id]++
+				dependents[dep.Server] = append(dependents[dep.Server], id)
+			}
+		}
+	}
+
+	var steps [][]string
+	for len(remaining) > 0 {
+		var step []string
+		for id, count := range remaining {
+			if count == 0 {
+				step = append(step, id)
+			}
+		}
+		if len(step) == 0 {
+			cycle := make([]string, 0, len(remaining))
+			for id := range remaining {
+				cycle = append(cycle, id)
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("start_after has a cycle among %s", strings.Join(cycle, ", "))
+		}
+		sort.Strings(step)
+		for _, id := range step {
+			delete(remaining, id)
+			for _, dependent := range dependents[id] {
+				remaining[dependent]--
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplatesDir holds the config templates, under the config directory
+const TemplatesDir = "templates"
+
+// defaultGamePort is the game's UDP port when query.port is unset
+const defaultGamePort = 5520
+
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ConfigTemplate is a configuration file rendered into the server directory
+// on every deploy
+type ConfigTemplate struct {
+	// Template is a file in the templates directory
+	Template string `json:"template" yaml:"template"`
+	// Path is where the rendered file goes, relative to the server directory
+	Path string `json:"path" yaml:"path"`
+}
+
+// RenderedFile is a config template rendered for one server
+type RenderedFile struct {
+	Path string
+	Data []byte
+}
+
+// TemplateVariables returns what a server's templates are rendered with: the
+// built-in server_id, server_name, description, host, port, motd and
+// world_seed, overridden and extended by the server's variables
+func TemplateVariables(server ServerDefinition) map[string]string {
+	port := server.Query.Port
+	if port == 0 {
+		port = defaultGamePort
+	}
+	vars := map[string]string{
+		"server_id":   server.ID,
+		"server_name": server.Name,
+		"description": server.Description,
+		"host":        server.Connection.Host,
+		"port":        strconv.Itoa(port),
+		"motd":        "",
+		"world_seed":  "",
+	}
+	for name, value := range server.Variables {
+		vars[name] = value
+	}
+	return vars
+}
+
+// RenderTemplates renders a server's config templates. A template using a
+// variable that is not defined fails to render.
+func RenderTemplates(configDir string, server ServerDefinition) ([]RenderedFile, error) {
+	vars := TemplateVariables(server)
+	files := make([]RenderedFile, 0, len(server.Templates))
+	for _, tmpl := range server.Templates {
+		source, err := os.ReadFile(filepath.Join(configDir, TemplatesDir, filepath.FromSlash(tmpl.Template)))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", tmpl.Template, err)
+		}
+		parsed, err := template.New(tmpl.Template).
+			Option("missingkey=error").
+			Funcs(template.FuncMap{"json": jsonValue}).
+			Parse(string(source))
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", tmpl.Template, err)
+		}
+		var out bytes.Buffer
+		if err := parsed.Execute(&out, vars); err != nil {
+			return nil, fmt.Errorf("template %s: %w", tmpl.Template, err)
+		}
+		files = append(files, RenderedFile{Path: path.Clean(tmpl.Path), Data: out.Bytes()})
+	}
+	return files, nil
+}
+
+// jsonValue quotes a variable for a JSON file, e.g. {{ json .motd }}
+func jsonValue(value string) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func validateTemplates(server *ServerDefinition) error {
+	for name := range server.Variables {
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("variable %q must be a letter or underscore followed by letters, digits or underscores", name)
+		}
+	}
+	paths := make(map[string]bool, len(server.Templates))
+	for _, tmpl := range server.Templates {
+		if !isLocalPath(tmpl.Template) {
+			return fmt.Errorf("template %q must be a relative path inside the templates directory", tmpl.Template)
+		}
+		if !isLocalPath(tmpl.Path) {
+			return fmt.Errorf("template %s: path %q must be a relative path inside the server directory", tmpl.Template, tmpl.Path)
+		}
+		if !isValidPath(tmpl.Path) {
+			return fmt.Errorf("template %s: path contains invalid characters", tmpl.Template)
+		}
+		clean := path.Clean(tmpl.Path)
+		if paths[clean] {
+			return fmt.Errorf("two templates render to %s", clean)
+		}
+		paths[clean] = true
+	}
+	return nil
+}
+
+// isLocalPath reports whether p is a relative slash-separated path that
+// stays below its base directory
+func isLocalPath(p string) bool {
+	if strings.TrimSpace(p) == "" || strings.Contains(p, "\\") || path.IsAbs(p) {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
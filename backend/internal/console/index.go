@@ -0,0 +1,254 @@
+package console
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+const (
+	indexQueueSize     = 8192
+	indexBatchSize     = 512
+	indexFlushInterval = time.Second
+	indexPruneInterval = time.Hour
+)
+
+// LogLine is one indexed console line
+type LogLine struct {
+	ID       int64     `json:"id"`
+	ServerID string    `json:"server_id"`
+	LoggedAt time.Time `json:"logged_at"`
+	Line     string    `json:"line"`
+}
+
+// LogQuery selects indexed lines of one server, newest first. Before is the
+// ID of the last line of the previous page.
+type LogQuery struct {
+	ServerID string
+	Text     string
+	From     time.Time
+	To       time.Time
+	Before   int64
+	Limit    int
+}
+
+// LogIndex stores console output for full-text search. Lines are queued and
+// written in batches, like metrics samples, and lines older than the
+// retention are pruned as it runs. SQLite searches through an FTS5 table,
+// PostgreSQL through a tsvector index.
+type LogIndex struct {
+	db        *database.DB
+	retention time.Duration
+	queue     chan LogLine
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+}
+
+// NewLogIndex creates an index. A retention of 0 keeps lines forever. Call
+// Start to begin writing.
+func NewLogIndex(db *database.DB, retention time.Duration) *LogIndex {
+	return &LogIndex{
+		db:        db,
+		retention: retention,
+		queue:     make(chan LogLine, indexQueueSize),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start runs the write and prune loop
+func (x *LogIndex) Start() {
+	x.wg.Add(1)
+	go func() {
+		defer x.wg.Done()
+		ticker := time.NewTicker(indexFlushInterval)
+		defer ticker.Stop()
+		lastPrune := time.Time{}
+
+		batch := make([]LogLine, 0, indexBatchSize)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if err := x.writeBatch(batch); err != nil {
+				logger.Error("Failed to index console lines", "lines", len(batch), "error", err)
+			}
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case line := <-x.queue:
+				batch = append(batch, line)
+				if len(batch) >= indexBatchSize {
+					flush()
+				}
+			case now := <-ticker.C:
+				flush()
+				if x.retention > 0 && now.Sub(lastPrune) >= indexPruneInterval {
+					lastPrune = now
+					if _, err := x.Prune(now.Add(-x.retention)); err != nil {
+						logger.Error("Failed to prune console index", "error", err)
+					}
+				}
+			case <-x.stopCh:
+				for {
+					select {
+					case line := <-x.queue:
+						batch = append(batch, line)
+					default:
+						flush()
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop writes anything still queued and ends the loop
+func (x *LogIndex) Stop() {
+	x.stopOnce.Do(func() { close(x.stopCh) })
+	x.wg.Wait()
+}
+
+// Record queues a line. Unlike metrics, console output may arrive faster than
+// it can be written, so a full queue drops the line rather than block the
+// console.
+func (x *LogIndex) Record(serverID, line string) {
+	if x == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	select {
+	case x.queue <- LogLine{ServerID: serverID, LoggedAt: time.Now().UTC(), Line: line}:
+	default:
+		logger.Warn("Console index queue full, dropping line", "server_id", serverID)
+	}
+}
+
+func (x *LogIndex) writeBatch(batch []LogLine) error {
+	tx, err := x.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO console_log_lines (server_id, logged_at, line) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, line := range batch {
+		if _, err := stmt.Exec(line.ServerID, line.LoggedAt, line.Line); err != nil {
+			return fmt.Errorf("insert %s: %w", line.ServerID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Prune deletes lines logged before cutoff and returns how many were removed
+func (x *LogIndex) Prune(cutoff time.Time) (int64, error) {
+	result, err := x.db.Exec(`DELETE FROM console_log_lines WHERE logged_at < ?`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Search returns the lines matching a query, newest first, and how many match
+// in total. Every word of the text must appear in a line; a word ending in *
+// matches as a prefix.
+func (x *LogIndex) Search(ctx context.Context, query LogQuery) ([]LogLine, int, error) {
+	where := []string{"l.server_id = ?"}
+	args := []interface{}{query.ServerID}
+	from := "console_log_lines l"
+
+	if terms := searchTerms(query.Text); len(terms) > 0 {
+		if x.db.Dialect == database.DialectPostgres {
+			for i, term := range terms {
+				if strings.HasSuffix(term, "*") {
+					terms[i] = strings.TrimSuffix(term, "*") + ":*"
+				}
+			}
+			where = append(where, "to_tsvector('simple', l.line) @@ to_tsquery('simple', ?)")
+			args = append(args, strings.Join(terms, " & "))
+		} else {
+			for i, term := range terms {
+				prefix := strings.HasSuffix(term, "*")
+				terms[i] = `"` + strings.TrimSuffix(term, "*") + `"`
+				if prefix {
+					terms[i] += "*"
+				}
+			}
+			from = "console_log_fts f INNER JOIN console_log_lines l ON l.id = f.rowid"
+			where = append(where, "console_log_fts MATCH ?")
+			args = append(args, strings.Join(terms, " "))
+		}
+	}
+	if !query.From.IsZero() {
+		where = append(where, "l.logged_at >= ?")
+		args = append(args, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		where = append(where, "l.logged_at < ?")
+		args = append(args, query.To.UTC())
+	}
+	filter := " FROM " + from + " WHERE " + strings.Join(where, " AND ")
+
+	var total int
+	if err := x.db.QueryRowContext(ctx, "SELECT COUNT(*)"+filter, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sqlQuery := "SELECT l.id, l.server_id, l.logged_at, l.line" + filter
+	if query.Before > 0 {
+		sqlQuery += " AND l.id < ?"
+		args = append(args, query.Before)
+	}
+	sqlQuery += " ORDER BY l.id DESC"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := x.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lines := []LogLine{}
+	for rows.Next() {
+		var line LogLine
+		if err := rows.Scan(&line.ID, &line.ServerID, &line.LoggedAt, &line.Line); err != nil {
+			return nil, 0, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, total, rows.Err()
+}
+
+// searchTerms splits search text into words made only of letters and digits,
+// so user input can't inject full-text query syntax. A trailing * is kept.
+func searchTerms(text string) []string {
+	var terms []string
+	for _, field := range strings.Fields(text) {
+		prefix := strings.HasSuffix(field, "*")
+		words := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for i, word := range words {
+			if prefix && i == len(words)-1 {
+				word += "*"
+			}
+			terms = append(terms, strings.ToLower(word))
+		}
+	}
+	return terms
+}
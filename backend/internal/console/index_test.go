@@ -0,0 +1,81 @@
+package console
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestLogIndexSearch(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "console.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	index := NewLogIndex(db, 0)
+	index.Start()
+	for _, line := range []string{
+		"[INFO] Player Ana joined the game",
+		"[WARN] Can't keep up! Is the server overloaded?",
+		"[INFO] Player Bob joined the game",
+		"[INFO] Player Ana left the game",
+		`[INFO] "quoted" OR NOT text`,
+	} {
+		index.Record("alpha", line)
+	}
+	index.Record("beta", "[INFO] Player Ana joined the game")
+	index.Stop()
+
+	ctx := context.Background()
+	search := func(query LogQuery) ([]string, int) {
+		t.Helper()
+		query.ServerID = "alpha"
+		lines, total, err := index.Search(ctx, query)
+		if err != nil {
+			t.Fatalf("search %+v: %v", query, err)
+		}
+		var text []string
+		for _, line := range lines {
+			text = append(text, line.Line)
+		}
+		return text, total
+	}
+
+	if lines, total := search(LogQuery{Text: "ana joined"}); total != 1 || !reflect.DeepEqual(lines, []string{"[INFO] Player Ana joined the game"}) {
+		t.Fatalf("expected only alpha's join line, got %v (%d)", lines, total)
+	}
+	if lines, total := search(LogQuery{Text: "overload*"}); total != 1 || len(lines) != 1 {
+		t.Fatalf("expected a prefix match, got %v", lines)
+	}
+	if _, total := search(LogQuery{Text: `"quoted" OR NOT`}); total != 1 {
+		t.Fatalf("expected query syntax to be matched as words, got %d", total)
+	}
+
+	first, total := search(LogQuery{Text: "player", Limit: 2})
+	if total != 3 || !reflect.DeepEqual(first, []string{"[INFO] Player Ana left the game", "[INFO] Player Bob joined the game"}) {
+		t.Fatalf("expected the newest two of three matches, got %v (%d)", first, total)
+	}
+	page, _, _ := index.Search(ctx, LogQuery{ServerID: "alpha", Text: "player", Limit: 2})
+	rest, _ := search(LogQuery{Text: "player", Before: page[1].ID})
+	if len(rest) != 1 || rest[0] != "[INFO] Player Ana joined the game" {
+		t.Fatalf("expected the oldest match on the next page, got %v", rest)
+	}
+
+	if _, total := search(LogQuery{From: time.Now().Add(time.Hour)}); total != 0 {
+		t.Fatalf("expected nothing logged after the range start, got %d", total)
+	}
+	if removed, err := index.Prune(time.Now().Add(time.Minute)); err != nil || removed != 6 {
+		t.Fatalf("expected every line to be pruned, got %d (%v)", removed, err)
+	}
+	if _, total := search(LogQuery{Text: "player"}); total != 0 {
+		t.Fatalf("expected pruned lines to leave the full-text index, got %d", total)
+	}
+}
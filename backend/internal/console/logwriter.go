@@ -3,7 +3,6 @@ package console
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"sync"
@@ -57,12 +56,12 @@ func NewLogWriter(config *LogWriterConfig) (*LogWriter, error) {
 
 	// Record in database
 	if err := lw.recordLogFile(); err != nil {
-		log.Printf("[LogWriter] Failed to record log file: %v", err)
+		logger.Error("Failed to record log file", "error", err)
 	}
 
 	go lw.rotationChecker()
 
-	log.Printf("[LogWriter] Created log writer for server %s: %s", config.ServerID, logPath)
+	logger.Info("Created log writer", "server_id", config.ServerID, "log_path", logPath)
 	return lw, nil
 }
 
@@ -127,7 +126,7 @@ func (lw *LogWriter) checkRotation() {
 
 	stat, err := lw.file.Stat()
 	if err != nil {
-		log.Printf("[LogWriter] Failed to stat log file: %v", err)
+		logger.Error("Failed to stat log file", "error", err)
 		return
 	}
 
@@ -147,9 +146,9 @@ func (lw *LogWriter) checkRotation() {
 	}
 
 	if shouldRotate {
-		log.Printf("[LogWriter] Rotating log file for server %s (reason: %s)", lw.serverID, reason)
+		logger.Info("Rotating log file", "server_id", lw.serverID, "reason", reason)
 		if err := lw.rotate(); err != nil {
-			log.Printf("[LogWriter] Failed to rotate log: %v", err)
+			logger.Error("Failed to rotate log", "error", err)
 		}
 	}
 }
@@ -169,7 +168,7 @@ func (lw *LogWriter) rotate() error {
 			WHERE id = ?
 		`, lw.currentLogID)
 		if err != nil {
-			log.Printf("[LogWriter] Failed to mark old log as inactive: %v", err)
+			logger.Error("Failed to mark old log as inactive", "error", err)
 		}
 	}
 
@@ -208,13 +207,13 @@ func CleanupOldLogs(db *sql.DB, retentionDays int) error {
 		var id int64
 		var logPath string
 		if err := rows.Scan(&id, &logPath); err != nil {
-			log.Printf("[LogWriter] Failed to scan log row: %v", err)
+			logger.Error("Failed to scan log row", "error", err)
 			continue
 		}
 
 		// Delete physical file
 		if err := os.Remove(logPath); err != nil {
-			log.Printf("[LogWriter] Failed to delete log file %s: %v", logPath, err)
+			logger.Error("Failed to delete log file", "log_path", logPath, "error", err)
 		} else {
 			deleted++
 		}
@@ -226,10 +225,10 @@ func CleanupOldLogs(db *sql.DB, retentionDays int) error {
 			WHERE id = ?
 		`, id)
 		if err != nil {
-			log.Printf("[LogWriter] Failed to mark log as deleted: %v", err)
+			logger.Error("Failed to mark log as deleted", "error", err)
 		}
 	}
 
-	log.Printf("[LogWriter] Cleaned up %d old log files (retention: %d days)", deleted, retentionDays)
+	logger.Info("Cleaned up old log files", "deleted", deleted, "retention_days", retentionDays)
 	return nil
 }
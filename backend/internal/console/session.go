@@ -4,12 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
 	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 	"github.com/TheGojiOG/HytaleSM/internal/websocket"
 )
@@ -32,8 +32,20 @@ type Session struct {
 	isActive        bool
 	outputChan      chan string
 	logWriter       *LogWriter
+	logIndex        *LogIndex
 	lastResizeTarget string
 	lastResizeTime   time.Time
+	source           Source
+}
+
+// Source feeds a session from the server's process manager instead of a
+// screen session, for servers that run elsewhere, such as in a container
+type Source interface {
+	// StreamLogs calls onLine with each line the server writes until ctx is
+	// done or the process exits
+	StreamLogs(ctx context.Context, onLine func(string)) error
+	// SendCommand writes a command to the server's console
+	SendCommand(command string) error
 }
 
 // SessionManager manages console sessions for multiple servers
@@ -42,6 +54,7 @@ type SessionManager struct {
 	hub      *websocket.Hub
 	sshPool  *ssh.ConnectionPool
 	db       *sql.DB
+	logIndex *LogIndex
 	mu       sync.RWMutex
 }
 
@@ -55,6 +68,9 @@ type RingBuffer struct {
 }
 
 // Match all ANSI/VT100 escape sequences including CSI, OSC, and other control sequences
+// logger is shared by the console package
+var logger = logging.For("console")
+
 var ansiEscapePattern = regexp.MustCompile(`\x1b(\[[0-9;?!]*[A-Za-z>hp]|\([B0]|[=>])`)
 
 // NewRingBuffer creates a new ring buffer
@@ -116,14 +132,39 @@ func NewSessionManager(hub *websocket.Hub, sshPool *ssh.ConnectionPool, db *sql.
 	}
 }
 
+// SetLogIndex makes sessions started from now on store their output in index
+func (sm *SessionManager) SetLogIndex(index *LogIndex) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.logIndex = index
+}
+
+// LogIndex returns the index console output is stored in, or nil when
+// console output is not indexed
+func (sm *SessionManager) LogIndex() *LogIndex {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.logIndex
+}
+
 // StartSession starts a new console session for a server
 func (sm *SessionManager) StartSession(serverID, screenSession string, sshConn *ssh.PooledConnection, runAsUser string, useSudo bool) (*Session, error) {
+	return sm.startSession(serverID, screenSession, sshConn, runAsUser, useSudo, nil)
+}
+
+// StartSourceSession starts a console session that reads the server's output
+// from source and sends commands through it
+func (sm *SessionManager) StartSourceSession(serverID, sessionName string, source Source) (*Session, error) {
+	return sm.startSession(serverID, sessionName, nil, "", false, source)
+}
+
+func (sm *SessionManager) startSession(serverID, screenSession string, sshConn *ssh.PooledConnection, runAsUser string, useSudo bool, source Source) (*Session, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	// Check if session already exists
 	if session, exists := sm.sessions[serverID]; exists && session.isActive {
-		log.Printf("[Console] Session already exists for server %s", serverID)
+		logger.Debug("Session already exists", "server_id", serverID)
 		return session, nil
 	}
 
@@ -144,16 +185,22 @@ func (sm *SessionManager) StartSession(serverID, screenSession string, sshConn *
 		lastActivity:  time.Now(),
 		isActive:      true,
 		outputChan:    make(chan string, 100),
+		logIndex:      sm.logIndex,
+		source:        source,
 	}
 
 	// Start output reader
-	go session.readScreenOutput(ctx)
+	if source != nil {
+		go session.readSourceOutput(ctx)
+	} else {
+		go session.readScreenOutput(ctx)
+	}
 
 	// Start output broadcaster
 	go session.broadcastOutput(ctx)
 
 	sm.sessions[serverID] = session
-	log.Printf("[Console] Started session %s for server %s (screen: %s)", session.ID, serverID, screenSession)
+	logger.Info("Started session", "session_id", session.ID, "server_id", serverID, "screen_session", screenSession)
 
 	return session, nil
 }
@@ -185,7 +232,7 @@ func (sm *SessionManager) StopSession(serverID string) error {
 	session.isActive = false
 	delete(sm.sessions, serverID)
 
-	log.Printf("[Console] Stopped session for server %s", serverID)
+	logger.Info("Stopped session", "server_id", serverID)
 	return nil
 }
 
@@ -227,9 +274,9 @@ func (s *Session) readScreenOutput(ctx context.Context) {
 			if err != nil && !strings.Contains(err.Error(), "exit status 124") && !strings.Contains(err.Error(), "exit status 1") {
 				trimmedOutput := strings.TrimSpace(output)
 				if trimmedOutput != "" {
-					log.Printf("[Console] Failed to read screen output: %v (output: %s)", err, trimmedOutput)
+					logger.Error("Failed to read screen output", "error", err, "output", trimmedOutput)
 				} else {
-					log.Printf("[Console] Failed to read screen output: %v", err)
+					logger.Error("Failed to read screen output", "error", err)
 				}
 				continue
 			}
@@ -282,6 +329,42 @@ func (s *Session) readScreenOutput(ctx context.Context) {
 	}
 }
 
+// readSourceOutput follows the output of the session's source, starting over
+// when the stream ends, e.g. because the server was restarted. Streams that
+// end at once, as for a stopped server, are retried less and less often.
+func (s *Session) readSourceOutput(ctx context.Context) {
+	defer close(s.outputChan)
+
+	backoff := time.Second
+	for {
+		started := time.Now()
+		err := s.source.StreamLogs(ctx, func(line string) {
+			if clean := sanitizeConsoleLine(line); clean != "" {
+				select {
+				case s.outputChan <- clean:
+				case <-ctx.Done():
+				}
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Debug("Console stream ended", "server_id", s.ServerID, "error", err)
+		}
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		} else {
+			backoff = min(backoff*2, 30*time.Second)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
 func (s *Session) ensureResize(target string) {
 	s.mu.Lock()
 	shouldResize := false
@@ -294,7 +377,7 @@ func (s *Session) ensureResize(target string) {
 
 	if shouldResize {
 		if err := s.resizeScreen(target); err != nil {
-			log.Printf("[Console] Failed to resize screen for %s (target=%s): %v", s.ServerID, target, err)
+			logger.Error("Failed to resize screen", "server_id", s.ServerID, "target", target, "error", err)
 		}
 	}
 }
@@ -319,9 +402,9 @@ func (s *Session) resolveScreenSessionID() string {
 	if err != nil {
 		trimmed := strings.TrimSpace(output)
 		if trimmed != "" {
-			log.Printf("[Console] Failed to list screen sessions: %v (output: %s)", err, trimmed)
+			logger.Error("Failed to list screen sessions", "error", err, "output", trimmed)
 		} else {
-			log.Printf("[Console] Failed to list screen sessions: %v", err)
+			logger.Error("Failed to list screen sessions", "error", err)
 		}
 		return ""
 	}
@@ -399,6 +482,7 @@ func (s *Session) broadcastOutput(ctx context.Context) {
 			if s.logWriter != nil {
 				s.logWriter.WriteLine(line)
 			}
+			s.logIndex.Record(s.ServerID, line)
 		}
 	}
 }
@@ -418,13 +502,17 @@ func (s *Session) ExecuteCommand(command string, userID int64, username string)
 		return err
 	}
 
-	// Escape command for screen
-	escapedCmd := strings.ReplaceAll(clean, `"`, `\"`)
-	
-	// Send command to screen session using 'stuff' command
-	screenCmd := fmt.Sprintf(`screen -S %s -X stuff "%s\n"`, s.ScreenSession, escapedCmd)
-	
-	_, err = s.runCommand(screenCmd)
+	if s.source != nil {
+		err = s.source.SendCommand(clean)
+	} else {
+		// Escape command for screen
+		escapedCmd := strings.ReplaceAll(clean, `"`, `\"`)
+
+		// Send command to screen session using 'stuff' command
+		screenCmd := fmt.Sprintf(`screen -S %s -X stuff "%s\n"`, s.ScreenSession, escapedCmd)
+
+		_, err = s.runCommand(screenCmd)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to send command: %w", err)
 	}
@@ -443,7 +531,7 @@ func (s *Session) ExecuteCommand(command string, userID int64, username string)
 		Timestamp: time.Now(),
 	})
 
-	log.Printf("[Console] Command executed on %s by %s: %s", s.ServerID, username, clean)
+	logger.Info("Command executed", "server_id", s.ServerID, "username", username, "command", clean)
 	return nil
 }
 
@@ -529,7 +617,7 @@ func (s *Session) saveCommandHistory(userID int64, username, command string, suc
 	`, s.ServerID, userID, command, success, outputPreview)
 
 	if err != nil {
-		log.Printf("[Console] Failed to save command history: %v", err)
+		logger.Error("Failed to save command history", "error", err)
 	}
 }
 
@@ -0,0 +1,101 @@
+// Package credentials keeps secrets that belong to a server, such as backup
+// repository passwords and SSH keys, encrypted in the server_credentials
+// table. A server has at most one active credential of each type; rotated
+// ones stay as retired rows without their secret.
+package credentials
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/crypto"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+// logger is shared by the credentials package
+var logger = logging.For("credentials")
+
+// ErrNotFound is returned when a server has no credential of a type
+var ErrNotFound = errors.New("credential not found")
+
+// Store reads and writes server credentials
+type Store struct {
+	db *sql.DB
+
+	once       sync.Once
+	encryption *crypto.EncryptionManager
+	initErr    error
+}
+
+// NewStore creates a credential store. The encryption key is read from
+// ENCRYPTION_KEY when the store is first used.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Get returns a server's credential of a type
+func (s *Store) Get(serverID, credentialType string) (string, error) {
+	manager, err := s.manager()
+	if err != nil {
+		return "", err
+	}
+	var encrypted []byte
+	err = s.db.QueryRow(`SELECT encrypted_value FROM server_credentials WHERE server_id = ? AND credential_type = ? AND retired_at IS NULL`,
+		serverID, credentialType).Scan(&encrypted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load credential: %w", err)
+	}
+	value, err := manager.Decrypt(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s of server %s: %w", credentialType, serverID, err)
+	}
+	return value, nil
+}
+
+// Set stores a server's credential of a type, replacing the one it had
+func (s *Store) Set(serverID, credentialType, value string) error {
+	manager, err := s.manager()
+	if err != nil {
+		return err
+	}
+	encrypted, err := manager.Encrypt(value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO server_credentials (server_id, credential_type, encrypted_value, encryption_key_id, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(server_id, credential_type) WHERE retired_at IS NULL DO UPDATE SET
+			encrypted_value = excluded.encrypted_value,
+			encryption_key_id = excluded.encryption_key_id,
+			updated_at = excluded.updated_at
+	`, serverID, credentialType, encrypted, manager.GetKeyID(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save credential: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a server's active credential of a type; a missing one is not an error
+func (s *Store) Delete(serverID, credentialType string) error {
+	if _, err := s.db.Exec(`DELETE FROM server_credentials WHERE server_id = ? AND credential_type = ? AND retired_at IS NULL`, serverID, credentialType); err != nil {
+		return fmt.Errorf("failed to delete credential: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) manager() (*crypto.EncryptionManager, error) {
+	s.once.Do(func() {
+		s.encryption, s.initErr = crypto.NewEncryptionManager()
+	})
+	if s.initErr != nil {
+		return nil, fmt.Errorf("failed to initialize encryption: %w", s.initErr)
+	}
+	return s.encryption, nil
+}
@@ -0,0 +1,62 @@
+package credentials
+
+import (
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestStoreKeepsOneCredentialPerType(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	store := NewStore(db.DB)
+
+	if _, err := store.Get("alpha", "restic_password"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected no credential, got %v", err)
+	}
+	for _, set := range []struct{ server, kind, value string }{
+		{"alpha", "restic_password", "first"},
+		{"alpha", "ssh_password", "ssh"},
+		{"beta", "restic_password", "other"},
+		{"alpha", "restic_password", "second"},
+	} {
+		if err := store.Set(set.server, set.kind, set.value); err != nil {
+			t.Fatalf("set %+v: %v", set, err)
+		}
+	}
+	for _, want := range []struct{ server, kind, value string }{
+		{"alpha", "restic_password", "second"},
+		{"alpha", "ssh_password", "ssh"},
+		{"beta", "restic_password", "other"},
+	} {
+		if got, err := store.Get(want.server, want.kind); err != nil || got != want.value {
+			t.Errorf("%s %s: expected %q, got %q (%v)", want.server, want.kind, want.value, got, err)
+		}
+	}
+
+	var stored []byte
+	if err := db.DB.QueryRow(`SELECT encrypted_value FROM server_credentials WHERE server_id = 'alpha' AND credential_type = 'restic_password'`).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stored), "second") {
+		t.Fatal("expected the credential to be stored encrypted")
+	}
+
+	if err := store.Delete("alpha", "restic_password"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get("alpha", "restic_password"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the credential to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package credentials
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	hsmssh "github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// TypeSSHKey is the credential type of the key the manager logs in to a server with
+const TypeSSHKey = "ssh_key"
+
+// SSHKey is an SSH key of a server, the active one or a retired one
+type SSHKey struct {
+	ID          int64      `json:"id"`
+	Fingerprint string     `json:"fingerprint"`
+	PublicKey   string     `json:"public_key"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RetiredAt   *time.Time `json:"retired_at,omitempty"`
+}
+
+// Remote runs shell commands as a server's SSH user
+type Remote interface {
+	RunCommandContext(ctx context.Context, command string) (string, error)
+}
+
+// RemoteConn is a connection opened for a rotation, closed once it is done
+type RemoteConn interface {
+	Remote
+	Close() error
+}
+
+// SSHKeyRotation replaces the key the manager logs in to a server with
+type SSHKeyRotation struct {
+	ServerID       string
+	Current        Remote                                   // connection logged in with the current key
+	Dial           func(keyPath string) (RemoteConn, error) // logs in with the key at keyPath
+	CurrentKeyPath string                                   // the key used today, may be empty
+	KeyPath        string                                   // where the new key is written
+	KeepOldKey     bool                                     // leave the old key in authorized_keys
+}
+
+// SSHKeyRotationResult describes a finished rotation. RetireError is set when
+// the new key is in use but the old one could not be removed from the host.
+type SSHKeyRotationResult struct {
+	Key         SSHKey   `json:"key"`
+	Retired     string   `json:"retired_fingerprint,omitempty"`
+	RetireError string   `json:"retire_error,omitempty"`
+	History     []SSHKey `json:"history"`
+}
+
+// GenerateSSHKey creates an ed25519 key pair, returning the private key in
+// OpenSSH PEM format
+func GenerateSSHKey(comment string) ([]byte, ssh.PublicKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	publicKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return pem.EncodeToMemory(block), publicKey, nil
+}
+
+// RotateSSHKey generates a key, adds it to the authorized_keys of the
+// server's SSH user over the current connection, checks that it logs in,
+// switches the server to it and removes the old key from authorized_keys.
+// If the new key does not log in, it is removed again and the old one kept.
+func (s *Store) RotateSSHKey(ctx context.Context, r SSHKeyRotation) (*SSHKeyRotationResult, error) {
+	oldKey, err := readPublicKey(r.CurrentKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, publicKey, err := GenerateSSHKey("hytale-server-manager@" + r.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	authorized := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))) + " hytale-server-manager@" + r.ServerID
+
+	staging := r.KeyPath + ".new"
+	if err := hsmssh.WritePrivateKeyFile(staging, privateKey); err != nil {
+		return nil, err
+	}
+	defer os.Remove(staging)
+
+	if _, err := r.Current.RunCommandContext(ctx, addAuthorizedKeyCommand(authorized)); err != nil {
+		return nil, fmt.Errorf("failed to add the new key to authorized_keys: %w", err)
+	}
+
+	conn, err := r.Dial(staging)
+	if err == nil {
+		_, err = conn.RunCommandContext(ctx, "true")
+		if err != nil {
+			conn.Close()
+		}
+	}
+	if err != nil {
+		if _, removeErr := r.Current.RunCommandContext(ctx, removeAuthorizedKeyCommand(publicKey)); removeErr != nil {
+			logger.Warn("Failed to remove unusable SSH key", "server_id", r.ServerID, "error", removeErr)
+		}
+		return nil, fmt.Errorf("the new key does not log in: %w", err)
+	}
+	defer conn.Close()
+
+	key, err := s.SetSSHKey(r.ServerID, privateKey, publicKey, oldKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(staging, r.KeyPath); err != nil {
+		return nil, fmt.Errorf("failed to store the new key: %w", err)
+	}
+
+	result := &SSHKeyRotationResult{Key: *key}
+	if oldKey != nil && !r.KeepOldKey && ssh.FingerprintSHA256(oldKey) != key.Fingerprint {
+		result.Retired = ssh.FingerprintSHA256(oldKey)
+		if _, err := conn.RunCommandContext(ctx, removeAuthorizedKeyCommand(oldKey)); err != nil {
+			result.RetireError = err.Error()
+			logger.Warn("Failed to remove old SSH key", "server_id", r.ServerID, "error", err)
+		}
+	}
+	if result.History, err = s.SSHKeys(r.ServerID); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetSSHKey stores privateKey as a server's active SSH key and retires the
+// previous one. previous is recorded as retired when the server had no
+// stored key yet, so the history starts with the key it was created with.
+func (s *Store) SetSSHKey(serverID string, privateKey []byte, publicKey, previous ssh.PublicKey) (*SSHKey, error) {
+	manager, err := s.manager()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := manager.Encrypt(string(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	result, err := tx.Exec(`UPDATE server_credentials SET encrypted_value = ?, retired_at = ?, updated_at = ?
+		WHERE server_id = ? AND credential_type = ? AND retired_at IS NULL`,
+		[]byte{}, now, now, serverID, TypeSSHKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retire SSH key: %w", err)
+	}
+	if retired, _ := result.RowsAffected(); retired == 0 && previous != nil {
+		if _, err := tx.Exec(`
+			INSERT INTO server_credentials (server_id, credential_type, encrypted_value, encryption_key_id, fingerprint, public_key, updated_at, retired_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, serverID, TypeSSHKey, []byte{}, manager.GetKeyID(), ssh.FingerprintSHA256(previous), authorizedKey(previous), now, now); err != nil {
+			return nil, fmt.Errorf("failed to record previous SSH key: %w", err)
+		}
+	}
+
+	key := &SSHKey{
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		PublicKey:   authorizedKey(publicKey),
+		CreatedAt:   now,
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO server_credentials (server_id, credential_type, encrypted_value, encryption_key_id, fingerprint, public_key, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, serverID, TypeSSHKey, encrypted, manager.GetKeyID(), key.Fingerprint, key.PublicKey, now, now); err != nil {
+		return nil, fmt.Errorf("failed to save SSH key: %w", err)
+	}
+	if err := tx.QueryRow(`SELECT id FROM server_credentials WHERE server_id = ? AND credential_type = ? AND retired_at IS NULL`,
+		serverID, TypeSSHKey).Scan(&key.ID); err != nil {
+		return nil, fmt.Errorf("failed to load SSH key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save SSH key: %w", err)
+	}
+	return key, nil
+}
+
+// SSHKeys returns a server's SSH keys, the active one first and then the
+// retired ones, newest first
+func (s *Store) SSHKeys(serverID string) ([]SSHKey, error) {
+	rows, err := s.db.Query(`SELECT id, fingerprint, public_key, created_at, retired_at FROM server_credentials
+		WHERE server_id = ? AND credential_type = ?
+		ORDER BY retired_at IS NOT NULL, retired_at DESC, id DESC`, serverID, TypeSSHKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SSH keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]SSHKey, 0)
+	for rows.Next() {
+		var (
+			key       SSHKey
+			createdAt sql.NullTime
+			retiredAt sql.NullTime
+		)
+		if err := rows.Scan(&key.ID, &key.Fingerprint, &key.PublicKey, &createdAt, &retiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan SSH key: %w", err)
+		}
+		key.CreatedAt = createdAt.Time
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// readPublicKey returns the public half of the private key at path, nil when
+// there is no such file
+func readPublicKey(path string) (ssh.PublicKey, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, nil
+	}
+	data, err := hsmssh.ReadPrivateKeyBytes(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse current private key: %w", err)
+	}
+	return signer.PublicKey(), nil
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+// addAuthorizedKeyCommand appends line to ~/.ssh/authorized_keys unless it is there
+func addAuthorizedKeyCommand(line string) string {
+	return fmt.Sprintf(`umask 077; mkdir -p "$HOME/.ssh" && f="$HOME/.ssh/authorized_keys" && touch "$f" && chmod 700 "$HOME/.ssh" && chmod 600 "$f" && `+
+		`{ grep -qxF %[1]s "$f" || { [ -z "$(tail -c1 "$f")" ] || echo >> "$f"; printf '%%s\n' %[1]s >> "$f"; }; }`, shellQuote(line))
+}
+
+// removeAuthorizedKeyCommand drops every authorized_keys line holding key,
+// rewriting the file in place so its owner and mode are kept
+func removeAuthorizedKeyCommand(key ssh.PublicKey) string {
+	blob := strings.Fields(authorizedKey(key))[1]
+	return fmt.Sprintf(`f="$HOME/.ssh/authorized_keys"; [ -f "$f" ] || exit 0; t=$(mktemp) && { grep -vF %s "$f" > "$t" || true; } && cat "$t" > "$f"; rm -f "$t"`,
+		shellQuote(blob))
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,178 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	hsmssh "github.com/TheGojiOG/HytaleSM/internal/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// homeRemote runs commands with bash in a fake home directory, and lets in
+// only keys listed in its authorized_keys
+type homeRemote struct {
+	home string
+}
+
+func (r *homeRemote) RunCommandContext(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Env = append(os.Environ(), "HOME="+r.home)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+func (r *homeRemote) Close() error {
+	return nil
+}
+
+func (r *homeRemote) dial(keyPath string) (RemoteConn, error) {
+	public, err := readPublicKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if !r.authorized(public) {
+		return nil, errors.New("permission denied (publickey)")
+	}
+	return r, nil
+}
+
+func (r *homeRemote) authorized(key ssh.PublicKey) bool {
+	data, _ := os.ReadFile(filepath.Join(r.home, ".ssh", "authorized_keys"))
+	for len(data) > 0 {
+		public, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return false
+		}
+		if string(public.Marshal()) == string(key.Marshal()) {
+			return true
+		}
+		data = rest
+	}
+	return false
+}
+
+func TestRotateSSHKey(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	t.Setenv("ENCRYPTION_KEY", base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	store := NewStore(db.DB)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	remote := &homeRemote{home: filepath.Join(dir, "home")}
+	original, originalKey, err := GenerateSSHKey("original")
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalPath := filepath.Join(dir, "original.pem")
+	if err := os.WriteFile(originalPath, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// authorized_keys without a trailing newline, with a key of someone else
+	_, otherKey, _ := GenerateSSHKey("other")
+	if err := os.MkdirAll(filepath.Join(remote.home, ".ssh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	lines := authorizedKey(otherKey) + " someone\n" + authorizedKey(originalKey) + " original"
+	if err := os.WriteFile(filepath.Join(remote.home, ".ssh", "authorized_keys"), []byte(lines), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(dir, "managed.pem")
+	first, err := store.RotateSSHKey(ctx, SSHKeyRotation{
+		ServerID:       "alpha",
+		Current:        remote,
+		Dial:           remote.dial,
+		CurrentKeyPath: originalPath,
+		KeyPath:        keyPath,
+	})
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if first.Retired != ssh.FingerprintSHA256(originalKey) || first.RetireError != "" {
+		t.Fatalf("expected original key to be retired, got %+v", first)
+	}
+	firstKey, err := readPublicKey(keyPath)
+	if err != nil || firstKey == nil || ssh.FingerprintSHA256(firstKey) != first.Key.Fingerprint {
+		t.Fatalf("expected the new key at %s, got %v (%v)", keyPath, firstKey, err)
+	}
+	if data, _ := os.ReadFile(keyPath); !strings.HasPrefix(string(data), "ENC1\n") {
+		t.Fatalf("expected the new key to be stored encrypted")
+	}
+	if !remote.authorized(firstKey) || remote.authorized(originalKey) || !remote.authorized(otherKey) {
+		t.Fatalf("expected only the new and the other key in authorized_keys")
+	}
+	if _, err := os.Stat(keyPath + ".new"); !os.IsNotExist(err) {
+		t.Fatalf("expected the staged key to be gone, got %v", err)
+	}
+
+	second, err := store.RotateSSHKey(ctx, SSHKeyRotation{
+		ServerID:       "alpha",
+		Current:        remote,
+		Dial:           remote.dial,
+		CurrentKeyPath: keyPath,
+		KeyPath:        keyPath,
+		KeepOldKey:     true,
+	})
+	if err != nil {
+		t.Fatalf("second rotate: %v", err)
+	}
+	if second.Retired != "" || !remote.authorized(firstKey) {
+		t.Fatalf("expected the old key to be kept, got %+v", second)
+	}
+	history := second.History
+	if len(history) != 3 || history[0].RetiredAt != nil || history[0].Fingerprint != second.Key.Fingerprint ||
+		history[1].Fingerprint != first.Key.Fingerprint || history[2].Fingerprint != ssh.FingerprintSHA256(originalKey) {
+		t.Fatalf("unexpected history %+v", history)
+	}
+	for _, key := range history[1:] {
+		if key.RetiredAt == nil {
+			t.Fatalf("expected %s to be retired", key.Fingerprint)
+		}
+	}
+	stored, err := store.Get("alpha", TypeSSHKey)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if signer, err := ssh.ParsePrivateKey([]byte(stored)); err != nil || ssh.FingerprintSHA256(signer.PublicKey()) != second.Key.Fingerprint {
+		t.Fatalf("expected the active key in the vault, got %v", err)
+	}
+
+	// A key that does not log in is taken back and nothing changes
+	_, err = store.RotateSSHKey(ctx, SSHKeyRotation{
+		ServerID:       "alpha",
+		Current:        remote,
+		Dial:           func(string) (RemoteConn, error) { return nil, errors.New("permission denied") },
+		CurrentKeyPath: keyPath,
+		KeyPath:        keyPath,
+	})
+	if err == nil {
+		t.Fatalf("expected rotation to fail")
+	}
+	if current, _ := hsmssh.ReadPrivateKeyBytes(keyPath); current == nil {
+		t.Fatalf("expected the key file to remain")
+	}
+	if current, _ := readPublicKey(keyPath); ssh.FingerprintSHA256(current) != second.Key.Fingerprint {
+		t.Fatalf("expected the key to be unchanged")
+	}
+	data, _ := os.ReadFile(filepath.Join(remote.home, ".ssh", "authorized_keys"))
+	if count := strings.Count(string(data), "hytale-server-manager@alpha"); count != 2 {
+		t.Fatalf("expected the unusable key to be removed, found %d managed keys:\n%s", count, data)
+	}
+}
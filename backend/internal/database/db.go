@@ -1,52 +1,129 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/config"
 	_ "modernc.org/sqlite"
 )
 
 // DB wraps the database connection
 type DB struct {
 	*sql.DB
+	Dialect Dialect
 }
 
-// NewDB creates a new database connection
+// Open connects to the database described by the configuration
+func Open(cfg config.DatabaseConfig) (*DB, error) {
+	dialect, ok := ParseDialect(cfg.Driver)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+
+	switch dialect {
+	case DialectPostgres:
+		return newPostgresDB(cfg)
+	default:
+		return NewSQLiteDB(cfg)
+	}
+}
+
+func newPostgresDB(cfg config.DatabaseConfig) (*DB, error) {
+	if strings.TrimSpace(cfg.DSN) == "" {
+		return nil, fmt.Errorf("database dsn is required for postgres")
+	}
+
+	db, err := sql.Open(postgresDriverName, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	configurePool(db, cfg)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return &DB{DB: db, Dialect: DialectPostgres}, nil
+}
+
+// Size returns the size of the database in bytes: the pages in use for
+// SQLite, excluding the WAL, or pg_database_size for PostgreSQL
+func (db *DB) Size(ctx context.Context) (int64, error) {
+	var size int64
+	if db.Dialect == DialectPostgres {
+		err := db.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&size)
+		return size, err
+	}
+	err := db.QueryRowContext(ctx, "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	return size, err
+}
+
+// NewDB creates a new SQLite database connection with default settings
 func NewDB(dbPath string) (*DB, error) {
+	return NewSQLiteDB(config.DatabaseConfig{Path: dbPath})
+}
+
+// NewSQLiteDB creates a new SQLite database connection
+func NewSQLiteDB(cfg config.DatabaseConfig) (*DB, error) {
 	// Create directory if it doesn't exist
-	dir := filepath.Dir(dbPath)
+	dir := filepath.Dir(cfg.Path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
 	// Open database connection
-	dsn, err := buildSQLiteDSN(dbPath)
+	dsn, err := buildSQLiteDSN(cfg.Path, cfg.SQLite)
 	if err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", dsn)
+	db, err := sql.Open(sqliteDriverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
+	configurePool(db, cfg)
 
 	// Verify connection
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, Dialect: DialectSQLite}, nil
+}
+
+func configurePool(db *sql.DB, cfg config.DatabaseConfig) {
+	maxOpen := cfg.MaxConnections
+	if maxOpen <= 0 {
+		maxOpen = 25
+	}
+	maxIdle := cfg.MaxIdleConnections
+	if maxIdle <= 0 {
+		maxIdle = 5
+	}
+	if maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+
+	if lifetime, err := time.ParseDuration(cfg.ConnMaxLifetime); err == nil && lifetime > 0 {
+		db.SetConnMaxLifetime(lifetime)
+	}
 }
 
-func buildSQLiteDSN(dbPath string) (string, error) {
+func buildSQLiteDSN(dbPath string, opts config.SQLiteConfig) (string, error) {
 	absPath, err := filepath.Abs(dbPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve database path: %w", err)
@@ -55,91 +132,61 @@ func buildSQLiteDSN(dbPath string) (string, error) {
 	// Ensure forward slashes for SQLite file URI
 	absPath = strings.ReplaceAll(absPath, "\\", "/")
 
-	// Apply pragmas on every connection
-	return fmt.Sprintf("file:%s?_pragma=foreign_keys(ON)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)", absPath), nil
-}
-
-// Migrate runs all database migrations
-func (db *DB) Migrate() error {
-	// Create migrations table if it doesn't exist
-	if err := db.createMigrationsTable(); err != nil {
-		return err
+	journalMode := strings.ToUpper(strings.TrimSpace(opts.JournalMode))
+	if journalMode == "" {
+		journalMode = "WAL"
 	}
-
-	// Get list of applied migrations
-	appliedMigrations, err := db.getAppliedMigrations()
-	if err != nil {
-		return err
+	synchronous := strings.ToUpper(strings.TrimSpace(opts.Synchronous))
+	if synchronous == "" {
+		synchronous = "NORMAL"
 	}
-
-	// Run each migration that hasn't been applied
-	for _, migration := range migrations {
-		if contains(appliedMigrations, migration.Version) {
-			continue
-		}
-
-		// Execute migration
-		tx, err := db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-
-		if _, err := tx.Exec(migration.Up); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to execute migration %s: %w", migration.Version, err)
-		}
-
-		// Record migration
-		if _, err := tx.Exec("INSERT INTO migrations (version, applied_at) VALUES (?, datetime('now'))", migration.Version); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
-		}
-
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %s: %w", migration.Version, err)
-		}
-
-		fmt.Printf("Applied migration: %s\n", migration.Version)
+	busyTimeout := 5 * time.Second
+	if d, err := time.ParseDuration(opts.BusyTimeout); err == nil && d >= 0 {
+		busyTimeout = d
+	}
+	txLock := strings.ToLower(strings.TrimSpace(opts.TxLock))
+	if txLock == "" {
+		txLock = "immediate"
 	}
 
-	return nil
+	// Apply pragmas on every connection. busy_timeout must come first so the
+	// journal_mode switch itself waits for other connections instead of failing.
+	// Immediate transactions take the write lock up front, avoiding SQLITE_BUSY
+	// when a read transaction later tries to upgrade to a write.
+	query := url.Values{}
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	query.Add("_pragma", "foreign_keys(ON)")
+	query.Add("_pragma", fmt.Sprintf("journal_mode(%s)", journalMode))
+	query.Add("_pragma", fmt.Sprintf("synchronous(%s)", synchronous))
+	query.Set("_txlock", txLock)
+
+	return "file:" + absPath + "?" + query.Encode(), nil
+}
+
+// Migrate runs all pending database migrations
+func (db *DB) Migrate() error {
+	return db.MigrateTo("")
 }
 
 func (db *DB) createMigrationsTable() error {
 	query := `
 		CREATE TABLE IF NOT EXISTS migrations (
 			version TEXT PRIMARY KEY,
-			applied_at DATETIME NOT NULL
+			applied_at DATETIME NOT NULL,
+			checksum TEXT
 		)
 	`
-	_, err := db.Exec(query)
-	return err
-}
-
-func (db *DB) getAppliedMigrations() ([]string, error) {
-	rows, err := db.Query("SELECT version FROM migrations ORDER BY applied_at")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var versions []string
-	for rows.Next() {
-		var version string
-		if err := rows.Scan(&version); err != nil {
-			return nil, err
-		}
-		versions = append(versions, version)
+	if _, err := db.Exec(query); err != nil {
+		return err
 	}
 
-	return versions, rows.Err()
-}
-
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
+	// Databases created before checksums were tracked need the column added
+	var checksum sql.NullString
+	err := db.QueryRow("SELECT checksum FROM migrations LIMIT 1").Scan(&checksum)
+	if err != nil && err != sql.ErrNoRows {
+		if _, err := db.Exec("ALTER TABLE migrations ADD COLUMN checksum TEXT"); err != nil {
+			return fmt.Errorf("failed to add migration checksum column: %w", err)
 		}
 	}
-	return false
+	return nil
 }
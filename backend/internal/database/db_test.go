@@ -1,8 +1,12 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"path/filepath"
 	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
 )
 
 func TestNewDBAndMigrate(t *testing.T) {
@@ -27,3 +31,167 @@ func TestNewDBAndMigrate(t *testing.T) {
 		t.Fatalf("expected migrations to be applied")
 	}
 }
+
+func TestRollbackAndChecksumGuard(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	last := migrations[len(migrations)-1].Version
+	rolledBack, err := db.Rollback(1)
+	if err != nil {
+		t.Fatalf("failed to roll back: %v", err)
+	}
+	if len(rolledBack) != 1 || rolledBack[0] != last {
+		t.Fatalf("expected %s to be rolled back, got %v", last, rolledBack)
+	}
+
+	states, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	if states[len(states)-1].Applied {
+		t.Fatalf("expected %s to be pending after rollback", last)
+	}
+
+	if err := db.MigrateTo(last); err != nil {
+		t.Fatalf("failed to migrate to %s: %v", last, err)
+	}
+	if err := db.MigrateTo("999_missing"); err == nil {
+		t.Fatalf("expected unknown target version to fail")
+	}
+
+	if _, err := db.Exec("UPDATE migrations SET checksum = 'edited' WHERE version = ?", migrations[0].Version); err != nil {
+		t.Fatalf("failed to tamper checksum: %v", err)
+	}
+	if err := db.Migrate(); err == nil {
+		t.Fatalf("expected modified migration to be rejected")
+	}
+}
+
+func TestSQLitePragmasFromConfig(t *testing.T) {
+	db, err := NewSQLiteDB(config.DatabaseConfig{
+		Path:           filepath.Join(t.TempDir(), "test.db"),
+		MaxConnections: 4,
+		SQLite:         config.SQLiteConfig{JournalMode: "wal", BusyTimeout: "2s"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+
+	var journalMode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		t.Fatalf("failed to read journal_mode: %v", err)
+	}
+	if journalMode != "wal" {
+		t.Fatalf("expected wal journal mode, got %s", journalMode)
+	}
+
+	var busyTimeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+		t.Fatalf("failed to read busy_timeout: %v", err)
+	}
+	if busyTimeout != 2000 {
+		t.Fatalf("expected busy_timeout 2000, got %d", busyTimeout)
+	}
+
+	if got := db.Stats().MaxOpenConnections; got != 4 {
+		t.Fatalf("expected 4 max open connections, got %d", got)
+	}
+}
+
+func TestHealthMonitorRun(t *testing.T) {
+	root := t.TempDir()
+	dbPath := filepath.Join(root, "test.db")
+	db, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	var alerts int
+	monitor := NewHealthMonitor(db, dbPath, config.DatabaseHealthConfig{
+		IntegrityCheck: "quick",
+		VacuumPages:    100,
+		MaxSizeMB:      0,
+	}, func(HealthReport) { alerts++ })
+
+	report, err := monitor.Run(context.Background(), true)
+	if err != nil {
+		t.Fatalf("health run failed: %v", err)
+	}
+	if !report.IntegrityChecked || !report.IntegrityOK {
+		t.Fatalf("expected integrity check to pass, got %+v", report)
+	}
+	if report.SizeBytes == 0 || report.Status != HealthOK {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.AutoVacuum != "incremental" {
+		t.Fatalf("expected incremental auto_vacuum after maintenance, got %s", report.AutoVacuum)
+	}
+	if alerts != 0 {
+		t.Fatalf("expected no alerts for a healthy database")
+	}
+	if monitor.Latest() == nil {
+		t.Fatalf("expected latest report to be stored")
+	}
+	if size, err := db.Size(context.Background()); err != nil || size != report.SizeBytes {
+		t.Fatalf("expected Size to match the report's %d bytes, got %d (%v)", report.SizeBytes, size, err)
+	}
+}
+
+func TestServerStoreOptimisticLocking(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	store := NewServerStore(db.DB)
+	created, err := store.CreateServer(config.ServerDefinition{ID: "srv-1", Name: "One"})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	if created.Version != 1 {
+		t.Fatalf("expected version 1, got %d", created.Version)
+	}
+
+	created.Name = "Renamed"
+	updated, err := store.UpdateServer(created)
+	if err != nil {
+		t.Fatalf("failed to update server: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Fatalf("expected version 2, got %d", updated.Version)
+	}
+
+	if _, err := store.UpdateServer(created); !errors.Is(err, config.ErrVersionConflict) {
+		t.Fatalf("expected version conflict for stale update, got %v", err)
+	}
+	if err := store.DeleteServer("srv-1", 1); !errors.Is(err, config.ErrVersionConflict) {
+		t.Fatalf("expected version conflict for stale delete, got %v", err)
+	}
+
+	servers, err := store.ListServers()
+	if err != nil || len(servers) != 1 || servers[0].Name != "Renamed" || servers[0].Version != 2 {
+		t.Fatalf("unexpected stored servers %+v (err %v)", servers, err)
+	}
+
+	if err := store.DeleteServer("srv-1", 2); err != nil {
+		t.Fatalf("failed to delete server: %v", err)
+	}
+}
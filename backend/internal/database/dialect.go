@@ -0,0 +1,229 @@
+package database
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour spoken by the underlying database
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// ParseDialect maps a configured driver name to a dialect
+func ParseDialect(driver string) (Dialect, bool) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", "sqlite", "sqlite3":
+		return DialectSQLite, true
+	case "postgres", "postgresql", "pg":
+		return DialectPostgres, true
+	default:
+		return "", false
+	}
+}
+
+// Rebind converts '?' placeholders to the dialect's positional form
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	scanSQL(query, func(segment string, literal bool) {
+		if literal {
+			b.WriteString(segment)
+			return
+		}
+		for _, r := range segment {
+			if r == '?' {
+				n++
+				b.WriteByte('$')
+				b.WriteString(strconv.Itoa(n))
+				continue
+			}
+			b.WriteRune(r)
+		}
+	})
+	return b.String()
+}
+
+// Translate rewrites the SQLite-flavoured SQL used throughout the codebase into the dialect.
+// Queries and migrations are written once for SQLite; this keeps PostgreSQL support in one place.
+func (d Dialect) Translate(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	statements := splitStatements(query)
+	for i, stmt := range statements {
+		statements[i] = translatePostgresStatement(stmt)
+	}
+	return d.Rebind(strings.Join(statements, ";"))
+}
+
+var (
+	pgAutoIncrement = regexp.MustCompile(`(?i)\bINTEGER\s+PRIMARY\s+KEY\s+AUTOINCREMENT\b`)
+	pgInteger       = regexp.MustCompile(`(?i)\bINTEGER\b`)
+	pgDatetimeType  = regexp.MustCompile(`(?i)\bDATETIME\b`)
+	pgBooleanType   = regexp.MustCompile(`(?i)\bBOOLEAN\b`)
+	pgBlobType      = regexp.MustCompile(`(?i)\bBLOB\b`)
+	pgDatetimeNow   = regexp.MustCompile(`(?i)datetime\(\s*'now'\s*\)`)
+	pgInsertOr      = regexp.MustCompile(`(?i)^(\s*(?:--[^\n]*\n\s*)*)INSERT\s+OR\s+IGNORE\s+INTO\b`)
+)
+
+func translatePostgresStatement(stmt string) string {
+	ignoreConflicts := pgInsertOr.MatchString(stmt)
+	if ignoreConflicts {
+		stmt = pgInsertOr.ReplaceAllString(stmt, "${1}INSERT INTO")
+	}
+
+	// Must run before the DATETIME type rewrite below, which would otherwise match the function name
+	stmt = pgDatetimeNow.ReplaceAllString(stmt, "CURRENT_TIMESTAMP")
+
+	var b strings.Builder
+	scanSQL(stmt, func(segment string, literal bool) {
+		if literal {
+			b.WriteString(segment)
+			return
+		}
+		// Booleans are stored as SMALLINT so existing "= 1" comparisons keep working
+		segment = pgAutoIncrement.ReplaceAllString(segment, "BIGSERIAL PRIMARY KEY")
+		segment = pgInteger.ReplaceAllString(segment, "BIGINT")
+		segment = pgDatetimeType.ReplaceAllString(segment, "TIMESTAMP")
+		segment = pgBooleanType.ReplaceAllString(segment, "SMALLINT")
+		segment = pgBlobType.ReplaceAllString(segment, "BYTEA")
+		b.WriteString(segment)
+	})
+	stmt = b.String()
+
+	if ignoreConflicts {
+		end := codeEnd(stmt)
+		stmt = stmt[:end] + " ON CONFLICT DO NOTHING" + stmt[end:]
+	}
+	return stmt
+}
+
+// codeEnd returns the offset just past the last SQL token, ignoring trailing comments
+func codeEnd(stmt string) int {
+	end, offset := 0, 0
+	scanSQL(stmt, func(segment string, literal bool) {
+		if !(literal && strings.HasPrefix(segment, "--")) {
+			if trimmed := strings.TrimRight(segment, " \t\r\n"); trimmed != "" {
+				end = offset + len(trimmed)
+			}
+		}
+		offset += len(segment)
+	})
+	return end
+}
+
+// isSingleInsert reports whether a statement is one INSERT without its own RETURNING clause
+func isSingleInsert(query string) bool {
+	statements := splitStatements(query)
+	nonEmpty := 0
+	var stmt string
+	for _, s := range statements {
+		if strings.TrimSpace(stripComments(s)) != "" {
+			nonEmpty++
+			stmt = s
+		}
+	}
+	if nonEmpty != 1 {
+		return false
+	}
+	body := strings.ToUpper(strings.TrimSpace(stripComments(stmt)))
+	return strings.HasPrefix(body, "INSERT") && !strings.Contains(body, "RETURNING")
+}
+
+// splitStatements splits on ';' outside of literals and comments
+func splitStatements(query string) []string {
+	var statements []string
+	var current strings.Builder
+	scanSQL(query, func(segment string, literal bool) {
+		if literal {
+			current.WriteString(segment)
+			return
+		}
+		for {
+			idx := strings.IndexByte(segment, ';')
+			if idx < 0 {
+				current.WriteString(segment)
+				return
+			}
+			current.WriteString(segment[:idx])
+			statements = append(statements, current.String())
+			current.Reset()
+			segment = segment[idx+1:]
+		}
+	})
+	statements = append(statements, current.String())
+	return statements
+}
+
+func stripComments(query string) string {
+	var b strings.Builder
+	scanSQL(query, func(segment string, literal bool) {
+		if literal && strings.HasPrefix(segment, "--") {
+			return
+		}
+		b.WriteString(segment)
+	})
+	return b.String()
+}
+
+// scanSQL walks a query and reports plain SQL segments separately from string
+// literals, quoted identifiers and line comments, which must never be rewritten.
+func scanSQL(query string, emit func(segment string, literal bool)) {
+	start := 0
+	i := 0
+	for i < len(query) {
+		ch := query[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			if i > start {
+				emit(query[start:i], false)
+			}
+			end := i + 1
+			for end < len(query) {
+				if query[end] == ch {
+					if end+1 < len(query) && query[end+1] == ch {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end < len(query) {
+				end++
+			}
+			emit(query[i:end], true)
+			i = end
+			start = i
+		case ch == '-' && i+1 < len(query) && query[i+1] == '-':
+			if i > start {
+				emit(query[start:i], false)
+			}
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i
+			}
+			emit(query[i:end], true)
+			i = end
+			start = i
+		default:
+			i++
+		}
+	}
+	if start < len(query) {
+		emit(query[start:], false)
+	}
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRebindSkipsLiterals(t *testing.T) {
+	got := DialectPostgres.Rebind(`SELECT id FROM users WHERE name = ? AND note = 'what?' AND email = ?`)
+	want := `SELECT id FROM users WHERE name = $1 AND note = 'what?' AND email = $2`
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	if DialectSQLite.Rebind("SELECT ?") != "SELECT ?" {
+		t.Fatalf("expected sqlite queries to be left untouched")
+	}
+}
+
+func TestTranslatePostgres(t *testing.T) {
+	got := DialectPostgres.Translate(`
+CREATE TABLE t (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    active BOOLEAN DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+-- seed
+INSERT OR IGNORE INTO t (active) VALUES (1)
+-- trailing comment
+;
+UPDATE t SET created_at = datetime('now') WHERE id = ?`)
+
+	for _, want := range []string{
+		"id BIGSERIAL PRIMARY KEY",
+		"active SMALLINT DEFAULT 1",
+		"created_at TIMESTAMP",
+		"INSERT INTO t (active) VALUES (1) ON CONFLICT DO NOTHING",
+		"created_at = CURRENT_TIMESTAMP WHERE id = $1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected translation to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestIsSingleInsert(t *testing.T) {
+	if !isSingleInsert("INSERT INTO users (username) VALUES (?);") {
+		t.Fatalf("expected single insert to be detected")
+	}
+	if isSingleInsert("INSERT INTO a VALUES (1); INSERT INTO b VALUES (2)") {
+		t.Fatalf("did not expect multiple statements to be treated as a single insert")
+	}
+	if isSingleInsert("INSERT INTO a VALUES (1) RETURNING id") {
+		t.Fatalf("did not expect statements with RETURNING to be rewritten")
+	}
+}
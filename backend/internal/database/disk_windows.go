@@ -0,0 +1,10 @@
+//go:build windows
+
+package database
+
+import "errors"
+
+// diskUsage is not implemented on Windows; disk space checks are skipped
+func diskUsage(path string) (total, free uint64, err error) {
+	return 0, 0, errors.New("disk usage is not supported on windows")
+}
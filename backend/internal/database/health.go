@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// Health status levels
+const (
+	HealthOK       = "ok"
+	HealthWarning  = "warning"
+	HealthCritical = "critical"
+)
+
+// HealthReport captures the database size, fragmentation and integrity at a point in time
+type HealthReport struct {
+	CheckedAt        time.Time `json:"checked_at"`
+	DurationMs       int64     `json:"duration_ms"`
+	Driver           string    `json:"driver"`
+	Status           string    `json:"status"`
+	SizeBytes        int64     `json:"size_bytes"`
+	WALSizeBytes     int64     `json:"wal_size_bytes,omitempty"`
+	PageSize         int64     `json:"page_size,omitempty"`
+	PageCount        int64     `json:"page_count,omitempty"`
+	FreePages        int64     `json:"free_pages,omitempty"`
+	FragmentationPct float64   `json:"fragmentation_pct"`
+	AutoVacuum       string    `json:"auto_vacuum,omitempty"`
+	ReclaimedPages   int64     `json:"reclaimed_pages,omitempty"`
+	IntegrityChecked bool      `json:"integrity_checked"`
+	IntegrityOK      bool      `json:"integrity_ok"`
+	IntegrityErrors  []string  `json:"integrity_errors,omitempty"`
+	DiskTotalBytes   uint64    `json:"disk_total_bytes,omitempty"`
+	DiskFreeBytes    uint64    `json:"disk_free_bytes,omitempty"`
+	DiskUsedPct      float64   `json:"disk_used_pct,omitempty"`
+	Warnings         []string  `json:"warnings,omitempty"`
+}
+
+// HealthMonitor periodically checks and maintains the database
+type HealthMonitor struct {
+	db      *DB
+	path    string
+	cfg     config.DatabaseHealthConfig
+	onAlert func(HealthReport)
+
+	mu     sync.RWMutex
+	runMu  sync.Mutex
+	latest *HealthReport
+}
+
+// NewHealthMonitor creates a health monitor. onAlert is called whenever a run
+// ends in a warning or critical state and may be nil.
+func NewHealthMonitor(db *DB, path string, cfg config.DatabaseHealthConfig, onAlert func(HealthReport)) *HealthMonitor {
+	return &HealthMonitor{
+		db:      db,
+		path:    path,
+		cfg:     cfg,
+		onAlert: onAlert,
+	}
+}
+
+// Start runs the maintenance job on the configured interval until ctx is cancelled
+func (m *HealthMonitor) Start(ctx context.Context) {
+	if !m.cfg.Enabled {
+		return
+	}
+
+	interval, err := time.ParseDuration(m.cfg.Interval)
+	if err != nil || interval <= 0 {
+		interval = 6 * time.Hour
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				log.Printf("[Database] Stopping health monitor")
+				return
+			case <-ticker.C:
+				if _, err := m.Run(ctx, true); err != nil {
+					log.Printf("[Database] Maintenance run failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Latest returns the most recent report, or nil if no run has completed
+func (m *HealthMonitor) Latest() *HealthReport {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.latest == nil {
+		return nil
+	}
+	report := *m.latest
+	return &report
+}
+
+// Run collects a report. When maintain is set the integrity check and
+// incremental vacuum are performed as configured; otherwise only sizes are read.
+func (m *HealthMonitor) Run(ctx context.Context, maintain bool) (*HealthReport, error) {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	start := time.Now()
+	report := HealthReport{
+		CheckedAt:   start.UTC(),
+		Driver:      string(m.db.Dialect),
+		IntegrityOK: true,
+	}
+
+	var err error
+	if m.db.Dialect == DialectPostgres {
+		err = m.collectPostgres(ctx, &report)
+	} else {
+		err = m.collectSQLite(ctx, &report, maintain)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m.evaluate(&report)
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	m.mu.Lock()
+	previous := m.latest
+	m.latest = &report
+	m.mu.Unlock()
+
+	if report.Status != HealthOK {
+		log.Printf("[Database] Database health %s: %s", report.Status, strings.Join(report.Warnings, "; "))
+		// Alert on transitions only so a persistent condition does not page every run
+		if m.onAlert != nil && (previous == nil || previous.Status != report.Status) {
+			m.onAlert(report)
+		}
+	}
+
+	return &report, nil
+}
+
+func (m *HealthMonitor) collectSQLite(ctx context.Context, report *HealthReport, maintain bool) error {
+	if maintain {
+		if err := m.incrementalVacuum(ctx, report); err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("incremental vacuum failed: %v", err))
+		}
+	}
+
+	if err := m.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&report.PageSize); err != nil {
+		return fmt.Errorf("failed to read page_size: %w", err)
+	}
+	if err := m.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&report.PageCount); err != nil {
+		return fmt.Errorf("failed to read page_count: %w", err)
+	}
+	if err := m.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&report.FreePages); err != nil {
+		return fmt.Errorf("failed to read freelist_count: %w", err)
+	}
+	var autoVacuum int
+	if err := m.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err == nil {
+		report.AutoVacuum = [...]string{"none", "full", "incremental"}[autoVacuum%3]
+	}
+
+	report.SizeBytes = report.PageSize * report.PageCount
+	if report.PageCount > 0 {
+		report.FragmentationPct = roundPct(float64(report.FreePages) / float64(report.PageCount) * 100)
+	}
+	if info, err := os.Stat(m.path + "-wal"); err == nil {
+		report.WALSizeBytes = info.Size()
+	}
+
+	if maintain && m.integrityMode() != "off" {
+		if err := m.checkIntegrity(ctx, report); err != nil {
+			return err
+		}
+	}
+
+	m.collectDisk(filepath.Dir(m.path), report)
+	return nil
+}
+
+// incrementalVacuum returns free pages to the filesystem. Databases created before
+// auto_vacuum was enabled are converted once, which requires a full VACUUM.
+func (m *HealthMonitor) incrementalVacuum(ctx context.Context, report *HealthReport) error {
+	if m.cfg.VacuumPages <= 0 {
+		return nil
+	}
+
+	var mode int
+	if err := m.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return err
+	}
+	if mode != 2 {
+		log.Printf("[Database] Enabling incremental auto_vacuum (one-time full VACUUM)")
+		// The pragma only sticks if VACUUM runs on the same connection
+		conn, err := m.db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return err
+		}
+		_, err = conn.ExecContext(ctx, "VACUUM")
+		return err
+	}
+
+	var before, after int64
+	if err := m.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&before); err != nil {
+		return err
+	}
+	if before == 0 {
+		return nil
+	}
+	if _, err := m.db.ExecContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", m.cfg.VacuumPages)); err != nil {
+		return err
+	}
+	if err := m.db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&after); err != nil {
+		return err
+	}
+	report.ReclaimedPages = before - after
+	return nil
+}
+
+func (m *HealthMonitor) checkIntegrity(ctx context.Context, report *HealthReport) error {
+	pragma := "PRAGMA quick_check"
+	if m.integrityMode() == "full" {
+		pragma = "PRAGMA integrity_check"
+	}
+
+	rows, err := m.db.QueryContext(ctx, pragma)
+	if err != nil {
+		return fmt.Errorf("failed to run integrity check: %w", err)
+	}
+	defer rows.Close()
+
+	report.IntegrityChecked = true
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			report.IntegrityOK = false
+			if len(report.IntegrityErrors) < 20 {
+				report.IntegrityErrors = append(report.IntegrityErrors, line)
+			}
+		}
+	}
+	return rows.Err()
+}
+
+func (m *HealthMonitor) collectPostgres(ctx context.Context, report *HealthReport) error {
+	if err := m.db.QueryRowContext(ctx, "SELECT pg_database_size(current_database())").Scan(&report.SizeBytes); err != nil {
+		return fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	// Dead tuples are the closest analogue to SQLite free pages
+	var live, dead int64
+	err := m.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(n_live_tup), 0), COALESCE(SUM(n_dead_tup), 0) FROM pg_stat_user_tables").Scan(&live, &dead)
+	if err == nil && live+dead > 0 {
+		report.FragmentationPct = roundPct(float64(dead) / float64(live+dead) * 100)
+	}
+	return nil
+}
+
+func (m *HealthMonitor) collectDisk(dir string, report *HealthReport) {
+	total, free, err := diskUsage(dir)
+	if err != nil || total == 0 {
+		return
+	}
+	report.DiskTotalBytes = total
+	report.DiskFreeBytes = free
+	report.DiskUsedPct = roundPct(float64(total-free) / float64(total) * 100)
+}
+
+func (m *HealthMonitor) evaluate(report *HealthReport) {
+	status := HealthOK
+	raise := func(level, warning string) {
+		report.Warnings = append(report.Warnings, warning)
+		if level == HealthCritical || status == HealthOK {
+			status = level
+		}
+	}
+
+	if len(report.Warnings) > 0 {
+		status = HealthWarning
+	}
+	if report.IntegrityChecked && !report.IntegrityOK {
+		raise(HealthCritical, "integrity check failed")
+	}
+	if m.cfg.DiskWarnPercent > 0 && report.DiskTotalBytes > 0 {
+		if report.DiskUsedPct >= 98 {
+			raise(HealthCritical, fmt.Sprintf("disk is %.1f%% full", report.DiskUsedPct))
+		} else if report.DiskUsedPct >= float64(m.cfg.DiskWarnPercent) {
+			raise(HealthWarning, fmt.Sprintf("disk is %.1f%% full", report.DiskUsedPct))
+		}
+	}
+	if m.cfg.MaxSizeMB > 0 && report.SizeBytes >= int64(m.cfg.MaxSizeMB)*1024*1024 {
+		raise(HealthWarning, fmt.Sprintf("database size %d MB exceeds the %d MB limit", report.SizeBytes/(1024*1024), m.cfg.MaxSizeMB))
+	}
+	if report.FragmentationPct >= 50 && report.SizeBytes > 64*1024*1024 {
+		raise(HealthWarning, fmt.Sprintf("%.1f%% of the database is free space", report.FragmentationPct))
+	}
+
+	report.Status = status
+}
+
+func (m *HealthMonitor) integrityMode() string {
+	switch strings.ToLower(strings.TrimSpace(m.cfg.IntegrityCheck)) {
+	case "off", "none", "false":
+		return "off"
+	case "full":
+		return "full"
+	default:
+		return "quick"
+	}
+}
+
+func roundPct(value float64) float64 {
+	return float64(int64(value*10+0.5)) / 10
+}
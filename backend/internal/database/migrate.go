@@ -0,0 +1,235 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// MigrationState describes one migration as seen by the migrate status command
+type MigrationState struct {
+	Version   string
+	Applied   bool
+	AppliedAt string
+	// Modified is set when an applied migration's SQL no longer matches what was run
+	Modified bool
+	// Unknown is set for versions recorded in the database but missing from this build
+	Unknown bool
+}
+
+type appliedMigration struct {
+	appliedAt string
+	checksum  string
+}
+
+// Checksum returns the fingerprint stored for a migration when it is applied
+func (m Migration) Checksum(dialect Dialect) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(m.upFor(dialect))))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrateTo applies pending migrations in order, stopping after target.
+// An empty target applies everything.
+func (db *DB) MigrateTo(target string) error {
+	if target != "" && migrationIndex(target) < 0 {
+		return fmt.Errorf("unknown migration version: %s", target)
+	}
+
+	if err := db.createMigrationsTable(); err != nil {
+		return err
+	}
+
+	applied, err := db.loadAppliedMigrations()
+	if err != nil {
+		return err
+	}
+	if err := db.verifyChecksums(applied); err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			if err := db.applyMigration(migration); err != nil {
+				return err
+			}
+			fmt.Printf("Applied migration: %s\n", migration.Version)
+		}
+
+		if migration.Version == target {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Rollback reverts the most recently applied migrations using their Down SQL
+// and returns the versions that were rolled back.
+func (db *DB) Rollback(steps int) ([]string, error) {
+	if steps <= 0 {
+		return nil, fmt.Errorf("rollback steps must be positive")
+	}
+
+	if err := db.createMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	applied, err := db.loadAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.verifyChecksums(applied); err != nil {
+		return nil, err
+	}
+
+	var rolledBack []string
+	for i := len(migrations) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return rolledBack, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+
+		if strings.TrimSpace(migration.Down) != "" {
+			if _, err := tx.Exec(migration.Down); err != nil {
+				tx.Rollback()
+				return rolledBack, fmt.Errorf("failed to roll back migration %s: %w", migration.Version, err)
+			}
+		}
+
+		if _, err := tx.Exec("DELETE FROM migrations WHERE version = ?", migration.Version); err != nil {
+			tx.Rollback()
+			return rolledBack, fmt.Errorf("failed to remove migration record %s: %w", migration.Version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return rolledBack, fmt.Errorf("failed to commit rollback of %s: %w", migration.Version, err)
+		}
+
+		rolledBack = append(rolledBack, migration.Version)
+	}
+
+	return rolledBack, nil
+}
+
+// MigrationStatus reports every known migration and whether it has been applied
+func (db *DB) MigrationStatus() ([]MigrationState, error) {
+	if err := db.createMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	applied, err := db.loadAppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	known := make(map[string]bool, len(migrations))
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		state := MigrationState{Version: migration.Version}
+		if record, ok := applied[migration.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = record.appliedAt
+			state.Modified = record.checksum != "" && record.checksum != migration.Checksum(db.Dialect)
+		}
+		states = append(states, state)
+	}
+
+	for version, record := range applied {
+		if !known[version] {
+			states = append(states, MigrationState{Version: version, Applied: true, AppliedAt: record.appliedAt, Unknown: true})
+		}
+	}
+
+	return states, nil
+}
+
+func (db *DB) applyMigration(migration Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	if up := migration.upFor(db.Dialect); strings.TrimSpace(up) != "" {
+		if _, err := tx.Exec(up); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to execute migration %s: %w", migration.Version, err)
+		}
+	}
+
+	// Record migration
+	if _, err := tx.Exec(
+		"INSERT INTO migrations (version, applied_at, checksum) VALUES (?, datetime('now'), ?)",
+		migration.Version, migration.Checksum(db.Dialect),
+	); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %s: %w", migration.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// verifyChecksums refuses to continue when an applied migration has been edited.
+// Rows recorded before checksums existed are backfilled with the current value.
+func (db *DB) verifyChecksums(applied map[string]appliedMigration) error {
+	for _, migration := range migrations {
+		record, ok := applied[migration.Version]
+		if !ok {
+			continue
+		}
+
+		checksum := migration.Checksum(db.Dialect)
+		if record.checksum == "" {
+			if _, err := db.Exec("UPDATE migrations SET checksum = ? WHERE version = ?", checksum, migration.Version); err != nil {
+				return fmt.Errorf("failed to record checksum for %s: %w", migration.Version, err)
+			}
+			record.checksum = checksum
+			applied[migration.Version] = record
+			continue
+		}
+
+		if record.checksum != checksum {
+			return fmt.Errorf("migration %s was modified after it was applied; add a new migration instead of editing it", migration.Version)
+		}
+	}
+	return nil
+}
+
+func (db *DB) loadAppliedMigrations() (map[string]appliedMigration, error) {
+	rows, err := db.Query("SELECT version, applied_at, checksum FROM migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]appliedMigration)
+	for rows.Next() {
+		var version string
+		var appliedAt, checksum sql.NullString
+		if err := rows.Scan(&version, &appliedAt, &checksum); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedMigration{appliedAt: appliedAt.String, checksum: checksum.String}
+	}
+
+	return applied, rows.Err()
+}
+
+func migrationIndex(version string) int {
+	for i, migration := range migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
@@ -5,6 +5,16 @@ type Migration struct {
 	Version string
 	Up      string
 	Down    string
+	// Postgres replaces Up on PostgreSQL when the generic translation is not enough
+	Postgres string
+}
+
+// upFor returns the forward SQL for a dialect
+func (m Migration) upFor(dialect Dialect) string {
+	if dialect == DialectPostgres && m.Postgres != "" {
+		return m.Postgres
+	}
+	return m.Up
 }
 
 // migrations contains all database migrations in order
@@ -1135,6 +1145,1170 @@ ALTER TABLE backup_schedules ADD COLUMN use_sudo BOOLEAN NOT NULL DEFAULT 0;
 DROP INDEX IF EXISTS idx_backup_schedules_server_unique;
 `,
         Down: `
+`,
+    },
+    {
+        Version: "022_password_reset_tokens",
+        Up: `
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    requested_ip TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_expires ON password_reset_tokens(expires_at);
+`,
+        Down: `
+DROP TABLE IF EXISTS password_reset_tokens;
+`,
+    },
+    {
+        Version: "023_postgres_sequence_sync",
+        // 001 seeds organizations with an explicit id; advance the serial past it on PostgreSQL
+        Up: ``,
+        Postgres: `
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT COALESCE(MAX(id), 1) FROM organizations));
+`,
+        Down: `
+`,
+    },
+    {
+        Version: "024_system_backup_permissions",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.backups.list', 'List manager self-backups', 'system'),
+    ('system.backups.create', 'Create manager self-backups', 'system'),
+    ('system.backups.download', 'Download manager self-backups', 'system'),
+    ('system.backups.delete', 'Delete manager self-backups', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('system.backups.list', 'system.backups.create', 'system.backups.download', 'system.backups.delete')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name LIKE 'system.backups.%');
+DELETE FROM permissions WHERE name LIKE 'system.backups.%';
+`,
+    },
+    {
+        Version: "025_system_db_permissions",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.db.read', 'View manager database health', 'system'),
+    ('system.db.maintain', 'Run manager database maintenance', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('system.db.read', 'system.db.maintain')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('system.db.read', 'system.db.maintain'));
+DELETE FROM permissions WHERE name IN ('system.db.read', 'system.db.maintain');
+`,
+    },
+    {
+        Version: "026_server_definitions",
+        Up: `
+CREATE TABLE IF NOT EXISTS server_definitions (
+    id TEXT PRIMARY KEY,
+    definition TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.export', 'Export server definitions as YAML', 'servers'),
+    ('servers.import', 'Import server definitions from YAML', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('servers.export', 'servers.import')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('servers.export', 'servers.import'));
+DELETE FROM permissions WHERE name IN ('servers.export', 'servers.import');
+DROP TABLE IF EXISTS server_definitions;
+`,
+    },
+    {
+        Version: "027_system_config_reload_permission",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.config.reload', 'Reload the manager configuration file', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'system.config.reload'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'system.config.reload');
+DELETE FROM permissions WHERE name = 'system.config.reload';
+`,
+    },
+    {
+        Version: "028_system_logging_permissions",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.logging.read', 'View manager log levels', 'system'),
+    ('system.logging.update', 'Change manager log levels at runtime', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('system.logging.read', 'system.logging.update')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('system.logging.read', 'system.logging.update'));
+DELETE FROM permissions WHERE name IN ('system.logging.read', 'system.logging.update');
+`,
+    },
+    {
+        Version: "029_system_debug_permission",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.debug', 'Access profiling and runtime debug endpoints', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'system.debug'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'system.debug');
+DELETE FROM permissions WHERE name = 'system.debug';
+`,
+    },
+    {
+        Version: "030_api_keys",
+        Up: `
+CREATE TABLE IF NOT EXISTS api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    key_hash TEXT UNIQUE NOT NULL,
+    prefix TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    revoked_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+`,
+        Down: `
+DROP TABLE IF EXISTS api_keys;
+`,
+    },
+    {
+        Version: "031_config_history_permissions",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.config.history.read', 'View previous versions of the manager configuration files', 'system'),
+    ('system.config.restore', 'Restore a previous version of a manager configuration file', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('system.config.history.read', 'system.config.restore')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('system.config.history.read', 'system.config.restore'));
+DELETE FROM permissions WHERE name IN ('system.config.history.read', 'system.config.restore');
+`,
+    },
+    {
+        Version: "032_drift_permission",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.drift.read', 'View differences between a server definition and its host', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.drift.read'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'servers.drift.read');
+DELETE FROM permissions WHERE name = 'servers.drift.read';
+`,
+    },
+    {
+        Version: "033_schedules",
+        Up: `
+-- Scheduled tasks of every type; backup settings stay in backup_schedules
+CREATE TABLE IF NOT EXISTS schedules (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    server_id TEXT,
+    type TEXT NOT NULL,
+    cron TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    overlap_policy TEXT NOT NULL DEFAULT 'skip',
+    params TEXT NOT NULL DEFAULT '{}',
+    last_run DATETIME,
+    next_run DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedules_server_id ON schedules(server_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_next_run ON schedules(enabled, next_run);
+
+CREATE TABLE IF NOT EXISTS schedule_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    schedule_id TEXT NOT NULL,
+    triggered_by TEXT NOT NULL,
+    status TEXT NOT NULL,
+    output TEXT,
+    error TEXT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    FOREIGN KEY (schedule_id) REFERENCES schedules(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_runs_schedule ON schedule_runs(schedule_id, started_at DESC);
+
+-- Existing backup schedules are run by the unified scheduler from now on
+INSERT OR IGNORE INTO schedules (id, name, server_id, type, cron, enabled, overlap_policy, params, last_run, next_run, created_at, updated_at)
+SELECT 'backup-' || id, 'Backup', server_id, 'backup', schedule, enabled, 'skip',
+       '{"backup_schedule_id":"' || id || '"}', last_run, next_run, created_at, updated_at
+FROM backup_schedules;
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('schedules.read', 'View scheduled tasks and their run history', 'schedules'),
+    ('schedules.manage', 'Create, change, delete and run scheduled tasks', 'schedules');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('schedules.read', 'schedules.manage')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('schedules.read', 'schedules.manage'));
+DELETE FROM permissions WHERE name IN ('schedules.read', 'schedules.manage');
+DROP TABLE IF EXISTS schedule_runs;
+DROP TABLE IF EXISTS schedules;
+`,
+    },
+    {
+        Version: "034_maintenance_windows",
+        Up: `
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    server_ids TEXT NOT NULL DEFAULT '[]',
+    server_group TEXT,
+    starts_at DATETIME,
+    ends_at DATETIME,
+    cron TEXT,
+    duration TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    active_until DATETIME,
+    stopped_servers TEXT NOT NULL DEFAULT '[]',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('maintenance.windows.read', 'View maintenance windows', 'maintenance'),
+    ('maintenance.windows.manage', 'Create, change and delete maintenance windows', 'maintenance');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('maintenance.windows.read', 'maintenance.windows.manage')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('maintenance.windows.read', 'maintenance.windows.manage'));
+DELETE FROM permissions WHERE name IN ('maintenance.windows.read', 'maintenance.windows.manage');
+DROP TABLE IF EXISTS maintenance_windows;
+`,
+    },
+    {
+        Version: "035_release_job_auth",
+        Up: `
+ALTER TABLE release_jobs ADD COLUMN needs_auth BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE release_jobs ADD COLUMN auth_url TEXT;
+ALTER TABLE release_jobs ADD COLUMN auth_code TEXT;
+`,
+        Down: `
+`,
+    },
+    {
+        Version: "036_cluster",
+        Up: `
+CREATE TABLE IF NOT EXISTS cluster_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at INTEGER NOT NULL                 -- Unix milliseconds
+);
+
+CREATE TABLE IF NOT EXISTS cluster_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    origin TEXT NOT NULL,                       -- Instance that published the event
+    topic TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at INTEGER NOT NULL                 -- Unix milliseconds
+);
+
+CREATE TABLE IF NOT EXISTS server_tasks (
+    id TEXT PRIMARY KEY,
+    server_id TEXT NOT NULL,
+    task TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+    error TEXT,
+    request_id TEXT,
+    instance_id TEXT NOT NULL                   -- Instance running the task
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_tasks_server ON server_tasks(server_id, started_at);
+
+CREATE TABLE IF NOT EXISTS server_task_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id TEXT NOT NULL,
+    server_id TEXT NOT NULL,
+    task TEXT NOT NULL,
+    line TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_task_lines_server ON server_task_lines(server_id, id);
+CREATE INDEX IF NOT EXISTS idx_server_task_lines_task ON server_task_lines(task_id, id);
+
+CREATE TABLE IF NOT EXISTS cpu_samples (
+    source TEXT NOT NULL,                       -- collector or live
+    server_id TEXT NOT NULL,
+    idle DOUBLE PRECISION NOT NULL,
+    total DOUBLE PRECISION NOT NULL,
+    sampled_at DATETIME NOT NULL,
+    PRIMARY KEY (source, server_id)
+);
+`,
+        Down: `
+DROP TABLE IF EXISTS cpu_samples;
+DROP TABLE IF EXISTS server_task_lines;
+DROP TABLE IF EXISTS server_tasks;
+DROP TABLE IF EXISTS cluster_events;
+DROP TABLE IF EXISTS cluster_leases;
+`,
+    },
+    {
+        Version: "037_apply_permission",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.apply', 'Apply a desired-state document of servers, schedules and maintenance windows', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'system.apply'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'system.apply');
+DELETE FROM permissions WHERE name = 'system.apply';
+`,
+    },
+    {
+        Version: "038_gitops",
+        Up: `
+CREATE TABLE IF NOT EXISTS gitops_syncs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    commit_hash TEXT,
+    status TEXT NOT NULL,                       -- synced, conflict or failed
+    changes TEXT NOT NULL DEFAULT '[]',         -- JSON list of applied changes
+    conflicts TEXT NOT NULL DEFAULT '[]',       -- JSON list of changes held back
+    error TEXT,
+    forced BOOLEAN NOT NULL DEFAULT 0,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL
+);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.gitops.read', 'View the GitOps sync status', 'system'),
+    ('system.gitops.sync', 'Run a GitOps sync now', 'system');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('system.gitops.read', 'system.gitops.sync')
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('system.gitops.read', 'system.gitops.sync'));
+DELETE FROM permissions WHERE name IN ('system.gitops.read', 'system.gitops.sync');
+DROP TABLE IF EXISTS gitops_syncs;
+`,
+    },
+    {
+        Version: "039_usage_reports",
+        Up: `
+-- Hourly usage per server, rolled up from server_metrics before the raw
+-- samples expire, so usage can be reported over months
+CREATE TABLE IF NOT EXISTS server_usage_hourly (
+    server_id TEXT NOT NULL,
+    hour DATETIME NOT NULL,                     -- start of the hour, UTC
+    samples INTEGER NOT NULL,
+    avg_cpu_usage REAL,                         -- Percentage (0-100)
+    avg_memory_used INTEGER,                    -- Bytes
+    max_memory_used INTEGER,                    -- Bytes
+    avg_disk_used INTEGER,                      -- Bytes
+    max_disk_used INTEGER,                      -- Bytes
+    PRIMARY KEY (server_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_usage_hourly_hour ON server_usage_hourly(hour);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('reports.usage.read', 'View and export per-server usage reports', 'reports');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'reports.usage.read'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'reports.usage.read');
+DELETE FROM permissions WHERE name = 'reports.usage.read';
+DROP INDEX IF EXISTS idx_server_usage_hourly_hour;
+DROP TABLE IF EXISTS server_usage_hourly;
+`,
+    },
+    {
+        Version: "040_game_jobs",
+        Up: `
+-- Long-running in-game jobs such as world pre-generation, launched through
+-- the console and followed through its output
+CREATE TABLE IF NOT EXISTS game_jobs (
+    id TEXT PRIMARY KEY,
+    server_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    command TEXT NOT NULL,
+    pause_command TEXT,
+    resume_command TEXT,
+    progress_pattern TEXT,
+    done_pattern TEXT,
+    fail_pattern TEXT,
+    status TEXT NOT NULL,                       -- waiting, running, paused, complete, failed, cancelled
+    progress REAL NOT NULL DEFAULT 0,           -- Percentage (0-100)
+    last_line TEXT,
+    error TEXT,
+    log_offset INTEGER NOT NULL DEFAULT 0,      -- console log bytes already read
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_jobs_server ON game_jobs(server_id);
+CREATE INDEX IF NOT EXISTS idx_game_jobs_status ON game_jobs(status);
+`,
+        Down: `
+DROP INDEX IF EXISTS idx_game_jobs_status;
+DROP INDEX IF EXISTS idx_game_jobs_server;
+DROP TABLE IF EXISTS game_jobs;
+`,
+    },
+    {
+        Version: "041_host_security",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('security.hosts.read', 'View fail2ban bans and failed SSH logins of server hosts', 'security');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'security.hosts.read'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'security.hosts.read');
+DELETE FROM permissions WHERE name = 'security.hosts.read';
+`,
+    },
+    {
+        Version: "042_player_lists",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.players.read', 'View server whitelists and ban lists', 'servers'),
+    ('servers.players.manage', 'Edit server whitelists and ban lists', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.players.read'
+WHERE r.name IN ('Admin', 'Operator', 'Viewer');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.players.manage'
+WHERE r.name IN ('Admin', 'Operator');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('servers.players.read', 'servers.players.manage'));
+DELETE FROM permissions WHERE name IN ('servers.players.read', 'servers.players.manage');
+`,
+    },
+    {
+        Version: "043_script_library",
+        Up: `
+-- Shell scripts for routine host maintenance, run only once approved
+CREATE TABLE IF NOT EXISTS scripts (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    body TEXT NOT NULL,
+    parameters TEXT NOT NULL DEFAULT '[]',     -- JSON list of parameters
+    status TEXT NOT NULL DEFAULT 'pending',    -- pending or approved
+    created_by TEXT,
+    updated_by TEXT,
+    approved_by TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    approved_at DATETIME
+);
+
+-- Every run of a script on a host, with the text and parameters it ran with
+CREATE TABLE IF NOT EXISTS script_runs (
+    id TEXT PRIMARY KEY,
+    script_id TEXT NOT NULL,
+    script_name TEXT NOT NULL,
+    body TEXT NOT NULL,
+    server_id TEXT NOT NULL,
+    host TEXT NOT NULL,
+    task_id TEXT,
+    parameters TEXT NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL,
+    output TEXT,
+    error TEXT,
+    started_by TEXT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_script_runs_script ON script_runs(script_id);
+CREATE INDEX IF NOT EXISTS idx_script_runs_server ON script_runs(server_id);
+CREATE INDEX IF NOT EXISTS idx_script_runs_started ON script_runs(started_at);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('scripts.manage', 'Add, edit, approve and delete library scripts', 'scripts'),
+    ('scripts.run', 'Run approved library scripts on server hosts and view their runs', 'scripts');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'scripts.manage'
+WHERE r.name IN ('Admin');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'scripts.run'
+WHERE r.name IN ('Admin', 'Operator');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('scripts.manage', 'scripts.run'));
+DELETE FROM permissions WHERE name IN ('scripts.manage', 'scripts.run');
+DROP INDEX IF EXISTS idx_script_runs_started;
+DROP INDEX IF EXISTS idx_script_runs_server;
+DROP INDEX IF EXISTS idx_script_runs_script;
+DROP TABLE IF EXISTS script_runs;
+DROP TABLE IF EXISTS scripts;
+`,
+    },
+    {
+        Version: "044_file_manager",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.files.view', 'Browse and download files in server directories', 'servers'),
+    ('servers.files.edit', 'Edit, upload, rename and delete files in server directories', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('servers.files.view', 'servers.files.edit')
+WHERE r.name IN ('Admin', 'Operator');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('servers.files.view', 'servers.files.edit'));
+DELETE FROM permissions WHERE name IN ('servers.files.view', 'servers.files.edit');
+`,
+    },
+    {
+        Version: "045_server_credentials_per_type",
+        Up: `
+-- A server may keep several credentials, one of each type
+CREATE TABLE server_credentials_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT NOT NULL,
+    credential_type TEXT NOT NULL,      -- 'ssh_key', 'ssh_password', 'restic_password', ...
+    encrypted_value BLOB NOT NULL,      -- AES-256 encrypted
+    encryption_key_id TEXT NOT NULL,    -- Version/ID of encryption key used
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (server_id, credential_type)
+);
+
+INSERT INTO server_credentials_new (id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at)
+SELECT id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at FROM server_credentials;
+
+DROP TABLE server_credentials;
+ALTER TABLE server_credentials_new RENAME TO server_credentials;
+CREATE INDEX idx_credentials_server ON server_credentials(server_id);
+`,
+        Down: `
+CREATE TABLE server_credentials_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT UNIQUE NOT NULL,
+    credential_type TEXT NOT NULL,
+    encrypted_value BLOB NOT NULL,
+    encryption_key_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO server_credentials_old (id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at)
+SELECT id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at FROM server_credentials ORDER BY id;
+
+DROP TABLE server_credentials;
+ALTER TABLE server_credentials_old RENAME TO server_credentials;
+CREATE INDEX idx_credentials_server ON server_credentials(server_id);
+`,
+    },
+    {
+        Version: "046_user_identities",
+        Up: `
+-- Accounts signed in through an OpenID Connect provider, keyed by the
+-- provider's stable subject rather than the email, which can change
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+`,
+        Down: `
+DROP INDEX IF EXISTS idx_user_identities_user;
+DROP TABLE IF EXISTS user_identities;
+`,
+    },
+    {
+        Version: "047_console_log_index",
+        Up: `
+-- Console output kept for searching, with an FTS5 index over the lines
+CREATE TABLE IF NOT EXISTS console_log_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT NOT NULL,
+    logged_at TIMESTAMP NOT NULL,
+    line TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_console_log_lines_server ON console_log_lines(server_id, id);
+CREATE INDEX IF NOT EXISTS idx_console_log_lines_logged ON console_log_lines(logged_at);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS console_log_fts USING fts5(line, content='console_log_lines', content_rowid='id');
+
+CREATE TRIGGER IF NOT EXISTS console_log_lines_ai AFTER INSERT ON console_log_lines BEGIN
+    INSERT INTO console_log_fts (rowid, line) VALUES (new.id, new.line);
+END;
+
+CREATE TRIGGER IF NOT EXISTS console_log_lines_ad AFTER DELETE ON console_log_lines BEGIN
+    INSERT INTO console_log_fts (console_log_fts, rowid, line) VALUES ('delete', old.id, old.line);
+END;
+`,
+        Postgres: `
+CREATE TABLE IF NOT EXISTS console_log_lines (
+    id BIGSERIAL PRIMARY KEY,
+    server_id TEXT NOT NULL,
+    logged_at TIMESTAMP NOT NULL,
+    line TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_console_log_lines_server ON console_log_lines(server_id, id);
+CREATE INDEX IF NOT EXISTS idx_console_log_lines_logged ON console_log_lines(logged_at);
+CREATE INDEX IF NOT EXISTS idx_console_log_lines_search ON console_log_lines USING GIN (to_tsvector('simple', line));
+`,
+        Down: `
+DROP TABLE IF EXISTS console_log_fts;
+DROP TABLE IF EXISTS console_log_lines;
+`,
+    },
+    {
+        Version: "048_notifications",
+        Up: `
+-- Where notifications are sent: Discord, Slack, a webhook or email
+CREATE TABLE IF NOT EXISTS notification_channels (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    type TEXT NOT NULL,                        -- discord, slack, webhook or email
+    url TEXT NOT NULL DEFAULT '',
+    secret TEXT NOT NULL DEFAULT '',           -- signs webhook bodies
+    recipients TEXT NOT NULL DEFAULT '[]',     -- JSON list of email addresses
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Which events are sent to which channels
+CREATE TABLE IF NOT EXISTS notification_rules (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    event TEXT NOT NULL,
+    channel_ids TEXT NOT NULL DEFAULT '[]',    -- JSON list of channel IDs
+    server_ids TEXT NOT NULL DEFAULT '[]',     -- JSON list, empty for every server
+    threshold REAL NOT NULL DEFAULT 0,
+    cooldown TEXT NOT NULL DEFAULT '15m',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    rule_id TEXT NOT NULL,
+    channel_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    server_id TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_created ON notification_deliveries(created_at);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('notifications.view', 'View notification channels, rules and deliveries', 'notifications'),
+    ('notifications.manage', 'Create, edit, test and delete notification channels and rules', 'notifications');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('notifications.view', 'notifications.manage')
+WHERE r.name IN ('Admin');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'notifications.view'
+WHERE r.name IN ('Operator');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('notifications.view', 'notifications.manage'));
+DELETE FROM permissions WHERE name IN ('notifications.view', 'notifications.manage');
+DROP INDEX IF EXISTS idx_notification_deliveries_created;
+DROP TABLE IF EXISTS notification_deliveries;
+DROP TABLE IF EXISTS notification_rules;
+DROP TABLE IF EXISTS notification_channels;
+`,
+    },
+    {
+        Version: "049_alerts",
+        Up: `
+-- Thresholds on collected metrics
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    metric TEXT NOT NULL,
+    comparator TEXT NOT NULL,                  -- >, >=, < or <=
+    threshold REAL NOT NULL,
+    duration TEXT NOT NULL DEFAULT '5m',       -- how long the condition must hold
+    severity TEXT NOT NULL DEFAULT 'warning',  -- info, warning or critical
+    server_ids TEXT NOT NULL DEFAULT '[]',     -- JSON list, empty for every server
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Each period a rule's condition held for a server, firing until resolved
+CREATE TABLE IF NOT EXISTS alerts (
+    id TEXT PRIMARY KEY,
+    rule_id TEXT NOT NULL,
+    rule_name TEXT NOT NULL,
+    server_id TEXT NOT NULL,
+    metric TEXT NOT NULL,
+    comparator TEXT NOT NULL,
+    threshold REAL NOT NULL,
+    severity TEXT NOT NULL,
+    state TEXT NOT NULL,                       -- firing or resolved
+    value REAL NOT NULL,
+    started_at DATETIME NOT NULL,
+    fired_at DATETIME NOT NULL,
+    resolved_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_rule_server ON alerts(rule_id, server_id, state);
+CREATE INDEX IF NOT EXISTS idx_alerts_fired ON alerts(fired_at);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('alerts.view', 'View alert rules and alerts', 'alerts'),
+    ('alerts.manage', 'Create, edit and delete alert rules', 'alerts');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('alerts.view', 'alerts.manage')
+WHERE r.name IN ('Admin');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'alerts.view'
+WHERE r.name IN ('Operator', 'Viewer');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('alerts.view', 'alerts.manage'));
+DELETE FROM permissions WHERE name IN ('alerts.view', 'alerts.manage');
+DROP INDEX IF EXISTS idx_alerts_fired;
+DROP INDEX IF EXISTS idx_alerts_rule_server;
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS alert_rules;
+`,
+    },
+    {
+        Version: "050_metrics_rollup_keys",
+        Up: `
+-- The rollup worker rewrites the last hour and day it rolled up, so each
+-- server has one row per hour and per day
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metrics_hourly_server_hour ON server_metrics_hourly(server_id, hour_timestamp);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_metrics_daily_server_date ON server_metrics_daily(server_id, date);
+CREATE INDEX IF NOT EXISTS idx_metrics_time ON server_metrics(timestamp);
+`,
+        Down: `
+DROP INDEX IF EXISTS idx_metrics_time;
+DROP INDEX IF EXISTS idx_metrics_daily_server_date;
+DROP INDEX IF EXISTS idx_metrics_hourly_server_hour;
+`,
+    },
+    {
+        Version: "051_server_jvm_metrics",
+        Up: `
+-- Heap, GC and thread readings of the Hytale server JVM, reported by the agent
+CREATE TABLE IF NOT EXISTS server_jvm_metrics (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT NOT NULL,
+    timestamp DATETIME NOT NULL,
+    pid INTEGER NOT NULL,
+    heap_used INTEGER NOT NULL,           -- Bytes
+    heap_committed INTEGER NOT NULL,      -- Bytes
+    heap_max INTEGER NOT NULL DEFAULT 0,  -- Bytes, 0 when unknown
+    metaspace_used INTEGER NOT NULL,      -- Bytes
+    young_gc_count INTEGER NOT NULL,
+    young_gc_seconds REAL NOT NULL,       -- Since the JVM started
+    full_gc_count INTEGER NOT NULL,
+    full_gc_seconds REAL NOT NULL,
+    gc_seconds REAL NOT NULL,
+    threads INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_jvm_metrics_server_time ON server_jvm_metrics(server_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_jvm_metrics_time ON server_jvm_metrics(timestamp);
+`,
+        Down: `
+DROP INDEX IF EXISTS idx_jvm_metrics_time;
+DROP INDEX IF EXISTS idx_jvm_metrics_server_time;
+DROP TABLE IF EXISTS server_jvm_metrics;
+`,
+    },
+    {
+        Version: "052_ssh_host_keys",
+        Up: `
+-- SSH host keys pinned per server. Each server has at most one trusted key
+-- and one pending key, the last key offered that did not match
+CREATE TABLE IF NOT EXISTS ssh_host_keys (
+    server_id TEXT NOT NULL,
+    status TEXT NOT NULL,                   -- trusted or pending
+    key_type TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,              -- SHA256:...
+    public_key TEXT NOT NULL,               -- authorized_keys format
+    seen_at DATETIME NOT NULL,
+    approved_at DATETIME,
+    approved_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (server_id, status)
+);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.ssh.hostkey.read', 'View the SSH host keys pinned for a server', 'servers'),
+    ('servers.ssh.hostkey.manage', 'Approve, rotate and forget SSH host keys', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.ssh.hostkey.read'
+WHERE r.name IN ('Admin', 'Operator');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.ssh.hostkey.manage'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('servers.ssh.hostkey.read', 'servers.ssh.hostkey.manage'));
+DELETE FROM permissions WHERE name IN ('servers.ssh.hostkey.read', 'servers.ssh.hostkey.manage');
+DROP TABLE IF EXISTS ssh_host_keys;
+`,
+    },
+    {
+        Version: "053_server_credentials_history",
+        Up: `
+-- Rotated credentials stay as history: retired rows keep their fingerprint
+-- and public key but no secret, and only one row of each type is active
+CREATE TABLE server_credentials_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT NOT NULL,
+    credential_type TEXT NOT NULL,      -- 'ssh_key', 'ssh_password', 'restic_password', ...
+    encrypted_value BLOB NOT NULL,      -- AES-256 encrypted, empty once retired
+    encryption_key_id TEXT NOT NULL,    -- Version/ID of encryption key used
+    fingerprint TEXT NOT NULL DEFAULT '',  -- SHA256 fingerprint of SSH keys
+    public_key TEXT NOT NULL DEFAULT '',   -- authorized_keys line of SSH keys
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    retired_at DATETIME
+);
+
+INSERT INTO server_credentials_new (id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at)
+SELECT id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at FROM server_credentials;
+
+DROP TABLE server_credentials;
+ALTER TABLE server_credentials_new RENAME TO server_credentials;
+CREATE INDEX idx_credentials_server ON server_credentials(server_id);
+CREATE UNIQUE INDEX idx_credentials_active ON server_credentials(server_id, credential_type) WHERE retired_at IS NULL;
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.ssh.key.rotate', 'Rotate the SSH key the manager logs in to a server with', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.ssh.key.rotate'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'servers.ssh.key.rotate');
+DELETE FROM permissions WHERE name = 'servers.ssh.key.rotate';
+
+CREATE TABLE server_credentials_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT NOT NULL,
+    credential_type TEXT NOT NULL,
+    encrypted_value BLOB NOT NULL,
+    encryption_key_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (server_id, credential_type)
+);
+
+INSERT INTO server_credentials_old (id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at)
+SELECT id, server_id, credential_type, encrypted_value, encryption_key_id, created_at, updated_at FROM server_credentials WHERE retired_at IS NULL;
+
+DROP TABLE server_credentials;
+ALTER TABLE server_credentials_old RENAME TO server_credentials;
+CREATE INDEX idx_credentials_server ON server_credentials(server_id);
+`,
+    },
+    {
+        Version: "054_ssh_connection_permissions",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('system.ssh.connections.read', 'View the SSH connection pool', 'system'),
+    ('servers.ssh.connection.manage', 'Close or reconnect the SSH connection of a server', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('system.ssh.connections.read', 'servers.ssh.connection.manage')
+WHERE r.name IN ('Admin', 'Operator');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('system.ssh.connections.read', 'servers.ssh.connection.manage'));
+DELETE FROM permissions WHERE name IN ('system.ssh.connections.read', 'servers.ssh.connection.manage');
+`,
+    },
+    {
+        Version: "055_deployments",
+        Up: `
+CREATE TABLE IF NOT EXISTS deployments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    server_id TEXT NOT NULL,
+    package_name TEXT NOT NULL,
+    sha256 TEXT,
+    install_dir TEXT NOT NULL,
+    release_dir TEXT NOT NULL,  -- directory under <install_dir>/releases
+    service_user TEXT NOT NULL,
+    use_sudo BOOLEAN NOT NULL DEFAULT 1,
+    status TEXT NOT NULL,  -- 'deploying', 'active', 'superseded', 'rolled_back', 'failed', 'pruned'
+    error TEXT,
+    deployed_by INTEGER,  -- user ID
+    deployed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    rolled_back_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployments_server ON deployments(server_id, id);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.deployments.read', 'View the deployment history of a server', 'servers'),
+    ('servers.releases.rollback', 'Roll a server back to its previous release', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.deployments.read'
+WHERE r.name IN ('Admin', 'Operator');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'servers.releases.rollback'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('servers.deployments.read', 'servers.releases.rollback'));
+DELETE FROM permissions WHERE name IN ('servers.deployments.read', 'servers.releases.rollback');
+DROP INDEX IF EXISTS idx_deployments_server;
+DROP TABLE IF EXISTS deployments;
+`,
+    },
+    {
+        Version: "056_release_uploads",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('releases.upload', 'Upload server packages', 'releases');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'releases.upload'
+WHERE r.name IN ('Admin', 'ReleaseManager');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'releases.upload');
+DELETE FROM permissions WHERE name = 'releases.upload';
+`,
+    },
+    {
+        Version: "057_auto_update",
+        Up: `
+CREATE TABLE IF NOT EXISTS auto_update_policies (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    server_ids TEXT NOT NULL DEFAULT '[]',
+    server_group TEXT,
+    patchline TEXT NOT NULL,
+    window_id TEXT NOT NULL,  -- maintenance window the deploys run in
+    backup BOOLEAN NOT NULL DEFAULT 1,
+    health_startup_minutes INTEGER NOT NULL DEFAULT 0,
+    health_stable_minutes INTEGER NOT NULL DEFAULT 0,
+    rollback_on_failure BOOLEAN NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS auto_update_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    policy_id TEXT NOT NULL,
+    server_id TEXT NOT NULL,
+    release_id INTEGER NOT NULL,
+    package_name TEXT NOT NULL,
+    version TEXT NOT NULL,
+    task_id TEXT NOT NULL,
+    status TEXT NOT NULL,  -- 'running', 'complete', 'failed', 'rolled_back'
+    error TEXT,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_auto_update_runs_policy ON auto_update_runs(policy_id, server_id, release_id);
+
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('releases.auto_update.read', 'View auto-update policies and their runs', 'releases'),
+    ('releases.auto_update.manage', 'Create, change and delete auto-update policies', 'releases');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'releases.auto_update.read'
+WHERE r.name IN ('Admin', 'ReleaseManager');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name = 'releases.auto_update.manage'
+WHERE r.name IN ('Admin');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('releases.auto_update.read', 'releases.auto_update.manage'));
+DELETE FROM permissions WHERE name IN ('releases.auto_update.read', 'releases.auto_update.manage');
+DROP INDEX IF EXISTS idx_auto_update_runs_policy;
+DROP TABLE IF EXISTS auto_update_runs;
+DROP TABLE IF EXISTS auto_update_policies;
+`,
+    },
+    {
+        Version: "058_world_saves",
+        Up: `
+INSERT OR IGNORE INTO permissions (name, description, category) VALUES
+    ('servers.worlds.view', 'List and download world saves', 'servers'),
+    ('servers.worlds.manage', 'Upload, duplicate, delete and snapshot world saves', 'servers');
+
+INSERT OR IGNORE INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON p.name IN ('servers.worlds.view', 'servers.worlds.manage')
+WHERE r.name IN ('Admin', 'Operator');
+`,
+        Down: `
+DELETE FROM role_permissions WHERE permission_id IN (SELECT id FROM permissions WHERE name IN ('servers.worlds.view', 'servers.worlds.manage'));
+DELETE FROM permissions WHERE name IN ('servers.worlds.view', 'servers.worlds.manage');
+`,
+    },
+    {
+        Version: "059_task_rerun",
+        Up: `
+ALTER TABLE server_tasks ADD COLUMN rerun_of TEXT;       -- Task this one re-runs
+ALTER TABLE server_tasks ADD COLUMN method TEXT;         -- Request that started the task, replayed to re-run it
+ALTER TABLE server_tasks ADD COLUMN path TEXT;
+ALTER TABLE server_tasks ADD COLUMN params TEXT;         -- The request's JSON body
+`,
+        Down: `
+ALTER TABLE server_tasks DROP COLUMN params;
+ALTER TABLE server_tasks DROP COLUMN path;
+ALTER TABLE server_tasks DROP COLUMN method;
+ALTER TABLE server_tasks DROP COLUMN rerun_of;
 `,
     },
 }
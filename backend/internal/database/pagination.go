@@ -0,0 +1,67 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by EncodeCursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const cursorPrefix = "c1:"
+
+// PageRequest selects one page of a keyset-paginated listing. After holds the
+// sort key of the last item on the previous page; a Limit of 0 means no limit.
+type PageRequest struct {
+	Limit int
+	After string
+}
+
+// PageInfo describes the page that was returned
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// EncodeCursor turns a sort key into an opaque cursor token
+func EncodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + key))
+}
+
+// DecodeCursor returns the sort key inside a cursor token
+func DecodeCursor(cursor string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(data), cursorPrefix) {
+		return "", ErrInvalidCursor
+	}
+	return strings.TrimPrefix(string(data), cursorPrefix), nil
+}
+
+// QueryLimit is the LIMIT to query with: one extra row tells whether a next
+// page exists. It is 0 when the query should not be limited.
+func (p PageRequest) QueryLimit() int {
+	if p.Limit <= 0 {
+		return 0
+	}
+	return p.Limit + 1
+}
+
+// Trim cuts the extra row fetched by QueryLimit off a result and returns the
+// number of items to keep and whether another page follows
+func (p PageRequest) Trim(count int) (int, bool) {
+	if p.Limit > 0 && count > p.Limit {
+		return p.Limit, true
+	}
+	return count, false
+}
+
+// Info builds the page description; lastKey is the sort key of the last item kept
+func (p PageRequest) Info(total int, hasMore bool, lastKey string) PageInfo {
+	info := PageInfo{Limit: p.Limit, Total: total}
+	if hasMore {
+		info.NextCursor = EncodeCursor(lastKey)
+	}
+	return info
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// postgresDriverName is registered with database/sql. It wraps lib/pq and translates the
+// SQLite-flavoured queries used by the rest of the codebase on the fly, so callers keep
+// using *sql.DB with '?' placeholders regardless of the configured backend.
+const postgresDriverName = "hsm-postgres"
+
+func init() {
+	sql.Register(postgresDriverName, &tracedDriver{base: &pgDriver{}, system: "postgresql"})
+}
+
+type pgDriver struct{}
+
+func (d *pgDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := pq.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &pgConn{conn: conn}, nil
+}
+
+type pgConn struct {
+	conn driver.Conn
+}
+
+var (
+	_ driver.ExecerContext      = (*pgConn)(nil)
+	_ driver.QueryerContext     = (*pgConn)(nil)
+	_ driver.ConnPrepareContext = (*pgConn)(nil)
+	_ driver.ConnBeginTx        = (*pgConn)(nil)
+	_ driver.NamedValueChecker  = (*pgConn)(nil)
+	_ driver.Pinger             = (*pgConn)(nil)
+)
+
+func (c *pgConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *pgConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = DialectPostgres.Translate(query)
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.conn.Prepare(query)
+}
+
+func (c *pgConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *pgConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *pgConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.conn.Begin()
+}
+
+func (c *pgConn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *pgConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *pgConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue stores Go bools as 0/1 to match the SMALLINT columns created by Translate
+func (c *pgConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if b, ok := value.(bool); ok {
+		if b {
+			value = int64(1)
+		} else {
+			value = int64(0)
+		}
+	}
+	nv.Value = value
+	return nil
+}
+
+func (c *pgConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return q.QueryContext(ctx, DialectPostgres.Translate(query), args)
+}
+
+// ExecContext emulates LastInsertId for single INSERT statements via RETURNING,
+// which lib/pq does not otherwise support.
+func (c *pgConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !isSingleInsert(query) {
+		e, ok := c.conn.(driver.ExecerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		return e.ExecContext(ctx, DialectPostgres.Translate(query), args)
+	}
+
+	q, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	translated := DialectPostgres.Translate(query)
+	statement := strings.TrimRight(translated[:codeEnd(translated)], "; \t\r\n")
+	rows, err := q.QueryContext(ctx, statement+" RETURNING *", args)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	idIndex := -1
+	for i, column := range rows.Columns() {
+		if column == "id" {
+			idIndex = i
+			break
+		}
+	}
+
+	result := pgResult{}
+	dest := make([]driver.Value, len(rows.Columns()))
+	for {
+		if err := rows.Next(dest); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		result.affected++
+		if idIndex >= 0 {
+			if id, ok := dest[idIndex].(int64); ok {
+				result.lastID = id
+				result.hasID = true
+			}
+		}
+	}
+	return result, nil
+}
+
+type pgResult struct {
+	lastID   int64
+	hasID    bool
+	affected int64
+}
+
+func (r pgResult) LastInsertId() (int64, error) {
+	if !r.hasID {
+		return 0, fmt.Errorf("LastInsertId is not available for this statement")
+	}
+	return r.lastID, nil
+}
+
+func (r pgResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
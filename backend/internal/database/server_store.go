@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// ServerStore persists server definitions in the server_definitions table
+type ServerStore struct {
+	db *sql.DB
+}
+
+var _ config.ServerStore = (*ServerStore)(nil)
+
+// NewServerStore creates a new server definition store
+func NewServerStore(db *sql.DB) *ServerStore {
+	return &ServerStore{db: db}
+}
+
+// ListServers returns every stored definition in creation order
+func (s *ServerStore) ListServers() ([]config.ServerDefinition, error) {
+	rows, err := s.db.Query(`SELECT definition, version FROM server_definitions ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query server definitions: %w", err)
+	}
+	defer rows.Close()
+
+	servers := []config.ServerDefinition{}
+	for rows.Next() {
+		var raw string
+		var version int64
+		if err := rows.Scan(&raw, &version); err != nil {
+			return nil, err
+		}
+		var server config.ServerDefinition
+		if err := json.Unmarshal([]byte(raw), &server); err != nil {
+			return nil, fmt.Errorf("failed to decode server definition: %w", err)
+		}
+		server.Version = version
+		servers = append(servers, server)
+	}
+	return servers, rows.Err()
+}
+
+// CreateServer inserts a new definition at version 1
+func (s *ServerStore) CreateServer(server config.ServerDefinition) (config.ServerDefinition, error) {
+	server.Version = 1
+	raw, err := encodeServer(server)
+	if err != nil {
+		return server, err
+	}
+
+	now := time.Now()
+	if _, err := s.db.Exec(`
+		INSERT INTO server_definitions (id, definition, version, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, server.ID, raw, server.Version, now, now); err != nil {
+		return server, fmt.Errorf("failed to insert server definition: %w", err)
+	}
+	return server, nil
+}
+
+// UpdateServer replaces a definition and bumps its version. A non-zero
+// server.Version must match the stored version.
+func (s *ServerStore) UpdateServer(server config.ServerDefinition) (config.ServerDefinition, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return server, err
+	}
+	defer tx.Rollback()
+
+	var current int64
+	err = tx.QueryRow(`SELECT version FROM server_definitions WHERE id = ?`, server.ID).Scan(&current)
+	if err == sql.ErrNoRows {
+		return server, config.ErrServerNotFound
+	}
+	if err != nil {
+		return server, err
+	}
+	if server.Version != 0 && server.Version != current {
+		return server, config.ErrVersionConflict
+	}
+
+	server.Version = current + 1
+	raw, err := encodeServer(server)
+	if err != nil {
+		return server, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE server_definitions SET definition = ?, version = ?, updated_at = ?
+		WHERE id = ? AND version = ?
+	`, raw, server.Version, time.Now(), server.ID, current)
+	if err != nil {
+		return server, fmt.Errorf("failed to update server definition: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return server, config.ErrVersionConflict
+	}
+
+	return server, tx.Commit()
+}
+
+// DeleteServer removes a definition. A non-zero version must match the stored version.
+func (s *ServerStore) DeleteServer(id string, version int64) error {
+	query := `DELETE FROM server_definitions WHERE id = ?`
+	args := []interface{}{id}
+	if version != 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete server definition: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		var exists int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM server_definitions WHERE id = ?`, id).Scan(&exists); err == nil && exists > 0 {
+			return config.ErrVersionConflict
+		}
+		return config.ErrServerNotFound
+	}
+	return nil
+}
+
+func encodeServer(server config.ServerDefinition) (string, error) {
+	// Key material is written to disk by the API before saving; never store it inline
+	server.Connection.KeyContent = ""
+	server.Version = 0
+	raw, err := json.Marshal(server)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode server definition: %w", err)
+	}
+	return string(raw), nil
+}
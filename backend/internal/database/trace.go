@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
+)
+
+// sqliteDriverName wraps modernc's "sqlite" driver with query tracing
+const sqliteDriverName = "hsm-sqlite"
+
+func init() {
+	base, err := sql.Open("sqlite", "")
+	if err != nil {
+		panic(err)
+	}
+	sql.Register(sqliteDriverName, &tracedDriver{base: base.Driver(), system: "sqlite"})
+	_ = base.Close()
+}
+
+// tracedDriver records a span for every query issued with a context that is
+// already part of a trace, such as one derived from an API request. Queries
+// made with context.Background() pass straight through.
+type tracedDriver struct {
+	base   driver.Driver
+	system string
+}
+
+func (d *tracedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.base.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn: conn, system: d.system}, nil
+}
+
+type tracedConn struct {
+	conn   driver.Conn
+	system string
+}
+
+var (
+	_ driver.ExecerContext      = (*tracedConn)(nil)
+	_ driver.QueryerContext     = (*tracedConn)(nil)
+	_ driver.ConnPrepareContext = (*tracedConn)(nil)
+	_ driver.ConnBeginTx        = (*tracedConn)(nil)
+	_ driver.NamedValueChecker  = (*tracedConn)(nil)
+	_ driver.Pinger             = (*tracedConn)(nil)
+)
+
+func (c *tracedConn) startSpan(ctx context.Context, query string) *tracing.Span {
+	_, span := tracing.StartChild(ctx, "db.query", tracing.KindClient)
+	span.SetAttribute("db.system", c.system)
+	span.SetAttribute("db.statement", strings.Join(strings.Fields(query), " "))
+	return span
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := c.startSpan(ctx, query)
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		span.RecordError(err)
+	}
+	span.End()
+	return rows, err
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span := c.startSpan(ctx, query)
+	result, err := e.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		span.RecordError(err)
+	}
+	span.End()
+	return result, err
+}
+
+func (c *tracedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.conn.Prepare(query)
+}
+
+func (c *tracedConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *tracedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.conn.Begin()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,124 @@
+// Package features gates experimental capabilities per installation. Flags
+// start at their default and are overridden by the features section of
+// config.yaml, which the settings API writes.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Known flags
+const (
+	// AgentPush has agents push their events to the manager over a stream
+	// instead of only being polled
+	AgentPush = "agent_push"
+	// PostgresBackend allows the postgres database driver
+	PostgresBackend = "postgres_backend"
+	// ProcessManagers allows starting servers with tmux, systemd, docker or the agent instead of screen
+	ProcessManagers = "process_managers"
+)
+
+// Flag describes a feature flag
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+	// RequiresRestart is set for flags only read at startup
+	RequiresRestart bool `json:"requires_restart"`
+}
+
+// State is a flag with the value in effect
+type State struct {
+	Flag
+	Enabled bool `json:"enabled"`
+	// Source is "config" when the configuration overrides the default, else "default"
+	Source string `json:"source"`
+}
+
+var flags = []Flag{
+	{Name: AgentPush, Description: "Agents installed from now on push service, port and Java process events to the manager instead of only being polled", RequiresRestart: true},
+	{Name: PostgresBackend, Description: "Store the manager's data in PostgreSQL instead of SQLite", RequiresRestart: true},
+	{Name: ProcessManagers, Description: "Start servers with tmux, systemd, docker or the agent instead of screen"},
+}
+
+var (
+	mu        sync.RWMutex
+	overrides = map[string]bool{}
+)
+
+// All returns every known flag, sorted by name
+func All() []Flag {
+	all := append([]Flag{}, flags...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+func lookup(name string) (Flag, bool) {
+	for _, flag := range flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Validate rejects overrides of unknown flags
+func Validate(values map[string]bool) error {
+	for name := range values {
+		if _, ok := lookup(name); !ok {
+			names := make([]string, 0, len(flags))
+			for _, flag := range All() {
+				names = append(names, flag.Name)
+			}
+			return fmt.Errorf("unknown feature flag %q (known: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// EnabledIn reports whether a flag is on given the overrides in values,
+// for checks that run before Set, such as configuration validation
+func EnabledIn(values map[string]bool, name string) bool {
+	if enabled, ok := values[name]; ok {
+		return enabled
+	}
+	flag, _ := lookup(name)
+	return flag.Default
+}
+
+// Set replaces the overrides in effect, on startup and on every reload
+func Set(values map[string]bool) {
+	next := make(map[string]bool, len(values))
+	for name, enabled := range values {
+		next[name] = enabled
+	}
+	mu.Lock()
+	overrides = next
+	mu.Unlock()
+}
+
+// Enabled reports whether a flag is on. Unknown flags are off.
+func Enabled(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return EnabledIn(overrides, name)
+}
+
+// States returns every known flag with the value in effect
+func States() []State {
+	mu.RLock()
+	defer mu.RUnlock()
+	states := make([]State, 0, len(flags))
+	for _, flag := range All() {
+		state := State{Flag: flag, Enabled: flag.Default, Source: "default"}
+		if enabled, ok := overrides[flag.Name]; ok {
+			state.Enabled = enabled
+			state.Source = "config"
+		}
+		states = append(states, state)
+	}
+	return states
+}
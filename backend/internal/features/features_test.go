@@ -0,0 +1,38 @@
+package features
+
+import "testing"
+
+func TestOverridesReplaceDefaults(t *testing.T) {
+	defer Set(nil)
+
+	if Enabled(ProcessManagers) {
+		t.Fatal("expected process_managers to be off by default")
+	}
+	Set(map[string]bool{ProcessManagers: true})
+	if !Enabled(ProcessManagers) || Enabled(PostgresBackend) {
+		t.Fatal("expected only the overridden flag to be on")
+	}
+
+	for _, state := range States() {
+		want := "default"
+		if state.Name == ProcessManagers {
+			want = "config"
+		}
+		if state.Source != want {
+			t.Fatalf("expected %s to come from %s, got %s", state.Name, want, state.Source)
+		}
+	}
+
+	if Enabled("no_such_flag") {
+		t.Fatal("expected an unknown flag to be off")
+	}
+}
+
+func TestValidateRejectsUnknownFlags(t *testing.T) {
+	if err := Validate(map[string]bool{AgentPush: true}); err != nil {
+		t.Fatalf("expected a known flag to validate, got %v", err)
+	}
+	if err := Validate(map[string]bool{"agent_pull": true}); err == nil {
+		t.Fatal("expected an unknown flag to be rejected")
+	}
+}
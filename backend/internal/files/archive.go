@@ -0,0 +1,238 @@
+package files
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrBadArchive is returned for an archive Extract cannot unpack safely
+var ErrBadArchive = errors.New("invalid archive")
+
+// Size returns the bytes held by a file, or by a directory and everything in
+// it. Symlinks count as themselves and are not followed.
+func (fs *FS) Size(rel string) (int64, error) {
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return 0, err
+	}
+	size, err := fs.size(p)
+	return size, mapError(err)
+}
+
+func (fs *FS) size(p string) (int64, error) {
+	info, err := fs.client.Lstat(p)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+	children, err := fs.client.ReadDir(p)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, child := range children {
+		size, err := fs.size(path.Join(p, child.Name()))
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// Archive writes a directory as a gzipped tar to w, its entries under the
+// directory's name. Only directories and regular files are archived;
+// symlinks are left out.
+func (fs *FS) Archive(rel string, w io.Writer) error {
+	return fs.ArchiveProgress(rel, w, nil)
+}
+
+// ArchiveProgress is Archive, calling progress, when set, with the bytes of
+// file content archived so far after each read. Size gives the total to
+// expect.
+func (fs *FS) ArchiveProgress(rel string, w io.Writer, progress func(done int64)) error {
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return err
+	}
+	info, err := fs.client.Stat(p)
+	if err != nil {
+		return mapError(err)
+	}
+	if !info.IsDir() {
+		return ErrNotDir
+	}
+
+	gz := gzip.NewWriter(w)
+	a := &archiver{fs: fs, tw: tar.NewWriter(gz), progress: progress}
+	if err := a.add(p, path.Base(p), info); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// archiver writes the entries of one archive
+type archiver struct {
+	fs       *FS
+	tw       *tar.Writer
+	progress func(done int64)
+	done     int64
+}
+
+func (a *archiver) add(p, name string, info os.FileInfo) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		return nil
+	}
+	if !info.IsDir() {
+		header.Typeflag = tar.TypeReg
+		header.Size = info.Size()
+		if err := a.tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := a.fs.client.Open(p)
+		if err != nil {
+			return mapError(err)
+		}
+		defer f.Close()
+		// A file that changes while it is read is cut or padded to the size
+		// in its header, rather than breaking the archive
+		n, err := io.Copy(a.tw, &progressReader{r: io.LimitReader(f, header.Size), a: a})
+		if err == nil && n < header.Size {
+			_, err = io.CopyN(a.tw, zeroReader{}, header.Size-n)
+		}
+		return err
+	}
+
+	header.Typeflag = tar.TypeDir
+	header.Name = name + "/"
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	children, err := a.fs.client.ReadDir(p)
+	if err != nil {
+		return mapError(err)
+	}
+	for _, child := range children {
+		if err := a.add(path.Join(p, child.Name()), path.Join(name, child.Name()), child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extract unpacks a gzipped tar into rel, a directory it creates. Only
+// directories and regular files are accepted, and no entry may lead outside
+// rel; anything else fails with ErrBadArchive. A failed extract removes rel
+// again.
+func (fs *FS) Extract(rel string, r io.Reader) (err error) {
+	p, err := fs.resolveEntry(rel)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.client.Lstat(p); err == nil {
+		return ErrExists
+	}
+	if err := fs.client.Mkdir(p); err != nil {
+		return mapError(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = fs.removeAll(p)
+		}
+	}()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: not gzip compressed: %v", ErrBadArchive, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBadArchive, err)
+		}
+		name := strings.ReplaceAll(header.Name, `\`, "/")
+		clean := path.Clean(name)
+		if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("%w: entry %q is outside the archive root", ErrBadArchive, header.Name)
+		}
+		if clean == "." {
+			continue
+		}
+		target := path.Join(p, clean)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = fs.client.MkdirAll(target)
+		case tar.TypeReg:
+			err = fs.extractFile(target, tr, os.FileMode(header.Mode).Perm())
+		default:
+			return fmt.Errorf("%w: entry %q is not a file or directory", ErrBadArchive, header.Name)
+		}
+		if err != nil {
+			return mapError(err)
+		}
+	}
+}
+
+func (fs *FS) extractFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := fs.client.MkdirAll(path.Dir(target)); err != nil {
+		return err
+	}
+	f, err := fs.client.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if mode != 0 {
+		_ = fs.client.Chmod(target, mode)
+	}
+	return nil
+}
+
+// progressReader reports the file content an archiver reads through it
+type progressReader struct {
+	r io.Reader
+	a *archiver
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 && r.a.progress != nil {
+		r.a.done += int64(n)
+		r.a.progress(r.a.done)
+	}
+	return n, err
+}
+
+// zeroReader reads zero bytes forever
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
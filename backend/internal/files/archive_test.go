@@ -0,0 +1,84 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesArchiveRoundTrip(t *testing.T) {
+	fsys, dir := newTestFS(t)
+	world := filepath.Join(dir, "server", "worlds", "default")
+	if err := os.MkdirAll(filepath.Join(world, "chunks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(world, "config.json"), []byte(`{"seed":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(world, "chunks", "0.0.region"), make([]byte, 4096), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(world, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	if size, err := fsys.Size("worlds/default"); err != nil || size < 4096+10 {
+		t.Fatalf("expected the world's size, got %d %v", size, err)
+	}
+
+	var buf bytes.Buffer
+	if err := fsys.Archive("worlds/default", &buf); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if err := fsys.Extract("worlds/copy", bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "server", "worlds", "copy", "default", "config.json"))
+	if err != nil || string(data) != `{"seed":1}` {
+		t.Fatalf("expected config.json extracted, got %q %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "server", "worlds", "copy", "default", "chunks", "0.0.region"))
+	if err != nil || info.Size() != 4096 || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the region file with its mode, got %v %v", info, err)
+	}
+	// Symlinks are left out
+	if _, err := os.Lstat(filepath.Join(dir, "server", "worlds", "copy", "default", "escape")); !os.IsNotExist(err) {
+		t.Fatalf("expected the symlink to be left out, got %v", err)
+	}
+
+	if err := fsys.Extract("worlds/copy", bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected an existing target to be refused, got %v", err)
+	}
+}
+
+func TestFilesExtractRefusesUnsafeEntries(t *testing.T) {
+	fsys, dir := newTestFS(t)
+	for name, header := range map[string]*tar.Header{
+		"climbing": {Name: "../outside.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		"absolute": {Name: "/etc/outside.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		"symlink":  {Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+	} {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Close()
+		gz.Close()
+
+		if err := fsys.Extract("upload", &buf); !errors.Is(err, ErrBadArchive) {
+			t.Errorf("%s: expected ErrBadArchive, got %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "server", "upload")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected the target removed after a failed extract, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written outside the root, got %v", err)
+	}
+}
@@ -0,0 +1,507 @@
+// Package files browses and edits a server's install directory over SFTP.
+//
+// Every path handed to an FS is relative to that directory and is resolved
+// inside it: ".." cannot climb out, and symlinks are followed on the manager's
+// side, component by component, so one pointing elsewhere on the host is
+// refused rather than trusted. SFTP servers disagree on whether realpath
+// resolves symlinks, so it is not relied on.
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/sftp"
+)
+
+// MaxTextSize bounds the files read and written as text
+const MaxTextSize = 2 << 20
+
+// backupStamp is the time format in the name of a Backup copy
+const backupStamp = "20060102-150405"
+
+// maxLinks bounds the symlinks followed while resolving one path
+const maxLinks = 40
+
+var (
+	ErrOutsideRoot = errors.New("path leads outside the server directory")
+	ErrRoot        = errors.New("the server directory itself cannot be changed")
+	ErrNotFound    = errors.New("no such file or directory")
+	ErrExists      = errors.New("a file or directory with that name already exists")
+	ErrIsDir       = errors.New("path is a directory")
+	ErrNotDir      = errors.New("path is not a directory")
+	ErrNotEmpty    = errors.New("directory is not empty")
+	ErrTooLarge    = fmt.Errorf("file is larger than %d bytes", MaxTextSize)
+	ErrNotText     = errors.New("file is not UTF-8 text")
+	ErrBadMode     = errors.New("mode must be between 0 and 0777")
+)
+
+// Entry describes a file or directory
+type Entry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // relative to the server directory
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"` // permission bits in octal, e.g. "0644"
+	ModTime time.Time `json:"mod_time"`
+	Dir     bool      `json:"dir"`
+	Symlink bool      `json:"symlink,omitempty"`
+}
+
+// FS is a server directory on a host reached over SFTP
+type FS struct {
+	client *sftp.Client
+	root   string
+}
+
+// New opens root, an existing directory on the host, for browsing. A relative
+// root is taken from the SFTP user's working directory.
+func New(client *sftp.Client, root string) (*FS, error) {
+	if root == "" {
+		return nil, errors.New("server has no working directory")
+	}
+	if !path.IsAbs(root) {
+		wd, err := client.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		root = path.Join(wd, root)
+	}
+	resolved, err := resolveLinks(client, "/", root)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.Stat(resolved)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", root, ErrNotDir)
+	}
+	return &FS{client: client, root: resolved}, nil
+}
+
+// Root returns the server directory as resolved on the host
+func (fs *FS) Root() string {
+	return fs.root
+}
+
+// HostPath returns the path on the host rel leads to, for commands run there
+func (fs *FS) HostPath(rel string) (string, error) {
+	return fs.resolve(rel)
+}
+
+// Clean returns rel in the form the Entry paths take: slash separated,
+// relative to the server directory and "." for the directory itself
+func Clean(rel string) string {
+	clean := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(rel, `\`, "/")), "/")
+	if clean == "" {
+		return "."
+	}
+	return clean
+}
+
+// List returns the entries of a directory, directories first
+func (fs *FS) List(rel string) ([]Entry, error) {
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := fs.client.ReadDir(p)
+	if err != nil {
+		if info, statErr := fs.client.Stat(p); statErr == nil && !info.IsDir() {
+			return nil, ErrNotDir
+		}
+		return nil, mapError(err)
+	}
+	entries := make([]Entry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, newEntry(path.Join(Clean(rel), info.Name()), info))
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if a.Dir != b.Dir {
+			if a.Dir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries, nil
+}
+
+// Stat describes one file or directory
+func (fs *FS) Stat(rel string) (Entry, error) {
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return Entry{}, err
+	}
+	info, err := fs.client.Stat(p)
+	if err != nil {
+		return Entry{}, mapError(err)
+	}
+	return newEntry(Clean(rel), info), nil
+}
+
+// ReadText reads a text file of at most MaxTextSize bytes
+func (fs *FS) ReadText(rel string) ([]byte, error) {
+	f, _, err := fs.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, MaxTextSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxTextSize {
+		return nil, ErrTooLarge
+	}
+	if !IsText(data) {
+		return nil, ErrNotText
+	}
+	return data, nil
+}
+
+// Open opens a file for reading. The caller closes it.
+func (fs *FS) Open(rel string) (*sftp.File, Entry, error) {
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	info, err := fs.client.Stat(p)
+	if err != nil {
+		return nil, Entry{}, mapError(err)
+	}
+	if info.IsDir() {
+		return nil, Entry{}, ErrIsDir
+	}
+	entry := newEntry(Clean(rel), info)
+	f, err := fs.client.Open(p)
+	if err != nil {
+		return nil, Entry{}, mapError(err)
+	}
+	return f, entry, nil
+}
+
+// Write replaces a file's content with r, or creates the file, atomically.
+// An existing file keeps its mode. Unless overwrite is set, an existing file
+// is refused with ErrExists.
+func (fs *FS) Write(rel string, r io.Reader, overwrite bool) error {
+	if Clean(rel) == "." {
+		return ErrRoot
+	}
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return err
+	}
+	if info, err := fs.client.Stat(p); err == nil {
+		if info.IsDir() {
+			return ErrIsDir
+		}
+		if !overwrite {
+			return ErrExists
+		}
+	}
+	if info, err := fs.client.Stat(path.Dir(p)); err != nil {
+		return mapError(err)
+	} else if !info.IsDir() {
+		return ErrNotDir
+	}
+	return WriteAtomic(fs.client, p, r)
+}
+
+// Mkdir creates a directory whose parent exists
+func (fs *FS) Mkdir(rel string) error {
+	p, err := fs.resolveEntry(rel)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.client.Lstat(p); err == nil {
+		return ErrExists
+	}
+	return mapError(fs.client.Mkdir(p))
+}
+
+// Rename moves a file or directory within the server directory. A symlink
+// is moved itself, not what it points to. An existing target is refused.
+func (fs *FS) Rename(from, to string) error {
+	src, err := fs.resolveEntry(from)
+	if err != nil {
+		return err
+	}
+	dst, err := fs.resolveEntry(to)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.client.Lstat(src); err != nil {
+		return mapError(err)
+	}
+	if _, err := fs.client.Lstat(dst); err == nil {
+		return ErrExists
+	}
+	if dst == src || strings.HasPrefix(dst, src+"/") {
+		return fmt.Errorf("cannot move %s into itself", Clean(from))
+	}
+	return mapError(fs.client.Rename(src, dst))
+}
+
+// Remove deletes a file, a symlink or an empty directory, or with recursive
+// a directory and everything in it. Symlinks inside are removed, never followed.
+func (fs *FS) Remove(rel string, recursive bool) error {
+	p, err := fs.resolveEntry(rel)
+	if err != nil {
+		return err
+	}
+	info, err := fs.client.Lstat(p)
+	if err != nil {
+		return mapError(err)
+	}
+	if !info.IsDir() {
+		return mapError(fs.client.Remove(p))
+	}
+	if !recursive {
+		children, err := fs.client.ReadDir(p)
+		if err != nil {
+			return mapError(err)
+		}
+		if len(children) > 0 {
+			return ErrNotEmpty
+		}
+		return mapError(fs.client.RemoveDirectory(p))
+	}
+	return mapError(fs.removeAll(p))
+}
+
+// Chmod sets the permission bits of a file or directory
+func (fs *FS) Chmod(rel string, mode os.FileMode) error {
+	if mode&^os.ModePerm != 0 {
+		return ErrBadMode
+	}
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return err
+	}
+	return mapError(fs.client.Chmod(p, mode))
+}
+
+// removeAll deletes p and, when it is a directory, all it holds. Unlike
+// sftp.Client.RemoveAll it looks at every entry with lstat, so a symlink to
+// a directory elsewhere is unlinked instead of emptied.
+func (fs *FS) removeAll(p string) error {
+	info, err := fs.client.Lstat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fs.client.Remove(p)
+	}
+	children, err := fs.client.ReadDir(p)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := fs.removeAll(path.Join(p, child.Name())); err != nil {
+			return err
+		}
+	}
+	return fs.client.RemoveDirectory(p)
+}
+
+// resolve returns the path on the host rel leads to, following symlinks
+func (fs *FS) resolve(rel string) (string, error) {
+	p, err := resolveLinks(fs.client, fs.root, Clean(rel))
+	if err != nil {
+		return "", err
+	}
+	if !within(fs.root, p) {
+		return "", ErrOutsideRoot
+	}
+	return p, nil
+}
+
+// resolveEntry returns the path on the host of rel itself: its directory is
+// resolved, but a symlink rel names is not followed. The server directory
+// itself is refused.
+func (fs *FS) resolveEntry(rel string) (string, error) {
+	clean := Clean(rel)
+	if clean == "." {
+		return "", ErrRoot
+	}
+	dir, err := fs.resolve(path.Dir(clean))
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, path.Base(clean)), nil
+}
+
+// resolveLinks joins rel onto base, an absolute path without symlinks, and
+// follows the symlinks along the way. Components that do not exist yet are
+// kept as they are.
+func resolveLinks(client *sftp.Client, base, rel string) (string, error) {
+	resolved := base
+	rest := split(rel)
+	for links := 0; len(rest) > 0; {
+		next := path.Join(resolved, rest[0])
+		rest = rest[1:]
+		info, err := client.Lstat(next)
+		if errors.Is(err, os.ErrNotExist) {
+			return path.Join(append([]string{next}, rest...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxLinks {
+			return "", fmt.Errorf("too many levels of symbolic links in %s", rel)
+		}
+		target, err := client.ReadLink(next)
+		if err != nil {
+			return "", err
+		}
+		if path.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(split(target), rest...)
+	}
+	return resolved, nil
+}
+
+// split breaks a path into its components, dropping the empty ones. ".."
+// stays, to be applied once the components before it are resolved.
+func split(p string) []string {
+	return slices.DeleteFunc(strings.Split(p, "/"), func(part string) bool {
+		return part == "" || part == "."
+	})
+}
+
+// within reports whether p is root or lies inside it
+func within(root, p string) bool {
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
+// WriteAtomic writes r next to p and renames it over p, so the server never
+// reads a half-written file. The new file keeps the old one's mode and, where
+// the SFTP user may change it, its owner.
+func WriteAtomic(client *sftp.Client, p string, r io.Reader) error {
+	mode := os.FileMode(0644)
+	previous, statErr := client.Stat(p)
+	if statErr == nil {
+		mode = previous.Mode().Perm()
+	}
+
+	tmp := path.Join(path.Dir(p), fmt.Sprintf(".%s.%08x.tmp", path.Base(p), rand.Uint32()))
+	f, err := client.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		client.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		client.Remove(tmp)
+		return err
+	}
+	_ = client.Chmod(tmp, mode)
+	if statErr == nil {
+		if stat, ok := previous.Sys().(*sftp.FileStat); ok {
+			_ = client.Chown(tmp, int(stat.UID), int(stat.GID))
+		}
+	}
+
+	if err := client.PosixRename(tmp, p); err != nil {
+		// Servers without the posix-rename extension only rename onto a free name
+		if renameErr := client.Rename(tmp, p); renameErr != nil {
+			client.Remove(tmp)
+			return err
+		}
+	}
+	return nil
+}
+
+// Backup copies data, the current content of rel, to a timestamped file
+// next to it, such as config.json.20260120-180411.bak, and deletes the
+// oldest copies beyond keep. It returns the copy's path, relative to the
+// server directory.
+func (fs *FS) Backup(rel string, data []byte, keep int) (string, error) {
+	p, err := fs.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	dir, base := path.Dir(p), path.Base(p)
+	name := fmt.Sprintf("%s.%s.bak", base, time.Now().UTC().Format(backupStamp))
+	if err := WriteAtomic(fs.client, path.Join(dir, name), bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	backup := path.Join(path.Dir(Clean(rel)), name)
+
+	infos, err := fs.client.ReadDir(dir)
+	if err != nil {
+		return backup, nil
+	}
+	var backups []string
+	for _, info := range infos {
+		stamp, hasBase := strings.CutPrefix(info.Name(), base+".")
+		stamp, hasSuffix := strings.CutSuffix(stamp, ".bak")
+		if !hasBase || !hasSuffix || info.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(backupStamp, stamp); err == nil {
+			backups = append(backups, info.Name())
+		}
+	}
+	// The stamps sort in time order
+	slices.Sort(backups)
+	for len(backups) > keep {
+		_ = fs.client.Remove(path.Join(dir, backups[0]))
+		backups = backups[1:]
+	}
+	return backup, nil
+}
+
+// IsText reports whether data looks like a text file: UTF-8 without NUL bytes
+func IsText(data []byte) bool {
+	return utf8.Valid(data) && !slices.Contains(data, 0)
+}
+
+// Checksum identifies a version of a file's content, so an edit made against
+// an older version can be refused
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func newEntry(rel string, info os.FileInfo) Entry {
+	return Entry{
+		Name:    info.Name(),
+		Path:    rel,
+		Size:    info.Size(),
+		Mode:    fmt.Sprintf("%04o", info.Mode().Perm()),
+		ModTime: info.ModTime().UTC(),
+		Dir:     info.IsDir(),
+		Symlink: info.Mode()&os.ModeSymlink != 0,
+	}
+}
+
+// mapError turns the SFTP status for a missing file into ErrNotFound
+func mapError(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// newTestFS serves SFTP for a temporary directory in-process and opens its
+// "server" subdirectory
+func newTestFS(t *testing.T) (*FS, string) {
+	t.Helper()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	if err != nil {
+		t.Fatalf("start sftp server: %v", err)
+	}
+	go server.Serve()
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	if err != nil {
+		t.Fatalf("start sftp client: %v", err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "server")
+	if err := os.MkdirAll(filepath.Join(root, "mods"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := New(client, filepath.ToSlash(root))
+	if err != nil {
+		t.Fatalf("open root: %v", err)
+	}
+	return fsys, dir
+}
+
+func TestFilesStayInsideRoot(t *testing.T) {
+	fsys, dir := newTestFS(t)
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("outside"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dir, filepath.Join(dir, "server", "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("mods", filepath.Join(dir, "server", "plugins")); err != nil {
+		t.Fatal(err)
+	}
+
+	// ".." is cleaned against the root, so it cannot climb out
+	if err := fsys.Write("../../secret.txt", strings.NewReader("inside"), true); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "secret.txt")); string(data) != "outside" {
+		t.Fatalf("expected the file outside the root to be untouched, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "server", "secret.txt")); err != nil {
+		t.Fatalf("expected the file inside the root: %v", err)
+	}
+
+	for _, rel := range []string{"escape/secret.txt", "escape", "plugins/../escape/secret.txt"} {
+		if _, err := fsys.ReadText(rel); !errors.Is(err, ErrOutsideRoot) {
+			t.Errorf("expected %s to be refused, got %v", rel, err)
+		}
+	}
+	if err := fsys.Chmod("escape/secret.txt", 0644); !errors.Is(err, ErrOutsideRoot) {
+		t.Errorf("expected chmod through the symlink to be refused, got %v", err)
+	}
+
+	// A symlink inside the root works, and deleting one leaves its target
+	if err := fsys.Write("plugins/a.json", strings.NewReader("{}"), false); err != nil {
+		t.Fatalf("write through an inner symlink: %v", err)
+	}
+	if err := fsys.Remove("escape", true); err != nil {
+		t.Fatalf("remove symlink: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "secret.txt")); err != nil {
+		t.Fatalf("expected the symlink's target to survive: %v", err)
+	}
+	if err := fsys.Remove("", true); !errors.Is(err, ErrRoot) {
+		t.Fatalf("expected the root to be kept, got %v", err)
+	}
+}
+
+func TestFilesEdit(t *testing.T) {
+	fsys, dir := newTestFS(t)
+	root := filepath.Join(dir, "server")
+
+	if err := fsys.Write("config.json", strings.NewReader(`{"a":1}`), false); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := fsys.Write("config.json", strings.NewReader(`{}`), false); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected an existing file to need overwrite, got %v", err)
+	}
+	if err := os.Chmod(filepath.Join(root, "config.json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Write("config.json", strings.NewReader(`{"a":2}`), true); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	data, err := fsys.ReadText("config.json")
+	if err != nil || string(data) != `{"a":2}` {
+		t.Fatalf("expected the new content, got %q (%v)", data, err)
+	}
+	if entry, _ := fsys.Stat("config.json"); entry.Mode != "0600" || entry.Path != "config.json" {
+		t.Fatalf("expected the file to keep its mode, got %+v", entry)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "world.bin"), []byte{0x00, 0x01}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.ReadText("world.bin"); !errors.Is(err, ErrNotText) {
+		t.Fatalf("expected a binary file to be refused, got %v", err)
+	}
+
+	if err := fsys.Mkdir("backups"); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := fsys.Rename("config.json", "backups/config.json"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := fsys.Rename("mods", "mods/inner"); err == nil {
+		t.Fatal("expected a directory not to move into itself")
+	}
+	if err := fsys.Chmod("backups/config.json", 0640); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if err := fsys.Chmod("backups/config.json", 04755); !errors.Is(err, ErrBadMode) {
+		t.Fatalf("expected setuid to be refused, got %v", err)
+	}
+
+	entries, err := fsys.List("/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	if strings.Join(names, ",") != "backups,mods,world.bin" {
+		t.Fatalf("expected directories first, got %v", names)
+	}
+	if _, err := fsys.List("world.bin"); !errors.Is(err, ErrNotDir) {
+		t.Fatalf("expected listing a file to fail, got %v", err)
+	}
+
+	if err := fsys.Remove("backups", false); !errors.Is(err, ErrNotEmpty) {
+		t.Fatalf("expected a non-empty directory to need recursive, got %v", err)
+	}
+	if err := fsys.Remove("backups", true); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := fsys.Stat("backups"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the directory to be gone, got %v", err)
+	}
+}
+
+func TestFilesBackupKeepsTheNewest(t *testing.T) {
+	fsys, dir := newTestFS(t)
+	root := filepath.Join(dir, "server")
+	for _, name := range []string{"config.json.20250101-000000.bak", "config.json.20250102-000000.bak", "config.json.notes.bak"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backup, err := fsys.Backup("config.json", []byte(`{"a":1}`), 2)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, backup)); err != nil || string(data) != `{"a":1}` {
+		t.Fatalf("expected the copy at %s, got %q (%v)", backup, data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "config.json.20250101-000000.bak")); !os.IsNotExist(err) {
+		t.Fatalf("expected the oldest copy to be deleted, got %v", err)
+	}
+	for _, name := range []string{"config.json.20250102-000000.bak", "config.json.notes.bak"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("expected %s to be kept: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package gamejobs
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+type fakeControl struct {
+	state    ServerState
+	console  string
+	commands []string
+	tasks    map[string]error
+	lines    []string
+}
+
+func (f *fakeControl) ServerState(serverID string) ServerState {
+	return f.state
+}
+
+func (f *fakeControl) SendCommand(ctx context.Context, serverID, command string) error {
+	f.commands = append(f.commands, command)
+	return nil
+}
+
+func (f *fakeControl) ReadConsole(ctx context.Context, serverID string, offset, limit int64) (string, int64, error) {
+	size := int64(len(f.console))
+	if offset >= size {
+		return "", size, nil
+	}
+	return f.console[offset:min(size, offset+limit)], size, nil
+}
+
+func (f *fakeControl) StartTask(ctx context.Context, serverID, name string) string {
+	id := fmt.Sprintf("task-%d", len(f.tasks)+1)
+	f.tasks[id] = nil
+	return id
+}
+
+func (f *fakeControl) TaskOutput(serverID, taskID, name, line string) {
+	f.lines = append(f.lines, line)
+}
+
+func (f *fakeControl) FinishTask(serverID, taskID string, err error) {
+	f.tasks[taskID] = err
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeControl) {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	control := &fakeControl{state: ServerRunning, console: "[boot] Server started\n", tasks: make(map[string]error)}
+	return NewManager(NewStore(db.DB), control), control
+}
+
+func TestParseProgress(t *testing.T) {
+	cases := []struct {
+		groups []string
+		want   float64
+		ok     bool
+	}{
+		{[]string{"42.5"}, 42.5, true},
+		{[]string{"1,024", "4,096"}, 25, true},
+		{[]string{"5", "0"}, 0, false},
+		{[]string{"250"}, 100, true},
+	}
+	for _, tc := range cases {
+		got, ok := parseProgress(tc.groups)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseProgress(%v) = %v, %v; want %v, %v", tc.groups, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	job := Job{Command: "pregen start", ProgressPattern: `(\d+)/(\d+)/(\d+)`}
+	job.Normalize()
+	if err := job.Validate(); err == nil {
+		t.Fatal("expected a progress pattern with three groups to be rejected")
+	}
+}
+
+func TestJobSurvivesServerRestart(t *testing.T) {
+	manager, control := newTestManager(t)
+	ctx := context.Background()
+
+	job := &Job{
+		ID:              "pregen",
+		ServerID:        "alpha",
+		Command:         "pregen start 5000",
+		PauseCommand:    "pregen pause",
+		ResumeCommand:   "pregen resume",
+		ProgressPattern: `Generated (\d+)/(\d+) chunks`,
+		DonePattern:     `Pregeneration finished`,
+	}
+	if err := manager.Create(ctx, job); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Launched, ignoring what the console printed before
+	manager.reconcile(ctx)
+	control.console += "Generated 100/400 chunks\nGenerated 200/4"
+	manager.reconcile(ctx)
+	job, _ = manager.Get(ctx, "pregen")
+	if job.Status != StatusRunning || job.Progress != 25 || len(control.commands) != 1 || control.commands[0] != "pregen start 5000" {
+		t.Fatalf("expected a running job at 25%%, got %+v after %v", job, control.commands)
+	}
+
+	// The server stops and comes back: the job waits, then resumes
+	control.state = ServerStopped
+	manager.reconcile(ctx)
+	if job, _ = manager.Get(ctx, "pregen"); job.Status != StatusWaiting {
+		t.Fatalf("expected the job to wait for its server, got %s", job.Status)
+	}
+	if err := control.tasks["task-1"]; err == nil {
+		t.Fatal("expected the interrupted run's task to fail")
+	}
+	control.state = ServerRunning
+	control.console = "[boot] Server started\n"
+	manager.reconcile(ctx)
+	if last := control.commands[len(control.commands)-1]; last != "pregen resume" {
+		t.Fatalf("expected the job to be resumed, sent %v", control.commands)
+	}
+
+	// Paused by hand, then finished after resuming
+	if _, err := manager.Pause(ctx, "pregen"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if _, err := manager.Pause(ctx, "pregen"); err != ErrNotActive {
+		t.Fatalf("expected pausing twice to fail, got %v", err)
+	}
+	manager.reconcile(ctx)
+	if _, err := manager.Resume(ctx, "pregen"); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	manager.reconcile(ctx)
+	control.console += "Generated 400/400 chunks\nPregeneration finished\n"
+	manager.reconcile(ctx)
+
+	job, _ = manager.Get(ctx, "pregen")
+	if job.Status != StatusComplete || job.Progress != 100 || job.FinishedAt == nil {
+		t.Fatalf("expected a complete job, got %+v", job)
+	}
+	want := []string{"pregen start 5000", "pregen resume", "pregen pause", "pregen resume"}
+	if fmt.Sprint(control.commands) != fmt.Sprint(want) {
+		t.Fatalf("expected commands %v, got %v", want, control.commands)
+	}
+	if _, err := manager.Cancel(ctx, "pregen"); err != ErrNotActive {
+		t.Fatalf("expected cancelling a complete job to fail, got %v", err)
+	}
+}
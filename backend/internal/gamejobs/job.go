@@ -0,0 +1,171 @@
+package gamejobs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Job statuses
+const (
+	// StatusWaiting jobs are launched, or resumed, as soon as their server runs
+	StatusWaiting = "waiting"
+	// StatusRunning jobs have been sent their command and are followed through the console
+	StatusRunning = "running"
+	// StatusPaused jobs were paused by hand and wait to be resumed
+	StatusPaused    = "paused"
+	StatusComplete  = "complete"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// DefaultProgressPattern matches a percentage such as "Generated 42.5%"
+const DefaultProgressPattern = `(\d+(?:\.\d+)?)\s*%`
+
+// Job is a long-running in-game job, such as world pre-generation, started
+// by sending Command to a server's console. Its progress is read from the
+// console output with ProgressPattern: one capture group is a percentage, two
+// are done and total. A line matching DonePattern completes the job, or
+// reaching 100% when there is none, and one matching FailPattern fails it.
+type Job struct {
+	ID       string `json:"id"`
+	ServerID string `json:"server_id"`
+	Name     string `json:"name"`
+	Command  string `json:"command"`
+	// PauseCommand is sent when the job is paused or cancelled
+	PauseCommand string `json:"pause_command,omitempty"`
+	// ResumeCommand continues the job after a pause or a server restart; it
+	// defaults to Command
+	ResumeCommand   string     `json:"resume_command"`
+	ProgressPattern string     `json:"progress_pattern"`
+	DonePattern     string     `json:"done_pattern,omitempty"`
+	FailPattern     string     `json:"fail_pattern,omitempty"`
+	Status          string     `json:"status"`
+	Progress        float64    `json:"progress"`
+	LastLine        string     `json:"last_line,omitempty"`
+	Error           string     `json:"error,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	// LogOffset is how much of the console log has been read
+	LogOffset int64 `json:"-"`
+}
+
+// Normalize trims fields and fills in defaults
+func (j *Job) Normalize() {
+	j.Name = strings.TrimSpace(j.Name)
+	j.Command = strings.TrimSpace(j.Command)
+	j.PauseCommand = strings.TrimSpace(j.PauseCommand)
+	j.ResumeCommand = strings.TrimSpace(j.ResumeCommand)
+	if j.ResumeCommand == "" {
+		j.ResumeCommand = j.Command
+	}
+	if strings.TrimSpace(j.ProgressPattern) == "" {
+		j.ProgressPattern = DefaultProgressPattern
+	}
+	if j.Name == "" {
+		j.Name = j.Command
+	}
+	if j.Status == "" {
+		j.Status = StatusWaiting
+	}
+}
+
+// Validate checks the command and patterns
+func (j *Job) Validate() error {
+	if j.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	if strings.ContainsAny(j.Command+j.PauseCommand+j.ResumeCommand, "\r\n") {
+		return fmt.Errorf("commands must be a single line")
+	}
+	progress, err := regexp.Compile(j.ProgressPattern)
+	if err != nil {
+		return fmt.Errorf("invalid progress_pattern: %w", err)
+	}
+	if groups := progress.NumSubexp(); groups != 1 && groups != 2 {
+		return fmt.Errorf("progress_pattern must have one capture group (percent) or two (done and total)")
+	}
+	for field, pattern := range map[string]string{"done_pattern": j.DonePattern, "fail_pattern": j.FailPattern} {
+		if _, err := regexp.Compile(pattern); pattern != "" && err != nil {
+			return fmt.Errorf("invalid %s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// Finished reports whether the job has ended for good
+func (j *Job) Finished() bool {
+	return j.Status == StatusComplete || j.Status == StatusFailed || j.Status == StatusCancelled
+}
+
+// matcher applies a job's patterns to console lines
+type matcher struct {
+	progress *regexp.Regexp
+	done     *regexp.Regexp
+	fail     *regexp.Regexp
+}
+
+func newMatcher(job *Job) (*matcher, error) {
+	if err := job.Validate(); err != nil {
+		return nil, err
+	}
+	m := &matcher{progress: regexp.MustCompile(job.ProgressPattern)}
+	if job.DonePattern != "" {
+		m.done = regexp.MustCompile(job.DonePattern)
+	}
+	if job.FailPattern != "" {
+		m.fail = regexp.MustCompile(job.FailPattern)
+	}
+	return m, nil
+}
+
+// apply updates the job from one console line and reports whether the line
+// was about the job
+func (m *matcher) apply(job *Job, line string) bool {
+	switch {
+	case m.fail != nil && m.fail.MatchString(line):
+		job.Status = StatusFailed
+		job.Error = line
+	case m.done != nil && m.done.MatchString(line):
+		job.Status = StatusComplete
+		job.Progress = 100
+	default:
+		match := m.progress.FindStringSubmatch(line)
+		if match == nil {
+			return false
+		}
+		if progress, ok := parseProgress(match[1:]); ok {
+			job.Progress = progress
+		}
+		// Without a done pattern, reaching 100% completes the job
+		if m.done == nil && job.Progress >= 100 {
+			job.Status = StatusComplete
+		}
+	}
+	job.LastLine = line
+	return true
+}
+
+// parseProgress turns a percentage, or done and total, into a percentage
+func parseProgress(groups []string) (float64, bool) {
+	values := make([]float64, len(groups))
+	for i, group := range groups {
+		value, err := strconv.ParseFloat(strings.ReplaceAll(group, ",", ""), 64)
+		if err != nil {
+			return 0, false
+		}
+		values[i] = value
+	}
+	progress := values[0]
+	if len(values) == 2 {
+		if values[1] <= 0 {
+			return 0, false
+		}
+		progress = values[0] / values[1] * 100
+	}
+	return min(max(progress, 0), 100), true
+}
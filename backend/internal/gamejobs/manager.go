@@ -0,0 +1,411 @@
+package gamejobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("gamejobs")
+
+// TaskName is the name of the server tasks a job's runs are recorded as
+const TaskName = "game-job"
+
+// maxConsoleRead caps how much console output is read per job and check
+const maxConsoleRead = 256 * 1024
+
+// ErrNotActive is returned when pausing or cancelling a job that has ended,
+// or resuming one that is not paused
+var ErrNotActive = errors.New("game job is not in a state that allows this")
+
+// ServerState is what the manager knows about a job's server
+type ServerState int
+
+const (
+	// ServerGone means the server has been deleted
+	ServerGone ServerState = iota
+	// ServerUnknown means the server's host cannot be reached
+	ServerUnknown
+	ServerStopped
+	ServerRunning
+)
+
+// ServerControl runs jobs on servers for the manager
+type ServerControl interface {
+	// ServerState reports whether a server's process is running
+	ServerState(serverID string) ServerState
+	// SendCommand sends a command to a server's console
+	SendCommand(ctx context.Context, serverID, command string) error
+	// ReadConsole returns up to limit bytes of a server's console log from
+	// offset on, and the log's size
+	ReadConsole(ctx context.Context, serverID string, offset, limit int64) (string, int64, error)
+	// StartTask records a task on a server and returns its ID
+	StartTask(ctx context.Context, serverID, name string) string
+	// TaskOutput adds a line to a task's output
+	TaskOutput(serverID, taskID, name, line string)
+	// FinishTask marks a task as done, or failed when err is set
+	FinishTask(serverID, taskID string, err error)
+}
+
+// Manager launches game jobs when their server runs and follows them through
+// the console. A job whose server stops waits and is resumed with its
+// ResumeCommand once the server is running again; its state is kept in the
+// database, so this also holds across restarts of the manager.
+type Manager struct {
+	store    *Store
+	control  ServerControl
+	interval time.Duration
+
+	mu sync.Mutex
+	// runs holds the task recording each job's current run on this instance
+	runs map[string]run
+	wake chan struct{}
+}
+
+type run struct {
+	serverID string
+	taskID   string
+}
+
+// NewManager creates a game job manager
+func NewManager(store *Store, control ServerControl) *Manager {
+	return &Manager{
+		store:    store,
+		control:  control,
+		interval: 10 * time.Second,
+		runs:     make(map[string]run),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start checks the active jobs every interval until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	go func() {
+		defer ticker.Stop()
+		m.reconcile(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Info("Stopping game job manager")
+				return
+			case <-ticker.C:
+			case <-m.wake:
+			}
+			m.reconcile(ctx)
+		}
+	}()
+}
+
+// Refresh checks the jobs again soon, after one was created or resumed
+func (m *Manager) Refresh() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get returns one job
+func (m *Manager) Get(ctx context.Context, id string) (*Job, error) {
+	return m.store.Get(ctx, id)
+}
+
+// List returns a server's jobs, newest first
+func (m *Manager) List(ctx context.Context, serverID string) ([]*Job, error) {
+	return m.store.List(ctx, serverID)
+}
+
+// Create saves a new job. It is launched once its server runs.
+func (m *Manager) Create(ctx context.Context, job *Job) error {
+	job.Status = StatusWaiting
+	job.Normalize()
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	if err := m.store.Save(ctx, job); err != nil {
+		return err
+	}
+	m.Refresh()
+	return nil
+}
+
+// Pause stops a job with its PauseCommand until it is resumed. A job that is
+// waiting for its server needs no command.
+func (m *Manager) Pause(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != StatusRunning && job.Status != StatusWaiting {
+		return nil, ErrNotActive
+	}
+	if job.Status == StatusRunning {
+		if job.PauseCommand == "" {
+			return nil, fmt.Errorf("job has no pause_command")
+		}
+		if err := m.control.SendCommand(ctx, job.ServerID, job.PauseCommand); err != nil {
+			return nil, fmt.Errorf("failed to send pause command: %w", err)
+		}
+		m.endRun(job, "Paused: "+job.PauseCommand, nil)
+	}
+	job.Status = StatusPaused
+	return job, m.store.Save(ctx, job)
+}
+
+// Resume continues a paused job with its ResumeCommand as soon as its server runs
+func (m *Manager) Resume(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != StatusPaused {
+		return nil, ErrNotActive
+	}
+	job.Status = StatusWaiting
+	if err := m.store.Save(ctx, job); err != nil {
+		return nil, err
+	}
+	m.Refresh()
+	return job, nil
+}
+
+// Cancel ends a job for good, sending its PauseCommand if it is running
+func (m *Manager) Cancel(ctx context.Context, id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return nil, ErrNotActive
+	}
+	if job.Status == StatusRunning && job.PauseCommand != "" {
+		if err := m.control.SendCommand(ctx, job.ServerID, job.PauseCommand); err != nil {
+			logger.WarnContext(ctx, "Failed to send pause command to cancelled job", "job_id", job.ID, "error", err)
+		}
+	}
+	job.Status = StatusCancelled
+	m.finish(job)
+	return job, m.store.Save(ctx, job)
+}
+
+// ServerStopped moves a server's running jobs back to waiting, so they are
+// resumed when it runs again
+func (m *Manager) ServerStopped(ctx context.Context, serverID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs, err := m.store.List(ctx, serverID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to list game jobs", "server_id", serverID, "error", err)
+		return
+	}
+	for _, job := range jobs {
+		if job.Status == StatusRunning {
+			m.interrupt(ctx, job)
+		}
+	}
+}
+
+func (m *Manager) reconcile(ctx context.Context) {
+	jobs, err := m.store.ListActive(ctx)
+	if err != nil {
+		logger.Error("Failed to list game jobs", "error", err)
+		return
+	}
+	m.closeStaleRuns(jobs)
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		m.check(ctx, job.ID)
+	}
+}
+
+// closeStaleRuns ends the runs of jobs another instance paused or cancelled
+func (m *Manager) closeStaleRuns(active []*Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running := make(map[string]bool, len(active))
+	for _, job := range active {
+		running[job.ID] = job.Status == StatusRunning
+	}
+	for id, r := range m.runs {
+		if !running[id] {
+			delete(m.runs, id)
+			m.control.FinishTask(r.serverID, r.taskID, nil)
+		}
+	}
+}
+
+// check launches or follows one job
+func (m *Manager) check(ctx context.Context, id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Reloaded under the lock, in case it was paused or cancelled meanwhile
+	job, err := m.store.Get(ctx, id)
+	if err != nil {
+		logger.Error("Failed to load game job", "job_id", id, "error", err)
+		return
+	}
+
+	// While the host cannot be reached the job is left as it is
+	state := m.control.ServerState(job.ServerID)
+	switch {
+	case state == ServerGone:
+		job.Status = StatusFailed
+		job.Error = "server no longer exists"
+		m.finish(job)
+	case job.Status == StatusWaiting && state == ServerRunning:
+		if !m.launch(ctx, job) {
+			return
+		}
+	case job.Status == StatusRunning && state == ServerStopped:
+		m.interrupt(ctx, job)
+		return
+	case job.Status == StatusRunning && state == ServerRunning:
+		if !m.follow(ctx, job) {
+			return
+		}
+	default:
+		return
+	}
+	if err := m.store.Save(ctx, job); err != nil {
+		logger.Error("Failed to save game job", "job_id", job.ID, "error", err)
+	}
+}
+
+// launch sends a waiting job's command, or its resume command when it ran before
+func (m *Manager) launch(ctx context.Context, job *Job) bool {
+	// Only output after the command is about the job
+	_, size, err := m.control.ReadConsole(ctx, job.ServerID, 0, 0)
+	if err != nil {
+		logger.Warn("Failed to read console log", "job_id", job.ID, "server_id", job.ServerID, "error", err)
+		return false
+	}
+	command := job.Command
+	if job.StartedAt != nil {
+		command = job.ResumeCommand
+	}
+	if err := m.control.SendCommand(ctx, job.ServerID, command); err != nil {
+		logger.Warn("Failed to send game job command", "job_id", job.ID, "server_id", job.ServerID, "error", err)
+		return false
+	}
+
+	now := time.Now().UTC()
+	if job.StartedAt == nil {
+		job.StartedAt = &now
+	}
+	job.Status = StatusRunning
+	job.Error = ""
+	job.LogOffset = size
+	m.startRun(ctx, job, "Sent: "+command)
+	logger.Info("Game job launched", "job_id", job.ID, "server_id", job.ServerID, "command", command)
+	return true
+}
+
+// follow reads a running job's new console output and reports whether the
+// job changed
+func (m *Manager) follow(ctx context.Context, job *Job) bool {
+	if _, ok := m.runs[job.ID]; !ok {
+		m.startRun(ctx, job, "Following job again after a manager restart")
+	}
+
+	data, size, err := m.control.ReadConsole(ctx, job.ServerID, job.LogOffset, maxConsoleRead)
+	if err != nil {
+		logger.Warn("Failed to read console log", "job_id", job.ID, "server_id", job.ServerID, "error", err)
+		return false
+	}
+	if size < job.LogOffset {
+		// The log was truncated or replaced; start over at its beginning
+		job.LogOffset = 0
+		return true
+	}
+
+	// A trailing partial line is read again on the next check, unless it
+	// alone fills a whole read
+	consumed := strings.LastIndexByte(data, '\n') + 1
+	if consumed == 0 && len(data) == maxConsoleRead {
+		consumed = len(data)
+	}
+	if consumed == 0 {
+		return false
+	}
+	job.LogOffset += int64(consumed)
+
+	matcher, err := newMatcher(job)
+	if err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+		m.finish(job)
+		return true
+	}
+	taskID := m.runs[job.ID].taskID
+	for _, line := range strings.Split(strings.TrimSuffix(data[:consumed], "\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !matcher.apply(job, line) {
+			continue
+		}
+		m.control.TaskOutput(job.ServerID, taskID, TaskName, line)
+		if job.Finished() {
+			m.finish(job)
+			break
+		}
+	}
+	return true
+}
+
+// interrupt moves a running job back to waiting after its server stopped
+func (m *Manager) interrupt(ctx context.Context, job *Job) {
+	job.Status = StatusWaiting
+	m.endRun(job, "", errors.New("server stopped; the job resumes when it runs again"))
+	if err := m.store.Save(ctx, job); err != nil {
+		logger.Error("Failed to save game job", "job_id", job.ID, "error", err)
+	}
+	logger.Info("Game job waiting for its server", "job_id", job.ID, "server_id", job.ServerID)
+}
+
+// finish records the end of a job and of its current run
+func (m *Manager) finish(job *Job) {
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	var err error
+	switch job.Status {
+	case StatusFailed:
+		err = errors.New(job.Error)
+	case StatusCancelled:
+		err = errors.New("cancelled")
+	}
+	m.endRun(job, "", err)
+	logger.Info("Game job finished", "job_id", job.ID, "server_id", job.ServerID, "status", job.Status)
+}
+
+// startRun records a task for the time the job runs until it pauses, its
+// server stops or it ends
+func (m *Manager) startRun(ctx context.Context, job *Job, line string) {
+	taskID := m.control.StartTask(ctx, job.ServerID, TaskName)
+	m.runs[job.ID] = run{serverID: job.ServerID, taskID: taskID}
+	m.control.TaskOutput(job.ServerID, taskID, TaskName, fmt.Sprintf("%s (%s)", job.Name, job.ID))
+	m.control.TaskOutput(job.ServerID, taskID, TaskName, line)
+}
+
+func (m *Manager) endRun(job *Job, line string, err error) {
+	r, ok := m.runs[job.ID]
+	if !ok {
+		return
+	}
+	delete(m.runs, job.ID)
+	if line != "" {
+		m.control.TaskOutput(r.serverID, r.taskID, TaskName, line)
+	}
+	m.control.FinishTask(r.serverID, r.taskID, err)
+}
@@ -0,0 +1,146 @@
+package gamejobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned for unknown job IDs
+var ErrNotFound = errors.New("game job not found")
+
+// Store persists game jobs. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new game job store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const jobColumns = `id, server_id, name, command, pause_command, resume_command, progress_pattern, done_pattern, fail_pattern, status, progress, last_line, error, log_offset, created_at, started_at, updated_at, finished_at`
+
+// Get returns one job
+func (s *Store) Get(ctx context.Context, id string) (*Job, error) {
+	job, err := scanJob(s.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM game_jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load game job: %w", err)
+	}
+	return job, nil
+}
+
+// List returns a server's jobs, newest first
+func (s *Store) List(ctx context.Context, serverID string) ([]*Job, error) {
+	return s.list(ctx, `SELECT `+jobColumns+` FROM game_jobs WHERE server_id = ? ORDER BY created_at DESC, id`, serverID)
+}
+
+// ListActive returns the jobs that are waiting for their server or running
+func (s *Store) ListActive(ctx context.Context) ([]*Job, error) {
+	return s.list(ctx, `SELECT `+jobColumns+` FROM game_jobs WHERE status IN (?, ?) ORDER BY created_at, id`, StatusWaiting, StatusRunning)
+}
+
+func (s *Store) list(ctx context.Context, query string, args ...any) ([]*Job, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list game jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := make([]*Job, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan game job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Save creates or updates a job
+func (s *Store) Save(ctx context.Context, job *Job) error {
+	now := time.Now().UTC()
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = now
+	}
+	job.UpdatedAt = now
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO game_jobs (`+jobColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			command = excluded.command,
+			pause_command = excluded.pause_command,
+			resume_command = excluded.resume_command,
+			progress_pattern = excluded.progress_pattern,
+			done_pattern = excluded.done_pattern,
+			fail_pattern = excluded.fail_pattern,
+			status = excluded.status,
+			progress = excluded.progress,
+			last_line = excluded.last_line,
+			error = excluded.error,
+			log_offset = excluded.log_offset,
+			started_at = excluded.started_at,
+			updated_at = excluded.updated_at,
+			finished_at = excluded.finished_at
+	`,
+		job.ID, job.ServerID, job.Name, job.Command, nullString(job.PauseCommand), nullString(job.ResumeCommand),
+		nullString(job.ProgressPattern), nullString(job.DonePattern), nullString(job.FailPattern), job.Status, job.Progress,
+		nullString(job.LastLine), nullString(job.Error), job.LogOffset, job.CreatedAt, nullTime(job.StartedAt), job.UpdatedAt,
+		nullTime(job.FinishedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save game job: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var (
+		job                                 Job
+		pause, resume, progress, done, fail sql.NullString
+		lastLine, jobError                  sql.NullString
+		startedAt, finishedAt               sql.NullTime
+	)
+	if err := row.Scan(&job.ID, &job.ServerID, &job.Name, &job.Command, &pause, &resume, &progress, &done, &fail,
+		&job.Status, &job.Progress, &lastLine, &jobError, &job.LogOffset, &job.CreatedAt, &startedAt, &job.UpdatedAt,
+		&finishedAt); err != nil {
+		return nil, err
+	}
+	job.PauseCommand = pause.String
+	job.ResumeCommand = resume.String
+	job.ProgressPattern = progress.String
+	job.DonePattern = done.String
+	job.FailPattern = fail.String
+	job.LastLine = lastLine.String
+	job.Error = jobError.String
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
@@ -0,0 +1,70 @@
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Files read from the configured path of the repository
+const (
+	ServersFile   = "servers.yaml"
+	SchedulesFile = "schedules.yaml"
+)
+
+// Document is the desired state found in one commit. A file missing from
+// the commit leaves its section nil, so that kind of object is not managed
+// from the repository.
+type Document struct {
+	Commit  string
+	Servers []config.ServerDefinition
+	// Schedules holds the schedules as a JSON list in the schedules API format
+	Schedules json.RawMessage
+}
+
+// Load reads the desired state from commit
+func (r *Repo) Load(ctx context.Context, commit string) (*Document, error) {
+	doc := &Document{Commit: commit}
+
+	data, found, err := r.ReadFile(ctx, commit, ServersFile)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		servers, _, err := config.ParseServersYAML(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ServersFile, err)
+		}
+		doc.Servers = servers
+	}
+
+	data, found, err = r.ReadFile(ctx, commit, SchedulesFile)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		schedules, err := parseSchedules(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", SchedulesFile, err)
+		}
+		doc.Schedules = schedules
+	}
+	return doc, nil
+}
+
+// parseSchedules converts the schedules list of a schedules.yaml file to JSON
+func parseSchedules(data []byte) (json.RawMessage, error) {
+	var file struct {
+		Schedules []map[string]interface{} `yaml:"schedules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules file: %w", err)
+	}
+	if file.Schedules == nil {
+		file.Schedules = []map[string]interface{}{}
+	}
+	return json.Marshal(file.Schedules)
+}
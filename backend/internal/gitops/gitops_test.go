@@ -0,0 +1,122 @@
+package gitops
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// commitFiles writes files into the source repository and commits them,
+// creating the repository on first use
+func commitFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		run("init", "--quiet", "-b", "main")
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("add", "-A")
+	run("commit", "--quiet", "-m", "update")
+}
+
+const serversYAML = `schema_version: 1
+servers:
+  - id: web-1
+    name: Web 1
+    connection:
+      host: 10.0.0.5
+      username: hytale
+      auth_method: key
+      key_path: /keys/web-1
+    server:
+      working_directory: /opt/hytale
+      executable: java
+      process_manager: screen
+`
+
+func TestFetchAndLoad(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	source := t.TempDir()
+	commitFiles(t, source, map[string]string{
+		"manager/servers.yaml": serversYAML,
+		"manager/schedules.yaml": `schedules:
+  - id: nightly
+    server_id: web-1
+    type: command
+    cron: "0 4 * * *"
+    params:
+      command: save
+`,
+	})
+
+	repo := NewRepo(filepath.Join(t.TempDir(), "gitops"), config.GitOpsConfig{Repository: source, Path: "manager"})
+	ctx := context.Background()
+	first, err := repo.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+
+	doc, err := repo.Load(ctx, first)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].ID != "web-1" || doc.Servers[0].Connection.KeyPath != "/keys/web-1" {
+		t.Fatalf("unexpected servers %+v", doc.Servers)
+	}
+	var schedules []map[string]interface{}
+	if err := json.Unmarshal(doc.Schedules, &schedules); err != nil {
+		t.Fatalf("schedules are not JSON: %v", err)
+	}
+	if len(schedules) != 1 || schedules[0]["id"] != "nightly" || schedules[0]["params"].(map[string]interface{})["command"] != "save" {
+		t.Fatalf("unexpected schedules %v", schedules)
+	}
+
+	// A later commit without schedules leaves them unmanaged, and the
+	// earlier commit stays readable
+	if err := os.Remove(filepath.Join(source, "manager", "schedules.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, source, nil)
+	second, err := repo.Fetch(ctx)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if second == first {
+		t.Fatal("expected fetch to pick up the new commit")
+	}
+	doc, err = repo.Load(ctx, second)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if doc.Schedules != nil || len(doc.Servers) != 1 {
+		t.Fatalf("expected only servers in the new commit, got %+v", doc)
+	}
+	if doc, err = repo.Load(ctx, first); err != nil || doc.Schedules == nil {
+		t.Fatalf("expected the first commit to still have schedules, got %v", err)
+	}
+
+	if _, err := repo.Load(ctx, "0000000000000000000000000000000000000000"); err == nil {
+		t.Fatal("expected an unknown commit to fail")
+	}
+}
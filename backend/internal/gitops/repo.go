@@ -0,0 +1,106 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// gitTimeout bounds a single git command, including fetches over the network
+const gitTimeout = 2 * time.Minute
+
+// Repo is a local copy of the repository holding the desired server
+// definitions. It is kept bare: files are read from commits, so the
+// definitions of earlier syncs stay readable.
+type Repo struct {
+	dir      string
+	settings config.GitOpsConfig
+}
+
+// NewRepo keeps the copy of the configured repository in dir
+func NewRepo(dir string, settings config.GitOpsConfig) *Repo {
+	if settings.Branch == "" {
+		settings.Branch = "main"
+	}
+	if settings.Path == "" {
+		settings.Path = "."
+	}
+	return &Repo{dir: dir, settings: settings}
+}
+
+// Settings returns the repository settings in use
+func (r *Repo) Settings() config.GitOpsConfig {
+	return r.settings
+}
+
+// Fetch brings the branch up to date with the remote and returns the commit
+// it points to
+func (r *Repo) Fetch(ctx context.Context) (string, error) {
+	if _, err := os.Stat(filepath.Join(r.dir, "HEAD")); os.IsNotExist(err) {
+		if err := os.MkdirAll(r.dir, 0700); err != nil {
+			return "", err
+		}
+		if _, err := r.git(ctx, "init", "--bare", "--quiet"); err != nil {
+			return "", err
+		}
+	}
+
+	ref := "refs/heads/" + r.settings.Branch
+	if _, err := r.git(ctx, "fetch", "--quiet", "--force", r.settings.Repository, "+"+ref+":"+ref); err != nil {
+		return "", err
+	}
+	commit, err := r.git(ctx, "rev-parse", ref)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(commit), nil
+}
+
+// ReadFile returns a file under the configured path as of commit. It reports
+// false if the file does not exist in that commit.
+func (r *Repo) ReadFile(ctx context.Context, commit, name string) ([]byte, bool, error) {
+	object := commit + ":" + path.Clean(path.Join(r.settings.Path, name))
+	if _, err := r.git(ctx, "cat-file", "-e", object); err != nil {
+		if _, commitErr := r.git(ctx, "cat-file", "-e", commit+"^{commit}"); commitErr != nil {
+			return nil, false, commitErr
+		}
+		return nil, false, nil
+	}
+	content, err := r.git(ctx, "cat-file", "blob", object)
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(content), true, nil
+}
+
+// git runs a git command against the local copy. A configured deploy key is
+// used for fetches over SSH; git never prompts for credentials.
+func (r *Repo) git(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if r.settings.DeployKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i %q -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new", r.settings.DeployKey))
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
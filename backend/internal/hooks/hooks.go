@@ -0,0 +1,200 @@
+// Package hooks runs the scripts and HTTP callouts configured under hooks at
+// points in a server's lifecycle, so behaviour can be extended without
+// changing the manager.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("hooks")
+
+// Points at which hooks run
+const (
+	PreStart   = "pre_start"
+	PostStart  = "post_start"
+	PreDeploy  = "pre_deploy"
+	PostDeploy = "post_deploy"
+	PostBackup = "post_backup"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	maxOutput      = 4000
+)
+
+// Event is what a hook runs for. HTTP hooks receive it as the JSON request
+// body and script hooks on stdin.
+type Event struct {
+	Name     string                 `json:"event"`
+	ServerID string                 `json:"server_id"`
+	Time     time.Time              `json:"time"`
+	Success  *bool                  `json:"success,omitempty"` // set for post_ events
+	Error    string                 `json:"error,omitempty"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+}
+
+// NewEvent describes a hook point reached for a server
+func NewEvent(name, serverID string, details map[string]interface{}) Event {
+	return Event{Name: name, ServerID: serverID, Time: time.Now().UTC(), Details: details}
+}
+
+// WithResult records the outcome of the operation a post_ event follows
+func (e Event) WithResult(err error) Event {
+	success := err == nil
+	e.Success = &success
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// Recorder writes hook runs to the activity log
+type Recorder interface {
+	LogHookRun(serverID, hook, event string, metadata map[string]interface{}, success bool, errorMsg string) error
+}
+
+// Runner runs the configured hooks. A nil Runner runs none.
+type Runner struct {
+	recorder Recorder
+
+	mu        sync.RWMutex
+	hooks     []config.HookConfig
+	listeners []func(Event)
+}
+
+// NewRunner returns a runner for hooks, recording each run with recorder
+// when it is not nil
+func NewRunner(hooks []config.HookConfig, recorder Recorder) *Runner {
+	return &Runner{hooks: hooks, recorder: recorder}
+}
+
+// SetHooks replaces the hooks, e.g. after a configuration reload
+func (r *Runner) SetHooks(hooks []config.HookConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = hooks
+}
+
+// OnEvent registers fn to be called with every event the runner is given,
+// whether or not a hook matches it
+func (r *Runner) OnEvent(fn func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Run runs the hooks registered for the event and its server one after
+// another, in configuration order. When a hook with on_failure: abort fails
+// the remaining hooks are skipped and its error is returned, so a pre_ event
+// can stop the operation it precedes; other failures are only logged.
+func (r *Runner) Run(ctx context.Context, event Event) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	listeners := r.listeners
+	r.mu.RUnlock()
+	for _, listener := range listeners {
+		listener(event)
+	}
+
+	for _, hook := range r.matching(event) {
+		err := r.runOne(ctx, hook, event)
+		if err != nil && hook.OnFailure == "abort" {
+			return fmt.Errorf("%s hook %s failed: %w", event.Name, hook.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) matching(event Event) []config.HookConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []config.HookConfig
+	for _, hook := range r.hooks {
+		if contains(hook.Events, event.Name) && (len(hook.Servers) == 0 || contains(hook.Servers, event.ServerID)) {
+			matched = append(matched, hook)
+		}
+	}
+	return matched
+}
+
+func (r *Runner) runOne(ctx context.Context, hook config.HookConfig, event Event) error {
+	timeout := defaultTimeout
+	if d, err := time.ParseDuration(hook.Timeout); err == nil && d > 0 {
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	var (
+		output string
+		err    error
+	)
+	switch hook.Type {
+	case "script":
+		output, err = runScript(ctx, hook, event)
+	case "http":
+		output, err = callURL(ctx, hook, event)
+	default:
+		err = fmt.Errorf("unknown hook type %q", hook.Type)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	duration := time.Since(started)
+
+	if err != nil {
+		logger.Warn("Hook failed", "hook", hook.Name, "event", event.Name, "server_id", event.ServerID, "duration", duration.String(), "error", err)
+	} else {
+		logger.Info("Hook ran", "hook", hook.Name, "event", event.Name, "server_id", event.ServerID, "duration", duration.String())
+	}
+
+	if r.recorder != nil {
+		metadata := map[string]interface{}{
+			"type":        hook.Type,
+			"duration_ms": duration.Milliseconds(),
+			"on_failure":  onFailure(hook),
+		}
+		if output = strings.TrimSpace(output); output != "" {
+			if len(output) > maxOutput {
+				output = output[:maxOutput] + "... (truncated)"
+			}
+			metadata["output"] = output
+		}
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if logErr := r.recorder.LogHookRun(event.ServerID, hook.Name, event.Name, metadata, err == nil, errMsg); logErr != nil {
+			logger.Warn("Failed to record hook run", "hook", hook.Name, "error", logErr)
+		}
+	}
+	return err
+}
+
+func onFailure(hook config.HookConfig) string {
+	if hook.OnFailure == "" {
+		return "continue"
+	}
+	return hook.OnFailure
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
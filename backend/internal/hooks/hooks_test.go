@@ -0,0 +1,113 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+type recordedRun struct {
+	serverID, hook, event string
+	success               bool
+}
+
+type fakeRecorder struct {
+	mu   sync.Mutex
+	runs []recordedRun
+}
+
+func (f *fakeRecorder) LogHookRun(serverID, hook, event string, metadata map[string]interface{}, success bool, errorMsg string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runs = append(f.runs, recordedRun{serverID: serverID, hook: hook, event: event, success: success})
+	return nil
+}
+
+func TestHTTPHookReceivesEvent(t *testing.T) {
+	var received Event
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer srv.Close()
+
+	recorder := &fakeRecorder{}
+	runner := NewRunner([]config.HookConfig{
+		{Name: "notify", Events: []string{PostBackup}, Type: "http", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Name: "other-server", Events: []string{PostBackup}, Type: "http", URL: srv.URL, Servers: []string{"beta"}},
+	}, recorder)
+
+	event := NewEvent(PostBackup, "alpha", map[string]interface{}{"backup_id": "backup-1"}).WithResult(nil)
+	if err := runner.Run(context.Background(), event); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if received.Name != PostBackup || received.ServerID != "alpha" || received.Success == nil || !*received.Success || received.Details["backup_id"] != "backup-1" {
+		t.Fatalf("unexpected event %+v", received)
+	}
+	if auth != "Bearer token" {
+		t.Fatalf("expected the configured header, got %q", auth)
+	}
+	if len(recorder.runs) != 1 || recorder.runs[0].hook != "notify" || !recorder.runs[0].success {
+		t.Fatalf("expected only the matching hook to run, got %+v", recorder.runs)
+	}
+}
+
+func TestFailurePolicies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	recorder := &fakeRecorder{}
+	runner := NewRunner([]config.HookConfig{
+		{Name: "best-effort", Events: []string{PreStart}, Type: "http", URL: srv.URL},
+		{Name: "gate", Events: []string{PreStart}, Type: "http", URL: srv.URL, OnFailure: "abort"},
+		{Name: "never", Events: []string{PreStart}, Type: "http", URL: srv.URL},
+	}, recorder)
+
+	err := runner.Run(context.Background(), NewEvent(PreStart, "alpha", nil))
+	if err == nil || !strings.Contains(err.Error(), "gate") {
+		t.Fatalf("expected the abort hook to stop the start, got %v", err)
+	}
+	if len(recorder.runs) != 2 || recorder.runs[0].success || recorder.runs[1].success {
+		t.Fatalf("expected two failed runs and the last hook skipped, got %+v", recorder.runs)
+	}
+}
+
+func TestScriptHookGetsEnvironmentAndTimesOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("script hooks are tested with sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	runner := NewRunner([]config.HookConfig{
+		{Name: "record", Events: []string{PostStart}, Type: "script", Command: `echo "$HSM_HOOK_EVENT $HSM_SERVER_ID $HSM_HOOK_SUCCESS" > ` + out},
+		{Name: "slow", Events: []string{PostStart}, Type: "script", Command: "sleep 5", Timeout: "50ms", OnFailure: "abort"},
+	}, nil)
+
+	err := runner.Run(context.Background(), NewEvent(PostStart, "alpha", nil).WithResult(errors.New("boom")))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected the slow hook to time out, got %v", err)
+	}
+	data, readErr := os.ReadFile(out)
+	if readErr != nil || strings.TrimSpace(string(data)) != "post_start alpha false" {
+		t.Fatalf("unexpected script output %q (%v)", data, readErr)
+	}
+}
+
+func TestNilRunnerRunsNothing(t *testing.T) {
+	var runner *Runner
+	if err := runner.Run(context.Background(), NewEvent(PreDeploy, "alpha", nil)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// runScript runs a script hook on the manager host with the event on stdin
+// and its main fields in HSM_ environment variables
+func runScript(ctx context.Context, hook config.HookConfig, event Event) (string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	}
+	cmd.Env = append(os.Environ(),
+		"HSM_HOOK_NAME="+hook.Name,
+		"HSM_HOOK_EVENT="+event.Name,
+		"HSM_SERVER_ID="+event.ServerID,
+	)
+	if event.Success != nil {
+		cmd.Env = append(cmd.Env, "HSM_HOOK_SUCCESS="+strconv.FormatBool(*event.Success))
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	// Children left running after a timeout must not keep the output open
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("script failed: %w", err)
+	}
+	return string(output), nil
+}
+
+// callURL sends the event to an HTTP hook. Responses other than 2xx fail it.
+func callURL(ctx context.Context, hook config.HookConfig, event Event) (string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(hook.Method))
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("invalid hook request: %w", err)
+	}
+	for name, value := range hook.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hytale-server-manager-hooks")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("hook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	output := fmt.Sprintf("%s\n%s", resp.Status, data)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return output, fmt.Errorf("hook returned %s", resp.Status)
+	}
+	return output, nil
+}
@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// csvColumns maps the header names an SSH inventory may use to the field
+// they set. Header names are matched case-insensitively.
+var csvColumns = map[string]func(*config.ServerDefinition, string) error{
+	"id":          func(s *config.ServerDefinition, v string) error { s.ID = v; return nil },
+	"name":        func(s *config.ServerDefinition, v string) error { s.Name = v; return nil },
+	"description": func(s *config.ServerDefinition, v string) error { s.Description = v; return nil },
+	"group":       func(s *config.ServerDefinition, v string) error { s.Group = v; return nil },
+	"host":        func(s *config.ServerDefinition, v string) error { s.Connection.Host = v; return nil },
+	"port": func(s *config.ServerDefinition, v string) error {
+		port, err := strconv.Atoi(v)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %q", v)
+		}
+		s.Connection.Port = port
+		return nil
+	},
+	"username":    func(s *config.ServerDefinition, v string) error { s.Connection.Username = v; return nil },
+	"auth_method": func(s *config.ServerDefinition, v string) error { s.Connection.AuthMethod = v; return nil },
+	"key_path": func(s *config.ServerDefinition, v string) error {
+		s.Connection.KeyPath = v
+		if s.Connection.AuthMethod == "" {
+			s.Connection.AuthMethod = "key"
+		}
+		return nil
+	},
+	"install_dir": func(s *config.ServerDefinition, v string) error {
+		s.Server.WorkingDirectory = v
+		s.Dependencies.InstallDir = v
+		return nil
+	},
+	"executable":      func(s *config.ServerDefinition, v string) error { s.Server.Executable = v; return nil },
+	"java_args":       func(s *config.ServerDefinition, v string) error { s.Server.JavaArgs = v; return nil },
+	"process_manager": func(s *config.ServerDefinition, v string) error { s.Server.ProcessManager = v; return nil },
+	"service_user":    func(s *config.ServerDefinition, v string) error { s.Dependencies.ServiceUser = v; return nil },
+	"java_xmx":        func(s *config.ServerDefinition, v string) error { s.Runtime.JavaXmx = v; return nil },
+}
+
+// csvAliases are other header names for the columns above
+var csvAliases = map[string]string{
+	"user":              "username",
+	"ssh_user":          "username",
+	"address":           "host",
+	"hostname":          "host",
+	"ssh_port":          "port",
+	"key":               "key_path",
+	"ssh_key":           "key_path",
+	"working_directory": "install_dir",
+	"dir":               "install_dir",
+	"memory":            "java_xmx",
+}
+
+// CSV converts an SSH inventory: a CSV file with a header row and one server
+// per line. Only host is required; empty cells fall back to the defaults and
+// servers without an id are named after their name or host.
+func CSV(data []byte, defaults Defaults) (*Result, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("the inventory is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	result := &Result{}
+	columns := make([]string, len(header))
+	hasHost := false
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if alias, ok := csvAliases[name]; ok {
+			name = alias
+		}
+		if _, ok := csvColumns[name]; !ok {
+			result.warnf("column %q is not recognised and was ignored", header[i])
+			continue
+		}
+		columns[i] = name
+		hasHost = hasHost || name == "host"
+	}
+	if !hasHost {
+		return nil, fmt.Errorf("the inventory has no host column")
+	}
+
+	seen := ids{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		server := newServer(defaults)
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if i >= len(columns) || columns[i] == "" || value == "" {
+				continue
+			}
+			if err := csvColumns[columns[i]](&server, value); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		if server.Connection.Host == "" {
+			return nil, fmt.Errorf("line %d: host is empty", line)
+		}
+
+		if server.ID == "" {
+			name := server.Name
+			if name == "" {
+				name = server.Connection.Host
+			}
+			server.ID = seen.next(name, fmt.Sprintf("server-%d", line))
+		} else {
+			seen[server.ID] = true
+		}
+		if server.Name == "" {
+			server.Name = server.ID
+		}
+		result.Servers = append(result.Servers, server)
+	}
+	return result, nil
+}
@@ -0,0 +1,131 @@
+// Package importer converts server definitions exported from other game
+// server panels into HytaleSM server definitions.
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// Formats that can be converted
+const (
+	FormatPterodactyl = "pterodactyl"
+	FormatCSV         = "csv"
+)
+
+// Defaults fill in what the export does not say, such as how the manager
+// logs in to the hosts
+type Defaults struct {
+	Host             string `json:"host"`
+	Port             int    `json:"port"`
+	Username         string `json:"username"`
+	AuthMethod       string `json:"auth_method"`
+	KeyPath          string `json:"key_path"`
+	ProcessManager   string `json:"process_manager"`
+	WorkingDirectory string `json:"working_directory"`
+	ServiceUser      string `json:"service_user"`
+	Group            string `json:"group"`
+}
+
+// Result holds the converted definitions and what could not be carried over
+type Result struct {
+	Servers  []config.ServerDefinition `json:"servers"`
+	Warnings []string                  `json:"warnings"`
+}
+
+func (r *Result) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Convert reads data in the given format. The definitions are not
+// validated; the caller does that when it stores them.
+func Convert(format string, data []byte, defaults Defaults) (*Result, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatPterodactyl:
+		return Pterodactyl(data, defaults)
+	case FormatCSV:
+		return CSV(data, defaults)
+	}
+	return nil, fmt.Errorf("unknown format %q (use %s or %s)", format, FormatPterodactyl, FormatCSV)
+}
+
+// newServer starts a definition from the defaults
+func newServer(defaults Defaults) config.ServerDefinition {
+	server := config.ServerDefinition{
+		Group: defaults.Group,
+		Connection: config.ConnectionConfig{
+			Host:       defaults.Host,
+			Port:       defaults.Port,
+			Username:   defaults.Username,
+			AuthMethod: defaults.AuthMethod,
+			KeyPath:    defaults.KeyPath,
+		},
+		Server: config.GameServerConfig{
+			WorkingDirectory: defaults.WorkingDirectory,
+			ProcessManager:   defaults.ProcessManager,
+		},
+		Dependencies: config.DependenciesConfig{
+			ServiceUser: defaults.ServiceUser,
+			InstallDir:  defaults.WorkingDirectory,
+		},
+	}
+	if server.Connection.Port == 0 {
+		server.Connection.Port = 22
+	}
+	if server.Connection.AuthMethod == "" && server.Connection.KeyPath != "" {
+		server.Connection.AuthMethod = "key"
+	}
+	if server.Server.ProcessManager == "" {
+		server.Server.ProcessManager = "screen"
+	}
+	return server
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// ids hands out server IDs derived from names, unique within one import
+type ids map[string]bool
+
+func (seen ids) next(name, fallback string) string {
+	id := strings.Trim(nonIDChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if id == "" {
+		id = fallback
+	}
+	candidate := id
+	for n := 2; seen[candidate]; n++ {
+		candidate = fmt.Sprintf("%s-%d", id, n)
+	}
+	seen[candidate] = true
+	return candidate
+}
+
+// applyStartup maps a start command onto the server: for "java ... -jar
+// file" the JVM flags become java_args and the jar the executable, anything
+// else runs as the executable. Arguments after the jar or program are
+// carried over as extra server arguments.
+func applyStartup(server *config.ServerDefinition, command string, result *Result) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+
+	program := fields[0]
+	if program == "java" || strings.HasSuffix(program, "/java") {
+		for i := 1; i < len(fields)-1; i++ {
+			if fields[i] == "-jar" {
+				server.Server.JavaArgs = strings.Join(fields[1:i], " ")
+				server.Server.Executable = fields[i+1]
+				server.Runtime.ExtraServerArgs = strings.Join(fields[i+2:], " ")
+				return
+			}
+		}
+		result.warnf("%s: start command %q has no -jar; set the executable by hand", server.ID, command)
+		return
+	}
+
+	server.Server.Executable = program
+	server.Runtime.ExtraServerArgs = strings.Join(fields[1:], " ")
+}
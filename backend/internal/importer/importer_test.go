@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+const pterodactylServers = `{
+  "object": "list",
+  "data": [
+    {
+      "object": "server",
+      "attributes": {
+        "uuid": "1a7ce997-259b-452e-8b4e-cecc464142ca",
+        "identifier": "1a7ce997",
+        "name": "Survival EU",
+        "description": "Main world",
+        "allocation": 7,
+        "limits": {"memory": 4096},
+        "container": {
+          "startup_command": "java -Xms128M -Xmx{{SERVER_MEMORY}}M -jar {{SERVER_JARFILE}} --port {{SERVER_PORT}}",
+          "environment": {"SERVER_JARFILE": "HytaleServer.jar", "SERVER_PORT": 5520}
+        },
+        "relationships": {
+          "allocations": {"object": "list", "data": [
+            {"object": "allocation", "attributes": {"id": 3, "ip": "10.0.0.3", "port": 5521}},
+            {"object": "allocation", "attributes": {"id": 7, "ip": "10.0.0.7", "port": 5520}}
+          ]},
+          "node": {"object": "node", "attributes": {"name": "eu-1", "fqdn": "", "daemon_base": "/srv/wings"}}
+        }
+      }
+    },
+    {
+      "object": "server",
+      "attributes": {
+        "uuid": "d3aac109-e5a0-4331-b1e4-ee2f1a0a8b4e",
+        "identifier": "d3aac109",
+        "name": "Survival EU",
+        "container": {"startup_command": "./start.sh", "environment": {}},
+        "relationships": {
+          "node": {"object": "node", "attributes": {"name": "eu-2", "fqdn": "eu-2.example.com"}}
+        }
+      }
+    }
+  ]
+}`
+
+func TestPterodactylServers(t *testing.T) {
+	result, err := Convert(FormatPterodactyl, []byte(pterodactylServers), Defaults{Username: "hytale", KeyPath: "/keys/panel"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(result.Servers) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(result.Servers))
+	}
+
+	first := result.Servers[0]
+	if first.ID != "survival-eu" || first.Group != "eu-1" || first.Connection.Host != "10.0.0.7" {
+		t.Fatalf("unexpected identity %q/%q/%q", first.ID, first.Group, first.Connection.Host)
+	}
+	if first.Connection.Username != "hytale" || first.Connection.AuthMethod != "key" || first.Connection.Port != 22 {
+		t.Fatalf("expected the connection defaults, got %+v", first.Connection)
+	}
+	if first.Server.WorkingDirectory != "/srv/wings/1a7ce997-259b-452e-8b4e-cecc464142ca" || first.Dependencies.InstallDir != first.Server.WorkingDirectory {
+		t.Fatalf("unexpected directories %q / %q", first.Server.WorkingDirectory, first.Dependencies.InstallDir)
+	}
+	if first.Server.Executable != "HytaleServer.jar" || first.Server.JavaArgs != "-Xms128M -Xmx4096M" || first.Runtime.ExtraServerArgs != "--port 5520" {
+		t.Fatalf("unexpected start command mapping %+v %+v", first.Server, first.Runtime)
+	}
+	if err := config.ValidateServerDefinition(&first); err != nil {
+		t.Fatalf("expected a valid definition: %v", err)
+	}
+
+	second := result.Servers[1]
+	if second.ID != "survival-eu-2" || second.Connection.Host != "eu-2.example.com" || second.Server.Executable != "./start.sh" {
+		t.Fatalf("unexpected second server %+v", second)
+	}
+	if second.Server.WorkingDirectory != defaultDaemonBase+"/d3aac109-e5a0-4331-b1e4-ee2f1a0a8b4e" {
+		t.Fatalf("expected the default volume path, got %q", second.Server.WorkingDirectory)
+	}
+}
+
+func TestPterodactylEgg(t *testing.T) {
+	egg := `{
+  "meta": {"version": "PTDL_v2"},
+  "name": "Hytale",
+  "startup": "java -Xmx{{SERVER_MEMORY}}M -jar {{SERVER_JARFILE}}",
+  "variables": [{"env_variable": "SERVER_JARFILE", "default_value": "server.jar"}]
+}`
+	result, err := Pterodactyl([]byte(egg), Defaults{Host: "10.0.0.9", WorkingDirectory: "/opt/hytale"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(result.Servers) != 1 {
+		t.Fatalf("expected one template, got %d", len(result.Servers))
+	}
+	server := result.Servers[0]
+	if server.ID != "hytale" || server.Connection.Host != "10.0.0.9" || server.Server.WorkingDirectory != "/opt/hytale" || server.Server.Executable != "server.jar" {
+		t.Fatalf("unexpected template %+v", server)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "SERVER_MEMORY") {
+		t.Fatalf("expected a warning about SERVER_MEMORY, got %v", result.Warnings)
+	}
+}
+
+func TestCSVInventory(t *testing.T) {
+	inventory := "\ufeffName,Address,SSH_Port,User,Key,Install_Dir,Executable,Rack\n" +
+		"# staging boxes\n" +
+		"Lobby,10.0.1.1,2222,games,/keys/lobby,/opt/lobby,HytaleServer.jar,a1\n" +
+		",10.0.1.2,,,,/opt/hytale,HytaleServer.jar,a2\n"
+	result, err := Convert("CSV", []byte(inventory), Defaults{Username: "hytale", ProcessManager: "tmux"})
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if len(result.Servers) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(result.Servers))
+	}
+
+	lobby := result.Servers[0]
+	if lobby.ID != "lobby" || lobby.Connection.Port != 2222 || lobby.Connection.Username != "games" || lobby.Connection.AuthMethod != "key" {
+		t.Fatalf("unexpected lobby %+v", lobby)
+	}
+	if lobby.Dependencies.InstallDir != "/opt/lobby" || lobby.Server.ProcessManager != "tmux" {
+		t.Fatalf("unexpected lobby server settings %+v", lobby.Server)
+	}
+
+	unnamed := result.Servers[1]
+	if unnamed.ID != "10-0-1-2" || unnamed.Name != "10-0-1-2" || unnamed.Connection.Username != "hytale" || unnamed.Connection.Port != 22 {
+		t.Fatalf("unexpected unnamed server %+v", unnamed)
+	}
+
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "Rack") {
+		t.Fatalf("expected a warning about the Rack column, got %v", result.Warnings)
+	}
+
+	if _, err := CSV([]byte("name,host\nweb,\n"), Defaults{}); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected an error for the row without a host, got %v", err)
+	}
+	if _, err := CSV([]byte("name,user\nweb,root\n"), Defaults{}); err == nil {
+		t.Fatal("expected an inventory without a host column to fail")
+	}
+}
@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// defaultDaemonBase is where Wings keeps server volumes unless the node says
+// otherwise
+const defaultDaemonBase = "/var/lib/pterodactyl/volumes"
+
+// pteroServer is a server as returned by the Pterodactyl application API,
+// with the allocations and node relationships included
+type pteroServer struct {
+	UUID        string `json:"uuid"`
+	Identifier  string `json:"identifier"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Allocation  int64  `json:"allocation"`
+	Limits      struct {
+		Memory int64 `json:"memory"`
+	} `json:"limits"`
+	Container struct {
+		StartupCommand string `json:"startup_command"`
+		// Environment values are mostly strings, but numbers and booleans
+		// come through as JSON
+		Environment map[string]interface{} `json:"environment"`
+	} `json:"container"`
+	Relationships struct {
+		Allocations struct {
+			Data []struct {
+				Attributes struct {
+					ID int64  `json:"id"`
+					IP string `json:"ip"`
+				} `json:"attributes"`
+			} `json:"data"`
+		} `json:"allocations"`
+		Node struct {
+			Attributes struct {
+				Name       string `json:"name"`
+				FQDN       string `json:"fqdn"`
+				DaemonBase string `json:"daemon_base"`
+			} `json:"attributes"`
+		} `json:"node"`
+	} `json:"relationships"`
+}
+
+// pteroEgg is an egg as exported from the Pterodactyl admin panel
+type pteroEgg struct {
+	Meta struct {
+		Version string `json:"version"`
+	} `json:"meta"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Startup     string `json:"startup"`
+	Variables   []struct {
+		EnvVariable  string `json:"env_variable"`
+		DefaultValue string `json:"default_value"`
+	} `json:"variables"`
+}
+
+// Pterodactyl converts a Pterodactyl export: a server list from the
+// application API (GET /api/application/servers?include=allocations,node), a
+// single server from it, or an exported egg. An egg becomes one server
+// template that takes its host and directory from the defaults.
+func Pterodactyl(data []byte, defaults Defaults) (*Result, error) {
+	var envelope struct {
+		Object     string          `json:"object"`
+		Attributes json.RawMessage `json:"attributes"`
+		Data       []struct {
+			Attributes json.RawMessage `json:"attributes"`
+		} `json:"data"`
+		Meta struct {
+			Version string `json:"version"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid Pterodactyl export: %w", err)
+	}
+
+	result := &Result{}
+	seen := ids{}
+	switch {
+	case strings.HasPrefix(envelope.Meta.Version, "PTDL_"):
+		var egg pteroEgg
+		if err := json.Unmarshal(data, &egg); err != nil {
+			return nil, fmt.Errorf("invalid Pterodactyl egg: %w", err)
+		}
+		convertEgg(egg, defaults, seen, result)
+	case envelope.Object == "list":
+		for _, item := range envelope.Data {
+			if err := convertPteroServer(item.Attributes, defaults, seen, result); err != nil {
+				return nil, err
+			}
+		}
+	case envelope.Object == "server":
+		if err := convertPteroServer(envelope.Attributes, defaults, seen, result); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("not a Pterodactyl server list, server or egg export")
+	}
+	return result, nil
+}
+
+func convertPteroServer(raw json.RawMessage, defaults Defaults, seen ids, result *Result) error {
+	var source pteroServer
+	if err := json.Unmarshal(raw, &source); err != nil {
+		return fmt.Errorf("invalid Pterodactyl server: %w", err)
+	}
+
+	server := newServer(defaults)
+	server.ID = seen.next(source.Name, source.Identifier)
+	server.Name = source.Name
+	server.Description = source.Description
+
+	node := source.Relationships.Node.Attributes
+	if node.Name != "" && server.Group == "" {
+		server.Group = node.Name
+	}
+	server.Connection.Host = node.FQDN
+	if server.Connection.Host == "" {
+		// Fall back to the primary allocation's address
+		for i, allocation := range source.Relationships.Allocations.Data {
+			if i == 0 || allocation.Attributes.ID == source.Allocation {
+				server.Connection.Host = allocation.Attributes.IP
+			}
+		}
+	}
+	if server.Connection.Host == "" {
+		server.Connection.Host = defaults.Host
+		result.warnf("%s: no node or allocation in the export; include=allocations,node gives the host", server.ID)
+	}
+
+	if defaults.WorkingDirectory == "" {
+		base := node.DaemonBase
+		if base == "" {
+			base = defaultDaemonBase
+		}
+		server.Server.WorkingDirectory = path.Join(base, source.UUID)
+		server.Dependencies.InstallDir = server.Server.WorkingDirectory
+	}
+
+	variables := make(map[string]string, len(source.Container.Environment)+1)
+	for name, value := range source.Container.Environment {
+		if value != nil {
+			variables[name] = fmt.Sprint(value)
+		}
+	}
+	if source.Limits.Memory > 0 {
+		variables["SERVER_MEMORY"] = strconv.FormatInt(source.Limits.Memory, 10)
+	}
+	applyStartup(&server, expandVariables(source.Container.StartupCommand, variables, &server, result), result)
+
+	result.Servers = append(result.Servers, server)
+	return nil
+}
+
+func convertEgg(egg pteroEgg, defaults Defaults, seen ids, result *Result) {
+	server := newServer(defaults)
+	server.ID = seen.next(egg.Name, "egg")
+	server.Name = egg.Name
+	server.Description = egg.Description
+
+	variables := make(map[string]string, len(egg.Variables))
+	for _, variable := range egg.Variables {
+		variables[variable.EnvVariable] = variable.DefaultValue
+	}
+	applyStartup(&server, expandVariables(egg.Startup, variables, &server, result), result)
+
+	if server.Connection.Host == "" {
+		result.warnf("%s: an egg has no host; pass one in the defaults", server.ID)
+	}
+	result.Servers = append(result.Servers, server)
+}
+
+var startupVariable = regexp.MustCompile(`\{\{\s*(?:server\.build\.env\.|env\.)?([A-Za-z0-9_]+)\s*\}\}`)
+
+// expandVariables fills in the {{VARIABLE}} placeholders of a startup command
+func expandVariables(command string, variables map[string]string, server *config.ServerDefinition, result *Result) string {
+	return startupVariable.ReplaceAllStringFunc(command, func(match string) string {
+		name := startupVariable.FindStringSubmatch(match)[1]
+		value, ok := variables[name]
+		if !ok {
+			result.warnf("%s: start command variable %s has no value", server.ID, name)
+		}
+		return value
+	})
+}
@@ -4,13 +4,17 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
 )
 
+var activityLog = For("activity")
+
 // ActivityLogger provides centralized logging of all server activities
 type ActivityLogger struct {
 	db           *sql.DB
@@ -22,6 +26,7 @@ type ActivityLogger struct {
 
 // Activity represents a logged activity
 type Activity struct {
+	ID           int64                  `json:"id,omitempty"`
 	Timestamp    time.Time              `json:"timestamp"`
 	ServerID     string                 `json:"server_id"`
 	UserID       *int64                 `json:"user_id,omitempty"`
@@ -38,13 +43,17 @@ const (
 	ActivityServerStop           = "server.stop"
 	ActivityServerRestart        = "server.restart"
 	ActivityServerStatusChange   = "server.status_change"
+	ActivityServerCrash          = "server.crash"
 	ActivityCommandExecute       = "command.execute"
 	ActivityConfigUpdate         = "config.update"
 	ActivityBackupCreate         = "backup.create"
 	ActivityBackupRestore        = "backup.restore"
+	ActivityReleaseRollback      = "release.rollback"
 	ActivityConnectionEstablished = "connection.established"
 	ActivityConnectionLost       = "connection.lost"
 	ActivitySSHReconnect         = "ssh.reconnect"
+	ActivitySSHHostKey           = "ssh.host_key"
+	ActivitySSHKeyRotate         = "ssh.key_rotate"
 	ActivityScreenCreate         = "screen.create"
 	ActivityScreenQuit           = "screen.quit"
 	ActivityPTYAttach            = "pty.attach"
@@ -52,6 +61,15 @@ const (
 	ActivityMetricsCollected     = "metrics.collected"
 	ActivityPackageInstall       = "package.install"
 	ActivityPackageDetect        = "package.detect"
+	ActivityHookRun              = "hook.run"
+	ActivityHostSecurityAlert    = "host.security_alert"
+	ActivityScriptRun            = "script.run"
+	ActivityPlayerKick           = "player.kick"
+	ActivityPlayerBan            = "player.ban"
+	ActivityPlayerUnban          = "player.unban"
+	ActivityPlayerWhitelist      = "player.whitelist"
+	ActivityFileChange           = "file.change"
+	ActivityWorldChange          = "world.change"
 	ActivityError                = "error"
 )
 
@@ -67,7 +85,7 @@ func NewActivityLogger(db *sql.DB, logDir string) (*ActivityLogger, error) {
 		logDir: logDir,
 	}
 
-	log.Printf("[ActivityLogger] Initialized (log directory: %s)", logDir)
+	activityLog.Info("Initialized", "log_dir", logDir)
 
 	return logger, nil
 }
@@ -84,13 +102,13 @@ func (al *ActivityLogger) LogActivity(activity *Activity) error {
 
 	// Log to database
 	if err := al.logToDatabase(activity); err != nil {
-		log.Printf("[ActivityLogger] Error logging to database: %v", err)
+		activityLog.Error("Error logging to database", "error", err)
 		// Don't return error, continue with file logging
 	}
 
 	// Log to file
 	if err := al.logToFile(activity); err != nil {
-		log.Printf("[ActivityLogger] Error logging to file: %v", err)
+		activityLog.Error("Error logging to file", "error", err)
 		return err
 	}
 
@@ -157,6 +175,46 @@ func (al *ActivityLogger) LogServerRestart(serverID string, userID *int64, grace
 	})
 }
 
+// LogServerCrash logs an unexpected exit of a server
+func (al *ActivityLogger) LogServerCrash(serverID string, metadata map[string]interface{}, errorMsg string) error {
+	return al.LogActivity(&Activity{
+		ServerID:     serverID,
+		ActivityType: ActivityServerCrash,
+		Description:  "Server exited unexpectedly",
+		Metadata:     metadata,
+		Success:      false,
+		ErrorMessage: errorMsg,
+	})
+}
+
+// LogSecurityAlert logs a spike of failed SSH logins on a server's host
+func (al *ActivityLogger) LogSecurityAlert(serverID string, metadata map[string]interface{}) error {
+	return al.LogActivity(&Activity{
+		ServerID:     serverID,
+		ActivityType: ActivityHostSecurityAlert,
+		Description:  fmt.Sprintf("%v failed SSH logins on host %v", metadata["failed_logins"], metadata["host"]),
+		Metadata:     metadata,
+		Success:      false,
+	})
+}
+
+// LogHookRun logs a run of a configured hook
+func (al *ActivityLogger) LogHookRun(serverID, hook, event string, metadata map[string]interface{}, success bool, errorMsg string) error {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	metadata["hook"] = hook
+	metadata["event"] = event
+	return al.LogActivity(&Activity{
+		ServerID:     serverID,
+		ActivityType: ActivityHookRun,
+		Description:  fmt.Sprintf("Ran %s hook %s", event, hook),
+		Metadata:     metadata,
+		Success:      success,
+		ErrorMessage: errorMsg,
+	})
+}
+
 // LogCommandExecute logs a console command execution
 func (al *ActivityLogger) LogCommandExecute(serverID string, userID *int64, command string, success bool, output string, errorMsg string) error {
 	metadata := map[string]interface{}{
@@ -256,29 +314,72 @@ func (al *ActivityLogger) GetActivities(serverID string, activityType string, si
 		return nil, fmt.Errorf("database not available")
 	}
 
-	query := `
-		SELECT timestamp, server_id, user_id, activity_type, description, metadata, success, error_message
-		FROM activity_log
-		WHERE 1=1
-	`
+	where, args := activityFilter(serverID, activityType, since)
+	return al.queryActivities(where, args, limit)
+}
+
+// ListActivities returns one page of activities, newest first
+func (al *ActivityLogger) ListActivities(serverID string, activityType string, page database.PageRequest) ([]*Activity, database.PageInfo, error) {
+	if al.db == nil {
+		return nil, database.PageInfo{}, fmt.Errorf("database not available")
+	}
+
+	where, args := activityFilter(serverID, activityType, time.Time{})
+	var total int
+	if err := al.db.QueryRow("SELECT COUNT(*) FROM activity_log WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, database.PageInfo{}, fmt.Errorf("failed to count activities: %w", err)
+	}
+
+	if page.After != "" {
+		afterID, err := strconv.ParseInt(page.After, 10, 64)
+		if err != nil {
+			return nil, database.PageInfo{}, database.ErrInvalidCursor
+		}
+		where += " AND id < ?"
+		args = append(args, afterID)
+	}
+
+	activities, err := al.queryActivities(where, args, page.QueryLimit())
+	if err != nil {
+		return nil, database.PageInfo{}, err
+	}
+	count, hasMore := page.Trim(len(activities))
+	activities = activities[:count]
+	lastKey := ""
+	if count > 0 {
+		lastKey = strconv.FormatInt(activities[count-1].ID, 10)
+	}
+	return activities, page.Info(total, hasMore, lastKey), nil
+}
+
+func activityFilter(serverID string, activityType string, since time.Time) (string, []interface{}) {
+	where := "1=1"
 	args := make([]interface{}, 0)
 
 	if serverID != "" {
-		query += " AND server_id = ?"
+		where += " AND server_id = ?"
 		args = append(args, serverID)
 	}
 
 	if activityType != "" {
-		query += " AND activity_type = ?"
+		where += " AND activity_type = ?"
 		args = append(args, activityType)
 	}
 
 	if !since.IsZero() {
-		query += " AND timestamp >= ?"
+		where += " AND timestamp >= ?"
 		args = append(args, since)
 	}
+	return where, args
+}
 
-	query += " ORDER BY timestamp DESC"
+func (al *ActivityLogger) queryActivities(where string, args []interface{}, limit int) ([]*Activity, error) {
+	query := `
+		SELECT id, timestamp, server_id, user_id, activity_type, description, metadata, success, error_message
+		FROM activity_log
+		WHERE ` + where + `
+		ORDER BY id DESC
+	`
 
 	if limit > 0 {
 		query += " LIMIT ?"
@@ -299,6 +400,7 @@ func (al *ActivityLogger) GetActivities(serverID string, activityType string, si
 		var metadataJSON sql.NullString
 
 		err := rows.Scan(
+			&activity.ID,
 			&activity.Timestamp,
 			&activity.ServerID,
 			&userID,
@@ -310,7 +412,7 @@ func (al *ActivityLogger) GetActivities(serverID string, activityType string, si
 		)
 
 		if err != nil {
-			log.Printf("[ActivityLogger] Error scanning row: %v", err)
+			activityLog.Error("Error scanning row", "error", err)
 			continue
 		}
 
@@ -321,7 +423,7 @@ func (al *ActivityLogger) GetActivities(serverID string, activityType string, si
 
 		if metadataJSON.Valid && metadataJSON.String != "" {
 			if err := json.Unmarshal([]byte(metadataJSON.String), &activity.Metadata); err != nil {
-				log.Printf("[ActivityLogger] Error unmarshaling metadata: %v", err)
+				activityLog.Error("Error unmarshaling metadata", "error", err)
 			}
 		}
 
@@ -430,7 +532,7 @@ func (al *ActivityLogger) rotateLogFile(date string) error {
 	al.currentFile = file
 	al.currentDate = date
 
-	log.Printf("[ActivityLogger] Rotated log file to: %s", logPath)
+	activityLog.Info("Rotated log file", "log_path", logPath)
 
 	// Compress old log files (older than 1 day)
 	go al.compressOldLogs()
@@ -449,7 +551,7 @@ func (al *ActivityLogger) compressOldLogs() {
 
 	if _, err := os.Stat(oldLogPath); err == nil {
 		// File exists, would compress here
-		log.Printf("[ActivityLogger] Would compress old log: %s", oldLogPath)
+		activityLog.Debug("Would compress old log", "old_log_path", oldLogPath)
 		// exec.Command("gzip", oldLogPath).Run()
 	}
 }
@@ -484,7 +586,7 @@ func (al *ActivityLogger) CleanupOldActivities(olderThan time.Duration) error {
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	log.Printf("[ActivityLogger] Cleaned up %d activities older than %v", rowsAffected, olderThan)
+	activityLog.Info("Cleaned up old activities", "deleted", rowsAffected, "older_than", olderThan.String())
 
 	return nil
 }
@@ -527,7 +629,7 @@ func (al *ActivityLogger) GetActivityStats(serverID string, since time.Time) (ma
 		var count int
 
 		if err := rows.Scan(&activityType, &count); err != nil {
-			log.Printf("[ActivityLogger] Error scanning stats row: %v", err)
+			activityLog.Error("Error scanning stats row", "server_id", serverID, "error", err)
 			continue
 		}
 
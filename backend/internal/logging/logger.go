@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"context"
 	"io"
 	"log"
 	"log/slog"
@@ -23,13 +24,15 @@ func Init(cfg config.LoggingConfig) (*slog.Logger, error) {
 	var initErr error
 
 	initOnce.Do(func() {
-		level := parseLevel(cfg.Level)
+		SetLevel(cfg.Level)
+		SetModuleLevels(cfg.Modules)
 		output, closer := buildOutput(cfg)
 		if closer != nil {
 			logCloser = closer
 		}
 
-		options := &slog.HandlerOptions{Level: level, AddSource: true}
+		// Levels are enforced per module by moduleHandler, so the base handler passes everything
+		options := &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true}
 		var handler slog.Handler
 		if strings.EqualFold(cfg.Format, "text") {
 			handler = slog.NewTextHandler(output, options)
@@ -37,23 +40,24 @@ func Init(cfg config.LoggingConfig) (*slog.Logger, error) {
 			handler = slog.NewJSONHandler(output, options)
 		}
 
-		logger = slog.New(handler)
+		setBase(handler)
+		logger = For("")
 		slog.SetDefault(logger)
 		log.SetFlags(0)
-		log.SetOutput(slogWriter{logger: logger})
+		log.SetOutput(slogWriter{})
 	})
 
 	if logger == nil {
-		logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		logger = For("")
 	}
 
 	return logger, initErr
 }
 
-// L returns the configured logger, or a no-op logger if not initialized.
+// L returns the application logger. Before Init it writes JSON to stderr.
 func L() *slog.Logger {
 	if logger == nil {
-		return slog.New(slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		return For("")
 	}
 	return logger
 }
@@ -66,16 +70,32 @@ func Close() error {
 	return nil
 }
 
-type slogWriter struct {
-	logger *slog.Logger
-}
+// slogWriter routes the standard library logger through slog for packages that
+// cannot import this one (config, database, tracing). A leading "[Tag]" in the
+// message becomes the module so per-module levels still apply, and messages
+// reporting a failure are logged as errors.
+type slogWriter struct{}
 
 func (w slogWriter) Write(p []byte) (int, error) {
 	msg := strings.TrimSpace(string(p))
 	if msg == "" {
 		return len(p), nil
 	}
-	w.logger.Info(msg)
+	module := ""
+	if strings.HasPrefix(msg, "[") {
+		if end := strings.Index(msg, "] "); end > 1 {
+			module = strings.ToLower(msg[1:end])
+			msg = msg[end+2:]
+		}
+	}
+	level := slog.LevelInfo
+	lower := strings.ToLower(msg)
+	if strings.HasPrefix(lower, "warning") {
+		level = slog.LevelWarn
+	} else if strings.Contains(lower, "failed") || strings.Contains(lower, "error") {
+		level = slog.LevelError
+	}
+	For(module).Log(context.Background(), level, msg)
 	return len(p), nil
 }
 
@@ -109,3 +129,7 @@ func parseLevel(level string) slog.Level {
 		return slog.LevelInfo
 	}
 }
+
+func levelName(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
@@ -1,10 +1,15 @@
 package logging
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
 )
 
 func TestInitAndCloseLogger(t *testing.T) {
@@ -28,3 +33,39 @@ func TestInitAndCloseLogger(t *testing.T) {
 		t.Fatalf("failed to close logger: %v", err)
 	}
 }
+
+func TestModuleLevels(t *testing.T) {
+	var buf bytes.Buffer
+	previous := baseHandler.Load().(slog.Handler)
+	setBase(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() {
+		setBase(previous)
+		SetLevel("info")
+		SetModuleLevels(nil)
+	})
+
+	SetLevel("info")
+	SetModuleLevels(map[string]string{"ssh": "debug"})
+	SetModuleLevel("backup", "error")
+
+	For("ssh").Debug("ssh_debug")
+	For("server").Debug("server_debug")
+	For("backup").Warn("backup_warn")
+	For("server").InfoContext(tracing.WithRequestID(context.Background(), "req-1"), "server_info", "server_id", "alpha")
+
+	out := buf.String()
+	if !strings.Contains(out, "ssh_debug") {
+		t.Fatalf("expected ssh debug record, got %s", out)
+	}
+	if strings.Contains(out, "server_debug") || strings.Contains(out, "backup_warn") {
+		t.Fatalf("expected records below module level to be dropped, got %s", out)
+	}
+	if !strings.Contains(out, `"module":"server"`) || !strings.Contains(out, `"request_id":"req-1"`) || !strings.Contains(out, `"server_id":"alpha"`) {
+		t.Fatalf("expected module, request_id and server_id fields, got %s", out)
+	}
+
+	SetModuleLevel("backup", "")
+	if levels := CurrentLevels(); levels.Modules["backup"] != "" || levels.Modules["ssh"] != "debug" {
+		t.Fatalf("unexpected levels %+v", levels)
+	}
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
+)
+
+var (
+	baseHandler  atomic.Value // slog.Handler
+	globalLevel  = new(slog.LevelVar)
+	moduleMu     sync.RWMutex
+	moduleLevels = map[string]slog.Level{}
+)
+
+func init() {
+	baseHandler.Store(slog.Handler(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})))
+}
+
+func setBase(handler slog.Handler) {
+	baseHandler.Store(handler)
+}
+
+// For returns the logger for a module such as "server" or "backup". Records
+// carry a module field, the request ID from the context passed to the
+// *Context methods, and are filtered by the module's level. Loggers may be
+// created before Init and pick up its output once it runs.
+func For(module string) *slog.Logger {
+	return slog.New(&moduleHandler{module: module})
+}
+
+// SetLevel changes the minimum level of the global logger at runtime.
+func SetLevel(level string) {
+	globalLevel.Set(parseLevel(level))
+}
+
+// SetModuleLevels replaces every per-module override. Modules without an
+// override follow the global level.
+func SetModuleLevels(levels map[string]string) {
+	parsed := make(map[string]slog.Level, len(levels))
+	for module, level := range levels {
+		parsed[strings.ToLower(strings.TrimSpace(module))] = parseLevel(level)
+	}
+	moduleMu.Lock()
+	moduleLevels = parsed
+	moduleMu.Unlock()
+}
+
+// SetModuleLevel overrides one module's level; an empty level removes the override
+func SetModuleLevel(module string, level string) {
+	module = strings.ToLower(strings.TrimSpace(module))
+	moduleMu.Lock()
+	defer moduleMu.Unlock()
+	if strings.TrimSpace(level) == "" {
+		delete(moduleLevels, module)
+		return
+	}
+	moduleLevels[module] = parseLevel(level)
+}
+
+// Levels is the active global level and per-module overrides
+type Levels struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+// CurrentLevels reports the levels in effect
+func CurrentLevels() Levels {
+	moduleMu.RLock()
+	defer moduleMu.RUnlock()
+	levels := Levels{Level: levelName(globalLevel.Level()), Modules: make(map[string]string, len(moduleLevels))}
+	for module, level := range moduleLevels {
+		levels.Modules[module] = levelName(level)
+	}
+	return levels
+}
+
+func levelFor(module string) slog.Level {
+	if module != "" {
+		moduleMu.RLock()
+		level, ok := moduleLevels[module]
+		moduleMu.RUnlock()
+		if ok {
+			return level
+		}
+	}
+	return globalLevel.Level()
+}
+
+// moduleHandler resolves the base handler on every record so loggers built
+// at package init follow the output configured later by Init
+type moduleHandler struct {
+	module string
+	with   []func(slog.Handler) slog.Handler
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= levelFor(h.module)
+}
+
+func (h *moduleHandler) Handle(ctx context.Context, record slog.Record) error {
+	handler := baseHandler.Load().(slog.Handler)
+	if h.module != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String("module", h.module)})
+	}
+	if id := tracing.RequestID(ctx); id != "" {
+		handler = handler.WithAttrs([]slog.Attr{slog.String("request_id", id)})
+	}
+	for _, apply := range h.with {
+		handler = apply(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.extend(func(handler slog.Handler) slog.Handler { return handler.WithAttrs(attrs) })
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return h.extend(func(handler slog.Handler) slog.Handler { return handler.WithGroup(name) })
+}
+
+func (h *moduleHandler) extend(apply func(slog.Handler) slog.Handler) slog.Handler {
+	with := make([]func(slog.Handler) slog.Handler, len(h.with), len(h.with)+1)
+	copy(with, h.with)
+	return &moduleHandler{module: h.module, with: append(with, apply)}
+}
@@ -0,0 +1,195 @@
+package maintenance
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+type fakeControl struct {
+	mu      sync.Mutex
+	groups  map[string]string
+	running map[string]bool
+	started []string
+}
+
+func (f *fakeControl) ServerGroups() map[string]string {
+	return f.groups
+}
+
+func (f *fakeControl) StopForMaintenance(ctx context.Context, serverID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	wasRunning := f.running[serverID]
+	f.running[serverID] = false
+	return wasRunning, nil
+}
+
+func (f *fakeControl) StartAfterMaintenance(ctx context.Context, serverID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running[serverID] = true
+	f.started = append(f.started, serverID)
+	return nil
+}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	return NewStore(db.DB)
+}
+
+func TestWindowOpenAt(t *testing.T) {
+	now := time.Date(2026, 3, 1, 4, 30, 0, 0, time.Local)
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+
+	oneOff := Window{StartsAt: &start, EndsAt: &end, Enabled: true}
+	if until, open := oneOff.OpenAt(now); !open || !until.Equal(end) {
+		t.Fatalf("expected one-off window open until %v, got %v %v", end, until, open)
+	}
+	if _, open := oneOff.OpenAt(end); open {
+		t.Fatal("expected one-off window closed at its end")
+	}
+
+	// Opens daily at 04:00 for two hours
+	recurring := Window{Cron: "0 4 * * *", Duration: "2h", Enabled: true}
+	if until, open := recurring.OpenAt(now); !open || until.Hour() != 6 {
+		t.Fatalf("expected recurring window open until 06:00, got %v %v", until, open)
+	}
+	if _, open := recurring.OpenAt(now.Add(2 * time.Hour)); open {
+		t.Fatal("expected recurring window closed at 06:30")
+	}
+
+	recurring.Enabled = false
+	if _, open := recurring.OpenAt(now); open {
+		t.Fatal("expected disabled window closed")
+	}
+}
+
+func TestWindowValidate(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Hour)
+	cases := []struct {
+		name   string
+		window Window
+		valid  bool
+	}{
+		{"one-off", Window{ServerIDs: []string{"srv"}, StartsAt: &start, EndsAt: &end}, true},
+		{"recurring group", Window{Group: "survival", Cron: "0 4 * * 0", Duration: "2h"}, true},
+		{"no targets", Window{StartsAt: &start, EndsAt: &end}, false},
+		{"ends before start", Window{ServerIDs: []string{"srv"}, StartsAt: &end, EndsAt: &start}, false},
+		{"both timings", Window{ServerIDs: []string{"srv"}, Cron: "@daily", Duration: "1h", StartsAt: &start}, false},
+		{"no duration", Window{ServerIDs: []string{"srv"}, Cron: "@daily"}, false},
+	}
+	for _, tc := range cases {
+		tc.window.Normalize()
+		if err := tc.window.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+func TestManagerStopsAndRestartsServers(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	control := &fakeControl{
+		groups:  map[string]string{"a": "survival", "b": "survival", "c": "creative"},
+		running: map[string]bool{"a": true, "b": false, "c": true},
+	}
+
+	start, end := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	window := &Window{ID: "w1", Name: "Patch day", Group: "survival", StartsAt: &start, EndsAt: &end, Enabled: true}
+	window.Normalize()
+	if err := store.Save(ctx, window); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	m := NewManager(store, control)
+	m.reconcile(ctx)
+	m.Wait()
+
+	if _, active := m.Active("a", "survival"); !active {
+		t.Fatal("expected server a to be in maintenance")
+	}
+	if _, active := m.Active("c", "creative"); active {
+		t.Fatal("expected server c not to be in maintenance")
+	}
+	if control.running["a"] || !control.running["c"] {
+		t.Fatalf("unexpected running servers %v", control.running)
+	}
+	saved, err := store.Get(ctx, "w1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if saved.ActiveUntil == nil || len(saved.StoppedServers) != 1 || saved.StoppedServers[0] != "a" {
+		t.Fatalf("unexpected saved state %+v", saved)
+	}
+
+	// A manager started later picks up the open window
+	restarted := NewManager(store, control)
+	restarted.reconcile(ctx)
+	if _, active := restarted.Active("b", "survival"); !active {
+		t.Fatal("expected open window to survive a restart")
+	}
+
+	if err := restarted.Close(ctx, "w1"); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	restarted.Wait()
+	sort.Strings(control.started)
+	if len(control.started) != 1 || control.started[0] != "a" || !control.running["a"] {
+		t.Fatalf("expected only server a to be started again, got %v", control.started)
+	}
+	if _, active := restarted.Active("a", "survival"); active {
+		t.Fatal("expected closed window to release server a")
+	}
+	if saved, _ := store.Get(ctx, "w1"); saved.ActiveUntil != nil || len(saved.StoppedServers) != 0 {
+		t.Fatalf("expected closed state to be saved, got %+v", saved)
+	}
+}
+
+func TestFollowerMirrorsOpenWindows(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	control := &fakeControl{
+		groups:  map[string]string{"a": "survival"},
+		running: map[string]bool{"a": true},
+	}
+
+	start, end := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	window := &Window{ID: "w1", Name: "Patch day", Group: "survival", StartsAt: &start, EndsAt: &end, Enabled: true}
+	window.Normalize()
+	if err := store.Save(ctx, window); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	follower := NewManager(store, control)
+	follower.SetLeader(func() bool { return false })
+	follower.reconcile(ctx)
+	if _, active := follower.Active("a", "survival"); active || !control.running["a"] {
+		t.Fatal("expected a follower not to open windows")
+	}
+
+	leader := NewManager(store, control)
+	changes := 0
+	leader.OnChange(func() { changes++ })
+	leader.reconcile(ctx)
+	leader.Wait()
+
+	follower.reconcile(ctx)
+	if _, active := follower.Active("a", "survival"); !active || changes == 0 {
+		t.Fatal("expected the follower to see the window the leader opened")
+	}
+}
@@ -0,0 +1,335 @@
+package maintenance
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("maintenance")
+
+// ServerControl stops and starts servers for the window manager
+type ServerControl interface {
+	// ServerGroups returns the group of every defined server, keyed by ID
+	ServerGroups() map[string]string
+	// StopForMaintenance gracefully stops a server and reports whether it was running
+	StopForMaintenance(ctx context.Context, serverID string) (bool, error)
+	// StartAfterMaintenance starts a server the window stopped
+	StartAfterMaintenance(ctx context.Context, serverID string) error
+}
+
+// Manager opens and closes maintenance windows. When a window opens, the
+// running servers it covers are stopped; when it closes, those servers are
+// started again.
+type Manager struct {
+	store    *Store
+	control  ServerControl
+	interval time.Duration
+	leader   func() bool
+	onChange []func()
+
+	mu   sync.RWMutex
+	open map[string]*Window
+	busy map[string]bool
+	ctx  context.Context
+	wg   sync.WaitGroup
+	wake chan struct{}
+}
+
+// NewManager creates a maintenance window manager
+func NewManager(store *Store, control ServerControl) *Manager {
+	return &Manager{
+		store:    store,
+		control:  control,
+		interval: 30 * time.Second,
+		leader:   func() bool { return true },
+		open:     make(map[string]*Window),
+		busy:     make(map[string]bool),
+		ctx:      context.Background(),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start checks the windows every interval until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	m.ctx = ctx
+	ticker := time.NewTicker(m.interval)
+	go func() {
+		defer ticker.Stop()
+		m.reconcile(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Info("Stopping maintenance window manager")
+				return
+			case <-ticker.C:
+			case <-m.wake:
+			}
+			m.reconcile(ctx)
+		}
+	}()
+}
+
+// SetLeader makes the manager open and close windows only while leader
+// reports true. Otherwise it follows the windows the leading manager
+// instance opened, so starts are still refused while they are open. It must
+// be called before Start.
+func (m *Manager) SetLeader(leader func() bool) {
+	m.leader = leader
+}
+
+// OnChange registers fn to be called after a window was recorded as opened or
+// closed. It must be called before Start.
+func (m *Manager) OnChange(fn func()) {
+	m.onChange = append(m.onChange, fn)
+}
+
+func (m *Manager) changed() {
+	for _, fn := range m.onChange {
+		fn()
+	}
+}
+
+// Refresh checks the windows again soon, after one was created or changed
+func (m *Manager) Refresh() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wait blocks until servers being stopped or started by a window are done
+func (m *Manager) Wait() {
+	m.wg.Wait()
+}
+
+// Active returns the open window covering a server, if any
+func (m *Manager) Active(serverID, group string) (*Window, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, window := range m.open {
+		if window.Covers(serverID, group) {
+			copied := *window
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// OpenWindows returns the IDs of the windows that are open
+func (m *Manager) OpenWindows() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := make(map[string]bool, len(m.open))
+	for id := range m.open {
+		ids[id] = true
+	}
+	return ids
+}
+
+// Close ends a window now, starting the servers it stopped. It is used
+// before a window is deleted.
+func (m *Manager) Close(ctx context.Context, id string) error {
+	window, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if window.ActiveUntil != nil {
+		m.closeWindow(window)
+	}
+	return nil
+}
+
+func (m *Manager) reconcile(ctx context.Context) {
+	windows, err := m.store.List(ctx)
+	if err != nil {
+		logger.Error("Failed to list maintenance windows", "error", err)
+		return
+	}
+	if !m.leader() {
+		m.follow(windows)
+		return
+	}
+
+	now := time.Now()
+	known := make(map[string]bool, len(windows))
+	for _, window := range windows {
+		known[window.ID] = true
+		until, open := window.OpenAt(now)
+		switch {
+		case window.ActiveUntil == nil && open:
+			m.openWindow(window, until)
+		case window.ActiveUntil != nil && (!open || !now.Before(*window.ActiveUntil)):
+			m.closeWindow(window)
+		case window.ActiveUntil != nil:
+			// Opened before a restart of the manager
+			m.mu.Lock()
+			if _, tracked := m.open[window.ID]; !tracked && !m.busy[window.ID] {
+				m.open[window.ID] = window
+			}
+			m.mu.Unlock()
+		}
+	}
+
+	m.mu.Lock()
+	for id := range m.open {
+		if !known[id] && !m.busy[id] {
+			delete(m.open, id)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// follow mirrors the windows another manager instance has open
+func (m *Manager) follow(windows []*Window) {
+	open := make(map[string]*Window)
+	for _, window := range windows {
+		if window.ActiveUntil != nil {
+			open[window.ID] = window
+		}
+	}
+	m.mu.Lock()
+	m.open = open
+	m.mu.Unlock()
+}
+
+func (m *Manager) openWindow(window *Window, until time.Time) {
+	if !m.claim(window.ID) {
+		return
+	}
+	window.ActiveUntil = &until
+	window.StoppedServers = []string{}
+
+	// Starts are blocked from here on, before the servers are stopped
+	m.mu.Lock()
+	m.open[window.ID] = window
+	m.mu.Unlock()
+	if err := m.store.SetActive(m.ctx, window.ID, &until, nil); err != nil {
+		logger.Error("Failed to record open maintenance window", "window_id", window.ID, "error", err)
+	}
+	m.changed()
+	logger.Info("Maintenance window opened", "window_id", window.ID, "name", window.Name, "until", until)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.release(window.ID)
+
+		var (
+			mu      sync.Mutex
+			stopped []string
+			wg      sync.WaitGroup
+		)
+		for _, serverID := range m.targets(window) {
+			wg.Add(1)
+			go func(serverID string) {
+				defer wg.Done()
+				wasRunning, err := m.control.StopForMaintenance(m.ctx, serverID)
+				if err != nil {
+					logger.Error("Failed to stop server for maintenance", "window_id", window.ID, "server_id", serverID, "error", err)
+				}
+				if wasRunning {
+					mu.Lock()
+					stopped = append(stopped, serverID)
+					mu.Unlock()
+				}
+			}(serverID)
+		}
+		wg.Wait()
+
+		// Servers handed over by a window that closed meanwhile are kept
+		m.mu.Lock()
+		if current, ok := m.open[window.ID]; ok {
+			stopped = append(stopped, current.StoppedServers...)
+			sort.Strings(stopped)
+			current.StoppedServers = stopped
+		}
+		m.mu.Unlock()
+		if err := m.store.SetActive(m.ctx, window.ID, &until, stopped); err != nil {
+			logger.Error("Failed to record stopped servers", "window_id", window.ID, "error", err)
+		}
+	}()
+}
+
+func (m *Manager) closeWindow(window *Window) {
+	if !m.claim(window.ID) {
+		return
+	}
+	m.mu.Lock()
+	if current, ok := m.open[window.ID]; ok {
+		window.StoppedServers = current.StoppedServers
+	}
+	delete(m.open, window.ID)
+	m.mu.Unlock()
+	logger.Info("Maintenance window closed", "window_id", window.ID, "name", window.Name)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.release(window.ID)
+
+		groups := m.control.ServerGroups()
+		for _, serverID := range window.StoppedServers {
+			// Another open window takes over servers it also covers
+			if other, ok := m.Active(serverID, groups[serverID]); ok {
+				m.handOver(other.ID, serverID)
+				continue
+			}
+			if err := m.control.StartAfterMaintenance(m.ctx, serverID); err != nil {
+				logger.Error("Failed to start server after maintenance", "window_id", window.ID, "server_id", serverID, "error", err)
+			}
+		}
+		if err := m.store.SetActive(m.ctx, window.ID, nil, nil); err != nil {
+			logger.Error("Failed to record closed maintenance window", "window_id", window.ID, "error", err)
+		}
+		m.changed()
+	}()
+}
+
+// handOver adds a server to the stopped list of another open window so it is
+// started when that window closes
+func (m *Manager) handOver(windowID, serverID string) {
+	m.mu.Lock()
+	window, ok := m.open[windowID]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	window.StoppedServers = append(window.StoppedServers, serverID)
+	until, stopped := window.ActiveUntil, append([]string{}, window.StoppedServers...)
+	m.mu.Unlock()
+
+	if err := m.store.SetActive(m.ctx, windowID, until, stopped); err != nil {
+		logger.Error("Failed to record stopped servers", "window_id", windowID, "error", err)
+	}
+}
+
+func (m *Manager) targets(window *Window) []string {
+	var ids []string
+	for serverID, group := range m.control.ServerGroups() {
+		if window.Covers(serverID, group) {
+			ids = append(ids, serverID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *Manager) claim(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[id] {
+		return false
+	}
+	m.busy[id] = true
+	return true
+}
+
+func (m *Manager) release(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busy, id)
+}
@@ -0,0 +1,166 @@
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned for unknown window IDs
+var ErrNotFound = errors.New("maintenance window not found")
+
+// Store persists maintenance windows. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new maintenance window store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const windowColumns = `id, name, server_ids, server_group, starts_at, ends_at, cron, duration, enabled, active_until, stopped_servers, created_at, updated_at`
+
+// Get returns one window
+func (s *Store) Get(ctx context.Context, id string) (*Window, error) {
+	window, err := scanWindow(s.db.QueryRowContext(ctx, `SELECT `+windowColumns+` FROM maintenance_windows WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load maintenance window: %w", err)
+	}
+	return window, nil
+}
+
+// List returns every window
+func (s *Store) List(ctx context.Context) ([]*Window, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+windowColumns+` FROM maintenance_windows ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list maintenance windows: %w", err)
+	}
+	defer rows.Close()
+
+	windows := make([]*Window, 0)
+	for rows.Next() {
+		window, err := scanWindow(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan maintenance window: %w", err)
+		}
+		windows = append(windows, window)
+	}
+	return windows, rows.Err()
+}
+
+// Save creates or updates a window's settings. Whether it is open, and which
+// servers it stopped, is only changed through SetActive.
+func (s *Store) Save(ctx context.Context, window *Window) error {
+	now := time.Now().UTC()
+	if window.CreatedAt.IsZero() {
+		window.CreatedAt = now
+	}
+	window.UpdatedAt = now
+
+	serverIDs, _ := json.Marshal(window.ServerIDs)
+	stopped, _ := json.Marshal(window.StoppedServers)
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO maintenance_windows (`+windowColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			server_ids = excluded.server_ids,
+			server_group = excluded.server_group,
+			starts_at = excluded.starts_at,
+			ends_at = excluded.ends_at,
+			cron = excluded.cron,
+			duration = excluded.duration,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`,
+		window.ID, window.Name, string(serverIDs), nullString(window.Group), nullTime(window.StartsAt), nullTime(window.EndsAt),
+		nullString(window.Cron), nullString(window.Duration), window.Enabled, nullTime(window.ActiveUntil), string(stopped),
+		window.CreatedAt, window.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save maintenance window: %w", err)
+	}
+	return nil
+}
+
+// SetActive records that a window is open until the given time, or closed
+// when until is nil, and which servers it stopped
+func (s *Store) SetActive(ctx context.Context, id string, until *time.Time, stopped []string) error {
+	if stopped == nil {
+		stopped = []string{}
+	}
+	data, _ := json.Marshal(stopped)
+	_, err := s.db.ExecContext(ctx, `UPDATE maintenance_windows SET active_until = ?, stopped_servers = ? WHERE id = ?`,
+		nullTime(until), string(data), id)
+	if err != nil {
+		return fmt.Errorf("failed to update maintenance window state: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a window. It returns false if no such window exists.
+func (s *Store) Delete(ctx context.Context, id string) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete maintenance window: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWindow(row rowScanner) (*Window, error) {
+	var (
+		window                        Window
+		serverIDs, stopped            string
+		group, cronExpr, duration     sql.NullString
+		startsAt, endsAt, activeUntil sql.NullTime
+	)
+	if err := row.Scan(&window.ID, &window.Name, &serverIDs, &group, &startsAt, &endsAt, &cronExpr, &duration,
+		&window.Enabled, &activeUntil, &stopped, &window.CreatedAt, &window.UpdatedAt); err != nil {
+		return nil, err
+	}
+	window.Group = group.String
+	window.Cron = cronExpr.String
+	window.Duration = duration.String
+	if startsAt.Valid {
+		window.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		window.EndsAt = &endsAt.Time
+	}
+	if activeUntil.Valid {
+		window.ActiveUntil = &activeUntil.Time
+	}
+	if err := json.Unmarshal([]byte(serverIDs), &window.ServerIDs); err != nil {
+		return nil, fmt.Errorf("invalid server_ids: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stopped), &window.StoppedServers); err != nil {
+		return nil, fmt.Errorf("invalid stopped_servers: %w", err)
+	}
+	return &window, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
@@ -0,0 +1,132 @@
+package maintenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser accepts the same syntax as scheduled tasks and backup schedules
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Window is a period during which servers are kept stopped. A one-off window
+// runs from StartsAt to EndsAt; a recurring one opens whenever Cron fires and
+// stays open for Duration. It covers the servers in ServerIDs and every
+// server whose group is Group.
+type Window struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	ServerIDs []string   `json:"server_ids"`
+	Group     string     `json:"group,omitempty"`
+	StartsAt  *time.Time `json:"starts_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Cron      string     `json:"cron,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	Enabled   bool       `json:"enabled"`
+	// ActiveUntil is set while the window is open
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	// StoppedServers lists the servers the window stopped, to be started again when it closes
+	StoppedServers []string  `json:"stopped_servers"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Recurring reports whether the window repeats on a cron expression
+func (w *Window) Recurring() bool {
+	return w.Cron != ""
+}
+
+// Normalize trims fields and fills in defaults
+func (w *Window) Normalize() {
+	w.Name = strings.TrimSpace(w.Name)
+	w.Group = strings.TrimSpace(w.Group)
+	w.Cron = strings.TrimSpace(w.Cron)
+	w.Duration = strings.TrimSpace(w.Duration)
+	ids := make([]string, 0, len(w.ServerIDs))
+	for _, id := range w.ServerIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	w.ServerIDs = ids
+	if w.StoppedServers == nil {
+		w.StoppedServers = []string{}
+	}
+	if w.Name == "" {
+		w.Name = "Maintenance"
+	}
+}
+
+// Validate checks that the window has targets and exactly one kind of timing
+func (w *Window) Validate() error {
+	if len(w.ServerIDs) == 0 && w.Group == "" {
+		return fmt.Errorf("server_ids or group is required")
+	}
+
+	if w.Recurring() {
+		if w.StartsAt != nil || w.EndsAt != nil {
+			return fmt.Errorf("use either cron and duration or starts_at and ends_at")
+		}
+		if _, err := cronParser.Parse(w.Cron); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", w.Cron, err)
+		}
+		d, err := time.ParseDuration(w.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("duration must be a positive duration such as 2h")
+		}
+		return nil
+	}
+
+	if w.StartsAt == nil || w.EndsAt == nil {
+		return fmt.Errorf("starts_at and ends_at are required for a one-off window")
+	}
+	if !w.EndsAt.After(*w.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// OpenAt reports whether the window is open at now and, if so, when it closes
+func (w *Window) OpenAt(now time.Time) (time.Time, bool) {
+	if !w.Enabled {
+		return time.Time{}, false
+	}
+
+	if !w.Recurring() {
+		if w.StartsAt == nil || w.EndsAt == nil {
+			return time.Time{}, false
+		}
+		return *w.EndsAt, !now.Before(*w.StartsAt) && now.Before(*w.EndsAt)
+	}
+
+	schedule, err := cronParser.Parse(w.Cron)
+	if err != nil {
+		return time.Time{}, false
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil || duration <= 0 {
+		return time.Time{}, false
+	}
+	// The earliest start after now-duration is the one whose window could
+	// still be running
+	start := schedule.Next(now.Add(-duration))
+	if start.After(now) {
+		return time.Time{}, false
+	}
+	return start.Add(duration), true
+}
+
+// Covers reports whether the window applies to a server in group
+func (w *Window) Covers(serverID, group string) bool {
+	if w.Group != "" && strings.EqualFold(w.Group, group) {
+		return true
+	}
+	for _, id := range w.ServerIDs {
+		if id == serverID {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package metrics
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,27 +11,24 @@ import (
 	"sync"
 	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/alerts"
 	"github.com/TheGojiOG/HytaleSM/internal/config"
 	"github.com/TheGojiOG/HytaleSM/internal/database"
 )
 
 type Collector struct {
 	cfg           *config.Config
+	settings      config.MetricsConfig
 	serverManager *config.ServerManager
 	db            *database.DB
+	writer        *Writer
 	client        *http.Client
 	stopCh        chan struct{}
 	wg            sync.WaitGroup
 	mu            sync.Mutex
 	lastCollected map[string]time.Time
-	cpuSamples    map[string]cpuSample
-	lastCleanup   time.Time
-}
-
-type cpuSample struct {
-	timestamp time.Time
-	idle      float64
-	total     float64
+	cpuSamples    *CPUSamples
+	alerts        *alerts.Evaluator
 }
 
 type nodeExporterMetrics struct {
@@ -49,23 +47,24 @@ type nodeExporterMetrics struct {
 	cpuTotal        float64
 }
 
-func NewCollector(cfg *config.Config, serverManager *config.ServerManager, db *database.DB) *Collector {
+func NewCollector(cfg *config.Config, serverManager *config.ServerManager, db *database.DB, writer *Writer) *Collector {
 	return &Collector{
 		cfg:           cfg,
+		settings:      cfg.Metrics,
 		serverManager: serverManager,
 		db:            db,
+		writer:        writer,
 		client:        &http.Client{Timeout: 5 * time.Second},
 		stopCh:        make(chan struct{}),
 		lastCollected: make(map[string]time.Time),
-		cpuSamples:    make(map[string]cpuSample),
+		cpuSamples:    NewCPUSamples(),
 	}
 }
 
-func (c *Collector) Start() {
-	if !c.cfg.Metrics.Enabled {
-		return
-	}
-
+// Start runs the collection loop until ctx is cancelled or Stop is called. It
+// keeps ticking while metrics are disabled so that re-enabling them through a
+// config reload takes effect without a restart.
+func (c *Collector) Start(ctx context.Context) {
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
@@ -75,6 +74,8 @@ func (c *Collector) Start() {
 			select {
 			case <-ticker.C:
 				c.collectAll()
+			case <-ctx.Done():
+				return
 			case <-c.stopCh:
 				return
 			}
@@ -82,13 +83,37 @@ func (c *Collector) Start() {
 	}()
 }
 
+// SetCPUSamples replaces where CPU counters are kept between collections
+func (c *Collector) SetCPUSamples(samples *CPUSamples) {
+	c.cpuSamples = samples
+}
+
+// SetAlerts has every collected sample checked against the alert rules
+func (c *Collector) SetAlerts(evaluator *alerts.Evaluator) {
+	c.alerts = evaluator
+}
+
 func (c *Collector) Stop() {
 	close(c.stopCh)
 	c.wg.Wait()
 }
 
+// SetConfig replaces the collection settings at runtime
+func (c *Collector) SetConfig(settings config.MetricsConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings = settings
+}
+
+func (c *Collector) currentSettings() config.MetricsConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
 func (c *Collector) collectAll() {
-	if !c.cfg.Metrics.Enabled {
+	settings := c.currentSettings()
+	if !settings.Enabled {
 		return
 	}
 
@@ -102,7 +127,7 @@ func (c *Collector) collectAll() {
 
 		interval := serverDef.Monitoring.Interval
 		if interval <= 0 {
-			interval = c.cfg.Metrics.DefaultInterval
+			interval = settings.DefaultInterval
 		}
 		if interval <= 0 {
 			interval = 60
@@ -112,16 +137,21 @@ func (c *Collector) collectAll() {
 			continue
 		}
 
-		metrics, err := c.collectNodeExporterMetrics(serverID, serverDef)
+		var metrics map[string]interface{}
+		var err error
+		if IsLocal(serverDef) {
+			metrics, err = LocalMetrics(serverID, serverDef, c.cpuSamples)
+		} else {
+			metrics, err = c.collectNodeExporterMetrics(serverID, serverDef)
+		}
 		if err != nil || len(metrics) == 0 {
 			continue
 		}
 
-		_ = c.recordMetrics(serverID, metrics, "online")
+		_ = c.writer.Record(serverID, metrics, "online")
 		c.setCollected(serverID, now)
+		c.alerts.Evaluate(context.Background(), serverID, metrics, now)
 	}
-
-	c.cleanupOldMetrics(now)
 }
 
 func (c *Collector) shouldCollect(serverID string, now time.Time, interval time.Duration) bool {
@@ -140,20 +170,6 @@ func (c *Collector) setCollected(serverID string, now time.Time) {
 	c.lastCollected[serverID] = now
 }
 
-func (c *Collector) cleanupOldMetrics(now time.Time) {
-	if c.db == nil || c.cfg.Metrics.RetentionDays <= 0 {
-		return
-	}
-
-	if !c.lastCleanup.IsZero() && now.Sub(c.lastCleanup) < 6*time.Hour {
-		return
-	}
-
-	cutoff := now.Add(-time.Duration(c.cfg.Metrics.RetentionDays) * 24 * time.Hour)
-	_, _ = c.db.Exec("DELETE FROM server_metrics WHERE timestamp < ?", cutoff.Format(time.RFC3339))
-	c.lastCleanup = now
-}
-
 func (c *Collector) collectNodeExporterMetrics(serverID string, serverDef config.ServerDefinition) (map[string]interface{}, error) {
 	url := resolveNodeExporterURL(serverDef)
 	if url == "" {
@@ -204,7 +220,7 @@ func (c *Collector) collectNodeExporterMetrics(serverID string, serverDef config
 	}
 
 	if parsed.cpuTotal > 0 {
-		if usage, ok := c.calculateCPUUsage(serverID, parsed.cpuIdle, parsed.cpuTotal); ok {
+		if usage, ok := c.cpuSamples.Usage(serverID, parsed.cpuIdle, parsed.cpuTotal); ok {
 			metrics["cpu_usage"] = usage
 		}
 	}
@@ -213,62 +229,6 @@ func (c *Collector) collectNodeExporterMetrics(serverID string, serverDef config
 	return metrics, nil
 }
 
-func (c *Collector) calculateCPUUsage(serverID string, idle float64, total float64) (float64, bool) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	now := time.Now()
-	prev, ok := c.cpuSamples[serverID]
-	c.cpuSamples[serverID] = cpuSample{timestamp: now, idle: idle, total: total}
-	if !ok {
-		return 0, false
-	}
-
-	if total <= prev.total {
-		return 0, false
-	}
-
-	deltaIdle := idle - prev.idle
-	deltaTotal := total - prev.total
-	if deltaTotal <= 0 {
-		return 0, false
-	}
-
-	usage := (1 - (deltaIdle / deltaTotal)) * 100
-	if usage < 0 {
-		usage = 0
-	}
-	if usage > 100 {
-		usage = 100
-	}
-
-	return usage, true
-}
-
-func (c *Collector) recordMetrics(serverID string, metrics map[string]interface{}, status string) error {
-	if c.db == nil {
-		return nil
-	}
-
-	_, err := c.db.Exec(`
-		INSERT INTO server_metrics (
-			server_id, cpu_usage, memory_used, memory_total, disk_used, disk_total, network_rx, network_tx, status
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`,
-		serverID,
-		metrics["cpu_usage"],
-		metrics["memory_used"],
-		metrics["memory_total"],
-		metrics["disk_used"],
-		metrics["disk_total"],
-		metrics["network_rx"],
-		metrics["network_tx"],
-		status,
-	)
-
-	return err
-}
-
 func resolveNodeExporterURL(serverDef config.ServerDefinition) string {
 	if serverDef.Monitoring.NodeExporterURL != "" {
 		return normalizeNodeExporterURL(serverDef.Monitoring.NodeExporterURL)
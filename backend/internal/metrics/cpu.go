@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+type cpuSample struct {
+	timestamp time.Time
+	idle      float64
+	total     float64
+}
+
+// CPUSamples keeps the last CPU counters read from each server, so usage can
+// be computed from the change between two reads. Shared samples live in the
+// database, so reads made by different manager instances follow on from
+// each other.
+type CPUSamples struct {
+	db     *sql.DB
+	source string
+
+	mu      sync.Mutex
+	samples map[string]cpuSample
+}
+
+// NewCPUSamples keeps samples in memory
+func NewCPUSamples() *CPUSamples {
+	return &CPUSamples{samples: make(map[string]cpuSample)}
+}
+
+// NewSharedCPUSamples keeps samples in the database. source separates
+// readers that must not see each other's samples.
+func NewSharedCPUSamples(db *sql.DB, source string) *CPUSamples {
+	return &CPUSamples{db: db, source: source}
+}
+
+// Usage records a read of a server's idle and total CPU seconds and returns
+// the usage in percent since the previous read. It reports false for the
+// first read and after the counters went back, e.g. after a reboot.
+func (s *CPUSamples) Usage(serverID string, idle float64, total float64) (float64, bool) {
+	prev, ok := s.swap(serverID, cpuSample{timestamp: time.Now(), idle: idle, total: total})
+	if !ok {
+		return 0, false
+	}
+
+	if total <= prev.total {
+		return 0, false
+	}
+
+	deltaIdle := idle - prev.idle
+	deltaTotal := total - prev.total
+	if deltaTotal <= 0 {
+		return 0, false
+	}
+
+	usage := (1 - (deltaIdle / deltaTotal)) * 100
+	if usage < 0 {
+		usage = 0
+	}
+	if usage > 100 {
+		usage = 100
+	}
+
+	return usage, true
+}
+
+// swap stores sample and returns the one it replaced
+func (s *CPUSamples) swap(serverID string, sample cpuSample) (cpuSample, bool) {
+	if s.db == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		prev, ok := s.samples[serverID]
+		s.samples[serverID] = sample
+		return prev, ok
+	}
+
+	var prev cpuSample
+	err := s.db.QueryRow(`SELECT idle, total, sampled_at FROM cpu_samples WHERE source = ? AND server_id = ?`,
+		s.source, serverID).Scan(&prev.idle, &prev.total, &prev.timestamp)
+	found := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		logger.Warn("Failed to load CPU sample", "server_id", serverID, "error", err)
+	}
+
+	if _, err := s.db.Exec(`
+		INSERT INTO cpu_samples (source, server_id, idle, total, sampled_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(source, server_id) DO UPDATE SET
+			idle = excluded.idle,
+			total = excluded.total,
+			sampled_at = excluded.sampled_at
+	`, s.source, serverID, sample.idle, sample.total, sample.timestamp.UTC()); err != nil {
+		logger.Warn("Failed to store CPU sample", "server_id", serverID, "error", err)
+	}
+	return prev, found
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package metrics
+
+import "syscall"
+
+// diskUsage reports the total and available bytes on the filesystem holding path
+func diskUsage(path string) (total, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
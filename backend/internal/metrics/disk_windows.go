@@ -0,0 +1,11 @@
+//go:build windows
+
+package metrics
+
+import "errors"
+
+// diskUsage is not implemented on Windows, where local servers are not
+// supported
+func diskUsage(path string) (total, free uint64, err error) {
+	return 0, 0, errors.New("disk usage is not supported on windows")
+}
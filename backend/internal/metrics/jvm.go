@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// JVMSample is one reading of a server's JVM as the agent reports it. Sizes
+// are bytes and GC times the seconds spent collecting since the JVM started.
+type JVMSample struct {
+	Timestamp      time.Time `json:"timestamp"`
+	PID            int       `json:"pid"`
+	HeapUsed       int64     `json:"heap_used"`
+	HeapCommitted  int64     `json:"heap_committed"`
+	HeapMax        int64     `json:"heap_max"`
+	MetaspaceUsed  int64     `json:"metaspace_used"`
+	YoungGCCount   int64     `json:"young_gc_count"`
+	YoungGCSeconds float64   `json:"young_gc_seconds"`
+	FullGCCount    int64     `json:"full_gc_count"`
+	FullGCSeconds  float64   `json:"full_gc_seconds"`
+	GCSeconds      float64   `json:"gc_seconds"`
+	Threads        int       `json:"threads"`
+}
+
+// RecordJVM stores a JVM reading. Readings are pruned with the raw samples.
+func RecordJVM(ctx context.Context, db *sql.DB, serverID string, sample JVMSample) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO server_jvm_metrics (
+			server_id, timestamp, pid, heap_used, heap_committed, heap_max, metaspace_used,
+			young_gc_count, young_gc_seconds, full_gc_count, full_gc_seconds, gc_seconds, threads
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, serverID, sample.Timestamp.UTC().Format(TimestampFormat), sample.PID, sample.HeapUsed, sample.HeapCommitted,
+		sample.HeapMax, sample.MetaspaceUsed, sample.YoungGCCount, sample.YoungGCSeconds, sample.FullGCCount,
+		sample.FullGCSeconds, sample.GCSeconds, sample.Threads)
+	if err != nil {
+		return fmt.Errorf("record jvm metrics: %w", err)
+	}
+	return nil
+}
+
+// ListJVM returns a server's JVM readings in [from, to), newest first. A
+// zero from or to leaves that end open.
+func ListJVM(ctx context.Context, db *sql.DB, serverID string, from, to time.Time, limit int) ([]JVMSample, error) {
+	query := `
+		SELECT timestamp, pid, heap_used, heap_committed, heap_max, metaspace_used,
+			young_gc_count, young_gc_seconds, full_gc_count, full_gc_seconds, gc_seconds, threads
+		FROM server_jvm_metrics
+		WHERE server_id = ?`
+	args := []interface{}{serverID}
+	if !from.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, from.UTC().Format(TimestampFormat))
+	}
+	if !to.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, to.UTC().Format(TimestampFormat))
+	}
+	query += " ORDER BY timestamp DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list jvm metrics: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make([]JVMSample, 0)
+	for rows.Next() {
+		var sample JVMSample
+		if err := rows.Scan(&sample.Timestamp, &sample.PID, &sample.HeapUsed, &sample.HeapCommitted, &sample.HeapMax,
+			&sample.MetaspaceUsed, &sample.YoungGCCount, &sample.YoungGCSeconds, &sample.FullGCCount,
+			&sample.FullGCSeconds, &sample.GCSeconds, &sample.Threads); err != nil {
+			return nil, fmt.Errorf("scan jvm metrics: %w", err)
+		}
+		sample.Timestamp = sample.Timestamp.UTC()
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestJVMSamplesRecordListAndPrune(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "jvm.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, at := range []time.Time{now.Add(-5 * 24 * time.Hour), now.Add(-time.Hour), now.Add(-time.Minute)} {
+		sample := JVMSample{Timestamp: at, PID: 4242, HeapUsed: int64(i+1) << 20, HeapCommitted: 8 << 20, GCSeconds: 1.5, Threads: 40 + i}
+		if err := RecordJVM(ctx, db.DB, "alpha", sample); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if err := RecordJVM(ctx, db.DB, "beta", JVMSample{Timestamp: now, PID: 1}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	samples, err := ListJVM(ctx, db.DB, "alpha", now.Add(-2*time.Hour), time.Time{}, 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(samples) != 2 || samples[0].Threads != 42 || !samples[0].Timestamp.Equal(now.Add(-time.Minute)) || samples[1].HeapUsed != 2<<20 {
+		t.Fatalf("expected the last two alpha readings newest first, got %+v", samples)
+	}
+
+	// JVM readings are kept as long as the raw samples
+	rollup := NewRollup(db, config.MetricsConfig{RetentionDays: 2})
+	if err := rollup.Run(ctx, now); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	samples, err = ListJVM(ctx, db.DB, "alpha", time.Time{}, time.Time{}, 10)
+	if err != nil || len(samples) != 2 {
+		t.Fatalf("expected the old reading pruned, got %+v (%v)", samples, err)
+	}
+}
@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// procRoot is where the kernel's process information is mounted
+var procRoot = "/proc"
+
+// procTicks is USER_HZ, the unit of the CPU times in /proc/stat
+const procTicks = 100
+
+// IsLocal reports whether a server's host metrics are read from this machine
+// instead of node_exporter: it runs on the manager's host and no node
+// exporter URL is configured
+func IsLocal(serverDef config.ServerDefinition) bool {
+	return serverDef.Connection.IsLocal() && strings.TrimSpace(serverDef.Monitoring.NodeExporterURL) == ""
+}
+
+// LocalMetrics reads the figures node_exporter would report for this machine
+// from /proc, with the disk holding the server's working directory
+func LocalMetrics(serverID string, serverDef config.ServerDefinition, samples *CPUSamples) (map[string]interface{}, error) {
+	host, err := readLocalHost(localDiskPath(serverDef.Server.WorkingDirectory))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := map[string]interface{}{}
+	if host.hasMemoryTotal && host.hasMemoryAvail {
+		metrics["memory_total"] = int64(host.memoryTotal)
+		metrics["memory_used"] = int64(max(host.memoryTotal-host.memoryAvailable, 0))
+	}
+	if host.hasDiskSize && host.hasDiskAvail {
+		metrics["disk_total"] = int64(host.diskSize)
+		metrics["disk_used"] = int64(max(host.diskSize-host.diskAvailable, 0))
+	}
+	if host.networkRx > 0 || host.networkTx > 0 {
+		metrics["network_rx"] = int64(host.networkRx)
+		metrics["network_tx"] = int64(host.networkTx)
+	}
+	if host.load1 >= 0 {
+		metrics["load1"] = host.load1
+	}
+	if host.cpuTotal > 0 {
+		if usage, ok := samples.Usage(serverID, host.cpuIdle, host.cpuTotal); ok {
+			metrics["cpu_usage"] = usage
+		}
+	}
+	metrics["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	return metrics, nil
+}
+
+// readLocalHost fills the node_exporter figures from /proc; memory is
+// required, the others are left out when they cannot be read
+func readLocalHost(diskPath string) (*nodeExporterMetrics, error) {
+	host := &nodeExporterMetrics{load1: -1}
+
+	meminfo, err := readProcFields("meminfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory: %w", err)
+	}
+	for _, fields := range meminfo {
+		if len(fields) < 2 {
+			continue
+		}
+		kb, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			host.memoryTotal, host.hasMemoryTotal = kb*1024, true
+		case "MemAvailable:":
+			host.memoryAvailable, host.hasMemoryAvail = kb*1024, true
+		}
+	}
+
+	if loadavg, err := readProcFields("loadavg"); err == nil && len(loadavg) > 0 && len(loadavg[0]) > 0 {
+		if load, err := strconv.ParseFloat(loadavg[0][0], 64); err == nil {
+			host.load1 = load
+		}
+	}
+
+	// cpu user nice system idle iowait irq softirq steal, in ticks; guest
+	// time is already counted in user
+	if stat, err := readProcFields("stat"); err == nil {
+		for _, fields := range stat {
+			if len(fields) < 5 || fields[0] != "cpu" {
+				continue
+			}
+			for i, field := range fields[1:min(len(fields), 9)] {
+				ticks, _ := strconv.ParseFloat(field, 64)
+				host.cpuTotal += ticks / procTicks
+				if i == 3 {
+					host.cpuIdle = ticks / procTicks
+				}
+			}
+			break
+		}
+	}
+
+	// Inter-|   Receive ...            |  Transmit
+	//  face |bytes packets ... (8 fields)|bytes ...
+	if netdev, err := readProcFields("net/dev"); err == nil {
+		for _, fields := range netdev {
+			if len(fields) < 10 || !strings.HasSuffix(fields[0], ":") || fields[0] == "lo:" {
+				continue
+			}
+			rx, _ := strconv.ParseFloat(fields[1], 64)
+			tx, _ := strconv.ParseFloat(fields[9], 64)
+			host.networkRx += rx
+			host.networkTx += tx
+		}
+	}
+
+	if total, free, err := diskUsage(diskPath); err == nil {
+		host.diskSize, host.hasDiskSize = float64(total), true
+		host.diskAvailable, host.hasDiskAvail = float64(free), true
+	}
+	return host, nil
+}
+
+// readProcFields returns the whitespace separated fields of each line of a
+// file under /proc. Interface names in net/dev are split from their counters.
+func readProcFields(name string) ([][]string, error) {
+	file, err := os.Open(filepath.Join(procRoot, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines [][]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if iface, counters, ok := strings.Cut(line, ":"); ok && name == "net/dev" {
+			line = strings.TrimSpace(iface) + ": " + counters
+		}
+		lines = append(lines, strings.Fields(line))
+	}
+	return lines, scanner.Err()
+}
+
+// localDiskPath is the working directory, or / when it is relative to a home
+// directory that may not be the manager's
+func localDiskPath(workingDir string) string {
+	if filepath.IsAbs(workingDir) {
+		if _, err := os.Stat(workingDir); err == nil {
+			return workingDir
+		}
+	}
+	return "/"
+}
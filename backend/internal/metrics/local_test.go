@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+func TestLocalMetrics(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"meminfo": "MemTotal:       16384000 kB\nMemFree:         1000000 kB\nMemAvailable:    4096000 kB\n",
+		"loadavg": "1.25 0.80 0.50 2/512 4242\n",
+		"stat":    "cpu  600 0 200 9000 100 0 100 0 0 0\ncpu0 300 0 100 4500 50 0 50 0 0 0\n",
+		"net/dev": "Inter-|   Receive                            |  Transmit\n" +
+			" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets\n" +
+			"    lo: 5000 10 0 0 0 0 0 0 5000 10 0 0 0 0 0 0\n" +
+			"  eth0:1000 10 0 0 0 0 0 0 2000 20 0 0 0 0 0 0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := procRoot
+	procRoot = root
+	defer func() { procRoot = previous }()
+
+	def := config.ServerDefinition{
+		Connection: config.ConnectionConfig{Host: "localhost"},
+		Server:     config.GameServerConfig{WorkingDirectory: root},
+	}
+	if !IsLocal(def) {
+		t.Fatalf("expected a localhost server to be read locally")
+	}
+	samples := NewCPUSamples()
+	metrics, err := LocalMetrics("alpha", def, samples)
+	if err != nil {
+		t.Fatalf("local metrics: %v", err)
+	}
+	if metrics["memory_total"] != int64(16384000*1024) || metrics["memory_used"] != int64((16384000-4096000)*1024) {
+		t.Errorf("unexpected memory %v / %v", metrics["memory_used"], metrics["memory_total"])
+	}
+	if metrics["load1"] != 1.25 || metrics["network_rx"] != int64(1000) || metrics["network_tx"] != int64(2000) {
+		t.Errorf("unexpected load or network in %v", metrics)
+	}
+	if _, ok := metrics["disk_total"]; !ok {
+		t.Errorf("expected the disk of the working directory in %v", metrics)
+	}
+
+	// 1000 more ticks, 500 of them idle
+	files["stat"] = "cpu  1000 0 300 9500 100 0 100 0 0 0\n"
+	if err := os.WriteFile(filepath.Join(root, "stat"), []byte(files["stat"]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	metrics, _ = LocalMetrics("alpha", def, samples)
+	if usage, ok := metrics["cpu_usage"].(float64); !ok || usage < 49.9 || usage > 50.1 {
+		t.Errorf("expected 50%% CPU usage, got %v", metrics["cpu_usage"])
+	}
+
+	def.Monitoring.NodeExporterURL = "http://localhost:9100/metrics"
+	if IsLocal(def) {
+		t.Errorf("expected a configured node exporter to be used")
+	}
+}
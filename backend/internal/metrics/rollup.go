@@ -0,0 +1,366 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+// rollupCheckInterval is how often the worker looks for an hour that ended
+const rollupCheckInterval = time.Minute
+
+// Rollup is the background worker that aggregates raw samples and enforces
+// retention. Once an hour it rolls the hours that ended into
+// server_metrics_hourly and server_usage_hourly, the days that ended into
+// server_metrics_daily, and then deletes raw, hourly and daily rows older
+// than their retention. It runs on the leading instance.
+type Rollup struct {
+	db       *database.DB
+	mu       sync.Mutex
+	settings config.MetricsConfig
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// NewRollup creates a rollup worker. Call Start to run it.
+func NewRollup(db *database.DB, settings config.MetricsConfig) *Rollup {
+	return &Rollup{db: db, settings: settings, stopCh: make(chan struct{})}
+}
+
+// SetConfig replaces the retention settings at runtime
+func (r *Rollup) SetConfig(settings config.MetricsConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = settings
+}
+
+func (r *Rollup) currentSettings() config.MetricsConfig {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.settings
+}
+
+// Start runs the worker until ctx is cancelled or Stop is called. The first
+// run happens right away, catching up on hours missed while no instance led.
+func (r *Rollup) Start(ctx context.Context) {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(rollupCheckInterval)
+		defer ticker.Stop()
+
+		var lastRun time.Time
+		run := func(now time.Time) {
+			hour := now.UTC().Truncate(time.Hour)
+			if hour.Equal(lastRun) {
+				return
+			}
+			if err := r.Run(ctx, now); err != nil {
+				logger.Warn("Failed to roll up metrics", "error", err)
+				return
+			}
+			lastRun = hour
+		}
+
+		run(time.Now())
+		for {
+			select {
+			case now := <-ticker.C:
+				run(now)
+			case <-ctx.Done():
+				return
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the worker
+func (r *Rollup) Stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	r.wg.Wait()
+}
+
+// Run rolls up and prunes once. Rows are rolled up before anything is
+// deleted, so raw samples always reach the rollups first.
+func (r *Rollup) Run(ctx context.Context, now time.Time) error {
+	if err := RollupUsage(ctx, r.db.DB, now); err != nil {
+		return fmt.Errorf("roll up usage: %w", err)
+	}
+	if err := rollupHours(ctx, r.db.DB, now); err != nil {
+		return fmt.Errorf("roll up hours: %w", err)
+	}
+	if err := rollupDays(ctx, r.db.DB, now); err != nil {
+		return fmt.Errorf("roll up days: %w", err)
+	}
+	return r.prune(ctx, now)
+}
+
+func (r *Rollup) prune(ctx context.Context, now time.Time) error {
+	settings := r.currentSettings()
+	days := func(n int) time.Time {
+		return now.UTC().Add(-time.Duration(n) * 24 * time.Hour)
+	}
+	var removed [4]int64
+	if settings.RetentionDays > 0 {
+		result, err := r.db.ExecContext(ctx, `DELETE FROM server_metrics WHERE timestamp < ?`, days(settings.RetentionDays).Format(TimestampFormat))
+		if err != nil {
+			return fmt.Errorf("prune raw samples: %w", err)
+		}
+		removed[0], _ = result.RowsAffected()
+		result, err = r.db.ExecContext(ctx, `DELETE FROM server_jvm_metrics WHERE timestamp < ?`, days(settings.RetentionDays).Format(TimestampFormat))
+		if err != nil {
+			return fmt.Errorf("prune jvm samples: %w", err)
+		}
+		removed[3], _ = result.RowsAffected()
+	}
+	if settings.HourlyRetentionDays > 0 {
+		result, err := r.db.ExecContext(ctx, `DELETE FROM server_metrics_hourly WHERE hour_timestamp < ?`, days(settings.HourlyRetentionDays).Truncate(time.Hour))
+		if err != nil {
+			return fmt.Errorf("prune hourly rollups: %w", err)
+		}
+		removed[1], _ = result.RowsAffected()
+	}
+	if settings.DailyRetentionDays > 0 {
+		result, err := r.db.ExecContext(ctx, `DELETE FROM server_metrics_daily WHERE date < ?`, days(settings.DailyRetentionDays).Truncate(24*time.Hour))
+		if err != nil {
+			return fmt.Errorf("prune daily rollups: %w", err)
+		}
+		removed[2], _ = result.RowsAffected()
+	}
+	if removed != [4]int64{} {
+		logger.Debug("Pruned metrics", "raw", removed[0], "hourly", removed[1], "daily", removed[2], "jvm", removed[3])
+	}
+	return nil
+}
+
+// metricsHour is one server's samples, or hourly rows, within one bucket
+type metricsHour struct {
+	serverID string
+	start    time.Time
+	samples  int64
+	online   int64
+	cpu      stat
+	cpuPeak  stat
+	memory   stat
+	memPeak  stat
+	players  stat
+	plPeak   stat
+	uptime   float64
+	restarts int64
+}
+
+// lastRolledUp returns the start of the newest row of a rollup table, or the
+// zero time when it is empty
+func lastRolledUp(ctx context.Context, db *sql.DB, query string) (time.Time, error) {
+	var start time.Time
+	err := db.QueryRowContext(ctx, query).Scan(&start)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return start.UTC(), err
+}
+
+// rollupHours folds the raw samples of the hours that ended into
+// server_metrics_hourly. The newest hour is redone to pick up samples that
+// were still queued when it was rolled up.
+func rollupHours(ctx context.Context, db *sql.DB, now time.Time) error {
+	from, err := lastRolledUp(ctx, db, `SELECT hour_timestamp FROM server_metrics_hourly ORDER BY hour_timestamp DESC LIMIT 1`)
+	if err != nil {
+		return err
+	}
+	query := `
+		SELECT server_id, timestamp, cpu_usage, memory_used, player_count, status
+		FROM server_metrics
+		WHERE timestamp < ?
+	`
+	args := []interface{}{now.UTC().Truncate(time.Hour).Format(TimestampFormat)}
+	if !from.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, from.Format(TimestampFormat))
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	buckets := make(map[string]*metricsHour)
+	var hours []*metricsHour
+	for rows.Next() {
+		var (
+			serverID             string
+			timestamp            time.Time
+			cpu, memory, players sql.NullFloat64
+			status               sql.NullString
+		)
+		if err := rows.Scan(&serverID, &timestamp, &cpu, &memory, &players, &status); err != nil {
+			return err
+		}
+		start := timestamp.UTC().Truncate(time.Hour)
+		key := serverID + "|" + start.Format(time.RFC3339)
+		h, ok := buckets[key]
+		if !ok {
+			h = &metricsHour{serverID: serverID, start: start}
+			buckets[key] = h
+			hours = append(hours, h)
+		}
+		h.samples++
+		if status.String == "online" {
+			h.online++
+		}
+		h.cpu.add(cpu)
+		h.memory.add(memory)
+		h.players.add(players)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, h := range hours {
+		uptime := int64(float64(h.online)/float64(h.samples)*60 + 0.5)
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO server_metrics_hourly (
+				server_id, hour_timestamp, avg_cpu_usage, max_cpu_usage, avg_memory_used, max_memory_used,
+				avg_player_count, max_player_count, uptime_minutes
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(server_id, hour_timestamp) DO UPDATE SET
+				avg_cpu_usage = excluded.avg_cpu_usage,
+				max_cpu_usage = excluded.max_cpu_usage,
+				avg_memory_used = excluded.avg_memory_used,
+				max_memory_used = excluded.max_memory_used,
+				avg_player_count = excluded.avg_player_count,
+				max_player_count = excluded.max_player_count,
+				uptime_minutes = excluded.uptime_minutes
+		`, h.serverID, h.start, h.cpu.value(), h.cpu.maximum(), h.memory.bytes(), h.memory.peak(),
+			h.players.value(), h.players.peak(), uptime); err != nil {
+			return fmt.Errorf("roll up %s at %s: %w", h.serverID, h.start.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
+
+// rollupDays folds the hourly rows of the days that ended, in UTC, into
+// server_metrics_daily, with the restarts logged on each day. The newest
+// day is redone like the newest hour.
+func rollupDays(ctx context.Context, db *sql.DB, now time.Time) error {
+	from, err := lastRolledUp(ctx, db, `SELECT date FROM server_metrics_daily ORDER BY date DESC LIMIT 1`)
+	if err != nil {
+		return err
+	}
+	to := now.UTC().Truncate(24 * time.Hour)
+
+	query := `
+		SELECT server_id, hour_timestamp, avg_cpu_usage, max_cpu_usage, avg_memory_used, max_memory_used,
+			avg_player_count, max_player_count, uptime_minutes
+		FROM server_metrics_hourly
+		WHERE hour_timestamp < ?
+	`
+	args := []interface{}{to}
+	if !from.IsZero() {
+		query += " AND hour_timestamp >= ?"
+		args = append(args, from)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	buckets := make(map[string]*metricsHour)
+	var days []*metricsHour
+	for rows.Next() {
+		var (
+			serverID                           string
+			hour                               time.Time
+			cpu, cpuMax, memory, memoryMax     sql.NullFloat64
+			players, playersMax, uptimeMinutes sql.NullFloat64
+		)
+		if err := rows.Scan(&serverID, &hour, &cpu, &cpuMax, &memory, &memoryMax, &players, &playersMax, &uptimeMinutes); err != nil {
+			return err
+		}
+		day := hour.UTC().Truncate(24 * time.Hour)
+		key := serverID + "|" + day.Format(time.RFC3339)
+		d, ok := buckets[key]
+		if !ok {
+			d = &metricsHour{serverID: serverID, start: day}
+			buckets[key] = d
+			days = append(days, d)
+		}
+		d.cpu.add(cpu)
+		d.cpuPeak.add(cpuMax)
+		d.memory.add(memory)
+		d.memPeak.add(memoryMax)
+		d.players.add(players)
+		d.plPeak.add(playersMax)
+		d.uptime += uptimeMinutes.Float64 / 60
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if err := countRestarts(ctx, db, buckets, from, to); err != nil {
+		return err
+	}
+	for _, d := range days {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO server_metrics_daily (
+				server_id, date, avg_cpu_usage, max_cpu_usage, avg_memory_used, max_memory_used,
+				avg_player_count, max_player_count, uptime_hours, total_restarts
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(server_id, date) DO UPDATE SET
+				avg_cpu_usage = excluded.avg_cpu_usage,
+				max_cpu_usage = excluded.max_cpu_usage,
+				avg_memory_used = excluded.avg_memory_used,
+				max_memory_used = excluded.max_memory_used,
+				avg_player_count = excluded.avg_player_count,
+				max_player_count = excluded.max_player_count,
+				uptime_hours = excluded.uptime_hours,
+				total_restarts = excluded.total_restarts
+		`, d.serverID, d.start, d.cpu.value(), d.cpuPeak.maximum(), d.memory.bytes(), d.memPeak.peak(),
+			d.players.value(), d.plPeak.peak(), d.uptime, d.restarts); err != nil {
+			return fmt.Errorf("roll up %s on %s: %w", d.serverID, d.start.Format(time.DateOnly), err)
+		}
+	}
+	return nil
+}
+
+// countRestarts adds the restarts in the activity log to the days being
+// rolled up
+func countRestarts(ctx context.Context, db *sql.DB, days map[string]*metricsHour, from, to time.Time) error {
+	query := `SELECT server_id, timestamp FROM activity_log WHERE activity_type = ? AND server_id IS NOT NULL AND timestamp < ?`
+	args := []interface{}{logging.ActivityServerRestart, to.Format(TimestampFormat)}
+	if !from.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, from.Format(TimestampFormat))
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("count restarts: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			serverID  string
+			timestamp time.Time
+		)
+		if err := rows.Scan(&serverID, &timestamp); err != nil {
+			return fmt.Errorf("count restarts: %w", err)
+		}
+		if d, ok := days[serverID+"|"+timestamp.UTC().Truncate(24*time.Hour).Format(time.RFC3339)]; ok {
+			d.restarts++
+		}
+	}
+	return rows.Err()
+}
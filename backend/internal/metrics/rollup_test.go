@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+func TestRollupAggregatesAndPrunes(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "rollup.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	sample := func(at time.Time, cpu float64, memory int64, status string) {
+		t.Helper()
+		if _, err := db.Exec(`
+			INSERT INTO server_metrics (server_id, timestamp, cpu_usage, memory_used, player_count, status)
+			VALUES ('alpha', ?, ?, ?, 2, ?)
+		`, at.Format(TimestampFormat), cpu, memory, status); err != nil {
+			t.Fatalf("insert sample: %v", err)
+		}
+	}
+	restart := func(at time.Time) {
+		t.Helper()
+		if _, err := db.Exec(`INSERT INTO activity_log (timestamp, server_id, activity_type) VALUES (?, 'alpha', ?)`,
+			at.Format(TimestampFormat), logging.ActivityServerRestart); err != nil {
+			t.Fatalf("insert restart: %v", err)
+		}
+	}
+	yesterday := now.Truncate(24 * time.Hour).Add(-24 * time.Hour)
+	// Yesterday 10:00 has one online and one offline sample, 11:00 one online
+	sample(yesterday.Add(10*time.Hour), 40, 1000, "online")
+	sample(yesterday.Add(10*time.Hour+30*time.Minute), 60, 3000, "offline")
+	sample(yesterday.Add(11*time.Hour), 80, 2000, "online")
+	restart(yesterday.Add(10*time.Hour + 20*time.Minute))
+	restart(yesterday.Add(11*time.Hour + 5*time.Minute))
+	// The current hour is not rolled up yet
+	sample(now.Add(-10*time.Minute), 20, 4000, "online")
+
+	ctx := context.Background()
+	rollup := NewRollup(db, config.MetricsConfig{RetentionDays: 2, HourlyRetentionDays: 30, DailyRetentionDays: 365})
+	if err := rollup.Run(ctx, now); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var hours int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM server_metrics_hourly`).Scan(&hours); err != nil || hours != 2 {
+		t.Fatalf("expected two hourly rows, got %d (%v)", hours, err)
+	}
+	var (
+		avgCPU, maxCPU float64
+		maxMemory      int64
+		uptime         int64
+	)
+	if err := db.QueryRow(`
+		SELECT avg_cpu_usage, max_cpu_usage, max_memory_used, uptime_minutes
+		FROM server_metrics_hourly WHERE hour_timestamp = ?
+	`, yesterday.Add(10*time.Hour)).Scan(&avgCPU, &maxCPU, &maxMemory, &uptime); err != nil {
+		t.Fatalf("load hourly row: %v", err)
+	}
+	if avgCPU != 50 || maxCPU != 60 || maxMemory != 3000 || uptime != 30 {
+		t.Fatalf("unexpected hourly row: avg %v max %v memory %d uptime %d", avgCPU, maxCPU, maxMemory, uptime)
+	}
+
+	var (
+		day        time.Time
+		uptimeDay  float64
+		restarts   int64
+		dailyCount int
+	)
+	if err := db.QueryRow(`SELECT date, max_cpu_usage, uptime_hours, total_restarts FROM server_metrics_daily`).
+		Scan(&day, &maxCPU, &uptimeDay, &restarts); err != nil {
+		t.Fatalf("load daily row: %v", err)
+	}
+	if !day.Equal(yesterday) || maxCPU != 80 || math.Abs(uptimeDay-1.5) > 0.001 || restarts != 2 {
+		t.Fatalf("unexpected daily row: %v max %v uptime %v restarts %d", day, maxCPU, uptimeDay, restarts)
+	}
+
+	// Running again the next day updates the rows in place and
+	// prunes the raw samples past their retention
+	if err := rollup.Run(ctx, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM server_metrics_daily WHERE date = ?`, yesterday).Scan(&dailyCount); err != nil || dailyCount != 1 {
+		t.Fatalf("expected one daily row for yesterday, got %d (%v)", dailyCount, err)
+	}
+	var raw int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM server_metrics`).Scan(&raw); err != nil || raw != 1 {
+		t.Fatalf("expected only the newest raw sample to remain, got %d (%v)", raw, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM server_metrics_hourly`).Scan(&hours); err != nil || hours != 3 {
+		t.Fatalf("expected the hourly rows to be kept, got %d (%v)", hours, err)
+	}
+
+	// Shorter hourly retention removes the rolled-up hours but not the days
+	rollup.SetConfig(config.MetricsConfig{RetentionDays: 2, HourlyRetentionDays: 1, DailyRetentionDays: 365})
+	if err := rollup.Run(ctx, now.Add(24*time.Hour)); err != nil {
+		t.Fatalf("third run: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM server_metrics_hourly`).Scan(&hours); err != nil || hours != 1 {
+		t.Fatalf("expected one recent hourly row, got %d (%v)", hours, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM server_metrics_daily`).Scan(&dailyCount); err != nil || dailyCount != 2 {
+		t.Fatalf("expected both daily rows to be kept, got %d (%v)", dailyCount, err)
+	}
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// Resolutions a series is read at, from the table holding it
+const (
+	ResolutionRaw    = "raw"
+	ResolutionHourly = "hourly"
+	ResolutionDaily  = "daily"
+)
+
+// Longest ranges read from the raw samples and from the hourly rows; longer
+// ones come from the next table, which keeps a series to a few thousand rows
+const (
+	maxRawRange    = 48 * time.Hour
+	maxHourlyRange = 31 * 24 * time.Hour
+)
+
+// seriesColumn is where a series metric is read from at each resolution,
+// and whether buckets keep the average or the peak of their values
+type seriesColumn struct {
+	raw, rollup string
+	peak        bool
+}
+
+var seriesColumns = map[string]seriesColumn{
+	"cpu_usage":        {raw: "cpu_usage", rollup: "avg_cpu_usage"},
+	"cpu_usage_max":    {raw: "cpu_usage", rollup: "max_cpu_usage", peak: true},
+	"memory_used":      {raw: "memory_used", rollup: "avg_memory_used"},
+	"memory_used_max":  {raw: "memory_used", rollup: "max_memory_used", peak: true},
+	"player_count":     {raw: "player_count", rollup: "avg_player_count"},
+	"player_count_max": {raw: "player_count", rollup: "max_player_count", peak: true},
+}
+
+// ErrUnknownMetric is returned for a metric QuerySeries does not serve
+var ErrUnknownMetric = errors.New("unknown metric")
+
+// SeriesMetrics returns the metrics QuerySeries serves, sorted
+func SeriesMetrics() []string {
+	names := make([]string, 0, len(seriesColumns))
+	for name := range seriesColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SeriesQuery selects one metric of some servers over [From, To)
+type SeriesQuery struct {
+	Metric    string
+	ServerIDs []string // all servers when empty
+	From, To  time.Time
+	// Step is the width of the buckets points are averaged (or, for _max
+	// metrics, maxed) into. It is never narrower than the resolution.
+	Step time.Duration
+}
+
+// Point is one value of a series
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// Series is one server's points for a metric, oldest first
+type Series struct {
+	ServerID   string  `json:"server_id"`
+	Metric     string  `json:"metric"`
+	Resolution string  `json:"resolution"`
+	Points     []Point `json:"points"`
+}
+
+// ChooseResolution picks the table to read [from, to) from: raw samples for
+// up to two days, hourly rows for up to a month, daily rows beyond that. A
+// range starting before a table's retention moves on to the next table.
+func ChooseResolution(from, to, now time.Time, settings config.MetricsConfig) string {
+	span := to.Sub(from)
+	kept := func(days int) bool {
+		return days <= 0 || !from.Before(now.Add(-time.Duration(days)*24*time.Hour))
+	}
+	switch {
+	case span <= maxRawRange && kept(settings.RetentionDays):
+		return ResolutionRaw
+	case span <= maxHourlyRange && kept(settings.HourlyRetentionDays):
+		return ResolutionHourly
+	default:
+		return ResolutionDaily
+	}
+}
+
+// QuerySeries reads a metric at resolution, one series per server, sorted
+// by server ID
+func QuerySeries(ctx context.Context, db *sql.DB, query SeriesQuery, resolution string) ([]Series, error) {
+	column, ok := seriesColumns[query.Metric]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMetric, query.Metric)
+	}
+
+	var (
+		sqlQuery string
+		args     []interface{}
+		minStep  time.Duration
+	)
+	switch resolution {
+	case ResolutionRaw:
+		sqlQuery = `SELECT server_id, timestamp, ` + column.raw + ` FROM server_metrics WHERE timestamp >= ? AND timestamp < ?`
+		args = []interface{}{query.From.UTC().Format(TimestampFormat), query.To.UTC().Format(TimestampFormat)}
+	case ResolutionHourly:
+		sqlQuery = `SELECT server_id, hour_timestamp, ` + column.rollup + ` FROM server_metrics_hourly WHERE hour_timestamp >= ? AND hour_timestamp < ?`
+		args = []interface{}{query.From.UTC().Truncate(time.Hour), query.To.UTC()}
+		minStep = time.Hour
+	case ResolutionDaily:
+		sqlQuery = `SELECT server_id, date, ` + column.rollup + ` FROM server_metrics_daily WHERE date >= ? AND date < ?`
+		args = []interface{}{query.From.UTC().Truncate(24 * time.Hour), query.To.UTC()}
+		minStep = 24 * time.Hour
+	default:
+		return nil, fmt.Errorf("unknown resolution %q", resolution)
+	}
+	if len(query.ServerIDs) > 0 {
+		sqlQuery += " AND server_id IN (?" + strings.Repeat(", ?", len(query.ServerIDs)-1) + ")"
+		for _, id := range query.ServerIDs {
+			args = append(args, id)
+		}
+	}
+	step := query.Step
+	if step < minStep {
+		step = minStep
+	}
+
+	rows, err := db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type bucket struct {
+		stat
+		start time.Time
+	}
+	buckets := make(map[string]map[int64]*bucket)
+	for rows.Next() {
+		var (
+			serverID string
+			at       time.Time
+			value    sql.NullFloat64
+		)
+		if err := rows.Scan(&serverID, &at, &value); err != nil {
+			return nil, err
+		}
+		if !value.Valid {
+			continue
+		}
+		start := at.UTC()
+		if step > 0 {
+			start = start.Truncate(step)
+		}
+		if buckets[serverID] == nil {
+			buckets[serverID] = make(map[int64]*bucket)
+		}
+		b, ok := buckets[serverID][start.Unix()]
+		if !ok {
+			b = &bucket{start: start}
+			buckets[serverID][start.Unix()] = b
+		}
+		b.add(value)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	series := make([]Series, 0, len(buckets))
+	for serverID, byStart := range buckets {
+		points := make([]Point, 0, len(byStart))
+		for _, b := range byStart {
+			value := b.sum / float64(b.n)
+			if column.peak {
+				value = b.max
+			}
+			points = append(points, Point{Time: b.start, Value: value})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		series = append(series, Series{ServerID: serverID, Metric: query.Metric, Resolution: resolution, Points: points})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].ServerID < series[j].ServerID })
+	return series, nil
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestChooseResolution(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	settings := config.MetricsConfig{RetentionDays: 2, HourlyRetentionDays: 30, DailyRetentionDays: 365}
+	cases := []struct {
+		from, to time.Time
+		want     string
+	}{
+		{now.Add(-6 * time.Hour), now, ResolutionRaw},
+		{now.Add(-7 * 24 * time.Hour), now, ResolutionHourly},
+		// A short range past the raw retention reads the hourly rollups
+		{now.Add(-5 * 24 * time.Hour), now.Add(-4 * 24 * time.Hour), ResolutionHourly},
+		{now.Add(-90 * 24 * time.Hour), now, ResolutionDaily},
+		{now.Add(-40 * 24 * time.Hour), now.Add(-39 * 24 * time.Hour), ResolutionDaily},
+	}
+	for _, tc := range cases {
+		if got := ChooseResolution(tc.from, tc.to, now, settings); got != tc.want {
+			t.Fatalf("%s to %s: expected %s, got %s", tc.from, tc.to, tc.want, got)
+		}
+	}
+}
+
+func TestQuerySeriesDownsamples(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "series.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	start := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i, cpu := range []float64{10, 30, 50, 90} {
+		for _, server := range []string{"alpha", "beta"} {
+			if _, err := db.Exec(`INSERT INTO server_metrics (server_id, timestamp, cpu_usage) VALUES (?, ?, ?)`,
+				server, start.Add(time.Duration(i)*5*time.Minute).Format(TimestampFormat), cpu); err != nil {
+				t.Fatalf("insert sample: %v", err)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	query := SeriesQuery{Metric: "cpu_usage", ServerIDs: []string{"alpha"}, From: start, To: start.Add(time.Hour), Step: 10 * time.Minute}
+	series, err := QuerySeries(ctx, db.DB, query, ResolutionRaw)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(series) != 1 || series[0].ServerID != "alpha" || len(series[0].Points) != 2 {
+		t.Fatalf("expected two ten-minute points for alpha, got %+v", series)
+	}
+	if series[0].Points[0].Value != 20 || series[0].Points[1].Value != 70 || !series[0].Points[1].Time.Equal(start.Add(10*time.Minute)) {
+		t.Fatalf("expected averaged buckets, got %+v", series[0].Points)
+	}
+
+	query.Metric, query.ServerIDs = "cpu_usage_max", nil
+	series, err = QuerySeries(ctx, db.DB, query, ResolutionRaw)
+	if err != nil || len(series) != 2 || series[1].ServerID != "beta" || series[1].Points[1].Value != 90 {
+		t.Fatalf("expected peak buckets for both servers, got %+v (%v)", series, err)
+	}
+
+	// Hourly rows are read once rolled up, and never in buckets under an hour
+	if err := rollupHours(ctx, db.DB, start.Add(2*time.Hour)); err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	query.Metric = "cpu_usage"
+	series, err = QuerySeries(ctx, db.DB, query, ResolutionHourly)
+	if err != nil || len(series) != 2 || len(series[0].Points) != 1 || series[0].Points[0].Value != 45 {
+		t.Fatalf("expected one hourly point per server, got %+v (%v)", series, err)
+	}
+
+	query.Metric = "fan_speed"
+	if _, err := QuerySeries(ctx, db.DB, query, ResolutionRaw); !errors.Is(err, ErrUnknownMetric) {
+		t.Fatalf("expected ErrUnknownMetric, got %v", err)
+	}
+}
@@ -0,0 +1,349 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimestampFormat matches the CURRENT_TIMESTAMP text server_metrics rows are
+// written with, so range filters compare like with like
+const TimestampFormat = "2006-01-02 15:04:05"
+
+// Usage is what one server's host used over a report period, as measured by
+// node_exporter. CPUHours counts whole-host CPU: one hour at 100% is one
+// CPU-hour.
+type Usage struct {
+	ServerID           string  `json:"server_id"`
+	MonitoredHours     float64 `json:"monitored_hours"`
+	CPUHours           float64 `json:"cpu_hours"`
+	AvgCPUPercent      float64 `json:"avg_cpu_percent"`
+	AvgMemoryBytes     int64   `json:"avg_memory_bytes"`
+	PeakMemoryBytes    int64   `json:"peak_memory_bytes"`
+	AvgDiskBytes       int64   `json:"avg_disk_bytes"`
+	PeakDiskBytes      int64   `json:"peak_disk_bytes"`
+	BackupCount        int     `json:"backup_count"`
+	BackupBytes        int64   `json:"backup_bytes"`
+	BackupBytesCreated int64   `json:"backup_bytes_created"`
+}
+
+// stat averages one metric over the samples that have it
+type stat struct {
+	sum float64
+	n   int64
+	max float64
+}
+
+func (s *stat) add(value sql.NullFloat64) {
+	if !value.Valid {
+		return
+	}
+	s.sum += value.Float64
+	s.n++
+	if value.Float64 > s.max {
+		s.max = value.Float64
+	}
+}
+
+// value returns the average, or nil when no sample had the metric
+func (s stat) value() interface{} {
+	if s.n == 0 {
+		return nil
+	}
+	return s.sum / float64(s.n)
+}
+
+// bytes returns the average rounded to whole bytes
+func (s stat) bytes() interface{} {
+	if s.n == 0 {
+		return nil
+	}
+	return int64(s.sum/float64(s.n) + 0.5)
+}
+
+func (s stat) peak() interface{} {
+	if s.n == 0 {
+		return nil
+	}
+	return int64(s.max)
+}
+
+// maximum returns the largest value, or nil when no sample had the metric
+func (s stat) maximum() interface{} {
+	if s.n == 0 {
+		return nil
+	}
+	return s.max
+}
+
+// hourUsage is one server's samples within one hour
+type hourUsage struct {
+	serverID string
+	hour     time.Time
+	samples  int64
+	cpu      stat
+	memory   stat
+	disk     stat
+}
+
+// rawHours groups the raw samples taken in [from, to) by server and hour
+func rawHours(ctx context.Context, db *sql.DB, from, to time.Time) ([]*hourUsage, error) {
+	query := `
+		SELECT server_id, timestamp, cpu_usage, memory_used, disk_used
+		FROM server_metrics
+		WHERE timestamp < ?
+	`
+	args := []interface{}{to.UTC().Format(TimestampFormat)}
+	if !from.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, from.UTC().Format(TimestampFormat))
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make(map[string]*hourUsage)
+	var hours []*hourUsage
+	for rows.Next() {
+		var (
+			serverID          string
+			timestamp         time.Time
+			cpu, memory, disk sql.NullFloat64
+		)
+		if err := rows.Scan(&serverID, &timestamp, &cpu, &memory, &disk); err != nil {
+			return nil, err
+		}
+		hour := timestamp.UTC().Truncate(time.Hour)
+		key := serverID + "|" + hour.Format(time.RFC3339)
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &hourUsage{serverID: serverID, hour: hour}
+			buckets[key] = bucket
+			hours = append(hours, bucket)
+		}
+		bucket.samples++
+		bucket.cpu.add(cpu)
+		bucket.memory.add(memory)
+		bucket.disk.add(disk)
+	}
+	return hours, rows.Err()
+}
+
+// rolledUpTo returns the end of the last hour in server_usage_hourly, or
+// the zero time when nothing has been rolled up
+func rolledUpTo(ctx context.Context, db *sql.DB) (time.Time, error) {
+	var hour time.Time
+	err := db.QueryRowContext(ctx, `SELECT hour FROM server_usage_hourly ORDER BY hour DESC LIMIT 1`).Scan(&hour)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return hour.UTC().Add(time.Hour), nil
+}
+
+// RollupUsage folds the raw samples of the hours that have ended into
+// server_usage_hourly, so usage outlives the metrics retention. The last
+// rolled-up hour is redone to pick up samples that were still queued.
+func RollupUsage(ctx context.Context, db *sql.DB, now time.Time) error {
+	from, err := rolledUpTo(ctx, db)
+	if err != nil {
+		return err
+	}
+	if !from.IsZero() {
+		from = from.Add(-time.Hour)
+	}
+	hours, err := rawHours(ctx, db, from, now.UTC().Truncate(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hours {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO server_usage_hourly (server_id, hour, samples, avg_cpu_usage, avg_memory_used, max_memory_used, avg_disk_used, max_disk_used)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(server_id, hour) DO UPDATE SET
+				samples = excluded.samples,
+				avg_cpu_usage = excluded.avg_cpu_usage,
+				avg_memory_used = excluded.avg_memory_used,
+				max_memory_used = excluded.max_memory_used,
+				avg_disk_used = excluded.avg_disk_used,
+				max_disk_used = excluded.max_disk_used
+		`, h.serverID, h.hour, h.samples, h.cpu.value(), h.memory.bytes(), h.memory.peak(), h.disk.bytes(), h.disk.peak()); err != nil {
+			return fmt.Errorf("roll up %s at %s: %w", h.serverID, h.hour.Format(time.RFC3339), err)
+		}
+	}
+	return nil
+}
+
+// UsageReport sums up each server's usage between from and to. Hours that
+// have been rolled up come from server_usage_hourly and later ones from the
+// raw samples; an hour counts for the part of it inside the period and
+// before now.
+func UsageReport(ctx context.Context, db *sql.DB, from, to, now time.Time) (map[string]*Usage, error) {
+	from, to = from.UTC(), to.UTC()
+	if now.Before(to) {
+		to = now.UTC()
+	}
+	report := make(map[string]*Usage)
+	if !to.After(from) {
+		return report, nil
+	}
+
+	rolled, err := rolledUpTo(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var hours []*hourUsage
+	if rolled.After(from) {
+		rows, err := db.QueryContext(ctx, `
+			SELECT server_id, hour, samples, avg_cpu_usage, avg_memory_used, max_memory_used, avg_disk_used, max_disk_used
+			FROM server_usage_hourly
+			WHERE hour >= ? AND hour < ?
+		`, from.Truncate(time.Hour), minTime(to, rolled))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			h := &hourUsage{}
+			var cpu, avgMemory, maxMemory, avgDisk, maxDisk sql.NullFloat64
+			if err := rows.Scan(&h.serverID, &h.hour, &h.samples, &cpu, &avgMemory, &maxMemory, &avgDisk, &maxDisk); err != nil {
+				return nil, err
+			}
+			h.hour = h.hour.UTC()
+			h.cpu = hourStat(cpu, cpu)
+			h.memory = hourStat(avgMemory, maxMemory)
+			h.disk = hourStat(avgDisk, maxDisk)
+			hours = append(hours, h)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if to.After(rolled) {
+		raw, err := rawHours(ctx, db, maxTime(from.Truncate(time.Hour), rolled), to)
+		if err != nil {
+			return nil, err
+		}
+		hours = append(hours, raw...)
+	}
+
+	type weighted struct{ memory, memoryHours, disk, diskHours, cpu, cpuHours float64 }
+	sums := make(map[string]*weighted)
+	for _, h := range hours {
+		weight := minTime(h.hour.Add(time.Hour), to).Sub(maxTime(h.hour, from)).Hours()
+		if weight <= 0 {
+			continue
+		}
+		usage, ok := report[h.serverID]
+		if !ok {
+			usage = &Usage{ServerID: h.serverID}
+			report[h.serverID] = usage
+			sums[h.serverID] = &weighted{}
+		}
+		sum := sums[h.serverID]
+		usage.MonitoredHours += weight
+		if cpu, ok := h.cpu.value().(float64); ok {
+			usage.CPUHours += cpu / 100 * weight
+			sum.cpu += cpu * weight
+			sum.cpuHours += weight
+		}
+		if memory, ok := h.memory.value().(float64); ok {
+			sum.memory += memory * weight
+			sum.memoryHours += weight
+			usage.PeakMemoryBytes = max(usage.PeakMemoryBytes, int64(h.memory.max))
+		}
+		if disk, ok := h.disk.value().(float64); ok {
+			sum.disk += disk * weight
+			sum.diskHours += weight
+			usage.PeakDiskBytes = max(usage.PeakDiskBytes, int64(h.disk.max))
+		}
+	}
+	for serverID, sum := range sums {
+		usage := report[serverID]
+		if sum.cpuHours > 0 {
+			usage.AvgCPUPercent = sum.cpu / sum.cpuHours
+		}
+		if sum.memoryHours > 0 {
+			usage.AvgMemoryBytes = int64(sum.memory / sum.memoryHours)
+		}
+		if sum.diskHours > 0 {
+			usage.AvgDiskBytes = int64(sum.disk / sum.diskHours)
+		}
+	}
+
+	if err := addBackupUsage(ctx, db, report, from, to); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// addBackupUsage adds the backups still stored that were taken before to,
+// and the size of all backups taken within the period
+func addBackupUsage(ctx context.Context, db *sql.DB, report map[string]*Usage, from, to time.Time) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT server_id, status, size_bytes, created_at
+		FROM backups
+		WHERE status IN ('completed', 'deleted') AND created_at < ?
+	`, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			serverID, status string
+			size             int64
+			createdAt        time.Time
+		)
+		if err := rows.Scan(&serverID, &status, &size, &createdAt); err != nil {
+			return err
+		}
+		created := !createdAt.Before(from)
+		if status != "completed" && !created {
+			continue
+		}
+		usage, ok := report[serverID]
+		if !ok {
+			usage = &Usage{ServerID: serverID}
+			report[serverID] = usage
+		}
+		if status == "completed" {
+			usage.BackupCount++
+			usage.BackupBytes += size
+		}
+		if created {
+			usage.BackupBytesCreated += size
+		}
+	}
+	return rows.Err()
+}
+
+// hourStat rebuilds a stat from a rolled-up hour
+func hourStat(avg, peak sql.NullFloat64) stat {
+	if !avg.Valid {
+		return stat{}
+	}
+	return stat{sum: avg.Float64, n: 1, max: peak.Float64}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
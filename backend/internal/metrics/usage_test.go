@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestUsageReportOutlivesRawSamples(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "usage.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	now := time.Date(2026, 3, 10, 12, 30, 0, 0, time.UTC)
+	sample := func(at time.Time, cpu float64, memory, disk int64) {
+		t.Helper()
+		if _, err := db.Exec(`
+			INSERT INTO server_metrics (server_id, timestamp, cpu_usage, memory_used, disk_used, status)
+			VALUES ('alpha', ?, ?, ?, ?, 'online')
+		`, at.Format(TimestampFormat), cpu, memory, disk); err != nil {
+			t.Fatalf("insert sample: %v", err)
+		}
+	}
+	// Two full hours at 50% and 100% CPU, then half of the current hour at 20%
+	sample(now.Add(-150*time.Minute), 40, 1000, 5000)
+	sample(now.Add(-140*time.Minute), 60, 3000, 5000)
+	sample(now.Add(-90*time.Minute), 100, 2000, 7000)
+	sample(now.Add(-10*time.Minute), 20, 4000, 7000)
+
+	ctx := context.Background()
+	if err := RollupUsage(ctx, db.DB, now); err != nil {
+		t.Fatalf("rollup: %v", err)
+	}
+	// The raw samples of the rolled-up hours expire
+	if _, err := db.Exec(`DELETE FROM server_metrics WHERE timestamp < ?`, now.Truncate(time.Hour).Format(TimestampFormat)); err != nil {
+		t.Fatalf("expire samples: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO backups (id, server_id, filename, size_bytes, created_at, destination_type, destination_path, status)
+		VALUES ('old', 'alpha', 'old.tar.gz', 100, ?, 'local', '/backups', 'completed'),
+		       ('new', 'alpha', 'new.tar.gz', 200, ?, 'local', '/backups', 'completed'),
+		       ('gone', 'alpha', 'gone.tar.gz', 400, ?, 'local', '/backups', 'deleted')
+	`, now.Add(-48*time.Hour), now.Add(-time.Hour), now.Add(-time.Hour)); err != nil {
+		t.Fatalf("insert backups: %v", err)
+	}
+
+	report, err := UsageReport(ctx, db.DB, now.Add(-24*time.Hour), now.Add(time.Hour), now)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	usage := report["alpha"]
+	if usage == nil {
+		t.Fatalf("expected usage for alpha, got %v", report)
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 0.001 }
+	if !near(usage.MonitoredHours, 2.5) || !near(usage.CPUHours, 0.5+1+0.1) {
+		t.Fatalf("unexpected hours %+v", usage)
+	}
+	if usage.PeakMemoryBytes != 4000 || usage.PeakDiskBytes != 7000 {
+		t.Fatalf("unexpected peaks %+v", usage)
+	}
+	// Memory averages over time: 2000 for an hour, 2000 for an hour, 4000 for half an hour
+	if usage.AvgMemoryBytes != 2400 {
+		t.Fatalf("expected a time-weighted memory average of 2400, got %d", usage.AvgMemoryBytes)
+	}
+	if usage.BackupCount != 2 || usage.BackupBytes != 300 || usage.BackupBytesCreated != 600 {
+		t.Fatalf("unexpected backup usage %+v", usage)
+	}
+
+	// Rolling up again changes nothing
+	if err := RollupUsage(ctx, db.DB, now); err != nil {
+		t.Fatalf("second rollup: %v", err)
+	}
+	again, err := UsageReport(ctx, db.DB, now.Add(-24*time.Hour), now, now)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if !near(again["alpha"].CPUHours, usage.CPUHours) {
+		t.Fatalf("expected the same CPU-hours after a second rollup, got %v", again["alpha"].CPUHours)
+	}
+}
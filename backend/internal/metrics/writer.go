@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("metrics")
+
+const (
+	writerQueueSize     = 4096
+	writerBatchSize     = 256
+	writerFlushInterval = time.Second
+)
+
+// Sample is one server_metrics row waiting to be written
+type Sample struct {
+	ServerID string
+	Values   map[string]interface{}
+	Status   string
+}
+
+// Writer queues server_metrics rows and writes them in batches, one
+// transaction per batch, so polling many servers costs one SQLite commit per
+// second instead of one per server.
+type Writer struct {
+	db        *database.DB
+	queue     chan Sample
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+	stopOnce  sync.Once
+	mu        sync.Mutex
+	listeners []func()
+	recorded  []func(Sample)
+}
+
+// NewWriter creates a writer. Call Start to begin flushing.
+func NewWriter(db *database.DB) *Writer {
+	return &Writer{
+		db:     db,
+		queue:  make(chan Sample, writerQueueSize),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start runs the flush loop
+func (w *Writer) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(writerFlushInterval)
+		defer ticker.Stop()
+
+		batch := make([]Sample, 0, writerBatchSize)
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			if err := w.writeBatch(batch); err != nil {
+				logger.Error("Failed to write metrics batch", "rows", len(batch), "error", err)
+			}
+			batch = batch[:0]
+		}
+
+		for {
+			select {
+			case sample := <-w.queue:
+				batch = append(batch, sample)
+				if len(batch) >= writerBatchSize {
+					flush()
+				}
+			case <-ticker.C:
+				flush()
+			case <-w.stopCh:
+				for {
+					select {
+					case sample := <-w.queue:
+						batch = append(batch, sample)
+					default:
+						flush()
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// Stop writes anything still queued and ends the flush loop
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+	w.wg.Wait()
+}
+
+// OnFlush registers fn to be called after each batch is committed
+func (w *Writer) OnFlush(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// OnRecord registers fn to be called with every sample as it is queued. It
+// runs on the caller of Record, so it must not block.
+func (w *Writer) OnRecord(fn func(Sample)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.recorded = append(w.recorded, fn)
+}
+
+// Record queues a row. When the queue is full the row is written directly so
+// samples are never dropped.
+func (w *Writer) Record(serverID string, values map[string]interface{}, status string) error {
+	if w == nil || w.db == nil {
+		return nil
+	}
+	sample := Sample{ServerID: serverID, Values: values, Status: status}
+	w.mu.Lock()
+	recorded := w.recorded
+	w.mu.Unlock()
+	for _, fn := range recorded {
+		fn(sample)
+	}
+	select {
+	case w.queue <- sample:
+		return nil
+	default:
+		logger.Warn("Metrics write queue full, writing directly", "server_id", serverID)
+		return w.writeBatch([]Sample{sample})
+	}
+}
+
+func (w *Writer) writeBatch(batch []Sample) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO server_metrics (
+			server_id, cpu_usage, memory_used, memory_total, disk_used, disk_total, network_rx, network_tx, status
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, sample := range batch {
+		if _, err := stmt.Exec(
+			sample.ServerID,
+			sample.Values["cpu_usage"],
+			sample.Values["memory_used"],
+			sample.Values["memory_total"],
+			sample.Values["disk_used"],
+			sample.Values["disk_total"],
+			sample.Values["network_rx"],
+			sample.Values["network_tx"],
+			sample.Status,
+		); err != nil {
+			return fmt.Errorf("insert %s: %w", sample.ServerID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	w.mu.Lock()
+	listeners := append([]func(){}, w.listeners...)
+	w.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestWriterFlushesQueuedRowsOnStop(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "metrics.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	writer := NewWriter(db)
+	flushes := 0
+	writer.OnFlush(func() { flushes++ })
+	writer.Start()
+
+	for i := 0; i < 300; i++ {
+		if err := writer.Record("alpha", map[string]interface{}{"cpu_usage": float64(i)}, "online"); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+	writer.Stop()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM server_metrics WHERE server_id = ?", "alpha").Scan(&count); err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	if count != 300 {
+		t.Fatalf("expected 300 rows, got %d", count)
+	}
+	if flushes == 0 || flushes > 3 {
+		t.Fatalf("expected rows to be written in a few batches, got %d flushes", flushes)
+	}
+}
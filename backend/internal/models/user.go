@@ -3,7 +3,7 @@ package models
 import (
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
 )
 
 // User represents a system user
@@ -20,8 +20,8 @@ type User struct {
 }
 
 // NewUser creates a new user with hashed password
-func NewUser(username, email, password, fullName string, bcryptCost int) (*User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+func NewUser(username, email, password, fullName string, passwords auth.PasswordHasher) (*User, error) {
+	hashedPassword, err := passwords.Hash(password)
 	if err != nil {
 		return nil, err
 	}
@@ -30,7 +30,7 @@ func NewUser(username, email, password, fullName string, bcryptCost int) (*User,
 	return &User{
 		Username:     username,
 		Email:        email,
-		PasswordHash: string(hashedPassword),
+		PasswordHash: hashedPassword,
 		FullName:     fullName,
 		IsActive:     true,
 		CreatedAt:    now,
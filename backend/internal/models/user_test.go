@@ -4,10 +4,12 @@ import (
 	"testing"
 
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
 )
 
 func TestNewUserCreatesHash(t *testing.T) {
-	user, err := NewUser("test", "test@example.com", "secret", "Tester", bcrypt.DefaultCost)
+	user, err := NewUser("test", "test@example.com", "secret", "Tester", auth.PasswordHasher{BcryptCost: bcrypt.DefaultCost})
 	if err != nil {
 		t.Fatalf("failed to create user: %v", err)
 	}
@@ -0,0 +1,209 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sendTimeout       = 10 * time.Second
+	deliveryRetention = 30 * 24 * time.Hour
+	pruneInterval     = time.Hour
+	maxErrorBody      = 512
+)
+
+// Mailer sends email; *notify.SMTPSender implements it
+type Mailer interface {
+	Enabled() bool
+	Send(to []string, subject, body string) error
+}
+
+// Dispatcher matches events against the stored rules and sends them to the
+// rules' channels. A rule notifies at most once per cooldown for each server;
+// cooldowns are kept in memory, so a restart resets them.
+type Dispatcher struct {
+	store  *Store
+	mailer Mailer
+	client *http.Client
+
+	mu        sync.Mutex
+	lastSent  map[string]time.Time
+	lastPrune time.Time
+	wg        sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for the rules in store. mailer may be
+// nil, in which case email channels fail.
+func NewDispatcher(store *Store, mailer Mailer) *Dispatcher {
+	return &Dispatcher{
+		store:    store,
+		mailer:   mailer,
+		client:   &http.Client{Timeout: sendTimeout},
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// Notify sends event in the background to the channels of every rule it
+// matches. A nil Dispatcher sends nothing.
+func (d *Dispatcher) Notify(event Event) {
+	if d == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.dispatch(context.Background(), event)
+	}()
+}
+
+// Wait blocks until the notifications in flight are sent
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, event Event) {
+	rules, err := d.store.ListRules(ctx)
+	if err != nil {
+		logger.Error("Failed to load notification rules", "event", event.Type, "error", err)
+		return
+	}
+
+	channels := make(map[string]*Channel)
+	for _, rule := range rules {
+		if !rule.Matches(event) || !d.claim(rule, event) {
+			continue
+		}
+		for _, channelID := range rule.ChannelIDs {
+			channel, ok := channels[channelID]
+			if !ok {
+				if channel, err = d.store.GetChannel(ctx, channelID); err != nil {
+					logger.Warn("Notification rule refers to a missing channel", "rule", rule.Name, "channel_id", channelID, "error", err)
+					continue
+				}
+				channels[channelID] = channel
+			}
+			if !channel.Enabled {
+				continue
+			}
+
+			delivery := &Delivery{RuleID: rule.ID, ChannelID: channel.ID, Event: event.Type, ServerID: event.ServerID, Success: true}
+			if err := d.Send(ctx, channel, event); err != nil {
+				logger.Warn("Failed to send notification", "rule", rule.Name, "channel", channel.Name, "event", event.Type, "error", err)
+				delivery.Success = false
+				delivery.Error = err.Error()
+			}
+			if err := d.store.RecordDelivery(ctx, delivery); err != nil {
+				logger.Error("Failed to record notification delivery", "error", err)
+			}
+		}
+	}
+	d.prune(ctx)
+}
+
+// claim reports whether the rule is out of its cooldown for the event's
+// server, and starts a new cooldown when it is
+func (d *Dispatcher) claim(rule *Rule, event Event) bool {
+	key := rule.ID + "/" + event.ServerID
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.lastSent[key]; ok && event.Time.Sub(last) < rule.cooldown() {
+		return false
+	}
+	d.lastSent[key] = event.Time
+	return true
+}
+
+func (d *Dispatcher) prune(ctx context.Context) {
+	d.mu.Lock()
+	due := time.Since(d.lastPrune) >= pruneInterval
+	if due {
+		d.lastPrune = time.Now()
+	}
+	d.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := d.store.PruneDeliveries(ctx, time.Now().Add(-deliveryRetention)); err != nil {
+		logger.Error("Failed to prune notification deliveries", "error", err)
+	}
+}
+
+// Test sends a test message to channel, whether or not it is enabled
+func (d *Dispatcher) Test(ctx context.Context, channel *Channel) error {
+	return d.Send(ctx, channel, Event{
+		Type:    "test",
+		Title:   "Test notification",
+		Message: fmt.Sprintf("Channel %s is set up to receive notifications from Hytale Server Manager.", channel.Name),
+		Time:    time.Now().UTC(),
+	})
+}
+
+// Send delivers one event to one channel
+func (d *Dispatcher) Send(ctx context.Context, channel *Channel, event Event) error {
+	switch channel.Type {
+	case ChannelDiscord:
+		return d.post(ctx, channel.URL, discordPayload(event), nil)
+	case ChannelSlack:
+		return d.post(ctx, channel.URL, slackPayload(event), nil)
+	case ChannelWebhook:
+		body, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+		headers := map[string]string{"X-HSM-Event": event.Type}
+		if channel.Secret != "" {
+			headers["X-HSM-Signature"] = "sha256=" + Sign(channel.Secret, body)
+		}
+		return d.post(ctx, channel.URL, body, headers)
+	case ChannelEmail:
+		if d.mailer == nil || !d.mailer.Enabled() {
+			return fmt.Errorf("smtp delivery is not configured")
+		}
+		return d.mailer.Send(channel.Recipients, "Hytale Server Manager: "+event.Title, emailBody(event))
+	default:
+		return fmt.Errorf("unknown channel type %q", channel.Type)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of body, as webhook channels send it in
+// X-HSM-Signature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) post(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HytaleSM-Notifications")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(text))
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/agentstream"
+	"github.com/TheGojiOG/HytaleSM/internal/alerts"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+	"github.com/TheGojiOG/HytaleSM/internal/watchdog"
+)
+
+// Embed colours by event, as Discord's decimal RGB
+var discordColors = map[string]int{
+	EventServerCrash:     0xE74C3C,
+	EventBackupFailed:    0xE67E22,
+	EventCPUHigh:         0xF1C40F,
+	EventAgentOffline:    0x95A5A6,
+	EventDeployCompleted: 0x2ECC71,
+	EventAlertFiring:     0xE74C3C,
+	EventAlertResolved:   0x2ECC71,
+}
+
+// CrashEvent describes a crash the watchdog detected
+func CrashEvent(crash watchdog.Crash) Event {
+	event := Event{
+		Type:     EventServerCrash,
+		ServerID: crash.ServerID,
+		Title:    fmt.Sprintf("Server %s crashed", crash.ServerID),
+		Time:     crash.DetectedAt.UTC(),
+		Details:  map[string]interface{}{"restarts": crash.Restarts, "gave_up": crash.GaveUp},
+	}
+	var message strings.Builder
+	fmt.Fprintf(&message, "Server %s exited unexpectedly.", crash.ServerID)
+	if crash.RestartError != "" {
+		fmt.Fprintf(&message, " The last restart failed: %s.", crash.RestartError)
+	}
+	switch {
+	case crash.GaveUp:
+		event.Title = fmt.Sprintf("Server %s keeps crashing", crash.ServerID)
+		fmt.Fprintf(&message, " It was restarted %d times recently and will not be restarted again.", crash.Restarts)
+	case crash.NextRestart != nil:
+		fmt.Fprintf(&message, " It will be restarted at %s.", crash.NextRestart.UTC().Format(time.RFC3339))
+	}
+	if len(crash.ConsoleLines) > 0 {
+		event.Details["console_lines"] = crash.ConsoleLines
+	}
+	event.Message = message.String()
+	return event
+}
+
+// HookEvent turns the hook events notifications are sent for into an event:
+// failed backups and finished deploys, successful or not
+func HookEvent(hook hooks.Event) (Event, bool) {
+	if hook.Success == nil {
+		return Event{}, false
+	}
+	event := Event{ServerID: hook.ServerID, Time: hook.Time, Details: hook.Details}
+	switch {
+	case hook.Name == hooks.PostBackup && !*hook.Success:
+		event.Type = EventBackupFailed
+		event.Title = fmt.Sprintf("Backup of %s failed", hook.ServerID)
+		event.Message = fmt.Sprintf("The backup of server %s failed: %s", hook.ServerID, hook.Error)
+	case hook.Name == hooks.PostDeploy:
+		event.Type = EventDeployCompleted
+		event.Title = fmt.Sprintf("Deploy to %s finished", hook.ServerID)
+		event.Message = fmt.Sprintf("The deploy to server %s finished.", hook.ServerID)
+		if !*hook.Success {
+			event.Title = fmt.Sprintf("Deploy to %s failed", hook.ServerID)
+			event.Message = fmt.Sprintf("The deploy to server %s failed: %s", hook.ServerID, hook.Error)
+		}
+		details := map[string]interface{}{"success": *hook.Success}
+		for key, value := range hook.Details {
+			details[key] = value
+		}
+		event.Details = details
+	default:
+		return Event{}, false
+	}
+	return event, true
+}
+
+// CPUEvent turns a metrics sample with a CPU reading into an event. Rules
+// compare its value with their threshold.
+func CPUEvent(sample metrics.Sample) (Event, bool) {
+	var usage float64
+	switch value := sample.Values["cpu_usage"].(type) {
+	case float64:
+		usage = value
+	case int:
+		usage = float64(value)
+	default:
+		return Event{}, false
+	}
+	return Event{
+		Type:     EventCPUHigh,
+		ServerID: sample.ServerID,
+		Title:    fmt.Sprintf("High CPU on %s", sample.ServerID),
+		Message:  fmt.Sprintf("CPU usage on server %s is at %.1f%%.", sample.ServerID, usage),
+		Time:     time.Now().UTC(),
+		Value:    &usage,
+	}, true
+}
+
+// AgentOfflineEvents describes a lost agent stream, once for each server on
+// the agent's host so rules can select servers
+func AgentOfflineEvents(agent agentstream.Agent) []Event {
+	serverIDs := agent.ServerIDs
+	if len(serverIDs) == 0 {
+		serverIDs = []string{""}
+	}
+	events := make([]Event, 0, len(serverIDs))
+	for _, serverID := range serverIDs {
+		events = append(events, Event{
+			Type:     EventAgentOffline,
+			ServerID: serverID,
+			Title:    fmt.Sprintf("Agent on %s went offline", agent.HostUUID),
+			Message:  fmt.Sprintf("The agent on host %s disconnected; it was last seen at %s.", agent.HostUUID, agent.LastSeen.UTC().Format(time.RFC3339)),
+			Time:     time.Now().UTC(),
+			Details:  map[string]interface{}{"host_uuid": agent.HostUUID, "remote_addr": agent.RemoteAddr},
+		})
+	}
+	return events
+}
+
+// AlertEvent describes a metric alert firing or resolving
+func AlertEvent(alert alerts.Alert) Event {
+	event := Event{
+		Type:     EventAlertFiring,
+		ServerID: alert.ServerID,
+		Title:    fmt.Sprintf("[%s] %s on %s", alert.Severity, alert.RuleName, alert.ServerID),
+		Message: fmt.Sprintf("%s on server %s is %.1f, %s %g since %s.", alert.Metric, alert.ServerID, alert.Value,
+			alert.Comparator, alert.Threshold, alert.StartedAt.Format(time.RFC3339)),
+		Time:  alert.FiredAt,
+		Value: &alert.Value,
+		Details: map[string]interface{}{
+			"alert_id": alert.ID,
+			"rule_id":  alert.RuleID,
+			"severity": alert.Severity,
+		},
+	}
+	if alert.State == alerts.StateResolved && alert.ResolvedAt != nil {
+		event.Type = EventAlertResolved
+		event.Title = fmt.Sprintf("Resolved: %s on %s", alert.RuleName, alert.ServerID)
+		event.Message = fmt.Sprintf("%s on server %s is back to %.1f.", alert.Metric, alert.ServerID, alert.Value)
+		event.Time = *alert.ResolvedAt
+	}
+	return event
+}
+
+func discordPayload(event Event) []byte {
+	embed := map[string]interface{}{
+		"title":       event.Title,
+		"description": event.Message,
+		"color":       discordColors[event.Type],
+		"timestamp":   event.Time.Format(time.RFC3339),
+	}
+	if event.ServerID != "" {
+		embed["fields"] = []map[string]interface{}{{"name": "Server", "value": event.ServerID, "inline": true}}
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"username": "Hytale Server Manager",
+		"embeds":   []interface{}{embed},
+	})
+	return body
+}
+
+func slackPayload(event Event) []byte {
+	body, _ := json.Marshal(map[string]string{"text": "*" + event.Title + "*\n" + event.Message})
+	return body
+}
+
+func emailBody(event Event) string {
+	var body strings.Builder
+	body.WriteString(event.Message)
+	body.WriteString("\n")
+	if lines, ok := event.Details["console_lines"].([]string); ok {
+		fmt.Fprintf(&body, "\nLast %d console lines:\n\n%s\n", len(lines), strings.Join(lines, "\n"))
+	}
+	fmt.Fprintf(&body, "\nTime: %s\n", event.Time.Format(time.RFC3339))
+	return body.String()
+}
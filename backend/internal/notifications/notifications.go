@@ -0,0 +1,218 @@
+// Package notifications tells people about events in the manager, such as a
+// crashed server or a failed backup, through Discord, Slack, a generic
+// webhook or email. Channels say where a message goes and rules which events
+// are sent to which channels.
+package notifications
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("notifications")
+
+// Events rules can be written for
+const (
+	EventServerCrash     = "server_crash"
+	EventBackupFailed    = "backup_failed"
+	EventCPUHigh         = "cpu_high"
+	EventAgentOffline    = "agent_offline"
+	EventDeployCompleted = "deploy_completed"
+	EventAlertFiring     = "alert_firing"
+	EventAlertResolved   = "alert_resolved"
+)
+
+// Events lists every event, in the order they are documented
+var Events = []string{EventServerCrash, EventBackupFailed, EventCPUHigh, EventAgentOffline, EventDeployCompleted, EventAlertFiring, EventAlertResolved}
+
+// Channel types
+const (
+	ChannelDiscord = "discord"
+	ChannelSlack   = "slack"
+	ChannelWebhook = "webhook"
+	ChannelEmail   = "email"
+)
+
+// ChannelTypes lists every channel type
+var ChannelTypes = []string{ChannelDiscord, ChannelSlack, ChannelWebhook, ChannelEmail}
+
+const (
+	defaultCPUThreshold = 90
+	defaultCooldown     = 15 * time.Minute
+)
+
+// Event is something that happened that rules may notify about. Webhook
+// channels receive it as their JSON body.
+type Event struct {
+	Type     string    `json:"event"`
+	ServerID string    `json:"server_id,omitempty"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+	// Value is the measurement a threshold event was raised for, e.g. CPU percent
+	Value   *float64               `json:"value,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Channel is somewhere notifications are sent
+type Channel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// URL is the Discord or Slack incoming webhook, or the webhook endpoint
+	URL string `json:"url,omitempty"`
+	// Secret signs webhook bodies with HMAC-SHA256 in X-HSM-Signature
+	Secret     string    `json:"-"`
+	HasSecret  bool      `json:"has_secret"`
+	Recipients []string  `json:"recipients,omitempty"` // email addresses
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Rule sends one event to channels
+type Rule struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Event      string   `json:"event"`
+	ChannelIDs []string `json:"channel_ids"`
+	ServerIDs  []string `json:"server_ids"` // empty matches every server
+	// Threshold is the CPU percent a cpu_high rule fires at
+	Threshold float64 `json:"threshold,omitempty"`
+	// Cooldown is the least time between two notifications of the rule for
+	// the same server
+	Cooldown  string    `json:"cooldown"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Delivery records one notification sent, or tried, to a channel
+type Delivery struct {
+	ID        int64     `json:"id"`
+	RuleID    string    `json:"rule_id"`
+	ChannelID string    `json:"channel_id"`
+	Event     string    `json:"event"`
+	ServerID  string    `json:"server_id,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Normalize trims fields and fills in defaults
+func (c *Channel) Normalize() {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Type = strings.ToLower(strings.TrimSpace(c.Type))
+	c.URL = strings.TrimSpace(c.URL)
+	recipients := make([]string, 0, len(c.Recipients))
+	for _, recipient := range c.Recipients {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			recipients = append(recipients, recipient)
+		}
+	}
+	c.Recipients = recipients
+	c.HasSecret = c.Secret != ""
+}
+
+// Validate checks that the channel can be sent to
+func (c *Channel) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch c.Type {
+	case ChannelDiscord, ChannelSlack, ChannelWebhook:
+		parsed, err := url.Parse(c.URL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+			return fmt.Errorf("url must be an http(s) URL")
+		}
+	case ChannelEmail:
+		if len(c.Recipients) == 0 {
+			return fmt.Errorf("recipients must list at least one address")
+		}
+		for _, recipient := range c.Recipients {
+			if strings.ContainsAny(recipient, "\r\n") || !strings.Contains(recipient, "@") {
+				return fmt.Errorf("invalid recipient %q", recipient)
+			}
+		}
+	default:
+		return fmt.Errorf("type must be one of %s", strings.Join(ChannelTypes, ", "))
+	}
+	return nil
+}
+
+// Redacted returns the channel as the API shows it. Discord and Slack
+// webhook URLs carry their token in the path, so only the host is kept.
+func (c Channel) Redacted() Channel {
+	if c.Type == ChannelDiscord || c.Type == ChannelSlack || c.Type == ChannelWebhook {
+		if parsed, err := url.Parse(c.URL); err == nil && parsed.Host != "" {
+			c.URL = parsed.Scheme + "://" + parsed.Host + "/…"
+		}
+	}
+	c.Secret = ""
+	return c
+}
+
+// Normalize trims fields and fills in defaults
+func (r *Rule) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Event = strings.TrimSpace(r.Event)
+	if r.ChannelIDs == nil {
+		r.ChannelIDs = []string{}
+	}
+	if r.ServerIDs == nil {
+		r.ServerIDs = []string{}
+	}
+	if r.Event == EventCPUHigh && r.Threshold == 0 {
+		r.Threshold = defaultCPUThreshold
+	}
+	if strings.TrimSpace(r.Cooldown) == "" {
+		r.Cooldown = defaultCooldown.String()
+	}
+}
+
+// Validate checks the rule's event, channels, threshold and cooldown
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !slices.Contains(Events, r.Event) {
+		return fmt.Errorf("event must be one of %s", strings.Join(Events, ", "))
+	}
+	if len(r.ChannelIDs) == 0 {
+		return fmt.Errorf("channel_ids must list at least one channel")
+	}
+	if r.Event == EventCPUHigh && (r.Threshold <= 0 || r.Threshold > 100) {
+		return fmt.Errorf("threshold must be a CPU percent between 0 and 100")
+	}
+	if d, err := time.ParseDuration(r.Cooldown); err != nil || d < 0 {
+		return fmt.Errorf("invalid cooldown %q", r.Cooldown)
+	}
+	return nil
+}
+
+// Matches reports whether the rule notifies about event
+func (r *Rule) Matches(event Event) bool {
+	if !r.Enabled || r.Event != event.Type {
+		return false
+	}
+	if len(r.ServerIDs) > 0 && !slices.Contains(r.ServerIDs, event.ServerID) {
+		return false
+	}
+	if r.Event == EventCPUHigh && (event.Value == nil || *event.Value < r.Threshold) {
+		return false
+	}
+	return true
+}
+
+func (r *Rule) cooldown() time.Duration {
+	d, err := time.ParseDuration(r.Cooldown)
+	if err != nil || d < 0 {
+		return defaultCooldown
+	}
+	return d
+}
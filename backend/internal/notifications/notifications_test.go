@@ -0,0 +1,168 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
+	"github.com/TheGojiOG/HytaleSM/internal/metrics"
+)
+
+type received struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+func newWebhook(t *testing.T) (*httptest.Server, func() []received) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		hits []received
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		hits = append(hits, received{event: r.Header.Get("X-HSM-Event"), signature: r.Header.Get("X-HSM-Signature"), body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received{}, hits...)
+	}
+}
+
+func newStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "notifications.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewStore(db.DB)
+}
+
+func TestDispatcherSendsMatchingEvents(t *testing.T) {
+	store := newStore(t)
+	webhook, hits := newWebhook(t)
+	ctx := context.Background()
+
+	channel := &Channel{ID: "hook", Name: "Ops webhook", Type: ChannelWebhook, URL: webhook.URL, Secret: "s3cret", Enabled: true}
+	channel.Normalize()
+	if err := channel.Validate(); err != nil {
+		t.Fatalf("validate channel: %v", err)
+	}
+	if err := store.SaveChannel(ctx, channel); err != nil {
+		t.Fatalf("save channel: %v", err)
+	}
+	for _, rule := range []*Rule{
+		{ID: "cpu", Name: "Busy CPU", Event: EventCPUHigh, ChannelIDs: []string{"hook"}, Threshold: 80, Enabled: true},
+		{ID: "backups", Name: "Failed backups", Event: EventBackupFailed, ChannelIDs: []string{"hook"}, ServerIDs: []string{"alpha"}, Cooldown: "0s", Enabled: true},
+	} {
+		rule.Normalize()
+		if err := rule.Validate(); err != nil {
+			t.Fatalf("validate rule %s: %v", rule.Name, err)
+		}
+		if err := store.SaveRule(ctx, rule); err != nil {
+			t.Fatalf("save rule %s: %v", rule.Name, err)
+		}
+	}
+
+	dispatcher := NewDispatcher(store, nil)
+	notify := func(event Event, ok bool) {
+		if ok {
+			dispatcher.Notify(event)
+		}
+		dispatcher.Wait()
+	}
+	notify(CPUEvent(metrics.Sample{ServerID: "alpha", Values: map[string]interface{}{"cpu_usage": 42.0}}))
+	notify(CPUEvent(metrics.Sample{ServerID: "alpha", Values: map[string]interface{}{"cpu_usage": 95.0}}))
+	notify(CPUEvent(metrics.Sample{ServerID: "alpha", Values: map[string]interface{}{"cpu_usage": 97.0}}))
+	failed := hooks.NewEvent(hooks.PostBackup, "alpha", nil).WithResult(errors.New("disk full"))
+	notify(HookEvent(failed))
+	notify(HookEvent(failed))
+	notify(HookEvent(hooks.NewEvent(hooks.PostBackup, "beta", nil).WithResult(errors.New("disk full"))))
+	notify(HookEvent(hooks.NewEvent(hooks.PostBackup, "alpha", nil).WithResult(nil)))
+
+	got := hits()
+	if len(got) != 3 {
+		t.Fatalf("expected one CPU alert inside the cooldown and two backup failures, got %d", len(got))
+	}
+	if got[0].event != EventCPUHigh || got[1].event != EventBackupFailed {
+		t.Fatalf("unexpected events %q and %q", got[0].event, got[1].event)
+	}
+	if got[0].signature != "sha256="+Sign("s3cret", got[0].body) {
+		t.Fatalf("expected the body to be signed, got %q", got[0].signature)
+	}
+	var event Event
+	if err := json.Unmarshal(got[0].body, &event); err != nil || event.Value == nil || *event.Value != 95 {
+		t.Fatalf("expected the CPU reading in the body, got %s (%v)", got[0].body, err)
+	}
+
+	deliveries, err := store.ListDeliveries(ctx, 10)
+	if err != nil || len(deliveries) != 3 || !deliveries[0].Success {
+		t.Fatalf("expected three successful deliveries, got %+v (%v)", deliveries, err)
+	}
+
+	var inUse *ChannelInUseError
+	if err := store.DeleteChannel(ctx, "hook"); !errors.As(err, &inUse) || len(inUse.Rules) != 2 {
+		t.Fatalf("expected the channel to be kept while rules use it, got %v", err)
+	}
+}
+
+func TestRuleValidation(t *testing.T) {
+	rule := &Rule{Name: "cpu", Event: EventCPUHigh, ChannelIDs: []string{"a"}}
+	rule.Normalize()
+	if err := rule.Validate(); err != nil || rule.Threshold != defaultCPUThreshold || rule.Cooldown != "15m0s" {
+		t.Fatalf("expected defaults to make the rule valid, got %+v (%v)", rule, err)
+	}
+	for _, bad := range []Rule{
+		{Name: "x", Event: "server_on_fire", ChannelIDs: []string{"a"}},
+		{Name: "x", Event: EventServerCrash},
+		{Name: "x", Event: EventCPUHigh, ChannelIDs: []string{"a"}, Threshold: 140},
+		{Name: "x", Event: EventServerCrash, ChannelIDs: []string{"a"}, Cooldown: "soon"},
+	} {
+		bad.Normalize()
+		if err := bad.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", bad)
+		}
+	}
+
+	channel := &Channel{Name: "mail", Type: ChannelEmail, Recipients: []string{"ops@example.com\r\nBcc: x@example.com"}}
+	channel.Normalize()
+	if err := channel.Validate(); err == nil {
+		t.Fatalf("expected a header injection in a recipient to be rejected")
+	}
+	discord := Channel{Type: ChannelDiscord, URL: "https://discord.com/api/webhooks/1/token", Secret: "s"}
+	if redacted := discord.Redacted(); redacted.URL != "https://discord.com/…" || redacted.Secret != "" {
+		t.Fatalf("expected the webhook token to be redacted, got %+v", redacted)
+	}
+}
+
+func TestHookEventDeploy(t *testing.T) {
+	event, ok := HookEvent(hooks.NewEvent(hooks.PostDeploy, "alpha", map[string]interface{}{"version": "1.2"}).WithResult(nil))
+	if !ok || event.Type != EventDeployCompleted || event.Details["success"] != true || event.Details["version"] != "1.2" {
+		t.Fatalf("unexpected deploy event %+v", event)
+	}
+	if _, ok := HookEvent(hooks.NewEvent(hooks.PreDeploy, "alpha", nil)); ok {
+		t.Fatalf("expected pre_deploy to be ignored")
+	}
+	if time.Since(event.Time) > time.Minute {
+		t.Fatalf("expected the hook's time, got %s", event.Time)
+	}
+}
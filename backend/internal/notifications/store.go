@@ -0,0 +1,324 @@
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for unknown channel and rule IDs
+var ErrNotFound = errors.New("notification channel or rule not found")
+
+// ErrNameTaken is returned when another channel or rule has the same name
+var ErrNameTaken = errors.New("a channel or rule with this name already exists")
+
+// ChannelInUseError is returned when a channel that rules send to is deleted
+type ChannelInUseError struct {
+	Rules []string
+}
+
+func (e *ChannelInUseError) Error() string {
+	return "channel is used by rules: " + strings.Join(e.Rules, ", ")
+}
+
+// Store persists channels, rules and deliveries. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new notification store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const channelColumns = `id, name, type, url, secret, recipients, enabled, created_at, updated_at`
+
+const ruleColumns = `id, name, event, channel_ids, server_ids, threshold, cooldown, enabled, created_at, updated_at`
+
+// GetChannel returns one channel
+func (s *Store) GetChannel(ctx context.Context, id string) (*Channel, error) {
+	channel, err := scanChannel(s.db.QueryRowContext(ctx, `SELECT `+channelColumns+` FROM notification_channels WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification channel: %w", err)
+	}
+	return channel, nil
+}
+
+// ListChannels returns every channel, by name
+func (s *Store) ListChannels(ctx context.Context) ([]*Channel, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+channelColumns+` FROM notification_channels ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	channels := make([]*Channel, 0)
+	for rows.Next() {
+		channel, err := scanChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// SaveChannel creates or updates a channel
+func (s *Store) SaveChannel(ctx context.Context, channel *Channel) error {
+	now := time.Now().UTC()
+	if channel.CreatedAt.IsZero() {
+		channel.CreatedAt = now
+	}
+	channel.UpdatedAt = now
+
+	if err := s.checkName(ctx, "notification_channels", channel.ID, channel.Name); err != nil {
+		return err
+	}
+	recipients, err := json.Marshal(channel.Recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encode recipients: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO notification_channels (`+channelColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			type = excluded.type,
+			url = excluded.url,
+			secret = excluded.secret,
+			recipients = excluded.recipients,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`, channel.ID, channel.Name, channel.Type, channel.URL, channel.Secret, string(recipients), channel.Enabled,
+		channel.CreatedAt, channel.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save notification channel: %w", err)
+	}
+	return nil
+}
+
+// DeleteChannel removes a channel no rule sends to
+func (s *Store) DeleteChannel(ctx context.Context, id string) error {
+	rules, err := s.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	var users []string
+	for _, rule := range rules {
+		if slices.Contains(rule.ChannelIDs, id) {
+			users = append(users, rule.Name)
+		}
+	}
+	if len(users) > 0 {
+		return &ChannelInUseError{Rules: users}
+	}
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM notification_channels WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetRule returns one rule
+func (s *Store) GetRule(ctx context.Context, id string) (*Rule, error) {
+	rule, err := scanRule(s.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM notification_rules WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load notification rule: %w", err)
+	}
+	return rule, nil
+}
+
+// ListRules returns every rule, by name
+func (s *Store) ListRules(ctx context.Context) ([]*Rule, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+ruleColumns+` FROM notification_rules ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*Rule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// SaveRule creates or updates a rule. Its channels must exist.
+func (s *Store) SaveRule(ctx context.Context, rule *Rule) error {
+	now := time.Now().UTC()
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = now
+	}
+	rule.UpdatedAt = now
+
+	if err := s.checkName(ctx, "notification_rules", rule.ID, rule.Name); err != nil {
+		return err
+	}
+	for _, channelID := range rule.ChannelIDs {
+		if _, err := s.GetChannel(ctx, channelID); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("%w: channel %s", ErrNotFound, channelID)
+			}
+			return err
+		}
+	}
+	channelIDs, err := json.Marshal(rule.ChannelIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode channel ids: %w", err)
+	}
+	serverIDs, err := json.Marshal(rule.ServerIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode server ids: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO notification_rules (`+ruleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			event = excluded.event,
+			channel_ids = excluded.channel_ids,
+			server_ids = excluded.server_ids,
+			threshold = excluded.threshold,
+			cooldown = excluded.cooldown,
+			enabled = excluded.enabled,
+			updated_at = excluded.updated_at
+	`, rule.ID, rule.Name, rule.Event, string(channelIDs), string(serverIDs), rule.Threshold, rule.Cooldown, rule.Enabled,
+		rule.CreatedAt, rule.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save notification rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteRule removes a rule. Its deliveries are kept.
+func (s *Store) DeleteRule(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM notification_rules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification rule: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordDelivery writes the outcome of one notification
+func (s *Store) RecordDelivery(ctx context.Context, delivery *Delivery) error {
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO notification_deliveries (rule_id, channel_id, event, server_id, success, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, delivery.RuleID, delivery.ChannelID, delivery.Event, delivery.ServerID, delivery.Success, delivery.Error, delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record notification delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries, newest first
+func (s *Store) ListDeliveries(ctx context.Context, limit int) ([]*Delivery, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, rule_id, channel_id, event, server_id, success, error, created_at
+		FROM notification_deliveries
+		ORDER BY id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*Delivery, 0)
+	for rows.Next() {
+		var delivery Delivery
+		if err := rows.Scan(&delivery.ID, &delivery.RuleID, &delivery.ChannelID, &delivery.Event, &delivery.ServerID,
+			&delivery.Success, &delivery.Error, &delivery.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// PruneDeliveries deletes deliveries recorded before cutoff
+func (s *Store) PruneDeliveries(ctx context.Context, cutoff time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM notification_deliveries WHERE created_at < ?`, cutoff.UTC()); err != nil {
+		return fmt.Errorf("failed to prune notification deliveries: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) checkName(ctx context.Context, table, id, name string) error {
+	var taken string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM `+table+` WHERE name = ? AND id != ?`, name, id).Scan(&taken)
+	if err == nil {
+		return ErrNameTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check name: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanChannel(row rowScanner) (*Channel, error) {
+	var (
+		channel    Channel
+		recipients string
+	)
+	if err := row.Scan(&channel.ID, &channel.Name, &channel.Type, &channel.URL, &channel.Secret, &recipients,
+		&channel.Enabled, &channel.CreatedAt, &channel.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(recipients), &channel.Recipients); err != nil {
+		return nil, fmt.Errorf("invalid recipients of channel %s: %w", channel.ID, err)
+	}
+	channel.Normalize()
+	return &channel, nil
+}
+
+func scanRule(row rowScanner) (*Rule, error) {
+	var (
+		rule                  Rule
+		channelIDs, serverIDs string
+	)
+	if err := row.Scan(&rule.ID, &rule.Name, &rule.Event, &channelIDs, &serverIDs, &rule.Threshold, &rule.Cooldown,
+		&rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(channelIDs), &rule.ChannelIDs); err != nil {
+		return nil, fmt.Errorf("invalid channels of rule %s: %w", rule.ID, err)
+	}
+	if err := json.Unmarshal([]byte(serverIDs), &rule.ServerIDs); err != nil {
+		return nil, fmt.Errorf("invalid servers of rule %s: %w", rule.ID, err)
+	}
+	rule.Normalize()
+	return &rule, nil
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+// SMTPSender delivers plain-text email through an SMTP relay
+type SMTPSender struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTPSender creates a new SMTP sender
+func NewSMTPSender(cfg config.SMTPConfig) *SMTPSender {
+	return &SMTPSender{cfg: cfg}
+}
+
+// Enabled reports whether SMTP delivery is configured
+func (s *SMTPSender) Enabled() bool {
+	return s != nil && s.cfg.Enabled && s.cfg.Host != "" && s.cfg.From != ""
+}
+
+// Send delivers a plain-text message to the given recipients.
+// net/smtp upgrades to STARTTLS automatically when the relay supports it.
+func (s *SMTPSender) Send(to []string, subject, body string) error {
+	if !s.Enabled() {
+		return fmt.Errorf("smtp delivery is not configured")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+
+	port := s.cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := fmt.Sprintf("%s:%d", s.cfg.Host, port)
+
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	msg := buildMessage(s.cfg.From, to, subject, body, time.Now())
+	if err := smtp.SendMail(addr, auth, s.cfg.From, to, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+func buildMessage(from string, to []string, subject, body string, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", sanitizeHeader(from))
+	fmt.Fprintf(&buf, "To: %s\r\n", sanitizeHeader(strings.Join(to, ", ")))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", sanitizeHeader(subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return buf.Bytes()
+}
+
+// sanitizeHeader strips CR/LF so user-controlled values cannot inject headers
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+func TestBuildMessageStripsHeaderInjection(t *testing.T) {
+	msg := string(buildMessage("manager@example.com", []string{"user@example.com"}, "Reset\r\nBcc: evil@example.com", "line one\nline two", time.Unix(0, 0)))
+
+	if strings.Contains(msg, "\r\nBcc:") {
+		t.Fatalf("expected subject newlines to be stripped, got %q", msg)
+	}
+	if !strings.Contains(msg, "line one\r\nline two") {
+		t.Fatalf("expected body line endings to be normalized, got %q", msg)
+	}
+}
+
+func TestSMTPSenderDisabled(t *testing.T) {
+	sender := NewSMTPSender(config.SMTPConfig{Enabled: true, Host: "smtp.example.com"})
+	if sender.Enabled() {
+		t.Fatalf("expected sender without from address to be disabled")
+	}
+	if err := sender.Send([]string{"user@example.com"}, "subject", "body"); err == nil {
+		t.Fatalf("expected send to fail when disabled")
+	}
+}
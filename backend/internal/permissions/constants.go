@@ -28,9 +28,23 @@ const (
 	ServersDependenciesCheck    = "servers.dependencies.check"
 	ServersAgentInstall         = "servers.agent.install"
 	ServersAgentStateRead       = "servers.agent.state.read"
+	ServersDriftRead            = "servers.drift.read"
 	ServersProcessKill          = "servers.process.kill"
 	ServersReleaseDeploy        = "servers.releases.deploy"
+	ServersReleaseRollback      = "servers.releases.rollback"
+	ServersDeploymentsRead      = "servers.deployments.read"
 	ServersTransferBenchmark    = "servers.transfer.benchmark"
+	ServersExport               = "servers.export"
+	ServersImport               = "servers.import"
+	ServersPlayersRead          = "servers.players.read"
+	ServersPlayersManage        = "servers.players.manage"
+	ServersFilesView            = "servers.files.view"
+	ServersFilesEdit            = "servers.files.edit"
+	ServersWorldsView           = "servers.worlds.view"
+	ServersWorldsManage         = "servers.worlds.manage"
+	ServersSSHHostKeyRead       = "servers.ssh.hostkey.read"
+	ServersSSHHostKeyManage     = "servers.ssh.hostkey.manage"
+	ServersSSHKeyRotate         = "servers.ssh.key.rotate"
 
 	// Server backups
 	ServersBackupsCreate           = "servers.backups.create"
@@ -44,6 +58,65 @@ const (
 	SettingsGet    = "settings.get"
 	SettingsUpdate = "settings.update"
 
+	// Manager self-backups
+	SystemBackupsList     = "system.backups.list"
+	SystemBackupsCreate   = "system.backups.create"
+	SystemBackupsDownload = "system.backups.download"
+	SystemBackupsDelete   = "system.backups.delete"
+
+	// Manager database
+	SystemDatabaseRead     = "system.db.read"
+	SystemDatabaseMaintain = "system.db.maintain"
+
+	// Manager configuration
+	SystemConfigReload      = "system.config.reload"
+	SystemConfigHistoryRead = "system.config.history.read"
+	SystemConfigRestore     = "system.config.restore"
+
+	// Declarative apply of servers, schedules and maintenance windows
+	SystemApply = "system.apply"
+
+	// GitOps sync of server definitions and schedules
+	SystemGitOpsRead = "system.gitops.read"
+	SystemGitOpsSync = "system.gitops.sync"
+
+	// Usage reports for billing
+	ReportsUsageRead = "reports.usage.read"
+
+	// Host security status (fail2ban bans, failed SSH logins)
+	SecurityHostsRead = "security.hosts.read"
+
+	// Manager logging
+	SystemLoggingRead   = "system.logging.read"
+	SystemLoggingUpdate = "system.logging.update"
+
+	// Manager profiling and runtime debug endpoints
+	SystemDebug = "system.debug"
+
+	// SSH connection pool
+	SystemSSHConnectionsRead   = "system.ssh.connections.read"
+	ServersSSHConnectionManage = "servers.ssh.connection.manage"
+
+	// Scheduled tasks
+	SchedulesRead   = "schedules.read"
+	SchedulesManage = "schedules.manage"
+
+	// Maintenance windows
+	MaintenanceWindowsRead   = "maintenance.windows.read"
+	MaintenanceWindowsManage = "maintenance.windows.manage"
+
+	// Script library for host maintenance
+	ScriptsManage = "scripts.manage"
+	ScriptsRun    = "scripts.run"
+
+	// Notification channels and rules
+	NotificationsView   = "notifications.view"
+	NotificationsManage = "notifications.manage"
+
+	// Metric alert rules and alerts
+	AlertsView   = "alerts.view"
+	AlertsManage = "alerts.manage"
+
 	// Releases
 	ReleasesList              = "releases.list"
 	ReleasesGet               = "releases.get"
@@ -51,6 +124,9 @@ const (
 	ReleasesJobsGet           = "releases.jobs.get"
 	ReleasesJobsStream        = "releases.jobs.stream"
 	ReleasesDownload          = "releases.download"
+	ReleasesUpload            = "releases.upload"
+	ReleasesAutoUpdateRead    = "releases.auto_update.read"
+	ReleasesAutoUpdateManage  = "releases.auto_update.manage"
 	ReleasesDelete            = "releases.delete"
 	ReleasesPrintVersion      = "releases.print_version"
 	ReleasesCheckUpdate       = "releases.check_update"
@@ -26,6 +26,20 @@ func TestPermissionConstants(t *testing.T) {
 		ServersConsoleHistorySearch,
 		ServersConsoleAutocomplete,
 		ServersTasksRead,
+		ServersDriftRead,
+		ServersExport,
+		ServersImport,
+		ServersPlayersRead,
+		ServersPlayersManage,
+		ServersFilesView,
+		ServersFilesEdit,
+		ServersWorldsView,
+		ServersWorldsManage,
+		ServersSSHHostKeyRead,
+		ServersSSHHostKeyManage,
+		ServersSSHKeyRotate,
+		ServersReleaseRollback,
+		ServersDeploymentsRead,
 		ServersBackupsCreate,
 		ServersBackupsList,
 		ServersBackupsGet,
@@ -34,12 +48,44 @@ func TestPermissionConstants(t *testing.T) {
 		ServersBackupsRetentionEnforce,
 		SettingsGet,
 		SettingsUpdate,
+		SystemBackupsList,
+		SystemBackupsCreate,
+		SystemBackupsDownload,
+		SystemBackupsDelete,
+		SystemDatabaseRead,
+		SystemDatabaseMaintain,
+		SystemConfigReload,
+		SystemConfigHistoryRead,
+		SystemConfigRestore,
+		SystemApply,
+		SystemGitOpsRead,
+		SystemGitOpsSync,
+		ReportsUsageRead,
+		SecurityHostsRead,
+		SystemLoggingRead,
+		SystemLoggingUpdate,
+		SystemDebug,
+		SystemSSHConnectionsRead,
+		ServersSSHConnectionManage,
+		SchedulesRead,
+		SchedulesManage,
+		MaintenanceWindowsRead,
+		MaintenanceWindowsManage,
+		ScriptsManage,
+		ScriptsRun,
+		NotificationsView,
+		NotificationsManage,
+		AlertsView,
+		AlertsManage,
 		ReleasesList,
 		ReleasesGet,
 		ReleasesJobsList,
 		ReleasesJobsGet,
 		ReleasesJobsStream,
 		ReleasesDownload,
+		ReleasesUpload,
+		ReleasesAutoUpdateRead,
+		ReleasesAutoUpdateManage,
 		ReleasesPrintVersion,
 		ReleasesCheckUpdate,
 		ReleasesDownloaderVersion,
@@ -0,0 +1,276 @@
+// Package playerlists reads, validates and writes a Hytale server's whitelist
+// and ban list files, and turns a change to them into the console commands
+// that make a running server apply it.
+//
+// A running server keeps both lists in memory and writes them back on every
+// change, so an edit to the files only sticks while the server is stopped.
+// While it runs, the same edit is made with add and remove commands instead.
+package playerlists
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Files in the server's working directory
+const (
+	WhitelistFile = "whitelist.json"
+	BansFile      = "bans.json"
+)
+
+// Ban types
+const (
+	BanInfinite = "infinite"
+	BanTimed    = "timed"
+)
+
+// Whitelist is whitelist.json: whether only listed players may join, and the
+// UUIDs of those players
+type Whitelist struct {
+	Enabled bool     `json:"enabled"`
+	List    []string `json:"list"`
+}
+
+// Ban is an entry of bans.json. Fields the manager does not know, such as a
+// timed ban's duration, are kept as they are.
+type Ban struct {
+	Type      string `json:"type"`
+	Target    string `json:"target"` // the banned player's UUID
+	By        string `json:"by,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"` // unix milliseconds
+	Reason    string `json:"reason,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+var banFields = []string{"type", "target", "by", "timestamp", "reason"}
+
+// UnmarshalJSON keeps the fields of a ban the manager does not know
+func (b *Ban) UnmarshalJSON(data []byte) error {
+	type plain Ban
+	if err := json.Unmarshal(data, (*plain)(b)); err != nil {
+		return err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for _, field := range banFields {
+		delete(all, field)
+	}
+	b.Extra = nil
+	if len(all) > 0 {
+		b.Extra = all
+	}
+	return nil
+}
+
+// MarshalJSON writes a ban with the fields it was read with
+func (b Ban) MarshalJSON() ([]byte, error) {
+	type plain Ban
+	known, err := json.Marshal(plain(b))
+	if err != nil || len(b.Extra) == 0 {
+		return known, err
+	}
+	all := make(map[string]json.RawMessage, len(b.Extra)+len(banFields))
+	for key, value := range b.Extra {
+		all[key] = value
+	}
+	if err := json.Unmarshal(known, &all); err != nil {
+		return nil, err
+	}
+	return json.Marshal(all)
+}
+
+// ParseWhitelist reads whitelist.json; a missing file is empty
+func ParseWhitelist(data []byte) (*Whitelist, error) {
+	list := &Whitelist{List: []string{}}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return list, nil
+	}
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", WhitelistFile, err)
+	}
+	if list.List == nil {
+		list.List = []string{}
+	}
+	return list, nil
+}
+
+// ParseBans reads bans.json; a missing file is empty
+func ParseBans(data []byte) ([]Ban, error) {
+	bans := []Ban{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return bans, nil
+	}
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", BansFile, err)
+	}
+	if bans == nil {
+		bans = []Ban{}
+	}
+	return bans, nil
+}
+
+// Normalize writes the whitelist's UUIDs in canonical form and validates them
+func (w *Whitelist) Normalize() error {
+	seen := make(map[string]bool, len(w.List))
+	list := make([]string, 0, len(w.List))
+	for _, entry := range w.List {
+		id, err := playerUUID(entry)
+		if err != nil {
+			return fmt.Errorf("whitelist: %w", err)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		list = append(list, id)
+	}
+	w.List = list
+	return nil
+}
+
+// NormalizeBans validates bans, writing UUIDs in canonical form. A player can
+// only be banned once.
+func NormalizeBans(bans []Ban) error {
+	seen := make(map[string]bool, len(bans))
+	for i := range bans {
+		ban := &bans[i]
+		target, err := playerUUID(ban.Target)
+		if err != nil {
+			return fmt.Errorf("ban %d: %w", i+1, err)
+		}
+		if seen[target] {
+			return fmt.Errorf("ban %d: player %s is banned twice", i+1, target)
+		}
+		seen[target] = true
+		ban.Target = target
+		if ban.By != "" {
+			if ban.By, err = playerUUID(ban.By); err != nil {
+				return fmt.Errorf("ban %d: by: %w", i+1, err)
+			}
+		}
+		if ban.Type == "" {
+			ban.Type = BanInfinite
+		}
+		if ban.Type != BanInfinite && ban.Type != BanTimed {
+			return fmt.Errorf("ban %d: type must be '%s' or '%s'", i+1, BanInfinite, BanTimed)
+		}
+		if strings.ContainsAny(ban.Reason, "\r\n") {
+			return fmt.Errorf("ban %d: reason must be a single line", i+1)
+		}
+	}
+	return nil
+}
+
+func playerUUID(value string) (string, error) {
+	id, err := uuid.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("%q is not a player UUID", value)
+	}
+	return id.String(), nil
+}
+
+// Encode writes a list the way the server does
+func Encode(value any) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Checksum identifies a version of a file, so an edit can be refused when the
+// file changed since it was read
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WhitelistCommands returns the console commands that turn current into desired
+func WhitelistCommands(current, desired *Whitelist) []string {
+	commands := []string{}
+	added, removed := diff(current.List, desired.List)
+	for _, id := range removed {
+		commands = append(commands, "whitelist remove "+id)
+	}
+	for _, id := range added {
+		commands = append(commands, "whitelist add "+id)
+	}
+	// Switched last, so no listed player is locked out in between
+	if current.Enabled != desired.Enabled {
+		if desired.Enabled {
+			commands = append(commands, "whitelist enable")
+		} else {
+			commands = append(commands, "whitelist disable")
+		}
+	}
+	return commands
+}
+
+// BanCommands returns the console commands that turn current into desired.
+// The console bans for good, so only the banned players are reconciled; a
+// changed reason or duration of an existing ban needs the server stopped.
+func BanCommands(current, desired []Ban) []string {
+	commands := []string{}
+	reasons := make(map[string]string, len(desired))
+	for _, ban := range desired {
+		reasons[ban.Target] = ban.Reason
+	}
+	added, removed := diff(targets(current), targets(desired))
+	for _, id := range removed {
+		commands = append(commands, "unban "+id)
+	}
+	for _, id := range added {
+		command := "ban " + id
+		if reason := strings.TrimSpace(reasons[id]); reason != "" {
+			command += " " + reason
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func targets(bans []Ban) []string {
+	ids := make([]string, 0, len(bans))
+	for _, ban := range bans {
+		ids = append(ids, ban.Target)
+	}
+	return ids
+}
+
+// diff returns the entries only in desired and those only in current, in
+// the order they are listed. UUIDs match whatever their case or form.
+func diff(current, desired []string) (added, removed []string) {
+	contains := func(list []string, id string) bool {
+		return slices.ContainsFunc(list, func(entry string) bool { return sameUUID(entry, id) })
+	}
+	for _, id := range desired {
+		if !contains(current, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range current {
+		if !contains(desired, id) {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+func sameUUID(a, b string) bool {
+	idA, errA := uuid.Parse(a)
+	idB, errB := uuid.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return idA == idB
+}
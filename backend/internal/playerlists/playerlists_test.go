@@ -0,0 +1,66 @@
+package playerlists
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const (
+	kweebec = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	trork   = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	feran   = "1b4e28ba-2fa1-11d2-883f-0016d3cca427"
+)
+
+func TestBansKeepUnknownFields(t *testing.T) {
+	bans, err := ParseBans([]byte(`[{"type":"timed","target":"` + strings.ToUpper(kweebec) + `","timestamp":1767225600000,"duration":86400000,"reason":"griefing"}]`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := NormalizeBans(bans); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	data, err := Encode(bans)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	for _, want := range []string{`"duration": 86400000`, `"target": "` + kweebec + `"`, `"reason": "griefing"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %s in\n%s", want, data)
+		}
+	}
+
+	twice := []Ban{{Target: kweebec}, {Target: strings.ToUpper(kweebec)}}
+	if err := NormalizeBans(twice); err == nil {
+		t.Fatal("expected a player banned twice to be rejected")
+	}
+	if err := NormalizeBans([]Ban{{Target: "Kweebec"}}); err == nil {
+		t.Fatal("expected a player name to be rejected")
+	}
+}
+
+func TestCommandsReconcileLists(t *testing.T) {
+	current, err := ParseWhitelist([]byte(`{"enabled": false, "list": ["` + strings.ToUpper(kweebec) + `", "` + trork + `"]}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	desired := &Whitelist{Enabled: true, List: []string{kweebec, feran, feran}}
+	if err := desired.Normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	want := []string{"whitelist remove " + trork, "whitelist add " + feran, "whitelist enable"}
+	if got := WhitelistCommands(current, desired); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	bans := BanCommands(
+		[]Ban{{Target: kweebec, Reason: "spam"}},
+		[]Ban{{Target: kweebec, Reason: "changed"}, {Target: trork, Reason: "griefing spawn"}},
+	)
+	if want := []string{"ban " + trork + " griefing spawn"}; fmt.Sprint(bans) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, bans)
+	}
+	if unban := BanCommands([]Ban{{Target: kweebec}}, nil); len(unban) != 1 || unban[0] != "unban "+kweebec {
+		t.Fatalf("expected an unban, got %v", unban)
+	}
+}
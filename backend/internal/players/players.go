@@ -0,0 +1,119 @@
+// Package players finds out who is online on a Hytale server from its
+// console, and builds the console commands that kick, ban and unban players
+// and change the whitelist.
+//
+// The server has no API for its player list, so the list is read back from
+// the console log after asking for it with ListCommand. Servers with a query
+// port answer the same question without the console; see package query.
+package players
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ListCommand asks the server to print the players online
+const ListCommand = "who"
+
+// Player is a player online on a server
+type Player struct {
+	Name string `json:"name"`
+	UUID string `json:"uuid,omitempty"`
+}
+
+var (
+	// logPrefix matches the timestamp, level and logger a console line starts
+	// with, e.g. "[2026/01/20 18:04:11   INFO]  [CommandManager] "
+	logPrefix = regexp.MustCompile(`^(\s*\[[^\]]*\]\s*)+`)
+	// listHeader matches the line announcing the list, e.g. "There are 2/20
+	// players online: a, b" or "Online players (2): a, b"
+	listHeader = regexp.MustCompile(`(?i)\bonline\b[^:]*:(.*)$`)
+	// playerEntry matches a name, optionally followed by the player's UUID
+	playerEntry = regexp.MustCompile(`^([A-Za-z0-9_]{1,32})(?:\s*[(\[]([0-9a-fA-F-]{32,36})[)\]])?$`)
+	// playerName is what a console command accepts as a player
+	playerName = regexp.MustCompile(`^[A-Za-z0-9_]{1,32}$`)
+)
+
+// ParseList reads the players out of the console output that followed
+// ListCommand. The last list in output wins. found is false when output holds
+// no list, such as when the server has not answered yet.
+func ParseList(output string) (list []Player, found bool) {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(logPrefix.ReplaceAllString(strings.TrimRight(line, "\r"), ""))
+		match := listHeader.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		found = true
+		list = []Player{}
+		for _, entry := range strings.Split(match[1], ",") {
+			parsed := playerEntry.FindStringSubmatch(strings.TrimSpace(entry))
+			if parsed == nil {
+				continue
+			}
+			player := Player{Name: parsed[1]}
+			if id, err := uuid.Parse(parsed[2]); err == nil {
+				player.UUID = id.String()
+			}
+			list = append(list, player)
+		}
+	}
+	return list, found
+}
+
+// Target checks a player named in a command, a name or a UUID, and returns
+// it in the form the console takes
+func Target(player string) (string, error) {
+	player = strings.TrimSpace(player)
+	if id, err := uuid.Parse(player); err == nil {
+		return id.String(), nil
+	}
+	if !playerName.MatchString(player) {
+		return "", fmt.Errorf("%q is not a player name or UUID", player)
+	}
+	return player, nil
+}
+
+// Reason checks a kick or ban reason, which ends the console command
+func Reason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if strings.ContainsAny(reason, "\r\n") {
+		return "", fmt.Errorf("reason must be a single line")
+	}
+	return reason, nil
+}
+
+// KickCommand disconnects a player
+func KickCommand(target, reason string) string {
+	return withReason("kick "+target, reason)
+}
+
+// BanCommand bans a player for good and disconnects them
+func BanCommand(target, reason string) string {
+	return withReason("ban "+target, reason)
+}
+
+// UnbanCommand lifts a player's ban
+func UnbanCommand(target string) string {
+	return "unban " + target
+}
+
+// WhitelistAddCommand lets a player join while the whitelist is on
+func WhitelistAddCommand(target string) string {
+	return "whitelist add " + target
+}
+
+// WhitelistRemoveCommand takes a player off the whitelist
+func WhitelistRemoveCommand(target string) string {
+	return "whitelist remove " + target
+}
+
+func withReason(command, reason string) string {
+	if reason == "" {
+		return command
+	}
+	return command + " " + reason
+}
@@ -0,0 +1,44 @@
+package players
+
+import "testing"
+
+func TestParseList(t *testing.T) {
+	output := "[2026/01/20 18:04:10   INFO]  [World|default] Saved\n" +
+		"[2026/01/20 18:04:11   INFO]  [CommandManager] There are 2/20 players online: Kweebec_1, Trork (7c9e6679-7425-40de-944b-e07fc1f90ae7)\r\n"
+	list, found := ParseList(output)
+	if !found || len(list) != 2 {
+		t.Fatalf("expected two players, got %+v (found %v)", list, found)
+	}
+	if list[0] != (Player{Name: "Kweebec_1"}) || list[1] != (Player{Name: "Trork", UUID: "7c9e6679-7425-40de-944b-e07fc1f90ae7"}) {
+		t.Fatalf("unexpected players %+v", list)
+	}
+
+	if list, found := ParseList("Online players (0):"); !found || len(list) != 0 {
+		t.Fatalf("expected an empty list, got %+v (found %v)", list, found)
+	}
+	if _, found := ParseList("[2026/01/20 18:04:10   INFO]  [World|default] Saved\n"); found {
+		t.Fatal("expected no list before the server answered")
+	}
+}
+
+func TestTargetAndReason(t *testing.T) {
+	for _, player := range []string{"Kweebec_1", "7C9E6679-7425-40DE-944B-E07FC1F90AE7"} {
+		if _, err := Target(player); err != nil {
+			t.Errorf("expected %q to be accepted: %v", player, err)
+		}
+	}
+	for _, player := range []string{"", "two words", "name;stop", "x\nstop"} {
+		if _, err := Target(player); err == nil {
+			t.Errorf("expected %q to be rejected", player)
+		}
+	}
+	if _, err := Reason("griefing\nstop"); err == nil {
+		t.Error("expected a multi-line reason to be rejected")
+	}
+	if got := BanCommand("Trork", "griefing"); got != "ban Trork griefing" {
+		t.Errorf("unexpected ban command %q", got)
+	}
+	if got := KickCommand("Trork", ""); got != "kick Trork" {
+		t.Errorf("unexpected kick command %q", got)
+	}
+}
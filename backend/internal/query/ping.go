@@ -0,0 +1,89 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Pong is a game port's answer to a ping
+type Pong struct {
+	Latency time.Duration
+	// Versions are the QUIC versions the server supports
+	Versions []uint32
+}
+
+// probeVersion is reserved for forcing version negotiation (RFC 9000 15):
+// no server supports it, so every server answers with the ones it does
+const probeVersion = 0x1a2a3a4a
+
+// QUIC requires datagrams carrying an Initial to be at least this long, and
+// servers drop shorter ones without answering
+const minDatagramSize = 1200
+
+const connectionIDLength = 8
+
+// Ping checks that a game port accepts QUIC connections
+func Ping(ctx context.Context, addr string) (*Pong, error) {
+	packet, dcid, scid := versionProbe()
+	reply, latency, err := exchange(ctx, addr, packet, func(reply []byte) bool {
+		_, err := parseVersionNegotiation(reply, dcid, scid)
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ping %s: %w", addr, err)
+	}
+	versions, _ := parseVersionNegotiation(reply, dcid, scid)
+	return &Pong{Latency: latency, Versions: versions}, nil
+}
+
+// versionProbe builds a long header packet with a version no server speaks,
+// padded to the minimum datagram size
+func versionProbe() (packet, dcid, scid []byte) {
+	packet = make([]byte, minDatagramSize)
+	rand.Read(packet)
+	packet[0] = 0xc0 | packet[0]&0x3f // long header, fixed bit
+	binary.BigEndian.PutUint32(packet[1:5], probeVersion)
+	packet[5] = connectionIDLength
+	dcid = packet[6 : 6+connectionIDLength]
+	packet[6+connectionIDLength] = connectionIDLength
+	scid = packet[7+connectionIDLength : 7+2*connectionIDLength]
+	return packet, dcid, scid
+}
+
+// parseVersionNegotiation reads the versions from a Version Negotiation
+// packet answering the probe with the given connection IDs, which the
+// server echoes back swapped
+func parseVersionNegotiation(reply, dcid, scid []byte) ([]uint32, error) {
+	if len(reply) < 7 || reply[0]&0x80 == 0 || binary.BigEndian.Uint32(reply[1:5]) != 0 {
+		return nil, fmt.Errorf("not a version negotiation packet")
+	}
+	rest := reply[5:]
+	readID := func() ([]byte, bool) {
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return nil, false
+		}
+		id := rest[1 : 1+int(rest[0])]
+		rest = rest[1+int(rest[0]):]
+		return id, true
+	}
+	replyDCID, ok := readID()
+	if !ok || !bytes.Equal(replyDCID, scid) {
+		return nil, fmt.Errorf("version negotiation for another connection")
+	}
+	replySCID, ok := readID()
+	if !ok || !bytes.Equal(replySCID, dcid) {
+		return nil, fmt.Errorf("version negotiation for another connection")
+	}
+	if len(rest) == 0 || len(rest)%4 != 0 {
+		return nil, fmt.Errorf("malformed version list")
+	}
+	versions := make([]uint32, 0, len(rest)/4)
+	for ; len(rest) > 0; rest = rest[4:] {
+		versions = append(versions, binary.BigEndian.Uint32(rest))
+	}
+	return versions, nil
+}
@@ -0,0 +1,133 @@
+// Package query asks a Hytale server how it is doing over its own UDP ports,
+// without SSH, the console or the agent.
+//
+// Ping sends a QUIC version negotiation probe to the game port. Hytale's game
+// port is a QUIC listener, and every QUIC listener answers a packet carrying
+// an unknown version with the versions it supports, so a reply shows the
+// server is up and accepting connections, and how long the round trip took.
+//
+// Query speaks the UT3 (GameSpy 4) full status protocol that server query
+// plugins serve on a port of their own, and adds the player count, the player
+// names, the version and the MOTD.
+package query
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultPort is the game's default UDP port
+const DefaultPort = 5520
+
+// DefaultTimeout bounds a probe whose context has no deadline
+const DefaultTimeout = 2 * time.Second
+
+// Sources of a status
+const (
+	SourceQuery = "query"
+	SourcePing  = "ping"
+)
+
+// Status is what a server's game ports said about it
+type Status struct {
+	Online    bool   `json:"online"`
+	Source    string `json:"source,omitempty"` // "query" or "ping"
+	Address   string `json:"address"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	// Players and the fields below are only known from a query
+	Players     int      `json:"players,omitempty"`
+	MaxPlayers  int      `json:"max_players,omitempty"`
+	PlayerNames []string `json:"player_names,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	MOTD        string   `json:"motd,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// Probe queries a server's query port when it has one and falls back to
+// pinging its game port. The server is offline when neither answers.
+func Probe(ctx context.Context, host string, port, queryPort int) Status {
+	if port <= 0 {
+		port = DefaultPort
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	status := Status{Address: address}
+
+	var queryErr error
+	if queryPort > 0 {
+		info, err := Query(ctx, net.JoinHostPort(host, strconv.Itoa(queryPort)))
+		if err == nil {
+			status.Online = true
+			status.Source = SourceQuery
+			status.LatencyMS = info.Latency.Milliseconds()
+			status.Players = info.Players
+			status.MaxPlayers = info.MaxPlayers
+			status.PlayerNames = info.PlayerNames
+			status.Version = info.Version
+			status.MOTD = info.MOTD
+			return status
+		}
+		queryErr = err
+	}
+
+	pong, err := Ping(ctx, address)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Online = true
+	status.Source = SourcePing
+	status.LatencyMS = pong.Latency.Milliseconds()
+	if queryErr != nil {
+		// Up, but the query plugin did not answer
+		status.Error = queryErr.Error()
+	}
+	return status
+}
+
+// exchange sends a request to addr and returns the first reply accept takes,
+// skipping stray datagrams
+func exchange(ctx context.Context, addr string, request []byte, accept func([]byte) bool) ([]byte, time.Duration, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, 0, err
+	}
+	// Unblock the read when the context is cancelled before its deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return nil, 0, errNoReply
+			}
+			// A refused port shows up as a read error on a connected socket
+			return nil, 0, err
+		}
+		if accept(buf[:n]) {
+			return buf[:n], time.Since(sent), nil
+		}
+	}
+}
+
+var errNoReply = errors.New("no reply")
@@ -0,0 +1,111 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serve answers each datagram on a local UDP port with what respond returns
+func serve(t *testing.T, respond func([]byte) [][]byte) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			for _, reply := range respond(append([]byte{}, buf[:n]...)) {
+				conn.WriteTo(reply, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestPingNegotiatesVersion(t *testing.T) {
+	port := serve(t, func(packet []byte) [][]byte {
+		if len(packet) < minDatagramSize {
+			return nil
+		}
+		dcid := packet[6 : 6+packet[5]]
+		scid := packet[7+len(dcid) : 7+len(dcid)+int(packet[6+len(dcid)])]
+		reply := []byte{0x80, 0, 0, 0, 0, byte(len(scid))}
+		reply = append(reply, scid...)
+		reply = append(reply, byte(len(dcid)))
+		reply = append(reply, dcid...)
+		reply = binary.BigEndian.AppendUint32(reply, 1)
+		// A reply for another connection comes first and must be skipped
+		stray := bytes.Clone(reply)
+		stray[6]++
+		return [][]byte{stray, reply}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	pong, err := Ping(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if len(pong.Versions) != 1 || pong.Versions[0] != 1 {
+		t.Fatalf("expected QUIC v1, got %v", pong.Versions)
+	}
+}
+
+func TestProbeQueriesFullStatus(t *testing.T) {
+	queryPort := serve(t, func(packet []byte) [][]byte {
+		if !bytes.HasPrefix(packet, queryMagic) {
+			return nil
+		}
+		session := packet[3:7]
+		switch packet[2] {
+		case packetHandshake:
+			return [][]byte{append(append([]byte{packetHandshake}, session...), "-4211\x00"...)}
+		case packetStat:
+			if len(packet) != 15 || int32(binary.BigEndian.Uint32(packet[7:11])) != -4211 {
+				return nil
+			}
+			reply := append([]byte{packetStat}, session...)
+			reply = append(reply, statPadding...)
+			reply = append(reply, "hostname\x00Orbis Survival\x00version\x002026.03.1\x00numplayers\x002\x00maxplayers\x0050\x00\x00"...)
+			reply = append(reply, playerHeader...)
+			reply = append(reply, "Kweebec\x00Trork\x00\x00"...)
+			return [][]byte{reply}
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	status := Probe(ctx, "127.0.0.1", 1, queryPort)
+	if !status.Online || status.Source != SourceQuery {
+		t.Fatalf("expected the query to answer, got %+v", status)
+	}
+	if status.Players != 2 || status.MaxPlayers != 50 || status.Version != "2026.03.1" || status.MOTD != "Orbis Survival" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if len(status.PlayerNames) != 2 || status.PlayerNames[1] != "Trork" {
+		t.Fatalf("unexpected players %v", status.PlayerNames)
+	}
+}
+
+func TestProbeReportsOfflineServer(t *testing.T) {
+	silent := serve(t, func([]byte) [][]byte { return nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	status := Probe(ctx, "127.0.0.1", silent, 0)
+	if status.Online || status.Error == "" {
+		t.Fatalf("expected an offline server with an error, got %+v", status)
+	}
+}
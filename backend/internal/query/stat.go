@@ -0,0 +1,117 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info is a query port's full status
+type Info struct {
+	Latency     time.Duration
+	MOTD        string
+	Version     string
+	Players     int
+	MaxPlayers  int
+	PlayerNames []string
+	// Fields holds every key the server sent, including the ones above
+	Fields map[string]string
+}
+
+// UT3 packet types
+const (
+	packetStat      = 0x00
+	packetHandshake = 0x09
+)
+
+var (
+	queryMagic   = []byte{0xfe, 0xfd}
+	statPadding  = []byte("splitnum\x00\x80\x00")
+	playerHeader = []byte("\x01player_\x00\x00")
+)
+
+// Query asks a query port for the server's full status. It takes a handshake
+// for a challenge token, then the status itself.
+func Query(ctx context.Context, addr string) (*Info, error) {
+	session := sessionID()
+
+	reply, _, err := exchange(ctx, addr, queryPacket(packetHandshake, session, nil), isReply(packetHandshake, session))
+	if err != nil {
+		return nil, fmt.Errorf("query %s: handshake: %w", addr, err)
+	}
+	token, err := strconv.ParseInt(string(bytes.TrimRight(reply[5:], "\x00")), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: invalid challenge token", addr)
+	}
+
+	// The token goes first, then four bytes asking for the full status
+	payload := binary.BigEndian.AppendUint32(nil, uint32(int32(token)))
+	payload = append(payload, 0, 0, 0, 0)
+	reply, latency, err := exchange(ctx, addr, queryPacket(packetStat, session, payload), isReply(packetStat, session))
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", addr, err)
+	}
+	info, err := parseStat(reply[5:])
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", addr, err)
+	}
+	info.Latency = latency
+	return info, nil
+}
+
+// sessionID picks a session; only the low nibble of each byte is used, as
+// some servers mask the rest
+func sessionID() []byte {
+	session := make([]byte, 4)
+	rand.Read(session)
+	for i := range session {
+		session[i] &= 0x0f
+	}
+	return session
+}
+
+func queryPacket(kind byte, session, payload []byte) []byte {
+	packet := append(append([]byte{}, queryMagic...), kind)
+	packet = append(packet, session...)
+	return append(packet, payload...)
+}
+
+func isReply(kind byte, session []byte) func([]byte) bool {
+	return func(reply []byte) bool {
+		return len(reply) > 5 && reply[0] == kind && bytes.Equal(reply[1:5], session)
+	}
+}
+
+// parseStat reads the key/value section and the player list of a full status
+func parseStat(data []byte) (*Info, error) {
+	data = bytes.TrimPrefix(data, statPadding)
+	values, players, found := bytes.Cut(data, playerHeader)
+	if !found {
+		return nil, fmt.Errorf("malformed status: no player list")
+	}
+
+	info := &Info{Fields: make(map[string]string), PlayerNames: []string{}}
+	fields := strings.Split(string(values), "\x00")
+	for i := 0; i+1 < len(fields) && fields[i] != ""; i += 2 {
+		info.Fields[fields[i]] = fields[i+1]
+	}
+	for _, name := range strings.Split(string(players), "\x00") {
+		if name != "" {
+			info.PlayerNames = append(info.PlayerNames, name)
+		}
+	}
+
+	info.MOTD = info.Fields["hostname"]
+	info.Version = info.Fields["version"]
+	info.Players, _ = strconv.Atoi(info.Fields["numplayers"])
+	info.MaxPlayers, _ = strconv.Atoi(info.Fields["maxplayers"])
+	if info.Players < len(info.PlayerNames) {
+		info.Players = len(info.PlayerNames)
+	}
+	return info, nil
+}
@@ -0,0 +1,253 @@
+package releases
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Deployment statuses. A server has at most one active deployment; the one
+// it replaced is superseded and is what a rollback returns to.
+const (
+	DeploymentDeploying  = "deploying"
+	DeploymentActive     = "active"
+	DeploymentSuperseded = "superseded"
+	DeploymentRolledBack = "rolled_back"
+	DeploymentFailed     = "failed"
+	DeploymentPruned     = "pruned" // its release directory was removed
+)
+
+// ErrNoRollbackTarget is returned when a server has no earlier release to
+// roll back to
+var ErrNoRollbackTarget = errors.New("no previous release to roll back to")
+
+// Deployment is a release deployed to a server, kept in its own directory
+// under <install_dir>/releases
+type Deployment struct {
+	ID           int64      `json:"id"`
+	ServerID     string     `json:"server_id"`
+	PackageName  string     `json:"package_name"`
+	SHA256       string     `json:"sha256,omitempty"`
+	InstallDir   string     `json:"install_dir"`
+	ReleaseDir   string     `json:"release_dir"`
+	ServiceUser  string     `json:"service_user"`
+	UseSudo      bool       `json:"use_sudo"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	DeployedBy   *int64     `json:"deployed_by,omitempty"` // user ID
+	DeployedAt   time.Time  `json:"deployed_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	RolledBackAt *time.Time `json:"rolled_back_at,omitempty"`
+}
+
+var releaseDirUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ReleaseDirName names the directory a package deployed at the given time is
+// extracted to; names sort by deployment time
+func ReleaseDirName(packageName string, at time.Time) string {
+	name := strings.Trim(releaseDirUnsafe.ReplaceAllString(packageName, "-"), "-.")
+	if name == "" {
+		name = "release"
+	}
+	return at.UTC().Format("20060102T150405Z") + "-" + name
+}
+
+// StartDeployment records a deployment that is about to run
+func (m *Manager) StartDeployment(d *Deployment) error {
+	if m.db == nil {
+		return nil
+	}
+	d.Status = DeploymentDeploying
+	if d.DeployedAt.IsZero() {
+		d.DeployedAt = time.Now().UTC()
+	}
+	result, err := m.db.Exec(`
+		INSERT INTO deployments (server_id, package_name, sha256, install_dir, release_dir, service_user, use_sudo, status, deployed_by, deployed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, d.ServerID, d.PackageName, d.SHA256, d.InstallDir, d.ReleaseDir, d.ServiceUser, boolToInt(d.UseSudo), d.Status, d.DeployedBy, d.DeployedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record deployment: %w", err)
+	}
+	d.ID, err = result.LastInsertId()
+	return err
+}
+
+// FinishDeployment marks a deployment active, superseding the server's
+// previous one, or failed when deployErr is set
+func (m *Manager) FinishDeployment(d *Deployment, deployErr error) error {
+	if m.db == nil || d.ID == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	if deployErr != nil {
+		d.Status = DeploymentFailed
+		d.Error = deployErr.Error()
+		d.FinishedAt = &now
+		_, err := m.db.Exec(`UPDATE deployments SET status = ?, error = ?, finished_at = ? WHERE id = ?`,
+			d.Status, d.Error, now, d.ID)
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE deployments SET status = ? WHERE server_id = ? AND status = ? AND id != ?`,
+		DeploymentSuperseded, d.ServerID, DeploymentActive, d.ID); err != nil {
+		return fmt.Errorf("failed to supersede deployment: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE deployments SET status = ?, finished_at = ? WHERE id = ?`,
+		DeploymentActive, now, d.ID); err != nil {
+		return fmt.Errorf("failed to activate deployment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.Status = DeploymentActive
+	d.FinishedAt = &now
+	return nil
+}
+
+// ListDeployments returns a server's deployments, newest first
+func (m *Manager) ListDeployments(serverID string, limit int) ([]*Deployment, error) {
+	if m.db == nil {
+		return []*Deployment{}, nil
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	return m.queryDeployments(`WHERE server_id = ? ORDER BY id DESC LIMIT ?`, serverID, limit)
+}
+
+// ActiveDeployment returns a server's active deployment, or nil when it has
+// none
+func (m *Manager) ActiveDeployment(serverID string) (*Deployment, error) {
+	if m.db == nil {
+		return nil, nil
+	}
+	active, err := m.queryDeployments(`WHERE server_id = ? AND status = ? ORDER BY id DESC LIMIT 1`, serverID, DeploymentActive)
+	if err != nil || len(active) == 0 {
+		return nil, err
+	}
+	return active[0], nil
+}
+
+// RollbackTarget returns a server's active deployment and the newest
+// superseded one in the same install dir, the release a rollback returns to
+func (m *Manager) RollbackTarget(serverID string) (*Deployment, *Deployment, error) {
+	if m.db == nil {
+		return nil, nil, ErrNoRollbackTarget
+	}
+	active, err := m.queryDeployments(`WHERE server_id = ? AND status = ? ORDER BY id DESC LIMIT 1`, serverID, DeploymentActive)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(active) == 0 {
+		return nil, nil, ErrNoRollbackTarget
+	}
+	previous, err := m.queryDeployments(`WHERE server_id = ? AND install_dir = ? AND status = ? ORDER BY id DESC LIMIT 1`,
+		serverID, active[0].InstallDir, DeploymentSuperseded)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(previous) == 0 {
+		return nil, nil, ErrNoRollbackTarget
+	}
+	return active[0], previous[0], nil
+}
+
+// CompleteRollback marks active as rolled back and previous as active again
+func (m *Manager) CompleteRollback(active, previous *Deployment) error {
+	if m.db == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE deployments SET status = ?, rolled_back_at = ? WHERE id = ?`,
+		DeploymentRolledBack, now, active.ID); err != nil {
+		return fmt.Errorf("failed to record rollback: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE deployments SET status = ? WHERE id = ?`, DeploymentActive, previous.ID); err != nil {
+		return fmt.Errorf("failed to record rollback: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	active.Status, active.RolledBackAt = DeploymentRolledBack, &now
+	previous.Status = DeploymentActive
+	return nil
+}
+
+// PruneCandidates returns the releases of a server's install dir to remove
+// when a new one is deployed, so that keep earlier releases remain. The
+// active release is kept first, then superseded ones, then rolled back ones.
+func (m *Manager) PruneCandidates(serverID, installDir string, keep int) ([]*Deployment, error) {
+	if m.db == nil {
+		return []*Deployment{}, nil
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	return m.queryDeployments(`WHERE server_id = ? AND install_dir = ? AND status IN (?, ?, ?)
+		ORDER BY CASE status WHEN ? THEN 0 WHEN ? THEN 1 ELSE 2 END, id DESC LIMIT -1 OFFSET ?`,
+		serverID, installDir, DeploymentActive, DeploymentSuperseded, DeploymentRolledBack, DeploymentActive, DeploymentSuperseded, keep)
+}
+
+// MarkPruned records that the release directories of deployments were removed
+func (m *Manager) MarkPruned(deployments []*Deployment) error {
+	if m.db == nil {
+		return nil
+	}
+	for _, d := range deployments {
+		if _, err := m.db.Exec(`UPDATE deployments SET status = ? WHERE id = ?`, DeploymentPruned, d.ID); err != nil {
+			return fmt.Errorf("failed to mark deployment pruned: %w", err)
+		}
+		d.Status = DeploymentPruned
+	}
+	return nil
+}
+
+func (m *Manager) queryDeployments(where string, args ...interface{}) ([]*Deployment, error) {
+	rows, err := m.db.Query(`
+		SELECT id, server_id, package_name, sha256, install_dir, release_dir, service_user, use_sudo, status, error, deployed_by, deployed_at, finished_at, rolled_back_at
+		FROM deployments `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	defer rows.Close()
+
+	deployments := make([]*Deployment, 0)
+	for rows.Next() {
+		var (
+			d                                    Deployment
+			sha, deployErr                       sql.NullString
+			deployedBy                           sql.NullInt64
+			deployedAt, finishedAt, rolledBackAt sql.NullTime
+		)
+		if err := rows.Scan(&d.ID, &d.ServerID, &d.PackageName, &sha, &d.InstallDir, &d.ReleaseDir, &d.ServiceUser, &d.UseSudo,
+			&d.Status, &deployErr, &deployedBy, &deployedAt, &finishedAt, &rolledBackAt); err != nil {
+			return nil, fmt.Errorf("failed to scan deployment: %w", err)
+		}
+		d.SHA256, d.Error = sha.String, deployErr.String
+		if deployedBy.Valid {
+			d.DeployedBy = &deployedBy.Int64
+		}
+		d.DeployedAt = deployedAt.Time
+		if finishedAt.Valid {
+			d.FinishedAt = &finishedAt.Time
+		}
+		if rolledBackAt.Valid {
+			d.RolledBackAt = &rolledBackAt.Time
+		}
+		deployments = append(deployments, &d)
+	}
+	return deployments, rows.Err()
+}
@@ -0,0 +1,88 @@
+package releases
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestDeploymentRollbackAndPrune(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "deployments.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	manager := NewManager(nil, db)
+
+	deploy := func(name string, deployErr error) *Deployment {
+		t.Helper()
+		d := &Deployment{ServerID: "alpha", PackageName: name, InstallDir: "/srv/hytale", ReleaseDir: ReleaseDirName(name, time.Now()), ServiceUser: "hytale"}
+		if err := manager.StartDeployment(d); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+		if err := manager.FinishDeployment(d, deployErr); err != nil {
+			t.Fatalf("finish: %v", err)
+		}
+		return d
+	}
+
+	first := deploy("first", nil)
+	if _, _, err := manager.RollbackTarget("alpha"); !errors.Is(err, ErrNoRollbackTarget) {
+		t.Fatalf("expected no rollback target, got %v", err)
+	}
+	second := deploy("second", nil)
+	deploy("broken", errors.New("unzip failed"))
+	third := deploy("third", nil)
+
+	active, previous, err := manager.RollbackTarget("alpha")
+	if err != nil || active.ID != third.ID || previous.ID != second.ID {
+		t.Fatalf("expected a rollback from third to second, got %+v %+v %v", active, previous, err)
+	}
+	if err := manager.CompleteRollback(active, previous); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	active, previous, err = manager.RollbackTarget("alpha")
+	if err != nil || active.ID != second.ID || previous.ID != first.ID {
+		t.Fatalf("expected a rollback from second to first, got %+v %+v %v", active, previous, err)
+	}
+
+	// Keeping one earlier release keeps the active one, even though the
+	// release rolled back from is newer
+	pruned, err := manager.PruneCandidates("alpha", "/srv/hytale", 1)
+	if err != nil {
+		t.Fatalf("prune candidates: %v", err)
+	}
+	if len(pruned) != 2 || pruned[0].ID != first.ID || pruned[1].ID != third.ID {
+		t.Fatalf("expected first and third to be pruned, got %+v", pruned)
+	}
+	if err := manager.MarkPruned(pruned); err != nil {
+		t.Fatalf("mark pruned: %v", err)
+	}
+
+	list, err := manager.ListDeployments("alpha", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	statuses := make([]string, 0, len(list))
+	for _, d := range list {
+		statuses = append(statuses, d.Status)
+	}
+	want := []string{DeploymentPruned, DeploymentFailed, DeploymentActive, DeploymentPruned}
+	if len(statuses) != len(want) {
+		t.Fatalf("expected %v, got %v", want, statuses)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, statuses)
+		}
+	}
+	if list[1].Error != "unzip failed" {
+		t.Fatalf("expected the failure to be recorded, got %q", list[1].Error)
+	}
+}
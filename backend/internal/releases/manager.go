@@ -5,12 +5,14 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -43,10 +45,14 @@ type Job struct {
 }
 
 type StreamEvent struct {
-	Event string
-	Data  string
+	Event string `json:"event"`
+	Data  string `json:"data"`
 }
 
+// sharedPersistInterval bounds how often a shared job's output is written
+// while it runs
+const sharedPersistInterval = time.Second
+
 type Release struct {
 	ID               int64     `json:"id"`
 	Version          string    `json:"version"`
@@ -68,17 +74,35 @@ type Manager struct {
 	mu   sync.Mutex
 	jobs map[string]*Job
 	subs map[string]map[chan StreamEvent]struct{}
+
+	// relay is set when jobs are shared with other manager instances
+	relay     func(jobID string, event StreamEvent)
+	persisted map[string]time.Time
 }
 
 func NewManager(cfg *config.Config, db *database.DB) *Manager {
 	return &Manager{
-		cfg:  cfg,
-		db:   db,
-		jobs: make(map[string]*Job),
-		subs: make(map[string]map[chan StreamEvent]struct{}),
+		cfg:       cfg,
+		db:        db,
+		jobs:      make(map[string]*Job),
+		subs:      make(map[string]map[chan StreamEvent]struct{}),
+		persisted: make(map[string]time.Time),
 	}
 }
 
+// Share keeps the output of running jobs in the database and passes job
+// events to relay, so other manager instances can serve the jobs this one
+// runs. It must be called before jobs are created.
+func (m *Manager) Share(relay func(jobID string, event StreamEvent)) {
+	m.relay = relay
+}
+
+// Deliver passes an event relayed from another manager instance to the
+// subscribers of the job on this one
+func (m *Manager) Deliver(jobID string, event StreamEvent) {
+	m.emit(jobID, event)
+}
+
 func (m *Manager) CreateJob(action string) *Job {
 	job := &Job{
 		ID:        fmt.Sprintf("job-%d", time.Now().UnixNano()),
@@ -101,12 +125,24 @@ func (m *Manager) CreateJob(action string) *Job {
 
 func (m *Manager) GetJob(id string) (*Job, bool) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 	job, ok := m.jobs[id]
+	m.mu.Unlock()
+	if !ok && m.relay != nil {
+		// Run by another manager instance
+		job, err := m.loadJob(id)
+		return job, err == nil
+	}
 	return job, ok
 }
 
 func (m *Manager) ListJobs(limit int) []*Job {
+	if m.relay != nil {
+		jobs, err := m.loadJobs(limit)
+		if err == nil {
+			return jobs
+		}
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	jobs := make([]*Job, 0, len(m.jobs))
@@ -122,12 +158,20 @@ func (m *Manager) ListJobs(limit int) []*Job {
 func (m *Manager) AppendOutput(job *Job, line string) {
 	m.mu.Lock()
 	job.Output = append(job.Output, line)
+	neededAuth := job.NeedsAuth
 	m.parseAuthPrompt(job, line)
+	persist := m.relay != nil && (job.NeedsAuth != neededAuth || time.Since(m.persisted[job.ID]) >= sharedPersistInterval)
+	if persist {
+		m.persisted[job.ID] = time.Now()
+	}
 	m.mu.Unlock()
-	m.emit(job.ID, StreamEvent{Event: "log", Data: line})
+	m.publish(job.ID, StreamEvent{Event: "log", Data: line})
 	if job.NeedsAuth {
 		payload := fmt.Sprintf("{\"auth_url\":\"%s\",\"auth_code\":\"%s\"}", escapeJSON(job.AuthURL), escapeJSON(job.AuthCode))
-		m.emit(job.ID, StreamEvent{Event: "auth", Data: payload})
+		m.publish(job.ID, StreamEvent{Event: "auth", Data: payload})
+	}
+	if persist {
+		_ = m.updateJob(job)
 	}
 }
 
@@ -144,8 +188,11 @@ func (m *Manager) SetStatus(job *Job, status JobStatus, err error) {
 			job.Error = err.Error()
 		}
 	}
+	if status == StatusFailed || status == StatusComplete {
+		delete(m.persisted, job.ID)
+	}
 	m.mu.Unlock()
-	m.emit(job.ID, StreamEvent{Event: "status", Data: string(status)})
+	m.publish(job.ID, StreamEvent{Event: "status", Data: string(status)})
 
 	_ = m.updateJob(job)
 }
@@ -169,6 +216,14 @@ func (m *Manager) Subscribe(jobID string) (chan StreamEvent, func()) {
 	}
 }
 
+// publish sends an event to the job's subscribers on every manager instance
+func (m *Manager) publish(jobID string, event StreamEvent) {
+	m.emit(jobID, event)
+	if m.relay != nil {
+		m.relay(jobID, event)
+	}
+}
+
 func (m *Manager) emit(jobID string, event StreamEvent) {
 	m.mu.Lock()
 	subs := m.subs[jobID]
@@ -340,32 +395,46 @@ func (m *Manager) UpdateRelease(release *Release) error {
 	return err
 }
 
-func (m *Manager) ListReleases(limit int, includeRemoved bool) ([]*Release, error) {
+// ListReleases returns one page of releases, newest first
+func (m *Manager) ListReleases(includeRemoved bool, page database.PageRequest) ([]*Release, database.PageInfo, error) {
 	if m.db == nil {
-		return []*Release{}, nil
+		return []*Release{}, database.PageInfo{Limit: page.Limit}, nil
+	}
+
+	where := "1=1"
+	args := []interface{}{}
+	if !includeRemoved {
+		where += " AND removed = 0"
+	}
+
+	var total int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM releases WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, database.PageInfo{}, err
+	}
+
+	if page.After != "" {
+		afterID, err := strconv.ParseInt(page.After, 10, 64)
+		if err != nil {
+			return nil, database.PageInfo{}, database.ErrInvalidCursor
+		}
+		where += " AND id < ?"
+		args = append(args, afterID)
 	}
 
 	query := `
 		SELECT id, version, patchline, file_path, file_size, sha256, downloader_version, downloaded_at, status, source, removed
 		FROM releases
-		ORDER BY downloaded_at DESC
+		WHERE ` + where + `
+		ORDER BY id DESC
 	`
-	if !includeRemoved {
-		query = strings.Replace(query, "FROM releases", "FROM releases\n\t\tWHERE removed = 0", 1)
-	}
-	if limit > 0 {
+	if limit := page.QueryLimit(); limit > 0 {
 		query += " LIMIT ?"
+		args = append(args, limit)
 	}
 
-	var rows *sql.Rows
-	var err error
-	if limit > 0 {
-		rows, err = m.db.Query(query, limit)
-	} else {
-		rows, err = m.db.Query(query)
-	}
+	rows, err := m.db.Query(query, args...)
 	if err != nil {
-		return nil, err
+		return nil, database.PageInfo{}, err
 	}
 	defer rows.Close()
 
@@ -379,7 +448,14 @@ func (m *Manager) ListReleases(limit int, includeRemoved bool) ([]*Release, erro
 		release.Removed = removed != 0
 		releases = append(releases, release)
 	}
-	return releases, nil
+
+	count, hasMore := page.Trim(len(releases))
+	releases = releases[:count]
+	lastKey := ""
+	if count > 0 {
+		lastKey = strconv.FormatInt(releases[count-1].ID, 10)
+	}
+	return releases, page.Info(total, hasMore, lastKey), nil
 }
 
 func (m *Manager) GetRelease(id int64) (*Release, error) {
@@ -465,6 +541,32 @@ func (m *Manager) GetReleaseByVersionPatchline(version string, patchline string)
 	return release, nil
 }
 
+// LatestRelease returns the newest release of a patchline that is ready to
+// deploy, or nil when it has none
+func (m *Manager) LatestRelease(patchline string) (*Release, error) {
+	if m.db == nil {
+		return nil, fmt.Errorf("database not available")
+	}
+	row := m.db.QueryRow(`
+		SELECT id, version, patchline, file_path, file_size, sha256, downloader_version, downloaded_at, status, source, removed
+		FROM releases
+		WHERE patchline = ? AND status = 'ready' AND removed = 0
+		ORDER BY downloaded_at DESC, id DESC
+		LIMIT 1
+	`, patchline)
+	release := &Release{}
+	var removed int
+	err := row.Scan(&release.ID, &release.Version, &release.Patchline, &release.FilePath, &release.FileSize, &release.SHA256, &release.DownloaderVersion, &release.DownloadedAt, &release.Status, &release.Source, &removed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	release.Removed = removed != 0
+	return release, nil
+}
+
 func (m *Manager) parseAuthPrompt(job *Job, line string) {
 	trimmed := strings.TrimSpace(line)
 	if trimmed == "" {
@@ -504,15 +606,78 @@ func (m *Manager) updateJob(job *Job) error {
 	if m.db == nil {
 		return nil
 	}
+	m.mu.Lock()
 	output := strings.Join(job.Output, "\n")
+	status, startedAt, finishedAt, jobErr := job.Status, job.StartedAt, job.FinishedAt, job.Error
+	needsAuth, authURL, authCode := job.NeedsAuth, job.AuthURL, job.AuthCode
+	m.mu.Unlock()
 	_, err := m.db.Exec(`
 		UPDATE release_jobs
-		SET status = ?, started_at = ?, finished_at = ?, output = ?, error = ?
+		SET status = ?, started_at = ?, finished_at = ?, output = ?, error = ?, needs_auth = ?, auth_url = ?, auth_code = ?
 		WHERE id = ?
-	`, job.Status, job.StartedAt, job.FinishedAt, output, job.Error, job.ID)
+	`, status, startedAt, finishedAt, output, jobErr, needsAuth, authURL, authCode, job.ID)
 	return err
 }
 
+const jobColumns = `id, action, status, created_at, started_at, finished_at, output, error, needs_auth, auth_url, auth_code`
+
+// loadJob reads a job another manager instance runs or ran
+func (m *Manager) loadJob(id string) (*Job, error) {
+	if m.db == nil {
+		return nil, sql.ErrNoRows
+	}
+	return scanJob(m.db.QueryRow(`SELECT `+jobColumns+` FROM release_jobs WHERE id = ?`, id))
+}
+
+// loadJobs reads the most recent jobs of every manager instance
+func (m *Manager) loadJobs(limit int) ([]*Job, error) {
+	if m.db == nil {
+		return nil, sql.ErrNoRows
+	}
+	rows, err := m.db.Query(`SELECT `+jobColumns+` FROM release_jobs ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*Job, 0, limit)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func scanJob(row interface{ Scan(...interface{}) error }) (*Job, error) {
+	job := &Job{Output: []string{}}
+	var (
+		startedAt, finishedAt         sql.NullTime
+		output, jobErr, authURL, code sql.NullString
+		needsAuth                     sql.NullBool
+	)
+	if err := row.Scan(&job.ID, &job.Action, &job.Status, &job.CreatedAt, &startedAt, &finishedAt,
+		&output, &jobErr, &needsAuth, &authURL, &code); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if output.String != "" {
+		job.Output = strings.Split(output.String, "\n")
+	}
+	job.Error = jobErr.String
+	job.NeedsAuth = needsAuth.Bool
+	job.AuthURL = authURL.String
+	job.AuthCode = code.String
+	return job, nil
+}
+
 func escapeJSON(value string) string {
 	value = strings.ReplaceAll(value, "\\", "\\\\")
 	value = strings.ReplaceAll(value, "\"", "\\\"")
@@ -0,0 +1,51 @@
+package releases
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ServerJarPath is where a server package keeps the server, relative to the
+// root of the archive
+const ServerJarPath = "Server/HytaleServer.jar"
+
+// PackageName is the name a deploy picks the release by: its file name
+// without the extension
+func (r *Release) PackageName() string {
+	base := filepath.Base(r.FilePath)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// ErrInvalidPackage is returned for an archive that is not a server package
+var ErrInvalidPackage = errors.New("invalid server package")
+
+// ValidatePackage checks that the zip at zipPath is a server package a deploy
+// can extract: it contains Server/HytaleServer.jar, and no entry would land
+// outside the directory it is extracted to
+func ValidatePackage(zipPath string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("%w: not a zip archive: %v", ErrInvalidPackage, err)
+	}
+	defer reader.Close()
+
+	hasServer := false
+	for _, file := range reader.File {
+		name := strings.ReplaceAll(file.Name, `\`, "/")
+		clean := path.Clean(name)
+		if path.IsAbs(name) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("%w: entry %q is outside the archive root", ErrInvalidPackage, file.Name)
+		}
+		if clean == ServerJarPath && !file.FileInfo().IsDir() {
+			hasServer = true
+		}
+	}
+	if !hasServer {
+		return fmt.Errorf("%w: %s is missing", ErrInvalidPackage, ServerJarPath)
+	}
+	return nil
+}
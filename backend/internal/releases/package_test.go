@@ -0,0 +1,52 @@
+package releases
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeZip(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "package.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	for _, name := range names {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte("content"))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	return path
+}
+
+func TestValidatePackage(t *testing.T) {
+	if err := ValidatePackage(writeZip(t, "Assets.zip", "Server/HytaleServer.jar", "Server/Licenses/README.txt")); err != nil {
+		t.Fatalf("expected a server package to be accepted, got %v", err)
+	}
+	if err := ValidatePackage(writeZip(t, "./Server/HytaleServer.jar")); err != nil {
+		t.Fatalf("expected a leading ./ to be accepted, got %v", err)
+	}
+
+	for name, path := range map[string]string{
+		"missing jar": writeZip(t, "Assets.zip", "HytaleServer.jar"),
+		"nested":      writeZip(t, "hytale/Server/HytaleServer.jar"),
+		"escaping":    writeZip(t, "Server/HytaleServer.jar", "../evil.sh"),
+		"absolute":    writeZip(t, "Server/HytaleServer.jar", "/etc/cron.d/evil"),
+		"not a zip":   filepath.Join(t.TempDir(), "missing.zip"),
+	} {
+		if err := ValidatePackage(path); !errors.Is(err, ErrInvalidPackage) {
+			t.Errorf("%s: expected the package to be refused, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Task types a schedule can run
+const (
+	TypeCommand = "command"
+	TypeRestart = "restart"
+	TypeStop    = "stop"
+	TypeStart   = "start"
+	TypeBackup  = "backup"
+	TypeScript  = "script"
+	TypeWebhook = "webhook"
+)
+
+// Overlap policies decide what happens when a schedule comes due while its
+// previous run is still going
+const (
+	OverlapSkip  = "skip"  // the new run is recorded as skipped
+	OverlapQueue = "queue" // one run waits for the current one to finish
+	OverlapAllow = "allow" // runs proceed side by side
+)
+
+// Run statuses
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+
+	// StatusQueued is reported for a run waiting behind the current one; it
+	// is recorded once the run starts
+	StatusQueued = "queued"
+)
+
+// What started a run
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// cronParser accepts the same syntax as the backup and self-backup schedules
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Schedule is a task that runs on a cron expression. Params holds the
+// type-specific settings, see CommandParams and the other *Params types.
+type Schedule struct {
+	ID            string          `json:"id"`
+	Name          string          `json:"name"`
+	ServerID      string          `json:"server_id,omitempty"`
+	Type          string          `json:"type"`
+	Cron          string          `json:"cron"`
+	Enabled       bool            `json:"enabled"`
+	OverlapPolicy string          `json:"overlap_policy"`
+	Params        json.RawMessage `json:"params"`
+	LastRun       *time.Time      `json:"last_run,omitempty"`
+	NextRun       *time.Time      `json:"next_run,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// Run is one execution of a schedule
+type Run struct {
+	ID          int64      `json:"id"`
+	ScheduleID  string     `json:"schedule_id"`
+	TriggeredBy string     `json:"triggered_by"`
+	Status      string     `json:"status"`
+	Output      string     `json:"output,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// CommandParams sends a console command to the server
+type CommandParams struct {
+	Command string `json:"command"`
+}
+
+// RestartParams restarts the server, gracefully unless Graceful is false
+type RestartParams struct {
+	Graceful *bool `json:"graceful,omitempty"`
+	PowerParams
+}
+
+// StopParams stops the server, gracefully unless Graceful is false
+type StopParams struct {
+	Graceful *bool `json:"graceful,omitempty"`
+	PowerParams
+}
+
+// StartParams starts the server; a server already running is left alone
+type StartParams struct{}
+
+// PowerParams are the settings restart and stop tasks share
+type PowerParams struct {
+	// Warnings are how long before a graceful stop players are warned, e.g.
+	// ["10m", "1m", "10s"]. The countdown starts when the task runs.
+	Warnings []string `json:"warnings,omitempty"`
+	// WarningMessage is said in game at each warning, with {time} replaced
+	// by the time left
+	WarningMessage string `json:"warning_message,omitempty"`
+	// SkipIfOffline leaves a server that is not running alone instead of
+	// starting it (restart) or reporting it stopped (stop)
+	SkipIfOffline bool `json:"skip_if_offline,omitempty"`
+}
+
+// DefaultWarningMessage is said in game before a scheduled restart or stop
+const DefaultWarningMessage = "Server shutting down in {time}"
+
+// maxWarning bounds how far ahead of a stop a warning may be
+const maxWarning = time.Hour
+
+// WarningDelays parses Warnings, longest first
+func (p PowerParams) WarningDelays() ([]time.Duration, error) {
+	delays := make([]time.Duration, 0, len(p.Warnings))
+	for _, value := range p.Warnings {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 || d > maxWarning {
+			return nil, fmt.Errorf("invalid warning %q: must be a duration up to %s", value, maxWarning)
+		}
+		delays = append(delays, d)
+	}
+	sort.Slice(delays, func(i, j int) bool { return delays[i] > delays[j] })
+	return delays, nil
+}
+
+// Message returns the warning said when left remains before the stop
+func (p PowerParams) Message(left time.Duration) string {
+	message := p.WarningMessage
+	if strings.TrimSpace(message) == "" {
+		message = DefaultWarningMessage
+	}
+	return strings.ReplaceAll(message, "{time}", formatLeft(left))
+}
+
+// formatLeft spells out a countdown for players, e.g. "5 minutes"
+func formatLeft(d time.Duration) string {
+	unit, size := "second", time.Second
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, size = "hour", time.Hour
+	case d >= time.Minute && d%time.Minute == 0:
+		unit, size = "minute", time.Minute
+	}
+	n := int64(d / size)
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// BackupParams runs a backup schedule's settings, or the server's backup
+// defaults when BackupScheduleID is empty
+type BackupParams struct {
+	BackupScheduleID string `json:"backup_schedule_id,omitempty"`
+}
+
+// ScriptParams runs a shell script on the server's host, in its working directory
+type ScriptParams struct {
+	Script  string `json:"script"`
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// WebhookParams sends an HTTP request
+type WebhookParams struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+}
+
+// BackupEntryID returns the ID of the schedule that runs a backup schedule
+func BackupEntryID(backupScheduleID string) string {
+	return "backup-" + backupScheduleID
+}
+
+// NextRun returns the first time after from that expr fires
+func NextRun(expr string, from time.Time) (time.Time, error) {
+	parsed, err := cronParser.Parse(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parsed.Next(from), nil
+}
+
+// DecodeParams unmarshals the schedule's params into v
+func (s *Schedule) DecodeParams(v any) error {
+	if len(s.Params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(s.Params, v); err != nil {
+		return fmt.Errorf("invalid params for %s schedule: %w", s.Type, err)
+	}
+	return nil
+}
+
+// Normalize fills in defaults for omitted fields
+func (s *Schedule) Normalize() {
+	s.Name = strings.TrimSpace(s.Name)
+	s.ServerID = strings.TrimSpace(s.ServerID)
+	s.Type = strings.ToLower(strings.TrimSpace(s.Type))
+	s.Cron = strings.TrimSpace(s.Cron)
+	s.OverlapPolicy = strings.ToLower(strings.TrimSpace(s.OverlapPolicy))
+	if s.OverlapPolicy == "" {
+		s.OverlapPolicy = OverlapSkip
+	}
+	if s.Name == "" {
+		s.Name = s.Type
+	}
+	if len(s.Params) == 0 {
+		s.Params = json.RawMessage("{}")
+	}
+}
+
+// Validate checks the schedule's cron expression, type and params
+func (s *Schedule) Validate() error {
+	if _, err := cronParser.Parse(s.Cron); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+	}
+
+	switch s.OverlapPolicy {
+	case OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return fmt.Errorf("overlap_policy must be skip, queue or allow")
+	}
+
+	if s.Type != TypeWebhook && s.ServerID == "" {
+		return fmt.Errorf("server_id is required for %s schedules", s.Type)
+	}
+
+	switch s.Type {
+	case TypeCommand:
+		var params CommandParams
+		if err := s.DecodeParams(&params); err != nil {
+			return err
+		}
+		if strings.TrimSpace(params.Command) == "" {
+			return fmt.Errorf("params.command is required")
+		}
+	case TypeRestart:
+		var params RestartParams
+		if err := s.DecodeParams(&params); err != nil {
+			return err
+		}
+		_, err := params.WarningDelays()
+		return err
+	case TypeStop:
+		var params StopParams
+		if err := s.DecodeParams(&params); err != nil {
+			return err
+		}
+		_, err := params.WarningDelays()
+		return err
+	case TypeStart:
+		var params StartParams
+		return s.DecodeParams(&params)
+	case TypeBackup:
+		var params BackupParams
+		return s.DecodeParams(&params)
+	case TypeScript:
+		var params ScriptParams
+		if err := s.DecodeParams(&params); err != nil {
+			return err
+		}
+		if strings.TrimSpace(params.Script) == "" {
+			return fmt.Errorf("params.script is required")
+		}
+		if _, err := parseTimeout(params.Timeout, 0); err != nil {
+			return err
+		}
+	case TypeWebhook:
+		var params WebhookParams
+		if err := s.DecodeParams(&params); err != nil {
+			return err
+		}
+		parsed, err := url.Parse(params.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("params.url must be an http or https URL")
+		}
+		if _, err := parseTimeout(params.Timeout, 0); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("type must be command, restart, stop, start, backup, script or webhook")
+	}
+
+	return nil
+}
+
+// parseTimeout reads a duration param, returning fallback when it is empty
+func parseTimeout(value string, fallback time.Duration) (time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return d, nil
+}
@@ -0,0 +1,238 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("scheduler")
+
+// Executor runs one task of a schedule and returns its output
+type Executor func(ctx context.Context, schedule *Schedule) (string, error)
+
+// ErrSkipped is returned by an executor that found nothing to do, such as a
+// restart of a server that is offline; the run is recorded as skipped
+var ErrSkipped = errors.New("nothing to do")
+
+// Scheduler runs due schedules with the executor registered for their type.
+// It polls the database, so schedules saved through the Store are picked up
+// without notifying it.
+type Scheduler struct {
+	store     *Store
+	interval  time.Duration
+	executors map[string]Executor
+
+	mu     sync.Mutex
+	active map[string]*scheduleState
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// scheduleState tracks the runs of one schedule in this process
+type scheduleState struct {
+	running int
+	queued  bool
+}
+
+// NewScheduler creates a scheduler backed by store
+func NewScheduler(store *Store) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		store:     store,
+		interval:  30 * time.Second,
+		executors: make(map[string]Executor),
+		active:    make(map[string]*scheduleState),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Register sets the executor for a task type
+func (s *Scheduler) Register(taskType string, executor Executor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executors[taskType] = executor
+}
+
+// Start polls for due schedules until ctx is cancelled or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	if err := s.store.FailInterruptedRuns(ctx); err != nil {
+		logger.Error("Failed to close interrupted runs", "error", err)
+	}
+
+	ticker := time.NewTicker(s.interval)
+	go func() {
+		defer ticker.Stop()
+		s.runDue()
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Info("Stopping scheduler")
+				return
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.runDue()
+			}
+		}
+	}()
+}
+
+// Stop cancels running tasks and waits for them to record their outcome
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunNow starts a schedule immediately, applying its overlap policy, and
+// returns the recorded run
+func (s *Scheduler) RunNow(ctx context.Context, id string) (*Run, error) {
+	schedule, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.trigger(schedule, TriggerManual)
+}
+
+// Running reports whether a run of the schedule is in progress
+func (s *Scheduler) Running(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.active[id]
+	return ok && state.running > 0
+}
+
+func (s *Scheduler) runDue() {
+	now := time.Now()
+	schedules, err := s.store.ListDue(s.ctx, now)
+	if err != nil {
+		logger.Error("Failed to list due schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		next, err := NextRun(schedule.Cron, now)
+		if err != nil {
+			logger.Error("Invalid schedule", "schedule_id", schedule.ID, "error", err)
+			continue
+		}
+
+		// A schedule that was never planned waits for its first slot
+		if schedule.NextRun == nil {
+			if err := s.store.SetNextRun(s.ctx, schedule.ID, nil, next); err != nil {
+				logger.Error("Failed to plan schedule", "schedule_id", schedule.ID, "error", err)
+			}
+			continue
+		}
+
+		if err := s.store.SetNextRun(s.ctx, schedule.ID, &now, next); err != nil {
+			logger.Error("Failed to update run times", "schedule_id", schedule.ID, "error", err)
+			continue
+		}
+		if _, err := s.trigger(schedule, TriggerSchedule); err != nil {
+			logger.Error("Failed to start scheduled task", "schedule_id", schedule.ID, "error", err)
+		}
+	}
+}
+
+// trigger starts a run or, depending on the overlap policy, skips or queues it
+func (s *Scheduler) trigger(schedule *Schedule, triggeredBy string) (*Run, error) {
+	if s.ctx.Err() != nil {
+		return nil, fmt.Errorf("scheduler is stopped")
+	}
+
+	s.mu.Lock()
+	executor, ok := s.executors[schedule.Type]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("no executor for %s schedules", schedule.Type)
+	}
+
+	state := s.active[schedule.ID]
+	if state == nil {
+		state = &scheduleState{}
+		s.active[schedule.ID] = state
+	}
+	if state.running > 0 {
+		switch schedule.OverlapPolicy {
+		case OverlapQueue:
+			state.queued = true
+			s.mu.Unlock()
+			logger.Info("Queued scheduled task behind the running one", "schedule_id", schedule.ID)
+			return &Run{ScheduleID: schedule.ID, TriggeredBy: triggeredBy, Status: StatusQueued}, nil
+		case OverlapAllow:
+		default:
+			s.mu.Unlock()
+			logger.Info("Skipped scheduled task, previous run still in progress", "schedule_id", schedule.ID)
+			return s.store.StartRun(s.ctx, schedule.ID, triggeredBy, StatusSkipped)
+		}
+	}
+	state.running++
+	s.mu.Unlock()
+
+	run, err := s.store.StartRun(s.ctx, schedule.ID, triggeredBy, StatusRunning)
+	if err != nil {
+		s.finish(schedule.ID)
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.execute(schedule, executor, run)
+	return run, nil
+}
+
+func (s *Scheduler) execute(schedule *Schedule, executor Executor, run *Run) {
+	defer s.wg.Done()
+
+	logger.Info("Running scheduled task", "schedule_id", schedule.ID, "type", schedule.Type, "server_id", schedule.ServerID)
+	output, err := executor(s.ctx, schedule)
+	run.Output = output
+	run.Status = StatusSuccess
+	if errors.Is(err, ErrSkipped) {
+		run.Status = StatusSkipped
+		logger.Info("Scheduled task skipped", "schedule_id", schedule.ID, "type", schedule.Type, "reason", output)
+	} else if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+		logger.Error("Scheduled task failed", "schedule_id", schedule.ID, "type", schedule.Type, "error", err)
+	}
+	// The run is recorded even when the scheduler is stopping
+	if err := s.store.FinishRun(context.Background(), run); err != nil {
+		logger.Error("Failed to record run outcome", "schedule_id", schedule.ID, "error", err)
+	}
+
+	if s.finish(schedule.ID) && s.ctx.Err() == nil {
+		if _, err := s.trigger(schedule, run.TriggeredBy); err != nil {
+			logger.Error("Failed to start queued task", "schedule_id", schedule.ID, "error", err)
+		}
+	}
+}
+
+// finish releases a run slot and reports whether a queued run should start
+func (s *Scheduler) finish(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.active[id]
+	state.running--
+	if state.running > 0 {
+		return false
+	}
+	delete(s.active, id)
+	return state.queued
+}
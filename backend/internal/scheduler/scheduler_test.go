@@ -0,0 +1,229 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	return NewStore(db.DB)
+}
+
+func saveSchedule(t *testing.T, store *Store, id, policy string) *Schedule {
+	t.Helper()
+	schedule := &Schedule{ID: id, ServerID: "srv", Type: TypeCommand, Cron: "@daily", Enabled: true, OverlapPolicy: policy,
+		Params: json.RawMessage(`{"command":"save-all"}`)}
+	schedule.Normalize()
+	if err := schedule.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := store.Save(context.Background(), schedule); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	return schedule
+}
+
+func waitForRuns(t *testing.T, store *Store, id string, want func([]Run) bool) []Run {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		runs, err := store.ListRuns(context.Background(), id, 0)
+		if err != nil {
+			t.Fatalf("list runs: %v", err)
+		}
+		if want(runs) {
+			return runs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected runs %+v", runs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func countStatus(runs []Run, status string) int {
+	n := 0
+	for _, run := range runs {
+		if run.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSchedulerOverlapPolicies(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	saveSchedule(t, store, "skip", OverlapSkip)
+	saveSchedule(t, store, "queue", OverlapQueue)
+
+	release := make(chan struct{})
+	s := NewScheduler(store)
+	s.Register(TypeCommand, func(ctx context.Context, schedule *Schedule) (string, error) {
+		<-release
+		return "sent", nil
+	})
+
+	for _, id := range []string{"skip", "queue"} {
+		if run, err := s.RunNow(ctx, id); err != nil || run.Status != StatusRunning {
+			t.Fatalf("%s: expected first run to start, got %+v (%v)", id, run, err)
+		}
+	}
+	if run, err := s.RunNow(ctx, "skip"); err != nil || run.Status != StatusSkipped {
+		t.Fatalf("expected overlapping run to be skipped, got %+v (%v)", run, err)
+	}
+	if run, err := s.RunNow(ctx, "queue"); err != nil || run.Status != StatusQueued {
+		t.Fatalf("expected overlapping run to be queued, got %+v (%v)", run, err)
+	}
+	close(release)
+
+	waitForRuns(t, store, "skip", func(runs []Run) bool {
+		return countStatus(runs, StatusSuccess) == 1 && countStatus(runs, StatusSkipped) == 1
+	})
+	runs := waitForRuns(t, store, "queue", func(runs []Run) bool {
+		return countStatus(runs, StatusSuccess) == 2
+	})
+	if runs[0].Output != "sent" || runs[0].TriggeredBy != TriggerManual || runs[0].FinishedAt == nil {
+		t.Fatalf("unexpected run record %+v", runs[0])
+	}
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+}
+
+func TestSchedulerRecordsFailures(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	saveSchedule(t, store, "failing", OverlapAllow)
+
+	s := NewScheduler(store)
+	s.Register(TypeCommand, func(ctx context.Context, schedule *Schedule) (string, error) {
+		return "partial", errors.New("server offline")
+	})
+	if _, err := s.RunNow(ctx, "failing"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	runs := waitForRuns(t, store, "failing", func(runs []Run) bool {
+		return countStatus(runs, StatusFailed) == 1
+	})
+	if runs[0].Error != "server offline" || runs[0].Output != "partial" {
+		t.Fatalf("unexpected failed run %+v", runs[0])
+	}
+
+	if _, err := s.RunNow(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestSchedulerRecordsSkips(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	saveSchedule(t, store, "offline", OverlapSkip)
+
+	s := NewScheduler(store)
+	s.Register(TypeCommand, func(ctx context.Context, schedule *Schedule) (string, error) {
+		return "Server is offline, restart skipped", ErrSkipped
+	})
+	if _, err := s.RunNow(ctx, "offline"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	runs := waitForRuns(t, store, "offline", func(runs []Run) bool {
+		return countStatus(runs, StatusSkipped) == 1 && runs[0].FinishedAt != nil
+	})
+	if runs[0].Error != "" || runs[0].Output != "Server is offline, restart skipped" {
+		t.Fatalf("unexpected skipped run %+v", runs[0])
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		schedule Schedule
+		valid    bool
+	}{
+		{"command", Schedule{ServerID: "srv", Type: TypeCommand, Cron: "0 4 * * *", Params: json.RawMessage(`{"command":"say hi"}`)}, true},
+		{"command without text", Schedule{ServerID: "srv", Type: TypeCommand, Cron: "@hourly"}, false},
+		{"restart without server", Schedule{Type: TypeRestart, Cron: "@daily"}, false},
+		{"bad cron", Schedule{ServerID: "srv", Type: TypeRestart, Cron: "every day"}, false},
+		{"restart warnings", Schedule{ServerID: "srv", Type: TypeRestart, Cron: "0 4 * * *", Params: json.RawMessage(`{"warnings":["10m","1m"],"skip_if_offline":true}`)}, true},
+		{"stop warning too early", Schedule{ServerID: "srv", Type: TypeStop, Cron: "0 4 * * *", Params: json.RawMessage(`{"warnings":["2h"]}`)}, false},
+		{"stop bad warning", Schedule{ServerID: "srv", Type: TypeStop, Cron: "0 4 * * *", Params: json.RawMessage(`{"warnings":["soon"]}`)}, false},
+		{"start", Schedule{ServerID: "srv", Type: TypeStart, Cron: "0 8 * * 1-5"}, true},
+		{"webhook", Schedule{Type: TypeWebhook, Cron: "@every 1h", Params: json.RawMessage(`{"url":"https://example.com/hook"}`)}, true},
+		{"webhook without scheme", Schedule{Type: TypeWebhook, Cron: "@daily", Params: json.RawMessage(`{"url":"example.com"}`)}, false},
+		{"script timeout", Schedule{ServerID: "srv", Type: TypeScript, Cron: "@daily", Params: json.RawMessage(`{"script":"ls","timeout":"soon"}`)}, false},
+		{"unknown type", Schedule{ServerID: "srv", Type: "reboot", Cron: "@daily"}, false},
+		{"overlap policy", Schedule{ServerID: "srv", Type: TypeBackup, Cron: "@daily", OverlapPolicy: "wait"}, false},
+	}
+	for _, tc := range cases {
+		tc.schedule.Normalize()
+		if err := tc.schedule.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: expected valid=%v, got %v", tc.name, tc.valid, err)
+		}
+	}
+}
+
+func TestPowerParamsWarnings(t *testing.T) {
+	params := PowerParams{Warnings: []string{"10s", "5m", "1h"}, WarningMessage: "Restart in {time}!"}
+	delays, err := params.WarningDelays()
+	if err != nil {
+		t.Fatalf("warnings: %v", err)
+	}
+	if len(delays) != 3 || delays[0] != time.Hour || delays[2] != 10*time.Second {
+		t.Fatalf("expected warnings longest first, got %v", delays)
+	}
+	for left, want := range map[time.Duration]string{
+		time.Hour:        "Restart in 1 hour!",
+		5 * time.Minute:  "Restart in 5 minutes!",
+		90 * time.Second: "Restart in 90 seconds!",
+	} {
+		if got := params.Message(left); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+	if got := (PowerParams{}).Message(time.Minute); got != "Server shutting down in 1 minute" {
+		t.Errorf("unexpected default message %q", got)
+	}
+}
+
+func TestWebhookExecutor(t *testing.T) {
+	var gotMethod, gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotHeader = r.Method, r.Header.Get("X-Token")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	execute := WebhookExecutor(server.Client())
+	schedule := &Schedule{Type: TypeWebhook, Params: json.RawMessage(`{"url":"` + server.URL + `/hook","method":"put","headers":{"X-Token":"abc"}}`)}
+	output, err := execute(context.Background(), schedule)
+	if err != nil || gotMethod != http.MethodPut || gotHeader != "abc" || output != "200 OK\nok" {
+		t.Fatalf("unexpected webhook result %q (%v), method %s header %s", output, err, gotMethod, gotHeader)
+	}
+
+	schedule.Params = json.RawMessage(`{"url":"` + server.URL + `/fail"}`)
+	if _, err := execute(context.Background(), schedule); err == nil {
+		t.Fatal("expected non-2xx response to fail the run")
+	}
+}
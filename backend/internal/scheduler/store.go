@@ -0,0 +1,281 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned for unknown schedule IDs
+var ErrNotFound = errors.New("schedule not found")
+
+// maxRunOutput caps the output kept in a run's history entry
+const maxRunOutput = 64 * 1024
+
+// Store persists schedules and their run history. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new schedule store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const scheduleColumns = `id, name, server_id, type, cron, enabled, overlap_policy, params, last_run, next_run, created_at, updated_at`
+
+// Get returns one schedule
+func (s *Store) Get(ctx context.Context, id string) (*Schedule, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, id)
+	schedule, err := scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// List returns all schedules, or those of one server when serverID is set
+func (s *Store) List(ctx context.Context, serverID string) ([]*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + ` FROM schedules`
+	var args []any
+	if serverID != "" {
+		query += ` WHERE server_id = ?`
+		args = append(args, serverID)
+	}
+	query += ` ORDER BY created_at, id`
+	return s.query(ctx, query, args...)
+}
+
+// ListDue returns the enabled schedules whose next run is at or before now.
+// Schedules that have never been planned are returned too, with a nil NextRun.
+func (s *Store) ListDue(ctx context.Context, now time.Time) ([]*Schedule, error) {
+	return s.query(ctx, `SELECT `+scheduleColumns+` FROM schedules
+		WHERE enabled = 1 AND (next_run IS NULL OR next_run <= ?)`, now.UTC())
+}
+
+// Save creates or replaces a schedule. The next run is planned from the cron
+// expression when the schedule is enabled.
+func (s *Store) Save(ctx context.Context, schedule *Schedule) error {
+	now := time.Now().UTC()
+	schedule.NextRun = nil
+	if schedule.Enabled {
+		next, err := NextRun(schedule.Cron, now)
+		if err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+		schedule.NextRun = &next
+	}
+	if schedule.CreatedAt.IsZero() {
+		schedule.CreatedAt = now
+	}
+	schedule.UpdatedAt = now
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedules (`+scheduleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			server_id = excluded.server_id,
+			type = excluded.type,
+			cron = excluded.cron,
+			enabled = excluded.enabled,
+			overlap_policy = excluded.overlap_policy,
+			params = excluded.params,
+			next_run = excluded.next_run,
+			updated_at = excluded.updated_at
+	`,
+		schedule.ID, schedule.Name, nullString(schedule.ServerID), schedule.Type, schedule.Cron, schedule.Enabled,
+		schedule.OverlapPolicy, string(schedule.Params), nullTime(schedule.LastRun), nullTime(schedule.NextRun),
+		schedule.CreatedAt, schedule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a schedule and its run history. It returns false if no such
+// schedule exists.
+func (s *Store) Delete(ctx context.Context, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schedule_runs WHERE schedule_id = ?`, id); err != nil {
+		return false, fmt.Errorf("failed to delete schedule runs: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, tx.Commit()
+}
+
+// SetNextRun records when a schedule was last due and when it runs next
+func (s *Store) SetNextRun(ctx context.Context, id string, lastRun *time.Time, nextRun time.Time) error {
+	var err error
+	if lastRun != nil {
+		_, err = s.db.ExecContext(ctx, `UPDATE schedules SET last_run = ?, next_run = ? WHERE id = ?`, lastRun.UTC(), nextRun.UTC(), id)
+	} else {
+		_, err = s.db.ExecContext(ctx, `UPDATE schedules SET next_run = ? WHERE id = ?`, nextRun.UTC(), id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update schedule run times: %w", err)
+	}
+	return nil
+}
+
+// StartRun records the start of a run with the given status
+func (s *Store) StartRun(ctx context.Context, scheduleID, triggeredBy, status string) (*Run, error) {
+	run := &Run{
+		ScheduleID:  scheduleID,
+		TriggeredBy: triggeredBy,
+		Status:      status,
+		StartedAt:   time.Now().UTC(),
+	}
+	if status != StatusRunning {
+		run.FinishedAt = &run.StartedAt
+	}
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO schedule_runs (schedule_id, triggered_by, status, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, scheduleID, triggeredBy, status, run.StartedAt, nullTime(run.FinishedAt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record schedule run: %w", err)
+	}
+	if run.ID, err = result.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("failed to record schedule run: %w", err)
+	}
+	return run, nil
+}
+
+// FinishRun records the outcome of a run
+func (s *Store) FinishRun(ctx context.Context, run *Run) error {
+	finished := time.Now().UTC()
+	run.FinishedAt = &finished
+	if len(run.Output) > maxRunOutput {
+		run.Output = run.Output[len(run.Output)-maxRunOutput:]
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE schedule_runs SET status = ?, output = ?, error = ?, finished_at = ? WHERE id = ?
+	`, run.Status, nullString(run.Output), nullString(run.Error), finished, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update schedule run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns returns a schedule's most recent runs, newest first
+func (s *Store) ListRuns(ctx context.Context, scheduleID string, limit int) ([]Run, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, schedule_id, triggered_by, status, output, error, started_at, finished_at
+		FROM schedule_runs
+		WHERE schedule_id = ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, scheduleID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]Run, 0)
+	for rows.Next() {
+		var run Run
+		var output, runErr sql.NullString
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.TriggeredBy, &run.Status, &output, &runErr, &run.StartedAt, &finishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule run: %w", err)
+		}
+		run.Output = output.String
+		run.Error = runErr.String
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// FailInterruptedRuns marks runs left in the running state by a previous
+// process as failed
+func (s *Store) FailInterruptedRuns(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE schedule_runs SET status = ?, error = 'interrupted by a manager restart', finished_at = ?
+		WHERE status = ?
+	`, StatusFailed, time.Now().UTC(), StatusRunning)
+	if err != nil {
+		return fmt.Errorf("failed to close interrupted schedule runs: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...any) ([]*Schedule, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := make([]*Schedule, 0)
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row rowScanner) (*Schedule, error) {
+	var (
+		schedule Schedule
+		serverID sql.NullString
+		params   string
+		lastRun  sql.NullTime
+		nextRun  sql.NullTime
+	)
+	if err := row.Scan(&schedule.ID, &schedule.Name, &serverID, &schedule.Type, &schedule.Cron, &schedule.Enabled,
+		&schedule.OverlapPolicy, &params, &lastRun, &nextRun, &schedule.CreatedAt, &schedule.UpdatedAt); err != nil {
+		return nil, err
+	}
+	schedule.ServerID = serverID.String
+	schedule.Params = []byte(params)
+	if lastRun.Valid {
+		schedule.LastRun = &lastRun.Time
+	}
+	if nextRun.Valid {
+		schedule.NextRun = &nextRun.Time
+	}
+	return &schedule, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
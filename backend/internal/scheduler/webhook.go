@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout = 30 * time.Second
+	maxWebhookResponse    = 16 * 1024
+)
+
+// WebhookExecutor sends the HTTP request described by a webhook schedule.
+// Responses other than 2xx fail the run.
+func WebhookExecutor(client *http.Client) Executor {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, schedule *Schedule) (string, error) {
+		var params WebhookParams
+		if err := schedule.DecodeParams(&params); err != nil {
+			return "", err
+		}
+		timeout, err := parseTimeout(params.Timeout, defaultWebhookTimeout)
+		if err != nil {
+			return "", err
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		method := strings.ToUpper(strings.TrimSpace(params.Method))
+		if method == "" {
+			method = http.MethodPost
+		}
+		var body io.Reader
+		if params.Body != "" {
+			body = strings.NewReader(params.Body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, params.URL, body)
+		if err != nil {
+			return "", fmt.Errorf("invalid webhook request: %w", err)
+		}
+		for name, value := range params.Headers {
+			req.Header.Set(name, value)
+		}
+		if params.Body != "" && req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("User-Agent", "hytale-server-manager-scheduler")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("webhook request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+		output := fmt.Sprintf("%s\n%s", resp.Status, data)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return output, fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		return output, nil
+	}
+}
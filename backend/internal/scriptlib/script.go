@@ -0,0 +1,182 @@
+// Package scriptlib keeps a library of parameterized shell scripts for
+// routine host maintenance. A script runs only once it has been approved, and
+// every run is kept with the script text and parameters it ran with.
+package scriptlib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Script statuses
+const (
+	// StatusPending scripts were added or changed and wait for approval
+	StatusPending = "pending"
+	// StatusApproved scripts may be run
+	StatusApproved = "approved"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+)
+
+// maxBodySize bounds the text of a script
+const maxBodySize = 256 * 1024
+
+// parameterName is a shell variable name that is not one of bash's own
+var parameterName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// reservedParameters are variables the shell or the login environment relies on
+var reservedParameters = map[string]bool{
+	"HOME": true, "PATH": true, "SHELL": true, "USER": true, "LOGNAME": true,
+	"PWD": true, "IFS": true, "PS1": true, "PS2": true, "PS4": true, "BASH_ENV": true, "ENV": true,
+}
+
+// Parameter is a value a script is run with. The script reads it as a shell
+// variable of the same name.
+type Parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// Script is a shell script of the library
+type Script struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Body        string      `json:"body"`
+	Parameters  []Parameter `json:"parameters"`
+	Status      string      `json:"status"`
+	CreatedBy   string      `json:"created_by,omitempty"`
+	UpdatedBy   string      `json:"updated_by,omitempty"`
+	ApprovedBy  string      `json:"approved_by,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	ApprovedAt  *time.Time  `json:"approved_at,omitempty"`
+}
+
+// Run is one execution of a script on one host
+type Run struct {
+	ID         string `json:"id"`
+	ScriptID   string `json:"script_id"`
+	ScriptName string `json:"script_name"`
+	// Body is the script text as it ran
+	Body       string            `json:"body"`
+	ServerID   string            `json:"server_id"`
+	Host       string            `json:"host"`
+	TaskID     string            `json:"task_id,omitempty"`
+	Parameters map[string]string `json:"parameters"`
+	Status     string            `json:"status"`
+	Output     string            `json:"output,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	StartedBy  string            `json:"started_by,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Normalize trims fields and fills in defaults
+func (s *Script) Normalize() {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Description = strings.TrimSpace(s.Description)
+	s.Body = strings.ReplaceAll(s.Body, "\r\n", "\n")
+	if s.Parameters == nil {
+		s.Parameters = []Parameter{}
+	}
+	for i := range s.Parameters {
+		s.Parameters[i].Name = strings.TrimSpace(s.Parameters[i].Name)
+		s.Parameters[i].Description = strings.TrimSpace(s.Parameters[i].Description)
+	}
+	if s.Status == "" {
+		s.Status = StatusPending
+	}
+}
+
+// Validate checks the name, text and parameters
+func (s *Script) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(s.Body) == "" {
+		return fmt.Errorf("body is required")
+	}
+	if len(s.Body) > maxBodySize {
+		return fmt.Errorf("body must be at most %d bytes", maxBodySize)
+	}
+	seen := make(map[string]bool, len(s.Parameters))
+	for _, param := range s.Parameters {
+		if !parameterName.MatchString(param.Name) || reservedParameters[param.Name] {
+			return fmt.Errorf("parameter %q must be an upper-case shell variable name that the shell does not use itself", param.Name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("parameter %s is listed twice", param.Name)
+		}
+		seen[param.Name] = true
+	}
+	return nil
+}
+
+// Changed reports whether next runs differently from s, so it needs to be approved again
+func (s *Script) Changed(next *Script) bool {
+	if s.Body != next.Body || len(s.Parameters) != len(next.Parameters) {
+		return true
+	}
+	for i := range s.Parameters {
+		if s.Parameters[i] != next.Parameters[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the parameters a run uses: the given values, with defaults
+// filled in. Unknown names and missing required values are rejected.
+func (s *Script) Values(given map[string]string) (map[string]string, error) {
+	known := make(map[string]bool, len(s.Parameters))
+	values := make(map[string]string, len(s.Parameters))
+	for _, param := range s.Parameters {
+		known[param.Name] = true
+		value, ok := given[param.Name]
+		if !ok || value == "" {
+			value = param.Default
+		}
+		if value == "" && param.Required {
+			return nil, fmt.Errorf("parameter %s is required", param.Name)
+		}
+		values[param.Name] = value
+	}
+	for name := range given {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	return values, nil
+}
+
+// Command returns the script preceded by its parameters as shell variables.
+// Values are quoted, so they are never run as code.
+func Command(body string, values map[string]string) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + "=" + shellQuote(values[name]) + "\n")
+	}
+	b.WriteString(body)
+	return b.String()
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,117 @@
+package scriptlib
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("failed to migrate db: %v", err)
+	}
+	return NewStore(db.DB)
+}
+
+func TestValuesFillDefaultsAndRejectUnknownNames(t *testing.T) {
+	script := &Script{Parameters: []Parameter{
+		{Name: "DAYS", Default: "7"},
+		{Name: "TARGET", Required: true},
+	}}
+
+	values, err := script.Values(map[string]string{"TARGET": "/var/log"})
+	if err != nil {
+		t.Fatalf("expected values, got %v", err)
+	}
+	if values["DAYS"] != "7" || values["TARGET"] != "/var/log" {
+		t.Fatalf("unexpected values %v", values)
+	}
+	if _, err := script.Values(map[string]string{}); err == nil {
+		t.Fatal("expected a missing required parameter to be rejected")
+	}
+	if _, err := script.Values(map[string]string{"TARGET": "x", "OTHER": "y"}); err == nil {
+		t.Fatal("expected an unknown parameter to be rejected")
+	}
+}
+
+func TestCommandQuotesValues(t *testing.T) {
+	command := Command("echo \"$NAME\"", map[string]string{"NAME": "it's $(reboot)", "A": "1"})
+	want := "A='1'\nNAME='it'\\''s $(reboot)'\necho \"$NAME\""
+	if command != want {
+		t.Fatalf("expected %q, got %q", want, command)
+	}
+}
+
+func TestValidateRejectsShellVariables(t *testing.T) {
+	for _, name := range []string{"PATH", "lower", "1ST", "WITH-DASH"} {
+		script := &Script{Name: "clean", Body: "true", Parameters: []Parameter{{Name: name}}}
+		if err := script.Validate(); err == nil {
+			t.Fatalf("expected parameter %q to be rejected", name)
+		}
+	}
+}
+
+func TestChangedOnlyForBodyAndParameters(t *testing.T) {
+	script := &Script{Name: "clean", Body: "true", Parameters: []Parameter{{Name: "DAYS"}}}
+	renamed := *script
+	renamed.Name = "cleanup"
+	renamed.Description = "Removes old logs"
+	if script.Changed(&renamed) {
+		t.Fatal("expected a rename not to need approval")
+	}
+	edited := renamed
+	edited.Parameters = []Parameter{{Name: "DAYS", Default: "7"}}
+	if !script.Changed(&edited) {
+		t.Fatal("expected a parameter change to need approval")
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	script := &Script{ID: "script-1", Name: "clean", Body: "true", Parameters: []Parameter{{Name: "DAYS", Default: "7"}}}
+	script.Normalize()
+	if err := store.Save(ctx, script); err != nil {
+		t.Fatalf("save script: %v", err)
+	}
+	if err := store.Save(ctx, &Script{ID: "script-2", Name: "clean", Body: "true", Status: StatusPending}); !errors.Is(err, ErrNameTaken) {
+		t.Fatalf("expected the name to be taken, got %v", err)
+	}
+	loaded, err := store.Get(ctx, "script-1")
+	if err != nil {
+		t.Fatalf("get script: %v", err)
+	}
+	if loaded.Status != StatusPending || len(loaded.Parameters) != 1 || loaded.Parameters[0].Default != "7" {
+		t.Fatalf("unexpected script %+v", loaded)
+	}
+
+	run := &Run{ID: "run-1", ScriptID: "script-1", ScriptName: "clean", Body: "true", ServerID: "srv", Host: "host",
+		Parameters: map[string]string{"DAYS": "7"}, Status: RunRunning}
+	if err := store.SaveRun(ctx, run); err != nil {
+		t.Fatalf("save run: %v", err)
+	}
+	if err := store.FailInterrupted(ctx); err != nil {
+		t.Fatalf("fail interrupted: %v", err)
+	}
+	if err := store.Delete(ctx, "script-1"); err != nil {
+		t.Fatalf("delete script: %v", err)
+	}
+
+	runs, err := store.ListRuns(ctx, RunFilter{ScriptID: "script-1"})
+	if err != nil {
+		t.Fatalf("list runs: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Status != RunFailed || runs[0].FinishedAt == nil || runs[0].Parameters["DAYS"] != "7" {
+		t.Fatalf("expected the interrupted run to be kept as failed, got %+v", runs)
+	}
+}
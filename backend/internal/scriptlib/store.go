@@ -0,0 +1,282 @@
+package scriptlib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for unknown script and run IDs
+var ErrNotFound = errors.New("script not found")
+
+// ErrNameTaken is returned when another script has the same name
+var ErrNameTaken = errors.New("a script with this name already exists")
+
+// Store persists scripts and their runs. Times are stored in UTC.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a new script store
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+const scriptColumns = `id, name, description, body, parameters, status, created_by, updated_by, approved_by, created_at, updated_at, approved_at`
+
+const runColumns = `id, script_id, script_name, body, server_id, host, task_id, parameters, status, output, error, started_by, started_at, finished_at`
+
+// Get returns one script
+func (s *Store) Get(ctx context.Context, id string) (*Script, error) {
+	script, err := scanScript(s.db.QueryRowContext(ctx, `SELECT `+scriptColumns+` FROM scripts WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load script: %w", err)
+	}
+	return script, nil
+}
+
+// List returns every script, by name
+func (s *Store) List(ctx context.Context) ([]*Script, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+scriptColumns+` FROM scripts ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scripts: %w", err)
+	}
+	defer rows.Close()
+
+	scripts := make([]*Script, 0)
+	for rows.Next() {
+		script, err := scanScript(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan script: %w", err)
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, rows.Err()
+}
+
+// Save creates or updates a script
+func (s *Store) Save(ctx context.Context, script *Script) error {
+	now := time.Now().UTC()
+	if script.CreatedAt.IsZero() {
+		script.CreatedAt = now
+	}
+	script.UpdatedAt = now
+
+	var taken string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM scripts WHERE name = ? AND id != ?`, script.Name, script.ID).Scan(&taken)
+	if err == nil {
+		return ErrNameTaken
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check script name: %w", err)
+	}
+
+	params, err := json.Marshal(script.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to encode parameters: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO scripts (`+scriptColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = excluded.name,
+			description = excluded.description,
+			body = excluded.body,
+			parameters = excluded.parameters,
+			status = excluded.status,
+			updated_by = excluded.updated_by,
+			approved_by = excluded.approved_by,
+			updated_at = excluded.updated_at,
+			approved_at = excluded.approved_at
+	`,
+		script.ID, script.Name, nullString(script.Description), script.Body, string(params), script.Status,
+		nullString(script.CreatedBy), nullString(script.UpdatedBy), nullString(script.ApprovedBy),
+		script.CreatedAt, script.UpdatedAt, nullTime(script.ApprovedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save script: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a script. Its runs are kept.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM scripts WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete script: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RunFilter narrows a listing of runs; empty fields match everything
+type RunFilter struct {
+	ScriptID string
+	ServerID string
+	Limit    int
+}
+
+// GetRun returns one run
+func (s *Store) GetRun(ctx context.Context, id string) (*Run, error) {
+	run, err := scanRun(s.db.QueryRowContext(ctx, `SELECT `+runColumns+` FROM script_runs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load script run: %w", err)
+	}
+	return run, nil
+}
+
+// ListRuns returns runs, newest first
+func (s *Store) ListRuns(ctx context.Context, filter RunFilter) ([]*Run, error) {
+	conditions := []string{}
+	args := []any{}
+	if filter.ScriptID != "" {
+		conditions = append(conditions, "script_id = ?")
+		args = append(args, filter.ScriptID)
+	}
+	if filter.ServerID != "" {
+		conditions = append(conditions, "server_id = ?")
+		args = append(args, filter.ServerID)
+	}
+	query := `SELECT ` + runColumns + ` FROM script_runs`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY started_at DESC, id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list script runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := make([]*Run, 0)
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan script run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// SaveRun creates or updates a run
+func (s *Store) SaveRun(ctx context.Context, run *Run) error {
+	if run.StartedAt.IsZero() {
+		run.StartedAt = time.Now().UTC()
+	}
+	params, err := json.Marshal(run.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to encode parameters: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO script_runs (`+runColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			task_id = excluded.task_id,
+			status = excluded.status,
+			output = excluded.output,
+			error = excluded.error,
+			finished_at = excluded.finished_at
+	`,
+		run.ID, run.ScriptID, run.ScriptName, run.Body, run.ServerID, run.Host, nullString(run.TaskID), string(params),
+		run.Status, nullString(run.Output), nullString(run.Error), nullString(run.StartedBy), run.StartedAt.UTC(),
+		nullTime(run.FinishedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save script run: %w", err)
+	}
+	return nil
+}
+
+// FailInterrupted marks the runs left running by a previous process as failed
+func (s *Store) FailInterrupted(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE script_runs SET status = ?, error = ?, finished_at = ?
+		WHERE status = ?
+	`, RunFailed, "interrupted by a manager restart", time.Now().UTC(), RunRunning)
+	if err != nil {
+		return fmt.Errorf("failed to close interrupted script runs: %w", err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanScript(row rowScanner) (*Script, error) {
+	var (
+		script                                        Script
+		description, createdBy, updatedBy, approvedBy sql.NullString
+		params                                        string
+		approvedAt                                    sql.NullTime
+	)
+	if err := row.Scan(&script.ID, &script.Name, &description, &script.Body, &params, &script.Status,
+		&createdBy, &updatedBy, &approvedBy, &script.CreatedAt, &script.UpdatedAt, &approvedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(params), &script.Parameters); err != nil {
+		return nil, fmt.Errorf("invalid parameters of script %s: %w", script.ID, err)
+	}
+	script.Description = description.String
+	script.CreatedBy = createdBy.String
+	script.UpdatedBy = updatedBy.String
+	script.ApprovedBy = approvedBy.String
+	if approvedAt.Valid {
+		script.ApprovedAt = &approvedAt.Time
+	}
+	script.Normalize()
+	return &script, nil
+}
+
+func scanRun(row rowScanner) (*Run, error) {
+	var (
+		run                                 Run
+		taskID, output, runError, startedBy sql.NullString
+		params                              string
+		finishedAt                          sql.NullTime
+	)
+	if err := row.Scan(&run.ID, &run.ScriptID, &run.ScriptName, &run.Body, &run.ServerID, &run.Host, &taskID, &params,
+		&run.Status, &output, &runError, &startedBy, &run.StartedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(params), &run.Parameters); err != nil {
+		return nil, fmt.Errorf("invalid parameters of script run %s: %w", run.ID, err)
+	}
+	run.TaskID = taskID.String
+	run.Output = output.String
+	run.Error = runError.String
+	run.StartedBy = startedBy.String
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
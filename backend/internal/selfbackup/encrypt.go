@@ -0,0 +1,203 @@
+package selfbackup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Encrypted snapshots start with encryptedMagic, the argon2id parameters and
+// salt the key was derived with, and a random nonce prefix. The archive
+// follows in chunks sealed with AES-256-GCM; each chunk's nonce ends with its
+// index and a flag marking the last one, so chunks cannot be reordered, and a
+// truncated archive fails to decrypt.
+const (
+	encryptedMagic  = "HSMBAK1\n"
+	encryptedSuffix = ".enc"
+	chunkSize       = 64 * 1024
+	saltSize        = 16
+	noncePrefixSize = 7
+	argonTime       = 3
+	argonMemory     = 64 * 1024
+	argonThreads    = 4
+)
+
+var (
+	// ErrPassphraseRequired is returned for an encrypted snapshot when no
+	// passphrase is configured
+	ErrPassphraseRequired = errors.New("snapshot is encrypted; set self_backup.passphrase")
+	// ErrWrongPassphrase is returned when a snapshot fails to decrypt
+	ErrWrongPassphrase = errors.New("wrong passphrase or damaged snapshot")
+)
+
+type encryptionHeader struct {
+	Time        uint32
+	Memory      uint32
+	Threads     uint8
+	Salt        [saltSize]byte
+	NoncePrefix [noncePrefixSize]byte
+}
+
+func (h encryptionHeader) aead(passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), h.Salt[:], h.Time, h.Memory, h.Threads, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (h encryptionHeader) nonce(index uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, h.NoncePrefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// encryptWriter seals everything written to it into out
+type encryptWriter struct {
+	out    io.Writer
+	header encryptionHeader
+	aead   cipher.AEAD
+	buf    []byte
+	index  uint32
+}
+
+func newEncryptWriter(out io.Writer, passphrase string) (*encryptWriter, error) {
+	header := encryptionHeader{Time: argonTime, Memory: argonMemory, Threads: argonThreads}
+	if _, err := rand.Read(header.Salt[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(header.NoncePrefix[:]); err != nil {
+		return nil, err
+	}
+	aead, err := header.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(out, encryptedMagic); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(out, binary.BigEndian, header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{out: out, header: header, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data follows, since the last
+		// chunk is sealed differently
+		if len(w.buf) == chunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := min(chunkSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the last chunk. It does not close out.
+func (w *encryptWriter) Close() error {
+	return w.seal(true)
+}
+
+func (w *encryptWriter) seal(last bool) error {
+	sealed := w.aead.Seal(nil, w.header.nonce(w.index, last), w.buf, nil)
+	if _, err := w.out.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// decryptReader opens the chunks an encryptWriter sealed
+type decryptReader struct {
+	in     *bufio.Reader
+	header encryptionHeader
+	aead   cipher.AEAD
+	chunk  []byte
+	plain  []byte
+	index  uint32
+	done   bool
+}
+
+func newDecryptReader(in *bufio.Reader, passphrase string) (*decryptReader, error) {
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(in, magic); err != nil || string(magic) != encryptedMagic {
+		return nil, fmt.Errorf("invalid encrypted snapshot")
+	}
+	var header encryptionHeader
+	if err := binary.Read(in, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("invalid encrypted snapshot: %w", err)
+	}
+	if header.Time == 0 || header.Time > 16 || header.Memory == 0 || header.Memory > 1024*1024 || header.Threads == 0 {
+		return nil, fmt.Errorf("invalid encrypted snapshot: unsupported key parameters")
+	}
+	aead, err := header.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{in: in, header: header, aead: aead, chunk: make([]byte, chunkSize+aead.Overhead())}, nil
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *decryptReader) open() error {
+	n, err := io.ReadFull(r.in, r.chunk)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	// A short chunk is the last one; a full one is too when nothing follows it
+	last := n < len(r.chunk)
+	if !last {
+		if _, err := r.in.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		}
+	}
+
+	plain, err := r.aead.Open(r.chunk[:0], r.header.nonce(r.index, last), r.chunk[:n], nil)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+	r.plain = plain
+	r.index++
+	r.done = last
+	return nil
+}
+
+// isEncrypted reports whether the archive read by in starts like an
+// encrypted snapshot
+func isEncrypted(in *bufio.Reader) bool {
+	magic, err := in.Peek(len(encryptedMagic))
+	return err == nil && bytes.Equal(magic, []byte(encryptedMagic))
+}
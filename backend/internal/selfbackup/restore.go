@@ -0,0 +1,296 @@
+package selfbackup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"gopkg.in/yaml.v3"
+)
+
+// RestoreResult lists what a restore wrote
+type RestoreResult struct {
+	Manifest Manifest
+	// Restored maps archive entries to the paths they were written to
+	Restored map[string]string
+	// EncryptionKeyPath is set when the archive carried ENCRYPTION_KEY; the
+	// operator must export it before starting the manager
+	EncryptionKeyPath string
+}
+
+// Verify decrypts and unpacks a snapshot without installing it, and checks it
+// the way Restore does before it replaces anything
+func Verify(cfg *config.Config, archivePath string) (Manifest, error) {
+	staging, err := os.MkdirTemp("", "hsm-verify-")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, _, err := extractArchive(archivePath, staging, cfg.SelfBackup.Passphrase)
+	return manifest, err
+}
+
+// Restore installs a snapshot over the current configuration, database and keys.
+// It must run while the manager is stopped. An encrypted snapshot is opened
+// with self_backup.passphrase. Nothing is replaced unless every file matches
+// its checksum, the database passes an integrity check and the YAML files
+// parse; every replaced file is kept next to the original with a
+// .pre-restore-<timestamp> suffix.
+func Restore(cfg *config.Config, configPath, archivePath string) (*RestoreResult, error) {
+	staging, err := os.MkdirTemp("", "hsm-restore-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, staged, err := extractArchive(archivePath, staging, cfg.SelfBackup.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		target, err := restoreTarget(cfg, configPath, entry.Name)
+		if err != nil {
+			return nil, err
+		}
+		targets[entry.Name] = target
+	}
+
+	if _, ok := targets[databaseEntry]; ok {
+		if dialect, _ := database.ParseDialect(cfg.Database.Driver); dialect != database.DialectSQLite {
+			return nil, fmt.Errorf("snapshot contains a SQLite database but the manager is configured for %s", cfg.Database.Driver)
+		}
+	}
+
+	suffix := preRestoreSuffix + time.Now().Format(snapshotTimeFmt)
+	result := &RestoreResult{Manifest: manifest, Restored: make(map[string]string, len(targets))}
+	for _, entry := range manifest.Entries {
+		target := targets[entry.Name]
+		mode := os.FileMode(0600)
+		if strings.HasPrefix(entry.Name, configsPrefix) || entry.Name == configEntry || strings.HasSuffix(entry.Name, ".crt") {
+			mode = 0644
+		}
+
+		if entry.Name == databaseEntry {
+			// Stale WAL files would be replayed on top of the restored database
+			for _, sidecar := range []string{target + "-wal", target + "-shm"} {
+				if err := moveAside(sidecar, suffix); err != nil {
+					return result, err
+				}
+			}
+		}
+
+		if err := installFile(staged[entry.Name], target, mode, suffix); err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", entry.Name, err)
+		}
+		result.Restored[entry.Name] = target
+		if entry.Name == encryptionEntry {
+			result.EncryptionKeyPath = target
+		}
+	}
+
+	return result, nil
+}
+
+// restoreTarget maps an archive entry to where it belongs under the current configuration
+func restoreTarget(cfg *config.Config, configPath, name string) (string, error) {
+	switch {
+	case name == databaseEntry:
+		return cfg.Database.Path, nil
+	case name == configEntry:
+		return configPath, nil
+	case name == knownHostsEntry:
+		return cfg.Security.SSH.KnownHostsPath, nil
+	case name == encryptionEntry:
+		return filepath.Join(cfg.Storage.DataDir, restoredKeyName), nil
+	case strings.HasPrefix(name, configsPrefix):
+		if base, ok := safeBase(strings.TrimPrefix(name, configsPrefix)); ok {
+			return filepath.Join(cfg.Storage.ConfigDir, base), nil
+		}
+	case strings.HasPrefix(name, agentCAPrefix):
+		if base, ok := safeBase(strings.TrimPrefix(name, agentCAPrefix)); ok {
+			return filepath.Join(cfg.Storage.DataDir, "agent-ca", base), nil
+		}
+	case strings.HasPrefix(name, sshKeysPrefix):
+		if base, ok := safeBase(strings.TrimPrefix(name, sshKeysPrefix)); ok {
+			return filepath.Join(cfg.Storage.DataDir, "ssh_keys", base), nil
+		}
+	}
+	return "", fmt.Errorf("unexpected entry in snapshot: %s", name)
+}
+
+func safeBase(name string) (string, bool) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	return name, true
+}
+
+// extractArchive unpacks a snapshot into dir, decrypting it with passphrase
+// if it is encrypted, and verifies it against its manifest
+func extractArchive(archivePath, dir, passphrase string) (Manifest, map[string]string, error) {
+	var manifest Manifest
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return manifest, nil, err
+	}
+	defer f.Close()
+
+	in := bufio.NewReader(f)
+	var archive io.Reader = in
+	if isEncrypted(in) {
+		if passphrase == "" {
+			return manifest, nil, ErrPassphraseRequired
+		}
+		if archive, err = newDecryptReader(in, passphrase); err != nil {
+			return manifest, nil, err
+		}
+	}
+
+	gz, err := gzip.NewReader(archive)
+	if err != nil {
+		if errors.Is(err, ErrWrongPassphrase) {
+			return manifest, nil, err
+		}
+		return manifest, nil, fmt.Errorf("invalid snapshot archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	staged := make(map[string]string)
+	haveManifest := false
+	for i := 0; ; i++ {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, ErrWrongPassphrase) {
+			return manifest, nil, err
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("invalid snapshot archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if header.Name == manifestName {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, nil, fmt.Errorf("invalid snapshot manifest: %w", err)
+			}
+			haveManifest = true
+			continue
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("entry-%d", i))
+		out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return manifest, nil, err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return manifest, nil, err
+		}
+		if err := out.Close(); err != nil {
+			return manifest, nil, err
+		}
+		staged[header.Name] = path
+	}
+
+	if !haveManifest {
+		return manifest, nil, fmt.Errorf("snapshot is missing %s", manifestName)
+	}
+	if manifest.Version > manifestVersion {
+		return manifest, nil, fmt.Errorf("snapshot format %d is newer than this build supports", manifest.Version)
+	}
+
+	for _, entry := range manifest.Entries {
+		path, ok := staged[entry.Name]
+		if !ok {
+			return manifest, nil, fmt.Errorf("snapshot is missing %s", entry.Name)
+		}
+		size, sum, err := hashFile(path)
+		if err != nil {
+			return manifest, nil, err
+		}
+		if size != entry.SizeBytes || sum != entry.SHA256 {
+			return manifest, nil, fmt.Errorf("checksum mismatch for %s", entry.Name)
+		}
+		if err := checkEntry(entry.Name, path); err != nil {
+			return manifest, nil, err
+		}
+	}
+
+	return manifest, staged, nil
+}
+
+// checkEntry makes sure the database and config files are usable, so a
+// snapshot taken from a damaged install is not restored over a working one
+func checkEntry(name, path string) error {
+	switch {
+	case name == databaseEntry:
+		db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?mode=ro")
+		if err != nil {
+			return fmt.Errorf("failed to open database in snapshot: %w", err)
+		}
+		defer db.Close()
+		var result string
+		if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+			return fmt.Errorf("database in snapshot is unreadable: %w", err)
+		}
+		if result != "ok" {
+			return fmt.Errorf("database in snapshot failed its integrity check: %s", result)
+		}
+	case name == configEntry || strings.HasPrefix(name, configsPrefix):
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var parsed map[string]interface{}
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return fmt.Errorf("%s in snapshot is not valid YAML: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func installFile(src, target string, mode os.FileMode, suffix string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tmp := target + ".restore-tmp"
+	if err := copyFile(src, tmp, mode); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := moveAside(target, suffix); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+func moveAside(path, suffix string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.Rename(path, path+suffix); err != nil {
+		return fmt.Errorf("failed to keep existing %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,476 @@
+package selfbackup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+const (
+	manifestName     = "manifest.json"
+	manifestVersion  = 1
+	databaseEntry    = "database/manager.db"
+	configEntry      = "config/config.yaml"
+	knownHostsEntry  = "ssh/known_hosts"
+	encryptionEntry  = "secrets/encryption.key"
+	configsPrefix    = "configs/"
+	agentCAPrefix    = "agent-ca/"
+	sshKeysPrefix    = "ssh-keys/"
+	snapshotPrefix   = "manager-backup_"
+	snapshotSuffix   = ".tar.gz"
+	snapshotTimeFmt  = "2006-01-02_15-04-05"
+	restoredKeyName  = "restored-encryption.key"
+	preRestoreSuffix = ".pre-restore-"
+)
+
+// logger is shared by the selfbackup package
+var logger = logging.For("selfbackup")
+
+var snapshotNamePattern = regexp.MustCompile(`^manager-backup_[0-9_-]+\.tar\.gz(\.enc)?$`)
+
+// Manifest describes the contents of a manager snapshot
+type Manifest struct {
+	Version          int       `json:"version"`
+	CreatedAt        time.Time `json:"created_at"`
+	DatabaseDriver   string    `json:"database_driver"`
+	DatabaseIncluded bool      `json:"database_included"`
+	Entries          []Entry   `json:"entries"`
+}
+
+// Entry is a single file stored in a snapshot
+type Entry struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// Snapshot is a manager backup archive on disk
+type Snapshot struct {
+	Name      string    `json:"name"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// Manager creates and prunes snapshots of the manager's own state
+type Manager struct {
+	cfg        *config.Config
+	db         *database.DB
+	configPath string
+	knownHosts atomic.Pointer[string] // follows config reloads
+	mu         sync.Mutex
+}
+
+// NewManager creates a new self-backup manager
+func NewManager(cfg *config.Config, db *database.DB, configPath string) *Manager {
+	m := &Manager{
+		cfg:        cfg,
+		db:         db,
+		configPath: configPath,
+	}
+	m.SetKnownHostsPath(cfg.Security.SSH.KnownHostsPath)
+	return m
+}
+
+// SetKnownHostsPath changes the known_hosts file included in snapshots
+func (m *Manager) SetKnownHostsPath(path string) {
+	m.knownHosts.Store(&path)
+}
+
+// Dir returns the directory snapshots are written to
+func (m *Manager) Dir() string {
+	return m.cfg.SelfBackup.Dir
+}
+
+// Start runs the scheduled snapshot job until ctx is cancelled
+func (m *Manager) Start(ctx context.Context) {
+	if !m.cfg.SelfBackup.Enabled {
+		return
+	}
+
+	parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	schedule, err := parser.Parse(m.cfg.SelfBackup.Schedule)
+	if err != nil {
+		logger.Error("Invalid schedule", "schedule", m.cfg.SelfBackup.Schedule, "error", err)
+		return
+	}
+
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(schedule.Next(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				logger.Info("Stopping scheduler")
+				return
+			case <-timer.C:
+				snapshot, err := m.Create()
+				if err != nil {
+					logger.Error("Scheduled snapshot failed", "error", err)
+					continue
+				}
+				logger.Info("Created scheduled snapshot", "name", snapshot.Name, "size_bytes", snapshot.SizeBytes)
+			}
+		}
+	}()
+}
+
+// Create writes a new snapshot archive and applies retention
+func (m *Manager) Create() (*Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir := m.Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	staging, err := os.MkdirTemp(dir, ".staging-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	now := time.Now().UTC()
+	manifest := Manifest{
+		Version:        manifestVersion,
+		CreatedAt:      now,
+		DatabaseDriver: string(m.db.Dialect),
+	}
+
+	sources := make(map[string]string)
+	if m.db.Dialect == database.DialectSQLite {
+		// VACUUM INTO produces a consistent copy without stopping writers
+		dbCopy := filepath.Join(staging, "manager.db")
+		if _, err := m.db.Exec("VACUUM INTO ?", dbCopy); err != nil {
+			return nil, fmt.Errorf("failed to snapshot database: %w", err)
+		}
+		sources[databaseEntry] = dbCopy
+		manifest.DatabaseIncluded = true
+	} else {
+		logger.Warn("Database is not included in snapshots; back it up with its native tooling", "driver", m.db.Dialect)
+	}
+
+	for name, path := range fileSources(m.cfg, m.configPath, *m.knownHosts.Load()) {
+		sources[name] = path
+	}
+
+	if m.cfg.SelfBackup.IncludeSecrets {
+		if key := strings.TrimSpace(os.Getenv("ENCRYPTION_KEY")); key != "" {
+			keyPath := filepath.Join(staging, "encryption.key")
+			if err := os.WriteFile(keyPath, []byte(key+"\n"), 0600); err != nil {
+				return nil, fmt.Errorf("failed to stage encryption key: %w", err)
+			}
+			sources[encryptionEntry] = keyPath
+		}
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Stage every file first so the manifest checksums match what is archived
+	staged := make(map[string]string, len(names))
+	for i, name := range names {
+		path := sources[name]
+		if !strings.HasPrefix(path, staging) {
+			copyPath := filepath.Join(staging, fmt.Sprintf("file-%d", i))
+			if err := copyFile(path, copyPath, 0600); err != nil {
+				return nil, fmt.Errorf("failed to stage %s: %w", name, err)
+			}
+			path = copyPath
+		}
+		size, sum, err := hashFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", name, err)
+		}
+		staged[name] = path
+		manifest.Entries = append(manifest.Entries, Entry{Name: name, SizeBytes: size, SHA256: sum})
+	}
+
+	passphrase := m.cfg.SelfBackup.Passphrase
+	filename := snapshotPrefix + now.Format(snapshotTimeFmt) + snapshotSuffix
+	if passphrase != "" {
+		filename += encryptedSuffix
+	}
+	finalPath := filepath.Join(dir, filename)
+	tmpPath := finalPath + ".tmp"
+	if err := writeArchive(tmpPath, manifest, staged, passphrase); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to finalize snapshot: %w", err)
+	}
+
+	info, err := os.Stat(finalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.prune(); err != nil {
+		logger.Error("Failed to apply retention", "error", err)
+	}
+
+	return &Snapshot{Name: filename, SizeBytes: info.Size(), CreatedAt: now, Encrypted: passphrase != ""}, nil
+}
+
+// List returns snapshots newest first
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.Dir())
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		if entry.IsDir() || !snapshotNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		encrypted := strings.HasSuffix(entry.Name(), encryptedSuffix)
+		stamp := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(entry.Name(), snapshotPrefix), encryptedSuffix), snapshotSuffix)
+		createdAt, err := time.Parse(snapshotTimeFmt, stamp)
+		if err != nil {
+			createdAt = info.ModTime()
+		}
+		snapshots = append(snapshots, Snapshot{Name: entry.Name(), SizeBytes: info.Size(), CreatedAt: createdAt, Encrypted: encrypted})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Path resolves a snapshot name to its file, rejecting anything outside the backup directory
+func (m *Manager) Path(name string) (string, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name")
+	}
+	path := filepath.Join(m.Dir(), name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Verify checks that a snapshot in the backup directory can be decrypted and
+// restored, and returns its manifest
+func (m *Manager) Verify(name string) (Manifest, error) {
+	path, err := m.Path(name)
+	if err != nil {
+		return Manifest{}, err
+	}
+	return Verify(m.cfg, path)
+}
+
+// Delete removes a snapshot
+func (m *Manager) Delete(name string) error {
+	path, err := m.Path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (m *Manager) prune() error {
+	retain := m.cfg.SelfBackup.Retain
+	if retain <= 0 {
+		return nil
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		return err
+	}
+	for i := retain; i < len(snapshots); i++ {
+		if err := os.Remove(filepath.Join(m.Dir(), snapshots[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileSources maps archive entry names to the on-disk files they are read from
+func fileSources(cfg *config.Config, configPath, knownHostsPath string) map[string]string {
+	sources := make(map[string]string)
+
+	absConfig, _ := filepath.Abs(configPath)
+	if isRegularFile(configPath) {
+		sources[configEntry] = configPath
+	}
+
+	if matches, err := filepath.Glob(filepath.Join(cfg.Storage.ConfigDir, "*.yaml")); err == nil {
+		for _, match := range matches {
+			absMatch, _ := filepath.Abs(match)
+			if absMatch == absConfig || strings.HasSuffix(match, ".example.yaml") || !isRegularFile(match) {
+				continue
+			}
+			sources[configsPrefix+filepath.Base(match)] = match
+		}
+	}
+
+	caDir := filepath.Join(cfg.Storage.DataDir, "agent-ca")
+	if entries, err := os.ReadDir(caDir); err == nil {
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				sources[agentCAPrefix+entry.Name()] = filepath.Join(caDir, entry.Name())
+			}
+		}
+	}
+
+	// Keys saved through the API, still encrypted with ENCRYPTION_KEY
+	keysDir := filepath.Join(cfg.Storage.DataDir, "ssh_keys")
+	if entries, err := os.ReadDir(keysDir); err == nil {
+		for _, entry := range entries {
+			if entry.Type().IsRegular() {
+				sources[sshKeysPrefix+entry.Name()] = filepath.Join(keysDir, entry.Name())
+			}
+		}
+	}
+
+	if isRegularFile(knownHostsPath) {
+		sources[knownHostsEntry] = knownHostsPath
+	}
+
+	return sources
+}
+
+// writeArchive writes the manifest and files as a gzipped tarball, encrypted
+// with passphrase unless it is empty
+func writeArchive(path string, manifest Manifest, files map[string]string, passphrase string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer out.Close()
+
+	var archive io.Writer = out
+	var sealer *encryptWriter
+	if passphrase != "" {
+		if sealer, err = newEncryptWriter(out, passphrase); err != nil {
+			return fmt.Errorf("failed to encrypt snapshot: %w", err)
+		}
+		archive = sealer
+	}
+
+	gz := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gz)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(manifestJSON)), ModTime: manifest.CreatedAt}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifestJSON); err != nil {
+		return err
+	}
+
+	for _, entry := range manifest.Entries {
+		if err := addFile(tw, entry.Name, files[entry.Name], manifest.CreatedAt); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", entry.Name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if sealer != nil {
+		if err := sealer.Close(); err != nil {
+			return err
+		}
+	}
+	return out.Sync()
+}
+
+func addFile(tw *tar.Writer, name, path string, modTime time.Time) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: info.Size(), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, in)
+	return err
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func hashFile(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isRegularFile(path string) bool {
+	if strings.TrimSpace(path) == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
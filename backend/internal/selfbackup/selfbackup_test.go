@@ -0,0 +1,213 @@
+package selfbackup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestCreateAndRestore(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Database.Path = filepath.Join(root, "data", "manager.db")
+	cfg.Storage.ConfigDir = filepath.Join(root, "configs")
+	cfg.Storage.DataDir = filepath.Join(root, "data")
+	cfg.Security.SSH.KnownHostsPath = filepath.Join(root, "data", "known_hosts")
+	cfg.SelfBackup.Dir = filepath.Join(root, "data", "manager-backups")
+	cfg.SelfBackup.Retain = 1
+	configPath := filepath.Join(cfg.Storage.ConfigDir, "config.yaml")
+
+	writeFile(t, configPath, "server:\n  port: 8080\n")
+	writeFile(t, filepath.Join(cfg.Storage.ConfigDir, "servers.yaml"), "servers: []\n")
+	writeFile(t, filepath.Join(cfg.Storage.DataDir, "agent-ca", "ca.key"), "ca-key")
+	writeFile(t, cfg.Security.SSH.KnownHostsPath, "host ssh-ed25519 AAAA\n")
+
+	db, err := database.NewDB(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE marker (value TEXT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO marker (value) VALUES ('before')"); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	manager := NewManager(cfg, db, configPath)
+	snapshot, err := manager.Create()
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+
+	snapshots, err := manager.List()
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name != snapshot.Name {
+		t.Fatalf("expected snapshot to be listed, got %v (err %v)", snapshots, err)
+	}
+	if _, err := manager.Path("../config.yaml"); err == nil {
+		t.Fatalf("expected invalid snapshot name to be rejected")
+	}
+
+	if _, err := db.Exec("UPDATE marker SET value = 'after'"); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	db.Close()
+	writeFile(t, filepath.Join(cfg.Storage.ConfigDir, "servers.yaml"), "servers: [changed]\n")
+
+	archivePath, _ := manager.Path(snapshot.Name)
+	result, err := Restore(cfg, configPath, archivePath)
+	if err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if len(result.Restored) != 5 {
+		t.Fatalf("expected 5 restored entries, got %v", result.Restored)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(cfg.Storage.ConfigDir, "servers.yaml"))
+	if string(data) != "servers: []\n" {
+		t.Fatalf("expected servers.yaml to be restored, got %q", data)
+	}
+
+	restored, err := database.NewDB(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("failed to open restored db: %v", err)
+	}
+	defer restored.Close()
+
+	var value string
+	if err := restored.QueryRow("SELECT value FROM marker").Scan(&value); err != nil {
+		t.Fatalf("failed to query restored db: %v", err)
+	}
+	if value != "before" {
+		t.Fatalf("expected restored database value 'before', got %q", value)
+	}
+}
+
+func TestEncryptedSnapshot(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Database.Path = filepath.Join(root, "data", "manager.db")
+	cfg.Storage.ConfigDir = filepath.Join(root, "configs")
+	cfg.Storage.DataDir = filepath.Join(root, "data")
+	cfg.SelfBackup.Dir = filepath.Join(root, "data", "manager-backups")
+	cfg.SelfBackup.Passphrase = "correct horse"
+	configPath := filepath.Join(cfg.Storage.ConfigDir, "config.yaml")
+
+	writeFile(t, configPath, "server:\n  port: 8080\n")
+	keyPath := filepath.Join(cfg.Storage.DataDir, "ssh_keys", "alpha.pem")
+	writeFile(t, keyPath, "HSM-ENCRYPTED-KEY:c2VjcmV0")
+
+	db, err := database.NewDB(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	// Enough rows to span several encrypted chunks
+	if _, err := db.Exec("CREATE TABLE marker (value TEXT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for i := 0; i < 200; i++ {
+		if _, err := db.Exec("INSERT INTO marker (value) VALUES (?)", strings.Repeat(fmt.Sprint(i), 500)); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+
+	manager := NewManager(cfg, db, configPath)
+	snapshot, err := manager.Create()
+	db.Close()
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+	if !snapshot.Encrypted || !strings.HasSuffix(snapshot.Name, ".tar.gz.enc") {
+		t.Fatalf("expected an encrypted snapshot, got %+v", snapshot)
+	}
+	snapshots, err := manager.List()
+	if err != nil || len(snapshots) != 1 || !snapshots[0].Encrypted {
+		t.Fatalf("expected the encrypted snapshot listed, got %v (err %v)", snapshots, err)
+	}
+	archivePath, _ := manager.Path(snapshot.Name)
+	data, _ := os.ReadFile(archivePath)
+	if strings.Contains(string(data), "HSM-ENCRYPTED-KEY") {
+		t.Fatal("expected the archive's content not to be readable")
+	}
+
+	manifest, err := manager.Verify(snapshot.Name)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if len(manifest.Entries) != 3 {
+		t.Fatalf("expected the database, config and SSH key, got %+v", manifest.Entries)
+	}
+
+	wrong := *cfg
+	wrong.SelfBackup.Passphrase = "wrong"
+	if _, err := Verify(&wrong, archivePath); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected a wrong passphrase rejected, got %v", err)
+	}
+	wrong.SelfBackup.Passphrase = ""
+	if _, err := Verify(&wrong, archivePath); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("expected a passphrase required, got %v", err)
+	}
+	truncated := filepath.Join(root, "truncated.tar.gz.enc")
+	writeFile(t, truncated, string(data[:len(data)/2]))
+	if _, err := Verify(cfg, truncated); err == nil {
+		t.Fatal("expected a truncated snapshot rejected")
+	}
+
+	if err := os.Remove(keyPath); err != nil {
+		t.Fatalf("failed to remove key: %v", err)
+	}
+	if _, err := Restore(cfg, configPath, archivePath); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if key, _ := os.ReadFile(keyPath); string(key) != "HSM-ENCRYPTED-KEY:c2VjcmV0" {
+		t.Fatalf("expected the SSH key restored, got %q", key)
+	}
+}
+
+func TestRestoreRejectsDamagedDatabase(t *testing.T) {
+	root := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Database.Path = filepath.Join(root, "data", "manager.db")
+	cfg.Storage.DataDir = filepath.Join(root, "data")
+	cfg.SelfBackup.Dir = filepath.Join(root, "data", "manager-backups")
+
+	db, err := database.NewDB(cfg.Database.Path)
+	if err != nil {
+		t.Fatalf("failed to create db: %v", err)
+	}
+	defer db.Close()
+	snapshot, err := NewManager(cfg, db, filepath.Join(root, "config.yaml")).Create()
+	if err != nil {
+		t.Fatalf("failed to create snapshot: %v", err)
+	}
+
+	// Rebuild the archive around a database that is not one
+	damaged := filepath.Join(root, "not-a-db")
+	writeFile(t, damaged, strings.Repeat("garbage", 1024))
+	size, sum, _ := hashFile(damaged)
+	manifest := Manifest{Version: manifestVersion, DatabaseDriver: "sqlite", DatabaseIncluded: true,
+		Entries: []Entry{{Name: databaseEntry, SizeBytes: size, SHA256: sum}}}
+	archivePath := filepath.Join(cfg.SelfBackup.Dir, snapshot.Name)
+	if err := writeArchive(archivePath, manifest, map[string]string{databaseEntry: damaged}, ""); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	if _, err := Restore(cfg, filepath.Join(root, "config.yaml"), archivePath); err == nil || !strings.Contains(err.Error(), "database in snapshot") {
+		t.Fatalf("expected the damaged database rejected, got %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
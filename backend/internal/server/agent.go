@@ -0,0 +1,240 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	agentPort           = "9443"
+	agentRequestTimeout = 20 * time.Second
+
+	// defaultAgentRunAs is the user a server without a service user runs as;
+	// the agent never starts servers as root
+	defaultAgentRunAs = "hytale"
+)
+
+// AgentProcessManager has the hytale-agent on each host run the server as a
+// transient systemd unit, through the agent's mTLS API instead of SSH.
+// Console input goes to the unit's stdin FIFO and output to the journal and
+// the console log.
+type AgentProcessManager struct {
+	hostOf    func(serverID string) string
+	tlsConfig func() (*tls.Config, error)
+	port      string
+	mu        sync.RWMutex
+	runAs     map[string]string
+}
+
+type agentProcessStatus struct {
+	Running       bool   `json:"running"`
+	State         string `json:"state"`
+	PID           int    `json:"pid"`
+	ServerPID     int    `json:"server_pid"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+}
+
+// NewAgentProcessManager creates an agent process manager. hostOf returns
+// the host a server's agent listens on and tlsConfig the manager's client
+// TLS settings.
+func NewAgentProcessManager(hostOf func(serverID string) string, tlsConfig func() (*tls.Config, error)) *AgentProcessManager {
+	return &AgentProcessManager{
+		hostOf:    hostOf,
+		tlsConfig: tlsConfig,
+		port:      agentPort,
+		runAs:     make(map[string]string),
+	}
+}
+
+// SetRunAsUser configures the user the unit runs as; the agent runs every
+// server as an unprivileged user, so sudo does not apply
+func (am *AgentProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	if strings.TrimSpace(runAsUser) == "" {
+		delete(am.runAs, serverID)
+		return
+	}
+	am.runAs[serverID] = strings.TrimSpace(runAsUser)
+}
+
+// Start has the agent create and start the server's units
+func (am *AgentProcessManager) Start(serverID, sessionName, command, logFile string) error {
+	body := map[string]string{"user": am.user(serverID), "command": command, "log_file": logFile}
+	if err := am.call(serverID, http.MethodPost, sessionName, "start", body, nil); err != nil {
+		return fmt.Errorf("failed to start server through agent: %w", err)
+	}
+
+	running, err := am.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify server: %w", err)
+	}
+	if !running {
+		return fmt.Errorf("server unit is not active after start; see journalctl -u hsm-%s", sessionName)
+	}
+
+	logger.Info("Started server through agent", "server_id", serverID, "session", sessionName, "log_file", logFile)
+
+	return nil
+}
+
+// Stop has the agent stop the units; systemd kills the server if it has not
+// exited after a minute
+func (am *AgentProcessManager) Stop(serverID, sessionName string) error {
+	if err := am.call(serverID, http.MethodPost, sessionName, "stop", nil, nil); err != nil {
+		return fmt.Errorf("failed to stop server through agent: %w", err)
+	}
+
+	logger.Info("Stopped server through agent", "server_id", serverID, "session", sessionName)
+
+	return nil
+}
+
+// Kill has the agent send SIGKILL to the server and stop its units
+func (am *AgentProcessManager) Kill(serverID, sessionName string) error {
+	if err := am.call(serverID, http.MethodPost, sessionName, "kill", nil, nil); err != nil {
+		return fmt.Errorf("failed to kill server through agent: %w", err)
+	}
+
+	logger.Info("Force killed server through agent", "server_id", serverID, "session", sessionName)
+
+	return nil
+}
+
+// IsRunning asks the agent whether the server's unit is active
+func (am *AgentProcessManager) IsRunning(serverID, sessionName string) (bool, error) {
+	var status agentProcessStatus
+	if err := am.call(serverID, http.MethodGet, sessionName, "", nil, &status); err != nil {
+		return false, fmt.Errorf("failed to check server through agent: %w", err)
+	}
+	return status.Running, nil
+}
+
+// GetPID returns the main PID of the server's unit
+func (am *AgentProcessManager) GetPID(serverID, sessionName string) (int, error) {
+	var status agentProcessStatus
+	if err := am.call(serverID, http.MethodGet, sessionName, "", nil, &status); err != nil {
+		return 0, fmt.Errorf("failed to read server PID through agent: %w", err)
+	}
+	if !status.Running || status.PID == 0 {
+		return 0, fmt.Errorf("server unit hsm-%s is not running", sessionName)
+	}
+	return status.PID, nil
+}
+
+// InspectProcess returns the server's Java process, which the agent finds in
+// the unit's cgroup, and its uptime
+func (am *AgentProcessManager) InspectProcess(serverID, sessionName string) (int, time.Duration, error) {
+	var status agentProcessStatus
+	if err := am.call(serverID, http.MethodGet, sessionName, "", nil, &status); err != nil {
+		return 0, 0, fmt.Errorf("failed to inspect server through agent: %w", err)
+	}
+	if !status.Running {
+		return 0, 0, nil
+	}
+	return status.ServerPID, time.Duration(status.UptimeSeconds) * time.Second, nil
+}
+
+// SendCommand has the agent write a command to the server's console
+func (am *AgentProcessManager) SendCommand(serverID, sessionName, command string) error {
+	if err := am.call(serverID, http.MethodPost, sessionName, "command", map[string]string{"command": command}, nil); err != nil {
+		return fmt.Errorf("failed to send command through agent: %w", err)
+	}
+
+	logger.Info("Sent command through agent", "server_id", serverID, "session", sessionName, "command", command)
+
+	return nil
+}
+
+// SendCtrlC has the agent send SIGINT to the server's main process
+func (am *AgentProcessManager) SendCtrlC(serverID, sessionName string) error {
+	if err := am.call(serverID, http.MethodPost, sessionName, "interrupt", nil, nil); err != nil {
+		return fmt.Errorf("failed to send SIGINT through agent: %w", err)
+	}
+
+	logger.Info("Sent SIGINT through agent", "server_id", serverID, "session", sessionName)
+
+	return nil
+}
+
+func (am *AgentProcessManager) user(serverID string) string {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	if user := am.runAs[serverID]; user != "" {
+		return user
+	}
+	return defaultAgentRunAs
+}
+
+// call sends one request to the server's agent and decodes the answer into
+// out. The agent answers errors as {"error": "..."}.
+func (am *AgentProcessManager) call(serverID, method, sessionName, action string, body, out interface{}) error {
+	host := strings.TrimSpace(am.hostOf(serverID))
+	if host == "" {
+		return fmt.Errorf("server %s has no host", serverID)
+	}
+	tlsConfig, err := am.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://%s/servers/%s", net.JoinHostPort(host, am.port), url.PathEscape(sessionName))
+	if action != "" {
+		endpoint += "/" + action
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The TLS settings can change when the agent is reinstalled, so each call
+	// gets its own transport
+	client := &http.Client{
+		Timeout:   agentRequestTimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("the agent on %s does not manage servers; reinstall it to update", host)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+			return fmt.Errorf("agent: %s", failure.Error)
+		}
+		return fmt.Errorf("agent returned %s", resp.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentProcessManager(t *testing.T) {
+	var started map[string]string
+	agent := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /servers/hytale-alpha/start":
+			_ = json.NewDecoder(r.Body).Decode(&started)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case "GET /servers/hytale-alpha":
+			_, _ = w.Write([]byte(`{"running":true,"state":"active","pid":100,"server_pid":101,"uptime_seconds":90}`))
+		case "POST /servers/hytale-alpha/command":
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"server is not running"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer agent.Close()
+
+	host, port, _ := net.SplitHostPort(agent.Listener.Addr().String())
+	clientTLS := agent.Client().Transport.(*http.Transport).TLSClientConfig
+	manager := NewAgentProcessManager(func(string) string { return host }, func() (*tls.Config, error) { return clientTLS, nil })
+	manager.port = port
+
+	if err := manager.Start("alpha", "hytale-alpha", "cd /srv && java -jar HytaleServer.jar", "/srv/console.log"); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	// Without a service user the server runs as hytale, never root
+	if started["user"] != "hytale" || started["log_file"] != "/srv/console.log" {
+		t.Fatalf("unexpected start request %v", started)
+	}
+
+	// Status checks read the Java process from the agent, not over SSH
+	router := NewProcessManagerRouter(mockProcessManager{}, func(string) string { return "agent" })
+	router.Register("agent", manager)
+	detector := NewStatusDetector(&MockCommandExecutor{}, router, nil)
+	status, err := detector.DetectStatus("alpha", "hytale-alpha")
+	if err != nil || status.Status != StatusOnline || status.PID != 101 || status.UptimeSeconds != 90 {
+		t.Fatalf("expected online with the agent's PID and uptime, got %+v (%v)", status, err)
+	}
+
+	if err := manager.SendCommand("alpha", "hytale-alpha", "say hi"); err == nil || !strings.Contains(err.Error(), "server is not running") {
+		t.Fatalf("expected the agent's error, got %v", err)
+	}
+	if err := manager.Stop("alpha", "hytale-alpha"); err == nil || !strings.Contains(err.Error(), "reinstall") {
+		t.Fatalf("expected an outdated agent to be reported, got %v", err)
+	}
+	if _, uptime, err := manager.InspectProcess("alpha", "hytale-alpha"); err != nil || uptime != 90*time.Second {
+		t.Fatalf("expected 90s uptime, got %s (%v)", uptime, err)
+	}
+}
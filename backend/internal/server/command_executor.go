@@ -7,7 +7,7 @@ import (
 	"runtime"
 	"strings"
 
-	"github.com/yourusername/hytale-server-manager/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 )
 
 // CommandExecutor abstracts command execution (local or remote)
@@ -32,16 +32,13 @@ func (e *DefaultCommandExecutor) Execute(serverID, command string) (string, erro
 
 	// Fallback to local execution
 	// Note: This assumes the server is running locally if no SSH connection exists
-	
-	// On Windows, some commands like 'ps' won't work, but for production (Linux) this is fine.
-	// For dev/test on Windows, we might need special handling or just fail.
+
+	// Game servers are Linux hosts; a manager running on Windows has no bash,
+	// ps or screen to run these commands against, so it only works over SSH
 	if runtime.GOOS == "windows" {
-		// Minimal simulation for Windows dev?
-		// Or just try running it (e.g. if using WSL or Git Bash tools are in PATH)
-		// Usually we wrap in "bash -c"
-		return runLocalCommand("bash", "-c", command)
+		return "", fmt.Errorf("no SSH connection for server %s (local commands are not supported on Windows)", serverID)
 	}
-	
+
 	return runLocalCommand("bash", "-c", command)
 }
 
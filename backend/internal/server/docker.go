@@ -0,0 +1,463 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+)
+
+const (
+	// DefaultDockerImage ships the Java runtime the Hytale server needs
+	DefaultDockerImage = "eclipse-temurin:25-jre"
+
+	// dockerConsoleFifo is created in the working directory and feeds the
+	// server's stdin, so console commands can be written from the host
+	dockerConsoleFifo = ".hsm-console"
+
+	dockerStopTimeout = 30
+)
+
+// DockerOptions describes the container a server runs in
+type DockerOptions struct {
+	Image      string
+	Network    string
+	Ports      []string
+	Volumes    []string
+	Env        map[string]string
+	ExtraArgs  []string
+	WorkingDir string
+	// User runs the container as uid:gid instead of the calling user
+	User string
+	// MemoryBytes and CPUs limit the container when set
+	MemoryBytes int64
+	CPUs        float64
+	// Host, when set, is the Docker API the containers are managed through
+	// instead of the docker CLI over SSH, with the client certificate below
+	Host      string
+	TLSCACert string
+	TLSCert   string
+	TLSKey    string
+}
+
+// DockerProcessManager runs servers inside Docker containers on the remote
+// host using the docker CLI over SSH, or the Docker API over TLS for servers
+// with a docker host. The working directory is bind mounted at the same path,
+// so the server files and console log stay on the host.
+type DockerProcessManager struct {
+	sshPool    *ssh.ConnectionPool
+	optionsFor func(serverID string) DockerOptions
+	mu         sync.RWMutex
+	runAs      map[string]screenRunAs
+}
+
+// NewDockerProcessManager creates a docker process manager. optionsFor returns
+// the container settings of a server.
+func NewDockerProcessManager(pool *ssh.ConnectionPool, optionsFor func(serverID string) DockerOptions) *DockerProcessManager {
+	return &DockerProcessManager{
+		sshPool:    pool,
+		optionsFor: optionsFor,
+		runAs:      make(map[string]screenRunAs),
+	}
+}
+
+// SetRunAsUser configures which user runs the docker CLI. The user needs
+// access to the docker daemon, usually through the docker group.
+func (dm *DockerProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if strings.TrimSpace(runAsUser) == "" {
+		delete(dm.runAs, serverID)
+		return
+	}
+	dm.runAs[serverID] = screenRunAs{user: strings.TrimSpace(runAsUser), useSudo: useSudo}
+}
+
+// Start creates and starts the server container
+func (dm *DockerProcessManager) Start(serverID, sessionName, command, logFile string) error {
+	if opts := dm.options(serverID); opts.Host != "" {
+		err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) error {
+			return api.run(ctx, sessionName, dockerCreateSpec(opts.WorkingDir, command, logFile, opts))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create container: %w", err)
+		}
+	} else {
+		runCmd := buildDockerRunCommand(sessionName, dm.workingDir(serverID), command, logFile, opts)
+		output, err := dm.run(serverID, runCmd)
+		if err != nil {
+			return fmt.Errorf("failed to create container: %w (output: %s)", err, strings.TrimSpace(output))
+		}
+	}
+
+	// Verify the container is still up; a bad image or command exits at once
+	time.Sleep(500 * time.Millisecond)
+	running, err := dm.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify container: %w", err)
+	}
+	if !running {
+		logs, _ := dm.Logs(serverID, sessionName, 20)
+		return fmt.Errorf("container exited right after starting: %s", strings.TrimSpace(logs))
+	}
+
+	logger.Info("Started container", "server_id", serverID, "container", sessionName, "log_file", logFile)
+
+	return nil
+}
+
+// IsRunning checks if the server container is running
+func (dm *DockerProcessManager) IsRunning(serverID, sessionName string) (bool, error) {
+	if dm.options(serverID).Host != "" {
+		state, err := dm.inspect(serverID, sessionName)
+		if errors.Is(err, errNoSuchContainer) {
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to inspect container: %w", err)
+		}
+		return state.Running, nil
+	}
+	output, err := dm.run(serverID, fmt.Sprintf("docker inspect -f '{{.State.Running}}' %s 2>/dev/null || true", bashQuote(sessionName)))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return strings.TrimSpace(output) == "true", nil
+}
+
+// GetPID returns the host PID of the container's init process; the server
+// runs among its descendants
+func (dm *DockerProcessManager) GetPID(serverID, sessionName string) (int, error) {
+	if dm.options(serverID).Host != "" {
+		state, err := dm.inspect(serverID, sessionName)
+		if err != nil && !errors.Is(err, errNoSuchContainer) {
+			return 0, fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if !state.Running || state.Pid == 0 {
+			return 0, fmt.Errorf("container %s is not running", sessionName)
+		}
+		return state.Pid, nil
+	}
+	output, err := dm.run(serverID, fmt.Sprintf("docker inspect -f '{{.State.Pid}}' %s", bashQuote(sessionName)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect container: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil || pid == 0 {
+		return 0, fmt.Errorf("container %s is not running", sessionName)
+	}
+	return pid, nil
+}
+
+// SendCommand writes a command to the server's stdin through the console FIFO
+func (dm *DockerProcessManager) SendCommand(serverID, sessionName, command string) error {
+	running, err := dm.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify container: %w", err)
+	}
+	if !running {
+		return fmt.Errorf("container %s is not running", sessionName)
+	}
+
+	if dm.options(serverID).Host != "" {
+		err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) error {
+			return api.writeStdin(ctx, sessionName, command+"\n")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send command to container: %w", err)
+		}
+		logger.Debug("Sent command to container", "server_id", serverID, "container", sessionName, "command", command)
+		return nil
+	}
+
+	fifo := fmt.Sprintf("\"%s\"/%s", escapeForDoubleQuotes(dm.workingDir(serverID)), dockerConsoleFifo)
+	writeCmd := fmt.Sprintf("printf '%%s\\n' '%s' | timeout 5 tee -a %s >/dev/null", escapeCommand(command), fifo)
+	output, err := dm.run(serverID, writeCmd)
+	if err != nil {
+		return fmt.Errorf("failed to send command to container: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Debug("Sent command to container", "server_id", serverID, "container", sessionName, "command", command)
+
+	return nil
+}
+
+// SendCtrlC sends SIGINT to the processes in the container
+func (dm *DockerProcessManager) SendCtrlC(serverID, sessionName string) error {
+	if dm.options(serverID).Host != "" {
+		err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) error {
+			return api.signal(ctx, sessionName, "SIGINT")
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send SIGINT: %w", err)
+		}
+		logger.Info("Sent SIGINT to container", "server_id", serverID, "container", sessionName)
+		return nil
+	}
+	output, err := dm.run(serverID, fmt.Sprintf("docker kill --signal=SIGINT %s", bashQuote(sessionName)))
+	if err != nil {
+		return fmt.Errorf("failed to send SIGINT: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Info("Sent SIGINT to container", "server_id", serverID, "container", sessionName)
+
+	return nil
+}
+
+// Stop stops the container, giving the server time to shut down, and removes it
+func (dm *DockerProcessManager) Stop(serverID, sessionName string) error {
+	if dm.options(serverID).Host != "" {
+		err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) error {
+			if err := api.stop(ctx, sessionName, dockerStopTimeout); err != nil {
+				return err
+			}
+			return api.remove(ctx, sessionName, false)
+		})
+		if err != nil && !errors.Is(err, errNoSuchContainer) {
+			return fmt.Errorf("failed to stop container: %w", err)
+		}
+		logger.Info("Stopped container", "server_id", serverID, "container", sessionName)
+		return nil
+	}
+	name := bashQuote(sessionName)
+	stopCmd := fmt.Sprintf("if docker inspect %s >/dev/null 2>&1; then docker stop -t %d %s >/dev/null && docker rm %s >/dev/null; fi", name, dockerStopTimeout, name, name)
+	output, err := dm.run(serverID, stopCmd)
+	if err != nil {
+		return fmt.Errorf("failed to stop container: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Info("Stopped container", "server_id", serverID, "container", sessionName)
+
+	return nil
+}
+
+// Kill forcefully removes the container
+func (dm *DockerProcessManager) Kill(serverID, sessionName string) error {
+	if dm.options(serverID).Host != "" {
+		err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) error {
+			return api.remove(ctx, sessionName, true)
+		})
+		if err != nil && !errors.Is(err, errNoSuchContainer) {
+			return fmt.Errorf("failed to kill container: %w", err)
+		}
+		logger.Info("Force removed container", "server_id", serverID, "container", sessionName)
+		return nil
+	}
+	output, err := dm.run(serverID, fmt.Sprintf("docker rm -f %s >/dev/null 2>&1 || true", bashQuote(sessionName)))
+	if err != nil {
+		return fmt.Errorf("failed to kill container: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Info("Force removed container", "server_id", serverID, "container", sessionName)
+
+	return nil
+}
+
+// Logs returns the last lines the container wrote to stdout and stderr
+func (dm *DockerProcessManager) Logs(serverID, sessionName string, tail int) (string, error) {
+	if tail <= 0 {
+		tail = 100
+	}
+	if dm.options(serverID).Host != "" {
+		var logs bytes.Buffer
+		err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) error {
+			return api.logs(ctx, sessionName, tail, false, &logs)
+		})
+		if err != nil {
+			return logs.String(), fmt.Errorf("failed to read container logs: %w", err)
+		}
+		return logs.String(), nil
+	}
+	output, err := dm.run(serverID, fmt.Sprintf("docker logs --tail %d %s 2>&1", tail, bashQuote(sessionName)))
+	if err != nil {
+		return output, fmt.Errorf("failed to read container logs: %w", err)
+	}
+	return output, nil
+}
+
+// StreamLogs follows the container's output with docker logs -f, from the
+// moment it is called until ctx is done or the container exits
+func (dm *DockerProcessManager) StreamLogs(ctx context.Context, serverID, sessionName string, onLine func(string)) error {
+	writer := newLineWriter(onLine)
+	defer writer.Flush()
+
+	if opts := dm.options(serverID); opts.Host != "" {
+		api, err := newDockerAPI(opts)
+		if err != nil {
+			return err
+		}
+		return api.logs(ctx, sessionName, 0, true, writer)
+	}
+	conn := dm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+	cmd := dm.wrapForUser(serverID, fmt.Sprintf("docker logs -f --tail 0 %s 2>&1", bashQuote(sessionName)))
+	return conn.Client.StreamCommandContext(ctx, cmd, writer, writer)
+}
+
+func (dm *DockerProcessManager) inspect(serverID, sessionName string) (dockerContainerState, error) {
+	var state dockerContainerState
+	err := dm.withAPI(serverID, func(ctx context.Context, api *dockerAPI) (err error) {
+		state, err = api.inspect(ctx, sessionName)
+		return err
+	})
+	return state, err
+}
+
+// withAPI calls fn with a client for the server's Docker API; calls that do
+// not stream are bounded by the stop timeout and a margin
+func (dm *DockerProcessManager) withAPI(serverID string, fn func(ctx context.Context, api *dockerAPI) error) error {
+	api, err := newDockerAPI(dm.options(serverID))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dockerStopTimeout*time.Second+dockerAPITimeout)
+	defer cancel()
+	return fn(ctx, api)
+}
+
+func (dm *DockerProcessManager) options(serverID string) DockerOptions {
+	if dm.optionsFor == nil {
+		return DockerOptions{}
+	}
+	return dm.optionsFor(serverID)
+}
+
+// workingDir returns the server directory as a shell expression for the host
+func (dm *DockerProcessManager) workingDir(serverID string) string {
+	dm.mu.RLock()
+	config := dm.runAs[serverID]
+	dm.mu.RUnlock()
+	return expandTildeToHomeExpr(dm.options(serverID).WorkingDir, config.user)
+}
+
+func (dm *DockerProcessManager) run(serverID, cmd string) (string, error) {
+	conn := dm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return "", fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+	return conn.Client.RunCommand(dm.wrapForUser(serverID, cmd))
+}
+
+// wrapForUser single-quotes the command so $PWD and $(id -u) are expanded by
+// the run-as user's shell rather than the SSH user's
+func (dm *DockerProcessManager) wrapForUser(serverID, cmd string) string {
+	dm.mu.RLock()
+	config, ok := dm.runAs[serverID]
+	dm.mu.RUnlock()
+	if !ok || config.user == "" || !config.useSudo {
+		return cmd
+	}
+	return fmt.Sprintf("sudo -n -i -u %s bash -lc %s", bashQuote(config.user), bashQuote(cmd))
+}
+
+// buildDockerRunCommand returns the host command that recreates the console
+// FIFO and starts the container. The container runs as the calling user with
+// the working directory mounted at the same path. tini signals the whole
+// process group so SIGINT and SIGTERM reach the server behind the pipeline.
+func buildDockerRunCommand(name, workingDir, command, logFile string, opts DockerOptions) string {
+	image := strings.TrimSpace(opts.Image)
+	if image == "" {
+		image = DefaultDockerImage
+	}
+	network := strings.TrimSpace(opts.Network)
+	if network == "" {
+		network = "host"
+	}
+
+	user := `"$(id -u):$(id -g)"`
+	if opts.User != "" {
+		user = bashQuote(opts.User)
+	}
+
+	args := []string{
+		"docker", "run", "-d", "--init",
+		"--name", bashQuote(name),
+		"--user", user,
+		"--network", bashQuote(network),
+		"-e", "TINI_KILL_PROCESS_GROUP=1",
+		"-e", "COLUMNS=500", "-e", "LINES=100",
+		"-v", `"$PWD:$PWD"`, "-w", `"$PWD"`,
+	}
+	keys := make([]string, 0, len(opts.Env))
+	for key := range opts.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "-e", bashQuote(key+"="+opts.Env[key]))
+	}
+	for _, volume := range opts.Volumes {
+		args = append(args, "-v", "\""+escapeForDoubleQuotes(expandTildeToHomeVarForShell(volume))+"\"")
+	}
+	if network != "host" {
+		for _, port := range opts.Ports {
+			args = append(args, "-p", bashQuote(port))
+		}
+	}
+	if opts.MemoryBytes > 0 {
+		args = append(args, "--memory", strconv.FormatInt(opts.MemoryBytes, 10))
+	}
+	if opts.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(opts.CPUs, 'f', -1, 64))
+	}
+	for _, arg := range opts.ExtraArgs {
+		args = append(args, bashQuote(arg))
+	}
+
+	inner := fmt.Sprintf("exec 3<>%s; %s <&3 2>&1", dockerConsoleFifo, command)
+	if logFile != "" {
+		inner += fmt.Sprintf(" | tee -i -a \"%s\"", logFile)
+	}
+	args = append(args, bashQuote(image), "sh", "-c", "\""+escapeForDoubleQuotes(inner)+"\"")
+
+	steps := []string{
+		fmt.Sprintf("cd \"%s\"", escapeForDoubleQuotes(workingDir)),
+		fmt.Sprintf("rm -f %s", dockerConsoleFifo),
+		fmt.Sprintf("mkfifo -m 600 %s", dockerConsoleFifo),
+		fmt.Sprintf("{ docker rm -f %s >/dev/null 2>&1 || true; }", bashQuote(name)),
+		strings.Join(args, " "),
+	}
+	return strings.Join(steps, " && ")
+}
+
+// lineWriter calls onLine with every complete line written to it
+type lineWriter struct {
+	mu      sync.Mutex
+	onLine  func(string)
+	pending string
+}
+
+func newLineWriter(onLine func(string)) *lineWriter {
+	return &lineWriter{onLine: onLine}
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lines := strings.Split(lw.pending+string(p), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		lw.onLine(strings.TrimRight(line, "\r"))
+	}
+	lw.pending = lines[len(lines)-1]
+	return len(p), nil
+}
+
+// Flush passes on a last line without a newline
+func (lw *lineWriter) Flush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.pending != "" {
+		lw.onLine(lw.pending)
+		lw.pending = ""
+	}
+}
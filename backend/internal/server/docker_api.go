@@ -0,0 +1,388 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dockerAPIPort    = "2376"
+	dockerAPITimeout = 30 * time.Second
+)
+
+var errNoSuchContainer = errors.New("no such container")
+
+// dockerAPI talks to the Docker Engine API of a host over TLS with a client
+// certificate, as the docker CLI does with --tlsverify
+type dockerAPI struct {
+	addr string
+	tls  *tls.Config
+}
+
+type dockerContainerState struct {
+	Running   bool      `json:"Running"`
+	Pid       int       `json:"Pid"`
+	StartedAt time.Time `json:"StartedAt"`
+}
+
+type dockerCreateRequest struct {
+	Image        string              `json:"Image"`
+	Cmd          []string            `json:"Cmd"`
+	User         string              `json:"User,omitempty"`
+	WorkingDir   string              `json:"WorkingDir"`
+	Env          []string            `json:"Env"`
+	OpenStdin    bool                `json:"OpenStdin"`
+	AttachStdin  bool                `json:"AttachStdin"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   dockerHostConfig    `json:"HostConfig"`
+}
+
+type dockerHostConfig struct {
+	Binds        []string                       `json:"Binds"`
+	NetworkMode  string                         `json:"NetworkMode"`
+	PortBindings map[string][]dockerPortBinding `json:"PortBindings,omitempty"`
+	Init         bool                           `json:"Init"`
+	Memory       int64                          `json:"Memory,omitempty"`
+	NanoCpus     int64                          `json:"NanoCpus,omitempty"`
+}
+
+type dockerPortBinding struct {
+	HostIP   string `json:"HostIp,omitempty"`
+	HostPort string `json:"HostPort"`
+}
+
+// newDockerAPI loads the client certificate and CA of opts, which are read
+// again for every call so renewed certificates are picked up
+func newDockerAPI(opts DockerOptions) (*dockerAPI, error) {
+	host := strings.TrimPrefix(strings.TrimSpace(opts.Host), "tcp://")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), dockerAPIPort)
+	}
+	serverName, _, _ := net.SplitHostPort(host)
+
+	caPEM, err := os.ReadFile(opts.TLSCACert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker CA: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("docker CA %s holds no certificates", opts.TLSCACert)
+	}
+	cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load docker client certificate: %w", err)
+	}
+	return &dockerAPI{
+		addr: host,
+		tls: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			RootCAs:      roots,
+			Certificates: []tls.Certificate{cert},
+			ServerName:   serverName,
+		},
+	}, nil
+}
+
+// inspect returns the state of a container, or errNoSuchContainer
+func (d *dockerAPI) inspect(ctx context.Context, name string) (dockerContainerState, error) {
+	var info struct {
+		State dockerContainerState `json:"State"`
+	}
+	err := d.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil, nil, &info)
+	return info.State, err
+}
+
+// run removes any container left with the name, pulls the image when the
+// host lacks it, then creates and starts the container
+func (d *dockerAPI) run(ctx context.Context, name string, spec dockerCreateRequest) error {
+	if err := d.remove(ctx, name, true); err != nil && !errors.Is(err, errNoSuchContainer) {
+		return err
+	}
+	query := url.Values{"name": {name}}
+	err := d.do(ctx, http.MethodPost, "/containers/create", query, spec, nil)
+	if err != nil && strings.Contains(err.Error(), "No such image") {
+		if err := d.pull(ctx, spec.Image); err != nil {
+			return err
+		}
+		err = d.do(ctx, http.MethodPost, "/containers/create", query, spec, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	if err := d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/start", nil, nil, nil); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	return nil
+}
+
+// pull downloads an image. The API streams the progress as JSON and reports
+// failures in it rather than in the status code.
+func (d *dockerAPI) pull(ctx context.Context, image string) error {
+	from, tag := image, "latest"
+	if !strings.Contains(image, "@") {
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			from, tag = image[:i], image[i+1:]
+		}
+	}
+	resp, err := d.send(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {from}, "tag": {tag}}, nil, 0)
+	if err != nil {
+		return fmt.Errorf("pull %s: %w", image, err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var progress struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&progress); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("pull %s: %w", image, err)
+		}
+		if progress.Error != "" {
+			return fmt.Errorf("pull %s: %s", image, progress.Error)
+		}
+	}
+}
+
+// stop has the daemon send SIGTERM and, after timeout, SIGKILL
+func (d *dockerAPI) stop(ctx context.Context, name string, timeout int) error {
+	query := url.Values{"t": {strconv.Itoa(timeout)}}
+	return d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/stop", query, nil, nil)
+}
+
+func (d *dockerAPI) remove(ctx context.Context, name string, force bool) error {
+	query := url.Values{"force": {strconv.FormatBool(force)}}
+	return d.do(ctx, http.MethodDelete, "/containers/"+url.PathEscape(name), query, nil, nil)
+}
+
+func (d *dockerAPI) signal(ctx context.Context, name, signal string) error {
+	return d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/kill", url.Values{"signal": {signal}}, nil, nil)
+}
+
+// logs writes the container's output to w, the last tail lines and, with
+// follow, everything it writes until ctx is done or the container exits
+func (d *dockerAPI) logs(ctx context.Context, name string, tail int, follow bool, w io.Writer) error {
+	query := url.Values{
+		"stdout": {"1"},
+		"stderr": {"1"},
+		"tail":   {strconv.Itoa(tail)},
+		"follow": {strconv.FormatBool(follow)},
+	}
+	timeout := dockerAPITimeout
+	if follow {
+		timeout = 0
+	}
+	resp, err := d.send(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/logs", query, nil, timeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return demuxDockerStream(resp.Body, w)
+}
+
+// writeStdin attaches to the container's stdin and writes data to it. The
+// API hands the connection over to the raw stream after the request.
+func (d *dockerAPI) writeStdin(ctx context.Context, name, data string) error {
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: dockerAPITimeout}, Config: d.tls}
+	conn, err := dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(dockerAPITimeout))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+d.addr+"/containers/"+url.PathEscape(name)+"/attach?stream=1&stdin=1", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		return dockerAPIError(resp)
+	}
+	_, err = io.WriteString(conn, data)
+	return err
+}
+
+// do sends a request and decodes the JSON answer into out
+func (d *dockerAPI) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	resp, err := d.send(ctx, method, path, query, body, dockerAPITimeout)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send returns the response to a request, or the daemon's error for a
+// status other than 2xx and 304 (already stopped)
+func (d *dockerAPI) send(ctx context.Context, method, path string, query url.Values, body interface{}, timeout time.Duration) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint := "https://" + d.addr + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{TLSClientConfig: d.tls, DisableKeepAlives: true},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
+		return nil, dockerAPIError(resp)
+	}
+	return resp, nil
+}
+
+// dockerAPIError reads the daemon's {"message": "..."} error body
+func dockerAPIError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var failure struct {
+		Message string `json:"message"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &failure) == nil && failure.Message != "" {
+		message = failure.Message
+	}
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(message, "No such container") {
+		return errNoSuchContainer
+	}
+	if message == "" {
+		message = resp.Status
+	}
+	return fmt.Errorf("docker: %s", message)
+}
+
+// demuxDockerStream copies the output of a container without a TTY, which the
+// API frames with an 8 byte header holding the stream and the frame length
+func demuxDockerStream(r io.Reader, w io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// dockerCreateSpec maps the container settings to an API create request. The
+// working directory is bound at the same path, like the CLI does with $PWD.
+func dockerCreateSpec(workingDir, command, logFile string, opts DockerOptions) dockerCreateRequest {
+	image := strings.TrimSpace(opts.Image)
+	if image == "" {
+		image = DefaultDockerImage
+	}
+	network := strings.TrimSpace(opts.Network)
+	if network == "" {
+		network = "host"
+	}
+
+	inner := command + " 2>&1"
+	if logFile != "" {
+		inner += fmt.Sprintf(" | tee -i -a \"%s\"", escapeForDoubleQuotes(logFile))
+	}
+	spec := dockerCreateRequest{
+		Image:       image,
+		Cmd:         []string{"sh", "-c", inner},
+		User:        opts.User,
+		WorkingDir:  workingDir,
+		Env:         []string{"TINI_KILL_PROCESS_GROUP=1", "COLUMNS=500", "LINES=100"},
+		OpenStdin:   true,
+		AttachStdin: true,
+		HostConfig: dockerHostConfig{
+			Binds:       append([]string{workingDir + ":" + workingDir}, opts.Volumes...),
+			NetworkMode: network,
+			Init:        true,
+			Memory:      opts.MemoryBytes,
+			NanoCpus:    int64(opts.CPUs * 1e9),
+		},
+	}
+	keys := make([]string, 0, len(opts.Env))
+	for key := range opts.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		spec.Env = append(spec.Env, key+"="+opts.Env[key])
+	}
+	if network != "host" {
+		for _, port := range opts.Ports {
+			container, binding := parseDockerPort(port)
+			if spec.ExposedPorts == nil {
+				spec.ExposedPorts = make(map[string]struct{})
+				spec.HostConfig.PortBindings = make(map[string][]dockerPortBinding)
+			}
+			spec.ExposedPorts[container] = struct{}{}
+			spec.HostConfig.PortBindings[container] = append(spec.HostConfig.PortBindings[container], binding)
+		}
+	}
+	return spec
+}
+
+// parseDockerPort splits [ip:]host:container[/proto] into the container port
+// key of the API and its host binding
+func parseDockerPort(port string) (string, dockerPortBinding) {
+	spec, proto, _ := strings.Cut(port, "/")
+	if proto == "" {
+		proto = "tcp"
+	}
+	parts := strings.Split(spec, ":")
+	binding := dockerPortBinding{HostPort: parts[len(parts)-2]}
+	if len(parts) == 3 {
+		binding.HostIP = parts[0]
+	}
+	return parts[len(parts)-1] + "/" + proto, binding
+}
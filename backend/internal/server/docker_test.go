@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildDockerRunCommand(t *testing.T) {
+	cmd := buildDockerRunCommand("hytale-alpha", "/srv/hytale", "cd /srv/hytale && java -jar server.jar nogui", "/srv/hytale/console.log", DockerOptions{
+		Network: "bridge",
+		Ports:   []string{"5520:5520/udp"},
+		Env:     map[string]string{"TZ": "UTC"},
+	})
+
+	for _, want := range []string{
+		`cd "/srv/hytale" && rm -f .hsm-console && mkfifo -m 600 .hsm-console`,
+		`docker rm -f 'hytale-alpha'`,
+		`--network 'bridge'`,
+		`-e 'TZ=UTC'`,
+		`-p '5520:5520/udp'`,
+		`-v "$PWD:$PWD" -w "$PWD"`,
+		`'eclipse-temurin:25-jre' sh -c "exec 3<>.hsm-console; cd /srv/hytale && java -jar server.jar nogui <&3 2>&1 | tee -i -a \"/srv/hytale/console.log\""`,
+	} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("expected %q in %q", want, cmd)
+		}
+	}
+
+	hostCmd := buildDockerRunCommand("hytale-alpha", "/srv/hytale", "java -jar server.jar", "", DockerOptions{Ports: []string{"5520:5520/udp"}})
+	if strings.Contains(hostCmd, "-p ") || !strings.Contains(hostCmd, "--network 'host'") {
+		t.Errorf("expected host networking without published ports, got %q", hostCmd)
+	}
+}
+
+func TestProcessManagerRouter(t *testing.T) {
+	screen := NewMockProcessManager()
+	docker := NewMockProcessManager()
+	kinds := map[string]string{"alpha": "docker", "beta": "screen"}
+	router := NewProcessManagerRouter(screen, func(serverID string) string { return kinds[serverID] })
+	router.Register("docker", docker)
+
+	router.Start("alpha", "hytale-alpha", "java", "")
+	router.Start("beta", "hytale-beta", "java", "")
+	router.Start("gamma", "hytale-gamma", "java", "")
+
+	if !docker.processes["alpha"] || docker.processes["beta"] {
+		t.Errorf("expected only alpha in docker, got %v", docker.processes)
+	}
+	if !screen.processes["beta"] || !screen.processes["gamma"] || screen.processes["alpha"] {
+		t.Errorf("expected beta and gamma in screen, got %v", screen.processes)
+	}
+}
+
+func TestProcessManagerRouterGate(t *testing.T) {
+	docker := NewMockProcessManager()
+	router := NewProcessManagerRouter(NewMockProcessManager(), func(serverID string) string { return "docker" })
+	router.Register("docker", docker)
+	router.Gate("docker", func() error { return fmt.Errorf("docker is disabled") })
+
+	if err := router.Start("alpha", "hytale-alpha", "java", ""); err == nil || docker.processes["alpha"] {
+		t.Fatalf("expected the gate to refuse the start, got %v", err)
+	}
+
+	// A gated server that is already running can still be stopped
+	docker.processes["alpha"] = true
+	if err := router.Stop("alpha", "hytale-alpha"); err != nil || docker.processes["alpha"] {
+		t.Fatalf("expected the stop to pass the gate, got %v", err)
+	}
+}
+
+func TestDockerResourceLimits(t *testing.T) {
+	opts := DockerOptions{User: "1000:1000", MemoryBytes: 12 << 30, CPUs: 2.5, Network: "bridge", Ports: []string{"0.0.0.0:5520:5520/udp"}, Volumes: []string{"/srv/shared:/shared:ro"}}
+
+	cmd := buildDockerRunCommand("hytale-alpha", "/srv/hytale", "java -jar server.jar", "", opts)
+	for _, want := range []string{`--user '1000:1000'`, `--memory 12884901888`, `--cpus 2.5`} {
+		if !strings.Contains(cmd, want) {
+			t.Errorf("expected %q in %q", want, cmd)
+		}
+	}
+
+	spec := dockerCreateSpec("/srv/hytale", "java -jar server.jar", "/srv/hytale/console.log", opts)
+	if spec.HostConfig.Memory != 12<<30 || spec.HostConfig.NanoCpus != 2500000000 || spec.User != "1000:1000" {
+		t.Errorf("expected the limits and user in the create request, got %+v", spec)
+	}
+	if len(spec.HostConfig.Binds) != 2 || spec.HostConfig.Binds[0] != "/srv/hytale:/srv/hytale" || spec.WorkingDir != "/srv/hytale" {
+		t.Errorf("expected the working directory bound at its own path, got %v", spec.HostConfig.Binds)
+	}
+	binding := spec.HostConfig.PortBindings["5520/udp"]
+	if len(binding) != 1 || binding[0].HostIP != "0.0.0.0" || binding[0].HostPort != "5520" {
+		t.Errorf("unexpected port bindings %+v", spec.HostConfig.PortBindings)
+	}
+	if !spec.OpenStdin || spec.Cmd[2] != `java -jar server.jar 2>&1 | tee -i -a "/srv/hytale/console.log"` {
+		t.Errorf("expected the console on stdin and logged, got %v", spec.Cmd)
+	}
+}
+
+func TestDemuxDockerStream(t *testing.T) {
+	frame := func(stream byte, data string) []byte {
+		return append([]byte{stream, 0, 0, 0, 0, 0, 0, byte(len(data))}, data...)
+	}
+	var stream bytes.Buffer
+	stream.Write(frame(1, "[Server] Booting\n[Ser"))
+	stream.Write(frame(2, "ver] Ready\nno newline"))
+
+	var lines []string
+	writer := newLineWriter(func(line string) { lines = append(lines, line) })
+	if err := demuxDockerStream(&stream, writer); err != nil {
+		t.Fatalf("demux: %v", err)
+	}
+	writer.Flush()
+	if strings.Join(lines, "|") != "[Server] Booting|[Server] Ready|no newline" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+}
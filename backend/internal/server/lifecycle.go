@@ -1,23 +1,43 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"path"
-	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/yourusername/hytale-server-manager/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/hooks"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 )
 
+// logger is shared by the server package
+var logger = logging.For("server")
+
+// serverLockTimeout bounds how long a start or stop waits for another manager
+// instance to let go of the server
+const serverLockTimeout = time.Minute
+
+// ErrServerBusy is returned when another manager instance keeps working on a
+// server for longer than a start or stop waits for it
+var ErrServerBusy = errors.New("another manager instance is working on the server")
+
 // LifecycleManager orchestrates server start/stop/restart operations
 type LifecycleManager struct {
 	sshPool        *ssh.ConnectionPool
 	processManager ProcessManager
 	statusTracker  *StatusDetector
 	db             *sql.DB
+	hooks          *hooks.Runner
+	locker         Locker
+	lockTimeout    time.Duration
+
+	stoppingMu sync.Mutex
+	stopping   map[string]bool
 }
 
 // ServerConfig represents the configuration for starting a server
@@ -36,6 +56,7 @@ type ServerConfig struct {
 	SSHConfig      *ssh.ClientConfig // SSH connection details
 	RunAsUser      string
 	UseSudo        bool
+	ProcessManager string // "screen" when empty; "agent" needs no SSH
 }
 
 // StopWarning represents a warning message to send before shutdown
@@ -51,41 +72,104 @@ func NewLifecycleManager(pool *ssh.ConnectionPool, process ProcessManager, statu
 		processManager: process,
 		statusTracker:  status,
 		db:             db,
+		lockTimeout:    serverLockTimeout,
+		stopping:       make(map[string]bool),
 	}
 }
 
-// StartServer starts a game server
+// SetHooks runs pre_start and post_start hooks around every start, whether
+// it comes from the API, the watchdog, a maintenance window or a schedule
+func (lm *LifecycleManager) SetHooks(runner *hooks.Runner) {
+	lm.hooks = runner
+}
+
+// Locker keeps manager instances that share a database from driving the same
+// server at once. cluster.Node implements it.
+type Locker interface {
+	Lock(ctx context.Context, name string) (func(), error)
+}
+
+// ServerLease names the lease held by the instance starting, stopping or
+// running a task on a server
+func ServerLease(serverID string) string {
+	return "server:" + serverID
+}
+
+// SetLocker makes every start and stop wait until no other manager instance
+// is starting, stopping or running a task on the server
+func (lm *LifecycleManager) SetLocker(locker Locker) {
+	lm.locker = locker
+}
+
+// lock waits for the server's lease when other instances may hold it, and
+// gives up with ErrServerBusy after lockTimeout
+func (lm *LifecycleManager) lock(serverID string) (func(), error) {
+	if lm.locker == nil {
+		return func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lm.lockTimeout)
+	defer cancel()
+	unlock, err := lm.locker.Lock(ctx, ServerLease(serverID))
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, ErrServerBusy
+	}
+	return unlock, err
+}
+
+// StartServer starts a game server. pre_start hooks run once the server is
+// known not to be running and can stop the start; post_start hooks run after
+// it, whatever the outcome.
 func (lm *LifecycleManager) StartServer(serverID string, config *ServerConfig) error {
-	log.Printf("[Lifecycle] Starting server %s...", serverID)
+	unlock, err := lm.lock(serverID)
+	if err != nil {
+		return fmt.Errorf("failed to lock server: %w", err)
+	}
+	defer unlock()
+
+	logger.Info("Starting server", "server_id", serverID)
 	if lm.processManager != nil {
 		lm.processManager.SetRunAsUser(serverID, config.RunAsUser, config.UseSudo)
 	}
 
-	// Establish SSH connection if not already connected
-	if config.SSHConfig != nil {
-		log.Printf("[Lifecycle] Establishing SSH connection to %s...", serverID)
-		_, err := lm.sshPool.GetConnection(serverID, config.SSHConfig)
+	// Establish SSH connection if not already connected; the agent starts
+	// servers without one
+	if config.SSHConfig != nil && config.ProcessManager != "agent" {
+		logger.Info("Establishing SSH connection", "server_id", serverID)
+		conn, err := lm.sshPool.GetConnection(serverID, config.SSHConfig)
 		if err != nil {
 			return fmt.Errorf("failed to establish SSH connection: %w", err)
 		}
-		log.Printf("[Lifecycle] SSH connection established for %s", serverID)
-		if err := lm.ensureRemotePrereqs(serverID, config); err != nil {
-			lm.updateStatus(serverID, "error", err.Error(), 0)
-			return err
+		logger.Info("SSH connection established", "server_id", serverID, "local", conn.Client.IsLocal())
+		// A local server shares the manager's host, which is set up by hand
+		if !conn.Client.IsLocal() {
+			if err := lm.ensureRemotePrereqs(serverID, config); err != nil {
+				lm.updateStatus(serverID, "error", err.Error(), 0)
+				return err
+			}
 		}
 	}
 
 	// Check if server is already running
 	status, err := lm.statusTracker.DetectStatus(serverID, config.SessionName)
 	if err != nil {
-		log.Printf("[Lifecycle] Failed to check current status: %v", err)
+		logger.Error("Failed to check current status", "server_id", serverID, "error", err)
 	} else if status != nil && (status.Status == "online" || status.Status == "starting") {
 		return fmt.Errorf("server is already %s", status.Status)
 	}
 
+	if err := lm.hooks.Run(context.Background(), hooks.NewEvent(hooks.PreStart, serverID, nil)); err != nil {
+		return err
+	}
+	err = lm.launch(serverID, config)
+	lm.hooks.Run(context.Background(), hooks.NewEvent(hooks.PostStart, serverID, nil).WithResult(err))
+	return err
+}
+
+// launch starts the server's process and waits for it to come online
+func (lm *LifecycleManager) launch(serverID string, config *ServerConfig) error {
 	// Update status to starting
 	if err := lm.updateStatus(serverID, "starting", "", 0); err != nil {
-		log.Printf("[Lifecycle] Warning: Failed to update status: %v", err)
+		logger.Warn("Failed to update status", "server_id", serverID, "error", err)
 	}
 
 	// Build the Java command
@@ -97,7 +181,7 @@ func (lm *LifecycleManager) StartServer(serverID string, config *ServerConfig) e
 	}
 
 	// Create screen session with logging
-	err = lm.processManager.Start(
+	err := lm.processManager.Start(
 		serverID,
 		config.SessionName,
 		javaCmd,
@@ -109,21 +193,21 @@ func (lm *LifecycleManager) StartServer(serverID string, config *ServerConfig) e
 	}
 
 	// Wait for server to start
-	log.Printf("[Lifecycle] Waiting for server %s to start (timeout: %v)...", serverID, config.StartupTimeout)
+	logger.Info("Waiting for server to start", "server_id", serverID, "startup_timeout", config.StartupTimeout.String())
 	startTime := time.Now()
 	deadline := startTime.Add(config.StartupTimeout)
 
 	for time.Now().Before(deadline) {
 		status, err := lm.statusTracker.DetectStatus(serverID, config.SessionName)
 		if err != nil {
-			log.Printf("[Lifecycle] Status check error: %v", err)
+			logger.Error("Status check error", "server_id", serverID, "error", err)
 			time.Sleep(2 * time.Second)
 			continue
 		}
 
 		if status != nil && status.Status == "online" {
 			elapsed := time.Since(startTime)
-			log.Printf("[Lifecycle] Server %s started successfully in %v", serverID, elapsed)
+			logger.Info("Server started successfully", "server_id", serverID, "elapsed", elapsed.String())
 
 			// Update status with PID
 			lm.updateStatus(serverID, "online", "", status.PID)
@@ -171,8 +255,9 @@ func (lm *LifecycleManager) ensureRemotePrereqs(serverID string, config *ServerC
 		}
 	}
 
-	if err := run("command -v screen >/dev/null 2>&1"); err != nil {
-		return fmt.Errorf("screen is not installed on the target host")
+	tool := processManagerTool(config.ProcessManager)
+	if err := run(fmt.Sprintf("command -v %s >/dev/null 2>&1", tool)); err != nil {
+		return fmt.Errorf("%s is not installed on the target host", tool)
 	}
 
 	if config.WorkingDir != "" {
@@ -228,6 +313,17 @@ func (lm *LifecycleManager) ensureRemotePrereqs(serverID string, config *ServerC
 	return nil
 }
 
+// processManagerTool returns the command a process manager needs on the host
+func processManagerTool(kind string) string {
+	switch kind {
+	case "tmux", "docker":
+		return kind
+	case "systemd":
+		return "systemctl"
+	}
+	return "screen"
+}
+
 func bashQuote(value string) string {
 	if value == "" {
 		return "''"
@@ -265,29 +361,37 @@ func isSudoError(output string) bool {
 
 // StopServer stops a game server
 func (lm *LifecycleManager) StopServer(serverID string, config *ServerConfig, graceful bool) error {
-	log.Printf("[Lifecycle] Stopping server %s (graceful: %v)...", serverID, graceful)
-	log.Printf("[Lifecycle] Looking for screen session: %s", config.SessionName)
+	unlock, err := lm.lock(serverID)
+	if err != nil {
+		return fmt.Errorf("failed to lock server: %w", err)
+	}
+	defer unlock()
+
+	logger.Info("Stopping server", "server_id", serverID, "graceful", graceful)
+	logger.Debug("Looking for screen session", "server_id", serverID, "session", config.SessionName)
 	if lm.processManager != nil {
 		lm.processManager.SetRunAsUser(serverID, config.RunAsUser, config.UseSudo)
 	}
+	lm.setStopping(serverID, true)
+	defer lm.setStopping(serverID, false)
 
 	// Check if server is running
 	status, err := lm.statusTracker.DetectStatus(serverID, config.SessionName)
 	if err != nil {
-		log.Printf("[Lifecycle] Status detection error: %v", err)
+		logger.Error("Status detection error", "server_id", serverID, "error", err)
 		return fmt.Errorf("failed to check server status: %w", err)
 	}
 
-	log.Printf("[Lifecycle] Current status: %+v", status)
+	logger.Debug("Current status", "server_id", serverID, "status", status)
 
 	if status == nil || status.Status == "offline" {
-		log.Printf("[Lifecycle] Server %s is already offline, skipping stop", serverID)
+		logger.Info("Server is already offline, skipping stop", "server_id", serverID)
 		return nil
 	}
 
 	// Update status to stopping
 	if err := lm.updateStatus(serverID, "stopping", "", status.PID); err != nil {
-		log.Printf("[Lifecycle] Warning: Failed to update status: %v", err)
+		logger.Warn("Failed to update status", "server_id", serverID, "error", err)
 	}
 
 	if graceful {
@@ -297,52 +401,52 @@ func (lm *LifecycleManager) StopServer(serverID string, config *ServerConfig, gr
 				time.Sleep(warning.Delay)
 			}
 			if warning.Message != "" {
-				log.Printf("[Lifecycle] Sending warning: %s", warning.Message)
+				logger.Info("Sending warning", "server_id", serverID, "message", warning.Message)
 				if err := lm.processManager.SendCommand(serverID, config.SessionName, fmt.Sprintf("say %s", warning.Message)); err != nil {
-					log.Printf("[Lifecycle] Warning: Failed to send warning: %v", err)
+					logger.Warn("Failed to send warning", "server_id", serverID, "error", err)
 				}
 			}
 		}
 
 		// Send stop commands
 		for _, cmd := range config.StopCommands {
-			log.Printf("[Lifecycle] Sending stop command: %s", cmd)
+			logger.Info("Sending stop command", "server_id", serverID, "command", cmd)
 			if err := lm.processManager.SendCommand(serverID, config.SessionName, cmd); err != nil {
-				log.Printf("[Lifecycle] Warning: Failed to send stop command: %v", err)
+				logger.Warn("Failed to send stop command", "server_id", serverID, "error", err)
 			}
 			time.Sleep(1 * time.Second)
 		}
 
 		// Wait for graceful shutdown
-		log.Printf("[Lifecycle] Waiting for graceful shutdown (timeout: %v)...", config.StopTimeout)
+		logger.Info("Waiting for graceful shutdown", "server_id", serverID, "stop_timeout", config.StopTimeout.String())
 		if err := lm.waitForShutdown(serverID, config.SessionName, config.StopTimeout); err == nil {
-			log.Printf("[Lifecycle] Server %s stopped gracefully", serverID)
+			logger.Info("Server stopped gracefully", "server_id", serverID)
 			lm.updateStatus(serverID, "offline", "", 0)
 			lm.updateServerTimes(serverID, time.Time{}, time.Now())
 			return nil
 		}
 
-		log.Printf("[Lifecycle] Graceful shutdown timeout, forcing stop...")
+		logger.Warn("Graceful shutdown timed out, forcing stop", "server_id", serverID)
 	}
 
 	// Force stop: Send Ctrl+C
-	log.Printf("[Lifecycle] Sending Ctrl+C to session %s", config.SessionName)
+	logger.Info("Sending Ctrl+C to session", "server_id", serverID, "session", config.SessionName)
 	if err := lm.processManager.SendCtrlC(serverID, config.SessionName); err != nil {
-		log.Printf("[Lifecycle] Warning: Failed to send Ctrl+C: %v", err)
+		logger.Warn("Failed to send Ctrl+C", "server_id", serverID, "error", err)
 	}
 
 	// Wait for process to stop (60 seconds)
 	if err := lm.waitForShutdown(serverID, config.SessionName, 60*time.Second); err == nil {
-		log.Printf("[Lifecycle] Server %s stopped after Ctrl+C", serverID)
+		logger.Info("Server stopped after Ctrl+C", "server_id", serverID)
 		lm.updateStatus(serverID, "offline", "", 0)
 		lm.updateServerTimes(serverID, time.Time{}, time.Now())
 		return nil
 	}
 
 	// Last resort: Force kill screen session
-	log.Printf("[Lifecycle] Force killing screen session %s", config.SessionName)
+	logger.Info("Force killing screen session", "server_id", serverID, "session", config.SessionName)
 	if err := lm.processManager.Stop(serverID, config.SessionName); err != nil {
-		log.Printf("[Lifecycle] Warning: Failed to quit session: %v", err)
+		logger.Warn("Failed to quit session", "server_id", serverID, "error", err)
 	}
 
 	// Final verification
@@ -352,7 +456,7 @@ func (lm *LifecycleManager) StopServer(serverID string, config *ServerConfig, gr
 		return fmt.Errorf("failed to stop server completely")
 	}
 
-	log.Printf("[Lifecycle] Server %s stopped (forced)", serverID)
+	logger.Info("Server stopped (forced)", "server_id", serverID)
 	lm.updateStatus(serverID, "offline", "", 0)
 	lm.updateServerTimes(serverID, time.Time{}, time.Now())
 
@@ -361,7 +465,14 @@ func (lm *LifecycleManager) StopServer(serverID string, config *ServerConfig, gr
 
 // RestartServer restarts a game server
 func (lm *LifecycleManager) RestartServer(serverID string, config *ServerConfig, graceful bool) error {
-	log.Printf("[Lifecycle] Restarting server %s...", serverID)
+	// Hold the server between the stop and the start
+	unlock, err := lm.lock(serverID)
+	if err != nil {
+		return fmt.Errorf("failed to lock server: %w", err)
+	}
+	defer unlock()
+
+	logger.Info("Restarting server", "server_id", serverID)
 
 	// Stop the server
 	if err := lm.StopServer(serverID, config, graceful); err != nil {
@@ -376,11 +487,40 @@ func (lm *LifecycleManager) RestartServer(serverID string, config *ServerConfig,
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
-	log.Printf("[Lifecycle] Server %s restarted successfully", serverID)
+	logger.Info("Server restarted successfully", "server_id", serverID)
 
 	return nil
 }
 
+// WantsRunning reports whether a server was last started, not stopped, through
+// the manager and is not being stopped right now. An exit of such a server was
+// not asked for.
+func (lm *LifecycleManager) WantsRunning(serverID string) bool {
+	lm.stoppingMu.Lock()
+	stopping := lm.stopping[serverID]
+	lm.stoppingMu.Unlock()
+	if stopping || lm.db == nil {
+		return false
+	}
+
+	var started, stopped sql.NullTime
+	err := lm.db.QueryRow(`SELECT last_started, last_stopped FROM server_status WHERE server_id = ?`, serverID).Scan(&started, &stopped)
+	if err != nil || !started.Valid {
+		return false
+	}
+	return !stopped.Valid || started.Time.After(stopped.Time)
+}
+
+func (lm *LifecycleManager) setStopping(serverID string, stopping bool) {
+	lm.stoppingMu.Lock()
+	defer lm.stoppingMu.Unlock()
+	if stopping {
+		lm.stopping[serverID] = true
+	} else {
+		delete(lm.stopping, serverID)
+	}
+}
+
 // GetServerPID retrieves the process ID of a running server
 func (lm *LifecycleManager) GetServerPID(serverID, sessionName string) (int, error) {
 	status, err := lm.statusTracker.DetectStatus(serverID, sessionName)
@@ -408,7 +548,7 @@ func (lm *LifecycleManager) buildJavaCommand(config *ServerConfig) string {
 		parts = append(parts, config.JavaArgs...)
 
 		// Add -jar and executable
-		parts = append(parts, "-jar", filepath.Base(config.Executable))
+		parts = append(parts, "-jar", path.Base(config.Executable))
 
 		// Add server arguments
 		parts = append(parts, config.ServerArgs...)
@@ -434,7 +574,7 @@ func (lm *LifecycleManager) waitForShutdown(serverID, sessionName string, timeou
 	for time.Now().Before(deadline) {
 		status, err := lm.statusTracker.DetectStatus(serverID, sessionName)
 		if err != nil {
-			log.Printf("[Lifecycle] Status check error during shutdown wait: %v", err)
+			logger.Error("Status check error during shutdown wait", "server_id", serverID, "error", err)
 			time.Sleep(2 * time.Second)
 			continue
 		}
@@ -1,6 +1,11 @@
 package server
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
 
 type noopProcessManager struct{}
 
@@ -32,6 +37,8 @@ func (noopProcessManager) GetPID(serverID, sessionName string) (int, error) {
 	return 0, nil
 }
 
+func (noopProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {}
+
 func TestBuildJavaCommand(t *testing.T) {
 	manager := NewLifecycleManager(nil, noopProcessManager{}, nil, nil)
 	cmd := manager.buildJavaCommand(&ServerConfig{
@@ -45,3 +52,22 @@ func TestBuildJavaCommand(t *testing.T) {
 		t.Fatalf("expected command to be built")
 	}
 }
+
+// heldLocker never grants a lease, as when another instance holds it
+type heldLocker struct{}
+
+func (heldLocker) Lock(ctx context.Context, name string) (func(), error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestStartServerGivesUpOnHeldLease(t *testing.T) {
+	manager := NewLifecycleManager(nil, noopProcessManager{}, nil, nil)
+	manager.SetLocker(heldLocker{})
+	manager.lockTimeout = 10 * time.Millisecond
+
+	err := manager.StartServer("alpha", &ServerConfig{})
+	if !errors.Is(err, ErrServerBusy) {
+		t.Fatalf("expected ErrServerBusy, got %v", err)
+	}
+}
@@ -53,6 +53,8 @@ func (m *MockProcessManager) SendCtrlC(serverID, sessionName string) error {
 	return nil
 }
 
+func (m *MockProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {}
+
 func (m *MockProcessManager) GetPID(serverID, sessionName string) (int, error) {
 	if m.processes[serverID] {
 		return 1234, nil
@@ -1,5 +1,10 @@
 package server
 
+import (
+	"context"
+	"time"
+)
+
 // ProcessManager defines the interface for managing game server processes
 type ProcessManager interface {
 	// Start starts a new process
@@ -26,3 +31,20 @@ type ProcessManager interface {
 	// GetPID returns the process ID
 	GetPID(serverID, sessionName string) (int, error)
 }
+
+// ProcessInspector is implemented by process managers that find the server
+// process and its uptime themselves, so status checks need not list the
+// host's processes over SSH
+type ProcessInspector interface {
+	// InspectProcess returns the PID of the server process, 0 when it is
+	// not running, and how long it has run
+	InspectProcess(serverID, sessionName string) (int, time.Duration, error)
+}
+
+// LogStreamer is implemented by process managers that can follow the output
+// of a server, so its console need not read a screen session
+type LogStreamer interface {
+	// StreamLogs calls onLine with each line the server writes until ctx is
+	// done or the process exits
+	StreamLogs(ctx context.Context, serverID, sessionName string, onLine func(string)) error
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"strings"
+	"sync"
+)
+
+// ProcessManagerRouter dispatches each call to the process manager configured
+// for the server, falling back to the default one for unknown kinds
+type ProcessManagerRouter struct {
+	fallback ProcessManager
+	kindOf   func(serverID string) string
+	mu       sync.RWMutex
+	managers map[string]ProcessManager
+	gates    map[string]func() error
+}
+
+// NewProcessManagerRouter creates a router. kindOf returns the process_manager
+// value of a server definition.
+func NewProcessManagerRouter(fallback ProcessManager, kindOf func(serverID string) string) *ProcessManagerRouter {
+	return &ProcessManagerRouter{
+		fallback: fallback,
+		kindOf:   kindOf,
+		managers: make(map[string]ProcessManager),
+		gates:    make(map[string]func() error),
+	}
+}
+
+// Register makes a process manager available under the given kind
+func (r *ProcessManagerRouter) Register(kind string, manager ProcessManager) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.managers[strings.ToLower(kind)] = manager
+}
+
+// Gate has check approve every start of a server of the given kind. Servers
+// of that kind already running can still be inspected and stopped.
+func (r *ProcessManagerRouter) Gate(kind string, check func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gates[strings.ToLower(kind)] = check
+}
+
+func (r *ProcessManagerRouter) kind(serverID string) string {
+	if r.kindOf == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(r.kindOf(serverID)))
+}
+
+// For returns the process manager that handles the server
+func (r *ProcessManagerRouter) For(serverID string) ProcessManager {
+	if r.kindOf == nil {
+		return r.fallback
+	}
+	kind := r.kind(serverID)
+	r.mu.RLock()
+	manager, ok := r.managers[kind]
+	r.mu.RUnlock()
+	if !ok {
+		return r.fallback
+	}
+	return manager
+}
+
+// Inspector returns the server's process manager when it inspects the server
+// process itself
+func (r *ProcessManagerRouter) Inspector(serverID string) (ProcessInspector, bool) {
+	inspector, ok := r.For(serverID).(ProcessInspector)
+	return inspector, ok
+}
+
+// Streamer returns the server's process manager when it can follow the
+// server's output
+func (r *ProcessManagerRouter) Streamer(serverID string) (LogStreamer, bool) {
+	streamer, ok := r.For(serverID).(LogStreamer)
+	return streamer, ok
+}
+
+// SetRunAsUser configures the run-as user on every registered manager so a
+// server that switches process manager keeps its settings
+func (r *ProcessManagerRouter) SetRunAsUser(serverID, runAsUser string, useSudo bool) {
+	r.fallback.SetRunAsUser(serverID, runAsUser, useSudo)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, manager := range r.managers {
+		if manager != r.fallback {
+			manager.SetRunAsUser(serverID, runAsUser, useSudo)
+		}
+	}
+}
+
+// Start starts the server process
+func (r *ProcessManagerRouter) Start(serverID, sessionName, command, logFile string) error {
+	kind := r.kind(serverID)
+	r.mu.RLock()
+	check := r.gates[kind]
+	r.mu.RUnlock()
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return r.For(serverID).Start(serverID, sessionName, command, logFile)
+}
+
+// Stop gracefully stops the server process
+func (r *ProcessManagerRouter) Stop(serverID, sessionName string) error {
+	return r.For(serverID).Stop(serverID, sessionName)
+}
+
+// Kill forcefully kills the server process
+func (r *ProcessManagerRouter) Kill(serverID, sessionName string) error {
+	return r.For(serverID).Kill(serverID, sessionName)
+}
+
+// IsRunning checks if the server process is running
+func (r *ProcessManagerRouter) IsRunning(serverID, sessionName string) (bool, error) {
+	return r.For(serverID).IsRunning(serverID, sessionName)
+}
+
+// SendCommand sends a command to the server console
+func (r *ProcessManagerRouter) SendCommand(serverID, sessionName, command string) error {
+	return r.For(serverID).SendCommand(serverID, sessionName, command)
+}
+
+// SendCtrlC sends a Ctrl+C signal to the server process
+func (r *ProcessManagerRouter) SendCtrlC(serverID, sessionName string) error {
+	return r.For(serverID).SendCtrlC(serverID, sessionName)
+}
+
+// GetPID returns the process ID the server runs under
+func (r *ProcessManagerRouter) GetPID(serverID, sessionName string) (int, error) {
+	return r.For(serverID).GetPID(serverID, sessionName)
+}
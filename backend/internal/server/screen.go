@@ -2,13 +2,12 @@ package server
 
 import (
 	"fmt"
-	"log"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/yourusername/hytale-server-manager/internal/ssh"
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
 )
 
 // ScreenProcessManager handles interactions with GNU Screen sessions
@@ -86,7 +85,7 @@ func (sm *ScreenProcessManager) Start(serverID, sessionName, command, logFile st
 		return fmt.Errorf("screen session created but not found in session list")
 	}
 
-	log.Printf("[Screen] Created session %s with logging to %s", sessionName, logFile)
+	logger.Info("Created screen session", "server_id", serverID, "session", sessionName, "log_file", logFile)
 
 	return nil
 }
@@ -213,7 +212,7 @@ func (sm *ScreenProcessManager) SendCommand(serverID, sessionName, command strin
 		return fmt.Errorf("failed to send command to screen: %w (output: %s)", err, output)
 	}
 
-	log.Printf("[Screen] Sent command to session %s: %s", sessionName, command)
+	logger.Debug("Sent command to session", "server_id", serverID, "session", sessionName, "command", command)
 
 	return nil
 }
@@ -280,7 +279,7 @@ func (sm *ScreenProcessManager) DetachSession(serverID, sessionName string) erro
 		return fmt.Errorf("failed to detach session: %w (output: %s)", err, output)
 	}
 
-	log.Printf("[Screen] Detached session %s for server %s", sessionName, serverID)
+	logger.Info("Detached screen session", "session", sessionName, "server_id", serverID)
 
 	return nil
 }
@@ -298,7 +297,7 @@ func (sm *ScreenProcessManager) Stop(serverID, sessionName string) error {
 		return fmt.Errorf("failed to verify session: %w", err)
 	}
 	if !exists {
-		log.Printf("[Screen] Session %s already does not exist", sessionName)
+		logger.Debug("Screen session already gone", "server_id", serverID, "session", sessionName)
 		return nil // Not an error if it's already gone
 	}
 
@@ -314,10 +313,10 @@ func (sm *ScreenProcessManager) Stop(serverID, sessionName string) error {
 	time.Sleep(500 * time.Millisecond)
 	exists, _ = sm.IsRunning(serverID, sessionName)
 	if exists {
-		log.Printf("[Screen] Warning: Session %s still exists after quit command", sessionName)
+		logger.Warn("Screen session still exists after quit command", "server_id", serverID, "session", sessionName)
 	}
 
-	log.Printf("[Screen] Quit session %s for server %s", sessionName, serverID)
+	logger.Info("Quit screen session", "session", sessionName, "server_id", serverID)
 
 	return nil
 }
@@ -338,7 +337,7 @@ func (sm *ScreenProcessManager) SendCtrlC(serverID, sessionName string) error {
 		return fmt.Errorf("failed to send Ctrl+C: %w (output: %s)", err, output)
 	}
 
-	log.Printf("[Screen] Sent Ctrl+C to session %s", sessionName)
+	logger.Info("Sent Ctrl+C to session", "server_id", serverID, "session", sessionName)
 
 	return nil
 }
@@ -375,7 +374,7 @@ func (sm *ScreenProcessManager) Kill(serverID, sessionName string) error {
 		return fmt.Errorf("failed to kill session: %w (output: %s)", err, output)
 	}
 
-	log.Printf("[Screen] Forcefully killed session %s (PID: %d)", sessionName, pid)
+	logger.Info("Force killed screen session", "server_id", serverID, "session", sessionName, "pid", pid)
 
 	return nil
 }
@@ -401,7 +400,7 @@ func (sm *ScreenProcessManager) WaitForSessionExit(serverID, sessionName string,
 		}
 
 		if !exists {
-			log.Printf("[Screen] Session %s has exited", sessionName)
+			logger.Info("Screen session has exited", "server_id", serverID, "session", sessionName)
 			return nil
 		}
 
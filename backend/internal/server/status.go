@@ -3,7 +3,6 @@ package server
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"regexp"
 	"strconv"
 	"strings"
@@ -56,7 +55,7 @@ func (sd *StatusDetector) DetectStatus(serverID, sessionName string) (*ServerSta
 	// Step 1: Check if screen session exists
 	sessionExists, err := sd.processManager.IsRunning(serverID, sessionName)
 	if err != nil {
-		log.Printf("[Status] Error checking screen session: %v", err)
+		logger.Error("Error checking screen session", "server_id", serverID, "error", err)
 		info.Status = StatusError
 		info.ErrorMessage = fmt.Sprintf("Failed to check screen session: %v", err)
 		return info, nil
@@ -67,24 +66,39 @@ func (sd *StatusDetector) DetectStatus(serverID, sessionName string) (*ServerSta
 		if dbStatus == StatusError && dbError != "" {
 			info.Status = StatusError
 			info.ErrorMessage = dbError
-			log.Printf("[Status] Server %s: Screen session not found - ERROR", serverID)
+			logger.Debug("Screen session not found", "server_id", serverID, "status", info.Status)
 			return info, nil
 		}
 		if dbStatus == StatusStarting || dbStatus == StatusStopping {
 			info.Status = dbStatus
-			log.Printf("[Status] Server %s: Screen session not found - %s", serverID, info.Status)
+			logger.Debug("Screen session not found", "server_id", serverID, "status", info.Status)
 			return info, nil
 		}
-		log.Printf("[Status] Server %s: Screen session not found - OFFLINE", serverID)
+		logger.Debug("Screen session not found", "server_id", serverID, "status", StatusOffline)
 		info.Status = StatusOffline
 		return info, nil
 	}
 
 	// Step 3: Check if server process is running (Java or any process in screen)
-	processPID, processRunning, err := sd.checkServerProcess(serverID, sessionName)
-	if err != nil {
-		log.Printf("[Status] Error checking server process: %v", err)
-		// Continue with detection, this is not fatal
+	var processPID int
+	var processRunning bool
+	processUptime := func() (time.Duration, error) {
+		return sd.getProcessUptime(serverID, processPID)
+	}
+	if inspector, ok := sd.inspector(serverID); ok {
+		var uptime time.Duration
+		processPID, uptime, err = inspector.InspectProcess(serverID, sessionName)
+		if err != nil {
+			logger.Error("Error inspecting server process", "server_id", serverID, "error", err)
+		}
+		processRunning = err == nil && processPID > 0
+		processUptime = func() (time.Duration, error) { return uptime, nil }
+	} else {
+		processPID, processRunning, err = sd.checkServerProcess(serverID, sessionName)
+		if err != nil {
+			logger.Error("Error checking server process", "server_id", serverID, "error", err)
+			// Continue with detection, this is not fatal
+		}
 	}
 
 	// Determine status based on findings
@@ -98,27 +112,27 @@ func (sd *StatusDetector) DetectStatus(serverID, sessionName string) (*ServerSta
 			info.Status = StatusError
 			info.ErrorMessage = "Screen session exists but server process not found (crashed?)"
 		}
-		log.Printf("[Status] Server %s: Screen exists but no server process - %s", serverID, info.Status)
+		logger.Debug("Screen exists but no server process", "server_id", serverID, "status", info.Status)
 	} else {
 		// Server process is running
 		info.PID = processPID
 
 		// Check if process recently started (< 30 seconds)
-		uptime, err := sd.getProcessUptime(serverID, processPID)
+		uptime, err := processUptime()
 		if err == nil {
 			info.UptimeSeconds = int64(uptime.Seconds())
 
 			if uptime < 30*time.Second {
 				info.Status = StatusStarting
-				log.Printf("[Status] Server %s: Server process recently started (uptime: %v) - STARTING", serverID, uptime)
+				logger.Debug("Server process recently started", "server_id", serverID, "status", StatusStarting, "uptime", uptime.String())
 			} else {
 				info.Status = StatusOnline
-				log.Printf("[Status] Server %s: Server process running (PID: %d, uptime: %v) - ONLINE", serverID, processPID, uptime)
+				logger.Debug("Server process running", "server_id", serverID, "pid", processPID, "uptime", uptime.String())
 			}
 		} else {
 			// Couldn't get uptime, assume online if process exists
 			info.Status = StatusOnline
-			log.Printf("[Status] Server %s: Server process running (PID: %d) - ONLINE", serverID, processPID)
+			logger.Debug("Server process running", "server_id", serverID, "pid", processPID)
 		}
 	}
 
@@ -130,6 +144,16 @@ func (sd *StatusDetector) DetectStatus(serverID, sessionName string) (*ServerSta
 	return info, nil
 }
 
+// inspector returns the server's process manager when it finds the server
+// process itself
+func (sd *StatusDetector) inspector(serverID string) (ProcessInspector, bool) {
+	if router, ok := sd.processManager.(*ProcessManagerRouter); ok {
+		return router.Inspector(serverID)
+	}
+	inspector, ok := sd.processManager.(ProcessInspector)
+	return inspector, ok
+}
+
 // checkServerProcess checks if a server process is running in the screen session
 // This works for both Java servers and bash script mock servers
 func (sd *StatusDetector) checkServerProcess(serverID, sessionName string) (int, bool, error) {
@@ -189,7 +213,7 @@ func (sd *StatusDetector) checkServerProcess(serverID, sessionName string) (int,
 	
 	descendants := findDescendants(screenPID)
 	if len(descendants) == 0 {
-		log.Printf("[Status] No processes found for screen session %d", screenPID)
+		logger.Debug("No processes found for screen session", "server_id", serverID, "screen_pid", screenPID)
 		return 0, false, nil
 	}
 	
@@ -203,7 +227,7 @@ func (sd *StatusDetector) checkServerProcess(serverID, sessionName string) (int,
 		
 		// Prefer Java processes
 		if strings.Contains(comm, "java") {
-			log.Printf("[Status] Found Java process: PID=%d", pid)
+			logger.Debug("Found Java process", "server_id", serverID, "pid", pid)
 			return pid, true, nil
 		}
 		
@@ -217,14 +241,14 @@ func (sd *StatusDetector) checkServerProcess(serverID, sessionName string) (int,
 	
 	// If we found a bash/sh candidate, use it
 	if candidatePID != 0 {
-		log.Printf("[Status] Found server process: PID=%d, Command=%s", candidatePID, candidateComm)
+		logger.Debug("Found server process", "server_id", serverID, "pid", candidatePID, "command", candidateComm)
 		return candidatePID, true, nil
 	}
 	
 	// Otherwise use the first descendant
 	if len(descendants) > 0 {
 		candidatePID = descendants[0]
-		log.Printf("[Status] Using first descendant process: PID=%d", candidatePID)
+		logger.Debug("Using first descendant process", "server_id", serverID, "pid", candidatePID)
 		return candidatePID, true, nil
 	}
 
@@ -328,7 +352,7 @@ func (sd *StatusDetector) getLastKnownStatus(serverID string) string {
 		if err == sql.ErrNoRows {
 			return StatusUnknown
 		}
-		log.Printf("[Status] Error querying last known status: %v", err)
+		logger.Error("Error querying last known status", "server_id", serverID, "error", err)
 		return StatusUnknown
 	}
 
@@ -385,7 +409,7 @@ func (sd *StatusDetector) updateStatusInDB(info *ServerStatusInfo) {
 	)
 
 	if err != nil {
-		log.Printf("[Status] Error updating status in database: %v", err)
+		logger.Error("Error updating status in database", "error", err)
 	}
 }
 
@@ -426,17 +450,17 @@ func (sd *StatusDetector) MonitorServerStatus(serverID, sessionName string, inte
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	log.Printf("[Status] Started monitoring server %s (interval: %v)", serverID, interval)
+	logger.Info("Started monitoring server", "server_id", serverID, "interval", interval.String())
 
 	for {
 		select {
 		case <-stopChan:
-			log.Printf("[Status] Stopped monitoring server %s", serverID)
+			logger.Info("Stopped monitoring server", "server_id", serverID)
 			return
 		case <-ticker.C:
 			_, err := sd.DetectStatus(serverID, sessionName)
 			if err != nil {
-				log.Printf("[Status] Error detecting status for %s: %v", serverID, err)
+				logger.Error("Error detecting status", "server_id", serverID, "error", err)
 			}
 		}
 	}
@@ -471,7 +495,7 @@ func (sd *StatusDetector) GetAllServerStatuses() ([]*ServerStatusInfo, error) {
 			&info.LastChecked,
 		)
 		if err != nil {
-			log.Printf("[Status] Error scanning row: %v", err)
+			logger.Error("Error scanning row", "error", err)
 			continue
 		}
 
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+)
+
+const (
+	systemdUnitDir     = "/etc/systemd/system"
+	systemdStopTimeout = 60
+
+	// systemdSudo runs systemctl and unit file writes through sudo unless
+	// the SSH user is already root
+	systemdSudo = `SUDO=; [ "$(id -u)" = 0 ] || SUDO="sudo -n"; `
+)
+
+// SystemdOptions describes the unit a server runs under
+type SystemdOptions struct {
+	UnitName string
+}
+
+// SystemdProcessManager runs each server as a systemd service on the remote
+// host. A companion socket unit provides a FIFO on the server's stdin so
+// console commands can be sent; output goes to journald and the console log.
+type SystemdProcessManager struct {
+	sshPool    *ssh.ConnectionPool
+	optionsFor func(serverID string) SystemdOptions
+	mu         sync.RWMutex
+	runAs      map[string]screenRunAs
+}
+
+// NewSystemdProcessManager creates a systemd process manager. optionsFor
+// returns the unit settings of a server.
+func NewSystemdProcessManager(pool *ssh.ConnectionPool, optionsFor func(serverID string) SystemdOptions) *SystemdProcessManager {
+	return &SystemdProcessManager{
+		sshPool:    pool,
+		optionsFor: optionsFor,
+		runAs:      make(map[string]screenRunAs),
+	}
+}
+
+// SetRunAsUser configures the User= of the generated service
+func (sd *SystemdProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if strings.TrimSpace(runAsUser) == "" {
+		delete(sd.runAs, serverID)
+		return
+	}
+	sd.runAs[serverID] = screenRunAs{user: strings.TrimSpace(runAsUser), useSudo: useSudo}
+}
+
+// Start writes the unit files, reloads systemd and starts the service
+func (sd *SystemdProcessManager) Start(serverID, sessionName, command, logFile string) error {
+	unit := sd.unitName(serverID, sessionName)
+	socket, service := buildSystemdUnits(serverID, unit, sd.user(serverID), command, logFile)
+
+	var script strings.Builder
+	script.WriteString(systemdSudo + "\n")
+	fmt.Fprintf(&script, "$SUDO tee %s/%s.socket >/dev/null <<HSM_UNIT\n%sHSM_UNIT\n", systemdUnitDir, unit, socket)
+	fmt.Fprintf(&script, "$SUDO tee %s/%s.service >/dev/null <<HSM_UNIT\n%sHSM_UNIT\n", systemdUnitDir, unit, service)
+	fmt.Fprintf(&script, "$SUDO systemctl daemon-reload && $SUDO systemctl start %s.service\n", unit)
+
+	output, err := sd.run(serverID, script.String())
+	if err != nil {
+		return fmt.Errorf("failed to start systemd service: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	running, err := sd.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify service: %w", err)
+	}
+	if !running {
+		logs, _ := sd.Logs(serverID, sessionName, 20)
+		return fmt.Errorf("service %s is not active after start: %s", unit, strings.TrimSpace(logs))
+	}
+
+	logger.Info("Started systemd service", "server_id", serverID, "unit", unit, "log_file", logFile)
+
+	return nil
+}
+
+// IsRunning checks if the service is active or restarting
+func (sd *SystemdProcessManager) IsRunning(serverID, sessionName string) (bool, error) {
+	output, err := sd.run(serverID, fmt.Sprintf("systemctl is-active %s.service || true", sd.unitName(serverID, sessionName)))
+	if err != nil {
+		return false, fmt.Errorf("failed to check service state: %w", err)
+	}
+	switch strings.TrimSpace(output) {
+	case "active", "activating", "reloading":
+		return true, nil
+	}
+	return false, nil
+}
+
+// GetPID returns the main PID of the service
+func (sd *SystemdProcessManager) GetPID(serverID, sessionName string) (int, error) {
+	unit := sd.unitName(serverID, sessionName)
+	output, err := sd.run(serverID, fmt.Sprintf("systemctl show -p MainPID --value %s.service", unit))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read service PID: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil || pid == 0 {
+		return 0, fmt.Errorf("service %s is not running", unit)
+	}
+	return pid, nil
+}
+
+// SendCommand writes a command to the service's stdin FIFO
+func (sd *SystemdProcessManager) SendCommand(serverID, sessionName, command string) error {
+	unit := sd.unitName(serverID, sessionName)
+	running, err := sd.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify service: %w", err)
+	}
+	if !running {
+		return fmt.Errorf("service %s is not running", unit)
+	}
+
+	writeCmd := fmt.Sprintf("%sprintf '%%s\\n' '%s' | timeout 5 $SUDO tee -a %s >/dev/null", systemdSudo, escapeCommand(command), systemdFifoPath(unit))
+	output, err := sd.run(serverID, writeCmd)
+	if err != nil {
+		return fmt.Errorf("failed to send command to service: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Debug("Sent command to service", "server_id", serverID, "unit", unit, "command", command)
+
+	return nil
+}
+
+// SendCtrlC sends SIGINT to the service's processes
+func (sd *SystemdProcessManager) SendCtrlC(serverID, sessionName string) error {
+	unit := sd.unitName(serverID, sessionName)
+	output, err := sd.run(serverID, fmt.Sprintf("%s$SUDO systemctl kill -s SIGINT %s.service", systemdSudo, unit))
+	if err != nil {
+		return fmt.Errorf("failed to send SIGINT: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Info("Sent SIGINT to service", "server_id", serverID, "unit", unit)
+
+	return nil
+}
+
+// Stop stops the service; systemd waits TimeoutStopSec before killing it
+func (sd *SystemdProcessManager) Stop(serverID, sessionName string) error {
+	unit := sd.unitName(serverID, sessionName)
+	output, err := sd.run(serverID, fmt.Sprintf("%s$SUDO systemctl stop %s.service %s.socket", systemdSudo, unit, unit))
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Info("Stopped systemd service", "server_id", serverID, "unit", unit)
+
+	return nil
+}
+
+// Kill sends SIGKILL to the service's processes and stops the unit
+func (sd *SystemdProcessManager) Kill(serverID, sessionName string) error {
+	unit := sd.unitName(serverID, sessionName)
+	killCmd := fmt.Sprintf("%s$SUDO systemctl kill -s SIGKILL %s.service; $SUDO systemctl stop %s.service %s.socket", systemdSudo, unit, unit, unit)
+	output, err := sd.run(serverID, killCmd)
+	if err != nil {
+		return fmt.Errorf("failed to kill service: %w (output: %s)", err, strings.TrimSpace(output))
+	}
+
+	logger.Info("Force killed systemd service", "server_id", serverID, "unit", unit)
+
+	return nil
+}
+
+// Logs returns the last journal lines of the service
+func (sd *SystemdProcessManager) Logs(serverID, sessionName string, tail int) (string, error) {
+	if tail <= 0 {
+		tail = 100
+	}
+	unit := sd.unitName(serverID, sessionName)
+	output, err := sd.run(serverID, fmt.Sprintf("%s$SUDO journalctl -u %s.service -n %d --no-pager -o cat 2>&1", systemdSudo, unit, tail))
+	if err != nil {
+		return output, fmt.Errorf("failed to read service journal: %w", err)
+	}
+	return output, nil
+}
+
+func (sd *SystemdProcessManager) unitName(serverID, sessionName string) string {
+	if sd.optionsFor != nil {
+		if name := strings.TrimSuffix(strings.TrimSpace(sd.optionsFor(serverID).UnitName), ".service"); name != "" {
+			return name
+		}
+	}
+	return sessionName
+}
+
+func (sd *SystemdProcessManager) user(serverID string) string {
+	sd.mu.RLock()
+	defer sd.mu.RUnlock()
+	return sd.runAs[serverID].user
+}
+
+func (sd *SystemdProcessManager) run(serverID, cmd string) (string, error) {
+	conn := sd.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return "", fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+	return conn.Client.RunCommand(cmd)
+}
+
+func systemdFifoPath(unit string) string {
+	return "/run/" + unit + ".stdin"
+}
+
+// buildSystemdUnits returns the socket and service unit files. They are
+// written through an unquoted heredoc, so shell expressions in the command
+// (such as the service user's home) are expanded on the host.
+func buildSystemdUnits(serverID, unit, user, command, logFile string) (string, string) {
+	if user == "" {
+		user = "$(id -un)"
+	}
+
+	socket := fmt.Sprintf(`[Unit]
+Description=Hytale server %[1]s console input
+PartOf=%[2]s.service
+
+[Socket]
+ListenFIFO=%[3]s
+SocketUser=%[4]s
+SocketMode=0600
+RemoveOnStop=true
+`, serverID, unit, systemdFifoPath(unit), user)
+
+	inner := command + " 2>&1"
+	if logFile != "" {
+		inner += fmt.Sprintf(` | tee -i -a "%s"`, logFile)
+	}
+	// % starts a systemd specifier
+	inner = strings.ReplaceAll(inner, "%", "%%")
+
+	service := fmt.Sprintf(`[Unit]
+Description=Hytale server %[1]s
+After=network-online.target %[2]s.socket
+Wants=network-online.target
+Requires=%[2]s.socket
+
+[Service]
+Type=simple
+User=%[3]s
+ExecStart=/bin/bash -c '%[4]s'
+Sockets=%[2]s.socket
+StandardInput=socket
+StandardOutput=journal
+StandardError=journal
+Restart=on-failure
+RestartSec=10
+TimeoutStopSec=%[5]d
+SuccessExitStatus=130 143
+
+[Install]
+WantedBy=multi-user.target
+`, serverID, unit, user, inner, systemdStopTimeout)
+
+	return socket, service
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildSystemdUnits(t *testing.T) {
+	socket, service := buildSystemdUnits("alpha", "hytale-alpha", "hytale", "cd /srv/hytale && java -XX:MaxRAMPercentage=75% -jar server.jar nogui", "/srv/hytale/console.log")
+
+	for _, want := range []string{
+		"ListenFIFO=/run/hytale-alpha.stdin",
+		"SocketUser=hytale",
+		"PartOf=hytale-alpha.service",
+	} {
+		if !strings.Contains(socket, want) {
+			t.Errorf("expected %q in socket unit:\n%s", want, socket)
+		}
+	}
+	for _, want := range []string{
+		"User=hytale",
+		`ExecStart=/bin/bash -c 'cd /srv/hytale && java -XX:MaxRAMPercentage=75%% -jar server.jar nogui 2>&1 | tee -i -a "/srv/hytale/console.log"'`,
+		"Sockets=hytale-alpha.socket",
+		"StandardInput=socket",
+		"StandardOutput=journal",
+		"Restart=on-failure",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("expected %q in service unit:\n%s", want, service)
+		}
+	}
+
+	_, service = buildSystemdUnits("alpha", "hytale-alpha", "", "java -jar server.jar", "")
+	if !strings.Contains(service, "User=$(id -un)") || strings.Contains(service, "tee") {
+		t.Errorf("expected the SSH user and no log file, got:\n%s", service)
+	}
+}
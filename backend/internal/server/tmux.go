@@ -0,0 +1,258 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/ssh"
+)
+
+// TmuxProcessManager handles interactions with tmux sessions, for hosts where
+// screen is unavailable or tmux is preferred
+type TmuxProcessManager struct {
+	sshPool *ssh.ConnectionPool
+	mu      sync.RWMutex
+	runAs   map[string]screenRunAs
+}
+
+// NewTmuxProcessManager creates a new tmux manager
+func NewTmuxProcessManager(pool *ssh.ConnectionPool) *TmuxProcessManager {
+	return &TmuxProcessManager{
+		sshPool: pool,
+		runAs:   make(map[string]screenRunAs),
+	}
+}
+
+// SetRunAsUser configures which user should own/manage the tmux session
+func (tm *TmuxProcessManager) SetRunAsUser(serverID, runAsUser string, useSudo bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if strings.TrimSpace(runAsUser) == "" {
+		delete(tm.runAs, serverID)
+		return
+	}
+	tm.runAs[serverID] = screenRunAs{user: strings.TrimSpace(runAsUser), useSudo: useSudo}
+}
+
+// Start starts a new process in a detached tmux session with logging
+func (tm *TmuxProcessManager) Start(serverID, sessionName, command, logFile string) error {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	tmuxCmd := buildTmuxStartCommand(sessionName, command, logFile)
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, tmuxCmd))
+	if err != nil {
+		return fmt.Errorf("failed to create tmux session with logging: %w (output: %s)", err, output)
+	}
+
+	// Verify session was created
+	time.Sleep(500 * time.Millisecond)
+	exists, err := tm.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify session creation: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("tmux session created but not found")
+	}
+
+	logger.Info("Created tmux session", "server_id", serverID, "session", sessionName, "log_file", logFile)
+
+	return nil
+}
+
+// IsRunning checks if a tmux session exists
+func (tm *TmuxProcessManager) IsRunning(serverID, sessionName string) (bool, error) {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return false, fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	// has-session exits non-zero when the session (or the tmux server) is gone
+	checkCmd := fmt.Sprintf("tmux has-session -t %s 2>/dev/null && echo yes || true", tmuxSessionTarget(sessionName))
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, checkCmd))
+	if err != nil {
+		return false, fmt.Errorf("failed to check session existence: %w", err)
+	}
+
+	return strings.TrimSpace(output) == "yes", nil
+}
+
+// SendCommand types a command into the tmux session and presses Enter
+func (tm *TmuxProcessManager) SendCommand(serverID, sessionName, command string) error {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	exists, err := tm.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify session: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("tmux session %s does not exist", sessionName)
+	}
+
+	// -l sends the text literally so words like "Enter" are not key names
+	target := tmuxPaneTarget(sessionName)
+	sendCmd := fmt.Sprintf("tmux send-keys -t %s -l '%s' && tmux send-keys -t %s Enter", target, escapeCommand(command), target)
+
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, sendCmd))
+	if err != nil {
+		return fmt.Errorf("failed to send command to tmux: %w (output: %s)", err, output)
+	}
+
+	logger.Debug("Sent command to session", "server_id", serverID, "session", sessionName, "command", command)
+
+	return nil
+}
+
+// SendCtrlC sends Ctrl+C to a tmux session
+func (tm *TmuxProcessManager) SendCtrlC(serverID, sessionName string) error {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	ctrlCCmd := fmt.Sprintf("tmux send-keys -t %s C-c", tmuxPaneTarget(sessionName))
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, ctrlCCmd))
+	if err != nil {
+		return fmt.Errorf("failed to send Ctrl+C: %w (output: %s)", err, output)
+	}
+
+	logger.Info("Sent Ctrl+C to session", "server_id", serverID, "session", sessionName)
+
+	return nil
+}
+
+// CapturePane returns the last lines visible in the session's pane
+func (tm *TmuxProcessManager) CapturePane(serverID, sessionName string, lines int) (string, error) {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return "", fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+	if lines <= 0 {
+		lines = 100
+	}
+
+	captureCmd := fmt.Sprintf("tmux capture-pane -p -J -t %s -S -%d", tmuxPaneTarget(sessionName), lines)
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, captureCmd))
+	if err != nil {
+		return "", fmt.Errorf("failed to capture pane: %w (output: %s)", err, output)
+	}
+
+	return output, nil
+}
+
+// Stop terminates a tmux session
+func (tm *TmuxProcessManager) Stop(serverID, sessionName string) error {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	exists, err := tm.IsRunning(serverID, sessionName)
+	if err != nil {
+		return fmt.Errorf("failed to verify session: %w", err)
+	}
+	if !exists {
+		logger.Debug("Tmux session already gone", "server_id", serverID, "session", sessionName)
+		return nil // Not an error if it's already gone
+	}
+
+	killCmd := fmt.Sprintf("tmux kill-session -t %s", tmuxSessionTarget(sessionName))
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, killCmd))
+	if err != nil {
+		return fmt.Errorf("failed to kill session: %w (output: %s)", err, output)
+	}
+
+	logger.Info("Killed tmux session", "session", sessionName, "server_id", serverID)
+
+	return nil
+}
+
+// GetPID returns the PID of the shell running in the session's pane
+func (tm *TmuxProcessManager) GetPID(serverID, sessionName string) (int, error) {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return 0, fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	pidCmd := fmt.Sprintf("tmux list-panes -t %s -F '#{pane_pid}'", tmuxSessionTarget(sessionName))
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, pidCmd))
+	if err != nil {
+		return 0, fmt.Errorf("session %s not found: %w", sessionName, err)
+	}
+
+	return parseTmuxPanePID(output)
+}
+
+// Kill forcefully kills the process in a tmux session and the session itself
+func (tm *TmuxProcessManager) Kill(serverID, sessionName string) error {
+	conn := tm.sshPool.GetExistingConnection(serverID)
+	if conn == nil {
+		return fmt.Errorf("no SSH connection available for server %s", serverID)
+	}
+
+	pid, err := tm.GetPID(serverID, sessionName)
+	if err != nil {
+		// Session might not exist
+		return nil
+	}
+
+	// Kill the pane's process group so the server dies with its shell
+	killCmd := fmt.Sprintf("kill -9 -- -%d 2>/dev/null || kill -9 %d; tmux kill-session -t %s 2>/dev/null || true", pid, pid, tmuxSessionTarget(sessionName))
+	output, err := conn.Client.RunCommand(tm.wrapForUser(serverID, killCmd))
+	if err != nil {
+		return fmt.Errorf("failed to kill session: %w (output: %s)", err, output)
+	}
+
+	logger.Info("Force killed tmux session", "server_id", serverID, "session", sessionName, "pid", pid)
+
+	return nil
+}
+
+func (tm *TmuxProcessManager) wrapForUser(serverID, cmd string) string {
+	tm.mu.RLock()
+	config, ok := tm.runAs[serverID]
+	tm.mu.RUnlock()
+	if !ok || config.user == "" || !config.useSudo {
+		return cmd
+	}
+	return fmt.Sprintf("sudo -n -i -u %s bash -lc %s", bashQuote(config.user), bashDoubleQuote(cmd))
+}
+
+// buildTmuxStartCommand returns the command that creates a detached, wide
+// tmux session running the server with its output tee'd to the log file
+func buildTmuxStartCommand(sessionName, command, logFile string) string {
+	inner := "export COLUMNS=500 LINES=100; " + expandTildeToHomeVarForShell(command) + " 2>&1"
+	if logFile != "" {
+		inner += " | tee -a " + expandTildeToHomeVarForShell(logFile)
+	}
+	return fmt.Sprintf("tmux new-session -d -s %s -x 500 -y 100 \"bash -lc %s\"",
+		bashQuote(sessionName),
+		escapeForDoubleQuotes(bashQuote(inner)),
+	)
+}
+
+// tmuxSessionTarget matches the session name exactly instead of by prefix
+func tmuxSessionTarget(sessionName string) string {
+	return bashQuote("=" + sessionName)
+}
+
+func tmuxPaneTarget(sessionName string) string {
+	return bashQuote("=" + sessionName + ":")
+}
+
+func parseTmuxPanePID(output string) (int, error) {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(output), "\n", 2)[0])
+	pid, err := strconv.Atoi(line)
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("unexpected tmux pane PID %q", line)
+	}
+	return pid, nil
+}
@@ -0,0 +1,21 @@
+package server
+
+import "testing"
+
+func TestBuildTmuxStartCommand(t *testing.T) {
+	cmd := buildTmuxStartCommand("hytale-alpha", "cd /srv/hytale && java -jar server.jar nogui", "/srv/hytale/console.log")
+	want := `tmux new-session -d -s 'hytale-alpha' -x 500 -y 100 "bash -lc 'export COLUMNS=500 LINES=100; cd /srv/hytale && java -jar server.jar nogui 2>&1 | tee -a /srv/hytale/console.log'"`
+	if cmd != want {
+		t.Fatalf("unexpected command:\n got %s\nwant %s", cmd, want)
+	}
+}
+
+func TestParseTmuxPanePID(t *testing.T) {
+	pid, err := parseTmuxPanePID("4242\n4343\n")
+	if err != nil || pid != 4242 {
+		t.Fatalf("expected 4242, got %d (%v)", pid, err)
+	}
+	if _, err := parseTmuxPanePID("no server running"); err == nil {
+		t.Fatalf("expected an error for unexpected output")
+	}
+}
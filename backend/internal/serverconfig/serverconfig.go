@@ -0,0 +1,268 @@
+// Package serverconfig reads, validates and writes a Hytale server's
+// config.json, and checks the launch settings the manager starts it with:
+// heap sizes and the game port.
+//
+// The server reads config.json when it starts, so an edit takes effect on
+// the next start. Settings the manager does not know are kept as they are.
+package serverconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// File is the server's configuration, in its working directory
+const File = "config.json"
+
+// Game modes a world can default to
+const (
+	GameModeAdventure = "Adventure"
+	GameModeCreative  = "Creative"
+)
+
+// Limits of the settings checked by Validate
+const (
+	MaxNameLength   = 64
+	MaxMOTDLength   = 256
+	MaxPlayersLimit = 1000
+	MaxViewRadius   = 64
+	// MinHeap and MaxHeap bound the heap sizes a server is started with
+	MinHeap = 512 << 20
+	MaxHeap = 1 << 40
+)
+
+// Config is config.json. Fields the manager does not know are kept in Extra.
+type Config struct {
+	Version       int      `json:"Version,omitempty"`
+	ServerName    string   `json:"ServerName"`
+	MOTD          string   `json:"MOTD"`
+	Password      string   `json:"Password"`
+	MaxPlayers    int      `json:"MaxPlayers"`
+	MaxViewRadius int      `json:"MaxViewRadius"`
+	Defaults      Defaults `json:"Defaults"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Defaults are the world and game mode players join
+type Defaults struct {
+	World    string `json:"World"`
+	GameMode string `json:"GameMode"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+var (
+	configFields   = []string{"Version", "ServerName", "MOTD", "Password", "MaxPlayers", "MaxViewRadius", "Defaults"}
+	defaultsFields = []string{"World", "GameMode"}
+	worldName      = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	// heapSize is a JVM memory size as -Xms and -Xmx take it, e.g. 512M or 10G
+	heapSize = regexp.MustCompile(`^([0-9]+)([kKmMgGtT]?)$`)
+)
+
+// Default returns the configuration a new server writes on its first start
+func Default() *Config {
+	return &Config{
+		Version:       3,
+		ServerName:    "Hytale Server",
+		MaxPlayers:    100,
+		MaxViewRadius: 32,
+		Defaults:      Defaults{World: "default", GameMode: GameModeAdventure},
+	}
+}
+
+// Parse reads config.json; a missing file gives the defaults
+func Parse(data []byte) (*Config, error) {
+	config := Default()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return config, nil
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", File, err)
+	}
+	return config, nil
+}
+
+// Encode writes config.json the way the server does
+func Encode(config *Config) ([]byte, error) {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Validate checks the settings the manager knows
+func (c *Config) Validate() error {
+	c.ServerName = strings.TrimSpace(c.ServerName)
+	switch {
+	case c.ServerName == "":
+		return fmt.Errorf("ServerName is required")
+	case utf8.RuneCountInString(c.ServerName) > MaxNameLength:
+		return fmt.Errorf("ServerName must be at most %d characters", MaxNameLength)
+	case utf8.RuneCountInString(c.MOTD) > MaxMOTDLength:
+		return fmt.Errorf("MOTD must be at most %d characters", MaxMOTDLength)
+	case strings.ContainsAny(c.ServerName+c.MOTD+c.Password, "\r\n"):
+		return fmt.Errorf("ServerName, MOTD and Password must be single lines")
+	case c.MaxPlayers < 1 || c.MaxPlayers > MaxPlayersLimit:
+		return fmt.Errorf("MaxPlayers must be between 1 and %d", MaxPlayersLimit)
+	case c.MaxViewRadius < 1 || c.MaxViewRadius > MaxViewRadius:
+		return fmt.Errorf("MaxViewRadius must be between 1 and %d", MaxViewRadius)
+	case !worldName.MatchString(c.Defaults.World):
+		return fmt.Errorf("Defaults.World %q is not a world name", c.Defaults.World)
+	case c.Defaults.GameMode != GameModeAdventure && c.Defaults.GameMode != GameModeCreative:
+		return fmt.Errorf("Defaults.GameMode must be '%s' or '%s'", GameModeAdventure, GameModeCreative)
+	}
+	return nil
+}
+
+// Keep carries over what an edit leaves out from current, the configuration
+// it replaces: settings the manager does not know, which an edit may change
+// but not drop
+func (c *Config) Keep(current *Config) {
+	c.Extra = merge(c.Extra, current.Extra)
+	c.Defaults.Extra = merge(c.Defaults.Extra, current.Defaults.Extra)
+}
+
+// UnmarshalJSON keeps the settings the manager does not know
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plain Config
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, configFields)
+	c.Extra = extra
+	return err
+}
+
+// MarshalJSON writes the configuration with the settings it was read with
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	return withFields(plain(c), c.Extra)
+}
+
+// UnmarshalJSON keeps the defaults the manager does not know
+func (d *Defaults) UnmarshalJSON(data []byte) error {
+	type plain Defaults
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, defaultsFields)
+	d.Extra = extra
+	return err
+}
+
+// MarshalJSON writes the defaults with the fields they were read with
+func (d Defaults) MarshalJSON() ([]byte, error) {
+	type plain Defaults
+	return withFields(plain(d), d.Extra)
+}
+
+// Launch is how the manager starts a server: its heap and game port
+type Launch struct {
+	JavaXms       string `json:"java_xms"`
+	JavaXmx       string `json:"java_xmx"`
+	JavaMetaspace string `json:"java_metaspace,omitempty"`
+	// Port is the game's UDP port; 0 means the default, 5520
+	Port int `json:"port,omitempty"`
+}
+
+// Validate checks the heap sizes and port. Empty sizes leave the JVM's
+// default; an initial heap larger than the maximum is refused.
+func (l *Launch) Validate() error {
+	l.JavaXms, l.JavaXmx, l.JavaMetaspace = strings.TrimSpace(l.JavaXms), strings.TrimSpace(l.JavaXmx), strings.TrimSpace(l.JavaMetaspace)
+	var xms, xmx int64
+	for _, size := range []struct {
+		name  string
+		value string
+		bytes *int64
+	}{{"java_xms", l.JavaXms, &xms}, {"java_xmx", l.JavaXmx, &xmx}} {
+		if size.value == "" {
+			continue
+		}
+		n, err := ParseMemory(size.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", size.name, err)
+		}
+		if n < MinHeap || n > MaxHeap {
+			return fmt.Errorf("%s must be between 512M and 1T", size.name)
+		}
+		*size.bytes = n
+	}
+	if xms > 0 && xmx > 0 && xms > xmx {
+		return fmt.Errorf("java_xms (%s) must not be larger than java_xmx (%s)", l.JavaXms, l.JavaXmx)
+	}
+	if l.JavaMetaspace != "" {
+		if _, err := ParseMemory(l.JavaMetaspace); err != nil {
+			return fmt.Errorf("java_metaspace: %w", err)
+		}
+	}
+	if l.Port < 0 || l.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// ParseMemory reads a JVM memory size, such as 512M or 10G, into bytes
+func ParseMemory(size string) (int64, error) {
+	match := heapSize.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0, fmt.Errorf("%q is not a memory size such as 512M or 4G", size)
+	}
+	n, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is too large", size)
+	}
+	shift := map[string]uint{"": 0, "k": 10, "m": 20, "g": 30, "t": 40}[strings.ToLower(match[2])]
+	if n > (1<<62)>>shift {
+		return 0, fmt.Errorf("%q is too large", size)
+	}
+	return n << shift, nil
+}
+
+func merge(edited, current map[string]json.RawMessage) map[string]json.RawMessage {
+	for key, raw := range current {
+		if _, ok := edited[key]; ok {
+			continue
+		}
+		if edited == nil {
+			edited = make(map[string]json.RawMessage, len(current))
+		}
+		edited[key] = raw
+	}
+	return edited
+}
+
+func unknownFields(data []byte, known []string) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	for _, field := range known {
+		delete(all, field)
+	}
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
+
+func withFields(value any, extra map[string]json.RawMessage) ([]byte, error) {
+	known, err := json.Marshal(value)
+	if err != nil || len(extra) == 0 {
+		return known, err
+	}
+	all := make(map[string]json.RawMessage, len(extra)+8)
+	for key, raw := range extra {
+		all[key] = raw
+	}
+	if err := json.Unmarshal(known, &all); err != nil {
+		return nil, err
+	}
+	return json.Marshal(all)
+}
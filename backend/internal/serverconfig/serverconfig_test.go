@@ -0,0 +1,90 @@
+package serverconfig
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConfigKeepsUnknownSettings(t *testing.T) {
+	current, err := Parse([]byte(`{"Version":3,"ServerName":"Orbis","MOTD":"hi","Password":"secret","MaxPlayers":20,"MaxViewRadius":16,
+		"Defaults":{"World":"default","GameMode":"Creative","Spawn":"hub"},"ConnectionTimeouts":{"Join":30}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if current.ServerName != "Orbis" || current.Defaults.GameMode != GameModeCreative {
+		t.Fatalf("unexpected config %+v", current)
+	}
+
+	// An edit sent without the unknown settings keeps them
+	var edited Config
+	if err := json.Unmarshal([]byte(`{"ServerName":" Orbis 2 ","MaxPlayers":50,"MaxViewRadius":24,"Defaults":{"World":"default","GameMode":"Adventure"}}`), &edited); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	edited.Keep(current)
+	if err := edited.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	data, err := Encode(&edited)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	for _, want := range []string{`"ServerName": "Orbis 2"`, `"MaxPlayers": 50`, `"Join": 30`, `"Spawn": "hub"`, `"GameMode": "Adventure"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in\n%s", want, data)
+		}
+	}
+
+	if config, err := Parse(nil); err != nil || config.MaxPlayers != 100 {
+		t.Fatalf("expected the defaults for a missing file, got %+v (%v)", config, err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for name, change := range map[string]func(*Config){
+		"empty name":     func(c *Config) { c.ServerName = " " },
+		"no players":     func(c *Config) { c.MaxPlayers = 0 },
+		"view radius":    func(c *Config) { c.MaxViewRadius = 500 },
+		"game mode":      func(c *Config) { c.Defaults.GameMode = "Survival" },
+		"world name":     func(c *Config) { c.Defaults.World = "../other" },
+		"multiline motd": func(c *Config) { c.MOTD = "a\nb" },
+	} {
+		config := Default()
+		change(config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestLaunchValidate(t *testing.T) {
+	valid := []Launch{
+		{},
+		{JavaXms: "4G", JavaXmx: "10G", JavaMetaspace: "2560M", Port: 5520},
+		{JavaXmx: "1024m"},
+	}
+	for _, launch := range valid {
+		if err := launch.Validate(); err != nil {
+			t.Errorf("expected %+v to be valid: %v", launch, err)
+		}
+	}
+	invalid := []Launch{
+		{JavaXmx: "10 GB"},
+		{JavaXmx: "128M"},
+		{JavaXmx: "2T"},
+		{JavaXms: "8G", JavaXmx: "4G"},
+		{Port: 70000},
+	}
+	for _, launch := range invalid {
+		if err := launch.Validate(); err == nil {
+			t.Errorf("expected %+v to be refused", launch)
+		}
+	}
+
+	if n, err := ParseMemory("10G"); err != nil || n != 10<<30 {
+		t.Fatalf("expected 10G in bytes, got %d (%v)", n, err)
+	}
+	if _, err := ParseMemory("99999999999T"); err == nil {
+		t.Fatal("expected an overflowing size to be refused")
+	}
+}
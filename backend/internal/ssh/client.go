@@ -2,21 +2,29 @@ package ssh
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
+	"sync/atomic"
 	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/tracing"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
 
+// keepaliveTimeout bounds the keepalive IsConnected sends
+const keepaliveTimeout = 10 * time.Second
+
 // Client wraps an SSH connection
 type Client struct {
 	config       *ClientConfig
 	client       *ssh.Client
 	connectedAt  time.Time
 	lastActivity time.Time
+	local        bool
+	inUse        atomic.Int32 // commands, streams and SFTP sessions running on the connection
 }
 
 // ClientConfig holds SSH connection configuration
@@ -28,8 +36,12 @@ type ClientConfig struct {
 	KeyPath         string
 	Password        string
 	Timeout         time.Duration
-	KnownHostsPath  string
-	TrustOnFirstUse bool
+	KnownHostsPath  string // set by the pool from its HostKeyPolicy
+	TrustOnFirstUse bool   // set by the pool from its HostKeyPolicy
+
+	// set by the pool to check the key pinned for the server
+	serverID string
+	hostKeys *HostKeyStore
 }
 
 // NewClient creates a new SSH client
@@ -38,6 +50,10 @@ func NewClient(config *ClientConfig) (*Client, error) {
 		config.Timeout = 30 * time.Second
 	}
 
+	if IsLocalHost(config.Host) {
+		return newLocalClient(config)
+	}
+
 	client := &Client{
 		config: config,
 	}
@@ -51,6 +67,10 @@ func NewClient(config *ClientConfig) (*Client, error) {
 
 // Connect establishes the SSH connection
 func (c *Client) Connect() error {
+	if c.local {
+		return nil
+	}
+
 	var authMethod ssh.AuthMethod
 
 	switch c.config.AuthMethod {
@@ -72,6 +92,9 @@ func (c *Client) Connect() error {
 	if err != nil {
 		return fmt.Errorf("failed to configure host key verification: %w", err)
 	}
+	if c.config.hostKeys != nil {
+		hostKeyCallback = c.config.hostKeys.Callback(c.config.serverID, hostKeyCallback)
+	}
 
 	sshConfig := &ssh.ClientConfig{
 		User:            c.config.Username,
@@ -103,13 +126,28 @@ func (c *Client) Close() error {
 
 // IsConnected checks if the connection is still active
 func (c *Client) IsConnected() bool {
+	if c.local {
+		return true
+	}
 	if c.client == nil {
 		return false
 	}
 
-	// Try to send a keepalive
-	_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
-	if err != nil {
+	// Try to send a keepalive. A peer that stopped answering would block the
+	// request for good, so give up after keepaliveTimeout and drop the connection.
+	client := c.client
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return false
+		}
+	case <-time.After(keepaliveTimeout):
+		client.Close()
 		return false
 	}
 
@@ -119,24 +157,54 @@ func (c *Client) IsConnected() bool {
 
 // RunCommand executes a command and returns the output
 func (c *Client) RunCommand(command string) (string, error) {
-	session, err := c.client.NewSession()
-	if err != nil {
-		return "", fmt.Errorf("failed to create session: %w", err)
-	}
-	defer session.Close()
+	return c.RunCommandContext(context.Background(), command)
+}
 
-	output, err := session.CombinedOutput(command)
-	c.lastActivity = time.Now()
+// RunCommandContext executes a command on behalf of the request or task in ctx,
+// tracing it and recording an audit line tagged with the request ID. The
+// command is interrupted if ctx is cancelled.
+func (c *Client) RunCommandContext(ctx context.Context, command string) (output string, err error) {
+	defer c.hold()()
+	defer c.traceCommand(ctx, command)(&err)
+
+	var combined []byte
+	if c.local {
+		var out string
+		out, err = c.runLocalCombined(ctx, command)
+		combined = []byte(out)
+	} else {
+		session, sessionErr := c.client.NewSession()
+		if sessionErr != nil {
+			return "", fmt.Errorf("failed to create session: %w", sessionErr)
+		}
+		defer session.Close()
+		defer interruptOnCancel(ctx, session)()
+
+		combined, err = session.CombinedOutput(command)
+		c.lastActivity = time.Now()
+	}
 
+	if ctx.Err() != nil {
+		return string(combined), fmt.Errorf("command cancelled: %w", ctx.Err())
+	}
 	if err != nil {
-		return string(output), fmt.Errorf("command failed: %w", err)
+		return string(combined), fmt.Errorf("command failed: %w", err)
 	}
 
-	return string(output), nil
+	return string(combined), nil
 }
 
 // RunCommandWithPTY executes a command with a PTY of the requested size.
 func (c *Client) RunCommandWithPTY(command string, cols, rows int) (string, error) {
+	defer c.hold()()
+	if c.local {
+		output, err := c.runLocalCombined(context.Background(), localPTYCommand(command, cols, rows))
+		if err != nil {
+			return output, fmt.Errorf("command failed: %w", err)
+		}
+		return output, nil
+	}
+
 	session, err := c.client.NewSession()
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
@@ -190,6 +258,10 @@ func (c *Client) RunCommandWithTimeout(command string, timeout time.Duration) (s
 
 // StartInteractiveSession creates a new session for interactive use (PTY)
 func (c *Client) StartInteractiveSession() (*ssh.Session, error) {
+	if c.local {
+		return nil, errLocalSession
+	}
+
 	session, err := c.client.NewSession()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
@@ -213,16 +285,39 @@ func (c *Client) StartInteractiveSession() (*ssh.Session, error) {
 
 // StreamCommand runs a command and streams output to the provided writer
 func (c *Client) StreamCommand(command string, stdout, stderr io.Writer) error {
+	return c.StreamCommandContext(context.Background(), command, stdout, stderr)
+}
+
+// StreamCommandContext is StreamCommand traced and audited against ctx
+func (c *Client) StreamCommandContext(ctx context.Context, command string, stdout, stderr io.Writer) (err error) {
+	defer c.hold()()
+	defer c.traceCommand(ctx, command)(&err)
+
+	if c.local {
+		if err := c.runLocal(ctx, command, stdout, stderr); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("command cancelled: %w", ctx.Err())
+			}
+			return fmt.Errorf("command failed: %w", err)
+		}
+		return nil
+	}
+
 	session, err := c.client.NewSession()
 	if err != nil {
 		return fmt.Errorf("failed to create session: %w", err)
 	}
 	defer session.Close()
 
+	defer interruptOnCancel(ctx, session)()
+
 	session.Stdout = stdout
 	session.Stderr = stderr
 
 	if err := session.Run(command); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("command cancelled: %w", ctx.Err())
+		}
 		return fmt.Errorf("command failed: %w", err)
 	}
 
@@ -230,6 +325,46 @@ func (c *Client) StreamCommand(command string, stdout, stderr io.Writer) error {
 	return nil
 }
 
+// interruptOnCancel sends SIGTERM to the remote command and closes its session
+// when ctx is cancelled, so shutdown does not wait on long-running scripts.
+// The returned function detaches the watcher once the command has finished.
+func interruptOnCancel(ctx context.Context, session *ssh.Session) func() {
+	stop := context.AfterFunc(ctx, func() {
+		_ = session.Signal(ssh.SIGTERM)
+		_ = session.Close()
+	})
+	return func() { stop() }
+}
+
+// traceCommand starts a client span for a command and returns the function
+// that ends it. Commands issued for an API request are also written to the
+// log with the request ID so they can be tied back to the caller.
+func (c *Client) traceCommand(ctx context.Context, command string) func(*error) {
+	start := time.Now()
+	_, span := tracing.Start(ctx, "ssh.command", tracing.KindClient)
+	span.SetAttribute("server.address", c.config.Host)
+	span.SetAttribute("server.port", c.config.Port)
+	span.SetAttribute("ssh.command", command)
+
+	return func(errp *error) {
+		span.RecordError(*errp)
+		span.End()
+
+		requestID := tracing.RequestID(ctx)
+		if requestID == "" {
+			return
+		}
+		logger.Info("ssh_command",
+			"request_id", requestID,
+			"host", c.config.Host,
+			"user", c.config.Username,
+			"command", command,
+			"duration", time.Since(start).String(),
+			"success", *errp == nil,
+		)
+	}
+}
+
 // GetUptime returns how long the connection has been active
 func (c *Client) GetUptime() time.Duration {
 	return time.Since(c.connectedAt)
@@ -240,8 +375,12 @@ func (c *Client) GetLastActivity() time.Time {
 	return c.lastActivity
 }
 
-// NewSession creates a new SSH session
+// NewSession creates a new SSH session. Unlike commands, it does not count
+// towards InUse; a caller keeping it open holds the client itself.
 func (c *Client) NewSession() (*ssh.Session, error) {
+	if c.local {
+		return nil, errLocalSession
+	}
 	if c.client == nil {
 		return nil, fmt.Errorf("not connected")
 	}
@@ -250,20 +389,62 @@ func (c *Client) NewSession() (*ssh.Session, error) {
 
 // NewSFTP creates a new SFTP client
 func (c *Client) NewSFTP() (*sftp.Client, error) {
+	if c.local {
+		return newLocalSFTP()
+	}
 	if c.client == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 	c.lastActivity = time.Now()
-	return sftp.NewClient(c.client)
+	client, err := sftp.NewClient(c.client)
+	if err != nil {
+		return nil, err
+	}
+	c.holdSFTP(client)
+	return client, nil
 }
 
 // NewSFTPWithOptions creates a new SFTP client with options
 func (c *Client) NewSFTPWithOptions(opts ...sftp.ClientOption) (*sftp.Client, error) {
+	if c.local {
+		return newLocalSFTP(opts...)
+	}
 	if c.client == nil {
 		return nil, fmt.Errorf("not connected")
 	}
 	c.lastActivity = time.Now()
-	return sftp.NewClient(c.client, opts...)
+	client, err := sftp.NewClient(c.client, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.holdSFTP(client)
+	return client, nil
+}
+
+// hold marks the connection in use until the returned function is called
+func (c *Client) hold() func() {
+	c.inUse.Add(1)
+	return func() { c.inUse.Add(-1) }
+}
+
+// holdSFTP keeps the connection in use until the SFTP client is closed
+func (c *Client) holdSFTP(client *sftp.Client) {
+	release := c.hold()
+	go func() {
+		_ = client.Wait()
+		release()
+	}()
+}
+
+// InUse reports whether commands, streams or SFTP sessions are running on
+// the connection
+func (c *Client) InUse() bool {
+	return c.inUse.Load() > 0
+}
+
+// IsLocal reports whether the client runs commands on this machine
+func (c *Client) IsLocal() bool {
+	return c.local
 }
 
 // GetConfig returns the client configuration
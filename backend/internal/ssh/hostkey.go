@@ -7,7 +7,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/TheGojiOG/HytaleSM/internal/logging"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
 )
@@ -47,14 +46,14 @@ func NewHostKeyCallback(knownHostsPath string, trustOnFirstUse bool) (ssh.HostKe
 				return err
 			}
 
-			logging.L().Info("ssh_host_key_accepted",
+			logger.Info("ssh_host_key_accepted",
 				"host", hostname,
 				"fingerprint", ssh.FingerprintSHA256(key),
 			)
 			return nil
 		}
 
-		logging.L().Warn("ssh_host_key_changed",
+		logger.Warn("ssh_host_key_changed",
 			"host", hostname,
 			"fingerprint", ssh.FingerprintSHA256(key),
 		)
@@ -0,0 +1,287 @@
+package ssh
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Host key states
+const (
+	HostKeyTrusted = "trusted"
+	HostKeyPending = "pending"
+)
+
+// ErrNoPendingHostKey is returned when approving a server that has no pending key
+var ErrNoPendingHostKey = errors.New("no pending SSH host key to approve")
+
+// ErrHostKeyFingerprint is returned when the approved fingerprint is not the pending key's
+var ErrHostKeyFingerprint = errors.New("fingerprint does not match the pending SSH host key")
+
+// HostKey is an SSH host key stored for a server
+type HostKey struct {
+	Type        string     `json:"type"`
+	Fingerprint string     `json:"fingerprint"`
+	PublicKey   string     `json:"public_key"` // authorized_keys format
+	SeenAt      time.Time  `json:"seen_at"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	ApprovedBy  string     `json:"approved_by,omitempty"`
+}
+
+// HostKeys are the trusted key of a server and the last key it offered that
+// was not trusted. Either may be nil.
+type HostKeys struct {
+	Trusted *HostKey `json:"trusted"`
+	Pending *HostKey `json:"pending"`
+}
+
+// HostKeyError is returned when a host offers a key that is not trusted. The
+// offered key is kept as the server's pending key until someone approves it.
+type HostKeyError struct {
+	ServerID string
+	Host     string
+	Expected string // fingerprint of the trusted key, empty when none is pinned
+	Offered  string
+	Err      error // why known_hosts rejected the key when none is pinned
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Expected != "" {
+		return fmt.Sprintf("SSH host key of %s changed: expected %s, got %s; approve the new key under SSH host key if the change is expected",
+			e.Host, e.Expected, e.Offered)
+	}
+	return fmt.Sprintf("SSH host key %s of %s is not trusted (%v); approve it under SSH host key", e.Offered, e.Host, e.Err)
+}
+
+func (e *HostKeyError) Unwrap() error {
+	return e.Err
+}
+
+// HostKeyStore pins the SSH host key of each server in the database. Times
+// are stored in UTC.
+type HostKeyStore struct {
+	db *sql.DB
+}
+
+// NewHostKeyStore creates a new host key store
+func NewHostKeyStore(db *sql.DB) *HostKeyStore {
+	return &HostKeyStore{db: db}
+}
+
+const hostKeyColumns = `key_type, fingerprint, public_key, seen_at, approved_at, approved_by`
+
+// Get returns the trusted and pending keys of a server
+func (s *HostKeyStore) Get(ctx context.Context, serverID string) (*HostKeys, error) {
+	trusted, err := s.get(ctx, serverID, HostKeyTrusted)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.get(ctx, serverID, HostKeyPending)
+	if err != nil {
+		return nil, err
+	}
+	return &HostKeys{Trusted: trusted, Pending: pending}, nil
+}
+
+// Approve trusts the pending key of a server. fingerprint must be the pending
+// key's, so a key offered after the user looked is not approved by accident.
+func (s *HostKeyStore) Approve(ctx context.Context, serverID, fingerprint, approvedBy string) (*HostKey, error) {
+	pending, err := s.get(ctx, serverID, HostKeyPending)
+	if err != nil {
+		return nil, err
+	}
+	if pending == nil {
+		return nil, ErrNoPendingHostKey
+	}
+	if pending.Fingerprint != strings.TrimSpace(fingerprint) {
+		return nil, ErrHostKeyFingerprint
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pending.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pending SSH host key: %w", err)
+	}
+	return s.Pin(ctx, serverID, key, approvedBy)
+}
+
+// Pin trusts key for a server, replacing the trusted key and dropping the
+// pending one
+func (s *HostKeyStore) Pin(ctx context.Context, serverID string, key ssh.PublicKey, approvedBy string) (*HostKey, error) {
+	now := time.Now().UTC()
+	pinned := newHostKey(key, now)
+	pinned.ApprovedAt = &now
+	pinned.ApprovedBy = approvedBy
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveHostKey(ctx, tx, serverID, HostKeyTrusted, pinned); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ssh_host_keys WHERE server_id = ? AND status = ?`, serverID, HostKeyPending); err != nil {
+		return nil, fmt.Errorf("failed to drop pending SSH host key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to pin SSH host key: %w", err)
+	}
+	return pinned, nil
+}
+
+// Offer records key as the pending key of a server, unless it is already trusted
+func (s *HostKeyStore) Offer(ctx context.Context, serverID string, key ssh.PublicKey) (*HostKey, error) {
+	trusted, err := s.get(ctx, serverID, HostKeyTrusted)
+	if err != nil {
+		return nil, err
+	}
+	offered := newHostKey(key, time.Now().UTC())
+	if trusted != nil && trusted.PublicKey == offered.PublicKey {
+		return trusted, nil
+	}
+	if err := saveHostKey(ctx, s.db, serverID, HostKeyPending, offered); err != nil {
+		return nil, err
+	}
+	return offered, nil
+}
+
+// Forget removes the keys of a server, so the next connection goes back to
+// known_hosts
+func (s *HostKeyStore) Forget(ctx context.Context, serverID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM ssh_host_keys WHERE server_id = ?`, serverID); err != nil {
+		return fmt.Errorf("failed to forget SSH host keys: %w", err)
+	}
+	return nil
+}
+
+// Callback verifies the key a server offers against its pinned key. Until a
+// key is pinned, fallback (known_hosts) decides and the key it accepts is
+// pinned. Keys that are rejected are kept as pending for approval.
+func (s *HostKeyStore) Callback(serverID string, fallback ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		ctx := context.Background()
+		trusted, err := s.get(ctx, serverID, HostKeyTrusted)
+		if err != nil {
+			return err
+		}
+		offered := newHostKey(key, time.Now().UTC())
+
+		if trusted != nil {
+			if trusted.PublicKey == offered.PublicKey {
+				if _, err := s.db.ExecContext(ctx, `UPDATE ssh_host_keys SET seen_at = ? WHERE server_id = ? AND status = ?`,
+					offered.SeenAt, serverID, HostKeyTrusted); err != nil {
+					logger.Warn("Failed to update SSH host key", "server_id", serverID, "error", err)
+				}
+				return nil
+			}
+			s.keepPending(ctx, serverID, offered)
+			logger.Warn("ssh_host_key_mismatch",
+				"server_id", serverID,
+				"host", hostname,
+				"expected", trusted.Fingerprint,
+				"fingerprint", offered.Fingerprint,
+			)
+			return &HostKeyError{ServerID: serverID, Host: hostname, Expected: trusted.Fingerprint, Offered: offered.Fingerprint}
+		}
+
+		if err := fallback(hostname, remote, key); err != nil {
+			s.keepPending(ctx, serverID, offered)
+			return &HostKeyError{ServerID: serverID, Host: hostname, Offered: offered.Fingerprint, Err: err}
+		}
+		if _, err := s.Pin(ctx, serverID, key, "known_hosts"); err != nil {
+			logger.Warn("Failed to pin SSH host key", "server_id", serverID, "error", err)
+		}
+		return nil
+	}
+}
+
+func (s *HostKeyStore) keepPending(ctx context.Context, serverID string, key *HostKey) {
+	if err := saveHostKey(ctx, s.db, serverID, HostKeyPending, key); err != nil {
+		logger.Warn("Failed to keep pending SSH host key", "server_id", serverID, "error", err)
+	}
+}
+
+func (s *HostKeyStore) get(ctx context.Context, serverID, status string) (*HostKey, error) {
+	var (
+		key        HostKey
+		approvedAt sql.NullTime
+	)
+	err := s.db.QueryRowContext(ctx, `SELECT `+hostKeyColumns+` FROM ssh_host_keys WHERE server_id = ? AND status = ?`, serverID, status).
+		Scan(&key.Type, &key.Fingerprint, &key.PublicKey, &key.SeenAt, &approvedAt, &key.ApprovedBy)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SSH host key: %w", err)
+	}
+	if approvedAt.Valid {
+		key.ApprovedAt = &approvedAt.Time
+	}
+	return &key, nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func saveHostKey(ctx context.Context, db execer, serverID, status string, key *HostKey) error {
+	var approvedAt interface{}
+	if key.ApprovedAt != nil {
+		approvedAt = *key.ApprovedAt
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ssh_host_keys (server_id, status, `+hostKeyColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(server_id, status) DO UPDATE SET
+			key_type = excluded.key_type,
+			fingerprint = excluded.fingerprint,
+			public_key = excluded.public_key,
+			seen_at = excluded.seen_at,
+			approved_at = excluded.approved_at,
+			approved_by = excluded.approved_by
+	`, serverID, status, key.Type, key.Fingerprint, key.PublicKey, key.SeenAt, approvedAt, key.ApprovedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save SSH host key: %w", err)
+	}
+	return nil
+}
+
+func newHostKey(key ssh.PublicKey, seenAt time.Time) *HostKey {
+	return &HostKey{
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+		SeenAt:      seenAt,
+	}
+}
+
+// errHostKeyScanned ends the handshake once ScanHostKey has the key
+var errHostKeyScanned = errors.New("host key scanned")
+
+// ScanHostKey returns the key a host offers, without authenticating
+func ScanHostKey(host string, port int, timeout time.Duration) (ssh.PublicKey, error) {
+	var offered ssh.PublicKey
+	config := &ssh.ClientConfig{
+		User: "hytale-server-manager",
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			offered = key
+			return errHostKeyScanned
+		},
+		Timeout: timeout,
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)), config)
+	if client != nil {
+		client.Close()
+	}
+	if offered != nil {
+		return offered, nil
+	}
+	return nil, fmt.Errorf("failed to read SSH host key: %w", err)
+}
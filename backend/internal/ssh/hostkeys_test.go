@@ -0,0 +1,116 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKeyStore(t *testing.T) *HostKeyStore {
+	t.Helper()
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "hostkeys.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewHostKeyStore(db.DB)
+}
+
+func TestHostKeyStorePinsAcceptedKeyAndRejectsChange(t *testing.T) {
+	store := newTestHostKeyStore(t)
+	ctx := context.Background()
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+	callback := store.Callback("srv", ssh.InsecureIgnoreHostKey())
+
+	key1 := generateTestPublicKey(t)
+	if err := callback("example.com:22", addr, key1); err != nil {
+		t.Fatalf("expected first key to be accepted, got %v", err)
+	}
+	keys, err := store.Get(ctx, "srv")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if keys.Trusted == nil || keys.Trusted.Fingerprint != ssh.FingerprintSHA256(key1) || keys.Pending != nil {
+		t.Fatalf("expected first key to be pinned, got %+v", keys)
+	}
+	if err := callback("example.com:22", addr, key1); err != nil {
+		t.Fatalf("expected pinned key to be accepted, got %v", err)
+	}
+
+	key2 := generateTestPublicKey(t)
+	err = callback("example.com:22", addr, key2)
+	var keyErr *HostKeyError
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("expected host key error, got %v", err)
+	}
+	if keyErr.Expected != ssh.FingerprintSHA256(key1) || keyErr.Offered != ssh.FingerprintSHA256(key2) {
+		t.Fatalf("unexpected fingerprints in %v", keyErr)
+	}
+
+	keys, _ = store.Get(ctx, "srv")
+	if keys.Pending == nil || keys.Pending.Fingerprint != ssh.FingerprintSHA256(key2) {
+		t.Fatalf("expected changed key to be pending, got %+v", keys)
+	}
+	if _, err := store.Approve(ctx, "srv", "SHA256:wrong", "admin"); !errors.Is(err, ErrHostKeyFingerprint) {
+		t.Fatalf("expected fingerprint error, got %v", err)
+	}
+	approved, err := store.Approve(ctx, "srv", keys.Pending.Fingerprint, "admin")
+	if err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if approved.ApprovedBy != "admin" || approved.ApprovedAt == nil {
+		t.Fatalf("expected approval to be recorded, got %+v", approved)
+	}
+	if err := callback("example.com:22", addr, key2); err != nil {
+		t.Fatalf("expected approved key to be accepted, got %v", err)
+	}
+	if err := callback("example.com:22", addr, key1); err == nil {
+		t.Fatalf("expected old key to be rejected after rotation")
+	}
+	if _, err := store.Approve(ctx, "other", "SHA256:any", "admin"); !errors.Is(err, ErrNoPendingHostKey) {
+		t.Fatalf("expected no pending key error, got %v", err)
+	}
+}
+
+func TestHostKeyStoreKeepsKeyRejectedByKnownHosts(t *testing.T) {
+	store := newTestHostKeyStore(t)
+	ctx := context.Background()
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 22}
+	callback := store.Callback("srv", func(hostname string, _ net.Addr, _ ssh.PublicKey) error {
+		return fmt.Errorf("unknown SSH host key for %s", hostname)
+	})
+
+	key := generateTestPublicKey(t)
+	err := callback("example.com:22", addr, key)
+	var keyErr *HostKeyError
+	if !errors.As(err, &keyErr) || keyErr.Expected != "" {
+		t.Fatalf("expected untrusted host key error, got %v", err)
+	}
+
+	keys, _ := store.Get(ctx, "srv")
+	if keys.Trusted != nil || keys.Pending == nil {
+		t.Fatalf("expected rejected key to be pending, got %+v", keys)
+	}
+	if _, err := store.Approve(ctx, "srv", keys.Pending.Fingerprint, "admin"); err != nil {
+		t.Fatalf("approve: %v", err)
+	}
+	if err := callback("example.com:22", addr, key); err != nil {
+		t.Fatalf("expected approved key to be accepted, got %v", err)
+	}
+
+	if err := store.Forget(ctx, "srv"); err != nil {
+		t.Fatalf("forget: %v", err)
+	}
+	if err := callback("example.com:22", addr, key); err == nil {
+		t.Fatalf("expected known_hosts to decide after forgetting")
+	}
+}
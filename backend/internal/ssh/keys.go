@@ -41,3 +41,23 @@ func ReadPrivateKeyBytes(path string) ([]byte, error) {
 
 	return []byte(plaintext), nil
 }
+
+// WritePrivateKeyFile encrypts a private key and writes it with ENC1 encoding,
+// readable only by the manager's user
+func WritePrivateKeyFile(path string, key []byte) error {
+	manager, err := crypto.NewEncryptionManager()
+	if err != nil {
+		return fmt.Errorf("failed to initialize encryption manager: %w", err)
+	}
+
+	encrypted, err := manager.EncryptSSHKey(string(key))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	payload := encryptedKeyHeader + base64.StdEncoding.EncodeToString(encrypted)
+	if err := os.WriteFile(path, []byte(payload), 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+	return nil
+}
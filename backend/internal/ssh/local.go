@@ -0,0 +1,102 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+var errLocalSession = errors.New("interactive SSH sessions are not available for local servers")
+
+// IsLocalHost reports whether a server on host runs on the manager's own
+// machine. Such servers are managed with local processes instead of SSH.
+func IsLocalHost(host string) bool {
+	switch strings.ToLower(strings.TrimSpace(host)) {
+	case "", "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// newLocalClient returns a client that runs commands on this machine
+func newLocalClient(config *ClientConfig) (*Client, error) {
+	// Game servers are Linux hosts and the commands assume bash, ps and screen
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("local servers are not supported on Windows; connect over SSH instead")
+	}
+	return &Client{
+		config:       config,
+		local:        true,
+		connectedAt:  time.Now(),
+		lastActivity: time.Now(),
+	}, nil
+}
+
+// runLocal runs command with bash and sends SIGTERM when ctx is cancelled,
+// like interruptOnCancel does for remote commands
+func (c *Client) runLocal(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, "bash", "-c", command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 5 * time.Second
+	err := cmd.Run()
+	c.lastActivity = time.Now()
+	return err
+}
+
+// runLocalCombined is runLocal returning stdout and stderr interleaved, like
+// an SSH session's CombinedOutput
+func (c *Client) runLocalCombined(ctx context.Context, command string) (string, error) {
+	var combined bytes.Buffer
+	err := c.runLocal(ctx, command, &combined, &combined)
+	return combined.String(), err
+}
+
+// localPTYCommand runs command under script(1) so tools that need a terminal,
+// such as screen -r, work without an SSH PTY
+func localPTYCommand(command string, cols, rows int) string {
+	inner := fmt.Sprintf("stty cols %d rows %d 2>/dev/null; %s", cols, rows, command)
+	return fmt.Sprintf("script -qfec %s /dev/null", shellQuote(inner))
+}
+
+// newLocalSFTP serves SFTP for the local filesystem in-process, so file
+// transfers work the same for local servers
+func newLocalSFTP(opts ...sftp.ClientOption) (*sftp.Client, error) {
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start local SFTP server: %w", err)
+	}
+	go func() {
+		_ = server.Serve()
+		server.Close()
+	}()
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter, opts...)
+	if err != nil {
+		clientWriter.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,56 @@
+package ssh
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLocalClient(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("local servers are not supported on Windows")
+	}
+
+	client, err := NewClient(&ClientConfig{Host: "localhost"})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if !client.IsLocal() || !client.IsConnected() {
+		t.Fatalf("expected a connected local client")
+	}
+
+	output, err := client.RunCommand("echo out; echo err >&2")
+	if err != nil {
+		t.Fatalf("RunCommand: %v", err)
+	}
+	if !strings.Contains(output, "out") || !strings.Contains(output, "err") {
+		t.Fatalf("expected combined output, got %q", output)
+	}
+	if _, err := client.RunCommand("exit 3"); err == nil {
+		t.Fatalf("expected an error for a failing command")
+	}
+
+	sftpClient, err := client.NewSFTP()
+	if err != nil {
+		t.Fatalf("NewSFTP: %v", err)
+	}
+	defer sftpClient.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	file, err := sftpClient.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := io.WriteString(file, "hello"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	file.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected uploaded file, got %q (%v)", data, err)
+	}
+}
@@ -3,20 +3,35 @@ package ssh
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
 )
 
+// logger is shared by the ssh package
+var logger = logging.For("ssh")
+
 // ConnectionPool manages SSH connections to multiple servers
 type ConnectionPool struct {
 	connections map[string]*PooledConnection
+	retired     []*PooledConnection // replaced while still in use, closed once idle
+	policy      HostKeyPolicy
 	mu          sync.RWMutex
 	db          *sql.DB
+	hostKeys    *HostKeyStore
 	stopChan    chan struct{}
 	wg          sync.WaitGroup
 }
 
+// HostKeyPolicy is how connections check host keys that are not pinned for
+// their server yet
+type HostKeyPolicy struct {
+	KnownHostsPath  string
+	TrustOnFirstUse bool
+}
+
 // PooledConnection wraps an SSH client with pool metadata
 type PooledConnection struct {
 	Client            *Client
@@ -25,6 +40,7 @@ type PooledConnection struct {
 	ReconnectAttempts int
 	LastHealthCheck   time.Time
 	mu                sync.Mutex
+	stale             bool // opened under an older host key policy; guarded by the pool
 }
 
 // NewConnectionPool creates a new connection pool
@@ -34,6 +50,9 @@ func NewConnectionPool(db *sql.DB) *ConnectionPool {
 		db:          db,
 		stopChan:    make(chan struct{}),
 	}
+	if db != nil {
+		pool.hostKeys = NewHostKeyStore(db)
+	}
 
 	// Start health check routine
 	pool.wg.Add(1)
@@ -49,15 +68,20 @@ func (p *ConnectionPool) GetConnection(serverID string, config *ClientConfig) (*
 
 	// Check if connection exists
 	if conn, exists := p.connections[serverID]; exists {
-		// Check if connection is still alive
-		if conn.Client.IsConnected() {
+		if conn.stale {
+			// Work still running on it keeps it open; new work gets a new connection
+			logger.Info("Host key policy changed, reconnecting", "server_id", serverID)
+			p.retired = append(p.retired, conn)
+			delete(p.connections, serverID)
+			p.recordConnection(serverID, false)
+		} else if conn.Client.IsConnected() {
 			conn.updateActivity()
 			return conn, nil
+		} else {
+			// Connection is dead, remove it
+			logger.Warn("Connection is dead, removing", "server_id", serverID)
+			delete(p.connections, serverID)
 		}
-
-		// Connection is dead, remove it
-		log.Printf("[Pool] Connection to %s is dead, removing", serverID)
-		delete(p.connections, serverID)
 	}
 
 	// Create new connection
@@ -72,9 +96,10 @@ func (p *ConnectionPool) GetConnection(serverID string, config *ClientConfig) (*
 	return conn, nil
 }
 
-// createConnection creates a new pooled connection
+// createConnection creates a new pooled connection; the caller holds p.mu
 func (p *ConnectionPool) createConnection(serverID string, config *ClientConfig) (*PooledConnection, error) {
-	client, err := NewClient(config)
+	pinned := p.pin(serverID, config, p.policy)
+	client, err := NewClient(pinned)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create SSH client: %w", err)
 	}
@@ -86,10 +111,51 @@ func (p *ConnectionPool) createConnection(serverID string, config *ClientConfig)
 		LastHealthCheck: time.Now(),
 	}
 
-	log.Printf("[Pool] Created new connection to %s", serverID)
+	logger.Info("Created new connection", "server_id", serverID)
 	return conn, nil
 }
 
+// Dial opens a connection to a server outside the pool, checked against the
+// server's pinned host key. The caller closes it.
+func (p *ConnectionPool) Dial(serverID string, config *ClientConfig) (*Client, error) {
+	return NewClient(p.pin(serverID, config, p.HostKeyPolicy()))
+}
+
+// pin copies config for serverID, checking host keys against the key pinned
+// for the server and then policy
+func (p *ConnectionPool) pin(serverID string, config *ClientConfig, policy HostKeyPolicy) *ClientConfig {
+	pinned := *config
+	pinned.serverID = serverID
+	pinned.hostKeys = p.hostKeys
+	pinned.KnownHostsPath = policy.KnownHostsPath
+	pinned.TrustOnFirstUse = policy.TrustOnFirstUse
+	return &pinned
+}
+
+// HostKeyPolicy returns the policy new connections are checked against
+func (p *ConnectionPool) HostKeyPolicy() HostKeyPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.policy
+}
+
+// SetHostKeyPolicy changes how new connections check host keys. Pooled
+// connections opened under the old policy are marked stale: the next
+// GetConnection for their server dials again, and the old connection is
+// closed once nothing runs on it any more.
+func (p *ConnectionPool) SetHostKeyPolicy(policy HostKeyPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if policy == p.policy {
+		return
+	}
+	p.policy = policy
+	for _, conn := range p.connections {
+		conn.stale = true
+	}
+}
+
 // RemoveConnection removes a connection from the pool
 func (p *ConnectionPool) RemoveConnection(serverID string) error {
 	p.mu.Lock()
@@ -99,12 +165,17 @@ func (p *ConnectionPool) RemoveConnection(serverID string) error {
 		conn.Client.Close()
 		delete(p.connections, serverID)
 		p.recordConnection(serverID, false)
-		log.Printf("[Pool] Removed connection to %s", serverID)
+		logger.Info("Removed connection", "server_id", serverID)
 	}
 
 	return nil
 }
 
+// HostKeys returns the store of pinned SSH host keys, nil without a database
+func (p *ConnectionPool) HostKeys() *HostKeyStore {
+	return p.hostKeys
+}
+
 // GetExistingConnection retrieves an existing connection without creating a new one
 func (p *ConnectionPool) GetExistingConnection(serverID string) *PooledConnection {
 	p.mu.RLock()
@@ -121,10 +192,14 @@ func (p *ConnectionPool) CloseAll() {
 	for serverID, conn := range p.connections {
 		conn.Client.Close()
 		p.recordConnection(serverID, false)
-		log.Printf("[Pool] Closed connection to %s", serverID)
+		logger.Info("Closed connection", "server_id", serverID)
+	}
+	for _, conn := range p.retired {
+		conn.Client.Close()
 	}
 
 	p.connections = make(map[string]*PooledConnection)
+	p.retired = nil
 }
 
 // GetConnectionCount returns the number of active connections
@@ -151,8 +226,11 @@ func (p *ConnectionPool) healthCheckLoop() {
 	}
 }
 
-// performHealthChecks checks all connections
+// performHealthChecks checks all connections and closes retired ones that
+// are no longer in use
 func (p *ConnectionPool) performHealthChecks() {
+	p.closeIdleRetired()
+
 	p.mu.RLock()
 	serverIDs := make([]string, 0, len(p.connections))
 	for serverID := range p.connections {
@@ -163,9 +241,11 @@ func (p *ConnectionPool) performHealthChecks() {
 	for _, serverID := range serverIDs {
 		p.mu.RLock()
 		conn, exists := p.connections[serverID]
+		stale := exists && conn.stale
 		p.mu.RUnlock()
 
-		if !exists {
+		// A stale connection would reconnect under the old policy
+		if !exists || stale {
 			continue
 		}
 
@@ -173,13 +253,30 @@ func (p *ConnectionPool) performHealthChecks() {
 	}
 }
 
+// closeIdleRetired closes the retired connections nothing runs on any more
+func (p *ConnectionPool) closeIdleRetired() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kept := p.retired[:0]
+	for _, conn := range p.retired {
+		if conn.Client.InUse() {
+			kept = append(kept, conn)
+			continue
+		}
+		conn.Client.Close()
+		logger.Info("Closed replaced connection", "server_id", conn.ServerID)
+	}
+	p.retired = kept
+}
+
 // performHealthCheck checks the health of a single connection
 func (pc *PooledConnection) performHealthCheck(pool *ConnectionPool) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
 	if !pc.Client.IsConnected() {
-		log.Printf("[Pool] Health check failed for %s, attempting reconnect", pc.ServerID)
+		logger.Warn("Health check failed, attempting reconnect", "server_id", pc.ServerID)
 		
 		pc.HealthStatus = "failed"
 		pc.ReconnectAttempts++
@@ -187,16 +284,15 @@ func (pc *PooledConnection) performHealthCheck(pool *ConnectionPool) {
 		// Try to reconnect if not exceeded max attempts
 		if pc.ReconnectAttempts <= 3 {
 			if err := pc.Client.Connect(); err != nil {
-				log.Printf("[Pool] Reconnect attempt %d failed for %s: %v", 
-					pc.ReconnectAttempts, pc.ServerID, err)
+				logger.Warn("Reconnect attempt failed", "server_id", pc.ServerID, "attempt", pc.ReconnectAttempts, "error", err)
 				
 				// If max attempts reached, remove from pool
 				if pc.ReconnectAttempts >= 3 {
-					log.Printf("[Pool] Max reconnect attempts reached for %s, removing from pool", pc.ServerID)
+					logger.Error("Max reconnect attempts reached, removing from pool", "server_id", pc.ServerID)
 					pool.RemoveConnection(pc.ServerID)
 				}
 			} else {
-				log.Printf("[Pool] Reconnected to %s successfully", pc.ServerID)
+				logger.Info("Reconnected", "server_id", pc.ServerID)
 				pc.HealthStatus = "healthy"
 				pc.ReconnectAttempts = 0
 			}
@@ -204,7 +300,7 @@ func (pc *PooledConnection) performHealthCheck(pool *ConnectionPool) {
 	} else {
 		// Connection is healthy
 		if pc.HealthStatus != "healthy" {
-			log.Printf("[Pool] Connection to %s recovered", pc.ServerID)
+			logger.Info("Connection recovered", "server_id", pc.ServerID)
 			pc.HealthStatus = "healthy"
 			pc.ReconnectAttempts = 0
 		}
@@ -236,7 +332,7 @@ func (p *ConnectionPool) recordConnection(serverID string, connected bool) {
 			VALUES (?, datetime('now'), datetime('now'), 'healthy', 1)
 		`, serverID)
 		if err != nil {
-			log.Printf("[Pool] Failed to record connection for %s: %v", serverID, err)
+			logger.Error("Failed to record connection", "server_id", serverID, "error", err)
 		}
 	} else {
 		_, err := p.db.Exec(`
@@ -245,7 +341,7 @@ func (p *ConnectionPool) recordConnection(serverID string, connected bool) {
 			WHERE server_id = ? AND is_active = 1
 		`, serverID)
 		if err != nil {
-			log.Printf("[Pool] Failed to update connection status for %s: %v", serverID, err)
+			logger.Error("Failed to update connection status", "server_id", serverID, "error", err)
 		}
 	}
 }
@@ -261,7 +357,7 @@ func (p *ConnectionPool) updateConnectionHealth(serverID, healthStatus string, r
 	`, healthStatus, reconnectAttempts, serverID)
 	
 	if err != nil {
-		log.Printf("[Pool] Failed to update health for %s: %v", serverID, err)
+		logger.Error("Failed to update health", "server_id", serverID, "error", err)
 	}
 }
 
@@ -306,3 +402,128 @@ func (p *ConnectionPool) GetStats() map[string]interface{} {
 		"failed":            failed,
 	}
 }
+
+// ConnectionInfo is the state of a server's SSH connection: the pooled one
+// when it is open, otherwise the last one recorded in ssh_connections, which
+// may belong to another instance
+type ConnectionInfo struct {
+	ServerID          string     `json:"server_id"`
+	Pooled            bool       `json:"pooled"`        // an open connection is in this instance's pool
+	HealthStatus      string     `json:"health_status"` // healthy, degraded, failed or closed
+	ReconnectAttempts int        `json:"reconnect_attempts"`
+	ConnectedAt       *time.Time `json:"connected_at,omitempty"`
+	LastActivity      *time.Time `json:"last_activity,omitempty"`
+	LastHealthCheck   *time.Time `json:"last_health_check,omitempty"`
+}
+
+// Connections returns the connection state of every server that has
+// connected, by server ID
+func (p *ConnectionPool) Connections() ([]ConnectionInfo, error) {
+	infos, err := p.recordedConnections("")
+	if err != nil {
+		return nil, err
+	}
+	byServer := make(map[string]int, len(infos))
+	for i, info := range infos {
+		byServer[info.ServerID] = i
+	}
+
+	p.mu.RLock()
+	pooled := make([]*PooledConnection, 0, len(p.connections))
+	for _, conn := range p.connections {
+		pooled = append(pooled, conn)
+	}
+	p.mu.RUnlock()
+
+	for _, conn := range pooled {
+		info := conn.info()
+		if i, ok := byServer[info.ServerID]; ok {
+			infos[i] = info
+		} else {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ServerID < infos[j].ServerID })
+	return infos, nil
+}
+
+// Connection returns the connection state of a server; ok is false when it
+// never connected
+func (p *ConnectionPool) Connection(serverID string) (info ConnectionInfo, ok bool, err error) {
+	if conn := p.GetExistingConnection(serverID); conn != nil {
+		return conn.info(), true, nil
+	}
+	infos, err := p.recordedConnections(serverID)
+	if err != nil || len(infos) == 0 {
+		return ConnectionInfo{ServerID: serverID}, false, err
+	}
+	return infos[0], true, nil
+}
+
+// recordedConnections reads the last ssh_connections row of each server, or
+// of one server when serverID is set
+func (p *ConnectionPool) recordedConnections(serverID string) ([]ConnectionInfo, error) {
+	infos := make([]ConnectionInfo, 0)
+	if p.db == nil {
+		return infos, nil
+	}
+	query := `
+		SELECT server_id, connected_at, last_activity, health_status, reconnect_attempts, is_active
+		FROM ssh_connections
+		WHERE id IN (SELECT MAX(id) FROM ssh_connections GROUP BY server_id)`
+	args := []interface{}{}
+	if serverID != "" {
+		query += ` AND server_id = ?`
+		args = append(args, serverID)
+	}
+	rows, err := p.db.Query(query+` ORDER BY server_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SSH connections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			info              ConnectionInfo
+			connectedAt       sql.NullTime
+			lastActivity      sql.NullTime
+			healthStatus      sql.NullString
+			reconnectAttempts sql.NullInt64
+			active            bool
+		)
+		if err := rows.Scan(&info.ServerID, &connectedAt, &lastActivity, &healthStatus, &reconnectAttempts, &active); err != nil {
+			return nil, fmt.Errorf("failed to scan SSH connection: %w", err)
+		}
+		if connectedAt.Valid {
+			info.ConnectedAt = &connectedAt.Time
+		}
+		if lastActivity.Valid {
+			info.LastActivity = &lastActivity.Time
+		}
+		info.HealthStatus = "closed"
+		if active && healthStatus.Valid {
+			info.HealthStatus = healthStatus.String
+		}
+		info.ReconnectAttempts = int(reconnectAttempts.Int64)
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+func (pc *PooledConnection) info() ConnectionInfo {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	connectedAt := pc.Client.connectedAt
+	lastActivity := pc.Client.GetLastActivity()
+	lastHealthCheck := pc.LastHealthCheck
+	return ConnectionInfo{
+		ServerID:          pc.ServerID,
+		Pooled:            true,
+		HealthStatus:      pc.HealthStatus,
+		ReconnectAttempts: pc.ReconnectAttempts,
+		ConnectedAt:       &connectedAt,
+		LastActivity:      &lastActivity,
+		LastHealthCheck:   &lastHealthCheck,
+	}
+}
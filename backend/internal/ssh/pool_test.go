@@ -0,0 +1,116 @@
+package ssh
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/TheGojiOG/HytaleSM/internal/database"
+)
+
+func TestConnectionPoolReportsLastRecordedConnection(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "pool.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	pool := NewConnectionPool(db.DB)
+	t.Cleanup(pool.Stop)
+
+	rows := []struct {
+		serverID string
+		health   string
+		attempts int
+		active   bool
+	}{
+		{"beta", "healthy", 0, false},
+		{"beta", "degraded", 2, true},
+		{"alpha", "failed", 3, false},
+	}
+	for _, row := range rows {
+		if _, err := db.Exec(`INSERT INTO ssh_connections (server_id, health_status, reconnect_attempts, is_active) VALUES (?, ?, ?, ?)`,
+			row.serverID, row.health, row.attempts, row.active); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	infos, err := pool.Connections()
+	if err != nil {
+		t.Fatalf("connections: %v", err)
+	}
+	if len(infos) != 2 || infos[0].ServerID != "alpha" || infos[1].ServerID != "beta" {
+		t.Fatalf("expected alpha and beta, got %+v", infos)
+	}
+	if infos[0].HealthStatus != "closed" || infos[0].ReconnectAttempts != 3 || infos[0].Pooled {
+		t.Fatalf("expected alpha to be closed after 3 attempts, got %+v", infos[0])
+	}
+	if infos[1].HealthStatus != "degraded" || infos[1].ReconnectAttempts != 2 || infos[1].LastActivity == nil {
+		t.Fatalf("expected the last beta row, got %+v", infos[1])
+	}
+
+	info, ok, err := pool.Connection("beta")
+	if err != nil || !ok || info.HealthStatus != "degraded" {
+		t.Fatalf("expected beta to be degraded, got %+v %v %v", info, ok, err)
+	}
+	if _, ok, err := pool.Connection("gamma"); err != nil || ok {
+		t.Fatalf("expected gamma to be unknown, got %v %v", ok, err)
+	}
+}
+
+func TestHostKeyPolicyChangeReplacesPooledConnections(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("local servers are not supported on Windows")
+	}
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "pool.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	pool := NewConnectionPool(db.DB)
+	t.Cleanup(pool.Stop)
+
+	old, err := pool.GetConnection("alpha", &ClientConfig{Host: "localhost"})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	release := old.Client.hold() // a console stream still running
+
+	policy := HostKeyPolicy{KnownHostsPath: "/tmp/known_hosts", TrustOnFirstUse: true}
+	pool.SetHostKeyPolicy(policy)
+	if pool.GetExistingConnection("alpha") != old {
+		t.Fatal("expected the connection kept until it is next used")
+	}
+
+	current, err := pool.GetConnection("alpha", &ClientConfig{Host: "localhost"})
+	if err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	if current == old {
+		t.Fatal("expected a new connection under the new policy")
+	}
+	if cfg := current.Client.GetConfig(); cfg.KnownHostsPath != policy.KnownHostsPath || !cfg.TrustOnFirstUse {
+		t.Fatalf("expected the new policy applied, got %+v", cfg)
+	}
+
+	pool.performHealthChecks()
+	if len(pool.retired) != 1 {
+		t.Fatalf("expected the busy connection left open, got %d retired", len(pool.retired))
+	}
+	release()
+	pool.performHealthChecks()
+	if len(pool.retired) != 0 {
+		t.Fatalf("expected the idle connection closed, got %d retired", len(pool.retired))
+	}
+
+	// Setting the same policy again keeps the pooled connection
+	pool.SetHostKeyPolicy(policy)
+	if again, err := pool.GetConnection("alpha", &ClientConfig{Host: "localhost"}); err != nil || again != current {
+		t.Fatalf("expected the pooled connection reused, got %v", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"log"
 	"sync"
 	"time"
 
@@ -77,7 +76,7 @@ func (pm *PTYManager) AttachToScreen(serverID, sessionName string) (*PTYSession,
 
 	// Check if already attached
 	if session, exists := pm.sessions[serverID]; exists && session.isAttached {
-		log.Printf("[PTY] Already attached to server %s, returning existing session", serverID)
+		logger.Debug("Already attached, returning existing session", "server_id", serverID)
 		return session, nil
 	}
 
@@ -134,11 +133,16 @@ func (pm *PTYManager) AttachToScreen(serverID, sessionName string) (*PTYSession,
 		sshSession.Close()
 		return nil, fmt.Errorf("failed to attach to screen session: %w", err)
 	}
+	release := pooledConn.Client.hold()
+	go func() {
+		_ = sshSession.Wait()
+		release()
+	}()
 
 	// Send window size change signal to ensure screen recognizes the terminal dimensions
 	time.Sleep(100 * time.Millisecond)
 	if err := sshSession.WindowChange(100, 500); err != nil {
-		log.Printf("[PTY] Warning: Failed to send window change signal: %v", err)
+		logger.Warn("Failed to send window change signal", "server_id", serverID, "error", err)
 	}
 
 	// Create PTY session
@@ -163,7 +167,7 @@ func (pm *PTYManager) AttachToScreen(serverID, sessionName string) (*PTYSession,
 	go ptySession.readOutput(ConsoleLineTypeStdout, stdout)
 	go ptySession.readOutput(ConsoleLineTypeStderr, stderr)
 
-	log.Printf("[PTY] Successfully attached to screen session %s for server %s", sessionName, serverID)
+	logger.Info("Attached to screen session", "session", sessionName, "server_id", serverID)
 
 	return ptySession, nil
 }
@@ -209,7 +213,7 @@ func (ps *PTYSession) SendCommand(command string) error {
 
 	ps.lastActivity = time.Now()
 
-	log.Printf("[PTY] Sent command to server %s: %s", ps.ServerID, command)
+	logger.Debug("Sent command", "server_id", ps.ServerID, "command", command)
 
 	return nil
 }
@@ -243,7 +247,7 @@ func (pm *PTYManager) SubscribeToOutput(serverID string) (chan string, error) {
 	session.Subscribers[subscriber] = true
 	session.mu.Unlock()
 
-	log.Printf("[PTY] New subscriber for server %s (total: %d)", serverID, len(session.Subscribers))
+	logger.Debug("Subscriber added", "server_id", serverID, "subscribers", len(session.Subscribers))
 
 	return subscriber, nil
 }
@@ -262,7 +266,7 @@ func (pm *PTYManager) UnsubscribeFromOutput(serverID string, subscriber chan str
 	if _, ok := session.Subscribers[subscriber]; ok {
 		delete(session.Subscribers, subscriber)
 		close(subscriber)
-		log.Printf("[PTY] Removed subscriber for server %s (remaining: %d)", serverID, len(session.Subscribers))
+		logger.Debug("Subscriber removed", "server_id", serverID, "subscribers", len(session.Subscribers))
 	}
 	session.mu.Unlock()
 }
@@ -294,7 +298,7 @@ func (ps *PTYSession) Detach() error {
 	detachSequence := []byte{0x01, 0x44}
 	_, err := ps.Stdin.Write(detachSequence)
 	if err != nil {
-		log.Printf("[PTY] Failed to send detach sequence: %v", err)
+		logger.Error("Failed to send detach sequence", "error", err)
 		// Continue with cleanup anyway
 	}
 
@@ -315,7 +319,7 @@ func (ps *PTYSession) Detach() error {
 	ps.isAttached = false
 	close(ps.stopChan)
 
-	log.Printf("[PTY] Detached from screen session %s for server %s", ps.SessionName, ps.ServerID)
+	logger.Info("Detached from screen session", "session", ps.SessionName, "server_id", ps.ServerID)
 
 	return nil
 }
@@ -327,7 +331,7 @@ func (pm *PTYManager) DetachAll() {
 
 	for serverID, session := range pm.sessions {
 		if err := session.Detach(); err != nil {
-			log.Printf("[PTY] Error detaching from server %s: %v", serverID, err)
+			logger.Error("Error detaching", "server_id", serverID, "error", err)
 		}
 	}
 
@@ -388,7 +392,7 @@ func (ps *PTYSession) readOutput(outputType string, reader io.Reader) {
 					case subscriber <- line:
 					default:
 						// Subscriber channel full, skip
-						log.Printf("[PTY] Subscriber channel full for server %s, dropping line", ps.ServerID)
+						logger.Warn("Subscriber channel full, dropping line", "server_id", ps.ServerID)
 					}
 				}
 				ps.mu.RUnlock()
@@ -399,7 +403,7 @@ func (ps *PTYSession) readOutput(outputType string, reader io.Reader) {
 			} else {
 				// Check for errors
 				if err := scanner.Err(); err != nil {
-					log.Printf("[PTY] Error reading %s from server %s: %v", outputType, ps.ServerID, err)
+					logger.Error("Error reading output", "server_id", ps.ServerID, "stream", outputType, "error", err)
 				}
 				// Exit loop on EOF or error
 				return
@@ -0,0 +1,176 @@
+package startup
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("startup")
+
+// Defaults for start dependencies that leave timeout unset, and how often a
+// port condition is re-checked
+var (
+	defaultTimeout = 5 * time.Minute
+	pollInterval   = 2 * time.Second
+)
+
+// Outcomes of a server in a sequence
+const (
+	StatusStarted = "started"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Starter starts servers for a sequence
+type Starter interface {
+	// EnsureStarted starts a server unless it is already running
+	EnsureStarted(ctx context.Context, serverID string) error
+	// PortListening reports whether a port is listening on a server's host
+	PortListening(ctx context.Context, serverID string, port int) (bool, error)
+}
+
+// Result is the outcome of one server in a sequence
+type Result struct {
+	ServerID string `json:"server_id"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Sequence starts servers in dependency order. A server starts once every
+// server it starts after is up and that dependency's wait condition holds;
+// servers without pending dependencies start at the same time. If a
+// dependency fails, the servers after it are skipped.
+type Sequence struct {
+	Steps   [][]string
+	servers map[string]config.ServerDefinition
+}
+
+// Plan orders the servers in ids, or every server when ids is empty
+func Plan(servers []config.ServerDefinition, ids []string) (*Sequence, error) {
+	steps, err := config.StartOrder(servers, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]config.ServerDefinition, len(servers))
+	for _, server := range servers {
+		byID[server.ID] = server
+	}
+	return &Sequence{Steps: steps, servers: byID}, nil
+}
+
+// Run starts the servers and returns their outcomes in start order
+func (s *Sequence) Run(ctx context.Context, starter Starter) []Result {
+	done := make(map[string]chan struct{})
+	var order []string
+	for _, step := range s.Steps {
+		for _, id := range step {
+			done[id] = make(chan struct{})
+			order = append(order, id)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		results = make(map[string]Result, len(order))
+		wg      sync.WaitGroup
+	)
+	outcome := func(id string) Result {
+		mu.Lock()
+		defer mu.Unlock()
+		return results[id]
+	}
+
+	for _, id := range order {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			defer close(done[id])
+			result := s.runOne(ctx, starter, id, done, outcome)
+			mu.Lock()
+			results[id] = result
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	ordered := make([]Result, 0, len(order))
+	for _, id := range order {
+		ordered = append(ordered, results[id])
+	}
+	return ordered
+}
+
+func (s *Sequence) runOne(ctx context.Context, starter Starter, id string, done map[string]chan struct{}, outcome func(string) Result) Result {
+	for _, dep := range s.servers[id].StartAfter {
+		depDone, inSequence := done[dep.Server]
+		if !inSequence {
+			continue
+		}
+		select {
+		case <-depDone:
+		case <-ctx.Done():
+			return Result{ServerID: id, Status: StatusSkipped, Error: ctx.Err().Error()}
+		}
+		if outcome(dep.Server).Status != StatusStarted {
+			return Result{ServerID: id, Status: StatusSkipped, Error: fmt.Sprintf("%s did not start", dep.Server)}
+		}
+		if err := waitFor(ctx, starter, dep); err != nil {
+			return Result{ServerID: id, Status: StatusSkipped, Error: err.Error()}
+		}
+	}
+
+	logger.Info("Starting server in sequence", "server_id", id)
+	if err := starter.EnsureStarted(ctx, id); err != nil {
+		logger.Warn("Server in sequence failed to start", "server_id", id, "error", err)
+		return Result{ServerID: id, Status: StatusFailed, Error: err.Error()}
+	}
+	return Result{ServerID: id, Status: StatusStarted}
+}
+
+// waitFor blocks until a dependency's condition holds and its delay passed
+func waitFor(ctx context.Context, starter Starter, dep config.StartDependency) error {
+	timeout := parseDuration(dep.Timeout, defaultTimeout)
+	if dep.WaitFor == config.WaitForPort {
+		deadline := time.Now().Add(timeout)
+		for {
+			listening, err := starter.PortListening(ctx, dep.Server, dep.Port)
+			if err == nil && listening {
+				break
+			}
+			if !time.Now().Before(deadline) {
+				return fmt.Errorf("port %d on %s was not listening after %s", dep.Port, dep.Server, timeout)
+			}
+			if err := sleep(ctx, pollInterval); err != nil {
+				return err
+			}
+		}
+	}
+	return sleep(ctx, parseDuration(dep.Delay, 0))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
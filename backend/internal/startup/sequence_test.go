@@ -0,0 +1,125 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+type fakeStarter struct {
+	mu      sync.Mutex
+	started []string
+	fail    map[string]bool
+	ports   map[string]int // checks left before the port listens
+}
+
+func (f *fakeStarter) EnsureStarted(ctx context.Context, serverID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail[serverID] {
+		return errors.New("startup timeout")
+	}
+	f.started = append(f.started, serverID)
+	return nil
+}
+
+func (f *fakeStarter) PortListening(ctx context.Context, serverID string, port int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.ports[serverID] > 0 {
+		f.ports[serverID]--
+		return false, nil
+	}
+	return true, nil
+}
+
+func server(id string, after ...config.StartDependency) config.ServerDefinition {
+	return config.ServerDefinition{ID: id, StartAfter: after}
+}
+
+func TestPlanOrdersDependencies(t *testing.T) {
+	servers := []config.ServerDefinition{
+		server("lobby", config.StartDependency{Server: "proxy"}),
+		server("survival", config.StartDependency{Server: "proxy"}, config.StartDependency{Server: "lobby"}),
+		server("proxy"),
+		server("creative"),
+	}
+
+	plan, err := Plan(servers, nil)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	want := [][]string{{"creative", "proxy"}, {"lobby"}, {"survival"}}
+	if !reflect.DeepEqual(plan.Steps, want) {
+		t.Fatalf("expected steps %v, got %v", want, plan.Steps)
+	}
+
+	// Dependencies outside the selection are ignored
+	plan, err = Plan(servers, []string{"survival", "lobby"})
+	if err != nil || !reflect.DeepEqual(plan.Steps, [][]string{{"lobby"}, {"survival"}}) {
+		t.Fatalf("unexpected partial plan %v (%v)", plan, err)
+	}
+
+	servers[2] = server("proxy", config.StartDependency{Server: "survival"})
+	if _, err := Plan(servers, nil); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if _, err := Plan(servers, []string{"missing"}); err == nil {
+		t.Fatal("expected an unknown server to be rejected")
+	}
+}
+
+func TestSequenceWaitsAndSkipsAfterFailures(t *testing.T) {
+	pollInterval = time.Millisecond
+	servers := []config.ServerDefinition{
+		server("proxy"),
+		server("lobby", config.StartDependency{Server: "proxy", WaitFor: config.WaitForPort, Port: 5520, Delay: "1ms"}),
+		server("broken"),
+		server("survival", config.StartDependency{Server: "broken"}),
+	}
+	starter := &fakeStarter{fail: map[string]bool{"broken": true}, ports: map[string]int{"proxy": 3}}
+
+	plan, err := Plan(servers, nil)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	results := plan.Run(context.Background(), starter)
+
+	statuses := make(map[string]Result)
+	for _, result := range results {
+		statuses[result.ServerID] = result
+	}
+	if statuses["proxy"].Status != StatusStarted || statuses["lobby"].Status != StatusStarted {
+		t.Fatalf("expected proxy and lobby to start, got %+v", results)
+	}
+	if statuses["broken"].Status != StatusFailed || statuses["survival"].Status != StatusSkipped {
+		t.Fatalf("expected survival to be skipped after broken failed, got %+v", results)
+	}
+	if starter.ports["proxy"] != 0 {
+		t.Fatal("expected lobby to wait for the proxy port")
+	}
+	if len(starter.started) != 2 || starter.started[len(starter.started)-1] != "lobby" {
+		t.Fatalf("expected lobby to start after proxy, got %v", starter.started)
+	}
+}
+
+func TestSequenceGivesUpOnPort(t *testing.T) {
+	pollInterval = time.Millisecond
+	servers := []config.ServerDefinition{
+		server("proxy"),
+		server("lobby", config.StartDependency{Server: "proxy", WaitFor: config.WaitForPort, Port: 5520, Timeout: "5ms"}),
+	}
+	starter := &fakeStarter{ports: map[string]int{"proxy": 1 << 30}}
+
+	plan, _ := Plan(servers, nil)
+	results := plan.Run(context.Background(), starter)
+	if results[1].Status != StatusSkipped || !strings.Contains(results[1].Error, "port 5520") {
+		t.Fatalf("expected lobby to be skipped on the port timeout, got %+v", results)
+	}
+}
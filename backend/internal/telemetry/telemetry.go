@@ -0,0 +1,273 @@
+// Package telemetry keeps the manager's own counters, gauges and histograms and
+// writes them in the Prometheus text exposition format. It covers the handful
+// of metric types the manager needs without pulling in the Prometheus client.
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are latency buckets in seconds, from 5ms to 30s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metric is one family in a registry
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds metric families and renders them for scraping
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.metrics {
+		if existing.name() == m.name() {
+			panic("telemetry: duplicate metric " + m.name())
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes every metric in the Prometheus text format, sorted by name
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the registry for a Prometheus scraper
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// vec holds one series per combination of label values
+type vec struct {
+	metricName string
+	help       string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string][]string // key -> label values
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{metricName: name, help: help, labels: labels, series: make(map[string][]string)}
+}
+
+func (v *vec) name() string { return v.metricName }
+
+// key returns the series key for values, registering them on first use.
+// v.mu must be held.
+func (v *vec) key(values []string) string {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("telemetry: %s expects %d label values, got %d", v.metricName, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	if _, ok := v.series[key]; !ok {
+		v.series[key] = append([]string{}, values...)
+	}
+	return key
+}
+
+// sortedKeys returns series keys in a stable order. v.mu must be held.
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (v *vec) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.metricName, v.help, v.metricName, kind)
+}
+
+// labelString renders {a="x",b="y"} with any extra pair appended
+func (v *vec) labelString(values []string, extra ...string) string {
+	if len(values) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range v.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(label + `="` + escapeLabel(values[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i] + `="` + escapeLabel(extra[i+1]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	vec
+	values map[string]float64
+}
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec: newVec(name, help, labels), values: make(map[string]float64)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series for the label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series for the label values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[c.key(values)] += delta
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelString(c.series[key]), formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec
+	buckets []float64
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds and label
+// names. Nil buckets use DefaultBuckets.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{vec: newVec(name, help, labels), buckets: buckets, values: make(map[string]*histogram)}
+	r.register(h)
+	return h
+}
+
+// Observe records one value in the series for the label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := h.key(values)
+	series, ok := h.values[key]
+	if !ok {
+		series = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = series
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, key := range h.sortedKeys() {
+		labels, series := h.series[key], h.values[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(labels, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(labels, "le", "+Inf"), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelString(labels), formatFloat(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelString(labels), series.count)
+	}
+}
+
+// GaugeFunc is a gauge whose series are read from collect on every scrape,
+// for values the manager already tracks elsewhere (pool sizes, queue depths)
+type GaugeFunc struct {
+	vec
+	collect func(observe func(value float64, values ...string))
+}
+
+// NewGaugeFunc registers a gauge with the given label names. collect reports
+// each series by calling observe; series it does not report are left out.
+func (r *Registry) NewGaugeFunc(name, help string, collect func(observe func(value float64, values ...string)), labels ...string) *GaugeFunc {
+	g := &GaugeFunc{vec: newVec(name, help, labels), collect: collect}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	values := make(map[string]float64)
+	observe := func(value float64, labels ...string) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		values[g.key(labels)] = value
+	}
+	g.collect(observe)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.header(w, "gauge")
+	for _, key := range g.sortedKeys() {
+		if value, ok := values[key]; ok {
+			fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.labelString(g.series[key]), formatFloat(value))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWritesPrometheusText(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("test_requests_total", "Requests.", "route")
+	latency := registry.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	pools := map[string]float64{"healthy": 3, "failed": 1}
+	registry.NewGaugeFunc("test_connections", "Connections.", func(observe func(float64, ...string)) {
+		for health, count := range pools {
+			observe(count, health)
+		}
+	}, "health")
+
+	requests.Inc(`/a"b`)
+	requests.Add(2, "/c")
+	latency.Observe(0.05, "/c")
+	latency.Observe(0.5, "/c")
+	latency.Observe(3, "/c")
+
+	var out strings.Builder
+	registry.WriteText(&out)
+	text := out.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{route="/a\"b"} 1` + "\n",
+		`test_requests_total{route="/c"} 2` + "\n",
+		"# TYPE test_latency_seconds histogram\n",
+		`test_latency_seconds_bucket{route="/c",le="0.1"} 1` + "\n",
+		`test_latency_seconds_bucket{route="/c",le="1"} 2` + "\n",
+		`test_latency_seconds_bucket{route="/c",le="+Inf"} 3` + "\n",
+		`test_latency_seconds_sum{route="/c"} 3.55` + "\n",
+		`test_latency_seconds_count{route="/c"} 3` + "\n",
+		"# TYPE test_connections gauge\n",
+		`test_connections{health="failed"} 1` + "\n",
+		`test_connections{health="healthy"} 3` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "test_latency_seconds") > strings.Index(text, "test_requests_total") {
+		t.Fatalf("expected metrics sorted by name")
+	}
+
+	// Gauge series the collector stops reporting disappear
+	delete(pools, "failed")
+	out.Reset()
+	registry.WriteText(&out)
+	if strings.Contains(out.String(), `health="failed"`) {
+		t.Fatalf("expected the failed series to be gone, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,220 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+const (
+	exportBatchSize = 256
+	exportInterval  = 5 * time.Second
+	exportQueueSize = 4096
+	scopeName       = "github.com/TheGojiOG/HytaleSM"
+)
+
+// Exporter batches finished spans and posts them to an OTLP/HTTP collector as JSON
+type Exporter struct {
+	url           string
+	serviceName   string
+	samplePercent int
+	client        *http.Client
+	queue         chan exportedSpan
+	done          chan struct{}
+	wg            sync.WaitGroup
+}
+
+type exportedSpan struct {
+	span *Span
+	end  time.Time
+}
+
+// Init starts exporting spans when tracing is enabled. The returned function
+// flushes queued spans and stops the exporter.
+func Init(cfg config.TracingConfig) func(context.Context) {
+	if !cfg.Enabled {
+		return func(context.Context) {}
+	}
+
+	exporter := &Exporter{
+		url:           strings.TrimRight(cfg.Endpoint, "/") + "/v1/traces",
+		serviceName:   cfg.ServiceName,
+		samplePercent: cfg.SamplePercent,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		queue:         make(chan exportedSpan, exportQueueSize),
+		done:          make(chan struct{}),
+	}
+	if exporter.serviceName == "" {
+		exporter.serviceName = "hytale-server-manager"
+	}
+
+	exporter.wg.Add(1)
+	go exporter.run()
+	active.Store(exporter)
+	log.Printf("[Tracing] Exporting traces to %s (%d%% sampled)", exporter.url, exporter.samplePercent)
+
+	return func(ctx context.Context) {
+		active.CompareAndSwap(exporter, nil)
+		close(exporter.done)
+		finished := make(chan struct{})
+		go func() {
+			exporter.wg.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (e *Exporter) sample() bool {
+	if e.samplePercent >= 100 {
+		return true
+	}
+	return rand.Intn(100) < e.samplePercent
+}
+
+func (e *Exporter) enqueue(span *Span, end time.Time) {
+	select {
+	case e.queue <- exportedSpan{span: span, end: end}:
+	default:
+		// Dropping spans is preferable to blocking the request that produced them
+	}
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	batch := make([]exportedSpan, 0, exportBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Printf("[Tracing] Failed to export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case item := <-e.queue:
+			batch = append(batch, item)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case item := <-e.queue:
+					batch = append(batch, item)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) export(batch []exportedSpan) error {
+	body, err := json.Marshal(e.payload(batch))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// payload builds an OTLP ExportTraceServiceRequest in its JSON encoding
+func (e *Exporter) payload(batch []exportedSpan) map[string]interface{} {
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, item := range batch {
+		span := item.span
+		encoded := map[string]interface{}{
+			"traceId":           hex.EncodeToString(span.traceID[:]),
+			"spanId":            hex.EncodeToString(span.spanID[:]),
+			"name":              span.name,
+			"kind":              int(span.kind),
+			"startTimeUnixNano": strconv.FormatInt(span.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(item.end.UnixNano(), 10),
+			"attributes":        encodeAttributes(span.attributes),
+		}
+		if span.parentID != ([8]byte{}) {
+			encoded["parentSpanId"] = hex.EncodeToString(span.parentID[:])
+		}
+		if span.errMessage != "" {
+			encoded["status"] = map[string]interface{}{"code": 2, "message": span.errMessage}
+		}
+		spans = append(spans, encoded)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": encodeAttributes(map[string]interface{}{"service.name": e.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": scopeName},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func encodeAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]interface{}{"key": key, "value": value})
+	}
+	return encoded
+}
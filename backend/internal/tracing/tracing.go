@@ -0,0 +1,180 @@
+// Package tracing carries request IDs through contexts and records spans for
+// HTTP handlers, SSH commands and database queries. Spans are exported to an
+// OpenTelemetry collector over OTLP/HTTP when tracing is enabled; otherwise
+// Start returns a nil span whose methods do nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind values follow the OTLP enum
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type requestIDKey struct{}
+type spanKey struct{}
+
+// active is the exporter spans are sent to, nil while tracing is disabled
+var active atomic.Pointer[Exporter]
+
+// WithRequestID stores the request ID in a context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in a context, or an empty string
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// spanContext identifies a span, either local or received in a traceparent header
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+// Span is one timed operation. A nil *Span is valid and records nothing.
+type Span struct {
+	spanContext
+	parentID   [8]byte
+	name       string
+	kind       SpanKind
+	start      time.Time
+	attributes map[string]interface{}
+	errMessage string
+	exporter   *Exporter
+	ended      atomic.Bool
+}
+
+// Start begins a span as a child of the span in ctx, if any, and returns a
+// context carrying the new span. It returns a nil span when tracing is
+// disabled or the trace was not sampled.
+func Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	exporter := active.Load()
+	if exporter == nil {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, kind: kind, start: time.Now(), attributes: map[string]interface{}{}, exporter: exporter}
+	if parent, ok := ctx.Value(spanKey{}).(spanContext); ok {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = parent.sampled
+	} else {
+		_, _ = rand.Read(span.traceID[:])
+		span.sampled = exporter.sample()
+	}
+	if !span.sampled {
+		return ctx, nil
+	}
+	_, _ = rand.Read(span.spanID[:])
+
+	if id := RequestID(ctx); id != "" {
+		span.attributes["request.id"] = id
+	}
+	return context.WithValue(ctx, spanKey{}, span.spanContext), span
+}
+
+// StartChild is Start for operations only worth recording as part of an
+// existing trace, such as database queries issued while serving a request
+func StartChild(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if _, ok := ctx.Value(spanKey{}).(spanContext); !ok {
+		return ctx, nil
+	}
+	return Start(ctx, name, kind)
+}
+
+// SetAttribute records a key/value on the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.errMessage = err.Error()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+	s.exporter.enqueue(s, time.Now())
+}
+
+// TraceParent formats the span as a W3C traceparent header value
+func (s *Span) TraceParent() string {
+	if s == nil {
+		return ""
+	}
+	return formatTraceParent(s.spanContext)
+}
+
+// TraceID returns the hex trace ID, or an empty string for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// ContextWithTraceParent continues a trace started by the caller, as described
+// by a W3C traceparent header. Malformed headers are ignored.
+func ContextWithTraceParent(ctx context.Context, header string) context.Context {
+	parent, ok := parseTraceParent(header)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, parent)
+}
+
+func formatTraceParent(sc spanContext) string {
+	flags := "00"
+	if sc.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.traceID[:]), hex.EncodeToString(sc.spanID[:]), flags)
+}
+
+func parseTraceParent(header string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || sc.traceID == ([16]byte{}) {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || sc.spanID == ([8]byte{}) {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.sampled = flags[0]&0x01 == 0x01
+	return sc, true
+}
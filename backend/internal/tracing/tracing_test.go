@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+)
+
+func TestTraceParentRoundTrip(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, ok := parseTraceParent(header)
+	if !ok || !sc.sampled {
+		t.Fatalf("expected valid sampled traceparent, got %v %+v", ok, sc)
+	}
+	if got := formatTraceParent(sc); got != header {
+		t.Fatalf("expected %s, got %s", header, got)
+	}
+
+	for _, bad := range []string{"", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-xyz-00f067aa0ba902b7-01"} {
+		if _, ok := parseTraceParent(bad); ok {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestExportContinuesTrace(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("unexpected export path %s", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+	}))
+	defer collector.Close()
+
+	if _, span := Start(context.Background(), "disabled", KindInternal); span != nil {
+		t.Fatalf("expected nil span while tracing is disabled")
+	}
+
+	shutdown := Init(config.TracingConfig{Enabled: true, Endpoint: collector.URL, ServiceName: "test", SamplePercent: 100})
+	ctx := WithRequestID(context.Background(), "req-1")
+	ctx = ContextWithTraceParent(ctx, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, parent := Start(ctx, "GET /api/v1/servers", KindServer)
+	_, child := StartChild(ctx, "db.query", KindClient)
+	child.End()
+	parent.End()
+
+	if _, span := StartChild(context.Background(), "db.query", KindClient); span != nil {
+		t.Fatalf("expected no span for a query outside a trace")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(shutdownCtx)
+
+	var body []byte
+	select {
+	case body = <-bodies:
+	default:
+		t.Fatal("expected spans to be exported on shutdown")
+	}
+
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	spans := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[0].Name != "db.query" || spans[1].ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected spans: %+v", spans)
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID || spans[0].TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("child span not linked to parent: %+v", spans)
+	}
+	if !strings.Contains(string(body), `"request.id"`) {
+		t.Fatalf("expected request.id attribute in %s", body)
+	}
+}
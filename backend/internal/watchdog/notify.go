@@ -0,0 +1,44 @@
+package watchdog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/notify"
+)
+
+// EmailNotifier mails crash reports to the addresses recipients returns.
+// Nothing is sent while SMTP is not configured or the list is empty.
+func EmailNotifier(mailer *notify.SMTPSender, recipients func() []string) Notifier {
+	return func(crash Crash) {
+		to := recipients()
+		if len(to) == 0 || !mailer.Enabled() {
+			return
+		}
+		subject, body := crashMessage(crash)
+		if err := mailer.Send(to, subject, body); err != nil {
+			logger.Error("Failed to send crash notification email", "server_id", crash.ServerID, "error", err)
+		}
+	}
+}
+
+func crashMessage(crash Crash) (string, string) {
+	subject := fmt.Sprintf("Hytale Server Manager: server %s crashed", crash.ServerID)
+	var body strings.Builder
+	fmt.Fprintf(&body, "Server %s exited unexpectedly at %s.\n", crash.ServerID, crash.DetectedAt.Format(time.RFC3339))
+	if crash.RestartError != "" {
+		fmt.Fprintf(&body, "The last restart failed: %s\n", crash.RestartError)
+	}
+	switch {
+	case crash.GaveUp:
+		subject = fmt.Sprintf("Hytale Server Manager: server %s keeps crashing", crash.ServerID)
+		fmt.Fprintf(&body, "It was restarted %d times recently, so it will not be restarted again until it is started by hand or its watchdog is reset.\n", crash.Restarts)
+	case crash.NextRestart != nil:
+		fmt.Fprintf(&body, "It will be restarted at %s (restart %d).\n", crash.NextRestart.Format(time.RFC3339), crash.Restarts+1)
+	}
+	if len(crash.ConsoleLines) > 0 {
+		fmt.Fprintf(&body, "\nLast %d console lines:\n\n%s\n", len(crash.ConsoleLines), strings.Join(crash.ConsoleLines, "\n"))
+	}
+	return subject, body.String()
+}
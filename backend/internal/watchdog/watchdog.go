@@ -0,0 +1,318 @@
+package watchdog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
+)
+
+var logger = logging.For("watchdog")
+
+// Controller restarts servers for the watchdog
+type Controller interface {
+	// Watched reports whether an exit of the server was unexpected and should
+	// be acted on: the watchdog applies to it and it was not stopped on purpose
+	Watched(serverID string) bool
+	// Restart starts a server that exited
+	Restart(ctx context.Context, serverID string) error
+	// ConsoleTail returns the last lines of a server's console log
+	ConsoleTail(ctx context.Context, serverID string, lines int) ([]string, error)
+}
+
+// Crash describes an unexpected exit of a server
+type Crash struct {
+	ServerID   string    `json:"server_id"`
+	DetectedAt time.Time `json:"detected_at"`
+	// Restarts counts the restarts within the window before this crash
+	Restarts    int        `json:"restarts"`
+	NextRestart *time.Time `json:"next_restart,omitempty"`
+	// GaveUp is set when the crash tripped the max-restarts limit
+	GaveUp       bool     `json:"gave_up"`
+	RestartError string   `json:"restart_error,omitempty"`
+	ConsoleLines []string `json:"console_lines,omitempty"`
+}
+
+// Notifier is told about every crash
+type Notifier func(Crash)
+
+// State is the watchdog's view of one server
+type State struct {
+	ServerID    string      `json:"server_id"`
+	Restarts    []time.Time `json:"restarts"`
+	LastCrash   *time.Time  `json:"last_crash,omitempty"`
+	NextRestart *time.Time  `json:"next_restart,omitempty"`
+	Restarting  bool        `json:"restarting"`
+	GaveUp      bool        `json:"gave_up"`
+	LastError   string      `json:"last_error,omitempty"`
+}
+
+// Settings are the parsed watchdog configuration
+type Settings struct {
+	Enabled      bool
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	MaxRestarts  int
+	Window       time.Duration
+	ConsoleLines int
+}
+
+// SettingsFrom parses the watchdog section of the manager configuration,
+// falling back to the defaults for unset values
+func SettingsFrom(cfg config.WatchdogConfig) Settings {
+	settings := Settings{
+		Enabled:      cfg.Enabled,
+		InitialDelay: parseDuration(cfg.InitialDelay, 10*time.Second),
+		MaxDelay:     parseDuration(cfg.MaxDelay, 5*time.Minute),
+		MaxRestarts:  cfg.MaxRestarts,
+		Window:       parseDuration(cfg.Window, time.Hour),
+		ConsoleLines: cfg.ConsoleLines,
+	}
+	if settings.MaxDelay < settings.InitialDelay {
+		settings.MaxDelay = settings.InitialDelay
+	}
+	return settings
+}
+
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+type serverState struct {
+	restarts  []time.Time
+	lastCrash time.Time
+	next      time.Time
+	timer     *time.Timer
+	// restarting is set while a restart runs, so the exit seen mid-start is not another crash
+	restarting bool
+	gaveUp     bool
+	lastError  string
+}
+
+// Watchdog restarts servers that exit unexpectedly. Each crash waits twice as
+// long as the one before it, up to MaxDelay; after MaxRestarts restarts
+// within Window the watchdog stops restarting the server until it is reset.
+type Watchdog struct {
+	control   Controller
+	notifiers []Notifier
+
+	mu       sync.Mutex
+	settings Settings
+	servers  map[string]*serverState
+	ctx      context.Context
+	wg       sync.WaitGroup
+}
+
+// New creates a watchdog
+func New(control Controller, settings Settings) *Watchdog {
+	return &Watchdog{
+		control:  control,
+		settings: settings,
+		servers:  make(map[string]*serverState),
+		ctx:      context.Background(),
+	}
+}
+
+// Start lets the watchdog restart servers until ctx is cancelled
+func (w *Watchdog) Start(ctx context.Context) {
+	w.mu.Lock()
+	w.ctx = ctx
+	w.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for _, state := range w.servers {
+			if state.timer != nil {
+				state.timer.Stop()
+				state.timer = nil
+			}
+		}
+	}()
+}
+
+// SetSettings applies new settings, e.g. after a configuration reload
+func (w *Watchdog) SetSettings(settings Settings) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.settings = settings
+}
+
+// OnCrash registers fn to be told about crashes
+func (w *Watchdog) OnCrash(fn Notifier) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.notifiers = append(w.notifiers, fn)
+}
+
+// Wait blocks until restarts in progress are done
+func (w *Watchdog) Wait() {
+	w.wg.Wait()
+}
+
+// ServerExited is called when a running server's process is found gone
+func (w *Watchdog) ServerExited(serverID string) {
+	w.mu.Lock()
+	enabled := w.settings.Enabled
+	state := w.servers[serverID]
+	pending := state != nil && (state.timer != nil || state.restarting)
+	w.mu.Unlock()
+	if !enabled || pending || !w.control.Watched(serverID) {
+		return
+	}
+	w.crashed(serverID, "")
+}
+
+// Reset forgets a server's crashes and cancels a pending restart. It is
+// called when the server is started or stopped by hand, and re-arms the
+// watchdog after it gave up.
+func (w *Watchdog) Reset(serverID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if state, ok := w.servers[serverID]; ok {
+		if state.timer != nil {
+			state.timer.Stop()
+		}
+		delete(w.servers, serverID)
+	}
+}
+
+// State returns the watchdog's view of a server
+func (w *Watchdog) State(serverID string) State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	result := State{ServerID: serverID, Restarts: []time.Time{}}
+	state, ok := w.servers[serverID]
+	if !ok {
+		return result
+	}
+	result.Restarts = append(result.Restarts, w.recentRestarts(state, time.Now())...)
+	result.Restarting = state.restarting
+	result.GaveUp = state.gaveUp
+	result.LastError = state.lastError
+	if !state.lastCrash.IsZero() {
+		lastCrash := state.lastCrash
+		result.LastCrash = &lastCrash
+	}
+	if state.timer != nil {
+		next := state.next
+		result.NextRestart = &next
+	}
+	return result
+}
+
+// crashed records a crash, or a failed restart, and schedules the next restart
+func (w *Watchdog) crashed(serverID, restartErr string) {
+	now := time.Now()
+
+	w.mu.Lock()
+	state, ok := w.servers[serverID]
+	if !ok {
+		state = &serverState{}
+		w.servers[serverID] = state
+	}
+	settings := w.settings
+	ctx := w.ctx
+	state.restarts = w.recentRestarts(state, now)
+	state.lastCrash = now
+	state.lastError = restartErr
+
+	crash := Crash{ServerID: serverID, DetectedAt: now, Restarts: len(state.restarts), RestartError: restartErr}
+	if settings.MaxRestarts > 0 && len(state.restarts) >= settings.MaxRestarts {
+		state.gaveUp = true
+		crash.GaveUp = true
+	} else if ctx.Err() == nil {
+		delay := backoff(settings, len(state.restarts))
+		state.next = now.Add(delay)
+		next := state.next
+		crash.NextRestart = &next
+		state.timer = time.AfterFunc(delay, func() { w.restart(serverID) })
+	}
+	notifiers := append([]Notifier{}, w.notifiers...)
+	w.mu.Unlock()
+
+	if crash.GaveUp {
+		logger.Error("Server keeps crashing, giving up on restarts", "server_id", serverID, "restarts", crash.Restarts, "window", settings.Window)
+	} else {
+		logger.Warn("Server exited unexpectedly", "server_id", serverID, "restarts", crash.Restarts, "next_restart", crash.NextRestart, "restart_error", restartErr)
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if settings.ConsoleLines > 0 {
+			lines, err := w.control.ConsoleTail(ctx, serverID, settings.ConsoleLines)
+			if err != nil {
+				logger.Warn("Failed to read console log of crashed server", "server_id", serverID, "error", err)
+			}
+			crash.ConsoleLines = lines
+		}
+		for _, notify := range notifiers {
+			notify(crash)
+		}
+	}()
+}
+
+func (w *Watchdog) restart(serverID string) {
+	w.mu.Lock()
+	state, ok := w.servers[serverID]
+	if !ok || state.timer == nil {
+		// Reset meanwhile
+		w.mu.Unlock()
+		return
+	}
+	state.timer = nil
+	state.restarting = true
+	state.restarts = append(state.restarts, time.Now())
+	ctx := w.ctx
+	w.wg.Add(1)
+	w.mu.Unlock()
+	defer w.wg.Done()
+
+	var err error
+	// Stopped by hand while the restart was waiting
+	if ctx.Err() == nil && w.control.Watched(serverID) {
+		logger.Info("Restarting crashed server", "server_id", serverID)
+		err = w.control.Restart(ctx, serverID)
+	}
+
+	w.mu.Lock()
+	state.restarting = false
+	w.mu.Unlock()
+	if err != nil {
+		logger.Error("Failed to restart crashed server", "server_id", serverID, "error", err)
+		w.crashed(serverID, err.Error())
+	}
+}
+
+// recentRestarts drops restarts older than the window. Callers hold w.mu.
+func (w *Watchdog) recentRestarts(state *serverState, now time.Time) []time.Time {
+	cutoff := now.Add(-w.settings.Window)
+	recent := state.restarts[:0]
+	for _, at := range state.restarts {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	return recent
+}
+
+// backoff returns the wait before the restart that follows the given number
+// of recent restarts
+func backoff(settings Settings, restarts int) time.Duration {
+	delay := settings.InitialDelay
+	for i := 0; i < restarts && delay < settings.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > settings.MaxDelay {
+		delay = settings.MaxDelay
+	}
+	return delay
+}
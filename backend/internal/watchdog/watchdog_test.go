@@ -0,0 +1,167 @@
+package watchdog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeController struct {
+	mu       sync.Mutex
+	watched  bool
+	restarts int
+	failNext bool
+}
+
+func (f *fakeController) Watched(serverID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.watched
+}
+
+func (f *fakeController) Restart(ctx context.Context, serverID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restarts++
+	if f.failNext {
+		f.failNext = false
+		return errors.New("startup timeout")
+	}
+	return nil
+}
+
+func (f *fakeController) ConsoleTail(ctx context.Context, serverID string, lines int) ([]string, error) {
+	return []string{"[INFO] Saving world", "java.lang.OutOfMemoryError"}[:lines], nil
+}
+
+func (f *fakeController) restartCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.restarts
+}
+
+func testSettings() Settings {
+	return Settings{Enabled: true, InitialDelay: 5 * time.Millisecond, MaxDelay: 20 * time.Millisecond, MaxRestarts: 2, Window: time.Hour, ConsoleLines: 2}
+}
+
+func collectCrashes(w *Watchdog) func() []Crash {
+	var mu sync.Mutex
+	var crashes []Crash
+	w.OnCrash(func(crash Crash) {
+		mu.Lock()
+		defer mu.Unlock()
+		crashes = append(crashes, crash)
+	})
+	return func() []Crash {
+		w.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Crash{}, crashes...)
+	}
+}
+
+// settled reports whether no restart of the server is waiting or running
+func settled(w *Watchdog) bool {
+	state := w.State("srv")
+	return state.NextRestart == nil && !state.Restarting
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the watchdog")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchdogRestartsUntilLimit(t *testing.T) {
+	control := &fakeController{watched: true}
+	w := New(control, testSettings())
+	crashes := collectCrashes(w)
+
+	for i := 1; i <= 2; i++ {
+		w.ServerExited("srv")
+		waitFor(t, func() bool { return control.restartCount() == i && settled(w) })
+	}
+	w.ServerExited("srv")
+
+	got := crashes()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 crash reports, got %+v", got)
+	}
+	if got[0].NextRestart == nil || got[0].Restarts != 0 || len(got[0].ConsoleLines) != 2 {
+		t.Fatalf("unexpected first crash %+v", got[0])
+	}
+	if !got[2].GaveUp || got[2].NextRestart != nil {
+		t.Fatalf("expected the third crash to trip the limit, got %+v", got[2])
+	}
+	state := w.State("srv")
+	if !state.GaveUp || len(state.Restarts) != 2 {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	w.Reset("srv")
+	if state := w.State("srv"); state.GaveUp || len(state.Restarts) != 0 {
+		t.Fatalf("expected reset to clear the state, got %+v", state)
+	}
+}
+
+func TestWatchdogRetriesFailedRestart(t *testing.T) {
+	control := &fakeController{watched: true, failNext: true}
+	settings := testSettings()
+	settings.MaxRestarts = 5
+	w := New(control, settings)
+	crashes := collectCrashes(w)
+
+	w.ServerExited("srv")
+	waitFor(t, func() bool { return control.restartCount() == 2 && settled(w) })
+
+	got := crashes()
+	if len(got) != 2 || got[1].RestartError != "startup timeout" || got[1].Restarts != 1 {
+		t.Fatalf("expected the failed restart to be reported and retried, got %+v", got)
+	}
+}
+
+func TestWatchdogIgnoresExpectedExits(t *testing.T) {
+	control := &fakeController{watched: false}
+	w := New(control, testSettings())
+	crashes := collectCrashes(w)
+
+	w.ServerExited("srv")
+	if got := crashes(); len(got) != 0 {
+		t.Fatalf("expected no crash for a server stopped on purpose, got %+v", got)
+	}
+
+	control.watched = true
+	settings := testSettings()
+	settings.Enabled = false
+	w.SetSettings(settings)
+	w.ServerExited("srv")
+	if got := crashes(); len(got) != 0 {
+		t.Fatalf("expected no crash while disabled, got %+v", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	settings := Settings{InitialDelay: 10 * time.Second, MaxDelay: time.Minute}
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for restarts, expected := range want {
+		if got := backoff(settings, restarts); got != expected {
+			t.Errorf("backoff after %d restarts: expected %v, got %v", restarts, expected, got)
+		}
+	}
+}
+
+func TestCrashMessage(t *testing.T) {
+	crash := Crash{ServerID: "srv", DetectedAt: time.Now(), Restarts: 5, GaveUp: true, ConsoleLines: []string{"java.lang.OutOfMemoryError"}}
+	subject, body := crashMessage(crash)
+	if !strings.Contains(subject, "keeps crashing") || !strings.Contains(body, "java.lang.OutOfMemoryError") {
+		t.Fatalf("unexpected message %q\n%s", subject, body)
+	}
+}
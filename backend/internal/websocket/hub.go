@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/TheGojiOG/HytaleSM/internal/logging"
 	"github.com/gorilla/websocket"
 )
 
+// logger is shared by the websocket package
+var logger = logging.For("websocket")
+
 // Message represents a WebSocket message
 type Message struct {
 	Type      string                 `json:"type"`
@@ -28,7 +32,9 @@ type Client struct {
 	Room     string
 	Send     chan *Message
 	Hub      *Hub
-	mu       sync.Mutex
+	// Filter, when set, decides which room messages are sent to the client
+	Filter func(*Message) bool
+	mu     sync.Mutex
 }
 
 // Hub manages all WebSocket connections and rooms
@@ -48,6 +54,9 @@ type Hub struct {
 	// Active clients by ID for quick lookup
 	clients map[string]*Client
 
+	// Passes published messages on to other manager instances
+	relay func(room string, message *Message)
+
 	mu sync.RWMutex
 }
 
@@ -83,7 +92,7 @@ func (h *Hub) Run(ctx context.Context) {
 			h.broadcastToRoom(message)
 
 		case <-ctx.Done():
-			log.Println("[WebSocket] Hub shutting down")
+			logger.Info("Hub shutting down")
 			h.shutdown()
 			return
 		}
@@ -106,8 +115,7 @@ func (h *Hub) registerClient(client *Client) {
 	// Add client to room
 	h.rooms[client.Room][client] = true
 
-	log.Printf("[WebSocket] Client %s (user=%s) joined room %s. Room size: %d",
-		client.ID, client.Username, client.Room, len(h.rooms[client.Room]))
+	logger.Debug("Client joined room", "client_id", client.ID, "username", client.Username, "room", client.Room, "room_size", len(h.rooms[client.Room]))
 
 	// Notify room about new user
 	h.broadcast <- &BroadcastMessage{
@@ -142,10 +150,9 @@ func (h *Hub) unregisterClient(client *Client) {
 			// Remove room if empty
 			if len(clients) == 0 {
 				delete(h.rooms, client.Room)
-				log.Printf("[WebSocket] Room %s is now empty and removed", client.Room)
+				logger.Debug("Room is empty, removed", "room", client.Room)
 			} else {
-				log.Printf("[WebSocket] Client %s left room %s. Room size: %d",
-					client.ID, client.Room, len(clients))
+				logger.Debug("Client left room", "client_id", client.ID, "room", client.Room, "room_size", len(clients))
 
 				// Notify room about user leaving
 				go func() {
@@ -178,12 +185,15 @@ func (h *Hub) broadcastToRoom(bm *BroadcastMessage) {
 			if bm.Exclude != nil && client.ID == bm.Exclude.ID {
 				continue
 			}
+			if client.Filter != nil && !client.Filter(bm.Message) {
+				continue
+			}
 
 			select {
 			case client.Send <- bm.Message:
 			default:
 				// Client's send channel is full, drop message to avoid disconnecting
-				log.Printf("[WebSocket] Client %s send channel full, dropping message", client.ID)
+				logger.Warn("Client send channel full, dropping message", "client_id", client.ID)
 			}
 		}
 	}
@@ -214,6 +224,20 @@ func (h *Hub) GetRoomSize(room string) int {
 	return 0
 }
 
+// ClientCounts returns the number of connected clients by kind of room, the
+// part of the room name before the first colon (console, server-tasks, ...)
+func (h *Hub) ClientCounts() map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for room, clients := range h.rooms {
+		kind, _, _ := strings.Cut(room, ":")
+		counts[kind] += len(clients)
+	}
+	return counts
+}
+
 // BroadcastToRoom sends a message to all clients in a room
 func (h *Hub) BroadcastToRoom(room string, message *Message) {
 	h.broadcast <- &BroadcastMessage{
@@ -222,6 +246,28 @@ func (h *Hub) BroadcastToRoom(room string, message *Message) {
 	}
 }
 
+// Publish sends a message to all clients in a room on every manager
+// instance. Messages that each instance produces itself, such as status
+// changes, use BroadcastToRoom instead.
+func (h *Hub) Publish(room string, message *Message) {
+	h.BroadcastToRoom(room, message)
+
+	h.mu.RLock()
+	relay := h.relay
+	h.mu.RUnlock()
+	if relay != nil {
+		relay(room, message)
+	}
+}
+
+// SetRelay makes Publish pass messages to relay as well, which delivers them
+// to the hubs of the other manager instances
+func (h *Hub) SetRelay(relay func(room string, message *Message)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.relay = relay
+}
+
 // shutdown closes all connections gracefully
 func (h *Hub) shutdown() {
 	h.mu.Lock()
@@ -229,7 +275,10 @@ func (h *Hub) shutdown() {
 
 	for _, client := range h.clients {
 		close(client.Send)
-		client.Conn.Close()
+		// Clients without a connection, such as event streams, end when Send closes
+		if client.Conn != nil {
+			client.Conn.Close()
+		}
 	}
 
 	h.rooms = make(map[string]map[*Client]bool)
@@ -253,7 +302,7 @@ func (c *Client) ReadPump() {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[WebSocket] Read error: %v", err)
+				logger.Error("Read error", "error", err)
 			}
 			break
 		}
@@ -261,13 +310,13 @@ func (c *Client) ReadPump() {
 		// Parse message
 		var msg Message
 		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("[WebSocket] Failed to parse message: %v", err)
+			logger.Error("Failed to parse message", "error", err)
 			continue
 		}
 
 		msg.Timestamp = time.Now()
 		// Message handling will be done by specific handlers (console, etc.)
-		log.Printf("[WebSocket] Received message type=%s from client=%s", msg.Type, c.ID)
+		logger.Debug("Received message", "type", msg.Type, "client_id", c.ID)
 	}
 }
 
@@ -297,7 +346,7 @@ func (c *Client) WritePump() {
 			// Marshal and write message
 			data, err := json.Marshal(message)
 			if err != nil {
-				log.Printf("[WebSocket] Failed to marshal message: %v", err)
+				logger.Error("Failed to marshal message", "error", err)
 				continue
 			}
 
@@ -329,7 +378,16 @@ func (c *Client) WritePump() {
 }
 
 // SendMessage sends a message to this specific client
-func (c *Client) SendMessage(msgType string, payload interface{}) (err error) {
+func (c *Client) SendMessage(msgType string, payload interface{}) error {
+	return c.Deliver(&Message{
+		Type:      msgType,
+		Payload:   payload,
+		Timestamp: time.Now(),
+	})
+}
+
+// Deliver queues a message for this client only
+func (c *Client) Deliver(msg *Message) (err error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	defer func() {
@@ -338,12 +396,6 @@ func (c *Client) SendMessage(msgType string, payload interface{}) (err error) {
 		}
 	}()
 
-	msg := &Message{
-		Type:      msgType,
-		Payload:   payload,
-		Timestamp: time.Now(),
-	}
-
 	select {
 	case c.Send <- msg:
 		return nil
@@ -19,6 +19,12 @@ func TestHubRegisterAndUnregister(t *testing.T) {
 	if hub.GetRoomSize("room-1") != 1 {
 		t.Fatalf("expected room size 1")
 	}
+	console := &Client{ID: "client-2", Room: "console:alpha", Send: make(chan *Message, 1), Hub: hub}
+	hub.registerClient(console)
+	if counts := hub.ClientCounts(); counts["room-1"] != 1 || counts["console"] != 1 {
+		t.Fatalf("expected one client per kind of room, got %v", counts)
+	}
+	hub.unregisterClient(console)
 
 	hub.unregisterClient(client)
 	if hub.GetRoomSize("room-1") != 0 {
@@ -0,0 +1,93 @@
+// Package worlds knows where a Hytale server keeps its world saves and builds
+// the console commands and host scripts that copy and snapshot them.
+//
+// Each world is a directory under Dir in the server's working directory; the
+// one players join is Defaults.World in config.json. Snapshots are gzipped
+// tars written to SnapshotDir, next to the worlds rather than among them.
+package worlds
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Dir holds the worlds, relative to the server's working directory
+const Dir = "universe/worlds"
+
+// SnapshotDir holds world snapshots, relative to the server's working directory
+const SnapshotDir = "world-snapshots"
+
+// Console commands that pause autosave while a running server's world is
+// snapshotted: SaveCommand flushes the world to disk first
+const (
+	SaveOffCommand = "save-off"
+	SaveCommand    = "save-all"
+	SaveOnCommand  = "save-on"
+)
+
+// snapshotStamp is the time format in a snapshot's name
+const snapshotStamp = "20060102-150405"
+
+// name is what a world directory may be called, as config.json accepts it
+var name = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// World is a world save on a server
+type World struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // relative to the server's working directory
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// Active is the world players join
+	Active bool `json:"active"`
+}
+
+// ValidateName checks that a world name is a plain directory name
+func ValidateName(world string) error {
+	if !name.MatchString(world) {
+		return fmt.Errorf("world name %q must be 1-64 letters, digits, '-' or '_'", world)
+	}
+	return nil
+}
+
+// Path returns a world's directory, relative to the server's working directory
+func Path(world string) string {
+	return Dir + "/" + world
+}
+
+// SnapshotPath returns where a snapshot of world taken at t is written,
+// relative to the server's working directory
+func SnapshotPath(world string, t time.Time) string {
+	return fmt.Sprintf("%s/%s-%s.tar.gz", SnapshotDir, world, t.UTC().Format(snapshotStamp))
+}
+
+// SnapshotScript renders the script that tars the world directory src into
+// dest, absolute paths on the host. The archive is written next to dest and
+// renamed into place, so a failed snapshot leaves no partial file.
+func SnapshotScript(src, dest string) string {
+	tmp := quote(dest + ".partial")
+	return fmt.Sprintf("set -e\n"+
+		"mkdir -p %s\n"+
+		"if ! tar -czf %s -C %s %s; then rm -f %s; exit 1; fi\n"+
+		"mv %s %s\n"+
+		"wc -c < %s",
+		quote(path.Dir(dest)), tmp, quote(path.Dir(src)), quote(path.Base(src)), tmp, tmp, quote(dest), quote(dest))
+}
+
+// CopyScript renders the script that copies the world directory src to dest,
+// absolute paths on the host, keeping modes and times. The copy is made next
+// to dest and renamed into place.
+func CopyScript(src, dest string) string {
+	tmp := quote(dest + ".partial")
+	return fmt.Sprintf("set -e\n"+
+		"if ! cp -a %s %s; then rm -rf %s; exit 1; fi\n"+
+		"mv %s %s",
+		quote(src), tmp, tmp, tmp, quote(dest))
+}
+
+// quote single-quotes a value for sh
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
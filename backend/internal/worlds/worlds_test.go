@@ -0,0 +1,82 @@
+package worlds
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateName(t *testing.T) {
+	for _, world := range []string{"default", "Adventure_2", "spawn-eu"} {
+		if err := ValidateName(world); err != nil {
+			t.Errorf("expected %q to be accepted, got %v", world, err)
+		}
+	}
+	for _, world := range []string{"", "..", "a/b", "with space", "default.partial", strings.Repeat("a", 65)} {
+		if err := ValidateName(world); err == nil {
+			t.Errorf("expected %q to be refused", world)
+		}
+	}
+}
+
+func TestSnapshotPath(t *testing.T) {
+	at := time.Date(2026, 10, 18, 9, 30, 5, 0, time.UTC)
+	if got := SnapshotPath("default", at); got != "world-snapshots/default-20261018-093005.tar.gz" {
+		t.Fatalf("unexpected snapshot path %q", got)
+	}
+}
+
+// runScript runs a rendered script with sh, as the host would
+func runScript(t *testing.T, script string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	output, err := exec.Command("sh", "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("script failed: %v\n%s", err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestScripts(t *testing.T) {
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar is not available")
+	}
+	// A quote in the path must not break out of the script's quoting
+	dir := filepath.Join(t.TempDir(), "it's here")
+	src := filepath.Join(dir, "universe", "worlds", "default")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "config.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(dir, "universe", "worlds", "copy")
+	runScript(t, CopyScript(src, dest))
+	if info, err := os.Stat(filepath.Join(dest, "config.json")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the copy to keep config.json and its mode, got %v %v", info, err)
+	}
+	if _, err := os.Stat(dest + ".partial"); !os.IsNotExist(err) {
+		t.Fatalf("expected no partial copy left, got %v", err)
+	}
+
+	archive := filepath.Join(dir, SnapshotDir, "default.tar.gz")
+	size := runScript(t, SnapshotScript(src, archive))
+	info, err := os.Stat(archive)
+	if err != nil {
+		t.Fatalf("expected the snapshot: %v", err)
+	}
+	if size != strconv.FormatInt(info.Size(), 10) {
+		t.Fatalf("expected the snapshot's size %d, got %q", info.Size(), size)
+	}
+	listing, err := exec.Command("tar", "-tzf", archive).Output()
+	if err != nil || !strings.Contains(string(listing), "default/config.json") {
+		t.Fatalf("expected the snapshot to hold default/config.json, got %q %v", listing, err)
+	}
+}
@@ -1,75 +1,225 @@
+// Command create-admin creates a user with the given roles, or adds the roles
+// to an existing user. It uses the manager's config.yaml (CONFIG_PATH) to find
+// the database, so it works with SQLite and PostgreSQL alike, and applies any
+// pending migrations first.
 package main
 
 import (
+	"bufio"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
-	"golang.org/x/crypto/bcrypt"
-	_ "modernc.org/sqlite"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
+	"github.com/TheGojiOG/HytaleSM/internal/config"
+	"github.com/TheGojiOG/HytaleSM/internal/database"
 )
 
 func main() {
 	username := flag.String("username", "admin", "Username to create or promote")
 	email := flag.String("email", "admin@example.com", "Email for new user")
-	password := flag.String("password", "", "Password for new user")
-	dbPath := flag.String("db", "./data/hytale-manager.db", "Path to SQLite database")
+	fullName := flag.String("full-name", "", "Full name for new user")
+	password := flag.String("password", "", "Password for new user (visible in shell history and ps; prefer -password-stdin)")
+	passwordStdin := flag.Bool("password-stdin", false, "Read the password from the first line of stdin")
+	resetPassword := flag.Bool("reset-password", false, "Also set a new password when the user already exists")
+	roles := flag.String("roles", "Admin,ReleaseManager", "Comma-separated role names to assign")
+	org := flag.String("org", "1", "Organization ID or name for a new user and its roles")
+	dbPath := flag.String("db", "", "SQLite database path, overriding config.yaml")
 	flag.Parse()
 
-	if *password == "" {
-		*password = os.Getenv("HSM_ADMIN_PASSWORD")
+	cfg, err := config.Read()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
-	if *password == "" {
-		log.Fatal("Password is required (use -password or set HSM_ADMIN_PASSWORD)")
+	if *dbPath != "" {
+		cfg.Database.Driver = "sqlite"
+		cfg.Database.Path = *dbPath
 	}
 
-	// Open database
-	db, err := sql.Open("sqlite", *dbPath)
+	db, err := database.Open(cfg.Database)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.Close()
-
-	var existingID int64
-	err = db.QueryRow("SELECT id FROM users WHERE username = ?", *username).Scan(&existingID)
-	if err == nil {
-		assignAdminRoles(db, existingID)
-		fmt.Printf("User %s promoted to Admin + ReleaseManager roles.\n", *username)
-		return
+	if err := db.Migrate(); err != nil {
+		log.Fatalf("Failed to apply migrations: %v", err)
 	}
 
-	// Generate password hash
-	hash, err := bcrypt.GenerateFromPassword([]byte(*password), 12)
+	orgID, err := resolveOrganization(db.DB, *org)
 	if err != nil {
 		log.Fatal(err)
 	}
+	roleIDs, err := resolveRoles(db.DB, orgID, splitList(*roles))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var userID int64
+	err = db.QueryRow("SELECT id FROM users WHERE username = ?", *username).Scan(&userID)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		log.Fatalf("Failed to look up user: %v", err)
+	}
+
+	var hash string
+	if !exists || *resetPassword {
+		secret, err := readPassword(*password, *passwordStdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		passwords := auth.PasswordHasher{
+			Algorithm:  cfg.Auth.PasswordHash,
+			BcryptCost: cfg.Auth.BcryptCost,
+			Argon2:     auth.Argon2Params(cfg.Auth.Argon2),
+		}
+		if hash, err = passwords.Hash(secret); err != nil {
+			log.Fatalf("Failed to hash password: %v", err)
+		}
+	}
 
-	// Insert admin user
-	result, err := db.Exec(`
-		INSERT INTO users (username, email, password_hash, is_active)
-		VALUES (?, ?, ?, 1)
-	`, *username, *email, string(hash))
+	tx, err := db.Begin()
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer tx.Rollback()
 
-	userID, _ := result.LastInsertId()
+	if !exists {
+		result, err := tx.Exec(`
+			INSERT INTO users (organization_id, username, email, full_name, password_hash, is_active)
+			VALUES (?, ?, ?, ?, ?, 1)
+		`, orgID, *username, *email, *fullName, hash)
+		if err != nil {
+			log.Fatalf("Failed to create user: %v", err)
+		}
+		if userID, err = result.LastInsertId(); err != nil {
+			log.Fatalf("Failed to read user ID: %v", err)
+		}
+	} else if hash != "" {
+		if _, err := tx.Exec(`UPDATE users SET password_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, hash, userID); err != nil {
+			log.Fatalf("Failed to update password: %v", err)
+		}
+	}
 
-	assignAdminRoles(db, userID)
+	for _, roleID := range roleIDs {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO user_roles (user_id, role_id) VALUES (?, ?)`, userID, roleID); err != nil {
+			log.Fatalf("Failed to assign roles: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Printf("Admin user created successfully!\n")
-	fmt.Printf("Username: %s\n", *username)
+	if exists {
+		fmt.Printf("User %s now has roles: %s\n", *username, strings.Join(splitList(*roles), ", "))
+		if hash != "" {
+			fmt.Println("Password updated.")
+		}
+		return
+	}
+	fmt.Printf("User %s created with roles: %s\n", *username, strings.Join(splitList(*roles), ", "))
 	fmt.Printf("\nIMPORTANT: Change this password after first login!\n")
 }
 
-func assignAdminRoles(db *sql.DB, userID int64) {
-	_, err := db.Exec(`
-		INSERT OR IGNORE INTO user_roles (user_id, role_id)
-		SELECT ?, id FROM roles WHERE name IN ('Admin', 'ReleaseManager')
-	`, userID)
+// readPassword takes the password from, in order: -password, -password-stdin,
+// HSM_ADMIN_PASSWORD, or an interactive prompt when stdin is a terminal
+func readPassword(flagValue string, fromStdin bool) (string, error) {
+	if flagValue != "" {
+		fmt.Fprintln(os.Stderr, "Warning: -password is visible in shell history; prefer -password-stdin")
+		return flagValue, nil
+	}
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read password from stdin: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return "", errors.New("no password on stdin")
+		}
+		return line, nil
+	}
+	if env := os.Getenv("HSM_ADMIN_PASSWORD"); env != "" {
+		return env, nil
+	}
+
+	first, err := promptPassword("Password: ")
+	if err != nil {
+		return "", fmt.Errorf("password is required (use -password-stdin or set HSM_ADMIN_PASSWORD): %w", err)
+	}
+	if first == "" {
+		return "", errors.New("password must not be empty")
+	}
+	second, err := promptPassword("Confirm password: ")
+	if err != nil {
+		return "", err
+	}
+	if first != second {
+		return "", errors.New("passwords do not match")
+	}
+	return first, nil
+}
+
+func resolveOrganization(db *sql.DB, value string) (int64, error) {
+	var orgID int64
+	var err error
+	if id, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil {
+		err = db.QueryRow("SELECT id FROM organizations WHERE id = ?", id).Scan(&orgID)
+	} else {
+		err = db.QueryRow("SELECT id FROM organizations WHERE name = ?", value).Scan(&orgID)
+	}
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("organization %q not found", value)
+	}
+	return orgID, err
+}
+
+func resolveRoles(db *sql.DB, orgID int64, names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return nil, errors.New("at least one role is required")
+	}
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		var id int64
+		err := db.QueryRow("SELECT id FROM roles WHERE organization_id = ? AND name = ?", orgID, name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("role %q not found in organization %d (available: %s)", name, orgID, strings.Join(listRoles(db, orgID), ", "))
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func listRoles(db *sql.DB, orgID int64) []string {
+	rows, err := db.Query("SELECT name FROM roles WHERE organization_id = ? ORDER BY name", orgID)
 	if err != nil {
-		log.Fatal(err)
+		return nil
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+
+package main
+
+import "errors"
+
+// promptPassword is only implemented on Linux and Windows; elsewhere use -password-stdin
+func promptPassword(prompt string) (string, error) {
+	return "", errors.New("interactive password entry is not supported on this platform")
+}
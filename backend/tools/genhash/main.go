@@ -6,11 +6,16 @@ import (
 	"log"
 	"os"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/TheGojiOG/HytaleSM/internal/auth"
 )
 
 func main() {
 	password := flag.String("password", "", "Password to hash")
+	algorithm := flag.String("algorithm", auth.PasswordHashBcrypt, "Hash algorithm: bcrypt or argon2id")
+	cost := flag.Int("cost", 12, "bcrypt cost")
+	memory := flag.Uint("memory", uint(auth.DefaultArgon2Params.Memory), "argon2id memory in KiB")
+	iterations := flag.Uint("iterations", uint(auth.DefaultArgon2Params.Iterations), "argon2id iterations")
+	parallelism := flag.Uint("parallelism", uint(auth.DefaultArgon2Params.Parallelism), "argon2id parallelism")
 	flag.Parse()
 
 	if *password == "" {
@@ -19,9 +24,19 @@ func main() {
 	if *password == "" {
 		log.Fatal("Password is required (use -password or set HSM_PASSWORD)")
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(*password), 12)
+	if *algorithm != auth.PasswordHashBcrypt && *algorithm != auth.PasswordHashArgon2id {
+		log.Fatalf("Unknown algorithm %q (use bcrypt or argon2id)", *algorithm)
+	}
+
+	params := auth.DefaultArgon2Params
+	params.Memory = uint32(*memory)
+	params.Iterations = uint32(*iterations)
+	params.Parallelism = uint8(*parallelism)
+	hasher := auth.PasswordHasher{Algorithm: *algorithm, BcryptCost: *cost, Argon2: params}
+
+	hash, err := hasher.Hash(*password)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println(string(hash))
+	fmt.Println(hash)
 }
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/TheGojiOG/HytaleSM/internal/api/openapi"
+)
+
+func main() {
+	apiDir := flag.String("api", "internal/api", "Path to the internal/api package")
+	out := flag.String("out", "internal/api/openapi/openapi.json", "Output file")
+	flag.Parse()
+
+	spec, err := openapi.Generate(*apiDir)
+	if err != nil {
+		log.Fatalf("Failed to generate OpenAPI spec: %v", err)
+	}
+	if err := os.WriteFile(*out, spec, 0644); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+	log.Printf("Wrote %s", *out)
+}
@@ -1,11 +1,10 @@
-import { apiClient } from './client';
+import { apiClient, fetchAllPages } from './client';
 import type { Backup, BackupSchedule, CreateBackupRequest, RestoreBackupRequest } from './types';
 
 export const backupsApi = {
   // List backups for a server
   listBackups: async (serverId: string): Promise<Backup[]> => {
-    const response = await apiClient.get<{ backups: Backup[] }>(`/servers/${serverId}/backups`);
-    return response.data.backups;
+    return fetchAllPages<Backup>(`/servers/${serverId}/backups`);
   },
 
   // Get backup details
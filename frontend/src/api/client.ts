@@ -1,5 +1,5 @@
 import axios, { AxiosError } from 'axios';
-import type { ApiError } from './types';
+import type { ApiError, PaginatedResponse } from './types';
 
 // Create axios instance with base configuration
 export const apiClient = axios.create({
@@ -44,11 +44,49 @@ apiClient.interceptors.response.use(
   }
 );
 
+// List endpoints with cursor pagination live under /api/v2
+export const API_V2_BASE_URL = '/api/v2';
+
+// Fetch one page of a v2 listing
+export async function fetchPage<T>(
+  url: string,
+  params?: Record<string, unknown>
+): Promise<PaginatedResponse<T>> {
+  const response = await apiClient.get<PaginatedResponse<T>>(url, { baseURL: API_V2_BASE_URL, params });
+  return response.data;
+}
+
+// Follow next_cursor through a v2 listing until every item is loaded
+export async function fetchAllPages<T>(url: string, params?: Record<string, unknown>): Promise<T[]> {
+  const items: T[] = [];
+  let cursor: string | undefined;
+  do {
+    const page = await fetchPage<T>(url, { ...params, limit: 500, cursor });
+    items.push(...page.data);
+    cursor = page.pagination.next_cursor;
+  } while (cursor);
+  return items;
+}
+
+// Machine-readable error code from either response shape
+export function getErrorCode(error: unknown): string | undefined {
+  if (!axios.isAxiosError(error)) {
+    return undefined;
+  }
+  const data = (error as AxiosError<ApiError>).response?.data;
+  if (typeof data?.error === 'object' && data.error !== null) {
+    return data.error.code;
+  }
+  return data?.code;
+}
+
 // Helper function to extract error message
 export function getErrorMessage(error: unknown): string {
   if (axios.isAxiosError(error)) {
     const axiosError = error as AxiosError<ApiError>;
-    return axiosError.response?.data?.error || axiosError.message || 'An unexpected error occurred';
+    const body = axiosError.response?.data?.error;
+    const message = typeof body === 'object' && body !== null ? body.message : body;
+    return message || axiosError.message || 'An unexpected error occurred';
   }
   if (error instanceof Error) {
     return error.message;
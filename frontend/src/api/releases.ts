@@ -1,4 +1,4 @@
-import { apiClient } from './client';
+import { apiClient, fetchPage } from './client';
 
 export interface Release {
   id: number;
@@ -33,6 +33,12 @@ export interface ReleaseRequest {
   download_path?: string;
 }
 
+export interface ReleaseUploadOptions {
+  version?: string;
+  patchline?: string;
+  overwrite?: boolean;
+}
+
 export interface DownloaderInitRequest {
   force?: boolean;
 }
@@ -50,10 +56,8 @@ export interface DownloaderAuthStatusResponse {
 
 export const releasesApi = {
   listReleases: async (includeRemoved = false): Promise<Release[]> => {
-    const response = await apiClient.get<{ releases: Release[] }>('/releases', {
-      params: includeRemoved ? { include_removed: 'true' } : undefined,
-    });
-    return response.data.releases;
+    const page = await fetchPage<Release>('/releases', includeRemoved ? { include_removed: 'true' } : undefined);
+    return page.data;
   },
 
   getRelease: async (id: number): Promise<Release> => {
@@ -76,6 +80,21 @@ export const releasesApi = {
     return response.data.job;
   },
 
+  uploadRelease: async (file: File, options: ReleaseUploadOptions = {}): Promise<Release> => {
+    const form = new FormData();
+    form.append('file', file);
+    const response = await apiClient.post<Release>('/releases/upload', form, {
+      params: {
+        version: options.version || undefined,
+        patchline: options.patchline || undefined,
+        overwrite: options.overwrite ? 'true' : undefined,
+      },
+      headers: { 'Content-Type': 'multipart/form-data' },
+      timeout: 0,
+    });
+    return response.data;
+  },
+
   initDownloader: async (payload?: DownloaderInitRequest): Promise<ReleaseJob> => {
     const response = await apiClient.post<{ job: ReleaseJob }>('/releases/downloader/init', payload ?? {});
     return response.data.job;
@@ -1,9 +1,10 @@
-import { apiClient } from './client';
-import type { ActivityLogEntry, AgentState, DependenciesCheckResponse, NodeExporterStatus, Server, ServerMetric, ServerStatus } from './types';
+import { apiClient, fetchPage } from './client';
+import type { ActivityLogEntry, AgentState, DependenciesCheckResponse, Deployment, NodeExporterStatus, Server, ServerMetric, ServerStatus, SSHConnectionInfo, SSHHostKeyStatus, SSHKeyRotationResult } from './types';
 
 export interface CreateServerRequest {
   id?: string;
   name: string;
+  version?: number;
   description?: string;
   connection: {
     host: string;
@@ -18,7 +19,7 @@ export interface CreateServerRequest {
     working_directory: string;
     executable: string;
     java_args?: string;
-    process_manager: 'screen' | 'systemd';
+    process_manager: 'screen' | 'tmux' | 'systemd' | 'docker' | 'agent';
   };
   monitoring?: {
     enabled?: boolean;
@@ -76,6 +77,12 @@ export interface StartServerRequest {
   extra_server_args?: string;
 }
 
+export interface SSHHostKeyRequest {
+  action: 'approve' | 'rotate' | 'forget';
+  fingerprint?: string;
+  public_key?: string;
+}
+
 export const serversApi = {
   // List all servers
   listServers: async (): Promise<Server[]> => {
@@ -139,10 +146,8 @@ export const serversApi = {
   },
 
   getMetricsHistory: async (id: string, limit = 50): Promise<ServerMetric[]> => {
-    const response = await apiClient.get<{ metrics: ServerMetric[] }>(`/servers/${id}/metrics`, {
-      params: { limit },
-    });
-    return response.data.metrics;
+    const page = await fetchPage<ServerMetric>(`/servers/${id}/metrics`, { limit });
+    return page.data;
   },
 
   getLatestMetrics: async (): Promise<Record<string, ServerMetric>> => {
@@ -165,6 +170,46 @@ export const serversApi = {
     return response.data;
   },
 
+  getSSHHostKey: async (id: string): Promise<SSHHostKeyStatus> => {
+    const response = await apiClient.get<SSHHostKeyStatus>(`/servers/${id}/ssh/hostkey`);
+    return response.data;
+  },
+
+  updateSSHHostKey: async (id: string, data: SSHHostKeyRequest): Promise<SSHHostKeyStatus> => {
+    const response = await apiClient.post<SSHHostKeyStatus>(`/servers/${id}/ssh/hostkey`, data);
+    return response.data;
+  },
+
+  rotateSSHKey: async (id: string, keepOldKey = false): Promise<SSHKeyRotationResult> => {
+    const response = await apiClient.post<SSHKeyRotationResult>(`/servers/${id}/ssh/rotate-key`, { keep_old_key: keepOldKey });
+    return response.data;
+  },
+
+  getSSHConnection: async (id: string): Promise<SSHConnectionInfo> => {
+    const response = await apiClient.get<SSHConnectionInfo>(`/servers/${id}/ssh/connection`);
+    return response.data;
+  },
+
+  closeSSHConnection: async (id: string): Promise<SSHConnectionInfo> => {
+    const response = await apiClient.post<SSHConnectionInfo>(`/servers/${id}/ssh/connection/close`);
+    return response.data;
+  },
+
+  reconnectSSHConnection: async (id: string): Promise<SSHConnectionInfo> => {
+    const response = await apiClient.post<SSHConnectionInfo>(`/servers/${id}/ssh/connection/reconnect`);
+    return response.data;
+  },
+
+  listDeployments: async (id: string, limit = 10): Promise<Deployment[]> => {
+    const response = await apiClient.get<{ deployments: Deployment[] }>(`/servers/${id}/deployments`, { params: { limit } });
+    return response.data.deployments;
+  },
+
+  rollbackRelease: async (id: string): Promise<{ message: string; from: Deployment; to: Deployment }> => {
+    const response = await apiClient.post<{ message: string; from: Deployment; to: Deployment }>(`/servers/${id}/deploy/rollback`);
+    return response.data;
+  },
+
   checkDependencies: async (id: string): Promise<DependenciesCheckResponse> => {
     const response = await apiClient.get<DependenciesCheckResponse>(`/servers/${id}/dependencies/check`);
     return response.data;
@@ -180,9 +225,7 @@ export const serversApi = {
   },
 
   getServerActivity: async (id: string, limit = 50, type?: string): Promise<ActivityLogEntry[]> => {
-    const response = await apiClient.get<{ activities: ActivityLogEntry[] }>(`/servers/${id}/activity`, {
-      params: { limit, type },
-    });
-    return response.data.activities;
+    const page = await fetchPage<ActivityLogEntry>(`/servers/${id}/activity`, { limit, type });
+    return page.data;
   },
 };
@@ -28,9 +28,19 @@ export interface AppSettings {
     default_interval: number;
     retention_days: number;
   };
+  features?: Record<string, boolean>;
   requires_restart?: boolean;
 }
 
+export interface FeatureFlag {
+  name: string;
+  description: string;
+  default: boolean;
+  requires_restart: boolean;
+  enabled: boolean;
+  source: 'default' | 'config';
+}
+
 export const settingsApi = {
   getSettings: async (): Promise<AppSettings> => {
     const response = await apiClient.get<AppSettings>('/settings');
@@ -41,4 +51,9 @@ export const settingsApi = {
     const response = await apiClient.put<AppSettings>('/settings', settings);
     return response.data;
   },
+
+  getFeatures: async (): Promise<FeatureFlag[]> => {
+    const response = await apiClient.get<{ features: FeatureFlag[] }>('/system/features');
+    return response.data.features;
+  },
 };
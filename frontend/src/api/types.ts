@@ -44,6 +44,7 @@ export interface AuthResponse {
 export interface Server {
   id: string;
   name: string;
+  version?: number;
   description?: string;
   host?: string;
   port?: number;
@@ -66,9 +67,22 @@ export interface Server {
     working_directory: string;
     executable: string;
     java_args?: string;
-    process_manager: 'screen' | 'systemd';
+    process_manager: 'screen' | 'tmux' | 'systemd' | 'docker' | 'agent';
     screen_session_name?: string;
     systemd_service_name?: string;
+    docker?: {
+      image?: string;
+      network?: string;
+      ports?: string[];
+      volumes?: string[];
+      env?: Record<string, string>;
+      extra_args?: string[];
+      user?: string;
+      host?: string;
+      tls_ca_cert?: string;
+      tls_cert?: string;
+      tls_key?: string;
+    };
   };
   monitoring?: {
     enabled?: boolean;
@@ -115,6 +129,8 @@ export interface Server {
     assets_path?: string;
     extra_java_args?: string;
     extra_server_args?: string;
+    memory_limit?: string;
+    cpu_limit?: string;
   };
   status?: ServerStatus;
   tags?: string[];
@@ -191,9 +207,77 @@ export interface NodeExporterStatus {
   enabled?: boolean;
   version?: string;
   url?: string;
+  // host metrics are read by the manager itself, without node_exporter
+  local?: boolean;
   output?: string;
 }
 
+export interface SSHHostKey {
+  type: string;
+  fingerprint: string;
+  public_key: string;
+  seen_at: string;
+  approved_at?: string;
+  approved_by?: string;
+}
+
+export interface SSHHostKeyStatus {
+  server_id: string;
+  host: string;
+  port: number;
+  local: boolean;
+  trusted: SSHHostKey | null;
+  // last key the host offered that is not trusted
+  pending: SSHHostKey | null;
+}
+
+export interface SSHLoginKey {
+  id: number;
+  fingerprint: string;
+  public_key: string;
+  created_at: string;
+  retired_at?: string;
+}
+
+export interface SSHKeyRotationResult {
+  key: SSHLoginKey;
+  retired_fingerprint?: string;
+  // the new key is in use but the old one is still in authorized_keys
+  retire_error?: string;
+  history: SSHLoginKey[];
+}
+
+export interface SSHConnectionInfo {
+  server_id: string;
+  // an open connection is in the manager's pool
+  pooled: boolean;
+  health_status: 'healthy' | 'degraded' | 'failed' | 'closed' | string;
+  reconnect_attempts: number;
+  connected_at?: string;
+  last_activity?: string;
+  last_health_check?: string;
+}
+
+export type DeploymentStatus = 'deploying' | 'active' | 'superseded' | 'rolled_back' | 'failed' | 'pruned';
+
+export interface Deployment {
+  id: number;
+  server_id: string;
+  package_name: string;
+  sha256?: string;
+  install_dir: string;
+  // directory under <install_dir>/releases
+  release_dir: string;
+  service_user: string;
+  use_sudo: boolean;
+  status: DeploymentStatus;
+  error?: string;
+  deployed_by?: number;
+  deployed_at: string;
+  finished_at?: string;
+  rolled_back_at?: string;
+}
+
 export interface DependenciesCheckResponse {
   java_ok: boolean;
   java_line: string;
@@ -305,14 +389,28 @@ export interface ConsoleMessage {
   timestamp: string;
 }
 
+// v1 routes send the message in `error`; v2 routes nest the whole error under it
+export interface ApiErrorBody {
+  code: string;
+  message: string;
+  details?: unknown;
+  request_id?: string;
+}
+
 export interface ApiError {
-  error: string;
+  error: string | ApiErrorBody;
+  code?: string;
   details?: string;
+  request_id?: string;
+}
+
+export interface PageInfo {
+  limit: number;
+  total: number;
+  next_cursor?: string;
 }
 
 export interface PaginatedResponse<T> {
   data: T[];
-  total: number;
-  page: number;
-  per_page: number;
+  pagination: PageInfo;
 }
@@ -1,9 +1,8 @@
-import { apiClient } from './client';
+import { apiClient, fetchAllPages } from './client';
 import type { UserWithRoles } from './types';
 
 export async function listUsers(): Promise<UserWithRoles[]> {
-  const response = await apiClient.get<UserWithRoles[]>('/users');
-  return response.data;
+  return fetchAllPages<UserWithRoles>('/users');
 }
 
 export interface CreateUserPayload {
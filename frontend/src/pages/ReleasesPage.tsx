@@ -30,6 +30,10 @@ export function ReleasesPage() {
   const [actionNotice, setActionNotice] = useState<string | null>(null);
   const [downloaderMissing, setDownloaderMissing] = useState(false);
   const [showRemoved, setShowRemoved] = useState(false);
+  const [uploadFile, setUploadFile] = useState<File | null>(null);
+  const [uploadVersion, setUploadVersion] = useState('');
+  const [uploadOverwrite, setUploadOverwrite] = useState(false);
+  const [uploading, setUploading] = useState(false);
   const jobSocketRef = useRef<WebSocket | null>(null);
   const logsRef = useRef<HTMLDivElement | null>(null);
   const [downloaderAuth, setDownloaderAuth] = useState<{ exists: boolean; expiresAt?: number; branch?: string }>({
@@ -362,6 +366,40 @@ export function ReleasesPage() {
     }
   };
 
+  const handleUpload = async () => {
+    if (!uploadFile) {
+      return;
+    }
+    setActionError(null);
+    setActionNotice(null);
+    setUploading(true);
+    try {
+      const release = await releasesApi.uploadRelease(uploadFile, {
+        version: uploadVersion.trim(),
+        overwrite: uploadOverwrite,
+      });
+      setActionNotice(`Uploaded release ${release.version} (${formatBytes(release.file_size)}).`);
+      setUploadFile(null);
+      setUploadVersion('');
+      await queryClient.invalidateQueries({ queryKey: ['releases'] });
+    } catch (err) {
+      setActionError(getErrorMessage(err));
+    } finally {
+      setUploading(false);
+    }
+  };
+
+  const sourceLabel = (release: Release | undefined) => {
+    switch (release?.source) {
+      case 'user_added':
+        return 'Discovered';
+      case 'uploaded':
+        return 'Uploaded';
+      default:
+        return 'Downloaded';
+    }
+  };
+
   const getPackageName = (release: Release | undefined) => {
     if (!release?.file_path) {
       return '';
@@ -525,7 +563,7 @@ export function ReleasesPage() {
               <p className="text-white font-medium break-all">{latestRelease?.sha256}</p>
             </div>
             <div>
-              <p className="text-neutral-400">{latestRelease?.source === 'user_added' ? 'Discovered' : 'Downloaded'}</p>
+              <p className="text-neutral-400">{sourceLabel(latestRelease)}</p>
               <p className="text-white font-medium">{renderDateLabel(latestRelease)}</p>
             </div>
             <div className="md:col-span-2">
@@ -590,7 +628,7 @@ export function ReleasesPage() {
                       </td>
                       <td className="px-3 py-2 break-all">{release.sha256}</td>
                       <td className="px-3 py-2">
-                        {release.source === 'user_added' ? 'Discovered' : 'Downloaded'} {renderDateLabel(release)}
+                        {sourceLabel(release)} {renderDateLabel(release)}
                       </td>
                       <td className="px-3 py-2 text-right">
                         <Button variant="danger" size="sm" onClick={() => handleDeleteRelease(release)}>
@@ -631,6 +669,38 @@ export function ReleasesPage() {
         </CardContent>
       </Card>
 
+      <Card>
+        <CardHeader>
+          <CardTitle>Upload Release</CardTitle>
+          <CardDescription>Add your own server package: a .zip with Server/HytaleServer.jar.</CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-4">
+          <input
+            type="file"
+            accept=".zip,application/zip"
+            onChange={(event) => setUploadFile(event.target.files?.[0] ?? null)}
+            className="block text-sm text-neutral-300"
+          />
+          <Input
+            label="Version"
+            value={uploadVersion}
+            onChange={(event) => setUploadVersion(event.target.value)}
+            placeholder="File name without .zip"
+          />
+          <label className="flex items-center gap-2 text-sm text-neutral-300">
+            <input
+              type="checkbox"
+              checked={uploadOverwrite}
+              onChange={(event) => setUploadOverwrite(event.target.checked)}
+            />
+            Replace a release with the same version
+          </label>
+          <Button variant="primary" onClick={handleUpload} disabled={!uploadFile || uploading}>
+            {uploading ? 'Uploading...' : 'Upload'}
+          </Button>
+        </CardContent>
+      </Card>
+
       {activeJob && (
         <Card>
           <CardHeader>
@@ -2,7 +2,7 @@ import { useEffect, useMemo, useRef, useState, type Dispatch, type SetStateActio
 import { useParams, Link } from 'react-router-dom';
 import { useQuery, useQueryClient } from '@tanstack/react-query';
 import { releasesApi, serversApi } from '@/api';
-import type { ActivityLogEntry, AgentState, DependenciesCheckResponse, NodeExporterStatus, Server as ServerType, ServerMetric, ServerStatus } from '@/api/types';
+import type { ActivityLogEntry, AgentState, DependenciesCheckResponse, Deployment, NodeExporterStatus, Server as ServerType, ServerMetric, ServerStatus, SSHConnectionInfo, SSHHostKeyStatus, SSHKeyRotationResult } from '@/api/types';
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/Card';
 import { Input } from '@/components/Input';
 import { Button } from '@/components/Button';
@@ -42,6 +42,9 @@ export function ServerDetailPage() {
     expanded: boolean;
     visible: boolean;
   }>({ installing: false, outputLines: [], expanded: false, visible: false });
+  const [hostKeyState, setHostKeyState] = useState<{ action?: 'approve' | 'rotate' | 'forget'; error?: string }>({});
+  const [keyRotation, setKeyRotation] = useState<{ loading: boolean; result?: SSHKeyRotationResult; error?: string }>({ loading: false });
+  const [connectionAction, setConnectionAction] = useState<{ action?: 'close' | 'reconnect'; error?: string }>({});
   const [depsCheck, setDepsCheck] = useState<{ loading: boolean; result?: DependenciesCheckResponse; error?: string }>({ loading: false });
   const [deployState, setDeployState] = useState<{
     deploying: boolean;
@@ -102,6 +105,8 @@ export function ServerDetailPage() {
   const deployAbortRef = useRef<AbortController | null>(null);
   const benchmarkAbortRef = useRef<AbortController | null>(null);
   const serverStreamSocketRef = useRef<WebSocket | null>(null);
+  // Number of the last task output line received, to resume after a reconnect
+  const taskSeqRef = useRef(0);
   const [depsOptions, setDepsOptions] = useState({
     skip_update: false,
     use_sudo: true,
@@ -191,16 +196,18 @@ export function ServerDetailPage() {
   const applyTaskSnapshot = (task: {
     id: string;
     task: string;
-    status: 'running' | 'complete' | 'failed';
+    status: 'queued' | 'running' | 'complete' | 'failed' | 'interrupted';
     last_line?: string;
     error?: string;
   }) => {
+    const active = task.status === 'queued' || task.status === 'running';
+    const failed = task.status === 'failed' || task.status === 'interrupted';
     if (task.task === 'transfer-benchmark') {
       setBenchmarkState((prev) => ({
         ...prev,
-        running: task.status === 'running',
+        running: active,
         visible: true,
-        error: task.status === 'failed' ? task.error || prev.error : prev.error,
+        error: failed ? task.error || prev.error : prev.error,
         currentLine: task.last_line || prev.currentLine,
       }));
       return;
@@ -208,9 +215,9 @@ export function ServerDetailPage() {
     if (task.task === 'dependencies-install') {
       setDepsState((prev) => ({
         ...prev,
-        installing: task.status === 'running',
+        installing: active,
         visible: true,
-        error: task.status === 'failed' ? task.error || prev.error : prev.error,
+        error: failed ? task.error || prev.error : prev.error,
         currentLine: task.last_line || prev.currentLine,
       }));
       return;
@@ -218,19 +225,19 @@ export function ServerDetailPage() {
     if (task.task === 'agent-install') {
       setAgentInstallState((prev) => ({
         ...prev,
-        installing: task.status === 'running',
+        installing: active,
         visible: true,
-        error: task.status === 'failed' ? task.error || prev.error : prev.error,
+        error: failed ? task.error || prev.error : prev.error,
         currentLine: task.last_line || prev.currentLine,
       }));
       return;
     }
-    if (task.task === 'release-deploy') {
+    if (task.task === 'release-deploy' || task.task === 'release-rollback') {
       setDeployState((prev) => ({
         ...prev,
-        deploying: task.status === 'running',
+        deploying: active,
         visible: true,
-        error: task.status === 'failed' ? task.error || prev.error : prev.error,
+        error: failed ? task.error || prev.error : prev.error,
         currentLine: task.last_line || prev.currentLine,
       }));
       return;
@@ -238,9 +245,9 @@ export function ServerDetailPage() {
     if (task.task === 'node-exporter-install') {
       setNodeExporterState((prev) => ({
         ...prev,
-        installing: task.status === 'running',
+        installing: active,
         visible: true,
-        error: task.status === 'failed' ? task.error || prev.error : prev.error,
+        error: failed ? task.error || prev.error : prev.error,
         currentLine: task.last_line || prev.currentLine,
       }));
     }
@@ -304,7 +311,8 @@ export function ServerDetailPage() {
       return;
     }
 
-    const wsUrl = buildWsUrl(`/api/v1/ws/servers/${serverId}/tasks`);
+    const since = taskSeqRef.current > 0 ? `?since=${taskSeqRef.current}` : '';
+    const wsUrl = buildWsUrl(`/api/v1/ws/servers/${serverId}/tasks${since}`);
     const socket = new WebSocket(wsUrl);
     serverStreamSocketRef.current = socket;
 
@@ -372,9 +380,28 @@ export function ServerDetailPage() {
             ...prev,
             deploying: false,
           }));
+          void queryClient.invalidateQueries({ queryKey: ['deployments', serverId] });
         }
         return;
       }
+      if (task === 'release-rollback') {
+        appendStreamLines(setDeployState, [line]);
+        if (line.startsWith('Rollback failed:') || line.startsWith('Restart failed:')) {
+          setDeployState((prev) => ({
+            ...prev,
+            deploying: false,
+            error: line,
+          }));
+        }
+        if (line.startsWith('Rollback complete.')) {
+          setDeployState((prev) => ({
+            ...prev,
+            deploying: false,
+          }));
+        }
+        void queryClient.invalidateQueries({ queryKey: ['deployments', serverId] });
+        return;
+      }
       if (task === 'node-exporter-install') {
         appendStreamLines(setNodeExporterState, [line]);
         if (line.startsWith('Install failed:')) {
@@ -408,6 +435,13 @@ export function ServerDetailPage() {
             if (typeof parsed.payload?.task !== 'string' || typeof parsed.payload?.line !== 'string') {
               continue;
             }
+            const seq = typeof parsed.payload.seq === 'number' ? parsed.payload.seq : 0;
+            if (seq > 0) {
+              if (seq <= taskSeqRef.current) {
+                continue;
+              }
+              taskSeqRef.current = seq;
+            }
             handleTaskOutput(parsed.payload.task, parsed.payload.line);
             continue;
           }
@@ -483,6 +517,7 @@ export function ServerDetailPage() {
     return () => {
       serverStreamSocketRef.current?.close();
       serverStreamSocketRef.current = null;
+      taskSeqRef.current = 0;
     };
   }, [serverId]);
 
@@ -497,6 +532,14 @@ export function ServerDetailPage() {
     queryFn: () => releasesApi.listReleases(false),
   });
 
+  const { data: deployments } = useQuery<Deployment[]>({
+    queryKey: ['deployments', serverId],
+    queryFn: () => serversApi.listDeployments(serverId || '', 5),
+    enabled: Boolean(serverId),
+    retry: false,
+  });
+  const canRollback = Boolean(deployments?.some((deployment) => deployment.status === 'superseded'));
+
   useEffect(() => {
     if (!server?.dependencies?.configured) {
       return;
@@ -614,6 +657,21 @@ export function ServerDetailPage() {
     retry: false,
   });
 
+  const { data: hostKey, refetch: refetchHostKey } = useQuery<SSHHostKeyStatus>({
+    queryKey: ['ssh-host-key', serverId],
+    queryFn: () => serversApi.getSSHHostKey(serverId || ''),
+    enabled: Boolean(serverId),
+    retry: false,
+  });
+
+  const { data: sshConnection } = useQuery<SSHConnectionInfo>({
+    queryKey: ['ssh-connection', serverId],
+    queryFn: () => serversApi.getSSHConnection(serverId || ''),
+    enabled: Boolean(serverId),
+    refetchInterval: 30000,
+    retry: false,
+  });
+
   const { data: activityLog, isFetching: activityLoading } = useQuery<ActivityLogEntry[]>({
     queryKey: ['server-activity', serverId],
     queryFn: () => serversApi.getServerActivity(serverId || '', 25),
@@ -642,13 +700,13 @@ export function ServerDetailPage() {
   const username = useMemo(() => server?.connection?.username ?? server?.ssh_user ?? 'unknown', [server]);
 
   const getErrorMessage = (err: unknown, fallback: string) => {
-    const maybe = err as { response?: { data?: { error?: string; details?: string } } };
+    const maybe = err as { response?: { data?: { error?: string; details?: unknown } } };
     const error = maybe?.response?.data?.error;
     const details = maybe?.response?.data?.details;
-    if (error && details) {
+    if (error && typeof details === 'string') {
       return `${error}: ${details}`;
     }
-    return error ?? details ?? fallback;
+    return error ?? (typeof details === 'string' ? details : undefined) ?? fallback;
   };
 
   const getAgentErrorDetails = (err: unknown) => {
@@ -742,6 +800,7 @@ export function ServerDetailPage() {
       const updatePayload: Partial<ServerType> = {
         id: server.id,
         name: server.name,
+        version: server.version,
         description: server.description,
         connection: server.connection,
         server: server.server,
@@ -754,6 +813,7 @@ export function ServerDetailPage() {
           use_sudo: runtimeOptions.use_sudo,
         },
         runtime: {
+          ...server.runtime,
           java_xms: runtimeOptions.java_xms,
           java_xmx: runtimeOptions.java_xmx,
           java_metaspace: runtimeOptions.java_metaspace,
@@ -823,6 +883,66 @@ export function ServerDetailPage() {
     }
   };
 
+  const updateHostKey = async (action: 'approve' | 'rotate' | 'forget') => {
+    if (!serverId) {
+      return;
+    }
+    if (action === 'approve' && !window.confirm(`Trust host key ${hostKey?.pending?.fingerprint} for this server?`)) {
+      return;
+    }
+    if (action === 'forget' && !window.confirm('Forget the SSH host keys of this server? known_hosts decides the next connection.')) {
+      return;
+    }
+
+    setHostKeyState({ action });
+    try {
+      const result = await serversApi.updateSSHHostKey(serverId, {
+        action,
+        fingerprint: action === 'approve' ? hostKey?.pending?.fingerprint : undefined,
+      });
+      queryClient.setQueryData(['ssh-host-key', serverId], result);
+      setHostKeyState({});
+    } catch (err: unknown) {
+      setHostKeyState({ error: getErrorMessage(err, 'Failed to update the SSH host key.') });
+      void refetchHostKey();
+    }
+  };
+
+  const rotateSSHKey = async () => {
+    if (!serverId) {
+      return;
+    }
+    if (!window.confirm('Generate a new SSH key for this server and remove the current one from its authorized_keys?')) {
+      return;
+    }
+
+    setKeyRotation({ loading: true });
+    try {
+      const result = await serversApi.rotateSSHKey(serverId);
+      setKeyRotation({ loading: false, result });
+      void queryClient.invalidateQueries({ queryKey: ['server', serverId] });
+    } catch (err: unknown) {
+      setKeyRotation({ loading: false, error: getErrorMessage(err, 'Key rotation failed.') });
+    }
+  };
+
+  const updateSSHConnection = async (action: 'close' | 'reconnect') => {
+    if (!serverId) {
+      return;
+    }
+
+    setConnectionAction({ action });
+    try {
+      const result = action === 'close'
+        ? await serversApi.closeSSHConnection(serverId)
+        : await serversApi.reconnectSSHConnection(serverId);
+      queryClient.setQueryData(['ssh-connection', serverId], result);
+      setConnectionAction({});
+    } catch (err: unknown) {
+      setConnectionAction({ error: getErrorMessage(err, action === 'close' ? 'Failed to close the connection.' : 'Reconnect failed.') });
+    }
+  };
+
   const installNodeExporter = async () => {
     if (!serverId) {
       return;
@@ -1141,6 +1261,34 @@ export function ServerDetailPage() {
     deployAbortRef.current?.abort();
   };
 
+  const rollbackRelease = async () => {
+    if (!serverId) {
+      return;
+    }
+    if (!window.confirm('Switch this server back to its previous release and restart it?')) {
+      return;
+    }
+
+    setDeployState({
+      deploying: true,
+      error: undefined,
+      outputLines: [],
+      currentLine: 'Starting rollback...',
+      expanded: false,
+      visible: true,
+    });
+    try {
+      ensureServerStreamSocket();
+      await serversApi.rollbackRelease(serverId);
+    } catch (err: unknown) {
+      setDeployState((prev) => ({
+        ...prev,
+        deploying: false,
+        error: getErrorMessage(err, 'Rollback failed.'),
+      }));
+    }
+  };
+
   const runTransferBenchmark = async () => {
     if (!serverId) {
       return;
@@ -1278,6 +1426,64 @@ export function ServerDetailPage() {
             <p className="text-neutral-400">Username</p>
             <p className="text-white font-medium">{username}</p>
           </div>
+          {sshConnection && (
+            <div className="md:col-span-3 flex flex-wrap items-center gap-3">
+              <p className="text-neutral-400">
+                Pool: <span className="text-white font-medium">{sshConnection.pooled ? sshConnection.health_status : 'not connected'}</span>
+                {sshConnection.reconnect_attempts > 0 && ` · ${sshConnection.reconnect_attempts} reconnect attempt(s)`}
+                {sshConnection.last_activity && ` · last activity ${formatRelativeTime(sshConnection.last_activity)}`}
+              </p>
+              <Button
+                variant="secondary"
+                size="sm"
+                onClick={() => updateSSHConnection('reconnect')}
+                isLoading={connectionAction.action === 'reconnect'}
+                disabled={Boolean(connectionAction.action)}
+              >
+                Reconnect
+              </Button>
+              {sshConnection.pooled && (
+                <Button
+                  variant="secondary"
+                  size="sm"
+                  onClick={() => updateSSHConnection('close')}
+                  isLoading={connectionAction.action === 'close'}
+                  disabled={Boolean(connectionAction.action)}
+                >
+                  Close
+                </Button>
+              )}
+              {connectionAction.error && (
+                <span className="text-red-400">{connectionAction.error}</span>
+              )}
+            </div>
+          )}
+          {server.connection?.auth_method === 'key' && hostKey && !hostKey.local && (
+            <div className="md:col-span-3 space-y-2">
+              <Button variant="secondary" size="sm" onClick={rotateSSHKey} isLoading={keyRotation.loading}>
+                Rotate SSH Key
+              </Button>
+              {keyRotation.error && (
+                <div className="text-red-400">{keyRotation.error}</div>
+              )}
+              {keyRotation.result && (
+                <div className="space-y-1">
+                  <p className="text-white">New key {keyRotation.result.key.fingerprint}</p>
+                  {keyRotation.result.retired_fingerprint && !keyRotation.result.retire_error && (
+                    <p className="text-neutral-400">Removed {keyRotation.result.retired_fingerprint} from the host.</p>
+                  )}
+                  {keyRotation.result.retire_error && (
+                    <p className="text-yellow-400">
+                      The old key {keyRotation.result.retired_fingerprint} is still authorized: {keyRotation.result.retire_error}
+                    </p>
+                  )}
+                  <p className="text-xs text-neutral-500">
+                    {keyRotation.result.history.filter((key) => key.retired_at).length} retired key(s) on record.
+                  </p>
+                </div>
+              )}
+            </div>
+          )}
         </CardContent>
       </Card>
 
@@ -1556,6 +1762,60 @@ export function ServerDetailPage() {
         </CardContent>
       </Card>
 
+      {hostKey && !hostKey.local && (
+        <Card>
+          <CardHeader>
+            <CardTitle>SSH Host Key</CardTitle>
+            <CardDescription>The host key this server must offer. Connections are refused when the host offers another key.</CardDescription>
+          </CardHeader>
+          <CardContent className="space-y-4">
+            <div className="flex flex-wrap gap-2">
+              <Button variant="secondary" size="sm" onClick={() => updateHostKey('rotate')} isLoading={hostKeyState.action === 'rotate'}>
+                Read Key from Host
+              </Button>
+              {hostKey.trusted && (
+                <Button variant="ghost" size="sm" onClick={() => updateHostKey('forget')} isLoading={hostKeyState.action === 'forget'}>
+                  Forget
+                </Button>
+              )}
+            </div>
+
+            {hostKeyState.error && (
+              <div className="text-sm text-red-400">{hostKeyState.error}</div>
+            )}
+
+            <div className="grid grid-cols-1 md:grid-cols-2 gap-4 text-sm">
+              <div>
+                <p className="text-neutral-400">Trusted key</p>
+                {hostKey.trusted ? (
+                  <>
+                    <p className="text-white font-medium break-all">{hostKey.trusted.type} {hostKey.trusted.fingerprint}</p>
+                    <p className="text-xs text-neutral-500">
+                      Approved {hostKey.trusted.approved_at ? formatRelativeTime(hostKey.trusted.approved_at) : 'n/a'}
+                      {hostKey.trusted.approved_by ? ` by ${hostKey.trusted.approved_by}` : ''}, last seen {formatRelativeTime(hostKey.trusted.seen_at)}
+                    </p>
+                  </>
+                ) : (
+                  <p className="text-white font-medium">None yet; known_hosts decides the next connection.</p>
+                )}
+              </div>
+              {hostKey.pending && (
+                <div>
+                  <p className="text-neutral-400">{hostKey.trusted ? 'Offered key (does not match)' : 'Offered key (not trusted)'}</p>
+                  <p className={`font-medium break-all ${hostKey.trusted ? 'text-red-400' : 'text-yellow-400'}`}>
+                    {hostKey.pending.type} {hostKey.pending.fingerprint}
+                  </p>
+                  <p className="text-xs text-neutral-500">Seen {formatRelativeTime(hostKey.pending.seen_at)}</p>
+                  <Button className="mt-2" variant="primary" size="sm" onClick={() => updateHostKey('approve')} isLoading={hostKeyState.action === 'approve'}>
+                    Approve
+                  </Button>
+                </div>
+              )}
+            </div>
+          </CardContent>
+        </Card>
+      )}
+
       <Card>
         <CardHeader>
           <CardTitle>Live Metrics</CardTitle>
@@ -1641,6 +1901,12 @@ export function ServerDetailPage() {
             <div className="text-sm text-red-400">Failed to load status.</div>
           )}
 
+          {nodeExporterStatus?.local && (
+            <div className="text-sm text-neutral-400">
+              This server runs on the manager&apos;s host, so host metrics are read directly and node_exporter is not needed.
+            </div>
+          )}
+
           {nodeExporterStatus ? (
             <div className="grid grid-cols-1 md:grid-cols-4 gap-4 text-sm">
               <div>
@@ -2121,6 +2387,14 @@ export function ServerDetailPage() {
             >
               Cancel
             </Button>
+            <Button
+              variant="secondary"
+              size="sm"
+              disabled={deployState.deploying || !canRollback}
+              onClick={rollbackRelease}
+            >
+              Roll Back
+            </Button>
             <Button
               variant="secondary"
               size="sm"
@@ -2236,6 +2510,24 @@ export function ServerDetailPage() {
             </div>
           )}
 
+          {deployments && deployments.length > 0 && (
+            <div className="space-y-1 text-sm">
+              <p className="text-neutral-400">Recent deployments</p>
+              {deployments.map((deployment) => (
+                <div key={deployment.id} className="flex flex-wrap items-center gap-2">
+                  <span className="text-white font-medium">{deployment.package_name}</span>
+                  <span className={deployment.status === 'active' ? 'text-emerald-400' : deployment.status === 'failed' ? 'text-red-400' : 'text-neutral-400'}>
+                    {deployment.status.replace('_', ' ')}
+                  </span>
+                  <span className="text-xs text-neutral-500">{formatRelativeTime(deployment.deployed_at)}</span>
+                  {deployment.error && (
+                    <span className="text-xs text-red-400">{deployment.error}</span>
+                  )}
+                </div>
+              ))}
+            </div>
+          )}
+
           {deployState.error && (
             <div className="text-sm text-red-400">{deployState.error}</div>
           )}
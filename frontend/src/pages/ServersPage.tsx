@@ -1,7 +1,7 @@
 import { useMemo, useState } from 'react';
 import type { FormEvent } from 'react';
 import { useQuery, useQueryClient } from '@tanstack/react-query';
-import { serversApi } from '@/api';
+import { serversApi, settingsApi } from '@/api';
 import type { CreateServerRequest } from '@/api/servers';
 import type { Server as ServerType } from '@/api/types';
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/Card';
@@ -54,6 +54,12 @@ export function ServersPage() {
     queryFn: serversApi.listServers,
     refetchInterval: 15000, // Refresh every 15 seconds
   });
+  const { data: features } = useQuery({
+    queryKey: ['features'],
+    queryFn: settingsApi.getFeatures,
+    staleTime: 60000,
+  });
+  const processManagersEnabled = Boolean(features?.find((flag) => flag.name === 'process_managers')?.enabled);
   const { data: latestMetrics } = useQuery({
     queryKey: ['servers-latest-metrics'],
     queryFn: serversApi.getLatestMetrics,
@@ -550,12 +556,19 @@ export function ServersPage() {
                           onChange={(event) =>
                             setCreateForm((prev) => ({
                               ...prev,
-                              server: { ...prev.server, process_manager: event.target.value as 'screen' | 'systemd' },
+                              server: { ...prev.server, process_manager: event.target.value as 'screen' | 'tmux' | 'systemd' | 'docker' | 'agent' },
                             }))
                           }
                         >
                           <option value="screen">screen</option>
-                          <option value="systemd">systemd</option>
+                          {processManagersEnabled && (
+                            <>
+                              <option value="tmux">tmux</option>
+                              <option value="systemd">systemd</option>
+                              <option value="docker">docker</option>
+                              <option value="agent">agent</option>
+                            </>
+                          )}
                         </select>
                       </div>
                     </div>
@@ -62,7 +62,11 @@ export function SettingsPage() {
         ...updated,
         requires_restart: updated.requires_restart ?? true,
       });
-      setSavedMessage('Settings saved. Some changes require a backend restart.');
+      setSavedMessage(
+        updated.requires_restart
+          ? 'Settings saved. Some changes require a backend restart.'
+          : 'Settings saved and applied.'
+      );
       setTimeout(() => setSavedMessage(null), 5000);
     },
   });
@@ -352,7 +356,7 @@ export function SettingsPage() {
       )}
       <div className="flex items-center gap-2 text-xs text-neutral-500 mt-6">
         <Settings className="w-4 h-4" />
-        Some settings require a backend restart to take effect.
+        Log level, CORS, rate limit and metrics changes apply immediately. SSH and log file settings require a backend restart.
       </div>
     </div>
   );
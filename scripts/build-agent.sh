@@ -0,0 +1,24 @@
+#!/usr/bin/env bash
+# Cross-compiles hytale-agent for every supported architecture into
+# backend/internal/agentbin/dist so the next backend build embeds them and the
+# manager host does not need a Go toolchain.
+set -euo pipefail
+
+root_dir="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+backend_dir="$root_dir/backend"
+dist_dir="$backend_dir/internal/agentbin/dist"
+
+mkdir -p "$dist_dir"
+rm -f "$dist_dir"/hytale-agent-linux-* "$dist_dir/SHA256SUMS"
+
+cd "$backend_dir"
+for arch in amd64 arm64; do
+  echo "Building hytale-agent-linux-$arch"
+  GOOS=linux GOARCH="$arch" CGO_ENABLED=0 go build -trimpath -ldflags="-s -w" \
+    -o "$dist_dir/hytale-agent-linux-$arch" ./agent
+done
+
+cd "$dist_dir"
+sha256sum hytale-agent-linux-* > SHA256SUMS
+cat SHA256SUMS
+echo "Now build the backend (go build ./cmd/server) to embed these binaries."